@@ -20,6 +20,14 @@ type Buffer struct {
 	pins        int
 	txnNum      int
 	lsn         int
+	// prefetched is true if the block was read ahead of a scan and has not been pinned since.
+	prefetched bool
+	// loading is true while the contents of a prefetched block are being read.
+	loading bool
+	// dirty is the index of the dirty buffers of the pool the buffer belongs to, or nil if it has none.
+	dirty *dirtyBuffers
+	// counters are the counters of the buffer manager of the pool, or nil if it has none, which count the writes of the buffer.
+	counters *counters
 }
 
 func NewBuffer(fileManager *file.Manager, logManager *log.Manager) *Buffer {
@@ -43,8 +51,10 @@ func (b *Buffer) Block() *file.BlockId {
 	return b.block
 }
 
+// SetModified records that the specified transaction modified the contents of the buffer,
+// generating the log record with the specified LSN.
 func (b *Buffer) SetModified(txnNum, lsn int) {
-	b.txnNum = txnNum
+	b.setModifyingTxn(txnNum)
 
 	// If LSN is smaller than 0, it indicates that a log record was not generated for this update.
 	if lsn >= 0 {
@@ -57,8 +67,22 @@ func (b *Buffer) isPinned() bool {
 	return b.pins > 0
 }
 
+// modifyingTxn returns the transaction that last modified the buffer, or -1 if it is clean.
 func (b *Buffer) modifyingTxn() int {
-	return b.txnNum
+	if b.dirty == nil {
+		return b.txnNum
+	}
+	return b.dirty.modifyingTxn(b)
+}
+
+// setModifyingTxn sets the transaction that last modified the buffer, keeping the pool's index
+// of dirty buffers up to date. A negative transaction number marks the buffer as clean.
+func (b *Buffer) setModifyingTxn(txnNum int) {
+	if b.dirty == nil {
+		b.txnNum = txnNum
+		return
+	}
+	b.dirty.setModifyingTxn(b, txnNum)
 }
 
 // assignToBlock reads the contents of the specified block into the contents of the buffer.
@@ -67,7 +91,9 @@ func (b *Buffer) assignToBlock(block *file.BlockId) error {
 	if err := b.flush(); err != nil {
 		return fmt.Errorf("failed to flush buffer for block %s: %v", b.block.String(), err)
 	}
-	b.block = block
+	// Keep a private copy so later changes to the caller's BlockId cannot retarget the buffer.
+	key := block.Key()
+	b.block = &key
 	if err := b.fileManager.Read(block, b.contents); err != nil {
 		return fmt.Errorf("failed to read block %s to buffer: %v", block.String(), err)
 	}
@@ -75,17 +101,38 @@ func (b *Buffer) assignToBlock(block *file.BlockId) error {
 	return nil
 }
 
+// reserve assigns the clean buffer to the specified block for a prefetch without reading
+// the block's contents, and pins the buffer until they have been read with load.
+func (b *Buffer) reserve(block *file.BlockId) {
+	key := block.Key()
+	b.block = &key
+	b.pins = 1
+	b.prefetched = true
+	b.loading = true
+}
+
+// load reads the contents of the block the buffer was reserved for.
+func (b *Buffer) load() error {
+	if err := b.fileManager.Read(b.block, b.contents); err != nil {
+		return fmt.Errorf("failed to read block %s to buffer: %v", b.block.String(), err)
+	}
+	return nil
+}
+
 // flush writes the buffer to its disk block if it is dirty. The method first writes the log record to the log file,
 // and then writes the contents of the buffer to disk.
 func (b *Buffer) flush() error {
-	if b.txnNum >= 0 {
+	if txnNum := b.modifyingTxn(); txnNum >= 0 {
 		if err := b.logManager.Flush(b.lsn); err != nil {
-			return fmt.Errorf("failed to flush log record for txn %d: %v", b.txnNum, err)
+			return fmt.Errorf("failed to flush log record for txn %d: %v", txnNum, err)
 		}
 		if err := b.fileManager.Write(b.block, b.contents); err != nil {
 			return fmt.Errorf("failed to write block: %v", err)
 		}
-		b.txnNum = -1
+		if b.counters != nil {
+			b.counters.writes.Add(1)
+		}
+		b.setModifyingTxn(-1)
 	}
 	return nil
 }
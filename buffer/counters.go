@@ -0,0 +1,32 @@
+package buffer
+
+import "sync/atomic"
+
+// counters count the activity of a buffer manager (see Stats). They are atomic, so that they are read
+// without taking the lock of the manager.
+type counters struct {
+	pins           atomic.Int64
+	unpins         atomic.Int64
+	hits           atomic.Int64
+	reads          atomic.Int64
+	writes         atomic.Int64
+	waits          atomic.Int64
+	evictions      atomic.Int64
+	prefetchIssued atomic.Int64
+	prefetchHits   atomic.Int64
+	prefetchWasted atomic.Int64
+}
+
+// load copies the counters into the specified stats.
+func (c *counters) load(stats *Stats) {
+	stats.Pins = int(c.pins.Load())
+	stats.Unpins = int(c.unpins.Load())
+	stats.Hits = int(c.hits.Load())
+	stats.Reads = int(c.reads.Load())
+	stats.Writes = int(c.writes.Load())
+	stats.Waits = int(c.waits.Load())
+	stats.Evictions = int(c.evictions.Load())
+	stats.PrefetchIssued = int(c.prefetchIssued.Load())
+	stats.PrefetchHits = int(c.prefetchHits.Load())
+	stats.PrefetchWasted = int(c.prefetchWasted.Load())
+}
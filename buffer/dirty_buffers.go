@@ -0,0 +1,83 @@
+package buffer
+
+import "sync"
+
+// dirtyBuffers indexes the dirty buffers of a pool by the transaction that last modified them,
+// so that the buffers of a transaction can be flushed without going through the whole pool.
+// Buffers are modified without holding the lock of the buffer manager, so the index has its
+// own lock, which also guards the modifying transaction of the buffers it indexes.
+type dirtyBuffers struct {
+	mu    sync.Mutex
+	byTxn map[int]map[*Buffer]struct{}
+}
+
+func newDirtyBuffers() *dirtyBuffers {
+	return &dirtyBuffers{byTxn: make(map[int]map[*Buffer]struct{})}
+}
+
+// setModifyingTxn records that the buffer was last modified by the specified transaction,
+// or that it is clean if the transaction number is negative. A buffer re-modified by
+// another transaction moves from the buffers of the previous one to those of the new one.
+func (d *dirtyBuffers) setModifyingTxn(b *Buffer, txnNum int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if b.txnNum >= 0 {
+		delete(d.byTxn[b.txnNum], b)
+		if len(d.byTxn[b.txnNum]) == 0 {
+			delete(d.byTxn, b.txnNum)
+		}
+	}
+	b.txnNum = txnNum
+	if txnNum >= 0 {
+		if d.byTxn[txnNum] == nil {
+			d.byTxn[txnNum] = make(map[*Buffer]struct{})
+		}
+		d.byTxn[txnNum][b] = struct{}{}
+	}
+}
+
+// modifyingTxn returns the transaction that last modified the buffer, or -1 if it is clean.
+func (d *dirtyBuffers) modifyingTxn(b *Buffer) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return b.txnNum
+}
+
+// buffersOf returns the dirty buffers last modified by the specified transaction.
+func (d *dirtyBuffers) buffersOf(txnNum int) []*Buffer {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	buffers := make([]*Buffer, 0, len(d.byTxn[txnNum]))
+	for b := range d.byTxn[txnNum] {
+		buffers = append(buffers, b)
+	}
+	return buffers
+}
+
+// all returns every dirty buffer.
+func (d *dirtyBuffers) all() []*Buffer {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var buffers []*Buffer
+	for _, txnBuffers := range d.byTxn {
+		for b := range txnBuffers {
+			buffers = append(buffers, b)
+		}
+	}
+	return buffers
+}
+
+// count returns the number of dirty buffers.
+func (d *dirtyBuffers) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := 0
+	for _, txnBuffers := range d.byTxn {
+		n += len(txnBuffers)
+	}
+	return n
+}
@@ -0,0 +1,69 @@
+package buffer
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRUStrategy is a buffer replacement strategy that selects the unpinned buffer that was unpinned the longest time ago,
+// so that blocks that are pinned again and again, such as the blocks of the catalog, stay in the pool while
+// the blocks read once by a scan are replaced.
+type LRUStrategy struct {
+	ReplacementStrategy
+	// unpinned holds the unpinned buffers, from the least to the most recently unpinned.
+	unpinned *list.List
+	// elements maps the unpinned buffers to their element in unpinned.
+	elements map[*Buffer]*list.Element
+	mu       sync.Mutex
+}
+
+// NewLRUStrategy creates a new LRUStrategy.
+func NewLRUStrategy() *LRUStrategy {
+	return &LRUStrategy{}
+}
+
+// initialize initializes the strategy with the buffer pool, whose buffers are all unpinned.
+func (ls *LRUStrategy) initialize(buffers []*Buffer) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.unpinned = list.New()
+	ls.elements = make(map[*Buffer]*list.Element, len(buffers))
+	for _, buff := range buffers {
+		ls.elements[buff] = ls.unpinned.PushBack(buff)
+	}
+}
+
+// pinBuffer notifies the strategy that a buffer has been pinned, so that it cannot be chosen until it is unpinned.
+func (ls *LRUStrategy) pinBuffer(buff *Buffer) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if element, ok := ls.elements[buff]; ok {
+		ls.unpinned.Remove(element)
+		delete(ls.elements, buff)
+	}
+}
+
+// unpinBuffer notifies the strategy that a buffer has been unpinned. Once it is no longer pinned at all,
+// it becomes the most recently used of the unpinned buffers.
+func (ls *LRUStrategy) unpinBuffer(buff *Buffer) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if buff.isPinned() {
+		return
+	}
+	if element, ok := ls.elements[buff]; ok {
+		ls.unpinned.MoveToBack(element)
+		return
+	}
+	ls.elements[buff] = ls.unpinned.PushBack(buff)
+}
+
+// chooseUnpinnedBuffer selects the least recently used unpinned buffer.
+func (ls *LRUStrategy) chooseUnpinnedBuffer() *Buffer {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if front := ls.unpinned.Front(); front != nil {
+		return front.Value.(*Buffer)
+	}
+	return nil
+}
@@ -17,30 +17,98 @@ const maxWaitTime = 10 * time.Second
 // It maintains a pool of buffers and uses a replacement strategy to choose which buffer to replace when a new block
 // needs to be pinned.
 type Manager struct {
-	bufferPool   []*Buffer
-	numAvailable int
-	mu           sync.Mutex
-	cond         *sync.Cond
-	strategy     ReplacementStrategy
+	fileManager   *file.Manager
+	bufferPool    []*Buffer
+	numAvailable  int
+	mu            sync.Mutex
+	cond          *sync.Cond
+	strategy      ReplacementStrategy
+	prefetchDepth int
+	prefetches    sync.WaitGroup
+	counters      counters
+	// waiters is the number of goroutines waiting in Pin for a buffer to become available.
+	waiters int
+	// pressureHooks are called when the fraction of pinned buffers crosses their threshold.
+	pressureHooks []*pressureHook
+	// dirty indexes the dirty buffers of the pool by modifying transaction.
+	dirty *dirtyBuffers
+	// buffers indexes the buffers of the pool assigned to a block by the block's key.
+	buffers map[file.BlockId]*Buffer
+}
+
+// Stats describes the state of the buffer pool and the activity of the buffer manager.
+// The counters of the activity are atomic, and are read without taking the lock of the manager,
+// so a snapshot returned by Stats may count a pin that the state of the pool does not reflect yet.
+type Stats struct {
+	// Buffers is the number of buffers of the pool.
+	Buffers int
+	// Pinned is the number of pinned buffers.
+	Pinned int
+	// Dirty is the number of buffers holding modifications that are not written to disk yet.
+	Dirty int
+	// Waiters is the number of pins waiting for a buffer to become available.
+	Waiters int
+	// Pins is the number of pins of a block.
+	Pins int
+	// Unpins is the number of unpins of a buffer.
+	Unpins int
+	// Hits is the number of pins whose block was in the pool.
+	Hits int
+	// Reads is the number of blocks read from disk to satisfy a pin, that is the number of pins that missed the pool.
+	Reads int
+	// Writes is the number of dirty buffers written to disk, when flushed or replaced.
+	Writes int
+	// Waits is the number of pins that waited for a buffer to become available.
+	Waits int
+	// Evictions is the number of blocks replaced in the pool by another block.
+	Evictions int
+	// PrefetchIssued is the number of blocks read ahead of a sequential scan.
+	PrefetchIssued int
+	// PrefetchHits is the number of prefetched blocks that were pinned before being replaced.
+	PrefetchHits int
+	// PrefetchWasted is the number of prefetched blocks that were replaced without being pinned.
+	PrefetchWasted int
+}
+
+// HitRatio returns the fraction of pins whose block was in the pool, or 0 if nothing was pinned.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Reads == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Reads)
+}
+
+// PinnedFraction returns the fraction of the buffers of the pool that are pinned.
+func (s Stats) PinnedFraction() float64 {
+	if s.Buffers == 0 {
+		return 0
+	}
+	return float64(s.Pinned) / float64(s.Buffers)
 }
 
 // NewManager creates a buffer manager having the specified number of buffer slots.
-// It depends on a file.Manager and log.Manager instance. Uses the Naive replacement strategy by default.
+// It depends on a file.Manager and log.Manager instance. Uses the LRU replacement strategy by default;
+// NewManagerWithReplacementStrategy chooses another one, such as the Naive strategy.
 func NewManager(fileManager *file.Manager, logManager *log.Manager, numBuffers int) *Manager {
-	return NewManagerWithReplacementStrategy(fileManager, logManager, numBuffers, NewNaiveStrategy())
+	return NewManagerWithReplacementStrategy(fileManager, logManager, numBuffers, NewLRUStrategy())
 }
 
 // NewManagerWithReplacementStrategy creates a buffer manager with a given replacement strategy having the specified number of buffer slots.
 // It depends on a file.Manager and log.Manager instance.
 func NewManagerWithReplacementStrategy(fileManager *file.Manager, logManager *log.Manager, numBuffers int, strategy ReplacementStrategy) *Manager {
 	bm := &Manager{
+		fileManager:  fileManager,
 		bufferPool:   make([]*Buffer, numBuffers),
+		buffers:      make(map[file.BlockId]*Buffer, numBuffers),
 		numAvailable: numBuffers,
 		strategy:     strategy,
+		dirty:        newDirtyBuffers(),
 	}
 	bm.cond = sync.NewCond(&bm.mu)
 	for i := 0; i < numBuffers; i++ {
 		bm.bufferPool[i] = NewBuffer(fileManager, logManager)
+		bm.bufferPool[i].dirty = bm.dirty
+		bm.bufferPool[i].counters = &bm.counters
 	}
 	// initialize the strategy with the buffer pool
 	strategy.initialize(bm.bufferPool)
@@ -54,21 +122,127 @@ func (m *Manager) Available() int {
 	return m.numAvailable
 }
 
+// Stats returns a snapshot of the state of the pool and of the buffer manager's counters.
+// Only the state of the pool is read under the lock of the manager.
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	stats := m.poolState()
+	m.mu.Unlock()
+	m.counters.load(&stats)
+	return stats
+}
+
+// snapshot returns the stats of the manager. This method is not thread-safe.
+func (m *Manager) snapshot() Stats {
+	stats := m.poolState()
+	m.counters.load(&stats)
+	return stats
+}
+
+// poolState returns the stats describing the state of the pool, without the counters of the manager.
+// This method is not thread-safe.
+func (m *Manager) poolState() Stats {
+	return Stats{
+		Buffers: len(m.bufferPool),
+		Pinned:  len(m.bufferPool) - m.numAvailable,
+		Dirty:   m.dirty.count(),
+		Waiters: m.waiters,
+	}
+}
+
+// SetPrefetchDepth sets the number of blocks that PinSequential reads ahead of the pinned block.
+// A depth of 0, the default, disables prefetching.
+func (m *Manager) SetPrefetchDepth(depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prefetchDepth = max(depth, 0)
+}
+
+// WaitForPrefetch blocks until every prefetch started by PinSequential has completed.
+func (m *Manager) WaitForPrefetch() {
+	m.prefetches.Wait()
+}
+
 // FlushAll flushes the dirty buffers modified by the specified transaction.
+// Only the buffers the transaction modified are visited, whatever the size of the pool.
+// A buffer re-modified by another transaction since belongs to that transaction instead.
 func (m *Manager) FlushAll(txnNum int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, buff := range m.bufferPool {
-		if buff.modifyingTxn() == txnNum {
-			if err := buff.flush(); err != nil {
-				return fmt.Errorf("failed to flush buffer for txn %d: %v", txnNum, err)
-			}
+	for _, buff := range m.dirty.buffersOf(txnNum) {
+		if buff.modifyingTxn() != txnNum {
+			continue
+		}
+		if err := buff.flush(); err != nil {
+			return fmt.Errorf("failed to flush buffer for txn %d: %v", txnNum, err)
 		}
 	}
 	return nil
 }
 
+// FlushAllDirty flushes every dirty buffer, whichever transaction modified it,
+// for example to make the database files consistent for a checkpoint or a backup.
+func (m *Manager) FlushAllDirty() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, buff := range m.dirty.all() {
+		if err := buff.flush(); err != nil {
+			return fmt.Errorf("failed to flush buffer for block %s: %v", buff.Block(), err)
+		}
+	}
+	return nil
+}
+
+// FlushBlock writes the specified block to disk if its buffer is dirty,
+// after the log records of the modifications it holds.
+// A block that is not in the pool is already on disk, so nothing is written.
+func (m *Manager) FlushBlock(block *file.BlockId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buff := m.findExistingBuffer(block)
+	if buff == nil || buff.loading {
+		return nil
+	}
+	if err := buff.flush(); err != nil {
+		return fmt.Errorf("failed to flush block %s: %v", block, err)
+	}
+	return nil
+}
+
+// DiscardFile forgets the blocks of the specified file held by the unpinned buffers of the pool,
+// without writing them to disk, so that the file can be removed, and a file later created with the
+// same name is read from disk rather than from the pool.
+func (m *Manager) DiscardFile(filename string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, buff := range m.bufferPool {
+		if block := buff.Block(); block == nil || block.Filename() != filename || buff.isPinned() {
+			continue
+		}
+		buff.setModifyingTxn(-1)
+		m.unassign(buff)
+		buff.prefetched = false
+	}
+}
+
+// HasPinnedBlocks returns true if a buffer of the pool holding a block of the specified file is pinned,
+// in which case the file cannot be removed (see DiscardFile).
+func (m *Manager) HasPinnedBlocks(filename string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, buff := range m.bufferPool {
+		if block := buff.Block(); block != nil && block.Filename() == filename && buff.isPinned() {
+			return true
+		}
+	}
+	return false
+}
+
 // Unpin unpins the specified buffer. If its pin count goes to zero, it increases the number
 // of available buffers and notifies any waiting goroutines.
 func (m *Manager) Unpin(buffer *Buffer) {
@@ -77,9 +251,11 @@ func (m *Manager) Unpin(buffer *Buffer) {
 
 	buffer.unpin()
 	m.strategy.unpinBuffer(buffer)
+	m.counters.unpins.Add(1)
 	if !buffer.isPinned() {
 		m.numAvailable++
 		m.cond.Broadcast()
+		m.checkPressure()
 	}
 }
 
@@ -127,14 +303,19 @@ func (m *Manager) Pin(block *file.BlockId) (*Buffer, error) {
 	// either the context is done and f has been started in its own goroutine; or f was already stopped.
 	defer stop()
 
-	for {
+	for waited := false; ; waited = true {
 		if buff, err := m.tryToPin(block); err != nil {
 			return nil, err
 		} else if buff != nil { // buffer was pinned, return it to the caller.
 			return buff, nil
 		}
 
+		if !waited {
+			m.counters.waits.Add(1)
+		}
+		m.waiters++
 		m.cond.Wait()
+		m.waiters--
 
 		if ctx.Err() != nil {
 			// Check if the wait timed out, if yes, return a buffer abort exception to the caller. At this stage,
@@ -147,37 +328,203 @@ func (m *Manager) Pin(block *file.BlockId) (*Buffer, error) {
 	}
 }
 
+// PinSequential pins a buffer to the specified block like Pin, as part of a scan that
+// moves forward through the file one block at a time. If prefetching is enabled, it
+// then asynchronously reads the following blocks of the file into free buffers, so that
+// the scan finds them in memory.
+func (m *Manager) PinSequential(block *file.BlockId) (*Buffer, error) {
+	buff, err := m.Pin(block)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if reserved := m.reservePrefetch(block); len(reserved) > 0 {
+		m.prefetches.Add(1)
+		go m.prefetch(reserved)
+	}
+	return buff, nil
+}
+
+// reservePrefetch assigns free buffers to the blocks following the specified block, up to the
+// prefetch depth, that are not in the pool yet. A buffer is free if it is neither pinned nor dirty,
+// so prefetching never evicts a buffer that is in use or needs to be written. If there are fewer
+// free buffers than the depth, nothing is reserved, so that prefetching cannot crowd out the buffers
+// other transactions need. The reserved buffers stay pinned until prefetch has read their contents,
+// and a transaction pinning one of their blocks meanwhile waits for the read instead of repeating it.
+// This method is not thread-safe.
+func (m *Manager) reservePrefetch(block *file.BlockId) []*Buffer {
+	if m.prefetchDepth == 0 {
+		return nil
+	}
+	// Prefetching is only a hint, so it is skipped if the file size is unknown.
+	size, err := m.fileManager.Length(block.Filename())
+	if err != nil {
+		return nil
+	}
+
+	var targets []file.BlockId
+	for n := block.Number() + 1; n <= block.Number()+m.prefetchDepth && n < size; n++ {
+		target := file.BlockId{File: block.Filename(), BlockNumber: n}
+		if m.findExistingBuffer(&target) == nil {
+			targets = append(targets, target)
+		}
+	}
+
+	free := m.freeBuffers()
+	if len(targets) == 0 || len(free) < m.prefetchDepth {
+		return nil
+	}
+
+	reserved := free[:len(targets)]
+	for i, buff := range reserved {
+		if buff.prefetched {
+			m.counters.prefetchWasted.Add(1)
+		}
+		if buff.Block() != nil {
+			m.counters.evictions.Add(1)
+		}
+		previous := buff.Block()
+		buff.reserve(&targets[i])
+		m.reindex(buff, previous)
+		m.numAvailable--
+		m.strategy.pinBuffer(buff)
+		m.counters.prefetchIssued.Add(1)
+	}
+	m.checkPressure()
+	return reserved
+}
+
+// prefetch reads the contents of the reserved buffers, unpinning each one once it is read.
+// Prefetched buffers stay unpinned and clean until they are pinned, so they are cheap to replace if they go unused.
+func (m *Manager) prefetch(buffers []*Buffer) {
+	defer m.prefetches.Done()
+
+	for _, buff := range buffers {
+		err := buff.load()
+
+		m.mu.Lock()
+		if err != nil {
+			// The buffer does not hold the block's contents, so make it look unassigned.
+			m.unassign(buff)
+			buff.prefetched = false
+		}
+		buff.loading = false
+		buff.unpin()
+		m.strategy.unpinBuffer(buff)
+		m.numAvailable++
+		m.cond.Broadcast()
+		m.checkPressure()
+		m.mu.Unlock()
+	}
+}
+
+// freeBuffers returns the buffers that are neither pinned nor dirty, preferring buffers
+// that hold no block, then buffers caching a block that has been used, over buffers
+// holding prefetched blocks that a scan has yet to reach. This method is not thread-safe.
+func (m *Manager) freeBuffers() []*Buffer {
+	var unassigned, prefetched, cached []*Buffer
+	for _, buff := range m.bufferPool {
+		if buff.isPinned() || buff.modifyingTxn() >= 0 {
+			continue
+		}
+		switch {
+		case buff.Block() == nil:
+			unassigned = append(unassigned, buff)
+		case buff.prefetched:
+			prefetched = append(prefetched, buff)
+		default:
+			cached = append(cached, buff)
+		}
+	}
+	return append(append(unassigned, cached...), prefetched...)
+}
+
 // tryToPin tries to pin a buffer to the specified block.
 // If there is already a buffer assigned to that block, it uses that buffer.
 // Otherwise, it chooses an unpinned buffer from the pool.
-// Returns nil if there are no available buffers.
+// Returns nil if there are no available buffers, or if the block is still being prefetched.
 // This method is not thread-safe.
 func (m *Manager) tryToPin(block *file.BlockId) (*Buffer, error) {
 	buffer := m.findExistingBuffer(block)
+	if buffer != nil && buffer.loading {
+		// The block is being prefetched, so wait for its contents rather than reading it again.
+		return nil, nil
+	}
 	if buffer == nil {
 		buffer = m.strategy.chooseUnpinnedBuffer()
 		if buffer == nil {
 			return nil, nil
 		}
-		if err := buffer.assignToBlock(block); err != nil {
+		// Keep the blocks prefetched for a scan while there are other buffers to replace.
+		if buffer.prefetched {
+			buffer = m.replaceableBuffer(buffer)
+		}
+		if buffer.prefetched {
+			m.counters.prefetchWasted.Add(1)
+			buffer.prefetched = false
+		}
+		if buffer.Block() != nil {
+			m.counters.evictions.Add(1)
+		}
+		previous := buffer.Block()
+		err := buffer.assignToBlock(block)
+		m.reindex(buffer, previous)
+		if err != nil {
 			return nil, err
 		}
+		m.counters.reads.Add(1)
+	} else {
+		m.counters.hits.Add(1)
+		if buffer.prefetched {
+			m.counters.prefetchHits.Add(1)
+			buffer.prefetched = false
+		}
 	}
 	if !buffer.isPinned() {
 		m.numAvailable--
+		defer m.checkPressure()
 	}
 	buffer.pin()
 	m.strategy.pinBuffer(buffer)
+	m.counters.pins.Add(1)
 	return buffer, nil
 }
 
-// findExistingBuffer searches for a buffer assigned to the specified block.
-func (m *Manager) findExistingBuffer(block *file.BlockId) *Buffer {
+// replaceableBuffer returns an unpinned buffer that does not hold a prefetched block,
+// or the specified buffer if there is none. This method is not thread-safe.
+func (m *Manager) replaceableBuffer(choice *Buffer) *Buffer {
 	for _, buffer := range m.bufferPool {
-		b := buffer.Block()
-		if b != nil && b.Equals(block) {
+		if !buffer.isPinned() && !buffer.prefetched {
 			return buffer
 		}
 	}
-	return nil
+	return choice
+}
+
+// findExistingBuffer returns the buffer assigned to the specified block, or nil if there is none.
+// This method is not thread-safe.
+func (m *Manager) findExistingBuffer(block *file.BlockId) *Buffer {
+	return m.buffers[block.Key()]
+}
+
+// reindex updates the index of the buffers by block after the buffer was assigned to another block,
+// or to none, having previously been assigned to the specified block. This method is not thread-safe.
+func (m *Manager) reindex(buffer *Buffer, previous *file.BlockId) {
+	if previous != nil && m.buffers[previous.Key()] == buffer {
+		delete(m.buffers, previous.Key())
+	}
+	if block := buffer.Block(); block != nil {
+		m.buffers[block.Key()] = buffer
+	}
+}
+
+// unassign makes the buffer look unassigned, removing it from the index of the buffers by block.
+// This method is not thread-safe.
+func (m *Manager) unassign(buffer *Buffer) {
+	previous := buffer.Block()
+	buffer.block = nil
+	m.reindex(buffer, previous)
 }
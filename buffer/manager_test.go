@@ -199,4 +199,480 @@ func TestConcurrentBufferAccess(t *testing.T) {
 	wg.Wait()
 
 	assert.Equal(t, 2, env.bm.Available(), "all buffers should be available after completion")
+	stats := env.bm.Stats()
+	assert.Equal(t, 3, stats.Pins)
+	assert.Equal(t, 3, stats.Unpins)
+	assert.Equal(t, 1, stats.Waits, "the third pin waited")
+}
+
+func TestBufferKeepsOwnBlockId(t *testing.T) {
+	env := setupTest(t, 3)
+	defer env.cleanup()
+
+	blk := createBlock("testfile", 1)
+	buff, err := env.bm.Pin(&blk)
+	require.NoError(t, err)
+
+	// Changing the caller's BlockId must not retarget the pinned buffer.
+	blk.BlockNumber = 2
+	assert.Equal(t, 1, buff.Block().Number())
+
+	// A separately constructed BlockId for the same block finds the existing buffer.
+	same, err := env.bm.Pin(file.NewBlockId("testfile", 1))
+	require.NoError(t, err)
+	assert.Same(t, buff, same)
+	assert.Equal(t, 2, env.bm.Available())
+
+	env.bm.Unpin(same)
+	env.bm.Unpin(buff)
+	assert.Equal(t, 3, env.bm.Available())
+}
+
+func TestBufferManagerPrefetch(t *testing.T) {
+	// appendBlocks creates a file with the specified number of blocks.
+	appendBlocks := func(t *testing.T, env *testEnv, fileName string, count int) {
+		for i := 0; i < count; i++ {
+			_, err := env.fm.Append(fileName)
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("sequential pins hit prefetched blocks", func(t *testing.T) {
+		env := setupTest(t, 8)
+		defer env.cleanup()
+		appendBlocks(t, env, "seqfile", 10)
+		env.bm.SetPrefetchDepth(3)
+
+		first := createBlock("seqfile", 0)
+		buff, err := env.bm.PinSequential(&first)
+		require.NoError(t, err)
+		env.bm.WaitForPrefetch()
+		assert.Equal(t, Stats{Buffers: 8, Pinned: 1, Pins: 1, Reads: 1, PrefetchIssued: 3}, env.bm.Stats())
+		assert.Equal(t, 7, env.bm.Available(), "prefetched buffers stay unpinned")
+		env.bm.Unpin(buff)
+
+		second := createBlock("seqfile", 1)
+		buff, err = env.bm.PinSequential(&second)
+		require.NoError(t, err)
+		env.bm.WaitForPrefetch()
+		env.bm.Unpin(buff)
+
+		// Block 1 was a hit, and only block 4 had to be read ahead.
+		assert.Equal(t, Stats{Buffers: 8, Pins: 2, Unpins: 2, Hits: 1, Reads: 1, PrefetchIssued: 4, PrefetchHits: 1}, env.bm.Stats())
+	})
+
+	t.Run("prefetch does not read past the end of the file", func(t *testing.T) {
+		env := setupTest(t, 8)
+		defer env.cleanup()
+		appendBlocks(t, env, "seqfile", 2)
+		env.bm.SetPrefetchDepth(3)
+
+		blk := createBlock("seqfile", 0)
+		buff, err := env.bm.PinSequential(&blk)
+		require.NoError(t, err)
+		env.bm.WaitForPrefetch()
+		env.bm.Unpin(buff)
+
+		assert.Equal(t, Stats{Buffers: 8, Pins: 1, Unpins: 1, Reads: 1, PrefetchIssued: 1}, env.bm.Stats())
+	})
+
+	t.Run("prefetch is skipped without enough free buffers", func(t *testing.T) {
+		env := setupTest(t, 4)
+		defer env.cleanup()
+		appendBlocks(t, env, "seqfile", 10)
+		env.bm.SetPrefetchDepth(3)
+
+		other := createBlock("otherfile", 0)
+		pinned, err := env.bm.Pin(&other)
+		require.NoError(t, err)
+
+		blk := createBlock("seqfile", 0)
+		buff, err := env.bm.PinSequential(&blk)
+		require.NoError(t, err)
+		env.bm.WaitForPrefetch()
+
+		assert.Equal(t, Stats{Buffers: 4, Pinned: 2, Pins: 2, Reads: 2}, env.bm.Stats())
+		env.bm.Unpin(buff)
+		env.bm.Unpin(pinned)
+	})
+
+	t.Run("unused prefetched blocks are counted as wasted when replaced", func(t *testing.T) {
+		env := setupTest(t, 4)
+		defer env.cleanup()
+		appendBlocks(t, env, "seqfile", 10)
+		env.bm.SetPrefetchDepth(3)
+
+		blk := createBlock("seqfile", 0)
+		buff, err := env.bm.PinSequential(&blk)
+		require.NoError(t, err)
+		env.bm.WaitForPrefetch()
+		env.bm.Unpin(buff)
+
+		// Pinning other blocks replaces the prefetched buffers before the scan uses them.
+		var others []*Buffer
+		for i := 0; i < 4; i++ {
+			other := createBlock("otherfile", i)
+			buff, err := env.bm.Pin(&other)
+			require.NoError(t, err)
+			others = append(others, buff)
+		}
+		for _, buff := range others {
+			env.bm.Unpin(buff)
+		}
+
+		stats := env.bm.Stats()
+		assert.Equal(t, 3, stats.PrefetchIssued)
+		assert.Equal(t, 3, stats.PrefetchWasted)
+		assert.Equal(t, 0, stats.PrefetchHits)
+	})
+}
+
+// dirtyBlock pins the specified block, writes a value into its buffer as the specified transaction, and unpins it.
+func dirtyBlock(t *testing.T, bm *Manager, block file.BlockId, txnNum, value int) {
+	t.Helper()
+	buff, err := bm.Pin(&block)
+	require.NoError(t, err)
+	buff.Contents().SetInt(0, value)
+	buff.SetModified(txnNum, -1)
+	bm.Unpin(buff)
+}
+
+func TestBufferManager_FlushAllOnlyFlushesTheTransactionsBuffers(t *testing.T) {
+	env := setupTest(t, 1000)
+	defer env.cleanup()
+
+	// Fill most of the pool, modifying a few blocks as other transactions and three as transaction 7.
+	// The buffers stay pinned until all are assigned, so that none is replaced.
+	var pinned []*Buffer
+	for i := 0; i < 903; i++ {
+		blk := createBlock("testfile", i)
+		buff, err := env.bm.Pin(&blk)
+		require.NoError(t, err)
+		switch {
+		case i >= 900:
+			buff.SetModified(7, -1)
+		case i%100 == 0:
+			buff.SetModified(100+i, -1)
+		}
+		pinned = append(pinned, buff)
+	}
+	for _, buff := range pinned {
+		env.bm.Unpin(buff)
+	}
+
+	written := env.fm.GetBlocksWritten()
+	require.NoError(t, env.bm.FlushAll(7))
+	assert.Equal(t, 3, env.fm.GetBlocksWritten()-written, "only the blocks modified by the transaction are written")
+
+	written = env.fm.GetBlocksWritten()
+	require.NoError(t, env.bm.FlushAll(7))
+	assert.Zero(t, env.fm.GetBlocksWritten()-written, "flushed buffers are clean")
+
+	written = env.fm.GetBlocksWritten()
+	require.NoError(t, env.bm.FlushAllDirty())
+	assert.Equal(t, 9, env.fm.GetBlocksWritten()-written, "the blocks modified by the other transactions are written")
+}
+
+func TestBufferManager_FlushAllAfterRemodification(t *testing.T) {
+	env := setupTest(t, 2)
+	defer env.cleanup()
+
+	blk := createBlock("testfile", 1)
+	flushes := func(txnNum int) int {
+		written := env.fm.GetBlocksWritten()
+		require.NoError(t, env.bm.FlushAll(txnNum))
+		return env.fm.GetBlocksWritten() - written
+	}
+
+	// Re-modified by another transaction after a flush.
+	dirtyBlock(t, env.bm, blk, 1, 10)
+	assert.Equal(t, 1, flushes(1))
+	dirtyBlock(t, env.bm, blk, 2, 20)
+	assert.Equal(t, 0, flushes(1))
+	assert.Equal(t, 1, flushes(2))
+
+	// Re-modified by another transaction before being flushed.
+	dirtyBlock(t, env.bm, blk, 3, 30)
+	dirtyBlock(t, env.bm, blk, 4, 40)
+	assert.Equal(t, 0, flushes(3))
+	assert.Equal(t, 1, flushes(4))
+
+	// Flushed when it is replaced.
+	dirtyBlock(t, env.bm, blk, 5, 50)
+	for i := 2; i <= 3; i++ {
+		other := createBlock("testfile", i)
+		buff, err := env.bm.Pin(&other)
+		require.NoError(t, err)
+		env.bm.Unpin(buff)
+	}
+	assert.Equal(t, 0, flushes(5), "the buffer was flushed when it was replaced")
+
+	page := file.NewPage(env.fm.BlockSize())
+	require.NoError(t, env.fm.Read(&blk, page))
+	assert.Equal(t, 50, page.GetInt(0))
+}
+
+func TestBufferManager_FlushBlock(t *testing.T) {
+	env := setupTest(t, 3)
+	defer env.cleanup()
+
+	blk := createBlock("testfile", 2)
+	buff, err := env.bm.Pin(&blk)
+	require.NoError(t, err)
+	defer env.bm.Unpin(buff)
+	buff.Contents().SetInt(0, 12345)
+	buff.SetModified(1, -1)
+
+	// Read the file directly, bypassing the buffer pool.
+	readFromDisk := func() int {
+		contents, err := os.ReadFile(filepath.Join(os.TempDir(), "testdb", "testfile"))
+		require.NoError(t, err)
+		page := file.NewPageFromBytes(contents[2*env.fm.BlockSize() : 3*env.fm.BlockSize()])
+		return page.GetInt(0)
+	}
+
+	require.NoError(t, env.bm.FlushBlock(&blk))
+	assert.Equal(t, 12345, readFromDisk())
+	assert.Equal(t, -1, buff.modifyingTxn(), "the flushed buffer is clean")
+
+	written := env.fm.GetBlocksWritten()
+	require.NoError(t, env.bm.FlushBlock(&blk))
+	uncached := createBlock("testfile", 9)
+	require.NoError(t, env.bm.FlushBlock(&uncached))
+	assert.Equal(t, written, env.fm.GetBlocksWritten(), "clean and uncached blocks are not written")
+}
+
+func TestBufferManager_DiscardFile(t *testing.T) {
+	env := setupTest(t, 3)
+	defer env.cleanup()
+
+	pinned := createBlock("discarded", 1)
+	pinnedBuff, err := env.bm.Pin(&pinned)
+	require.NoError(t, err)
+	defer env.bm.Unpin(pinnedBuff)
+
+	discarded := createBlock("discarded", 0)
+	buff, err := env.bm.Pin(&discarded)
+	require.NoError(t, err)
+	buff.Contents().SetInt(0, 12345)
+	buff.SetModified(1, -1)
+	env.bm.Unpin(buff)
+
+	assert.True(t, env.bm.HasPinnedBlocks("discarded"))
+	assert.False(t, env.bm.HasPinnedBlocks("other"))
+	env.bm.DiscardFile("discarded")
+	assert.Nil(t, buff.Block(), "the unpinned block is forgotten")
+	assert.Equal(t, -1, buff.modifyingTxn(), "its modifications are dropped")
+	assert.Equal(t, 0, env.bm.Stats().Dirty)
+	assert.True(t, pinnedBuff.Block().Equals(&pinned), "a pinned block is kept")
+
+	// The block is read again from disk.
+	reads := env.bm.Stats().Reads
+	buff, err = env.bm.Pin(&discarded)
+	require.NoError(t, err)
+	defer env.bm.Unpin(buff)
+	assert.Equal(t, reads+1, env.bm.Stats().Reads)
+}
+
+func TestBufferManager_Stats(t *testing.T) {
+	env := setupTest(t, 3)
+	defer env.cleanup()
+
+	pin := func(blockNum int) *Buffer {
+		blk := createBlock("testfile", blockNum)
+		buff, err := env.bm.Pin(&blk)
+		require.NoError(t, err)
+		return buff
+	}
+
+	assert.Equal(t, Stats{Buffers: 3}, env.bm.Stats())
+	assert.Equal(t, 0.0, env.bm.Stats().HitRatio())
+
+	first := pin(1)
+	again := pin(1)
+	assert.Equal(t, Stats{Buffers: 3, Pinned: 1, Pins: 2, Hits: 1, Reads: 1}, env.bm.Stats(), "pinning a block twice pins one buffer")
+	assert.Equal(t, 0.5, env.bm.Stats().HitRatio())
+
+	second := pin(2)
+	third := pin(3)
+	third.SetModified(1, -1)
+	assert.Equal(t, Stats{Buffers: 3, Pinned: 3, Dirty: 1, Pins: 4, Hits: 1, Reads: 3}, env.bm.Stats())
+	assert.Equal(t, 1.0, env.bm.Stats().PinnedFraction())
+
+	// A pin waits while every buffer is pinned.
+	pinned := make(chan *Buffer)
+	go func() {
+		blk := createBlock("testfile", 4)
+		buff, err := env.bm.Pin(&blk)
+		assert.NoError(t, err)
+		pinned <- buff
+	}()
+	assert.Eventually(t, func() bool { return env.bm.Stats().Waiters == 1 }, time.Second, time.Millisecond)
+
+	// Unpinning the block twice frees its buffer, which the waiting pin replaces.
+	env.bm.Unpin(first)
+	env.bm.Unpin(again)
+	fourth := <-pinned
+	assert.Equal(t, Stats{Buffers: 3, Pinned: 3, Dirty: 1, Pins: 5, Unpins: 2, Hits: 1, Reads: 4, Waits: 1, Evictions: 1}, env.bm.Stats())
+
+	for _, buff := range []*Buffer{second, third, fourth} {
+		env.bm.Unpin(buff)
+	}
+	require.NoError(t, env.bm.FlushAll(1))
+	assert.Equal(t, Stats{Buffers: 3, Pins: 5, Unpins: 5, Hits: 1, Reads: 4, Writes: 1, Waits: 1, Evictions: 1}, env.bm.Stats())
+}
+
+func TestBufferManager_StatsDuringPins(t *testing.T) {
+	env := setupTest(t, 4)
+	defer env.cleanup()
+
+	const workers, pinsPerWorker = 4, 200
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < pinsPerWorker; i++ {
+				blk := createBlock("testfile", worker)
+				buff, err := env.bm.Pin(&blk)
+				if !assert.NoError(t, err) {
+					return
+				}
+				env.bm.Unpin(buff)
+			}
+		}()
+	}
+
+	// The counters are read while the pins go on, and never go backwards.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	var last Stats
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		stats := env.bm.Stats()
+		assert.GreaterOrEqual(t, stats.Pins, last.Pins)
+		assert.GreaterOrEqual(t, stats.Unpins, last.Unpins)
+		last = stats
+	}
+
+	stats := env.bm.Stats()
+	assert.Equal(t, workers*pinsPerWorker, stats.Pins)
+	assert.Equal(t, workers*pinsPerWorker, stats.Unpins)
+	assert.Equal(t, stats.Pins, stats.Hits+stats.Reads)
+	assert.Zero(t, stats.Pinned)
+}
+
+func TestBufferManager_PressureHook(t *testing.T) {
+	interval := pressureHookInterval
+	pressureHookInterval = 0
+	defer func() { pressureHookInterval = interval }()
+
+	env := setupTest(t, 4)
+	defer env.cleanup()
+
+	calls := make(chan Stats, 10)
+	require.Error(t, env.bm.RegisterPressureHook(0, func(Stats) {}))
+	require.NoError(t, env.bm.RegisterPressureHook(0.5, func(stats Stats) { calls <- stats }))
+
+	// expectCalls checks that the hook was called the specified number of times since it was last checked.
+	expectCalls := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case stats := <-calls:
+				assert.GreaterOrEqual(t, stats.PinnedFraction(), 0.5)
+			case <-time.After(time.Second):
+				t.Fatalf("the hook was called %d times, not %d", i, n)
+			}
+		}
+		select {
+		case <-calls:
+			t.Fatalf("the hook was called more than %d times", n)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	var buffers []*Buffer
+	pin := func(blockNum int) {
+		blk := createBlock("testfile", blockNum)
+		buff, err := env.bm.Pin(&blk)
+		require.NoError(t, err)
+		buffers = append(buffers, buff)
+	}
+	unpin := func() {
+		env.bm.Unpin(buffers[len(buffers)-1])
+		buffers = buffers[:len(buffers)-1]
+	}
+
+	pin(1)
+	expectCalls(0)
+	pin(2)
+	expectCalls(1)
+
+	// Pins above the threshold do not call the hook again.
+	pin(3)
+	pin(4)
+	unpin()
+	pin(4)
+	expectCalls(0)
+
+	// The hook is called again once the fraction went below the threshold and back.
+	unpin()
+	unpin()
+	unpin()
+	expectCalls(0)
+	pin(2)
+	expectCalls(1)
+
+	unpin()
+	unpin()
+}
+
+func TestBufferManager_LRUKeepsHotBlock(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		strategy ReplacementStrategy
+		// hotReads checks the number of times the hot block is read from disk.
+		hotReads func(reads int) bool
+	}{
+		{name: "LRU", strategy: NewLRUStrategy(), hotReads: func(reads int) bool { return reads == 1 }},
+		{name: "Naive", strategy: NewNaiveStrategy(), hotReads: func(reads int) bool { return reads > 1 }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			env := setupTest(t, 3)
+			defer env.cleanup()
+			bm := NewManagerWithReplacementStrategy(env.fm, env.lm, 3, test.strategy)
+
+			// A pinned block is never replaced, however many blocks are read meanwhile.
+			pinnedBlock := createBlock("pinned", 0)
+			pinned, err := bm.Pin(&pinnedBlock)
+			require.NoError(t, err)
+
+			// The hot block is pinned again before each block of the scan of a large table.
+			hot := createBlock("hot", 0)
+			for blockNum := 0; blockNum < 20; blockNum++ {
+				buff, err := bm.Pin(&hot)
+				require.NoError(t, err)
+				bm.Unpin(buff)
+
+				blk := createBlock("table", blockNum)
+				buff, err = bm.Pin(&blk)
+				require.NoError(t, err)
+				bm.Unpin(buff)
+			}
+
+			assert.Equal(t, &pinnedBlock, pinned.Block())
+			assert.Equal(t, 1, env.fm.GetBlocksReadFrom("pinned"))
+			assert.Equal(t, 20, env.fm.GetBlocksReadFrom("table"))
+			assert.True(t, test.hotReads(env.fm.GetBlocksReadFrom("hot")), "hot block read %d times", env.fm.GetBlocksReadFrom("hot"))
+			bm.Unpin(pinned)
+		})
+	}
 }
@@ -0,0 +1,64 @@
+package buffer
+
+import (
+	"fmt"
+	"time"
+)
+
+// pressureHookInterval is the minimum time between two calls of a pressure hook,
+// so that a pool whose pinned fraction hovers around the threshold does not flood the hook.
+var pressureHookInterval = time.Second
+
+// pressureHook is a function called when the fraction of pinned buffers of the pool crosses a threshold.
+type pressureHook struct {
+	threshold float64
+	fn        func(Stats)
+	// above is true while the pinned fraction is at or above the threshold.
+	above bool
+	// lastCall is when the hook was last called.
+	lastCall time.Time
+}
+
+// RegisterPressureHook registers a function called with the stats of the pool when the fraction of pinned
+// buffers reaches the specified threshold, between 0 and 1, so that the application can shed load or log
+// it before pins start timing out. The function is called once each time the fraction crosses the threshold
+// from below, at most once per second, and in a goroutine of its own, so that it may call Stats.
+func (m *Manager) RegisterPressureHook(threshold float64, fn func(Stats)) error {
+	if threshold <= 0 || threshold > 1 {
+		return fmt.Errorf("pressure threshold %v is not in (0, 1]", threshold)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hook := &pressureHook{threshold: threshold, fn: fn}
+	m.pressureHooks = append(m.pressureHooks, hook)
+	// A pool already under pressure calls the hook right away.
+	m.checkHook(hook, m.snapshot())
+	return nil
+}
+
+// checkPressure calls the pressure hooks whose threshold the pinned fraction of the pool crossed
+// since it was last checked. It is called whenever the number of pinned buffers changes.
+// This method is not thread-safe.
+func (m *Manager) checkPressure() {
+	if len(m.pressureHooks) == 0 {
+		return
+	}
+	stats := m.snapshot()
+	for _, hook := range m.pressureHooks {
+		m.checkHook(hook, stats)
+	}
+}
+
+// checkHook calls the hook if the pinned fraction of the pool crossed its threshold from below.
+// This method is not thread-safe.
+func (m *Manager) checkHook(hook *pressureHook, stats Stats) {
+	above := stats.PinnedFraction() >= hook.threshold
+	crossed := above && !hook.above
+	hook.above = above
+	if !crossed || time.Since(hook.lastCall) < pressureHookInterval {
+		return
+	}
+	hook.lastCall = time.Now()
+	go hook.fn(stats)
+}
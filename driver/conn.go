@@ -3,8 +3,12 @@ package driver
 import (
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/server"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"time"
 )
 
 // DropDBConn implements driver.Conn.
@@ -13,21 +17,35 @@ type DropDBConn struct {
 
 	// activeTx is non-nil if we are in an explicit transaction
 	activeTx *tx.Transaction
+
+	// abortErr is the error that aborted the explicit transaction, which was rolled back
+	// when one of its statements timed out or lost a lock conflict. It is nil while the transaction can go on.
+	abortErr error
+
+	// queryTimeout is how long a statement may run before it is aborted. Zero means no timeout.
+	queryTimeout time.Duration
+
+	// release is called once when the connection is closed, to let go of the shared database.
+	release func()
 }
 
-// Prepare returns a prepared statement, but we'll simply store the SQL string.
-// Actual planning happens in Stmt.Exec / Stmt.Query (auto-commit style).
+// Prepare returns a prepared statement, which stores the SQL string along with the number of its positional parameters.
+// Actual planning happens in Stmt.Exec / Stmt.Query (auto-commit style), where the parameters are replaced with the arguments.
 func (c *DropDBConn) Prepare(query string) (driver.Stmt, error) {
 	return &DropDBStmt{
-		conn:  c,
-		query: query,
+		conn:     c,
+		query:    query,
+		numInput: parse.CountPositionalParameters(query),
 	}, nil
 }
 
 // Close is called when database/sql is done with this connection.
+// The database stays open as long as other connections to it are open.
 func (c *DropDBConn) Close() error {
-	// There's no real "closing" an embedded DB, but if you had
-	// a long-running Tx or resources pinned, you could clean them up here.
+	if c.release != nil {
+		c.release()
+		c.release = nil
+	}
 	return nil
 }
 
@@ -39,8 +57,50 @@ func (c *DropDBConn) Begin() (driver.Tx, error) {
 	}
 	newTx := c.db.NewTx()
 	c.activeTx = newTx
+	c.abortErr = nil
 	return &DropDBTx{
 		conn: c,
 		tx:   newTx,
 	}, nil
 }
+
+// deadline returns the deadline of a statement starting now, or the zero time if statements have no timeout.
+func (c *DropDBConn) deadline() time.Time {
+	if c.queryTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.queryTimeout)
+}
+
+// checkActiveTx returns an error if the explicit transaction was aborted by a statement that timed out
+// or lost a lock conflict: its changes were rolled back, so the statements that follow must not run in it.
+func (c *DropDBConn) checkActiveTx() error {
+	if c.activeTx != nil && c.abortErr != nil {
+		return fmt.Errorf("transaction was rolled back: %w", c.abortErr)
+	}
+	return nil
+}
+
+// abortIfNeeded rolls back the explicit transaction if err aborts it (see abortsTransaction), releasing
+// its locks and pins, and remembers it as aborted until database/sql ends it. Auto-commit transactions
+// are rolled back by the statements themselves.
+func (c *DropDBConn) abortIfNeeded(t *tx.Transaction, err error) {
+	if !abortsTransaction(err) || t != c.activeTx || c.abortErr != nil {
+		return
+	}
+	c.abortErr = err
+	_ = t.Rollback()
+}
+
+// abortsTransaction returns true if err is a query timeout, or if it is retryable (see IsRetryable):
+// a transaction that waited for a lock in vain must release its locks for the others to proceed.
+func abortsTransaction(err error) bool {
+	return errors.Is(err, tx.ErrQueryTimeout) || IsRetryable(err)
+}
+
+// IsRetryable returns true if err is a deadlock (see concurrency.ErrDeadlock) or a lock wait that timed out
+// (see concurrency.ErrLockTimeout). The transaction that failed with it was rolled back, so that the
+// transactions it conflicted with could proceed, and running it again from the start may succeed.
+func IsRetryable(err error) bool {
+	return errors.Is(err, concurrency.ErrDeadlock) || errors.Is(err, concurrency.ErrLockTimeout)
+}
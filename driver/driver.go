@@ -3,7 +3,14 @@ package driver
 import (
 	"database/sql"
 	"database/sql/driver"
+	"fmt"
 	"github.com/JyotinderSingh/dropdb/server"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const dbName = "dropdb"
@@ -16,16 +23,120 @@ func init() {
 // DropDBDriver implements database/sql/driver.Driver.
 var _ driver.Driver = (*DropDBDriver)(nil)
 
-type DropDBDriver struct{}
+// DropDBDriver keeps one database per directory, which is shared by all the connections to that directory.
+// Databases in different directories share nothing: each has its own buffers, log, locks and transaction numbering.
+type DropDBDriver struct {
+	mu sync.Mutex
+	// databases holds the open databases, keyed by the absolute path of their directory.
+	databases map[string]*sharedDB
+}
+
+// sharedDB is a database along with the number of open connections to it.
+type sharedDB struct {
+	db          *server.DropDB
+	connections int
+	// orderJoins is the join_order option the database was opened with.
+	orderJoins bool
+}
 
-// Open is the entry point. The directory is the path to the DB directory.
-func (d *DropDBDriver) Open(directory string) (driver.Conn, error) {
-	db, err := server.NewDropDB(directory)
+// dsnOptions are the options of a data source name.
+type dsnOptions struct {
+	queryTimeout time.Duration
+	orderJoins   bool
+}
+
+// Open is the entry point. The name is the path to the DB directory, optionally followed by options
+// in URL query form, such as "path/to/db?query_timeout_ms=5000". The options are:
+//   - query_timeout_ms: the number of milliseconds a statement of the connection may run before it is
+//     aborted with tx.ErrQueryTimeout. Zero, the default, means no timeout. SET QUERY TIMEOUT overrides it.
+//   - join_order: "heuristic" to join the tables of a query reading three or more in the order estimated
+//     to keep the intermediate results smallest (see plan_impl.HeuristicQueryPlanner), or "from", the default,
+//     to join them in the order of the FROM clause. It applies to the whole database, so every connection
+//     open at the same time to the same directory must use the same join order.
+//
+// The database is opened, and recovered if necessary, by the first connection to its directory.
+// It is closed once every connection to it is closed.
+func (d *DropDBDriver) Open(name string) (driver.Conn, error) {
+	directory, options, err := parseDSN(name)
 	if err != nil {
 		return nil, err
 	}
+	key, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, fmt.Errorf("resolve database directory %s: %w", directory, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	shared, ok := d.databases[key]
+	if !ok {
+		db, err := server.NewDropDBWithJoinOrdering(directory, options.orderJoins)
+		if err != nil {
+			return nil, err
+		}
+		if d.databases == nil {
+			d.databases = make(map[string]*sharedDB)
+		}
+		shared = &sharedDB{db: db, orderJoins: options.orderJoins}
+		d.databases[key] = shared
+	} else if shared.orderJoins != options.orderJoins {
+		return nil, fmt.Errorf("database %s is already open with another join_order", directory)
+	}
+	shared.connections++
+
 	return &DropDBConn{
-		db: db,
+		db:           shared.db,
+		queryTimeout: options.queryTimeout,
+		release:      func() { d.release(key) },
 		// We do not open a transaction here. We'll open a new one for each statement (auto-commit).
 	}, nil
 }
+
+// release forgets the database in the specified directory once its last connection is closed,
+// so that the next connection opens it anew.
+func (d *DropDBDriver) release(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	shared, ok := d.databases[key]
+	if !ok {
+		return
+	}
+	shared.connections--
+	if shared.connections == 0 {
+		delete(d.databases, key)
+	}
+}
+
+// parseDSN splits a data source name into the database directory and the options following it.
+func parseDSN(name string) (directory string, options dsnOptions, err error) {
+	directory, rawOptions, _ := strings.Cut(name, "?")
+	values, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return "", dsnOptions{}, fmt.Errorf("parse options of %s: %w", name, err)
+	}
+	for option, optionValues := range values {
+		value := optionValues[len(optionValues)-1]
+		switch option {
+		case "query_timeout_ms":
+			milliseconds, err := strconv.Atoi(value)
+			if err != nil || milliseconds < 0 {
+				return "", dsnOptions{}, fmt.Errorf("invalid query_timeout_ms %q: expected a non-negative number of milliseconds", value)
+			}
+			options.queryTimeout = time.Duration(milliseconds) * time.Millisecond
+		case "join_order":
+			switch value {
+			case "heuristic":
+				options.orderJoins = true
+			case "from":
+				options.orderJoins = false
+			default:
+				return "", dsnOptions{}, fmt.Errorf("invalid join_order %q: expected heuristic or from", value)
+			}
+		default:
+			return "", dsnOptions{}, fmt.Errorf("unknown option %q in %s", option, name)
+		}
+	}
+	return directory, options, nil
+}
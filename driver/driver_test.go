@@ -1,11 +1,19 @@
 package driver
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/server"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestDropDBDriver(t *testing.T) {
@@ -60,8 +68,7 @@ func TestDropDBDriver(t *testing.T) {
 	for rows.Next() {
 		var name string
 		var year int
-		// NOTE: The order of columns is reversed due to how SortPlan is implemented. This is a known issue and should be fixed using some changes in the SortPlan implementation.
-		err := rows.Scan(&year, &name)
+		err := rows.Scan(&name, &year)
 		require.NoError(t, err, "failed to scan row")
 		results = append(results, struct {
 			sname    string
@@ -160,3 +167,921 @@ func TestDropDBDriver_ExplicitTransaction(t *testing.T) {
 	assert.Equal(t, 2, results[0].ID, "ID mismatch after commit")
 	assert.Equal(t, "commit", results[0].Val, "Val mismatch after commit")
 }
+
+func TestDropDBDriver_DumpBlock(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "dump"))
+	require.NoError(t, err, "failed to open DropDB")
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE student (sname VARCHAR(10), gradyear INT)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO student (sname, gradyear) VALUES ('Alice', 2023)")
+	require.NoError(t, err)
+
+	rows, err := db.Query("DUMP BLOCK student 0")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"slot", "flag", "sname", "sname_offset", "gradyear", "gradyear_offset"}, columns)
+
+	dumped := 0
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		require.NoError(t, rows.Scan(pointers...))
+
+		row := make(map[string]any)
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+		if dumped == 0 {
+			assert.Equal(t, "used", row["flag"])
+			assert.Equal(t, `"Alice"`, row["sname"])
+			assert.Equal(t, "2023", row["gradyear"])
+		} else {
+			assert.Equal(t, "empty", row["flag"])
+		}
+		dumped++
+	}
+	require.NoError(t, rows.Err())
+	assert.Greater(t, dumped, 1)
+
+	_, err = db.Query("DUMP BLOCK student 5")
+	assert.ErrorContains(t, err, "block 5 is out of range")
+}
+
+func TestDropDBDriver_ShowConstraints(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "constraints"))
+	require.NoError(t, err, "failed to open DropDB")
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE users (id INT, email VARCHAR(30), active BOOL)")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE UNIQUE INDEX users_id ON users (id)")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE UNIQUE INDEX active_email ON users (email) WHERE active = true")
+	require.NoError(t, err)
+
+	rows, err := db.Query("SHOW CONSTRAINTS users")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"constraint_name", "constraint_kind", "column_names", "predicate"}, columns)
+
+	var constraints [][]string
+	for rows.Next() {
+		var name, kind, columnNames, predicate string
+		require.NoError(t, rows.Scan(&name, &kind, &columnNames, &predicate))
+		constraints = append(constraints, []string{name, kind, columnNames, predicate})
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][]string{
+		{"active_email", "UNIQUE", "email", "active = true"},
+		{"users_id", "UNIQUE", "id", ""},
+	}, constraints)
+}
+
+func TestDropDBDriver_CheckTable(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "check"))
+	require.NoError(t, err, "failed to open DropDB")
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE users (id INT, email VARCHAR(30))")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE INDEX users_id ON users (id)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (id, email) VALUES (1, 'a@example.com'), (2, 'b@example.com')")
+	require.NoError(t, err)
+
+	rows, err := db.Query("CHECK TABLE users")
+	require.NoError(t, err)
+	defer rows.Close()
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"severity", "location", "description"}, columns)
+	assert.False(t, rows.Next(), "a consistent table has no findings")
+	require.NoError(t, rows.Err())
+
+	result, err := db.Exec("CHECK TABLE users")
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+}
+
+// transactionNumbers returns the transaction numbers in the log of the database in the specified directory,
+// in the order in which they first appear, split into the runs delimited by the checkpoints of recovery.
+func transactionNumbers(t *testing.T, directory string) [][]int {
+	db, err := server.NewDropDB(directory)
+	require.NoError(t, err)
+	iter, err := db.LogManager().Iterator()
+	require.NoError(t, err)
+
+	var records []tx.LogRecord
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		record, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+	slices.Reverse(records)
+
+	runs := [][]int{nil}
+	seen := make(map[int]bool)
+	for _, record := range records {
+		if record.Op() == tx.Checkpoint {
+			runs = append(runs, nil)
+			continue
+		}
+		if !seen[record.TxNumber()] {
+			seen[record.TxNumber()] = true
+			runs[len(runs)-1] = append(runs[len(runs)-1], record.TxNumber())
+		}
+	}
+	return runs
+}
+
+func TestDropDBDriver_MultipleDatabases(t *testing.T) {
+	directories := []string{filepath.Join(t.TempDir(), "tenant_a"), filepath.Join(t.TempDir(), "tenant_b")}
+	run := func(statements func(dbs []*sql.DB)) {
+		dbs := make([]*sql.DB, len(directories))
+		for i, directory := range directories {
+			db, err := sql.Open("dropdb", directory)
+			require.NoError(t, err)
+			dbs[i] = db
+		}
+		statements(dbs)
+		for _, db := range dbs {
+			require.NoError(t, db.Close())
+		}
+	}
+
+	// Interleave the statements and transactions of both databases.
+	run(func(dbs []*sql.DB) {
+		for i, db := range dbs {
+			_, err := db.Exec("CREATE TABLE events (id INT)")
+			require.NoError(t, err, "database %d", i)
+		}
+		for id := 0; id < 3; id++ {
+			for _, db := range dbs {
+				_, err := db.Exec(fmt.Sprintf("INSERT INTO events (id) VALUES (%d)", id))
+				require.NoError(t, err)
+			}
+		}
+		transactions := make([]*sql.Tx, len(dbs))
+		for i, db := range dbs {
+			var err error
+			transactions[i], err = db.Begin()
+			require.NoError(t, err)
+		}
+		for _, transaction := range transactions {
+			_, err := transaction.Exec("INSERT INTO events (id) VALUES (3)")
+			require.NoError(t, err)
+		}
+		for _, transaction := range transactions {
+			require.NoError(t, transaction.Commit())
+		}
+	})
+
+	// Reopen both databases, which recovers them.
+	run(func(dbs []*sql.DB) {
+		for _, db := range dbs {
+			_, err := db.Exec("INSERT INTO events (id) VALUES (4)")
+			require.NoError(t, err)
+			var count int
+			require.NoError(t, db.QueryRow("SELECT id FROM events WHERE id = 4").Scan(&count))
+		}
+	})
+
+	for _, directory := range directories {
+		runs := transactionNumbers(t, directory)
+		require.Len(t, runs, 3, "two restarts, each recovering the database")
+
+		// The first run numbers its transactions from 1, whatever happens in the other database.
+		first := runs[0]
+		require.NotEmpty(t, first)
+		for i, txNum := range first {
+			assert.Equal(t, i+1, txNum, "%s: transaction numbers of the first run", directory)
+		}
+
+		// Later runs never reuse a number, and keep increasing.
+		last := first[len(first)-1]
+		for _, later := range runs[1:] {
+			for _, txNum := range later {
+				assert.Greater(t, txNum, last, "%s: transaction numbers must increase across restarts", directory)
+				last = txNum
+			}
+		}
+	}
+}
+
+// queryRow returns the single row of a query, keyed by column name.
+func queryRow(t *testing.T, db *sql.DB, query string, args ...any) map[string]any {
+	rows, err := db.Query(query, args...)
+	require.NoError(t, err, query)
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	require.True(t, rows.Next(), "no row for %s", query)
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	require.NoError(t, rows.Scan(pointers...))
+	require.False(t, rows.Next(), "more than one row for %s", query)
+	require.NoError(t, rows.Err())
+
+	row := make(map[string]any, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row
+}
+
+func TestDropDBDriver_Parameters(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "parameters"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE events (id INT, name VARCHAR(20), active BOOL, day DATE)")
+	require.NoError(t, err)
+
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	ids := []any{int(1), int8(2), int16(3), int32(4), int64(5), uint(6), uint8(7), uint16(8), uint32(9), uint64(10)}
+	for i, id := range ids {
+		result, err := db.Exec(
+			"INSERT INTO events (id, name, active, day) VALUES (:id, :name, :active, :start_date)",
+			sql.Named("id", id), sql.Named("name", []byte(fmt.Sprintf("event %d", i+1))),
+			sql.Named("active", i%2 == 0), sql.Named("start_date", day.AddDate(0, 0, i)),
+		)
+		require.NoError(t, err, "id of type %T", id)
+		affected, err := result.RowsAffected()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), affected)
+	}
+
+	// Every value is stored with the type of its field.
+	for i := range ids {
+		row := queryRow(t, db, "SELECT id, name, active, day FROM events WHERE id = @id", sql.Named("id", i+1))
+		assert.Equal(t, i+1, row["id"])
+		assert.Equal(t, fmt.Sprintf("event %d", i+1), row["name"])
+		assert.Equal(t, i%2 == 0, row["active"])
+		assert.True(t, day.AddDate(0, 0, i).Equal(row["day"].(time.Time)), "day of event %d: %v", i+1, row["day"])
+	}
+
+	// Parameters work in every clause holding constants, positional parameters too.
+	row := queryRow(t, db, "SELECT id FROM events WHERE day = :day AND active = :active", sql.Named("day", day.AddDate(0, 0, 2)), sql.Named("active", true))
+	assert.Equal(t, 3, row["id"])
+	result, err := db.Exec("UPDATE events SET name = ? WHERE id = ?", "renamed", 4)
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	assert.Equal(t, "renamed", queryRow(t, db, "SELECT name FROM events WHERE id = ?", 4)["name"])
+	result, err = db.Exec("DELETE FROM events WHERE active = @active", sql.Named("active", false))
+	require.NoError(t, err)
+	affected, err = result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), affected)
+
+	// Unsupported types, missing values, and statements mixing both kinds of parameters are rejected.
+	_, err = db.Exec("INSERT INTO events (id) VALUES (:id)", sql.Named("id", complex(1, 2)))
+	assert.ErrorContains(t, err, "argument id: unsupported type complex128: supported types are int")
+	_, err = db.Exec("INSERT INTO events (id) VALUES (:id)", sql.Named("id", 1.5))
+	assert.ErrorContains(t, err, "cannot convert float64 value 1.5 to type int")
+	_, err = db.Exec("INSERT INTO events (id) VALUES (?)", uint64(1<<63))
+	assert.ErrorContains(t, err, "out of range")
+	_, err = db.Exec("INSERT INTO events (id, name) VALUES (:id, :name)", sql.Named("id", 11))
+	assert.ErrorContains(t, err, `no value bound to parameter "name"`)
+	_, err = db.Exec("INSERT INTO events (id, name) VALUES (?, :name)", 11, sql.Named("name", "mixed"))
+	assert.ErrorContains(t, err, "positional and named arguments cannot be mixed")
+	_, err = db.Exec("INSERT INTO events (id, name) VALUES (?, :name)", 11)
+	assert.ErrorContains(t, err, "positional and named parameters cannot be mixed")
+
+	var count int
+	rows, err := db.Query("SELECT id FROM events")
+	require.NoError(t, err)
+	for rows.Next() {
+		count++
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 5, count, "the rejected statements insert nothing")
+}
+
+func TestDropDBDriver_ColumnMetadata(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "columns"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, statement := range []string{
+		"CREATE TABLE people (id INT, name VARCHAR(10), dept_id INT)",
+		"CREATE TABLE depts (did INT, dname VARCHAR(15))",
+		"INSERT INTO people (id, name, dept_id) VALUES (1, 'ann', 10)",
+		"INSERT INTO people (id, name, dept_id) VALUES (2, 'bob', 10)",
+		"INSERT INTO depts (did, dname) VALUES (10, 'sales')",
+	} {
+		_, err := db.Exec(statement)
+		require.NoError(t, err, statement)
+	}
+
+	rows, err := db.Query("SELECT dname, count(id), max(name) FROM people, depts WHERE dept_id = did GROUP BY dname")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dname", "countOfid", "maxOfname"}, columns)
+
+	columnTypes, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	require.Len(t, columnTypes, 3)
+	for i, expected := range []struct {
+		typeName string
+		length   int64
+	}{{"varchar", 15}, {"long", 0}, {"varchar", 10}} {
+		assert.Equal(t, expected.typeName, columnTypes[i].DatabaseTypeName(), columns[i])
+		length, ok := columnTypes[i].Length()
+		assert.Equal(t, expected.length != 0, ok, columns[i])
+		assert.Equal(t, expected.length, length, columns[i])
+	}
+
+	require.True(t, rows.Next())
+	var dname, maxName string
+	var count int64
+	require.NoError(t, rows.Scan(&dname, &count, &maxName))
+	assert.Equal(t, "sales", dname)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, "bob", maxName)
+	assert.False(t, rows.Next())
+	require.NoError(t, rows.Err())
+}
+
+// createCrossJoinTables creates tables a, b and c, each holding the specified number of rows, so that
+// reading their product takes much longer than a few milliseconds. The values of b and c never match.
+func createCrossJoinTables(t *testing.T, db *sql.DB, rowsPerTable int) {
+	for _, table := range []string{"a", "b", "c"} {
+		_, err := db.Exec(fmt.Sprintf("CREATE TABLE %s (%sv INT)", table, table))
+		require.NoError(t, err)
+	}
+
+	transaction, err := db.Begin()
+	require.NoError(t, err)
+	for i := 0; i < rowsPerTable; i++ {
+		for table, offset := range map[string]int{"a": 0, "b": 0, "c": 100000} {
+			_, err := transaction.Exec(fmt.Sprintf("INSERT INTO %s (%sv) VALUES (?)", table, table), i+offset)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, transaction.Commit())
+}
+
+// slowCrossJoin is a query reading the product of the tables created by createCrossJoinTables,
+// which returns no rows only once the whole product is read. Its predicate is not an equality,
+// so that the tables cannot be joined on it instead.
+const slowCrossJoin = "SELECT av, bv, cv FROM a, b, c WHERE bv > cv"
+
+// queryError runs a query, reads all its rows, and returns the first error.
+func queryError(queryer interface {
+	Query(string, ...any) (*sql.Rows, error)
+}, query string) error {
+	rows, err := queryer.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+func TestDropDBDriver_QueryTimeout(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "timeout")
+	db, err := sql.Open("dropdb", directory+"?query_timeout_ms=50")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	createCrossJoinTables(t, db, 150)
+
+	// The cross join is aborted promptly once it exceeds the timeout.
+	start := time.Now()
+	err = queryError(db, slowCrossJoin)
+	assert.ErrorIs(t, err, tx.ErrQueryTimeout)
+	assert.Less(t, time.Since(start), time.Second, "the query should be aborted soon after its timeout")
+
+	// A fast query is unaffected.
+	assert.Equal(t, map[string]any{"av": 3}, queryRow(t, db, "SELECT av FROM a WHERE av = 3"))
+
+	// The locks of the aborted query were released, so the tables it read can be written.
+	result, err := db.Exec("UPDATE a SET av = 1000 WHERE av = 3")
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+
+	// An aborted explicit transaction is rolled back, along with what it wrote.
+	transaction, err := db.Begin()
+	require.NoError(t, err)
+	_, err = transaction.Exec("INSERT INTO a (av) VALUES (5000)")
+	require.NoError(t, err)
+	assert.ErrorIs(t, queryError(transaction, slowCrossJoin), tx.ErrQueryTimeout)
+	_, err = transaction.Exec("INSERT INTO a (av) VALUES (5001)")
+	assert.ErrorIs(t, err, tx.ErrQueryTimeout)
+	assert.ErrorIs(t, transaction.Commit(), tx.ErrQueryTimeout)
+
+	var count int
+	rows, err := db.Query("SELECT av FROM a WHERE av = 5000")
+	require.NoError(t, err)
+	for rows.Next() {
+		count++
+	}
+	require.NoError(t, rows.Err())
+	assert.Zero(t, count)
+}
+
+func TestDropDBDriver_SetQueryTimeout(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "set_timeout"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	createCrossJoinTables(t, db, 150)
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SET QUERY TIMEOUT 50")
+	require.NoError(t, err)
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, slowCrossJoin)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		require.NoError(t, rows.Close())
+	}
+	assert.ErrorIs(t, err, tx.ErrQueryTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The timeout only applies to the connection that set it.
+	other, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer other.Close()
+	var av int
+	require.NoError(t, other.QueryRowContext(ctx, "SELECT av FROM a WHERE av = 7").Scan(&av))
+	assert.Equal(t, 7, av)
+
+	_, err = conn.ExecContext(ctx, "SET QUERY TIMEOUT 0")
+	require.NoError(t, err)
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT av FROM a WHERE av = 7").Scan(&av))
+}
+
+func TestDropDBDriver_InvalidQueryTimeout(t *testing.T) {
+	for _, dsn := range []string{"dir?query_timeout_ms=soon", "dir?query_timeout_ms=-1", "dir?timeout=5", "dir?join_order=cost"} {
+		db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), dsn))
+		require.NoError(t, err)
+		assert.Error(t, db.Ping(), dsn)
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestDropDBDriver_JoinOrder(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "star")
+	db, err := sql.Open("dropdb", directory+"?join_order=heuristic")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, statement := range []string{
+		"CREATE TABLE sales (sid INT, sprod INT, sstore INT)",
+		"CREATE TABLE products (pid INT, pcat VARCHAR(5))",
+		"CREATE TABLE stores (stid INT, sregion VARCHAR(5))",
+		"INSERT INTO sales (sid, sprod, sstore) VALUES (1, 1, 1), (2, 1, 2), (3, 2, 1)",
+		"INSERT INTO products (pid, pcat) VALUES (1, 'toys'), (2, 'food')",
+		"INSERT INTO stores (stid, sregion) VALUES (1, 'east'), (2, 'west')",
+	} {
+		_, err := db.Exec(statement)
+		require.NoError(t, err, statement)
+	}
+	var sid int
+	require.NoError(t, db.QueryRow("SELECT sid FROM sales, stores, products "+
+		"WHERE sprod = pid AND sstore = stid AND pcat = 'toys' AND sregion = 'east'").Scan(&sid))
+	assert.Equal(t, 1, sid)
+
+	// The join order applies to the whole database, so a connection asking for another one is refused while it is open.
+	other, err := sql.Open("dropdb", directory)
+	require.NoError(t, err)
+	defer other.Close()
+	assert.ErrorContains(t, other.Ping(), "join_order")
+}
+
+func TestDropDBDriver_UnknownField(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "unknown"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE users (id INT, name VARCHAR(10))")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (id, name) VALUES (1, 'ann')")
+	require.NoError(t, err)
+
+	// The misspelled field is reported by the query itself, rather than once its rows are read.
+	_, err = db.Query("SELECT id, nme FROM users")
+	assert.ErrorContains(t, err, "select list field nme is not a field of table users")
+	_, err = db.Query("SELECT id FROM users ORDER BY nme")
+	assert.ErrorContains(t, err, "ORDER BY field nme")
+	_, err = db.Exec("CREATE VIEW misspelled AS SELECT nme FROM users")
+	require.NoError(t, err)
+	_, err = db.Query("SELECT nme FROM misspelled")
+	assert.ErrorContains(t, err, "select list field nme is not a field of table users")
+}
+
+func TestDropDBDriver_ConstantQueries(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "constants"))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Ping())
+
+	// The connection probe of most tools.
+	rows, err := db.Query("SELECT 1")
+	require.NoError(t, err)
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"column1"}, columns)
+	var values []int
+	for rows.Next() {
+		var value int
+		require.NoError(t, rows.Scan(&value))
+		values = append(values, value)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []int{1}, values)
+
+	// A VALUES list of every type of constant.
+	day := time.Date(2024, 2, 29, 13, 4, 5, 0, time.UTC)
+	rows, err = db.Query("VALUES (1, 'a', true, 2024-02-29 13:04:05), (-2, 'it''s', false, ?)", day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	columns, err = rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"column1", "column2", "column3", "column4"}, columns)
+	type row struct {
+		id     int
+		name   string
+		active bool
+		day    time.Time
+	}
+	var results []row
+	for rows.Next() {
+		var r row
+		require.NoError(t, rows.Scan(&r.id, &r.name, &r.active, &r.day))
+		results = append(results, r)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	require.Len(t, results, 2)
+	assert.Equal(t, row{1, "a", true, results[0].day}, results[0])
+	assert.True(t, day.Equal(results[0].day))
+	assert.Equal(t, row{-2, "it's", false, results[1].day}, results[1])
+	assert.True(t, day.AddDate(0, 0, 1).Equal(results[1].day))
+
+	// The values of a column must have the same type in every row.
+	_, err = db.Query("VALUES (1, 'a'), ('b', 2)")
+	assert.EqualError(t, err, "values of column1 must all have the same type, but row 1 has type int and row 2 has type varchar")
+	_, err = db.Query("VALUES (1, 'a'), (2)")
+	assert.ErrorContains(t, err, "VALUES row 2 has 1 values, but row 1 has 2")
+	_, err = db.Query("SELECT 1 FROM t")
+	assert.ErrorContains(t, err, "a select list of constants cannot read tables")
+
+	// An insert reads the rows of its VALUES list like a VALUES query.
+	_, err = db.Exec("CREATE TABLE items (id INT, name VARCHAR(10))")
+	require.NoError(t, err)
+	result, err := db.Exec("INSERT INTO items (id, name) VALUES (1, 'one'), (2, 'two'), (3, 'three')")
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	_, err = db.Exec("INSERT INTO items (id, name) VALUES (4, 'four'), ('five', 5)")
+	assert.ErrorContains(t, err, "must all have the same type")
+
+	rows, err = db.Query("SELECT id, name FROM items")
+	require.NoError(t, err)
+	defer rows.Close()
+	items := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var name string
+		require.NoError(t, rows.Scan(&id, &name))
+		items[id] = name
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, map[int]string{1: "one", 2: "two", 3: "three"}, items)
+}
+
+func TestDropDBDriver_FloatColumn(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "floats"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE products (id INT, price FLOAT)")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE INDEX products_price ON products (price)")
+	require.NoError(t, err)
+	// Integers are stored in a float column as floats, and parameters bind floats of either size.
+	_, err = db.Exec("INSERT INTO products (id, price) VALUES (1, 19.99), (2, 5), (3, -0.5), (4, 1.25e2)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO products (id, price) VALUES (?, ?), (?, ?)", 5, 24.5, 6, float32(19.5))
+	require.NoError(t, err)
+
+	query := func(sql string) ([]int, []float64) {
+		rows, err := db.Query(sql)
+		require.NoError(t, err)
+		defer rows.Close()
+		var ids []int
+		var prices []float64
+		for rows.Next() {
+			var id int
+			var price float64
+			require.NoError(t, rows.Scan(&id, &price))
+			ids = append(ids, id)
+			prices = append(prices, price)
+		}
+		require.NoError(t, rows.Err())
+		return ids, prices
+	}
+
+	ids, prices := query("SELECT id, price FROM products WHERE price > 19.99 ORDER BY price")
+	assert.Equal(t, []int{5, 4}, ids)
+	assert.Equal(t, []float64{24.5, 125}, prices)
+
+	ids, prices = query("SELECT id, price FROM products ORDER BY price")
+	assert.Equal(t, []int{3, 2, 6, 1, 5, 4}, ids)
+	assert.Equal(t, []float64{-0.5, 5, 19.5, 19.99, 24.5, 125}, prices)
+
+	// Floats compare with integers, and the index finds the floats holding integers.
+	ids, _ = query("SELECT id, price FROM products WHERE price = 5")
+	assert.Equal(t, []int{2}, ids)
+	ids, _ = query("SELECT id, price FROM products WHERE price < -0.25")
+	assert.Equal(t, []int{3}, ids)
+}
+
+func TestDropDBDriver_NullValues(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "nulls"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE contacts (id INT, email VARCHAR(20))")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO contacts (id, email) VALUES (1, 'a@example.com'), (2, NULL)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO contacts (id, email) VALUES (?, ?)", 3, nil)
+	require.NoError(t, err)
+
+	rows, err := db.Query("SELECT id, email FROM contacts WHERE email IS NULL")
+	require.NoError(t, err)
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		var email sql.NullString
+		require.NoError(t, rows.Scan(&id, &email))
+		assert.False(t, email.Valid)
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	assert.ElementsMatch(t, []int{2, 3}, ids)
+
+	var email sql.NullString
+	require.NoError(t, db.QueryRow("SELECT email FROM contacts WHERE email IS NOT NULL").Scan(&email))
+	assert.Equal(t, sql.NullString{String: "a@example.com", Valid: true}, email)
+}
+
+func TestDropDBDriver_SelectWildcard(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "wildcard"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, statement := range []string{
+		"CREATE TABLE employees (name VARCHAR(10), salary INT, dept INT)",
+		"CREATE TABLE departments (dept_id INT, dept_name VARCHAR(10))",
+		"CREATE TABLE badges (name VARCHAR(10), badge_no INT)",
+		"INSERT INTO employees (name, salary, dept) VALUES ('alice', 100, 10), ('bob', 80, 20)",
+		"INSERT INTO departments (dept_id, dept_name) VALUES (10, 'sales'), (20, 'ops')",
+	} {
+		_, err := db.Exec(statement)
+		require.NoError(t, err, statement)
+	}
+
+	rows, err := db.Query("SELECT * FROM employees ORDER BY salary")
+	require.NoError(t, err)
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "salary", "dept"}, columns)
+	var names []string
+	for rows.Next() {
+		var name string
+		var salary, dept int
+		require.NoError(t, rows.Scan(&name, &salary, &dept))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []string{"bob", "alice"}, names)
+
+	// The wildcard expands to the fields of every table, in the order of the FROM clause.
+	rows, err = db.Query("SELECT * FROM employees, departments WHERE dept = dept_id")
+	require.NoError(t, err)
+	columns, err = rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "salary", "dept", "dept_id", "dept_name"}, columns)
+	require.NoError(t, rows.Close())
+	row := queryRow(t, db, "SELECT * FROM employees, departments WHERE dept = dept_id AND name = 'alice'")
+	assert.Equal(t, map[string]any{"name": "alice", "salary": 100, "dept": 10, "dept_id": 10, "dept_name": "sales"}, row)
+
+	// A field that two tables have is ambiguous.
+	_, err = db.Query("SELECT * FROM employees, badges")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "field name is ambiguous")
+}
+
+func TestDropDBDriver_PreparedStatements(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "prepared"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE readings (id INT, sensor VARCHAR(10), ok BOOL, day DATE)")
+	require.NoError(t, err)
+
+	// A statement prepared once is executed with the arguments of each row.
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	transaction, err := db.Begin()
+	require.NoError(t, err)
+	insert, err := transaction.Prepare("INSERT INTO readings (id, sensor, ok, day) VALUES (?, ?, ?, ?)")
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		_, err := insert.Exec(i, fmt.Sprintf("s%d", i%10), i%3 == 0, day.AddDate(0, 0, i%7))
+		require.NoError(t, err, "row %d", i)
+	}
+	require.NoError(t, insert.Close())
+	require.NoError(t, transaction.Commit())
+
+	query, err := db.Prepare("SELECT id FROM readings WHERE sensor = ? AND ok = ? AND day = ?")
+	require.NoError(t, err)
+	defer query.Close()
+	rows, err := query.Query("s4", true, day.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	var ids []int
+	for rows.Next() {
+		var id int
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	// The ids ending in 4 that are multiples of 3, and are 3 more than a multiple of 7.
+	var expected []int
+	for i := 0; i < 1000; i++ {
+		if i%10 == 4 && i%3 == 0 && i%7 == 3 {
+			expected = append(expected, i)
+		}
+	}
+	assert.ElementsMatch(t, expected, ids)
+
+	// The number of arguments must match the number of positional parameters.
+	_, err = query.Query("s4", true)
+	assert.ErrorContains(t, err, "expected 3 arguments, got 2")
+	_, err = db.Exec("INSERT INTO readings (id) VALUES (?)", 1, 2)
+	assert.ErrorContains(t, err, "expected 1 arguments, got 2")
+}
+
+func TestDropDBDriver_Deadlock(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "deadlock"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE a (av INT)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO a (av) VALUES (1)")
+	require.NoError(t, err)
+
+	// Both transactions read the table without changing it, so that each has to wait for the other's
+	// shared lock to write it.
+	transactions := make([]*sql.Tx, 2)
+	for i := range transactions {
+		transactions[i], err = db.Begin()
+		require.NoError(t, err)
+		_, err = transactions[i].Exec("UPDATE a SET av = 0 WHERE av = 0")
+		require.NoError(t, err)
+	}
+
+	errs := make(chan error, len(transactions))
+	for i, transaction := range transactions {
+		go func() {
+			_, err := transaction.Exec("UPDATE a SET av = ?", 10*(i+1))
+			if err == nil {
+				err = transaction.Commit()
+			} else {
+				_ = transaction.Rollback()
+			}
+			errs <- err
+		}()
+	}
+
+	// One of them is aborted, which lets the other one commit.
+	var failures []error
+	for range transactions {
+		if err := <-errs; err != nil {
+			failures = append(failures, err)
+		}
+	}
+	require.Len(t, failures, 1)
+	assert.ErrorIs(t, failures[0], concurrency.ErrDeadlock)
+	assert.True(t, IsRetryable(failures[0]))
+
+	av := queryRow(t, db, "SELECT av FROM a")["av"]
+	assert.Contains(t, []any{10, 20}, av)
+}
+
+func TestDropDBDriver_CountAll(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "counts"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE contacts (id INT, email VARCHAR(20))")
+	require.NoError(t, err)
+
+	var count int64
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM contacts").Scan(&count))
+	assert.Zero(t, count)
+
+	_, err = db.Exec("INSERT INTO contacts (id, email) VALUES (1, 'a@example.com'), (2, NULL), (3, NULL)")
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM contacts").Scan(&count))
+	assert.Equal(t, int64(3), count)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM contacts WHERE id > ?", 1).Scan(&count))
+	assert.Equal(t, int64(2), count)
+
+	rows, err := db.Query("SELECT COUNT(*) AS total, COUNT(email) FROM contacts")
+	require.NoError(t, err)
+	defer rows.Close()
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"total", "countOfemail"}, columns)
+	require.True(t, rows.Next())
+	var total, emails int64
+	require.NoError(t, rows.Scan(&total, &emails))
+	assert.Equal(t, []int64{3, 1}, []int64{total, emails})
+	assert.False(t, rows.Next())
+	require.NoError(t, rows.Err())
+}
+
+func TestDropDBDriver_CatalogListings(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "listings"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE employees (id INT, name VARCHAR(20))")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE INDEX employees_name ON employees (name)")
+	require.NoError(t, err)
+
+	rows, err := db.Query("SELECT * FROM dropdb_columns WHERE tblname = 'employees'")
+	require.NoError(t, err)
+	defer rows.Close()
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tblname", "field_name", "field_type", "field_length", "field_ordinal"}, columns)
+	var fields []string
+	for rows.Next() {
+		var tableName, fieldName, fieldType string
+		var length, ordinal int
+		require.NoError(t, rows.Scan(&tableName, &fieldName, &fieldType, &length, &ordinal))
+		fields = append(fields, fmt.Sprintf("%s %s(%d) #%d", fieldName, fieldType, length, ordinal))
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"id int(0) #0", "name varchar(20) #1"}, fields)
+
+	var indexName, indexedColumns string
+	require.NoError(t, db.QueryRow("SELECT index_name, column_names FROM dropdb_indexes WHERE tblname = ?", "employees").
+		Scan(&indexName, &indexedColumns))
+	assert.Equal(t, []string{"employees_name", "name"}, []string{indexName, indexedColumns})
+
+	_, err = db.Exec("DELETE FROM dropdb_indexes WHERE index_name = 'employees_name'")
+	assert.ErrorContains(t, err, "read-only table: dropdb_indexes")
+
+	// Dropping the table removes it from the listings at once.
+	_, err = db.Exec("DROP TABLE employees")
+	require.NoError(t, err)
+	var count int64
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM dropdb_tables").Scan(&count))
+	assert.Zero(t, count)
+}
@@ -0,0 +1,114 @@
+package driver
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+)
+
+// supportedParameterTypes lists the Go types accepted as statement arguments, for error messages.
+const supportedParameterTypes = "int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool, string, []byte, time.Time and nil for null"
+
+var _ driver.NamedValueChecker = (*DropDBConn)(nil)
+
+// CheckNamedValue converts a statement argument to the representation the parser uses for constants:
+// integers of every size to int, floats of either size to float64, []byte to string, and booleans, strings and dates
+// (time.Time) unchanged.
+// Values implementing driver.Valuer are converted first. Any other type is rejected.
+func (c *DropDBConn) CheckNamedValue(nv *driver.NamedValue) error {
+	val, err := parameterValue(nv.Value)
+	if err != nil {
+		if nv.Name != "" {
+			return fmt.Errorf("argument %s: %w", nv.Name, err)
+		}
+		return fmt.Errorf("argument %d: %w", nv.Ordinal, err)
+	}
+	nv.Value = val
+	return nil
+}
+
+// parameterValue converts a Go value to the representation the parser uses for constants.
+func parameterValue(val any) (any, error) {
+	if valuer, ok := val.(driver.Valuer); ok {
+		converted, err := valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+		val = converted
+	}
+
+	switch v := val.(type) {
+	case int:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		if v < math.MinInt || v > math.MaxInt {
+			return nil, fmt.Errorf("integer %d is out of range for type int", v)
+		}
+		return int(v), nil
+	case uint:
+		return unsignedParameterValue(uint64(v))
+	case uint8:
+		return int(v), nil
+	case uint16:
+		return int(v), nil
+	case uint32:
+		return unsignedParameterValue(uint64(v))
+	case uint64:
+		return unsignedParameterValue(v)
+	case float32:
+		return float64(v), nil
+	case float64, bool, string, time.Time, nil:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T: supported types are %s", val, supportedParameterTypes)
+	}
+}
+
+// unsignedParameterValue converts an unsigned integer to an int, checking that it fits.
+func unsignedParameterValue(v uint64) (any, error) {
+	if v > math.MaxInt {
+		return nil, fmt.Errorf("integer %d is out of range for type int", v)
+	}
+	return int(v), nil
+}
+
+// statementParameters returns the parameters of a statement called with the specified arguments,
+// which are either all positional or all named.
+func statementParameters(args []driver.NamedValue) (*parse.Parameters, error) {
+	params := &parse.Parameters{}
+	for _, arg := range args {
+		if arg.Name == "" {
+			params.Positional = append(params.Positional, arg.Value)
+			continue
+		}
+		if params.Named == nil {
+			params.Named = make(map[string]any)
+		}
+		params.Named[arg.Name] = arg.Value
+	}
+	if len(params.Positional) > 0 && len(params.Named) > 0 {
+		return nil, errors.New("positional and named arguments cannot be mixed in a statement")
+	}
+	return params, nil
+}
+
+// namedValues returns the positional arguments of a statement as named values.
+func namedValues(args []driver.Value) []driver.NamedValue {
+	namedArgs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		namedArgs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return namedArgs
+}
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/scan"
@@ -18,19 +19,45 @@ type DropDBRows struct {
 	plan plan.Plan
 	done bool
 
-	// We'll extract column names once.
-	columns []string
+	// We'll extract the fields of the scan once.
+	fields []types.FieldInfo
 }
 
-// Columns returns the column names from the schema.
+var (
+	_ driver.RowsColumnTypeDatabaseTypeName = (*DropDBRows)(nil)
+	_ driver.RowsColumnTypeLength           = (*DropDBRows)(nil)
+)
+
+// Columns returns the column names, as reported by the fields of the scan.
 func (r *DropDBRows) Columns() []string {
-	if r.columns == nil {
-		sch := r.plan.Schema()
-		fields := sch.Fields()
-		r.columns = make([]string, len(fields))
-		copy(r.columns, fields)
+	fields := r.scanFields()
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.Name
+	}
+	return columns
+}
+
+// ColumnTypeDatabaseTypeName returns the SQL name of the type of the column at the specified index, such as "varchar".
+func (r *DropDBRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.scanFields()[index].Type.String()
+}
+
+// ColumnTypeLength returns the length of the column at the specified index if it is a varchar column.
+func (r *DropDBRows) ColumnTypeLength(index int) (int64, bool) {
+	field := r.scanFields()[index]
+	if field.Type != types.Varchar {
+		return 0, false
+	}
+	return int64(field.Length), true
+}
+
+// scanFields returns the fields of the scan, which are the columns of the result set.
+func (r *DropDBRows) scanFields() []types.FieldInfo {
+	if r.fields == nil {
+		r.fields = r.scan.Fields()
 	}
-	return r.columns
+	return r.fields
 }
 
 // Close is called by database/sql when the result set is done.
@@ -39,10 +66,15 @@ func (r *DropDBRows) Close() error {
 	if r.done {
 		return nil
 	}
+	return r.finish()
+}
+
+// finish ends the result set once it is done: it clears the deadline set on the transaction when the query
+// was planned, so that closing the scan is not cut short by it, releases the scan and commits the transaction.
+func (r *DropDBRows) finish() error {
 	r.done = true
-	r.scan.Close()
-	// We can commit the transaction to auto-commit.
-	return r.tx.Commit()
+	r.tx.ClearDeadline()
+	return errors.Join(r.scan.Close(), r.tx.Commit())
 }
 
 // Next is called to advance the cursor and populate one row of data into 'dest'.
@@ -54,25 +86,29 @@ func (r *DropDBRows) Next(dest []driver.Value) error {
 	// Attempt to move to the next record
 	hasNext, err := r.scan.Next()
 	if err != nil {
-		// On error, rollback so no partial commit
-		_ = r.tx.Rollback()
-		r.done = true
-		return err
+		return r.fail(err)
 	}
 	if !hasNext {
-		// no more rows
-		r.done = true
-		// auto-commit
-		if commitErr := r.tx.Commit(); commitErr != nil {
+		// no more rows, auto-commit
+		if commitErr := r.finish(); commitErr != nil {
 			return commitErr
 		}
 		return io.EOF
 	}
 
 	// We have another row. Extract each column from the scan.
-	cols := r.Columns()
-	for i, col := range cols {
-		columnType := r.plan.Schema().Type(col)
+	for i, field := range r.scanFields() {
+		col, columnType := field.Name, field.Type
+
+		// A null value is returned as nil, since the typed getters return the zero value of the type for it.
+		val, err := r.scan.GetVal(col)
+		if err != nil {
+			return r.fail(err)
+		}
+		if val == nil {
+			dest[i] = nil
+			continue
+		}
 
 		// Convert from scan's type to driver.Value
 		var v interface{}
@@ -80,37 +116,56 @@ func (r *DropDBRows) Next(dest []driver.Value) error {
 		case types.Integer:
 			v, err = r.scan.GetInt(col)
 			if err != nil {
-				return err
+				return r.fail(err)
 			}
 		case types.Varchar:
 			v, err = r.scan.GetString(col)
 			if err != nil {
-				return err
+				return r.fail(err)
 			}
 		case types.Boolean:
 			v, err = r.scan.GetBool(col)
 			if err != nil {
-				return err
+				return r.fail(err)
 			}
 		case types.Long:
 			v, err = r.scan.GetLong(col)
 			if err != nil {
-				return err
+				return r.fail(err)
 			}
 		case types.Short:
 			v, err = r.scan.GetShort(col)
 			if err != nil {
-				return err
+				return r.fail(err)
+			}
+		case types.Float:
+			v, err = r.scan.GetFloat(col)
+			if err != nil {
+				return r.fail(err)
 			}
 		case types.Date:
 			v, err = r.scan.GetDate(col)
 			if err != nil {
-				return err
+				return r.fail(err)
 			}
 		default:
-			return fmt.Errorf("unsupported field type: %v", columnType)
+			return r.fail(fmt.Errorf("unsupported field type: %v", columnType))
 		}
 		dest[i] = v
 	}
 	return nil
 }
+
+// fail ends the result set after an error reading the scan, which database/sql reports through Rows.Err
+// once the rows already returned by Next have been read. The transaction is rolled back rather than
+// committed, so that no partial result is mistaken for a complete one.
+func (r *DropDBRows) fail(err error) error {
+	r.done = true
+	_ = r.scan.Close()
+	if abortsTransaction(err) && r.tx == r.stmt.conn.activeTx {
+		r.stmt.conn.abortIfNeeded(r.tx, err)
+	} else {
+		_ = r.tx.Rollback()
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readError is the error of the reads failed by a faultyScan.
+type readError struct {
+	reads int
+}
+
+func (e *readError) Error() string {
+	return fmt.Sprintf("injected read failure after %d reads", e.reads)
+}
+
+// faultyScan wraps a scan, failing the reads of integer fields once the specified number of them succeeded.
+type faultyScan struct {
+	scan.Scan
+	reads     int
+	failAfter int
+}
+
+func (s *faultyScan) GetInt(fieldName string) (int, error) {
+	if s.reads >= s.failAfter {
+		return 0, &readError{reads: s.reads}
+	}
+	s.reads++
+	return s.Scan.GetInt(fieldName)
+}
+
+// closeRecordingScan wraps a scan, recording whether its transaction had a deadline when it was closed.
+type closeRecordingScan struct {
+	scan.Scan
+	tx              *tx.Transaction
+	closed          bool
+	deadlineCleared bool
+}
+
+func (s *closeRecordingScan) Close() error {
+	_, hasDeadline := s.tx.Deadline()
+	s.closed, s.deadlineCleared = true, !hasDeadline
+	return s.Scan.Close()
+}
+
+func TestDropDBRows_CloseClearsDeadline(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "deadline")+"?query_timeout_ms=60000")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE items (id INT)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO items (id) VALUES (1), (2)")
+	require.NoError(t, err)
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Raw(func(driverConn any) error {
+		stmt, err := driverConn.(*DropDBConn).Prepare("SELECT id FROM items")
+		require.NoError(t, err)
+		defer stmt.Close()
+
+		// The deadline of the query is cleared before its scan is closed, whether the rows are closed
+		// before they are all read or once they are.
+		for _, readAll := range []bool{false, true} {
+			queried, err := stmt.(*DropDBStmt).QueryContext(context.Background(), nil)
+			require.NoError(t, err)
+			rows := queried.(*DropDBRows)
+			_, hasDeadline := rows.tx.Deadline()
+			require.True(t, hasDeadline)
+			recording := &closeRecordingScan{Scan: rows.scan, tx: rows.tx}
+			rows.scan = recording
+
+			dest := make([]driver.Value, 1)
+			require.NoError(t, rows.Next(dest))
+			if readAll {
+				require.NoError(t, rows.Next(dest))
+				assert.Equal(t, io.EOF, rows.Next(dest))
+			}
+			assert.NoError(t, rows.Close())
+			assert.True(t, recording.closed, "readAll=%v", readAll)
+			assert.True(t, recording.deadlineCleared, "readAll=%v", readAll)
+		}
+		return nil
+	}))
+}
+
+func TestDropDBRows_ReadError(t *testing.T) {
+	db, err := sql.Open("dropdb", filepath.Join(t.TempDir(), "faults"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE items (id INT, name VARCHAR(10))")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO items (id, name) VALUES (1, 'one'), (2, 'two'), (3, 'three'), (4, 'four')")
+	require.NoError(t, err)
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Raw(func(driverConn any) error {
+		stmt, err := driverConn.(*DropDBConn).Prepare("SELECT id, name FROM items")
+		require.NoError(t, err)
+		defer stmt.Close()
+		queried, err := stmt.(*DropDBStmt).QueryContext(context.Background(), nil)
+		require.NoError(t, err)
+		rows := queried.(*DropDBRows)
+		rows.scan = &faultyScan{Scan: rows.scan, failAfter: 2}
+
+		// The rows read before the failure are returned, then the error, and nothing after it.
+		dest := make([]driver.Value, 2)
+		for range 2 {
+			require.NoError(t, rows.Next(dest))
+		}
+		err = rows.Next(dest)
+		var injected *readError
+		assert.True(t, errors.As(err, &injected), "expected an injected read error, got %v", err)
+		assert.Equal(t, io.EOF, rows.Next(dest))
+		assert.NoError(t, rows.Close())
+		return nil
+	}))
+
+	// The transaction of the query was rolled back, releasing its locks.
+	_, err = db.Exec("DELETE FROM items WHERE id = 1")
+	require.NoError(t, err)
+	var count int
+	rows, err := db.Query("SELECT id FROM items")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		count++
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 3, count)
+}
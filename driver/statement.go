@@ -1,16 +1,24 @@
 package driver
 
 import (
+	"context"
 	"database/sql/driver"
 	"fmt"
+	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"strings"
+	"time"
 )
 
+var _ driver.StmtExecContext = (*DropDBStmt)(nil)
+var _ driver.StmtQueryContext = (*DropDBStmt)(nil)
+
 // DropDBStmt implements driver.Stmt.
 type DropDBStmt struct {
 	conn  *DropDBConn
 	query string
+	// numInput is the number of positional parameters of the statement, or -1 if it has named parameters.
+	numInput int
 }
 
 // Close is a no-op for this simple driver.
@@ -18,14 +26,40 @@ func (s *DropDBStmt) Close() error {
 	return nil
 }
 
-// NumInput returns -1 indicating we don't do bound parameters in this example.
+// NumInput returns the number of positional parameters ("?") of the statement, so that database/sql
+// rejects calls with another number of arguments, or -1 if the statement has named parameters (":name" or "@name"),
+// whose arguments are only checked once the statement is parsed. Each parameter stands for a constant.
 func (s *DropDBStmt) NumInput() int {
-	return -1
+	return s.numInput
 }
 
-// Exec executes a non-SELECT statement (INSERT, UPDATE, DELETE, CREATE, etc).
-// If the statement is actually a SELECT, we throw an error or ignore.
+// Exec executes the statement with positional arguments; see ExecContext.
 func (s *DropDBStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+// Query executes the statement with positional arguments; see QueryContext.
+func (s *DropDBStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+// ExecContext executes a non-SELECT statement (INSERT, UPDATE, DELETE, CREATE, etc),
+// replacing its parameters with the arguments, converted by DropDBConn.CheckNamedValue.
+// If the statement is actually a SELECT, we throw an error or ignore.
+// SET QUERY TIMEOUT statements set the timeout of the following statements of the connection.
+func (s *DropDBStmt) ExecContext(_ context.Context, args []driver.NamedValue) (driver.Result, error) {
+	params, err := statementParameters(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if parser := parse.NewParser(s.query); parser.IsSetQueryTimeout() {
+		return s.setQueryTimeout(parser)
+	}
+	if err := s.conn.checkActiveTx(); err != nil {
+		return nil, err
+	}
+
 	var t *tx.Transaction
 	if s.conn.activeTx == nil {
 		// create transaction for auto-commit
@@ -49,12 +83,14 @@ func (s *DropDBStmt) Exec(args []driver.Value) (driver.Result, error) {
 
 	// For all other statements (CREATE, INSERT, UPDATE, DELETE, etc.),
 	// use planner.ExecuteUpdate
-	rowsAffected, err := planner.ExecuteUpdate(s.query, t)
+	rowsAffected, err := planner.ExecuteUpdateWithDeadline(s.query, params, t, s.conn.deadline())
 
 	if err != nil {
 		// if it was an auto-commit transaction, rollback
 		if s.conn.activeTx == nil {
 			_ = t.Rollback()
+		} else {
+			s.conn.abortIfNeeded(t, err)
 		}
 		return nil, err
 	}
@@ -70,8 +106,17 @@ func (s *DropDBStmt) Exec(args []driver.Value) (driver.Result, error) {
 	return &DropDBResult{rowsAffected: int64(rowsAffected)}, nil
 }
 
-// Query executes a SELECT statement and returns the resulting rows.
-func (s *DropDBStmt) Query(args []driver.Value) (driver.Rows, error) {
+// QueryContext executes a SELECT statement and returns the resulting rows,
+// replacing its parameters with the arguments, converted by DropDBConn.CheckNamedValue.
+func (s *DropDBStmt) QueryContext(_ context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	params, err := statementParameters(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.conn.checkActiveTx(); err != nil {
+		return nil, err
+	}
+
 	// Decide whether we're in an explicit transaction or need to auto-commit
 	var t *tx.Transaction
 	if s.conn.activeTx == nil {
@@ -82,9 +127,9 @@ func (s *DropDBStmt) Query(args []driver.Value) (driver.Rows, error) {
 		t = s.conn.activeTx
 	}
 
-	// We'll detect SELECT queries by prefix:
+	// We'll detect SELECT queries (and VALUES lists, DUMP BLOCK, SHOW CONSTRAINTS and CHECK TABLE statements, which return rows too) by prefix:
 	lower := strings.ToLower(strings.TrimSpace(s.query))
-	if !strings.HasPrefix(lower, "select") {
+	if !strings.HasPrefix(lower, "select") && !strings.HasPrefix(lower, "values") && !strings.HasPrefix(lower, "dump") && !strings.HasPrefix(lower, "show") && !strings.HasPrefix(lower, "check") {
 		// By the test logic, Query is only for SELECT statements.
 		// For everything else (CREATE, INSERT, etc.) we do Exec.
 		return nil, fmt.Errorf("Query called with non-SELECT statement: %s", s.query)
@@ -92,11 +137,14 @@ func (s *DropDBStmt) Query(args []driver.Value) (driver.Rows, error) {
 
 	planner := s.conn.db.Planner()
 
-	// Use the Planner to build a query plan
-	plan, err := planner.CreateQueryPlan(s.query, t)
+	// Use the Planner to build a query plan, whose scan times out with the statement
+	plan, err := planner.CreateQueryPlanWithDeadline(s.query, params, t, s.conn.deadline())
 	if err != nil {
-		// Roll back on error
-		_ = t.Rollback()
+		if s.conn.activeTx == nil {
+			_ = t.Rollback()
+		} else {
+			s.conn.abortIfNeeded(t, err)
+		}
 		return nil, err
 	}
 
@@ -104,6 +152,8 @@ func (s *DropDBStmt) Query(args []driver.Value) (driver.Rows, error) {
 	if err != nil {
 		if s.conn.activeTx == nil {
 			_ = t.Rollback()
+		} else {
+			s.conn.abortIfNeeded(t, err)
 		}
 		return nil, err
 	}
@@ -117,3 +167,14 @@ func (s *DropDBStmt) Query(args []driver.Value) (driver.Rows, error) {
 		plan: plan,
 	}, nil
 }
+
+// setQueryTimeout executes a SET QUERY TIMEOUT statement, which applies to the statements
+// of the connection that start after it, in or out of a transaction.
+func (s *DropDBStmt) setQueryTimeout(parser *parse.Parser) (driver.Result, error) {
+	data, err := parser.SetQueryTimeout()
+	if err != nil {
+		return nil, err
+	}
+	s.conn.queryTimeout = time.Duration(data.Milliseconds()) * time.Millisecond
+	return &DropDBResult{}, nil
+}
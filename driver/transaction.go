@@ -10,14 +10,30 @@ type DropDBTx struct {
 	tx   *tx.Transaction
 }
 
+// Commit commits the transaction, unless a statement that aborted it already rolled it back.
 func (t *DropDBTx) Commit() error {
+	if err := t.conn.checkActiveTx(); err != nil {
+		t.end()
+		return err
+	}
 	err := t.tx.Commit()
-	t.conn.activeTx = nil
+	t.end()
 	return err
 }
 
+// Rollback rolls back the transaction. It has nothing left to do if a statement that aborted it already rolled it back.
 func (t *DropDBTx) Rollback() error {
+	if t.conn.abortErr != nil {
+		t.end()
+		return nil
+	}
 	err := t.tx.Rollback()
-	t.conn.activeTx = nil
+	t.end()
 	return err
 }
+
+// end forgets the transaction, so that the following statements of the connection auto-commit.
+func (t *DropDBTx) end() {
+	t.conn.activeTx = nil
+	t.conn.abortErr = nil
+}
@@ -38,7 +38,8 @@ func main() {
 	if _, err = db.Exec(createTableSQL); err != nil {
 		log.Fatalf("Failed to create table: %v\n", err)
 	}
-	fmt.Println("Table 'student' created successfully.\n")
+	fmt.Println("Table 'student' created successfully.")
+	fmt.Println()
 
 	// ----------------------------------------------------------------
 	// 2. Demonstrate a ROLLBACK
@@ -62,7 +63,8 @@ func main() {
 		log.Fatalf("Failed to roll back tx1: %v\n", err)
 	}
 
-	fmt.Println("Rolled back transaction. Row for 'Zoe' should NOT be in the table.\n")
+	fmt.Println("Rolled back transaction. Row for 'Zoe' should NOT be in the table.")
+	fmt.Println()
 
 	// ----------------------------------------------------------------
 	// 3. Demonstrate a COMMIT with multiple inserts
@@ -92,7 +94,8 @@ func main() {
 	if err := tx2.Commit(); err != nil {
 		log.Fatalf("Failed to commit tx2: %v\n", err)
 	}
-	fmt.Println("Transaction tx2 committed successfully.\n")
+	fmt.Println("Transaction tx2 committed successfully.")
+	fmt.Println()
 
 	// ----------------------------------------------------------------
 	// 4. Query the table to confirm the results
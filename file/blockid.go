@@ -3,6 +3,9 @@ package file
 import "fmt"
 
 // BlockId identifies a disk block by its filename and block number.
+// BlockId is a comparable value type: two BlockIds that name the same block are equal
+// regardless of how or where they were allocated. Code that keys maps by block
+// should use Key rather than a *BlockId, whose identity depends on the allocation.
 type BlockId struct {
 	File        string
 	BlockNumber int
@@ -27,6 +30,14 @@ func (b *BlockId) String() string {
 	return fmt.Sprintf("[file %s, block %d]", b.File, b.BlockNumber)
 }
 
+// Equals returns true if both BlockIds refer to the same block.
 func (b *BlockId) Equals(other *BlockId) bool {
-	return b.File == other.File && b.BlockNumber == other.BlockNumber
+	return b.Key() == other.Key()
+}
+
+// Key returns the canonical, comparable form of the block identifier,
+// suitable for use as a map key. The returned value is a copy, so later
+// changes to b do not affect it.
+func (b *BlockId) Key() BlockId {
+	return *b
 }
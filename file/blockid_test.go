@@ -0,0 +1,57 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockIdEquality(t *testing.T) {
+	a := NewBlockId("testfile", 3)
+	b := &BlockId{File: "testfile", BlockNumber: 3}
+
+	assert.NotSame(t, a, b)
+	assert.True(t, a.Equals(b))
+	assert.Equal(t, a.Key(), b.Key())
+
+	assert.False(t, a.Equals(NewBlockId("testfile", 4)))
+	assert.False(t, a.Equals(NewBlockId("otherfile", 3)))
+}
+
+func TestBlockIdKeyAsMapKey(t *testing.T) {
+	m := make(map[BlockId]int)
+	m[NewBlockId("testfile", 1).Key()] = 1
+	m[NewBlockId("testfile", 2).Key()] = 2
+
+	// A separately allocated BlockId for the same block finds the same entry.
+	blk := BlockId{File: "testfile", BlockNumber: 1}
+	assert.Equal(t, 1, m[blk.Key()])
+	assert.Len(t, m, 2)
+
+	m[blk.Key()]++
+	assert.Equal(t, 2, m[NewBlockId("testfile", 1).Key()])
+	assert.Len(t, m, 2)
+}
+
+func TestBlockIdKeyIsCopy(t *testing.T) {
+	blk := NewBlockId("testfile", 1)
+	key := blk.Key()
+
+	blk.BlockNumber = 5
+	assert.Equal(t, 1, key.BlockNumber)
+	assert.False(t, blk.Equals(&key))
+}
+
+func TestBlockIdKeyOfSeparatelyConstructedBlocks(t *testing.T) {
+	block := NewBlockId("shared_file", 2)
+	byConstructor := NewBlockId(block.Filename(), block.Number())
+	byLiteral := &BlockId{File: block.Filename(), BlockNumber: block.Number()}
+
+	m := map[BlockId]int{block.Key(): 1}
+	assert.Equal(t, 1, m[byConstructor.Key()])
+	assert.Equal(t, 1, m[byLiteral.Key()])
+
+	// An entry added through one BlockId is removed through another naming the same block.
+	delete(m, byLiteral.Key())
+	assert.Empty(t, m)
+}
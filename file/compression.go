@@ -0,0 +1,360 @@
+package file
+
+import (
+	"bytes"
+	"cmp"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	// compressedDataSuffix is appended to the name of a compressed file to name the file holding its compressed blocks.
+	compressedDataSuffix = ".blocks"
+	// compressedIndexSuffix is appended to the name of a compressed file to name the file holding its offset index.
+	compressedIndexSuffix = ".offsets"
+	// slotEntrySize is the size of an entry of the offset index: the offset of the slot and its capacity.
+	slotEntrySize = 12
+)
+
+// compressedFile is a file whose blocks are stored compressed.
+// Since compressed blocks are variable-length, they are stored in slots of a data file,
+// and an offset index records the slot of each block: entry i of the index holds the offset
+// and the capacity of the slot of block i. A block is never rewritten in place: it is written to
+// a free slot, and its index entry is only written once that write is on disk, so that a crash in
+// between leaves the block with its previous contents. The slot it leaves behind is then free, and
+// reused by the blocks written next; the free space at the end of the data file is truncated.
+type compressedFile struct {
+	data     *os.File
+	index    *os.File
+	slots    []slot
+	dataSize int64
+	// free holds the free slots of the data file, ordered by offset. Adjacent free slots are merged.
+	free []slot
+}
+
+// slot is the location of a compressed block in the data file.
+// A slot of capacity 0 holds a block that was never written, which reads as zeros.
+type slot struct {
+	offset   int64
+	capacity int
+}
+
+// EnableCompression makes the specified file store its blocks compressed.
+// Compression is transparent to the callers of the Manager: blocks are still read and written
+// as fixed-size pages, and the file keeps its logical block numbers. It is persisted with the file,
+// so it does not need to be enabled again when the database is reopened.
+// The file must not have any uncompressed block yet; enabling compression on a compressed file does nothing.
+func (m *Manager) EnableCompression(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cf, err := m.compressedFile(filename)
+	if err != nil {
+		return err
+	}
+	if cf != nil {
+		return nil
+	}
+
+	length, err := m.length(filename)
+	if err != nil {
+		return err
+	}
+	if length > 0 {
+		return fmt.Errorf("cannot compress %s: it already has %d uncompressed blocks", filename, length)
+	}
+	f := m.openFiles[filename]
+	delete(m.openFiles, filename)
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close file %s: %v", filename, err)
+	}
+	if err := os.Remove(filepath.Join(m.dbDirectory, filename)); err != nil {
+		return fmt.Errorf("cannot remove file %s: %v", filename, err)
+	}
+
+	cf = &compressedFile{}
+	if cf.data, err = m.openCompanionFile(filename + compressedDataSuffix); err != nil {
+		return err
+	}
+	if cf.index, err = m.openCompanionFile(filename + compressedIndexSuffix); err != nil {
+		_ = cf.data.Close()
+		return err
+	}
+	m.compressedFiles[filename] = cf
+	return nil
+}
+
+// IsCompressed returns true if the blocks of the specified file are stored compressed.
+func (m *Manager) IsCompressed(filename string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cf, err := m.compressedFile(filename)
+	return cf != nil, err
+}
+
+// SizeOnDisk returns the number of bytes the specified file occupies on disk,
+// including the data file and the offset index of a compressed file.
+func (m *Manager) SizeOnDisk(filename string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cf, err := m.compressedFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	files := []*os.File{}
+	if cf != nil {
+		files = append(files, cf.data, cf.index)
+	} else {
+		f, err := m.getFile(filename)
+		if err != nil {
+			return 0, err
+		}
+		files = append(files, f)
+	}
+
+	var size int64
+	for _, f := range files {
+		fileInfo, err := f.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("cannot stat %s: %v", f.Name(), err)
+		}
+		size += fileInfo.Size()
+	}
+	return size, nil
+}
+
+// compressedFile returns the compressed file having the specified name, opening it if needed,
+// or nil if the file is not compressed. A file is compressed if its offset index exists.
+// This method is not thread-safe.
+func (m *Manager) compressedFile(filename string) (*compressedFile, error) {
+	if cf, ok := m.compressedFiles[filename]; ok {
+		return cf, nil
+	}
+	if _, ok := m.openFiles[filename]; ok {
+		return nil, nil
+	}
+
+	indexPath := filepath.Join(m.dbDirectory, filename+compressedIndexSuffix)
+	if _, err := os.Stat(indexPath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot access %s: %v", indexPath, err)
+	}
+
+	cf := &compressedFile{}
+	var err error
+	if cf.data, err = m.openCompanionFile(filename + compressedDataSuffix); err != nil {
+		return nil, err
+	}
+	if cf.index, err = m.openCompanionFile(filename + compressedIndexSuffix); err != nil {
+		_ = cf.data.Close()
+		return nil, err
+	}
+	if err := cf.load(); err != nil {
+		_ = cf.data.Close()
+		_ = cf.index.Close()
+		return nil, fmt.Errorf("cannot load offset index of %s: %v", filename, err)
+	}
+	m.compressedFiles[filename] = cf
+	return cf, nil
+}
+
+// openCompanionFile opens the specified data file or offset index of a compressed file, creating it if needed.
+func (m *Manager) openCompanionFile(filename string) (*os.File, error) {
+	path := filepath.Join(m.dbDirectory, filename)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_SYNC, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open file %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// load reads the offset index of the file, and the size of its data file.
+func (cf *compressedFile) load() error {
+	entries, err := io.ReadAll(cf.index)
+	if err != nil {
+		return err
+	}
+	// A partially written trailing entry belongs to a block whose append did not complete.
+	for i := 0; i+slotEntrySize <= len(entries); i += slotEntrySize {
+		cf.slots = append(cf.slots, slot{
+			offset:   int64(binary.BigEndian.Uint64(entries[i:])),
+			capacity: int(binary.BigEndian.Uint32(entries[i+8:])),
+		})
+	}
+
+	fileInfo, err := cf.data.Stat()
+	if err != nil {
+		return err
+	}
+	cf.dataSize = fileInfo.Size()
+	for _, s := range cf.slots {
+		cf.dataSize = max(cf.dataSize, s.offset+int64(s.capacity))
+	}
+
+	// The space between the slots of the blocks is free: it was left behind by blocks written elsewhere since,
+	// or written by a crashed write whose block kept its previous slot.
+	used := slices.DeleteFunc(slices.Clone(cf.slots), func(s slot) bool { return s.capacity == 0 })
+	slices.SortFunc(used, func(a, b slot) int { return cmp.Compare(a.offset, b.offset) })
+	var end int64
+	for _, s := range used {
+		if s.offset > end {
+			if err := cf.release(slot{offset: end, capacity: int(s.offset - end)}); err != nil {
+				return err
+			}
+		}
+		end = max(end, s.offset+int64(s.capacity))
+	}
+	if end < cf.dataSize {
+		return cf.release(slot{offset: end, capacity: int(cf.dataSize - end)})
+	}
+	return nil
+}
+
+// length returns the number of blocks in the file.
+func (cf *compressedFile) length() int {
+	return len(cf.slots)
+}
+
+// read decompresses the specified block into the buffer.
+// The blocks past the end of the file, and the blocks that were never written, read as zeros.
+func (cf *compressedFile) read(blockNumber int, buf []byte) error {
+	if blockNumber >= len(cf.slots) || cf.slots[blockNumber].capacity == 0 {
+		clear(buf)
+		return nil
+	}
+
+	s := cf.slots[blockNumber]
+	compressed := make([]byte, s.capacity)
+	if _, err := cf.data.ReadAt(compressed, s.offset); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot read slot at offset %d: %v", s.offset, err)
+	}
+	// The compressed stream marks its own end, so the unused end of the slot is never read.
+	reader := flate.NewReader(bytes.NewReader(compressed))
+	defer reader.Close()
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return fmt.Errorf("cannot decompress slot at offset %d: %v", s.offset, err)
+	}
+	return nil
+}
+
+// write compresses the buffer into the specified block.
+func (cf *compressedFile) write(blockNumber int, buf []byte) error {
+	compressed, err := compress(buf)
+	if err != nil {
+		return err
+	}
+
+	// The block is written to a free slot, which the data file is opened to write synchronously, before its
+	// index entry: the slot it had until then is only released once the entry no longer refers to it.
+	s := cf.allocate(len(compressed))
+	if _, err := cf.data.WriteAt(compressed, s.offset); err != nil {
+		return errors.Join(fmt.Errorf("cannot write data: %v", err), cf.release(s))
+	}
+
+	// Writing past the end of the file extends it with blocks that read as zeros, as for uncompressed files.
+	for len(cf.slots) < blockNumber {
+		cf.slots = append(cf.slots, slot{})
+		if err := cf.writeEntry(len(cf.slots) - 1); err != nil {
+			return err
+		}
+	}
+	var previous slot
+	if blockNumber == len(cf.slots) {
+		cf.slots = append(cf.slots, s)
+	} else {
+		previous = cf.slots[blockNumber]
+		cf.slots[blockNumber] = s
+	}
+	if err := cf.writeEntry(blockNumber); err != nil {
+		return err
+	}
+	if previous.capacity == 0 {
+		return nil
+	}
+	return cf.release(previous)
+}
+
+// compress returns the compressed contents of a block.
+func compress(buf []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(buf); err != nil {
+		return nil, fmt.Errorf("cannot compress data: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("cannot compress data: %v", err)
+	}
+	return compressed.Bytes(), nil
+}
+
+// allocate returns a slot of the specified capacity for a block: the smallest free slot large enough for it,
+// whose remaining space stays free, or a new slot at the end of the data file if there is none.
+func (cf *compressedFile) allocate(capacity int) slot {
+	best := -1
+	for i, s := range cf.free {
+		if s.capacity >= capacity && (best < 0 || s.capacity < cf.free[best].capacity) {
+			best = i
+		}
+	}
+	if best < 0 {
+		s := slot{offset: cf.dataSize, capacity: capacity}
+		cf.dataSize += int64(capacity)
+		return s
+	}
+
+	s := slot{offset: cf.free[best].offset, capacity: capacity}
+	if remaining := cf.free[best].capacity - capacity; remaining > 0 {
+		cf.free[best] = slot{offset: s.offset + int64(capacity), capacity: remaining}
+	} else {
+		cf.free = slices.Delete(cf.free, best, best+1)
+	}
+	return s
+}
+
+// release adds the slot to the free slots, merging it with the adjacent ones.
+// The data file is truncated when the free slot ends it.
+func (cf *compressedFile) release(s slot) error {
+	i, _ := slices.BinarySearchFunc(cf.free, s.offset, func(free slot, offset int64) int { return cmp.Compare(free.offset, offset) })
+	if i < len(cf.free) && s.offset+int64(s.capacity) == cf.free[i].offset {
+		s.capacity += cf.free[i].capacity
+		cf.free = slices.Delete(cf.free, i, i+1)
+	}
+	if i > 0 && cf.free[i-1].offset+int64(cf.free[i-1].capacity) == s.offset {
+		i--
+		s = slot{offset: cf.free[i].offset, capacity: cf.free[i].capacity + s.capacity}
+		cf.free = slices.Delete(cf.free, i, i+1)
+	}
+
+	if s.offset+int64(s.capacity) < cf.dataSize {
+		cf.free = slices.Insert(cf.free, i, s)
+		return nil
+	}
+	if err := cf.data.Truncate(s.offset); err != nil {
+		return fmt.Errorf("cannot truncate data file: %v", err)
+	}
+	cf.dataSize = s.offset
+	return nil
+}
+
+// writeEntry writes the entry of the specified block to the offset index.
+func (cf *compressedFile) writeEntry(blockNumber int) error {
+	entry := make([]byte, slotEntrySize)
+	binary.BigEndian.PutUint64(entry, uint64(cf.slots[blockNumber].offset))
+	binary.BigEndian.PutUint32(entry[8:], uint32(cf.slots[blockNumber].capacity))
+	if _, err := cf.index.WriteAt(entry, int64(blockNumber)*slotEntrySize); err != nil {
+		return fmt.Errorf("cannot write offset index: %v", err)
+	}
+	return nil
+}
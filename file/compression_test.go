@@ -0,0 +1,244 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLogLines fills the page with compressible text, numbered from the specified line.
+func writeLogLines(t *testing.T, page *Page, firstLine int) {
+	offset := 0
+	for line := firstLine; ; line++ {
+		text := fmt.Sprintf("%s INFO request %d served in 12ms", strings.Repeat("-", 8), line)
+		if offset+MaxLength(len(text)) > len(page.Contents()) {
+			return
+		}
+		require.NoError(t, page.SetString(offset, text))
+		offset += MaxLength(len(text))
+	}
+}
+
+func TestManager_CompressedFile(t *testing.T) {
+	const blockSize = 4096
+	const blocks = 20
+	dir := t.TempDir()
+	mgr, err := NewManager(dir, blockSize)
+	require.NoError(t, err)
+
+	require.NoError(t, mgr.EnableCompression("logs.tbl"))
+	compressed, err := mgr.IsCompressed("logs.tbl")
+	require.NoError(t, err)
+	assert.True(t, compressed)
+	compressed, err = mgr.IsCompressed("plain.tbl")
+	require.NoError(t, err)
+	assert.False(t, compressed)
+
+	// The same blocks are written to a compressed and an uncompressed file.
+	expected := make([][]byte, blocks)
+	for i := 0; i < blocks; i++ {
+		page := NewPage(blockSize)
+		writeLogLines(t, page, i*100)
+		expected[i] = append([]byte(nil), page.Contents()...)
+		for _, filename := range []string{"logs.tbl", "plain.tbl"} {
+			block, err := mgr.Append(filename)
+			require.NoError(t, err)
+			require.Equal(t, i, block.Number())
+			require.NoError(t, mgr.Write(block, page))
+		}
+	}
+
+	length, err := mgr.Length("logs.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, blocks, length)
+	for i := 0; i < blocks; i++ {
+		page := NewPage(blockSize)
+		require.NoError(t, mgr.Read(NewBlockId("logs.tbl", i), page))
+		assert.Equal(t, expected[i], page.Contents(), "block %d", i)
+	}
+
+	// A block that grows and a block that shrinks are both moved to another slot.
+	grown := NewPage(blockSize)
+	for offset := 0; offset+8 <= blockSize; offset += 8 {
+		grown.SetInt(offset, offset*7919)
+	}
+	require.NoError(t, mgr.Write(NewBlockId("logs.tbl", 3), grown))
+	shrunk := NewPage(blockSize)
+	require.NoError(t, mgr.Write(NewBlockId("logs.tbl", 4), shrunk))
+	expected[3] = append([]byte(nil), grown.Contents()...)
+	expected[4] = append([]byte(nil), shrunk.Contents()...)
+
+	// Compression is persisted with the file.
+	reopened, err := NewManager(dir, blockSize)
+	require.NoError(t, err)
+	compressed, err = reopened.IsCompressed("logs.tbl")
+	require.NoError(t, err)
+	assert.True(t, compressed)
+	length, err = reopened.Length("logs.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, blocks, length)
+	for i := 0; i < blocks; i++ {
+		page := NewPage(blockSize)
+		require.NoError(t, reopened.Read(NewBlockId("logs.tbl", i), page))
+		assert.Equal(t, expected[i], page.Contents(), "block %d", i)
+	}
+
+	// The compressed file takes a fraction of the space of the uncompressed one.
+	compressedSize, err := reopened.SizeOnDisk("logs.tbl")
+	require.NoError(t, err)
+	plainSize, err := reopened.SizeOnDisk("plain.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, int64(blocks*blockSize), plainSize)
+	assert.Less(t, compressedSize, plainSize/3)
+	t.Logf("compressed file: %d bytes, uncompressed file: %d bytes", compressedSize, plainSize)
+}
+
+func TestManager_CompressedFileBlocksPastTheEnd(t *testing.T) {
+	const blockSize = 400
+	mgr, err := NewManager(t.TempDir(), blockSize)
+	require.NoError(t, err)
+	require.NoError(t, mgr.EnableCompression("sparse.tbl"))
+
+	// Writing past the end extends the file with blocks that read as zeros, as for uncompressed files.
+	page := NewPage(blockSize)
+	require.NoError(t, page.SetString(0, "last block"))
+	require.NoError(t, mgr.Write(NewBlockId("sparse.tbl", 2), page))
+	length, err := mgr.Length("sparse.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, 3, length)
+
+	read := NewPage(blockSize)
+	require.NoError(t, read.SetString(0, "stale contents"))
+	require.NoError(t, mgr.Read(NewBlockId("sparse.tbl", 1), read))
+	assert.Equal(t, make([]byte, blockSize), read.Contents())
+	require.NoError(t, mgr.Read(NewBlockId("sparse.tbl", 2), read))
+	value, err := read.GetString(0)
+	require.NoError(t, err)
+	assert.Equal(t, "last block", value)
+}
+
+func TestManager_EnableCompressionRequiresAnEmptyFile(t *testing.T) {
+	mgr, err := NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+
+	_, err = mgr.Append("full.tbl")
+	require.NoError(t, err)
+	assert.ErrorContains(t, mgr.EnableCompression("full.tbl"), "cannot compress full.tbl: it already has 1 uncompressed blocks")
+
+	// An empty file, even if it was already opened, can be compressed, and enabling compression again does nothing.
+	length, err := mgr.Length("empty.tbl")
+	require.NoError(t, err)
+	assert.Zero(t, length)
+	require.NoError(t, mgr.EnableCompression("empty.tbl"))
+	_, err = mgr.Append("empty.tbl")
+	require.NoError(t, err)
+	require.NoError(t, mgr.EnableCompression("empty.tbl"))
+	length, err = mgr.Length("empty.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, 1, length)
+}
+
+func TestManager_CompressedFileTornWrite(t *testing.T) {
+	const blockSize = 4096
+	dir := t.TempDir()
+	mgr, err := NewManager(dir, blockSize)
+	require.NoError(t, err)
+	require.NoError(t, mgr.EnableCompression("logs.tbl"))
+
+	expected := make([][]byte, 3)
+	for i := range expected {
+		page := NewPage(blockSize)
+		writeLogLines(t, page, i*100)
+		expected[i] = append([]byte(nil), page.Contents()...)
+		require.NoError(t, mgr.Write(NewBlockId("logs.tbl", i), page))
+	}
+	sizeBefore, err := mgr.SizeOnDisk("logs.tbl")
+	require.NoError(t, err)
+
+	// A new image of block 1 is written to a slot that no block uses, whatever its size.
+	cf := mgr.compressedFiles["logs.tbl"]
+	page := NewPage(blockSize)
+	writeLogLines(t, page, 5000)
+	compressed, err := compress(page.Contents())
+	require.NoError(t, err)
+	s := cf.allocate(len(compressed))
+	for i, used := range cf.slots {
+		assert.True(t, s.offset >= used.offset+int64(used.capacity) || s.offset+int64(s.capacity) <= used.offset,
+			"slot %v overlaps the slot %v of block %d", s, used, i)
+	}
+
+	// The database crashes halfway through writing it, before the index entry of the block is written.
+	_, err = cf.data.WriteAt(compressed[:len(compressed)/2], s.offset)
+	require.NoError(t, err)
+
+	// Block 1 keeps its previous image, and the torn write is discarded.
+	reopened, err := NewManager(dir, blockSize)
+	require.NoError(t, err)
+	for i := range expected {
+		read := NewPage(blockSize)
+		require.NoError(t, reopened.Read(NewBlockId("logs.tbl", i), read))
+		assert.Equal(t, expected[i], read.Contents(), "block %d", i)
+	}
+	sizeAfter, err := reopened.SizeOnDisk("logs.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, sizeBefore, sizeAfter)
+}
+
+func TestManager_CompressedFileReusesSlots(t *testing.T) {
+	const blockSize = 4096
+	const blocks = 10
+	mgr, err := NewManager(t.TempDir(), blockSize)
+	require.NoError(t, err)
+	require.NoError(t, mgr.EnableCompression("logs.tbl"))
+
+	// Each block is rewritten many times, alternating between images compressed to different sizes.
+	largest := 0
+	images := make([][]byte, 2)
+	for i := range images {
+		page := NewPage(blockSize)
+		writeLogLines(t, page, 0)
+		for offset := 0; offset+8 <= blockSize*i/2; offset += 8 {
+			page.SetInt(offset, offset*7919)
+		}
+		images[i] = append([]byte(nil), page.Contents()...)
+		compressed, err := compress(images[i])
+		require.NoError(t, err)
+		largest = max(largest, len(compressed))
+	}
+	require.NotEqual(t, images[0], images[1])
+
+	var maxSize int64
+	for round := 0; round < 200; round++ {
+		for block := 0; block < blocks; block++ {
+			require.NoError(t, mgr.Write(NewBlockId("logs.tbl", block), NewPageFromBytes(images[(round+block)%2])))
+		}
+		size, err := mgr.SizeOnDisk("logs.tbl")
+		require.NoError(t, err)
+		maxSize = max(maxSize, size)
+	}
+
+	// The slots left behind by the rewritten blocks are reused, so the file stays within a small multiple of
+	// the space its blocks take, instead of growing with every rewrite.
+	assert.LessOrEqual(t, maxSize, int64(2*blocks*(largest+slotEntrySize)))
+	for block := 0; block < blocks; block++ {
+		read := NewPage(blockSize)
+		require.NoError(t, mgr.Read(NewBlockId("logs.tbl", block), read))
+		assert.Equal(t, images[(199+block)%2], read.Contents(), "block %d", block)
+	}
+
+	// Once every block shrinks, and is rewritten, the space the blocks no longer need is reclaimed.
+	for round := 0; round < 2; round++ {
+		for block := 0; block < blocks; block++ {
+			require.NoError(t, mgr.Write(NewBlockId("logs.tbl", block), NewPage(blockSize)))
+		}
+	}
+	size, err := mgr.SizeOnDisk("logs.tbl")
+	require.NoError(t, err)
+	empty, err := compress(make([]byte, blockSize))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, size, int64(2*blocks*(len(empty)+slotEntrySize)))
+	t.Logf("largest compressed block: %d bytes, largest file: %d bytes, file of empty blocks: %d bytes", largest, maxSize, size)
+}
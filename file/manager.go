@@ -6,9 +6,14 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
+// TempFilePrefix starts the names of the files that do not outlive the database process, such as
+// temporary tables. The files having it are left over by a crash, so NewManager removes them.
+const TempFilePrefix = "temp"
+
 // Manager is the File Manager used by the database. It provides methods to read, write, and append blocks to disk.
 // The Manager is thread-safe.
 type Manager struct {
@@ -19,6 +24,10 @@ type Manager struct {
 	openFiles     map[string]*os.File
 	blocksRead    int
 	blocksWritten int
+	// fileReads is the number of blocks read from each file.
+	fileReads map[string]int
+	// compressedFiles holds the open files whose blocks are stored compressed (see EnableCompression).
+	compressedFiles map[string]*compressedFile
 }
 
 // NewManager instantiates a new File Manager. Creates a new database directory if one doesn't already exist.
@@ -34,7 +43,7 @@ func NewManager(dbDirectory string, blockSize int) (*Manager, error) {
 		return nil, fmt.Errorf("cannot access directory %s: %v", dbDirectory, err)
 	}
 
-	// Remove any leftover temporary tables.
+	// Remove any leftover temporary files.
 	entries, err := os.ReadDir(dbDirectory)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read directory %s: %v", dbDirectory, err)
@@ -43,7 +52,7 @@ func NewManager(dbDirectory string, blockSize int) (*Manager, error) {
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			name := entry.Name()
-			if len(name) >= 4 && name[:4] == "temp" {
+			if strings.HasPrefix(name, TempFilePrefix) {
 				tempFilePath := filepath.Join(dbDirectory, name)
 				if err := os.Remove(tempFilePath); err != nil {
 					return nil, fmt.Errorf("cannot remove file %s: %v", tempFilePath, err)
@@ -53,12 +62,14 @@ func NewManager(dbDirectory string, blockSize int) (*Manager, error) {
 	}
 
 	return &Manager{
-		dbDirectory:   dbDirectory,
-		blockSize:     blockSize,
-		isNew:         isNew,
-		openFiles:     make(map[string]*os.File),
-		blocksRead:    0,
-		blocksWritten: 0,
+		dbDirectory:     dbDirectory,
+		blockSize:       blockSize,
+		isNew:           isNew,
+		openFiles:       make(map[string]*os.File),
+		blocksRead:      0,
+		blocksWritten:   0,
+		fileReads:       make(map[string]int),
+		compressedFiles: make(map[string]*compressedFile),
 	}, nil
 }
 
@@ -67,6 +78,17 @@ func (m *Manager) Read(block *BlockId, page *Page) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if cf, err := m.compressedFile(block.Filename()); err != nil {
+		return fmt.Errorf("cannot read block %s: %v", block.String(), err)
+	} else if cf != nil {
+		if err := cf.read(block.Number(), page.Contents()); err != nil {
+			return fmt.Errorf("cannot read block %s: %v", block.String(), err)
+		}
+		m.blocksRead++
+		m.fileReads[block.Filename()]++
+		return nil
+	}
+
 	f, err := m.getFile(block.Filename())
 	if err != nil {
 		return fmt.Errorf("cannot read block %s: %v", block.String(), err)
@@ -83,6 +105,7 @@ func (m *Manager) Read(block *BlockId, page *Page) error {
 	// Handle successful read
 	if err == nil && n == len(buf) {
 		m.blocksRead++
+		m.fileReads[block.Filename()]++
 		return nil
 	}
 
@@ -91,6 +114,7 @@ func (m *Manager) Read(block *BlockId, page *Page) error {
 		// File was empty.
 		if n == 0 {
 			m.blocksRead++
+			m.fileReads[block.Filename()]++
 			return nil
 		}
 		// File wasn't empty, but encountered unexpected EOF.
@@ -110,6 +134,16 @@ func (m *Manager) Write(block *BlockId, page *Page) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if cf, err := m.compressedFile(block.Filename()); err != nil {
+		return fmt.Errorf("cannot write block %s: %v", block.String(), err)
+	} else if cf != nil {
+		if err := cf.write(block.Number(), page.Contents()); err != nil {
+			return fmt.Errorf("cannot write block %s: %v", block.String(), err)
+		}
+		m.blocksWritten++
+		return nil
+	}
+
 	f, err := m.getFile(block.Filename())
 	if err != nil {
 		return fmt.Errorf("cannot write block %s: %v", block.String(), err)
@@ -143,13 +177,23 @@ func (m *Manager) Append(filename string) (*BlockId, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	newBlockNumber, err := m.Length(filename)
+	newBlockNumber, err := m.length(filename)
 	if err != nil {
 		return &BlockId{}, fmt.Errorf("cannot get length of %s: %v", filename, err)
 	}
 
 	block := &BlockId{File: filename, BlockNumber: newBlockNumber}
 
+	if cf, err := m.compressedFile(filename); err != nil {
+		return &BlockId{}, fmt.Errorf("cannot append block %s: %v", block.String(), err)
+	} else if cf != nil {
+		if err := cf.write(newBlockNumber, make([]byte, m.blockSize)); err != nil {
+			return &BlockId{}, fmt.Errorf("cannot append block %s: %v", block.String(), err)
+		}
+		m.blocksWritten++
+		return block, nil
+	}
+
 	f, err := m.getFile(filename)
 	if err != nil {
 		return &BlockId{}, fmt.Errorf("cannot append block %s: %v", block.String(), err)
@@ -179,8 +223,21 @@ func (m *Manager) Append(filename string) (*BlockId, error) {
 	return block, nil
 }
 
-// Length returns the number of blocks in the specified file. This method is not thread-safe.
+// Length returns the number of blocks in the specified file.
 func (m *Manager) Length(filename string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.length(filename)
+}
+
+// length returns the number of blocks in the specified file. This method is not thread-safe.
+func (m *Manager) length(filename string) (int, error) {
+	if cf, err := m.compressedFile(filename); err != nil {
+		return 0, fmt.Errorf("cannot access %s: %v", filename, err)
+	} else if cf != nil {
+		return cf.length(), nil
+	}
+
 	f, err := m.getFile(filename)
 	if err != nil {
 		return 0, fmt.Errorf("cannot access %s: %v", filename, err)
@@ -195,6 +252,54 @@ func (m *Manager) Length(filename string) (int, error) {
 	return int(fileSizeInBytes / int64(m.blockSize)), nil
 }
 
+// Remove closes and deletes the specified file, and the files holding its blocks if it is compressed.
+// Removing a file that does not exist does nothing. No buffer may hold a modified block of the file,
+// since it would recreate the file when it is flushed.
+func (m *Manager) Remove(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	if f, ok := m.openFiles[filename]; ok {
+		errs = append(errs, f.Close())
+		delete(m.openFiles, filename)
+	}
+	if cf, ok := m.compressedFiles[filename]; ok {
+		errs = append(errs, cf.data.Close(), cf.index.Close())
+		delete(m.compressedFiles, filename)
+	}
+	for _, name := range []string{filename, filename + compressedDataSuffix, filename + compressedIndexSuffix} {
+		path := filepath.Join(m.dbDirectory, name)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("cannot remove file %s: %v", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Rename closes the specified file and renames it to the new name, replacing the file having that name, if any.
+// The file must not be compressed, and no buffer may hold a modified block of either file.
+func (m *Manager) Rename(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, name := range []string{oldName, newName} {
+		if f, ok := m.openFiles[name]; ok {
+			errs = append(errs, f.Close())
+			delete(m.openFiles, name)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	oldPath, newPath := filepath.Join(m.dbDirectory, oldName), filepath.Join(m.dbDirectory, newName)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("cannot rename file %s to %s: %v", oldPath, newPath, err)
+	}
+	return nil
+}
+
 // IsNew returns true if the database directory is newly created.
 func (m *Manager) IsNew() bool {
 	return m.isNew
@@ -221,6 +326,22 @@ func (m *Manager) getFile(filename string) (*os.File, error) {
 	return f, nil
 }
 
+// Stats counts the blocks the file manager read from and wrote to disk, whatever their file.
+type Stats struct {
+	// BlocksRead is the number of blocks read.
+	BlocksRead int
+	// BlocksWritten is the number of blocks written.
+	BlocksWritten int
+}
+
+// Stats returns a snapshot of the numbers of blocks read and written, taken under the lock
+// that reads and writes update them under.
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Stats{BlocksRead: m.blocksRead, BlocksWritten: m.blocksWritten}
+}
+
 // GetBlocksRead returns the total number of blocks read.
 func (m *Manager) GetBlocksRead() int {
 	m.mu.Lock()
@@ -228,6 +349,13 @@ func (m *Manager) GetBlocksRead() int {
 	return m.blocksRead
 }
 
+// GetBlocksReadFrom returns the number of blocks read from the specified file.
+func (m *Manager) GetBlocksReadFrom(filename string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fileReads[filename]
+}
+
 // GetBlocksWritten returns the total number of blocks written.
 func (m *Manager) GetBlocksWritten() int {
 	m.mu.Lock()
@@ -3,6 +3,7 @@ package file
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"os"
 	"path/filepath"
 	"sync"
@@ -182,3 +183,30 @@ func TestFileManager(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+func TestManager_Remove(t *testing.T) {
+	dir := t.TempDir()
+	mgr, err := NewManager(dir, 400)
+	require.NoError(t, err)
+
+	_, err = mgr.Append("plain.tbl")
+	require.NoError(t, err)
+	require.NoError(t, mgr.EnableCompression("packed.tbl"))
+	_, err = mgr.Append("packed.tbl")
+	require.NoError(t, err)
+
+	require.NoError(t, mgr.Remove("plain.tbl"))
+	require.NoError(t, mgr.Remove("packed.tbl"))
+	require.NoError(t, mgr.Remove("missing.tbl"))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the files and the companion files of compressed files should be removed")
+
+	// A removed file starts over empty and uncompressed.
+	length, err := mgr.Length("packed.tbl")
+	require.NoError(t, err)
+	assert.Zero(t, length)
+	compressed, err := mgr.IsCompressed("packed.tbl")
+	require.NoError(t, err)
+	assert.False(t, compressed)
+}
@@ -3,12 +3,37 @@ package file
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/JyotinderSingh/dropdb/types"
+	"math"
 	"runtime"
 	"time"
 	"unicode/utf8"
 )
 
+// ErrCorruptValue is returned when a value stored in a page cannot be read because its
+// length prefix or offset points outside of the page.
+var ErrCorruptValue = errors.New("corrupt value")
+
+// CorruptValueError describes a value that could not be read from a page.
+// It unwraps to ErrCorruptValue. Block is nil when the page is not known to hold a block.
+type CorruptValueError struct {
+	Block  *BlockId
+	Offset int
+	Length int
+}
+
+func (e *CorruptValueError) Error() string {
+	if e.Block == nil {
+		return fmt.Sprintf("%v at offset %d: claimed length %d", ErrCorruptValue, e.Offset, e.Length)
+	}
+	return fmt.Sprintf("%v in block %s at offset %d: claimed length %d", ErrCorruptValue, e.Block, e.Offset, e.Length)
+}
+
+func (e *CorruptValueError) Unwrap() error {
+	return ErrCorruptValue
+}
+
 // Page represents a page in the database file.
 // A page is a fixed-size block of data that is read from or written to disk as a unit.
 // The size of a page is determined by the file manager and is typically a multiple of the disk block size.
@@ -56,13 +81,20 @@ func (p *Page) SetLong(offset int, n int64) {
 }
 
 // GetBytes retrieves a byte slice from the buffer starting at the specified offset.
-func (p *Page) GetBytes(offset int) []byte {
+// It returns a *CorruptValueError, without allocating, if the length prefix or the
+// bytes it claims do not fit in the page.
+func (p *Page) GetBytes(offset int) ([]byte, error) {
+	if offset < 0 || offset > len(p.buffer)-types.IntSize {
+		return nil, &CorruptValueError{Offset: offset, Length: -1}
+	}
 	length := p.GetInt(offset)
 	start := offset + types.IntSize
-	end := start + int(length)
+	if length < 0 || length > len(p.buffer)-start {
+		return nil, &CorruptValueError{Offset: offset, Length: length}
+	}
 	b := make([]byte, length)
-	copy(b, p.buffer[start:end])
-	return b
+	copy(b, p.buffer[start:start+length])
+	return b, nil
 }
 
 // SetBytes writes a byte slice to the buffer starting at the specified offset.
@@ -75,7 +107,10 @@ func (p *Page) SetBytes(offset int, b []byte) {
 
 // GetString retrieves a string from the buffer at the specified offset.
 func (p *Page) GetString(offset int) (string, error) {
-	b := p.GetBytes(offset)
+	b, err := p.GetBytes(offset)
+	if err != nil {
+		return "", err
+	}
 	if !utf8.Valid(b) {
 		return "", errors.New("invalid UTF-8 encoding")
 	}
@@ -101,6 +136,16 @@ func (p *Page) SetShort(offset int, n int16) {
 	binary.BigEndian.PutUint16(p.buffer[offset:], uint16(n))
 }
 
+// GetFloat retrieves a 64-bit floating point number from the buffer at the specified offset.
+func (p *Page) GetFloat(offset int) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(p.buffer[offset:]))
+}
+
+// SetFloat writes a 64-bit floating point number to the buffer at the specified offset.
+func (p *Page) SetFloat(offset int, f float64) {
+	binary.BigEndian.PutUint64(p.buffer[offset:], math.Float64bits(f))
+}
+
 // GetBool retrieves a boolean from the buffer at the specified offset.
 func (p *Page) GetBool(offset int) bool {
 	return p.buffer[offset] != 0
@@ -126,13 +171,20 @@ func (p *Page) SetDate(offset int, date time.Time) {
 	binary.BigEndian.PutUint64(p.buffer[offset:], uint64(date.Unix()))
 }
 
-// MaxLength calculates the maximum number of bytes required to store a string of a given length.
+// MaxLength returns the number of bytes reserved for a string of at most the specified number of characters,
+// such as a varchar(n) field: the length prefix, and utf8.UTFMax bytes for each character, since a string is stored
+// as its UTF-8 encoding, prefixed by its length in bytes (see SetBytes).
+// Use EncodedLength for the number of bytes taken by a given string.
 func MaxLength(strlen int) int {
-	// Golang uses UTF-8 encoding
-	// Add utils.IntSize bytes for the length prefix.
 	return types.IntSize + strlen*utf8.UTFMax
 }
 
+// EncodedLength returns the number of bytes the specified string takes in a page:
+// the length prefix, followed by the bytes of its UTF-8 encoding.
+func EncodedLength(s string) int {
+	return types.IntSize + len(s)
+}
+
 // Contents returns the byte buffer maintained by the Page.
 func (p *Page) Contents() []byte {
 	return p.buffer
@@ -4,6 +4,7 @@ import (
 	"github.com/JyotinderSingh/dropdb/types"
 	"github.com/stretchr/testify/assert"
 	"math"
+	"math/rand"
 	"testing"
 	"unicode/utf8"
 )
@@ -46,6 +47,28 @@ func TestPage(t *testing.T) {
 		}
 	})
 
+	t.Run("FloatOperations", func(t *testing.T) {
+		assert := assert.New(t)
+		page := NewPage(100)
+		testCases := []struct {
+			offset int
+			value  float64
+		}{
+			{0, 19.99},
+			{8, -0.5},
+			{16, 0},
+			{24, math.MaxFloat64},
+			{32, math.SmallestNonzeroFloat64},
+			{40, math.Inf(-1)},
+		}
+
+		for _, tc := range testCases {
+			page.SetFloat(tc.offset, tc.value)
+			got := page.GetFloat(tc.offset)
+			assert.Equal(tc.value, got, "Float value at offset %d should match", tc.offset)
+		}
+	})
+
 	t.Run("BytesOperations", func(t *testing.T) {
 		assert := assert.New(t)
 		page := NewPage(100)
@@ -61,7 +84,8 @@ func TestPage(t *testing.T) {
 
 		for _, tc := range testCases {
 			page.SetBytes(tc.offset, tc.data)
-			got := page.GetBytes(tc.offset)
+			got, err := page.GetBytes(tc.offset)
+			assert.NoError(err)
 			assert.Equal(tc.data, got, "Byte data at offset %d should match", tc.offset)
 		}
 	})
@@ -158,3 +182,72 @@ func TestPage(t *testing.T) {
 		assert.Equal(string(largeString), got, "Large string content should match")
 	})
 }
+
+func TestPage_CorruptLengths(t *testing.T) {
+	blockSize := 100
+	tests := []struct {
+		name   string
+		offset int
+		length int
+	}{
+		{"negative length", 0, -1},
+		{"most negative length", 0, math.MinInt},
+		{"length past block end", 10, blockSize},
+		{"huge length", 0, math.MaxInt},
+		{"one byte too long", 20, blockSize - 20 - types.IntSize + 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			page := NewPage(blockSize)
+			page.SetInt(tc.offset, tc.length)
+
+			_, err := page.GetBytes(tc.offset)
+			assert.ErrorIs(t, err, ErrCorruptValue)
+
+			_, err = page.GetString(tc.offset)
+			assert.ErrorIs(t, err, ErrCorruptValue)
+			var corrupt *CorruptValueError
+			if assert.ErrorAs(t, err, &corrupt) {
+				assert.Equal(t, tc.offset, corrupt.Offset)
+				assert.Equal(t, tc.length, corrupt.Length)
+			}
+		})
+	}
+
+	t.Run("length filling the block", func(t *testing.T) {
+		page := NewPage(blockSize)
+		page.SetInt(0, blockSize-types.IntSize)
+		b, err := page.GetBytes(0)
+		assert.NoError(t, err)
+		assert.Len(t, b, blockSize-types.IntSize)
+	})
+
+	t.Run("offset outside the block", func(t *testing.T) {
+		page := NewPage(blockSize)
+		for _, offset := range []int{-1, blockSize - types.IntSize + 1, blockSize, blockSize * 10} {
+			_, err := page.GetString(offset)
+			assert.ErrorIs(t, err, ErrCorruptValue, "offset %d", offset)
+		}
+	})
+}
+
+func TestPage_RandomReadsNeverPanic(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, blockSize := range []int{0, 1, types.IntSize, 64, 400} {
+		page := NewPage(blockSize)
+		for i := 0; i < 2000; i++ {
+			rng.Read(page.Contents())
+			offset := rng.Intn(blockSize+2*types.IntSize+1) - types.IntSize
+			assert.NotPanics(t, func() {
+				b, err := page.GetBytes(offset)
+				if err == nil {
+					assert.LessOrEqual(t, len(b), blockSize)
+				} else {
+					assert.ErrorIs(t, err, ErrCorruptValue)
+				}
+				_, _ = page.GetString(offset)
+			}, "block size %d, offset %d", blockSize, offset)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package btree
+
+import (
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// pathEntry is a directory block on the path from the root to a leaf,
+// along with the slot of the entry whose child the path goes through.
+type pathEntry struct {
+	block *file.BlockId
+	slot  int
+}
+
+// searchPath returns the directory blocks on the path from the root to the leaf holding the specified search key,
+// starting with the root, and the number of the leaf.
+func (idx *Index) searchPath(searchKey any) ([]pathEntry, int, error) {
+	var path []pathEntry
+	block := idx.rootBlock
+	for {
+		directory, err := NewPage(idx.transaction, block, idx.directoryLayout)
+		if err != nil {
+			return nil, -1, err
+		}
+		slot, err := directory.findChildSlot(searchKey)
+		if err != nil {
+			directory.Close()
+			return nil, -1, err
+		}
+		child, err := directory.GetChildNumber(slot)
+		if err != nil {
+			directory.Close()
+			return nil, -1, err
+		}
+		level, err := directory.GetFlag()
+		directory.Close()
+		if err != nil {
+			return nil, -1, err
+		}
+
+		path = append(path, pathEntry{block: block, slot: slot})
+		if level == 0 {
+			return path, child, nil
+		}
+		block = file.NewBlockId(block.Filename(), child)
+	}
+}
+
+// deleteFromLeaf deletes the record having the specified search key and data record ID from the specified leaf,
+// or from its overflow blocks. Returns false if there is no such record.
+func (idx *Index) deleteFromLeaf(leafBlock *file.BlockId, searchKey any, dataRID *record.ID) (bool, error) {
+	leaf, err := NewPage(idx.transaction, leafBlock, idx.leafLayout)
+	if err != nil {
+		return false, err
+	}
+	defer leaf.Close()
+
+	records, err := leaf.GetNumberOfRecords()
+	if err != nil {
+		return false, err
+	}
+	slot, err := leaf.FindSlotBefore(searchKey)
+	if err != nil {
+		return false, err
+	}
+	for slot++; slot < records; slot++ {
+		dataVal, err := leaf.GetDataVal(slot)
+		if err != nil {
+			return false, err
+		}
+		if !types.CompareSupportedTypes(dataVal, searchKey, types.EQ) {
+			break
+		}
+		currentRID, err := leaf.getDataRID(slot)
+		if err != nil {
+			return false, err
+		}
+		if currentRID.Equals(dataRID) {
+			return true, idx.deleteLeafRecord(leaf, slot)
+		}
+	}
+
+	// The overflow blocks of the leaf hold more records of its first key.
+	if records == 0 {
+		return false, nil
+	}
+	firstKey, err := leaf.GetDataVal(0)
+	if err != nil || !types.CompareSupportedTypes(firstKey, searchKey, types.EQ) {
+		return false, err
+	}
+	return idx.deleteFromOverflow(leaf, dataRID)
+}
+
+// deleteLeafRecord deletes the record at the specified slot of the leaf. If the leaf has overflow blocks,
+// the records of its first key stay in the leaf for as long as the overflow blocks hold some, so that the
+// overflow blocks are still found: a deleted record of the first key is replaced by one moved from the first overflow block.
+func (idx *Index) deleteLeafRecord(leaf *Page, slot int) error {
+	overflow, err := leaf.GetFlag()
+	if err != nil {
+		return err
+	}
+	if overflow < 0 {
+		return leaf.delete(slot)
+	}
+	firstKey, err := leaf.GetDataVal(0)
+	if err != nil {
+		return err
+	}
+	deletedKey, err := leaf.GetDataVal(slot)
+	if err != nil {
+		return err
+	}
+	if !types.CompareSupportedTypes(deletedKey, firstKey, types.EQ) {
+		return leaf.delete(slot)
+	}
+
+	overflowPage, err := NewPage(idx.transaction, file.NewBlockId(idx.leafTable, overflow), idx.leafLayout)
+	if err != nil {
+		return err
+	}
+	defer overflowPage.Close()
+	if err := leaf.delete(slot); err != nil {
+		return err
+	}
+	if err := overflowPage.moveRecord(0, leaf, 0); err != nil {
+		return err
+	}
+	return idx.unlinkIfEmpty(leaf, overflowPage)
+}
+
+// deleteFromOverflow deletes the record having the specified data record ID from the overflow blocks of the leaf.
+// Returns false if there is no such record.
+func (idx *Index) deleteFromOverflow(leaf *Page, dataRID *record.ID) (bool, error) {
+	previous := leaf
+	// The overflow blocks stay open until the next one is read, which may have to be unlinked from them.
+	defer func() {
+		if previous != leaf {
+			previous.Close()
+		}
+	}()
+	for {
+		overflow, err := previous.GetFlag()
+		if err != nil || overflow < 0 {
+			return false, err
+		}
+		page, err := NewPage(idx.transaction, file.NewBlockId(idx.leafTable, overflow), idx.leafLayout)
+		if err != nil {
+			return false, err
+		}
+
+		records, err := page.GetNumberOfRecords()
+		if err != nil {
+			page.Close()
+			return false, err
+		}
+		for slot := 0; slot < records; slot++ {
+			currentRID, err := page.getDataRID(slot)
+			if err != nil {
+				page.Close()
+				return false, err
+			}
+			if currentRID.Equals(dataRID) {
+				defer page.Close()
+				if err := page.delete(slot); err != nil {
+					return false, err
+				}
+				return true, idx.unlinkIfEmpty(previous, page)
+			}
+		}
+		if previous != leaf {
+			previous.Close()
+		}
+		previous = page
+	}
+}
+
+// unlinkIfEmpty removes the specified overflow block from the chain of overflow blocks if it holds no more records,
+// linking the previous block of the chain to the next one, and frees it.
+func (idx *Index) unlinkIfEmpty(previous, overflowPage *Page) error {
+	records, err := overflowPage.GetNumberOfRecords()
+	if err != nil || records > 0 {
+		return err
+	}
+	next, err := overflowPage.GetFlag()
+	if err != nil {
+		return err
+	}
+	if err := previous.SetFlag(next); err != nil {
+		return err
+	}
+	return freeBlock(idx.transaction, overflowPage.currentBlk)
+}
+
+// rebalance restores the fill of the pages on the path to a leaf a record was deleted from. An underfull page
+// is merged with a sibling if their records fit in one page, removing the entry of one of them from their
+// parent directory block, which may become underfull in turn. Otherwise, records are moved from the sibling,
+// so that both pages are about as full. Once the root has a single child, the child becomes the root,
+// which shortens the paths to the leaves.
+func (idx *Index) rebalance(path []pathEntry, leafNumber int) error {
+	childFile, childLayout, childNumber := idx.leafTable, idx.leafLayout, leafNumber
+	for level := len(path) - 1; level >= 0; level-- {
+		underfull, err := idx.isUnderfull(file.NewBlockId(childFile, childNumber), childLayout)
+		if err != nil || !underfull {
+			return err
+		}
+		merged, err := idx.rebalanceChildren(path[level], childFile, childLayout)
+		if err != nil || !merged {
+			return err
+		}
+		childFile, childLayout, childNumber = idx.rootBlock.Filename(), idx.directoryLayout, path[level].block.Number()
+	}
+	return idx.collapseRoot()
+}
+
+// isUnderfull returns true if the page of the specified block is less than half full.
+func (idx *Index) isUnderfull(block *file.BlockId, layout *record.Layout) (bool, error) {
+	page, err := NewPage(idx.transaction, block, layout)
+	if err != nil {
+		return false, err
+	}
+	defer page.Close()
+	records, err := page.GetNumberOfRecords()
+	if err != nil {
+		return false, err
+	}
+	return page.isUnderfull(records), nil
+}
+
+// rebalanceChildren merges the child of the directory entry on the path with a sibling, or moves records
+// between them. The sibling is the child of the next entry, or of the previous entry for the last one.
+// Returns true if the children were merged, removing an entry from the directory block.
+func (idx *Index) rebalanceChildren(entry pathEntry, childFile string, childLayout *record.Layout) (bool, error) {
+	directory, err := NewPage(idx.transaction, entry.block, idx.directoryLayout)
+	if err != nil {
+		return false, err
+	}
+	defer directory.Close()
+
+	entries, err := directory.GetNumberOfRecords()
+	if err != nil || entries < 2 {
+		return false, err
+	}
+	left := entry.slot
+	if left == entries-1 {
+		left--
+	}
+	leftNumber, err := directory.GetChildNumber(left)
+	if err != nil {
+		return false, err
+	}
+	rightNumber, err := directory.GetChildNumber(left + 1)
+	if err != nil {
+		return false, err
+	}
+	leftPage, err := NewPage(idx.transaction, file.NewBlockId(childFile, leftNumber), childLayout)
+	if err != nil {
+		return false, err
+	}
+	defer leftPage.Close()
+	rightPage, err := NewPage(idx.transaction, file.NewBlockId(childFile, rightNumber), childLayout)
+	if err != nil {
+		return false, err
+	}
+	defer rightPage.Close()
+
+	isLeaf := childFile == idx.leafTable
+	if isLeaf {
+		// The overflow blocks of the right leaf hold the records of its first key, which must stay first.
+		overflow, err := rightPage.GetFlag()
+		if err != nil || overflow >= 0 {
+			return false, err
+		}
+	}
+
+	leftRecords, err := leftPage.GetNumberOfRecords()
+	if err != nil {
+		return false, err
+	}
+	rightRecords, err := rightPage.GetNumberOfRecords()
+	if err != nil {
+		return false, err
+	}
+	if leftPage.fits(leftRecords + rightRecords) {
+		if err := rightPage.transferRecords(0, leftPage, leftRecords); err != nil {
+			return false, err
+		}
+		if err := directory.delete(left + 1); err != nil {
+			return false, err
+		}
+		return true, freeBlock(idx.transaction, rightPage.currentBlk)
+	}
+
+	if err := redistribute(leftPage, rightPage, isLeaf); err != nil {
+		return false, err
+	}
+	firstKey, err := rightPage.GetDataVal(0)
+	if err != nil {
+		return false, err
+	}
+	return false, directory.setDataVal(left+1, firstKey)
+}
+
+// redistribute moves records between two sibling pages, from the fuller to the emptier one, for as long as
+// it brings their numbers of records closer. The records of a leaf move together with the other records of
+// their search key, since a search only reads the leaf its directory entry leads to.
+func redistribute(leftPage, rightPage *Page, isLeaf bool) error {
+	for {
+		leftRecords, err := leftPage.GetNumberOfRecords()
+		if err != nil {
+			return err
+		}
+		rightRecords, err := rightPage.GetNumberOfRecords()
+		if err != nil {
+			return err
+		}
+
+		if leftRecords < rightRecords {
+			moved, err := keyGroupSize(rightPage, 0, 1, rightRecords, isLeaf)
+			if err != nil || leftRecords+moved > rightRecords-moved {
+				return err
+			}
+			for range moved {
+				if err := rightPage.moveRecord(0, leftPage, leftRecords); err != nil {
+					return err
+				}
+				leftRecords++
+			}
+		} else {
+			moved, err := keyGroupSize(leftPage, leftRecords-1, -1, leftRecords, isLeaf)
+			if err != nil || rightRecords+moved > leftRecords-moved {
+				return err
+			}
+			for i := range moved {
+				if err := leftPage.moveRecord(leftRecords-1-i, rightPage, 0); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// keyGroupSize returns the number of consecutive records of the page having the search key of the record
+// at the specified slot, counting from that slot in the specified direction. Each directory entry is a group of its own.
+func keyGroupSize(page *Page, slot, direction, records int, isLeaf bool) (int, error) {
+	if !isLeaf {
+		return 1, nil
+	}
+	key, err := page.GetDataVal(slot)
+	if err != nil {
+		return 0, err
+	}
+	size := 1
+	for next := slot + direction; next >= 0 && next < records; next += direction {
+		val, err := page.GetDataVal(next)
+		if err != nil {
+			return 0, err
+		}
+		if !types.CompareSupportedTypes(val, key, types.EQ) {
+			break
+		}
+		size++
+	}
+	return size, nil
+}
+
+// collapseRoot moves the entries of the single child of the root directory block into the root,
+// for as long as the root has a single child that is a directory block, and frees the child.
+func (idx *Index) collapseRoot() error {
+	root, err := NewPage(idx.transaction, idx.rootBlock, idx.directoryLayout)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	for {
+		level, err := root.GetFlag()
+		if err != nil {
+			return err
+		}
+		entries, err := root.GetNumberOfRecords()
+		if err != nil || level == 0 || entries > 1 {
+			return err
+		}
+		childNumber, err := root.GetChildNumber(0)
+		if err != nil {
+			return err
+		}
+		child, err := NewPage(idx.transaction, file.NewBlockId(idx.rootBlock.Filename(), childNumber), idx.directoryLayout)
+		if err != nil {
+			return err
+		}
+		err = root.delete(0)
+		if err == nil {
+			err = child.transferRecords(0, root, 0)
+		}
+		if err == nil {
+			err = root.SetFlag(level - 1)
+		}
+		if err == nil {
+			err = freeBlock(idx.transaction, child.currentBlk)
+		}
+		child.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
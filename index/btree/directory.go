@@ -200,20 +200,11 @@ func (d *Directory) insertEntry(entry *DirectoryEntry) (*DirectoryEntry, error)
 
 // findChildBlock locates the appropriate child block for a given search key.
 func (d *Directory) findChildBlock(searchKey any) (*file.BlockId, error) {
-	slot, err := d.contents.FindSlotBefore(searchKey)
+	slot, err := d.contents.findChildSlot(searchKey)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if we need to move to the next slot
-	nextVal, err := d.contents.GetDataVal(slot + 1)
-	if err != nil {
-		return nil, err
-	}
-	if types.CompareSupportedTypes(nextVal, searchKey, types.EQ) {
-		slot++
-	}
-
 	// Get the child block number
 	childNum, err := d.contents.GetChildNumber(slot)
 	if err != nil {
@@ -222,3 +213,26 @@ func (d *Directory) findChildBlock(searchKey any) (*file.BlockId, error) {
 
 	return file.NewBlockId(d.filename, childNum), nil
 }
+
+// findChildSlot returns the slot of the directory entry whose child holds the specified search key.
+func (p *Page) findChildSlot(searchKey any) (int, error) {
+	slot, err := p.FindSlotBefore(searchKey)
+	if err != nil {
+		return -1, err
+	}
+
+	// Check if we need to move to the next slot. The slots past the last entry may still hold
+	// the entries removed by a merge, which must not be followed.
+	numberOfRecords, err := p.GetNumberOfRecords()
+	if err != nil || slot+1 >= numberOfRecords {
+		return slot, err
+	}
+	nextVal, err := p.GetDataVal(slot + 1)
+	if err != nil {
+		return -1, err
+	}
+	if types.CompareSupportedTypes(nextVal, searchKey, types.EQ) {
+		slot++
+	}
+	return slot, nil
+}
@@ -0,0 +1,131 @@
+package btree
+
+import (
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// freeListSuffix is the suffix of the file holding the free list of a b-tree file, whose single block
+// holds the number of the first free block, and the number of free blocks. Each free block holds
+// the number of the next one in its flag, and no records.
+const freeListSuffix = "_free"
+
+// noFreeBlock is the number of the first free block of an empty free list.
+const noFreeBlock = -1
+
+// freeListBlock returns the block holding the free list of the specified b-tree file.
+func freeListBlock(filename string) *file.BlockId {
+	return file.NewBlockId(filename+freeListSuffix, 0)
+}
+
+// freeBlock adds the specified block, which no page of the b-tree refers to anymore, to the free list of its file,
+// so that it is reused by a later split (see allocateBlock) rather than growing the file.
+func freeBlock(transaction *tx.Transaction, block *file.BlockId) error {
+	listBlock := freeListBlock(block.Filename())
+	size, err := transaction.Size(listBlock.Filename())
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		if _, err := transaction.Append(listBlock.Filename()); err != nil {
+			return err
+		}
+	}
+	if err := transaction.Pin(listBlock); err != nil {
+		return err
+	}
+	defer transaction.Unpin(listBlock)
+	if size == 0 {
+		if err := transaction.SetInt(listBlock, 0, noFreeBlock, true); err != nil {
+			return err
+		}
+		if err := transaction.SetInt(listBlock, types.IntSize, 0, true); err != nil {
+			return err
+		}
+	}
+
+	head, err := transaction.GetInt(listBlock, 0)
+	if err != nil {
+		return err
+	}
+	count, err := transaction.GetInt(listBlock, types.IntSize)
+	if err != nil {
+		return err
+	}
+
+	if err := transaction.Pin(block); err != nil {
+		return err
+	}
+	defer transaction.Unpin(block)
+	if err := transaction.SetInt(block, 0, head, true); err != nil {
+		return err
+	}
+	if err := transaction.SetInt(block, types.IntSize, 0, true); err != nil {
+		return err
+	}
+	if err := transaction.SetInt(listBlock, 0, block.Number(), true); err != nil {
+		return err
+	}
+	return transaction.SetInt(listBlock, types.IntSize, count+1, true)
+}
+
+// allocateBlock returns a block of the specified b-tree file for a new page, which is the first free block
+// of the file, if any, or else a block appended to the file.
+func allocateBlock(transaction *tx.Transaction, filename string) (*file.BlockId, error) {
+	listBlock := freeListBlock(filename)
+	size, err := transaction.Size(listBlock.Filename())
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return transaction.Append(filename)
+	}
+	if err := transaction.Pin(listBlock); err != nil {
+		return nil, err
+	}
+	defer transaction.Unpin(listBlock)
+
+	head, err := transaction.GetInt(listBlock, 0)
+	if err != nil {
+		return nil, err
+	}
+	if head == noFreeBlock {
+		return transaction.Append(filename)
+	}
+	count, err := transaction.GetInt(listBlock, types.IntSize)
+	if err != nil {
+		return nil, err
+	}
+
+	block := file.NewBlockId(filename, head)
+	if err := transaction.Pin(block); err != nil {
+		return nil, err
+	}
+	defer transaction.Unpin(block)
+	next, err := transaction.GetInt(block, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := transaction.SetInt(listBlock, 0, next, true); err != nil {
+		return nil, err
+	}
+	if err := transaction.SetInt(listBlock, types.IntSize, count-1, true); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// freeBlockCount returns the number of free blocks of the specified b-tree file.
+func freeBlockCount(transaction *tx.Transaction, filename string) (int, error) {
+	listBlock := freeListBlock(filename)
+	size, err := transaction.Size(listBlock.Filename())
+	if err != nil || size == 0 {
+		return 0, err
+	}
+	if err := transaction.Pin(listBlock); err != nil {
+		return 0, err
+	}
+	defer transaction.Unpin(listBlock)
+	return transaction.GetInt(listBlock, types.IntSize)
+}
@@ -1,6 +1,7 @@
 package btree
 
 import (
+	"errors"
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/index"
@@ -8,7 +9,6 @@ import (
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/types"
-	"math"
 	"time"
 )
 
@@ -26,6 +26,9 @@ type Index struct {
 	leafTable       string
 	leaf            *Leaf
 	rootBlock       *file.BlockId
+	// rangeScan is the position of the index in a range of search keys (see BeforeFirstRange),
+	// or nil if it is positioned at a search key by BeforeFirst.
+	rangeScan *rangeScan
 }
 
 // NewIndex opens a b-tree index for the specified index.
@@ -62,7 +65,10 @@ func NewIndex(transaction *tx.Transaction, indexName string, leafLayout *record.
 	// Deal with the directory
 	directorySchema := record.NewSchema()
 	directorySchema.Add(common.BlockField, leafLayout.Schema())
-	directorySchema.Add(common.DataValueField, leafLayout.Schema())
+	keyFields := common.KeyFields(leafLayout.Schema())
+	for _, fieldName := range keyFields {
+		directorySchema.Add(fieldName, leafLayout.Schema())
+	}
 
 	directoryTable := indexName + directorySuffix
 	idx.directoryLayout = record.NewLayout(directorySchema)
@@ -88,40 +94,42 @@ func NewIndex(transaction *tx.Transaction, indexName string, leafLayout *record.
 		}
 
 		// insert initial directory entry
-		fieldType := directorySchema.Type(common.DataValueField)
-		switch fieldType {
-		case types.Integer:
-			if err = node.InsertDirectory(0, 0, 0); err != nil {
-				return nil, err
-			}
-		case types.Varchar:
-			if err = node.InsertDirectory(0, "", 0); err != nil {
-				return nil, err
-			}
-		case types.Boolean:
-			if err = node.InsertDirectory(0, false, 0); err != nil {
-				return nil, err
-			}
-		case types.Long:
-			if err = node.InsertDirectory(0, int64(0), 0); err != nil {
-				return nil, err
-			}
-		case types.Short:
-			if err = node.InsertDirectory(0, int16(0), 0); err != nil {
-				return nil, err
-			}
-		case types.Date:
-			if err = node.InsertDirectory(0, time.Time{}, 0); err != nil {
-				return nil, err
-			}
-		default:
-			return nil, fmt.Errorf("unsupported type: %T", fieldType)
+		key, err := common.GetKey(func(fieldName string) (any, error) {
+			return zeroValue(directorySchema.Type(fieldName))
+		}, keyFields)
+		if err != nil {
+			return nil, err
+		}
+		if err = node.InsertDirectory(0, key, 0); err != nil {
+			return nil, err
 		}
 		node.Close()
 	}
 	return idx, nil
 }
 
+// zeroValue returns the zero value of the specified type, which is the key of the initial directory entry.
+func zeroValue(fieldType types.SchemaType) (any, error) {
+	switch fieldType {
+	case types.Integer:
+		return 0, nil
+	case types.Float:
+		return float64(0), nil
+	case types.Varchar:
+		return "", nil
+	case types.Boolean:
+		return false, nil
+	case types.Long:
+		return int64(0), nil
+	case types.Short:
+		return int16(0), nil
+	case types.Date:
+		return time.Time{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type: %T", fieldType)
+	}
+}
+
 // BeforeFirst traverses the directory to find the leaf block
 // corresponding to the specified search key.
 // The method then opens a page for that leaf block, and
@@ -146,14 +154,20 @@ func (idx *Index) BeforeFirst(searchKey interface{}) error {
 	return err
 }
 
-// Next moves to the next record having the previously specified search key.
+// Next moves to the next record having the previously specified search key, or in the previously specified range.
 // Returns false if there are no more such records.
 func (idx *Index) Next() (bool, error) {
+	if idx.rangeScan != nil {
+		return idx.rangeScan.next(idx)
+	}
 	return idx.leaf.Next()
 }
 
 // GetDataRecordID returns the record ID of the current leaf record.
 func (idx *Index) GetDataRecordID() (*record.ID, error) {
+	if idx.rangeScan != nil {
+		return idx.rangeScan.dataRecordID()
+	}
 	return idx.leaf.GetDataRID()
 }
 
@@ -204,18 +218,20 @@ func (idx *Index) Insert(dataVal any, dataRID *record.ID) error {
 // Delete deletes the specified index record.
 // The method first traverses the directory to find the
 // leaf page containing the record, then it deletes the
-// record from the page.
+// record from the page, or from its overflow blocks.
+// If the leaf becomes less than half full, it is merged with
+// a sibling or takes records from it (see rebalance).
 func (idx *Index) Delete(dataVal any, dataRID *record.ID) error {
-	if err := idx.BeforeFirst(dataVal); err != nil {
+	idx.Close()
+	path, leafNumber, err := idx.searchPath(dataVal)
+	if err != nil {
 		return err
 	}
-
-	if err := idx.leaf.Delete(dataRID); err != nil {
+	deleted, err := idx.deleteFromLeaf(file.NewBlockId(idx.leafTable, leafNumber), dataVal, dataRID)
+	if err != nil || !deleted {
 		return err
 	}
-
-	idx.leaf.Close()
-	return nil
+	return idx.rebalance(path, leafNumber)
 }
 
 // Close closes the index by closing the current leaf page, if necessary.
@@ -223,11 +239,96 @@ func (idx *Index) Close() {
 	if idx.leaf != nil {
 		idx.leaf.Close()
 	}
+	if idx.rangeScan != nil {
+		idx.rangeScan.close()
+		idx.rangeScan = nil
+	}
+}
+
+// Drop closes the index and deletes the files of its leaves and of its directory, along with their free lists.
+func (idx *Index) Drop() error {
+	idx.Close()
+	var errs []error
+	for _, filename := range []string{idx.leafTable, idx.rootBlock.Filename()} {
+		errs = append(errs, idx.transaction.RemoveFile(filename), idx.transaction.RemoveFile(filename+freeListSuffix))
+	}
+	return errors.Join(errs...)
+}
+
+// ForEach calls visit with each record of each leaf block, including the overflow blocks.
+// The free blocks hold no records.
+func (idx *Index) ForEach(visit func(dataValue any, dataRecordID *record.ID) error) error {
+	leaves, err := idx.transaction.Size(idx.leafTable)
+	if err != nil {
+		return err
+	}
+	for blockNumber := 0; blockNumber < leaves; blockNumber++ {
+		if err := idx.visitLeaf(file.NewBlockId(idx.leafTable, blockNumber), visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visitLeaf calls visit with each record of the specified leaf block.
+func (idx *Index) visitLeaf(block *file.BlockId, visit func(dataValue any, dataRecordID *record.ID) error) error {
+	leaf, err := NewPage(idx.transaction, block, idx.leafLayout)
+	if err != nil {
+		return err
+	}
+	defer leaf.Close()
+	records, err := leaf.GetNumberOfRecords()
+	if err != nil {
+		return err
+	}
+	for slot := 0; slot < records; slot++ {
+		dataValue, err := leaf.GetDataVal(slot)
+		if err != nil {
+			return err
+		}
+		dataRecordID, err := leaf.getDataRID(slot)
+		if err != nil {
+			return err
+		}
+		if err := visit(dataValue, dataRecordID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the height of the b-tree, which is recorded in the flag of its root,
+// and its number of leaf blocks in use, including overflow blocks.
+// The b-tree does not record its number of records.
+func (idx *Index) Stats() (*index.Stats, error) {
+	root, err := NewPage(idx.transaction, idx.rootBlock, idx.directoryLayout)
+	if err != nil {
+		return nil, err
+	}
+	level, err := root.GetFlag()
+	root.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	leaves, err := idx.transaction.Size(idx.leafTable)
+	if err != nil {
+		return nil, err
+	}
+	freeLeaves, err := freeBlockCount(idx.transaction, idx.leafTable)
+	if err != nil {
+		return nil, err
+	}
+	leaves -= freeLeaves
+
+	// The root is at the given level, and directory blocks at level 0 point to the leaves.
+	return &index.Stats{Height: level + 1, Blocks: leaves, Buckets: 1, Records: -1}, nil
 }
 
-// SearchCost returns the estimated number of block accesses
-// required to find all the index records having a particular
-// search key.
-func (idx *Index) SearchCost(numBlocks, recordsPerBlock int) int {
-	return 1 + int(math.Log(float64(numBlocks))/math.Log(float64(recordsPerBlock)))
+// SearchCost returns the estimated number of block accesses required to find
+// the specified number of records having a search key: one block per level
+// of the directory, followed by the leaves holding the records.
+func SearchCost(stats *index.Stats, matchingRecords, recordsPerBlock int) int {
+	leaves := max((matchingRecords+recordsPerBlock-1)/recordsPerBlock, 1)
+	return stats.Height + leaves
 }
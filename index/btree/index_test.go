@@ -10,11 +10,13 @@ import (
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"math"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func setupBTreeIndexTest(t *testing.T) (index.Index, func()) {
@@ -135,15 +137,34 @@ func TestBTreeIndex_Next_NoResults(t *testing.T) {
 }
 
 func TestBTreeIndex_SearchCost(t *testing.T) {
+	stats := &index.Stats{Height: 2, Blocks: 100, Buckets: 1, Records: -1}
+
+	// Two directory blocks, then the leaves holding the matching records.
+	assert.Equal(t, 3, SearchCost(stats, 1, 10))
+	assert.Equal(t, 3, SearchCost(stats, 0, 10))
+	assert.Equal(t, 5, SearchCost(stats, 25, 10))
+}
+
+func TestBTreeIndex_Stats(t *testing.T) {
 	btreeIndex, cleanup := setupBTreeIndexTest(t)
 	defer cleanup()
 
-	numBlocks := 1000
-	recordsPerBlock := 10
+	stats, err := btreeIndex.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, &index.Stats{Height: 1, Blocks: 1, Buckets: 1, Records: -1}, stats)
+
+	// Insert keys until the root splits, giving a root, a level of directory blocks and the leaves.
+	for i := 0; stats.Height < 2; i++ {
+		require.Less(t, i, 10000, "the b-tree should have grown")
+		require.NoError(t, btreeIndex.Insert(fmt.Sprintf("key%05d", i), record.NewID(i, 0)))
+		stats, err = btreeIndex.Stats()
+		require.NoError(t, err)
+	}
+	assert.Greater(t, stats.Blocks, 1)
 
-	cost := btreeIndex.(*Index).SearchCost(numBlocks, recordsPerBlock)
-	expectedCost := 1 + int(math.Log(float64(numBlocks))/math.Log(float64(recordsPerBlock)))
-	assert.Equal(t, expectedCost, cost)
+	// An equality probe reads the root, a directory block and a single leaf.
+	recordsPerLeaf := 800 / btreeIndex.(*Index).leafLayout.SlotSize()
+	assert.Equal(t, 3, SearchCost(stats, 1, recordsPerLeaf))
 }
 
 func TestBTreeIndex_MultipleValues(t *testing.T) {
@@ -281,3 +302,353 @@ func TestBTreeIndex_DirectorySplits(t *testing.T) {
 
 	assert.Equal(t, numRecords, foundCount)
 }
+
+func TestBTreeIndex_ForEach(t *testing.T) {
+	btreeIndex, cleanup := setupBTreeIndexTest(t)
+	defer cleanup()
+
+	// Enough records to split the leaves, with duplicate keys filling overflow blocks.
+	expected := make(map[record.ID]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key_%d", i%40)
+		rid := record.NewID(i/10, i%10)
+		expected[*rid] = key
+		require.NoError(t, btreeIndex.Insert(key, rid))
+	}
+
+	visited := make(map[record.ID]string)
+	require.NoError(t, btreeIndex.ForEach(func(dataValue any, dataRecordID *record.ID) error {
+		visited[*dataRecordID] = dataValue.(string)
+		return nil
+	}))
+	assert.Equal(t, expected, visited)
+}
+
+func TestBTreeIndex_BeforeFirstRange(t *testing.T) {
+	btreeIndex, cleanup := setupBTreeIndexTest(t)
+	defer cleanup()
+	rangeIndex, ok := btreeIndex.(index.RangeIndex)
+	require.True(t, ok)
+
+	// Enough keys to split the root of the directory, in an order that is not the key order,
+	// and enough records of one key to give its leaf overflow blocks.
+	const numKeys = 1500
+	keys := make(map[int]string)
+	for i := 0; i < numKeys; i++ {
+		n := (i * 7919) % numKeys
+		keys[n] = fmt.Sprintf("key_%04d", n)
+		require.NoError(t, btreeIndex.Insert(keys[n], record.NewID(n, 0)))
+	}
+	for i := 1; i <= 60; i++ {
+		require.NoError(t, btreeIndex.Insert(keys[700], record.NewID(700, i)))
+	}
+	stats, err := btreeIndex.Stats()
+	require.NoError(t, err)
+	require.Greater(t, stats.Height, 1)
+
+	// rangeRecords returns the number of records found in the range, for each key.
+	rangeRecords := func(keyRange index.Range) map[int]int {
+		require.NoError(t, rangeIndex.BeforeFirstRange(keyRange))
+		found := make(map[int]int)
+		for {
+			next, err := rangeIndex.Next()
+			require.NoError(t, err)
+			if !next {
+				return found
+			}
+			rid, err := rangeIndex.GetDataRecordID()
+			require.NoError(t, err)
+			found[rid.BlockNumber()]++
+		}
+	}
+	// expected returns the number of records of each key from low to high.
+	expected := func(low, high int) map[int]int {
+		records := make(map[int]int)
+		for n := max(low, 0); n <= min(high, numKeys-1); n++ {
+			records[n] = 1
+			if n == 700 {
+				records[n] = 61
+			}
+		}
+		return records
+	}
+
+	assert.Equal(t, expected(100, 499), rangeRecords(index.Range{Low: "key_0100", LowInclusive: true, High: "key_0500"}))
+	assert.Equal(t, expected(101, 500), rangeRecords(index.Range{Low: "key_0100", High: "key_0500", HighInclusive: true}))
+	assert.Equal(t, expected(650, 750), rangeRecords(index.Range{Low: "key_0650", LowInclusive: true, High: "key_0750", HighInclusive: true}))
+	assert.Equal(t, expected(700, 700), rangeRecords(index.Range{Low: "key_0700", LowInclusive: true, High: "key_0700", HighInclusive: true}))
+	assert.Equal(t, expected(1400, numKeys), rangeRecords(index.Range{Low: "key_1399"}))
+	assert.Equal(t, expected(0, 9), rangeRecords(index.Range{High: "key_0010"}))
+	assert.Equal(t, expected(0, numKeys), rangeRecords(index.Range{}))
+	assert.Empty(t, rangeRecords(index.Range{Low: "key_0500", High: "key_0500", HighInclusive: true}))
+	assert.Empty(t, rangeRecords(index.Range{Low: "zzz"}))
+
+	// Searching a key ends the range scan.
+	require.NoError(t, btreeIndex.BeforeFirst(keys[42]))
+	next, err := btreeIndex.Next()
+	require.NoError(t, err)
+	require.True(t, next)
+	rid, err := btreeIndex.GetDataRecordID()
+	require.NoError(t, err)
+	assert.Equal(t, record.NewID(42, 0), rid)
+}
+
+// Warning: This test is slow
+func TestBTreeIndex_DeleteRebalances(t *testing.T) {
+	btreeIndex, cleanup := setupBTreeIndexTest(t)
+	defer cleanup()
+	rangeIndex, ok := btreeIndex.(index.RangeIndex)
+	require.True(t, ok)
+
+	// Enough keys for a directory of three levels, in an order that is not the key order,
+	// and enough records of one key to give its leaf overflow blocks.
+	const numKeys = 10000
+	key := func(n int) string { return fmt.Sprintf("key_%05d", n) }
+	for i := 0; i < numKeys; i++ {
+		n := (i * 7919) % numKeys
+		require.NoError(t, btreeIndex.Insert(key(n), record.NewID(n, 0)))
+	}
+	for i := 1; i <= 100; i++ {
+		require.NoError(t, btreeIndex.Insert(key(5000), record.NewID(5000, i)))
+	}
+	before, err := btreeIndex.Stats()
+	require.NoError(t, err)
+	require.Greater(t, before.Height, 2)
+
+	// Delete 90% of the keys, and most records of the key with overflow blocks.
+	kept := func(n int) bool { return n%10 == 0 }
+	for i := 0; i < numKeys; i++ {
+		n := (i * 7919) % numKeys
+		if !kept(n) {
+			require.NoError(t, btreeIndex.Delete(key(n), record.NewID(n, 0)))
+		}
+	}
+	for i := 1; i <= 90; i++ {
+		require.NoError(t, btreeIndex.Delete(key(5000), record.NewID(5000, i)))
+	}
+	// Deleting a record that is not there changes nothing.
+	require.NoError(t, btreeIndex.Delete(key(1), record.NewID(1, 0)))
+
+	after, err := btreeIndex.Stats()
+	require.NoError(t, err)
+	assert.Less(t, after.Height, before.Height)
+	assert.Less(t, after.Blocks, before.Blocks/5)
+
+	// search returns the block numbers of the records found for the key.
+	search := func(n int) []int {
+		require.NoError(t, btreeIndex.BeforeFirst(key(n)))
+		var found []int
+		for {
+			next, err := btreeIndex.Next()
+			require.NoError(t, err)
+			if !next {
+				return found
+			}
+			rid, err := btreeIndex.GetDataRecordID()
+			require.NoError(t, err)
+			found = append(found, rid.BlockNumber())
+		}
+	}
+	for n := 0; n < numKeys; n++ {
+		switch {
+		case n == 5000:
+			assert.Len(t, search(n), 11)
+		case kept(n):
+			assert.Equal(t, []int{n}, search(n), key(n))
+		default:
+			assert.Empty(t, search(n), key(n))
+		}
+	}
+
+	require.NoError(t, rangeIndex.BeforeFirstRange(index.Range{Low: key(4000), LowInclusive: true, High: key(6000)}))
+	found := make(map[int]int)
+	for {
+		next, err := rangeIndex.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		rid, err := rangeIndex.GetDataRecordID()
+		require.NoError(t, err)
+		found[rid.BlockNumber()]++
+	}
+	assert.Len(t, found, 200)
+	assert.Equal(t, 11, found[5000])
+
+	// The blocks freed by the deletions are reused by the insertions that follow.
+	fileSize := func() int {
+		size, err := btreeIndex.(*Index).transaction.Size(btreeIndex.(*Index).leafTable)
+		require.NoError(t, err)
+		return size
+	}
+	size := fileSize()
+	for n := 0; n < numKeys/4; n++ {
+		if !kept(n) {
+			require.NoError(t, btreeIndex.Insert(key(n), record.NewID(n, 0)))
+		}
+	}
+	assert.Equal(t, size, fileSize())
+	assert.Equal(t, []int{1}, search(1))
+}
+
+func TestBTreeIndex_CompositeKey(t *testing.T) {
+	btreeIndex, cleanup := setupBTreeIndexTest(t)
+	defer cleanup()
+	transaction := btreeIndex.(*Index).transaction
+
+	schema := record.NewSchema()
+	schema.AddIntField(common.BlockField)
+	schema.AddIntField(common.IDField)
+	schema.AddIntField(common.KeyField(0))
+	schema.AddDateField(common.KeyField(1))
+	compositeIndex, err := NewIndex(transaction, "test_composite_index", record.NewLayout(schema))
+	require.NoError(t, err)
+	defer compositeIndex.Close()
+
+	// Enough keys to split the leaves and the root, in an order that is not the key order.
+	const numKeys = 600
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := func(n int) types.CompositeKey { return types.CompositeKey{n % 6, start.AddDate(0, 0, n/6)} }
+	for i := 0; i < numKeys; i++ {
+		n := (i * 7919) % numKeys
+		require.NoError(t, compositeIndex.Insert(key(n), record.NewID(n, 0)))
+	}
+	stats, err := compositeIndex.Stats()
+	require.NoError(t, err)
+	require.Greater(t, stats.Height, 1)
+
+	for n := 0; n < numKeys; n++ {
+		require.NoError(t, compositeIndex.BeforeFirst(key(n)))
+		next, err := compositeIndex.Next()
+		require.NoError(t, err)
+		require.True(t, next)
+		rid, err := compositeIndex.GetDataRecordID()
+		require.NoError(t, err)
+		assert.Equal(t, n, rid.BlockNumber())
+		next, err = compositeIndex.Next()
+		require.NoError(t, err)
+		assert.False(t, next)
+	}
+
+	// The keys are ordered field by field, so the keys of a value of the first field are consecutive.
+	require.NoError(t, compositeIndex.(index.RangeIndex).BeforeFirstRange(index.Range{
+		Low: types.CompositeKey{2, start.AddDate(0, 0, 10)}, LowInclusive: true,
+		High: types.CompositeKey{2, start.AddDate(0, 0, 20)},
+	}))
+	var found []int
+	for {
+		next, err := compositeIndex.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		rid, err := compositeIndex.GetDataRecordID()
+		require.NoError(t, err)
+		found = append(found, rid.BlockNumber())
+	}
+	var expected []int
+	for day := 10; day < 20; day++ {
+		expected = append(expected, day*6+2)
+	}
+	assert.Equal(t, expected, found)
+
+	require.NoError(t, compositeIndex.Delete(key(62), record.NewID(62, 0)))
+	require.NoError(t, compositeIndex.BeforeFirst(key(62)))
+	next, err := compositeIndex.Next()
+	require.NoError(t, err)
+	assert.False(t, next)
+}
+
+func TestBTreeIndex_UTF8Keys(t *testing.T) {
+	btreeIndex, cleanup := setupBTreeIndexTest(t)
+	defer cleanup()
+
+	// Keys whose length in bytes differs from their number of characters, up to the 20 characters of the key
+	// field with most of them encoded in 4 bytes, inserted often enough to split the leaves.
+	words := []string{"café", "東京都", "👩‍👩‍👧", "e\u0301te\u0301", "한국어", strings.Repeat("🦀", 18), strings.Repeat("é", 18)}
+	key := func(n int) string { return fmt.Sprintf("%s%02d", words[n%len(words)], n/len(words)) }
+	const numKeys = 140
+	for n := 0; n < numKeys; n++ {
+		require.NoError(t, btreeIndex.Insert(key(n), record.NewID(n, 0)))
+	}
+	stats, err := btreeIndex.Stats()
+	require.NoError(t, err)
+	require.Greater(t, stats.Height, 1)
+
+	for n := 0; n < numKeys; n++ {
+		k := key(n)
+		require.NoError(t, btreeIndex.BeforeFirst(k))
+		next, err := btreeIndex.Next()
+		require.NoError(t, err)
+		require.True(t, next, "key %q", k)
+		rid, err := btreeIndex.GetDataRecordID()
+		require.NoError(t, err)
+		assert.Equal(t, n, rid.BlockNumber(), "key %q", k)
+	}
+}
+
+func TestBTreeIndex_NumericKeyTypes(t *testing.T) {
+	btreeIndex, cleanup := setupBTreeIndexTest(t)
+	defer cleanup()
+	transaction := btreeIndex.(*Index).transaction
+
+	schema := record.NewSchema()
+	schema.AddIntField(common.BlockField)
+	schema.AddIntField(common.IDField)
+	schema.AddIntField(common.DataValueField)
+	intIndex, err := NewIndex(transaction, "test_int_index", record.NewLayout(schema))
+	require.NoError(t, err)
+	defer intIndex.Close()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, intIndex.Insert(i%5, record.NewID(i, 0)))
+	}
+	// A short is stored as an integer of the index, and a value that is not a number is rejected.
+	require.NoError(t, intIndex.Insert(int16(3), record.NewID(20, 0)))
+	assert.ErrorIs(t, intIndex.Insert("3", record.NewID(21, 0)), types.ErrIncompatibleValue)
+
+	// An integer key of any size finds the records of the index having its value.
+	for _, key := range []any{3, int16(3), int64(3)} {
+		require.NoError(t, intIndex.BeforeFirst(key))
+		var found []int
+		for {
+			next, err := intIndex.Next()
+			require.NoError(t, err)
+			if !next {
+				break
+			}
+			rid, err := intIndex.GetDataRecordID()
+			require.NoError(t, err)
+			found = append(found, rid.BlockNumber())
+		}
+		assert.ElementsMatch(t, []int{3, 8, 13, 18, 20}, found, "key %T", key)
+	}
+}
+
+func TestBTreeIndex_SplitKeepsFirstKeyRecords(t *testing.T) {
+	btreeIndex, cleanup := setupBTreeIndexTest(t)
+	defer cleanup()
+
+	// Fill leaves whose first half holds only the smallest key, followed by larger keys. Splitting such a leaf
+	// keeps the records of the smallest key in it, and must not send the searches for that key to the new leaf.
+	numRecords := 30
+	for i := 0; i < numRecords; i++ {
+		require.NoError(t, btreeIndex.Insert("key_b", record.NewID(2, i)))
+		require.NoError(t, btreeIndex.Insert("key_a", record.NewID(1, i)))
+		require.NoError(t, btreeIndex.Insert("key_a", record.NewID(1, numRecords+i)))
+	}
+
+	for key, expected := range map[string]int{"key_a": 2 * numRecords, "key_b": numRecords} {
+		require.NoError(t, btreeIndex.BeforeFirst(key))
+		found := 0
+		for {
+			hasNext, err := btreeIndex.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				break
+			}
+			found++
+		}
+		assert.Equal(t, expected, found, key)
+	}
+}
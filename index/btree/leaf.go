@@ -200,16 +200,19 @@ func (l *Leaf) handlePageSplit() (*DirectoryEntry, error) {
 
 	// Adjust split position based on key distribution
 	if types.CompareSupportedTypes(splitKey, firstKey, types.EQ) {
+		// The records of the first key stay in this block, and the new block starts with the key following
+		// them, which must be the key of its directory entry: the directory would otherwise send searches
+		// for the first key to the new block.
 		for {
 			val, err := l.contents.GetDataVal(splitPos)
 			if err != nil {
 				return nil, err
 			}
-			if !types.CompareSupportedTypes(val, splitKey, types.EQ) {
+			if !types.CompareSupportedTypes(val, firstKey, types.EQ) {
+				splitKey = val
 				break
 			}
 			splitPos++
-			splitKey = val
 		}
 	} else {
 		for splitPos > 0 {
@@ -14,6 +14,18 @@ type Page struct {
 	tx         *tx.Transaction
 	currentBlk *file.BlockId
 	layout     *record.Layout
+	// keyFields are the fields of the records holding their search key (see common.KeyFields).
+	keyFields []string
+}
+
+// minRecordsPerPage is the number of records a page must be able to hold,
+// so that a full page can be split in two before a new record is inserted.
+const minRecordsPerPage = 3
+
+// MinBlockSize returns the smallest block size that can hold the header of a page
+// and enough records of the specified layout for the page to be split.
+func MinBlockSize(layout *record.Layout) int {
+	return types.IntSize*2 + minRecordsPerPage*layout.SlotSize()
 }
 
 func NewPage(tx *tx.Transaction, currentBlk *file.BlockId, layout *record.Layout) (*Page, error) {
@@ -24,6 +36,7 @@ func NewPage(tx *tx.Transaction, currentBlk *file.BlockId, layout *record.Layout
 		tx:         tx,
 		currentBlk: currentBlk,
 		layout:     layout,
+		keyFields:  common.KeyFields(layout.Schema()),
 	}, nil
 }
 
@@ -64,7 +77,18 @@ func (p *Page) IsFull() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return p.slotPosition(numberOfRecords+1) >= p.tx.BlockSize(), nil
+	return !p.fits(numberOfRecords), nil
+}
+
+// fits returns true if a page holding the specified number of records is not full, and so is not split.
+func (p *Page) fits(numberOfRecords int) bool {
+	return p.slotPosition(numberOfRecords+1) < p.tx.BlockSize()
+}
+
+// isUnderfull returns true if the page holding the specified number of records is less than half full,
+// so that it should be merged with a sibling, or take records from it.
+func (p *Page) isUnderfull(numberOfRecords int) bool {
+	return p.fits(2*numberOfRecords + 1)
 }
 
 // Split splits the page at the specified position.
@@ -79,7 +103,7 @@ func (p *Page) Split(splitPos, flag int) (*file.BlockId, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := p.transferRecords(splitPos, newPage); err != nil {
+	if err := p.transferRecords(splitPos, newPage, 0); err != nil {
 		return nil, err
 	}
 	if err := newPage.SetFlag(flag); err != nil {
@@ -89,9 +113,19 @@ func (p *Page) Split(splitPos, flag int) (*file.BlockId, error) {
 	return newBlk, nil
 }
 
-// GetDataVal returns the data value of the record at the specified slot.
+// GetDataVal returns the data value of the record at the specified slot,
+// which is a types.CompositeKey if the index is on several fields.
 func (p *Page) GetDataVal(slot int) (any, error) {
-	return p.getVal(slot, common.DataValueField)
+	return common.GetKey(func(fieldName string) (any, error) {
+		return p.getVal(slot, fieldName)
+	}, p.keyFields)
+}
+
+// setDataVal sets the data value of the record at the specified slot.
+func (p *Page) setDataVal(slot int, value any) error {
+	return common.SetKey(func(fieldName string, val any) error {
+		return p.setVal(slot, fieldName, val)
+	}, p.keyFields, value)
 }
 
 // GetFlag returns the page's flag field.
@@ -109,26 +143,29 @@ func (p *Page) SetFlag(val int) error {
 }
 
 // AppendNew appends a new block to the end of the specified b-tree file,
-// having the specified flag value.
+// having the specified flag value. A block freed by a deletion is reused instead, if there is one.
 func (p *Page) AppendNew(flag int) (*file.BlockId, error) {
-	blk, err := p.tx.Append(p.currentBlk.Filename())
+	blk, err := allocateBlock(p.tx, p.currentBlk.Filename())
 	if err != nil {
 		return nil, err
 	}
 	if err := p.tx.Pin(blk); err != nil {
 		return nil, err
 	}
+	defer p.tx.Unpin(blk)
 	if err := p.format(blk, flag); err != nil {
 		return nil, err
 	}
 	return blk, nil
 }
 
+// format initializes the block with the flag and default records. The values are logged so that
+// a follower replaying the log (see tx.LogApplier) formats the block the same way as the primary.
 func (p *Page) format(blk *file.BlockId, flag int) error {
-	if err := p.tx.SetInt(blk, 0, flag, false); err != nil {
+	if err := p.tx.SetInt(blk, 0, flag, true); err != nil {
 		return err
 	}
-	if err := p.tx.SetInt(blk, types.IntSize, 0, false); err != nil {
+	if err := p.tx.SetInt(blk, types.IntSize, 0, true); err != nil {
 		return err
 	}
 	recSize := p.layout.SlotSize()
@@ -146,27 +183,31 @@ func (p *Page) makeDefaultRecord(blk *file.BlockId, pos int) error {
 		offset := p.layout.Offset(field)
 		switch schema.Type(field) {
 		case types.Integer:
-			if err := p.tx.SetInt(blk, pos+offset, 0, false); err != nil {
+			if err := p.tx.SetInt(blk, pos+offset, 0, true); err != nil {
+				return err
+			}
+		case types.Float:
+			if err := p.tx.SetFloat(blk, pos+offset, 0, true); err != nil {
 				return err
 			}
 		case types.Varchar:
-			if err := p.tx.SetString(blk, pos+offset, "", false); err != nil {
+			if err := p.tx.SetString(blk, pos+offset, "", true); err != nil {
 				return err
 			}
 		case types.Boolean:
-			if err := p.tx.SetBool(blk, pos+offset, false, false); err != nil {
+			if err := p.tx.SetBool(blk, pos+offset, false, true); err != nil {
 				return err
 			}
 		case types.Date:
-			if err := p.tx.SetDate(blk, pos+offset, time.Time{}, false); err != nil {
+			if err := p.tx.SetDate(blk, pos+offset, time.Time{}, true); err != nil {
 				return err
 			}
 		case types.Long:
-			if err := p.tx.SetLong(blk, pos+offset, 0, false); err != nil {
+			if err := p.tx.SetLong(blk, pos+offset, 0, true); err != nil {
 				return err
 			}
 		case types.Short:
-			if err := p.tx.SetShort(blk, pos+offset, 0, false); err != nil {
+			if err := p.tx.SetShort(blk, pos+offset, 0, true); err != nil {
 				return err
 			}
 		default:
@@ -196,7 +237,7 @@ func (p *Page) InsertDirectory(slot int, value any, blockNumber int) error {
 	if err := p.insert(slot); err != nil {
 		return err
 	}
-	if err := p.setVal(slot, common.DataValueField, value); err != nil {
+	if err := p.setDataVal(slot, value); err != nil {
 		return err
 	}
 	return p.setInt(slot, common.BlockField, blockNumber)
@@ -220,7 +261,7 @@ func (p *Page) InsertLeaf(slot int, value any, rid *record.ID) error {
 	if err := p.insert(slot); err != nil {
 		return err
 	}
-	if err := p.setVal(slot, common.DataValueField, value); err != nil {
+	if err := p.setDataVal(slot, value); err != nil {
 		return err
 	}
 	if err := p.setInt(slot, common.BlockField, rid.BlockNumber()); err != nil {
@@ -238,28 +279,16 @@ func (p *Page) GetNumberOfRecords() (int, error) {
 	return numRecs, nil
 }
 
-func (p *Page) transferRecords(slot int, destination *Page) error {
-	destSlot := 0
+// transferRecords moves the records of the page from the specified slot on to the destination page,
+// where they are inserted from the specified destination slot on.
+func (p *Page) transferRecords(slot int, destination *Page, destSlot int) error {
 	numberOfRecords, err := p.GetNumberOfRecords()
 	if err != nil {
 		return err
 	}
 
 	for slot < numberOfRecords {
-		if err := destination.insert(destSlot); err != nil {
-			return err
-		}
-		schema := p.layout.Schema()
-		for _, field := range schema.Fields() {
-			val, err := p.getVal(slot, field)
-			if err != nil {
-				return err
-			}
-			if err := destination.setVal(destSlot, field, val); err != nil {
-				return err
-			}
-		}
-		if err := p.delete(slot); err != nil {
+		if err := p.moveRecord(slot, destination, destSlot); err != nil {
 			return err
 		}
 		destSlot++
@@ -274,6 +303,23 @@ func (p *Page) transferRecords(slot int, destination *Page) error {
 	return nil
 }
 
+// moveRecord moves the record at the specified slot to the destination page, where it is inserted at the destination slot.
+func (p *Page) moveRecord(slot int, destination *Page, destSlot int) error {
+	if err := destination.insert(destSlot); err != nil {
+		return err
+	}
+	for _, field := range p.layout.Schema().Fields() {
+		val, err := p.getVal(slot, field)
+		if err != nil {
+			return err
+		}
+		if err := destination.setVal(destSlot, field, val); err != nil {
+			return err
+		}
+	}
+	return p.delete(slot)
+}
+
 func (p *Page) fieldPosition(slot int, fieldName string) int {
 	return p.slotPosition(slot) + p.layout.Offset(fieldName)
 }
@@ -289,6 +335,8 @@ func (p *Page) getVal(slot int, fieldName string) (any, error) {
 	switch p.layout.Schema().Type(fieldName) {
 	case types.Integer:
 		return p.tx.GetInt(p.currentBlk, pos)
+	case types.Float:
+		return p.tx.GetFloat(p.currentBlk, pos)
 	case types.Varchar:
 		return p.tx.GetString(p.currentBlk, pos)
 	case types.Boolean:
@@ -306,9 +354,16 @@ func (p *Page) getVal(slot int, fieldName string) (any, error) {
 
 func (p *Page) setVal(slot int, fieldName string, val any) error {
 	pos := p.fieldPosition(slot, fieldName)
-	switch p.layout.Schema().Type(fieldName) {
+	fieldType := p.layout.Schema().Type(fieldName)
+	val, err := types.CoerceValue(val, fieldType)
+	if err != nil {
+		return fmt.Errorf("index field %s: %w", fieldName, err)
+	}
+	switch fieldType {
 	case types.Integer:
 		return p.tx.SetInt(p.currentBlk, pos, val.(int), true)
+	case types.Float:
+		return p.tx.SetFloat(p.currentBlk, pos, val.(float64), true)
 	case types.Varchar:
 		return p.tx.SetString(p.currentBlk, pos, val.(string), true)
 	case types.Boolean:
@@ -320,7 +375,7 @@ func (p *Page) setVal(slot int, fieldName string, val any) error {
 	case types.Short:
 		return p.tx.SetShort(p.currentBlk, pos, val.(int16), true)
 	default:
-		return fmt.Errorf("unsupported type: %T", p.layout.Schema().Type(fieldName))
+		return fmt.Errorf("unsupported type: %T", fieldType)
 	}
 }
 
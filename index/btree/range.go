@@ -0,0 +1,178 @@
+package btree
+
+import (
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ index.RangeIndex = (*Index)(nil)
+
+// rangeScan is the position of a b-tree index in the records of a range of search keys.
+// The leaves holding the range are found in the directory when the scan starts, and their records are read
+// one leaf at a time, each leaf followed by its overflow blocks, which hold more records of its first key.
+type rangeScan struct {
+	keyRange index.Range
+	// leaves are the numbers of the leaf blocks left to read, in search key order.
+	leaves []int
+	// contents is the leaf or overflow block being read, or nil before the first leaf and between leaves.
+	contents *Page
+	slot     int
+	// firstKey is the first key of the leaf being read, which its overflow blocks hold.
+	firstKey any
+}
+
+// BeforeFirstRange positions the index before the first record whose search key is in the specified range.
+// The records are then returned by Next leaf by leaf, in search key order except for the records of the
+// overflow blocks of a leaf, which follow the other records of the leaf.
+func (idx *Index) BeforeFirstRange(keyRange index.Range) error {
+	idx.Close()
+	leaves, err := idx.rangeLeaves(idx.rootBlock, keyRange, nil)
+	if err != nil {
+		return err
+	}
+	idx.rangeScan = &rangeScan{keyRange: keyRange, leaves: leaves}
+	return nil
+}
+
+// rangeLeaves appends the numbers of the leaf blocks under the specified directory block that may hold
+// search keys in the range to leaves, in search key order. The child of a directory entry holds the keys
+// from the key of the entry up to the key of the next entry, and the child of the first entry also the
+// keys below it, so the children whose keys are all outside the range are skipped.
+func (idx *Index) rangeLeaves(block *file.BlockId, keyRange index.Range, leaves []int) ([]int, error) {
+	directory, err := NewPage(idx.transaction, block, idx.directoryLayout)
+	if err != nil {
+		return nil, err
+	}
+	defer directory.Close()
+
+	level, err := directory.GetFlag()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := directory.GetNumberOfRecords()
+	if err != nil {
+		return nil, err
+	}
+	for slot := 0; slot < entries; slot++ {
+		if slot > 0 {
+			key, err := directory.GetDataVal(slot)
+			if err != nil {
+				return nil, err
+			}
+			if keyRange.Above(key) {
+				break
+			}
+		}
+		if slot+1 < entries {
+			nextKey, err := directory.GetDataVal(slot + 1)
+			if err != nil {
+				return nil, err
+			}
+			if keyRange.Low != nil && types.CompareSupportedTypes(nextKey, keyRange.Low, types.LT) {
+				continue
+			}
+		}
+
+		child, err := directory.GetChildNumber(slot)
+		if err != nil {
+			return nil, err
+		}
+		if level == 0 {
+			leaves = append(leaves, child)
+		} else if leaves, err = idx.rangeLeaves(file.NewBlockId(block.Filename(), child), keyRange, leaves); err != nil {
+			return nil, err
+		}
+	}
+	return leaves, nil
+}
+
+// next moves to the next record whose search key is in the range. Returns false if there are no more such records.
+func (rs *rangeScan) next(idx *Index) (bool, error) {
+	for {
+		if rs.contents == nil {
+			if len(rs.leaves) == 0 {
+				return false, nil
+			}
+			if err := rs.openLeaf(idx, rs.leaves[0]); err != nil {
+				return false, err
+			}
+			rs.leaves = rs.leaves[1:]
+		}
+
+		rs.slot++
+		records, err := rs.contents.GetNumberOfRecords()
+		if err != nil {
+			return false, err
+		}
+		if rs.slot >= records {
+			if err := rs.nextBlock(idx); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		key, err := rs.contents.GetDataVal(rs.slot)
+		if err != nil {
+			return false, err
+		}
+		if rs.keyRange.Contains(key) {
+			return true, nil
+		}
+		if rs.keyRange.Above(key) {
+			// The keys that follow in the leaf, and in the leaves after it, are above the range too,
+			// but the overflow blocks of the leaf may still hold records of its first key.
+			rs.leaves = nil
+			rs.slot = records - 1
+		}
+	}
+}
+
+// openLeaf starts reading the specified leaf block.
+func (rs *rangeScan) openLeaf(idx *Index, blockNumber int) error {
+	contents, err := NewPage(idx.transaction, file.NewBlockId(idx.leafTable, blockNumber), idx.leafLayout)
+	if err != nil {
+		return err
+	}
+	rs.contents, rs.slot, rs.firstKey = contents, -1, nil
+	records, err := contents.GetNumberOfRecords()
+	if err != nil || records == 0 {
+		return err
+	}
+	rs.firstKey, err = contents.GetDataVal(0)
+	return err
+}
+
+// nextBlock moves past the end of the block being read: to the next overflow block of the leaf,
+// if the first key of the leaf is in the range, or else to the next leaf.
+func (rs *rangeScan) nextBlock(idx *Index) error {
+	overflow, err := rs.contents.GetFlag()
+	if err != nil {
+		return err
+	}
+	rs.contents.Close()
+	rs.contents = nil
+	if overflow < 0 || rs.firstKey == nil || !rs.keyRange.Contains(rs.firstKey) {
+		return nil
+	}
+	contents, err := NewPage(idx.transaction, file.NewBlockId(idx.leafTable, overflow), idx.leafLayout)
+	if err != nil {
+		return err
+	}
+	rs.contents, rs.slot = contents, -1
+	return nil
+}
+
+// dataRecordID returns the record ID of the current record.
+func (rs *rangeScan) dataRecordID() (*record.ID, error) {
+	return rs.contents.getDataRID(rs.slot)
+}
+
+// close closes the block being read, if any.
+func (rs *rangeScan) close() {
+	if rs.contents != nil {
+		rs.contents.Close()
+		rs.contents = nil
+	}
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// KeyField returns the name of the field of an index record that holds the value of the specified indexed field,
+// counting from 0. The value of the first indexed field is held by DataValueField, so that the records of
+// an index on a single field only have that field.
+func KeyField(i int) string {
+	if i == 0 {
+		return DataValueField
+	}
+	return fmt.Sprintf("%s_%d", DataValueField, i)
+}
+
+// KeyFields returns the names of the fields of the index records of the specified schema that hold the search key.
+func KeyFields(schema *record.Schema) []string {
+	var fields []string
+	for i := 0; schema.HasField(KeyField(i)); i++ {
+		fields = append(fields, KeyField(i))
+	}
+	return fields
+}
+
+// GetKey returns the search key held by the specified fields of an index record, reading them with getVal.
+// The search key of an index on several fields is a types.CompositeKey.
+func GetKey(getVal func(fieldName string) (any, error), keyFields []string) (any, error) {
+	if len(keyFields) == 1 {
+		return getVal(keyFields[0])
+	}
+	key := make(types.CompositeKey, len(keyFields))
+	for i, fieldName := range keyFields {
+		val, err := getVal(fieldName)
+		if err != nil {
+			return nil, err
+		}
+		key[i] = val
+	}
+	return key, nil
+}
+
+// SetKey writes the search key into the specified fields of an index record with setVal.
+// It returns an error if the key of an index on several fields is not a types.CompositeKey of as many values.
+func SetKey(setVal func(fieldName string, val any) error, keyFields []string, key any) error {
+	if len(keyFields) == 1 {
+		return setVal(keyFields[0], key)
+	}
+	compositeKey, ok := key.(types.CompositeKey)
+	if !ok || len(compositeKey) != len(keyFields) {
+		return fmt.Errorf("search key %v does not have a value for each of the %d indexed fields", key, len(keyFields))
+	}
+	for i, fieldName := range keyFields {
+		if err := setVal(fieldName, compositeKey[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,28 +1,46 @@
 package hash
 
 import (
+	"errors"
 	"fmt"
+	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/index"
 	"github.com/JyotinderSingh/dropdb/index/common"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
 	"github.com/JyotinderSingh/dropdb/utils"
+	"slices"
 )
 
 const (
-	numBuckets = 100
+	// initialBuckets is the number of buckets of an index before any of them is split.
+	initialBuckets = 100
+
+	// statsSuffix names the file holding the number of records of the index and the state of its splits (see header).
+	statsSuffix = "-stats"
 )
 
 // ensure index interface is implemented
 var _ index.Index = (*Index)(nil)
 
+// Index is a hash index using linear hashing: it starts with initialBuckets buckets, and splits one more of them,
+// in turn, each time its buckets hold on average more records than fit in a block, so that a search reads about
+// one block of its bucket however many records the index has. Each bucket is a table of its own.
 type Index struct {
 	transaction *tx.Transaction
 	indexName   string
 	layout      *record.Layout
-	searchKey   any
-	tableScan   *table.Scan
+	// keyFields are the fields of the index records holding their search key (see common.KeyFields).
+	keyFields []string
+	searchKey any
+	tableScan *table.Scan
+	// pendingRecords is the number of records inserted through this index, less those deleted through it,
+	// which the block holding the number of records of the index does not count yet (see addRecords).
+	pendingRecords int
+	// countLocked is true once this index has locked the number of records of the index (see lockRecordCount).
+	countLocked bool
 }
 
 // NewIndex opens a hash index for the specified index.
@@ -31,6 +49,7 @@ func NewIndex(transaction *tx.Transaction, indexName string, layout *record.Layo
 		transaction: transaction,
 		indexName:   indexName,
 		layout:      layout,
+		keyFields:   common.KeyFields(layout.Schema()),
 		searchKey:   nil,
 		tableScan:   nil,
 	}
@@ -40,19 +59,43 @@ func NewIndex(transaction *tx.Transaction, indexName string, layout *record.Layo
 // The method hashes the search key to determine the bucket,
 // and then opens a table scan on the file corresponding to that bucket.
 // The table scan for the previous bucket (if any) is closed.
+// The file of a bucket is only created once a record is inserted into it, so that reading the index does not modify it.
 func (idx *Index) BeforeFirst(searchKey any) error {
+	return idx.openBucket(searchKey, false)
+}
+
+// openBucket opens the table scan on the bucket of the search key, creating its file if the scan is for an insertion.
+func (idx *Index) openBucket(searchKey any, forInsert bool) error {
 	idx.Close()
 	idx.searchKey = searchKey
 	hashValue, err := utils.HashValue(searchKey)
 	if err != nil {
 		return err
 	}
-	bucket := hashValue % numBuckets
-	tableName := fmt.Sprintf("%s-%d", idx.indexName, bucket)
+	h, err := idx.readHeader()
+	if err != nil {
+		return err
+	}
+	tableName := bucketTable(idx.indexName, h.bucket(hashValue))
+	if !forInsert {
+		blocks, err := table.BlockCount(idx.transaction, tableName)
+		if err != nil {
+			return err
+		}
+		if blocks == 0 {
+			idx.tableScan, err = table.NewBlockRangeScan(idx.transaction, tableName, idx.layout, 0, 0)
+			return err
+		}
+	}
 	idx.tableScan, err = table.NewTableScan(idx.transaction, tableName, idx.layout)
 	return err
 }
 
+// bucketTable returns the name of the table holding the records of the specified bucket of the index.
+func bucketTable(indexName string, bucket int) string {
+	return fmt.Sprintf("%s-%d", indexName, bucket)
+}
+
 // Next moves to the next index record having the search key.
 // The method loops through the table scan for the bucket, looking for a matching record,
 // and returns false if there are no more such records.
@@ -63,16 +106,36 @@ func (idx *Index) Next() (bool, error) {
 			return false, err
 		}
 
-		currentValue, err := idx.tableScan.GetVal(common.DataValueField)
+		currentValue, err := common.GetKey(idx.tableScan.GetVal, idx.keyFields)
 		if err != nil {
 			return false, err
 		}
-		if currentValue == idx.searchKey {
+		if sameKey(currentValue, idx.searchKey) {
 			return true, nil
 		}
 	}
 }
 
+// sameKey returns true if the specified search keys are the same. The values of a composite key are compared one by one.
+// Numbers are compared by value whatever their type, as utils.HashValue hashes them, so that a search key read from
+// a field of another numeric type, such as a short joined with the integers of the index, finds their index records.
+func sameKey(lhs, rhs any) bool {
+	lhsKey, lhsIsComposite := lhs.(types.CompositeKey)
+	rhsKey, rhsIsComposite := rhs.(types.CompositeKey)
+	if !lhsIsComposite || !rhsIsComposite {
+		return sameValue(lhs, rhs)
+	}
+	return slices.EqualFunc(lhsKey, rhsKey, sameValue)
+}
+
+// sameValue returns true if the specified values are equal, or are both nil.
+func sameValue(lhs, rhs any) bool {
+	if lhs == nil || rhs == nil {
+		return lhs == rhs
+	}
+	return types.CompareSupportedTypes(lhs, rhs, types.EQ)
+}
+
 // GetDataRecordID retrieves the data record ID from the current record in the table scan for the bucket.
 func (idx *Index) GetDataRecordID() (*record.ID, error) {
 	blockNumber, err := idx.tableScan.GetInt(common.BlockField)
@@ -89,7 +152,10 @@ func (idx *Index) GetDataRecordID() (*record.ID, error) {
 
 // Insert inserts a new record into the table scan for the bucket.
 func (idx *Index) Insert(dataValue any, dataRecordID *record.ID) error {
-	if err := idx.BeforeFirst(dataValue); err != nil {
+	if err := idx.lockRecordCount(); err != nil {
+		return err
+	}
+	if err := idx.openBucket(dataValue, true); err != nil {
 		return err
 	}
 
@@ -102,7 +168,113 @@ func (idx *Index) Insert(dataValue any, dataRecordID *record.ID) error {
 	if err := idx.tableScan.SetInt(common.IDField, dataRecordID.Slot()); err != nil {
 		return err
 	}
-	return idx.tableScan.SetVal(common.DataValueField, dataValue)
+	if err := common.SetKey(idx.tableScan.SetVal, idx.keyFields, dataValue); err != nil {
+		return err
+	}
+	idx.addRecords(1)
+	return idx.splitIfFull()
+}
+
+// splitIfFull splits the next bucket to split if the buckets hold on average more records than fit in a block.
+func (idx *Index) splitIfFull() error {
+	h, err := idx.readHeader()
+	if err != nil {
+		return err
+	}
+	recordsPerBlock := max(idx.transaction.BlockSize()/idx.layout.SlotSize(), 1)
+	if h.records <= h.buckets()*recordsPerBlock {
+		return nil
+	}
+	idx.Close()
+	return idx.split(h)
+}
+
+// split splits the bucket h.next, moving the records of the keys that h.level+1 hashes into a new bucket,
+// numbered h.next plus the number of buckets before the splits of its level, and then moves on to the next bucket.
+// Once each bucket of the level is split, the next level starts again from the first bucket, with twice as many.
+func (idx *Index) split(h header) error {
+	levelBuckets := initialBuckets << h.level
+	newBucket := h.next + levelBuckets
+	if err := idx.moveRecords(h.next, newBucket, 2*levelBuckets); err != nil {
+		return err
+	}
+	h.next++
+	if h.next == levelBuckets {
+		h.level, h.next = h.level+1, 0
+	}
+	return idx.writeSplits(h)
+}
+
+// moveRecords moves the records of a bucket whose keys hash into the other bucket modulo the specified number of
+// buckets into that bucket. The file of the other bucket is only created once a record is moved into it.
+func (idx *Index) moveRecords(bucket, otherBucket, buckets int) error {
+	tableName := bucketTable(idx.indexName, bucket)
+	blocks, err := table.BlockCount(idx.transaction, tableName)
+	if err != nil || blocks == 0 {
+		return err
+	}
+	source, err := table.NewBlockRangeScan(idx.transaction, tableName, idx.layout, 0, blocks)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	var target *table.Scan
+	defer func() {
+		if target != nil {
+			target.Close()
+		}
+	}()
+	for {
+		hasNext, err := source.Next()
+		if err != nil || !hasNext {
+			return err
+		}
+		key, err := common.GetKey(source.GetVal, idx.keyFields)
+		if err != nil {
+			return err
+		}
+		hashValue, err := utils.HashValue(key)
+		if err != nil {
+			return err
+		}
+		if int(uint64(hashValue)%uint64(buckets)) != otherBucket {
+			continue
+		}
+		if target == nil {
+			if target, err = table.NewTableScan(idx.transaction, bucketTable(idx.indexName, otherBucket), idx.layout); err != nil {
+				return err
+			}
+		}
+		if err := copyRecord(source, target, idx.keyFields, key); err != nil {
+			return err
+		}
+		if err := source.Delete(); err != nil {
+			return err
+		}
+	}
+}
+
+// copyRecord inserts into the target scan a copy of the index record the source scan is on, whose search key is given.
+func copyRecord(source, target *table.Scan, keyFields []string, key any) error {
+	blockNumber, err := source.GetInt(common.BlockField)
+	if err != nil {
+		return err
+	}
+	id, err := source.GetInt(common.IDField)
+	if err != nil {
+		return err
+	}
+	if err := target.Insert(); err != nil {
+		return err
+	}
+	if err := target.SetInt(common.BlockField, blockNumber); err != nil {
+		return err
+	}
+	if err := target.SetInt(common.IDField, id); err != nil {
+		return err
+	}
+	return common.SetKey(target.SetVal, keyFields, key)
 }
 
 // Delete deletes the specified record from the table scan for the bucket.
@@ -110,6 +282,9 @@ func (idx *Index) Insert(dataValue any, dataRecordID *record.ID) error {
 // records until the specified record is found. If the record is found, it is deleted.
 // If the record is not found, the method does nothing and does not return an error.
 func (idx *Index) Delete(dataValue any, dataRecordID *record.ID) error {
+	if err := idx.lockRecordCount(); err != nil {
+		return err
+	}
 	if err := idx.BeforeFirst(dataValue); err != nil {
 		return err
 	}
@@ -129,13 +304,69 @@ func (idx *Index) Delete(dataValue any, dataRecordID *record.ID) error {
 		}
 
 		if currentRecordID.Equals(dataRecordID) {
-			return idx.tableScan.Delete()
+			if err := idx.tableScan.Delete(); err != nil {
+				return err
+			}
+			idx.addRecords(-1)
+			return nil
 		}
 	}
 
 	return nil
 }
 
+// ForEach calls visit with each record of each bucket, reading the buckets in turn.
+func (idx *Index) ForEach(visit func(dataValue any, dataRecordID *record.ID) error) error {
+	h, err := idx.readHeader()
+	if err != nil {
+		return err
+	}
+	for bucket := 0; bucket < h.buckets(); bucket++ {
+		tableName := bucketTable(idx.indexName, bucket)
+		blocks, err := table.BlockCount(idx.transaction, tableName)
+		if err != nil {
+			return err
+		}
+		if blocks == 0 {
+			continue
+		}
+		if err := idx.visitBucket(tableName, blocks, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visitBucket calls visit with each record of the bucket stored in the specified table.
+func (idx *Index) visitBucket(tableName string, blocks int, visit func(dataValue any, dataRecordID *record.ID) error) error {
+	ts, err := table.NewBlockRangeScan(idx.transaction, tableName, idx.layout, 0, blocks)
+	if err != nil {
+		return err
+	}
+	defer ts.Close()
+	for {
+		hasNext, err := ts.Next()
+		if err != nil || !hasNext {
+			return err
+		}
+		blockNumber, err := ts.GetInt(common.BlockField)
+		if err != nil {
+			return err
+		}
+		id, err := ts.GetInt(common.IDField)
+		if err != nil {
+			return err
+		}
+		dataValue, err := common.GetKey(ts.GetVal, idx.keyFields)
+		if err != nil {
+			return err
+		}
+		if err := visit(dataValue, record.NewID(blockNumber, id)); err != nil {
+			return err
+		}
+	}
+}
+
 // Close closes the index by closing the current table scan.
 func (idx *Index) Close() {
 	if idx.tableScan != nil {
@@ -144,10 +375,180 @@ func (idx *Index) Close() {
 	}
 }
 
-// SearchCost returns the cost of searching an index file having
-// the specified number of blocks.
-// the method assumes that all buckets are about the same size,
-// so the cost is simply the size of the bucket.
-func SearchCost(numBlocks, recordsPerBucket int) int {
-	return numBlocks / numBuckets
+// Drop closes the index and deletes the files of its buckets and the file holding its number of records.
+func (idx *Index) Drop() error {
+	idx.Close()
+	idx.pendingRecords = 0
+	h, err := idx.readHeader()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for bucket := 0; bucket < h.buckets(); bucket++ {
+		errs = append(errs, table.RemoveTable(idx.transaction, bucketTable(idx.indexName, bucket)))
+	}
+	errs = append(errs, idx.transaction.RemoveFile(idx.statsBlock().Filename()))
+	return errors.Join(errs...)
+}
+
+// Stats returns the number of records of the index and its current number of buckets, which are kept in a block
+// of its own. The number of records is unknown if nothing was ever inserted in the index. The records inserted
+// or deleted through this index by its transaction are counted, but not those of the other indexes opened by it.
+func (idx *Index) Stats() (*index.Stats, error) {
+	h, err := idx.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	return &index.Stats{Height: 0, Blocks: -1, Buckets: h.buckets(), Records: h.records}, nil
+}
+
+// header is the state of an index kept in the block holding its number of records, after that number:
+// the level of its splits and the next bucket to split (see Index#split). An index whose buckets were never
+// split has initialBuckets buckets, as the indexes created before buckets were split have.
+type header struct {
+	// records is the number of records of the index, or -1 if nothing was ever inserted in it (see pinStatsBlock).
+	records int
+	level   int
+	next    int
+}
+
+// buckets returns the number of buckets of the index.
+func (h header) buckets() int {
+	return initialBuckets<<h.level + h.next
+}
+
+// bucket returns the bucket of the search keys having the specified hash value. The buckets before the next
+// bucket to split have been split at this level, so their keys are in the bucket given by the next level.
+func (h header) bucket(hashValue uint32) int {
+	bucket := int(uint64(hashValue) % uint64(initialBuckets<<h.level))
+	if bucket < h.next {
+		bucket = int(uint64(hashValue) % uint64(initialBuckets<<(h.level+1)))
+	}
+	return bucket
+}
+
+// readHeader reads the header of the index from the block holding its number of records,
+// adding the records inserted or deleted through this index that the block does not count yet.
+func (idx *Index) readHeader() (header, error) {
+	h := header{records: -1}
+	block := idx.statsBlock()
+	size, err := idx.transaction.Size(block.Filename())
+	if err != nil {
+		return h, err
+	}
+
+	if size > 0 {
+		if err := idx.transaction.Pin(block); err != nil {
+			return h, err
+		}
+		defer idx.transaction.Unpin(block)
+		for i, field := range []*int{&h.records, &h.level, &h.next} {
+			if *field, err = idx.transaction.GetInt(block, i*types.IntSize); err != nil {
+				return h, err
+			}
+		}
+	}
+	if idx.pendingRecords != 0 {
+		h.records = max(h.records, 0) + idx.pendingRecords
+	}
+	return h, nil
+}
+
+// writeSplits writes the level of the splits and the next bucket to split of the header into the block holding
+// the number of records of the index. The changes are logged, so that they are undone with the records the split
+// moved if the transaction rolls back.
+func (idx *Index) writeSplits(h header) error {
+	block, err := idx.pinStatsBlock()
+	if err != nil {
+		return err
+	}
+	defer idx.transaction.Unpin(block)
+	if err := idx.transaction.SetInt(block, types.IntSize, h.level, true); err != nil {
+		return err
+	}
+	return idx.transaction.SetInt(block, 2*types.IntSize, h.next, true)
+}
+
+// lockRecordCount locks the file holding the number of records of the index before this index first changes
+// its records, so that the other transactions changing the index wait for this one to complete, and registers
+// the write of the number when the transaction commits (see writeRecordCount). The file is locked before the
+// header is read, so that two transactions never both read the number and then wait for each other to write it.
+func (idx *Index) lockRecordCount() error {
+	if idx.countLocked {
+		return nil
+	}
+	if err := idx.transaction.XLockFile(idx.statsBlock().Filename()); err != nil {
+		return err
+	}
+	idx.transaction.BeforeCommit(idx.writeRecordCount)
+	idx.countLocked = true
+	return nil
+}
+
+// addRecords adds the specified number to the count of records of the index kept in memory by this index,
+// which is only written to the block holding the count when the transaction commits, so that inserting
+// a record does not write and log the count each time. Nothing needs to be undone if the transaction rolls back.
+func (idx *Index) addRecords(n int) {
+	idx.pendingRecords += n
+}
+
+// writeRecordCount adds the records inserted or deleted through this index to the count of records of the index,
+// creating the block holding the count if necessary.
+// The change is logged, so that the count is restored if the transaction fails to commit and rolls back.
+func (idx *Index) writeRecordCount() error {
+	n := idx.pendingRecords
+	if n == 0 {
+		return nil
+	}
+	idx.pendingRecords = 0
+	block, err := idx.pinStatsBlock()
+	if err != nil {
+		return err
+	}
+	defer idx.transaction.Unpin(block)
+	count, err := idx.transaction.GetInt(block, 0)
+	if err != nil {
+		return err
+	}
+	return idx.transaction.SetInt(block, 0, max(count, 0)+n, true)
+}
+
+// pinStatsBlock pins the block holding the number of records of the index, creating it if necessary.
+// A block created by a split holds an unknown number of records until the transaction commits.
+func (idx *Index) pinStatsBlock() (*file.BlockId, error) {
+	block := idx.statsBlock()
+	size, err := idx.transaction.Size(block.Filename())
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		return block, idx.transaction.Pin(block)
+	}
+	if _, err := idx.transaction.Append(block.Filename()); err != nil {
+		return nil, err
+	}
+	if err := idx.transaction.Pin(block); err != nil {
+		return nil, err
+	}
+	if err := idx.transaction.SetInt(block, 0, -1, true); err != nil {
+		idx.transaction.Unpin(block)
+		return nil, err
+	}
+	return block, nil
+}
+
+// statsBlock returns the block holding the number of records of the index.
+func (idx *Index) statsBlock() *file.BlockId {
+	return file.NewBlockId(idx.indexName+statsSuffix, 0)
+}
+
+// SearchCost returns the estimated number of block accesses required to find
+// the specified number of records having a search key, which is the size of its bucket.
+// The stats give the current number of buckets, which grows as the buckets are split.
+// The records of the index are assumed to be spread evenly over the buckets,
+// except for those of the search key, which all are in the same bucket.
+// The stats must record the number of records of the index.
+func SearchCost(stats *index.Stats, matchingRecords, recordsPerBlock int) int {
+	bucketRecords := max((stats.Records+stats.Buckets-1)/stats.Buckets, matchingRecords)
+	return (bucketRecords + recordsPerBlock - 1) / recordsPerBlock
 }
@@ -1,12 +1,15 @@
 package hash
 
 import (
+	"fmt"
 	"github.com/JyotinderSingh/dropdb/buffer"
 	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/index"
 	"github.com/JyotinderSingh/dropdb/index/common"
 	"github.com/JyotinderSingh/dropdb/log"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
+	"github.com/JyotinderSingh/dropdb/utils"
 	"os"
 	"testing"
 
@@ -176,10 +179,295 @@ func TestHashIndex_Close(t *testing.T) {
 }
 
 func TestHashIndex_SearchCost(t *testing.T) {
-	numBlocks := 1000
-	recordsPerBucket := 10
+	stats := &index.Stats{Buckets: initialBuckets, Records: 10000}
+
+	// 100 records per bucket, in blocks of 10 records.
+	assert.Equal(t, 10, SearchCost(stats, 1, 10))
+	// The records of a search key are all in its bucket, whatever the other buckets hold.
+	assert.Equal(t, 50, SearchCost(stats, 500, 10))
+	assert.Equal(t, 1, SearchCost(&index.Stats{Buckets: initialBuckets, Records: 3}, 1, 10))
+	assert.Equal(t, 0, SearchCost(&index.Stats{Buckets: initialBuckets, Records: 0}, 0, 10))
+}
+
+func TestHashIndex_Stats(t *testing.T) {
+	hashIndex, _, cleanup := setupHashIndexTest(t)
+	defer cleanup()
+
+	stats, err := hashIndex.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, &index.Stats{Height: 0, Blocks: -1, Buckets: initialBuckets, Records: -1}, stats,
+		"the number of records is unknown before the first insertion")
 
-	cost := SearchCost(numBlocks, recordsPerBucket)
-	expectedCost := numBlocks / numBuckets
-	assert.Equal(t, expectedCost, cost)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, hashIndex.Insert("key", record.NewID(1, i)))
+	}
+	require.NoError(t, hashIndex.Delete("key", record.NewID(1, 1)))
+	// Deleting a record that is not in the index does not change the count.
+	require.NoError(t, hashIndex.Delete("key", record.NewID(1, 1)))
+	require.NoError(t, hashIndex.Delete("other", record.NewID(1, 0)))
+
+	stats, err = hashIndex.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Records)
+}
+
+func TestHashIndex_RecordCountWrittenOnCommit(t *testing.T) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lockTable := concurrency.NewLockTable()
+
+	schema := record.NewSchema()
+	schema.AddIntField(common.BlockField)
+	schema.AddIntField(common.IDField)
+	schema.AddIntField(common.DataValueField)
+	layout := record.NewLayout(schema)
+	records := func(idx index.Index) int {
+		stats, err := idx.Stats()
+		require.NoError(t, err)
+		return stats.Records
+	}
+
+	// The count is kept in memory by the index until the transaction commits.
+	transaction := tx.NewTransaction(fm, lm, bm, lockTable)
+	hashIndex := NewIndex(transaction, "test_index", layout)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, hashIndex.Insert(i, record.NewID(1, i)))
+	}
+	require.NoError(t, hashIndex.Delete(7, record.NewID(1, 7)))
+	assert.Equal(t, 49, records(hashIndex))
+	assert.Equal(t, -1, records(NewIndex(transaction, "test_index", layout)), "count written before the commit")
+	hashIndex.Close()
+	require.NoError(t, transaction.Commit())
+
+	transaction = tx.NewTransaction(fm, lm, bm, lockTable)
+	hashIndex = NewIndex(transaction, "test_index", layout)
+	assert.Equal(t, 49, records(hashIndex))
+
+	// The records counted by a transaction that rolls back are forgotten.
+	for i := 50; i < 60; i++ {
+		require.NoError(t, hashIndex.Insert(i, record.NewID(1, i)))
+	}
+	assert.Equal(t, 59, records(hashIndex))
+	hashIndex.Close()
+	require.NoError(t, transaction.Rollback())
+
+	transaction = tx.NewTransaction(fm, lm, bm, lockTable)
+	defer func() { require.NoError(t, transaction.Commit()) }()
+	assert.Equal(t, 49, records(NewIndex(transaction, "test_index", layout)))
+}
+
+func TestHashIndex_ForEach(t *testing.T) {
+	hashIndex, transaction, cleanup := setupHashIndexTest(t)
+	defer cleanup()
+
+	// Searching an empty bucket does not create its file.
+	require.NoError(t, hashIndex.BeforeFirst("missing"))
+	hasNext, err := hashIndex.Next()
+	require.NoError(t, err)
+	assert.False(t, hasNext)
+	hashValue, err := utils.HashValue("missing")
+	require.NoError(t, err)
+	blocks, err := transaction.Size(bucketTable("test_index", int(hashValue%initialBuckets)) + ".tbl")
+	require.NoError(t, err)
+	assert.Equal(t, 0, blocks)
+
+	expected := make(map[string]*record.ID)
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		expected[key] = record.NewID(i/10, i%10)
+		require.NoError(t, hashIndex.Insert(key, expected[key]))
+	}
+	require.NoError(t, hashIndex.Delete("key_3", expected["key_3"]))
+	delete(expected, "key_3")
+
+	visited := make(map[string]*record.ID)
+	require.NoError(t, hashIndex.ForEach(func(dataValue any, dataRecordID *record.ID) error {
+		visited[dataValue.(string)] = dataRecordID
+		return nil
+	}))
+	assert.Equal(t, expected, visited)
+}
+
+func TestHashIndex_Drop(t *testing.T) {
+	hashIndex, transaction, cleanup := setupHashIndexTest(t)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, hashIndex.Insert(fmt.Sprintf("key_%d", i), record.NewID(0, i)))
+	}
+	require.NoError(t, hashIndex.BeforeFirst("key_0"))
+	require.NoError(t, hashIndex.Drop())
+
+	// An index created with the same name starts empty.
+	hashIndex = NewIndex(transaction, "test_index", hashIndex.(*Index).layout)
+	defer hashIndex.Close()
+	stats, err := hashIndex.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, -1, stats.Records)
+	require.NoError(t, hashIndex.ForEach(func(dataValue any, dataRecordID *record.ID) error {
+		t.Errorf("unexpected record %v at %v", dataValue, dataRecordID)
+		return nil
+	}))
+}
+
+func TestHashIndex_CompositeKey(t *testing.T) {
+	_, transaction, cleanup := setupHashIndexTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField(common.BlockField)
+	schema.AddIntField(common.IDField)
+	schema.AddIntField(common.KeyField(0))
+	schema.AddStringField(common.KeyField(1), 10)
+	compositeIndex := NewIndex(transaction, "test_composite_index", record.NewLayout(schema))
+	defer compositeIndex.Close()
+
+	for i := 0; i < 40; i++ {
+		key := types.CompositeKey{i % 4, fmt.Sprintf("name_%d", i%5)}
+		require.NoError(t, compositeIndex.Insert(key, record.NewID(i, 0)))
+	}
+
+	// search returns the block numbers of the records having the key.
+	search := func(key types.CompositeKey) []int {
+		require.NoError(t, compositeIndex.BeforeFirst(key))
+		var found []int
+		for {
+			next, err := compositeIndex.Next()
+			require.NoError(t, err)
+			if !next {
+				return found
+			}
+			rid, err := compositeIndex.GetDataRecordID()
+			require.NoError(t, err)
+			found = append(found, rid.BlockNumber())
+		}
+	}
+	assert.ElementsMatch(t, []int{3, 23}, search(types.CompositeKey{3, "name_3"}))
+	assert.ElementsMatch(t, []int{1, 21}, search(types.CompositeKey{1, "name_1"}))
+	assert.Empty(t, search(types.CompositeKey{1, "name_9"}))
+
+	require.NoError(t, compositeIndex.Delete(types.CompositeKey{1, "name_1"}, record.NewID(1, 0)))
+	assert.Equal(t, []int{21}, search(types.CompositeKey{1, "name_1"}))
+
+	visited := 0
+	require.NoError(t, compositeIndex.ForEach(func(dataValue any, dataRecordID *record.ID) error {
+		key := dataValue.(types.CompositeKey)
+		assert.Equal(t, types.CompositeKey{dataRecordID.BlockNumber() % 4, fmt.Sprintf("name_%d", dataRecordID.BlockNumber()%5)}, key)
+		visited++
+		return nil
+	}))
+	assert.Equal(t, 39, visited)
+
+	// A key that is not composite cannot be inserted.
+	assert.Error(t, compositeIndex.Insert(1, record.NewID(40, 0)))
+}
+
+func TestHashIndex_NumericKeyTypes(t *testing.T) {
+	_, transaction, cleanup := setupHashIndexTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField(common.BlockField)
+	schema.AddIntField(common.IDField)
+	schema.AddIntField(common.DataValueField)
+	intIndex := NewIndex(transaction, "test_int_index", record.NewLayout(schema))
+	defer intIndex.Close()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, intIndex.Insert(i%5, record.NewID(i, 0)))
+	}
+
+	// An integer key of any size, or a float holding an integer, finds the records of the index having its value.
+	for _, key := range []any{3, int16(3), int64(3), 3.0} {
+		require.NoError(t, intIndex.BeforeFirst(key))
+		var found []int
+		for {
+			next, err := intIndex.Next()
+			require.NoError(t, err)
+			if !next {
+				break
+			}
+			rid, err := intIndex.GetDataRecordID()
+			require.NoError(t, err)
+			found = append(found, rid.BlockNumber())
+		}
+		assert.ElementsMatch(t, []int{3, 8, 13, 18}, found, "key %T", key)
+	}
+
+	require.NoError(t, intIndex.BeforeFirst(3.5))
+	next, err := intIndex.Next()
+	require.NoError(t, err)
+	assert.False(t, next)
+}
+
+func TestHashIndex_BucketSplits(t *testing.T) {
+	_, transaction, cleanup := setupHashIndexTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField(common.BlockField)
+	schema.AddIntField(common.IDField)
+	schema.AddIntField(common.DataValueField)
+	layout := record.NewLayout(schema)
+	intIndex := NewIndex(transaction, "test_split_index", layout)
+	defer intIndex.Close()
+
+	// Enough records to split each of the initial buckets, and then some of the buckets they were split into.
+	recordsPerBlock := transaction.BlockSize() / layout.SlotSize()
+	numRecords := 3 * initialBuckets * recordsPerBlock
+	for i := 0; i < numRecords; i++ {
+		require.NoError(t, intIndex.Insert(i, record.NewID(i, i%7)))
+	}
+	stats, err := intIndex.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, numRecords, stats.Records)
+	assert.Greater(t, stats.Buckets, 2*initialBuckets)
+	assert.GreaterOrEqual(t, stats.Buckets*recordsPerBlock, numRecords, "the buckets hold a block of records on average")
+	assert.LessOrEqual(t, SearchCost(stats, 1, recordsPerBlock), 1)
+
+	// search returns the IDs of the records having the key.
+	search := func(key int) []*record.ID {
+		require.NoError(t, intIndex.BeforeFirst(key))
+		var found []*record.ID
+		for {
+			next, err := intIndex.Next()
+			require.NoError(t, err)
+			if !next {
+				return found
+			}
+			rid, err := intIndex.GetDataRecordID()
+			require.NoError(t, err)
+			found = append(found, rid)
+		}
+	}
+	for i := 0; i < numRecords; i++ {
+		require.Equal(t, []*record.ID{record.NewID(i, i%7)}, search(i), "key %d", i)
+	}
+	assert.Empty(t, search(numRecords))
+
+	for i := 0; i < numRecords; i += 2 {
+		require.NoError(t, intIndex.Delete(i, record.NewID(i, i%7)))
+	}
+	assert.Empty(t, search(10))
+	assert.Equal(t, []*record.ID{record.NewID(11, 4)}, search(11))
+	visited := 0
+	require.NoError(t, intIndex.ForEach(func(dataValue any, dataRecordID *record.ID) error {
+		assert.Equal(t, 1, dataValue.(int)%2)
+		visited++
+		return nil
+	}))
+	assert.Equal(t, numRecords/2, visited)
+
+	// Dropping the index deletes the buckets that were split off.
+	lastBucket := bucketTable("test_split_index", stats.Buckets-1) + ".tbl"
+	blocks, err := transaction.Size(lastBucket)
+	require.NoError(t, err)
+	require.Positive(t, blocks)
+	require.NoError(t, intIndex.Drop())
+	blocks, err = transaction.Size(lastBucket)
+	require.NoError(t, err)
+	assert.Equal(t, 0, blocks)
 }
@@ -1,6 +1,20 @@
 package index
 
-import "github.com/JyotinderSingh/dropdb/record"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+const (
+	// TypeHash is the type of the indexes hashing their search keys into buckets, which only serve equality searches.
+	TypeHash = "hash"
+	// TypeBTree is the type of the indexes keeping their search keys ordered in a b-tree,
+	// which also serve the searches for the keys in a range (see RangeIndex).
+	TypeBTree = "btree"
+)
 
 type Index interface {
 	// BeforeFirst positions the index before the
@@ -20,6 +34,97 @@ type Index interface {
 	// Delete deletes the index record having the specified dataValue and dataRecordID values.
 	Delete(dataValue any, dataRecordID *record.ID) error
 
+	// ForEach calls visit with the data value and data record ID of every index record, in no particular order,
+	// stopping at the first error visit returns. It is meant for checking the index against its table.
+	ForEach(visit func(dataValue any, dataRecordID *record.ID) error) error
+
+	// Stats returns the statistics that the index keeps about its own shape,
+	// which are used to estimate the cost of searching it.
+	Stats() (*Stats, error)
+
 	// Close closes the index.
 	Close()
+
+	// Drop closes the index and deletes its files. The removal is not logged (see tx.Transaction#RemoveFile),
+	// so it is meant for the indexes removed from the catalog by a transaction that has committed.
+	Drop() error
+}
+
+// Stats describes the shape of an index, as recorded by the index itself.
+type Stats struct {
+	// Height is the number of directory blocks read to find the first block
+	// holding the records of a search key. It is 0 for a hash index.
+	Height int
+	// Blocks is the number of blocks holding index records, or -1 if the index does not record it.
+	Blocks int
+	// Buckets is the number of buckets the index records are hashed into, or 1 for a b-tree.
+	Buckets int
+	// Records is the number of index records, or -1 if the index does not record it.
+	Records int
+}
+
+// RangeIndex is implemented by the indexes that keep their records ordered by search key, which can
+// find the records whose search keys are in a range without reading the records of the other keys.
+type RangeIndex interface {
+	Index
+
+	// BeforeFirstRange positions the index before the first record whose search key is in the specified range.
+	// Next then moves through the records in the range, until there are no more of them.
+	BeforeFirstRange(keyRange Range) error
+}
+
+// Range is a range of search keys, such as the keys between 100 and 500. A nil bound leaves the range
+// unbounded on its side, and an inclusive bound is part of the range.
+type Range struct {
+	Low           any
+	LowInclusive  bool
+	High          any
+	HighInclusive bool
+}
+
+// Contains returns true if the specified search key is in the range.
+func (r Range) Contains(key any) bool {
+	return !r.Below(key) && !r.Above(key)
+}
+
+// Below returns true if the specified search key is below the low bound of the range.
+func (r Range) Below(key any) bool {
+	if r.Low == nil {
+		return false
+	}
+	if r.LowInclusive {
+		return types.CompareSupportedTypes(key, r.Low, types.LT)
+	}
+	return types.CompareSupportedTypes(key, r.Low, types.LE)
+}
+
+// Above returns true if the specified search key is above the high bound of the range.
+func (r Range) Above(key any) bool {
+	if r.High == nil {
+		return false
+	}
+	if r.HighInclusive {
+		return types.CompareSupportedTypes(key, r.High, types.GT)
+	}
+	return types.CompareSupportedTypes(key, r.High, types.GE)
+}
+
+// Describe returns the comparisons of the specified field with the bounds of the range, such as "id > 100 and id < 500".
+func (r Range) Describe(fieldName string) string {
+	var bounds []string
+	if r.Low != nil {
+		op := ">"
+		if r.LowInclusive {
+			op = ">="
+		}
+		bounds = append(bounds, fmt.Sprintf("%s %s %v", fieldName, op, r.Low))
+	}
+	if r.High != nil {
+		op := "<"
+		if r.HighInclusive {
+			op = "<="
+		}
+		bounds = append(bounds, fmt.Sprintf("%s %s %v", fieldName, op, r.High))
+	}
+	return strings.Join(bounds, " and ")
 }
@@ -8,9 +8,16 @@ import (
 )
 
 // Iterator provides the ability to move through the records of the log file in reverse order.
+// An iterator created by a log manager keeps working when the manager truncates the log (see Manager#Truncate):
+// it goes on from the record it had reached, and ends at the oldest record left in the log.
 type Iterator struct {
-	fileManager     *file.Manager
-	block           *file.BlockId
+	fileManager *file.Manager
+	// manager is the log manager of the log file, or nil if the log file is read without a manager.
+	manager *Manager
+	block   *file.BlockId
+	// blockNumber is the number of the current block, counting the blocks truncated from the log file
+	// since the manager was created, so that it does not change when the log is truncated.
+	blockNumber     int
 	page            *file.Page
 	currentPosition int
 	boundary        int
@@ -22,6 +29,7 @@ func NewIterator(fileManager *file.Manager, block *file.BlockId) (*Iterator, err
 	iterator := &Iterator{
 		fileManager: fileManager,
 		block:       block,
+		blockNumber: block.Number(),
 		page:        page,
 	}
 	if err := iterator.moveToBlock(block); err != nil {
@@ -32,7 +40,7 @@ func NewIterator(fileManager *file.Manager, block *file.BlockId) (*Iterator, err
 
 // HasNext determines if the current log record is the earliest record in the log file. Returns true if there is an earlier record.
 func (it *Iterator) HasNext() bool {
-	return it.currentPosition < it.fileManager.BlockSize() || it.block.Number() > 0
+	return it.currentPosition < it.fileManager.BlockSize() || it.blockNumber > it.firstBlockNumber()
 }
 
 // Next moves to the next log record in the block.
@@ -42,22 +50,51 @@ func (it *Iterator) Next() ([]byte, error) {
 	// Check if there are no more records left in the current block.
 	if it.currentPosition == it.fileManager.BlockSize() {
 		// Check if this is the first block.
-		if it.block.Number() == 0 {
+		if it.blockNumber <= it.firstBlockNumber() {
 			return nil, errors.New("no more log records")
 		}
 
 		// Move to the previous block in the log file.
-		it.block = &file.BlockId{File: it.block.Filename(), BlockNumber: it.block.Number() - 1}
-		if err := it.moveToBlock(it.block); err != nil {
+		if err := it.moveToPreviousBlock(); err != nil {
 			return nil, fmt.Errorf("failed to move to block: %v", err)
 		}
 	}
 
-	record := it.page.GetBytes(it.currentPosition)
+	record, err := it.page.GetBytes(it.currentPosition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log record in block %s: %w", it.block, err)
+	}
 	it.currentPosition += types.IntSize + len(record) // (size of record) + (length of record)
 	return record, nil
 }
 
+// firstBlockNumber returns the number of the first block left in the log file.
+func (it *Iterator) firstBlockNumber() int {
+	if it.manager == nil {
+		return 0
+	}
+	it.manager.truncateMu.RLock()
+	defer it.manager.truncateMu.RUnlock()
+	return it.manager.truncatedBlocks
+}
+
+// moveToPreviousBlock moves to the block preceding the current one, wherever the truncation of the log moved it in the file.
+func (it *Iterator) moveToPreviousBlock() error {
+	it.blockNumber--
+	if it.manager == nil {
+		it.block = &file.BlockId{File: it.block.Filename(), BlockNumber: it.blockNumber}
+		return it.moveToBlock(it.block)
+	}
+
+	it.manager.truncateMu.RLock()
+	defer it.manager.truncateMu.RUnlock()
+	it.block = &file.BlockId{File: it.block.Filename(), BlockNumber: it.blockNumber - it.manager.truncatedBlocks}
+	if it.block.Number() < 0 {
+		return errors.New("the log was truncated past the block")
+	}
+	return it.moveToBlock(it.block)
+}
+
 // moveToBlock moves to the specified log block and positions it at the first record in that block (i.e., the most recent one).
 func (it *Iterator) moveToBlock(block *file.BlockId) error {
 	if err := it.fileManager.Read(block, it.page); err != nil {
@@ -4,9 +4,35 @@ import (
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/types"
+	"slices"
 	"sync"
+	"time"
 )
 
+const (
+	// txNumberReservation is how many transaction numbers are reserved each time the reservation is persisted.
+	txNumberReservation = 1000
+	// txNumberFileSuffix is appended to the name of the log file to name the file holding the reservation.
+	txNumberFileSuffix = ".txnum"
+	// truncatedFileSuffix is appended to the name of the log file to name the file holding the number of
+	// records truncated from the log (see Manager#Truncate).
+	truncatedFileSuffix = ".truncated"
+	// truncationFilePrefix is prepended to the name of the log file to name the copy of the records kept by
+	// a truncation. The file manager removes the copies left over by a crash.
+	truncationFilePrefix = file.TempFilePrefix
+)
+
+// GroupCommit configures a log manager to flush the commit records of concurrent transactions together
+// (see Manager#FlushCommit), so that they share the write of the log page instead of writing it in turn.
+type GroupCommit struct {
+	// Window is how long the first commit of a group waits for others to join it before the log is flushed.
+	// Zero disables group commit: every commit flushes the log immediately.
+	Window time.Duration
+	// MaxBatch is the number of commits in a group that flushes the log without waiting for the end of the window.
+	// Zero means that the group is only flushed at the end of the window.
+	MaxBatch int
+}
+
 // Manager manages the log file. It provides methods to append log records and to iterate over them.
 // The log file contains a series of log records, each of which is a sequence of bytes. The log records are written
 // backwards in the file.
@@ -22,11 +48,56 @@ type Manager struct {
 	latestLSN    int
 	lastSavedLSN int
 	mu           sync.Mutex
+
+	// shipping is the queue of the registered shipper, or nil if there is none.
+	shipping *shipQueue
+	// unshipped holds the records appended since the last flush, which are shipped by the next flush.
+	unshipped []shipment
+	// positionBase is the position in the log file of the record preceding the first record appended since startup.
+	positionBase int
+
+	// lastTxNumber is the last transaction number that was assigned.
+	lastTxNumber int
+	// reservedTxNumber is the highest transaction number that can be assigned before more numbers are reserved.
+	reservedTxNumber int
+	// persistedTxNumber is the reservation that was last written to the transaction number file.
+	persistedTxNumber int
+
+	// active holds the LSNs of the start records of the transactions whose start record was appended
+	// (see AppendStart), but not their commit or rollback record (see AppendEnd), by transaction number.
+	active map[int]int
+
+	// truncateMu is held to read the log file by its block numbers, which a truncation changes.
+	truncateMu sync.RWMutex
+	// truncatedBlocks is the number of blocks truncated from the start of the log file since the manager was created.
+	truncatedBlocks int
+	// truncatedRecords is the number of records ever truncated from the log, which is persisted so that
+	// the positions of the records shipped to a follower keep increasing.
+	truncatedRecords int
+
+	groupCommit GroupCommit
+	// groupFlushing is true while the goroutine flushing the current group of commits waits for it to fill.
+	groupFlushing bool
+	// groupSize is the number of commits in the current group.
+	groupSize int
+	// groupFull is closed when the current group reaches the maximum batch size.
+	groupFull chan struct{}
+	// groupFlushes counts the flushes of groups, and groupErr is the error of the last one.
+	groupFlushes int
+	groupErr     error
+	// groupFlushed is signaled when a group is flushed.
+	groupFlushed *sync.Cond
 }
 
-// NewManager creates the manager for the specified log file.
+// NewManager creates the manager for the specified log file, which flushes every commit immediately.
 // If the log file does not yet exist, it is created with an empty first block.
 func NewManager(fileManager *file.Manager, logFile string) (*Manager, error) {
+	return NewManagerWithGroupCommit(fileManager, logFile, GroupCommit{})
+}
+
+// NewManagerWithGroupCommit creates the manager for the specified log file, which flushes the commits
+// of concurrent transactions in groups, as configured (see Manager#FlushCommit).
+func NewManagerWithGroupCommit(fileManager *file.Manager, logFile string, groupCommit GroupCommit) (*Manager, error) {
 	// Create a new empty page.
 	logPage := file.NewPage(fileManager.BlockSize())
 	// Get the number of blocks in the log file. No need to take a lock here since this file is only accessed by the log
@@ -51,31 +122,186 @@ func NewManager(fileManager *file.Manager, logFile string) (*Manager, error) {
 		}
 	}
 
-	return &Manager{
+	m := &Manager{
 		fileManager:  fileManager,
 		logFile:      logFile,
 		logPage:      logPage,
 		currentBlock: currentBlock,
 		latestLSN:    0,
-	}, nil
+		active:       make(map[int]int),
+		groupCommit:  groupCommit,
+	}
+	m.groupFlushed = sync.NewCond(&m.mu)
+	if err := m.readTxNumberReservation(); err != nil {
+		return nil, err
+	}
+	if err := m.readTruncatedRecords(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NextTxNumber returns the number of a new transaction.
+// Transaction numbers identify the records of a transaction in the log, so they increase
+// across restarts: the numbers that may have been assigned are reserved in a file next to the
+// log file, which is written before the log records of a transaction having a reserved number.
+// Every number below the reservation is skipped when the log manager is created.
+func (m *Manager) NextTxNumber() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastTxNumber++
+	if m.lastTxNumber > m.reservedTxNumber {
+		m.reservedTxNumber = m.lastTxNumber + txNumberReservation - 1
+	}
+	return m.lastTxNumber
+}
+
+// readTxNumberReservation starts the transaction numbering after the reservation
+// written by the previous log manager. A log written before the reservations were kept has
+// no reservation file: the numbering then starts after the highest number found in the log,
+// which is persisted as the reservation.
+func (m *Manager) readTxNumberReservation() error {
+	fileName := m.logFile + txNumberFileSuffix
+	size, err := m.fileManager.Length(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction number file length: %v", err)
+	}
+	if size == 0 {
+		highest, err := m.highestLoggedTxNumber()
+		if err != nil {
+			return err
+		}
+		m.lastTxNumber = highest
+		m.reservedTxNumber = highest
+		return m.writeTxNumberReservation()
+	}
+
+	page := file.NewPage(m.fileManager.BlockSize())
+	if err := m.fileManager.Read(file.NewBlockId(fileName, 0), page); err != nil {
+		return fmt.Errorf("failed to read transaction number reservation: %v", err)
+	}
+	m.lastTxNumber = page.GetInt(0)
+	m.reservedTxNumber = m.lastTxNumber
+	m.persistedTxNumber = m.lastTxNumber
+	return nil
+}
+
+// highestLoggedTxNumber returns the highest transaction number of the records in the log, or 0 if it has none.
+// The records of a transaction hold its number after their operation code; records too short to hold one,
+// such as checkpoints, are skipped, and any other value read in place of a number only skips more numbers.
+func (m *Manager) highestLoggedTxNumber() (int, error) {
+	iterator, err := NewIterator(m.fileManager, m.currentBlock)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the log for its transaction numbers: %v", err)
+	}
+	highest := 0
+	for iterator.HasNext() {
+		record, err := iterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the log for its transaction numbers: %v", err)
+		}
+		if len(record) < 2*types.IntSize {
+			continue
+		}
+		highest = max(highest, file.NewPageFromBytes(record).GetInt(types.IntSize))
+	}
+	return highest, nil
+}
+
+// writeTxNumberReservation writes the current reservation of transaction numbers,
+// if it was not written yet. This method is not thread-safe.
+func (m *Manager) writeTxNumberReservation() error {
+	if m.reservedTxNumber == m.persistedTxNumber {
+		return nil
+	}
+
+	page := file.NewPage(m.fileManager.BlockSize())
+	page.SetInt(0, m.reservedTxNumber)
+	if err := m.fileManager.Write(file.NewBlockId(m.logFile+txNumberFileSuffix, 0), page); err != nil {
+		return fmt.Errorf("failed to write transaction number reservation: %v", err)
+	}
+	m.persistedTxNumber = m.reservedTxNumber
+	return nil
 }
 
 func (m *Manager) Flush(lsn int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if lsn >= m.lastSavedLSN {
+	if lsn > m.lastSavedLSN {
 		return m.flush()
 	}
 	return nil
 }
 
+// FlushCommit flushes the log up to the commit record with the specified LSN, like Flush. It does not return
+// before the record is on disk. In group commit mode, the first commit waiting for a flush starts a group,
+// which the commits waiting meanwhile join: the log is flushed once for all of them, at the end of the window
+// or as soon as the group reaches the maximum batch size.
+func (m *Manager) FlushCommit(lsn int) error {
+	if m.groupCommit.Window <= 0 {
+		return m.Flush(lsn)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.groupSize++
+	if !m.groupFlushing {
+		m.groupFlushing = true
+		m.groupFull = make(chan struct{})
+		go m.flushGroup(m.groupFull)
+	}
+	if m.groupSize == m.groupCommit.MaxBatch {
+		close(m.groupFull)
+	}
+
+	// The record is on disk once the group it joined is flushed, unless a flush of a block by the buffer
+	// manager flushed it sooner.
+	flushes := m.groupFlushes
+	for lsn > m.lastSavedLSN {
+		if m.groupFlushes != flushes && m.groupErr != nil {
+			return m.groupErr
+		}
+		m.groupFlushed.Wait()
+	}
+	return nil
+}
+
+// flushGroup flushes the log once the current group of commits is full or its window ends,
+// and wakes up the commits of the group.
+func (m *Manager) flushGroup(full <-chan struct{}) {
+	timer := time.NewTimer(m.groupCommit.Window)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-full:
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.groupErr = m.flush()
+	m.groupFlushes++
+	m.groupFlushing = false
+	m.groupSize = 0
+	m.groupFlushed.Broadcast()
+}
+
 // Iterator returns an iterator over the log records.
 func (m *Manager) Iterator() (*Iterator, error) {
 	if err := m.flush(); err != nil {
 		return nil, fmt.Errorf("failed to flush log: %v", err)
 	}
-	return NewIterator(m.fileManager, m.currentBlock)
+	m.truncateMu.RLock()
+	defer m.truncateMu.RUnlock()
+	iterator, err := NewIterator(m.fileManager, m.currentBlock)
+	if err != nil {
+		return nil, err
+	}
+	iterator.manager = m
+	iterator.blockNumber += m.truncatedBlocks
+	return iterator, nil
 }
 
 // Append appends a log record to the log buffer.
@@ -90,7 +316,69 @@ func (m *Manager) Iterator() (*Iterator, error) {
 func (m *Manager) Append(logRecord []byte) (int, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.append(logRecord)
+}
+
+// AppendStart appends the start record of the specified transaction, which is active
+// from then on, until its commit or rollback record is appended with AppendEnd.
+func (m *Manager) AppendStart(txNum int, logRecord []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lsn, err := m.append(logRecord)
+	if err != nil {
+		return 0, err
+	}
+	m.active[txNum] = lsn
+	return lsn, nil
+}
+
+// AppendEnd appends the commit or rollback record of the specified transaction, which is no longer active.
+func (m *Manager) AppendEnd(txNum int, logRecord []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lsn, err := m.append(logRecord)
+	if err != nil {
+		return 0, err
+	}
+	delete(m.active, txNum)
+	return lsn, nil
+}
+
+// AppendCheckpoint appends the log record that the specified function builds from the numbers of the active
+// transactions, in increasing order. No transaction starts or ends between the moment the numbers are read
+// and the moment the record is appended, so every transaction started before the record is either in the list,
+// or has its commit or rollback record before it.
+func (m *Manager) AppendCheckpoint(buildRecord func(activeTxNums []int) []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	activeTxNums := make([]int, 0, len(m.active))
+	for txNum := range m.active {
+		activeTxNums = append(activeTxNums, txNum)
+	}
+	slices.Sort(activeTxNums)
+	return m.append(buildRecord(activeTxNums))
+}
+
+// OldestActiveLSN returns the LSN of the oldest start record of the active transactions,
+// or false if no transaction is active.
+func (m *Manager) OldestActiveLSN() (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldest, found := 0, false
+	for _, lsn := range m.active {
+		if !found || lsn < oldest {
+			oldest, found = lsn, true
+		}
+	}
+	return oldest, found
+}
 
+// append appends a log record to the log buffer. This method is not thread-safe.
+func (m *Manager) append(logRecord []byte) (int, error) {
 	// Get the current boundary.
 	boundary := m.logPage.GetInt(0)
 
@@ -123,6 +411,11 @@ func (m *Manager) Append(logRecord []byte) (int, error) {
 	m.logPage.SetInt(0, recordPosition)
 
 	m.latestLSN++
+	if m.shipping != nil {
+		record := make([]byte, recordSize)
+		copy(record, logRecord)
+		m.unshipped = append(m.unshipped, shipment{lsn: m.positionBase + m.latestLSN, record: record})
+	}
 	return m.latestLSN, nil
 }
 
@@ -145,10 +438,19 @@ func appendNewBlock(fileManager *file.Manager, logFile string, logPage *file.Pag
 }
 
 // flush writes the buffer to the log file. This method is not thread-safe.
+// The reservation of transaction numbers is written first, so that the log never
+// holds the records of a transaction whose number could be assigned again after a restart.
 func (m *Manager) flush() error {
+	if err := m.writeTxNumberReservation(); err != nil {
+		return err
+	}
 	if err := m.fileManager.Write(m.currentBlock, m.logPage); err != nil {
 		return fmt.Errorf("failed to write log page: %v", err)
 	}
 	m.lastSavedLSN = m.latestLSN
+	if m.shipping != nil {
+		m.shipping.enqueue(m.unshipped)
+		m.unshipped = nil
+	}
 	return nil
 }
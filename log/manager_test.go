@@ -3,9 +3,13 @@ package log
 import (
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"os"
+	"sync"
 	"testing"
+	"time"
 )
 
 // Helper function to create a new temporary FileMgr
@@ -60,3 +64,194 @@ func TestLogMgr_AppendAndIteratorConsistency(t *testing.T) {
 
 	assert.Falsef(iterator.HasNext(), "Expected no more records, but iterator has more")
 }
+
+func TestLogMgr_TxNumbersIncreaseAcrossRestarts(t *testing.T) {
+	assert := assert.New(t)
+	fm, cleanup, err := createTempFileMgr(400)
+	defer cleanup()
+	assert.NoError(err)
+
+	lm, err := NewManager(fm, "testlog")
+	assert.NoError(err)
+	for i := 1; i <= 3; i++ {
+		assert.Equal(i, lm.NextTxNumber())
+	}
+
+	// Nothing was flushed, so no record of these transactions can be in the log.
+	lm, err = NewManager(fm, "testlog")
+	assert.NoError(err)
+	assert.Equal(1, lm.NextTxNumber())
+	lsn, err := lm.Append([]byte("record of transaction 1"))
+	assert.NoError(err)
+	assert.NoError(lm.Flush(lsn))
+
+	// The numbers reserved before the flush are never assigned again.
+	lm, err = NewManager(fm, "testlog")
+	assert.NoError(err)
+	assert.Equal(txNumberReservation+1, lm.NextTxNumber())
+
+	// The numbering of another log is independent.
+	other, cleanupOther, err := createTempFileMgr(400)
+	defer cleanupOther()
+	assert.NoError(err)
+	otherLm, err := NewManager(other, "testlog")
+	assert.NoError(err)
+	assert.Equal(1, otherLm.NextTxNumber())
+	assert.Equal(txNumberReservation+2, lm.NextTxNumber())
+}
+
+func TestLogMgr_TxNumbersFollowLogWithoutReservation(t *testing.T) {
+	fm, cleanup, err := createTempFileMgr(400)
+	require.NoError(t, err)
+	defer cleanup()
+
+	// A log written before the reservations were kept: records holding their transaction number after their
+	// operation code, spread over several blocks, and a checkpoint record holding no number.
+	lm, err := NewManager(fm, "testlog")
+	require.NoError(t, err)
+	highest := 0
+	for i := 0; i < 100; i++ {
+		txNum := (i * 37) % 500
+		highest = max(highest, txNum)
+		record := make([]byte, 2*types.IntSize)
+		page := file.NewPageFromBytes(record)
+		page.SetInt(0, 1)
+		page.SetInt(types.IntSize, txNum)
+		_, err := lm.Append(record)
+		require.NoError(t, err)
+	}
+	lsn, err := lm.Append(make([]byte, types.IntSize))
+	require.NoError(t, err)
+	require.NoError(t, lm.Flush(lsn))
+	require.NoError(t, fm.Remove("testlog"+txNumberFileSuffix))
+
+	// The numbers assigned after a restart are higher than any number in the log.
+	lm, err = NewManager(fm, "testlog")
+	require.NoError(t, err)
+	assert.Equal(t, highest+1, lm.NextTxNumber())
+
+	// And the numbers found in the log are reserved, even before anything is flushed.
+	size, err := fm.Length("testlog" + txNumberFileSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, 1, size)
+	lm, err = NewManager(fm, "testlog")
+	require.NoError(t, err)
+	assert.Equal(t, highest+1, lm.NextTxNumber())
+}
+
+func TestLogMgr_Truncate(t *testing.T) {
+	fm, cleanup, err := createTempFileMgr(400)
+	require.NoError(t, err)
+	defer cleanup()
+
+	lm, err := NewManager(fm, "testlog")
+	require.NoError(t, err)
+	recordCount := 3000
+	for i := 1; i <= recordCount; i++ {
+		lsn, err := lm.Append([]byte(fmt.Sprintf("log record %d", i)))
+		require.NoError(t, err)
+		require.Equal(t, i, lsn)
+	}
+
+	// An iterator opened before the truncation, which has read the most recent records.
+	before, err := lm.Iterator()
+	require.NoError(t, err)
+	for i := recordCount; i > recordCount-10; i-- {
+		record, err := before.Next()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("log record %d", i), string(record))
+	}
+
+	blocks, err := fm.Length("testlog")
+	require.NoError(t, err)
+	keptLSN := 2500
+	removed, err := lm.Truncate(keptLSN)
+	require.NoError(t, err)
+	// The block of the record kept may hold a few older records.
+	assert.Greater(t, removed, keptLSN-30)
+	assert.Less(t, removed, keptLSN)
+	truncatedBlocks, err := fm.Length("testlog")
+	require.NoError(t, err)
+	assert.Less(t, truncatedBlocks, blocks/5)
+
+	// Both the iterator opened before the truncation and a new one read the records that were kept.
+	after, err := lm.Iterator()
+	require.NoError(t, err)
+	for _, iterator := range []*Iterator{after, before} {
+		next := recordCount
+		if iterator == before {
+			next -= 10
+		}
+		for ; iterator.HasNext(); next-- {
+			record, err := iterator.Next()
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("log record %d", next), string(record))
+		}
+		assert.Equal(t, removed, next, "the oldest record left follows the ones removed")
+	}
+
+	// Records are appended after the ones kept, and the LSNs are unchanged.
+	lsn, err := lm.Append([]byte("log record after truncation"))
+	require.NoError(t, err)
+	assert.Equal(t, recordCount+1, lsn)
+	_, err = lm.Truncate(recordCount + 2)
+	assert.Error(t, err)
+
+	// After a restart, the positions of the shipped records count the ones truncated.
+	require.NoError(t, lm.Flush(lsn))
+	lm, err = NewManager(fm, "testlog")
+	require.NoError(t, err)
+	assert.Error(t, lm.RegisterShipperFrom(removed-1, (&recordingShipper{}).ship))
+	shipper := &recordingShipper{}
+	require.NoError(t, lm.RegisterShipperFrom(recordCount-1, shipper.ship))
+	require.NoError(t, lm.WaitForShipping())
+	assert.Equal(t, []int{recordCount, recordCount + 1}, shipper.lsns)
+	assert.Equal(t, []string{fmt.Sprintf("log record %d", recordCount), "log record after truncation"}, shipper.records)
+}
+
+func TestLogMgr_GroupCommit(t *testing.T) {
+	fm, cleanup, err := createTempFileMgr(400)
+	require.NoError(t, err)
+	defer cleanup()
+
+	lm, err := NewManagerWithGroupCommit(fm, "testlog", GroupCommit{Window: 5 * time.Millisecond, MaxBatch: 8})
+	require.NoError(t, err)
+
+	// Every commit returns once its record is on disk, and the commits share the writes of the log.
+	committers, commits := 16, 20
+	written := fm.GetBlocksWritten()
+	var wg sync.WaitGroup
+	for c := 0; c < committers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < commits; i++ {
+				lsn, err := lm.Append([]byte(fmt.Sprintf("commit %d of %d", i, c)))
+				assert.NoError(t, err)
+				assert.NoError(t, lm.FlushCommit(lsn))
+
+				lm.mu.Lock()
+				assert.LessOrEqual(t, lsn, lm.lastSavedLSN, "the commit record must be flushed")
+				lm.mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Less(t, fm.GetBlocksWritten()-written, committers*commits/2)
+
+	// A full group is flushed without waiting for the end of the window.
+	lm, err = NewManagerWithGroupCommit(fm, "testlog", GroupCommit{Window: time.Hour, MaxBatch: 4})
+	require.NoError(t, err)
+	start := time.Now()
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lsn, err := lm.Append([]byte(fmt.Sprintf("commit of %d", c)))
+			assert.NoError(t, err)
+			assert.NoError(t, lm.FlushCommit(lsn))
+		}()
+	}
+	wg.Wait()
+	assert.Less(t, time.Since(start), time.Minute)
+}
@@ -0,0 +1,163 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Shipper receives the log records written to the log, for example to replicate them to a follower.
+// The lsn of a shipped record is its position in the log file, counting from 1 at the first record
+// ever written. Unlike the LSNs returned by Append, which restart at every startup, these positions
+// keep increasing across restarts of the database.
+type Shipper func(lsn int, record []byte) error
+
+// shipment is a log record waiting to be shipped.
+type shipment struct {
+	lsn    int
+	record []byte
+}
+
+// shipQueue delivers shipments to a shipper asynchronously and in order.
+// There is at most one goroutine delivering shipments at a time, which is started
+// when shipments are enqueued and exits once the queue is empty.
+type shipQueue struct {
+	shipper Shipper
+	pending []shipment
+	running bool
+	err     error
+	mu      sync.Mutex
+	idle    *sync.Cond
+}
+
+// RegisterShipper registers a shipper that is called with every log record written after this call.
+// Records are shipped asynchronously, in log order, once they have been flushed to disk.
+// Only one shipper can be registered.
+func (m *Manager) RegisterShipper(shipper Shipper) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.startShipping(shipper)
+}
+
+// RegisterShipperFrom registers a shipper like RegisterShipper, but first ships the records
+// already in the log whose position is greater than afterLSN. A follower passes the position
+// of the last record it applied, so that it catches up with the log before receiving new records.
+func (m *Manager) RegisterShipperFrom(afterLSN int, shipper Shipper) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if afterLSN < m.truncatedRecords {
+		return fmt.Errorf("cannot ship the log records after position %d, since the records up to position %d were truncated",
+			afterLSN, m.truncatedRecords)
+	}
+	if err := m.startShipping(shipper); err != nil {
+		return err
+	}
+
+	// The iterator returns the records from the most recent one, whose position is the number of records.
+	var backlog []shipment
+	iter, err := m.Iterator()
+	if err != nil {
+		return err
+	}
+	for lsn := m.positionBase + m.latestLSN; lsn > afterLSN && iter.HasNext(); lsn-- {
+		record, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("failed to read log record %d: %v", lsn, err)
+		}
+		backlog = append(backlog, shipment{lsn: lsn, record: record})
+	}
+	for i, j := 0, len(backlog)-1; i < j; i, j = i+1, j-1 {
+		backlog[i], backlog[j] = backlog[j], backlog[i]
+	}
+
+	m.shipping.enqueue(backlog)
+	return nil
+}
+
+// WaitForShipping blocks until every flushed log record has been shipped.
+// It returns the first error returned by the shipper, after which no more records are shipped.
+func (m *Manager) WaitForShipping() error {
+	m.mu.Lock()
+	queue := m.shipping
+	m.mu.Unlock()
+
+	if queue == nil {
+		return errors.New("no shipper registered")
+	}
+	return queue.wait()
+}
+
+// startShipping counts the records in the log, and those truncated from it, to number them by their position,
+// and creates the queue for the shipper. This method is not thread-safe.
+func (m *Manager) startShipping(shipper Shipper) error {
+	if m.shipping != nil {
+		return errors.New("a shipper is already registered")
+	}
+
+	iter, err := m.Iterator()
+	if err != nil {
+		return err
+	}
+	count := 0
+	for iter.HasNext() {
+		if _, err := iter.Next(); err != nil {
+			return fmt.Errorf("failed to count log records: %v", err)
+		}
+		count++
+	}
+
+	m.positionBase = m.truncatedRecords + count - m.latestLSN
+	m.shipping = &shipQueue{shipper: shipper}
+	m.shipping.idle = sync.NewCond(&m.shipping.mu)
+	return nil
+}
+
+// enqueue adds the shipments to the queue and starts delivering them if no delivery is running.
+func (q *shipQueue) enqueue(shipments []shipment) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(shipments) == 0 || q.err != nil {
+		return
+	}
+	q.pending = append(q.pending, shipments...)
+	if !q.running {
+		q.running = true
+		go q.deliver()
+	}
+}
+
+// deliver ships the pending shipments in order until the queue is empty or the shipper fails.
+func (q *shipQueue) deliver() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.pending) > 0 && q.err == nil {
+		next := q.pending[0]
+		q.pending = q.pending[1:]
+
+		q.mu.Unlock()
+		err := q.shipper(next.lsn, next.record)
+		q.mu.Lock()
+
+		if err != nil {
+			q.err = fmt.Errorf("failed to ship log record %d: %w", next.lsn, err)
+			q.pending = nil
+		}
+	}
+	q.running = false
+	q.idle.Broadcast()
+}
+
+// wait blocks until no delivery is running and returns the shipper's error, if any.
+func (q *shipQueue) wait() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.running {
+		q.idle.Wait()
+	}
+	return q.err
+}
@@ -0,0 +1,125 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingShipper collects the shipped records by their LSN.
+type recordingShipper struct {
+	mu      sync.Mutex
+	lsns    []int
+	records []string
+}
+
+func (rs *recordingShipper) ship(lsn int, record []byte) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.lsns = append(rs.lsns, lsn)
+	rs.records = append(rs.records, string(record))
+	return nil
+}
+
+func TestShipper_ShipsFlushedRecordsInOrder(t *testing.T) {
+	fm, cleanup, err := createTempFileMgr(400)
+	require.NoError(t, err)
+	defer cleanup()
+
+	lm, err := NewManager(fm, "testlog")
+	require.NoError(t, err)
+
+	// Records written before the shipper is registered are not shipped.
+	_, err = lm.Append([]byte("before"))
+	require.NoError(t, err)
+
+	shipper := &recordingShipper{}
+	require.NoError(t, lm.RegisterShipper(shipper.ship))
+	assert.Error(t, lm.RegisterShipper(shipper.ship))
+
+	var expected []string
+	var lastLSN int
+	for i := 1; i <= 50; i++ {
+		record := fmt.Sprintf("log record %d", i)
+		expected = append(expected, record)
+		lastLSN, err = lm.Append([]byte(record))
+		require.NoError(t, err)
+	}
+	require.NoError(t, lm.Flush(lastLSN))
+	require.NoError(t, lm.WaitForShipping())
+
+	assert.Equal(t, expected, shipper.records)
+	for i, lsn := range shipper.lsns {
+		assert.Equal(t, i+2, lsn, "a shipped LSN is the position of the record in the log")
+	}
+
+	// Records are only shipped once they are flushed.
+	_, err = lm.Append([]byte("unflushed"))
+	require.NoError(t, err)
+	require.NoError(t, lm.WaitForShipping())
+	assert.Len(t, shipper.records, 50)
+}
+
+func TestShipper_RegisterFromCatchesUpAcrossRestarts(t *testing.T) {
+	fm, cleanup, err := createTempFileMgr(400)
+	require.NoError(t, err)
+	defer cleanup()
+
+	lm, err := NewManager(fm, "testlog")
+	require.NoError(t, err)
+	for i := 1; i <= 30; i++ {
+		_, err := lm.Append([]byte(fmt.Sprintf("log record %d", i)))
+		require.NoError(t, err)
+	}
+	_, err = lm.Iterator() // flushes the log
+	require.NoError(t, err)
+
+	// The LSNs returned by a new manager restart at 1, but the shipped positions continue.
+	lm, err = NewManager(fm, "testlog")
+	require.NoError(t, err)
+	shipper := &recordingShipper{}
+	require.NoError(t, lm.RegisterShipperFrom(25, shipper.ship))
+
+	lsn, err := lm.Append([]byte("log record 31"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, lsn)
+	require.NoError(t, lm.Flush(lsn))
+	require.NoError(t, lm.WaitForShipping())
+
+	assert.Equal(t, []int{26, 27, 28, 29, 30, 31}, shipper.lsns)
+	for i, record := range shipper.records {
+		assert.Equal(t, fmt.Sprintf("log record %d", shipper.lsns[i]), record)
+	}
+}
+
+func TestShipper_StopsAfterError(t *testing.T) {
+	fm, cleanup, err := createTempFileMgr(400)
+	require.NoError(t, err)
+	defer cleanup()
+
+	lm, err := NewManager(fm, "testlog")
+	require.NoError(t, err)
+
+	shipErr := errors.New("follower unavailable")
+	calls := 0
+	require.NoError(t, lm.RegisterShipper(func(lsn int, record []byte) error {
+		calls++
+		if lsn == 2 {
+			return shipErr
+		}
+		return nil
+	}))
+
+	for i := 1; i <= 5; i++ {
+		lsn, err := lm.Append([]byte(fmt.Sprintf("log record %d", i)))
+		require.NoError(t, err)
+		require.NoError(t, lm.Flush(lsn))
+	}
+
+	assert.ErrorIs(t, lm.WaitForShipping(), shipErr)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// Truncate removes the blocks at the start of the log file that only hold records older than the record with
+// the specified LSN, which must have been appended since the manager was created, and returns the number of
+// records removed. The block holding the record is kept whole, so a few older records may remain.
+//
+// The blocks kept are copied to a new file, which then replaces the log file. Iterators over the log go on
+// from where they are, and end at the oldest record left (see Iterator). The number of records removed is added
+// to a count persisted next to the log file once it is replaced, so that the positions of the records shipped
+// to a follower keep increasing (see Shipper). A crash in between only leaves that count behind.
+func (m *Manager) Truncate(beforeLSN int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if beforeLSN < 1 || beforeLSN > m.latestLSN {
+		return 0, fmt.Errorf("cannot truncate the log before LSN %d, which is not one of the LSNs 1 to %d appended since startup",
+			beforeLSN, m.latestLSN)
+	}
+	if err := m.flush(); err != nil {
+		return 0, fmt.Errorf("failed to flush log: %v", err)
+	}
+
+	// The log is read backwards from the latest record, down to the record to keep.
+	iterator, err := NewIterator(m.fileManager, m.currentBlock)
+	if err != nil {
+		return 0, err
+	}
+	for lsn := m.latestLSN; lsn >= beforeLSN; lsn-- {
+		if _, err := iterator.Next(); err != nil {
+			return 0, fmt.Errorf("failed to read log record %d: %v", lsn, err)
+		}
+	}
+	keptBlocks := iterator.block.Number()
+	if keptBlocks == 0 {
+		return 0, nil
+	}
+
+	removed := 0
+	page := file.NewPage(m.fileManager.BlockSize())
+	for blockNumber := 0; blockNumber < keptBlocks; blockNumber++ {
+		if err := m.fileManager.Read(file.NewBlockId(m.logFile, blockNumber), page); err != nil {
+			return 0, fmt.Errorf("failed to read log block %d: %v", blockNumber, err)
+		}
+		removed += countRecords(page, m.fileManager.BlockSize())
+	}
+
+	copyFile := truncationFilePrefix + m.logFile
+	if err := m.fileManager.Remove(copyFile); err != nil {
+		return 0, err
+	}
+	for blockNumber := keptBlocks; blockNumber <= m.currentBlock.Number(); blockNumber++ {
+		if err := m.fileManager.Read(file.NewBlockId(m.logFile, blockNumber), page); err != nil {
+			return 0, fmt.Errorf("failed to read log block %d: %v", blockNumber, err)
+		}
+		if err := m.fileManager.Write(file.NewBlockId(copyFile, blockNumber-keptBlocks), page); err != nil {
+			return 0, fmt.Errorf("failed to copy log block %d: %v", blockNumber, err)
+		}
+	}
+
+	m.truncateMu.Lock()
+	defer m.truncateMu.Unlock()
+	if err := m.fileManager.Rename(copyFile, m.logFile); err != nil {
+		return 0, err
+	}
+	m.currentBlock = file.NewBlockId(m.logFile, m.currentBlock.Number()-keptBlocks)
+	m.truncatedBlocks += keptBlocks
+	m.truncatedRecords += removed
+	return removed, m.writeTruncatedRecords()
+}
+
+// countRecords returns the number of log records in the specified page of a log block.
+func countRecords(page *file.Page, blockSize int) int {
+	count := 0
+	for position := page.GetInt(0); position < blockSize; count++ {
+		position += types.IntSize + page.GetInt(position)
+	}
+	return count
+}
+
+// readTruncatedRecords reads the number of records truncated from the log, if any were.
+func (m *Manager) readTruncatedRecords() error {
+	fileName := m.logFile + truncatedFileSuffix
+	size, err := m.fileManager.Length(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to get truncated records file length: %v", err)
+	}
+	if size == 0 {
+		return nil
+	}
+
+	page := file.NewPage(m.fileManager.BlockSize())
+	if err := m.fileManager.Read(file.NewBlockId(fileName, 0), page); err != nil {
+		return fmt.Errorf("failed to read the number of truncated records: %v", err)
+	}
+	m.truncatedRecords = page.GetInt(0)
+	return nil
+}
+
+// writeTruncatedRecords writes the number of records truncated from the log. This method is not thread-safe.
+func (m *Manager) writeTruncatedRecords() error {
+	page := file.NewPage(m.fileManager.BlockSize())
+	page.SetInt(0, m.truncatedRecords)
+	if err := m.fileManager.Write(file.NewBlockId(m.logFile+truncatedFileSuffix, 0), page); err != nil {
+		return fmt.Errorf("failed to write the number of truncated records: %v", err)
+	}
+	return nil
+}
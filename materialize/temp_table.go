@@ -1,7 +1,9 @@
 package materialize
 
 import (
+	"errors"
 	"fmt"
+	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/table"
@@ -9,7 +11,9 @@ import (
 	"sync"
 )
 
-const tempTablePrefix = "temp"
+// tempTablePrefix starts the names of the temporary tables, so that the file manager removes the files
+// of the tables left over by a crash when the database is next opened.
+const tempTablePrefix = file.TempFilePrefix
 
 // TempTable represents a temporary table not registered in the catalog.
 type TempTable struct {
@@ -24,12 +28,18 @@ var (
 )
 
 // NewTempTable creates a new temporary table with the specified schema and transaction.
+// The file of the table is removed when the transaction ends.
 func NewTempTable(tx *tx.Transaction, schema *record.Schema) *TempTable {
-	return &TempTable{
+	tt := &TempTable{
 		tx:      tx,
 		tblName: nextTableName(),
 		layout:  record.NewLayout(schema),
 	}
+	tx.OnEnd(func() {
+		// A file that cannot be removed is left to the sweep of temporary files when the database is next opened.
+		_ = table.RemoveTable(tx, tt.tblName)
+	})
+	return tt
 }
 
 // Open opens a table scan for the temporary table.
@@ -37,6 +47,23 @@ func (tt *TempTable) Open() (scan.UpdateScan, error) {
 	return table.NewTableScan(tt.tx, tt.tblName, tt.layout)
 }
 
+// OpenRemovingOnClose opens a table scan for the temporary table, which removes the table when it is closed.
+// It is meant for a table read by a single scan, such as the output of a materialized query.
+func (tt *TempTable) OpenRemovingOnClose() (scan.UpdateScan, error) {
+	tableScan, err := tt.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &removingScan{UpdateScan: tableScan, table: tt}, nil
+}
+
+// Remove deletes the file of the temporary table once no scan of it is open, rather than when the transaction ends,
+// so that a table whose records are no longer needed does not hold on to disk space.
+// It fails, leaving the file, if a block of the table is still pinned.
+func (tt *TempTable) Remove() error {
+	return table.RemoveTable(tt.tx, tt.tblName)
+}
+
 // TableName returns the name of the temporary table.
 func (tt *TempTable) TableName() string {
 	return tt.tblName
@@ -54,3 +81,14 @@ func nextTableName() string {
 	nextTableNum++
 	return fmt.Sprintf("%s%d", tempTablePrefix, nextTableNum)
 }
+
+// removingScan is a scan of a temporary table that removes the table when it is closed.
+type removingScan struct {
+	scan.UpdateScan
+	table *TempTable
+}
+
+// Close closes the scan, and removes its table.
+func (rs *removingScan) Close() error {
+	return errors.Join(rs.UpdateScan.Close(), rs.table.Remove())
+}
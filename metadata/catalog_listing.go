@@ -0,0 +1,195 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// ErrReadOnlyTable is returned by statements that would change a catalog listing, or create a table or view
+// named after one: the listings only change as the tables, views and indexes they describe are created and dropped.
+var ErrReadOnlyTable = errors.New("read-only table")
+
+// The catalog listings are virtual tables describing the tables, views, indexes and constraints created by the users
+// of the database, which queries read like any table. Their records are read from the catalog when a query
+// reading them is planned (see GetCatalogListing), so they reflect the statements executed until then.
+const (
+	// TablesListing holds one record per table, with its name and storage attributes.
+	TablesListing = "dropdb_tables"
+	// ColumnsListing holds one record per field of each table, in the order the fields were declared.
+	ColumnsListing = "dropdb_columns"
+	// IndexesListing holds one record per index, with its comma-separated fields and its attributes.
+	IndexesListing = "dropdb_indexes"
+	// ViewsListing holds one record per view, with its definition.
+	ViewsListing = "dropdb_views"
+	// ConstraintsListing holds one record per constraint of each table, with the name of the table followed by
+	// the fields of ConstraintSchema. The unique indexes are the only constraints the catalog records.
+	ConstraintsListing = "dropdb_constraints"
+
+	// ListingTableNameField is the field of the listings other than the ViewsListing holding the name of a table.
+	ListingTableNameField = "tblname"
+	// ColumnTypeField is the field of the ColumnsListing holding the type of a field, such as "varchar".
+	ColumnTypeField = "field_type"
+	// ColumnLengthField is the field of the ColumnsListing holding the length of a varchar field, and 0 otherwise.
+	ColumnLengthField = "field_length"
+	// ColumnOrdinalField is the field of the ColumnsListing holding the position of a field in its table, from 0.
+	ColumnOrdinalField = "field_ordinal"
+)
+
+// maxTypeNameLength is the length of the longest type name of the ColumnsListing.
+const maxTypeNameLength = len("varchar")
+
+// IsCatalogListing returns true if the specified name is the name of a catalog listing.
+func IsCatalogListing(name string) bool {
+	switch name {
+	case TablesListing, ColumnsListing, IndexesListing, ViewsListing, ConstraintsListing:
+		return true
+	default:
+		return false
+	}
+}
+
+// CheckWritable returns an error wrapping ErrReadOnlyTable if the specified table is a catalog listing.
+func CheckWritable(tableName string) error {
+	if IsCatalogListing(tableName) {
+		return fmt.Errorf("%w: %s lists the catalog, which only changes by creating and dropping tables, views and indexes",
+			ErrReadOnlyTable, tableName)
+	}
+	return nil
+}
+
+// CatalogListingSchema returns the schema of the specified catalog listing, or nil if there is no such listing.
+// The fields holding names other than the name of a table are named like the fields of the catalog tables.
+func CatalogListingSchema(listing string) *record.Schema {
+	schema := record.NewSchema()
+	switch listing {
+	case TablesListing:
+		schema.AddStringField(ListingTableNameField, maxNameLength)
+		schema.AddIntField(slotSizeField)
+		schema.AddBoolField(compressedField)
+	case ColumnsListing:
+		schema.AddStringField(ListingTableNameField, maxNameLength)
+		schema.AddStringField(fieldNameField, maxNameLength)
+		schema.AddStringField(ColumnTypeField, maxTypeNameLength)
+		schema.AddIntField(ColumnLengthField)
+		schema.AddIntField(ColumnOrdinalField)
+	case IndexesListing:
+		schema.AddStringField(indexNameField, maxNameLength)
+		schema.AddStringField(ListingTableNameField, maxNameLength)
+		schema.AddStringField(ConstraintColumnsField, maxNameLength)
+		schema.AddStringField(indexTypeField, maxIndexTypeLength)
+		schema.AddBoolField(indexUniqueField)
+		schema.AddStringField(indexPredicateField, maxIndexPredicateLength)
+	case ViewsListing:
+		schema.AddStringField(viewNameField, maxNameLength)
+		schema.AddStringField(viewDefinitionField, maxViewDefinitionLength)
+	case ConstraintsListing:
+		schema.AddStringField(ListingTableNameField, maxNameLength)
+		schema.AddAll(ConstraintSchema())
+	default:
+		return nil
+	}
+	return schema
+}
+
+// GetCatalogListing returns the records of the specified catalog listing, read from the catalog, each holding
+// the values of the fields of CatalogListingSchema in order. The records follow the order in which the tables
+// and views were created, and the indexes and constraints of each table are ordered by name.
+func (m *Manager) GetCatalogListing(listing string, transaction *tx.Transaction) ([][]any, error) {
+	if listing == ViewsListing {
+		views, err := m.GetViews(transaction)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([][]any, len(views))
+		for i, view := range views {
+			rows[i] = []any{view.Name, view.Definition}
+		}
+		return rows, nil
+	}
+	if !IsCatalogListing(listing) {
+		return nil, fmt.Errorf("catalog listing %s not found", listing)
+	}
+
+	tableNames, err := m.GetTableNames(transaction)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]any
+	for _, tableName := range tableNames {
+		var tableRows [][]any
+		switch listing {
+		case TablesListing:
+			tableRows, err = m.tableListing(tableName, transaction)
+		case ColumnsListing:
+			tableRows, err = m.columnListing(tableName, transaction)
+		case IndexesListing:
+			tableRows, err = m.indexListing(tableName, transaction)
+		case ConstraintsListing:
+			tableRows, err = m.constraintListing(tableName, transaction)
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, tableRows...)
+	}
+	return rows, nil
+}
+
+// tableListing returns the record of the TablesListing describing the specified table.
+func (m *Manager) tableListing(tableName string, transaction *tx.Transaction) ([][]any, error) {
+	layout, err := m.GetLayout(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	options, err := m.GetTableOptions(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	return [][]any{{tableName, layout.SlotSize(), options.Compressed}}, nil
+}
+
+// columnListing returns the records of the ColumnsListing describing the fields of the specified table.
+func (m *Manager) columnListing(tableName string, transaction *tx.Transaction) ([][]any, error) {
+	layout, err := m.GetLayout(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	schema := layout.Schema()
+	rows := make([][]any, len(schema.Fields()))
+	for i, fieldName := range schema.Fields() {
+		rows[i] = []any{tableName, fieldName, schema.Type(fieldName).String(), schema.Length(fieldName), i}
+	}
+	return rows, nil
+}
+
+// indexListing returns the records of the IndexesListing describing the indexes of the specified table.
+func (m *Manager) indexListing(tableName string, transaction *tx.Transaction) ([][]any, error) {
+	definitions, err := m.indexManager.indexDefinitions(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]any, len(definitions))
+	for i, definition := range definitions {
+		rows[i] = []any{definition.indexName, tableName, strings.Join(definition.fieldNames, ","),
+			definition.indexType, definition.unique, definition.predicate}
+	}
+	return rows, nil
+}
+
+// constraintListing returns the records of the ConstraintsListing describing the constraints of the specified table.
+func (m *Manager) constraintListing(tableName string, transaction *tx.Transaction) ([][]any, error) {
+	constraints, err := m.GetTableConstraints(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]any, len(constraints))
+	for i, constraint := range constraints {
+		rows[i] = []any{tableName, constraint.Name, string(constraint.Kind), strings.Join(constraint.Columns, ","),
+			constraint.Predicate}
+	}
+	return rows, nil
+}
@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/record"
+)
+
+func TestManager_GetCatalogListing(t *testing.T) {
+	m, txn, cleanup := setupManagerTest(t)
+	defer cleanup()
+
+	// A new database has no table, view or index of its users, whatever its catalog tables.
+	for _, listing := range []string{TablesListing, ColumnsListing, IndexesListing, ViewsListing, ConstraintsListing} {
+		rows, err := m.GetCatalogListing(listing, txn)
+		require.NoError(t, err)
+		assert.Empty(t, rows, listing)
+	}
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("email", 30)
+	require.NoError(t, m.CreateTable("users", schema, txn))
+	require.NoError(t, m.CreateIndexWithOptions("users_id", "users", "id", IndexOptions{Unique: true, Type: index.TypeBTree}, txn))
+	require.NoError(t, m.CreatePartialIndex("users_email", "users", "email", "id > 5", txn))
+	require.NoError(t, m.CreateView("user_ids", "select id from users", txn))
+
+	layout, err := m.GetLayout("users", txn)
+	require.NoError(t, err)
+	tables, err := m.GetCatalogListing(TablesListing, txn)
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{"users", layout.SlotSize(), false}}, tables)
+
+	columns, err := m.GetCatalogListing(ColumnsListing, txn)
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{"users", "id", "int", 0, 0}, {"users", "email", "varchar", 30, 1}}, columns)
+
+	indexes, err := m.GetCatalogListing(IndexesListing, txn)
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{
+		{"users_email", "users", "email", index.TypeHash, false, "id > 5"},
+		{"users_id", "users", "id", index.TypeBTree, true, ""},
+	}, indexes)
+
+	views, err := m.GetCatalogListing(ViewsListing, txn)
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{"user_ids", "select id from users"}}, views)
+
+	constraints, err := m.GetCatalogListing(ConstraintsListing, txn)
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{"users", "users_id", "UNIQUE", "id", ""}}, constraints)
+
+	// Every record holds a value of each field of the schema of its listing.
+	for listing, rows := range map[string][][]any{TablesListing: tables, ColumnsListing: columns, IndexesListing: indexes,
+		ViewsListing: views, ConstraintsListing: constraints} {
+		for _, row := range rows {
+			assert.Len(t, row, len(CatalogListingSchema(listing).Fields()), listing)
+		}
+	}
+
+	// Dropping the table removes it from the listings, along with its indexes and constraints.
+	require.NoError(t, m.DropTable("users", txn))
+	for _, listing := range []string{TablesListing, ColumnsListing, IndexesListing, ConstraintsListing} {
+		rows, err := m.GetCatalogListing(listing, txn)
+		require.NoError(t, err)
+		assert.Empty(t, rows, listing)
+	}
+
+	_, err = m.GetCatalogListing("table_catalog", txn)
+	assert.EqualError(t, err, "catalog listing table_catalog not found")
+	assert.Nil(t, CatalogListingSchema("table_catalog"))
+}
+
+func TestCheckWritable(t *testing.T) {
+	for _, listing := range []string{TablesListing, ColumnsListing, IndexesListing, ViewsListing, ConstraintsListing} {
+		assert.True(t, IsCatalogListing(listing))
+		assert.ErrorIs(t, CheckWritable(listing), ErrReadOnlyTable)
+	}
+	assert.False(t, IsCatalogListing("users"))
+	assert.NoError(t, CheckWritable("users"))
+	assert.EqualError(t, CheckWritable(ColumnsListing),
+		"read-only table: dropdb_columns lists the catalog, which only changes by creating and dropping tables, views and indexes")
+}
@@ -0,0 +1,137 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// Ensure ConstraintScan implements the Scan interface.
+var _ scan.Scan = (*ConstraintScan)(nil)
+
+const (
+	// ConstraintNameField is the field of a constraint listing that holds the name of the constraint.
+	ConstraintNameField = "constraint_name"
+	// ConstraintKindField is the field of a constraint listing that holds the kind of the constraint.
+	ConstraintKindField = "constraint_kind"
+	// ConstraintColumnsField is the field of a constraint listing that holds the comma-separated columns of the constraint.
+	ConstraintColumnsField = "column_names"
+	// ConstraintPredicateField is the field of a constraint listing that holds the predicate of the constraint,
+	// or an empty string if it applies to the whole table.
+	ConstraintPredicateField = "predicate"
+)
+
+// ConstraintSchema returns the schema of the records returned by a ConstraintScan.
+func ConstraintSchema() *record.Schema {
+	schema := record.NewSchema()
+	schema.AddStringField(ConstraintNameField, maxNameLength)
+	schema.AddStringField(ConstraintKindField, len(UniqueConstraint))
+	schema.AddStringField(ConstraintColumnsField, maxNameLength)
+	schema.AddStringField(ConstraintPredicateField, maxIndexPredicateLength)
+	return schema
+}
+
+// ConstraintScan is a scan over the constraints of a table, returning one record per constraint
+// with the fields described by ConstraintSchema.
+type ConstraintScan struct {
+	constraints []TableConstraint
+	current     int
+}
+
+// NewConstraintScan creates a scan over the specified constraints.
+func NewConstraintScan(constraints []TableConstraint) *ConstraintScan {
+	return &ConstraintScan{constraints: constraints, current: -1}
+}
+
+// BeforeFirst positions the scan before the first constraint.
+func (cs *ConstraintScan) BeforeFirst() error {
+	cs.current = -1
+	return nil
+}
+
+// Next moves to the next constraint.
+func (cs *ConstraintScan) Next() (bool, error) {
+	if cs.current+1 >= len(cs.constraints) {
+		return false, nil
+	}
+	cs.current++
+	return true, nil
+}
+
+// GetVal returns the value of the specified field for the current constraint.
+func (cs *ConstraintScan) GetVal(fieldName string) (any, error) {
+	if cs.current < 0 || cs.current >= len(cs.constraints) {
+		return nil, fmt.Errorf("no current constraint")
+	}
+	constraint := cs.constraints[cs.current]
+	switch fieldName {
+	case ConstraintNameField:
+		return constraint.Name, nil
+	case ConstraintKindField:
+		return string(constraint.Kind), nil
+	case ConstraintColumnsField:
+		return strings.Join(constraint.Columns, ","), nil
+	case ConstraintPredicateField:
+		return constraint.Predicate, nil
+	default:
+		return nil, fmt.Errorf("field %s not found", fieldName)
+	}
+}
+
+// GetString returns the value of the specified field for the current constraint.
+func (cs *ConstraintScan) GetString(fieldName string) (string, error) {
+	val, err := cs.GetVal(fieldName)
+	if err != nil {
+		return "", err
+	}
+	return val.(string), nil
+}
+
+// GetInt returns an error, since every field of a constraint listing is a string.
+func (cs *ConstraintScan) GetInt(fieldName string) (int, error) {
+	return 0, fmt.Errorf("field %s is not an int", fieldName)
+}
+
+// GetLong returns an error, since every field of a constraint listing is a string.
+func (cs *ConstraintScan) GetLong(fieldName string) (int64, error) {
+	return 0, fmt.Errorf("field %s is not a long", fieldName)
+}
+
+// GetShort returns an error, since every field of a constraint listing is a string.
+func (cs *ConstraintScan) GetShort(fieldName string) (int16, error) {
+	return 0, fmt.Errorf("field %s is not a short", fieldName)
+}
+
+// GetFloat returns an error, since every field of a constraint listing is a string.
+func (cs *ConstraintScan) GetFloat(fieldName string) (float64, error) {
+	return 0, fmt.Errorf("field %s is not a float", fieldName)
+}
+
+// GetBool returns an error, since every field of a constraint listing is a string.
+func (cs *ConstraintScan) GetBool(fieldName string) (bool, error) {
+	return false, fmt.Errorf("field %s is not a bool", fieldName)
+}
+
+// GetDate returns an error, since every field of a constraint listing is a string.
+func (cs *ConstraintScan) GetDate(fieldName string) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("field %s is not a date", fieldName)
+}
+
+// HasField returns true if the specified field is described by ConstraintSchema.
+func (cs *ConstraintScan) HasField(fieldName string) bool {
+	return ConstraintSchema().HasField(fieldName)
+}
+
+// Fields returns the fields of the constraint listing, which are described by ConstraintSchema.
+func (cs *ConstraintScan) Fields() []types.FieldInfo {
+	return ConstraintSchema().FieldInfos()
+}
+
+// Close does nothing, since the constraints are held in memory.
+func (cs *ConstraintScan) Close() error {
+	return nil
+}
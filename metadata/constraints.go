@@ -0,0 +1,191 @@
+package metadata
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// ConstraintKind is the kind of a table constraint.
+type ConstraintKind string
+
+const (
+	// UniqueConstraint forbids two records of the table from having the same values of its columns.
+	// It is enforced by a unique index, and only applies to the records satisfying its predicate, if any.
+	UniqueConstraint ConstraintKind = "UNIQUE"
+)
+
+// TableConstraint describes a constraint of a table, as recorded in the catalog.
+// Unique constraints are the only kind of constraint the catalog records: they are
+// the unique indexes of the table.
+type TableConstraint struct {
+	// Name is the name of the constraint, which is the name of the index enforcing it.
+	Name string
+	// Kind is the kind of the constraint.
+	Kind ConstraintKind
+	// Columns are the fields the constraint applies to.
+	Columns []string
+	// Predicate is the text of the predicate restricting the records the constraint applies to,
+	// or an empty string if it applies to the whole table.
+	Predicate string
+}
+
+// GetTableConstraints returns the constraints of the specified table, ordered by name.
+func (m *Manager) GetTableConstraints(tableName string, transaction *tx.Transaction) ([]TableConstraint, error) {
+	definitions, err := m.indexManager.indexDefinitions(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	var constraints []TableConstraint
+	for _, definition := range definitions {
+		if !definition.unique {
+			continue
+		}
+		constraints = append(constraints, TableConstraint{
+			Name:      definition.indexName,
+			Kind:      UniqueConstraint,
+			Columns:   definition.fieldNames,
+			Predicate: definition.predicate,
+		})
+	}
+	return constraints, nil
+}
+
+// GenerateDDL returns the statements recreating the specified table: a CREATE TABLE statement
+// with the fields and storage attributes of the table, followed by a CREATE INDEX statement for
+// each of its indexes, ordered by name, which recreate its constraints along with its other indexes.
+// Executed in another database, the statements create a table with the same catalog entries.
+func (m *Manager) GenerateDDL(tableName string, transaction *tx.Transaction) ([]string, error) {
+	layout, err := m.GetLayout(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if len(layout.Schema().Fields()) == 0 {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+	options, err := m.GetTableOptions(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldDefs := make([]string, 0, len(layout.Schema().Fields()))
+	for _, fieldName := range layout.Schema().Fields() {
+		fieldType, err := fieldTypeDDL(layout.Schema(), fieldName)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", tableName, err)
+		}
+		fieldDefs = append(fieldDefs, fieldName+" "+fieldType)
+	}
+	createTable := fmt.Sprintf("create table %s (%s)", tableName, strings.Join(fieldDefs, ", "))
+	if options.Compressed {
+		createTable += " with compression"
+	}
+	statements := []string{createTable}
+
+	definitions, err := m.indexManager.indexDefinitions(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	for _, definition := range definitions {
+		createIndex := "create index"
+		if definition.unique {
+			createIndex = "create unique index"
+		}
+		createIndex += fmt.Sprintf(" %s on %s (%s)", definition.indexName, tableName, strings.Join(definition.fieldNames, ", "))
+		if definition.indexType != index.TypeHash {
+			createIndex += " using " + definition.indexType
+		}
+		if definition.predicate != "" {
+			createIndex += " where " + definition.predicate
+		}
+		statements = append(statements, createIndex)
+	}
+	return statements, nil
+}
+
+// fieldTypeDDL returns the type of the specified field, as written in a CREATE TABLE statement.
+func fieldTypeDDL(schema *record.Schema, fieldName string) (string, error) {
+	switch schema.Type(fieldName) {
+	case types.Integer:
+		return "int", nil
+	case types.Float:
+		return "float", nil
+	case types.Varchar:
+		return fmt.Sprintf("varchar(%d)", schema.Length(fieldName)), nil
+	case types.Boolean:
+		return "bool", nil
+	case types.Date:
+		return "date", nil
+	default:
+		return "", fmt.Errorf("field %s has type %s, which CREATE TABLE cannot declare", fieldName, schema.Type(fieldName))
+	}
+}
+
+// indexDefinition is the definition of an index, as recorded in the index catalog.
+type indexDefinition struct {
+	indexName  string
+	fieldNames []string
+	predicate  string
+	unique     bool
+	indexType  string
+}
+
+// indexDefinitions returns the definitions of the indexes on the specified table, ordered by index name.
+// Unlike GetIndexInfo, it returns the predicates as they were written, and does not read the statistics of the table.
+func (im *IndexManager) indexDefinitions(tableName string, transaction *tx.Transaction) ([]indexDefinition, error) {
+	tableScan, err := table.NewTableScan(transaction, indexCatalogTable, im.layout)
+	if err != nil {
+		return nil, err
+	}
+	defer tableScan.Close()
+
+	var result []indexDefinition
+	for {
+		hasNext, err := tableScan.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			break
+		}
+
+		currentTableName, err := tableScan.GetString(tableNameField)
+		if err != nil {
+			return nil, err
+		}
+		if currentTableName != tableName {
+			continue
+		}
+
+		var definition indexDefinition
+		if definition.indexName, err = tableScan.GetString(indexNameField); err != nil {
+			return nil, err
+		}
+		if definition.fieldNames, err = im.indexFields(tableScan, transaction); err != nil {
+			return nil, err
+		}
+		if im.layout.Schema().HasField(indexPredicateField) {
+			if definition.predicate, err = tableScan.GetString(indexPredicateField); err != nil {
+				return nil, err
+			}
+		}
+		if definition.unique, err = im.indexUnique(tableScan); err != nil {
+			return nil, err
+		}
+		if definition.indexType, err = im.indexType(tableScan); err != nil {
+			return nil, err
+		}
+		result = append(result, definition)
+	}
+
+	slices.SortFunc(result, func(a, b indexDefinition) int {
+		return strings.Compare(a.indexName, b.indexName)
+	})
+	return result, nil
+}
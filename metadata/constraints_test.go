@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// setupManagerTest creates a metadata manager over a new database.
+func setupManagerTest(t *testing.T) (*Manager, *tx.Transaction, func()) {
+	t.Helper()
+
+	_, txn, cleanup := setupTestMetadata(800, t)
+	m, err := NewManager(true, txn)
+	require.NoError(t, err)
+	return m, txn, cleanup
+}
+
+func TestManager_GetTableConstraints(t *testing.T) {
+	m, txn, cleanup := setupManagerTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("email", 30)
+	schema.AddBoolField("active")
+	require.NoError(t, m.CreateTable("users", schema, txn))
+
+	require.NoError(t, m.CreateIndexWithOptions("users_id", "users", "id", IndexOptions{Unique: true}, txn))
+	require.NoError(t, m.CreateIndexWithOptions("active_email", "users", "email",
+		IndexOptions{Unique: true, Predicate: "active = true"}, txn))
+	require.NoError(t, m.CreateIndex("users_email", "users", "email", txn))
+
+	constraints, err := m.GetTableConstraints("users", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []TableConstraint{
+		{Name: "active_email", Kind: UniqueConstraint, Columns: []string{"email"}, Predicate: "active = true"},
+		{Name: "users_id", Kind: UniqueConstraint, Columns: []string{"id"}},
+	}, constraints)
+
+	constraints, err = m.GetTableConstraints("missing", txn)
+	require.NoError(t, err)
+	assert.Empty(t, constraints)
+}
+
+func TestManager_GenerateDDL(t *testing.T) {
+	m, txn, cleanup := setupManagerTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("email", 30)
+	schema.AddBoolField("active")
+	schema.AddDateField("joined")
+	require.NoError(t, m.CreateTableWithOptions("users", schema, TableOptions{Compressed: true}, txn))
+	require.NoError(t, m.CreateIndexWithOptions("users_id", "users", "id", IndexOptions{Unique: true}, txn))
+	require.NoError(t, m.CreatePartialIndex("active_email", "users", "email", "active = true", txn))
+	require.NoError(t, m.CreateIndexWithOptions("joined_date", "users", "joined",
+		IndexOptions{Type: index.TypeBTree, Predicate: "active = true"}, txn))
+
+	statements, err := m.GenerateDDL("users", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"create table users (id int, email varchar(30), active bool, joined date) with compression",
+		"create index active_email on users (email) where active = true",
+		"create index joined_date on users (joined) using btree where active = true",
+		"create unique index users_id on users (id)",
+	}, statements)
+
+	_, err = m.GenerateDDL("missing", txn)
+	assert.Error(t, err)
+
+	// Long and short fields cannot be declared by CREATE TABLE.
+	schema = record.NewSchema()
+	schema.AddLongField("big")
+	require.NoError(t, m.CreateTable("longs", schema, txn))
+	_, err = m.GenerateDDL("longs", txn)
+	assert.ErrorContains(t, err, "field big has type long")
+}
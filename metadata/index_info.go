@@ -1,29 +1,64 @@
 package metadata
 
 import (
+	"math"
+	"slices"
+	"strings"
+
 	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/index/btree"
 	"github.com/JyotinderSingh/dropdb/index/common"
 	"github.com/JyotinderSingh/dropdb/index/hash"
+	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/types"
 )
 
 type IndexInfo struct {
-	indexName   string
-	fieldName   string
+	indexName string
+	// tableName is the name of the indexed table, which is only known for an index read from the catalog.
+	tableName string
+	// fieldNames are the indexed fields, of which a composite index has several.
+	fieldNames  []string
+	predicate   *query.Predicate
+	unique      bool
+	indexType   string
 	transaction *tx.Transaction
 	tableSchema *record.Schema
 	indexLayout *record.Layout
 	statInfo    *StatInfo
+	statManager *StatManager
+	// indexStats are the statistics of the shape of the index, read by its first cost estimate.
+	indexStats *index.Stats
 }
 
 // NewIndexInfo creates an IndexInfo object for the specified index.
 func NewIndexInfo(indexName, fieldName string, tableSchema *record.Schema,
+	transaction *tx.Transaction, statInfo *StatInfo) *IndexInfo {
+	return NewPartialIndexInfo(indexName, fieldName, nil, tableSchema, transaction, statInfo)
+}
+
+// NewPartialIndexInfo creates an IndexInfo object for an index that only
+// contains the records satisfying the specified predicate.
+// A nil predicate describes an index over the whole table.
+// The statistics should describe only the records in the index.
+func NewPartialIndexInfo(indexName, fieldName string, predicate *query.Predicate, tableSchema *record.Schema,
+	transaction *tx.Transaction, statInfo *StatInfo) *IndexInfo {
+	return NewCompositeIndexInfo(indexName, []string{fieldName}, predicate, tableSchema, transaction, statInfo)
+}
+
+// NewCompositeIndexInfo creates an IndexInfo object for an index on the specified fields,
+// whose search keys are types.CompositeKey values if there are several fields.
+// A nil predicate describes an index over the whole table.
+func NewCompositeIndexInfo(indexName string, fieldNames []string, predicate *query.Predicate, tableSchema *record.Schema,
 	transaction *tx.Transaction, statInfo *StatInfo) *IndexInfo {
 	ii := &IndexInfo{
 		indexName:   indexName,
-		fieldName:   fieldName,
+		fieldNames:  fieldNames,
+		predicate:   predicate,
+		indexType:   index.TypeHash,
 		transaction: transaction,
 		tableSchema: tableSchema,
 		statInfo:    statInfo,
@@ -32,36 +67,214 @@ func NewIndexInfo(indexName, fieldName string, tableSchema *record.Schema,
 	return ii
 }
 
-// Open opens the index described by this object.
-func (ii *IndexInfo) Open() index.Index {
-	return hash.NewIndex(ii.transaction, ii.indexName, ii.indexLayout)
-	//return NewBtreeIndex(ii.transaction, ii.indexName, ii.indexLayout)
+// IndexName returns the name of the index.
+func (ii *IndexInfo) IndexName() string {
+	return ii.indexName
+}
+
+// FieldName returns the name of the indexed field.
+// For a composite index, it returns the names of the indexed fields separated by commas,
+// as they are listed in the CREATE INDEX statement.
+func (ii *IndexInfo) FieldName() string {
+	return strings.Join(ii.fieldNames, ", ")
+}
+
+// FieldNames returns the names of the indexed fields, in the order of the index.
+func (ii *IndexInfo) FieldNames() []string {
+	return ii.fieldNames
+}
+
+// Composite returns true if the index is on several fields.
+func (ii *IndexInfo) Composite() bool {
+	return len(ii.fieldNames) > 1
+}
+
+// Predicate returns the predicate of a partial index,
+// or nil if the index covers the whole table.
+func (ii *IndexInfo) Predicate() *query.Predicate {
+	return ii.predicate
+}
+
+// Unique returns true if no two records in the index may have the same value of the indexed field.
+// The update planners that maintain indexes enforce it.
+func (ii *IndexInfo) Unique() bool {
+	return ii.unique
+}
+
+// IndexType returns the type of the index, index.TypeHash or index.TypeBTree.
+func (ii *IndexInfo) IndexType() string {
+	return ii.indexType
+}
+
+// SupportsRanges returns true if the index can find the records whose search keys are in a range
+// (see index.RangeIndex), which only btree indexes on a single field can.
+func (ii *IndexInfo) SupportsRanges() bool {
+	return ii.indexType == index.TypeBTree && !ii.Composite()
+}
+
+// MarkSuspect records that the index was found to be out of date with its table, if the
+// index was read from the catalog. Otherwise, it does nothing.
+func (ii *IndexInfo) MarkSuspect() {
+	if ii.statManager != nil {
+		ii.statManager.MarkIndexSuspect(ii.indexName)
+	}
+}
+
+// Suspect returns true if the index was found to be out of date with its table, and should be rebuilt.
+func (ii *IndexInfo) Suspect() bool {
+	return ii.statManager != nil && ii.statManager.IsIndexSuspect(ii.indexName)
+}
+
+// Includes returns true if the current record of the specified scan belongs in the index,
+// which is the case unless this is a partial index or an indexed field of the record is null:
+// no search looks for a null key, since comparing null with any value fails.
+func (ii *IndexInfo) Includes(s scan.Scan) (bool, error) {
+	for _, fieldName := range ii.fieldNames {
+		if val, err := s.GetVal(fieldName); err != nil || val == nil {
+			return false, err
+		}
+	}
+	if ii.predicate == nil {
+		return true, nil
+	}
+	return ii.predicate.IsSatisfied(s)
+}
+
+// Key returns the search key of the current record of the specified scan, which is the value of the indexed field,
+// or a types.CompositeKey holding the values of the indexed fields of a composite index.
+func (ii *IndexInfo) Key(s scan.Scan) (any, error) {
+	if !ii.Composite() {
+		return s.GetVal(ii.fieldNames[0])
+	}
+	key := make(types.CompositeKey, len(ii.fieldNames))
+	for i, fieldName := range ii.fieldNames {
+		val, err := s.GetVal(fieldName)
+		if err != nil {
+			return nil, err
+		}
+		key[i] = val
+	}
+	return key, nil
+}
+
+// KeyOf returns the search key of a record having the specified values, like Key.
+// It returns false if one of the indexed fields has no value, or a null value.
+func (ii *IndexInfo) KeyOf(values map[string]any) (any, bool) {
+	key := make(types.CompositeKey, len(ii.fieldNames))
+	for i, fieldName := range ii.fieldNames {
+		if key[i] = values[fieldName]; key[i] == nil {
+			return nil, false
+		}
+	}
+	if !ii.Composite() {
+		return key[0], true
+	}
+	return key, true
+}
+
+// Open opens the index described by this object, as an index of its type.
+// A btree index implements index.RangeIndex.
+func (ii *IndexInfo) Open() (index.Index, error) {
+	if ii.indexType == index.TypeBTree {
+		return btree.NewIndex(ii.transaction, ii.indexName, ii.indexLayout)
+	}
+	return hash.NewIndex(ii.transaction, ii.indexName, ii.indexLayout), nil
 }
 
 // BlocksAccessed estimates the number of block accesses required to
 // find all the index records having a particular search key.
-// The method uses the table's metadata to estimate the size of the
-// index file and the number of index records per block.
-// It then passes this information to the traversalCost method of the
-// appropriate index type, which then provides the estimate.
+// The method reads the statistics the index keeps about its own shape,
+// and passes them to the SearchCost function of the appropriate index type,
+// which then provides the estimate.
+// If the index does not know its number of records, the table's metadata is used instead,
+// and if its statistics cannot be read, the whole index is assumed to be read.
 func (ii *IndexInfo) BlocksAccessed() int {
+	return ii.searchCost(ii.RecordsOutput())
+}
+
+// RangeBlocksAccessed estimates the number of block accesses required to
+// find all the index records whose search keys are in the specified range, like BlocksAccessed.
+func (ii *IndexInfo) RangeBlocksAccessed(keyRange index.Range) int {
+	return ii.searchCost(ii.RangeRecordsOutput(keyRange))
+}
+
+// searchCost estimates the number of block accesses required to find the specified number of index records.
+func (ii *IndexInfo) searchCost(matchingRecords int) int {
 	recordsPerBlock := ii.transaction.BlockSize() / ii.indexLayout.SlotSize()
-	numBlocks := ii.statInfo.RecordsOutput() / recordsPerBlock
-	return hash.SearchCost(numBlocks, recordsPerBlock)
-	//return BtreeIndex.SearchCost(numBlocks, recordsPerBlock)
+
+	cached, err := ii.getIndexStats()
+	if err != nil {
+		return ii.statInfo.RecordsOutput() / recordsPerBlock
+	}
+	// the cached statistics are shared, so the number of records is filled in on a copy.
+	stats := *cached
+	if stats.Records < 0 {
+		stats.Records = ii.statInfo.RecordsOutput()
+	}
+	if ii.indexType == index.TypeBTree {
+		return btree.SearchCost(&stats, matchingRecords, recordsPerBlock)
+	}
+	return hash.SearchCost(&stats, matchingRecords, recordsPerBlock)
+}
+
+// getIndexStats returns the statistics the index keeps about its own shape, which are read once for each IndexInfo,
+// so that the cost of every plan reading the index does not open it again. An index read from the catalog
+// has them cached by the stat manager too, for the IndexInfo objects of the following queries.
+func (ii *IndexInfo) getIndexStats() (*index.Stats, error) {
+	if ii.indexStats != nil {
+		return ii.indexStats, nil
+	}
+	var stats *index.Stats
+	var err error
+	if ii.statManager != nil {
+		stats, err = ii.statManager.GetIndexStats(ii.tableName, ii.indexName, ii.readIndexStats)
+	} else {
+		stats, err = ii.readIndexStats()
+	}
+	if err != nil {
+		return nil, err
+	}
+	ii.indexStats = stats
+	return stats, nil
+}
+
+// readIndexStats opens the index to read the statistics it keeps about its own shape.
+func (ii *IndexInfo) readIndexStats() (*index.Stats, error) {
+	idx, err := ii.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer idx.Close()
+	return idx.Stats()
 }
 
 // RecordsOutput returns the estimated number of records having a search key.
 // This value is the same as doing a select query; that is, it is the number of records in the table
 // divided by the number of distinct values of the indexed field.
+// The fields of a composite index are assumed to be independent, so the number of distinct keys
+// is the product of the numbers of distinct values of the fields, up to the number of records.
+// An index with no records (and so no distinct values) outputs no records.
 func (ii *IndexInfo) RecordsOutput() int {
-	return ii.statInfo.RecordsOutput() / ii.statInfo.DistinctValues(ii.fieldName)
+	distinctValues := 1
+	for _, fieldName := range ii.fieldNames {
+		distinctValues = min(distinctValues*ii.statInfo.DistinctValues(fieldName), max(ii.statInfo.RecordsOutput(), 1))
+	}
+	if distinctValues == 0 {
+		return 0
+	}
+	return ii.statInfo.RecordsOutput() / distinctValues
+}
+
+// RangeRecordsOutput returns the estimated number of records whose search keys are in the specified range,
+// which is the fraction of the records of the table that the statistics place in the range.
+func (ii *IndexInfo) RangeRecordsOutput(keyRange index.Range) int {
+	return int(math.Ceil(float64(ii.statInfo.RecordsOutput()) * ii.statInfo.RangeFraction(ii.fieldNames[0], keyRange)))
 }
 
 // DistinctValues returns the number of distinct values for the indexed field
-// in the underlying table, or 1 for the indexed field.
+// in the underlying table, or 1 for an indexed field.
 func (ii *IndexInfo) DistinctValues(fieldName string) int {
-	if ii.fieldName == fieldName {
+	if slices.Contains(ii.fieldNames, fieldName) {
 		return 1
 	}
 	return ii.statInfo.DistinctValues(fieldName)
@@ -70,24 +283,30 @@ func (ii *IndexInfo) DistinctValues(fieldName string) int {
 // CreateIndexLayout returns the layout of the index records.
 // The schema consists of the dataRecordID (which is represented as two integers,
 // the block number and the record ID) and the dataValue (which is the indexed field).
-// Schema information about the indexed field is obtained from the table's schema.
+// A composite index has a field for the value of each indexed field (see common.KeyField).
+// Schema information about the indexed fields is obtained from the table's schema.
 func (ii *IndexInfo) CreateIndexLayout() *record.Layout {
 	schema := record.NewSchema()
 	schema.AddIntField(common.BlockField)
 	schema.AddIntField(common.IDField)
-	switch ii.tableSchema.Type(ii.fieldName) {
-	case types.Integer:
-		schema.AddIntField(common.DataValueField)
-	case types.Varchar:
-		schema.AddStringField(common.DataValueField, ii.tableSchema.Length(ii.fieldName))
-	case types.Boolean:
-		schema.AddBoolField(common.DataValueField)
-	case types.Long:
-		schema.AddLongField(common.DataValueField)
-	case types.Short:
-		schema.AddShortField(common.DataValueField)
-	case types.Date:
-		schema.AddDateField(common.DataValueField)
+	for i, fieldName := range ii.fieldNames {
+		keyField := common.KeyField(i)
+		switch ii.tableSchema.Type(fieldName) {
+		case types.Integer:
+			schema.AddIntField(keyField)
+		case types.Varchar:
+			schema.AddStringField(keyField, ii.tableSchema.Length(fieldName))
+		case types.Boolean:
+			schema.AddBoolField(keyField)
+		case types.Long:
+			schema.AddLongField(keyField)
+		case types.Short:
+			schema.AddShortField(keyField)
+		case types.Float:
+			schema.AddFloatField(keyField)
+		case types.Date:
+			schema.AddDateField(keyField)
+		}
 	}
 
 	return record.NewLayout(schema)
@@ -1,6 +1,7 @@
 package metadata
 
 import (
+	"fmt"
 	"github.com/JyotinderSingh/dropdb/buffer"
 	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/index/common"
@@ -65,10 +66,11 @@ func TestIndexInfo_InsertAndValidate(t *testing.T) {
 	indexInfo, _, cleanup := setupIndexInfoTest(t)
 	defer cleanup()
 
-	idx := indexInfo.Open()
+	idx, err := indexInfo.Open()
+	require.NoError(t, err)
 
 	// Insert records into the index
-	err := idx.Insert("key1", record.NewID(1, 1))
+	err = idx.Insert("key1", record.NewID(1, 1))
 	require.NoError(t, err)
 	err = idx.Insert("key2", record.NewID(2, 2))
 	require.NoError(t, err)
@@ -85,10 +87,11 @@ func TestIndexInfo_DeleteAndValidate(t *testing.T) {
 	indexInfo, _, cleanup := setupIndexInfoTest(t)
 	defer cleanup()
 
-	idx := indexInfo.Open()
+	idx, err := indexInfo.Open()
+	require.NoError(t, err)
 
 	// Insert and delete a record
-	err := idx.Insert("key1", record.NewID(1, 1))
+	err = idx.Insert("key1", record.NewID(1, 1))
 	require.NoError(t, err)
 	err = idx.Delete("key1", record.NewID(1, 1))
 	require.NoError(t, err)
@@ -111,3 +114,57 @@ func TestIndexInfo_CreateIndexLayout(t *testing.T) {
 	assert.True(t, schema.HasField(common.DataValueField))
 	assert.Equal(t, types.Varchar, schema.Type(common.DataValueField))
 }
+
+func TestIndexInfo_BlocksAccessedUsesIndexStats(t *testing.T) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lockTable := concurrency.NewLockTable()
+
+	tableSchema := record.NewSchema()
+	tableSchema.AddIntField("id")
+	tableSchema.AddStringField("data_value", 20)
+	statInfo := NewStatInfo(10, 100, map[string]int{"id": 100, "data_value": 20})
+	transaction := tx.NewTransaction(fm, lm, bm, lockTable)
+	indexInfo := NewIndexInfo("test_index", "data_value", tableSchema, transaction, statInfo)
+	recordsPerBlock := transaction.BlockSize() / indexInfo.CreateIndexLayout().SlotSize()
+
+	// Before anything is inserted, the index is assumed to hold the 100 records of the table,
+	// so the bucket of a key holds its 5 matching records.
+	emptyCost := (5 + recordsPerBlock - 1) / recordsPerBlock
+	assert.Equal(t, emptyCost, indexInfo.BlocksAccessed())
+	cached := indexInfo.indexStats
+	require.NotNil(t, cached)
+
+	// The index actually holds many more records than the table statistics claim, so many that its 100 buckets
+	// are split.
+	idx, err := indexInfo.Open()
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, idx.Insert(fmt.Sprintf("key%d", i), record.NewID(i, 0)))
+	}
+	idx.Close()
+	require.NoError(t, transaction.Commit())
+
+	// The statistics of the index are read once for each IndexInfo.
+	assert.Equal(t, emptyCost, indexInfo.BlocksAccessed())
+	assert.Same(t, cached, indexInfo.indexStats)
+	assert.Equal(t, 100, cached.Buckets)
+
+	// Those read by another IndexInfo spread the records over the current buckets,
+	// the bucket of a key still holding its 5 records.
+	transaction = tx.NewTransaction(fm, lm, bm, lockTable)
+	defer func() { require.NoError(t, transaction.Commit()) }()
+	indexInfo = NewIndexInfo("test_index", "data_value", tableSchema, transaction, statInfo)
+	idx, err = indexInfo.Open()
+	require.NoError(t, err)
+	stats, err := idx.Stats()
+	idx.Close()
+	require.NoError(t, err)
+	require.Equal(t, 1000, stats.Records)
+	require.Greater(t, stats.Buckets, 100)
+	bucketRecords := max((1000+stats.Buckets-1)/stats.Buckets, 5)
+	assert.Equal(t, (bucketRecords+recordsPerBlock-1)/recordsPerBlock, indexInfo.BlocksAccessed())
+}
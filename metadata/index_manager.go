@@ -2,19 +2,48 @@ package metadata
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/index/btree"
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
 )
 
 const (
-	indexCatalogTable = "index_catalog"
-	indexNameField    = "index_name"
+	indexCatalogTable       = "index_catalog"
+	indexNameField          = "index_name"
+	indexPredicateField     = "index_predicate"
+	indexUniqueField        = "index_unique"
+	indexTypeField          = "index_type"
+	maxIndexPredicateLength = 100
+	maxIndexTypeLength      = 10
+
+	// indexFieldCatalogTable holds the fields of the composite indexes after their first field,
+	// which the index catalog records, along with their position in the index.
+	indexFieldCatalogTable = "index_field_catalog"
+	fieldPositionField     = "field_position"
 )
 
+// IndexOptions holds the attributes of an index, which are recorded in the index catalog.
+type IndexOptions struct {
+	// Predicate is the text of the predicate of a partial index, or empty if the index covers the whole table.
+	Predicate string
+	// Unique is true if no two records in the index may have the same value of the indexed field.
+	Unique bool
+	// Type is the type of the index, index.TypeHash or index.TypeBTree. An empty type is index.TypeHash.
+	Type string
+}
+
 // IndexManager is responsible for managing indexes in the database.
 type IndexManager struct {
-	layout       *record.Layout
+	layout *record.Layout
+	// fieldLayout is the layout of the index field catalog, or nil if the catalog predates composite indexes.
+	fieldLayout  *record.Layout
 	tableManager *TableManager
 	StatManager  *StatManager
 }
@@ -28,8 +57,19 @@ func NewIndexManager(isNew bool, tableManager *TableManager, statManager *StatMa
 		schema.AddStringField(indexNameField, maxNameLength)
 		schema.AddStringField(tableNameField, maxNameLength)
 		schema.AddStringField(fieldNameField, maxNameLength)
+		schema.AddStringField(indexPredicateField, maxIndexPredicateLength)
+		schema.AddBoolField(indexUniqueField)
+		schema.AddStringField(indexTypeField, maxIndexTypeLength)
 
-		if err := tableManager.CreateTable(indexCatalogTable, schema, transaction); err != nil {
+		if err := tableManager.createTable(indexCatalogTable, schema, TableOptions{}, transaction); err != nil {
+			return nil, err
+		}
+
+		fieldSchema := record.NewSchema()
+		fieldSchema.AddStringField(indexNameField, maxNameLength)
+		fieldSchema.AddStringField(fieldNameField, maxNameLength)
+		fieldSchema.AddIntField(fieldPositionField)
+		if err := tableManager.createTable(indexFieldCatalogTable, fieldSchema, TableOptions{}, transaction); err != nil {
 			return nil, err
 		}
 	}
@@ -39,8 +79,21 @@ func NewIndexManager(isNew bool, tableManager *TableManager, statManager *StatMa
 		return nil, err
 	}
 
+	// Catalogs that predate composite indexes have no index field catalog.
+	var fieldLayout *record.Layout
+	tableNames, err := tableManager.TableNames(transaction)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(tableNames, indexFieldCatalogTable) {
+		if fieldLayout, err = tableManager.GetLayout(indexFieldCatalogTable, transaction); err != nil {
+			return nil, err
+		}
+	}
+
 	return &IndexManager{
 		layout:       layout,
+		fieldLayout:  fieldLayout,
 		tableManager: tableManager,
 		StatManager:  statManager,
 	}, nil
@@ -49,6 +102,70 @@ func NewIndexManager(isNew bool, tableManager *TableManager, statManager *StatMa
 // CreateIndex creates a new index of the specified type for the specified field.
 // A unique ID is assigned to this index, and its information is stored in the indexCatalogTable.
 func (im *IndexManager) CreateIndex(indexName, tableName, fieldName string, transaction *tx.Transaction) error {
+	return im.CreatePartialIndex(indexName, tableName, fieldName, "", transaction)
+}
+
+// CreatePartialIndex creates a new index for the specified field that only contains
+// the records satisfying the predicate, whose text is stored in the indexCatalogTable.
+// An empty predicate creates an index over the whole table.
+func (im *IndexManager) CreatePartialIndex(indexName, tableName, fieldName, predicate string, transaction *tx.Transaction) error {
+	return im.CreateIndexWithOptions(indexName, tableName, fieldName, IndexOptions{Predicate: predicate}, transaction)
+}
+
+// CreateIndexWithOptions creates a new index for the specified field, with the specified attributes,
+// which are stored in the indexCatalogTable.
+// It returns an error wrapping ErrInvalidName if the name of the index is invalid.
+func (im *IndexManager) CreateIndexWithOptions(indexName, tableName, fieldName string, options IndexOptions, transaction *tx.Transaction) error {
+	return im.CreateCompositeIndex(indexName, tableName, []string{fieldName}, options, transaction)
+}
+
+// CreateCompositeIndex creates a new index on the specified fields, with the specified attributes, like CreateIndexWithOptions.
+// The search key of an index on several fields holds the value of each field, and the keys are ordered field by field.
+// The first field is recorded in the index catalog, and the other fields in the index field catalog.
+func (im *IndexManager) CreateCompositeIndex(indexName, tableName string, fieldNames []string, options IndexOptions, transaction *tx.Transaction) error {
+	if err := checkFileName("index", indexName); err != nil {
+		return err
+	}
+	if len(fieldNames) == 0 {
+		return fmt.Errorf("index %s has no fields", indexName)
+	}
+	for i, fieldName := range fieldNames {
+		if slices.Contains(fieldNames[:i], fieldName) {
+			return fmt.Errorf("field %s appears twice in index %s", fieldName, indexName)
+		}
+	}
+	if len(fieldNames) > 1 && im.fieldLayout == nil {
+		return fmt.Errorf("index catalog does not support composite indexes")
+	}
+	predicate := options.Predicate
+	if options.Unique && !im.layout.Schema().HasField(indexUniqueField) {
+		return fmt.Errorf("index catalog does not support unique indexes")
+	}
+	switch options.Type {
+	case "", index.TypeHash:
+	case index.TypeBTree:
+		if !im.layout.Schema().HasField(indexTypeField) {
+			return fmt.Errorf("index catalog does not support btree indexes")
+		}
+	default:
+		return fmt.Errorf("unknown type %s of index %s; the types are %s and %s", options.Type, indexName, index.TypeHash, index.TypeBTree)
+	}
+	if predicate != "" {
+		if !im.layout.Schema().HasField(indexPredicateField) {
+			return fmt.Errorf("index catalog does not support partial indexes")
+		}
+		if len(predicate) > maxIndexPredicateLength {
+			return fmt.Errorf("predicate of index %s exceeds %d characters", indexName, maxIndexPredicateLength)
+		}
+		if _, err := parse.NewParser(predicate).Predicate(); err != nil {
+			return fmt.Errorf("invalid predicate for index %s: %w", indexName, err)
+		}
+	}
+
+	if err := im.checkIndexFitsBlock(indexName, tableName, fieldNames, transaction); err != nil {
+		return err
+	}
+
 	tableScan, err := table.NewTableScan(transaction, indexCatalogTable, im.layout)
 	if err != nil {
 		return fmt.Errorf("failed to create table scan: %w", err)
@@ -67,22 +184,132 @@ func (im *IndexManager) CreateIndex(indexName, tableName, fieldName string, tran
 		return fmt.Errorf("failed to set string: %w", err)
 	}
 
-	if err := tableScan.SetString(fieldNameField, fieldName); err != nil {
+	if err := tableScan.SetString(fieldNameField, fieldNames[0]); err != nil {
 		return fmt.Errorf("failed to set string: %w", err)
 	}
 
+	if predicate != "" {
+		if err := tableScan.SetString(indexPredicateField, predicate); err != nil {
+			return fmt.Errorf("failed to set string: %w", err)
+		}
+	}
+
+	if options.Unique {
+		if err := tableScan.SetBool(indexUniqueField, true); err != nil {
+			return fmt.Errorf("failed to set bool: %w", err)
+		}
+	}
+
+	if options.Type == index.TypeBTree {
+		if err := tableScan.SetString(indexTypeField, options.Type); err != nil {
+			return fmt.Errorf("failed to set string: %w", err)
+		}
+	}
+
+	if len(fieldNames) > 1 {
+		if err := im.insertIndexFields(indexName, fieldNames, transaction); err != nil {
+			return fmt.Errorf("failed to record fields of index %s: %w", indexName, err)
+		}
+	}
+	return nil
+}
+
+// DropIndex removes the specified index from the index catalog, and returns its information,
+// whose statistics are all zero, so that its files can be deleted (see index.Index#Drop).
+// The table of the index is locked (see table.LockTable) until the transaction completes,
+// so that no other transaction modifies the table while its index is dropped.
+func (im *IndexManager) DropIndex(indexName string, transaction *tx.Transaction) (*IndexInfo, error) {
+	tableScan, err := table.NewTableScan(transaction, indexCatalogTable, im.layout)
+	if err != nil {
+		return nil, err
+	}
+	defer tableScan.Close()
+
+	for {
+		hasNext, err := tableScan.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			return nil, fmt.Errorf("index %s not found", indexName)
+		}
+		currentIndexName, err := tableScan.GetString(indexNameField)
+		if err != nil {
+			return nil, err
+		}
+		if currentIndexName == indexName {
+			break
+		}
+	}
+
+	tableName, err := tableScan.GetString(tableNameField)
+	if err != nil {
+		return nil, err
+	}
+	fieldNames, err := im.indexFields(tableScan, transaction)
+	if err != nil {
+		return nil, err
+	}
+	indexType, err := im.indexType(tableScan)
+	if err != nil {
+		return nil, err
+	}
+	tableLayout, err := im.tableManager.GetLayout(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.LockTable(transaction, tableName); err != nil {
+		return nil, err
+	}
+	if err := tableScan.Delete(); err != nil {
+		return nil, fmt.Errorf("failed to delete from index catalog: %w", err)
+	}
+	if err := im.deleteIndexFields(indexName, transaction); err != nil {
+		return nil, fmt.Errorf("failed to delete from index field catalog: %w", err)
+	}
+	indexInfo := NewCompositeIndexInfo(indexName, fieldNames, nil, tableLayout.Schema(), transaction, NewStatInfo(0, 0, nil))
+	indexInfo.indexType = indexType
+	return indexInfo, nil
+}
+
+// checkIndexFitsBlock returns an error if the records of the index are too large for a block,
+// so that a bad index is rejected when it is created rather than when a page first needs to be split.
+// The size of the indexed values is derived from the declared type, and length, of the indexed field.
+func (im *IndexManager) checkIndexFitsBlock(indexName, tableName string, fieldNames []string, transaction *tx.Transaction) error {
+	tableLayout, err := im.tableManager.GetLayout(tableName, transaction)
+	if err != nil {
+		return err
+	}
+	indexLayout := NewCompositeIndexInfo(indexName, fieldNames, nil, tableLayout.Schema(), transaction, nil).CreateIndexLayout()
+	if minBlockSize := btree.MinBlockSize(indexLayout); minBlockSize > transaction.BlockSize() {
+		return fmt.Errorf("index %s on %s.%s needs a block size of at least %d bytes, but the block size is %d; use a larger block size or index a shorter field",
+			indexName, tableName, strings.Join(fieldNames, ", "), minBlockSize, transaction.BlockSize())
+	}
 	return nil
 }
 
-// GetIndexInfo returns a map containing the index info for all indexes on the specified table.
-func (im *IndexManager) GetIndexInfo(tableName string, transaction *tx.Transaction) (map[string]*IndexInfo, error) {
+// GetIndexInfo returns the index info for all indexes on the specified table, ordered by index name,
+// so that the result does not depend on the order in which the indexes were created.
+func (im *IndexManager) GetIndexInfo(tableName string, transaction *tx.Transaction) ([]*IndexInfo, error) {
+	return im.getIndexInfo(tableName, true, transaction)
+}
+
+// GetIndexInfoWithoutStats returns the index info for all indexes on the specified table like GetIndexInfo,
+// without reading the records of the table to calculate its statistics, so that it works on tables whose
+// records cannot be read. The cost estimates of the returned index info are all zero.
+func (im *IndexManager) GetIndexInfoWithoutStats(tableName string, transaction *tx.Transaction) ([]*IndexInfo, error) {
+	return im.getIndexInfo(tableName, false, transaction)
+}
+
+// getIndexInfo returns the index info for all indexes on the specified table, with the statistics of the table if withStats is true.
+func (im *IndexManager) getIndexInfo(tableName string, withStats bool, transaction *tx.Transaction) ([]*IndexInfo, error) {
 	tableScan, err := table.NewTableScan(transaction, indexCatalogTable, im.layout)
 	if err != nil {
 		return nil, err
 	}
 	defer tableScan.Close()
 
-	result := make(map[string]*IndexInfo)
+	var result []*IndexInfo
 
 	for {
 		hasNext, err := tableScan.Next()
@@ -101,12 +328,12 @@ func (im *IndexManager) GetIndexInfo(tableName string, transaction *tx.Transacti
 			continue
 		}
 
-		var indexName, fieldName string
-
-		if indexName, err = tableScan.GetString(indexNameField); err != nil {
+		indexName, err := tableScan.GetString(indexNameField)
+		if err != nil {
 			return nil, err
 		}
-		if fieldName, err = tableScan.GetString(fieldNameField); err != nil {
+		fieldNames, err := im.indexFields(tableScan, transaction)
+		if err != nil {
 			return nil, err
 		}
 
@@ -115,14 +342,178 @@ func (im *IndexManager) GetIndexInfo(tableName string, transaction *tx.Transacti
 			return nil, err
 		}
 
-		tableStatInfo, err := im.StatManager.GetStatInfo(tableName, tableLayout, transaction)
+		predicate, err := im.indexPredicate(tableScan, tableLayout.Schema())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read predicate of index %s: %w", indexName, err)
+		}
+
+		statInfo := NewStatInfo(0, 0, nil)
+		switch {
+		case withStats && predicate == nil:
+			statInfo, err = im.StatManager.GetStatInfo(tableName, tableLayout, transaction)
+		case withStats:
+			statInfo, err = im.StatManager.GetPartialStatInfo(tableName, tableLayout, predicate, transaction)
+		}
 		if err != nil {
 			return nil, err
 		}
 
-		indexInfo := NewIndexInfo(indexName, fieldName, tableLayout.Schema(), transaction, tableStatInfo)
-		result[fieldName] = indexInfo
+		indexInfo := NewCompositeIndexInfo(indexName, fieldNames, predicate, tableLayout.Schema(), transaction, statInfo)
+		indexInfo.tableName = tableName
+		indexInfo.statManager = im.StatManager
+		if indexInfo.unique, err = im.indexUnique(tableScan); err != nil {
+			return nil, fmt.Errorf("failed to read uniqueness of index %s: %w", indexName, err)
+		}
+		if indexInfo.indexType, err = im.indexType(tableScan); err != nil {
+			return nil, fmt.Errorf("failed to read type of index %s: %w", indexName, err)
+		}
+		result = append(result, indexInfo)
 	}
 
+	slices.SortFunc(result, func(a, b *IndexInfo) int {
+		return strings.Compare(a.IndexName(), b.IndexName())
+	})
 	return result, nil
 }
+
+// indexPredicate parses the predicate of the index catalog record the scan is positioned at,
+// converting its constants to the types of the table's fields. It returns nil if the index covers the whole table, or if the catalog
+// predates partial indexes and has no predicate field.
+func (im *IndexManager) indexPredicate(tableScan *table.Scan, tableSchema *record.Schema) (*query.Predicate, error) {
+	if !im.layout.Schema().HasField(indexPredicateField) {
+		return nil, nil
+	}
+	predicateText, err := tableScan.GetString(indexPredicateField)
+	if err != nil || predicateText == "" {
+		return nil, err
+	}
+	predicate, err := parse.NewParser(predicateText).Predicate()
+	if err != nil {
+		return nil, err
+	}
+	predicate.CoerceConstants(tableSchema)
+	return predicate, nil
+}
+
+// indexUnique returns true if the index catalog record the scan is positioned at describes a unique index.
+// Catalogs that predate unique indexes have no uniqueness field, and their indexes are not unique.
+func (im *IndexManager) indexUnique(tableScan *table.Scan) (bool, error) {
+	if !im.layout.Schema().HasField(indexUniqueField) {
+		return false, nil
+	}
+	return tableScan.GetBool(indexUniqueField)
+}
+
+// indexType returns the type of the index described by the index catalog record the scan is positioned at.
+// Catalogs that predate btree indexes have no type field, and their indexes are hash indexes.
+func (im *IndexManager) indexType(tableScan *table.Scan) (string, error) {
+	if !im.layout.Schema().HasField(indexTypeField) {
+		return index.TypeHash, nil
+	}
+	indexType, err := tableScan.GetString(indexTypeField)
+	if err != nil || indexType == "" {
+		return index.TypeHash, err
+	}
+	return indexType, nil
+}
+
+// indexFields returns the indexed fields of the index described by the index catalog record the scan is positioned at.
+// The index catalog records the first field, and the index field catalog the other fields of a composite index.
+func (im *IndexManager) indexFields(tableScan *table.Scan, transaction *tx.Transaction) ([]string, error) {
+	fieldName, err := tableScan.GetString(fieldNameField)
+	if err != nil {
+		return nil, err
+	}
+	fieldNames := []string{fieldName}
+	if im.fieldLayout == nil {
+		return fieldNames, nil
+	}
+	indexName, err := tableScan.GetString(indexNameField)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldScan, err := table.NewTableScan(transaction, indexFieldCatalogTable, im.fieldLayout)
+	if err != nil {
+		return nil, err
+	}
+	defer fieldScan.Close()
+	for {
+		hasNext, err := fieldScan.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			return fieldNames, nil
+		}
+		currentIndexName, err := fieldScan.GetString(indexNameField)
+		if err != nil {
+			return nil, err
+		}
+		if currentIndexName != indexName {
+			continue
+		}
+		position, err := fieldScan.GetInt(fieldPositionField)
+		if err != nil {
+			return nil, err
+		}
+		if fieldName, err = fieldScan.GetString(fieldNameField); err != nil {
+			return nil, err
+		}
+		if position >= len(fieldNames) {
+			fieldNames = append(fieldNames, make([]string, position+1-len(fieldNames))...)
+		}
+		fieldNames[position] = fieldName
+	}
+}
+
+// insertIndexFields records the fields of the specified composite index after its first field in the index field catalog.
+func (im *IndexManager) insertIndexFields(indexName string, fieldNames []string, transaction *tx.Transaction) error {
+	fieldScan, err := table.NewTableScan(transaction, indexFieldCatalogTable, im.fieldLayout)
+	if err != nil {
+		return err
+	}
+	defer fieldScan.Close()
+	for position := 1; position < len(fieldNames); position++ {
+		if err := fieldScan.Insert(); err != nil {
+			return err
+		}
+		if err := fieldScan.SetString(indexNameField, indexName); err != nil {
+			return err
+		}
+		if err := fieldScan.SetString(fieldNameField, fieldNames[position]); err != nil {
+			return err
+		}
+		if err := fieldScan.SetInt(fieldPositionField, position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteIndexFields removes the fields of the specified index from the index field catalog, if it has any.
+func (im *IndexManager) deleteIndexFields(indexName string, transaction *tx.Transaction) error {
+	if im.fieldLayout == nil {
+		return nil
+	}
+	fieldScan, err := table.NewTableScan(transaction, indexFieldCatalogTable, im.fieldLayout)
+	if err != nil {
+		return err
+	}
+	defer fieldScan.Close()
+	for {
+		hasNext, err := fieldScan.Next()
+		if err != nil || !hasNext {
+			return err
+		}
+		currentIndexName, err := fieldScan.GetString(indexNameField)
+		if err != nil {
+			return err
+		}
+		if currentIndexName == indexName {
+			if err := fieldScan.Delete(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package metadata
 
 import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/index/btree"
+	"github.com/JyotinderSingh/dropdb/index/common"
 	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/types"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/tx"
@@ -13,7 +20,7 @@ import (
 func setupIndexManagerTest(t *testing.T) (*TableManager, *IndexManager, *tx.Transaction, func()) {
 	t.Helper()
 
-	tm, txn, cleanup := setupTestMetadata(400, t)
+	tm, txn, cleanup := setupTestMetadata(800, t)
 	sm, err := NewStatManager(tm, txn, 100)
 	require.NoError(t, err)
 	indexManager, err := NewIndexManager(true, tm, sm, txn)
@@ -92,14 +99,15 @@ func TestIndexManager_GetIndexInfo(t *testing.T) {
 	// Retrieve index info
 	indexInfos, err := indexManager.GetIndexInfo("test_table", txn)
 	require.NoError(t, err)
-	assert.Contains(t, indexInfos, "id", "IndexInfo for 'id' field not found")
+	require.Len(t, indexInfos, 1, "IndexInfo for 'id' field not found")
 
-	indexInfo := indexInfos["id"]
+	indexInfo := indexInfos[0]
 	assert.Equal(t, "test_index", indexInfo.indexName)
-	assert.Equal(t, "id", indexInfo.fieldName)
+	assert.Equal(t, []string{"id"}, indexInfo.fieldNames)
 
 	// Open the index and perform operations
-	idx := indexInfo.Open()
+	idx, err := indexInfo.Open()
+	require.NoError(t, err)
 	err = idx.Insert(1234, record.NewID(1, 1))
 	require.NoError(t, err)
 	err = idx.BeforeFirst(1234)
@@ -108,3 +116,269 @@ func TestIndexManager_GetIndexInfo(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, hasNext)
 }
+
+func TestIndexManager_PartialIndex(t *testing.T) {
+	tm, indexManager, txn, cleanup := setupIndexManagerTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddBoolField("deleted")
+	require.NoError(t, tm.CreateTable("test_table", schema, txn))
+
+	layout, err := tm.GetLayout("test_table", txn)
+	require.NoError(t, err)
+	ts, err := table.NewTableScan(txn, "test_table", layout)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+		require.NoError(t, ts.SetBool("deleted", i%5 == 0))
+	}
+	ts.Close()
+
+	err = indexManager.CreatePartialIndex("live_index", "test_table", "id", "deleted = false", txn)
+	require.NoError(t, err)
+
+	indexInfos, err := indexManager.GetIndexInfo("test_table", txn)
+	require.NoError(t, err)
+	require.Len(t, indexInfos, 1)
+
+	indexInfo := indexInfos[0]
+	require.NotNil(t, indexInfo.Predicate())
+	assert.Equal(t, "deleted = false", indexInfo.Predicate().String())
+
+	// Statistics only count the 8 records that are not deleted.
+	assert.Equal(t, 8, indexInfo.statInfo.RecordsOutput())
+	assert.Equal(t, 8, indexInfo.statInfo.DistinctValues("id"))
+	assert.Equal(t, 1, indexInfo.RecordsOutput())
+}
+
+func TestIndexManager_PartialIndexInvalidPredicate(t *testing.T) {
+	tm, indexManager, txn, cleanup := setupIndexManagerTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	require.NoError(t, tm.CreateTable("test_table", schema, txn))
+
+	err := indexManager.CreatePartialIndex("bad_index", "test_table", "id", "id = ", txn)
+	assert.ErrorContains(t, err, "invalid predicate")
+
+	indexInfos, err := indexManager.GetIndexInfo("test_table", txn)
+	require.NoError(t, err)
+	assert.Empty(t, indexInfos)
+}
+
+func TestIndexManager_UniqueIndex(t *testing.T) {
+	tm, indexManager, txn, cleanup := setupIndexManagerTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddBoolField("deleted")
+	require.NoError(t, tm.CreateTable("test_table", schema, txn))
+
+	require.NoError(t, indexManager.CreateIndex("plain_index", "test_table", "id", txn))
+	options := IndexOptions{Predicate: "deleted = false", Unique: true}
+	require.NoError(t, indexManager.CreateIndexWithOptions("unique_index", "test_table", "id", options, txn))
+
+	indexInfos, err := indexManager.GetIndexInfo("test_table", txn)
+	require.NoError(t, err)
+	require.Len(t, indexInfos, 2)
+	assert.Equal(t, "plain_index", indexInfos[0].IndexName())
+	assert.False(t, indexInfos[0].Unique())
+	assert.Nil(t, indexInfos[0].Predicate())
+	assert.Equal(t, "unique_index", indexInfos[1].IndexName())
+	assert.True(t, indexInfos[1].Unique())
+	require.NotNil(t, indexInfos[1].Predicate())
+	assert.Equal(t, "deleted = false", indexInfos[1].Predicate().String())
+}
+
+func TestIndexManager_IndexType(t *testing.T) {
+	tm, indexManager, txn, cleanup := setupIndexManagerTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	require.NoError(t, tm.CreateTable("test_table", schema, txn))
+
+	require.NoError(t, indexManager.CreateIndex("hash_index", "test_table", "id", txn))
+	require.NoError(t, indexManager.CreateIndexWithOptions("btree_index", "test_table", "id", IndexOptions{Type: index.TypeBTree}, txn))
+	err := indexManager.CreateIndexWithOptions("bad_index", "test_table", "id", IndexOptions{Type: "bitmap"}, txn)
+	assert.ErrorContains(t, err, "unknown type bitmap")
+
+	indexInfos, err := indexManager.GetIndexInfo("test_table", txn)
+	require.NoError(t, err)
+	require.Len(t, indexInfos, 2)
+	assert.Equal(t, "btree_index", indexInfos[0].IndexName())
+	assert.Equal(t, index.TypeBTree, indexInfos[0].IndexType())
+	assert.True(t, indexInfos[0].SupportsRanges())
+	assert.Equal(t, index.TypeHash, indexInfos[1].IndexType())
+	assert.False(t, indexInfos[1].SupportsRanges())
+
+	// Only the btree index can find the search keys in a range.
+	for _, indexInfo := range indexInfos {
+		idx, err := indexInfo.Open()
+		require.NoError(t, err)
+		_, isRangeIndex := idx.(index.RangeIndex)
+		assert.Equal(t, indexInfo.SupportsRanges(), isRangeIndex)
+		idx.Close()
+	}
+
+	dropped, err := indexManager.DropIndex("btree_index", txn)
+	require.NoError(t, err)
+	assert.Equal(t, index.TypeBTree, dropped.IndexType())
+}
+
+func TestIndexManager_CompositeIndex(t *testing.T) {
+	tm, indexManager, txn, cleanup := setupIndexManagerTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("dept_id")
+	schema.AddDateField("hire_date")
+	schema.AddStringField("name", 20)
+	require.NoError(t, tm.CreateTable("emp", schema, txn))
+
+	fields := []string{"dept_id", "hire_date", "name"}
+	require.NoError(t, indexManager.CreateCompositeIndex("emp_dept_hired", "emp", fields, IndexOptions{Type: index.TypeBTree}, txn))
+	require.NoError(t, indexManager.CreateIndex("emp_name", "emp", "name", txn))
+	err := indexManager.CreateCompositeIndex("emp_twice", "emp", []string{"dept_id", "dept_id"}, IndexOptions{}, txn)
+	assert.ErrorContains(t, err, "field dept_id appears twice in index emp_twice")
+
+	indexInfos, err := indexManager.GetIndexInfo("emp", txn)
+	require.NoError(t, err)
+	require.Len(t, indexInfos, 2)
+	composite := indexInfos[0]
+	assert.Equal(t, fields, composite.FieldNames())
+	assert.Equal(t, "dept_id, hire_date, name", composite.FieldName())
+	assert.True(t, composite.Composite())
+	assert.False(t, composite.SupportsRanges())
+	assert.Equal(t, 1, composite.DistinctValues("hire_date"))
+	assert.Equal(t, []string{"name"}, indexInfos[1].FieldNames())
+	assert.False(t, indexInfos[1].Composite())
+
+	// The records of the index hold a value of each indexed field.
+	layoutSchema := composite.CreateIndexLayout().Schema()
+	assert.Equal(t, []string{common.KeyField(0), common.KeyField(1), common.KeyField(2)}, common.KeyFields(layoutSchema))
+	assert.Equal(t, types.Date, layoutSchema.Type(common.KeyField(1)))
+
+	hired := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	values := map[string]any{"dept_id": 5, "hire_date": hired, "name": "ann"}
+	key, ok := composite.KeyOf(values)
+	require.True(t, ok)
+	assert.Equal(t, types.CompositeKey{5, hired, "ann"}, key)
+	_, ok = composite.KeyOf(map[string]any{"dept_id": 5, "hire_date": nil, "name": "ann"})
+	assert.False(t, ok)
+
+	definitions, err := indexManager.indexDefinitions("emp", txn)
+	require.NoError(t, err)
+	assert.Equal(t, fields, definitions[0].fieldNames)
+
+	dropped, err := indexManager.DropIndex("emp_dept_hired", txn)
+	require.NoError(t, err)
+	assert.Equal(t, fields, dropped.FieldNames())
+
+	// Dropping the index removed its fields from the index field catalog.
+	fieldScan, err := table.NewTableScan(txn, indexFieldCatalogTable, indexManager.fieldLayout)
+	require.NoError(t, err)
+	defer fieldScan.Close()
+	hasNext, err := fieldScan.Next()
+	require.NoError(t, err)
+	assert.False(t, hasNext)
+}
+
+func TestIndexManager_IndexMustFitBlock(t *testing.T) {
+	wideSchema := record.NewSchema()
+	wideSchema.AddIntField("id")
+	wideSchema.AddStringField("description", 300)
+
+	t.Run("rejected with small blocks", func(t *testing.T) {
+		tm, txn, cleanup := setupTestMetadata(400, t)
+		defer cleanup()
+		sm, err := NewStatManager(tm, txn, 100)
+		require.NoError(t, err)
+		indexManager, err := NewIndexManager(true, tm, sm, txn)
+		require.NoError(t, err)
+		require.NoError(t, tm.CreateTable("documents", wideSchema, txn))
+
+		err = indexManager.CreateIndex("description_idx", "documents", "description", txn)
+		assert.EqualError(t, err, "index description_idx on documents.description needs a block size of at least 3736 bytes, "+
+			"but the block size is 400; use a larger block size or index a shorter field")
+
+		// The index was rejected before anything was written to the catalog.
+		size, err := txn.Size(indexCatalogTable + ".tbl")
+		require.NoError(t, err)
+		assert.Zero(t, size)
+	})
+
+	t.Run("accepted with large blocks", func(t *testing.T) {
+		tm, txn, cleanup := setupTestMetadata(8192, t)
+		defer cleanup()
+		sm, err := NewStatManager(tm, txn, 100)
+		require.NoError(t, err)
+		indexManager, err := NewIndexManager(true, tm, sm, txn)
+		require.NoError(t, err)
+		require.NoError(t, tm.CreateTable("documents", wideSchema, txn))
+
+		require.NoError(t, indexManager.CreateIndex("description_idx", "documents", "description", txn))
+		indexInfos, err := indexManager.GetIndexInfo("documents", txn)
+		require.NoError(t, err)
+		require.Len(t, indexInfos, 1)
+		require.Equal(t, "description", indexInfos[0].FieldName())
+
+		// The index records hold the full declared length of the field,
+		// and a b-tree over them splits its pages as it grows.
+		indexLayout := indexInfos[0].CreateIndexLayout()
+		assert.Equal(t, 300, indexLayout.Schema().Length(common.DataValueField))
+		btreeIndex, err := btree.NewIndex(txn, "description_idx", indexLayout)
+		require.NoError(t, err)
+		defer btreeIndex.Close()
+
+		key := func(i int) string {
+			return fmt.Sprintf("%03d%s", i, strings.Repeat("x", 297))
+		}
+		for i := 0; i < 50; i++ {
+			require.NoError(t, btreeIndex.Insert(key(i), record.NewID(i, 0)))
+		}
+		for i := 0; i < 50; i++ {
+			require.NoError(t, btreeIndex.BeforeFirst(key(i)))
+			found, err := btreeIndex.Next()
+			require.NoError(t, err)
+			require.True(t, found, "key %d not found", i)
+			rid, err := btreeIndex.GetDataRecordID()
+			require.NoError(t, err)
+			assert.Equal(t, i, rid.BlockNumber())
+		}
+		size, err := txn.Size("description_idx_leaf")
+		require.NoError(t, err)
+		assert.Greater(t, size, 1)
+	})
+}
+
+func TestIndexManager_DropIndex(t *testing.T) {
+	tm, indexManager, txn, cleanup := setupIndexManagerTest(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("name", 20)
+	require.NoError(t, tm.CreateTable("test_table", schema, txn))
+	require.NoError(t, indexManager.CreateIndex("test_id", "test_table", "id", txn))
+	require.NoError(t, indexManager.CreateIndex("test_name", "test_table", "name", txn))
+
+	indexInfo, err := indexManager.DropIndex("test_id", txn)
+	require.NoError(t, err)
+	assert.Equal(t, "test_id", indexInfo.IndexName())
+	assert.Equal(t, "id", indexInfo.FieldName())
+	assert.True(t, table.HoldsTableLock(txn, "test_table"))
+
+	indexInfos, err := indexManager.GetIndexInfo("test_table", txn)
+	require.NoError(t, err)
+	require.Len(t, indexInfos, 1)
+	assert.Equal(t, "test_name", indexInfos[0].IndexName())
+
+	_, err = indexManager.DropIndex("test_id", txn)
+	assert.ErrorContains(t, err, "index test_id not found")
+}
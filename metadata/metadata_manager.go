@@ -1,10 +1,18 @@
 package metadata
 
 import (
+	"fmt"
+	"slices"
+	"time"
+
 	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
 )
 
+// catalogTables are the tables of the catalog, which describe the tables created by the users of the database.
+var catalogTables = []string{tableCatalogTable, fieldCatalogTable, viewCatalogTable, indexCatalogTable, indexFieldCatalogTable}
+
 type Manager struct {
 	tableManager *TableManager
 	viewManager  *ViewManager
@@ -37,11 +45,67 @@ func (m *Manager) CreateTable(tableName string, schema *record.Schema, transacti
 	return m.tableManager.CreateTable(tableName, schema, transaction)
 }
 
+// CreateTableWithOptions creates a new table having the specified name, schema, and storage attributes.
+func (m *Manager) CreateTableWithOptions(tableName string, schema *record.Schema, options TableOptions, transaction *tx.Transaction) error {
+	return m.tableManager.CreateTableWithOptions(tableName, schema, options, transaction)
+}
+
+// GetTableOptions returns the storage attributes of the specified table from the catalog.
+func (m *Manager) GetTableOptions(tableName string, transaction *tx.Transaction) (TableOptions, error) {
+	return m.tableManager.GetTableOptions(tableName, transaction)
+}
+
 // GetLayout returns the layout of the specified table from the catalog.
 func (m *Manager) GetLayout(tableName string, transaction *tx.Transaction) (*record.Layout, error) {
 	return m.tableManager.GetLayout(tableName, transaction)
 }
 
+// GetTableNames returns the names of the tables created by the users of the database, in the order they were
+// created. The catalog tables are left out.
+func (m *Manager) GetTableNames(transaction *tx.Transaction) ([]string, error) {
+	tableNames, err := m.tableManager.TableNames(transaction)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(tableNames, func(tableName string) bool {
+		return slices.Contains(catalogTables, tableName)
+	}), nil
+}
+
+// DropTable removes the specified table, and its indexes, from the catalog, and forgets its statistics.
+// The table is locked (see table.LockTable) until the transaction completes. Its file, and those of its indexes,
+// are deleted once the transaction commits, so that they are kept along with the catalog records if it rolls back.
+// The catalog tables cannot be dropped.
+func (m *Manager) DropTable(tableName string, transaction *tx.Transaction) error {
+	if slices.Contains(catalogTables, tableName) {
+		return fmt.Errorf("catalog table %s cannot be dropped", tableName)
+	}
+	if _, err := m.tableManager.GetLayout(tableName, transaction); err != nil {
+		return err
+	}
+	if err := table.LockTable(transaction, tableName); err != nil {
+		return err
+	}
+	definitions, err := m.indexManager.indexDefinitions(tableName, transaction)
+	if err != nil {
+		return err
+	}
+	for _, definition := range definitions {
+		if err := m.DropIndex(definition.indexName, transaction); err != nil {
+			return err
+		}
+	}
+	if err := m.tableManager.DropTable(tableName, transaction); err != nil {
+		return err
+	}
+	m.statManager.InvalidateTable(tableName)
+	transaction.OnCommit(func() {
+		// A file that cannot be removed is left behind, like the file of a temporary table.
+		_ = table.RemoveTable(transaction, tableName)
+	})
+	return nil
+}
+
 // CreateView creates a view.
 func (m *Manager) CreateView(viewName, viewDefinition string, transaction *tx.Transaction) error {
 	return m.viewManager.CreateView(viewName, viewDefinition, transaction)
@@ -52,19 +116,81 @@ func (m *Manager) GetViewDefinition(viewName string, transaction *tx.Transaction
 	return m.viewManager.GetViewDefinition(viewName, transaction)
 }
 
+// GetViews returns the views of the database, in the order they were created.
+func (m *Manager) GetViews(transaction *tx.Transaction) ([]View, error) {
+	return m.viewManager.Views(transaction)
+}
+
 // CreateIndex creates a new index of the specified type for the specified field.
 // A unique ID is assigned to this index, and its information is stored in the indexCatalogTable.
 func (m *Manager) CreateIndex(indexName, tableName, fieldName string, transaction *tx.Transaction) error {
 	return m.indexManager.CreateIndex(indexName, tableName, fieldName, transaction)
 }
 
-// GetIndexInfo returns a map containing the index info for all indexes on the specified table.
-func (m *Manager) GetIndexInfo(tableName string, transaction *tx.Transaction) (map[string]*IndexInfo, error) {
+// CreatePartialIndex creates a new index for the specified field that only contains
+// the records satisfying the specified predicate.
+func (m *Manager) CreatePartialIndex(indexName, tableName, fieldName, predicate string, transaction *tx.Transaction) error {
+	return m.indexManager.CreatePartialIndex(indexName, tableName, fieldName, predicate, transaction)
+}
+
+// CreateIndexWithOptions creates a new index for the specified field, with the specified attributes.
+func (m *Manager) CreateIndexWithOptions(indexName, tableName, fieldName string, options IndexOptions, transaction *tx.Transaction) error {
+	return m.indexManager.CreateIndexWithOptions(indexName, tableName, fieldName, options, transaction)
+}
+
+// CreateCompositeIndex creates a new index on the specified fields, with the specified attributes.
+func (m *Manager) CreateCompositeIndex(indexName, tableName string, fieldNames []string, options IndexOptions, transaction *tx.Transaction) error {
+	return m.indexManager.CreateCompositeIndex(indexName, tableName, fieldNames, options, transaction)
+}
+
+// DropIndex removes the specified index from the catalog, locking its table (see table.LockTable) until the
+// transaction completes. The files of the index are deleted once the transaction commits, so that they are kept
+// along with its catalog record if it rolls back.
+func (m *Manager) DropIndex(indexName string, transaction *tx.Transaction) error {
+	indexInfo, err := m.indexManager.DropIndex(indexName, transaction)
+	if err != nil {
+		return err
+	}
+	m.statManager.ForgetIndex(indexName)
+	transaction.OnCommit(func() {
+		if idx, err := indexInfo.Open(); err == nil {
+			_ = idx.Drop()
+		}
+	})
+	return nil
+}
+
+// GetIndexInfo returns the index info for all indexes on the specified table, ordered by index name.
+func (m *Manager) GetIndexInfo(tableName string, transaction *tx.Transaction) ([]*IndexInfo, error) {
 	return m.indexManager.GetIndexInfo(tableName, transaction)
 }
 
+// GetIndexInfoWithoutStats returns the index info for all indexes on the specified table like GetIndexInfo,
+// without reading the records of the table, which may be corrupted. The cost estimates of the index info are all zero.
+func (m *Manager) GetIndexInfoWithoutStats(tableName string, transaction *tx.Transaction) ([]*IndexInfo, error) {
+	return m.indexManager.GetIndexInfoWithoutStats(tableName, transaction)
+}
+
+// IsIndexSuspect returns true if the specified index was found to be out of date with its table,
+// for instance by an index join reading records that do not match the index, so that it should be rebuilt.
+func (m *Manager) IsIndexSuspect(indexName string) bool {
+	return m.statManager.IsIndexSuspect(indexName)
+}
+
 // GetStatInfo returns statistical information about the specified table.
-// It refreshes statistics periodically based on the refreshLimit.
+// The statistics are calculated again once records of the table changed (see RecordTableChanges).
 func (m *Manager) GetStatInfo(tableName string, layout *record.Layout, transaction *tx.Transaction) (*StatInfo, error) {
 	return m.statManager.GetStatInfo(tableName, layout, transaction)
 }
+
+// RecordTableChanges records that the specified number of records of the table were inserted, deleted or modified,
+// so that its statistics are calculated again (see StatManager#RecordChanges).
+func (m *Manager) RecordTableChanges(tableName string, count int) {
+	m.statManager.RecordChanges(tableName, count)
+}
+
+// StartBackgroundStatsRefresh calculates the statistics of the changed tables in a goroutine, at the specified
+// interval, rather than when a query needs them (see StatManager#StartBackgroundRefresh). The returned function stops it.
+func (m *Manager) StartBackgroundStatsRefresh(interval time.Duration, newTransaction func() *tx.Transaction) (func(), error) {
+	return m.statManager.StartBackgroundRefresh(interval, newTransaction)
+}
@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+)
+
+// ErrInvalidName is returned when a table, field, view or index would be created with a name
+// that the parser could not read back, or that cannot be stored in the catalog or used as a file name.
+var ErrInvalidName = errors.New("invalid name")
+
+// reservedFileNames are the names that cannot be used as file names on some platforms,
+// such as the device names of Windows, whatever their extension.
+var reservedFileNames = map[string]struct{}{
+	"con": {}, "prn": {}, "aux": {}, "nul": {},
+	"com1": {}, "com2": {}, "com3": {}, "com4": {}, "com5": {}, "com6": {}, "com7": {}, "com8": {}, "com9": {},
+	"lpt1": {}, "lpt2": {}, "lpt3": {}, "lpt4": {}, "lpt5": {}, "lpt6": {}, "lpt7": {}, "lpt8": {}, "lpt9": {},
+}
+
+// checkName returns an error wrapping ErrInvalidName if the specified name of a table, field, view or index
+// is not an identifier: a letter or underscore followed by letters, digits and underscores, of at most maxNameLength
+// characters.
+// The parser only produces such names, but programmatic callers may not.
+func checkName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: %s name is empty", ErrInvalidName, kind)
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("%w: %s name %.16q... exceeds %d characters", ErrInvalidName, kind, name, maxNameLength)
+	}
+	for i, r := range name {
+		if !parse.IsIdentifierRune(r) || (i == 0 && unicode.IsDigit(r)) {
+			return fmt.Errorf("%w: %s name %q contains %q; names consist of letters, digits and underscores, and do not start with a digit",
+				ErrInvalidName, kind, name, r)
+		}
+	}
+	return nil
+}
+
+// checkFileName checks the name of a table or index like checkName, and also returns an error wrapping
+// ErrInvalidName if the files storing the table or index could not be named after it.
+func checkFileName(kind, name string) error {
+	if err := checkName(kind, name); err != nil {
+		return err
+	}
+	if _, reserved := reservedFileNames[strings.ToLower(name)]; reserved {
+		return fmt.Errorf("%w: %s name %q is reserved as a file name", ErrInvalidName, kind, name)
+	}
+	return nil
+}
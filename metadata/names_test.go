@@ -0,0 +1,28 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckName(t *testing.T) {
+	for _, name := range []string{"users", "_tmp", "t1", "order_items", "café", strings.Repeat("n", maxNameLength), strings.Repeat("é", maxNameLength)} {
+		assert.NoError(t, checkName("table", name), name)
+	}
+	for _, name := range []string{"", "a/b", "../etc", "a.b", "a b", "new\nline", "nul\x00", "1st", "\xff", strings.Repeat("n", maxNameLength+1), strings.Repeat("é", maxNameLength+1)} {
+		assert.ErrorIs(t, checkName("table", name), ErrInvalidName, name)
+	}
+	assert.EqualError(t, checkName("field", "a/b"),
+		`invalid name: field name "a/b" contains '/'; names consist of letters, digits and underscores, and do not start with a digit`)
+}
+
+func TestCheckFileName(t *testing.T) {
+	assert.NoError(t, checkFileName("index", "idx_console"))
+	for _, name := range []string{"con", "NUL", "com1", "lpt9"} {
+		assert.ErrorIs(t, checkFileName("index", name), ErrInvalidName, name)
+	}
+	// Views and fields are not stored in files of their own.
+	assert.NoError(t, checkName("view", "con"))
+}
@@ -1,9 +1,23 @@
 package metadata
 
+import (
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// unknownRangeFraction is the fraction of the records assumed to be on each bounded side of a range,
+// when the values of the field cannot be interpolated between its smallest and largest value.
+const unknownRangeFraction = 1.0 / 3
+
 type StatInfo struct {
 	numBlocks      int
 	numRecords     int
 	distinctValues map[string]int
+	// minValues and maxValues hold the smallest and largest non-null value of each field, if known.
+	minValues map[string]any
+	maxValues map[string]any
 }
 
 // NewStatInfo creates a new StatInfo object with calculated distinct values.
@@ -15,6 +29,12 @@ func NewStatInfo(numBlocks, numRecords int, distinctValues map[string]int) *Stat
 	}
 }
 
+// withBounds records the smallest and largest value of the fields, and returns the StatInfo.
+func (si *StatInfo) withBounds(minValues, maxValues map[string]any) *StatInfo {
+	si.minValues, si.maxValues = minValues, maxValues
+	return si
+}
+
 // BlocksAccessed returns the estimated number of blocks in the table.
 func (si *StatInfo) BlocksAccessed() int {
 	return si.numBlocks
@@ -33,3 +53,92 @@ func (si *StatInfo) DistinctValues(fieldName string) int {
 	}
 	return -1 // Default to -1 if the field is not found
 }
+
+// RangeFraction returns the estimated fraction of the records whose value of the field is in the range.
+// The values of numeric and date fields are assumed to be spread evenly between the smallest and largest value
+// of the field, and a range overlapping them holds at least the records of one distinct value.
+// For the other fields, each bound of the range is assumed to select a third of the records.
+func (si *StatInfo) RangeFraction(fieldName string, keyRange index.Range) float64 {
+	low, lowOK := rangePosition(si.minValues[fieldName])
+	high, highOK := rangePosition(si.maxValues[fieldName])
+	if !lowOK || !highOK {
+		fraction := 1.0
+		if keyRange.Low != nil {
+			fraction *= unknownRangeFraction
+		}
+		if keyRange.High != nil {
+			fraction *= unknownRangeFraction
+		}
+		return fraction
+	}
+
+	from, to := low, high
+	if keyRange.Low != nil {
+		bound, ok := rangePosition(keyRange.Low)
+		if !ok {
+			return unknownRangeFraction
+		}
+		from = max(from, bound)
+	}
+	if keyRange.High != nil {
+		bound, ok := rangePosition(keyRange.High)
+		if !ok {
+			return unknownRangeFraction
+		}
+		to = min(to, bound)
+	}
+	switch {
+	case keyRange.Above(si.minValues[fieldName]) || keyRange.Below(si.maxValues[fieldName]) || from > to:
+		return 0
+	case high == low:
+		return 1
+	}
+	fraction := (to - from) / (high - low)
+	if distinctValues := si.DistinctValues(fieldName); distinctValues > 0 {
+		fraction = max(fraction, 1/float64(distinctValues))
+	}
+	return min(fraction, 1)
+}
+
+// rangePosition returns the position of a numeric, date or string value on a line, along which the values
+// of a field can be interpolated. A string is placed by its first bytes, read as the digits of a number
+// in base 256, which keeps the order of strings differing in those bytes. It returns false for the values of the other types.
+func rangePosition(val any) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case float64:
+		return v, true
+	case time.Time:
+		return float64(v.Unix()), true
+	case string:
+		position := 0.0
+		for i := 0; i < 8; i++ {
+			position *= 256
+			if i < len(v) {
+				position += float64(v[i])
+			}
+		}
+		return position, true
+	default:
+		return 0, false
+	}
+}
+
+// updateBounds widens the smallest and largest value of the field recorded in the maps to include the value.
+// Null and boolean values, which have no order, are skipped.
+func updateBounds(minValues, maxValues map[string]any, fieldName string, val any) {
+	if _, isBool := val.(bool); val == nil || isBool {
+		return
+	}
+	if current, ok := minValues[fieldName]; !ok || types.CompareSupportedTypes(val, current, types.LT) {
+		minValues[fieldName] = val
+	}
+	if current, ok := maxValues[fieldName]; !ok || types.CompareSupportedTypes(val, current, types.GT) {
+		maxValues[fieldName] = val
+	}
+}
@@ -1,26 +1,53 @@
 package metadata
 
 import (
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"slices"
 	"sync"
 )
 
+// StatManager keeps the statistics of the tables in memory. The statistics of a table are calculated by scanning it,
+// and calculated again once the records of the table changed since then exceed a threshold (see RecordChanges),
+// so that a query only pays for scanning the tables it reads that changed. The changes can instead be applied by
+// a goroutine in the background (see StartBackgroundRefresh).
+// A StatInfo is never modified once calculated: new statistics replace it, so each of them is a consistent snapshot.
 type StatManager struct {
 	tableManager *TableManager
 	tableStats   map[string]*StatInfo
-	numCalls     int
-	mu           sync.Mutex
-	refreshLimit int
+	// indexStats are the statistics of the shape of the indexes of each table, by index name.
+	indexStats map[string]map[string]*index.Stats
+	// partialStats are the statistics of the records of each table satisfying a predicate, such as the records
+	// of its partial indexes, by predicate. They are calculated again with the statistics of the table.
+	partialStats map[string]map[string]*StatInfo
+	// changes are the approximate numbers of records inserted, deleted or modified in each table
+	// since its statistics were calculated.
+	changes        map[string]int
+	suspectIndexes map[string]bool
+	mu             sync.Mutex
+	// refreshMu is held while a table is scanned to calculate its statistics, so that the tables are scanned
+	// one at a time, leaving the buffers to the queries, while the cached statistics are read under mu.
+	refreshMu sync.Mutex
+	// changeThreshold is the number of changes to a table beyond which its statistics are calculated again.
+	changeThreshold int
+	// refresher is the goroutine refreshing the statistics in the background, if it is running.
+	refresher *statRefresher
 }
 
 // NewStatManager creates a new StatManager instance, initializing statistics by scanning the entire database.
-func NewStatManager(tableManager *TableManager, transaction *tx.Transaction, refreshLimit int) (*StatManager, error) {
+// The statistics of a table are calculated again once more than changeThreshold of its records changed.
+func NewStatManager(tableManager *TableManager, transaction *tx.Transaction, changeThreshold int) (*StatManager, error) {
 	statMgr := &StatManager{
-		tableManager: tableManager,
-		tableStats:   make(map[string]*StatInfo),
-		refreshLimit: refreshLimit,
+		tableManager:    tableManager,
+		tableStats:      make(map[string]*StatInfo),
+		indexStats:      make(map[string]map[string]*index.Stats),
+		partialStats:    make(map[string]map[string]*StatInfo),
+		changes:         make(map[string]int),
+		suspectIndexes:  make(map[string]bool),
+		changeThreshold: changeThreshold,
 	}
 	if err := statMgr.RefreshStatistics(transaction); err != nil {
 		return nil, err
@@ -28,30 +55,162 @@ func NewStatManager(tableManager *TableManager, transaction *tx.Transaction, ref
 	return statMgr, nil
 }
 
+// MarkIndexSuspect records that the specified index was found to be out of date with its table,
+// so that it should be rebuilt. The mark is kept in memory, like the statistics.
+func (sm *StatManager) MarkIndexSuspect(indexName string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.suspectIndexes[indexName] = true
+}
+
+// IsIndexSuspect returns true if the specified index was found to be out of date with its table.
+func (sm *StatManager) IsIndexSuspect(indexName string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.suspectIndexes[indexName]
+}
+
+// ForgetIndex forgets that the specified index was found to be out of date with its table,
+// and the statistics of its shape, such as when it is dropped, so that an index later created
+// with the same name is not suspect.
+func (sm *StatManager) ForgetIndex(indexName string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.suspectIndexes, indexName)
+	for _, tableIndexStats := range sm.indexStats {
+		delete(tableIndexStats, indexName)
+	}
+}
+
+// GetIndexStats returns the statistics of the shape of the specified index of the table, such as the height of a b-tree
+// or the number of buckets of a hash index, reading them with the specified function if they are not cached.
+// They are cached until the statistics of the table are calculated again.
+func (sm *StatManager) GetIndexStats(tableName, indexName string, read func() (*index.Stats, error)) (*index.Stats, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if stats, ok := sm.indexStats[tableName][indexName]; ok {
+		return stats, nil
+	}
+	stats, err := read()
+	if err != nil {
+		return nil, err
+	}
+	if sm.indexStats[tableName] == nil {
+		sm.indexStats[tableName] = make(map[string]*index.Stats)
+	}
+	sm.indexStats[tableName][indexName] = stats
+	return stats, nil
+}
+
+// InvalidateTable forgets the statistics of the specified table, such as when it is dropped,
+// so that they are calculated again the next time they are needed.
+func (sm *StatManager) InvalidateTable(tableName string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.tableStats, tableName)
+	delete(sm.indexStats, tableName)
+	delete(sm.partialStats, tableName)
+	delete(sm.changes, tableName)
+}
+
+// RecordChanges records that the specified number of records of the table were inserted, deleted or modified,
+// which the update planners do after each statement. The count is approximate: the changes of a transaction
+// that rolls back are counted too.
+func (sm *StatManager) RecordChanges(tableName string, count int) {
+	if count <= 0 {
+		return
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.changes[tableName] += count
+}
+
 // GetStatInfo returns statistical information about the specified table.
-// It refreshes statistics periodically based on the refreshLimit.
+// The statistics are calculated if the table has none yet, or if more of its records changed since they were
+// calculated than the threshold, unless they are refreshed in the background (see StartBackgroundRefresh).
 func (sm *StatManager) GetStatInfo(tableName string, layout *record.Layout, transaction *tx.Transaction) (*StatInfo, error) {
+	sm.mu.Lock()
+	statInfo, exists := sm.tableStats[tableName]
+	stale := !exists || (sm.refresher == nil && sm.changes[tableName] > sm.changeThreshold)
+	sm.mu.Unlock()
+	if !stale {
+		return statInfo, nil
+	}
+	return sm.refreshTable(tableName, layout, transaction)
+}
+
+// refreshTable calculates the statistics of the specified table, and replaces its cached statistics with them.
+// The table is scanned without holding sm.mu, so that the cached statistics can be read meanwhile.
+// The changes made to the table during the scan are left to be counted towards the next refresh.
+func (sm *StatManager) refreshTable(tableName string, layout *record.Layout, transaction *tx.Transaction) (*StatInfo, error) {
+	sm.refreshMu.Lock()
+	defer sm.refreshMu.Unlock()
+
+	sm.mu.Lock()
+	changes := sm.changes[tableName]
+	sm.mu.Unlock()
+
+	statInfo, err := sm.calcTableStats(tableName, layout, nil, transaction)
+	if err != nil {
+		return nil, err
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	sm.tableStats[tableName] = statInfo
+	delete(sm.indexStats, tableName)
+	delete(sm.partialStats, tableName)
+	if sm.changes[tableName] -= changes; sm.changes[tableName] <= 0 {
+		delete(sm.changes, tableName)
+	}
+	return statInfo, nil
+}
 
-	sm.numCalls++
-	if sm.numCalls > sm.refreshLimit {
-		// Call the internal refresh that expects the lock to already be held
-		if err := sm._refreshStatistics(transaction); err != nil {
-			return nil, err
+// changedTables returns the names of the tables whose statistics are out of date: the tables
+// that have statistics, and more changed records since they were calculated than the threshold.
+func (sm *StatManager) changedTables() []string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	var tableNames []string
+	for tableName, changes := range sm.changes {
+		if _, ok := sm.tableStats[tableName]; ok && changes > sm.changeThreshold {
+			tableNames = append(tableNames, tableName)
 		}
 	}
+	slices.Sort(tableNames)
+	return tableNames
+}
 
-	if statInfo, exists := sm.tableStats[tableName]; exists {
+// GetPartialStatInfo returns statistical information about the records
+// of the specified table that satisfy the predicate, such as the records of a partial index.
+// These statistics are cached with those of the table, and calculated again when they are (see GetStatInfo).
+func (sm *StatManager) GetPartialStatInfo(tableName string, layout *record.Layout, predicate *query.Predicate, transaction *tx.Transaction) (*StatInfo, error) {
+	tableStats, err := sm.GetStatInfo(tableName, layout, transaction)
+	if err != nil {
+		return nil, err
+	}
+	key := predicate.String()
+	sm.mu.Lock()
+	statInfo, ok := sm.partialStats[tableName][key]
+	sm.mu.Unlock()
+	if ok {
 		return statInfo, nil
 	}
 
-	// Calculate statistics if not already available
-	statInfo, err := sm.calcTableStats(tableName, layout, transaction)
+	statInfo, err = sm.calcTableStats(tableName, layout, predicate, transaction)
 	if err != nil {
 		return nil, err
 	}
-	sm.tableStats[tableName] = statInfo
+
+	// The statistics are only cached if those of the table were not calculated again meanwhile.
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.tableStats[tableName] == tableStats {
+		if sm.partialStats[tableName] == nil {
+			sm.partialStats[tableName] = make(map[string]*StatInfo)
+		}
+		sm.partialStats[tableName][key] = statInfo
+	}
 	return statInfo, nil
 }
 
@@ -63,13 +222,16 @@ func (sm *StatManager) RefreshStatistics(transaction *tx.Transaction) error {
 	return sm._refreshStatistics(transaction)
 }
 
-// _refreshStatistics recalculates statistics for all tables in the database.
+// _refreshStatistics recalculates statistics for all tables in the database, and forgets the statistics
+// of the indexes and of the records satisfying predicates, which are calculated again the next time they are needed.
 // It assumes the caller already holds sm.mu.
 func (sm *StatManager) _refreshStatistics(transaction *tx.Transaction) error {
 	// Since the caller already holds the lock, do NOT lock here.
 
 	sm.tableStats = make(map[string]*StatInfo)
-	sm.numCalls = 0
+	sm.indexStats = make(map[string]map[string]*index.Stats)
+	sm.partialStats = make(map[string]map[string]*StatInfo)
+	sm.changes = make(map[string]int)
 
 	tableCatalogLayout, err := sm.tableManager.GetLayout(tableCatalogTable, transaction)
 	if err != nil {
@@ -100,7 +262,7 @@ func (sm *StatManager) _refreshStatistics(transaction *tx.Transaction) error {
 			return err
 		}
 
-		statInfo, err := sm.calcTableStats(tblName, layout, transaction)
+		statInfo, err := sm.calcTableStats(tblName, layout, nil, transaction)
 		if err != nil {
 			return err
 		}
@@ -110,11 +272,14 @@ func (sm *StatManager) _refreshStatistics(transaction *tx.Transaction) error {
 	return nil
 }
 
-// calcTableStats calculates the number of records, blocks, and distinct values for a specific table.
-func (sm *StatManager) calcTableStats(tableName string, layout *record.Layout, transaction *tx.Transaction) (*StatInfo, error) {
+// calcTableStats calculates the number of records, blocks, and distinct values for a specific table,
+// and the smallest and largest value of each field.
+// If predicate is not nil, only the records satisfying it are counted.
+func (sm *StatManager) calcTableStats(tableName string, layout *record.Layout, predicate *query.Predicate, transaction *tx.Transaction) (*StatInfo, error) {
 	numRecords := 0
 	numBlocks := 0
 	distinctValues := make(map[string]map[any]interface{}) // field name -> distinct values
+	minValues, maxValues := make(map[string]any), make(map[string]any)
 
 	for _, field := range layout.Schema().Fields() {
 		distinctValues[field] = make(map[any]interface{})
@@ -134,9 +299,21 @@ func (sm *StatManager) calcTableStats(tableName string, layout *record.Layout, t
 		if !hasNext {
 			break
 		}
+		if predicate != nil {
+			satisfied, err := predicate.IsSatisfied(ts)
+			if err != nil {
+				return nil, err
+			}
+			if !satisfied {
+				continue
+			}
+		}
 
 		numRecords++
-		rid := ts.GetRecordID()
+		rid, err := ts.GetRecordID()
+		if err != nil {
+			return nil, err
+		}
 		if rid.BlockNumber() >= numBlocks {
 			numBlocks = rid.BlockNumber() + 1
 		}
@@ -148,6 +325,7 @@ func (sm *StatManager) calcTableStats(tableName string, layout *record.Layout, t
 				return nil, err
 			}
 			distinctValues[field][val] = struct{}{}
+			updateBounds(minValues, maxValues, field, val)
 		}
 	}
 
@@ -156,5 +334,5 @@ func (sm *StatManager) calcTableStats(tableName string, layout *record.Layout, t
 		distinctCounts[field] = len(values)
 	}
 
-	return NewStatInfo(numBlocks, numRecords, distinctCounts), nil
+	return NewStatInfo(numBlocks, numRecords, distinctCounts).withBounds(minValues, maxValues), nil
 }
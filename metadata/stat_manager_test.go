@@ -2,10 +2,18 @@ package metadata
 
 import (
 	"testing"
+	"time"
 
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -51,6 +59,14 @@ func TestStatMgr_GetStatInfo(t *testing.T) {
 	assert.Equal(t, 4, stats.BlocksAccessed(), "Number of blocks mismatch")
 	assert.Equal(t, 10, stats.DistinctValues("id"), "Distinct values for 'id' mismatch")
 	assert.Equal(t, 10, stats.DistinctValues("name"), "Distinct values for 'name' mismatch")
+
+	// The ids are interpolated between 1 and 10, and the names by their first bytes.
+	assert.InDelta(t, 3.0/9, stats.RangeFraction("id", index.Range{Low: 4, LowInclusive: true, High: 7}), 1e-9)
+	assert.InDelta(t, 0.1, stats.RangeFraction("id", index.Range{Low: 5, High: 5, HighInclusive: true}), 1e-9)
+	assert.Equal(t, 0.0, stats.RangeFraction("id", index.Range{Low: 10}))
+	assert.Equal(t, 1.0, stats.RangeFraction("id", index.Range{High: 20}))
+	assert.InDelta(t, 3.0/9, stats.RangeFraction("name", index.Range{Low: "name\x04", LowInclusive: true, High: "name\x07"}), 1e-9)
+	assert.Equal(t, 1.0, stats.RangeFraction("name", index.Range{Low: "a"}))
 }
 
 func TestStatMgr_RefreshStatistics(t *testing.T) {
@@ -64,9 +80,13 @@ func TestStatMgr_RefreshStatistics(t *testing.T) {
 	err := tableManager.CreateTable("test_table", schema, txn)
 	require.NoError(t, err)
 
-	// Insert some data
 	layout, err := tableManager.GetLayout("test_table", txn)
 	require.NoError(t, err)
+	stats, err := statMgr.GetStatInfo("test_table", layout, txn)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.RecordsOutput())
+
+	// Insert some data
 	ts, err := table.NewTableScan(txn, "test_table", layout)
 	require.NoError(t, err)
 	defer ts.Close()
@@ -77,14 +97,171 @@ func TestStatMgr_RefreshStatistics(t *testing.T) {
 		require.NoError(t, ts.SetString("name", "name"+string(rune(i))))
 	}
 
-	// Call GetStatInfo twice to trigger a refresh
+	// The statistics are calculated again once more records changed than the threshold of 2.
+	statMgr.RecordChanges("test_table", 2)
+	stats, err = statMgr.GetStatInfo("test_table", layout, txn)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.RecordsOutput(), "statistics refreshed before the threshold")
+
+	statMgr.RecordChanges("test_table", 3)
+	stats, err = statMgr.GetStatInfo("test_table", layout, txn)
+	require.NoError(t, err)
+	assert.Equal(t, 5, stats.RecordsOutput(), "Number of records mismatch after refresh")
+}
+
+func TestStatMgr_GetIndexStats(t *testing.T) {
+	statMgr, _, txn, cleanup := setupStatMgr(t, 100)
+	defer cleanup()
+
+	reads := 0
+	read := func() (*index.Stats, error) {
+		reads++
+		return &index.Stats{Height: reads, Blocks: -1, Buckets: 1, Records: -1}, nil
+	}
+
+	// The statistics are read once, and cached until they are refreshed or the index is forgotten.
 	for i := 0; i < 3; i++ {
-		_, err := statMgr.GetStatInfo("test_table", layout, txn)
+		stats, err := statMgr.GetIndexStats("t", "idx", read)
 		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Height)
 	}
+	require.NoError(t, statMgr.RefreshStatistics(txn))
+	stats, err := statMgr.GetIndexStats("t", "idx", read)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Height)
 
-	// Confirm that statistics are refreshed
-	stats, err := statMgr.GetStatInfo("test_table", layout, txn)
+	statMgr.ForgetIndex("idx")
+	stats, err = statMgr.GetIndexStats("t", "idx", read)
 	require.NoError(t, err)
-	assert.Equal(t, 5, stats.RecordsOutput(), "Number of records mismatch after refresh")
+	assert.Equal(t, 3, stats.Height)
+}
+
+// insertStatRecords inserts records with the ids from 1 to n into the test table, and records the changes.
+func insertStatRecords(t *testing.T, statMgr *StatManager, layout *record.Layout, txn *tx.Transaction, tableName string, n int) {
+	ts, err := table.NewTableScan(txn, tableName, layout)
+	require.NoError(t, err)
+	defer ts.Close()
+	for i := 1; i <= n; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+	}
+	statMgr.RecordChanges(tableName, n)
+}
+
+func TestStatMgr_RefreshesOnlyChangedTables(t *testing.T) {
+	statMgr, tableManager, txn, cleanup := setupStatMgr(t, 10)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	require.NoError(t, tableManager.CreateTable("busy", schema, txn))
+	require.NoError(t, tableManager.CreateTable("quiet", schema, txn))
+	layout, err := tableManager.GetLayout("busy", txn)
+	require.NoError(t, err)
+	insertStatRecords(t, statMgr, layout, txn, "quiet", 5)
+	require.NoError(t, statMgr.RefreshStatistics(txn))
+	quietStats, err := statMgr.GetStatInfo("quiet", layout, txn)
+	require.NoError(t, err)
+
+	// Inserting heavily into one table recalculates its statistics only.
+	for round := 1; round <= 5; round++ {
+		insertStatRecords(t, statMgr, layout, txn, "busy", 100)
+		busyStats, err := statMgr.GetStatInfo("busy", layout, txn)
+		require.NoError(t, err)
+		assert.Equal(t, round*100, busyStats.RecordsOutput())
+
+		stats, err := statMgr.GetStatInfo("quiet", layout, txn)
+		require.NoError(t, err)
+		assert.Same(t, quietStats, stats)
+	}
+	assert.Empty(t, statMgr.changedTables())
+}
+
+func TestStatMgr_GetPartialStatInfo(t *testing.T) {
+	statMgr, tableManager, txn, cleanup := setupStatMgr(t, 10)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	require.NoError(t, tableManager.CreateTable("test_table", schema, txn))
+	layout, err := tableManager.GetLayout("test_table", txn)
+	require.NoError(t, err)
+	insertStatRecords(t, statMgr, layout, txn, "test_table", 20)
+
+	predicate := query.NewPredicateFromTerm(query.NewTerm(query.NewFieldExpression("id"), query.NewConstantExpression(15), types.GT))
+	stats, err := statMgr.GetPartialStatInfo("test_table", layout, predicate, txn)
+	require.NoError(t, err)
+	assert.Equal(t, 5, stats.RecordsOutput())
+
+	// The statistics are cached until those of the table are calculated again.
+	insertStatRecords(t, statMgr, layout, txn, "test_table", 10)
+	cached, err := statMgr.GetPartialStatInfo("test_table", layout, predicate, txn)
+	require.NoError(t, err)
+	assert.Same(t, stats, cached)
+
+	insertStatRecords(t, statMgr, layout, txn, "test_table", 1)
+	stats, err = statMgr.GetPartialStatInfo("test_table", layout, predicate, txn)
+	require.NoError(t, err)
+	assert.Equal(t, 5, stats.RecordsOutput())
+	assert.NotSame(t, cached, stats)
+
+	statMgr.InvalidateTable("test_table")
+	cached, err = statMgr.GetPartialStatInfo("test_table", layout, predicate, txn)
+	require.NoError(t, err)
+	assert.NotSame(t, stats, cached)
+}
+
+func TestStatMgr_BackgroundRefresh(t *testing.T) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lockTable := concurrency.NewLockTable()
+	newTransaction := func() *tx.Transaction { return tx.NewTransaction(fm, lm, bm, lockTable) }
+
+	txn := newTransaction()
+	tableManager, err := NewTableManager(true, txn)
+	require.NoError(t, err)
+	statMgr, err := NewStatManager(tableManager, txn, 0)
+	require.NoError(t, err)
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	require.NoError(t, tableManager.CreateTable("events", schema, txn))
+	layout, err := tableManager.GetLayout("events", txn)
+	require.NoError(t, err)
+	initial, err := statMgr.GetStatInfo("events", layout, txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	stop, err := statMgr.StartBackgroundRefresh(10*time.Millisecond, newTransaction)
+	require.NoError(t, err)
+	defer stop()
+	_, err = statMgr.StartBackgroundRefresh(10*time.Millisecond, newTransaction)
+	assert.Error(t, err)
+
+	// Queries keep reading the statistics they have until the goroutine calculates them again.
+	txn = newTransaction()
+	insertStatRecords(t, statMgr, layout, txn, "events", 20)
+	stats, err := statMgr.GetStatInfo("events", layout, txn)
+	require.NoError(t, err)
+	assert.Same(t, initial, stats)
+	require.NoError(t, txn.Commit())
+
+	assert.Eventually(t, func() bool {
+		txn := newTransaction()
+		defer func() { require.NoError(t, txn.Commit()) }()
+		stats, err := statMgr.GetStatInfo("events", layout, txn)
+		require.NoError(t, err)
+		return stats.RecordsOutput() == 20
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Once the goroutine is stopped, queries calculate the statistics of the changed tables again.
+	stop()
+	txn = newTransaction()
+	defer func() { require.NoError(t, txn.Commit()) }()
+	insertStatRecords(t, statMgr, layout, txn, "events", 5)
+	stats, err = statMgr.GetStatInfo("events", layout, txn)
+	require.NoError(t, err)
+	assert.Equal(t, 25, stats.RecordsOutput())
 }
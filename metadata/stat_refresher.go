@@ -0,0 +1,77 @@
+package metadata
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// statRefresher is a goroutine calculating the statistics of the changed tables again at a fixed interval.
+type statRefresher struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// StartBackgroundRefresh starts a goroutine that calculates again, at the specified interval, the statistics of
+// the tables changed beyond the threshold (see RefreshChangedTables), each time in a transaction returned by
+// newTransaction, which it commits. A refresh that fails is rolled back, and tried again at the next interval.
+// Until the goroutine is stopped by calling the returned function, GetStatInfo returns the statistics it has
+// even if they are out of date, so that queries never wait for the statistics of a table to be calculated,
+// unless it has none yet. It fails if the statistics are already refreshed in the background.
+func (sm *StatManager) StartBackgroundRefresh(interval time.Duration, newTransaction func() *tx.Transaction) (func(), error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.refresher != nil {
+		return nil, errors.New("statistics are already refreshed in the background")
+	}
+	refresher := &statRefresher{stop: make(chan struct{}), done: make(chan struct{})}
+	sm.refresher = refresher
+
+	go func() {
+		defer close(refresher.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-refresher.stop:
+				return
+			case <-ticker.C:
+				transaction := newTransaction()
+				if err := sm.RefreshChangedTables(transaction); err != nil {
+					_ = transaction.Rollback()
+				} else {
+					_ = transaction.Commit()
+				}
+			}
+		}
+	}()
+
+	stop := func() {
+		refresher.stopOnce.Do(func() {
+			close(refresher.stop)
+			<-refresher.done
+			sm.mu.Lock()
+			defer sm.mu.Unlock()
+			sm.refresher = nil
+		})
+	}
+	return stop, nil
+}
+
+// RefreshChangedTables calculates again the statistics of the tables having more changed records than the threshold
+// since their statistics were calculated, leaving the statistics of the other tables as they are.
+func (sm *StatManager) RefreshChangedTables(transaction *tx.Transaction) error {
+	for _, tableName := range sm.changedTables() {
+		layout, err := sm.tableManager.GetLayout(tableName, transaction)
+		if err != nil {
+			return err
+		}
+		if _, err := sm.refreshTable(tableName, layout, transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
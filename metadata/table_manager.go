@@ -1,7 +1,10 @@
 package metadata
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
+
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
@@ -9,18 +12,26 @@ import (
 )
 
 const (
-	maxNameLength  = 16
-	tableNameField = "table_name"
-	slotSizeField  = "slot_size"
-	fieldNameField = "field_name"
-	typeField      = "type"
-	lengthField    = "length"
-	offsetField    = "offset"
+	maxNameLength   = 16
+	tableNameField  = "table_name"
+	slotSizeField   = "slot_size"
+	fieldNameField  = "field_name"
+	typeField       = "type"
+	lengthField     = "length"
+	offsetField     = "offset"
+	compressedField = "compressed"
+	ordinalField    = "ordinal"
 
 	tableCatalogTable = "table_catalog"
 	fieldCatalogTable = "field_catalog"
 )
 
+// TableOptions holds the storage attributes of a table, which are recorded in the table catalog.
+type TableOptions struct {
+	// Compressed is true if the blocks of the table are stored compressed (see file.Manager#EnableCompression).
+	Compressed bool
+}
+
 // TableManager manages table data.
 // It has methods to create a atable, save the metadata in the catalog,
 // and obtain the metadata of a previously created table.
@@ -31,41 +42,117 @@ type TableManager struct {
 
 // NewTableManager creates a new TableManager.
 // This creates a new catalog manager for the database system.
-// If the database is new, the two catalog tables are created.
+// If the database is new, the two catalog tables are created. Otherwise, the catalog tables may have been created
+// before some of their fields were added, and are read with the layout they were created with (see catalogLayout).
 func NewTableManager(isNew bool, tx *tx.Transaction) (*TableManager, error) {
 	tm := &TableManager{}
 
-	tableCatalogSchema := record.NewSchema()
-	tableCatalogSchema.AddStringField(tableNameField, maxNameLength)
-	tableCatalogSchema.AddIntField(slotSizeField)
+	tableCatalogSchema := newTableCatalogSchema()
+	tableCatalogSchema.AddBoolField(compressedField)
 	tm.tableCatalogLayout = record.NewLayout(tableCatalogSchema)
 
-	fieldCatalogSchema := record.NewSchema()
-	fieldCatalogSchema.AddStringField(tableNameField, maxNameLength)
-	fieldCatalogSchema.AddStringField(fieldNameField, maxNameLength)
-	fieldCatalogSchema.AddIntField(typeField)
-	fieldCatalogSchema.AddIntField(lengthField)
-	fieldCatalogSchema.AddIntField(offsetField)
+	fieldCatalogSchema := newFieldCatalogSchema()
+	fieldCatalogSchema.AddIntField(ordinalField)
 	tm.fieldCatalogLayout = record.NewLayout(fieldCatalogSchema)
 
 	if isNew {
-		if err := tm.CreateTable(tableCatalogTable, tableCatalogSchema, tx); err != nil {
+		if err := tm.createTable(tableCatalogTable, tableCatalogSchema, TableOptions{}, tx); err != nil {
 			return nil, fmt.Errorf("failed to create table catalog: %w", err)
 		}
-		if err := tm.CreateTable(fieldCatalogTable, fieldCatalogSchema, tx); err != nil {
+		if err := tm.createTable(fieldCatalogTable, fieldCatalogSchema, TableOptions{}, tx); err != nil {
 			return nil, fmt.Errorf("failed to create field catalog: %w", err)
 		}
+		return tm, nil
 	}
 
+	// The fields added to the table catalog come after the others, so the record describing the table catalog
+	// itself, which is its first record, is read with the layout of its first fields whatever its actual layout.
+	currentLayout := tm.tableCatalogLayout
+	uncompressedLayout := record.NewLayout(newTableCatalogSchema())
+	tm.tableCatalogLayout = uncompressedLayout
+	var err error
+	if tm.tableCatalogLayout, err = tm.catalogLayout(tableCatalogTable, tx, currentLayout, uncompressedLayout); err != nil {
+		return nil, err
+	}
+	unorderedLayout := record.NewLayout(newFieldCatalogSchema())
+	if tm.fieldCatalogLayout, err = tm.catalogLayout(fieldCatalogTable, tx, tm.fieldCatalogLayout, unorderedLayout); err != nil {
+		return nil, err
+	}
 	return tm, nil
 }
 
+// newTableCatalogSchema returns the schema of the table catalogs created before tables could be compressed,
+// to which the table catalogs created since add the compressed field.
+func newTableCatalogSchema() *record.Schema {
+	schema := record.NewSchema()
+	schema.AddStringField(tableNameField, maxNameLength)
+	schema.AddIntField(slotSizeField)
+	return schema
+}
+
+// newFieldCatalogSchema returns the schema of the field catalogs created before fields had ordinals,
+// to which the field catalogs created since add the ordinal field.
+func newFieldCatalogSchema() *record.Schema {
+	schema := record.NewSchema()
+	schema.AddStringField(tableNameField, maxNameLength)
+	schema.AddStringField(fieldNameField, maxNameLength)
+	schema.AddIntField(typeField)
+	schema.AddIntField(lengthField)
+	schema.AddIntField(offsetField)
+	return schema
+}
+
+// catalogLayout returns the layout among the specified ones of the specified catalog table, which is the one
+// whose slot size the table catalog records for it. The catalog tables of a database created before fields
+// were added to them lack those fields.
+func (tm *TableManager) catalogLayout(tableName string, tx *tx.Transaction, layouts ...*record.Layout) (*record.Layout, error) {
+	size, err := tm.slotSize(tableName, tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, layout := range layouts {
+		if layout.SlotSize() == size {
+			return layout, nil
+		}
+	}
+	return nil, fmt.Errorf("catalog table %s has records of %d bytes, which none of its layouts has", tableName, size)
+}
+
 // CreateTable creates a new table having the specified name and schema.
 func (tm *TableManager) CreateTable(tableName string, schema *record.Schema, tx *tx.Transaction) error {
+	return tm.CreateTableWithOptions(tableName, schema, TableOptions{}, tx)
+}
+
+// CreateTableWithOptions creates a new table having the specified name, schema, and storage attributes.
+// It returns an error wrapping ErrInvalidName if the name of the table or of one of its fields is invalid.
+func (tm *TableManager) CreateTableWithOptions(tableName string, schema *record.Schema, options TableOptions, tx *tx.Transaction) error {
+	if err := checkFileName("table", tableName); err != nil {
+		return err
+	}
+	for _, fieldName := range schema.Fields() {
+		if err := checkName("field", fieldName); err != nil {
+			return err
+		}
+	}
+	return tm.createTable(tableName, schema, options, tx)
+}
+
+// createTable creates a new table like CreateTableWithOptions, without checking the names of the table and its
+// fields. The catalog tables are created with it, their names being longer than the names of the users may be.
+func (tm *TableManager) createTable(tableName string, schema *record.Schema, options TableOptions, tx *tx.Transaction) error {
 	layout := record.NewLayout(schema)
 
+	if options.Compressed && !tm.tableCatalogLayout.Schema().HasField(compressedField) {
+		return fmt.Errorf("table catalog does not support compressed tables")
+	}
+	if options.Compressed {
+		if err := table.EnableCompression(tx, tableName); err != nil {
+			return fmt.Errorf("failed to enable compression: %w", err)
+		}
+	}
+
 	// Insert the table into the table catalog
-	if err := tm.insertIntoTableCatalog(tx, tableName, layout); err != nil {
+	if err := tm.insertIntoTableCatalog(tx, tableName, layout, options); err != nil {
 		return fmt.Errorf("failed to insert into table catalog: %w", err)
 	}
 
@@ -78,7 +165,7 @@ func (tm *TableManager) CreateTable(tableName string, schema *record.Schema, tx
 }
 
 // insertIntoTableCatalog inserts a new record into the table catalog.
-func (tm *TableManager) insertIntoTableCatalog(tx *tx.Transaction, tableName string, layout *record.Layout) error {
+func (tm *TableManager) insertIntoTableCatalog(tx *tx.Transaction, tableName string, layout *record.Layout, options TableOptions) error {
 	tableCatalog, err := table.NewTableScan(tx, tableCatalogTable, tm.tableCatalogLayout)
 	if err != nil {
 		return err
@@ -94,10 +181,14 @@ func (tm *TableManager) insertIntoTableCatalog(tx *tx.Transaction, tableName str
 	if err := tableCatalog.SetInt(slotSizeField, layout.SlotSize()); err != nil {
 		return err
 	}
-	return nil
+	if !tm.tableCatalogLayout.Schema().HasField(compressedField) {
+		return nil
+	}
+	return tableCatalog.SetBool(compressedField, options.Compressed)
 }
 
-// insertIntoFieldCatalog inserts schema fields into the field catalog.
+// insertIntoFieldCatalog inserts schema fields into the field catalog,
+// recording the position of each field in the schema as its ordinal.
 func (tm *TableManager) insertIntoFieldCatalog(tx *tx.Transaction, tableName string, schema *record.Schema, layout *record.Layout) error {
 	fieldCatalog, err := table.NewTableScan(tx, fieldCatalogTable, tm.fieldCatalogLayout)
 	if err != nil {
@@ -105,7 +196,7 @@ func (tm *TableManager) insertIntoFieldCatalog(tx *tx.Transaction, tableName str
 	}
 	defer fieldCatalog.Close()
 
-	for _, field := range schema.Fields() {
+	for ordinal, field := range schema.Fields() {
 		if err := fieldCatalog.Insert(); err != nil {
 			return err
 		}
@@ -124,11 +215,62 @@ func (tm *TableManager) insertIntoFieldCatalog(tx *tx.Transaction, tableName str
 		if err := fieldCatalog.SetInt(offsetField, layout.Offset(field)); err != nil {
 			return err
 		}
+		if tm.fieldCatalogLayout.Schema().HasField(ordinalField) {
+			if err := fieldCatalog.SetInt(ordinalField, ordinal); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// DropTable removes the specified table from the table catalog, and its fields from the field catalog.
+// The removal is logged like any modification of the catalog, so it is undone if the transaction rolls back.
+// The file of the table is left alone.
+func (tm *TableManager) DropTable(tableName string, tx *tx.Transaction) error {
+	removed, err := tm.deleteCatalogRecords(tx, tableCatalogTable, tm.tableCatalogLayout, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to delete from table catalog: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("table %s not found", tableName)
+	}
+	if _, err := tm.deleteCatalogRecords(tx, fieldCatalogTable, tm.fieldCatalogLayout, tableName); err != nil {
+		return fmt.Errorf("failed to delete from field catalog: %w", err)
+	}
+	return nil
+}
+
+// deleteCatalogRecords deletes the records of the specified catalog table describing the specified table,
+// and returns the number of records deleted.
+func (tm *TableManager) deleteCatalogRecords(tx *tx.Transaction, catalogTable string, layout *record.Layout, tableName string) (int, error) {
+	catalog, err := table.NewTableScan(tx, catalogTable, layout)
+	if err != nil {
+		return 0, err
+	}
+	defer catalog.Close()
+
+	deleted := 0
+	for {
+		hasNext, err := catalog.Next()
+		if err != nil || !hasNext {
+			return deleted, err
+		}
+		currentTableName, err := catalog.GetString(tableNameField)
+		if err != nil {
+			return deleted, err
+		}
+		if currentTableName != tableName {
+			continue
+		}
+		if err := catalog.Delete(); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+}
+
 func (tm *TableManager) TableCatalogLayout() *record.Layout {
 	return tm.tableCatalogLayout
 }
@@ -137,47 +279,58 @@ func (tm *TableManager) FieldCatalogLayout() *record.Layout {
 	return tm.fieldCatalogLayout
 }
 
-// GetLayout returns the layout of the specified table from the catalog.
-func (tm *TableManager) GetLayout(tableName string, tx *tx.Transaction) (*record.Layout, error) {
-	size := -1
-
-	// Read the slot size from the table catalog
+// GetTableOptions returns the storage attributes of the specified table from the catalog.
+func (tm *TableManager) GetTableOptions(tableName string, tx *tx.Transaction) (TableOptions, error) {
 	tableCatalog, err := table.NewTableScan(tx, tableCatalogTable, tm.tableCatalogLayout)
 	if err != nil {
-		return nil, err
+		return TableOptions{}, err
 	}
 	defer tableCatalog.Close()
 
 	for {
 		hasNext, err := tableCatalog.Next()
 		if err != nil {
-			return nil, err
+			return TableOptions{}, err
 		}
 		if !hasNext {
-			// no more rows
-			break
+			return TableOptions{}, fmt.Errorf("table %s not found", tableName)
 		}
 
 		currentTableName, err := tableCatalog.GetString(tableNameField)
 		if err != nil {
-			return nil, err
+			return TableOptions{}, err
 		}
-
 		if currentTableName == tableName {
-			size, err = tableCatalog.GetInt(slotSizeField)
+			// Catalogs that predate compressed tables have no compressed field, and their tables are not compressed.
+			if !tm.tableCatalogLayout.Schema().HasField(compressedField) {
+				return TableOptions{}, nil
+			}
+			compressed, err := tableCatalog.GetBool(compressedField)
 			if err != nil {
-				return nil, err
+				return TableOptions{}, err
 			}
-			break
+			return TableOptions{Compressed: compressed}, nil
 		}
 	}
+}
 
-	if size < 0 {
-		return nil, fmt.Errorf("table %s not found", tableName)
+// GetLayout returns the layout of the specified table from the catalog.
+// The fields of its schema are in the order they were declared in, whatever the order of the field catalog records,
+// except in the catalogs that predate ordinals, where they are in the order of the records.
+func (tm *TableManager) GetLayout(tableName string, tx *tx.Transaction) (*record.Layout, error) {
+	size, err := tm.slotSize(tableName, tx)
+	if err != nil {
+		return nil, err
 	}
 
-	schema := record.NewSchema()
-	offsets := make(map[string]int)
+	type catalogField struct {
+		name      string
+		fieldType types.SchemaType
+		length    int
+		offset    int
+		ordinal   int
+	}
+	var fields []catalogField
 
 	// Read the fields from the field catalog
 	fieldCatalog, err := table.NewTableScan(tx, fieldCatalogTable, tm.fieldCatalogLayout)
@@ -224,9 +377,85 @@ func (tm *TableManager) GetLayout(tableName string, tx *tx.Transaction) (*record
 			return nil, err
 		}
 
-		schema.AddField(fieldName, types.SchemaType(fieldType), fieldLength)
-		offsets[fieldName] = fieldOffset
+		// Catalogs that predate ordinals have no ordinal field, and hold the fields of a table in their order.
+		fieldOrdinal := len(fields)
+		if tm.fieldCatalogLayout.Schema().HasField(ordinalField) {
+			if fieldOrdinal, err = fieldCatalog.GetInt(ordinalField); err != nil {
+				return nil, err
+			}
+		}
+
+		fields = append(fields, catalogField{
+			name:      fieldName,
+			fieldType: types.SchemaType(fieldType),
+			length:    fieldLength,
+			offset:    fieldOffset,
+			ordinal:   fieldOrdinal,
+		})
+	}
+
+	slices.SortFunc(fields, func(a, b catalogField) int {
+		return cmp.Compare(a.ordinal, b.ordinal)
+	})
+	schema := record.NewSchema()
+	offsets := make(map[string]int)
+	for _, field := range fields {
+		schema.AddField(field.name, field.fieldType, field.length)
+		offsets[field.name] = field.offset
 	}
 
 	return record.NewLayoutFromMetadata(schema, offsets, size), nil
 }
+
+// slotSize reads the slot size of the specified table from the table catalog.
+func (tm *TableManager) slotSize(tableName string, tx *tx.Transaction) (int, error) {
+	tableCatalog, err := table.NewTableScan(tx, tableCatalogTable, tm.tableCatalogLayout)
+	if err != nil {
+		return 0, err
+	}
+	defer tableCatalog.Close()
+
+	for {
+		hasNext, err := tableCatalog.Next()
+		if err != nil {
+			return 0, err
+		}
+		if !hasNext {
+			return 0, fmt.Errorf("table %s not found", tableName)
+		}
+
+		currentTableName, err := tableCatalog.GetString(tableNameField)
+		if err != nil {
+			return 0, err
+		}
+		if currentTableName == tableName {
+			return tableCatalog.GetInt(slotSizeField)
+		}
+	}
+}
+
+// TableNames returns the names of the tables recorded in the table catalog, in the order they were created,
+// including the catalog tables themselves.
+func (tm *TableManager) TableNames(tx *tx.Transaction) ([]string, error) {
+	tableCatalog, err := table.NewTableScan(tx, tableCatalogTable, tm.tableCatalogLayout)
+	if err != nil {
+		return nil, err
+	}
+	defer tableCatalog.Close()
+
+	var tableNames []string
+	for {
+		hasNext, err := tableCatalog.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			return tableNames, nil
+		}
+		tableName, err := tableCatalog.GetString(tableNameField)
+		if err != nil {
+			return nil, err
+		}
+		tableNames = append(tableNames, tableName)
+	}
+}
@@ -247,3 +247,232 @@ func TestTableManager_GetLayout(t *testing.T) {
 		}
 	}
 }
+
+func TestTableManager_CreateTableWithOptions(t *testing.T) {
+	tm, txn, cleanup := setupTestMetadata(400, t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddStringField("line", 40)
+	require.NoError(t, tm.CreateTableWithOptions("logs", schema, TableOptions{Compressed: true}, txn))
+	require.NoError(t, tm.CreateTable("plain", schema, txn))
+
+	options, err := tm.GetTableOptions("logs", txn)
+	require.NoError(t, err)
+	assert.Equal(t, TableOptions{Compressed: true}, options)
+	options, err = tm.GetTableOptions("plain", txn)
+	require.NoError(t, err)
+	assert.Equal(t, TableOptions{}, options)
+	_, err = tm.GetTableOptions("missing", txn)
+	assert.ErrorContains(t, err, "table missing not found")
+
+	// The records of the compressed table are read and written as in any other table.
+	layout, err := tm.GetLayout("logs", txn)
+	require.NoError(t, err)
+	ts, err := table.NewTableScan(txn, "logs", layout)
+	require.NoError(t, err)
+	defer ts.Close()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetString("line", "GET /index.html 200"))
+	}
+	require.NoError(t, ts.BeforeFirst())
+	count := 0
+	for {
+		next, err := ts.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		line, err := ts.GetString("line")
+		require.NoError(t, err)
+		assert.Equal(t, "GET /index.html 200", line)
+		count++
+	}
+	assert.Equal(t, 20, count)
+}
+
+func TestTableManager_GetLayoutOrdersFieldsByOrdinal(t *testing.T) {
+	tm, txn, cleanup := setupTestMetadata(400, t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("zeta")
+	schema.AddStringField("alpha", 10)
+	schema.AddBoolField("mid")
+	require.NoError(t, tm.CreateTable("scrambled", schema, txn))
+	expected, err := tm.GetLayout("scrambled", txn)
+	require.NoError(t, err)
+
+	// Rewrite the field catalog records of the table in reverse order, so that they are
+	// stored in an order other than the order of their fields.
+	fieldCatalog, err := table.NewTableScan(txn, fieldCatalogTable, tm.FieldCatalogLayout())
+	require.NoError(t, err)
+	defer fieldCatalog.Close()
+	type fieldRecord map[string]any
+	var records []fieldRecord
+	for {
+		next, err := fieldCatalog.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		tableName, err := fieldCatalog.GetString(tableNameField)
+		require.NoError(t, err)
+		if tableName != "scrambled" {
+			continue
+		}
+		rec := fieldRecord{}
+		for _, field := range tm.FieldCatalogLayout().Schema().Fields() {
+			rec[field], err = fieldCatalog.GetVal(field)
+			require.NoError(t, err)
+		}
+		records = append(records, rec)
+		require.NoError(t, fieldCatalog.Delete())
+	}
+	require.Len(t, records, 3)
+	require.NoError(t, fieldCatalog.BeforeFirst())
+	for i := len(records) - 1; i >= 0; i-- {
+		require.NoError(t, fieldCatalog.Insert())
+		for field, val := range records[i] {
+			require.NoError(t, fieldCatalog.SetVal(field, val))
+		}
+	}
+
+	layout, err := tm.GetLayout("scrambled", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zeta", "alpha", "mid"}, layout.Schema().Fields())
+	for _, field := range layout.Schema().Fields() {
+		assert.Equal(t, expected.Offset(field), layout.Offset(field), field)
+	}
+}
+
+func TestTableManager_CreateTableRejectsInvalidNames(t *testing.T) {
+	tm, txn, cleanup := setupTestMetadata(400, t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	assert.ErrorIs(t, tm.CreateTable("logs/2024", schema, txn), ErrInvalidName)
+	assert.ErrorIs(t, tm.CreateTable("aux", schema, txn), ErrInvalidName)
+
+	badField := record.NewSchema()
+	badField.AddIntField("id; drop")
+	assert.ErrorIs(t, tm.CreateTable("logs", badField, txn), ErrInvalidName)
+
+	// Neither table was recorded in the catalog.
+	for _, tableName := range []string{"logs/2024", "aux", "logs"} {
+		_, err := tm.GetTableOptions(tableName, txn)
+		assert.ErrorContains(t, err, "not found", tableName)
+	}
+}
+
+func TestTableManager_DropTable(t *testing.T) {
+	tm, txn, cleanup := setupTestMetadata(400, t)
+	defer cleanup()
+
+	for _, tableName := range []string{"users", "orders"} {
+		schema := record.NewSchema()
+		schema.AddIntField("id")
+		schema.AddStringField("name", 20)
+		require.NoError(t, tm.CreateTable(tableName, schema, txn))
+	}
+
+	require.NoError(t, tm.DropTable("users", txn))
+	_, err := tm.GetLayout("users", txn)
+	assert.ErrorContains(t, err, "table users not found")
+	tableNames, err := tm.TableNames(txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"table_catalog", "field_catalog", "orders"}, tableNames)
+
+	// The fields of the other table are kept, and those of the dropped table are gone.
+	layout, err := tm.GetLayout("orders", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name"}, layout.Schema().Fields())
+	require.NoError(t, tm.CreateTable("users", record.NewSchema(), txn))
+	layout, err = tm.GetLayout("users", txn)
+	require.NoError(t, err)
+	assert.Empty(t, layout.Schema().Fields())
+
+	assert.ErrorContains(t, tm.DropTable("missing", txn), "table missing not found")
+}
+
+func TestTableManager_CatalogWithoutCompressedField(t *testing.T) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	txn := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), concurrency.NewLockTable())
+	defer func() { require.NoError(t, txn.Commit()) }()
+
+	// A database whose table catalog was created before tables could be compressed.
+	current, _, cleanup := setupTestMetadata(400, t)
+	defer cleanup()
+	legacy := &TableManager{
+		tableCatalogLayout: record.NewLayout(newTableCatalogSchema()),
+		fieldCatalogLayout: current.FieldCatalogLayout(),
+	}
+	require.NoError(t, legacy.CreateTable(tableCatalogTable, newTableCatalogSchema(), txn))
+	require.NoError(t, legacy.CreateTable(fieldCatalogTable, current.FieldCatalogLayout().Schema(), txn))
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("name", 10)
+	require.NoError(t, legacy.CreateTable("users", schema, txn))
+
+	// It is read with the layout it was created with, and its tables are not compressed.
+	tm, err := NewTableManager(false, txn)
+	require.NoError(t, err)
+	assert.False(t, tm.TableCatalogLayout().Schema().HasField(compressedField))
+	layout, err := tm.GetLayout("users", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name"}, layout.Schema().Fields())
+	options, err := tm.GetTableOptions("users", txn)
+	require.NoError(t, err)
+	assert.Equal(t, TableOptions{}, options)
+
+	// Tables are still created in it, but cannot be compressed.
+	require.NoError(t, tm.CreateTable("plain", schema, txn))
+	_, err = tm.GetLayout("plain", txn)
+	require.NoError(t, err)
+	assert.ErrorContains(t, tm.CreateTableWithOptions("logs", schema, TableOptions{Compressed: true}, txn),
+		"table catalog does not support compressed tables")
+	tableNames, err := tm.TableNames(txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{tableCatalogTable, fieldCatalogTable, "users", "plain"}, tableNames)
+}
+
+func TestTableManager_CatalogWithoutOrdinalField(t *testing.T) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	txn := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), concurrency.NewLockTable())
+	defer func() { require.NoError(t, txn.Commit()) }()
+
+	// A database whose catalog was created before tables could be compressed and fields had ordinals.
+	legacy := &TableManager{
+		tableCatalogLayout: record.NewLayout(newTableCatalogSchema()),
+		fieldCatalogLayout: record.NewLayout(newFieldCatalogSchema()),
+	}
+	require.NoError(t, legacy.CreateTable(tableCatalogTable, newTableCatalogSchema(), txn))
+	require.NoError(t, legacy.CreateTable(fieldCatalogTable, newFieldCatalogSchema(), txn))
+	schema := record.NewSchema()
+	schema.AddIntField("zeta")
+	schema.AddStringField("alpha", 10)
+	schema.AddBoolField("mid")
+	require.NoError(t, legacy.CreateTable("users", schema, txn))
+
+	// Its fields are read in the order of the field catalog records, which is the order they were declared in.
+	tm, err := NewTableManager(false, txn)
+	require.NoError(t, err)
+	assert.False(t, tm.FieldCatalogLayout().Schema().HasField(ordinalField))
+	layout, err := tm.GetLayout("users", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zeta", "alpha", "mid"}, layout.Schema().Fields())
+	assert.Equal(t, record.NewLayout(schema).Offset("mid"), layout.Offset("mid"))
+
+	require.NoError(t, tm.CreateTable("plain", schema, txn))
+	layout, err = tm.GetLayout("plain", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zeta", "alpha", "mid"}, layout.Schema().Fields())
+}
@@ -25,7 +25,7 @@ func NewViewManager(isNew bool, tableManager *TableManager, tx *tx.Transaction)
 		schema := record.NewSchema()
 		schema.AddStringField(viewNameField, maxNameLength)
 		schema.AddStringField(viewDefinitionField, maxViewDefinitionLength)
-		if err := vm.tableManager.CreateTable(viewCatalogTable, schema, tx); err != nil {
+		if err := vm.tableManager.createTable(viewCatalogTable, schema, TableOptions{}, tx); err != nil {
 			return nil, err
 		}
 	}
@@ -34,7 +34,11 @@ func NewViewManager(isNew bool, tableManager *TableManager, tx *tx.Transaction)
 }
 
 // CreateView creates a view.
+// It returns an error wrapping ErrInvalidName if the name of the view is invalid.
 func (vm *ViewManager) CreateView(viewName, viewDefinition string, tx *tx.Transaction) error {
+	if err := checkName("view", viewName); err != nil {
+		return err
+	}
 	layout, err := vm.tableManager.GetLayout(viewCatalogTable, tx)
 	if err != nil {
 		return err
@@ -94,3 +98,44 @@ func (vm *ViewManager) GetViewDefinition(viewName string, tx *tx.Transaction) (s
 
 	return "", nil
 }
+
+// View is a view recorded in the view catalog.
+type View struct {
+	Name       string
+	Definition string
+}
+
+// Views returns the views recorded in the view catalog, in the order they were created.
+func (vm *ViewManager) Views(tx *tx.Transaction) ([]View, error) {
+	layout, err := vm.tableManager.GetLayout(viewCatalogTable, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	viewCatalogTableScan, err := table.NewTableScan(tx, viewCatalogTable, layout)
+	if err != nil {
+		return nil, err
+	}
+	defer viewCatalogTableScan.Close()
+
+	var views []View
+	for {
+		hasNext, err := viewCatalogTableScan.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			return views, nil
+		}
+
+		name, err := viewCatalogTableScan.GetString(viewNameField)
+		if err != nil {
+			return nil, err
+		}
+		definition, err := viewCatalogTableScan.GetString(viewDefinitionField)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, View{Name: name, Definition: definition})
+	}
+}
@@ -0,0 +1,17 @@
+package parse
+
+// CheckTableData holds the data of a CHECK TABLE statement,
+// which verifies that a table agrees with its indexes and its catalog entries.
+type CheckTableData struct {
+	tableName string
+}
+
+func NewCheckTableData(tableName string) *CheckTableData {
+	return &CheckTableData{
+		tableName: tableName,
+	}
+}
+
+func (ctd *CheckTableData) TableName() string {
+	return ctd.tableName
+}
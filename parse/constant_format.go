@@ -0,0 +1,55 @@
+package parse
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateConstantLayout is the layout of the date constants written by FormatConstant, which the lexer reads back.
+const dateConstantLayout = "2006-01-02 15:04:05"
+
+// FormatConstant returns the text of a constant that the parser reads as the specified value:
+// strings are quoted, doubling the quotes they contain, and dates are written in UTC to the second,
+// which is how precisely they are stored, floats always have a fraction, so that they are not read as integers,
+// and nil is null. It returns an error for a value no constant stands for.
+func FormatConstant(val any) (string, error) {
+	switch v := val.(type) {
+	case nil:
+		return "null", nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int16:
+		return strconv.Itoa(int(v)), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return formatFloat(v)
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.UTC().Format(dateConstantLayout), nil
+	default:
+		return "", fmt.Errorf("no constant stands for %v of type %T", val, val)
+	}
+}
+
+// formatFloat returns the shortest text of the float that reads back as the same value,
+// with a fraction even if the float is integral (e.g. "20.0" or "1.0e+21").
+func formatFloat(v float64) (string, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", fmt.Errorf("no constant stands for %v", v)
+	}
+	text := strconv.FormatFloat(v, 'g', -1, 64)
+	if strings.ContainsRune(text, '.') {
+		return text, nil
+	}
+	if exponent := strings.IndexRune(text, 'e'); exponent >= 0 {
+		return text[:exponent] + ".0" + text[exponent:], nil
+	}
+	return text + ".0", nil
+}
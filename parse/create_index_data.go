@@ -3,14 +3,41 @@ package parse
 type CreateIndexData struct {
 	indexName string
 	tableName string
-	fieldName string
+	// fieldNames are the indexed fields, of which a composite index has several.
+	fieldNames []string
+	predicate  string
+	unique     bool
+	indexType  string
 }
 
 func NewCreateIndexData(indexName, tableName, fieldName string) *CreateIndexData {
 	return &CreateIndexData{
-		indexName: indexName,
-		tableName: tableName,
-		fieldName: fieldName,
+		indexName:  indexName,
+		tableName:  tableName,
+		fieldNames: []string{fieldName},
+	}
+}
+
+// NewCreatePartialIndexData creates the data for an index that only
+// contains the records satisfying the specified predicate.
+func NewCreatePartialIndexData(indexName, tableName, fieldName, predicate string) *CreateIndexData {
+	return &CreateIndexData{
+		indexName:  indexName,
+		tableName:  tableName,
+		fieldNames: []string{fieldName},
+		predicate:  predicate,
+	}
+}
+
+// NewCreateUniqueIndexData creates the data for an index in which no two records
+// may have the same value of the indexed field. An empty predicate covers the whole table.
+func NewCreateUniqueIndexData(indexName, tableName, fieldName, predicate string) *CreateIndexData {
+	return &CreateIndexData{
+		indexName:  indexName,
+		tableName:  tableName,
+		fieldNames: []string{fieldName},
+		predicate:  predicate,
+		unique:     true,
 	}
 }
 
@@ -22,6 +49,42 @@ func (cid *CreateIndexData) TableName() string {
 	return cid.tableName
 }
 
+// FieldName returns the indexed field, or the first indexed field of a composite index.
 func (cid *CreateIndexData) FieldName() string {
-	return cid.fieldName
+	return cid.fieldNames[0]
+}
+
+// FieldNames returns the indexed fields, in the order of the index.
+func (cid *CreateIndexData) FieldNames() []string {
+	return cid.fieldNames
+}
+
+// WithFields sets the indexed fields of a composite index, in the order of the index, and returns the data.
+func (cid *CreateIndexData) WithFields(fieldNames []string) *CreateIndexData {
+	cid.fieldNames = fieldNames
+	return cid
+}
+
+// Predicate returns the text of the partial index predicate,
+// or an empty string if the index covers the whole table.
+func (cid *CreateIndexData) Predicate() string {
+	return cid.predicate
+}
+
+// Unique returns true if no two records in the index may have the same value of the indexed field.
+func (cid *CreateIndexData) Unique() bool {
+	return cid.unique
+}
+
+// Using sets the type of the index, such as "hash" or "btree", and returns the data.
+// An empty type leaves the choice of the type to the database.
+func (cid *CreateIndexData) Using(indexType string) *CreateIndexData {
+	cid.indexType = indexType
+	return cid
+}
+
+// IndexType returns the type of the index named in the USING clause,
+// or an empty string if the statement does not name one.
+func (cid *CreateIndexData) IndexType() string {
+	return cid.indexType
 }
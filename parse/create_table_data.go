@@ -3,14 +3,22 @@ package parse
 import "github.com/JyotinderSingh/dropdb/record"
 
 type CreateTableData struct {
-	tableName string
-	schema    *record.Schema
+	tableName  string
+	schema     *record.Schema
+	compressed bool
 }
 
 func NewCreateTableData(tableName string, sch *record.Schema) *CreateTableData {
+	return NewCompressedCreateTableData(tableName, sch, false)
+}
+
+// NewCompressedCreateTableData creates the data for a create table statement,
+// which stores the blocks of the table compressed if compressed is true ("WITH COMPRESSION").
+func NewCompressedCreateTableData(tableName string, sch *record.Schema, compressed bool) *CreateTableData {
 	return &CreateTableData{
-		tableName: tableName,
-		schema:    sch,
+		tableName:  tableName,
+		schema:     sch,
+		compressed: compressed,
 	}
 }
 
@@ -21,3 +29,8 @@ func (ctd *CreateTableData) TableName() string {
 func (ctd *CreateTableData) NewSchema() *record.Schema {
 	return ctd.schema
 }
+
+// Compressed returns true if the blocks of the table are stored compressed.
+func (ctd *CreateTableData) Compressed() bool {
+	return ctd.compressed
+}
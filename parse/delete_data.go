@@ -2,15 +2,26 @@ package parse
 
 import "github.com/JyotinderSingh/dropdb/query"
 
+// NoLimit is the limit of a statement without a LIMIT clause.
+const NoLimit = -1
+
 type DeleteData struct {
 	tableName string
 	predicate *query.Predicate
+	limit     int
 }
 
 func NewDeleteData(tableName string, predicate *query.Predicate) *DeleteData {
+	return NewLimitedDeleteData(tableName, predicate, NoLimit)
+}
+
+// NewLimitedDeleteData creates the data for a delete statement that
+// deletes at most limit of the records satisfying the predicate.
+func NewLimitedDeleteData(tableName string, predicate *query.Predicate, limit int) *DeleteData {
 	return &DeleteData{
 		tableName: tableName,
 		predicate: predicate,
+		limit:     limit,
 	}
 }
 
@@ -21,3 +32,9 @@ func (dd *DeleteData) TableName() string {
 func (dd *DeleteData) Predicate() *query.Predicate {
 	return dd.predicate
 }
+
+// Limit returns the maximum number of records to delete, or NoLimit.
+// Which of the matching records are deleted when there are more than the limit is unspecified.
+func (dd *DeleteData) Limit() int {
+	return dd.limit
+}
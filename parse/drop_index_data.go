@@ -0,0 +1,17 @@
+package parse
+
+// DropIndexData holds the data of a DROP INDEX statement,
+// which removes an index from the database.
+type DropIndexData struct {
+	indexName string
+}
+
+func NewDropIndexData(indexName string) *DropIndexData {
+	return &DropIndexData{
+		indexName: indexName,
+	}
+}
+
+func (did *DropIndexData) IndexName() string {
+	return did.indexName
+}
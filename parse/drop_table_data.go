@@ -0,0 +1,17 @@
+package parse
+
+// DropTableData holds the data of a DROP TABLE statement,
+// which removes a table and its indexes from the database.
+type DropTableData struct {
+	tableName string
+}
+
+func NewDropTableData(tableName string) *DropTableData {
+	return &DropTableData{
+		tableName: tableName,
+	}
+}
+
+func (dtd *DropTableData) TableName() string {
+	return dtd.tableName
+}
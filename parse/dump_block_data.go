@@ -0,0 +1,23 @@
+package parse
+
+// DumpBlockData holds the data of a DUMP BLOCK statement, which inspects
+// the physical contents of a block of a table.
+type DumpBlockData struct {
+	tableName   string
+	blockNumber int
+}
+
+func NewDumpBlockData(tableName string, blockNumber int) *DumpBlockData {
+	return &DumpBlockData{
+		tableName:   tableName,
+		blockNumber: blockNumber,
+	}
+}
+
+func (dbd *DumpBlockData) TableName() string {
+	return dbd.tableName
+}
+
+func (dbd *DumpBlockData) BlockNumber() int {
+	return dbd.blockNumber
+}
@@ -0,0 +1,18 @@
+package parse
+
+// DumpDatabaseData holds the data of a DUMP DATABASE statement, which writes
+// the statements recreating the database to a file.
+type DumpDatabaseData struct {
+	fileName string
+}
+
+func NewDumpDatabaseData(fileName string) *DumpDatabaseData {
+	return &DumpDatabaseData{
+		fileName: fileName,
+	}
+}
+
+// FileName returns the name of the file the statements are written to.
+func (ddd *DumpDatabaseData) FileName() string {
+	return ddd.fileName
+}
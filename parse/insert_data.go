@@ -1,9 +1,13 @@
 package parse
 
+import "github.com/JyotinderSingh/dropdb/query"
+
 type InsertData struct {
-	tableName string
-	fields    []string
-	values    []any
+	tableName  string
+	fields     []string
+	values     []any
+	rows       [][]any
+	onConflict *OnConflictData
 }
 
 func NewInsertData(tableName string, fields []string, values []any) *InsertData {
@@ -14,6 +18,29 @@ func NewInsertData(tableName string, fields []string, values []any) *InsertData
 	}
 }
 
+// NewUpsertData creates the data for an insert statement that takes the specified
+// action instead of inserting the record when it conflicts with an existing record.
+func NewUpsertData(tableName string, fields []string, values []any, onConflict *OnConflictData) *InsertData {
+	return &InsertData{
+		tableName:  tableName,
+		fields:     fields,
+		values:     values,
+		onConflict: onConflict,
+	}
+}
+
+// NewInsertRowsData creates the data for an insert statement inserting several records, one for each row of
+// its VALUES list, with an optional ON CONFLICT clause applying to each of them.
+func NewInsertRowsData(tableName string, fields []string, rows [][]any, onConflict *OnConflictData) *InsertData {
+	return &InsertData{
+		tableName:  tableName,
+		fields:     fields,
+		values:     rows[0],
+		rows:       rows,
+		onConflict: onConflict,
+	}
+}
+
 func (id *InsertData) TableName() string {
 	return id.tableName
 }
@@ -22,6 +49,94 @@ func (id *InsertData) Fields() []string {
 	return id.fields
 }
 
+// Values returns the values of the inserted record, or of the first record of an insert of several records (see Rows).
 func (id *InsertData) Values() []any {
 	return id.values
 }
+
+// Rows returns the values of each record inserted by the statement, in the order of its VALUES list.
+func (id *InsertData) Rows() [][]any {
+	if id.rows == nil {
+		return [][]any{id.values}
+	}
+	return id.rows
+}
+
+// RowData returns the data for the insert of a single record of the statement, with the specified values,
+// into the same fields and with the same ON CONFLICT clause.
+func (id *InsertData) RowData(values []any) *InsertData {
+	return &InsertData{
+		tableName:  id.tableName,
+		fields:     id.fields,
+		values:     values,
+		onConflict: id.onConflict,
+	}
+}
+
+// OnConflict returns what the insert does when the record conflicts with an existing record,
+// or nil if the insert has no ON CONFLICT clause.
+func (id *InsertData) OnConflict() *OnConflictData {
+	return id.onConflict
+}
+
+// ConflictAction is what an insert does instead of inserting a conflicting record.
+type ConflictAction int
+
+const (
+	// DoNothing skips the record.
+	DoNothing ConflictAction = iota
+	// DoUpdate modifies the existing record, applying the assignments of the ON CONFLICT clause.
+	DoUpdate
+)
+
+// OnConflictData is the ON CONFLICT clause of an insert statement.
+// A record conflicts with an existing record having the same value of a field with a unique index:
+// the conflict target, or any such field if the clause has no target.
+type OnConflictData struct {
+	targetField string
+	action      ConflictAction
+	assignments []Assignment
+}
+
+// NewOnConflictData creates an ON CONFLICT clause. An empty target field matches the conflicts
+// on any unique index, and the assignments are only used by the DoUpdate action.
+func NewOnConflictData(targetField string, action ConflictAction, assignments []Assignment) *OnConflictData {
+	return &OnConflictData{
+		targetField: targetField,
+		action:      action,
+		assignments: assignments,
+	}
+}
+
+// TargetField returns the field whose unique index detects the conflicts,
+// or an empty string if the conflicts are detected on any unique index.
+func (ocd *OnConflictData) TargetField() string {
+	return ocd.targetField
+}
+
+func (ocd *OnConflictData) Action() ConflictAction {
+	return ocd.action
+}
+
+// Assignments returns the modifications applied to the existing record by the DoUpdate action.
+func (ocd *OnConflictData) Assignments() []Assignment {
+	return ocd.assignments
+}
+
+// Assignment sets a field to the value of an expression, such as "name = 'Alice'" in a SET list.
+type Assignment struct {
+	fieldName string
+	value     *query.Expression
+}
+
+func NewAssignment(fieldName string, value *query.Expression) Assignment {
+	return Assignment{fieldName: fieldName, value: value}
+}
+
+func (a Assignment) FieldName() string {
+	return a.fieldName
+}
+
+func (a Assignment) Value() *query.Expression {
+	return a.value
+}
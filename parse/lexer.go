@@ -2,6 +2,7 @@
 package parse
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -25,11 +26,13 @@ type TokenType int
 const (
 	TTDelimiter TokenType = iota
 	TTNumber
+	TTFloat
 	TTString
 	TTWord
 	TTBoolean
 	TTDate
 	TTOperator
+	TTParameter
 	TTEOF
 )
 
@@ -38,22 +41,49 @@ type Token struct {
 	Type      TokenType
 	StringVal string    // for string/word tokens or operator text
 	NumVal    int       // for integer tokens
+	FloatVal  float64   // for float tokens
 	BoolVal   bool      // for boolean tokens
 	TimeVal   time.Time // for date tokens
 	Rune      rune      // for delimiter tokens (e.g. ',', '(', ')', ...)
+	// for parameter tokens, StringVal holds the name of a named parameter (":name" or "@name"),
+	// and NumVal the 1-based position of a positional parameter ("?") among the positional parameters.
+}
+
+// LexerLimits bounds the lengths of the tokens a lexer accepts, so that an adversarial statement
+// cannot make it allocate unbounded memory. A token exceeding its limit is a syntax error.
+type LexerLimits struct {
+	// MaxIdentifierLength is the maximum length in bytes of an identifier, keyword, parameter name or number.
+	MaxIdentifierLength int
+	// MaxStringLength is the maximum length in bytes of a string constant, without its quotes.
+	MaxStringLength int
+}
+
+// DefaultLexerLimits are the limits of the lexers created by NewLexer.
+var DefaultLexerLimits = LexerLimits{
+	MaxIdentifierLength: 4 << 10,
+	MaxStringLength:     1 << 20,
 }
 
 // Lexer processes an input string and produces tokens on demand.
 type Lexer struct {
 	input        string
 	position     int
+	tokenStart   int // position in input where the current token starts
 	currentToken Token
 	keywords     map[string]struct{}
+	limits       LexerLimits
+	// positionalParameters is the number of positional parameters scanned so far.
+	positionalParameters int
 }
 
-// NewLexer creates a new Lexer from the given SQL statement.
+// NewLexer creates a new Lexer from the given SQL statement, with the DefaultLexerLimits.
 func NewLexer(s string) *Lexer {
-	l := &Lexer{input: s}
+	return NewLexerWithLimits(s, DefaultLexerLimits)
+}
+
+// NewLexerWithLimits creates a new Lexer from the given SQL statement, accepting the tokens within the specified limits.
+func NewLexerWithLimits(s string, limits LexerLimits) *Lexer {
+	l := &Lexer{input: s, limits: limits}
 	l.initKeywords()
 	_ = l.nextToken() // Get the first token (ignore error here, or handle it)
 	return l
@@ -73,6 +103,11 @@ func (l *Lexer) MatchIntConstant() bool {
 	return l.currentToken.Type == TTNumber
 }
 
+// MatchFloatConstant returns true if the current token is a float, a number with a fraction such as 19.99.
+func (l *Lexer) MatchFloatConstant() bool {
+	return l.currentToken.Type == TTFloat
+}
+
 // MatchStringConstant returns true if the current token is a string constant.
 func (l *Lexer) MatchStringConstant() bool {
 	return l.currentToken.Type == TTString
@@ -84,14 +119,29 @@ func (l *Lexer) MatchKeyword(w string) bool {
 }
 
 // MatchId returns true if the current token is a legal identifier (non-keyword).
+// Aggregate function names are only reserved when they are followed by '(',
+// so they can still be used as field names (e.g. a column called "count").
 func (l *Lexer) MatchId() bool {
 	if l.currentToken.Type != TTWord {
 		return false
 	}
+	if _, isAggregate := aggregateFunctions[l.currentToken.StringVal]; isAggregate {
+		return !l.peekDelim('(')
+	}
 	_, isKeyword := l.keywords[l.currentToken.StringVal]
 	return !isKeyword
 }
 
+// MatchAggregate returns true if the current token is an aggregate function
+// name (max, min, count, avg, sum, approx_count_distinct) followed by an opening parenthesis.
+func (l *Lexer) MatchAggregate() bool {
+	if l.currentToken.Type != TTWord {
+		return false
+	}
+	_, isAggregate := aggregateFunctions[l.currentToken.StringVal]
+	return isAggregate && l.peekDelim('(')
+}
+
 // MatchBooleanConstant returns true if the current token is a boolean (true/false).
 func (l *Lexer) MatchBooleanConstant() bool {
 	return l.currentToken.Type == TTBoolean
@@ -102,6 +152,17 @@ func (l *Lexer) MatchDateConstant() bool {
 	return l.currentToken.Type == TTDate
 }
 
+// MatchParameter returns true if the current token is a statement parameter,
+// either positional ("?") or named (":name" or "@name").
+func (l *Lexer) MatchParameter() bool {
+	return l.currentToken.Type == TTParameter
+}
+
+// MatchEOF returns true if the entire input has been consumed.
+func (l *Lexer) MatchEOF() bool {
+	return l.currentToken.Type == TTEOF
+}
+
 // MatchOperator returns true if the current token is an operator (e.g. "=", ">=", etc.).
 func (l *Lexer) MatchOperator(op string) bool {
 	return l.currentToken.Type == TTOperator && l.currentToken.StringVal == op
@@ -129,6 +190,17 @@ func (l *Lexer) EatIntConstant() (int, error) {
 	return val, nil
 }
 
+func (l *Lexer) EatFloatConstant() (float64, error) {
+	if !l.MatchFloatConstant() {
+		return 0, &SyntaxError{Message: "expected float constant"}
+	}
+	val := l.currentToken.FloatVal
+	if err := l.nextToken(); err != nil {
+		return 0, err
+	}
+	return val, nil
+}
+
 func (l *Lexer) EatStringConstant() (string, error) {
 	if !l.MatchStringConstant() {
 		return "", &SyntaxError{Message: "expected string constant"}
@@ -180,6 +252,19 @@ func (l *Lexer) EatDateConstant() (time.Time, error) {
 	return val, nil
 }
 
+// EatParameter returns the name of the current named parameter, or an empty name
+// and the position of the current positional parameter.
+func (l *Lexer) EatParameter() (string, int, error) {
+	if !l.MatchParameter() {
+		return "", 0, &SyntaxError{Message: "expected parameter"}
+	}
+	name, position := l.currentToken.StringVal, l.currentToken.NumVal
+	if err := l.nextToken(); err != nil {
+		return "", 0, err
+	}
+	return name, position, nil
+}
+
 // EatOperator checks if the current token is the specified operator.
 // If so, it advances the lexer; otherwise returns an error.
 func (l *Lexer) EatOperator(op string) error {
@@ -197,6 +282,7 @@ func (l *Lexer) EatOperator(op string) error {
 // It returns an error if there's an unexpected character or parse failure.
 func (l *Lexer) nextToken() error {
 	l.skipWhitespace()
+	l.tokenStart = l.position
 	if l.position >= len(l.input) {
 		l.currentToken = Token{Type: TTEOF}
 		return nil // end of input is not an error
@@ -224,6 +310,26 @@ func (l *Lexer) nextToken() error {
 		l.currentToken = Token{Type: TTString, StringVal: strVal}
 		return nil
 
+	// Positional parameter
+	case r == '?':
+		l.position += width
+		l.positionalParameters++
+		l.currentToken = Token{Type: TTParameter, NumVal: l.positionalParameters}
+		return nil
+
+	// Named parameter, whose name is kept as written
+	case r == ':' || r == '@':
+		l.position += width
+		name, err := l.scanWord()
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return &SyntaxError{Message: fmt.Sprintf("expected parameter name after '%c'", r)}
+		}
+		l.currentToken = Token{Type: TTParameter, StringVal: name}
+		return nil
+
 	// Delimiter? (commas, parentheses, semicolons, etc.)
 	case isDelimiter(r):
 		l.position += width
@@ -241,16 +347,26 @@ func (l *Lexer) nextToken() error {
 			l.position += width
 		}
 		tokenStr := l.input[start:l.position]
+		if isDigits(tokenStr) && l.matchFraction() {
+			return l.scanFloat(start)
+		}
 		if t, err := parseDate(tokenStr); err == nil {
 			l.currentToken = Token{Type: TTDate, TimeVal: t}
 			return nil
 		} else {
-			// Fall back to number parsing
-			// strip any whitespace
-			tokenStr = strings.ReplaceAll(tokenStr, " ", "")
+			// Fall back to the integer of the leading digits, leaving what follows them,
+			// such as the "- 3" of "12 - 3", to the next tokens.
+			l.position = start
+			l.skipDigits()
+			tokenStr = l.input[start:l.position]
+			if len(tokenStr) > l.limits.MaxIdentifierLength {
+				return &SyntaxError{Message: fmt.Sprintf("numeric constant exceeds %d bytes", l.limits.MaxIdentifierLength)}
+			}
 			if n, err := strconv.Atoi(tokenStr); err == nil {
 				l.currentToken = Token{Type: TTNumber, NumVal: n}
 				return nil
+			} else if errors.Is(err, strconv.ErrRange) {
+				return &SyntaxError{Message: fmt.Sprintf("integer constant %s is out of range for type int", tokenStr)}
 			} else {
 				return &SyntaxError{Message: fmt.Sprintf("invalid token: '%s'", tokenStr)}
 			}
@@ -258,7 +374,10 @@ func (l *Lexer) nextToken() error {
 
 	// Letter/underscore => could be boolean, date, or identifier/keyword
 	case unicode.IsLetter(r) || r == '_':
-		wordVal := l.scanWord()
+		wordVal, err := l.scanWord()
+		if err != nil {
+			return err
+		}
 		wordValLower := strings.ToLower(wordVal)
 
 		// Check for boolean: "true" or "false"
@@ -279,6 +398,58 @@ func (l *Lexer) nextToken() error {
 	}
 }
 
+// isDigits returns true if the string is a non-empty run of decimal digits.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// matchFraction returns true if the input at the current position is a '.' followed by a digit,
+// which continues the digits before it as a float constant (e.g. "19.99").
+func (l *Lexer) matchFraction() bool {
+	return l.position+1 < len(l.input) && l.input[l.position] == '.' && unicode.IsDigit(rune(l.input[l.position+1]))
+}
+
+// scanFloat scans the fraction and the optional exponent of the float constant starting at the specified position,
+// whose integer part has been scanned, e.g. "19.99" or "1.5e-3".
+func (l *Lexer) scanFloat(start int) error {
+	l.position++ // the '.'
+	l.skipDigits()
+	if l.position < len(l.input) && (l.input[l.position] == 'e' || l.input[l.position] == 'E') {
+		exponent := l.position + 1
+		if exponent < len(l.input) && (l.input[exponent] == '+' || l.input[exponent] == '-') {
+			exponent++
+		}
+		if exponent < len(l.input) && unicode.IsDigit(rune(l.input[exponent])) {
+			l.position = exponent
+			l.skipDigits()
+		}
+	}
+	tokenStr := l.input[start:l.position]
+	if l.position-start > l.limits.MaxIdentifierLength {
+		return &SyntaxError{Message: fmt.Sprintf("numeric constant exceeds %d bytes", l.limits.MaxIdentifierLength)}
+	}
+	f, err := strconv.ParseFloat(tokenStr, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return &SyntaxError{Message: fmt.Sprintf("float constant %s is out of range", tokenStr)}
+	} else if err != nil {
+		return &SyntaxError{Message: fmt.Sprintf("invalid token: '%s'", tokenStr)}
+	}
+	l.currentToken = Token{Type: TTFloat, FloatVal: f}
+	return nil
+}
+
+// skipDigits advances the position past a run of decimal digits.
+func (l *Lexer) skipDigits() {
+	for l.position < len(l.input) && unicode.IsDigit(rune(l.input[l.position])) {
+		l.position++
+	}
+}
+
 // scanOperator checks for either single- or multi-character operators
 // like '=', '>', '<', '>=', '<=', '!=', '<>', etc.
 func (l *Lexer) scanOperator() (string, error) {
@@ -301,19 +472,26 @@ func (l *Lexer) scanOperator() (string, error) {
 	return string(r), nil
 }
 
-// scanString scans a single-quoted string literal.
-// Returns the string value (without quotes), or an error if unterminated.
+// scanString scans a single-quoted string literal, in which two consecutive quotes stand for one quote.
+// Returns the string value (without quotes), or an error if unterminated or longer than the limit.
 func (l *Lexer) scanString() (string, error) {
 	l.position++ // consume the quote
 	var sb strings.Builder
 
 	for l.position < len(l.input) {
 		r, width := utf8.DecodeRuneInString(l.input[l.position:])
-		if r == '\'' {
+		if r == '\'' && !strings.HasPrefix(l.input[l.position+width:], "'") {
 			// Found the closing quote
 			l.position += width
 			return sb.String(), nil
 		}
+		if r == '\'' {
+			// Skip the first quote of an escaped quote
+			l.position += width
+		}
+		if sb.Len()+width > l.limits.MaxStringLength {
+			return "", &SyntaxError{Message: fmt.Sprintf("string constant exceeds %d bytes", l.limits.MaxStringLength)}
+		}
 		sb.WriteRune(r)
 		l.position += width
 	}
@@ -321,16 +499,44 @@ func (l *Lexer) scanString() (string, error) {
 }
 
 // scanWord scans an identifier-like token (letters, digits, underscores).
-func (l *Lexer) scanWord() string {
+// Returns an error if the token is longer than the limit.
+func (l *Lexer) scanWord() (string, error) {
 	start := l.position
 	for l.position < len(l.input) {
 		r, width := utf8.DecodeRuneInString(l.input[l.position:])
-		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+		if !IsIdentifierRune(r) {
 			break
 		}
 		l.position += width
+		if l.position-start > l.limits.MaxIdentifierLength {
+			return "", &SyntaxError{Message: fmt.Sprintf("identifier exceeds %d bytes", l.limits.MaxIdentifierLength)}
+		}
 	}
-	return l.input[start:l.position]
+	return l.input[start:l.position], nil
+}
+
+// IsIdentifierRune reports whether the rune may appear in an identifier: a letter, a digit or an underscore.
+func IsIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// peekDelim reports whether the next non-whitespace character after the
+// current token is the specified delimiter, without consuming any input.
+func (l *Lexer) peekDelim(d rune) bool {
+	for pos := l.position; pos < len(l.input); {
+		r, width := utf8.DecodeRuneInString(l.input[pos:])
+		if !unicode.IsSpace(r) {
+			return r == d
+		}
+		pos += width
+	}
+	return false
+}
+
+// sourceFrom returns the input text from the specified position up to,
+// but not including, the current token.
+func (l *Lexer) sourceFrom(start int) string {
+	return strings.TrimSpace(l.input[start:l.tokenStart])
 }
 
 // skipWhitespace advances over any sequence of whitespace.
@@ -344,16 +550,22 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// aggregateFunctions is the set of aggregate function names recognized by the parser.
+var aggregateFunctions = map[string]struct{}{
+	"max": {}, "min": {}, "count": {}, "avg": {}, "sum": {},
+	"approx_count_distinct": {},
+}
+
 // initKeywords initializes the set of SQL keywords, in lowercase.
 func (l *Lexer) initKeywords() {
 	kwList := []string{
-		"select", "from", "where", "and",
+		"select", "from", "where", "and", "or", "not", "is", "null", "like", "in", "between",
 		"insert", "into", "values", "delete", "update", "set",
-		"create", "table", "int", "varchar", "view", "as", "index", "on",
+		"create", "table", "int", "float", "varchar", "view", "as", "index", "on",
 		// Add new keywords
-		"group", "by", "having", "order", "asc", "desc",
+		"group", "by", "having", "order", "asc", "desc", "limit", "offset",
 		// Add aggregate function keywords
-		"max", "min", "count", "avg", "sum",
+		"max", "min", "count", "avg", "sum", "approx_count_distinct",
 	}
 	l.keywords = make(map[string]struct{}, len(kwList))
 	for _, kw := range kwList {
@@ -393,9 +605,9 @@ func isOperatorStart(r rune) bool {
 // isDelimiter checks if a rune is treated as a single-character delimiter.
 // (Operators are handled separately, in isOperatorStart/scanOperator.)
 func isDelimiter(r rune) bool {
-	// e.g. commas, parentheses, semicolons, plus, minus, period...
+	// e.g. commas, parentheses, semicolons, plus, minus, period, asterisk, slash...
 	// We deliberately *exclude* <, >, =, ! so we can handle multi-char operators.
-	delimiters := []rune{',', '(', ')', '.', ';', '+', '-'}
+	delimiters := []rune{',', '(', ')', '.', ';', '+', '-', '*', '/'}
 	for _, d := range delimiters {
 		if r == d {
 			return true
@@ -2,6 +2,8 @@ package parse
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
 	"time"
 )
@@ -54,6 +56,39 @@ func TestLexer_EatIntConstant(t *testing.T) {
 	assert.Equal(t, 42, val, "Expected value to be 42")
 }
 
+func TestLexer_ArithmeticOperators(t *testing.T) {
+	// The digits of an integer followed by a minus are not read as a date.
+	lexer := NewLexer("12 - 3/4")
+	for _, expected := range []any{12, '-', 3, '/', 4} {
+		if n, ok := expected.(int); ok {
+			val, err := lexer.EatIntConstant()
+			require.NoError(t, err)
+			assert.Equal(t, n, val)
+		} else {
+			require.NoError(t, lexer.EatDelim(expected.(rune)))
+		}
+	}
+	assert.Equal(t, TTEOF, lexer.currentToken.Type)
+}
+
+func TestLexer_EatFloatConstant(t *testing.T) {
+	for text, expected := range map[string]float64{"19.99": 19.99, "0.5": 0.5, "1.5e3": 1500, "2.5E-2": 0.025} {
+		lexer := NewLexer(text)
+		assert.True(t, lexer.MatchFloatConstant(), text)
+		val, err := lexer.EatFloatConstant()
+		assert.NoError(t, err, text)
+		assert.Equal(t, expected, val, text)
+		assert.True(t, lexer.MatchEOF(), text)
+	}
+
+	// A '.' not followed by a digit is a delimiter.
+	lexer := NewLexer("1. 2")
+	val, err := lexer.EatIntConstant()
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+	assert.True(t, lexer.MatchDelim('.'))
+}
+
 func TestLexer_EatStringConstant(t *testing.T) {
 	lexer := NewLexer("'HELLO'")
 	val, err := lexer.EatStringConstant()
@@ -141,3 +176,63 @@ func TestLexer_SyntaxError(t *testing.T) {
 	assert.ErrorAs(t, err, &syntaxErr, "Expected SyntaxError type")
 	assert.Equal(t, "expected integer constant", syntaxErr.Message, "Unexpected error message")
 }
+
+func TestLexer_IntConstantRange(t *testing.T) {
+	lexer := NewLexer("9223372036854775807")
+	val, err := lexer.EatIntConstant()
+	assert.NoError(t, err, "Unexpected error for the largest int")
+	assert.Equal(t, 9223372036854775807, val)
+
+	lexer = NewLexer("(9223372036854775808")
+	err = lexer.EatDelim('(')
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr, "Expected SyntaxError type")
+	assert.Equal(t, "integer constant 9223372036854775808 is out of range for type int", syntaxErr.Message, "Unexpected error message")
+}
+
+func TestLexer_EatParameter(t *testing.T) {
+	lexer := NewLexer("? :start_date @Name ?")
+	for _, expected := range []struct {
+		name     string
+		position int
+	}{{"", 1}, {"start_date", 0}, {"Name", 0}, {"", 2}} {
+		assert.True(t, lexer.MatchParameter())
+		name, position, err := lexer.EatParameter()
+		require.NoError(t, err)
+		assert.Equal(t, expected.name, name)
+		assert.Equal(t, expected.position, position)
+	}
+
+	err := NewLexer(": x").EatDelim('(')
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr, "Expected SyntaxError type for a parameter without a name")
+}
+
+func TestLexer_TokenLimits(t *testing.T) {
+	// A long string constant within the limit is read whole.
+	long := strings.Repeat("x", DefaultLexerLimits.MaxStringLength)
+	lexer := NewLexer("'" + long + "'")
+	val, err := lexer.EatStringConstant()
+	require.NoError(t, err)
+	assert.Equal(t, long, val)
+
+	limits := LexerLimits{MaxIdentifierLength: 8, MaxStringLength: 16}
+	var syntaxErr *SyntaxError
+	err = NewLexerWithLimits("('"+strings.Repeat("x", 17)+"'", limits).EatDelim('(')
+	require.ErrorAs(t, err, &syntaxErr, "Expected SyntaxError type for a string constant over the limit")
+	assert.Equal(t, "string constant exceeds 16 bytes", syntaxErr.Message)
+
+	err = NewLexerWithLimits("(abcdefghi", limits).EatDelim('(')
+	require.ErrorAs(t, err, &syntaxErr, "Expected SyntaxError type for an identifier over the limit")
+	assert.Equal(t, "identifier exceeds 8 bytes", syntaxErr.Message)
+
+	err = NewLexerWithLimits("(123456789", limits).EatDelim('(')
+	require.ErrorAs(t, err, &syntaxErr, "Expected SyntaxError type for a number over the limit")
+	assert.Equal(t, "numeric constant exceeds 8 bytes", syntaxErr.Message)
+
+	lexer = NewLexerWithLimits("abcdefgh '"+strings.Repeat("x", 16)+"'", limits)
+	id, err := lexer.EatId()
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefgh", id)
+	assert.True(t, lexer.MatchStringConstant(), "Expected the tokens at the limits to be read")
+}
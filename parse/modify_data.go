@@ -7,14 +7,22 @@ type ModifyData struct {
 	fieldName string
 	newValue  *query.Expression
 	predicate *query.Predicate
+	limit     int
 }
 
 func NewModifyData(tableName, fieldName string, newVal *query.Expression, pred *query.Predicate) *ModifyData {
+	return NewLimitedModifyData(tableName, fieldName, newVal, pred, NoLimit)
+}
+
+// NewLimitedModifyData creates the data for an update statement that
+// modifies at most limit of the records satisfying the predicate.
+func NewLimitedModifyData(tableName, fieldName string, newVal *query.Expression, pred *query.Predicate, limit int) *ModifyData {
 	return &ModifyData{
 		tableName: tableName,
 		fieldName: fieldName,
 		newValue:  newVal,
 		predicate: pred,
+		limit:     limit,
 	}
 }
 
@@ -33,3 +41,9 @@ func (md *ModifyData) NewValue() *query.Expression {
 func (md *ModifyData) Predicate() *query.Predicate {
 	return md.predicate
 }
+
+// Limit returns the maximum number of records to modify, or NoLimit.
+// Which of the matching records are modified when there are more than the limit is unspecified.
+func (md *ModifyData) Limit() int {
+	return md.limit
+}
@@ -0,0 +1,49 @@
+package parse
+
+import "fmt"
+
+// Parameters holds the values bound to the parameters of a statement, which stand for constants:
+// positional parameters ("?") take their values in order, and named parameters (":name" or "@name")
+// take the value bound to their name. A statement cannot use both kinds of parameters.
+// The values must already have the representation used for constants, such as int for integers.
+type Parameters struct {
+	Positional []any
+	Named      map[string]any
+}
+
+// value returns the value bound to the parameter with the specified name,
+// or to the positional parameter at the specified 1-based position if the name is empty.
+func (params *Parameters) value(name string, position int) (any, error) {
+	if name != "" {
+		if params != nil {
+			if val, ok := params.Named[name]; ok {
+				return val, nil
+			}
+		}
+		return nil, fmt.Errorf("no value bound to parameter %q", name)
+	}
+
+	if params == nil || position > len(params.Positional) {
+		return nil, fmt.Errorf("no value bound to parameter %d", position)
+	}
+	return params.Positional[position-1], nil
+}
+
+// CountPositionalParameters returns the number of positional parameters ("?") of the specified statement,
+// which is the number of values it must be executed with. It returns -1 if the statement has named parameters,
+// whose values are bound by name, or if it cannot be split into tokens, which the parser then reports.
+func CountPositionalParameters(statement string) int {
+	l := &Lexer{input: statement, limits: DefaultLexerLimits}
+	l.initKeywords()
+	for {
+		if err := l.nextToken(); err != nil {
+			return -1
+		}
+		switch {
+		case l.MatchEOF():
+			return l.positionalParameters
+		case l.MatchParameter() && l.currentToken.StringVal != "":
+			return -1
+		}
+	}
+}
@@ -1,21 +1,51 @@
 package parse
 
 import (
+	"cmp"
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/query/functions"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/types"
+	"maps"
+	"slices"
 	"strings"
 )
 
 type Parser struct {
-	lex *Lexer
+	lex    *Lexer
+	params *Parameters
+	// usesPositional and usesNamed record the kinds of parameters the statement uses.
+	usesPositional, usesNamed bool
+	// expressionAggregates are the aggregate functions found in the expressions parsed since it was last reset.
+	expressionAggregates []functions.AggregationFunction
+	// selectNames map the names by which a clause may refer to the fields of the select list to those fields,
+	// while the clauses that may do so are parsed, and is nil otherwise.
+	selectNames map[string]string
+	// semiJoins are the subquery conditions found in the WHERE clause of the query being parsed,
+	// which is the only clause they may appear in, and queryWhere is true while it is parsed.
+	semiJoins  []*SemiJoin
+	queryWhere bool
+	// qualified is true once the query being parsed qualifies one of its fields (see fieldReference).
+	qualified bool
 }
 
 func NewParser(s string) *Parser {
+	return NewParserWithParameters(s, nil)
+}
+
+// NewParserWithParameters creates a parser for a statement whose parameters
+// are replaced by the specified values.
+func NewParserWithParameters(s string, params *Parameters) *Parser {
+	return NewParserWithLimits(s, params, DefaultLexerLimits)
+}
+
+// NewParserWithLimits creates a parser like NewParserWithParameters,
+// whose lexer accepts the tokens within the specified limits.
+func NewParserWithLimits(s string, params *Parameters, limits LexerLimits) *Parser {
 	return &Parser{
-		lex: NewLexer(s),
+		lex:    NewLexerWithLimits(s, limits),
+		params: params,
 	}
 }
 
@@ -29,6 +59,47 @@ func (p *Parser) field() (string, error) {
 	return fld, nil
 }
 
+// fieldReference parses a field read by a query, which may be qualified by the alias or the name
+// of the table of the FROM clause it is a field of, such as "e.salary" (see QualifiedField).
+func (p *Parser) fieldReference() (string, error) {
+	field, err := p.field()
+	if err != nil || !p.lex.MatchDelim('.') {
+		return field, err
+	}
+	if err := p.lex.EatDelim('.'); err != nil {
+		return "", err
+	}
+	return p.qualifiedField(field)
+}
+
+// qualifiedField parses the name of a field qualified by the specified alias or table, whose '.' has been parsed.
+func (p *Parser) qualifiedField(qualifier string) (string, error) {
+	field, err := p.field()
+	if err != nil {
+		return "", err
+	}
+	p.qualified = true
+	return QualifiedField(qualifier, field), nil
+}
+
+// fieldReferenceList parses a comma-separated list of fields read by a query (see fieldReference).
+func (p *Parser) fieldReferenceList() ([]string, error) {
+	var fields []string
+	for {
+		field, err := p.fieldReference()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		if !p.lex.MatchDelim(',') {
+			return fields, nil
+		}
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
 func (p *Parser) constant() (any, error) {
 	if p.lex.MatchStringConstant() {
 		stringVal, err := p.lex.EatStringConstant()
@@ -44,48 +115,179 @@ func (p *Parser) constant() (any, error) {
 		}
 		return intVal, nil
 	}
+	if p.lex.MatchFloatConstant() {
+		floatVal, err := p.lex.EatFloatConstant()
+		if err != nil {
+			return nil, err
+		}
+		return floatVal, nil
+	}
 	if p.lex.MatchBooleanConstant() {
 		boolVal, err := p.lex.EatBooleanConstant()
-		if err == nil {
-			return boolVal, nil
+		if err != nil {
+			return nil, err
 		}
 		return boolVal, nil
 	}
 	if p.lex.MatchDateConstant() {
 		dateVal, err := p.lex.EatDateConstant()
-		if err == nil {
-			return dateVal, nil
+		if err != nil {
+			return nil, err
 		}
 		return dateVal, nil
 	}
+	if p.lex.MatchDelim('-') {
+		if err := p.lex.EatDelim('-'); err != nil {
+			return nil, err
+		}
+		if p.lex.MatchFloatConstant() {
+			floatVal, err := p.lex.EatFloatConstant()
+			if err != nil {
+				return nil, err
+			}
+			return -floatVal, nil
+		}
+		intVal, err := p.lex.EatIntConstant()
+		if err != nil {
+			return nil, err
+		}
+		return -intVal, nil
+	}
+	if p.lex.MatchParameter() {
+		return p.parameter()
+	}
+	if p.lex.MatchKeyword("null") {
+		// The null constant is nil.
+		return nil, p.lex.EatKeyword("null")
+	}
 	return nil, &SyntaxError{Message: "expected constant"}
 }
 
+// matchConstant returns true if the current token starts a constant.
+func (p *Parser) matchConstant() bool {
+	return p.lex.MatchStringConstant() || p.lex.MatchIntConstant() || p.lex.MatchFloatConstant() || p.lex.MatchBooleanConstant() ||
+		p.lex.MatchDateConstant() || p.lex.MatchDelim('-') || p.lex.MatchParameter() || p.lex.MatchKeyword("null")
+}
+
+// parameter returns the value bound to the current parameter.
+func (p *Parser) parameter() (any, error) {
+	name, position, err := p.lex.EatParameter()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		p.usesPositional = true
+	} else {
+		p.usesNamed = true
+	}
+	if p.usesPositional && p.usesNamed {
+		return nil, &SyntaxError{Message: "positional and named parameters cannot be mixed in a statement"}
+	}
+	return p.params.value(name, position)
+}
+
+// expression parses an arithmetic expression, in which * and / bind tighter than + and -,
+// and operators of the same precedence apply from left to right:
+//
+//	<expression> := <product> [ ( + | - ) <product> ]*
+//	<product>    := <operand> [ ( * | / ) <operand> ]*
+//	<operand>    := <aggregate> | <field> | <constant> | ( <expression> )
 func (p *Parser) expression() (*query.Expression, error) {
+	first, err := p.operand()
+	if err != nil {
+		return &query.Expression{}, err
+	}
+	return p.expressionFrom(first)
+}
+
+// expressionFrom parses the rest of an expression whose first operand is the specified expression.
+func (p *Parser) expressionFrom(first *query.Expression) (*query.Expression, error) {
+	lhs, err := p.productFrom(first)
+	if err != nil {
+		return nil, err
+	}
+	for p.lex.MatchDelim('+') || p.lex.MatchDelim('-') {
+		op, err := p.arithmeticOperator()
+		if err != nil {
+			return nil, err
+		}
+		operand, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := p.productFrom(operand)
+		if err != nil {
+			return nil, err
+		}
+		lhs = query.NewArithmeticExpression(lhs, op, rhs)
+	}
+	return lhs, nil
+}
+
+// productFrom parses the rest of a product whose first operand is the specified expression.
+func (p *Parser) productFrom(first *query.Expression) (*query.Expression, error) {
+	lhs := first
+	for p.lex.MatchDelim('*') || p.lex.MatchDelim('/') {
+		op, err := p.arithmeticOperator()
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		lhs = query.NewArithmeticExpression(lhs, op, rhs)
+	}
+	return lhs, nil
+}
+
+// arithmeticOperator parses the operator of an arithmetic expression.
+func (p *Parser) arithmeticOperator() (types.ArithmeticOperator, error) {
+	for _, op := range []types.ArithmeticOperator{types.ADD, types.SUB, types.MUL, types.DIV} {
+		if delim := rune(op.String()[0]); p.lex.MatchDelim(delim) {
+			return op, p.lex.EatDelim(delim)
+		}
+	}
+	return 0, &SyntaxError{Message: "expected arithmetic operator (+, -, * or /)"}
+}
+
+// operand parses an operand of an arithmetic expression.
+func (p *Parser) operand() (*query.Expression, error) {
 	// Check for aggregate function first
-	if p.lex.MatchKeyword("max") || p.lex.MatchKeyword("min") ||
-		p.lex.MatchKeyword("count") || p.lex.MatchKeyword("avg") ||
-		p.lex.MatchKeyword("sum") {
+	if p.lex.MatchAggregate() {
 		agg, err := p.parseAggregate()
 		if err != nil {
 			return nil, err
 		}
+		p.expressionAggregates = append(p.expressionAggregates, agg)
 		return query.NewFieldExpression(agg.FieldName()), nil
 	}
 
 	// If next token is an identifier, treat as field
 	if p.lex.MatchId() {
-		f, err := p.field()
+		f, err := p.fieldReference()
 		if err != nil {
-			return &query.Expression{}, err
+			return nil, err
 		}
-		return query.NewFieldExpression(f), nil
+		return query.NewFieldExpression(p.resolveAlias(f)), nil
+	}
+
+	// A parenthesized expression
+	if p.lex.MatchDelim('(') {
+		if err := p.lex.EatDelim('('); err != nil {
+			return nil, err
+		}
+		e, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.lex.EatDelim(')')
 	}
 
 	// Otherwise treat as constant
 	c, err := p.constant()
 	if err != nil {
-		return &query.Expression{}, err
+		return nil, err
 	}
 	return query.NewConstantExpression(c), nil
 }
@@ -96,6 +298,22 @@ func (p *Parser) term() (*query.Term, error) {
 	if err != nil {
 		return &query.Term{}, err
 	}
+	return p.comparison(lhs)
+}
+
+// comparison parses the rest of a term whose left-hand side is the specified expression.
+func (p *Parser) comparison(lhs *query.Expression) (*query.Term, error) {
+	if p.lex.MatchKeyword("is") {
+		return p.nullTest(lhs)
+	}
+	if p.lex.MatchKeyword("like") || p.lex.MatchKeyword("not") {
+		return p.patternMatch(lhs)
+	}
+
+	// A field without a comparison, such as "active" in "where not active", is a boolean field compared with true.
+	if lhs.IsFieldName() && p.lex.currentToken.Type != TTOperator {
+		return query.NewTerm(lhs, query.NewConstantExpression(true), types.EQ), nil
+	}
 
 	// Read the operator from the lexer
 	op, err := p.parseOperator()
@@ -118,6 +336,47 @@ func (p *Parser) term() (*query.Term, error) {
 	return query.NewTerm(lhs, rhs, parsedOp), nil
 }
 
+// nullTest parses the rest of a term testing the specified expression for null:
+//
+//	<term> := <expression> IS [ NOT ] NULL
+func (p *Parser) nullTest(lhs *query.Expression) (*query.Term, error) {
+	if err := p.lex.EatKeyword("is"); err != nil {
+		return nil, err
+	}
+	op := types.IS
+	if p.lex.MatchKeyword("not") {
+		if err := p.lex.EatKeyword("not"); err != nil {
+			return nil, err
+		}
+		op = types.ISNOT
+	}
+	if err := p.lex.EatKeyword("null"); err != nil {
+		return nil, err
+	}
+	return query.NewTerm(lhs, query.NewConstantExpression(nil), op), nil
+}
+
+// patternMatch parses the rest of a term matching the specified expression with a pattern:
+//
+//	<term> := <expression> [ NOT ] LIKE <expression>
+func (p *Parser) patternMatch(lhs *query.Expression) (*query.Term, error) {
+	op := types.LIKE
+	if p.lex.MatchKeyword("not") {
+		if err := p.lex.EatKeyword("not"); err != nil {
+			return nil, err
+		}
+		op = types.NOTLIKE
+	}
+	if err := p.lex.EatKeyword("like"); err != nil {
+		return nil, err
+	}
+	pattern, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewTerm(lhs, pattern, op), nil
+}
+
 func (p *Parser) parseOperator() (string, error) {
 	// Ensure the current token is indeed an operator
 	if p.lex.currentToken.Type != TTOperator {
@@ -133,35 +392,334 @@ func (p *Parser) parseOperator() (string, error) {
 	return op, nil
 }
 
+// predicate parses the disjunction of conjunctions, in which "and" binds tighter than "or":
+//
+//	<predicate>   := <conjunction> [ OR <conjunction> ]*
+//	<conjunction> := <factor> [ AND <factor> ]*
+//	<factor>      := NOT <factor> | ( <predicate> ) | <term>
 func (p *Parser) predicate() (*query.Predicate, error) {
-	firstTerm, err := p.term()
+	semiJoins := len(p.semiJoins)
+	pred, err := p.conjunction()
 	if err != nil {
-		return &query.Predicate{}, err
+		return nil, err
+	}
+	if !p.lex.MatchKeyword("or") {
+		return pred, nil
 	}
-	pred := query.NewPredicateFromTerm(firstTerm)
 
-	// check if there's an "and"
-	if p.lex.MatchKeyword("and") {
-		_ = p.lex.EatKeyword("and") // ignoring error for brevity
-		otherPred, err := p.predicate()
+	if len(p.semiJoins) > semiJoins {
+		return nil, errSemiJoinPlacement
+	}
+
+	branches := []*query.Predicate{pred}
+	for p.lex.MatchKeyword("or") {
+		if err := p.lex.EatKeyword("or"); err != nil {
+			return nil, err
+		}
+		branch, err := p.conjunction()
 		if err != nil {
-			return &query.Predicate{}, err
+			return nil, err
+		}
+		if len(p.semiJoins) > semiJoins {
+			return nil, errSemiJoinPlacement
+		}
+		branches = append(branches, branch)
+	}
+	return query.NewPredicateFromDisjunction(query.NewDisjunction(branches...)), nil
+}
+
+func (p *Parser) conjunction() (*query.Predicate, error) {
+	pred, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.lex.MatchKeyword("and") {
+		if err := p.lex.EatKeyword("and"); err != nil {
+			return nil, err
+		}
+		otherPred, err := p.factor()
+		if err != nil {
+			return nil, err
 		}
 		pred.ConjoinWith(otherPred)
 	}
 	return pred, nil
 }
 
+func (p *Parser) factor() (*query.Predicate, error) {
+	if p.lex.MatchKeyword("not") {
+		if err := p.lex.EatKeyword("not"); err != nil {
+			return nil, err
+		}
+		semiJoins := len(p.semiJoins)
+		pred, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		if len(p.semiJoins) > semiJoins {
+			return nil, errSemiJoinPlacement
+		}
+		return pred.Negate(), nil
+	}
+	if p.lex.MatchKeyword("exists") && p.lex.peekDelim('(') {
+		if err := p.lex.EatKeyword("exists"); err != nil {
+			return nil, err
+		}
+		return p.semiJoin("")
+	}
+	if !p.lex.MatchDelim('(') {
+		return p.termFactor()
+	}
+
+	// A parenthesized predicate, or else a term starting with a parenthesized expression, such as "(a + b) * 2 > c",
+	// which is parsed again from the parenthesis if it is not a predicate, or is followed by the rest of a term.
+	lexer, semiJoins, aggregates := *p.lex, len(p.semiJoins), len(p.expressionAggregates)
+	pred, err := p.parenthesizedPredicate()
+	if err == nil && !p.matchTermContinuation() {
+		return pred, nil
+	}
+	*p.lex = lexer
+	p.semiJoins, p.expressionAggregates = p.semiJoins[:semiJoins], p.expressionAggregates[:aggregates]
+	termPred, termErr := p.termFactor()
+	if termErr != nil && err != nil {
+		return nil, err
+	}
+	return termPred, termErr
+}
+
+// matchTermContinuation returns true if the current token is an arithmetic or comparison operator,
+// which continues the expression before it.
+func (p *Parser) matchTermContinuation() bool {
+	return p.lex.currentToken.Type == TTOperator || p.lex.MatchKeyword("is") ||
+		p.lex.MatchKeyword("like") || p.lex.MatchKeyword("not") || p.lex.MatchKeyword("in") || p.lex.MatchKeyword("between") ||
+		p.lex.MatchDelim('+') || p.lex.MatchDelim('-') || p.lex.MatchDelim('*') || p.lex.MatchDelim('/')
+}
+
+// termFactor parses a factor that is a term, the subquery condition of a field, or a condition desugared
+// into terms: a BETWEEN is the conjunction of two range terms, and an IN list the disjunction of equalities.
+// Their negations are built like for NOT (see query.Predicate#Negate).
+//
+//	<factor> := <term> | <field> IN ( <query> )
+//	          | <expression> [ NOT ] IN ( <expression> [ , <expression> ]* )
+//	          | <expression> [ NOT ] BETWEEN <expression> AND <expression>
+func (p *Parser) termFactor() (*query.Predicate, error) {
+	lhs, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	lexer := *p.lex
+	negated := p.lex.MatchKeyword("not")
+	if negated {
+		if err := p.lex.EatKeyword("not"); err != nil {
+			return nil, err
+		}
+	}
+	var pred *query.Predicate
+	switch {
+	case p.lex.MatchKeyword("in"):
+		pred, err = p.inCondition(lhs, negated)
+	case p.lex.MatchKeyword("between"):
+		pred, err = p.between(lhs)
+	default:
+		// "NOT LIKE" is read by the comparison.
+		*p.lex = lexer
+		negated = false
+		var term *query.Term
+		if term, err = p.comparison(lhs); err == nil {
+			pred = query.NewPredicateFromTerm(term)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if negated {
+		return pred.Negate(), nil
+	}
+	return pred, nil
+}
+
+// inCondition parses the rest of an IN condition on the specified expression, whose values are either
+// a subquery, for which the expression must be a field, or a list of expressions it must equal one of.
+func (p *Parser) inCondition(lhs *query.Expression, negated bool) (*query.Predicate, error) {
+	if err := p.lex.EatKeyword("in"); err != nil {
+		return nil, err
+	}
+	lexer := *p.lex
+	if err := p.lex.EatDelim('('); err != nil {
+		return nil, err
+	}
+	if p.lex.MatchKeyword("select") {
+		*p.lex = lexer
+		if negated || !lhs.IsFieldName() {
+			return nil, errSemiJoinPlacement
+		}
+		return p.semiJoin(lhs.String())
+	}
+
+	var branches []*query.Predicate
+	for {
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, query.NewPredicateFromTerm(query.NewTerm(lhs, value, types.EQ)))
+		if !p.lex.MatchDelim(',') {
+			break
+		}
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.lex.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	if len(branches) == 1 {
+		return branches[0], nil
+	}
+	return query.NewPredicateFromDisjunction(query.NewDisjunction(branches...)), nil
+}
+
+// between parses the rest of a BETWEEN condition on the specified expression, which holds if the
+// expression is at least the first value and at most the second.
+func (p *Parser) between(lhs *query.Expression) (*query.Predicate, error) {
+	if err := p.lex.EatKeyword("between"); err != nil {
+		return nil, err
+	}
+	low, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("and"); err != nil {
+		return nil, err
+	}
+	high, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	pred := query.NewPredicateFromTerm(query.NewTerm(lhs, low, types.GE))
+	pred.ConjoinWith(query.NewPredicateFromTerm(query.NewTerm(lhs, high, types.LE)))
+	return pred, nil
+}
+
+// parenthesizedPredicate parses a predicate between parentheses.
+func (p *Parser) parenthesizedPredicate() (*query.Predicate, error) {
+	if err := p.lex.EatDelim('('); err != nil {
+		return nil, err
+	}
+	pred, err := p.predicate()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	return pred, nil
+}
+
+// errSemiJoinPlacement is returned for a subquery condition that is not conjoined with the rest of the predicate.
+var errSemiJoinPlacement = &SyntaxError{Message: "IN and EXISTS subqueries can only be conjoined with the rest of the WHERE clause of a query"}
+
+// semiJoin parses the subquery of a subquery condition on the specified field, or of an EXISTS condition if the
+// field is empty, and adds the condition to those of the query (see SemiJoin). The condition stands for TRUE in
+// the predicate, to which it is conjoined.
+//
+//	<factor> := <field> IN ( <query> ) | EXISTS ( <query> )
+func (p *Parser) semiJoin(field string) (*query.Predicate, error) {
+	if !p.queryWhere {
+		return nil, errSemiJoinPlacement
+	}
+	if err := p.lex.EatDelim('('); err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchKeyword("select") {
+		return nil, &SyntaxError{Message: "expected a subquery"}
+	}
+	subquery, err := p.Query()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	if field != "" && len(subquery.Fields())+len(subquery.SelectAggregates()) != 1 {
+		return nil, &SyntaxError{Message: fmt.Sprintf("the subquery of %s IN must select a single field", field)}
+	}
+	p.semiJoins = append(p.semiJoins, &SemiJoin{field: field, subquery: subquery})
+	return query.NewPredicate(), nil
+}
+
+// Predicate parses the entire input as a predicate, such as the
+// definition of a partial index stored in the catalog.
+func (p *Parser) Predicate() (*query.Predicate, error) {
+	pred, err := p.predicate()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchEOF() {
+		return nil, &SyntaxError{Message: "unexpected input after predicate"}
+	}
+	return pred, nil
+}
+
 // -- Queries --
 
+// Query parses a select statement, or the union of several select statements, whose queries are added
+// to those of the first one (see QueryData#Unions):
+//
+//	<query> := <select> [ UNION [ ALL ] <select> ]*
+//
+// The clauses of each select statement, such as ORDER BY, apply to that statement.
 func (p *Parser) Query() (*QueryData, error) {
+	qd, err := p.selectQuery()
+	if err != nil {
+		return nil, err
+	}
+	for p.lex.MatchKeyword("union") {
+		if err := p.lex.EatKeyword("union"); err != nil {
+			return nil, err
+		}
+		all := p.lex.MatchKeyword("all")
+		if all {
+			if err := p.lex.EatKeyword("all"); err != nil {
+				return nil, err
+			}
+		}
+		next, err := p.selectQuery()
+		if err != nil {
+			return nil, err
+		}
+		width, known := qd.selectWidth()
+		nextWidth, nextKnown := next.selectWidth()
+		if known && nextKnown && width != nextWidth {
+			return nil, &SyntaxError{Message: fmt.Sprintf("the queries of a UNION must select as many fields, not %d and %d", width, nextWidth)}
+		}
+		qd.unions = append(qd.unions, &Union{query: next, all: all})
+	}
+	return qd, nil
+}
+
+// selectQuery parses a select statement. It also parses the queries outputting rows of constants instead of reading
+// tables (see QueryData#Values): a VALUES list, or a select list of constants without a FROM clause, such as "select 1".
+func (p *Parser) selectQuery() (*QueryData, error) {
+	if p.lex.MatchKeyword("values") {
+		return p.valuesQuery()
+	}
+
 	// "select"
 	if err := p.lex.EatKeyword("select"); err != nil {
 		return nil, err
 	}
+	// The subquery conditions and the qualified fields of a subquery belong to the subquery.
+	outerSemiJoins, outerWhere, outerQualified := p.semiJoins, p.queryWhere, p.qualified
+	p.semiJoins, p.queryWhere, p.qualified = nil, false, false
+	defer func() { p.semiJoins, p.queryWhere, p.qualified = outerSemiJoins, outerWhere, outerQualified }()
+	if p.matchConstant() {
+		return p.constantQuery()
+	}
 
 	// Parse fields and aggregates
-	fields, aggregates, err := p.selectList()
+	fields, aggregates, aliases, computed, err := p.selectList()
 	if err != nil {
 		return nil, err
 	}
@@ -170,7 +728,7 @@ func (p *Parser) Query() (*QueryData, error) {
 	if err := p.lex.EatKeyword("from"); err != nil {
 		return nil, err
 	}
-	tables, err := p.tableList()
+	tables, tableAliases, err := p.tableList()
 	if err != nil {
 		return nil, err
 	}
@@ -180,11 +738,15 @@ func (p *Parser) Query() (*QueryData, error) {
 
 	// Optional "where"
 	if p.lex.MatchKeyword("where") {
-		_ = p.lex.EatKeyword("where")
+		if err := p.lex.EatKeyword("where"); err != nil {
+			return nil, err
+		}
+		p.queryWhere = true
 		pr, err := p.predicate()
 		if err != nil {
 			return nil, err
 		}
+		p.queryWhere = false
 		pred = pr
 	}
 
@@ -197,14 +759,28 @@ func (p *Parser) Query() (*QueryData, error) {
 		}
 	}
 
-	// Optional "having"
+	// The having and order by clauses may refer to the fields of the select list by their alias,
+	// and to its aggregates by their generated name, which is read in lower case like any identifier.
+	p.selectNames = make(map[string]string)
+	for _, agg := range aggregates {
+		p.selectNames[strings.ToLower(agg.FieldName())] = agg.FieldName()
+	}
+	maps.Copy(p.selectNames, aliases)
+	defer func() { p.selectNames = nil }()
+
+	// Optional "having", whose aggregates are computed by the grouping even if they are not selected
 	var having *query.Predicate
+	selectAggregates := len(aggregates)
 	if p.lex.MatchKeyword("having") {
-		_ = p.lex.EatKeyword("having")
+		if err := p.lex.EatKeyword("having"); err != nil {
+			return nil, err
+		}
+		p.expressionAggregates = nil
 		having, err = p.predicate()
 		if err != nil {
 			return nil, err
 		}
+		aggregates = addAggregates(aggregates, p.expressionAggregates)
 	}
 
 	// Optional "order by"
@@ -216,50 +792,225 @@ func (p *Parser) Query() (*QueryData, error) {
 		}
 	}
 
+	// Optional "limit" and "offset"
+	limit, err := p.parseLimit()
+	if err != nil {
+		return nil, err
+	}
+	offset, err := p.parseOffset()
+	if err != nil {
+		return nil, err
+	}
+
 	return &QueryData{
-		fields:     fields,
-		tables:     tables,
-		predicate:  pred,
-		groupBy:    groupBy,
-		having:     having,
-		orderBy:    orderBy,
-		aggregates: aggregates,
+		fields:           fields,
+		tables:           tables,
+		tableAliases:     tableAliases,
+		qualified:        p.qualified || tableAliases != nil,
+		predicate:        pred,
+		groupBy:          groupBy,
+		having:           having,
+		orderBy:          orderBy,
+		aggregates:       aggregates,
+		selectAggregates: selectAggregates,
+		aliases:          aliases,
+		computed:         computed,
+		limit:            limit,
+		offset:           offset,
+		semiJoins:        p.semiJoins,
 	}, nil
 }
 
-func (p *Parser) selectList() ([]string, []functions.AggregationFunction, error) {
+// addAggregates appends the specified aggregate functions to aggregates, except those computing
+// the same field as one of aggregates, such as a HAVING clause repeating an aggregate of the select list.
+func addAggregates(aggregates, added []functions.AggregationFunction) []functions.AggregationFunction {
+	for _, agg := range added {
+		if !slices.ContainsFunc(aggregates, func(other functions.AggregationFunction) bool {
+			return other.FieldName() == agg.FieldName()
+		}) {
+			aggregates = append(aggregates, agg)
+		}
+	}
+	return aggregates
+}
+
+// constantQuery parses the select list of constants of a query outputting a single row, such as "select 1, 'a'".
+func (p *Parser) constantQuery() (*QueryData, error) {
+	vals, err := p.constList()
+	if err != nil {
+		return nil, err
+	}
+	if p.lex.MatchKeyword("from") {
+		return nil, &SyntaxError{Message: "a select list of constants cannot read tables"}
+	}
+	return NewValuesQueryData([][]any{vals}), nil
+}
+
+// valuesQuery parses a query of the form "values (constant, ...), ...".
+func (p *Parser) valuesQuery() (*QueryData, error) {
+	if err := p.lex.EatKeyword("values"); err != nil {
+		return nil, err
+	}
+	rows, err := p.valuesList()
+	if err != nil {
+		return nil, err
+	}
+	return NewValuesQueryData(rows), nil
+}
+
+// valuesList parses the rows of a VALUES list, "(constant, ...), ...", which must all have the same number of values.
+func (p *Parser) valuesList() ([][]any, error) {
+	var rows [][]any
+	for {
+		if err := p.lex.EatDelim('('); err != nil {
+			return nil, err
+		}
+		vals, err := p.constList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatDelim(')'); err != nil {
+			return nil, err
+		}
+		if len(rows) > 0 && len(vals) != len(rows[0]) {
+			return nil, &SyntaxError{Message: fmt.Sprintf("VALUES row %d has %d values, but row 1 has %d", len(rows)+1, len(vals), len(rows[0]))}
+		}
+		rows = append(rows, vals)
+
+		if !p.lex.MatchDelim(',') {
+			return rows, nil
+		}
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// selectList parses the select list of a query, returning its fields, its aggregates, its aliases,
+// which map each alias given with "as" to the field or aggregate field it names, and its computed fields,
+// which map the text of each arithmetic expression of the list, such as "salary * 12", to the expression.
+// A field or aggregate can only be named once in the list, and an alias cannot be the name of another field of the list.
+func (p *Parser) selectList() ([]string, []functions.AggregationFunction, map[string]string, map[string]*query.Expression, error) {
 	var fields []string
 	var aggregates []functions.AggregationFunction
+	var aliases map[string]string
+	var computed map[string]*query.Expression
+	// names are the fields and aggregate fields of the list, each with the name it is output under.
+	names := make(map[string]string)
 
 	for {
-		// Check for aggregate function
-		if p.lex.MatchKeyword("max") || p.lex.MatchKeyword("min") ||
-			p.lex.MatchKeyword("count") || p.lex.MatchKeyword("avg") ||
-			p.lex.MatchKeyword("sum") {
+		// Check for the wildcard "*", or an aggregate function
+		var name string
+		if p.lex.MatchDelim('*') {
+			if err := p.lex.EatDelim('*'); err != nil {
+				return nil, nil, nil, nil, err
+			}
+			fields = append(fields, Wildcard)
+		} else if p.lex.MatchAggregate() {
 			agg, err := p.parseAggregate()
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, nil, err
 			}
 			aggregates = append(aggregates, agg)
+			name = agg.FieldName()
 			// I don't think this is needed, might uncomment later :P
 			//fields = append(fields, agg.FieldName())
 		} else {
-			// Regular field
-			field, err := p.field()
-			if err != nil {
-				return nil, nil, err
+			// Regular field, qualified wildcard "table.*", or arithmetic expression of fields and constants
+			var expression *query.Expression
+			if p.lex.MatchDelim('(') {
+				e, err := p.expression()
+				if err != nil {
+					return nil, nil, nil, nil, err
+				}
+				expression = e
+			} else {
+				field, err := p.field()
+				if err != nil {
+					return nil, nil, nil, nil, err
+				}
+				if p.lex.MatchDelim('.') {
+					if err := p.lex.EatDelim('.'); err != nil {
+						return nil, nil, nil, nil, err
+					}
+					if p.lex.MatchDelim('*') {
+						if err := p.lex.EatDelim('*'); err != nil {
+							return nil, nil, nil, nil, err
+						}
+						fields = append(fields, QualifiedWildcard(field))
+						field = ""
+					} else if field, err = p.qualifiedField(field); err != nil {
+						return nil, nil, nil, nil, err
+					}
+				}
+				if field != "" {
+					if expression, err = p.expressionFrom(query.NewFieldExpression(field)); err != nil {
+						return nil, nil, nil, nil, err
+					}
+				}
+			}
+			if expression != nil {
+				name = expression.String()
+				if !expression.IsFieldName() {
+					if computed == nil {
+						computed = make(map[string]*query.Expression)
+					}
+					computed[name] = expression
+				}
+				fields = append(fields, name)
 			}
-			fields = append(fields, field)
+		}
+
+		// Optional alias of a field or an aggregate
+		if name != "" {
+			outputName := name
+			if p.lex.MatchKeyword("as") {
+				if err := p.lex.EatKeyword("as"); err != nil {
+					return nil, nil, nil, nil, err
+				}
+				alias, err := p.field()
+				if err != nil {
+					return nil, nil, nil, nil, err
+				}
+				if _, ok := aliases[alias]; ok {
+					return nil, nil, nil, nil, &SyntaxError{Message: fmt.Sprintf("alias %s is given twice in the select list", alias)}
+				}
+				if aliases == nil {
+					aliases = make(map[string]string)
+				}
+				aliases[alias] = name
+				outputName = alias
+			}
+			if previous, ok := names[name]; ok && previous != outputName {
+				return nil, nil, nil, nil, &SyntaxError{Message: fmt.Sprintf("%s is named twice in the select list", name)}
+			}
+			names[name] = outputName
 		}
 
 		// Continue if there's a comma
 		if !p.lex.MatchDelim(',') {
 			break
 		}
-		_ = p.lex.EatDelim(',')
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	for alias, name := range aliases {
+		if outputName, ok := names[alias]; ok && alias != name && outputName == alias {
+			return nil, nil, nil, nil, &SyntaxError{Message: fmt.Sprintf("alias %s of %s is the name of another field of the select list", alias, name)}
+		}
 	}
+	return fields, aggregates, aliases, computed, nil
+}
 
-	return fields, aggregates, nil
+// resolveAlias returns the field of the select list that the specified name refers to, if the clause being parsed
+// may refer to it by that name (see selectNames), or else the specified field.
+func (p *Parser) resolveAlias(field string) string {
+	if name, ok := p.selectNames[field]; ok {
+		return name
+	}
+	return field
 }
 
 // Parse aggregate function
@@ -275,8 +1026,19 @@ func (p *Parser) parseAggregate() (functions.AggregationFunction, error) {
 		return nil, err
 	}
 
+	// COUNT(*) counts every record, and is the only aggregate of no field
+	if funcName == "count" && p.lex.MatchDelim('*') {
+		if err := p.lex.EatDelim('*'); err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatDelim(')'); err != nil {
+			return nil, err
+		}
+		return functions.NewCountAllFunction(), nil
+	}
+
 	// Get field name
-	field, err := p.field()
+	field, err := p.fieldReference()
 	if err != nil {
 		return nil, err
 	}
@@ -297,6 +1059,8 @@ func (p *Parser) parseAggregate() (functions.AggregationFunction, error) {
 		return functions.NewAvgFunction(field), nil
 	case "sum":
 		return functions.NewSumFunction(field), nil
+	case "approx_count_distinct":
+		return functions.NewApproxCountDistinctFunction(field), nil
 	default:
 		return nil, fmt.Errorf("unknown aggregate function: %s", funcName)
 	}
@@ -311,7 +1075,7 @@ func (p *Parser) parseGroupBy() ([]string, error) {
 		return nil, err
 	}
 
-	return p.fieldList()
+	return p.fieldReferenceList()
 }
 
 // Parse ORDER BY clause
@@ -329,28 +1093,31 @@ func (p *Parser) parseOrderBy() ([]OrderByItem, error) {
 		var err error
 
 		// Check for aggregate function
-		if p.lex.MatchKeyword("max") || p.lex.MatchKeyword("min") ||
-			p.lex.MatchKeyword("count") || p.lex.MatchKeyword("avg") ||
-			p.lex.MatchKeyword("sum") {
+		if p.lex.MatchAggregate() {
 			agg, err := p.parseAggregate()
 			if err != nil {
 				return nil, err
 			}
 			field = agg.FieldName()
 		} else {
-			field, err = p.field()
+			field, err = p.fieldReference()
 			if err != nil {
 				return nil, err
 			}
+			field = p.resolveAlias(field)
 		}
 
 		// Check for optional ASC/DESC
 		descending := false
 		if p.lex.MatchKeyword("desc") {
-			_ = p.lex.EatKeyword("desc")
+			if err := p.lex.EatKeyword("desc"); err != nil {
+				return nil, err
+			}
 			descending = true
 		} else if p.lex.MatchKeyword("asc") {
-			_ = p.lex.EatKeyword("asc")
+			if err := p.lex.EatKeyword("asc"); err != nil {
+				return nil, err
+			}
 		}
 
 		items = append(items, OrderByItem{field: field, descending: descending})
@@ -358,27 +1125,60 @@ func (p *Parser) parseOrderBy() ([]OrderByItem, error) {
 		if !p.lex.MatchDelim(',') {
 			break
 		}
-		_ = p.lex.EatDelim(',')
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, err
+		}
 	}
 
 	return items, nil
 }
 
-func (p *Parser) tableList() ([]string, error) {
-	t, err := p.lex.EatId()
-	if err != nil {
-		return nil, err
-	}
-	tables := []string{t}
-	if p.lex.MatchDelim(',') {
-		_ = p.lex.EatDelim(',')
-		rest, err := p.tableList()
+// tableList parses the tables of a FROM clause, each of which may be followed by an alias, with or without "as",
+// such as "emp e1, emp as e2". It returns the tables, and the alias of each of them, or an empty string for a table
+// without one, or nil aliases if no table has one. Two tables cannot be qualified by the same alias or name
+// (see QueryData#Qualifiers) if one of them has an alias.
+func (p *Parser) tableList() ([]string, []string, error) {
+	var tables, aliases []string
+	for {
+		table, err := p.lex.EatId()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		alias := ""
+		if p.lex.MatchKeyword("as") {
+			if err := p.lex.EatKeyword("as"); err != nil {
+				return nil, nil, err
+			}
+			if alias, err = p.lex.EatId(); err != nil {
+				return nil, nil, err
+			}
+		} else if p.lex.MatchId() && !p.lex.MatchKeyword("union") {
+			if alias, err = p.lex.EatId(); err != nil {
+				return nil, nil, err
+			}
+		}
+		tables = append(tables, table)
+		aliases = append(aliases, alias)
+		if !p.lex.MatchDelim(',') {
+			break
+		}
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, nil, err
 		}
-		tables = append(tables, rest...)
 	}
-	return tables, nil
+
+	if !slices.ContainsFunc(aliases, func(alias string) bool { return alias != "" }) {
+		return tables, nil, nil
+	}
+	qualifiers := make(map[string]bool, len(tables))
+	for i, table := range tables {
+		qualifier := cmp.Or(aliases[i], table)
+		if qualifiers[qualifier] {
+			return nil, nil, &SyntaxError{Message: fmt.Sprintf("%s qualifies two tables of the FROM clause", qualifier)}
+		}
+		qualifiers[qualifier] = true
+	}
+	return tables, aliases, nil
 }
 
 // -- Update Commands --
@@ -390,6 +1190,14 @@ func (p *Parser) UpdateCmd() (interface{}, error) {
 		return p.delete()
 	} else if p.lex.MatchKeyword("update") {
 		return p.modify()
+	} else if p.lex.MatchKeyword("dump") {
+		return p.dumpDatabase()
+	} else if p.lex.MatchKeyword("restore") {
+		return p.restore()
+	} else if p.lex.MatchKeyword("check") {
+		return p.CheckTable()
+	} else if p.lex.MatchKeyword("drop") {
+		return p.drop()
 	} else {
 		return p.create()
 	}
@@ -408,6 +1216,33 @@ func (p *Parser) create() (interface{}, error) {
 	}
 }
 
+// drop parses a statement of the form "drop table tablename" or "drop index indexname".
+// The word DROP is not reserved, so that it can still be used as an identifier.
+func (p *Parser) drop() (interface{}, error) {
+	if err := p.lex.EatKeyword("drop"); err != nil {
+		return nil, err
+	}
+	isTable := p.lex.MatchKeyword("table")
+	if isTable {
+		if err := p.lex.EatKeyword("table"); err != nil {
+			return nil, err
+		}
+	} else if err := p.lex.EatKeyword("index"); err != nil {
+		return nil, err
+	}
+	name, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchEOF() {
+		return nil, &SyntaxError{Message: "unexpected input after " + name}
+	}
+	if isTable {
+		return NewDropTableData(name), nil
+	}
+	return NewDropIndexData(name), nil
+}
+
 // -- Delete Commands --
 
 func (p *Parser) delete() (*DeleteData, error) {
@@ -423,14 +1258,42 @@ func (p *Parser) delete() (*DeleteData, error) {
 	}
 	pred := query.NewPredicate()
 	if p.lex.MatchKeyword("where") {
-		_ = p.lex.EatKeyword("where")
+		if err := p.lex.EatKeyword("where"); err != nil {
+			return nil, err
+		}
 		pr, err := p.predicate()
 		if err != nil {
 			return nil, err
 		}
 		pred = pr
 	}
-	return NewDeleteData(tableName, pred), nil
+	limit, err := p.parseLimit()
+	if err != nil {
+		return nil, err
+	}
+	return NewLimitedDeleteData(tableName, pred, limit), nil
+}
+
+// parseLimit parses an optional "LIMIT n" clause, returning NoLimit if there is none.
+func (p *Parser) parseLimit() (int, error) {
+	if !p.lex.MatchKeyword("limit") {
+		return NoLimit, nil
+	}
+	if err := p.lex.EatKeyword("limit"); err != nil {
+		return 0, err
+	}
+	return p.lex.EatIntConstant()
+}
+
+// parseOffset parses an optional "OFFSET n" clause, returning 0 if there is none.
+func (p *Parser) parseOffset() (int, error) {
+	if !p.lex.MatchKeyword("offset") {
+		return 0, nil
+	}
+	if err := p.lex.EatKeyword("offset"); err != nil {
+		return 0, err
+	}
+	return p.lex.EatIntConstant()
 }
 
 // -- Insert Commands --
@@ -459,17 +1322,89 @@ func (p *Parser) insert() (*InsertData, error) {
 	if err := p.lex.EatKeyword("values"); err != nil {
 		return nil, err
 	}
-	if err := p.lex.EatDelim('('); err != nil {
+	rows, err := p.valuesList()
+	if err != nil {
 		return nil, err
 	}
-	vals, err := p.constList()
-	if err != nil {
+	var onConflict *OnConflictData
+	if p.lex.MatchKeyword("on") {
+		if onConflict, err = p.onConflict(); err != nil {
+			return nil, err
+		}
+	}
+	if len(rows) > 1 {
+		return NewInsertRowsData(tableName, fields, rows, onConflict), nil
+	}
+	if onConflict == nil {
+		return NewInsertData(tableName, fields, rows[0]), nil
+	}
+	return NewUpsertData(tableName, fields, rows[0], onConflict), nil
+}
+
+// onConflict parses "ON CONFLICT [(field)] DO NOTHING" or "ON CONFLICT (field) DO UPDATE SET field = expression [, ...]".
+// The words CONFLICT, DO and NOTHING are not reserved, so that they can still be used as names.
+func (p *Parser) onConflict() (*OnConflictData, error) {
+	if err := p.lex.EatKeyword("on"); err != nil {
 		return nil, err
 	}
-	if err := p.lex.EatDelim(')'); err != nil {
+	if err := p.lex.EatKeyword("conflict"); err != nil {
+		return nil, err
+	}
+	targetField := ""
+	if p.lex.MatchDelim('(') {
+		if err := p.lex.EatDelim('('); err != nil {
+			return nil, err
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatDelim(')'); err != nil {
+			return nil, err
+		}
+		targetField = field
+	}
+	if err := p.lex.EatKeyword("do"); err != nil {
+		return nil, err
+	}
+
+	if p.lex.MatchKeyword("nothing") {
+		if err := p.lex.EatKeyword("nothing"); err != nil {
+			return nil, err
+		}
+		return NewOnConflictData(targetField, DoNothing, nil), nil
+	}
+
+	if err := p.lex.EatKeyword("update"); err != nil {
+		return nil, err
+	}
+	if targetField == "" {
+		return nil, &SyntaxError{Message: "ON CONFLICT DO UPDATE requires a conflict target"}
+	}
+	if err := p.lex.EatKeyword("set"); err != nil {
 		return nil, err
 	}
-	return NewInsertData(tableName, fields, vals), nil
+	var assignments []Assignment
+	for {
+		fieldName, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatOperator("="); err != nil {
+			return nil, err
+		}
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, NewAssignment(fieldName, value))
+		if !p.lex.MatchDelim(',') {
+			return NewOnConflictData(targetField, DoUpdate, assignments), nil
+		}
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, err
+		}
+	}
 }
 
 func (p *Parser) fieldList() ([]string, error) {
@@ -479,7 +1414,9 @@ func (p *Parser) fieldList() ([]string, error) {
 	}
 	fields := []string{f}
 	if p.lex.MatchDelim(',') {
-		_ = p.lex.EatDelim(',')
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, err
+		}
 		rest, err := p.fieldList()
 		if err != nil {
 			return nil, err
@@ -496,7 +1433,9 @@ func (p *Parser) constList() ([]any, error) {
 	}
 	vals := []any{c}
 	if p.lex.MatchDelim(',') {
-		_ = p.lex.EatDelim(',')
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, err
+		}
 		rest, err := p.constList()
 		if err != nil {
 			return nil, err
@@ -532,14 +1471,20 @@ func (p *Parser) modify() (*ModifyData, error) {
 	}
 	pred := query.NewPredicate()
 	if p.lex.MatchKeyword("where") {
-		_ = p.lex.EatKeyword("where")
+		if err := p.lex.EatKeyword("where"); err != nil {
+			return nil, err
+		}
 		pr, err := p.predicate()
 		if err != nil {
 			return nil, err
 		}
 		pred = pr
 	}
-	return NewModifyData(tableName, fieldName, newVal, pred), nil
+	limit, err := p.parseLimit()
+	if err != nil {
+		return nil, err
+	}
+	return NewLimitedModifyData(tableName, fieldName, newVal, pred, limit), nil
 }
 
 // -- Create Table Commands --
@@ -562,7 +1507,18 @@ func (p *Parser) createTable() (*CreateTableData, error) {
 	if err := p.lex.EatDelim(')'); err != nil {
 		return nil, err
 	}
-	return NewCreateTableData(tableName, sch), nil
+	// The words of the optional "WITH COMPRESSION" clause are not reserved, so that they can still be used as identifiers.
+	compressed := false
+	if p.lex.MatchKeyword("with") {
+		if err := p.lex.EatKeyword("with"); err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatKeyword("compression"); err != nil {
+			return nil, err
+		}
+		compressed = true
+	}
+	return NewCompressedCreateTableData(tableName, sch, compressed), nil
 }
 
 func (p *Parser) fieldDefs() (*record.Schema, error) {
@@ -571,7 +1527,9 @@ func (p *Parser) fieldDefs() (*record.Schema, error) {
 		return nil, err
 	}
 	if p.lex.MatchDelim(',') {
-		_ = p.lex.EatDelim(',')
+		if err := p.lex.EatDelim(','); err != nil {
+			return nil, err
+		}
 		schema2, err := p.fieldDefs()
 		if err != nil {
 			return nil, err
@@ -594,21 +1552,35 @@ func (p *Parser) fieldType(fieldName string) (*record.Schema, error) {
 
 	switch {
 	case p.lex.MatchKeyword("int"):
-		_ = p.lex.EatKeyword("int")
+		if err := p.lex.EatKeyword("int"); err != nil {
+			return nil, err
+		}
 		schema.AddIntField(fieldName)
 
+	case p.lex.MatchKeyword("float"):
+		if err := p.lex.EatKeyword("float"); err != nil {
+			return nil, err
+		}
+		schema.AddFloatField(fieldName)
+
 	case p.lex.MatchKeyword("varchar"):
-		_ = p.lex.EatKeyword("varchar")
+		if err := p.lex.EatKeyword("varchar"); err != nil {
+			return nil, err
+		}
 		if err := p.parseVarcharLength(fieldName, schema); err != nil {
 			return nil, err
 		}
 
 	case p.lex.MatchKeyword("bool"):
-		_ = p.lex.EatKeyword("bool")
+		if err := p.lex.EatKeyword("bool"); err != nil {
+			return nil, err
+		}
 		schema.AddBoolField(fieldName)
 
 	case p.lex.MatchKeyword("date"):
-		_ = p.lex.EatKeyword("date")
+		if err := p.lex.EatKeyword("date"); err != nil {
+			return nil, err
+		}
 		schema.AddDateField(fieldName)
 
 	default:
@@ -619,12 +1591,16 @@ func (p *Parser) fieldType(fieldName string) (*record.Schema, error) {
 }
 
 func (p *Parser) parseVarcharLength(fieldName string, schema *record.Schema) error {
-	_ = p.lex.EatDelim('(')
+	if err := p.lex.EatDelim('('); err != nil {
+		return err
+	}
 	length, err := p.lex.EatIntConstant()
 	if err != nil {
 		return err
 	}
-	_ = p.lex.EatDelim(')')
+	if err := p.lex.EatDelim(')'); err != nil {
+		return err
+	}
 	schema.AddStringField(fieldName, length)
 	return nil
 }
@@ -646,12 +1622,25 @@ func (p *Parser) createView() (*CreateViewData, error) {
 	if err != nil {
 		return nil, err
 	}
+	// The definition of a view is stored as the text of its query, which is parsed again when the view is read.
+	if qd.String() == "" {
+		return nil, &SyntaxError{Message: fmt.Sprintf("the query of view %s cannot be stored as text", viewName)}
+	}
 	return NewCreateViewData(viewName, qd), nil
 }
 
 // -- Create Index Commands --
 
+// createIndex parses the rest of a CREATE [UNIQUE] INDEX statement, whose parenthesized list of fields
+// has several fields for a composite index.
+// The words UNIQUE and USING are not reserved, so that they can still be used as names.
 func (p *Parser) createIndex() (*CreateIndexData, error) {
+	unique := p.lex.MatchKeyword("unique")
+	if unique {
+		if err := p.lex.EatKeyword("unique"); err != nil {
+			return nil, err
+		}
+	}
 	if err := p.lex.EatKeyword("index"); err != nil {
 		return nil, err
 	}
@@ -669,12 +1658,196 @@ func (p *Parser) createIndex() (*CreateIndexData, error) {
 	if err := p.lex.EatDelim('('); err != nil {
 		return nil, err
 	}
-	fieldName, err := p.field()
+	fieldNames, err := p.fieldList()
 	if err != nil {
 		return nil, err
 	}
 	if err := p.lex.EatDelim(')'); err != nil {
 		return nil, err
 	}
-	return NewCreateIndexData(indexName, tableName, fieldName), nil
+	fieldName := fieldNames[0]
+	indexType := ""
+	if p.lex.MatchKeyword("using") {
+		if err := p.lex.EatKeyword("using"); err != nil {
+			return nil, err
+		}
+		if indexType, err = p.lex.EatId(); err != nil {
+			return nil, err
+		}
+	}
+	predicate := ""
+	if p.lex.MatchKeyword("where") {
+		// A partial index keeps the predicate's source text, which is what the catalog stores.
+		if err := p.lex.EatKeyword("where"); err != nil {
+			return nil, err
+		}
+		start := p.lex.tokenStart
+		if _, err := p.predicate(); err != nil {
+			return nil, err
+		}
+		predicate = p.lex.sourceFrom(start)
+	}
+
+	var data *CreateIndexData
+	switch {
+	case unique:
+		data = NewCreateUniqueIndexData(indexName, tableName, fieldName, predicate)
+	case predicate != "":
+		data = NewCreatePartialIndexData(indexName, tableName, fieldName, predicate)
+	default:
+		data = NewCreateIndexData(indexName, tableName, fieldName)
+	}
+	return data.WithFields(fieldNames).Using(indexType), nil
+}
+
+// -- Debug Commands --
+
+// IsDumpBlock returns true if the statement is a DUMP BLOCK statement.
+// The words of the statement are not reserved, so that they can still be used as identifiers.
+func (p *Parser) IsDumpBlock() bool {
+	return p.lex.MatchKeyword("dump")
+}
+
+// DumpBlock parses a statement of the form "dump block tablename n".
+func (p *Parser) DumpBlock() (*DumpBlockData, error) {
+	if err := p.lex.EatKeyword("dump"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("block"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	blockNumber, err := p.lex.EatIntConstant()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchEOF() {
+		return nil, &SyntaxError{Message: "unexpected input after block number"}
+	}
+	return NewDumpBlockData(tableName, blockNumber), nil
+}
+
+// -- Dump and Restore Commands --
+
+// dumpDatabase parses a statement of the form "dump database to 'filename'".
+// The words DUMP, DATABASE and TO are not reserved, so that they can still be used as names.
+func (p *Parser) dumpDatabase() (*DumpDatabaseData, error) {
+	if err := p.lex.EatKeyword("dump"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("database"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("to"); err != nil {
+		return nil, err
+	}
+	fileName, err := p.lex.EatStringConstant()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchEOF() {
+		return nil, &SyntaxError{Message: "unexpected input after file name"}
+	}
+	return NewDumpDatabaseData(fileName), nil
+}
+
+// restore parses a statement of the form "restore from 'filename'".
+// The word RESTORE is not reserved, so that it can still be used as a name.
+func (p *Parser) restore() (*RestoreData, error) {
+	if err := p.lex.EatKeyword("restore"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("from"); err != nil {
+		return nil, err
+	}
+	fileName, err := p.lex.EatStringConstant()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchEOF() {
+		return nil, &SyntaxError{Message: "unexpected input after file name"}
+	}
+	return NewRestoreData(fileName), nil
+}
+
+// IsShowConstraints returns true if the statement is a SHOW CONSTRAINTS statement.
+// The words of the statement are not reserved, so that they can still be used as identifiers.
+func (p *Parser) IsShowConstraints() bool {
+	return p.lex.MatchKeyword("show")
+}
+
+// ShowConstraints parses a statement of the form "show constraints tablename".
+func (p *Parser) ShowConstraints() (*ShowConstraintsData, error) {
+	if err := p.lex.EatKeyword("show"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("constraints"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchEOF() {
+		return nil, &SyntaxError{Message: "unexpected input after table name"}
+	}
+	return NewShowConstraintsData(tableName), nil
+}
+
+// IsCheckTable returns true if the statement is a CHECK TABLE statement.
+// The word CHECK is not reserved, so that it can still be used as an identifier.
+func (p *Parser) IsCheckTable() bool {
+	return p.lex.MatchKeyword("check")
+}
+
+// CheckTable parses a statement of the form "check table tablename".
+func (p *Parser) CheckTable() (*CheckTableData, error) {
+	if err := p.lex.EatKeyword("check"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("table"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchEOF() {
+		return nil, &SyntaxError{Message: "unexpected input after table name"}
+	}
+	return NewCheckTableData(tableName), nil
+}
+
+// IsSetQueryTimeout returns true if the statement is a SET QUERY TIMEOUT statement.
+// No other statement starts with SET, and the other words of the statement are not reserved.
+func (p *Parser) IsSetQueryTimeout() bool {
+	return p.lex.MatchKeyword("set")
+}
+
+// SetQueryTimeout parses a statement of the form "set query timeout n",
+// where n is a number of milliseconds, zero meaning no timeout.
+func (p *Parser) SetQueryTimeout() (*SetQueryTimeoutData, error) {
+	if err := p.lex.EatKeyword("set"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("query"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("timeout"); err != nil {
+		return nil, err
+	}
+	milliseconds, err := p.lex.EatIntConstant()
+	if err != nil {
+		return nil, err
+	}
+	if milliseconds < 0 {
+		return nil, &SyntaxError{Message: "query timeout must not be negative"}
+	}
+	if !p.lex.MatchEOF() {
+		return nil, &SyntaxError{Message: "unexpected input after query timeout"}
+	}
+	return NewSetQueryTimeoutData(milliseconds), nil
 }
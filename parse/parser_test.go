@@ -1,7 +1,14 @@
 package parse
 
 import (
+	"errors"
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/query/functions"
 	"github.com/JyotinderSingh/dropdb/types"
+	"io"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +37,291 @@ func TestParserSelect(t *testing.T) {
 	assert.Contains(t, predStr, "name = Alice")
 }
 
+func TestParserOrPredicates(t *testing.T) {
+	tests := []struct {
+		where    string
+		expected string
+	}{
+		{"dept = 10 OR dept = 20", "(dept = 10 or dept = 20)"},
+		{"a = 1 AND b = 2 OR c = 3", "(a = 1 and b = 2 or c = 3)"},
+		{"a = 1 OR b = 2 AND c = 3", "(a = 1 or b = 2 and c = 3)"},
+		{"(a = 1 OR b = 2) AND c > 3", "c > 3 and (a = 1 or b = 2)"},
+		{"((a = 1)) AND (b = 2 AND c = 3)", "a = 1 and b = 2 and c = 3"},
+		{"a = 1 OR (b = 2 OR c = 3)", "(a = 1 or (b = 2 or c = 3))"},
+	}
+	for _, tt := range tests {
+		qd, err := NewParser("SELECT a FROM t WHERE " + tt.where).Query()
+		require.NoError(t, err, tt.where)
+		assert.Equal(t, tt.expected, qd.Pred().String(), tt.where)
+
+		// The predicate reads back as itself, as the definitions of views and partial indexes do.
+		reparsed, err := NewParser(qd.Pred().String()).Predicate()
+		require.NoError(t, err, tt.where)
+		assert.Equal(t, tt.expected, reparsed.String(), tt.where)
+	}
+
+	for _, where := range []string{"a = 1 OR", "(a = 1 OR b = 2", "()", "or = 1"} {
+		_, err := NewParser("SELECT a FROM t WHERE " + where).Query()
+		assert.Error(t, err, where)
+	}
+}
+
+func TestParserNot(t *testing.T) {
+	tests := []struct {
+		where    string
+		expected string
+	}{
+		{"NOT active", "active <> true"},
+		{"active AND NOT deleted", "active = true and deleted <> true"},
+		{"NOT (age > 30)", "age <= 30"},
+		{"NOT age > 30 AND dept = 10", "age <= 30 and dept = 10"},
+		{"NOT (age > 30 AND dept = 10)", "(age <= 30 or dept <> 10)"},
+		{"NOT (age > 30 OR dept = 10)", "age <= 30 and dept <> 10"},
+		{"NOT NOT (age > 30)", "age > 30"},
+		{"NOT (NOT (age > 30 AND dept = 10))", "age > 30 and dept = 10"},
+	}
+	for _, tt := range tests {
+		qd, err := NewParser("SELECT a FROM t WHERE " + tt.where).Query()
+		require.NoError(t, err, tt.where)
+		assert.Equal(t, tt.expected, qd.Pred().String(), tt.where)
+	}
+
+	for _, where := range []string{"NOT", "NOT (age > 30", "age > NOT 30"} {
+		_, err := NewParser("SELECT a FROM t WHERE " + where).Query()
+		assert.Error(t, err, where)
+	}
+}
+
+func TestParserUnions(t *testing.T) {
+	qd, err := NewParser("SELECT a, b FROM t WHERE a > 1 UNION SELECT c, d FROM u UNION ALL SELECT e, f FROM v").Query()
+	require.NoError(t, err)
+
+	// The unions are added to the first query, and the clauses of each query belong to it.
+	assert.Equal(t, []string{"a", "b"}, qd.Fields())
+	assert.Equal(t, "a > 1", qd.Pred().String())
+	require.Len(t, qd.Unions(), 2)
+	assert.False(t, qd.Unions()[0].All())
+	assert.Equal(t, []string{"c", "d"}, qd.Unions()[0].Query().Fields())
+	assert.True(t, qd.Unions()[1].All())
+	assert.Equal(t, []string{"v"}, qd.Unions()[1].Query().Tables())
+	assert.Empty(t, qd.Unions()[0].Query().Unions())
+	assert.Equal(t, "select a, b from t where a > 1 union select c, d from u union all select e, f from v", qd.String())
+
+	qd, err = NewParser("SELECT a FROM t UNION SELECT b FROM u ORDER BY b").Query()
+	require.NoError(t, err)
+	assert.Empty(t, qd.OrderBy())
+	assert.Len(t, qd.Unions()[0].Query().OrderBy(), 1)
+
+	// A wildcard may stand for any number of fields, which is checked once the tables are read.
+	_, err = NewParser("SELECT * FROM t UNION SELECT c, d FROM u").Query()
+	assert.NoError(t, err)
+
+	for _, sql := range []string{
+		"SELECT a FROM t UNION SELECT c, d FROM u",
+		"SELECT a FROM t UNION ALL",
+		"SELECT a FROM t UNION ALL ALL SELECT b FROM u",
+	} {
+		_, err := NewParser(sql).Query()
+		assert.Error(t, err, sql)
+	}
+}
+
+func TestParserSubqueries(t *testing.T) {
+	qd, err := NewParser("SELECT ename FROM emps WHERE salary > 10 AND dept IN (SELECT did FROM depts WHERE region = 'east') " +
+		"AND EXISTS (SELECT pid FROM projects WHERE pid IN (SELECT project FROM assignments))").Query()
+	require.NoError(t, err)
+
+	// The subquery conditions are kept apart from the predicate they are conjoined with.
+	assert.Equal(t, "salary > 10", qd.Pred().String())
+	require.Len(t, qd.SemiJoins(), 2)
+	in, exists := qd.SemiJoins()[0], qd.SemiJoins()[1]
+	assert.Equal(t, "dept", in.Field())
+	assert.Equal(t, []string{"did"}, in.Subquery().Fields())
+	assert.Equal(t, "region = east", in.Subquery().Pred().String())
+	assert.Empty(t, in.Subquery().SemiJoins())
+	assert.Equal(t, "", exists.Field())
+	require.Len(t, exists.Subquery().SemiJoins(), 1, "the subquery conditions of a subquery belong to it")
+	assert.Equal(t, "pid", exists.Subquery().SemiJoins()[0].Field())
+
+	// The query reads back as itself, as the definition of a view does.
+	expected := "select ename from emps where salary > 10 and dept in (select did from depts where region = 'east') " +
+		"and exists (select pid from projects where pid in (select project from assignments))"
+	assert.Equal(t, expected, qd.String())
+
+	qd, err = NewParser("SELECT a FROM t WHERE a IN (SELECT b FROM u)").Query()
+	require.NoError(t, err)
+	assert.Empty(t, qd.Pred().Terms())
+	assert.Equal(t, "select a from t where a in (select b from u)", qd.String())
+
+	for _, where := range []string{
+		"a IN (SELECT b, c FROM u)",
+		"a NOT IN (SELECT b FROM u)",
+		"a + 1 IN (SELECT b FROM u)",
+		"a = 1 OR a IN (SELECT b FROM u)",
+		"a IN (SELECT b FROM u) OR a = 1",
+		"NOT EXISTS (SELECT b FROM u)",
+		"(a = 1 OR (a IN (SELECT b FROM u)))",
+		"a IN (SELECT b FROM u",
+	} {
+		_, err := NewParser("SELECT a FROM t WHERE " + where).Query()
+		assert.Error(t, err, where)
+	}
+	_, err = NewParser("SELECT a, COUNT(b) FROM t GROUP BY a HAVING a IN (SELECT c FROM u)").Query()
+	assert.Error(t, err, "subquery conditions are only allowed in the WHERE clause")
+	_, err = NewParser("DELETE FROM t WHERE a IN (SELECT b FROM u)").UpdateCmd()
+	assert.Error(t, err, "subquery conditions are only allowed in queries")
+}
+
+func TestParserNullTests(t *testing.T) {
+	tests := []struct {
+		where    string
+		expected string
+	}{
+		{"email IS NULL", "email is null"},
+		{"email IS NOT NULL AND age > 30", "email is not null and age > 30"},
+		{"NOT (email IS NULL)", "email is not null"},
+		{"NOT (email IS NOT NULL OR age = 1)", "email is null and age <> 1"},
+		{"age = NULL", "age = null"},
+	}
+	for _, tt := range tests {
+		qd, err := NewParser("SELECT a FROM t WHERE " + tt.where).Query()
+		require.NoError(t, err, tt.where)
+		assert.Equal(t, tt.expected, qd.Pred().String(), tt.where)
+
+		reparsed, err := NewParser(qd.Pred().String()).Predicate()
+		require.NoError(t, err, tt.where)
+		assert.Equal(t, tt.expected, reparsed.String(), tt.where)
+	}
+
+	for _, where := range []string{"email IS", "email IS NOT", "email IS 5", "email IS NOT 5"} {
+		_, err := NewParser("SELECT a FROM t WHERE " + where).Query()
+		assert.Error(t, err, where)
+	}
+
+	cmd, err := NewParser("INSERT INTO t (id, email) VALUES (1, NULL), (NULL, 'a@b')").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{1, nil}, {nil, "a@b"}}, cmd.(*InsertData).Rows())
+
+	formatted, err := FormatConstant(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "null", formatted)
+}
+
+func TestParserPatternMatches(t *testing.T) {
+	tests := []struct {
+		where    string
+		expected string
+	}{
+		{"name LIKE 'ab%'", "name like ab%"},
+		{"name NOT LIKE '%b_' AND age > 30", "name not like %b_ and age > 30"},
+		{"NOT (name LIKE 'a%')", "name not like a%"},
+		{"NOT (name NOT LIKE 'a%' OR age = 1)", "name like a% and age <> 1"},
+		{"(name) LIKE 'a\\_%'", "name like a\\_%"},
+	}
+	for _, tt := range tests {
+		qd, err := NewParser("SELECT a FROM t WHERE " + tt.where).Query()
+		require.NoError(t, err, tt.where)
+		assert.Equal(t, tt.expected, qd.Pred().String(), tt.where)
+	}
+
+	for _, where := range []string{"name LIKE", "name NOT 'a%'", "name NOT NULL"} {
+		_, err := NewParser("SELECT a FROM t WHERE " + where).Query()
+		assert.Error(t, err, where)
+	}
+}
+
+func TestParserBetweenAndInLists(t *testing.T) {
+	tests := []struct {
+		where    string
+		expected string
+	}{
+		{"age BETWEEN 20 AND 30", "age >= 20 and age <= 30"},
+		{"age BETWEEN 20 AND 30 AND id = 1", "age >= 20 and age <= 30 and id = 1"},
+		{"age NOT BETWEEN 20 AND 30", "(age < 20 or age > 30)"},
+		{"age + 1 BETWEEN id AND id * 2", "age + 1 >= id and age + 1 <= id * 2"},
+		{"dept IN ('Sales', 'HR')", "(dept = Sales or dept = HR)"},
+		{"dept IN ('Sales')", "dept = Sales"},
+		{"dept NOT IN ('Sales', 'HR')", "dept <> Sales and dept <> HR"},
+		{"(dept) IN ('Sales', 'HR') OR age BETWEEN 1 AND 2", "((dept = Sales or dept = HR) or age >= 1 and age <= 2)"},
+		{"born IN ('2024-01-01', '2024-02-01')", "(born = 2024-01-01 or born = 2024-02-01)"},
+	}
+	for _, tt := range tests {
+		qd, err := NewParser("SELECT a FROM t WHERE " + tt.where).Query()
+		require.NoError(t, err, tt.where)
+		assert.Equal(t, tt.expected, qd.Pred().String(), tt.where)
+	}
+
+	for _, where := range []string{"age BETWEEN 20", "age BETWEEN 20 OR 30", "dept IN ()", "dept IN ('a',)", "dept IN ('a'", "dept NOT = 1"} {
+		_, err := NewParser("SELECT a FROM t WHERE " + where).Query()
+		assert.Error(t, err, where)
+	}
+}
+
+func TestParserWildcard(t *testing.T) {
+	qd, err := NewParser("SELECT * FROM emp, dept").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{Wildcard}, qd.Fields())
+	assert.Equal(t, "select * from emp, dept", qd.String())
+	_, ok := WildcardTable(qd.Fields()[0])
+	assert.False(t, ok)
+
+	qd, err = NewParser("SELECT id, *, COUNT(id) FROM emp GROUP BY id").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", Wildcard}, qd.Fields())
+	assert.Len(t, qd.Aggregates(), 1)
+
+	_, err = NewParser("SELECT * *  FROM emp").Query()
+	assert.Error(t, err)
+}
+
+func TestParserQualifiedWildcard(t *testing.T) {
+	qd, err := NewParser("SELECT e.*, dept_name FROM emp, dept WHERE dept_id = id").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e.*", "dept_name"}, qd.Fields())
+	assert.Equal(t, "select e.*, dept_name from emp, dept where dept_id = id", qd.String())
+
+	tableName, ok := WildcardTable(qd.Fields()[0])
+	assert.True(t, ok)
+	assert.Equal(t, "e", tableName)
+	_, ok = WildcardTable(qd.Fields()[1])
+	assert.False(t, ok)
+
+	for _, sql := range []string{"SELECT e. FROM emp", "SELECT e.name. FROM emp", "SELECT e.* + 1 FROM emp"} {
+		_, err := NewParser(sql).Query()
+		assert.Error(t, err, sql)
+	}
+}
+
+func TestParserQualifiedFields(t *testing.T) {
+	qd, err := NewParser("SELECT e1.name, e2.name AS boss FROM emp e1, emp AS e2 WHERE e1.manager = e2.id ORDER BY e2.name").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e1.name", "e2.name"}, qd.Fields())
+	assert.Equal(t, []string{"emp", "emp"}, qd.Tables())
+	assert.Equal(t, []string{"e1", "e2"}, qd.Qualifiers())
+	assert.True(t, qd.Qualified())
+	assert.Equal(t, "e2.name", qd.OrderBy()[0].Field())
+	assert.Equal(t, "select e1.name, e2.name as boss from emp e1, emp e2 where e1.manager = e2.id order by e2.name", qd.String())
+
+	// The string of a query parses back to the same query.
+	reparsed, err := NewParser(qd.String()).Query()
+	require.NoError(t, err)
+	assert.Equal(t, qd.String(), reparsed.String())
+	assert.Equal(t, qd.Qualifiers(), reparsed.Qualifiers())
+
+	// A table without an alias is qualified by its name, and a query is only qualified if it qualifies a field.
+	qd, err = NewParser("SELECT name, dept.title FROM emp, dept GROUP BY name, dept.title").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"emp", "dept"}, qd.Qualifiers())
+	assert.Equal(t, []string{"name", "dept.title"}, qd.GroupBy())
+	assert.True(t, qd.Qualified())
+	qd, err = NewParser("SELECT name FROM emp, dept").Query()
+	require.NoError(t, err)
+	assert.False(t, qd.Qualified())
+
+	_, err = NewParser("SELECT e.name FROM emp e, dept e").Query()
+	assert.EqualError(t, err, "syntax error: e qualifies two tables of the FROM clause")
+}
+
 // Test INSERT statement with different constant types (string, int, bool, date).
 func TestParserInsert(t *testing.T) {
 	// Note we are supporting a date in the format YYYY-MM-DD
@@ -87,6 +379,134 @@ func TestParserDelete(t *testing.T) {
 	assert.Contains(t, predStr, "salary >= 90000")
 }
 
+// Test DELETE and UPDATE statements with an optional LIMIT.
+func TestParserLimit(t *testing.T) {
+	cmd, err := NewParser("DELETE FROM events WHERE processed = true LIMIT 1000").UpdateCmd()
+	require.NoError(t, err)
+	deleteData, ok := cmd.(*DeleteData)
+	require.True(t, ok)
+	assert.Equal(t, "processed = true", deleteData.Predicate().String())
+	assert.Equal(t, 1000, deleteData.Limit())
+
+	cmd, err = NewParser("DELETE FROM events LIMIT 10").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, 10, cmd.(*DeleteData).Limit())
+
+	cmd, err = NewParser("UPDATE events SET processed = true WHERE id > 5 LIMIT 3").UpdateCmd()
+	require.NoError(t, err)
+	modData, ok := cmd.(*ModifyData)
+	require.True(t, ok)
+	assert.Equal(t, "id > 5", modData.Predicate().String())
+	assert.Equal(t, 3, modData.Limit())
+
+	// Statements without a LIMIT clause are not limited.
+	cmd, err = NewParser("DELETE FROM events WHERE processed = true").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, NoLimit, cmd.(*DeleteData).Limit())
+
+	_, err = NewParser("DELETE FROM events LIMIT many").UpdateCmd()
+	assert.Error(t, err)
+}
+
+func TestParserDumpBlock(t *testing.T) {
+	parser := NewParser("DUMP BLOCK orders 3")
+	require.True(t, parser.IsDumpBlock())
+	data, err := parser.DumpBlock()
+	require.NoError(t, err)
+	assert.Equal(t, "orders", data.TableName())
+	assert.Equal(t, 3, data.BlockNumber())
+
+	// The words of the statement are not reserved.
+	assert.False(t, NewParser("SELECT dump, block FROM t").IsDumpBlock())
+	_, err = NewParser("SELECT dump, block FROM t").Query()
+	assert.NoError(t, err)
+
+	_, err = NewParser("DUMP BLOCK orders").DumpBlock()
+	assert.Error(t, err)
+	_, err = NewParser("DUMP BLOCK orders 3 rows").DumpBlock()
+	assert.Error(t, err)
+}
+
+func TestParserCheckTable(t *testing.T) {
+	parser := NewParser("CHECK TABLE users")
+	require.True(t, parser.IsCheckTable())
+	data, err := parser.CheckTable()
+	require.NoError(t, err)
+	assert.Equal(t, "users", data.TableName())
+
+	// The statement can be executed as an update, returning the number of findings.
+	cmd, err := NewParser("check table users").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, data, cmd)
+
+	// The word CHECK is not reserved.
+	assert.False(t, NewParser("SELECT check FROM t").IsCheckTable())
+	_, err = NewParser("SELECT check FROM t").Query()
+	assert.NoError(t, err)
+
+	_, err = NewParser("CHECK TABLE").CheckTable()
+	assert.Error(t, err)
+	_, err = NewParser("CHECK users").CheckTable()
+	assert.Error(t, err)
+	_, err = NewParser("CHECK TABLE users now").CheckTable()
+	assert.Error(t, err)
+}
+func TestParserDrop(t *testing.T) {
+	cmd, err := NewParser("DROP TABLE users").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, NewDropTableData("users"), cmd)
+
+	cmd, err = NewParser("drop index users_id").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, NewDropIndexData("users_id"), cmd)
+
+	// The word DROP is not reserved.
+	_, err = NewParser("SELECT drop FROM t").Query()
+	assert.NoError(t, err)
+
+	for _, sql := range []string{"DROP TABLE", "DROP users", "DROP VIEW v", "DROP INDEX users_id now"} {
+		_, err := NewParser(sql).UpdateCmd()
+		assert.Error(t, err, sql)
+	}
+}
+
+func TestParserShowConstraints(t *testing.T) {
+	parser := NewParser("SHOW CONSTRAINTS users")
+	require.True(t, parser.IsShowConstraints())
+	data, err := parser.ShowConstraints()
+	require.NoError(t, err)
+	assert.Equal(t, "users", data.TableName())
+
+	// The words of the statement are not reserved.
+	assert.False(t, NewParser("SELECT show, constraints FROM t").IsShowConstraints())
+	_, err = NewParser("SELECT show, constraints FROM t").Query()
+	assert.NoError(t, err)
+
+	_, err = NewParser("SHOW CONSTRAINTS").ShowConstraints()
+	assert.Error(t, err)
+	_, err = NewParser("SHOW CONSTRAINTS users now").ShowConstraints()
+	assert.Error(t, err)
+}
+
+func TestParserSetQueryTimeout(t *testing.T) {
+	parser := NewParser("SET QUERY TIMEOUT 5000")
+	require.True(t, parser.IsSetQueryTimeout())
+	data, err := parser.SetQueryTimeout()
+	require.NoError(t, err)
+	assert.Equal(t, 5000, data.Milliseconds())
+
+	assert.False(t, NewParser("UPDATE t SET query = 1").IsSetQueryTimeout())
+	_, err = NewParser("SELECT query, timeout FROM t").Query()
+	assert.NoError(t, err)
+
+	_, err = NewParser("SET QUERY TIMEOUT").SetQueryTimeout()
+	assert.Error(t, err)
+	_, err = NewParser("SET QUERY TIMEOUT -5").SetQueryTimeout()
+	assert.Error(t, err)
+	_, err = NewParser("SET QUERY TIMEOUT 5 ms").SetQueryTimeout()
+	assert.Error(t, err)
+}
+
 // Test UPDATE statement with a single "set" and optional WHERE.
 func TestParserUpdate(t *testing.T) {
 	sql := "UPDATE projects SET status = 'Completed' WHERE end_date <= 2025-12-31"
@@ -106,6 +526,56 @@ func TestParserUpdate(t *testing.T) {
 	assert.Contains(t, predStr, "end_date <= 2025-12-31")
 }
 
+func TestParserArithmetic(t *testing.T) {
+	// Multiplication and division bind tighter than addition and subtraction, which apply from left to right.
+	for sql, expected := range map[string]string{
+		"a + b * 2":       "a + b * 2",
+		"(a + b) * 2":     "(a + b) * 2",
+		"a - b - c":       "a - b - c",
+		"a - (b - c)":     "a - (b - c)",
+		"a / 2 * 3":       "a / 2 * 3",
+		"a*(b+1)/(c-2)":   "a * (b + 1) / (c - 2)",
+		"12 - 3":          "12 - 3",
+		"a - -1":          "a - -1",
+		"(((a)))":         "a",
+		"salary * 1.5":    "salary * 1.5",
+		"(a + 1) / 2 + 3": "(a + 1) / 2 + 3",
+		"a + ":            "",
+		"(a + 1":          "",
+	} {
+		pred, err := NewParser("SELECT x FROM t WHERE " + sql + " = 0").Query()
+		if expected == "" {
+			assert.Error(t, err, sql)
+			continue
+		}
+		require.NoError(t, err, sql)
+		assert.Equal(t, expected+" = 0", pred.Pred().String(), sql)
+	}
+
+	qd, err := NewParser("SELECT ename, salary * 12 AS annual, (bonus + 1) / 2 FROM emp WHERE salary * 12 > 1000").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ename", "salary * 12", "(bonus + 1) / 2"}, qd.Fields())
+	require.Len(t, qd.Computed(), 2)
+	assert.Equal(t, []string{"salary"}, qd.Computed()["salary * 12"].Fields())
+	assert.Equal(t, "annual", qd.OutputName("salary * 12"))
+	assert.Equal(t, "salary * 12 > 1000", qd.Pred().String())
+	assert.Equal(t, "select ename, salary * 12 as annual, (bonus + 1) / 2 from emp where salary * 12 > 1000", qd.String())
+
+	cmd, err := NewParser("UPDATE emp SET salary = salary + 1000 * level WHERE ename = 'bob'").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, "salary + 1000 * level", cmd.(*ModifyData).NewValue().String())
+
+	for _, sql := range []string{
+		"SELECT a + FROM t",
+		"SELECT (a + b FROM t",
+		"SELECT a FROM t WHERE a + = 1",
+		"UPDATE t SET a = a *",
+	} {
+		_, err := NewParser(sql).UpdateCmd()
+		assert.Error(t, err, sql)
+	}
+}
+
 // Test CREATE TABLE statement with multiple columns (int, varchar, bool, date).
 func TestParserCreateTable(t *testing.T) {
 	sql := `
@@ -113,7 +583,8 @@ CREATE TABLE tasks (
     id int,
     description varchar(50),
     is_done bool,
-    due_date date
+    due_date date,
+    estimate float
 )
 `
 	p := NewParser(sql)
@@ -127,7 +598,7 @@ CREATE TABLE tasks (
 	assert.Equal(t, "tasks", tableData.TableName())
 
 	sch := tableData.NewSchema()
-	assert.Equal(t, 4, len(sch.Fields()))
+	assert.Equal(t, 5, len(sch.Fields()))
 	assert.True(t, sch.HasField("id"))
 	assert.Equal(t, types.Integer, sch.Type("id"))
 	assert.True(t, sch.HasField("description"))
@@ -136,6 +607,28 @@ CREATE TABLE tasks (
 	assert.Equal(t, types.Boolean, sch.Type("is_done"))
 	assert.True(t, sch.HasField("due_date"))
 	assert.Equal(t, types.Date, sch.Type("due_date"))
+	assert.True(t, sch.HasField("estimate"))
+	assert.Equal(t, types.Float, sch.Type("estimate"))
+}
+
+func TestParserCreateTableWithCompression(t *testing.T) {
+	data, err := NewParser("CREATE TABLE logs (line varchar(200)) WITH COMPRESSION").UpdateCmd()
+	require.NoError(t, err)
+	assert.True(t, data.(*CreateTableData).Compressed())
+
+	data, err = NewParser("create table logs (line varchar(200))").UpdateCmd()
+	require.NoError(t, err)
+	assert.False(t, data.(*CreateTableData).Compressed())
+
+	// The words of the clause are not reserved.
+	data, err = NewParser("create table compression (with int) with compression").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, "compression", data.(*CreateTableData).TableName())
+	assert.True(t, data.(*CreateTableData).NewSchema().HasField("with"))
+	assert.True(t, data.(*CreateTableData).Compressed())
+
+	_, err = NewParser("create table logs (line varchar(200)) with").UpdateCmd()
+	assert.Error(t, err)
 }
 
 // Test CREATE VIEW statement with a query inside.
@@ -154,9 +647,67 @@ func TestParserCreateView(t *testing.T) {
 	// The QueryData is inside. We can do a string check:
 	viewDef := viewData.ViewDefinition()
 	assert.Contains(t, viewDef, "select name, last_login from users where is_active = true")
+
+	// The definition of a view is parsed again when it is read, so it writes every clause, and quotes its strings.
+	for sql, expected := range map[string]string{
+		"CREATE VIEW v AS SELECT id FROM t WHERE name = 'O''Brien' AND price > 2.0 AND tag IN ('a', 'b')":       "select id from t where name = 'O''Brien' and price > 2.0 and (tag = 'a' or tag = 'b')",
+		"CREATE VIEW v AS SELECT id AS num, score * 2 AS doubled FROM t ORDER BY score DESC LIMIT 3 OFFSET 1":   "select id as num, score * 2 as doubled from t order by score desc limit 3 offset 1",
+		"CREATE VIEW v AS SELECT dept, COUNT(*), SUM(salary) AS total FROM emp GROUP BY dept HAVING total > 10": "select dept, count(*), sum(salary) as total from emp group by dept having sumOfsalary > 10",
+	} {
+		cmd, err := NewParser(sql).UpdateCmd()
+		require.NoError(t, err, sql)
+		definition := cmd.(*CreateViewData).ViewDefinition()
+		assert.Equal(t, expected, definition)
+		reparsed, err := NewParser(definition).Query()
+		require.NoError(t, err, definition)
+		assert.Equal(t, definition, reparsed.String())
+	}
+
+	// A having clause reading an aggregate that is not selected cannot be written.
+	_, err = NewParser("CREATE VIEW v AS SELECT dept FROM emp GROUP BY dept HAVING MAX(salary) > 10").UpdateCmd()
+	assert.EqualError(t, err, "syntax error: the query of view v cannot be stored as text")
 }
 
 // Test CREATE INDEX statement with single field.
+func TestParserInsertOnConflict(t *testing.T) {
+	cmd, err := NewParser("INSERT INTO users (id, name) VALUES (1, 'Alice') ON CONFLICT (id) DO UPDATE SET name = 'Alice', visits = visits").UpdateCmd()
+	require.NoError(t, err)
+	insertData, ok := cmd.(*InsertData)
+	require.True(t, ok)
+	assert.Equal(t, []any{1, "Alice"}, insertData.Values())
+	onConflict := insertData.OnConflict()
+	require.NotNil(t, onConflict)
+	assert.Equal(t, "id", onConflict.TargetField())
+	assert.Equal(t, DoUpdate, onConflict.Action())
+	require.Len(t, onConflict.Assignments(), 2)
+	assert.Equal(t, "name", onConflict.Assignments()[0].FieldName())
+	assert.Equal(t, "Alice", onConflict.Assignments()[0].Value().String())
+	assert.Equal(t, "visits", onConflict.Assignments()[1].FieldName())
+	assert.Equal(t, "visits", onConflict.Assignments()[1].Value().String())
+
+	cmd, err = NewParser("insert into users (id) values (1) on conflict do nothing").UpdateCmd()
+	require.NoError(t, err)
+	onConflict = cmd.(*InsertData).OnConflict()
+	require.NotNil(t, onConflict)
+	assert.Empty(t, onConflict.TargetField())
+	assert.Equal(t, DoNothing, onConflict.Action())
+	assert.Empty(t, onConflict.Assignments())
+
+	cmd, err = NewParser("insert into users (id) values (1)").UpdateCmd()
+	require.NoError(t, err)
+	assert.Nil(t, cmd.(*InsertData).OnConflict())
+
+	for _, sql := range []string{
+		"insert into users (id) values (1) on conflict do update set name = 'x'",
+		"insert into users (id) values (1) on conflict (id) do update set",
+		"insert into users (id) values (1) on conflict (id) update set name = 'x'",
+		"insert into users (id) values (1) on conflict (id) do",
+	} {
+		_, err := NewParser(sql).UpdateCmd()
+		assert.Error(t, err, sql)
+	}
+}
+
 func TestParserCreateIndex(t *testing.T) {
 	sql := "CREATE INDEX idx_name ON people(name)"
 	p := NewParser(sql)
@@ -172,6 +723,89 @@ func TestParserCreateIndex(t *testing.T) {
 	assert.Equal(t, "name", indexData.FieldName())
 }
 
+func TestParserCreateUniqueIndex(t *testing.T) {
+	cmd, err := NewParser("CREATE UNIQUE INDEX idx_email ON people(email)").UpdateCmd()
+	require.NoError(t, err)
+	indexData, ok := cmd.(*CreateIndexData)
+	require.True(t, ok)
+	assert.Equal(t, "idx_email", indexData.IndexName())
+	assert.Equal(t, "email", indexData.FieldName())
+	assert.True(t, indexData.Unique())
+	assert.Empty(t, indexData.Predicate())
+
+	cmd, err = NewParser("create unique index idx_live_email on people(email) where deleted = false").UpdateCmd()
+	require.NoError(t, err)
+	indexData = cmd.(*CreateIndexData)
+	assert.True(t, indexData.Unique())
+	assert.Equal(t, "deleted = false", indexData.Predicate())
+
+	// UNIQUE is not reserved.
+	cmd, err = NewParser("create index idx_unique on people(unique)").UpdateCmd()
+	require.NoError(t, err)
+	indexData = cmd.(*CreateIndexData)
+	assert.False(t, indexData.Unique())
+	assert.Equal(t, "unique", indexData.FieldName())
+}
+
+func TestParserCreateIndexUsing(t *testing.T) {
+	cmd, err := NewParser("CREATE UNIQUE INDEX idx_id ON people(id) USING btree WHERE age >= 18").UpdateCmd()
+	require.NoError(t, err)
+	indexData, ok := cmd.(*CreateIndexData)
+	require.True(t, ok)
+	assert.Equal(t, "btree", indexData.IndexType())
+	assert.True(t, indexData.Unique())
+	assert.Equal(t, "age >= 18", indexData.Predicate())
+
+	cmd, err = NewParser("create index idx_name on people(name)").UpdateCmd()
+	require.NoError(t, err)
+	assert.Empty(t, cmd.(*CreateIndexData).IndexType())
+
+	_, err = NewParser("create index idx_name on people(name) using").UpdateCmd()
+	assert.Error(t, err)
+}
+
+func TestParserCreateCompositeIndex(t *testing.T) {
+	cmd, err := NewParser("CREATE UNIQUE INDEX idx_dept_hired ON emp (dept_id, hire_date) USING btree WHERE active = true").UpdateCmd()
+	require.NoError(t, err)
+	indexData, ok := cmd.(*CreateIndexData)
+	require.True(t, ok)
+	assert.Equal(t, []string{"dept_id", "hire_date"}, indexData.FieldNames())
+	assert.Equal(t, "dept_id", indexData.FieldName())
+	assert.Equal(t, "btree", indexData.IndexType())
+	assert.True(t, indexData.Unique())
+	assert.Equal(t, "active = true", indexData.Predicate())
+
+	cmd, err = NewParser("create index idx_name on people(name)").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, cmd.(*CreateIndexData).FieldNames())
+
+	_, err = NewParser("create index idx_name on people(name,)").UpdateCmd()
+	assert.Error(t, err)
+}
+
+// Test CREATE INDEX statement with a WHERE clause.
+func TestParserCreatePartialIndex(t *testing.T) {
+	sql := "CREATE INDEX idx_live ON people(name) WHERE deleted = false AND age >= 18"
+	p := NewParser(sql)
+
+	cmd, err := p.UpdateCmd()
+	require.NoError(t, err)
+
+	indexData, ok := cmd.(*CreateIndexData)
+	require.True(t, ok)
+
+	assert.Equal(t, "idx_live", indexData.IndexName())
+	assert.Equal(t, "name", indexData.FieldName())
+	assert.Equal(t, "deleted = false AND age >= 18", indexData.Predicate())
+
+	pred, err := NewParser(indexData.Predicate()).Predicate()
+	require.NoError(t, err)
+	assert.Equal(t, "deleted = false and age >= 18", pred.String())
+
+	_, err = NewParser("deleted = false extra").Predicate()
+	assert.Error(t, err)
+}
+
 // Test for invalid syntax to ensure we return an error.
 func TestParserInvalidSyntax(t *testing.T) {
 	sql := "SELECT FROM" // Missing field(s)
@@ -199,6 +833,17 @@ func TestParserGroupBy(t *testing.T) {
 	assert.Equal(t, "countOffieldname", qd.aggregates[1].FieldName())
 }
 
+func TestParserApproxCountDistinct(t *testing.T) {
+	p := NewParser("SELECT department, APPROX_COUNT_DISTINCT(name), COUNT(name) FROM employees GROUP BY department")
+
+	qd, err := p.Query()
+	require.NoError(t, err)
+
+	require.Len(t, qd.aggregates, 2)
+	assert.Equal(t, "approxCountDistinctOfname", qd.aggregates[0].FieldName())
+	assert.Equal(t, "countOfname", qd.aggregates[1].FieldName())
+}
+
 func TestParserHaving(t *testing.T) {
 	sql := `
         SELECT department, AVG(salary)
@@ -221,6 +866,62 @@ func TestParserHaving(t *testing.T) {
 	assert.Contains(t, havingStr, "avgOfsalary > 50000")
 }
 
+func TestParserHavingAggregates(t *testing.T) {
+	// An aggregate only used by the having clause is computed, but not selected.
+	qd, err := NewParser("SELECT dept FROM emp GROUP BY dept HAVING count(id) > 5").Query()
+	require.NoError(t, err)
+	require.Len(t, qd.Aggregates(), 1)
+	assert.Equal(t, "countOfid", qd.Aggregates()[0].FieldName())
+	assert.Empty(t, qd.SelectAggregates())
+
+	// An aggregate of the select list repeated by the having clause is computed once.
+	qd, err = NewParser("SELECT dept, max(salary) FROM emp GROUP BY dept HAVING max(salary) > 10 AND count(id) > 5 AND count(id) < 9").Query()
+	require.NoError(t, err)
+	require.Len(t, qd.Aggregates(), 2)
+	assert.Equal(t, "maxOfsalary", qd.Aggregates()[0].FieldName())
+	assert.Equal(t, "countOfid", qd.Aggregates()[1].FieldName())
+	require.Len(t, qd.SelectAggregates(), 1)
+	assert.Equal(t, "maxOfsalary", qd.SelectAggregates()[0].FieldName())
+}
+
+func TestParserAliases(t *testing.T) {
+	qd, err := NewParser("SELECT dept AS d, sum(amount) AS total FROM emp GROUP BY dept HAVING total > 5 ORDER BY total DESC, d").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dept"}, qd.Fields())
+	assert.Equal(t, map[string]string{"d": "dept", "total": "sumOfamount"}, qd.Aliases())
+	assert.Equal(t, "d", qd.OutputName("dept"))
+	assert.Equal(t, "total", qd.OutputName("sumOfamount"))
+	assert.Equal(t, "amount", qd.OutputName("amount"))
+
+	// The having and order by clauses are parsed with the names of the fields the aliases refer to.
+	assert.Equal(t, "sumOfamount > 5", qd.Having().String())
+	require.Len(t, qd.OrderBy(), 2)
+	assert.Equal(t, "sumOfamount", qd.OrderBy()[0].Field())
+	assert.Equal(t, "dept", qd.OrderBy()[1].Field())
+	require.Len(t, qd.Aggregates(), 1)
+
+	// The generated name of an aggregate can be used too, whatever its case.
+	qd, err = NewParser("SELECT dept, count(id) FROM emp GROUP BY dept ORDER BY countOfid").Query()
+	require.NoError(t, err)
+	assert.Equal(t, "countOfid", qd.OrderBy()[0].Field())
+
+	// The aliases of the fields are kept in the text of the query, such as the definition of a view.
+	qd, err = NewParser("SELECT id AS num, name FROM emp").Query()
+	require.NoError(t, err)
+	assert.Equal(t, "select id as num, name from emp", qd.String())
+
+	for _, sql := range []string{
+		"SELECT id AS num, name AS num FROM emp",
+		"SELECT id AS num, id FROM emp",
+		"SELECT id AS name, name FROM emp",
+		"SELECT * AS all FROM emp",
+		"SELECT id AS FROM emp",
+	} {
+		_, err := NewParser(sql).Query()
+		assert.Error(t, err, sql)
+	}
+}
+
 func TestParserOrderBy(t *testing.T) {
 	sql := `
         SELECT name, age 
@@ -239,6 +940,36 @@ func TestParserOrderBy(t *testing.T) {
 	assert.False(t, qd.orderBy[1].descending)
 }
 
+func TestParserLimitOffset(t *testing.T) {
+	tests := []struct {
+		sql    string
+		limit  int
+		offset int
+	}{
+		{"SELECT name FROM users", NoLimit, 0},
+		{"SELECT name FROM users LIMIT 10", 10, 0},
+		{"SELECT name FROM users ORDER BY name LIMIT 0", 0, 0},
+		{"SELECT name FROM users WHERE age > 30 ORDER BY name LIMIT 10 OFFSET 20", 10, 20},
+		{"SELECT name FROM users OFFSET 5", NoLimit, 5},
+	}
+	for _, tt := range tests {
+		qd, err := NewParser(tt.sql).Query()
+		require.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.limit, qd.Limit(), tt.sql)
+		assert.Equal(t, tt.offset, qd.Offset(), tt.sql)
+	}
+
+	for _, sql := range []string{
+		"SELECT name FROM users LIMIT",
+		"SELECT name FROM users LIMIT -1",
+		"SELECT name FROM users LIMIT ten",
+		"SELECT name FROM users LIMIT 10 OFFSET",
+	} {
+		_, err := NewParser(sql).Query()
+		assert.Error(t, err, sql)
+	}
+}
+
 func TestParserComplexQuery(t *testing.T) {
 	sql := `
         SELECT 
@@ -312,3 +1043,184 @@ func TestParserAggregatesWithoutGroupBy(t *testing.T) {
 	assert.Equal(t, "countOffieldname", qd.aggregates[0].FieldName())
 	assert.Equal(t, "maxOfsalary", qd.aggregates[1].FieldName())
 }
+
+func TestParserCountAll(t *testing.T) {
+	qd, err := NewParser("SELECT dept, COUNT(*) FROM emp GROUP BY dept HAVING count(*) > 1 ORDER BY countOfall DESC").Query()
+	require.NoError(t, err)
+	require.Len(t, qd.Aggregates(), 1)
+	assert.Equal(t, functions.CountAllFieldName, qd.Aggregates()[0].FieldName())
+	assert.Empty(t, qd.Aggregates()[0].AggregatedField())
+	assert.Equal(t, "countOfall > 1", qd.Having().String())
+	assert.Equal(t, "countOfall", qd.OrderBy()[0].Field())
+
+	qd, err = NewParser("SELECT count(*) AS total, count(id) FROM emp").Query()
+	require.NoError(t, err)
+	require.Len(t, qd.SelectAggregates(), 2)
+	assert.Equal(t, "total", qd.OutputName(functions.CountAllFieldName))
+	assert.Equal(t, "countOfid", qd.SelectAggregates()[1].FieldName())
+
+	for _, sql := range []string{
+		"SELECT sum(*) FROM emp",
+		"SELECT count(* FROM emp",
+		"SELECT count(*, id) FROM emp",
+	} {
+		_, err := NewParser(sql).Query()
+		assert.Error(t, err, sql)
+	}
+}
+
+// Aggregate function names are only treated as functions when followed by '('.
+func TestParserAggregateNameAsField(t *testing.T) {
+	sql := "SELECT id, count, SUM(count) FROM products WHERE count > 5"
+	p := NewParser(sql)
+
+	qd, err := p.Query()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"id", "count"}, qd.Fields())
+	require.Len(t, qd.aggregates, 1)
+	assert.Equal(t, "sumOfcount", qd.aggregates[0].FieldName())
+	assert.Equal(t, "count > 5", qd.Pred().String())
+}
+
+func TestParserParameters(t *testing.T) {
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	p := NewParserWithParameters("SELECT name FROM events WHERE day = :start_date AND id = @id AND name = :start_date",
+		&Parameters{Named: map[string]any{"start_date": day, "id": 7}})
+	qd, err := p.Query()
+	require.NoError(t, err)
+	expected := query.NewPredicateFromTerm(query.NewTerm(query.NewFieldExpression("day"), query.NewConstantExpression(day), types.EQ))
+	expected.ConjoinWith(query.NewPredicateFromTerm(query.NewTerm(query.NewFieldExpression("id"), query.NewConstantExpression(7), types.EQ)))
+	expected.ConjoinWith(query.NewPredicateFromTerm(query.NewTerm(query.NewFieldExpression("name"), query.NewConstantExpression(day), types.EQ)))
+	assert.Equal(t, expected, qd.Pred())
+
+	p = NewParserWithParameters("INSERT INTO events (id, name, active) VALUES (?, ?, ?)", &Parameters{Positional: []any{1, "a", true}})
+	cmd, err := p.UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, []any{1, "a", true}, cmd.(*InsertData).Values())
+
+	_, err = NewParserWithParameters("SELECT name FROM events WHERE id = ?", &Parameters{Named: map[string]any{"id": 1}}).Query()
+	assert.EqualError(t, err, "no value bound to parameter 1")
+	_, err = NewParser("SELECT name FROM events WHERE id = :id").Query()
+	assert.EqualError(t, err, `no value bound to parameter "id"`)
+
+	_, err = NewParserWithParameters("SELECT name FROM events WHERE id = ? AND name = :name",
+		&Parameters{Positional: []any{1}, Named: map[string]any{"name": "a"}}).Query()
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, "positional and named parameters cannot be mixed in a statement", syntaxErr.Message)
+}
+
+func TestCountPositionalParameters(t *testing.T) {
+	assert.Equal(t, 0, CountPositionalParameters("SELECT name FROM events"))
+	assert.Equal(t, 3, CountPositionalParameters("INSERT INTO events (id, name, active) VALUES (?, ?, ?)"))
+	assert.Equal(t, 1, CountPositionalParameters("SELECT name FROM events WHERE name = '?' AND id = ?"))
+	assert.Equal(t, -1, CountPositionalParameters("SELECT name FROM events WHERE id = ? AND name = :name"))
+	assert.Equal(t, -1, CountPositionalParameters("SELECT name FROM events WHERE name = 'unterminated"))
+}
+
+func TestParserOversizedStringConstant(t *testing.T) {
+	sql := "INSERT INTO t (s) VALUES ('" + strings.Repeat("x", DefaultLexerLimits.MaxStringLength+1) + "')"
+	_, err := NewParser(sql).UpdateCmd()
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, fmt.Sprintf("string constant exceeds %d bytes", DefaultLexerLimits.MaxStringLength), syntaxErr.Message)
+
+	// A lower limit applies to the statements of a parser created with it.
+	_, err = NewParserWithLimits("INSERT INTO t (s) VALUES ('abcde')", nil, LexerLimits{MaxIdentifierLength: 64, MaxStringLength: 4}).UpdateCmd()
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, "string constant exceeds 4 bytes", syntaxErr.Message)
+}
+
+func TestParserDumpAndRestore(t *testing.T) {
+	cmd, err := NewParser("DUMP DATABASE TO 'backup.sql'").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, "backup.sql", cmd.(*DumpDatabaseData).FileName())
+
+	cmd, err = NewParser("restore from 'it''s.sql'").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, "it's.sql", cmd.(*RestoreData).FileName())
+
+	_, err = NewParser("dump database to 'backup.sql' now").UpdateCmd()
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, "unexpected input after file name", syntaxErr.Message)
+}
+
+func TestFormatConstant(t *testing.T) {
+	day := time.Date(2024, 2, 29, 13, 4, 5, 0, time.UTC)
+	values := []any{42, -7, 19.99, -0.25, 20.0, 1e21, 1.5e-7, "it's 'quoted'; really", "", true, false, day, day.In(time.FixedZone("UTC+2", 2*60*60))}
+	for _, val := range values {
+		text, err := FormatConstant(val)
+		require.NoError(t, err)
+
+		// The parser reads the constant back as the same value.
+		cmd, err := NewParser("insert into t (f) values (" + text + ")").UpdateCmd()
+		require.NoError(t, err, text)
+		parsed := cmd.(*InsertData).Values()[0]
+		if date, ok := val.(time.Time); ok {
+			assert.True(t, date.Equal(parsed.(time.Time)), text)
+		} else {
+			assert.Equal(t, val, parsed, text)
+		}
+	}
+
+	_, err := FormatConstant(math.NaN())
+	assert.Error(t, err)
+	_, err = FormatConstant(float32(1.5))
+	assert.Error(t, err)
+}
+
+func TestScriptReader(t *testing.T) {
+	script := "create table t (s varchar(10));\n\ninsert into t (s) values ('a;b''c');;\n  insert into t (s) values ('')"
+	reader := NewScriptReader(strings.NewReader(script))
+	var statements []string
+	for {
+		statement, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		statements = append(statements, statement)
+	}
+	assert.Equal(t, []string{
+		"create table t (s varchar(10))",
+		"insert into t (s) values ('a;b''c')",
+		"insert into t (s) values ('')",
+	}, statements)
+
+	_, err := NewScriptReader(strings.NewReader("insert into t (s) values ('a);")).Next()
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+}
+
+func TestParserValues(t *testing.T) {
+	qd, err := NewParser("SELECT 1").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"column1"}, qd.Fields())
+	assert.Empty(t, qd.Tables())
+	assert.Equal(t, [][]any{{1}}, qd.Values())
+
+	qd, err = NewParser("VALUES (1, 'it''s', true), (-2, '', false)").Query()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"column1", "column2", "column3"}, qd.Fields())
+	assert.Equal(t, [][]any{{1, "it's", true}, {-2, "", false}}, qd.Values())
+	// The text of the query, such as the definition of a view, reads back as the same rows.
+	assert.Equal(t, "values (1, 'it''s', true), (-2, '', false)", qd.String())
+
+	cmd, err := NewParser("INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y') ON CONFLICT DO NOTHING").UpdateCmd()
+	require.NoError(t, err)
+	insert := cmd.(*InsertData)
+	assert.Equal(t, [][]any{{1, "x"}, {2, "y"}}, insert.Rows())
+	assert.Equal(t, []any{2, "y"}, insert.RowData([]any{2, "y"}).Values())
+	assert.Equal(t, DoNothing, insert.RowData(nil).OnConflict().Action())
+
+	cmd, err = NewParser("INSERT INTO t (a) VALUES (1)").UpdateCmd()
+	require.NoError(t, err)
+	assert.Equal(t, [][]any{{1}}, cmd.(*InsertData).Rows())
+
+	_, err = NewParser("VALUES (1), (2, 3)").Query()
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, "VALUES row 2 has 2 values, but row 1 has 1", syntaxErr.Message)
+}
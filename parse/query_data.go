@@ -1,10 +1,37 @@
 package parse
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/query/functions"
 )
 
+// Wildcard is the entry of the select list standing for all the fields of all the tables of a query, "*".
+const Wildcard = "*"
+
+// wildcardSuffix ends a qualified wildcard in the select list of a query.
+const wildcardSuffix = ".*"
+
+// QualifiedWildcard returns the entry of the select list standing for all the fields of the specified table, "table.*".
+func QualifiedWildcard(tableName string) string {
+	return tableName + wildcardSuffix
+}
+
+// WildcardTable returns the table whose fields the specified entry of the select list stands for, and true,
+// if the entry is a qualified wildcard. It returns false if the entry is a field.
+func WildcardTable(field string) (string, bool) {
+	return strings.CutSuffix(field, wildcardSuffix)
+}
+
+// QualifiedField returns the name of the specified field of the table of the FROM clause of a query that the
+// specified alias or table name qualifies (see QueryData#Qualifiers), such as "e.salary".
+func QualifiedField(qualifier, field string) string {
+	return qualifier + "." + field
+}
+
 type OrderByItem struct {
 	field      string
 	descending bool
@@ -14,14 +41,89 @@ func (obi *OrderByItem) Field() string {
 	return obi.field
 }
 
+// Descending returns true if the records are ordered on the field in descending order.
+func (obi *OrderByItem) Descending() bool {
+	return obi.descending
+}
+
+// SemiJoin is a condition of the WHERE clause of a query on the result of an uncorrelated subquery:
+// "field IN (subquery)", which holds for the records whose field equals a value of the single field
+// the subquery outputs, or "EXISTS (subquery)", which holds for every record if the subquery outputs any.
+// A null value is in no subquery result, and the null values a subquery outputs match no record.
+type SemiJoin struct {
+	field    string
+	subquery *QueryData
+}
+
+// Field returns the field whose values are looked for in the result of the subquery,
+// or an empty string for an EXISTS condition.
+func (sj *SemiJoin) Field() string {
+	return sj.field
+}
+
+// Subquery returns the data of the subquery.
+func (sj *SemiJoin) Subquery() *QueryData {
+	return sj.subquery
+}
+
+func (sj *SemiJoin) String() string {
+	if sj.field == "" {
+		return "exists (" + sj.subquery.String() + ")"
+	}
+	return sj.field + " in (" + sj.subquery.String() + ")"
+}
+
+// Union is a query whose records are added to those of the queries preceding it in a UNION: all of them with
+// UNION ALL, or with UNION, only those that are not output already, so that the result has no duplicate records.
+type Union struct {
+	query *QueryData
+	all   bool
+}
+
+// Query returns the data of the query whose records are added.
+func (u *Union) Query() *QueryData {
+	return u.query
+}
+
+// All returns true for UNION ALL, which keeps the duplicate records.
+func (u *Union) All() bool {
+	return u.all
+}
+
+func (u *Union) String() string {
+	if u.all {
+		return "union all " + u.query.String()
+	}
+	return "union " + u.query.String()
+}
+
 type QueryData struct {
-	fields     []string
-	tables     []string
+	fields []string
+	tables []string
+	// tableAliases are the aliases of the tables, or an empty string for a table without one.
+	// They are nil if no table has an alias.
+	tableAliases []string
+	// qualified is true if a table has an alias, or if the query qualifies one of its fields (see Qualified).
+	qualified  bool
 	predicate  *query.Predicate
 	groupBy    []string                        // Fields to group by
 	having     *query.Predicate                // Having clause predicate
 	orderBy    []OrderByItem                   // Order by clause items
 	aggregates []functions.AggregationFunction // Aggregate functions in use
+	values     [][]any                         // Rows of constants output instead of reading tables
+	limit      int                             // Maximum number of records output, or NoLimit
+	offset     int                             // Number of records skipped before the first one output
+	// selectAggregates is the number of aggregates of the select list, which come first in aggregates.
+	// The others are only used by the having clause.
+	selectAggregates int
+	// aliases map the aliases given in the select list to the fields and aggregate fields they name.
+	aliases map[string]string
+	// semiJoins are the subquery conditions conjoined with the predicate.
+	semiJoins []*SemiJoin
+	// unions are the queries whose records are added to those of this query, in order.
+	unions []*Union
+	// computed maps the fields of the select list that are arithmetic expressions to the expression they compute.
+	computed map[string]*query.Expression
 }
 
 func NewQueryData(fields, tables []string, predicate *query.Predicate) *QueryData {
@@ -29,21 +131,99 @@ func NewQueryData(fields, tables []string, predicate *query.Predicate) *QueryDat
 		fields:    fields,
 		tables:    tables,
 		predicate: predicate,
+		limit:     NoLimit,
+	}
+}
+
+// NewValuesQueryData creates the data of a query outputting the specified rows of constants, such as a VALUES list,
+// whose fields are named after their position (see ValuesColumn).
+func NewValuesQueryData(rows [][]any) *QueryData {
+	fields := make([]string, len(rows[0]))
+	for i := range fields {
+		fields[i] = ValuesColumn(i)
+	}
+	return &QueryData{
+		fields:    fields,
+		predicate: query.NewPredicate(),
+		values:    rows,
+		limit:     NoLimit,
 	}
 }
 
+// ValuesColumn returns the name of the field holding the values at the specified position, counted from 0,
+// in the rows of a VALUES list: "column1", "column2", and so on.
+func ValuesColumn(position int) string {
+	return fmt.Sprintf("column%d", position+1)
+}
+
 func (qd *QueryData) Fields() []string {
 	return qd.fields
 }
 
+// SetFields replaces the fields of the select list.
+// The planner uses it to expand the qualified wildcards of the list into the fields they stand for.
+func (qd *QueryData) SetFields(fields []string) {
+	qd.fields = fields
+}
+
 func (qd *QueryData) Tables() []string {
 	return qd.tables
 }
 
+// Qualifiers returns the name qualifying the fields of each table of the FROM clause (see QualifiedField):
+// its alias if it has one, or else its name.
+func (qd *QueryData) Qualifiers() []string {
+	qualifiers := slices.Clone(qd.tables)
+	for i, alias := range qd.tableAliases {
+		if alias != "" {
+			qualifiers[i] = alias
+		}
+	}
+	return qualifiers
+}
+
+// Qualified returns true if the query gives a table of its FROM clause an alias, or reads a field qualified
+// by the alias or the name of its table, such as "e.salary". The fields of the tables of such a query are
+// read under their qualified names, so that the query can read two tables having fields of the same name,
+// such as a table joined with itself.
+func (qd *QueryData) Qualified() bool {
+	return qd.qualified
+}
+
 func (qd *QueryData) Pred() *query.Predicate {
 	return qd.predicate
 }
 
+// SemiJoins returns the conditions on the results of subqueries (see SemiJoin) that the WHERE clause
+// conjoins with the predicate.
+func (qd *QueryData) SemiJoins() []*SemiJoin {
+	return qd.semiJoins
+}
+
+// Computed returns the fields of the select list that are arithmetic expressions, such as "salary * 12", each mapped
+// to the expression computing its values. They are named after the text of the expression, unless given an alias.
+func (qd *QueryData) Computed() map[string]*query.Expression {
+	return qd.computed
+}
+
+// Unions returns the queries whose records are added to those of this query, in order: the records of the
+// first one are added to those of this query, those of the next one to the result, and so on. The fields of
+// the result are named after those of this query, the fields of the other queries matching them by position.
+func (qd *QueryData) Unions() []*Union {
+	return qd.unions
+}
+
+// selectWidth returns the number of fields the query outputs, and false if it cannot be known before the
+// tables are read, because the select list has a wildcard.
+func (qd *QueryData) selectWidth() (int, bool) {
+	for _, field := range qd.fields {
+		if _, ok := WildcardTable(field); ok || field == Wildcard {
+			return 0, false
+		}
+	}
+	return len(qd.fields) + len(qd.SelectAggregates()), true
+}
+
 func (qd *QueryData) GroupBy() []string {
 	return qd.groupBy
 }
@@ -56,32 +236,202 @@ func (qd *QueryData) OrderBy() []OrderByItem {
 	return qd.orderBy
 }
 
+// Aggregates returns the aggregate functions computed by the grouping: those of the select list,
+// followed by those only used by the having clause.
 func (qd *QueryData) Aggregates() []functions.AggregationFunction {
 	return qd.aggregates
 }
 
+// SelectAggregates returns the aggregate functions of the select list, whose fields the query outputs.
+func (qd *QueryData) SelectAggregates() []functions.AggregationFunction {
+	return qd.aggregates[:qd.selectAggregates]
+}
+
+// Aliases returns the aliases given in the select list, mapping each alias to the field or aggregate field it names.
+// The having and order by clauses refer to a field by its alias or its own name, and are parsed with its own name.
+func (qd *QueryData) Aliases() map[string]string {
+	return qd.aliases
+}
+
+// OutputName returns the name under which the query outputs the specified field or aggregate field of its select list,
+// which is its alias if it has one, or else its own name.
+func (qd *QueryData) OutputName(field string) string {
+	for alias, name := range qd.aliases {
+		if name == field {
+			return alias
+		}
+	}
+	return field
+}
+
+// Limit returns the maximum number of records the query outputs, or NoLimit.
+// Without an ORDER BY clause, which of the records are output is unspecified.
+func (qd *QueryData) Limit() int {
+	return qd.limit
+}
+
+// Offset returns the number of records the query skips before the first one it outputs.
+func (qd *QueryData) Offset() int {
+	return qd.offset
+}
+
+// Values returns the rows of constants the query outputs instead of reading tables, or nil if it reads tables.
+func (qd *QueryData) Values() [][]any {
+	return qd.values
+}
+
 func (qd *QueryData) String() string {
-	if len(qd.fields) == 0 || len(qd.tables) == 0 {
+	result := qd.selectString()
+	if result == "" {
 		return ""
 	}
-	result := "select "
+	for _, union := range qd.unions {
+		if union.query.String() == "" {
+			return ""
+		}
+		result += " " + union.String()
+	}
+	return result
+}
+
+// selectString returns the text of the query without its unions, or an empty string if it cannot be written,
+// such as a query whose constants have no text (see FormatConstant). The constants of its predicates are
+// quoted, so that the text is parsed again into the same query, as the definition of a view is.
+func (qd *QueryData) selectString() string {
+	if qd.values != nil {
+		return valuesString(qd.values)
+	}
+	if len(qd.fields)+qd.selectAggregates == 0 || len(qd.tables) == 0 {
+		return ""
+	}
+	selectList := make([]string, 0, len(qd.fields)+qd.selectAggregates)
 	for _, fieldName := range qd.fields {
-		result += fieldName + ", "
+		text := fieldName
+		if expression, ok := qd.computed[fieldName]; ok {
+			var err error
+			if text, err = expression.Format(FormatConstant); err != nil {
+				return ""
+			}
+		}
+		if outputName := qd.OutputName(fieldName); outputName != fieldName {
+			text += " as " + outputName
+		}
+		selectList = append(selectList, text)
 	}
-	// remove final comma/space
-	if len(qd.fields) > 0 {
-		result = result[:len(result)-2]
+	for _, agg := range qd.SelectAggregates() {
+		text := aggregateString(agg)
+		if text == "" {
+			return ""
+		}
+		if outputName := qd.OutputName(agg.FieldName()); outputName != agg.FieldName() {
+			text += " as " + outputName
+		}
+		selectList = append(selectList, text)
 	}
-	result += " from "
-	for _, tableName := range qd.tables {
-		result += tableName + ", "
+	tables := slices.Clone(qd.tables)
+	for i, alias := range qd.tableAliases {
+		if alias != "" {
+			tables[i] += " " + alias
+		}
 	}
-	if len(qd.tables) > 0 {
-		result = result[:len(result)-2]
+	result := "select " + strings.Join(selectList, ", ") + " from " + strings.Join(tables, ", ")
+
+	conditions := make([]string, 0, 1+len(qd.semiJoins))
+	predicateString, err := qd.predicate.Format(FormatConstant)
+	if err != nil {
+		return ""
 	}
-	predicateString := qd.predicate.String()
 	if predicateString != "" {
-		result += " where " + predicateString
+		conditions = append(conditions, predicateString)
+	}
+	for _, semiJoin := range qd.semiJoins {
+		if semiJoin.subquery.String() == "" {
+			return ""
+		}
+		conditions = append(conditions, semiJoin.String())
+	}
+	if len(conditions) > 0 {
+		result += " where " + strings.Join(conditions, " and ")
+	}
+
+	if len(qd.groupBy) > 0 {
+		result += " group by " + strings.Join(qd.groupBy, ", ")
+	}
+	if qd.having != nil {
+		// The having clause reads the aggregates by their field names, which only name
+		// the aggregates of the select list when the text is parsed again.
+		for _, agg := range qd.aggregates[qd.selectAggregates:] {
+			if slices.Contains(qd.having.Fields(), agg.FieldName()) {
+				return ""
+			}
+		}
+		havingString, err := qd.having.Format(FormatConstant)
+		if err != nil {
+			return ""
+		}
+		if havingString != "" {
+			result += " having " + havingString
+		}
+	}
+	if len(qd.orderBy) > 0 {
+		items := make([]string, len(qd.orderBy))
+		for i, item := range qd.orderBy {
+			items[i] = item.field
+			if item.descending {
+				items[i] += " desc"
+			}
+		}
+		result += " order by " + strings.Join(items, ", ")
+	}
+	if qd.limit != NoLimit {
+		result += fmt.Sprintf(" limit %d", qd.limit)
+	}
+	if qd.offset != 0 {
+		result += fmt.Sprintf(" offset %d", qd.offset)
 	}
 	return result
 }
+
+// aggregateString returns the text of the specified aggregate function, such as "sum(amount)" or "count(*)",
+// or an empty string if it is not one of the functions the parser reads.
+func aggregateString(agg functions.AggregationFunction) string {
+	var name string
+	switch agg.(type) {
+	case *functions.MaxFunction:
+		name = "max"
+	case *functions.MinFunction:
+		name = "min"
+	case *functions.CountFunction:
+		if agg.FieldName() == functions.CountAllFieldName {
+			return "count(*)"
+		}
+		name = "count"
+	case *functions.AvgFunction:
+		name = "avg"
+	case *functions.SumFunction:
+		name = "sum"
+	case *functions.ApproxCountDistinctFunction:
+		name = "approx_count_distinct"
+	default:
+		return ""
+	}
+	return name + "(" + agg.AggregatedField() + ")"
+}
+
+// valuesString returns the text of a VALUES list outputting the specified rows,
+// or an empty string if one of the values cannot be written as a constant.
+func valuesString(rows [][]any) string {
+	texts := make([]string, len(rows))
+	for i, row := range rows {
+		vals := make([]string, len(row))
+		for j, val := range row {
+			text, err := FormatConstant(val)
+			if err != nil {
+				return ""
+			}
+			vals[j] = text
+		}
+		texts[i] = "(" + strings.Join(vals, ", ") + ")"
+	}
+	return "values " + strings.Join(texts, ", ")
+}
@@ -0,0 +1,18 @@
+package parse
+
+// RestoreData holds the data of a RESTORE statement, which executes
+// the statements of a file written by a DUMP DATABASE statement.
+type RestoreData struct {
+	fileName string
+}
+
+func NewRestoreData(fileName string) *RestoreData {
+	return &RestoreData{
+		fileName: fileName,
+	}
+}
+
+// FileName returns the name of the file the statements are read from.
+func (rd *RestoreData) FileName() string {
+	return rd.fileName
+}
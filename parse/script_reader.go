@@ -0,0 +1,57 @@
+package parse
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ScriptReader reads the statements of a script, such as the file written by a DUMP DATABASE statement,
+// one at a time, so that the script is never held in memory as a whole. The statements are separated
+// by semicolons, except for the semicolons within string constants.
+type ScriptReader struct {
+	reader *bufio.Reader
+}
+
+// NewScriptReader creates a reader of the statements of the specified script.
+func NewScriptReader(r io.Reader) *ScriptReader {
+	return &ScriptReader{reader: bufio.NewReader(r)}
+}
+
+// Next returns the next statement of the script, without its semicolon and the whitespace around it,
+// or io.EOF once every statement has been read. Empty statements are skipped, and the last statement
+// does not need a semicolon. It returns a SyntaxError if the script ends within a string constant.
+func (sr *ScriptReader) Next() (string, error) {
+	var statement strings.Builder
+	inString := false
+	for {
+		r, _, err := sr.reader.ReadRune()
+		if errors.Is(err, io.EOF) {
+			if inString {
+				return "", &SyntaxError{Message: "unterminated string constant at the end of the script"}
+			}
+			if text := strings.TrimSpace(statement.String()); text != "" {
+				return text, nil
+			}
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", err
+		}
+
+		// Each quote opens or closes a string constant: the two quotes standing for a quote within a
+		// string constant close it and open it again.
+		if r == '\'' {
+			inString = !inString
+		}
+		if r == ';' && !inString {
+			if text := strings.TrimSpace(statement.String()); text != "" {
+				return text, nil
+			}
+			statement.Reset()
+			continue
+		}
+		statement.WriteRune(r)
+	}
+}
@@ -0,0 +1,18 @@
+package parse
+
+// SetQueryTimeoutData holds the data of a SET QUERY TIMEOUT statement, which sets
+// the number of milliseconds a statement may run before it is aborted.
+type SetQueryTimeoutData struct {
+	milliseconds int
+}
+
+func NewSetQueryTimeoutData(milliseconds int) *SetQueryTimeoutData {
+	return &SetQueryTimeoutData{
+		milliseconds: milliseconds,
+	}
+}
+
+// Milliseconds returns the timeout in milliseconds. Zero means that statements have no timeout.
+func (sqtd *SetQueryTimeoutData) Milliseconds() int {
+	return sqtd.milliseconds
+}
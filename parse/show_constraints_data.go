@@ -0,0 +1,17 @@
+package parse
+
+// ShowConstraintsData holds the data of a SHOW CONSTRAINTS statement,
+// which lists the constraints of a table.
+type ShowConstraintsData struct {
+	tableName string
+}
+
+func NewShowConstraintsData(tableName string) *ShowConstraintsData {
+	return &ShowConstraintsData{
+		tableName: tableName,
+	}
+}
+
+func (scd *ShowConstraintsData) TableName() string {
+	return scd.tableName
+}
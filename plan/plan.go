@@ -8,6 +8,8 @@ import (
 type Plan interface {
 	// Open opens a scan corresponding to this plan.
 	// The scan will be positioned before its first record.
+	// The caller owns the scan and must close it. If Open fails,
+	// the scans it opened along the way are closed before it returns.
 	Open() (scan.Scan, error)
 
 	// BlocksAccessed returns the estimated number of
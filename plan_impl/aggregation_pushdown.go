@@ -0,0 +1,193 @@
+package plan_impl
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// aggregationPushdown is the rewrite of a grouped query over the join of two inputs whose aggregates
+// all read the same input: that input is aggregated before the join, grouped by its join fields and
+// the group fields it holds, so that far fewer records are joined. The partial aggregates are then
+// combined by the aggregation above the join.
+type aggregationPushdown struct {
+	// side is the index of the input that is aggregated below the join.
+	side int
+	// plan aggregates the input, after selecting its records on the conjuncts of the predicate that only read it.
+	plan plan.Plan
+	// predicate holds the other conjuncts of the predicate (see query.Predicate#Conjuncts), which are applied above the join.
+	predicate *query.Predicate
+	// aggregates combine the partial aggregates into the aggregates of the query.
+	aggregates []functions.AggregationFunction
+}
+
+// pushDownAggregation returns the rewrite of the query aggregating one of its two inputs below their join,
+// or nil if the rewrite does not apply or would not shrink the input. The check is conservative:
+// the query must group, every aggregate must read the same input and be one whose partial results can be
+// combined (COUNT, SUM, MIN or MAX), every projected field must be a group field, the inputs must
+// be joined on at least one term or disjunction of the predicate, and the query must have no subquery
+// conditions, which may read the fields of the aggregated input.
+func (qp *BasicQueryPlanner) pushDownAggregation(queryData *parse.QueryData, plans []plan.Plan, transaction *tx.Transaction) (*aggregationPushdown, error) {
+	if len(plans) != 2 || len(queryData.GroupBy()) == 0 || len(queryData.Aggregates()) == 0 || len(queryData.SemiJoins()) > 0 {
+		return nil, nil
+	}
+	for _, field := range queryData.Fields() {
+		if !slices.Contains(queryData.GroupBy(), field) {
+			return nil, nil
+		}
+	}
+
+	side := -1
+	for i, p := range plans {
+		if p.Schema().HasField(queryData.Aggregates()[0].AggregatedField()) {
+			side = i
+		}
+	}
+	if side < 0 {
+		return nil, nil
+	}
+	input, other := plans[side], plans[1-side]
+
+	combiners := make([]functions.AggregationFunction, len(queryData.Aggregates()))
+	partials := make([]functions.AggregationFunction, len(queryData.Aggregates()))
+	for i, aggregate := range queryData.Aggregates() {
+		if !input.Schema().HasField(aggregate.AggregatedField()) || other.Schema().HasField(aggregate.AggregatedField()) {
+			return nil, nil
+		}
+		combiner := combiningFunction(aggregate)
+		if combiner == nil {
+			return nil, nil
+		}
+		combiners[i] = combiner
+		partials[i] = aggregate.Clone()
+	}
+
+	// The input is grouped by its group fields and by its fields read above the join.
+	var groupFields []string
+	for _, field := range queryData.GroupBy() {
+		inInput, inOther := input.Schema().HasField(field), other.Schema().HasField(field)
+		if inInput == inOther {
+			return nil, nil
+		}
+		if inInput {
+			groupFields = append(groupFields, field)
+		}
+	}
+
+	var inputConjuncts, otherConjuncts []*query.Predicate
+	joined := false
+	for _, conjunct := range queryData.Pred().Conjuncts() {
+		if conjunct.AppliesTo(input.Schema()) {
+			inputConjuncts = append(inputConjuncts, conjunct)
+			continue
+		}
+		otherConjuncts = append(otherConjuncts, conjunct)
+		for _, field := range conjunct.Fields() {
+			if input.Schema().HasField(field) {
+				joined = true
+				if !slices.Contains(groupFields, field) {
+					groupFields = append(groupFields, field)
+				}
+			}
+		}
+	}
+	if !joined {
+		return nil, nil
+	}
+
+	inputPredicate := conjunctionOf(inputConjuncts)
+	if err := qp.checkTypes(inputPredicate, input.Schema()); err != nil {
+		return nil, err
+	}
+	if inputPredicate != nil {
+		var err error
+		if input, err = NewSelectPlan(input, inputPredicate); err != nil {
+			return nil, err
+		}
+	}
+
+	aggregated, err := NewHashAggregationPlan(transaction, input, groupFields, partials)
+	if err != nil {
+		return nil, err
+	}
+	if aggregated.RecordsOutput() >= input.RecordsOutput() {
+		return nil, nil
+	}
+
+	return &aggregationPushdown{
+		side:       side,
+		plan:       aggregated,
+		predicate:  conjunctionOf(otherConjuncts),
+		aggregates: combiners,
+	}, nil
+}
+
+// combiningFunction returns the function combining the partial results of the specified aggregate
+// into its result, under the aggregate's field name, or nil if its partial results cannot be combined.
+func combiningFunction(aggregate functions.AggregationFunction) functions.AggregationFunction {
+	partialField := aggregate.FieldName()
+	var combiner functions.AggregationFunction
+	switch aggregate.(type) {
+	case *functions.CountFunction, *functions.SumFunction:
+		combiner = functions.NewSumFunction(partialField)
+	case *functions.MinFunction:
+		combiner = functions.NewMinFunction(partialField)
+	case *functions.MaxFunction:
+		combiner = functions.NewMaxFunction(partialField)
+	default:
+		return nil
+	}
+	return &combinedAggregation{AggregationFunction: combiner, name: partialField}
+}
+
+// combinedAggregation is an aggregation function combining partial aggregates, whose field keeps
+// the name of the aggregate of the query, so that the HAVING clause, the ordering and the
+// projection of the query read it like the aggregate they refer to.
+type combinedAggregation struct {
+	functions.AggregationFunction
+	name string
+}
+
+// FieldName returns the name of the aggregate of the query.
+func (c *combinedAggregation) FieldName() string {
+	return c.name
+}
+
+// OutputField returns the field of the combining function, under the name of the aggregate of the query.
+func (c *combinedAggregation) OutputField(inputFields []types.FieldInfo) types.FieldInfo {
+	field := c.AggregationFunction.OutputField(inputFields)
+	field.Name = c.name
+	return field
+}
+
+// Clone returns a new combining function for the same aggregate.
+func (c *combinedAggregation) Clone() functions.AggregationFunction {
+	return &combinedAggregation{AggregationFunction: c.AggregationFunction.Clone(), name: c.name}
+}
+
+// Merge merges the combining function of the other combined aggregation into this one's.
+func (c *combinedAggregation) Merge(other functions.AggregationFunction) error {
+	o, ok := other.(*combinedAggregation)
+	if !ok || o.name != c.name {
+		return fmt.Errorf("cannot merge %s into %s", other.FieldName(), c.name)
+	}
+	return c.AggregationFunction.Merge(o.AggregationFunction)
+}
+
+// conjunctionOf returns the conjunction of the specified predicates, or nil if there are none.
+func conjunctionOf(predicates []*query.Predicate) *query.Predicate {
+	if len(predicates) == 0 {
+		return nil
+	}
+	predicate := query.NewPredicate()
+	for _, other := range predicates {
+		predicate.ConjoinWith(other)
+	}
+	return predicate
+}
@@ -0,0 +1,191 @@
+package plan_impl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// joinInputs returns the inputs of the product, merge join or hash join read by the specified plan, through its
+// single-input operators, and the select plan applying the join predicate to it.
+func joinInputs(t *testing.T, p plan.Plan) ([2]plan.Plan, *SelectPlan) {
+	var join *SelectPlan
+	for {
+		switch current := p.(type) {
+		case *ProductPlan:
+			return [2]plan.Plan{current.plan1, current.plan2}, join
+		case *MultibufferProductPlan:
+			return [2]plan.Plan{current.plan1, current.plan2}, join
+		case *MergeJoinPlan:
+			return [2]plan.Plan{current.plan1, current.plan2}, join
+		case *HashJoinPlan:
+			return [2]plan.Plan{current.plan1, current.plan2}, join
+		case *ProjectPlan:
+			p = current.inputPlan
+		case *SelectPlan:
+			join = current
+			p = current.inputPlan
+		case *HashAggregationPlan:
+			p = current.inputPlan
+		case *GroupByPlan:
+			p = current.inputPlan
+		case *SortPlan:
+			p = current.inputPlan
+		default:
+			require.Failf(t, "no join plan", "unexpected plan %T", p)
+			return [2]plan.Plan{}, nil
+		}
+	}
+}
+
+// countPlanRecords opens the plan and returns the number of records of its scan.
+func countPlanRecords(t *testing.T, p plan.Plan) int {
+	s, err := p.Open()
+	require.NoError(t, err)
+	return countRecords(t, s)
+}
+
+// setupEmployeesAndDepartments creates 10,000 employees in 10 departments. Two departments share a name,
+// so that grouping by name combines the partial aggregates of both.
+func setupEmployeesAndDepartments(t *testing.T) (*Planner, *tx.Transaction) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 4000, 20)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE emp (eid INT, edept INT, salary INT)",
+		"CREATE TABLE dept (did INT, dname VARCHAR(10))",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err)
+	}
+	for did := 0; did < 10; did++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO dept (did, dname) VALUES (%d, 'd%d')", did, did%9), txn)
+		require.NoError(t, err)
+	}
+	for eid := 0; eid < 10000; eid++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO emp (eid, edept, salary) VALUES (%d, %d, %d)", eid, eid%10, employeeSalary(eid)), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	t.Cleanup(func() { require.NoError(t, txn.Commit()) })
+	return p, txn
+}
+
+func employeeSalary(eid int) int {
+	return 1000 + (eid*37)%5000
+}
+
+// departmentAggregates holds the aggregates of the employees of the departments having the same name.
+type departmentAggregates struct {
+	count, sum int64
+	max        int
+}
+
+func TestBasicQueryPlanner_AggregationPushdown(t *testing.T) {
+	p, txn := setupEmployeesAndDepartments(t)
+
+	expected := make(map[string]departmentAggregates)
+	for eid := 0; eid < 10000; eid++ {
+		if eid == 0 || eid == 7 {
+			continue
+		}
+		name := fmt.Sprintf("d%d", (eid%10)%9)
+		g := expected[name]
+		g.count++
+		g.sum += int64(employeeSalary(eid))
+		g.max = max(g.max, employeeSalary(eid))
+		expected[name] = g
+	}
+
+	sql := "SELECT dname, COUNT(eid), SUM(salary), MAX(salary) FROM emp, dept WHERE edept = did AND eid != 0 AND eid != 7 GROUP BY dname"
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err)
+
+	// The employees are aggregated per department before they are joined with the departments,
+	// so that there is one joined record per department instead of one per employee.
+	explained, err := p.Explain(sql, txn)
+	require.NoError(t, err)
+	inputs, join := joinInputs(t, queryPlan)
+	assert.Contains(t, []string{planNode(inputs[0]).Type, planNode(inputs[1]).Type}, "HashAggregation", explained)
+	assert.Equal(t, 10, countPlanRecords(t, join))
+
+	unaggregated, err := p.CreateQueryPlan("SELECT eid FROM emp, dept WHERE edept = did AND eid != 0 AND eid != 7", txn)
+	require.NoError(t, err)
+	_, unaggregatedJoin := joinInputs(t, unaggregated)
+	assert.Equal(t, 9998, countPlanRecords(t, unaggregatedJoin))
+
+	// The results are those of aggregating the joined employees.
+	s, err := queryPlan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	results := make(map[string]departmentAggregates)
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		dname, err := s.GetString("dname")
+		require.NoError(t, err)
+		count, err := s.GetLong("countOfeid")
+		require.NoError(t, err)
+		sum, err := s.GetLong("sumOfsalary")
+		require.NoError(t, err)
+		maxSalary, err := s.GetInt("maxOfsalary")
+		require.NoError(t, err)
+		results[dname] = departmentAggregates{count, sum, maxSalary}
+	}
+	assert.Equal(t, expected, results)
+}
+
+func TestBasicQueryPlanner_AggregationPushdownApplicability(t *testing.T) {
+	p, txn := setupEmployeesAndDepartments(t)
+
+	pushedDown := func(sql string) bool {
+		queryPlan, err := p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err, sql)
+		inputs, _ := joinInputs(t, queryPlan)
+		_, aggregated1 := inputs[0].(*HashAggregationPlan)
+		_, aggregated2 := inputs[1].(*HashAggregationPlan)
+		return aggregated1 || aggregated2
+	}
+
+	assert.True(t, pushedDown("SELECT dname, MIN(salary) FROM emp, dept WHERE edept = did GROUP BY dname ORDER BY dname"))
+	assert.True(t, pushedDown("SELECT edept, COUNT(eid) FROM emp, dept WHERE edept = did GROUP BY edept"))
+	// AVG cannot be combined from partial averages.
+	assert.False(t, pushedDown("SELECT dname, AVG(salary) FROM emp, dept WHERE edept = did GROUP BY dname"))
+	// The aggregates read both inputs.
+	assert.False(t, pushedDown("SELECT dname, COUNT(eid), COUNT(did) FROM emp, dept WHERE edept = did GROUP BY dname"))
+	// Grouping by the employee ids does not shrink the employees.
+	assert.False(t, pushedDown("SELECT eid, COUNT(did) FROM emp, dept WHERE edept = did GROUP BY eid"))
+	// Without a join term, the inputs are not joined.
+	assert.False(t, pushedDown("SELECT dname, COUNT(eid) FROM emp, dept GROUP BY dname"))
+
+	// The HAVING clause reads the combined aggregates: only d0 names two departments of 1,000 employees.
+	sql := "SELECT dname, COUNT(eid) FROM emp, dept WHERE edept = did GROUP BY dname HAVING COUNT(eid) > 1000"
+	require.True(t, pushedDown(sql))
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err)
+	s, err := queryPlan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	var names []string
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		dname, err := s.GetString("dname")
+		require.NoError(t, err)
+		names = append(names, dname)
+	}
+	assert.Equal(t, []string{"d0"}, names)
+}
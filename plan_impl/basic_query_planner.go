@@ -1,16 +1,39 @@
 package plan_impl
 
 import (
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/tx"
 )
 
 var _ QueryPlanner = &BasicQueryPlanner{}
+var _ IndexAdvisor = &BasicQueryPlanner{}
+var _ BlockDumper = &BasicQueryPlanner{}
+var _ ConstraintLister = &BasicQueryPlanner{}
+var _ TableFilterer = &BasicQueryPlanner{}
+var _ TypeCheckConfigurer = &BasicQueryPlanner{}
+var _ StatsReader = &BasicQueryPlanner{}
+var _ ParallelScanner = &BasicQueryPlanner{}
+var _ DatabaseDumper = &BasicQueryPlanner{}
+var _ StatementStatsRecorder = &BasicQueryPlanner{}
 
 type BasicQueryPlanner struct {
+	typeChecker
+	statementStats
 	metadataManager *metadata.Manager
+	tableFilters    *TableFilters
+	statsSource     func() DatabaseStats
+	scanWorkers     int
+	newWorkerTx     func() *tx.Transaction
+	// orderJoins is true if the inputs of a query are joined in the order chosen by joinInGreedyOrder
+	// rather than in the order of its FROM clause (see HeuristicQueryPlanner).
+	orderJoins bool
 }
 
 // NewBasicQueryPlanner creates a new BasicQueryPlanner
@@ -18,16 +41,107 @@ func NewBasicQueryPlanner(metadataManager *metadata.Manager) *BasicQueryPlanner
 	return &BasicQueryPlanner{metadataManager: metadataManager}
 }
 
+// SetTableFilters sets the filters conjoined with the predicate of a query for each table it reads.
+func (qp *BasicQueryPlanner) SetTableFilters(filters *TableFilters) {
+	qp.tableFilters = filters
+}
+
+// SetStatsSource sets the function returning the stats of the database that the StatsTable holds.
+// Until it is set, the StatsTable is read like any other table.
+func (qp *BasicQueryPlanner) SetStatsSource(source func() DatabaseStats) {
+	qp.statsSource = source
+}
+
+// SetScanParallelism sets the number of workers aggregating a table in parallel (see ParallelTablePlan),
+// each in a read-only transaction returned by newTransaction. Until it is set to more than one worker,
+// tables are read serially.
+func (qp *BasicQueryPlanner) SetScanParallelism(workers int, newTransaction func() *tx.Transaction) {
+	qp.scanWorkers = workers
+	qp.newWorkerTx = newTransaction
+}
+
 // CreatePlan creates a query plan as follows:
-// 1. Takes the product of all tables and views
-// 2. Applies predicate selection
-// 3. Applies grouping and having if specified
-// 4. Projects on the field list
+// 1. Takes the product of all tables and views, and of the StatsTable and the catalog listings
+// (see metadata.GetCatalogListing), reading each table through an index if that is cheaper than scanning it.
+// The filter of each table, if any, is conjoined with the predicate first,
+// so that it is used to choose the index too. Views are expanded into
+// the tables they read, to which their filters apply. When two inputs are
+// joined and grouped, and only one of them is aggregated, it may be
+// aggregated before the join (see pushDownAggregation).
+// The conjuncts of the predicate reading a single input select its records before it is joined
+// (see pushDownSelections). The inputs are joined in turn, in the order of the FROM clause or in the order
+// chosen by joinInGreedyOrder (see HeuristicQueryPlanner), each through an index on a field the predicate
+// equates with a field of the other input, or else by merging both inputs sorted on such fields, or by hashing
+// the smaller input on such a field when it fits in memory, if that is cheaper than taking their product.
+// Inputs without such fields may be multiplied a chunk of the smaller one at a time (see MultibufferProductPlan).
+// 2. Applies predicate selection, and then the IN and EXISTS subquery conditions of the predicate,
+// each by a semijoin with the plan of its subquery (see semiJoin)
+// 3. Applies grouping and having if specified. A query with aggregates but no GROUP BY clause aggregates
+// all its records in a single group, which is output even if there is no record. A query grouping the
+// records of a single table that it reads without an index is aggregated by parallel workers if scan
+// parallelism is enabled (see SetScanParallelism), the selection being applied by each worker.
+// 4. Projects on the field list, in which the wildcards are expanded (see expandWildcards),
+// computing its arithmetic expressions from the fields they read
 // 5. Applies ordering if specified
+// 6. Unites the plan with the plans of the queries of its UNION clauses in turn: UNION ALL concatenates
+// the records of both plans, and UNION then sorts them on all fields to remove duplicate records.
+// A query outputting rows of constants, such as a VALUES list, is planned as a ConstantPlan instead.
+// A field of the select list or of the GROUP BY, HAVING or ORDER BY clauses that is not a field of the tables
+// the query reads is rejected with an error wrapping ErrUnknownField (see checkQueryFields), and a field that
+// several of them have is rejected with an error wrapping ErrAmbiguousField unless it is qualified by the alias
+// or the name of its table (see checkAmbiguousFields). The inputs of a query qualifying its fields output them
+// under their qualified names (see qualifyInputs), each selected on the conjuncts reading only its own fields.
 func (qp *BasicQueryPlanner) CreatePlan(queryData *parse.QueryData, transaction *tx.Transaction) (plan.Plan, error) {
-	// 1. Create a plan for each mentioned table or view
+	currentPlan, err := qp.createSelectPlan(queryData, transaction)
+	if err != nil {
+		return nil, err
+	}
+	for _, union := range queryData.Unions() {
+		unionPlan, err := qp.createSelectPlan(union.Query(), transaction)
+		if err != nil {
+			return nil, err
+		}
+		concatPlan, err := NewConcatPlan(currentPlan, unionPlan)
+		if err != nil {
+			return nil, err
+		}
+		currentPlan = concatPlan
+		if !union.All() {
+			currentPlan = NewDedupPlan(transaction, currentPlan)
+		}
+	}
+	return currentPlan, nil
+}
+
+// createSelectPlan creates the plan of a single SELECT of a query, ignoring its UNION clauses (see CreatePlan).
+func (qp *BasicQueryPlanner) createSelectPlan(queryData *parse.QueryData, transaction *tx.Transaction) (plan.Plan, error) {
+	if rows := queryData.Values(); rows != nil {
+		constantPlan, err := NewConstantPlan(queryData.Fields(), rows)
+		if err != nil {
+			return nil, err
+		}
+		return constantPlan, nil
+	}
+
+	// 1. Create a plan for each mentioned table or view. The fields the query reads are
+	// collected first, since the filters of its tables are conjoined with its predicate.
+	fields := readFields(queryData)
 	plans := make([]plan.Plan, len(queryData.Tables()))
+
 	for idx, tableName := range queryData.Tables() {
+		if tableName == StatsTable && qp.statsSource != nil {
+			plans[idx] = NewStatsPlan(qp.statsSource())
+			continue
+		}
+		if metadata.IsCatalogListing(tableName) {
+			listingPlan, err := NewCatalogListingPlan(transaction, tableName, qp.metadataManager)
+			if err != nil {
+				return nil, err
+			}
+			plans[idx] = listingPlan
+			continue
+		}
+
 		viewDefinition, err := qp.metadataManager.GetViewDefinition(tableName, transaction)
 		if err != nil {
 			return nil, err
@@ -38,10 +152,27 @@ func (qp *BasicQueryPlanner) CreatePlan(queryData *parse.QueryData, transaction
 			if err != nil {
 				return nil, err
 			}
-			plans[idx] = tablePlan
+			predicate := inputPredicate(queryData, idx)
+			predicate.CoerceConstants(tablePlan.Schema())
+			filter, err := qp.tableFilters.conjoinFilter(tableName, tablePlan.Schema(), predicate, transaction)
+			if err != nil {
+				return nil, err
+			}
+			accessPath, err := qp.chooseAccessPath(tablePlan, predicate, transaction)
+			if err != nil {
+				return nil, err
+			}
+			qp.recordAccessPath(transaction, accessPath)
+			plans[idx] = accessPath.plan(tablePlan)
+			// The filter of a table of a qualified query reads the fields of the table rather than
+			// their qualified names, so it selects the records of the table before they are qualified.
+			if filter != nil && queryData.Qualified() {
+				if plans[idx], err = NewSelectPlan(plans[idx], filter); err != nil {
+					return nil, err
+				}
+			}
 		} else {
-			parser := parse.NewParser(viewDefinition)
-			viewData, err := parser.Query()
+			viewData, err := qp.expandView(tableName, viewDefinition, inputPredicate(queryData, idx), transaction)
 			if err != nil {
 				return nil, err
 			}
@@ -54,62 +185,421 @@ func (qp *BasicQueryPlanner) CreatePlan(queryData *parse.QueryData, transaction
 		}
 	}
 
-	// 2. Create the product of all table plans
-	var err error
-	currentPlan := plans[0]
-	plans = plans[1:]
-
-	for _, nextPlan := range plans {
-		planChoice1, err := NewProductPlan(currentPlan, nextPlan)
-		if err != nil {
+	// The fields the query reads without qualifying them must be fields of a single input.
+	if err := checkAmbiguousFields(queryData, fields, plans); err != nil {
+		return nil, err
+	}
+	// The wildcards of the select list are expanded before the fields are read.
+	if err := expandWildcards(queryData, plans); err != nil {
+		return nil, err
+	}
+	// The inputs of a qualified query output their fields under their qualified names.
+	if queryData.Qualified() {
+		var err error
+		if plans, err = qualifyInputs(queryData, plans); err != nil {
 			return nil, err
 		}
+		for _, p := range plans {
+			queryData.Pred().CoerceConstants(p.Schema())
+		}
+	}
 
-		planChoice2, err := NewProductPlan(nextPlan, currentPlan)
-		if err != nil {
+	// 2. Join all table plans, through an index of a table if that is cheaper than the product (see joinPlans).
+	// When the query groups the join of two inputs and
+	// only aggregates one of them, that input is aggregated before the join if that shrinks it.
+	predicate, aggregates := queryData.Pred(), queryData.Aggregates()
+	pushdown, err := qp.pushDownAggregation(queryData, plans, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if pushdown != nil {
+		plans[pushdown.side] = pushdown.plan
+		predicate, aggregates = pushdown.predicate, pushdown.aggregates
+	}
+
+	// A single table read without an index can be aggregated by parallel workers, each applying the predicate,
+	// unless the query has subquery conditions, which the workers do not apply.
+	tablePlan, _ := plans[0].(*TablePlan)
+	parallel := len(plans) == 1 && tablePlan != nil && qp.scanWorkers > 1 && len(queryData.SemiJoins()) == 0
+
+	// The conjuncts of the predicate reading a single input select its records below the join (see pushDownSelections).
+	joinPredicate := predicate
+	if len(plans) > 1 {
+		if plans, joinPredicate, err = pushDownSelections(plans, predicate); err != nil {
 			return nil, err
 		}
+	}
 
-		if planChoice1.BlocksAccessed() < planChoice2.BlocksAccessed() {
-			currentPlan = planChoice1
-		} else {
-			currentPlan = planChoice2
+	// The inputs are joined in the order of the FROM clause, unless the planner orders the joins of three or more inputs.
+	var currentPlan plan.Plan
+	if qp.orderJoins && len(plans) > 2 {
+		if currentPlan, err = qp.joinInGreedyOrder(plans, joinPredicate, transaction); err != nil {
+			return nil, err
+		}
+	} else {
+		currentPlan = plans[0]
+		for _, nextPlan := range plans[1:] {
+			if currentPlan, err = qp.joinPlans(currentPlan, nextPlan, joinPredicate, transaction); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	// 3. Add a selection plan for the predicate
-	currentPlan = NewSelectPlan(currentPlan, queryData.Pred())
+	// 3. Add a selection plan for the rest of the predicate. All its terms, including
+	// the join conditions and the conjuncts pushed below the join, are type checked against all the tables
+	if err := qp.checkTypes(predicate, currentPlan.Schema()); err != nil {
+		return nil, err
+	}
+	if err := checkQueryFields(queryData, currentPlan.Schema()); err != nil {
+		return nil, err
+	}
+	currentPlan, err = NewSelectPlan(currentPlan, joinPredicate)
+	if err != nil {
+		return nil, err
+	}
+	for _, semiJoin := range queryData.SemiJoins() {
+		if currentPlan, err = qp.semiJoin(currentPlan, semiJoin, transaction); err != nil {
+			return nil, err
+		}
+	}
 
 	projectionFields := queryData.Fields()
-	// 4. Add grouping if specified
-	if len(queryData.GroupBy()) > 0 {
-		currentPlan = NewGroupByPlan(transaction, currentPlan, queryData.GroupBy(), queryData.Aggregates())
+	// 4. Add grouping if specified, or if the query aggregates all its records
+	if len(queryData.GroupBy()) > 0 || len(aggregates) > 0 {
+		groupSchema, err := groupBySchema(currentPlan.Schema(), queryData.GroupBy(), aggregates)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkFields("HAVING", queryData.Having().Fields(), groupSchema, queryData.Tables()); err != nil {
+			return nil, err
+		}
+		if err := qp.checkTypes(queryData.Having(), groupSchema); err != nil {
+			return nil, err
+		}
+
+		// Sorting the input only pays off when the output is ordered by the group fields anyway,
+		// and no sorting is needed to aggregate all the records in one group.
+		if len(queryData.GroupBy()) == 0 || ordersByGroupField(queryData) {
+			currentPlan, err = NewGroupByPlan(transaction, currentPlan, queryData.GroupBy(), aggregates)
+		} else if parallel {
+			currentPlan, err = NewParallelTablePlan(transaction, tablePlan, predicate, queryData.GroupBy(), aggregates, qp.scanWorkers, qp.newWorkerTx)
+		} else {
+			currentPlan, err = NewHashAggregationPlan(transaction, currentPlan, queryData.GroupBy(), aggregates)
+		}
+		if err != nil {
+			return nil, err
+		}
 
 		// Apply having clause if present
 		if queryData.Having() != nil {
-			currentPlan = NewSelectPlan(currentPlan, queryData.Having())
+			currentPlan, err = NewSelectPlan(currentPlan, queryData.Having())
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		for _, AggFunc := range queryData.Aggregates() {
+		for _, AggFunc := range queryData.SelectAggregates() {
 			projectionFields = append(projectionFields, AggFunc.FieldName())
 		}
 	}
 
-	// 5. Add a projection plan for the field list
-	currentPlan, err = NewProjectPlan(currentPlan, projectionFields)
+	// 5. Add ordering if specified
+	if len(queryData.OrderBy()) > 0 {
+		sortFields := make([]query.SortField, len(queryData.OrderBy()))
+		for i, item := range queryData.OrderBy() {
+			if err := checkFields("ORDER BY", []string{item.Field()}, currentPlan.Schema(), queryData.Tables()); err != nil {
+				return nil, err
+			}
+			sortFields[i] = query.SortField{Name: item.Field(), Descending: item.Descending()}
+		}
+		currentPlan = NewSortPlanWithDirections(transaction, currentPlan, sortFields)
+	}
+
+	// 6. Add a projection plan for the field list at the top, so that every operator
+	// below it can read the fields it needs, whether they are projected or not.
+	// The fields given an alias in the select list are output under their alias.
+	outputFields := make([]string, len(projectionFields))
+	for i, field := range projectionFields {
+		outputFields[i] = queryData.OutputName(field)
+	}
+	currentPlan, err = NewProjectPlanWithExpressions(currentPlan, outputFields, queryData.Aliases(), queryData.Computed())
 	if err != nil {
 		return nil, err
 	}
 
-	// 6. Add ordering if specified
-	if len(queryData.OrderBy()) > 0 {
-		sortFields := make([]string, len(queryData.OrderBy()))
-		for i, item := range queryData.OrderBy() {
-			// Note: Currently the SortPlan doesn't support descending order
-			sortFields[i] = item.Field()
-		}
-		currentPlan = NewSortPlan(transaction, currentPlan, sortFields)
+	// 7. Add a limit plan at the very top, so that the records it skips and outputs are the sorted ones
+	if queryData.Limit() != parse.NoLimit || queryData.Offset() > 0 {
+		currentPlan = NewLimitPlan(currentPlan, queryData.Limit(), queryData.Offset())
 	}
 
 	return currentPlan, nil
 }
+
+// IndexCandidates reports, for each table read by the query (including the tables
+// read by any views it mentions), the indexes considered and the access path chosen.
+func (qp *BasicQueryPlanner) IndexCandidates(queryData *parse.QueryData, transaction *tx.Transaction) ([]*AccessPath, error) {
+	var accessPaths []*AccessPath
+	for idx, tableName := range queryData.Tables() {
+		// The StatsTable and the catalog listings are held in memory, so they have no indexes.
+		if tableName == StatsTable && qp.statsSource != nil || metadata.IsCatalogListing(tableName) {
+			continue
+		}
+		viewDefinition, err := qp.metadataManager.GetViewDefinition(tableName, transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		if viewDefinition == "" {
+			tablePlan, err := NewTablePlan(transaction, tableName, qp.metadataManager)
+			if err != nil {
+				return nil, err
+			}
+			predicate := inputPredicate(queryData, idx)
+			predicate.CoerceConstants(tablePlan.Schema())
+			if _, err := qp.tableFilters.conjoinFilter(tableName, tablePlan.Schema(), predicate, transaction); err != nil {
+				return nil, err
+			}
+			accessPath, err := qp.chooseAccessPath(tablePlan, predicate, transaction)
+			if err != nil {
+				return nil, err
+			}
+			accessPaths = append(accessPaths, accessPath)
+		} else {
+			viewData, err := qp.expandView(tableName, viewDefinition, inputPredicate(queryData, idx), transaction)
+			if err != nil {
+				return nil, err
+			}
+
+			viewPaths, err := qp.IndexCandidates(viewData, transaction)
+			if err != nil {
+				return nil, err
+			}
+			accessPaths = append(accessPaths, viewPaths...)
+		}
+	}
+	return accessPaths, nil
+}
+
+// chooseAccessPath decides whether the table should be read through one of its indexes.
+func (qp *BasicQueryPlanner) chooseAccessPath(tablePlan *TablePlan, predicate *query.Predicate, transaction *tx.Transaction) (*AccessPath, error) {
+	indexes, err := qp.metadataManager.GetIndexInfo(tablePlan.tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	return chooseAccessPath(tablePlan, predicate, indexes), nil
+}
+
+// joinPlans returns the cheapest plan joining the specified plans: the product of the plans, in either order,
+// or an index join reading the records of one of them that is a table through an index on a field that the
+// predicate equates with a field of the other. Without such an index, a merge join on fields that the predicate
+// equates is considered instead (see mergeJoin), as well as a hash join if the smaller plan is expected to fit in
+// memory (see hashJoin). Without any of these joins, the multibuffer product of the plans is considered too (see
+// MultibufferProductPlan), which reads one of them once per chunk of the other rather than once per record.
+// The predicate is applied by a select plan above the join.
+// Ties are broken in favor of the product, reading the next plan first.
+func (qp *BasicQueryPlanner) joinPlans(currentPlan, nextPlan plan.Plan, predicate *query.Predicate, transaction *tx.Transaction) (plan.Plan, error) {
+	planChoice1, err := NewProductPlan(transaction, currentPlan, nextPlan)
+	if err != nil {
+		return nil, err
+	}
+	planChoice2, err := NewProductPlan(transaction, nextPlan, currentPlan)
+	if err != nil {
+		return nil, err
+	}
+	choices := []plan.Plan{planChoice2, planChoice1}
+
+	for _, inputs := range [][2]plan.Plan{{currentPlan, nextPlan}, {nextPlan, currentPlan}} {
+		// The selection of a table below the join (see pushDownSelections) is applied to the records it finds.
+		var selection *query.Predicate
+		if selectPlan, ok := inputs[1].(*SelectPlan); ok {
+			inputs[1], selection = selectPlan.inputPlan, selectPlan.predicate
+		}
+		// A table of a qualified query is joined below the projection qualifying its fields (see qualifyInputs),
+		// on the fields of the table that the qualified fields of the predicate stand for, and then selected on
+		// its filter, if any, before its fields are qualified.
+		joinPredicate := predicate
+		var qualifying *ProjectPlan
+		var filter *query.Predicate
+		if projectPlan, ok := inputs[1].(*ProjectPlan); ok && projectPlan.qualifier != "" {
+			inputs[1], qualifying, joinPredicate = projectPlan.inputPlan, projectPlan, predicate.RenameFields(projectPlan.aliases)
+			if selectPlan, ok := inputs[1].(*SelectPlan); ok {
+				inputs[1], filter = selectPlan.inputPlan, selectPlan.predicate
+			}
+		}
+		tablePlan, ok := inputs[1].(*TablePlan)
+		if !ok {
+			continue
+		}
+		indexJoins, err := qp.indexJoins(inputs[0], tablePlan, joinPredicate, transaction)
+		if err != nil {
+			return nil, err
+		}
+		for _, indexJoin := range indexJoins {
+			if filter != nil {
+				if indexJoin, err = NewSelectPlan(indexJoin, filter); err != nil {
+					return nil, err
+				}
+			}
+			if qualifying != nil {
+				if indexJoin, err = qualifyJoin(indexJoin, inputs[0], qualifying); err != nil {
+					return nil, err
+				}
+			}
+			if selection != nil {
+				if indexJoin, err = NewSelectPlan(indexJoin, selection); err != nil {
+					return nil, err
+				}
+			}
+			choices = append(choices, indexJoin)
+		}
+	}
+	if len(choices) == 2 {
+		if mergeJoin := mergeJoin(currentPlan, nextPlan, predicate, transaction); mergeJoin != nil {
+			choices = append(choices, mergeJoin)
+		}
+		if hashJoin := hashJoin(currentPlan, nextPlan, predicate, transaction); hashJoin != nil {
+			choices = append(choices, hashJoin)
+		}
+	}
+	if len(choices) == 2 {
+		choices = append(choices, NewMultibufferProductPlan(transaction, currentPlan, nextPlan))
+	}
+
+	best := choices[0]
+	for _, choice := range choices[1:] {
+		if choice.BlocksAccessed() < best.BlocksAccessed() {
+			best = choice
+		}
+	}
+	return best, nil
+}
+
+// indexJoins returns an index join of the outer plan with the table for each index on the table that can find
+// the records of the table matching a record of the outer plan: an index on a single field, which the predicate
+// equates with a field of the outer plan of the same type. Partial indexes, which may miss matching records,
+// and indexes found to be out of date with their table are not used.
+func (qp *BasicQueryPlanner) indexJoins(outerPlan plan.Plan, tablePlan *TablePlan, predicate *query.Predicate, transaction *tx.Transaction) ([]plan.Plan, error) {
+	indexes, err := qp.metadataManager.GetIndexInfo(tablePlan.tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexJoins []plan.Plan
+	for _, indexInfo := range indexes {
+		if indexInfo.Composite() || indexInfo.Predicate() != nil || indexInfo.Suspect() {
+			continue
+		}
+		fieldName := indexInfo.FieldName()
+		joinField := predicate.EquatesWithField(fieldName)
+		if joinField == "" || !outerPlan.Schema().HasField(joinField) || tablePlan.Schema().HasField(joinField) ||
+			outerPlan.Schema().Type(joinField) != tablePlan.Schema().Type(fieldName) {
+			continue
+		}
+		indexJoins = append(indexJoins, NewIndexJoinPlan(outerPlan, tablePlan, *indexInfo, joinField))
+	}
+	return indexJoins, nil
+}
+
+// equiJoinFields returns the first field of the current plan that the predicate equates with a field of the same
+// type of the next plan, and that field, or empty strings if there is no such field.
+func equiJoinFields(currentPlan, nextPlan plan.Plan, predicate *query.Predicate) (string, string) {
+	schema1, schema2 := currentPlan.Schema(), nextPlan.Schema()
+	for _, fieldName1 := range schema1.Fields() {
+		fieldName2 := predicate.EquatesWithField(fieldName1)
+		if fieldName2 == "" || !schema2.HasField(fieldName2) || schema1.HasField(fieldName2) || schema2.HasField(fieldName1) ||
+			schema1.Type(fieldName1) != schema2.Type(fieldName2) {
+			continue
+		}
+		return fieldName1, fieldName2
+	}
+	return "", ""
+}
+
+// mergeJoin returns a merge join of the specified plans on the fields that the predicate equates
+// (see equiJoinFields), or nil if there are no such fields.
+func mergeJoin(currentPlan, nextPlan plan.Plan, predicate *query.Predicate, transaction *tx.Transaction) plan.Plan {
+	fieldName1, fieldName2 := equiJoinFields(currentPlan, nextPlan, predicate)
+	if fieldName1 == "" {
+		return nil
+	}
+	return NewMergeJoinPlan(transaction, currentPlan, nextPlan, fieldName1, fieldName2)
+}
+
+// hashJoin returns a hash join of the specified plans on the fields that the predicate equates
+// (see equiJoinFields), building the hash table from the plan estimated to output fewer records.
+// It returns nil if there are no such fields, or if the records of that plan are not expected to fit in memory.
+func hashJoin(currentPlan, nextPlan plan.Plan, predicate *query.Predicate, transaction *tx.Transaction) plan.Plan {
+	fieldName1, fieldName2 := equiJoinFields(currentPlan, nextPlan, predicate)
+	if fieldName1 == "" {
+		return nil
+	}
+	hashJoin := NewHashJoinPlan(transaction, currentPlan, nextPlan, fieldName1, fieldName2)
+	if currentPlan.RecordsOutput() < nextPlan.RecordsOutput() {
+		hashJoin = NewHashJoinPlan(transaction, nextPlan, currentPlan, fieldName2, fieldName1)
+	}
+	if !hashJoin.fitsInMemory() {
+		return nil
+	}
+	return hashJoin
+}
+
+// semiJoin returns a semijoin of the plan with the plan of the subquery of the specified IN or EXISTS condition.
+// The subquery of an IN condition outputs a single field, whose type must be comparable with the type of the
+// field of the plan. Subqueries are not correlated: they cannot read the fields of the plan.
+func (qp *BasicQueryPlanner) semiJoin(currentPlan plan.Plan, semiJoin *parse.SemiJoin, transaction *tx.Transaction) (plan.Plan, error) {
+	subqueryPlan, err := qp.CreatePlan(semiJoin.Subquery(), transaction)
+	if err != nil {
+		return nil, err
+	}
+	fieldName := semiJoin.Field()
+	if fieldName == "" {
+		return NewSemiJoinPlan(currentPlan, subqueryPlan, "", ""), nil
+	}
+
+	if !currentPlan.Schema().HasField(fieldName) {
+		return nil, fmt.Errorf("field %s of the IN condition is not in the schema of the query (fields %s)",
+			fieldName, strings.Join(currentPlan.Schema().Fields(), ", "))
+	}
+	if subqueryFields := subqueryPlan.Schema().Fields(); len(subqueryFields) != 1 {
+		return nil, fmt.Errorf("the subquery of the IN condition on %s must output a single field, not %s",
+			fieldName, strings.Join(subqueryFields, ", "))
+	}
+	subqueryField := subqueryPlan.Schema().Fields()[0]
+	if err := qp.checkSemiJoinTypes(fieldName, currentPlan.Schema(), subqueryField, subqueryPlan.Schema()); err != nil {
+		return nil, err
+	}
+	return NewSemiJoinPlan(currentPlan, subqueryPlan, fieldName, subqueryField), nil
+}
+
+// ordersByGroupField returns true if the ORDER BY clause of the query refers to one of its group fields.
+func ordersByGroupField(queryData *parse.QueryData) bool {
+	for _, item := range queryData.OrderBy() {
+		for _, field := range queryData.GroupBy() {
+			if item.Field() == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CreateDumpBlockPlan creates a plan that returns the physical contents of the specified block of a table.
+func (qp *BasicQueryPlanner) CreateDumpBlockPlan(data *parse.DumpBlockData, transaction *tx.Transaction) (plan.Plan, error) {
+	return NewDumpBlockPlan(transaction, data.TableName(), data.BlockNumber(), qp.metadataManager)
+}
+
+// DumpDatabase writes the script recreating the tables, indexes and views of the database to the writer
+// (see DumpDatabase), and returns the number of records it dumped.
+func (qp *BasicQueryPlanner) DumpDatabase(w io.Writer, transaction *tx.Transaction) (int, error) {
+	return DumpDatabase(w, qp.metadataManager, transaction)
+}
+
+// CreateShowConstraintsPlan creates a plan that returns the constraints of a table.
+func (qp *BasicQueryPlanner) CreateShowConstraintsPlan(data *parse.ShowConstraintsData, transaction *tx.Transaction) (plan.Plan, error) {
+	return NewShowConstraintsPlan(transaction, data.TableName(), qp.metadataManager)
+}
+
+// CreateCheckTablePlan creates a plan that returns the inconsistencies found in a table (see CheckTable).
+func (qp *BasicQueryPlanner) CreateCheckTablePlan(data *parse.CheckTableData, transaction *tx.Transaction) (plan.Plan, error) {
+	return NewCheckTablePlan(transaction, data.TableName(), qp.metadataManager)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 func setupTestManagers(t *testing.T, blockSize, numBuffers int) (*file.Manager, *log.Manager, *buffer.Manager, *concurrency.LockTable) {
@@ -257,7 +258,7 @@ func TestBasicQueryPlanner_GroupBy(t *testing.T) {
 	require.NoError(t, err)
 	defer s.Close()
 
-	results := make(map[string]int)
+	results := make(map[string]float64)
 	require.NoError(t, s.BeforeFirst())
 	for {
 		hasNext, err := s.Next()
@@ -268,14 +269,14 @@ func TestBasicQueryPlanner_GroupBy(t *testing.T) {
 
 		dept, err := s.GetString("dept")
 		require.NoError(t, err)
-		avgSalary, err := s.GetInt("avgOfsalary")
+		avgSalary, err := s.GetFloat("avgOfsalary")
 		require.NoError(t, err)
 
 		results[dept] = avgSalary
 	}
 
-	assert.Equal(t, 85000, results["Engineering"])
-	assert.Equal(t, 62500, results["Sales"])
+	assert.Equal(t, 85000.0, results["Engineering"])
+	assert.Equal(t, 62500.0, results["Sales"])
 	require.NoError(t, queryTx.Commit())
 }
 
@@ -330,16 +331,74 @@ func TestBasicQueryPlanner_GroupByWithHaving(t *testing.T) {
 
 		product, err := s.GetString("product")
 		require.NoError(t, err)
-		total, err := s.GetInt("sumOfamount")
+		total, err := s.GetLong("sumOfamount")
 		require.NoError(t, err)
 
 		assert.Equal(t, "Widget", product)
-		assert.Equal(t, 250, total)
+		assert.Equal(t, int64(250), total)
 	}
 	assert.Equal(t, 1, count)
 	require.NoError(t, queryTx.Commit())
 }
 
+func TestBasicQueryPlanner_HavingAggregates(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+
+	mdm := createTableMetadataWithSchema(t, txn, "sales", map[string]interface{}{
+		"product": "string",
+		"amount":  0,
+	})
+
+	insertTestData(t, txn, "sales", mdm, []map[string]interface{}{
+		{"product": "Widget", "amount": 100},
+		{"product": "Widget", "amount": 150},
+		{"product": "Widget", "amount": 20},
+		{"product": "Gadget", "amount": 50},
+		{"product": "Gadget", "amount": 75},
+	})
+
+	require.NoError(t, txn.Commit())
+
+	qp := NewBasicQueryPlanner(mdm)
+
+	tests := []struct {
+		sql    string
+		fields []string
+	}{
+		// The having clause aggregates a field that is not selected.
+		{"select product from sales group by product having count(amount) > 2", []string{"product"}},
+		// The having clause repeats an aggregate of the select list, and adds another one.
+		{"select product, sum(amount) from sales group by product having sum(amount) > 100 and count(amount) > 2", []string{"product", "sumOfamount"}},
+	}
+	for _, tt := range tests {
+		queryData, err := parse.NewParser(tt.sql).Query()
+		require.NoError(t, err, tt.sql)
+
+		queryTx := tx.NewTransaction(fm, lm, bm, lt)
+		plan, err := qp.CreatePlan(queryData, queryTx)
+		require.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.fields, plan.Schema().Fields(), tt.sql)
+
+		s, err := plan.Open()
+		require.NoError(t, err, tt.sql)
+		var products []string
+		for {
+			hasNext, err := s.Next()
+			require.NoError(t, err, tt.sql)
+			if !hasNext {
+				break
+			}
+			product, err := s.GetString("product")
+			require.NoError(t, err)
+			products = append(products, product)
+		}
+		require.NoError(t, s.Close())
+		require.NoError(t, queryTx.Commit())
+		assert.Equal(t, []string{"Widget"}, products, tt.sql)
+	}
+}
+
 func TestBasicQueryPlanner_OrderBy(t *testing.T) {
 	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
 	txn := tx.NewTransaction(fm, lm, bm, lt)
@@ -433,7 +492,7 @@ func TestBasicQueryPlanner_ComplexQuery(t *testing.T) {
 	qp := NewBasicQueryPlanner(mdm)
 
 	sql := `
-		select category, date, sum(amount)
+		select category, date, sum(amount) as total
 		from orders
 		where amount > 500
 		group by category, date
@@ -455,7 +514,7 @@ func TestBasicQueryPlanner_ComplexQuery(t *testing.T) {
 	expected := []struct {
 		category string
 		date     string
-		total    int
+		total    int64
 	}{
 		{"Electronics", "2024-01", 4400},
 	}
@@ -473,7 +532,7 @@ func TestBasicQueryPlanner_ComplexQuery(t *testing.T) {
 		require.NoError(t, err)
 		date, err := s.GetString("date")
 		require.NoError(t, err)
-		total, err := s.GetInt("sumOfamount")
+		total, err := s.GetLong("total")
 		require.NoError(t, err)
 
 		assert.Equal(t, expected[count].category, category)
@@ -485,3 +544,77 @@ func TestBasicQueryPlanner_ComplexQuery(t *testing.T) {
 	assert.Equal(t, 1, count)
 	require.NoError(t, queryTx.Commit())
 }
+
+func TestBasicQueryPlanner_Aliases(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 16)
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table orders (product varchar(10), amount int)",
+		"insert into orders (product, amount) values ('Desk', 300), ('Laptop', 2000), ('Chair', 400), ('Laptop', 1200), ('Desk', 350), ('Lamp', 40)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	// The aggregate is output, ordered and filtered under its alias.
+	sql := "select product as item, sum(amount) as total from orders group by product having total > 100 order by total desc"
+	fields := []string{"item", "total"}
+	assert.Equal(t, []map[string]any{
+		{"item": "Laptop", "total": int64(3200)},
+		{"item": "Desk", "total": int64(650)},
+		{"item": "Chair", "total": int64(400)},
+	}, runPlannerQuery(t, p, sql, fm, lm, bm, lt, fields))
+
+	// The generated name of the aggregate, and the name of the field, can be used as well.
+	sql = "select product as item, sum(amount) as total from orders group by product having sumOfamount < 1000 order by product"
+	assert.Equal(t, []map[string]any{
+		{"item": "Chair", "total": int64(400)},
+		{"item": "Desk", "total": int64(650)},
+		{"item": "Lamp", "total": int64(40)},
+	}, runPlannerQuery(t, p, sql, fm, lm, bm, lt, fields))
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	queryPlan, err := p.CreateQueryPlan("select product as item, amount from orders where amount > 1000", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item", "amount"}, queryPlan.Schema().Fields())
+	assert.Equal(t, types.Varchar, queryPlan.Schema().Type("item"))
+
+	// Ordering by a name that is neither a field nor an alias is rejected when the query is planned.
+	_, err = p.CreateQueryPlan("select product, sum(amount) from orders group by product order by total desc", txn)
+	assert.ErrorContains(t, err, "ORDER BY field total")
+}
+
+func TestBasicQueryPlanner_UnknownFields(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 16)
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table orders (oid int, product varchar(10), amount int)",
+		"create table products (name varchar(10), price int)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	for sql, expected := range map[string]string{
+		"select oid, amout from orders":                                         "unknown field: select list field amout is not a field of table orders",
+		"select oid, amout * 2 as doubled from orders":                          "unknown field: select list field amout is not a field of table orders",
+		"select oid, cost from orders, products where product = name":           "unknown field: select list field cost is not a field of tables orders, products",
+		"select product, sum(amount) from orders group by prodct":               "unknown field: GROUP BY field prodct is not a field of table orders",
+		"select product, sum(amount) from orders group by product having x > 1": "unknown field: HAVING field x is not a field of table orders",
+		"select oid from orders order by price":                                 "unknown field: ORDER BY field price is not a field of table orders",
+	} {
+		// The error is returned when the query is planned, before any of its scans is opened.
+		_, err := p.CreateQueryPlan(sql, txn)
+		assert.ErrorIs(t, err, ErrUnknownField, sql)
+		assert.EqualError(t, err, expected, sql)
+	}
+
+	// The fields the query groups or aggregates on are output by the grouping, and can be filtered and ordered on.
+	_, err := p.CreateQueryPlan("select product, sum(amount) from orders group by product having sumOfamount > 1 order by product", txn)
+	assert.NoError(t, err)
+}
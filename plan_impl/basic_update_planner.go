@@ -1,6 +1,8 @@
 package plan_impl
 
 import (
+	"errors"
+
 	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/plan"
@@ -9,9 +11,17 @@ import (
 )
 
 var _ UpdatePlanner = &BasicUpdatePlanner{}
+var _ TableFilterer = &BasicUpdatePlanner{}
+var _ TypeCheckConfigurer = &BasicUpdatePlanner{}
 
+// BasicUpdatePlanner executes update statements by scanning the table for the affected records.
+// Like the IndexUpdatePlanner, it keeps every index on the table up to date, so that queries reading
+// through an index see the records it inserts, deletes and modifies.
 type BasicUpdatePlanner struct {
+	typeChecker
+	preparedInserts
 	metadataManager *metadata.Manager
+	tableFilters    *TableFilters
 }
 
 // NewBasicUpdatePlanner creates a new BasicUpdatePlanner.
@@ -19,6 +29,11 @@ func NewBasicUpdatePlanner(metadataManager *metadata.Manager) UpdatePlanner {
 	return &BasicUpdatePlanner{metadataManager: metadataManager}
 }
 
+// SetTableFilters sets the filters that the records updated, deleted or inserted in each table must satisfy.
+func (up *BasicUpdatePlanner) SetTableFilters(filters *TableFilters) {
+	up.tableFilters = filters
+}
+
 func (up *BasicUpdatePlanner) ExecuteDelete(data *parse.DeleteData, transaction *tx.Transaction) (int, error) {
 	var p plan.Plan
 	p, err := NewTablePlan(transaction, data.TableName(), up.metadataManager)
@@ -26,8 +41,25 @@ func (up *BasicUpdatePlanner) ExecuteDelete(data *parse.DeleteData, transaction
 		return 0, err
 	}
 
-	p = NewSelectPlan(p, data.Predicate())
-	s, err := p.Open()
+	data.Predicate().CoerceConstants(p.Schema())
+	if err := up.checkTypes(data.Predicate(), p.Schema()); err != nil {
+		return 0, err
+	}
+	if _, err := up.tableFilters.conjoinFilter(data.TableName(), p.Schema(), data.Predicate(), transaction); err != nil {
+		return 0, err
+	}
+	selectPlan, err := NewSelectPlan(p, data.Predicate())
+	if err != nil {
+		return 0, err
+	}
+	indexes, err := up.metadataManager.GetIndexInfo(data.TableName(), transaction)
+	if err != nil {
+		return 0, err
+	}
+	openIndex, closeIndexes := indexOpener(indexes)
+	defer closeIndexes()
+
+	s, err := selectPlan.Open()
 	if err != nil {
 		return 0, err
 	}
@@ -35,17 +67,21 @@ func (up *BasicUpdatePlanner) ExecuteDelete(data *parse.DeleteData, transaction
 	defer updateScan.Close()
 
 	count := 0
-	for {
+	for !limitReached(count, data.Limit()) {
 		hasNext, err := updateScan.Next()
 		if err != nil || !hasNext {
 			return count, err
 		}
 
+		if err := deleteIndexEntries(indexes, openIndex, updateScan); err != nil {
+			return count, err
+		}
 		if err := updateScan.Delete(); err != nil {
 			return count, err
 		}
 		count++
 	}
+	return count, nil
 }
 
 func (up *BasicUpdatePlanner) ExecuteModify(data *parse.ModifyData, transaction *tx.Transaction) (int, error) {
@@ -55,18 +91,41 @@ func (up *BasicUpdatePlanner) ExecuteModify(data *parse.ModifyData, transaction
 		return 0, err
 	}
 
-	p = NewSelectPlan(p, data.Predicate())
-	s, err := p.Open()
+	schema := p.Schema()
+	data.Predicate().CoerceConstants(schema)
+	if err := up.checkTypes(data.Predicate(), schema); err != nil {
+		return 0, err
+	}
+	filterPredicate, err := up.tableFilters.conjoinFilter(data.TableName(), schema, data.Predicate(), transaction)
+	if err != nil {
+		return 0, err
+	}
+	selectPlan, err := NewSelectPlan(p, data.Predicate())
+	if err != nil {
+		return 0, err
+	}
+	indexes, err := up.metadataManager.GetIndexInfo(data.TableName(), transaction)
+	if err != nil {
+		return 0, err
+	}
+	openIndex, closeIndexes := indexOpener(indexes)
+	defer closeIndexes()
+
+	s, err := selectPlan.Open()
 	if err != nil {
 		return 0, err
 	}
 	updateScan := s.(scan.UpdateScan)
 	defer updateScan.Close()
 
+	recordIDs, err := matchingRecordIDs(updateScan, data.Limit())
+	if err != nil {
+		return 0, err
+	}
+
 	count := 0
-	for {
-		hasNext, err := updateScan.Next()
-		if err != nil || !hasNext {
+	for _, recordID := range recordIDs {
+		if err := updateScan.MoveToRecordID(recordID); err != nil {
 			return count, err
 		}
 
@@ -74,52 +133,118 @@ func (up *BasicUpdatePlanner) ExecuteModify(data *parse.ModifyData, transaction
 		if err != nil {
 			return count, err
 		}
+		if val, err = coerceFieldValue(data.TableName(), schema, data.TargetField(), val); err != nil {
+			return count, err
+		}
+		newValues := map[string]any{data.TargetField(): val}
+		if err := checkFilter(data.TableName(), filterPredicate, updateScan, newValues); err != nil {
+			return count, err
+		}
+
+		// the new index entries are computed before the record is modified, so that a duplicate key leaves it unchanged.
+		oldEntries, err := indexEntries(indexes, updateScan)
+		if err != nil {
+			return count, err
+		}
+		newEntries, err := indexEntries(indexes, &rowScan{base: updateScan, values: newValues})
+		if err != nil {
+			return count, err
+		}
+		if err := checkUniqueEntries(transaction, indexes, openIndex, oldEntries, newEntries, recordID); err != nil {
+			return count, err
+		}
 		if err := updateScan.SetVal(data.TargetField(), val); err != nil {
 			return count, err
 		}
+		if err := updateIndexEntries(indexes, openIndex, oldEntries, newEntries, recordID); err != nil {
+			return count, err
+		}
 		count++
 	}
+	return count, nil
 }
 
+// ExecuteInsert inserts the record, and an index record into each index the record belongs in,
+// through the prepared insert of the transaction for the table, so that consecutive inserts into
+// the same table only plan the first one.
 func (up *BasicUpdatePlanner) ExecuteInsert(data *parse.InsertData, transaction *tx.Transaction) (int, error) {
-	p, err := NewTablePlan(transaction, data.TableName(), up.metadataManager)
-	if err != nil {
-		return 0, err
+	if data.OnConflict() != nil {
+		return 0, errors.New("ON CONFLICT is not supported by the basic update planner")
 	}
-
-	s, err := p.Open()
-	if err != nil {
-		return 0, err
-	}
-	updateScan := s.(scan.UpdateScan)
-	defer updateScan.Close()
-
-	if err := updateScan.Insert(); err != nil {
-		return 0, err
+	prepare := func() (*preparedInsert, error) {
+		indexes, err := up.metadataManager.GetIndexInfo(data.TableName(), transaction)
+		if err != nil {
+			return nil, err
+		}
+		return prepareInsert(data.TableName(), indexes, up.metadataManager, transaction)
 	}
+	err := up.insert(data.TableName(), transaction, prepare, func(prepared *preparedInsert) error {
+		// convert the values before writing anything, so that an invalid value fails the whole insert.
+		vals, err := coerceFieldValues(data.TableName(), prepared.schema, data.Fields(), data.Values())
+		if err != nil {
+			return err
+		}
+		if err := up.tableFilters.checkInsert(data, prepared.schema, vals, transaction); err != nil {
+			return err
+		}
 
-	vals := data.Values()
-	for idx, field := range data.Fields() {
-		val := vals[idx]
-		if err := updateScan.SetVal(field, val); err != nil {
-			return 0, err
+		values := make(map[string]any, len(vals))
+		for i, field := range data.Fields() {
+			values[field] = vals[i]
+		}
+		if err := prepared.lockUniqueKeys(values, transaction); err != nil {
+			return err
 		}
+		return prepared.insertRecord(data.Fields(), vals, transaction)
+	})
+	if err != nil {
+		return 0, err
 	}
-
 	return 1, nil
 }
 
+// ExecuteCreateTable creates the table. Like every DDL statement, it releases the prepared inserts of the transaction.
 func (up *BasicUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, transaction *tx.Transaction) (int, error) {
-	err := up.metadataManager.CreateTable(data.TableName(), data.NewSchema(), transaction)
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
+	options := metadata.TableOptions{Compressed: data.Compressed()}
+	err := up.metadataManager.CreateTableWithOptions(data.TableName(), data.NewSchema(), options, transaction)
 	return 0, err
 }
 
 func (up *BasicUpdatePlanner) ExecuteCreateView(data *parse.CreateViewData, transaction *tx.Transaction) (int, error) {
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
 	err := up.metadataManager.CreateView(data.ViewName(), data.ViewDefinition(), transaction)
 	return 0, err
 }
 
+// ExecuteCreateIndex creates the index and populates it with the records already in the table (see createIndex).
+// Like every DDL statement, it releases the prepared inserts of the transaction.
 func (up *BasicUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, transaction *tx.Transaction) (int, error) {
-	err := up.metadataManager.CreateIndex(data.IndexName(), data.TableName(), data.FieldName(), transaction)
-	return 0, err
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
+	return 0, createIndex(data, up.metadataManager, transaction)
+}
+
+// ExecuteDropTable removes the table and its indexes from the catalog, and deletes their files once the
+// transaction commits (see metadata.Manager#DropTable). Like every DDL statement, it releases the prepared
+// inserts of the transaction, which may hold the table open.
+func (up *BasicUpdatePlanner) ExecuteDropTable(data *parse.DropTableData, transaction *tx.Transaction) (int, error) {
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
+	return 0, up.metadataManager.DropTable(data.TableName(), transaction)
+}
+
+// ExecuteDropIndex removes the index from the catalog, and deletes its files once the transaction commits
+// (see metadata.Manager#DropIndex). Like every DDL statement, it releases the prepared inserts of the transaction.
+func (up *BasicUpdatePlanner) ExecuteDropIndex(data *parse.DropIndexData, transaction *tx.Transaction) (int, error) {
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
+	return 0, up.metadataManager.DropIndex(data.IndexName(), transaction)
 }
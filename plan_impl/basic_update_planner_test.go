@@ -1,6 +1,9 @@
 package plan_impl
 
 import (
+	"os"
+	"path/filepath"
+
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/types"
 	"testing"
@@ -184,6 +187,58 @@ func TestBasicUpdatePlanner_Modify(t *testing.T) {
 	assert.Equal(t, 60, rows[2]["age"])
 }
 
+func TestBasicUpdatePlanner_ModifyArithmetic(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	up := NewBasicUpdatePlanner(mdm)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE users (id INT, age INT, score FLOAT)",
+		"INSERT INTO users (id, age, score) VALUES (1, 20, 1.5)",
+		"INSERT INTO users (id, age, score) VALUES (2, 30, null)",
+		"INSERT INTO users (id, age, score) VALUES (3, 40, 2.5)",
+	} {
+		cmd, err := parse.NewParser(sql).UpdateCmd()
+		require.NoError(t, err, sql)
+		switch data := cmd.(type) {
+		case *parse.CreateTableData:
+			_, err = up.ExecuteCreateTable(data, txn)
+		case *parse.InsertData:
+			_, err = up.ExecuteInsert(data, txn)
+		}
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	// The new value is computed from the fields of each record, and a null operand makes it null.
+	for _, sql := range []string{
+		"UPDATE users SET age = age + 1000 WHERE id >= 2",
+		"UPDATE users SET score = score * (age - 10) / 2",
+	} {
+		cmd, err := parse.NewParser(sql).UpdateCmd()
+		require.NoError(t, err, sql)
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		_, err = up.ExecuteModify(cmd.(*parse.ModifyData), txn)
+		require.NoError(t, err, sql)
+		require.NoError(t, txn.Commit())
+	}
+
+	rows := runQuery(t, mdm, "select id, age, score from users order by id", fm, lm, bm, lt)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []any{20, 7.5}, []any{rows[0]["age"], rows[0]["score"]})
+	assert.Equal(t, []any{1030, nil}, []any{rows[1]["age"], rows[1]["score"]})
+	assert.Equal(t, []any{1040, 1287.5}, []any{rows[2]["age"], rows[2]["score"]})
+
+	// Dividing by zero fails the update.
+	cmd, err := parse.NewParser("UPDATE users SET age = age / (id - 2)").UpdateCmd()
+	require.NoError(t, err)
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = up.ExecuteModify(cmd.(*parse.ModifyData), txn)
+	assert.ErrorIs(t, err, types.ErrDivisionByZero)
+	require.NoError(t, txn.Rollback())
+}
+
 func TestBasicUpdatePlanner_Delete(t *testing.T) {
 	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
 	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
@@ -235,6 +290,29 @@ func TestBasicUpdatePlanner_Delete(t *testing.T) {
 	assert.Equal(t, 1, rows[0]["val"])
 }
 
+func TestBasicUpdatePlanner_CreateTableInvalidName(t *testing.T) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	up := NewBasicUpdatePlanner(mdm)
+
+	// A table created programmatically, rather than parsed, is held to the names the parser accepts.
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err = up.ExecuteCreateTable(parse.NewCompressedCreateTableData("archive/people", schema, true), txn)
+	assert.ErrorIs(t, err, metadata.ErrInvalidName)
+	require.NoError(t, txn.Rollback())
+
+	_, err = os.Stat(filepath.Join(dbDir, "archive"))
+	assert.True(t, os.IsNotExist(err), "no directory should be created for the table")
+}
+
 func TestBasicUpdatePlanner_CreateView(t *testing.T) {
 	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
 	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
@@ -277,5 +355,64 @@ func TestBasicUpdatePlanner_CreateIndex(t *testing.T) {
 	// Check that the index was created
 	idxInfo, err := mdm.GetIndexInfo("users", txn)
 	require.NoError(t, err)
-	require.Contains(t, idxInfo, "user_id")
+	require.NotNil(t, indexOnField(idxInfo, "user_id"))
+}
+
+func TestBasicUpdatePlanner_MaintainsIndexes(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewBasicUpdatePlanner(mdm))
+
+	execute := func(sql string, txn *tx.Transaction) error {
+		_, err := p.ExecuteUpdate(sql, txn)
+		return err
+	}
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, execute("create table users (id int, name varchar(10))", txn))
+	require.NoError(t, execute("insert into users (id, name) values (1, 'ann')", txn))
+	// The index is populated with the records already in the table.
+	require.NoError(t, execute("create index idx_name on users (name)", txn))
+	require.NoError(t, execute("create unique index idx_id on users (id)", txn))
+	require.NoError(t, execute("insert into users (id, name) values (2, 'bob')", txn))
+	require.NoError(t, execute("insert into users (id, name) values (3, 'cid')", txn))
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, execute("update users set name = 'dan' where id = 3", txn))
+	require.NoError(t, execute("delete from users where id = 1", txn))
+	assert.ErrorIs(t, execute("insert into users (id, name) values (2, 'eve')", txn), ErrDuplicateKey)
+	require.NoError(t, txn.Commit())
+
+	// idsWithName reads the ids of the records having the name through the index.
+	idsWithName := func(name string) []int {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		indexes, err := mdm.GetIndexInfo("users", txn)
+		require.NoError(t, err)
+		indexInfo := indexOnField(indexes, "name")
+		require.NotNil(t, indexInfo)
+		tablePlan, err := NewTablePlan(txn, "users", mdm)
+		require.NoError(t, err)
+
+		s, err := NewIndexSelectPlan(tablePlan, indexInfo, name).Open()
+		require.NoError(t, err)
+		defer s.Close()
+		var ids []int
+		for {
+			hasNext, err := s.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				return ids
+			}
+			id, err := s.GetInt("id")
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+	}
+	assert.Empty(t, idsWithName("ann"))
+	assert.Equal(t, []int{2}, idsWithName("bob"))
+	assert.Empty(t, idsWithName("cid"))
+	assert.Equal(t, []int{3}, idsWithName("dan"))
+	assert.Empty(t, idsWithName("eve"))
 }
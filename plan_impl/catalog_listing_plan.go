@@ -0,0 +1,66 @@
+package plan_impl
+
+import (
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &CatalogListingPlan{}
+var _ NodePlan = &CatalogListingPlan{}
+
+// CatalogListingPlan is the plan reading a catalog listing, such as metadata.ColumnsListing,
+// over the records of the listing read from the catalog when the plan is created.
+type CatalogListingPlan struct {
+	listing string
+	schema  *record.Schema
+	rows    [][]any
+}
+
+// NewCatalogListingPlan creates a plan reading the specified catalog listing.
+func NewCatalogListingPlan(transaction *tx.Transaction, listing string, metadataManager *metadata.Manager) (*CatalogListingPlan, error) {
+	rows, err := metadataManager.GetCatalogListing(listing, transaction)
+	if err != nil {
+		return nil, err
+	}
+	return &CatalogListingPlan{
+		listing: listing,
+		schema:  metadata.CatalogListingSchema(listing),
+		rows:    rows,
+	}, nil
+}
+
+// Open creates a scan over the records of the listing.
+func (p *CatalogListingPlan) Open() (scan.Scan, error) {
+	return query.NewConstantScan(p.schema, p.rows), nil
+}
+
+// BlocksAccessed returns 0, since the records were read when the plan was created.
+func (p *CatalogListingPlan) BlocksAccessed() int {
+	return 0
+}
+
+// RecordsOutput returns the number of records of the listing.
+func (p *CatalogListingPlan) RecordsOutput() int {
+	return len(p.rows)
+}
+
+// DistinctValues returns the number of records, which is the most distinct values a field can have.
+func (p *CatalogListingPlan) DistinctValues(fieldName string) int {
+	return p.RecordsOutput()
+}
+
+// Schema returns the schema of the listing.
+func (p *CatalogListingPlan) Schema() *record.Schema {
+	return p.schema
+}
+
+// ToNode returns the description of the catalog listing plan.
+func (p *CatalogListingPlan) ToNode() *PlanNode {
+	node := newPlanNode("CatalogListing", p)
+	node.Table = p.listing
+	return node
+}
@@ -0,0 +1,116 @@
+package plan_impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+func TestCatalogListingPlan(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupConstraintsTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE TABLE employees (id INT, name VARCHAR(20), dept INT)",
+		"CREATE TABLE depts (id INT, title VARCHAR(10)) WITH COMPRESSION",
+		"CREATE UNIQUE INDEX employees_id ON employees (id) USING btree",
+		"CREATE INDEX employees_dept ON employees (dept, name)",
+		"CREATE VIEW sales AS SELECT id, name FROM employees WHERE dept = 1",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	require.NoError(t, txn.Commit())
+
+	columnFields := []string{"tblname", "field_name", metadata.ColumnTypeField, metadata.ColumnLengthField, metadata.ColumnOrdinalField}
+	assert.Equal(t, []map[string]any{
+		{"tblname": "employees", "field_name": "id", "field_type": "int", "field_length": 0, "field_ordinal": 0},
+		{"tblname": "employees", "field_name": "name", "field_type": "varchar", "field_length": 20, "field_ordinal": 1},
+		{"tblname": "employees", "field_name": "dept", "field_type": "int", "field_length": 0, "field_ordinal": 2},
+	}, runPlannerQuery(t, p, "SELECT * FROM dropdb_columns WHERE tblname = 'employees'", fm, lm, bm, lt, columnFields))
+
+	assert.Equal(t, []map[string]any{
+		{"tblname": "employees", "compressed": false},
+		{"tblname": "depts", "compressed": true},
+	}, runPlannerQuery(t, p, "SELECT tblname, compressed FROM dropdb_tables", fm, lm, bm, lt, []string{"tblname", "compressed"}))
+
+	indexFields := []string{"index_name", "tblname", "column_names", "index_type", "index_unique"}
+	assert.Equal(t, []map[string]any{
+		{"index_name": "employees_dept", "tblname": "employees", "column_names": "dept,name", "index_type": "hash", "index_unique": false},
+		{"index_name": "employees_id", "tblname": "employees", "column_names": "id", "index_type": "btree", "index_unique": true},
+	}, runPlannerQuery(t, p, "SELECT * FROM dropdb_indexes ORDER BY index_name", fm, lm, bm, lt, indexFields))
+
+	assert.Equal(t, []map[string]any{{"view_name": "sales"}},
+		runPlannerQuery(t, p, "SELECT view_name FROM dropdb_views", fm, lm, bm, lt, []string{"view_name"}))
+
+	constraintFields := []string{"tblname", metadata.ConstraintNameField, metadata.ConstraintKindField,
+		metadata.ConstraintColumnsField, metadata.ConstraintPredicateField}
+	assert.Equal(t, []map[string]any{
+		{"tblname": "employees", "constraint_name": "employees_id", "constraint_kind": "UNIQUE", "column_names": "id", "predicate": ""},
+	}, runPlannerQuery(t, p, "SELECT * FROM dropdb_constraints WHERE constraint_kind = 'UNIQUE'", fm, lm, bm, lt, constraintFields))
+
+	// The listings are read like any table: joined with each other and aggregated.
+	// The indexed field id is a field of both tables.
+	assert.Equal(t, []map[string]any{{"index_name": "employees_id", "field_type": "int"}, {"index_name": "employees_id", "field_type": "int"}},
+		runPlannerQuery(t, p, "SELECT index_name, field_type FROM dropdb_columns, dropdb_indexes WHERE field_name = column_names",
+			fm, lm, bm, lt, []string{"index_name", "field_type"}))
+	assert.ElementsMatch(t, []map[string]any{
+		{"tblname": "employees", "countOfall": int64(3)},
+		{"tblname": "depts", "countOfall": int64(2)},
+	}, runPlannerQuery(t, p, "SELECT tblname, COUNT(*) FROM dropdb_columns GROUP BY tblname", fm, lm, bm, lt, []string{"tblname", "countOfall"}))
+
+	// The listings reflect the statements of the transaction reading them, as soon as they are executed.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("DROP TABLE depts", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("DROP INDEX employees_dept", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("CREATE TABLE projects (code VARCHAR(8))", txn)
+	require.NoError(t, err)
+	readNames := func(sql, field string) []string {
+		queryPlan, err := p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err, sql)
+		s, err := queryPlan.Open()
+		require.NoError(t, err, sql)
+		defer s.Close()
+		var names []string
+		for {
+			hasNext, err := s.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				return names
+			}
+			name, err := s.GetString(field)
+			require.NoError(t, err)
+			names = append(names, name)
+		}
+	}
+	assert.Equal(t, []string{"employees", "projects"}, readNames("SELECT tblname FROM dropdb_tables", "tblname"))
+	assert.Equal(t, []string{"employees_id"}, readNames("SELECT index_name FROM dropdb_indexes", "index_name"))
+	require.NoError(t, txn.Commit())
+
+	// The listings cannot be changed, nor shadowed by tables or views of the same name.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	for _, statement := range []string{
+		"UPDATE dropdb_columns SET field_name = 'x' WHERE tblname = 'employees'",
+		"DELETE FROM dropdb_tables",
+		"INSERT INTO dropdb_views (view_name, view_definition) VALUES ('v', 'select id from employees')",
+		"CREATE INDEX listing_idx ON dropdb_indexes (index_name)",
+		"DROP TABLE dropdb_tables",
+		"CREATE TABLE dropdb_views (id INT)",
+		"CREATE VIEW dropdb_columns AS SELECT id FROM employees",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		assert.ErrorIs(t, err, metadata.ErrReadOnlyTable, statement)
+	}
+	assert.Len(t, readNames("SELECT field_name FROM dropdb_columns", "field_name"), 4)
+
+	explanation, err := p.Explain("SELECT tblname FROM dropdb_tables", txn)
+	require.NoError(t, err)
+	assert.Contains(t, explanation, "CatalogListing")
+}
@@ -0,0 +1,89 @@
+package plan_impl
+
+import (
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+const (
+	// CheckSeverityField is the field of the output of a CHECK TABLE statement that holds the severity of a finding.
+	CheckSeverityField = "severity"
+	// CheckLocationField is the field of the output of a CHECK TABLE statement that holds the location of a finding.
+	CheckLocationField = "location"
+	// CheckDescriptionField is the field of the output of a CHECK TABLE statement that holds the description of a finding.
+	CheckDescriptionField = "description"
+)
+
+var _ plan.Plan = &CheckTablePlan{}
+var _ NodePlan = &CheckTablePlan{}
+
+// CheckTablePlan is the plan of a CHECK TABLE statement, which returns the inconsistencies found
+// by checking a table against its indexes and its catalog (see CheckTable), one record per finding.
+type CheckTablePlan struct {
+	transaction     *tx.Transaction
+	tableName       string
+	metadataManager *metadata.Manager
+	schema          *record.Schema
+}
+
+// NewCheckTablePlan creates a plan that checks the specified table. The table is checked when the plan is opened.
+func NewCheckTablePlan(transaction *tx.Transaction, tableName string, metadataManager *metadata.Manager) (*CheckTablePlan, error) {
+	// Fail early if the table does not exist.
+	if _, err := metadataManager.GetLayout(tableName, transaction); err != nil {
+		return nil, err
+	}
+	schema := record.NewSchema()
+	schema.AddStringField(CheckSeverityField, len(CheckWarning))
+	schema.AddStringField(CheckLocationField, 64)
+	schema.AddStringField(CheckDescriptionField, 200)
+	return &CheckTablePlan{
+		transaction:     transaction,
+		tableName:       tableName,
+		metadataManager: metadataManager,
+		schema:          schema,
+	}, nil
+}
+
+// Open checks the table and creates a scan over its findings.
+func (p *CheckTablePlan) Open() (scan.Scan, error) {
+	findings, err := CheckTable(p.tableName, p.metadataManager, p.transaction)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]any, len(findings))
+	for i, finding := range findings {
+		rows[i] = []any{finding.Severity, finding.Location, finding.Description}
+	}
+	return query.NewConstantScan(p.schema, rows), nil
+}
+
+// BlocksAccessed returns 0, since the cost of the check is not estimated.
+func (p *CheckTablePlan) BlocksAccessed() int {
+	return 0
+}
+
+// RecordsOutput returns 0, since a consistent table has no findings.
+func (p *CheckTablePlan) RecordsOutput() int {
+	return 0
+}
+
+// DistinctValues returns 0, since a consistent table has no findings.
+func (p *CheckTablePlan) DistinctValues(fieldName string) int {
+	return 0
+}
+
+// Schema returns the schema of the findings.
+func (p *CheckTablePlan) Schema() *record.Schema {
+	return p.schema
+}
+
+// ToNode returns the description of the check table plan.
+func (p *CheckTablePlan) ToNode() *PlanNode {
+	node := newPlanNode("CheckTable", p)
+	node.Table = p.tableName
+	return node
+}
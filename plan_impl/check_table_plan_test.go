@@ -0,0 +1,261 @@
+package plan_impl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+)
+
+// checkTableTest is a database holding a table of 10 people, with an index on their id
+// and a partial index on the names of the active ones, the people with an even id.
+// Person i is the row at slot i of block 0.
+type checkTableTest struct {
+	planner *Planner
+	mdm     *metadata.Manager
+	fm      *file.Manager
+	lm      *log.Manager
+	bm      *buffer.Manager
+	lt      *concurrency.LockTable
+}
+
+func setupCheckTableTest(t *testing.T) *checkTableTest {
+	p, mdm, fm, lm, bm, lt := setupConstraintsTest(t)
+	c := &checkTableTest{planner: p, mdm: mdm, fm: fm, lm: lm, bm: bm, lt: lt}
+
+	c.plant(t, func(txn *tx.Transaction) {
+		for _, statement := range []string{
+			"CREATE TABLE people (id INT, name VARCHAR(8), active BOOL)",
+			"CREATE INDEX people_id ON people (id)",
+			"CREATE INDEX active_name ON people (name) WHERE active = true",
+		} {
+			_, err := p.ExecuteUpdate(statement, txn)
+			require.NoError(t, err, statement)
+		}
+		for i := 0; i < 10; i++ {
+			_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO people (id, name, active) VALUES (%d, 'p%d', %t)", i, i, i%2 == 0), txn)
+			require.NoError(t, err)
+		}
+		layout, err := mdm.GetLayout("people", txn)
+		require.NoError(t, err)
+		require.Greater(t, txn.BlockSize()/layout.SlotSize(), 10, "the people must fit in block 0")
+	})
+	return c
+}
+
+// plant runs the function in a transaction of its own, which is committed.
+func (c *checkTableTest) plant(t *testing.T, f func(txn *tx.Transaction)) {
+	txn := tx.NewTransaction(c.fm, c.lm, c.bm, c.lt)
+	f(txn)
+	require.NoError(t, txn.Commit())
+}
+
+// setInt writes an integer at the specified offset of block 0 of the table, bypassing its layout.
+func (c *checkTableTest) setInt(t *testing.T, txn *tx.Transaction, offset, val int) {
+	block := table.BlockID("people", 0)
+	require.NoError(t, txn.Pin(block))
+	defer txn.Unpin(block)
+	require.NoError(t, txn.SetInt(block, offset, val, true))
+}
+
+// openIndex opens the specified index of the table.
+func (c *checkTableTest) openIndex(t *testing.T, txn *tx.Transaction, indexName string) *metadata.IndexInfo {
+	indexes, err := c.mdm.GetIndexInfo("people", txn)
+	require.NoError(t, err)
+	for _, indexInfo := range indexes {
+		if indexInfo.IndexName() == indexName {
+			return indexInfo
+		}
+	}
+	require.Failf(t, "index not found", "index %s", indexName)
+	return nil
+}
+
+// check runs CHECK TABLE both as a query, returning its findings, and as an update, whose count must match.
+func (c *checkTableTest) check(t *testing.T) []CheckFinding {
+	rows := runPlannerQuery(t, c.planner, "CHECK TABLE people", c.fm, c.lm, c.bm, c.lt,
+		[]string{CheckSeverityField, CheckLocationField, CheckDescriptionField})
+	findings := make([]CheckFinding, len(rows))
+	for i, row := range rows {
+		findings[i] = CheckFinding{
+			Severity:    row[CheckSeverityField].(string),
+			Location:    row[CheckLocationField].(string),
+			Description: row[CheckDescriptionField].(string),
+		}
+	}
+
+	txn := tx.NewTransaction(c.fm, c.lm, c.bm, c.lt)
+	count, err := c.planner.ExecuteUpdate("CHECK TABLE people", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+	assert.Equal(t, len(findings), count)
+	assert.Equal(t, 0, c.bm.Stats().Pinned, "the check must unpin the blocks it reads")
+	return findings
+}
+
+func TestCheckTable_Clean(t *testing.T) {
+	c := setupCheckTableTest(t)
+	assert.Empty(t, c.check(t))
+
+	// The deleted and modified rows leave the table and its indexes consistent.
+	c.plant(t, func(txn *tx.Transaction) {
+		for _, statement := range []string{
+			"DELETE FROM people WHERE id = 4",
+			"UPDATE people SET active = true WHERE id = 5",
+			"UPDATE people SET name = 'renamed' WHERE id = 6",
+		} {
+			_, err := c.planner.ExecuteUpdate(statement, txn)
+			require.NoError(t, err, statement)
+		}
+	})
+	assert.Empty(t, c.check(t))
+
+	txn := tx.NewTransaction(c.fm, c.lm, c.bm, c.lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	_, err := c.planner.CreateQueryPlan("CHECK TABLE missing", txn)
+	assert.ErrorContains(t, err, "table missing not found")
+}
+
+func TestCheckTable_Slots(t *testing.T) {
+	c := setupCheckTableTest(t)
+	c.plant(t, func(txn *tx.Transaction) {
+		layout, err := c.mdm.GetLayout("people", txn)
+		require.NoError(t, err)
+		// Person 1 is inactive, so only the id index points at its slot.
+		c.setInt(t, txn, 1*layout.SlotSize(), 7)
+		// The name of person 2 is longer than its field.
+		c.setInt(t, txn, 2*layout.SlotSize()+layout.Offset("name"), 1000)
+	})
+
+	findings := c.check(t)
+	require.Len(t, findings, 5, "%v", findings)
+	assert.Equal(t, CheckFinding{CheckError, "block 0 slot 1", "flag 7 is neither empty (0) nor used (1)"}, findings[0])
+	assert.Equal(t, CheckError, findings[1].Severity)
+	assert.Equal(t, "block 0 slot 2", findings[1].Location)
+	assert.Contains(t, findings[1].Description, "field name cannot be read: string length 1000")
+	// The rows that cannot be read are not counted, and their index records are not compared with them.
+	assert.Equal(t, CheckFinding{CheckWarning, "index active_name", "record counter is 5, but the index includes 4 rows of the table"}, findings[2])
+	assert.Equal(t, CheckFinding{CheckWarning, "index people_id", "record counter is 10, but the index includes 8 rows of the table"}, findings[3])
+	assert.Equal(t, CheckFinding{CheckError, "index people_id", "record with key 1 points at block 0 slot 1, which is not used"}, findings[4])
+}
+
+func TestCheckTable_MissingIndexRecord(t *testing.T) {
+	c := setupCheckTableTest(t)
+	c.plant(t, func(txn *tx.Transaction) {
+		idx, err := c.openIndex(t, txn, "people_id").Open()
+		require.NoError(t, err)
+		defer idx.Close()
+		require.NoError(t, idx.Delete(5, record.NewID(0, 5)))
+	})
+
+	assert.ElementsMatch(t, []CheckFinding{
+		{CheckError, "index people_id", "no record for the row at block 0 slot 5, whose id is 5"},
+		{CheckWarning, "index people_id", "record counter is 9, but the index includes 10 rows of the table"},
+	}, c.check(t))
+}
+
+func TestCheckTable_DanglingIndexRecords(t *testing.T) {
+	c := setupCheckTableTest(t)
+	c.plant(t, func(txn *tx.Transaction) {
+		idx, err := c.openIndex(t, txn, "people_id").Open()
+		require.NoError(t, err)
+		defer idx.Close()
+		require.NoError(t, idx.Insert(99, record.NewID(0, 3)))
+		require.NoError(t, idx.Insert(98, record.NewID(0, 10)))
+		require.NoError(t, idx.Insert(97, record.NewID(4, 0)))
+
+		// Person 3 is inactive, so the partial index must not include its name.
+		partial, err := c.openIndex(t, txn, "active_name").Open()
+		require.NoError(t, err)
+		defer partial.Close()
+		require.NoError(t, partial.Insert("p3", record.NewID(0, 3)))
+	})
+
+	assert.ElementsMatch(t, []CheckFinding{
+		{CheckWarning, "index people_id", "record counter is 13, but the index includes 10 rows of the table"},
+		{CheckWarning, "index active_name", "record counter is 6, but the index includes 5 rows of the table"},
+		{CheckError, "index people_id", "record with key 99 points at block 0 slot 3, whose id is 3"},
+		{CheckError, "index people_id", "record with key 98 points at block 0 slot 10, which is not used"},
+		{CheckError, "index people_id", "record with key 97 points at block 4, but the table has 1 blocks"},
+		{CheckError, "index active_name", "record with key p3 points at block 0 slot 3, whose row the index does not include"},
+	}, c.check(t))
+}
+
+func TestCheckTable_RecordCounter(t *testing.T) {
+	c := setupCheckTableTest(t)
+	c.plant(t, func(txn *tx.Transaction) {
+		// The counter is the first integer of the stats block of the index.
+		block := file.NewBlockId("people_id-stats", 0)
+		require.NoError(t, txn.Pin(block))
+		defer txn.Unpin(block)
+		require.NoError(t, txn.SetInt(block, 0, 12, true))
+	})
+
+	assert.Equal(t, []CheckFinding{
+		{CheckWarning, "index people_id", "record counter is 12, but the index includes 10 rows of the table"},
+	}, c.check(t))
+}
+
+func TestCheckTable_Catalog(t *testing.T) {
+	c := setupCheckTableTest(t)
+	var expected *record.Layout
+	c.plant(t, func(txn *tx.Transaction) {
+		layout, err := c.mdm.GetLayout("people", txn)
+		require.NoError(t, err)
+		expected = layout
+		updateCatalog(t, c.mdm, txn, "field_catalog", "people", "name", "offset", layout.Offset("name")+4)
+	})
+
+	findings := c.check(t)
+	require.NotEmpty(t, findings)
+	assert.Equal(t, CheckFinding{CheckError, "catalog field name",
+		fmt.Sprintf("offset is %d, but the layout of the table puts the field at offset %d", expected.Offset("name")+4, expected.Offset("name"))}, findings[0])
+
+	// Without a usable slot size, the slots are not checked.
+	c.plant(t, func(txn *tx.Transaction) {
+		updateCatalog(t, c.mdm, txn, "table_catalog", "people", "", "slot_size", 0)
+	})
+	assert.Equal(t, []CheckFinding{
+		{CheckError, "catalog table people", fmt.Sprintf("slot size is 0, but the fields of the table take %d bytes", expected.SlotSize())},
+		findings[0],
+		{CheckError, "catalog table people", "slot size 0 does not fit a block of 800 bytes, so the slots of the table were not checked"},
+	}, c.check(t))
+}
+
+// updateCatalog sets an integer field of the catalog record of the specified table, and field if any.
+func updateCatalog(t *testing.T, mdm *metadata.Manager, txn *tx.Transaction, catalogTable, tableName, fieldName, catalogField string, val int) {
+	layout, err := mdm.GetLayout(catalogTable, txn)
+	require.NoError(t, err)
+	ts, err := table.NewTableScan(txn, catalogTable, layout)
+	require.NoError(t, err)
+	defer ts.Close()
+	for {
+		next, err := ts.Next()
+		require.NoError(t, err)
+		require.True(t, next, "catalog record not found")
+		currentTable, err := ts.GetString("table_name")
+		require.NoError(t, err)
+		if currentTable != tableName {
+			continue
+		}
+		if fieldName != "" {
+			currentField, err := ts.GetString("field_name")
+			require.NoError(t, err)
+			if currentField != fieldName {
+				continue
+			}
+		}
+		require.NoError(t, ts.SetInt(catalogField, val))
+		return
+	}
+}
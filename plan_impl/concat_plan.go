@@ -0,0 +1,108 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ plan.Plan = &ConcatPlan{}
+var _ NodePlan = &ConcatPlan{}
+
+// ConcatPlan is the plan of UNION ALL, which outputs the records of its first plan, followed by those of its
+// second plan (see query.ConcatScan). The fields of the second plan match those of the first plan by position.
+type ConcatPlan struct {
+	plan1  plan.Plan
+	plan2  plan.Plan
+	schema *record.Schema
+}
+
+// NewConcatPlan creates a plan outputting the records of both plans, whose fields are named after the fields of
+// the first plan. Both plans must have as many fields, and the fields in the same position must have comparable
+// types: the field output has their type if it is the same, the longest length of varchar fields being kept, or
+// else the numeric type holding the values of both, a float if either of them is a float.
+// Otherwise, an error wrapping query.ErrTypeMismatch is returned.
+func NewConcatPlan(plan1, plan2 plan.Plan) (*ConcatPlan, error) {
+	fields1, fields2 := plan1.Schema().Fields(), plan2.Schema().Fields()
+	if len(fields1) != len(fields2) {
+		return nil, fmt.Errorf("the queries of a UNION must select as many fields, not %d and %d", len(fields1), len(fields2))
+	}
+	schema := record.NewSchema()
+	for i, field1 := range fields1 {
+		type1, type2 := plan1.Schema().Type(field1), plan2.Schema().Type(fields2[i])
+		if !types.AreComparable(type1, type2) {
+			return nil, fmt.Errorf("%w: field %s of type %s united with field %s of type %s",
+				query.ErrTypeMismatch, field1, type1, fields2[i], type2)
+		}
+		if type1 != type2 {
+			schema.AddField(field1, widerNumericType(type1, type2), 0)
+			continue
+		}
+		schema.AddField(field1, type1, max(plan1.Schema().Length(field1), plan2.Schema().Length(fields2[i])))
+	}
+	return &ConcatPlan{plan1: plan1, plan2: plan2, schema: schema}, nil
+}
+
+// widerNumericType returns the numeric type holding the values of both numeric types.
+func widerNumericType(type1, type2 types.SchemaType) types.SchemaType {
+	for _, fieldType := range []types.SchemaType{types.Float, types.Long, types.Integer} {
+		if type1 == fieldType || type2 == fieldType {
+			return fieldType
+		}
+	}
+	return types.Short
+}
+
+// Open opens a concatenation scan of the scans of both plans.
+func (cp *ConcatPlan) Open() (scan.Scan, error) {
+	scan1, err := cp.plan1.Open()
+	if err != nil {
+		return nil, err
+	}
+	scan2, err := cp.plan2.Open()
+	if err != nil {
+		return nil, errors.Join(err, scan1.Close())
+	}
+	concatScan, err := query.NewConcatScan(scan1, scan2, cp.schema.FieldInfos(), cp.plan2.Schema().Fields())
+	if err != nil {
+		return nil, errors.Join(err, scan1.Close(), scan2.Close())
+	}
+	return concatScan, nil
+}
+
+// BlocksAccessed estimates the number of block accesses of the concatenation, which reads each plan once.
+func (cp *ConcatPlan) BlocksAccessed() int {
+	return cp.plan1.BlocksAccessed() + cp.plan2.BlocksAccessed()
+}
+
+// RecordsOutput estimates the number of records output by the concatenation, which are those of both plans.
+func (cp *ConcatPlan) RecordsOutput() int {
+	return cp.plan1.RecordsOutput() + cp.plan2.RecordsOutput()
+}
+
+// DistinctValues estimates the number of distinct values of the field, assuming the values of both plans differ.
+func (cp *ConcatPlan) DistinctValues(fieldName string) int {
+	for i, field1 := range cp.plan1.Schema().Fields() {
+		if field1 == fieldName {
+			return cp.plan1.DistinctValues(field1) + cp.plan2.DistinctValues(cp.plan2.Schema().Fields()[i])
+		}
+	}
+	return 0
+}
+
+// Schema returns the schema of the concatenation, whose fields are named after the fields of the first plan.
+func (cp *ConcatPlan) Schema() *record.Schema {
+	return cp.schema
+}
+
+// ToNode returns the description of the concatenation and both its plans.
+func (cp *ConcatPlan) ToNode() *PlanNode {
+	node := newPlanNode("Concat", cp, cp.plan1, cp.plan2)
+	node.Fields = cp.schema.Fields()
+	return node
+}
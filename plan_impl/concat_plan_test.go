@@ -0,0 +1,120 @@
+package plan_impl
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupUnionTest creates two tables of customers and suppliers, some of which are in both tables.
+func setupUnionTest(t *testing.T) (*Planner, *tx.Transaction) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE customers (cname VARCHAR(5), ccity VARCHAR(8), cid INT)",
+		"CREATE TABLE suppliers (sname VARCHAR(12), scity VARCHAR(10), sid FLOAT)",
+		"INSERT INTO customers (cname, ccity, cid) VALUES ('acme', 'paris', 1)",
+		"INSERT INTO customers (cname, ccity, cid) VALUES ('bolt', 'rome', 2)",
+		"INSERT INTO customers (cname, ccity, cid) VALUES ('bolt', 'rome', 2)",
+		"INSERT INTO customers (cname, ccity, cid) VALUES ('cog', null, 3)",
+		"INSERT INTO suppliers (sname, scity, sid) VALUES ('bolt', 'rome', 2)",
+		"INSERT INTO suppliers (sname, scity, sid) VALUES ('cog', null, 3)",
+		"INSERT INTO suppliers (sname, scity, sid) VALUES ('dynamo works', 'copenhagen', 4)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	t.Cleanup(func() { require.NoError(t, txn.Commit()) })
+	return p, txn
+}
+
+// queryRecords plans and runs the query, and returns its records as the sorted values of the specified fields.
+func queryRecords(t *testing.T, p *Planner, sql string, txn *tx.Transaction, fields ...string) [][]any {
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err, sql)
+	s, err := queryPlan.Open()
+	require.NoError(t, err, sql)
+	defer s.Close()
+
+	var records [][]any
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err, sql)
+		if !hasNext {
+			sort.Slice(records, func(i, j int) bool { return records[i][0].(string) < records[j][0].(string) })
+			return records
+		}
+		record := make([]any, len(fields))
+		for i, field := range fields {
+			record[i], err = s.GetVal(field)
+			require.NoError(t, err, sql)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestConcatPlan_UnionAll(t *testing.T) {
+	p, txn := setupUnionTest(t)
+
+	// Every record of both tables is output, under the names of the fields of the first query,
+	// and with the ids of both tables as floats.
+	sql := "SELECT cname, cid FROM customers UNION ALL SELECT sname, sid FROM suppliers"
+	assert.Equal(t, [][]any{
+		{"acme", float64(1)},
+		{"bolt", float64(2)}, {"bolt", float64(2)}, {"bolt", float64(2)},
+		{"cog", float64(3)}, {"cog", float64(3)},
+		{"dynamo works", float64(4)},
+	}, queryRecords(t, p, sql, txn, "cname", "cid"))
+
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err)
+	concatPlan := queryPlan.(*ConcatPlan)
+	assert.Equal(t, []string{"cname", "cid"}, concatPlan.Schema().Fields())
+	assert.Equal(t, types.Float, concatPlan.Schema().Type("cid"))
+	assert.Equal(t, 12, concatPlan.Schema().Length("cname"), "the varchar fields take the longest length")
+	assert.Equal(t, 7, concatPlan.RecordsOutput())
+	explained, err := p.Explain(sql, txn)
+	require.NoError(t, err)
+	assert.Contains(t, explained, "Concat")
+	assert.NotContains(t, explained, "Dedup")
+}
+
+func TestConcatPlan_Union(t *testing.T) {
+	p, txn := setupUnionTest(t)
+
+	// The records of both tables are output once, records with the same null city being duplicates.
+	sql := "SELECT cname, ccity FROM customers UNION SELECT sname, scity FROM suppliers"
+	assert.Equal(t, [][]any{
+		{"acme", "paris"},
+		{"bolt", "rome"},
+		{"cog", nil},
+		{"dynamo works", "copenhagen"},
+	}, queryRecords(t, p, sql, txn, "cname", "ccity"))
+	explained, err := p.Explain(sql, txn)
+	require.NoError(t, err)
+	assert.Contains(t, explained, "Dedup")
+
+	// A UNION removes the duplicates of the records preceding it, and a later UNION ALL keeps its own.
+	sql = "SELECT cname FROM customers UNION SELECT sname FROM suppliers WHERE sid = 2 UNION ALL SELECT sname FROM suppliers WHERE sid > 2"
+	assert.Equal(t, [][]any{{"acme"}, {"bolt"}, {"cog"}, {"cog"}, {"dynamo works"}}, queryRecords(t, p, sql, txn, "cname"))
+}
+
+func TestConcatPlan_Errors(t *testing.T) {
+	p, txn := setupUnionTest(t)
+
+	_, err := p.CreateQueryPlan("SELECT cname FROM customers UNION SELECT sname, sid FROM suppliers", txn)
+	assert.ErrorContains(t, err, "as many fields")
+	_, err = p.CreateQueryPlan("SELECT * FROM customers UNION SELECT sname, sid FROM suppliers", txn)
+	assert.ErrorContains(t, err, "as many fields")
+	_, err = p.CreateQueryPlan("SELECT cname, cid FROM customers UNION SELECT sid, sname FROM suppliers", txn)
+	assert.ErrorIs(t, err, query.ErrTypeMismatch)
+}
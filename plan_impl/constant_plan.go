@@ -0,0 +1,60 @@
+package plan_impl
+
+import (
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+)
+
+var _ plan.Plan = &ConstantPlan{}
+var _ NodePlan = &ConstantPlan{}
+
+// ConstantPlan is the plan outputting rows of constants instead of reading tables (see query.ConstantScan),
+// such as the rows of a VALUES list or the single row of a select list of constants.
+type ConstantPlan struct {
+	schema *record.Schema
+	rows   [][]any
+}
+
+// NewConstantPlan creates a plan outputting the specified rows, whose values are read by the specified fields
+// in order. It returns an error if the values of a field do not all have the same type.
+func NewConstantPlan(fieldNames []string, rows [][]any) (*ConstantPlan, error) {
+	schema, err := query.ConstantSchema(fieldNames, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &ConstantPlan{schema: schema, rows: rows}, nil
+}
+
+// Open creates a scan over the rows.
+func (p *ConstantPlan) Open() (scan.Scan, error) {
+	return query.NewConstantScan(p.schema, p.rows), nil
+}
+
+// BlocksAccessed returns 0, since the rows are held in memory.
+func (p *ConstantPlan) BlocksAccessed() int {
+	return 0
+}
+
+// RecordsOutput returns the number of rows.
+func (p *ConstantPlan) RecordsOutput() int {
+	return len(p.rows)
+}
+
+// DistinctValues returns the number of rows, which is the most distinct values a field can have.
+func (p *ConstantPlan) DistinctValues(fieldName string) int {
+	return p.RecordsOutput()
+}
+
+// Schema returns the schema of the rows.
+func (p *ConstantPlan) Schema() *record.Schema {
+	return p.schema
+}
+
+// ToNode returns the description of the constant plan.
+func (p *ConstantPlan) ToNode() *PlanNode {
+	node := newPlanNode("Values", p)
+	node.Fields = p.schema.Fields()
+	return node
+}
@@ -0,0 +1,111 @@
+package plan_impl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// DumpDatabase writes the script recreating the tables, indexes and views of the database to the writer,
+// as read by the transaction, and returns the number of records it dumped. The statements of the script
+// are separated by semicolons (see parse.ScriptReader), in an order in which they can be executed:
+//   - For each table, in the order they were created, a CREATE TABLE statement, then an INSERT statement for
+//     each of its records, then the CREATE INDEX statements of its indexes, which populate the indexes with
+//     the records inserted before them.
+//   - Then a CREATE VIEW statement for each view, in the order they were created, since views read the tables
+//     and the views created before them.
+//
+// The records are read from the table one at a time and written through a buffered writer, so that tables are
+// never held in memory as a whole. The filters of the tables do not apply: every record is dumped.
+func DumpDatabase(w io.Writer, metadataManager *metadata.Manager, transaction *tx.Transaction) (int, error) {
+	writer := bufio.NewWriter(w)
+	tableNames, err := metadataManager.GetTableNames(transaction)
+	if err != nil {
+		return 0, err
+	}
+
+	records := 0
+	for _, tableName := range tableNames {
+		statements, err := metadataManager.GenerateDDL(tableName, transaction)
+		if err != nil {
+			return records, err
+		}
+		if err := writeStatement(writer, statements[0]); err != nil {
+			return records, err
+		}
+		count, err := dumpRecords(writer, tableName, metadataManager, transaction)
+		records += count
+		if err != nil {
+			return records, err
+		}
+		for _, createIndex := range statements[1:] {
+			if err := writeStatement(writer, createIndex); err != nil {
+				return records, err
+			}
+		}
+	}
+
+	views, err := metadataManager.GetViews(transaction)
+	if err != nil {
+		return records, err
+	}
+	for _, view := range views {
+		if err := writeStatement(writer, fmt.Sprintf("create view %s as %s", view.Name, view.Definition)); err != nil {
+			return records, err
+		}
+	}
+	return records, writer.Flush()
+}
+
+// dumpRecords writes an INSERT statement for each record of the table, and returns the number of records.
+func dumpRecords(writer *bufio.Writer, tableName string, metadataManager *metadata.Manager, transaction *tx.Transaction) (int, error) {
+	layout, err := metadataManager.GetLayout(tableName, transaction)
+	if err != nil {
+		return 0, err
+	}
+	fields := layout.Schema().Fields()
+	prefix := fmt.Sprintf("insert into %s (%s) values (", tableName, strings.Join(fields, ", "))
+
+	tableScan, err := table.NewTableScan(transaction, tableName, layout)
+	if err != nil {
+		return 0, err
+	}
+	defer tableScan.Close()
+
+	records := 0
+	values := make([]string, len(fields))
+	for {
+		next, err := tableScan.Next()
+		if err != nil {
+			return records, err
+		}
+		if !next {
+			return records, nil
+		}
+		for i, fieldName := range fields {
+			val, err := tableScan.GetVal(fieldName)
+			if err != nil {
+				return records, err
+			}
+			if values[i], err = parse.FormatConstant(val); err != nil {
+				return records, fmt.Errorf("table %s, field %s: %w", tableName, fieldName, err)
+			}
+		}
+		if err := writeStatement(writer, prefix+strings.Join(values, ", ")+")"); err != nil {
+			return records, err
+		}
+		records++
+	}
+}
+
+// writeStatement writes a statement of a script, followed by its semicolon, on a line of its own.
+func writeStatement(writer *bufio.Writer, statement string) error {
+	_, err := writer.WriteString(statement + ";\n")
+	return err
+}
@@ -0,0 +1,63 @@
+package plan_impl
+
+import (
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &DeadlinePlan{}
+var _ NodePlan = &DeadlinePlan{}
+
+// DeadlinePlan is the plan of a query that must be done by the deadline of its transaction.
+// Its scan checks the deadline each time it moves, so that iterating over the results of the
+// query is covered by the deadline as well as planning and executing it.
+type DeadlinePlan struct {
+	inputPlan   plan.Plan
+	transaction *tx.Transaction
+}
+
+// NewDeadlinePlan creates a plan reading the records of the specified plan,
+// up to the deadline of the specified transaction.
+func NewDeadlinePlan(inputPlan plan.Plan, transaction *tx.Transaction) *DeadlinePlan {
+	return &DeadlinePlan{inputPlan: inputPlan, transaction: transaction}
+}
+
+// Open opens the input plan, and wraps its scan in a deadline scan.
+func (dp *DeadlinePlan) Open() (scan.Scan, error) {
+	if err := dp.transaction.CheckDeadline(); err != nil {
+		return nil, err
+	}
+	inputScan, err := dp.inputPlan.Open()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewDeadlineScan(inputScan, dp.transaction), nil
+}
+
+// BlocksAccessed returns the estimated number of block accesses of the input plan.
+func (dp *DeadlinePlan) BlocksAccessed() int {
+	return dp.inputPlan.BlocksAccessed()
+}
+
+// RecordsOutput returns the estimated number of records output by the input plan.
+func (dp *DeadlinePlan) RecordsOutput() int {
+	return dp.inputPlan.RecordsOutput()
+}
+
+// DistinctValues returns the estimated number of distinct values of the field in the input plan.
+func (dp *DeadlinePlan) DistinctValues(fieldName string) int {
+	return dp.inputPlan.DistinctValues(fieldName)
+}
+
+// Schema returns the schema of the input plan.
+func (dp *DeadlinePlan) Schema() *record.Schema {
+	return dp.inputPlan.Schema()
+}
+
+// ToNode describes the input plan: the deadline does not change how the query is executed.
+func (dp *DeadlinePlan) ToNode() *PlanNode {
+	return planNode(dp.inputPlan)
+}
@@ -0,0 +1,66 @@
+package plan_impl
+
+import (
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &DedupPlan{}
+var _ NodePlan = &DedupPlan{}
+
+// DedupPlan is the plan of UNION, which outputs the distinct records of its input. The input is sorted on all of
+// its fields, so that duplicate records are adjacent (see query.DedupScan).
+type DedupPlan struct {
+	sortPlan *SortPlan
+}
+
+// NewDedupPlan creates a plan outputting the distinct records of the specified plan.
+func NewDedupPlan(transaction *tx.Transaction, inputPlan plan.Plan) *DedupPlan {
+	return &DedupPlan{sortPlan: NewSortPlan(transaction, inputPlan, inputPlan.Schema().Fields())}
+}
+
+// Open opens the sorted input plan, and wraps its scan in a dedup scan.
+func (dp *DedupPlan) Open() (scan.Scan, error) {
+	sortScan, err := dp.sortPlan.Open()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewDedupScan(sortScan), nil
+}
+
+// BlocksAccessed returns the estimated number of block accesses of sorting the input.
+func (dp *DedupPlan) BlocksAccessed() int {
+	return dp.sortPlan.BlocksAccessed()
+}
+
+// RecordsOutput estimates the number of distinct records output, which is at most the number of records
+// of the input, or the number of combinations of the distinct values of its fields.
+func (dp *DedupPlan) RecordsOutput() int {
+	records, combinations := dp.sortPlan.RecordsOutput(), 1
+	for _, fieldName := range dp.Schema().Fields() {
+		combinations *= max(dp.sortPlan.DistinctValues(fieldName), 1)
+		if combinations >= records {
+			return records
+		}
+	}
+	return combinations
+}
+
+// DistinctValues returns the estimated number of distinct values of the field in the input,
+// which the records output cannot exceed.
+func (dp *DedupPlan) DistinctValues(fieldName string) int {
+	return min(dp.sortPlan.DistinctValues(fieldName), dp.RecordsOutput())
+}
+
+// Schema returns the schema of the input plan.
+func (dp *DedupPlan) Schema() *record.Schema {
+	return dp.sortPlan.Schema()
+}
+
+// ToNode returns the description of the dedup plan and its sorted input.
+func (dp *DedupPlan) ToNode() *PlanNode {
+	return newPlanNode("Dedup", dp, dp.sortPlan)
+}
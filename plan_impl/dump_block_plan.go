@@ -0,0 +1,73 @@
+package plan_impl
+
+import (
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &DumpBlockPlan{}
+var _ NodePlan = &DumpBlockPlan{}
+
+// DumpBlockPlan is the plan of a DUMP BLOCK statement,
+// which returns the physical contents of a block of a table (see table.DumpScan).
+type DumpBlockPlan struct {
+	transaction *tx.Transaction
+	tableName   string
+	blockNumber int
+	layout      *record.Layout
+	schema      *record.Schema
+}
+
+// NewDumpBlockPlan creates a plan that dumps the specified block of the specified table.
+func NewDumpBlockPlan(transaction *tx.Transaction, tableName string, blockNumber int, metadataManager *metadata.Manager) (*DumpBlockPlan, error) {
+	layout, err := metadataManager.GetLayout(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	return &DumpBlockPlan{
+		transaction: transaction,
+		tableName:   tableName,
+		blockNumber: blockNumber,
+		layout:      layout,
+		schema:      table.DumpSchema(layout),
+	}, nil
+}
+
+// Open creates a dump scan over the block.
+func (p *DumpBlockPlan) Open() (scan.Scan, error) {
+	return table.NewDumpScan(p.transaction, p.tableName, p.layout, p.blockNumber)
+}
+
+// BlocksAccessed returns 1, since only the dumped block is read.
+func (p *DumpBlockPlan) BlocksAccessed() int {
+	return 1
+}
+
+// RecordsOutput returns the number of slots in the block.
+func (p *DumpBlockPlan) RecordsOutput() int {
+	return p.transaction.BlockSize() / p.layout.SlotSize()
+}
+
+// DistinctValues returns the number of slots in the block, since each record describes a different slot.
+func (p *DumpBlockPlan) DistinctValues(fieldName string) int {
+	return p.RecordsOutput()
+}
+
+// Schema returns the schema of the dump.
+func (p *DumpBlockPlan) Schema() *record.Schema {
+	return p.schema
+}
+
+// ToNode returns the description of the dump block plan.
+func (p *DumpBlockPlan) ToNode() *PlanNode {
+	node := newPlanNode("DumpBlock", p)
+	node.Table = p.tableName
+	node.Detail = fmt.Sprintf("block %d", p.blockNumber)
+	return node
+}
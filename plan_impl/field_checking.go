@@ -0,0 +1,46 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/record"
+)
+
+// ErrUnknownField is wrapped by the error returned when a query reads a field
+// that none of the tables it reads has, such as a misspelled field of its select list.
+var ErrUnknownField = errors.New("unknown field")
+
+// checkQueryFields returns an error wrapping ErrUnknownField if the select list, one of its arithmetic expressions,
+// or the GROUP BY clause of the query reads a field that is not in the schema of the join of the tables it reads,
+// so that the error is reported when the query is planned rather than once its scan reads the field.
+func checkQueryFields(queryData *parse.QueryData, schema *record.Schema) error {
+	for _, field := range queryData.Fields() {
+		fields := []string{field}
+		if expression, ok := queryData.Computed()[field]; ok {
+			fields = expression.Fields()
+		}
+		if err := checkFields("select list", fields, schema, queryData.Tables()); err != nil {
+			return err
+		}
+	}
+	return checkFields("GROUP BY", queryData.GroupBy(), schema, queryData.Tables())
+}
+
+// checkFields returns an error wrapping ErrUnknownField naming the first of the fields read by the specified
+// clause of a query that is not in the schema, and the tables the query reads, which could have had the field.
+func checkFields(clause string, fields []string, schema *record.Schema, tables []string) error {
+	for _, field := range fields {
+		if schema.HasField(field) {
+			continue
+		}
+		noun := "table"
+		if len(tables) > 1 {
+			noun = "tables"
+		}
+		return fmt.Errorf("%w: %s field %s is not a field of %s %s", ErrUnknownField, clause, field, noun, strings.Join(tables, ", "))
+	}
+	return nil
+}
@@ -1,49 +1,115 @@
 package plan_impl
 
 import (
+	"errors"
+	"fmt"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/query/functions"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+	"slices"
 )
 
 var _ plan.Plan = &GroupByPlan{}
+var _ NodePlan = &GroupByPlan{}
 
+// GroupByPlan is a plan for the GROUP BY operation that groups its input by sorting it on the group fields,
+// so that the records of each group are contiguous (see query.GroupByScan), unless the input is already sorted.
+// The groups are output in the order of the group fields.
 type GroupByPlan struct {
-	inputPlan            plan.Plan
+	inputPlan plan.Plan
+	// sortPlan sorts the input of the plan on the group fields, or is nil if the input is already sorted.
+	sortPlan             *SortPlan
 	groupFields          []string
 	aggregationFunctions []functions.AggregationFunction
 	schema               *record.Schema
 }
 
-// NewGroupByPlan creates a grorupbyy plan for the underlying
+// NewGroupByPlan creates a groupby plan for the underlying
 // query. The grouping is determined by the specified collection
 // of group fields, and the aggregation is computed by the specified
-// aggregation functions.
-func NewGroupByPlan(transaction *tx.Transaction, inputPlan plan.Plan, groupFields []string, aggregationFunctions []functions.AggregationFunction) *GroupByPlan {
+// aggregation functions. The input is sorted on the group fields
+// unless it is known to be sorted already (see sortedOn).
+// It returns an error if an aggregation function cannot aggregate its field (see groupBySchema).
+func NewGroupByPlan(transaction *tx.Transaction, inputPlan plan.Plan, groupFields []string, aggregationFunctions []functions.AggregationFunction) (*GroupByPlan, error) {
+	schema, err := groupBySchema(inputPlan.Schema(), groupFields, aggregationFunctions)
+	if err != nil {
+		return nil, err
+	}
 	gbp := &GroupByPlan{
-		inputPlan:            NewSortPlan(transaction, inputPlan, groupFields),
+		inputPlan:            inputPlan,
 		groupFields:          groupFields,
 		aggregationFunctions: aggregationFunctions,
-		schema:               record.NewSchema(),
+		schema:               schema,
 	}
+	if !sortedOn(inputPlan, groupFields) {
+		gbp.sortPlan = NewSortPlan(transaction, inputPlan, groupFields)
+		gbp.inputPlan = gbp.sortPlan
+	}
+
+	return gbp, nil
+}
+
+// sortedOn returns true if the records output by the specified plan are known to be sorted so that
+// the records having the same values for the specified fields are contiguous: the plan is a sort
+// whose first sort fields are the specified fields, in any order and either direction. Any input is sorted on no field.
+func sortedOn(inputPlan plan.Plan, fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	sortPlan, ok := inputPlan.(*SortPlan)
+	if !ok || len(sortPlan.sortFields) < len(fields) {
+		return false
+	}
+	for _, field := range sortPlan.sortFields[:len(fields)] {
+		if !slices.Contains(fields, field.Name) {
+			return false
+		}
+	}
+	return true
+}
 
+// groupBySchema returns the schema of the output of a grouping,
+// which consists of the grouping fields and the aggregation fields.
+// It returns an error wrapping query.ErrTypeMismatch if a sum or an average aggregates a field that is not a number.
+func groupBySchema(inputSchema *record.Schema, groupFields []string, aggregationFunctions []functions.AggregationFunction) (*record.Schema, error) {
+	schema := record.NewSchema()
 	for _, field := range groupFields {
-		gbp.schema.Add(field, gbp.inputPlan.Schema())
+		schema.Add(field, inputSchema)
 	}
 
+	inputFields := inputSchema.FieldInfos()
 	for _, f := range aggregationFunctions {
-		gbp.schema.AddIntField(f.FieldName())
+		if err := checkAggregatedType(f, inputSchema); err != nil {
+			return nil, err
+		}
+		field := f.OutputField(inputFields)
+		schema.AddField(field.Name, field.Type, field.Length)
 	}
+	return schema, nil
+}
 
-	return gbp
+// checkAggregatedType returns an error wrapping query.ErrTypeMismatch if the function is a sum or an average
+// of a field of the schema that is not a number. A field that is not in the schema is not checked.
+func checkAggregatedType(f functions.AggregationFunction, schema *record.Schema) error {
+	switch f.(type) {
+	case *functions.SumFunction, *functions.AvgFunction:
+	default:
+		return nil
+	}
+	fieldName := f.AggregatedField()
+	if !schema.HasField(fieldName) || types.IsNumericType(schema.Type(fieldName)) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s aggregates field %s of type %s, which is not a number",
+		query.ErrTypeMismatch, f.FieldName(), fieldName, schema.Type(fieldName))
 }
 
-// Open opens a sort plan for the specified plan.
-// The sort plan ensures that the underlying records
-// will be appropriately grouped.
+// Open opens the sorted input, which ensures that the
+// underlying records will be appropriately grouped.
 func (p *GroupByPlan) Open() (scan.Scan, error) {
 	sortScan, err := p.inputPlan.Open()
 	if err != nil {
@@ -52,7 +118,7 @@ func (p *GroupByPlan) Open() (scan.Scan, error) {
 
 	groupByScan, err := query.NewGroupByScan(sortScan, p.groupFields, p.aggregationFunctions)
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(err, sortScan.Close())
 	}
 
 	return groupByScan, nil
@@ -61,19 +127,32 @@ func (p *GroupByPlan) Open() (scan.Scan, error) {
 // BlocksAccessed returns the estimated number of block accesses
 // required to compute the aggregation,
 // which is one pass through the sorted table.
-// It does not include the one-time cost of materializing and sorting the records.
+// Unless the input is already sorted, it includes the cost of sorting it (see SortPlan#sortingCost).
 func (p *GroupByPlan) BlocksAccessed() int {
-	return p.inputPlan.BlocksAccessed()
+	if p.sortPlan == nil {
+		return p.inputPlan.BlocksAccessed()
+	}
+	return p.sortPlan.sortingCost() + p.sortPlan.BlocksAccessed()
 }
 
-// RecordsOutput returns the number of groups. Assuming equal distribution,
-// this is the product of the distinct values of each grouping field.
+// RecordsOutput returns the estimated number of groups (see estimateGroups).
 func (p *GroupByPlan) RecordsOutput() int {
+	return estimateGroups(p.inputPlan, p.groupFields)
+}
+
+// estimateGroups returns the estimated number of groups of the records output by the input plan.
+// Assuming equal distribution, this is the product of the distinct values of each grouping field,
+// but there cannot be more groups than records: an empty input has no group at all.
+// Without group fields, all the records, even none, form a single group.
+func estimateGroups(inputPlan plan.Plan, groupFields []string) int {
+	if len(groupFields) == 0 {
+		return 1
+	}
 	numGroups := 1
-	for _, field := range p.groupFields {
-		numGroups *= p.inputPlan.DistinctValues(field)
+	for _, field := range groupFields {
+		numGroups *= inputPlan.DistinctValues(field)
 	}
-	return numGroups
+	return min(numGroups, inputPlan.RecordsOutput())
 }
 
 // DistinctValues are the number of distinct values for the specified field.
@@ -92,3 +171,11 @@ func (p *GroupByPlan) DistinctValues(fieldName string) int {
 func (p *GroupByPlan) Schema() *record.Schema {
 	return p.schema
 }
+
+// ToNode returns the description of the group by plan and its input.
+func (p *GroupByPlan) ToNode() *PlanNode {
+	node := newPlanNode("GroupBy", p, p.inputPlan)
+	node.Fields = p.groupFields
+	node.Aggregates = aggregateFields(p.aggregationFunctions)
+	return node
+}
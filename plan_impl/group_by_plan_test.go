@@ -8,6 +8,7 @@ import (
 
 	"github.com/JyotinderSingh/dropdb/query/functions"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
 )
 
 // ----------------------------------------------------------------------
@@ -52,7 +53,8 @@ func TestGroupByPlan_NoGroupFields(t *testing.T) {
 
 	// 4) Create a GroupByPlan with NO group fields, aggregator: maxOfsalary
 	maxFn := functions.NewMaxFunction("salary")
-	gbPlan := NewGroupByPlan(txn, tp, []string{}, []functions.AggregationFunction{maxFn})
+	gbPlan, err := NewGroupByPlan(txn, tp, []string{}, []functions.AggregationFunction{maxFn})
+	require.NoError(t, err)
 
 	// 5) Check the schema
 	//    - We expect one field: maxOfsalary
@@ -130,7 +132,8 @@ func TestGroupByPlan_SingleGroupField(t *testing.T) {
 
 	// 4) GroupByPlan: group by "dept", aggregator = maxOfsalary
 	maxFn := functions.NewMaxFunction("salary")
-	gbPlan := NewGroupByPlan(txn, tp, []string{"dept"}, []functions.AggregationFunction{maxFn})
+	gbPlan, err := NewGroupByPlan(txn, tp, []string{"dept"}, []functions.AggregationFunction{maxFn})
+	require.NoError(t, err)
 
 	// 5) Check Schema
 	//    - We expect two fields: "dept" and "maxOfsalary"
@@ -221,7 +224,8 @@ func TestGroupByPlan_MultipleAggregators(t *testing.T) {
 	// 4) Create a GroupByPlan with groupFields=["dept"], aggregators: MaxFunction + CountFunction
 	maxFn := functions.NewMaxFunction("salary")
 	countFn := functions.NewCountFunction("salary") // or "countOf(salary)"
-	gbPlan := NewGroupByPlan(txn, tp, []string{"dept"}, []functions.AggregationFunction{maxFn, countFn})
+	gbPlan, err := NewGroupByPlan(txn, tp, []string{"dept"}, []functions.AggregationFunction{maxFn, countFn})
+	require.NoError(t, err)
 
 	// 5) Check Schema
 	//    - We expect three fields: "dept", "maxOfsalary", "countOfsalary"
@@ -285,3 +289,117 @@ func TestGroupByPlan_MultipleAggregators(t *testing.T) {
 	assert.Equal(t, 1800, mkt.maxSalary)
 	assert.EqualValues(t, 2, mkt.count)
 }
+
+// ----------------------------------------------------------------------
+// Test #4: An input without records has no group
+// ----------------------------------------------------------------------
+// A predicate matching no record leaves nothing to group, so the query outputs no row at all,
+// whether the groups are formed by sorting or hashing, and whatever its HAVING clause.
+// Sorting an empty input used to open no scan at all.
+func TestGroupByPlan_EmptyInput(t *testing.T) {
+	p, mdm, fm, lm, bm, lt := setupPlannerTest(t, 800, 16)
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table emp (dept varchar(12), salary int)",
+		"create table nobody (dept varchar(12), salary int)",
+		"insert into emp (dept, salary) values ('Sales', 1000), ('Sales', 2000), ('Marketing', 1500)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	fields := []string{"dept", "maxOfsalary"}
+	for _, sql := range []string{
+		"select dept, max(salary) from emp where 1 = 0 group by dept",
+		"select dept, max(salary) from emp where salary > 100000 group by dept",
+		"select dept, max(salary) from emp where salary > 100000 group by dept having max(salary) > 0",
+		"select dept, max(salary) from emp where salary > 100000 group by dept order by dept",
+		"select dept, max(salary) from emp where salary > 100000 group by dept having max(salary) > 0 order by dept",
+		"select dept, max(salary) from nobody group by dept",
+		"select dept, max(salary) from nobody group by dept order by dept",
+	} {
+		assert.Empty(t, runPlannerQuery(t, p, sql, fm, lm, bm, lt, fields), sql)
+	}
+	assert.Len(t, runPlannerQuery(t, p, "select dept, max(salary) from emp group by dept order by dept", fm, lm, bm, lt, fields), 2)
+
+	// No group is estimated for an empty table either.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	tp, err := NewTablePlan(txn, "nobody", mdm)
+	require.NoError(t, err)
+	require.Zero(t, tp.RecordsOutput())
+	aggregates := []functions.AggregationFunction{functions.NewMaxFunction("salary")}
+	gbPlan, err := NewGroupByPlan(txn, tp, []string{"dept"}, aggregates)
+	require.NoError(t, err)
+	assert.Zero(t, gbPlan.RecordsOutput())
+	hashPlan, err := NewHashAggregationPlan(txn, tp, []string{"dept"}, aggregates)
+	require.NoError(t, err)
+	assert.Zero(t, hashPlan.RecordsOutput())
+}
+
+// ----------------------------------------------------------------------
+// Test #5: The records of a group need not be contiguous in the table
+// ----------------------------------------------------------------------
+// The input is sorted on the group fields, so interleaved records of the same
+// department still form a single group, unless the input is already sorted.
+func TestGroupByPlan_InterleavedInput(t *testing.T) {
+	txn, cleanup := setupTestEnvironment(t, 800, 8)
+	defer cleanup()
+
+	mdm := createTableMetadataWithSchema(t, txn, "interleaved", map[string]interface{}{
+		"dept":   "string",
+		"salary": 0,
+	})
+	tp, err := NewTablePlan(txn, "interleaved", mdm)
+	require.NoError(t, err)
+	s, err := tp.Open()
+	require.NoError(t, err)
+	us, ok := s.(scan.UpdateScan)
+	require.True(t, ok)
+	depts := []string{"Sales", "Marketing", "Engineering"}
+	var sampleData []map[string]interface{}
+	for i := 0; i < 60; i++ {
+		sampleData = append(sampleData, map[string]interface{}{"dept": depts[i%len(depts)], "salary": i})
+	}
+	insertRecords(t, us, sampleData)
+	require.NoError(t, s.Close())
+
+	tp, err = NewTablePlan(txn, "interleaved", mdm)
+	require.NoError(t, err)
+	countFn := functions.NewCountFunction("salary")
+	gbPlan, err := NewGroupByPlan(txn, tp, []string{"dept"}, []functions.AggregationFunction{countFn})
+	require.NoError(t, err)
+	require.NotNil(t, gbPlan.sortPlan)
+	// The sort reads the table, and writes the sorted table that is then read.
+	assert.Equal(t, tp.BlocksAccessed()+2*gbPlan.sortPlan.BlocksAccessed(), gbPlan.BlocksAccessed())
+
+	gbScan, err := gbPlan.Open()
+	require.NoError(t, err)
+	var groups []string
+	for {
+		hasNext, err := gbScan.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		dept, err := gbScan.GetString("dept")
+		require.NoError(t, err)
+		count, err := gbScan.GetLong(countFn.FieldName())
+		require.NoError(t, err)
+		assert.Equal(t, int64(20), count, dept)
+		groups = append(groups, dept)
+	}
+	require.NoError(t, gbScan.Close())
+	assert.Equal(t, []string{"Engineering", "Marketing", "Sales"}, groups)
+
+	// An input sorted on the group fields, in any order, is not sorted again.
+	sortPlan := NewSortPlan(txn, tp, []string{"salary", "dept"})
+	gbPlan, err = NewGroupByPlan(txn, sortPlan, []string{"dept", "salary"}, []functions.AggregationFunction{countFn})
+	require.NoError(t, err)
+	assert.Nil(t, gbPlan.sortPlan)
+	assert.Equal(t, sortPlan.BlocksAccessed(), gbPlan.BlocksAccessed())
+	gbPlan, err = NewGroupByPlan(txn, sortPlan, []string{"dept"}, []functions.AggregationFunction{countFn})
+	require.NoError(t, err)
+	assert.NotNil(t, gbPlan.sortPlan)
+}
@@ -0,0 +1,103 @@
+package plan_impl
+
+import (
+	"errors"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &HashAggregationPlan{}
+var _ NodePlan = &HashAggregationPlan{}
+
+// HashAggregationPlan is a plan for the GROUP BY operation that groups its input by hashing
+// the group fields instead of sorting the input. The groups are output in no particular order.
+type HashAggregationPlan struct {
+	transaction          *tx.Transaction
+	inputPlan            plan.Plan
+	groupFields          []string
+	aggregationFunctions []functions.AggregationFunction
+	schema               *record.Schema
+}
+
+// NewHashAggregationPlan creates a hash aggregation plan for the underlying query.
+// The grouping is determined by the specified collection of group fields, and the
+// aggregation is computed by the specified aggregation functions.
+// It returns an error if an aggregation function cannot aggregate its field (see groupBySchema).
+func NewHashAggregationPlan(transaction *tx.Transaction, inputPlan plan.Plan, groupFields []string, aggregationFunctions []functions.AggregationFunction) (*HashAggregationPlan, error) {
+	schema, err := groupBySchema(inputPlan.Schema(), groupFields, aggregationFunctions)
+	if err != nil {
+		return nil, err
+	}
+	return &HashAggregationPlan{
+		transaction:          transaction,
+		inputPlan:            inputPlan,
+		groupFields:          groupFields,
+		aggregationFunctions: aggregationFunctions,
+		schema:               schema,
+	}, nil
+}
+
+// Open opens a hash aggregation scan over the underlying query.
+func (p *HashAggregationPlan) Open() (scan.Scan, error) {
+	inputScan, err := p.inputPlan.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	hashAggregationScan, err := query.NewHashAggregationScan(p.transaction, inputScan, p.inputPlan.Schema(), p.groupFields, p.aggregationFunctions, p.maxGroups())
+	if err != nil {
+		return nil, errors.Join(err, inputScan.Close())
+	}
+
+	return hashAggregationScan, nil
+}
+
+// maxGroups returns the number of groups that are aggregated in memory before records are spilled,
+// which is the number of output records that would fit in the currently available buffers.
+func (p *HashAggregationPlan) maxGroups() int {
+	groupsPerBlock := p.transaction.BlockSize() / record.NewLayout(p.schema).SlotSize()
+	return max(p.transaction.AvailableBuffers(), 1) * max(groupsPerBlock, 1)
+}
+
+// BlocksAccessed returns the estimated number of block accesses
+// required to compute the aggregation, which is one pass through the input.
+// It does not include the cost of spilling the records of the groups that do not fit in memory.
+func (p *HashAggregationPlan) BlocksAccessed() int {
+	return p.inputPlan.BlocksAccessed()
+}
+
+// RecordsOutput returns the estimated number of groups (see estimateGroups).
+func (p *HashAggregationPlan) RecordsOutput() int {
+	return estimateGroups(p.inputPlan, p.groupFields)
+}
+
+// DistinctValues are the number of distinct values for the specified field.
+// If the field is a grouping field, then the number of distinct values is the
+// same as in the underlying query.
+// If the field is an aggregation field, then we assume that all the values are distinct.
+func (p *HashAggregationPlan) DistinctValues(fieldName string) int {
+	for _, field := range p.groupFields {
+		if field == fieldName {
+			return p.inputPlan.DistinctValues(fieldName)
+		}
+	}
+	return p.RecordsOutput()
+}
+
+// Schema returns the schema of the output table.
+// The schema consists of the grouping fields and the aggregation fields.
+func (p *HashAggregationPlan) Schema() *record.Schema {
+	return p.schema
+}
+
+// ToNode returns the description of the hash aggregation plan and its input.
+func (p *HashAggregationPlan) ToNode() *PlanNode {
+	node := newPlanNode("HashAggregation", p, p.inputPlan)
+	node.Fields = p.groupFields
+	node.Aggregates = aggregateFields(p.aggregationFunctions)
+	return node
+}
@@ -0,0 +1,118 @@
+package plan_impl
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/scan"
+)
+
+// groupRows returns the records of the plan formatted as strings, in sorted order.
+func groupRows(t *testing.T, p plan.Plan, fields []string) []string {
+	s, err := p.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	var rows []string
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		row := ""
+		for _, field := range fields {
+			val, err := s.GetVal(field)
+			require.NoError(t, err)
+			row += fmt.Sprintf("%v|", val)
+		}
+		rows = append(rows, row)
+	}
+	sort.Strings(rows)
+	return rows
+}
+
+func TestHashAggregationPlan_MatchesGroupByPlan(t *testing.T) {
+	txn, cleanup := setupTestEnvironment(t, 800, 8)
+	defer cleanup()
+
+	mdm := createTableMetadataWithSchema(t, txn, "employees", map[string]interface{}{
+		"dept":   "string",
+		"region": "string",
+		"salary": 0,
+	})
+
+	tp, err := NewTablePlan(txn, "employees", mdm)
+	require.NoError(t, err)
+	s, err := tp.Open()
+	require.NoError(t, err)
+	us, ok := s.(scan.UpdateScan)
+	require.True(t, ok)
+
+	// The records of a group are scattered across the table.
+	depts := []string{"Sales", "Marketing", "Engineering"}
+	regions := []string{"North", "South", "East", "West"}
+	var data []map[string]interface{}
+	for i := 0; i < 100; i++ {
+		data = append(data, map[string]interface{}{
+			"dept":   depts[(i*5)%len(depts)],
+			"region": regions[(i*3)%len(regions)],
+			"salary": (i * 37) % 1000,
+		})
+	}
+	insertRecords(t, us, data)
+	s.Close()
+
+	tp, err = NewTablePlan(txn, "employees", mdm)
+	require.NoError(t, err)
+
+	newFunctions := func() []functions.AggregationFunction {
+		return []functions.AggregationFunction{
+			functions.NewCountFunction("salary"),
+			functions.NewSumFunction("salary"),
+			functions.NewMinFunction("salary"),
+			functions.NewMaxFunction("salary"),
+		}
+	}
+	groupFields := []string{"dept", "region"}
+	fields := []string{"dept", "region", "countOfsalary", "sumOfsalary", "minOfsalary", "maxOfsalary"}
+
+	hashPlan, err := NewHashAggregationPlan(txn, tp, groupFields, newFunctions())
+	require.NoError(t, err)
+	sortPlan, err := NewGroupByPlan(txn, tp, groupFields, newFunctions())
+	require.NoError(t, err)
+
+	for _, field := range fields {
+		assert.True(t, hashPlan.Schema().HasField(field))
+	}
+	assert.Equal(t, sortPlan.RecordsOutput(), hashPlan.RecordsOutput())
+
+	expected := groupRows(t, sortPlan, fields)
+	assert.Len(t, expected, 12)
+	assert.Equal(t, expected, groupRows(t, hashPlan, fields))
+}
+
+func TestBasicQueryPlanner_OrdersByGroupField(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected bool
+	}{
+		{"select dept from employees group by dept", false},
+		{"select dept from employees group by dept order by dept", true},
+		{"select dept, region from employees group by dept, region order by region", true},
+		{"select dept from employees group by dept order by salary", false},
+	}
+
+	for _, tt := range tests {
+		queryData, err := parse.NewParser(tt.sql).Query()
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, ordersByGroupField(queryData), tt.sql)
+	}
+}
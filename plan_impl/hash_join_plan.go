@@ -0,0 +1,131 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &HashJoinPlan{}
+var _ NodePlan = &HashJoinPlan{}
+
+// HashJoinPlan is a plan for the hash join operator, which joins the records of two plans whose join fields
+// are equal by reading the records of the second plan, the build side, into a hash table, and probing it with
+// each record of the first plan (see query.HashJoinScan).
+type HashJoinPlan struct {
+	transaction *tx.Transaction
+	plan1       plan.Plan
+	plan2       plan.Plan
+	fieldName1  string
+	fieldName2  string
+	// memoryBlocks is the number of blocks the build records kept in memory may fill,
+	// or 0 for the buffers available when the plan is opened.
+	memoryBlocks int
+	schema       *record.Schema
+}
+
+// NewHashJoinPlan creates a hash join plan of the specified plans, joining the records whose field of the
+// first plan equals the field of the second plan. The second plan, whose records are kept in memory, should be
+// the smaller one.
+func NewHashJoinPlan(transaction *tx.Transaction, plan1, plan2 plan.Plan, fieldName1, fieldName2 string) *HashJoinPlan {
+	hjp := &HashJoinPlan{
+		transaction: transaction,
+		plan1:       plan1,
+		plan2:       plan2,
+		fieldName1:  fieldName1,
+		fieldName2:  fieldName2,
+		schema:      record.NewSchema(),
+	}
+
+	hjp.schema.AddAll(plan1.Schema())
+	hjp.schema.AddAll(plan2.Schema())
+
+	return hjp
+}
+
+// SetMemoryBudget sets the number of blocks that the records of the second plan kept in memory may fill.
+// The records of both plans are spilled into partitions if there are more of them.
+// Until it is set, the budget is the number of buffers available when the plan is opened.
+func (hjp *HashJoinPlan) SetMemoryBudget(blocks int) {
+	hjp.memoryBlocks = blocks
+}
+
+// Open opens a hash join scan of both plans.
+func (hjp *HashJoinPlan) Open() (scan.Scan, error) {
+	s1, err := hjp.plan1.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	s2, err := hjp.plan2.Open()
+	if err != nil {
+		return nil, errors.Join(err, s1.Close())
+	}
+
+	hashJoinScan, err := query.NewHashJoinScan(hjp.transaction, s1, hjp.plan1.Schema(), hjp.fieldName1,
+		s2, hjp.plan2.Schema(), hjp.fieldName2, hjp.maxBuildRecords())
+	if err != nil {
+		return nil, errors.Join(err, s1.Close(), s2.Close())
+	}
+	return hashJoinScan, nil
+}
+
+// maxBuildRecords returns the number of records of the second plan that are kept in memory before the records
+// are spilled, which is the number of its records that would fit in the blocks of the memory budget.
+func (hjp *HashJoinPlan) maxBuildRecords() int {
+	blocks := hjp.memoryBlocks
+	if blocks <= 0 {
+		blocks = hjp.transaction.AvailableBuffers()
+	}
+	recordsPerBlock := hjp.transaction.BlockSize() / record.NewLayout(hjp.plan2.Schema()).SlotSize()
+	return max(blocks, 1) * max(recordsPerBlock, 1)
+}
+
+// fitsInMemory returns true if the estimated records of the second plan fit in the memory budget.
+func (hjp *HashJoinPlan) fitsInMemory() bool {
+	return hjp.plan2.RecordsOutput() <= hjp.maxBuildRecords()
+}
+
+// BlocksAccessed estimates the number of block accesses to compute the join, which is one pass through each plan.
+// If the records of the second plan do not fit in memory, the records of both plans are also written into
+// partitions, and read again.
+func (hjp *HashJoinPlan) BlocksAccessed() int {
+	blocks := hjp.plan1.BlocksAccessed() + hjp.plan2.BlocksAccessed()
+	if hjp.fitsInMemory() {
+		return blocks
+	}
+	partitionBlocks := NewMaterializePlan(hjp.transaction, hjp.plan1).BlocksAccessed() +
+		NewMaterializePlan(hjp.transaction, hjp.plan2).BlocksAccessed()
+	return blocks + 2*partitionBlocks
+}
+
+// RecordsOutput estimates the number of records output by the join, like MergeJoinPlan#RecordsOutput.
+func (hjp *HashJoinPlan) RecordsOutput() int {
+	maxValues := max(hjp.plan1.DistinctValues(hjp.fieldName1), hjp.plan2.DistinctValues(hjp.fieldName2), 1)
+	return hjp.plan1.RecordsOutput() * hjp.plan2.RecordsOutput() / maxValues
+}
+
+// DistinctValues estimates the number of distinct values for the specified field,
+// which is the same as in the plan it belongs to.
+func (hjp *HashJoinPlan) DistinctValues(fieldName string) int {
+	if hjp.plan1.Schema().HasField(fieldName) {
+		return hjp.plan1.DistinctValues(fieldName)
+	}
+	return hjp.plan2.DistinctValues(fieldName)
+}
+
+// Schema returns the schema of the join, which is the union of the schemas of the plans.
+func (hjp *HashJoinPlan) Schema() *record.Schema {
+	return hjp.schema
+}
+
+// ToNode returns the description of the hash join plan and its inputs, the probe side first.
+func (hjp *HashJoinPlan) ToNode() *PlanNode {
+	node := newPlanNode("HashJoin", hjp, hjp.plan1, hjp.plan2)
+	node.Predicate = fmt.Sprintf("%s = %s", hjp.fieldName1, hjp.fieldName2)
+	return node
+}
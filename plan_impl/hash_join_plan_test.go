@@ -0,0 +1,106 @@
+package plan_impl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupOrdersAndProducts creates 600 orders of 10 products, neither table having an index.
+func setupOrdersAndProducts(t *testing.T) (*Planner, *tx.Transaction) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 12)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE orders (oid INT, oproduct INT)",
+		"CREATE TABLE products (pid INT, pname VARCHAR(10))",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err)
+	}
+	for oid := 0; oid < 600; oid++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO orders (oid, oproduct) VALUES (%d, %d)", oid, (oid*7)%10), txn)
+		require.NoError(t, err)
+	}
+	for pid := 0; pid < 10; pid++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO products (pid, pname) VALUES (%d, 'p%d')", pid, pid), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	t.Cleanup(func() { require.NoError(t, txn.Commit()) })
+	return p, txn
+}
+
+// countOrderProducts opens the plan, checks that each order is joined with its product, and returns
+// the number of joined records.
+func countOrderProducts(t *testing.T, p plan.Plan) int {
+	s, err := p.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	count := 0
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			return count
+		}
+		oproduct, err := s.GetInt("oproduct")
+		require.NoError(t, err)
+		pid, err := s.GetInt("pid")
+		require.NoError(t, err)
+		pname, err := s.GetString("pname")
+		require.NoError(t, err)
+		require.Equal(t, oproduct, pid)
+		require.Equal(t, fmt.Sprintf("p%d", pid), pname)
+		count++
+	}
+}
+
+func TestHashJoinPlan_ChosenForSmallBuildSide(t *testing.T) {
+	p, txn := setupOrdersAndProducts(t)
+
+	sql := "SELECT oid, pname FROM products, orders WHERE oproduct = pid"
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err)
+
+	// The products fit in memory, so they are hashed, and the orders probe them.
+	inputs, _ := joinInputs(t, queryPlan)
+	hashJoin := queryPlan.(*ProjectPlan).inputPlan.(*SelectPlan).inputPlan.(*HashJoinPlan)
+	assert.Equal(t, "orders", inputs[0].(*TablePlan).tableName)
+	assert.Equal(t, "products", inputs[1].(*TablePlan).tableName)
+	assert.Equal(t, "oproduct = pid", hashJoin.ToNode().Predicate)
+
+	s, err := queryPlan.Open()
+	require.NoError(t, err)
+	assert.Equal(t, 600, countRecords(t, s))
+	assert.Equal(t, 600, countOrderProducts(t, hashJoin))
+}
+
+func TestHashJoinPlan_MemoryBudget(t *testing.T) {
+	p, txn := setupOrdersAndProducts(t)
+
+	orders, err := NewTablePlan(txn, "orders", p.queryPlanner.(*BasicQueryPlanner).metadataManager)
+	require.NoError(t, err)
+	products, err := NewTablePlan(txn, "products", p.queryPlanner.(*BasicQueryPlanner).metadataManager)
+	require.NoError(t, err)
+
+	// Hashing the products reads each table once.
+	inMemory := NewHashJoinPlan(txn, orders, products, "oproduct", "pid")
+	assert.Equal(t, orders.BlocksAccessed()+products.BlocksAccessed(), inMemory.BlocksAccessed())
+	assert.Equal(t, 600*10/10, inMemory.RecordsOutput())
+	assert.Equal(t, products.DistinctValues("pname"), inMemory.DistinctValues("pname"))
+	assert.Equal(t, 600, countOrderProducts(t, inMemory))
+
+	// Hashing the orders in a single block spills the records of both tables into partitions,
+	// which are written and read again.
+	spilled := NewHashJoinPlan(txn, products, orders, "pid", "oproduct")
+	spilled.SetMemoryBudget(1)
+	assert.Greater(t, spilled.BlocksAccessed(), orders.BlocksAccessed()+products.BlocksAccessed())
+	assert.Equal(t, 600, countOrderProducts(t, spilled))
+}
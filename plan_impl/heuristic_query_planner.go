@@ -0,0 +1,118 @@
+package plan_impl
+
+import (
+	"slices"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ QueryPlanner = &HeuristicQueryPlanner{}
+var _ IndexAdvisor = &HeuristicQueryPlanner{}
+var _ ParallelScanner = &HeuristicQueryPlanner{}
+
+// HeuristicQueryPlanner plans queries like BasicQueryPlanner, except that it joins the inputs of a query
+// reading three or more tables or views in the order estimated to keep the intermediate results smallest
+// (see joinInGreedyOrder), rather than in the order of its FROM clause, which may join the largest inputs first.
+type HeuristicQueryPlanner struct {
+	*BasicQueryPlanner
+}
+
+// NewHeuristicQueryPlanner creates a new HeuristicQueryPlanner.
+func NewHeuristicQueryPlanner(metadataManager *metadata.Manager) *HeuristicQueryPlanner {
+	basic := NewBasicQueryPlanner(metadataManager)
+	basic.orderJoins = true
+	return &HeuristicQueryPlanner{BasicQueryPlanner: basic}
+}
+
+// joinInGreedyOrder joins the inputs of a query, each selected on the conjuncts of the predicate reading only
+// its own fields, greedily: it starts with the input estimated to output the fewest records, and then joins
+// the input whose join with the inputs joined so far is estimated to output the fewest records once selected
+// on the conjuncts of the predicate reading both. An input that a conjunct relates to the inputs joined so far
+// is preferred to the others, whose product with them is only taken once no input is related to them. Each join
+// is the cheapest found by joinPlans, such as an index join on a field the predicate equates with a field of the
+// other input. Ties are broken by the blocks accessed, and then in the order of the FROM clause.
+// The predicate is applied above the last join.
+func (qp *BasicQueryPlanner) joinInGreedyOrder(plans []plan.Plan, predicate *query.Predicate, transaction *tx.Transaction) (plan.Plan, error) {
+	remaining := slices.Clone(plans)
+	first := 0
+	for i, p := range remaining {
+		if p.RecordsOutput() < remaining[first].RecordsOutput() {
+			first = i
+		}
+	}
+	currentPlan := remaining[first]
+	remaining = slices.Delete(remaining, first, first+1)
+
+	// The records output by a join are estimated from the selection of the product of its inputs,
+	// since a join applying some of the conjuncts itself estimates the records it outputs without the others.
+	estimate, unapplied := currentPlan, predicate.Conjuncts()
+	for len(remaining) > 0 {
+		var bestPlan, bestEstimate plan.Plan
+		var bestUnapplied []*query.Predicate
+		bestIndex, bestRelated := -1, false
+		for i, nextPlan := range remaining {
+			joinPlan, err := qp.joinPlans(currentPlan, nextPlan, predicate, transaction)
+			if err != nil {
+				return nil, err
+			}
+			joinEstimate, err := NewProductPlan(transaction, estimate, nextPlan)
+			if err != nil {
+				return nil, err
+			}
+			selectedEstimate, rest, err := selectJoin(joinEstimate, plans, unapplied)
+			if err != nil {
+				return nil, err
+			}
+			related := slices.ContainsFunc(unapplied, func(conjunct *query.Predicate) bool {
+				return !slices.Contains(rest, conjunct) && slices.ContainsFunc(conjunct.Fields(), nextPlan.Schema().HasField)
+			})
+			if bestPlan == nil || related && !bestRelated || related == bestRelated &&
+				(selectedEstimate.RecordsOutput() < bestEstimate.RecordsOutput() ||
+					selectedEstimate.RecordsOutput() == bestEstimate.RecordsOutput() && joinPlan.BlocksAccessed() < bestPlan.BlocksAccessed()) {
+				bestPlan, bestEstimate, bestUnapplied, bestIndex, bestRelated = joinPlan, selectedEstimate, rest, i, related
+			}
+		}
+		currentPlan, estimate, unapplied = bestPlan, bestEstimate, bestUnapplied
+		remaining = slices.Delete(remaining, bestIndex, bestIndex+1)
+	}
+	return currentPlan, nil
+}
+
+// selectJoin returns the product of some of the inputs of a query selected on the conjuncts that only read its
+// fields, and the other conjuncts. A conjunct reading a field that several inputs of the query have is left out,
+// since the input whose field it reads depends on the order of the joins.
+func selectJoin(joinPlan plan.Plan, plans []plan.Plan, conjuncts []*query.Predicate) (plan.Plan, []*query.Predicate, error) {
+	var applied, rest []*query.Predicate
+	for _, conjunct := range conjuncts {
+		if conjunct.AppliesTo(joinPlan.Schema()) && !slices.ContainsFunc(conjunct.Fields(), func(field string) bool {
+			return inputsHaving(plans, field) > 1
+		}) {
+			applied = append(applied, conjunct)
+		} else {
+			rest = append(rest, conjunct)
+		}
+	}
+	selection := conjunctionOf(applied)
+	if selection == nil {
+		return joinPlan, rest, nil
+	}
+	selectPlan, err := NewSelectPlan(joinPlan, selection)
+	if err != nil {
+		return nil, nil, err
+	}
+	return selectPlan, rest, nil
+}
+
+// inputsHaving returns the number of the inputs having the specified field.
+func inputsHaving(plans []plan.Plan, field string) int {
+	count := 0
+	for _, p := range plans {
+		if p.Schema().HasField(field) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,91 @@
+package plan_impl
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+func TestHeuristicQueryPlanner_StarJoin(t *testing.T) {
+	p, mdm, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+	heuristic := NewPlanner(NewHeuristicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	// Sale i is of product i%30 in store i%20. Product i is in category c(i%10), and store i in region r(i%4).
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE TABLE sales (sid INT, sprod INT, sstore INT)",
+		"CREATE TABLE products (pid INT, pcat VARCHAR(5))",
+		"CREATE TABLE stores (stid INT, sregion VARCHAR(5))",
+		"CREATE INDEX idx_sprod ON sales (sprod)",
+	} {
+		_, err := heuristic.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	for i := 0; i < 600; i++ {
+		_, err := heuristic.ExecuteUpdate(fmt.Sprintf("INSERT INTO sales (sid, sprod, sstore) VALUES (%d, %d, %d)", i, i%30, i%20), txn)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 30; i++ {
+		_, err := heuristic.ExecuteUpdate(fmt.Sprintf("INSERT INTO products (pid, pcat) VALUES (%d, 'c%d')", i, i%10), txn)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 20; i++ {
+		_, err := heuristic.ExecuteUpdate(fmt.Sprintf("INSERT INTO stores (stid, sregion) VALUES (%d, 'r%d')", i, i%4), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	sql := "SELECT sid FROM sales, stores, products WHERE sprod = pid AND sstore = stid AND pcat = 'c3' AND sregion = 'r1'"
+	for _, planner := range []*Planner{p, heuristic} {
+		rows := runPlannerQuery(t, planner, sql, fm, lm, bm, lt, []string{"sid"})
+		require.Len(t, rows, 30)
+		for _, row := range rows {
+			sid := row["sid"].(int)
+			assert.Equal(t, 3, sid%30%10)
+			assert.Equal(t, 1, sid%20%4)
+		}
+	}
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	// shape explains the query without the estimates of each plan.
+	shape := func(planner *Planner, sql string) string {
+		explanation, err := planner.Explain(sql, txn)
+		require.NoError(t, err)
+		return regexp.MustCompile(` \(blocks=\d+, records=\d+\)`).ReplaceAllString(explanation, "")
+	}
+
+	// In the order of the FROM clause, every sale is joined with the stores first.
+	assert.Equal(t, `Project fields=[sid]
+  Select predicate=(sprod = pid and sstore = stid)
+    HashJoin predicate=(sprod = pid)
+      HashJoin predicate=(sstore = stid)
+        TableScan table=sales
+        Select predicate=(sregion = r1)
+          TableScan table=stores
+      Select predicate=(pcat = c3)
+        TableScan table=products`, shape(p, sql))
+
+	// The heuristic planner starts with the fewest products, and only finds their sales through the index.
+	assert.Equal(t, `Project fields=[sid]
+  Select predicate=(sprod = pid and sstore = stid)
+    HashJoin predicate=(sstore = stid)
+      IndexJoin table=sales index=idx_sprod predicate=(sprod = pid)
+        Select predicate=(pcat = c3)
+          TableScan table=products
+      Select predicate=(sregion = r1)
+        TableScan table=stores`, shape(heuristic, sql))
+
+	// The products and the stores are not related by a conjunct, so their product is not taken first
+	// even though it is estimated to output fewer records than the join of the products with their sales.
+	assert.NotContains(t, shape(heuristic, sql), "Product")
+
+	// The inputs of a join of two tables are ordered by the cost of the join, like in the order of the FROM clause.
+	sql = "SELECT sid FROM sales, products WHERE sprod = pid AND pcat = 'c3'"
+	assert.Equal(t, shape(p, sql), shape(heuristic, sql))
+}
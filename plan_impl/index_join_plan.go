@@ -1,6 +1,7 @@
 package plan_impl
 
 import (
+	"errors"
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/plan"
@@ -11,6 +12,7 @@ import (
 )
 
 var _ plan.Plan = &IndexJoinPlan{}
+var _ NodePlan = &IndexJoinPlan{}
 
 // IndexJoinPlan is a plan that corresponds to an index join operation.
 type IndexJoinPlan struct {
@@ -46,16 +48,29 @@ func (ijp *IndexJoinPlan) Open() (scan.Scan, error) {
 
 	s2, err := ijp.plan2.Open()
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(err, s1.Close())
 	}
 	tableScan, ok := s2.(*table.Scan)
 	if !ok {
-		return nil, fmt.Errorf("first plan is not a table scan")
+		return nil, errors.Join(fmt.Errorf("second plan is not a table scan"), s1.Close(), s2.Close())
 	}
 
-	idx := ijp.indexInfo.Open()
+	idx, err := ijp.indexInfo.Open()
+	if err != nil {
+		return nil, errors.Join(err, s1.Close(), tableScan.Close())
+	}
 
-	return query.NewIndexJoinScan(s1, tableScan, ijp.joinField, idx)
+	indexJoinScan, err := query.NewIndexJoinScan(s1, tableScan, ijp.joinField, ijp.indexInfo.FieldName(), idx)
+	if err != nil {
+		idx.Close()
+		return nil, errors.Join(err, s1.Close(), tableScan.Close())
+	}
+	// If the index turns out to be stale, the scan reads the table instead, and the index is marked for rebuilding.
+	indexJoinScan.SetIndexPredicate(ijp.indexInfo.Predicate())
+	indexJoinScan.SetStaleIndexHandler(func(int, int) {
+		ijp.indexInfo.MarkSuspect()
+	})
+	return indexJoinScan, nil
 }
 
 // BlocksAccessed estimates the number of block access to compute the join.
@@ -84,3 +99,16 @@ func (ijp *IndexJoinPlan) DistinctValues(fieldName string) int {
 func (ijp *IndexJoinPlan) Schema() *record.Schema {
 	return ijp.schema
 }
+
+// ToNode returns the description of the index join plan and its first input.
+// The indexed table is described by the node itself, since only its records matching the join field are read.
+func (ijp *IndexJoinPlan) ToNode() *PlanNode {
+	node := newPlanNode("IndexJoin", ijp, ijp.plan1)
+	node.Table = planTableName(ijp.plan2)
+	node.Index = ijp.indexInfo.IndexName()
+	node.Predicate = fmt.Sprintf("%s = %s", ijp.indexInfo.FieldName(), ijp.joinField)
+	if ijp.indexInfo.Suspect() {
+		node.Detail = "suspect index, should be rebuilt"
+	}
+	return node
+}
@@ -1,6 +1,7 @@
 package plan_impl
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/JyotinderSingh/dropdb/buffer"
@@ -11,6 +12,7 @@ import (
 	"github.com/JyotinderSingh/dropdb/log"
 	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
@@ -89,7 +91,8 @@ func setupIndexJoinPlanTest(t *testing.T) *indexJoinPlanTestSetup {
 		require.NoError(t, deptScan.SetString("dept_name", d.name))
 		require.NoError(t, deptScan.SetInt("budget", d.budget))
 
-		rid := deptScan.GetRecordID()
+		rid, err := deptScan.GetRecordID()
+		require.NoError(t, err)
 		require.NoError(t, idx.Insert(d.deptID, rid))
 	}
 
@@ -114,17 +117,9 @@ func setupIndexJoinPlanTest(t *testing.T) *indexJoinPlanTestSetup {
 	}
 
 	// Create metadata managers
-	empMetadata := createTableMetadataWithSchema(t, transaction, "employee", map[string]interface{}{
-		"emp_id":  0,
-		"name":    "string",
-		"dept_id": 0,
-	})
+	empMetadata := createTableMetadata(t, transaction, "employee", empSchema)
 
-	deptMetadata := createTableMetadataWithSchema(t, transaction, "department", map[string]interface{}{
-		"dept_id":   0,
-		"dept_name": "string",
-		"budget":    0,
-	})
+	deptMetadata := createTableMetadata(t, transaction, "department", deptSchema)
 
 	// Create StatInfo and IndexInfo
 	statInfo := metadata.NewStatInfo(3, 3, map[string]int{
@@ -327,3 +322,147 @@ func TestIndexJoinPlan_Schema(t *testing.T) {
 	assert.True(t, schema.HasField("dept_name"))
 	assert.True(t, schema.HasField("budget"))
 }
+
+func TestIndexJoinPlan_StaleIndex(t *testing.T) {
+	_, mdm, fm, lm, bm, lt := setupPlannerTest(t, 800, 20)
+	indexPlanner := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	statements := []string{
+		"CREATE TABLE emp (emp_id INT, dept_id INT)",
+		"CREATE TABLE dept (dno INT, dname VARCHAR(10))",
+		"CREATE INDEX dept_dno ON dept (dno)",
+		"INSERT INTO dept (dno, dname) VALUES (1, 'sales')",
+		"INSERT INTO dept (dno, dname) VALUES (2, 'eng')",
+		"INSERT INTO dept (dno, dname) VALUES (3, 'ops')",
+	}
+	for i := 0; i < 60; i++ {
+		statements = append(statements, fmt.Sprintf("INSERT INTO emp (emp_id, dept_id) VALUES (%d, %d)", i, i%3+1))
+	}
+	for _, statement := range statements {
+		_, err := indexPlanner.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+
+	// Writing the table directly bypasses the index, which is left pointing at a modified and a deleted record.
+	deptPlan, err := NewTablePlan(txn, "dept", mdm)
+	require.NoError(t, err)
+	deptScan, err := deptPlan.Open()
+	require.NoError(t, err)
+	updateScan := deptScan.(scan.UpdateScan)
+	for {
+		hasNext, err := updateScan.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		dno, err := updateScan.GetInt("dno")
+		require.NoError(t, err)
+		switch dno {
+		case 1:
+			require.NoError(t, updateScan.SetInt("dno", 7))
+		case 3:
+			require.NoError(t, updateScan.Delete())
+		}
+	}
+	require.NoError(t, updateScan.Close())
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	indexes, err := mdm.GetIndexInfo("dept", txn)
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	empPlan, err := NewTablePlan(txn, "emp", mdm)
+	require.NoError(t, err)
+	deptPlan, err = NewTablePlan(txn, "dept", mdm)
+	require.NoError(t, err)
+	ijp := NewIndexJoinPlan(empPlan, deptPlan, *indexes[0], "dept_id")
+
+	s, err := ijp.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Only the employees of the department left unchanged are joined, each exactly once.
+	joined := map[int]bool{}
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		empID, err := s.GetInt("emp_id")
+		require.NoError(t, err)
+		dname, err := s.GetString("dname")
+		require.NoError(t, err)
+		assert.Equal(t, "eng", dname)
+		assert.Equal(t, 1, empID%3)
+		assert.False(t, joined[empID])
+		joined[empID] = true
+	}
+	assert.Len(t, joined, 20)
+
+	assert.True(t, mdm.IsIndexSuspect("dept_dno"))
+	assert.Equal(t, "suspect index, should be rebuilt", ijp.ToNode().Detail)
+}
+
+func TestBasicQueryPlanner_ChoosesIndexJoin(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 20)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	statements := []string{
+		"CREATE TABLE emp (emp_id INT, dept_id INT)",
+		"CREATE TABLE dept (dno INT, dname VARCHAR(10))",
+		"CREATE TABLE shift (kind INT, label VARCHAR(10))",
+		"CREATE INDEX dept_dno ON dept (dno)",
+		"CREATE INDEX shift_kind ON shift (kind)",
+	}
+	for i := 0; i < 200; i++ {
+		statements = append(statements,
+			fmt.Sprintf("INSERT INTO emp (emp_id, dept_id) VALUES (%d, %d)", i, i%100),
+			fmt.Sprintf("INSERT INTO shift (kind, label) VALUES (%d, 'shift %d')", i%2, i))
+	}
+	for i := 0; i < 100; i++ {
+		statements = append(statements, fmt.Sprintf("INSERT INTO dept (dno, dname) VALUES (%d, 'dept %d')", i, i))
+	}
+	for _, statement := range statements {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	require.NoError(t, txn.Commit())
+
+	// countRows explains the query, and returns the explanation along with the number of records the query outputs.
+	countRows := func(sql string) (string, int) {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		explanation, err := p.Explain(sql, txn)
+		require.NoError(t, err)
+
+		queryPlan, err := p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err)
+		s, err := queryPlan.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		count := 0
+		for {
+			hasNext, err := s.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				return explanation, count
+			}
+			count++
+		}
+	}
+
+	// Each employee matches a single department, which the index finds for far fewer block accesses than the product.
+	explanation, count := countRows("SELECT emp_id, dname FROM emp, dept WHERE dept_id = dno")
+	assert.Contains(t, explanation, "IndexJoin table=dept index=dept_dno")
+	assert.NotContains(t, explanation, "Product")
+	assert.Equal(t, 200, count)
+
+	// Each employee of the first two departments matches half of the shifts, which are cheaper to read by the product.
+	explanation, count = countRows("SELECT emp_id, label FROM emp, shift WHERE dept_id = kind")
+	assert.NotContains(t, explanation, "IndexJoin")
+	assert.Contains(t, explanation, "Product")
+	assert.Equal(t, 4*100, count)
+}
@@ -1,21 +1,28 @@
 package plan_impl
 
 import (
+	"errors"
 	"fmt"
+	"github.com/JyotinderSingh/dropdb/index"
 	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/types"
+	"strings"
 )
 
 var _ plan.Plan = &IndexSelectPlan{}
+var _ NodePlan = &IndexSelectPlan{}
 
 type IndexSelectPlan struct {
 	inputPlan plan.Plan
 	indexInfo *metadata.IndexInfo
 	value     any
+	// keyRange is the range of search keys of a range select, or nil for a select of the selection constant.
+	keyRange *index.Range
 }
 
 // NewIndexSelectPlan creates a new indexselect node in the query tree
@@ -28,6 +35,16 @@ func NewIndexSelectPlan(inputPlan plan.Plan, indexInfo *metadata.IndexInfo, valu
 	}
 }
 
+// NewIndexRangePlan creates a new indexselect node in the query tree for the records whose search keys
+// are in the specified range, which the index must be able to find (see metadata.IndexInfo#SupportsRanges).
+func NewIndexRangePlan(inputPlan plan.Plan, indexInfo *metadata.IndexInfo, keyRange index.Range) *IndexSelectPlan {
+	return &IndexSelectPlan{
+		inputPlan: inputPlan,
+		indexInfo: indexInfo,
+		keyRange:  &keyRange,
+	}
+}
+
 // Open creates a new indexselect scan for this query.
 func (isp *IndexSelectPlan) Open() (scan.Scan, error) {
 	inputScan, err := isp.inputPlan.Open()
@@ -36,29 +53,51 @@ func (isp *IndexSelectPlan) Open() (scan.Scan, error) {
 	}
 	tableScan, ok := inputScan.(*table.Scan)
 	if !ok {
-		return nil, fmt.Errorf("IndexSelectPlan requires a tablescan")
+		return nil, errors.Join(fmt.Errorf("IndexSelectPlan requires a tablescan"), inputScan.Close())
+	}
+	idx, err := isp.indexInfo.Open()
+	if err != nil {
+		return nil, errors.Join(err, tableScan.Close())
+	}
+	var indexSelectScan *query.IndexSelectScan
+	if isp.keyRange != nil {
+		indexSelectScan, err = query.NewIndexRangeScan(tableScan, idx, *isp.keyRange)
+	} else {
+		indexSelectScan, err = query.NewIndexSelectScan(tableScan, idx, isp.value)
 	}
-	idx := isp.indexInfo.Open()
-	return query.NewIndexSelectScan(tableScan, idx, isp.value)
+	if err != nil {
+		idx.Close()
+		return nil, errors.Join(err, tableScan.Close())
+	}
+	return indexSelectScan, nil
 }
 
 // BlocksAccessed returns the estimated number of block accesses
 // to compute the index selection, which is the same as the index
 // traversal cost plus the number of matching data records.
 func (isp *IndexSelectPlan) BlocksAccessed() int {
+	if isp.keyRange != nil {
+		return isp.indexInfo.RangeBlocksAccessed(*isp.keyRange) + isp.RecordsOutput()
+	}
 	return isp.indexInfo.BlocksAccessed() + isp.RecordsOutput()
 }
 
 // RecordsOutput returns the estimated number of records in the
 // index selection, which is the same as the number of search
-// key values for the index.
+// key values for the index, or the number of records in the range.
 func (isp *IndexSelectPlan) RecordsOutput() int {
+	if isp.keyRange != nil {
+		return isp.indexInfo.RangeRecordsOutput(*isp.keyRange)
+	}
 	return isp.indexInfo.RecordsOutput()
 }
 
 // DistinctValues returns the estimated number of distinct values
-// as defined by the index.
+// as defined by the index. A range select outputs the values of the indexed field in the range.
 func (isp *IndexSelectPlan) DistinctValues(fieldName string) int {
+	if isp.keyRange != nil {
+		return min(isp.inputPlan.DistinctValues(fieldName), isp.RecordsOutput())
+	}
 	return isp.indexInfo.DistinctValues(fieldName)
 }
 
@@ -66,3 +105,25 @@ func (isp *IndexSelectPlan) DistinctValues(fieldName string) int {
 func (isp *IndexSelectPlan) Schema() *record.Schema {
 	return isp.inputPlan.Schema()
 }
+
+// ToNode returns the description of the index select plan. The indexed table is described
+// by the node itself, rather than as an input, since only its records having the key, or a key in the range, are read.
+func (isp *IndexSelectPlan) ToNode() *PlanNode {
+	node := newPlanNode("IndexSelect", isp)
+	node.Table = planTableName(isp.inputPlan)
+	node.Index = isp.indexInfo.IndexName()
+	key, composite := isp.value.(types.CompositeKey)
+	switch {
+	case isp.keyRange != nil:
+		node.Predicate = isp.keyRange.Describe(isp.indexInfo.FieldName())
+	case composite:
+		terms := make([]string, len(key))
+		for i, fieldName := range isp.indexInfo.FieldNames() {
+			terms[i] = fmt.Sprintf("%s = %v", fieldName, key[i])
+		}
+		node.Predicate = strings.Join(terms, " and ")
+	default:
+		node.Predicate = fmt.Sprintf("%s = %v", isp.indexInfo.FieldName(), isp.value)
+	}
+	return node
+}
@@ -24,6 +24,7 @@ type indexPlanTestSetup struct {
 	tableScan   *table.Scan
 	idx         index.Index
 	indexInfo   *metadata.IndexInfo
+	tableSchema *record.Schema
 	cleanup     func()
 }
 
@@ -92,7 +93,8 @@ func setupIndexPlanTest(t *testing.T) *indexPlanTestSetup {
 		require.NoError(t, ts.SetString("name", d.name))
 		require.NoError(t, ts.SetInt("val", d.val))
 
-		rid := ts.GetRecordID()
+		rid, err := ts.GetRecordID()
+		require.NoError(t, err)
 		require.NoError(t, idx.Insert(d.val, rid))
 	}
 
@@ -112,20 +114,16 @@ func setupIndexPlanTest(t *testing.T) *indexPlanTestSetup {
 		tableScan:   ts,
 		idx:         idx,
 		indexInfo:   indexInfo,
+		tableSchema: tblSchema,
 		cleanup:     cleanup,
 	}
 }
 
-// Warning: Flaky in group run
 func TestIndexSelectPlan_Basic(t *testing.T) {
 	setup := setupIndexPlanTest(t)
 	defer setup.cleanup()
 
-	mdm := createTableMetadataWithSchema(t, setup.transaction, "test_table", map[string]interface{}{
-		"id":   0,
-		"name": "string",
-		"val":  0,
-	})
+	mdm := createTableMetadata(t, setup.transaction, "test_table", setup.tableSchema)
 
 	tp, err := NewTablePlan(setup.transaction, "test_table", mdm)
 	require.NoError(t, err)
@@ -166,16 +164,11 @@ func TestIndexSelectPlan_Basic(t *testing.T) {
 	assert.Equal(t, 4, isp.DistinctValues("id"))
 }
 
-// Warning: Flaky in group run
 func TestIndexSelectPlan_NoMatches(t *testing.T) {
 	setup := setupIndexPlanTest(t)
 	defer setup.cleanup()
 
-	mdm := createTableMetadataWithSchema(t, setup.transaction, "test_table", map[string]interface{}{
-		"id":   0,
-		"name": "string",
-		"val":  0,
-	})
+	mdm := createTableMetadata(t, setup.transaction, "test_table", setup.tableSchema)
 
 	tp, err := NewTablePlan(setup.transaction, "test_table", mdm)
 	require.NoError(t, err)
@@ -191,16 +184,11 @@ func TestIndexSelectPlan_NoMatches(t *testing.T) {
 	assert.False(t, hasNext)
 }
 
-// Warning: Flaky in group run
 func TestIndexSelectPlan_SingleMatch(t *testing.T) {
 	setup := setupIndexPlanTest(t)
 	defer setup.cleanup()
 
-	mdm := createTableMetadataWithSchema(t, setup.transaction, "test_table", map[string]interface{}{
-		"id":   0,
-		"name": "string",
-		"val":  0,
-	})
+	mdm := createTableMetadata(t, setup.transaction, "test_table", setup.tableSchema)
 
 	tp, err := NewTablePlan(setup.transaction, "test_table", mdm)
 	require.NoError(t, err)
@@ -0,0 +1,236 @@
+package plan_impl
+
+import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/types"
+	"strings"
+)
+
+// RejectionReason explains why the planner did not use a candidate index.
+type RejectionReason string
+
+const (
+	// RejectPredicateNotImplied means the index is a partial index whose predicate
+	// is not implied by the query's predicate, so it may be missing matching records.
+	RejectPredicateNotImplied RejectionReason = "index predicate not implied"
+	// RejectNoEqualityTerm means the predicate has no term of the form "F=c" on the indexed field,
+	// and, if the index can find the search keys in a range, no term comparing the field with a constant either.
+	// A composite index needs such a term for each of its fields.
+	RejectNoEqualityTerm RejectionReason = "no equality term"
+	// RejectTypeMismatch means the constant in the equality term, or a bound of the range,
+	// does not have the indexed field's type.
+	RejectTypeMismatch RejectionReason = "type mismatch"
+	// RejectMissingStatistics means there are no usable statistics for the indexed field.
+	RejectMissingStatistics RejectionReason = "statistics missing"
+	// RejectNotCheaper means an index select would not access fewer blocks than a table scan.
+	RejectNotCheaper RejectionReason = "not cheaper than a table scan"
+	// RejectCheaperIndex means another candidate index was at least as cheap.
+	RejectCheaperIndex RejectionReason = "another index is cheaper"
+)
+
+// IndexCandidate describes an index that the planner considered for reading a table.
+type IndexCandidate struct {
+	IndexName string
+	FieldName string
+	// Term is the predicate term the index could serve, or nil if there is none.
+	Term *query.Term
+	// Terms are the predicate terms a composite index could serve, one for each of its fields in order,
+	// or nil if the index is not composite or some of its fields have no term.
+	Terms []*query.Term
+	// Range is the range of the indexed field that the predicate terms comparing it with constants allow,
+	// if the index serves them instead of an equality term, or nil.
+	Range *index.Range
+	// BlocksWithIndex is the estimated number of block accesses of an index select
+	// using this index, or -1 if it could not be estimated.
+	BlocksWithIndex int
+	// BlocksWithoutIndex is the estimated number of block accesses of a table scan.
+	BlocksWithoutIndex int
+	// Rejection is the reason the index was not used, or empty if it was chosen.
+	Rejection RejectionReason
+
+	indexInfo *metadata.IndexInfo
+	value     any
+}
+
+// AccessPath describes how the planner decided to read a single table.
+type AccessPath struct {
+	TableName string
+	// Candidates holds every index on the table, ordered by index name.
+	Candidates []*IndexCandidate
+	// Chosen is the candidate used to read the table, or nil if the table is scanned.
+	Chosen *IndexCandidate
+}
+
+// String returns a short description of the chosen access path.
+func (ap *AccessPath) String() string {
+	if ap.Chosen == nil {
+		return fmt.Sprintf("table scan on %s", ap.TableName)
+	}
+	if ap.Chosen.Range != nil {
+		return fmt.Sprintf("index range select on %s using %s (%s)", ap.TableName, ap.Chosen.IndexName, ap.Chosen.Range.Describe(ap.Chosen.FieldName))
+	}
+	if ap.Chosen.Terms != nil {
+		terms := make([]string, len(ap.Chosen.Terms))
+		for i, term := range ap.Chosen.Terms {
+			terms[i] = term.String()
+		}
+		return fmt.Sprintf("index select on %s using %s (%s)", ap.TableName, ap.Chosen.IndexName, strings.Join(terms, " and "))
+	}
+	return fmt.Sprintf("index select on %s using %s (%s)", ap.TableName, ap.Chosen.IndexName, ap.Chosen.Term)
+}
+
+// plan returns the plan that reads the table along this access path.
+func (ap *AccessPath) plan(tablePlan *TablePlan) plan.Plan {
+	if ap.Chosen == nil {
+		return tablePlan
+	}
+	if ap.Chosen.Range != nil {
+		return NewIndexRangePlan(tablePlan, ap.Chosen.indexInfo, *ap.Chosen.Range)
+	}
+	return NewIndexSelectPlan(tablePlan, ap.Chosen.indexInfo, ap.Chosen.value)
+}
+
+// chooseAccessPath evaluates every index on the table, which are ordered by index name (see metadata.Manager#GetIndexInfo),
+// against the predicate and picks the cheapest index select, if any is cheaper than scanning the table.
+// Ties are broken in favor of the index whose name sorts first.
+func chooseAccessPath(tablePlan *TablePlan, predicate *query.Predicate, indexes []*metadata.IndexInfo) *AccessPath {
+	ap := &AccessPath{TableName: tablePlan.tableName}
+
+	for _, indexInfo := range indexes {
+		candidate := evaluateCandidate(tablePlan, predicate, indexInfo)
+		ap.Candidates = append(ap.Candidates, candidate)
+		if candidate.Rejection != "" {
+			continue
+		}
+
+		if ap.Chosen == nil {
+			ap.Chosen = candidate
+		} else if candidate.BlocksWithIndex < ap.Chosen.BlocksWithIndex {
+			ap.Chosen.Rejection = RejectCheaperIndex
+			ap.Chosen = candidate
+		} else {
+			candidate.Rejection = RejectCheaperIndex
+		}
+	}
+
+	return ap
+}
+
+// evaluateCandidate estimates the cost of reading the table through the specified index,
+// setting the candidate's rejection reason if the index cannot or should not be used.
+func evaluateCandidate(tablePlan *TablePlan, predicate *query.Predicate, indexInfo *metadata.IndexInfo) *IndexCandidate {
+	fieldName := indexInfo.FieldName()
+	candidate := &IndexCandidate{
+		IndexName:          indexInfo.IndexName(),
+		FieldName:          fieldName,
+		BlocksWithIndex:    -1,
+		BlocksWithoutIndex: tablePlan.BlocksAccessed(),
+		indexInfo:          indexInfo,
+	}
+
+	if indexPredicate := indexInfo.Predicate(); indexPredicate != nil {
+		if predicate == nil || !predicate.Implies(indexPredicate) {
+			candidate.Rejection = RejectPredicateNotImplied
+			return candidate
+		}
+	}
+
+	if indexInfo.Composite() {
+		return evaluateCompositeCandidate(tablePlan, predicate, candidate)
+	}
+	if predicate != nil {
+		candidate.Term = predicate.ConstantEqualityTerm(fieldName)
+	}
+	if candidate.Term == nil && predicate != nil && indexInfo.SupportsRanges() {
+		if keyRange, ok := predicate.ConstantRange(fieldName); ok {
+			return evaluateRangeCandidate(tablePlan, candidate, keyRange)
+		}
+	}
+	if candidate.Term == nil {
+		candidate.Rejection = RejectNoEqualityTerm
+		return candidate
+	}
+
+	candidate.value = candidate.Term.EquatesWithConstant(fieldName)
+	if !types.IsValueOfType(candidate.value, tablePlan.Schema().Type(fieldName)) {
+		candidate.Rejection = RejectTypeMismatch
+		return candidate
+	}
+
+	if tablePlan.DistinctValues(fieldName) <= 0 {
+		candidate.Rejection = RejectMissingStatistics
+		return candidate
+	}
+
+	candidate.BlocksWithIndex = NewIndexSelectPlan(tablePlan, indexInfo, candidate.value).BlocksAccessed()
+	if candidate.BlocksWithIndex >= candidate.BlocksWithoutIndex {
+		candidate.Rejection = RejectNotCheaper
+	}
+	return candidate
+}
+
+// evaluateRangeCandidate estimates the cost of reading the records whose indexed field is in the range
+// through the candidate's index, like evaluateCandidate does for an equality term.
+func evaluateRangeCandidate(tablePlan *TablePlan, candidate *IndexCandidate, keyRange index.Range) *IndexCandidate {
+	candidate.Range = &keyRange
+	fieldType := tablePlan.Schema().Type(candidate.FieldName)
+	for _, bound := range []any{keyRange.Low, keyRange.High} {
+		if bound != nil && !types.IsValueOfType(bound, fieldType) {
+			candidate.Rejection = RejectTypeMismatch
+			return candidate
+		}
+	}
+
+	if tablePlan.DistinctValues(candidate.FieldName) <= 0 {
+		candidate.Rejection = RejectMissingStatistics
+		return candidate
+	}
+
+	candidate.BlocksWithIndex = NewIndexRangePlan(tablePlan, candidate.indexInfo, keyRange).BlocksAccessed()
+	if candidate.BlocksWithIndex >= candidate.BlocksWithoutIndex {
+		candidate.Rejection = RejectNotCheaper
+	}
+	return candidate
+}
+
+// evaluateCompositeCandidate estimates the cost of reading the records whose indexed fields are all equal
+// to constants through the candidate's composite index, like evaluateCandidate does for an index on a single field.
+// The search key holds the constants the predicate equates the fields with.
+func evaluateCompositeCandidate(tablePlan *TablePlan, predicate *query.Predicate, candidate *IndexCandidate) *IndexCandidate {
+	fieldNames := candidate.indexInfo.FieldNames()
+	terms := make([]*query.Term, len(fieldNames))
+	key := make(types.CompositeKey, len(fieldNames))
+	for i, fieldName := range fieldNames {
+		if predicate != nil {
+			terms[i] = predicate.ConstantEqualityTerm(fieldName)
+		}
+		if terms[i] == nil {
+			candidate.Rejection = RejectNoEqualityTerm
+			return candidate
+		}
+		key[i] = terms[i].EquatesWithConstant(fieldName)
+	}
+	candidate.Terms = terms
+	candidate.value = key
+
+	for i, fieldName := range fieldNames {
+		if !types.IsValueOfType(key[i], tablePlan.Schema().Type(fieldName)) {
+			candidate.Rejection = RejectTypeMismatch
+			return candidate
+		}
+		if tablePlan.DistinctValues(fieldName) <= 0 {
+			candidate.Rejection = RejectMissingStatistics
+			return candidate
+		}
+	}
+
+	candidate.BlocksWithIndex = NewIndexSelectPlan(tablePlan, candidate.indexInfo, key).BlocksAccessed()
+	if candidate.BlocksWithIndex >= candidate.BlocksWithoutIndex {
+		candidate.Rejection = RejectNotCheaper
+	}
+	return candidate
+}
@@ -6,71 +6,90 @@ import (
 	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 var _ UpdatePlanner = &IndexUpdatePlanner{}
+var _ TableFilterer = &IndexUpdatePlanner{}
+var _ TypeCheckConfigurer = &IndexUpdatePlanner{}
 
 // IndexUpdatePlanner is a modification of the BasicUpdatePlanner that
 // uses indexes to speed up update and delete operations.
 // It dispatches each update statement to the corresponding index planner.
+// Unlike the BasicUpdatePlanner, it supports inserts with an ON CONFLICT clause.
 type IndexUpdatePlanner struct {
+	typeChecker
+	preparedInserts
 	metadataManager *metadata.Manager
+	tableFilters    *TableFilters
 }
 
 func NewIndexUpdatePlanner(metadataManager *metadata.Manager) UpdatePlanner {
 	return &IndexUpdatePlanner{metadataManager: metadataManager}
 }
 
+// SetTableFilters sets the filters that the records updated, deleted or inserted in each table must satisfy.
+func (up *IndexUpdatePlanner) SetTableFilters(filters *TableFilters) {
+	up.tableFilters = filters
+}
+
+// ExecuteInsert inserts the record, and an index record into each index the record belongs in.
+// The insert goes through the prepared insert of the transaction for the table, so that consecutive
+// inserts into the same table only plan the first one and open its indexes once.
+// An insert whose record has the same key as another record in a unique index fails, unless it has an
+// ON CONFLICT clause: it then leaves the existing record alone and returns 0 (DO NOTHING), or modifies it
+// and returns 1 (DO UPDATE), like an update statement would.
 func (up *IndexUpdatePlanner) ExecuteInsert(data *parse.InsertData, transaction *tx.Transaction) (int, error) {
 	tableName := data.TableName()
-	tablePlan, err := NewTablePlan(transaction, tableName, up.metadataManager)
-	if err != nil {
-		return 0, err
-	}
-
-	// first, insert the record.
-	tableScan, err := tablePlan.Open()
-	if err != nil {
-		return 0, err
-	}
-	updateScan, ok := tableScan.(scan.UpdateScan)
-	if !ok {
-		return 0, fmt.Errorf("table scan is not an update scan")
-	}
-	defer updateScan.Close()
-
-	if err := updateScan.Insert(); err != nil {
-		return 0, err
-	}
-	recordID := updateScan.GetRecordID()
-
-	// then modify each field, inserting an index record if appropriate.
-	indexes, err := up.metadataManager.GetIndexInfo(tableName, transaction)
-	if err != nil {
-		return 0, err
+	prepare := func() (*preparedInsert, error) {
+		indexes, err := up.metadataManager.GetIndexInfo(tableName, transaction)
+		if err != nil {
+			return nil, err
+		}
+		return prepareInsert(tableName, indexes, up.metadataManager, transaction)
 	}
-
-	vals := data.Values()
-	for i, field := range data.Fields() {
-		val := vals[i]
-		if err := updateScan.SetVal(field, val); err != nil {
-			return 0, err
+	count := 1
+	err := up.insert(tableName, transaction, prepare, func(prepared *preparedInsert) error {
+		// convert the values before writing anything, so that an invalid value fails the whole insert.
+		vals, err := coerceFieldValues(tableName, prepared.schema, data.Fields(), data.Values())
+		if err != nil {
+			return err
+		}
+		if err := up.tableFilters.checkInsert(data, prepared.schema, vals, transaction); err != nil {
+			return err
 		}
 
-		indexInfo, ok := indexes[field]
-		if !ok {
-			continue
+		values := make(map[string]any, len(vals))
+		for i, field := range data.Fields() {
+			values[field] = vals[i]
+		}
+		// lock the keys of the record before reading or writing the table, so that no other
+		// transaction writes them between the checks for conflicts and the write.
+		if err := prepared.lockUniqueKeys(values, transaction); err != nil {
+			return err
 		}
 
-		idx := indexInfo.Open()
-		if err := idx.Insert(val, recordID); err != nil {
-			return 0, err
+		if onConflict := data.OnConflict(); onConflict != nil {
+			conflictID, err := prepared.findConflict(tableName, onConflict.TargetField(), values)
+			if err != nil {
+				return err
+			}
+			if conflictID != nil {
+				if onConflict.Action() == parse.DoNothing {
+					count = 0
+					return nil
+				}
+				return up.updateConflict(tableName, prepared, conflictID, onConflict.Assignments(), transaction)
+			}
 		}
-		idx.Close()
+		return prepared.insertRecord(data.Fields(), vals, transaction)
+	})
+	if err != nil {
+		return 0, err
 	}
-
-	return 1, nil
+	return count, nil
 }
 
 func (up *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, transaction *tx.Transaction) (int, error) {
@@ -79,12 +98,25 @@ func (up *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, transaction
 	if err != nil {
 		return 0, err
 	}
-	selectPlan := NewSelectPlan(tablePlan, data.Predicate())
+	data.Predicate().CoerceConstants(tablePlan.Schema())
+	if err := up.checkTypes(data.Predicate(), tablePlan.Schema()); err != nil {
+		return 0, err
+	}
+	if _, err := up.tableFilters.conjoinFilter(tableName, tablePlan.Schema(), data.Predicate(), transaction); err != nil {
+		return 0, err
+	}
+	selectPlan, err := NewSelectPlan(tablePlan, data.Predicate())
+	if err != nil {
+		return 0, err
+	}
 	indexes, err := up.metadataManager.GetIndexInfo(tableName, transaction)
 	if err != nil {
 		return 0, err
 	}
 
+	openIndex, closeIndexes := indexOpener(indexes)
+	defer closeIndexes()
+
 	selectScan, err := selectPlan.Open()
 	if err != nil {
 		return 0, err
@@ -96,25 +128,15 @@ func (up *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, transaction
 	defer updateScan.Close()
 
 	count := 0
-	for {
+	for !limitReached(count, data.Limit()) {
 		hasNext, err := updateScan.Next()
 		if err != nil || !hasNext {
 			return count, err
 		}
 
-		// 1. delete the record's RecordID from each index.
-		recordID := updateScan.GetRecordID()
-		for fieldName, indexInfo := range indexes {
-			val, err := updateScan.GetVal(fieldName)
-			if err != nil {
-				return count, err
-			}
-			idx := indexInfo.Open()
-			if err := idx.Delete(val, recordID); err != nil {
-				idx.Close()
-				return count, err
-			}
-			idx.Close()
+		// 1. delete the record's RecordID from each index containing it.
+		if err := deleteIndexEntries(indexes, openIndex, updateScan); err != nil {
+			return count, err
 		}
 
 		// 2. delete the record.
@@ -123,8 +145,13 @@ func (up *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, transaction
 		}
 		count++
 	}
+	return count, nil
 }
 
+// ExecuteModify modifies the matching records and keeps every index on the table up to date.
+// Besides the index on the modified field, the modification can move a record into or out of
+// a partial index, in which case its index record is inserted or deleted.
+// A modification giving a record the key of another record in a unique index fails, leaving the record unchanged.
 func (up *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, transaction *tx.Transaction) (int, error) {
 	tableName := data.TableName()
 	fieldName := data.TargetField()
@@ -133,18 +160,26 @@ func (up *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, transaction
 	if err != nil {
 		return 0, err
 	}
-	selectPlan := NewSelectPlan(tablePlan, data.Predicate())
+	data.Predicate().CoerceConstants(tablePlan.Schema())
+	if err := up.checkTypes(data.Predicate(), tablePlan.Schema()); err != nil {
+		return 0, err
+	}
+	filterPredicate, err := up.tableFilters.conjoinFilter(tableName, tablePlan.Schema(), data.Predicate(), transaction)
+	if err != nil {
+		return 0, err
+	}
+	selectPlan, err := NewSelectPlan(tablePlan, data.Predicate())
+	if err != nil {
+		return 0, err
+	}
 
 	indexes, err := up.metadataManager.GetIndexInfo(tableName, transaction)
 	if err != nil {
 		return 0, err
 	}
 
-	var idx index.Index = nil
-	if indexInfo, ok := indexes[fieldName]; ok {
-		idx = indexInfo.Open()
-		defer idx.Close()
-	}
+	openIndex, closeIndexes := indexOpener(indexes)
+	defer closeIndexes()
 
 	selectScan, err := selectPlan.Open()
 	if err != nil {
@@ -156,10 +191,14 @@ func (up *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, transaction
 	}
 	defer updateScan.Close()
 
+	recordIDs, err := matchingRecordIDs(updateScan, data.Limit())
+	if err != nil {
+		return 0, err
+	}
+
 	count := 0
-	for {
-		hasNext, err := updateScan.Next()
-		if err != nil || !hasNext {
+	for _, recordID := range recordIDs {
+		if err := updateScan.MoveToRecordID(recordID); err != nil {
 			return count, err
 		}
 
@@ -167,42 +206,254 @@ func (up *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, transaction
 		if err != nil {
 			return count, err
 		}
+		if newValue, err = coerceFieldValue(tableName, tablePlan.Schema(), fieldName, newValue); err != nil {
+			return count, err
+		}
+		newValues := map[string]any{fieldName: newValue}
+		if err := checkFilter(tableName, filterPredicate, updateScan, newValues); err != nil {
+			return count, err
+		}
 
-		oldValue, err := updateScan.GetVal(fieldName)
+		// the new index entries are computed before the record is modified, so that a duplicate key leaves it unchanged.
+		oldEntries, err := indexEntries(indexes, updateScan)
 		if err != nil {
 			return count, err
 		}
+		newEntries, err := indexEntries(indexes, &rowScan{base: updateScan, values: newValues})
+		if err != nil {
+			return count, err
+		}
+		if err := checkUniqueEntries(transaction, indexes, openIndex, oldEntries, newEntries, recordID); err != nil {
+			return count, err
+		}
 
 		if err := updateScan.SetVal(fieldName, newValue); err != nil {
 			return count, err
 		}
 
-		// 1. delete the old value from the index.
-		if idx != nil {
-			recordID := updateScan.GetRecordID()
-			if err := idx.Delete(oldValue, recordID); err != nil {
-				return count, err
+		// replace the record's entry in each index whose entry changed.
+		if err := updateIndexEntries(indexes, openIndex, oldEntries, newEntries, recordID); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+	return count, nil
+}
+
+// indexEntry describes the index record of a data record in a single index.
+type indexEntry struct {
+	included bool
+	val      any
+}
+
+// equals returns true if the entries are the same index record. The search keys are compared with
+// types.CompareSupportedTypes, since composite keys cannot be compared with ==.
+func (e indexEntry) equals(other indexEntry) bool {
+	if e.included != other.included {
+		return false
+	}
+	return !e.included || types.CompareSupportedTypes(e.val, other.val, types.EQ)
+}
+
+// indexEntries returns, for each index, the index record of the scan's current record.
+// Records that do not belong in a partial index have no index record in it.
+func indexEntries(indexes []*metadata.IndexInfo, s scan.Scan) ([]indexEntry, error) {
+	entries := make([]indexEntry, len(indexes))
+	for i, indexInfo := range indexes {
+		included, err := indexInfo.Includes(s)
+		if err != nil {
+			return nil, err
+		}
+		if !included {
+			continue
+		}
+		val, err := indexInfo.Key(s)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = indexEntry{included: true, val: val}
+	}
+	return entries, nil
+}
+
+// indexOpener returns a function opening the index at the specified position of indexes the first time it is needed,
+// and a function closing the indexes it opened.
+func indexOpener(indexes []*metadata.IndexInfo) (func(i int) (index.Index, error), func()) {
+	openIndexes := make([]index.Index, len(indexes))
+	openIndex := func(i int) (index.Index, error) {
+		if openIndexes[i] == nil {
+			idx, err := indexes[i].Open()
+			if err != nil {
+				return nil, err
 			}
-			if err := idx.Insert(newValue, recordID); err != nil {
-				return count, err
+			openIndexes[i] = idx
+		}
+		return openIndexes[i], nil
+	}
+	closeIndexes := func() {
+		for _, idx := range openIndexes {
+			if idx != nil {
+				idx.Close()
 			}
 		}
+	}
+	return openIndex, closeIndexes
+}
 
-		count++
+// deleteIndexEntries deletes the index records of the scan's current record from each index containing it.
+// The indexes are opened with the specified function.
+func deleteIndexEntries(indexes []*metadata.IndexInfo, openIndex func(i int) (index.Index, error), s scan.UpdateScan) error {
+	entries, err := indexEntries(indexes, s)
+	if err != nil {
+		return err
+	}
+	recordID, err := s.GetRecordID()
+	if err != nil {
+		return err
 	}
+	return updateIndexEntries(indexes, openIndex, entries, make([]indexEntry, len(indexes)), recordID)
 }
 
+// ExecuteCreateTable creates the table. Like every DDL statement, it releases the prepared inserts of the transaction.
 func (up *IndexUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, transaction *tx.Transaction) (int, error) {
-	err := up.metadataManager.CreateTable(data.TableName(), data.NewSchema(), transaction)
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
+	options := metadata.TableOptions{Compressed: data.Compressed()}
+	err := up.metadataManager.CreateTableWithOptions(data.TableName(), data.NewSchema(), options, transaction)
 	return 0, err
 }
 
 func (up *IndexUpdatePlanner) ExecuteCreateView(data *parse.CreateViewData, transaction *tx.Transaction) (int, error) {
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
 	err := up.metadataManager.CreateView(data.ViewName(), data.ViewDefinition(), transaction)
 	return 0, err
 }
 
+// ExecuteCreateIndex creates the index and populates it with the records already in the table (see createIndex).
+// Like every DDL statement, it releases the prepared inserts of the transaction.
 func (up *IndexUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, transaction *tx.Transaction) (int, error) {
-	err := up.metadataManager.CreateIndex(data.IndexName(), data.TableName(), data.FieldName(), transaction)
-	return 0, err
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
+	return 0, createIndex(data, up.metadataManager, transaction)
+}
+
+// createIndex creates the index and populates it with the records
+// already in the table, so that queries reading through the index see them.
+// A partial index is only populated with the records satisfying its predicate.
+// The table is locked exclusively until the transaction completes: index creation
+// waits for transactions that are modifying the table, and new modifications wait
+// for the index to be created, so no record can be missed by the index.
+func createIndex(data *parse.CreateIndexData, metadataManager *metadata.Manager, transaction *tx.Transaction) error {
+	if err := table.LockTable(transaction, data.TableName()); err != nil {
+		return err
+	}
+	options := metadata.IndexOptions{Predicate: data.Predicate(), Unique: data.Unique(), Type: data.IndexType()}
+	if err := metadataManager.CreateCompositeIndex(data.IndexName(), data.TableName(), data.FieldNames(), options, transaction); err != nil {
+		return err
+	}
+
+	indexes, err := metadataManager.GetIndexInfo(data.TableName(), transaction)
+	if err != nil {
+		return err
+	}
+	var indexInfo *metadata.IndexInfo
+	for _, ii := range indexes {
+		if ii.IndexName() == data.IndexName() {
+			indexInfo = ii
+			break
+		}
+	}
+	if indexInfo == nil {
+		return fmt.Errorf("index %s not found after creation", data.IndexName())
+	}
+
+	return populateIndex(data.TableName(), indexInfo, metadataManager, transaction)
+}
+
+// populateIndex inserts an index record for every record currently in the table
+// that belongs in the index. It fails if two of the records have the same key in a unique index,
+// and the transaction should then be rolled back, since the index is left in the catalog.
+func populateIndex(tableName string, indexInfo *metadata.IndexInfo, metadataManager *metadata.Manager, transaction *tx.Transaction) error {
+	tablePlan, err := NewTablePlan(transaction, tableName, metadataManager)
+	if err != nil {
+		return err
+	}
+	tableScan, err := tablePlan.Open()
+	if err != nil {
+		return err
+	}
+	updateScan, ok := tableScan.(scan.UpdateScan)
+	if !ok {
+		return fmt.Errorf("table scan is not an update scan")
+	}
+	defer updateScan.Close()
+
+	idx, err := indexInfo.Open()
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+
+	for {
+		hasNext, err := updateScan.Next()
+		if err != nil {
+			return err
+		}
+		if !hasNext {
+			return nil
+		}
+		included, err := indexInfo.Includes(updateScan)
+		if err != nil {
+			return err
+		}
+		if !included {
+			continue
+		}
+
+		val, err := indexInfo.Key(updateScan)
+		if err != nil {
+			return err
+		}
+		if indexInfo.Unique() {
+			// the table is locked, so the keys do not need to be.
+			duplicateID, err := findKey(idx, val, nil)
+			if err != nil {
+				return err
+			}
+			if duplicateID != nil {
+				return fmt.Errorf("cannot create unique index %s: %w %v for field %s", indexInfo.IndexName(), ErrDuplicateKey, val, indexInfo.FieldName())
+			}
+		}
+		recordID, err := updateScan.GetRecordID()
+		if err != nil {
+			return err
+		}
+		if err := idx.Insert(val, recordID); err != nil {
+			return err
+		}
+	}
+}
+
+// ExecuteDropTable removes the table and its indexes from the catalog, and deletes their files once the
+// transaction commits (see metadata.Manager#DropTable). Like every DDL statement, it releases the prepared
+// inserts of the transaction, which may hold the table open.
+func (up *IndexUpdatePlanner) ExecuteDropTable(data *parse.DropTableData, transaction *tx.Transaction) (int, error) {
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
+	return 0, up.metadataManager.DropTable(data.TableName(), transaction)
+}
+
+// ExecuteDropIndex removes the index from the catalog, and deletes its files once the transaction commits
+// (see metadata.Manager#DropIndex). Like every DDL statement, it releases the prepared inserts of the transaction.
+func (up *IndexUpdatePlanner) ExecuteDropIndex(data *parse.DropIndexData, transaction *tx.Transaction) (int, error) {
+	if err := up.ReleaseTransaction(transaction); err != nil {
+		return 0, err
+	}
+	return 0, up.metadataManager.DropIndex(data.IndexName(), transaction)
 }
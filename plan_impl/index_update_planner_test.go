@@ -1,9 +1,13 @@
 package plan_impl
 
 import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/types"
 	"github.com/stretchr/testify/assert"
@@ -288,3 +292,437 @@ func TestIndexUpdatePlanner_MultipleIndices(t *testing.T) {
 	assert.Equal(t, "IT", rows[0]["department"])
 	assert.Equal(t, "IT", rows[1]["department"])
 }
+
+func TestIndexUpdatePlanner_CreateIndexConcurrentWithInserts(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 16)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	up := NewIndexUpdatePlanner(mdm)
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddIntField("val")
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := up.ExecuteCreateTable(parse.NewCreateTableData("nums", schema), txn)
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, err := up.ExecuteInsert(parse.NewInsertData("nums", []string{"id", "val"}, []any{i, i}), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	// Transaction A inserts rows while transaction B creates an index on the table.
+	const numRows = 100
+	firstInsertDone := make(chan struct{})
+	insertErr := make(chan error, 1)
+	go func() {
+		txA := tx.NewTransaction(fm, lm, bm, lt)
+		for i := 20; i < numRows; i++ {
+			if _, err := up.ExecuteInsert(parse.NewInsertData("nums", []string{"id", "val"}, []any{i, i}), txA); err != nil {
+				_ = txA.Rollback()
+				insertErr <- err
+				return
+			}
+			if i == 20 {
+				close(firstInsertDone)
+			}
+		}
+		insertErr <- txA.Commit()
+	}()
+
+	<-firstInsertDone
+	txB := tx.NewTransaction(fm, lm, bm, lt)
+	_, err = up.ExecuteCreateIndex(parse.NewCreateIndexData("idx_val", "nums", "val"), txB)
+	require.NoError(t, err)
+	require.NoError(t, txB.Commit())
+	require.NoError(t, <-insertErr)
+
+	// Every committed row must be findable through the new index.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	indexes, err := mdm.GetIndexInfo("nums", txn)
+	require.NoError(t, err)
+	require.NotNil(t, indexOnField(indexes, "val"))
+
+	idx, err := indexOnField(indexes, "val").Open()
+	require.NoError(t, err)
+	defer idx.Close()
+	for i := 0; i < numRows; i++ {
+		require.NoError(t, idx.BeforeFirst(i))
+		hasNext, err := idx.Next()
+		require.NoError(t, err)
+		assert.True(t, hasNext, "row with val %d missing from index", i)
+	}
+}
+
+// indexEntryIDs returns the ids of the records that the index on the specified field
+// lists under the search key, reading the id field of each listed record.
+func indexEntryIDs(t *testing.T, mdm *metadata.Manager, txn *tx.Transaction, tableName, fieldName string, searchKey any) []int {
+	t.Helper()
+	indexes, err := mdm.GetIndexInfo(tableName, txn)
+	require.NoError(t, err)
+	require.NotNil(t, indexOnField(indexes, fieldName))
+
+	tablePlan, err := NewTablePlan(txn, tableName, mdm)
+	require.NoError(t, err)
+	tableScan, err := tablePlan.Open()
+	require.NoError(t, err)
+	defer tableScan.Close()
+	updateScan := tableScan.(scan.UpdateScan)
+
+	idx, err := indexOnField(indexes, fieldName).Open()
+	require.NoError(t, err)
+	defer idx.Close()
+	require.NoError(t, idx.BeforeFirst(searchKey))
+
+	var ids []int
+	for {
+		hasNext, err := idx.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			return ids
+		}
+		recordID, err := idx.GetDataRecordID()
+		require.NoError(t, err)
+		require.NoError(t, updateScan.MoveToRecordID(recordID))
+		id, err := updateScan.GetInt("id")
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+}
+
+func TestIndexUpdatePlanner_PartialIndexMaintenance(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	execute := func(sql string) int {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		count, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+		require.NoError(t, txn.Commit())
+		return count
+	}
+	entries := func(owner string) []int {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		return indexEntryIDs(t, mdm, txn, "accounts", "owner", owner)
+	}
+
+	execute("CREATE TABLE accounts (id INT, owner VARCHAR(10), deleted BOOL)")
+	execute("INSERT INTO accounts (id, owner, deleted) VALUES (1, 'ann', false)")
+	execute("INSERT INTO accounts (id, owner, deleted) VALUES (2, 'ann', true)")
+
+	// Creating the index only adds the records satisfying its predicate.
+	execute("CREATE INDEX idx_live_owner ON accounts (owner) WHERE deleted = false")
+	assert.ElementsMatch(t, []int{1}, entries("ann"))
+
+	// Inserted records are indexed only if they satisfy the predicate.
+	execute("INSERT INTO accounts (id, owner, deleted) VALUES (3, 'bob', false)")
+	execute("INSERT INTO accounts (id, owner, deleted) VALUES (4, 'bob', true)")
+	assert.ElementsMatch(t, []int{3}, entries("bob"))
+
+	tests := []struct {
+		name     string
+		sql      string
+		expected map[string][]int
+	}{
+		{
+			name:     "into the predicate",
+			sql:      "UPDATE accounts SET deleted = false WHERE id = 2",
+			expected: map[string][]int{"ann": {1, 2}, "bob": {3}},
+		},
+		{
+			name:     "out of the predicate",
+			sql:      "UPDATE accounts SET deleted = true WHERE id = 1",
+			expected: map[string][]int{"ann": {2}, "bob": {3}},
+		},
+		{
+			name:     "indexed value changes inside the predicate",
+			sql:      "UPDATE accounts SET owner = 'cat' WHERE id = 3",
+			expected: map[string][]int{"ann": {2}, "bob": nil, "cat": {3}},
+		},
+		{
+			name:     "indexed value changes outside the predicate",
+			sql:      "UPDATE accounts SET owner = 'cat' WHERE id = 4",
+			expected: map[string][]int{"ann": {2}, "bob": nil, "cat": {3}},
+		},
+		{
+			name:     "unrelated field changes inside the predicate",
+			sql:      "UPDATE accounts SET id = 5 WHERE id = 2",
+			expected: map[string][]int{"ann": {5}, "cat": {3}},
+		},
+		{
+			name:     "delete outside the predicate",
+			sql:      "DELETE FROM accounts WHERE id = 4",
+			expected: map[string][]int{"ann": {5}, "cat": {3}},
+		},
+		{
+			name:     "delete inside the predicate",
+			sql:      "DELETE FROM accounts WHERE id = 3",
+			expected: map[string][]int{"ann": {5}, "cat": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, 1, execute(tt.sql))
+			for owner, ids := range tt.expected {
+				assert.ElementsMatch(t, ids, entries(owner), fmt.Sprintf("entries for %s", owner))
+			}
+		})
+	}
+
+	// Queries implying the predicate read through the index and see the same records as a table scan.
+	rows := runQuery(t, mdm, "select id from accounts where owner = 'ann' and deleted = false", fm, lm, bm, lt)
+	require.Len(t, rows, 1)
+	assert.Equal(t, 5, rows[0]["id"])
+}
+
+// indexedRecordIDs returns the record IDs of the table's records and those found through
+// the index on the specified field, looking up each of the specified keys.
+func indexedRecordIDs(t *testing.T, mdm *metadata.Manager, txn *tx.Transaction, tableName, fieldName string, keys []any) (tableIDs, indexIDs []string) {
+	t.Helper()
+
+	tablePlan, err := NewTablePlan(txn, tableName, mdm)
+	require.NoError(t, err)
+	s, err := tablePlan.Open()
+	require.NoError(t, err)
+	tableScan := s.(scan.UpdateScan)
+	defer tableScan.Close()
+	for {
+		hasNext, err := tableScan.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		rid, err := tableScan.GetRecordID()
+		require.NoError(t, err)
+		tableIDs = append(tableIDs, rid.String())
+	}
+
+	indexes, err := mdm.GetIndexInfo(tableName, txn)
+	require.NoError(t, err)
+	idx, err := indexOnField(indexes, fieldName).Open()
+	require.NoError(t, err)
+	defer idx.Close()
+	for _, key := range keys {
+		require.NoError(t, idx.BeforeFirst(key))
+		for {
+			hasNext, err := idx.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				break
+			}
+			rid, err := idx.GetDataRecordID()
+			require.NoError(t, err)
+			indexIDs = append(indexIDs, rid.String())
+		}
+	}
+	return tableIDs, indexIDs
+}
+
+func TestIndexUpdatePlanner_DeleteWithLimit(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 16)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	execute := func(sql string, txn *tx.Transaction) int {
+		count, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+		return count
+	}
+
+	// The records are inserted directly, since planning each insert refreshes the table statistics.
+	// The index is populated when it is created.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	execute("create table events (id int, kind int, processed bool)", txn)
+	layout, err := mdm.GetLayout("events", txn)
+	require.NoError(t, err)
+	tableScan, err := table.NewTableScan(txn, "events", layout)
+	require.NoError(t, err)
+	for i := 0; i < 5100; i++ {
+		// The last 100 events are not processed, and are not deleted.
+		require.NoError(t, tableScan.Insert())
+		require.NoError(t, tableScan.SetInt("id", i))
+		require.NoError(t, tableScan.SetInt("kind", i%10))
+		require.NoError(t, tableScan.SetBool("processed", i < 5000))
+	}
+	tableScan.Close()
+	execute("create index idx_kind on events (kind)", txn)
+	require.NoError(t, txn.Commit())
+
+	kinds := make([]any, 10)
+	for kind := range kinds {
+		kinds[kind] = kind
+	}
+
+	var counts []int
+	for {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		count := execute("delete from events where processed = true limit 1000", txn)
+		counts = append(counts, count)
+
+		// Each chunk removes the index records of exactly the deleted records.
+		tableIDs, indexIDs := indexedRecordIDs(t, mdm, txn, "events", "kind", kinds)
+		assert.Len(t, tableIDs, 5100-1000*(len(counts)-1)-count)
+		assert.ElementsMatch(t, tableIDs, indexIDs)
+		require.NoError(t, txn.Commit())
+
+		if count == 0 {
+			break
+		}
+	}
+	assert.Equal(t, []int{1000, 1000, 1000, 1000, 1000, 0}, counts)
+
+	rows := runQuery(t, mdm, "select id from events where processed = true", fm, lm, bm, lt)
+	assert.Empty(t, rows)
+	rows = runQuery(t, mdm, "select id from events where processed = false", fm, lm, bm, lt)
+	assert.Len(t, rows, 100)
+}
+
+func TestUpdatePlanners_ModifyWithLimit(t *testing.T) {
+	planners := map[string]func(*metadata.Manager) UpdatePlanner{
+		"basic": NewBasicUpdatePlanner,
+		"index": NewIndexUpdatePlanner,
+	}
+
+	for name, newUpdatePlanner := range planners {
+		t.Run(name, func(t *testing.T) {
+			fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+			mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+			p := NewPlanner(NewBasicQueryPlanner(mdm), newUpdatePlanner(mdm))
+
+			execute := func(sql string, txn *tx.Transaction) int {
+				count, err := p.ExecuteUpdate(sql, txn)
+				require.NoError(t, err, sql)
+				return count
+			}
+
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			execute("create table tasks (id int, status varchar(10))", txn)
+			execute("create index idx_status on tasks (status)", txn)
+			for i := 0; i < 20; i++ {
+				execute(fmt.Sprintf("insert into tasks (id, status) values (%d, 'queued')", i), txn)
+			}
+			require.NoError(t, txn.Commit())
+
+			txn = tx.NewTransaction(fm, lm, bm, lt)
+			assert.Equal(t, 7, execute("update tasks set status = 'running' where status = 'queued' limit 7", txn))
+			assert.Equal(t, 0, execute("update tasks set status = 'done' where id = 3 limit 0", txn))
+			require.NoError(t, txn.Commit())
+
+			assert.Len(t, runQuery(t, mdm, "select id from tasks where status = 'running'", fm, lm, bm, lt), 7)
+			assert.Len(t, runQuery(t, mdm, "select id from tasks where status = 'queued'", fm, lm, bm, lt), 13)
+			assert.Empty(t, runQuery(t, mdm, "select id from tasks where status = 'done'", fm, lm, bm, lt))
+		})
+	}
+}
+
+func TestUpdatePlanners_ModifyVisitsEachRecordOnce(t *testing.T) {
+	planners := map[string]func(*metadata.Manager) UpdatePlanner{
+		"basic": NewBasicUpdatePlanner,
+		"index": NewIndexUpdatePlanner,
+	}
+
+	for name, newUpdatePlanner := range planners {
+		t.Run(name, func(t *testing.T) {
+			fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+			mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+			p := NewPlanner(NewBasicQueryPlanner(mdm), newUpdatePlanner(mdm))
+
+			execute := func(sql string, txn *tx.Transaction) int {
+				count, err := p.ExecuteUpdate(sql, txn)
+				require.NoError(t, err, sql)
+				return count
+			}
+
+			// Each record holds its incremented value in a second field, since updates only assign values.
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			execute("create table t (id int, val int, incremented int)", txn)
+			execute("create index idx_val on t (val)", txn)
+			for i := 1; i <= 9; i++ {
+				execute(fmt.Sprintf("insert into t (id, val, incremented) values (%d, %d, %d)", i, i*10, i*10+10), txn)
+			}
+			require.NoError(t, txn.Commit())
+
+			// Most of the incremented values still satisfy the predicate, but each record is modified once.
+			txn = tx.NewTransaction(fm, lm, bm, lt)
+			assert.Equal(t, 9, execute("update t set val = incremented where val < 100", txn))
+			require.NoError(t, txn.Commit())
+
+			rows := runQuery(t, mdm, "select id, val from t", fm, lm, bm, lt)
+			require.Len(t, rows, 9)
+			for _, row := range rows {
+				assert.Equal(t, row["id"].(int)*10+10, row["val"], "record %v", row["id"])
+			}
+			rows = runQuery(t, mdm, "select id from t where val = 100", fm, lm, bm, lt)
+			require.Len(t, rows, 1)
+			assert.Equal(t, 9, rows[0]["id"])
+			assert.Empty(t, runQuery(t, mdm, "select id from t where val = 10", fm, lm, bm, lt))
+		})
+	}
+}
+
+// indexOnField returns the first of the indexes on the specified field, or nil if there is none.
+func indexOnField(indexes []*metadata.IndexInfo, fieldName string) *metadata.IndexInfo {
+	for _, indexInfo := range indexes {
+		if indexInfo.FieldName() == fieldName {
+			return indexInfo
+		}
+	}
+	return nil
+}
+
+func TestIndexUpdatePlanner_InsertReusesFreedSlot(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE items (id INT, tag VARCHAR(10))",
+		"CREATE INDEX idx_id ON items (id)",
+		"CREATE INDEX idx_tag ON items (tag) WHERE id > 5",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	layout, err := mdm.GetLayout("items", txn)
+	require.NoError(t, err)
+	slots := txn.BlockSize() / layout.SlotSize()
+	require.NoError(t, txn.Commit())
+
+	// The inserts of the transaction fill the first block, and the first three records are then deleted,
+	// so that the insert scan of the transaction finds no empty slot after its current record.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	insert := func(id int) {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO items (id, tag) VALUES (%d, 't%d')", id, id), txn)
+		require.NoError(t, err)
+	}
+	for id := 0; id < slots; id++ {
+		insert(id)
+	}
+	_, err = p.ExecuteUpdate("DELETE FROM items WHERE id < 3", txn)
+	require.NoError(t, err)
+	insert(100)
+	require.NoError(t, txn.Commit())
+
+	// The record was inserted into a freed slot rather than into another block, and no other record was moved.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	size, err := txn.Size("items.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, 1, size)
+	for id := 3; id < slots; id++ {
+		assert.Equal(t, []int{id}, indexEntryIDs(t, mdm, txn, "items", "id", id))
+		if id > 5 {
+			assert.Equal(t, []int{id}, indexEntryIDs(t, mdm, txn, "items", "tag", fmt.Sprintf("t%d", id)))
+		}
+	}
+	assert.Equal(t, []int{100}, indexEntryIDs(t, mdm, txn, "items", "id", 100))
+	assert.Empty(t, indexEntryIDs(t, mdm, txn, "items", "id", 0))
+	findings, err := p.ExecuteUpdate("CHECK TABLE items", txn)
+	require.NoError(t, err)
+	assert.Zero(t, findings)
+}
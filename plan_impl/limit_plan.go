@@ -0,0 +1,77 @@
+package plan_impl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+)
+
+var _ plan.Plan = &LimitPlan{}
+var _ NodePlan = &LimitPlan{}
+
+// LimitPlan is the plan of the LIMIT and OFFSET clauses of a query, which output at most limit records
+// of the input plan, after skipping the first offset of them.
+type LimitPlan struct {
+	inputPlan plan.Plan
+	limit     int
+	offset    int
+}
+
+// NewLimitPlan creates a plan outputting at most limit records of the specified plan, after skipping
+// the first offset of them. A limit of parse.NoLimit does not limit the number of records output.
+func NewLimitPlan(inputPlan plan.Plan, limit, offset int) *LimitPlan {
+	return &LimitPlan{inputPlan: inputPlan, limit: limit, offset: offset}
+}
+
+// Open opens the input plan, and wraps its scan in a limit scan.
+func (lp *LimitPlan) Open() (scan.Scan, error) {
+	inputScan, err := lp.inputPlan.Open()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewLimitScan(inputScan, lp.limit, lp.offset), nil
+}
+
+// BlocksAccessed returns the estimated number of block accesses of the input plan,
+// since the limit may only be reached after reading all of its records.
+func (lp *LimitPlan) BlocksAccessed() int {
+	return lp.inputPlan.BlocksAccessed()
+}
+
+// RecordsOutput returns the estimated number of records output by the input plan past the offset,
+// up to the limit.
+func (lp *LimitPlan) RecordsOutput() int {
+	records := max(0, lp.inputPlan.RecordsOutput()-lp.offset)
+	if lp.limit != parse.NoLimit {
+		records = min(records, lp.limit)
+	}
+	return records
+}
+
+// DistinctValues returns the estimated number of distinct values of the field in the input plan,
+// which the records output cannot exceed.
+func (lp *LimitPlan) DistinctValues(fieldName string) int {
+	return min(lp.inputPlan.DistinctValues(fieldName), lp.RecordsOutput())
+}
+
+// Schema returns the schema of the input plan.
+func (lp *LimitPlan) Schema() *record.Schema {
+	return lp.inputPlan.Schema()
+}
+
+// ToNode returns the description of the limit plan and its input.
+func (lp *LimitPlan) ToNode() *PlanNode {
+	node := newPlanNode("Limit", lp, lp.inputPlan)
+	if lp.limit != parse.NoLimit {
+		node.Detail = fmt.Sprintf("limit %d", lp.limit)
+	}
+	if lp.offset > 0 {
+		node.Detail = strings.TrimSpace(fmt.Sprintf("%s offset %d", node.Detail, lp.offset))
+	}
+	return node
+}
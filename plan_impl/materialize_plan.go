@@ -1,6 +1,7 @@
 package plan_impl
 
 import (
+	"errors"
 	"github.com/JyotinderSingh/dropdb/materialize"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/record"
@@ -9,6 +10,8 @@ import (
 	"math"
 )
 
+var _ NodePlan = &MaterializePlan{}
+
 // MaterializePlan represents the Plan for the materialize operator.
 type MaterializePlan struct {
 	srcPlan plan.Plan
@@ -24,7 +27,7 @@ func NewMaterializePlan(tx *tx.Transaction, srcPlan plan.Plan) *MaterializePlan
 }
 
 // Open loops through the underlying query, copying its output records into a temporary table.
-// It then returns a table scan for that table.
+// It then returns a table scan for that table, which removes the table when it is closed.
 func (mp *MaterializePlan) Open() (scan.Scan, error) {
 	schema := mp.srcPlan.Schema()
 	tempTable := materialize.NewTempTable(mp.tx, schema)
@@ -34,43 +37,69 @@ func (mp *MaterializePlan) Open() (scan.Scan, error) {
 	}
 	defer srcScan.Close()
 
+	destinationScan, err := tempTable.OpenRemovingOnClose()
+	if err != nil {
+		return nil, err
+	}
+	if err := mp.copyRecords(srcScan, destinationScan, schema); err != nil {
+		return nil, errors.Join(err, destinationScan.Close())
+	}
+	return destinationScan, nil
+}
+
+// materializeTable copies the output records of the underlying query into a new temporary table, which it returns
+// for the caller to read, and to remove once it is done (see materialize.TempTable#Remove).
+func (mp *MaterializePlan) materializeTable() (*materialize.TempTable, error) {
+	schema := mp.srcPlan.Schema()
+	tempTable := materialize.NewTempTable(mp.tx, schema)
+	srcScan, err := mp.srcPlan.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer srcScan.Close()
+
 	destinationScan, err := tempTable.Open()
 	if err != nil {
 		return nil, err
 	}
+	if err := errors.Join(mp.copyRecords(srcScan, destinationScan, schema), destinationScan.Close()); err != nil {
+		return nil, errors.Join(err, tempTable.Remove())
+	}
+	return tempTable, nil
+}
 
+// copyRecords copies the records of the source scan into the destination scan,
+// and positions the destination scan before its first record.
+func (mp *MaterializePlan) copyRecords(srcScan scan.Scan, destinationScan scan.UpdateScan, schema *record.Schema) error {
 	for {
 		hasNext, err := srcScan.Next()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if !hasNext {
 			break
 		}
 
 		if err := destinationScan.Insert(); err != nil {
-			return nil, err
+			return err
 		}
 		for _, fieldName := range schema.Fields() {
 			val, err := srcScan.GetVal(fieldName)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			if err := destinationScan.SetVal(fieldName, val); err != nil {
-				return nil, err
+				return err
 			}
 		}
 	}
 
-	if err := destinationScan.BeforeFirst(); err != nil {
-		return nil, err
-	}
-	return destinationScan, nil
+	return destinationScan.BeforeFirst()
 }
 
 // BlocksAccessed returns the estimated number of blocks in the materialized table.
 func (mp *MaterializePlan) BlocksAccessed() int {
-	// create a fake layout to calculate the record size
+	// create a fake layout to calculate the record size.
 	layout := record.NewLayout(mp.srcPlan.Schema())
 	recordLength := layout.SlotSize()
 	recordsPerBlock := float64(mp.tx.BlockSize()) / float64(recordLength)
@@ -91,3 +120,8 @@ func (mp *MaterializePlan) DistinctValues(fieldName string) int {
 func (mp *MaterializePlan) Schema() *record.Schema {
 	return mp.srcPlan.Schema()
 }
+
+// ToNode returns the description of the materialize plan and its input.
+func (mp *MaterializePlan) ToNode() *PlanNode {
+	return newPlanNode("Materialize", mp, mp.srcPlan)
+}
@@ -1,8 +1,17 @@
 package plan_impl
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -226,3 +235,94 @@ func TestMaterializePlan_MultipleFields(t *testing.T) {
 	}
 	assert.Equal(t, len(testData), count)
 }
+
+func TestMaterializePlan_RemovesTempTable(t *testing.T) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	txn := tx.NewTransaction(fm, lm, bm, concurrency.NewLockTable())
+
+	mdm := createTableMetadataWithSchema(t, txn, "events", map[string]interface{}{"id": 0})
+	tp, err := NewTablePlan(txn, "events", mdm)
+	require.NoError(t, err)
+	s, err := tp.Open()
+	require.NoError(t, err)
+	insertRecords(t, s.(scan.UpdateScan), []map[string]interface{}{{"id": 1}, {"id": 2}})
+	require.NoError(t, s.Close())
+
+	tempFiles := func() []string {
+		matches, err := filepath.Glob(filepath.Join(dbDir, "temp*"))
+		require.NoError(t, err)
+		return matches
+	}
+	matScan, err := NewMaterializePlan(txn, tp).Open()
+	require.NoError(t, err)
+	require.Len(t, tempFiles(), 1)
+	assert.True(t, strings.HasSuffix(tempFiles()[0], ".tbl"))
+	require.NoError(t, matScan.Close())
+	assert.Empty(t, tempFiles(), "the temporary table should be removed when its scan is closed")
+
+	_, err = NewMaterializePlan(txn, tp).Open()
+	require.NoError(t, err)
+	require.Len(t, tempFiles(), 1)
+	require.NoError(t, txn.Commit())
+	assert.Empty(t, tempFiles(), "the temporary table of a scan left open should be removed when the transaction commits")
+	_, err = os.Stat(filepath.Join(dbDir, "events.tbl"))
+	assert.NoError(t, err, "the tables the transaction read should be kept")
+}
+
+func TestMaterializePlan_LargeSortLeavesNoFiles(t *testing.T) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	txn := tx.NewTransaction(fm, lm, bm, concurrency.NewLockTable())
+	defer func() { require.NoError(t, txn.Commit()) }()
+
+	mdm := createTableMetadataWithSchema(t, txn, "readings", map[string]interface{}{"id": 0, "val": 0})
+	tp, err := NewTablePlan(txn, "readings", mdm)
+	require.NoError(t, err)
+	s, err := tp.Open()
+	require.NoError(t, err)
+	us := s.(scan.UpdateScan)
+	for i := 0; i < 2000; i++ {
+		require.NoError(t, us.Insert())
+		require.NoError(t, us.SetInt("id", i))
+		require.NoError(t, us.SetInt("val", (i*7)%2000))
+	}
+	require.NoError(t, s.Close())
+
+	fileCount := func() int {
+		entries, err := os.ReadDir(dbDir)
+		require.NoError(t, err)
+		return len(entries)
+	}
+	baseline := fileCount()
+
+	// The sort materializes its input, then splits it into many runs, which are merged several times.
+	tp, err = NewTablePlan(txn, "readings", mdm)
+	require.NoError(t, err)
+	sortPlan := NewSortPlan(txn, NewMaterializePlan(txn, tp), []string{"val"})
+	sortScan, err := sortPlan.Open()
+	require.NoError(t, err)
+	require.Greater(t, sortPlan.lastSort.mergePasses, 0)
+	count := 0
+	for {
+		hasNext, err := sortScan.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		count++
+	}
+	require.Equal(t, 2000, count)
+	assert.Greater(t, fileCount(), baseline, "the final runs are kept while the scan is open")
+
+	require.NoError(t, sortScan.Close())
+	assert.Equal(t, baseline, fileCount(), "every temporary file should be removed once the scan is closed")
+}
@@ -0,0 +1,112 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &MergeJoinPlan{}
+var _ NodePlan = &MergeJoinPlan{}
+
+// MergeJoinPlan is a plan for the merge join operator, which joins the records of two plans whose join fields
+// are equal by sorting both plans on their join field, and reading the sorted records side by side
+// (see query.MergeJoinScan).
+type MergeJoinPlan struct {
+	plan1      plan.Plan
+	plan2      plan.Plan
+	sortPlan1  *SortPlan
+	sortPlan2  *SortPlan
+	fieldName1 string
+	fieldName2 string
+	schema     *record.Schema
+}
+
+// NewMergeJoinPlan creates a merge join plan of the specified plans,
+// joining the records whose field of the first plan equals the field of the second plan.
+func NewMergeJoinPlan(transaction *tx.Transaction, plan1, plan2 plan.Plan, fieldName1, fieldName2 string) *MergeJoinPlan {
+	mjp := &MergeJoinPlan{
+		plan1:      plan1,
+		plan2:      plan2,
+		sortPlan1:  NewSortPlan(transaction, plan1, []string{fieldName1}),
+		sortPlan2:  NewSortPlan(transaction, plan2, []string{fieldName2}),
+		fieldName1: fieldName1,
+		fieldName2: fieldName2,
+		schema:     record.NewSchema(),
+	}
+
+	// Both sorted scans are read at once, so the runs left for each of them must leave buffers for the other.
+	mjp.sortPlan1.sharedSorts = 2
+	mjp.sortPlan2.sharedSorts = 2
+
+	mjp.schema.AddAll(plan1.Schema())
+	mjp.schema.AddAll(plan2.Schema())
+
+	return mjp
+}
+
+// Open sorts both plans on their join field, and opens a merge join scan of the sorted records.
+func (mjp *MergeJoinPlan) Open() (scan.Scan, error) {
+	s1, err := mjp.sortPlan1.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	s2, err := mjp.sortPlan2.Open()
+	if err != nil {
+		return nil, errors.Join(err, s1.Close())
+	}
+	sortScan, ok := s2.(*query.SortScan)
+	if !ok {
+		return nil, errors.Join(fmt.Errorf("second plan is not a sort scan"), s1.Close(), s2.Close())
+	}
+
+	mergeJoinScan, err := query.NewMergeJoinScan(s1, sortScan, mjp.fieldName1, mjp.fieldName2)
+	if err != nil {
+		return nil, errors.Join(err, s1.Close(), s2.Close())
+	}
+	return mergeJoinScan, nil
+}
+
+// BlocksAccessed estimates the number of block accesses to compute the join,
+// which is the cost of sorting each plan (see SortPlan#sortingCost), and one pass through each sorted plan.
+// The records of the second plan read again for the records of the first plan having the same join value
+// are assumed to be still in the buffer pool.
+func (mjp *MergeJoinPlan) BlocksAccessed() int {
+	return mjp.sortPlan1.sortingCost() + mjp.sortPlan1.BlocksAccessed() +
+		mjp.sortPlan2.sortingCost() + mjp.sortPlan2.BlocksAccessed()
+}
+
+// RecordsOutput estimates the number of records output by the join.
+// Assuming the join values of the plan having the fewer distinct values are among those of the other plan,
+// the formula is
+// rows(mergejoin(p1, p2)) = rows(p1) * rows(p2) / max(distinct(p1, field1), distinct(p2, field2))
+func (mjp *MergeJoinPlan) RecordsOutput() int {
+	maxValues := max(mjp.plan1.DistinctValues(mjp.fieldName1), mjp.plan2.DistinctValues(mjp.fieldName2), 1)
+	return mjp.plan1.RecordsOutput() * mjp.plan2.RecordsOutput() / maxValues
+}
+
+// DistinctValues estimates the number of distinct values for the specified field,
+// which is the same as in the plan it belongs to.
+func (mjp *MergeJoinPlan) DistinctValues(fieldName string) int {
+	if mjp.plan1.Schema().HasField(fieldName) {
+		return mjp.plan1.DistinctValues(fieldName)
+	}
+	return mjp.plan2.DistinctValues(fieldName)
+}
+
+// Schema returns the schema of the join, which is the union of the schemas of the plans.
+func (mjp *MergeJoinPlan) Schema() *record.Schema {
+	return mjp.schema
+}
+
+// ToNode returns the description of the merge join plan and its sorted inputs.
+func (mjp *MergeJoinPlan) ToNode() *PlanNode {
+	node := newPlanNode("MergeJoin", mjp, mjp.sortPlan1, mjp.sortPlan2)
+	node.Predicate = fmt.Sprintf("%s = %s", mjp.fieldName1, mjp.fieldName2)
+	return node
+}
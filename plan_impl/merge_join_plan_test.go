@@ -0,0 +1,95 @@
+package plan_impl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupOrdersAndShipments creates 600 orders and 300 shipments of 10 products, neither table having an index,
+// so that each product has many orders and many shipments.
+func setupOrdersAndShipments(t *testing.T) (*Planner, *tx.Transaction) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE orders (oid INT, oproduct INT)",
+		"CREATE TABLE shipments (sid INT, sproduct INT)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err)
+	}
+	for oid := 0; oid < 600; oid++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO orders (oid, oproduct) VALUES (%d, %d)", oid, (oid*7)%10), txn)
+		require.NoError(t, err)
+	}
+	for sid := 0; sid < 300; sid++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO shipments (sid, sproduct) VALUES (%d, %d)", sid, (sid*3)%10), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	t.Cleanup(func() { require.NoError(t, txn.Commit()) })
+	return p, txn
+}
+
+func TestMergeJoinPlan_ManyToMany(t *testing.T) {
+	p, txn := setupOrdersAndShipments(t)
+
+	sql := "SELECT oid, sid, oproduct, sproduct FROM orders, shipments WHERE oproduct = sproduct"
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err)
+	explained, err := p.Explain(sql, txn)
+	require.NoError(t, err)
+	assert.Contains(t, explained, "MergeJoin", "without an index, the equi-join should be a merge join")
+
+	// Each of the 60 orders of a product is joined with each of its 30 shipments, exactly once.
+	s, err := queryPlan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	pairs := make(map[[2]int]bool)
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		oid, err := s.GetInt("oid")
+		require.NoError(t, err)
+		sid, err := s.GetInt("sid")
+		require.NoError(t, err)
+		oproduct, err := s.GetInt("oproduct")
+		require.NoError(t, err)
+		sproduct, err := s.GetInt("sproduct")
+		require.NoError(t, err)
+		require.Equal(t, oproduct, sproduct)
+		require.False(t, pairs[[2]int{oid, sid}], "order %d and shipment %d are joined twice", oid, sid)
+		pairs[[2]int{oid, sid}] = true
+	}
+	assert.Len(t, pairs, 10*60*30)
+}
+
+func TestMergeJoinPlan_Estimates(t *testing.T) {
+	p, txn := setupOrdersAndShipments(t)
+
+	orders, err := NewTablePlan(txn, "orders", p.queryPlanner.(*BasicQueryPlanner).metadataManager)
+	require.NoError(t, err)
+	shipments, err := NewTablePlan(txn, "shipments", p.queryPlanner.(*BasicQueryPlanner).metadataManager)
+	require.NoError(t, err)
+	mergeJoin := NewMergeJoinPlan(txn, orders, shipments, "oproduct", "sproduct")
+
+	// Each input is read, written sorted, and the sorted records read once.
+	sortedOrders := NewSortPlan(txn, orders, []string{"oproduct"}).BlocksAccessed()
+	sortedShipments := NewSortPlan(txn, shipments, []string{"sproduct"}).BlocksAccessed()
+	assert.Equal(t, orders.BlocksAccessed()+2*sortedOrders+shipments.BlocksAccessed()+2*sortedShipments, mergeJoin.BlocksAccessed())
+	assert.Less(t, mergeJoin.BlocksAccessed(), orders.RecordsOutput()*shipments.BlocksAccessed())
+
+	assert.Equal(t, 600*300/10, mergeJoin.RecordsOutput())
+	assert.Equal(t, orders.DistinctValues("oid"), mergeJoin.DistinctValues("oid"))
+	assert.Equal(t, shipments.DistinctValues("sid"), mergeJoin.DistinctValues("sid"))
+	assert.Equal(t, "oproduct = sproduct", mergeJoin.ToNode().Predicate)
+}
@@ -0,0 +1,127 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &MultibufferProductPlan{}
+var _ NodePlan = &MultibufferProductPlan{}
+
+// MultibufferProductPlan is a plan for the multibuffer product operator, which materializes the smaller of its
+// inputs into a temporary table, and reads the other input once per chunk of the blocks of that table, each chunk
+// filling the buffers available when the plan is opened (see query.MultibufferProductScan).
+type MultibufferProductPlan struct {
+	transaction *tx.Transaction
+	plan1       plan.Plan
+	plan2       plan.Plan
+	schema      *record.Schema
+}
+
+// NewMultibufferProductPlan creates a multibuffer product plan of the specified plans.
+func NewMultibufferProductPlan(transaction *tx.Transaction, plan1, plan2 plan.Plan) *MultibufferProductPlan {
+	mpp := &MultibufferProductPlan{transaction: transaction, plan1: plan1, plan2: plan2, schema: record.NewSchema()}
+	mpp.schema.AddAll(plan1.Schema())
+	mpp.schema.AddAll(plan2.Schema())
+	return mpp
+}
+
+// inputs returns the materialization of the input read in chunks, which is the one whose materialization
+// has the fewest blocks, the second input if both have as many, and the other input. chunkFirst is true
+// if the input read in chunks is the first one.
+func (mpp *MultibufferProductPlan) inputs() (chunked *MaterializePlan, other plan.Plan, chunkFirst bool) {
+	materialized1 := NewMaterializePlan(mpp.transaction, mpp.plan1)
+	materialized2 := NewMaterializePlan(mpp.transaction, mpp.plan2)
+	if materialized1.BlocksAccessed() < materialized2.BlocksAccessed() {
+		return materialized1, mpp.plan2, true
+	}
+	return materialized2, mpp.plan1, false
+}
+
+// Open materializes the smaller input, and opens a multibuffer product scan of its temporary table,
+// whose chunks fill the buffers available once the other input is opened (see chunkBlocks).
+func (mpp *MultibufferProductPlan) Open() (scan.Scan, error) {
+	chunked, other, chunkFirst := mpp.inputs()
+	tempTable, err := chunked.materializeTable()
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := table.BlockCount(mpp.transaction, tempTable.TableName())
+	if err != nil {
+		return nil, errors.Join(err, tempTable.Remove())
+	}
+	otherScan, err := other.Open()
+	if err != nil {
+		return nil, errors.Join(err, tempTable.Remove())
+	}
+	productScan, err := query.NewMultibufferProductScan(mpp.transaction, tempTable, otherScan,
+		chunkBlocks(mpp.transaction.AvailableBuffers(), blocks), chunkFirst)
+	if err != nil {
+		return nil, errors.Join(err, otherScan.Close(), tempTable.Remove())
+	}
+	return productScan, nil
+}
+
+// chunkBlocks returns the number of blocks of each chunk of a temporary table of the specified number of blocks,
+// given the number of available buffers: the table is split into the fewest chunks that fit in the available
+// buffers but two, which are left for the other input, and the chunks have about the same number of blocks.
+func chunkBlocks(available, blocks int) int {
+	buffers := max(available-2, 1)
+	chunks := max((blocks+buffers-1)/buffers, 1)
+	return max((blocks+chunks-1)/chunks, 1)
+}
+
+// chunks returns the number of chunks the temporary table of the input read in chunks is estimated to have.
+func (mpp *MultibufferProductPlan) chunks() int {
+	chunked, _, _ := mpp.inputs()
+	blocks := chunked.BlocksAccessed()
+	size := chunkBlocks(mpp.transaction.AvailableBuffers(), blocks)
+	return (blocks + size - 1) / size
+}
+
+// BlocksAccessed estimates the number of block accesses of the product, which are those of executing the input
+// read in chunks once, writing its temporary table and reading it once, and executing the other input once per chunk.
+func (mpp *MultibufferProductPlan) BlocksAccessed() int {
+	chunked, other, _ := mpp.inputs()
+	return chunked.srcPlan.BlocksAccessed() + 2*chunked.BlocksAccessed() + mpp.chunks()*other.BlocksAccessed()
+}
+
+// RecordsOutput estimates the number of records in the product, like ProductPlan#RecordsOutput.
+func (mpp *MultibufferProductPlan) RecordsOutput() int {
+	return mpp.plan1.RecordsOutput() * mpp.plan2.RecordsOutput()
+}
+
+// DistinctValues estimates the number of distinct values for the specified field,
+// which is the same as in the plan it belongs to.
+func (mpp *MultibufferProductPlan) DistinctValues(fieldName string) int {
+	if mpp.plan1.Schema().HasField(fieldName) {
+		return mpp.plan1.DistinctValues(fieldName)
+	}
+	return mpp.plan2.DistinctValues(fieldName)
+}
+
+// Schema returns the schema of the product, which is the concatenation of the schemas of its inputs.
+func (mpp *MultibufferProductPlan) Schema() *record.Schema {
+	return mpp.schema
+}
+
+// ToNode returns the description of the multibuffer product and its inputs, in their order in the product,
+// the input read in chunks being described as materialized.
+func (mpp *MultibufferProductPlan) ToNode() *PlanNode {
+	chunked, other, chunkFirst := mpp.inputs()
+	var node *PlanNode
+	if chunkFirst {
+		node = newPlanNode("MultibufferProduct", mpp, chunked, other)
+	} else {
+		node = newPlanNode("MultibufferProduct", mpp, other, chunked)
+	}
+	node.Detail = fmt.Sprintf("%d chunks", mpp.chunks())
+	return node
+}
@@ -0,0 +1,88 @@
+package plan_impl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+func TestMultibufferProductPlan_ReadsFewerBlocks(t *testing.T) {
+	p, mdm, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	// Both tables fill more blocks than there are buffers, tb having half as many records as ta.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE TABLE ta (aid INT, apad VARCHAR(60))",
+		"CREATE TABLE tb (bid INT, bpad VARCHAR(60))",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	for table, count := range map[string]int{"ta (aid, apad)": 200, "tb (bid, bpad)": 100} {
+		var rows []string
+		for i := 0; i < count; i++ {
+			rows = append(rows, fmt.Sprintf("(%d, 'padding')", i))
+		}
+		_, err := p.ExecuteUpdate("INSERT INTO "+table+" VALUES "+strings.Join(rows, ", "), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	ta, err := NewTablePlan(txn, "ta", mdm)
+	require.NoError(t, err)
+	tb, err := NewTablePlan(txn, "tb", mdm)
+	require.NoError(t, err)
+	require.Greater(t, ta.BlocksAccessed(), 8)
+	require.Greater(t, tb.BlocksAccessed(), 8)
+
+	product, err := NewProductPlan(txn, ta, tb)
+	require.NoError(t, err)
+	multibufferProduct := NewMultibufferProductPlan(txn, ta, tb)
+	assert.Equal(t, product.Schema().Fields(), multibufferProduct.Schema().Fields())
+	assert.Less(t, multibufferProduct.BlocksAccessed()*4, product.BlocksAccessed(),
+		"%d blocks estimated for the multibuffer product, %d for the product", multibufferProduct.BlocksAccessed(), product.BlocksAccessed())
+
+	// Both plans output every pair of records, the multibuffer product reading far fewer blocks from disk.
+	before := fm.GetBlocksRead()
+	expected := readPlan(t, product, "aid", "bid")
+	productReads := fm.GetBlocksRead() - before
+	before = fm.GetBlocksRead()
+	actual := readPlan(t, multibufferProduct, "aid", "bid")
+	multibufferReads := fm.GetBlocksRead() - before
+
+	// The records are compared as sorted pairs, since matching 20,000 records element by element takes too long.
+	pairs := func(records []map[string]any) []string {
+		var pairs []string
+		for _, record := range records {
+			pairs = append(pairs, fmt.Sprintf("%v-%v", record["aid"], record["bid"]))
+		}
+		slices.Sort(pairs)
+		return pairs
+	}
+	assert.Len(t, actual, 200*100)
+	assert.Equal(t, pairs(expected), pairs(actual))
+	assert.Less(t, multibufferReads*4, productReads, "%d blocks read by the multibuffer product, %d by the product", multibufferReads, productReads)
+	assert.Zero(t, txn.PinnedCount())
+
+	// A query multiplying the tables is planned as a multibuffer product.
+	explanation, err := p.Explain("SELECT aid, bid FROM ta, tb WHERE aid < bid", txn)
+	require.NoError(t, err)
+	assert.Contains(t, explanation, "MultibufferProduct")
+}
+
+func TestChunkBlocks(t *testing.T) {
+	// The table is split into the fewest chunks fitting in the buffers but two, of about the same size.
+	assert.Equal(t, 5, chunkBlocks(8, 20))
+	assert.Equal(t, 5, chunkBlocks(8, 10))
+	assert.Equal(t, 4, chunkBlocks(8, 4))
+	assert.Equal(t, 1, chunkBlocks(2, 20))
+	assert.Equal(t, 1, chunkBlocks(8, 0))
+}
@@ -0,0 +1,180 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &ParallelTablePlan{}
+var _ NodePlan = &ParallelTablePlan{}
+
+// ParallelTablePlan is a plan for the GROUP BY operation over the records of a single table satisfying a predicate,
+// which splits the blocks of the table into contiguous ranges read in parallel (see query.ParallelAggregationScan).
+// It outputs the same groups as a HashAggregationPlan over the selection, in the same order.
+//
+// Each range is read by a worker goroutine in a read-only transaction of its own, created by the function
+// given to the plan, so that the workers pin their buffers and lock their blocks independently:
+//   - The workers do not see the changes of the transaction running the query that are not committed,
+//     and would wait for the locks it holds on the blocks it modified. The table is therefore read serially
+//     by a transaction that has modified it (see table.HoldsTableLock).
+//   - The number of blocks is read by the transaction running the query, which, unless it reads under
+//     tx.RelaxedPhantomProtection, keeps records from being appended to the table until it completes.
+//     The locks of a worker on the blocks it read are released once it is done, though, so other
+//     transactions may modify the records of the table before the query's transaction completes.
+//   - Each worker sees the records of its range as they are when it reads them, so the query may see
+//     the changes a transaction committed to some ranges but not to others that were read earlier.
+//
+// The groups are all held in memory: if they do not fit, the table is aggregated serially instead.
+type ParallelTablePlan struct {
+	transaction          *tx.Transaction
+	tablePlan            *TablePlan
+	inputPlan            *SelectPlan
+	predicate            *query.Predicate
+	groupFields          []string
+	aggregationFunctions []functions.AggregationFunction
+	workers              int
+	newTransaction       func() *tx.Transaction
+	serialPlan           *HashAggregationPlan
+}
+
+// NewParallelTablePlan creates a plan grouping the records of the table that satisfy the predicate with at most
+// the specified number of workers, each reading its range of blocks in a transaction returned by newTransaction.
+func NewParallelTablePlan(transaction *tx.Transaction, tablePlan *TablePlan, predicate *query.Predicate, groupFields []string, aggregationFunctions []functions.AggregationFunction, workers int, newTransaction func() *tx.Transaction) (*ParallelTablePlan, error) {
+	inputPlan, err := NewSelectPlan(tablePlan, predicate)
+	if err != nil {
+		return nil, err
+	}
+	serialPlan, err := NewHashAggregationPlan(transaction, inputPlan, groupFields, aggregationFunctions)
+	if err != nil {
+		return nil, err
+	}
+	return &ParallelTablePlan{
+		transaction:          transaction,
+		tablePlan:            tablePlan,
+		inputPlan:            inputPlan,
+		predicate:            predicate,
+		groupFields:          groupFields,
+		aggregationFunctions: aggregationFunctions,
+		workers:              workers,
+		newTransaction:       newTransaction,
+		serialPlan:           serialPlan,
+	}, nil
+}
+
+// Open splits the blocks of the table into one range per worker and aggregates the ranges in parallel.
+// The number of workers is limited by the number of blocks and the number of available buffers, since each
+// worker pins a buffer. The table is aggregated serially if a single worker would read it, if the transaction
+// running the query has modified it, or if the groups do not fit in memory.
+func (p *ParallelTablePlan) Open() (scan.Scan, error) {
+	blocks, err := table.BlockCount(p.transaction, p.tablePlan.tableName)
+	if err != nil {
+		return nil, err
+	}
+	workers := min(p.workers, blocks, p.transaction.AvailableBuffers())
+	if workers <= 1 || table.HoldsTableLock(p.transaction, p.tablePlan.tableName) {
+		return p.serialPlan.Open()
+	}
+
+	ranges := splitBlocks(blocks, workers)
+	inputs := make([]func() (scan.Scan, error), len(ranges))
+	for i, blockRange := range ranges {
+		inputs[i] = func() (scan.Scan, error) {
+			return p.openRange(blockRange[0], blockRange[1])
+		}
+	}
+
+	s, err := query.NewParallelAggregationScan(inputs, p.tablePlan.Schema().FieldInfos(), p.groupFields, p.aggregationFunctions, p.serialPlan.maxGroups())
+	if errors.Is(err, query.ErrTooManyGroups) {
+		return p.serialPlan.Open()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// openRange opens a scan of the records satisfying the predicate in the blocks of the table from firstBlock
+// up to but excluding endBlock, in a new transaction sharing the deadline of the query's transaction.
+// The transaction is committed when the scan is closed.
+func (p *ParallelTablePlan) openRange(firstBlock, endBlock int) (scan.Scan, error) {
+	transaction := p.newTransaction()
+	if deadline, ok := p.transaction.Deadline(); ok {
+		transaction.SetDeadline(deadline)
+	}
+
+	tableScan, err := table.NewBlockRangeScan(transaction, p.tablePlan.tableName, p.tablePlan.layout, firstBlock, endBlock)
+	if err != nil {
+		return nil, errors.Join(err, transaction.Rollback())
+	}
+	selectScan, err := query.NewSelectScan(tableScan, p.predicate)
+	if err != nil {
+		return nil, errors.Join(err, tableScan.Close(), transaction.Rollback())
+	}
+	return &workerScan{Scan: selectScan, transaction: transaction}, nil
+}
+
+// splitBlocks splits the specified number of blocks into the specified number of contiguous ranges,
+// each holding its first block and the block following its last one. The sizes of the ranges differ
+// by at most one block.
+func splitBlocks(blocks, ranges int) [][2]int {
+	result := make([][2]int, ranges)
+	first := 0
+	for i := range result {
+		size := blocks / ranges
+		if i < blocks%ranges {
+			size++
+		}
+		result[i] = [2]int{first, first + size}
+		first += size
+	}
+	return result
+}
+
+// BlocksAccessed returns the estimated number of block accesses required to compute the aggregation,
+// which is one pass through the table, split among the workers.
+func (p *ParallelTablePlan) BlocksAccessed() int {
+	return p.serialPlan.BlocksAccessed()
+}
+
+// RecordsOutput returns the estimated number of groups, like a HashAggregationPlan.
+func (p *ParallelTablePlan) RecordsOutput() int {
+	return p.serialPlan.RecordsOutput()
+}
+
+// DistinctValues returns the estimated number of distinct values of the specified field, like a HashAggregationPlan.
+func (p *ParallelTablePlan) DistinctValues(fieldName string) int {
+	return p.serialPlan.DistinctValues(fieldName)
+}
+
+// Schema returns the schema of the output table, which consists of the grouping fields and the aggregation fields.
+func (p *ParallelTablePlan) Schema() *record.Schema {
+	return p.serialPlan.Schema()
+}
+
+// ToNode returns the description of the parallel aggregation and of the selection each worker reads.
+func (p *ParallelTablePlan) ToNode() *PlanNode {
+	node := newPlanNode("ParallelAggregation", p, p.inputPlan)
+	node.Fields = p.groupFields
+	node.Aggregates = aggregateFields(p.aggregationFunctions)
+	node.Detail = fmt.Sprintf("up to %d workers", p.workers)
+	return node
+}
+
+// workerScan is the scan of a worker of a ParallelTablePlan, which commits its transaction when it is closed.
+type workerScan struct {
+	scan.Scan
+	transaction *tx.Transaction
+}
+
+// Close closes the scan and commits the transaction of the worker.
+func (s *workerScan) Close() error {
+	return errors.Join(s.Scan.Close(), s.transaction.Commit())
+}
@@ -0,0 +1,193 @@
+package plan_impl
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const parallelTestQuery = `select grp, count(amount), sum(amount), min(amount), max(amount), avg(amount), min(name), approx_count_distinct(amount)
+	from readings where amount > 100 group by grp`
+
+var parallelTestFields = []string{"grp", "countOfamount", "sumOfamount", "minOfamount", "maxOfamount", "avgOfamount", "minOfname", "approxCountDistinctOfamount"}
+
+// setupParallelTable creates a table of readings holding the specified number of records, spread over 37 groups.
+func setupParallelTable(t *testing.T, p *Planner, mdm *metadata.Manager, fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *concurrency.LockTable, records int) {
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("create table readings (id int, grp int, amount int, name varchar(8))", txn)
+	require.NoError(t, err)
+	layout, err := mdm.GetLayout("readings", txn)
+	require.NoError(t, err)
+
+	ts, err := table.NewTableScan(txn, "readings", layout)
+	require.NoError(t, err)
+	for i := 0; i < records; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+		require.NoError(t, ts.SetInt("grp", (i*7)%37))
+		require.NoError(t, ts.SetInt("amount", (i*13)%1000-200))
+		require.NoError(t, ts.SetString("name", fmt.Sprintf("n%d", (i*31)%5000)))
+	}
+	require.NoError(t, ts.Close())
+	require.NoError(t, txn.Commit())
+}
+
+// runParallelTestQuery runs the test query and returns its rows, in the order they are output.
+func runParallelTestQuery(t *testing.T, p *Planner, fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *concurrency.LockTable) []map[string]any {
+	return runPlannerQuery(t, p, parallelTestQuery, fm, lm, bm, lt, parallelTestFields)
+}
+
+func TestParallelTablePlan_MatchesSerialPlan(t *testing.T) {
+	p, mdm, fm, lm, bm, lt := setupPlannerTest(t, 4096, 32)
+	setupParallelTable(t, p, mdm, fm, lm, bm, lt, 100_000)
+
+	serial := runParallelTestQuery(t, p, fm, lm, bm, lt)
+	require.Len(t, serial, 37)
+
+	newWorkerTx := func() *tx.Transaction { return tx.NewReadOnlyTransaction(fm, lm, bm, lt) }
+	require.NoError(t, p.SetScanParallelism(4, newWorkerTx))
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	explained, err := p.Explain(parallelTestQuery, txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+	assert.Contains(t, explained, "ParallelAggregation")
+
+	// The groups are output in the same order, with the same aggregates.
+	assert.Equal(t, serial, runParallelTestQuery(t, p, fm, lm, bm, lt))
+
+	// A transaction that modified the table reads it serially, and sees its own changes.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.ExecuteUpdate("insert into readings (id, grp, amount, name) values (100000, 100, 500, 'new')", txn)
+	require.NoError(t, err)
+	queryPlan, err := p.CreateQueryPlan(parallelTestQuery, txn)
+	require.NoError(t, err)
+	s, err := queryPlan.Open()
+	require.NoError(t, err)
+	groups := 0
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		groups++
+	}
+	require.NoError(t, s.Close())
+	require.NoError(t, txn.Rollback())
+	assert.Equal(t, 38, groups)
+}
+
+func TestParallelTablePlan_SmallBufferPool(t *testing.T) {
+	p, mdm, fm, lm, bm, lt := setupPlannerTest(t, 800, 10)
+	setupParallelTable(t, p, mdm, fm, lm, bm, lt, 5_000)
+	expected := runParallelTestQuery(t, p, fm, lm, bm, lt)
+
+	bm.SetPrefetchDepth(2)
+	newWorkerTx := func() *tx.Transaction { return tx.NewReadOnlyTransaction(fm, lm, bm, lt) }
+	require.NoError(t, p.SetScanParallelism(8, newWorkerTx))
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("create table churn (id int, note varchar(20))", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	// Concurrent queries compete for the few buffers of the pool with each other's workers,
+	// and with a writer dirtying buffers of another table. A query finding too few buffers for its
+	// workers or its groups is aggregated serially, and may output the groups in another order.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			_, err := p.ExecuteUpdate(fmt.Sprintf("insert into churn (id, note) values (%d, 'note %d')", i, i), txn)
+			if !assert.NoError(t, err) {
+				assert.NoError(t, txn.Rollback())
+				return
+			}
+			assert.NoError(t, txn.Commit())
+		}
+	}()
+
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				rows, err := collectParallelTestQuery(p, fm, lm, bm, lt)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.ElementsMatch(t, expected, rows)
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	<-writerDone
+
+	assert.Equal(t, 0, bm.Stats().Pinned, "every worker must unpin its buffers")
+}
+
+// collectParallelTestQuery runs the test query like runParallelTestQuery, returning errors instead of
+// failing the test, so that it can be called from several goroutines.
+func collectParallelTestQuery(p *Planner, fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *concurrency.LockTable) ([]map[string]any, error) {
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	queryPlan, err := p.CreateQueryPlan(parallelTestQuery, txn)
+	if err != nil {
+		return nil, errorsJoinRollback(err, txn)
+	}
+	s, err := queryPlan.Open()
+	if err != nil {
+		return nil, errorsJoinRollback(err, txn)
+	}
+
+	var rows []map[string]any
+	for {
+		next, err := s.Next()
+		if err != nil {
+			s.Close()
+			return nil, errorsJoinRollback(err, txn)
+		}
+		if !next {
+			break
+		}
+		row := make(map[string]any)
+		for _, field := range parallelTestFields {
+			if row[field], err = s.GetVal(field); err != nil {
+				s.Close()
+				return nil, errorsJoinRollback(err, txn)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err := s.Close(); err != nil {
+		return nil, errorsJoinRollback(err, txn)
+	}
+	return rows, txn.Commit()
+}
+
+// errorsJoinRollback rolls back the transaction after the specified error.
+func errorsJoinRollback(err error, txn *tx.Transaction) error {
+	if rollbackErr := txn.Rollback(); rollbackErr != nil {
+		return fmt.Errorf("%w (rollback: %v)", err, rollbackErr)
+	}
+	return err
+}
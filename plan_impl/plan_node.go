@@ -0,0 +1,215 @@
+package plan_impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+)
+
+// PlanNode describes a node of a query plan: its operator, what it reads, the planner's estimates,
+// and the nodes of its input plans. It is produced by the ToNode method of every plan, and marshals
+// to JSON, so that the plan of a query can be recorded and compared with the plan of another process.
+type PlanNode struct {
+	// Type is the operator of the node, such as "TableScan" or "IndexSelect".
+	Type string `json:"type"`
+	// Table is the table read by the node, if it reads one.
+	Table string `json:"table,omitempty"`
+	// Index is the index read by the node, if it reads one.
+	Index string `json:"index,omitempty"`
+	// Predicate is the condition the records output by the node satisfy, such as the predicate of a select
+	// or the search key of an index.
+	Predicate string `json:"predicate,omitempty"`
+	// Fields are the fields the node projects, groups or sorts by.
+	Fields []string `json:"fields,omitempty"`
+	// Aggregates are the fields computed by the aggregation functions of the node.
+	Aggregates []string `json:"aggregates,omitempty"`
+	// Detail holds what else sets the operator apart, such as the block read by a block dump.
+	Detail    string        `json:"detail,omitempty"`
+	Estimates PlanEstimates `json:"estimates"`
+	Children  []*PlanNode   `json:"children,omitempty"`
+}
+
+// PlanEstimates are the estimates the planner made for a plan.
+type PlanEstimates struct {
+	BlocksAccessed int `json:"blocksAccessed"`
+	RecordsOutput  int `json:"recordsOutput"`
+}
+
+// NodePlan is implemented by the plans that describe themselves as a PlanNode.
+type NodePlan interface {
+	plan.Plan
+	// ToNode returns the description of the plan, including the descriptions of its input plans.
+	ToNode() *PlanNode
+}
+
+// planNode returns the description of the specified plan. A plan that does not describe itself
+// is described by its Go type and its estimates only.
+func planNode(p plan.Plan) *PlanNode {
+	if nodePlan, ok := p.(NodePlan); ok {
+		return nodePlan.ToNode()
+	}
+	return newPlanNode(fmt.Sprintf("%T", p), p)
+}
+
+// newPlanNode creates the description of a plan having the specified operator and input plans,
+// with the estimates of the plan.
+func newPlanNode(nodeType string, p plan.Plan, children ...plan.Plan) *PlanNode {
+	node := &PlanNode{
+		Type: nodeType,
+		Estimates: PlanEstimates{
+			BlocksAccessed: p.BlocksAccessed(),
+			RecordsOutput:  p.RecordsOutput(),
+		},
+	}
+	for _, child := range children {
+		node.Children = append(node.Children, planNode(child))
+	}
+	return node
+}
+
+// planTableName returns the name of the table read by the specified plan if it is a table plan,
+// or an empty string otherwise.
+func planTableName(p plan.Plan) string {
+	if tablePlan, ok := p.(*TablePlan); ok {
+		return tablePlan.tableName
+	}
+	return ""
+}
+
+// aggregateFields returns the names of the fields computed by the aggregation functions.
+func aggregateFields(aggregationFunctions []functions.AggregationFunction) []string {
+	fields := make([]string, len(aggregationFunctions))
+	for i, f := range aggregationFunctions {
+		fields[i] = f.FieldName()
+	}
+	return fields
+}
+
+// JSON returns the indented JSON encoding of the node and its children.
+func (n *PlanNode) JSON() (string, error) {
+	encoded, err := json.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("cannot encode plan: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// String returns the text description of the node and its children, one node per line,
+// each child being indented under its parent.
+func (n *PlanNode) String() string {
+	var sb strings.Builder
+	n.writeTo(&sb, 0)
+	return sb.String()
+}
+
+// writeTo writes the description of the node and its children at the specified depth.
+func (n *PlanNode) writeTo(sb *strings.Builder, depth int) {
+	if depth > 0 {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(n.Type)
+	if n.Table != "" {
+		fmt.Fprintf(sb, " table=%s", n.Table)
+	}
+	if n.Index != "" {
+		fmt.Fprintf(sb, " index=%s", n.Index)
+	}
+	if n.Predicate != "" {
+		fmt.Fprintf(sb, " predicate=(%s)", n.Predicate)
+	}
+	if len(n.Fields) > 0 {
+		fmt.Fprintf(sb, " fields=[%s]", strings.Join(n.Fields, ", "))
+	}
+	if len(n.Aggregates) > 0 {
+		fmt.Fprintf(sb, " aggregates=[%s]", strings.Join(n.Aggregates, ", "))
+	}
+	if n.Detail != "" {
+		fmt.Fprintf(sb, " %s", n.Detail)
+	}
+	fmt.Fprintf(sb, " (blocks=%d, records=%d)", n.Estimates.BlocksAccessed, n.Estimates.RecordsOutput)
+	for _, child := range n.Children {
+		child.writeTo(sb, depth+1)
+	}
+}
+
+// DiffPlanNodes compares two plan trees node by node, depth first, and describes the first difference,
+// naming the path of the node where the trees diverge. The operators of the trees and what they read are
+// compared first, so that a different access path is reported rather than the estimates of the nodes above it,
+// which it changes too; the estimates are only compared if the trees have the same shape.
+// It returns an empty string if the trees are identical.
+func DiffPlanNodes(first, second *PlanNode) string {
+	if diff := diffPlanNodes(first, second, nil, false); diff != "" {
+		return diff
+	}
+	return diffPlanNodes(first, second, nil, true)
+}
+
+// diffPlanNodes compares the nodes found at the specified path of two plan trees, and their children,
+// comparing either their estimates or their other attributes.
+func diffPlanNodes(first, second *PlanNode, path []string, compareEstimates bool) string {
+	if first == nil || second == nil {
+		if first == second {
+			return ""
+		}
+		missingFrom := "second"
+		if first == nil {
+			missingFrom = "first"
+		}
+		return fmt.Sprintf("%s: the node is missing from the %s plan", formatPlanPath(path), missingFrom)
+	}
+	if first.Type != second.Type {
+		return fmt.Sprintf("%s: type is %s in the first plan and %s in the second", formatPlanPath(path), first.Type, second.Type)
+	}
+
+	path = append(path, first.Type)
+	type attribute struct {
+		name          string
+		first, second string
+	}
+	attributes := []attribute{
+		{"table", first.Table, second.Table},
+		{"index", first.Index, second.Index},
+		{"predicate", first.Predicate, second.Predicate},
+		{"fields", strings.Join(first.Fields, ", "), strings.Join(second.Fields, ", ")},
+		{"aggregates", strings.Join(first.Aggregates, ", "), strings.Join(second.Aggregates, ", ")},
+		{"detail", first.Detail, second.Detail},
+	}
+	if compareEstimates {
+		attributes = []attribute{
+			{"blocks accessed", fmt.Sprint(first.Estimates.BlocksAccessed), fmt.Sprint(second.Estimates.BlocksAccessed)},
+			{"records output", fmt.Sprint(first.Estimates.RecordsOutput), fmt.Sprint(second.Estimates.RecordsOutput)},
+		}
+	}
+	for _, attribute := range attributes {
+		if attribute.first != attribute.second {
+			return fmt.Sprintf("%s: %s is %q in the first plan and %q in the second", formatPlanPath(path), attribute.name, attribute.first, attribute.second)
+		}
+	}
+
+	if len(first.Children) != len(second.Children) {
+		return fmt.Sprintf("%s: the node has %d inputs in the first plan and %d in the second", formatPlanPath(path), len(first.Children), len(second.Children))
+	}
+	for i := range first.Children {
+		childPath := slices.Clone(path)
+		if len(first.Children) > 1 {
+			childPath[len(childPath)-1] = fmt.Sprintf("%s[%d]", first.Type, i)
+		}
+		if diff := diffPlanNodes(first.Children[i], second.Children[i], childPath, compareEstimates); diff != "" {
+			return diff
+		}
+	}
+	return ""
+}
+
+// formatPlanPath returns the path of a plan node, as the operators leading to it from the root.
+func formatPlanPath(path []string) string {
+	if len(path) == 0 {
+		return "root"
+	}
+	return strings.Join(path, " > ")
+}
@@ -0,0 +1,223 @@
+package plan_impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// explainSQL joins the two tables of setupExplainTest, groups and orders the result.
+const explainSQL = "SELECT dname, count(eid) FROM emps, depts WHERE dept = did AND region = 'r1' GROUP BY dname ORDER BY dname"
+
+// explainGoldenJSON is the plan of explainSQL on the database of setupExplainTest with the index:
+// the departments of the region are read through the index, selected on the region below the join, and joined with the employees.
+const explainGoldenJSON = `{
+  "type": "Project",
+  "fields": [
+    "dname",
+    "countOfeid"
+  ],
+  "estimates": {
+    "blocksAccessed": 0,
+    "recordsOutput": 0
+  },
+  "children": [
+    {
+      "type": "Sort",
+      "fields": [
+        "dname"
+      ],
+      "estimates": {
+        "blocksAccessed": 0,
+        "recordsOutput": 0
+      },
+      "children": [
+        {
+          "type": "GroupBy",
+          "fields": [
+            "dname"
+          ],
+          "aggregates": [
+            "countOfeid"
+          ],
+          "estimates": {
+            "blocksAccessed": 16,
+            "recordsOutput": 0
+          },
+          "children": [
+            {
+              "type": "Sort",
+              "fields": [
+                "dname"
+              ],
+              "estimates": {
+                "blocksAccessed": 0,
+                "recordsOutput": 0
+              },
+              "children": [
+                {
+                  "type": "Select",
+                  "predicate": "dept = did",
+                  "estimates": {
+                    "blocksAccessed": 16,
+                    "recordsOutput": 0
+                  },
+                  "children": [
+                    {
+                      "type": "HashJoin",
+                      "predicate": "dept = did",
+                      "estimates": {
+                        "blocksAccessed": 16,
+                        "recordsOutput": 5
+                      },
+                      "children": [
+                        {
+                          "type": "TableScan",
+                          "table": "emps",
+                          "estimates": {
+                            "blocksAccessed": 10,
+                            "recordsOutput": 100
+                          }
+                        },
+                        {
+                          "type": "Select",
+                          "predicate": "region = r1",
+                          "estimates": {
+                            "blocksAccessed": 6,
+                            "recordsOutput": 5
+                          },
+                          "children": [
+                            {
+                              "type": "IndexSelect",
+                              "table": "depts",
+                              "index": "idx_region",
+                              "predicate": "region = r1",
+                              "estimates": {
+                                "blocksAccessed": 6,
+                                "recordsOutput": 5
+                              }
+                            }
+                          ]
+                        }
+                      ]
+                    }
+                  ]
+                }
+              ]
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`
+
+// setupExplainTest creates a database of employees and departments, with an index on the region of the departments
+// if withIndex is set. The tables are created in the specified order, so that the catalogs of two databases differ
+// while their contents do not.
+func setupExplainTest(t *testing.T, withIndex bool, tables ...string) (*Planner, *file.Manager, *log.Manager, *buffer.Manager, *concurrency.LockTable) {
+	fm, err := file.NewManager(t.TempDir(), 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	mdm, err := metadata.NewManager(true, txn)
+	require.NoError(t, err)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	statements := map[string][]string{
+		"emps":  {"CREATE TABLE emps (eid INT, ename VARCHAR(10), dept INT)"},
+		"depts": {"CREATE TABLE depts (did INT, dname VARCHAR(10), region VARCHAR(10))"},
+	}
+	if withIndex {
+		statements["depts"] = append(statements["depts"], "CREATE INDEX idx_region ON depts (region)")
+	}
+	for i := 0; i < 100; i++ {
+		statements["depts"] = append(statements["depts"], fmt.Sprintf("INSERT INTO depts (did, dname, region) VALUES (%d, 'd%d', 'r%d')", i, i, i%20))
+		statements["emps"] = append(statements["emps"], fmt.Sprintf("INSERT INTO emps (eid, ename, dept) VALUES (%d, 'e%d', %d)", i, i, i))
+	}
+	for _, table := range tables {
+		for _, sql := range statements[table] {
+			_, err := p.ExecuteUpdate(sql, txn)
+			require.NoError(t, err, sql)
+		}
+	}
+	require.NoError(t, txn.Commit())
+	return p, fm, lm, bm, lt
+}
+
+// explainJSON returns the JSON description of the plan of the query in a transaction of its own.
+func explainJSON(t *testing.T, p *Planner, sql string, fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *concurrency.LockTable) string {
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	encoded, err := p.ExplainJSON(sql, txn)
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestPlanner_ExplainJSON(t *testing.T) {
+	p, fm, lm, bm, lt := setupExplainTest(t, true, "emps", "depts")
+
+	encoded := explainJSON(t, p, explainSQL, fm, lm, bm, lt)
+	assert.Equal(t, explainGoldenJSON, encoded)
+
+	var node PlanNode
+	require.NoError(t, json.Unmarshal([]byte(encoded), &node))
+	assert.Equal(t, "Project", node.Type)
+
+	// The text description is made from the same nodes.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	text, err := p.Explain(explainSQL, txn)
+	require.NoError(t, err)
+	assert.Equal(t, node.String(), text)
+}
+
+func TestPlanner_ExplainJSONIsStable(t *testing.T) {
+	p, fm, lm, bm, lt := setupExplainTest(t, true, "emps", "depts")
+	encoded := explainJSON(t, p, explainSQL, fm, lm, bm, lt)
+	assert.Equal(t, encoded, explainJSON(t, p, explainSQL, fm, lm, bm, lt))
+
+	// A database with the same contents, whose tables were created in the other order, has the same plan.
+	p, fm, lm, bm, lt = setupExplainTest(t, true, "depts", "emps")
+	assert.Equal(t, encoded, explainJSON(t, p, explainSQL, fm, lm, bm, lt))
+}
+
+func TestDiffPlanNodes(t *testing.T) {
+	p, fm, lm, bm, lt := setupExplainTest(t, true, "emps", "depts")
+	var withIndex PlanNode
+	require.NoError(t, json.Unmarshal([]byte(explainJSON(t, p, explainSQL, fm, lm, bm, lt)), &withIndex))
+	assert.Empty(t, DiffPlanNodes(&withIndex, &withIndex))
+
+	// Without the index, all the departments are scanned instead, and selected on the region before they are hashed.
+	p, fm, lm, bm, lt = setupExplainTest(t, false, "emps", "depts")
+	var withoutIndex PlanNode
+	require.NoError(t, json.Unmarshal([]byte(explainJSON(t, p, explainSQL, fm, lm, bm, lt)), &withoutIndex))
+	assert.Equal(t, "Project > Sort > GroupBy > Sort > Select > HashJoin[1] > Select: type is IndexSelect in the first plan and TableScan in the second",
+		DiffPlanNodes(&withIndex, &withoutIndex))
+
+	changed := withIndex
+	changed.Fields = []string{"dname"}
+	assert.Equal(t, `Project: fields is "dname, countOfeid" in the first plan and "dname" in the second`, DiffPlanNodes(&withIndex, &changed))
+
+	changed = withIndex
+	changed.Estimates.RecordsOutput++
+	assert.Equal(t, `Project: records output is "0" in the first plan and "1" in the second`, DiffPlanNodes(&withIndex, &changed))
+
+	changed = withIndex
+	changed.Children = nil
+	assert.Equal(t, "Project: the node has 1 inputs in the first plan and 0 in the second", DiffPlanNodes(&withIndex, &changed))
+	assert.Equal(t, "root: the node is missing from the second plan", DiffPlanNodes(&withIndex, nil))
+}
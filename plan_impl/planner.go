@@ -1,27 +1,127 @@
 package plan_impl
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"time"
 )
 
 type Planner struct {
 	queryPlanner  QueryPlanner
 	updatePlanner UpdatePlanner
+	tableFilters  *TableFilters
+	restoreTx     func() *tx.Transaction
 }
 
 func NewPlanner(queryPlanner QueryPlanner, updatePlanner UpdatePlanner) *Planner {
+	tableFilters := NewTableFilters()
+	for _, planner := range []any{queryPlanner, updatePlanner} {
+		if filterer, ok := planner.(TableFilterer); ok {
+			filterer.SetTableFilters(tableFilters)
+		}
+	}
+
 	return &Planner{
 		queryPlanner:  queryPlanner,
 		updatePlanner: updatePlanner,
+		tableFilters:  tableFilters,
+	}
+}
+
+// RegisterTableFilter registers a filter that every statement reading or writing the specified table
+// is subject to, whatever its SQL: queries, updates and deletes only see the records of the table that
+// satisfy the filter's predicate, which is conjoined with their WHERE clause before they are planned,
+// and inserts and updates fail if the records they would write do not satisfy it.
+// The filter applies to the tables read through views too. Registering a filter for a table replaces
+// its previous filter. It fails if the query or update planner does not apply table filters.
+func (planner *Planner) RegisterTableFilter(tableName string, filter TableFilter) error {
+	for _, p := range []any{planner.queryPlanner, planner.updatePlanner} {
+		if _, ok := p.(TableFilterer); !ok {
+			return fmt.Errorf("planner %T does not apply table filters", p)
+		}
+	}
+	planner.tableFilters.Register(tableName, filter)
+	return nil
+}
+
+// SetTypeCheckMode sets how the query and update planners handle statements whose predicates
+// compare values of incompatible types. Types are checked strictly by default, rejecting such
+// statements; LenientTypeChecking restores the former behavior of planning them anyway.
+// The mode must be set before statements are planned.
+func (planner *Planner) SetTypeCheckMode(mode TypeCheckMode) {
+	for _, p := range []any{planner.queryPlanner, planner.updatePlanner} {
+		if configurer, ok := p.(TypeCheckConfigurer); ok {
+			configurer.SetTypeCheckMode(mode)
+		}
 	}
 }
 
+// SetStatsSource makes the database stats returned by the specified function readable by queries,
+// as the records of the StatsTable. It fails if the query planner cannot read them.
+func (planner *Planner) SetStatsSource(source func() DatabaseStats) error {
+	reader, ok := planner.queryPlanner.(StatsReader)
+	if !ok {
+		return fmt.Errorf("query planner %T does not read stats", planner.queryPlanner)
+	}
+	reader.SetStatsSource(source)
+	return nil
+}
+
+// SetScanParallelism makes queries grouping the records of a single table aggregate them with up to the
+// specified number of worker goroutines, each reading a range of the table's blocks in a read-only
+// transaction returned by newTransaction (see ParallelTablePlan for how this affects isolation).
+// A single worker, the default, reads tables serially. It fails if the query planner cannot read
+// tables in parallel.
+func (planner *Planner) SetScanParallelism(workers int, newTransaction func() *tx.Transaction) error {
+	scanner, ok := planner.queryPlanner.(ParallelScanner)
+	if !ok {
+		return fmt.Errorf("query planner %T does not read tables in parallel", planner.queryPlanner)
+	}
+	if workers < 1 {
+		return fmt.Errorf("scan parallelism must be at least 1, got %d", workers)
+	}
+	scanner.SetScanParallelism(workers, newTransaction)
+	return nil
+}
+
+// SetRestoreTransactions makes RESTORE statements restore each table in a transaction of its own, returned
+// by newTransaction and committed once the records and indexes of the table are restored, so that restoring
+// a large database does not hold the locks and log records of every table until the end. The views are
+// restored in a last transaction. With a nil function, the default, the whole script is restored in the
+// transaction executing the RESTORE statement.
+func (planner *Planner) SetRestoreTransactions(newTransaction func() *tx.Transaction) {
+	planner.restoreTx = newTransaction
+}
+
 // CreateQueryPlan creates a plan for a SQL select statement, using the supplied planner.
+// It also plans DUMP BLOCK, SHOW CONSTRAINTS and CHECK TABLE statements, whose output is read like the output of a query.
 func (planner *Planner) CreateQueryPlan(sql string, transaction *tx.Transaction) (plan.Plan, error) {
-	parser := parse.NewParser(sql)
+	return planner.CreateQueryPlanWithParameters(sql, nil, transaction)
+}
+
+// CreateQueryPlanWithParameters creates a plan for a SQL select statement like CreateQueryPlan,
+// replacing the parameters of the statement with the specified values.
+func (planner *Planner) CreateQueryPlanWithParameters(sql string, params *parse.Parameters, transaction *tx.Transaction) (plan.Plan, error) {
+	if recorder, ok := planner.queryPlanner.(StatementStatsRecorder); ok {
+		recorder.StartStatement(transaction)
+	}
+	parser := parse.NewParserWithParameters(sql, params)
+	if parser.IsDumpBlock() {
+		return planner.createDumpBlockPlan(parser, transaction)
+	}
+	if parser.IsShowConstraints() {
+		return planner.createShowConstraintsPlan(parser, transaction)
+	}
+	if parser.IsCheckTable() {
+		return planner.createCheckTablePlan(parser, transaction)
+	}
 	data, err := parser.Query()
 	if err != nil {
 		return nil, err
@@ -32,23 +132,169 @@ func (planner *Planner) CreateQueryPlan(sql string, transaction *tx.Transaction)
 	return planner.queryPlanner.CreatePlan(data, transaction)
 }
 
+// CreateQueryPlanWithDeadline creates a plan for a SQL select statement like CreateQueryPlanWithParameters,
+// which must be done by the specified deadline: planning the statement, opening its scan, and reading its
+// records all fail with tx.ErrQueryTimeout once the deadline has passed. The deadline is set on the
+// transaction, where it stays until the caller clears it once the results are read, or the transaction ends.
+func (planner *Planner) CreateQueryPlanWithDeadline(sql string, params *parse.Parameters, transaction *tx.Transaction, deadline time.Time) (plan.Plan, error) {
+	transaction.SetDeadline(deadline)
+	p, err := planner.CreateQueryPlanWithParameters(sql, params, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if err := transaction.CheckDeadline(); err != nil {
+		return nil, err
+	}
+	return NewDeadlinePlan(p, transaction), nil
+}
+
+// createDumpBlockPlan parses a DUMP BLOCK statement and plans it, if the query planner supports it.
+func (planner *Planner) createDumpBlockPlan(parser *parse.Parser, transaction *tx.Transaction) (plan.Plan, error) {
+	dumper, ok := planner.queryPlanner.(BlockDumper)
+	if !ok {
+		return nil, fmt.Errorf("query planner %T does not dump blocks", planner.queryPlanner)
+	}
+
+	data, err := parser.DumpBlock()
+	if err != nil {
+		return nil, err
+	}
+	return dumper.CreateDumpBlockPlan(data, transaction)
+}
+
+// createShowConstraintsPlan parses a SHOW CONSTRAINTS statement and plans it, if the query planner supports it.
+func (planner *Planner) createShowConstraintsPlan(parser *parse.Parser, transaction *tx.Transaction) (plan.Plan, error) {
+	lister, ok := planner.queryPlanner.(ConstraintLister)
+	if !ok {
+		return nil, fmt.Errorf("query planner %T does not list constraints", planner.queryPlanner)
+	}
+
+	data, err := parser.ShowConstraints()
+	if err != nil {
+		return nil, err
+	}
+	return lister.CreateShowConstraintsPlan(data, transaction)
+}
+
+// createCheckTablePlan parses a CHECK TABLE statement and plans it, if the query planner supports it.
+func (planner *Planner) createCheckTablePlan(parser *parse.Parser, transaction *tx.Transaction) (plan.Plan, error) {
+	checker, ok := planner.queryPlanner.(TableChecker)
+	if !ok {
+		return nil, fmt.Errorf("query planner %T does not check tables", planner.queryPlanner)
+	}
+
+	data, err := parser.CheckTable()
+	if err != nil {
+		return nil, err
+	}
+	return checker.CreateCheckTablePlan(data, transaction)
+}
+
+// StatementStats returns the stats of the last query planned by the transaction with CreateQueryPlan: the access
+// path chosen for each table it reads, with the reasons the other indexes on the table were rejected.
+// It fails if the transaction planned no query, or if the query planner does not record how it plans queries.
+func (planner *Planner) StatementStats(transaction *tx.Transaction) (*StatementStats, error) {
+	recorder, ok := planner.queryPlanner.(StatementStatsRecorder)
+	if !ok {
+		return nil, fmt.Errorf("query planner %T does not record statement stats", planner.queryPlanner)
+	}
+	stats, ok := recorder.StatementStats(transaction)
+	if !ok {
+		return nil, errors.New("no query was planned by the transaction")
+	}
+	return stats, nil
+}
+
+// IndexCandidates parses a SQL select statement and reports, for each table it reads,
+// the indexes the query planner considered, their estimated costs, why each rejected
+// index was not used, and the access path that was chosen.
+func (planner *Planner) IndexCandidates(sql string, transaction *tx.Transaction) ([]*AccessPath, error) {
+	advisor, ok := planner.queryPlanner.(IndexAdvisor)
+	if !ok {
+		return nil, fmt.Errorf("query planner %T does not report index candidates", planner.queryPlanner)
+	}
+
+	parser := parse.NewParser(sql)
+	data, err := parser.Query()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyQuery(data); err != nil {
+		return nil, err
+	}
+	return advisor.IndexCandidates(data, transaction)
+}
+
+// Explain plans a SQL select statement like CreateQueryPlan, and returns the text description of its plan,
+// one operator per line, each input being indented under the operator reading it.
+func (planner *Planner) Explain(sql string, transaction *tx.Transaction) (string, error) {
+	node, err := planner.explain(sql, transaction)
+	if err != nil {
+		return "", err
+	}
+	return node.String(), nil
+}
+
+// ExplainJSON plans a SQL select statement like CreateQueryPlan, and returns the JSON encoding of the
+// description of its plan (see PlanNode). Unlike the text of Explain, it can be decoded and compared
+// with the plans recorded by other processes, such as with DiffPlanNodes.
+func (planner *Planner) ExplainJSON(sql string, transaction *tx.Transaction) (string, error) {
+	node, err := planner.explain(sql, transaction)
+	if err != nil {
+		return "", err
+	}
+	return node.JSON()
+}
+
+// explain plans a SQL select statement and returns the description of its plan.
+func (planner *Planner) explain(sql string, transaction *tx.Transaction) (*PlanNode, error) {
+	p, err := planner.CreateQueryPlan(sql, transaction)
+	if err != nil {
+		return nil, err
+	}
+	return planNode(p), nil
+}
+
 // ExecuteUpdate executes a SQL insert, delete, modify, or create statement.
 // The method dispatches to the appropriate method of the supplied update planner,
 // depending on what the parser returns.
 func (planner *Planner) ExecuteUpdate(sql string, transaction *tx.Transaction) (int, error) {
-	parser := parse.NewParser(sql)
+	return planner.ExecuteUpdateWithParameters(sql, nil, transaction)
+}
+
+// ExecuteUpdateWithParameters executes a SQL statement like ExecuteUpdate,
+// replacing the parameters of the statement with the specified values.
+// It also executes DUMP DATABASE and RESTORE statements, whose affected count is the number of
+// records they dumped or restored, and CHECK TABLE statements, whose affected count is the number
+// of inconsistencies found; their findings are read by planning the statement as a query.
+func (planner *Planner) ExecuteUpdateWithParameters(sql string, params *parse.Parameters, transaction *tx.Transaction) (int, error) {
+	parser := parse.NewParserWithParameters(sql, params)
 	data, err := parser.UpdateCmd()
 	if err != nil {
 		return 0, err
 	}
 
+	switch data := data.(type) {
+	case *parse.DumpDatabaseData:
+		return planner.dumpDatabase(data, transaction)
+	case *parse.RestoreData:
+		return planner.restore(data, transaction)
+	case *parse.CheckTableData:
+		return planner.checkTable(data, transaction)
+	default:
+		return planner.executeUpdate(data, transaction)
+	}
+}
+
+// executeUpdate executes the parsed insert, delete, modify, create, or drop statement.
+func (planner *Planner) executeUpdate(data any, transaction *tx.Transaction) (int, error) {
 	if err := verifyUpdate(data); err != nil {
 		return 0, err
 	}
 
 	switch data.(type) {
 	case *parse.InsertData:
-		return planner.updatePlanner.ExecuteInsert(data.(*parse.InsertData), transaction)
+		return planner.executeInsert(data.(*parse.InsertData), transaction)
 	case *parse.DeleteData:
 		return planner.updatePlanner.ExecuteDelete(data.(*parse.DeleteData), transaction)
 	case *parse.ModifyData:
@@ -59,17 +305,226 @@ func (planner *Planner) ExecuteUpdate(sql string, transaction *tx.Transaction) (
 		return planner.updatePlanner.ExecuteCreateView(data.(*parse.CreateViewData), transaction)
 	case *parse.CreateIndexData:
 		return planner.updatePlanner.ExecuteCreateIndex(data.(*parse.CreateIndexData), transaction)
+	case *parse.DropTableData:
+		return planner.updatePlanner.ExecuteDropTable(data.(*parse.DropTableData), transaction)
+	case *parse.DropIndexData:
+		return planner.updatePlanner.ExecuteDropIndex(data.(*parse.DropIndexData), transaction)
 	default:
 		return 0, fmt.Errorf("unexpected type %T", data)
 	}
 }
 
+// executeInsert executes an insert statement. The rows of an insert of several records are read from a ConstantPlan,
+// which checks that the values of each field have the same type, and inserted one at a time, in order. The affected
+// count is the sum of the counts of the inserts of the records.
+func (planner *Planner) executeInsert(data *parse.InsertData, transaction *tx.Transaction) (int, error) {
+	rows := data.Rows()
+	if len(rows) == 1 {
+		return planner.updatePlanner.ExecuteInsert(data, transaction)
+	}
+
+	columns := make([]string, len(data.Fields()))
+	for i := range columns {
+		columns[i] = parse.ValuesColumn(i)
+	}
+	valuesPlan, err := NewConstantPlan(columns, rows)
+	if err != nil {
+		return 0, err
+	}
+	s, err := valuesPlan.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	count := 0
+	for row := 1; ; row++ {
+		next, err := s.Next()
+		if err != nil || !next {
+			return count, err
+		}
+		values := make([]any, len(columns))
+		for i, column := range columns {
+			if values[i], err = s.GetVal(column); err != nil {
+				return count, err
+			}
+		}
+		inserted, err := planner.updatePlanner.ExecuteInsert(data.RowData(values), transaction)
+		count += inserted
+		if err != nil {
+			return count, fmt.Errorf("row %d: %w", row, err)
+		}
+	}
+}
+
+// dumpDatabase executes a DUMP DATABASE statement, writing the script recreating the database to its file
+// (see DumpDatabase), which is replaced if it exists. It returns the number of records dumped.
+func (planner *Planner) dumpDatabase(data *parse.DumpDatabaseData, transaction *tx.Transaction) (int, error) {
+	dumper, ok := planner.queryPlanner.(DatabaseDumper)
+	if !ok {
+		return 0, fmt.Errorf("query planner %T does not dump databases", planner.queryPlanner)
+	}
+
+	f, err := os.Create(data.FileName())
+	if err != nil {
+		return 0, err
+	}
+	count, err := dumper.DumpDatabase(f, transaction)
+	if err := errors.Join(err, f.Close()); err != nil {
+		return 0, fmt.Errorf("dump database to %s: %w", data.FileName(), err)
+	}
+	return count, nil
+}
+
+// checkTable executes a CHECK TABLE statement, returning the number of inconsistencies found in the table.
+func (planner *Planner) checkTable(data *parse.CheckTableData, transaction *tx.Transaction) (int, error) {
+	checker, ok := planner.queryPlanner.(TableChecker)
+	if !ok {
+		return 0, fmt.Errorf("query planner %T does not check tables", planner.queryPlanner)
+	}
+
+	p, err := checker.CreateCheckTablePlan(data, transaction)
+	if err != nil {
+		return 0, err
+	}
+	s, err := p.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	count := 0
+	for {
+		next, err := s.Next()
+		if err != nil || !next {
+			return count, err
+		}
+		count++
+	}
+}
+
+// restore executes a RESTORE statement, executing the statements of its script one at a time, such as the
+// script written by a DUMP DATABASE statement. The script may not hold DUMP DATABASE or RESTORE statements.
+// The statements are executed in the transaction executing the RESTORE statement, or, if restore transactions
+// are set (see SetRestoreTransactions), in a new transaction for each table, started by its CREATE TABLE
+// statement, and for the views, started by the first CREATE VIEW statement.
+//
+// It returns the sum of the affected counts of the statements. If a statement fails, the error tells which,
+// and the restore transaction executing it is rolled back; the tables restored by the transactions committed
+// before it are kept.
+func (planner *Planner) restore(data *parse.RestoreData, transaction *tx.Transaction) (int, error) {
+	f, err := os.Open(data.FileName())
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	current := transaction
+	// endCurrent ends the current restore transaction, if it is not the transaction executing the statement.
+	endCurrent := func(commit bool) error {
+		if current == transaction {
+			return nil
+		}
+		if commit {
+			return current.Commit()
+		}
+		return current.Rollback()
+	}
+
+	reader := parse.NewScriptReader(f)
+	count := 0
+	restoringViews := false
+	for statement := 1; ; statement++ {
+		sql, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, errors.Join(fmt.Errorf("restore from %s: %w", data.FileName(), err), endCurrent(false))
+		}
+
+		update, err := parse.NewParser(sql).UpdateCmd()
+		if err == nil {
+			switch update.(type) {
+			case *parse.DumpDatabaseData, *parse.RestoreData:
+				err = errors.New("a script cannot dump or restore a database")
+			case *parse.CreateTableData, *parse.CreateViewData:
+				_, isView := update.(*parse.CreateViewData)
+				if planner.restoreTx != nil && !(isView && restoringViews) {
+					err = endCurrent(true)
+					current = transaction
+					if err == nil {
+						current = planner.newRestoreTransaction(transaction)
+					}
+					restoringViews = isView
+				}
+			}
+		}
+		if err == nil {
+			var affected int
+			affected, err = planner.executeUpdate(update, current)
+			count += affected
+		}
+		if err != nil {
+			return 0, errors.Join(fmt.Errorf("restore from %s: statement %d: %w", data.FileName(), statement, err), endCurrent(false))
+		}
+	}
+	if err := endCurrent(true); err != nil {
+		return 0, fmt.Errorf("restore from %s: %w", data.FileName(), err)
+	}
+	return count, nil
+}
+
+// newRestoreTransaction starts a restore transaction, sharing the deadline of the transaction executing the RESTORE statement.
+func (planner *Planner) newRestoreTransaction(transaction *tx.Transaction) *tx.Transaction {
+	restoreTransaction := planner.restoreTx()
+	if deadline, ok := transaction.Deadline(); ok {
+		restoreTransaction.SetDeadline(deadline)
+	}
+	return restoreTransaction
+}
+
+// ExecuteUpdateWithDeadline executes a SQL statement like ExecuteUpdateWithParameters, failing with
+// tx.ErrQueryTimeout if it is not done by the specified deadline. The statement may have modified
+// the database when it is aborted, so the caller should roll the transaction back.
+func (planner *Planner) ExecuteUpdateWithDeadline(sql string, params *parse.Parameters, transaction *tx.Transaction, deadline time.Time) (int, error) {
+	transaction.SetDeadline(deadline)
+	defer transaction.ClearDeadline()
+
+	count, err := planner.ExecuteUpdateWithParameters(sql, params, transaction)
+	if err != nil {
+		return 0, err
+	}
+	if err := transaction.CheckDeadline(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func verifyQuery(data *parse.QueryData) error {
 	// TODO: Implement this
 	return nil
 }
 
+// verifyUpdate returns an error wrapping metadata.ErrReadOnlyTable if the statement would change a catalog listing,
+// or create a table or view named after one.
 func verifyUpdate(data any) error {
-	// TODO: Implement this
-	return nil
+	switch data := data.(type) {
+	case *parse.InsertData:
+		return metadata.CheckWritable(data.TableName())
+	case *parse.DeleteData:
+		return metadata.CheckWritable(data.TableName())
+	case *parse.ModifyData:
+		return metadata.CheckWritable(data.TableName())
+	case *parse.CreateTableData:
+		return metadata.CheckWritable(data.TableName())
+	case *parse.CreateViewData:
+		return metadata.CheckWritable(data.ViewName())
+	case *parse.CreateIndexData:
+		return metadata.CheckWritable(data.TableName())
+	case *parse.DropTableData:
+		return metadata.CheckWritable(data.TableName())
+	default:
+		return nil
+	}
 }
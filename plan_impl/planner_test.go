@@ -2,6 +2,8 @@ package plan_impl
 
 import (
 	"fmt"
+	"path/filepath"
+	"sort"
 	"testing"
 	"time"
 
@@ -10,10 +12,14 @@ import (
 
 	"github.com/JyotinderSingh/dropdb/buffer"
 	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/index"
 	"github.com/JyotinderSingh/dropdb/log"
 	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/query/functions"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 func setupPlannerTest(t *testing.T, blockSize, numBuffers int) (*Planner, *metadata.Manager, *file.Manager, *log.Manager, *buffer.Manager, *concurrency.LockTable) {
@@ -283,5 +289,1285 @@ func TestPlanner_CreateIndex(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, txn3.Commit())
 
-	require.Contains(t, idxInfo, "user_id", "index info should contain an index on user_id")
+	require.NotNil(t, indexOnField(idxInfo, "user_id"), "index info should contain an index on user_id")
+}
+
+func TestPlanner_DropTableAndIndex(t *testing.T) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	mdm, err := metadata.NewManager(true, txn)
+	require.NoError(t, err)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+	for _, sql := range []string{
+		"CREATE TABLE people (id INT, name VARCHAR(10))",
+		"CREATE INDEX people_id ON people (id)",
+		"CREATE INDEX people_name ON people (name)",
+		"INSERT INTO people (id, name) VALUES (1, 'alice'), (2, 'bob'), (3, 'carol')",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	files := func(pattern string) []string {
+		matches, err := filepath.Glob(filepath.Join(dbDir, pattern))
+		require.NoError(t, err)
+		return matches
+	}
+	indexNames := func() []string {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		indexes, err := mdm.GetIndexInfo("people", txn)
+		require.NoError(t, err)
+		var names []string
+		for _, indexInfo := range indexes {
+			names = append(names, indexInfo.IndexName())
+		}
+		return names
+	}
+	require.NotEmpty(t, files("people_id-*"))
+
+	// A rolled back drop leaves the index, and its files, alone.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.ExecuteUpdate("DROP INDEX people_id", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Rollback())
+	assert.Equal(t, []string{"people_id", "people_name"}, indexNames())
+	assert.NotEmpty(t, files("people_id-*"))
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.ExecuteUpdate("DROP INDEX people_id", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+	assert.Equal(t, []string{"people_name"}, indexNames())
+	assert.Empty(t, files("people_id-*"))
+	rows := runPlannerQuery(t, p, "SELECT id FROM people WHERE id = 2", fm, lm, bm, lt, []string{"id"})
+	assert.Equal(t, []map[string]any{{"id": 2}}, rows)
+
+	// Dropping the table drops its remaining index, and the table is gone within the transaction.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.ExecuteUpdate("DROP TABLE people", txn)
+	require.NoError(t, err)
+	_, err = p.CreateQueryPlan("SELECT id FROM people", txn)
+	assert.ErrorContains(t, err, "table people not found")
+	require.NoError(t, txn.Commit())
+	assert.Empty(t, indexNames())
+	assert.Empty(t, files("people*"))
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.CreateQueryPlan("SELECT id FROM people", txn)
+	assert.ErrorContains(t, err, "table people not found")
+	for _, sql := range []string{"DROP TABLE people", "DROP INDEX people_name", "DROP TABLE table_catalog"} {
+		_, err = p.ExecuteUpdate(sql, txn)
+		assert.Error(t, err, sql)
+	}
+
+	// A table created with the same name starts empty, rather than reading the blocks of the dropped table.
+	_, err = p.ExecuteUpdate("CREATE TABLE people (id INT, name VARCHAR(10))", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("INSERT INTO people (id, name) VALUES (4, 'dave')", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+	rows = runPlannerQuery(t, p, "SELECT id, name FROM people", fm, lm, bm, lt, []string{"id", "name"})
+	assert.Equal(t, []map[string]any{{"id": 4, "name": "dave"}}, rows)
+}
+
+// setupIndexedPlannerTest creates a planner that maintains indexes on updates,
+// along with a table "items" holding 200 records and indexes on two of its fields.
+func setupIndexedPlannerTest(t *testing.T) (*Planner, *file.Manager, *log.Manager, *buffer.Manager, *concurrency.LockTable) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	mdm, err := metadata.NewManager(true, txn)
+	require.NoError(t, err)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	_, err = p.ExecuteUpdate("CREATE TABLE items (id INT, category VARCHAR(10), score INT)", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_score ON items (score)", txn)
+	require.NoError(t, err)
+
+	// Insert the records before creating the second index, so that it has to be populated from the table.
+	for i := 0; i < 200; i++ {
+		insertSQL := fmt.Sprintf("INSERT INTO items (id, category, score) VALUES (%d, 'c%d', %d)", i, i%20, i%50)
+		_, err := p.ExecuteUpdate(insertSQL, txn)
+		require.NoError(t, err)
+	}
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_category ON items (category)", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	return p, fm, lm, bm, lt
+}
+
+func TestPlanner_IndexCandidates(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	selectSQL := "SELECT id FROM items WHERE category = 'c3' AND score = 3"
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	accessPaths, err := p.IndexCandidates(selectSQL, txn)
+	require.NoError(t, err)
+	require.Len(t, accessPaths, 1)
+
+	accessPath := accessPaths[0]
+	assert.Equal(t, "items", accessPath.TableName)
+	require.Len(t, accessPath.Candidates, 2)
+
+	// Candidates are reported in index name order.
+	category, score := accessPath.Candidates[0], accessPath.Candidates[1]
+	assert.Equal(t, "idx_category", category.IndexName)
+	assert.Equal(t, "category", category.FieldName)
+	assert.Equal(t, "category = c3", category.Term.String())
+	assert.Equal(t, "idx_score", score.IndexName)
+	assert.Equal(t, "score", score.FieldName)
+	assert.Equal(t, "score = 3", score.Term.String())
+
+	// Both indexes beat a table scan, but score is more selective (50 distinct values vs 20).
+	assert.Equal(t, category.BlocksWithoutIndex, score.BlocksWithoutIndex)
+	assert.Less(t, category.BlocksWithIndex, category.BlocksWithoutIndex)
+	assert.Less(t, score.BlocksWithIndex, category.BlocksWithIndex)
+	assert.Equal(t, RejectCheaperIndex, category.Rejection)
+	assert.Empty(t, score.Rejection)
+	assert.Same(t, score, accessPath.Chosen)
+	assert.Equal(t, "index select on items using idx_score (score = 3)", accessPath.String())
+
+	// The plan actually built for the query reads the table through the chosen index.
+	queryPlan, err := p.CreateQueryPlan(selectSQL, txn)
+	require.NoError(t, err)
+	projectPlan, ok := queryPlan.(*ProjectPlan)
+	require.True(t, ok)
+	selectPlan, ok := projectPlan.inputPlan.(*SelectPlan)
+	require.True(t, ok)
+	indexSelectPlan, ok := selectPlan.inputPlan.(*IndexSelectPlan)
+	require.True(t, ok, "expected an index select, got %T", selectPlan.inputPlan)
+	assert.Equal(t, "idx_score", indexSelectPlan.indexInfo.IndexName())
+	require.NoError(t, txn.Commit())
+
+	rows := runPlannerQuery(t, p, selectSQL, fm, lm, bm, lt, []string{"id"})
+	assert.ElementsMatch(t, []map[string]any{{"id": 3}, {"id": 103}}, rows)
+
+	// The index populated at creation time finds the records inserted before it existed.
+	rows = runPlannerQuery(t, p, "SELECT id FROM items WHERE category = 'c7'", fm, lm, bm, lt, []string{"id"})
+	assert.Len(t, rows, 10)
+}
+
+func TestPlanner_StatementStats(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.StatementStats(txn)
+	assert.EqualError(t, err, "no query was planned by the transaction")
+
+	// The stats of a query record the index chosen to read the table, and why the other one was rejected.
+	_, err = p.CreateQueryPlan("SELECT id FROM items WHERE category = 'c3' AND score = 3", txn)
+	require.NoError(t, err)
+	stats, err := p.StatementStats(txn)
+	require.NoError(t, err)
+	require.Len(t, stats.AccessPaths, 1)
+	accessPath := stats.AccessPaths[0]
+	assert.Equal(t, "index select on items using idx_score (score = 3)", accessPath.String())
+	require.Len(t, accessPath.Candidates, 2)
+	assert.Equal(t, "idx_category", accessPath.Candidates[0].IndexName)
+	assert.Equal(t, RejectCheaperIndex, accessPath.Candidates[0].Rejection)
+	assert.Same(t, accessPath.Candidates[1], accessPath.Chosen)
+
+	// Reporting the index candidates of a query does not replace the stats of the last query planned.
+	_, err = p.IndexCandidates("SELECT id FROM items WHERE score > 3", txn)
+	require.NoError(t, err)
+	sameStats, err := p.StatementStats(txn)
+	require.NoError(t, err)
+	assert.Same(t, stats, sameStats)
+
+	// The next query replaces them: the table is scanned, since neither index serves its predicate.
+	_, err = p.CreateQueryPlan("SELECT id FROM items WHERE score > 3", txn)
+	require.NoError(t, err)
+	stats, err = p.StatementStats(txn)
+	require.NoError(t, err)
+	require.Len(t, stats.AccessPaths, 1)
+	accessPath = stats.AccessPaths[0]
+	assert.Nil(t, accessPath.Chosen)
+	assert.Equal(t, "table scan on items", accessPath.String())
+	for _, candidate := range accessPath.Candidates {
+		assert.Equal(t, RejectNoEqualityTerm, candidate.Rejection, candidate.IndexName)
+	}
+
+	// The stats of a transaction are forgotten once it ends.
+	require.NoError(t, txn.Commit())
+	_, err = p.StatementStats(txn)
+	assert.Error(t, err)
+}
+
+func TestPlanner_IndexCandidatesRejections(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE empty_items (id INT)", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_empty ON empty_items (id)", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	tests := []struct {
+		name     string
+		sql      string
+		expected map[string]RejectionReason
+	}{
+		{
+			name: "no equality term",
+			sql:  "SELECT id FROM items WHERE score > 3",
+			expected: map[string]RejectionReason{
+				"idx_category": RejectNoEqualityTerm,
+				"idx_score":    RejectNoEqualityTerm,
+			},
+		},
+		{
+			name: "type mismatch",
+			sql:  "SELECT id FROM items WHERE score = 'three'",
+			expected: map[string]RejectionReason{
+				"idx_category": RejectNoEqualityTerm,
+				"idx_score":    RejectTypeMismatch,
+			},
+		},
+		{
+			name: "statistics missing",
+			sql:  "SELECT id FROM empty_items WHERE id = 1",
+			expected: map[string]RejectionReason{
+				"idx_empty": RejectMissingStatistics,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			defer func() { require.NoError(t, txn.Commit()) }()
+
+			accessPaths, err := p.IndexCandidates(tt.sql, txn)
+			require.NoError(t, err)
+			require.Len(t, accessPaths, 1)
+			assert.Nil(t, accessPaths[0].Chosen)
+
+			reasons := make(map[string]RejectionReason)
+			for _, candidate := range accessPaths[0].Candidates {
+				reasons[candidate.IndexName] = candidate.Rejection
+				assert.Equal(t, -1, candidate.BlocksWithIndex)
+			}
+			assert.Equal(t, tt.expected, reasons)
+		})
+	}
+}
+
+func TestPlanner_OrPredicates(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	// Item i is in category c(i%20) and has score i%50.
+	tests := []struct {
+		name   string
+		sql    string
+		chosen string
+		ids    []int
+	}{
+		{
+			name: "disjunction of equalities on an indexed field",
+			sql:  "SELECT id FROM items WHERE category = 'c3' OR category = 'c4'",
+			ids:  []int{3, 4, 23, 24, 43, 44, 63, 64, 83, 84, 103, 104, 123, 124, 143, 144, 163, 164, 183, 184},
+		},
+		{
+			name: "parenthesized disjunction conjoined with a term",
+			sql:  "SELECT id FROM items WHERE (category = 'c3' OR score = 4) AND id < 60",
+			ids:  []int{3, 4, 23, 43, 54},
+		},
+		{
+			name: "and binds tighter than or",
+			sql:  "SELECT id FROM items WHERE category = 'c3' AND score = 3 OR id = 7",
+			ids:  []int{3, 7, 103},
+		},
+		{
+			name:   "index on a term conjoined with a disjunction",
+			sql:    "SELECT id FROM items WHERE score = 3 AND (category = 'c3' OR category = 'c13')",
+			chosen: "idx_score",
+			ids:    []int{3, 53, 103, 153},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			accessPaths, err := p.IndexCandidates(tt.sql, txn)
+			require.NoError(t, err)
+			require.NoError(t, txn.Commit())
+			require.Len(t, accessPaths, 1)
+			if tt.chosen == "" {
+				assert.Nil(t, accessPaths[0].Chosen, "an index on a field of a disjunction cannot find every record")
+			} else {
+				require.NotNil(t, accessPaths[0].Chosen)
+				assert.Equal(t, tt.chosen, accessPaths[0].Chosen.IndexName)
+			}
+
+			rows := runPlannerQuery(t, p, tt.sql, fm, lm, bm, lt, []string{"id"})
+			ids := make([]int, 0, len(rows))
+			for _, row := range rows {
+				ids = append(ids, row["id"].(int))
+			}
+			assert.ElementsMatch(t, tt.ids, ids)
+		})
+	}
+
+	// A view defined with a disjunction reads back its definition.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE VIEW picked AS SELECT id FROM items WHERE id = 1 OR (score = 2 AND id > 100)", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+	rows := runPlannerQuery(t, p, "SELECT id FROM picked", fm, lm, bm, lt, []string{"id"})
+	ids := make([]int, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row["id"].(int))
+	}
+	assert.ElementsMatch(t, []int{1, 102, 152}, ids)
+}
+
+func TestPlanner_NotPredicates(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE people (id INT, age INT, active BOOL)", txn)
+	require.NoError(t, err)
+	// Person i is 20+5*i years old, and active if i is even.
+	for i := 0; i < 8; i++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO people (id, age, active) VALUES (%d, %d, %t)", i, 20+5*i, i%2 == 0), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	tests := []struct {
+		where string
+		ids   []int
+	}{
+		{"NOT active", []int{1, 3, 5, 7}},
+		{"NOT (age > 30)", []int{0, 1, 2}},
+		{"active AND NOT (age > 30)", []int{0, 2}},
+		{"NOT (active AND age > 30)", []int{0, 1, 2, 3, 5, 7}},
+		{"NOT (active OR age > 30)", []int{1}},
+		{"NOT NOT active", []int{0, 2, 4, 6}},
+		{"NOT (NOT (age > 30) OR NOT active)", []int{4, 6}},
+	}
+	for _, tt := range tests {
+		rows := runPlannerQuery(t, p, "SELECT id FROM people WHERE "+tt.where, fm, lm, bm, lt, []string{"id"})
+		ids := make([]int, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row["id"].(int))
+		}
+		assert.ElementsMatch(t, tt.ids, ids, tt.where)
+	}
+}
+
+func TestPlanner_BetweenAndInLists(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE people (id INT, age INT, dept VARCHAR(10), hired DATE)", txn)
+	require.NoError(t, err)
+	// Person i is 20+5*i years old, works in the department i%3 and was hired on the first of month i+1 of 2024.
+	depts := []string{"Sales", "HR", "Eng"}
+	for i := 0; i < 8; i++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO people (id, age, dept, hired) VALUES (%d, %d, '%s', '2024-%02d-01')",
+			i, 20+5*i, depts[i%3], i+1), txn)
+		require.NoError(t, err)
+	}
+	_, err = p.ExecuteUpdate("INSERT INTO people (id, age, dept, hired) VALUES (8, NULL, NULL, NULL)", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	tests := []struct {
+		where string
+		ids   []int
+	}{
+		{"age BETWEEN 30 AND 40", []int{2, 3, 4}},
+		{"age BETWEEN 40 AND 30", []int{}},
+		{"age NOT BETWEEN 30 AND 40", []int{0, 1, 5, 6, 7}},
+		{"age BETWEEN 20 AND 30 AND dept = 'Sales'", []int{0}},
+		{"dept IN ('Sales', 'HR')", []int{0, 1, 3, 4, 6, 7}},
+		{"dept IN ('Eng')", []int{2, 5}},
+		{"dept NOT IN ('Sales', 'HR')", []int{2, 5}},
+		{"dept IN ('Sales', 'HR') AND age BETWEEN 30 AND 50", []int{3, 4, 6}},
+		{"id IN (1, 3, 5, 100)", []int{1, 3, 5}},
+		{"id IN (1, NULL)", []int{1}},
+		{"id NOT IN (1, NULL)", []int{}},
+		{"age IN (id * 5 + 20)", []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{"hired BETWEEN '2024-02-15' AND '2024-05-01'", []int{2, 3, 4}},
+		{"hired IN ('2024-01-01', '2024-08-01', '2025-01-01')", []int{0, 7}},
+	}
+	for _, tt := range tests {
+		rows := runPlannerQuery(t, p, "SELECT id FROM people WHERE "+tt.where, fm, lm, bm, lt, []string{"id"})
+		ids := make([]int, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row["id"].(int))
+		}
+		assert.ElementsMatch(t, tt.ids, ids, tt.where)
+	}
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	_, err = p.CreateQueryPlan("SELECT id FROM people WHERE dept IN ('Sales', 1)", txn)
+	assert.ErrorIs(t, err, query.ErrTypeMismatch)
+	_, err = p.CreateQueryPlan("SELECT id FROM people WHERE age BETWEEN 'a' AND 'b'", txn)
+	assert.ErrorIs(t, err, query.ErrTypeMismatch)
+}
+
+func TestPlanner_NullValues(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	execute := func(statements ...string) {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		for _, statement := range statements {
+			_, err := p.ExecuteUpdate(statement, txn)
+			require.NoError(t, err, statement)
+		}
+		require.NoError(t, txn.Commit())
+	}
+	ids := func(where string) []int {
+		rows := runPlannerQuery(t, p, "SELECT id FROM scores WHERE "+where, fm, lm, bm, lt, []string{"id"})
+		ids := make([]int, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row["id"].(int))
+		}
+		return ids
+	}
+
+	execute(
+		"CREATE TABLE scores (id INT, grp INT, score INT)",
+		"CREATE INDEX idx_scores_score ON scores (score)",
+		"INSERT INTO scores (id, grp, score) VALUES (0, 1, 10), (1, 1, NULL), (2, 2, NULL), (4, 1, 30)",
+		"INSERT INTO scores (id, grp) VALUES (3, 2)",
+	)
+
+	assert.ElementsMatch(t, []int{1, 2, 3}, ids("score IS NULL"))
+	assert.ElementsMatch(t, []int{0, 4}, ids("score IS NOT NULL"))
+	assert.ElementsMatch(t, []int{0}, ids("score = 10"))
+	// A comparison does not hold for a null value, nor does its negation.
+	assert.ElementsMatch(t, []int{4}, ids("score <> 10"))
+	assert.ElementsMatch(t, []int{4}, ids("NOT (score = 10)"))
+	assert.Empty(t, ids("score = NULL"))
+
+	// The aggregates skip null values, and those over no value but count are null.
+	rows := runPlannerQuery(t, p, "SELECT grp, COUNT(score), SUM(score), MIN(score), MAX(score) FROM scores GROUP BY grp",
+		fm, lm, bm, lt, []string{"grp", "countOfscore", "sumOfscore", "minOfscore", "maxOfscore"})
+	assert.ElementsMatch(t, []map[string]any{
+		{"grp": 1, "countOfscore": int64(2), "sumOfscore": int64(40), "minOfscore": 10, "maxOfscore": 30},
+		{"grp": 2, "countOfscore": int64(0), "sumOfscore": nil, "minOfscore": nil, "maxOfscore": nil},
+	}, rows)
+
+	// Null keys are left out of the index, which stays consistent with the table as values become null.
+	execute("UPDATE scores SET score = NULL WHERE id = 0")
+	assert.Empty(t, ids("score = 10"))
+	assert.ElementsMatch(t, []int{0, 1, 2, 3}, ids("score IS NULL"))
+	execute("DELETE FROM scores WHERE score IS NULL")
+	assert.ElementsMatch(t, []int{4}, ids("score >= 0"))
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	findings, err := p.ExecuteUpdate("CHECK TABLE scores", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+	assert.Zero(t, findings)
+}
+
+func TestPlanner_LimitOffset(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE people (id INT, age INT)", txn)
+	require.NoError(t, err)
+	// The ids are inserted out of order, and person i is 20+i years old.
+	for _, i := range []int{5, 2, 8, 0, 9, 3, 6, 1, 7, 4} {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO people (id, age) VALUES (%d, %d)", i, 20+i), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	tests := []struct {
+		sql string
+		ids []int
+	}{
+		{"SELECT id FROM people ORDER BY age LIMIT 3", []int{0, 1, 2}},
+		{"SELECT id FROM people ORDER BY age LIMIT 3 OFFSET 4", []int{4, 5, 6}},
+		{"SELECT id FROM people WHERE age > 25 ORDER BY id LIMIT 2", []int{6, 7}},
+		{"SELECT id FROM people ORDER BY id OFFSET 8", []int{8, 9}},
+		{"SELECT id FROM people ORDER BY id LIMIT 5 OFFSET 7", []int{7, 8, 9}},
+		{"SELECT id FROM people ORDER BY id LIMIT 0", []int{}},
+		{"SELECT id FROM people ORDER BY id LIMIT 2 OFFSET 10", []int{}},
+	}
+	for _, tt := range tests {
+		rows := runPlannerQuery(t, p, tt.sql, fm, lm, bm, lt, []string{"id"})
+		ids := make([]int, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row["id"].(int))
+		}
+		assert.Equal(t, tt.ids, ids, tt.sql)
+	}
+
+	// Without ORDER BY, the limit still bounds the number of records.
+	rows := runPlannerQuery(t, p, "SELECT id FROM people LIMIT 4", fm, lm, bm, lt, []string{"id"})
+	assert.Len(t, rows, 4)
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	queryPlan, err := p.CreateQueryPlan("SELECT id FROM people LIMIT 4 OFFSET 3", txn)
+	require.NoError(t, err)
+	assert.IsType(t, &LimitPlan{}, queryPlan)
+	assert.Equal(t, 4, queryPlan.RecordsOutput())
+	queryPlan, err = p.CreateQueryPlan("SELECT id FROM people LIMIT 4 OFFSET 8", txn)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queryPlan.RecordsOutput())
+}
+
+func TestPlanner_PartialIndexSelection(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE accounts (id INT, owner VARCHAR(10), deleted BOOL)", txn)
+	require.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		insertSQL := fmt.Sprintf("INSERT INTO accounts (id, owner, deleted) VALUES (%d, 'o%d', %t)", i, i%20, i%3 == 0)
+		_, err := p.ExecuteUpdate(insertSQL, txn)
+		require.NoError(t, err)
+	}
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_live_owner ON accounts (owner) WHERE deleted = false", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_recent_id ON accounts (id) WHERE id >= 100", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	tests := []struct {
+		name     string
+		sql      string
+		chosen   string
+		expected map[string]RejectionReason
+		ids      []int
+	}{
+		{
+			name:   "query repeats the index predicate",
+			sql:    "SELECT id FROM accounts WHERE owner = 'o3' AND deleted = false",
+			chosen: "idx_live_owner",
+			expected: map[string]RejectionReason{
+				"idx_live_owner": "",
+				"idx_recent_id":  RejectPredicateNotImplied,
+			},
+			ids: []int{23, 43, 83, 103, 143, 163},
+		},
+		{
+			name: "query does not restrict to live accounts",
+			sql:  "SELECT id FROM accounts WHERE owner = 'o3'",
+			expected: map[string]RejectionReason{
+				"idx_live_owner": RejectPredicateNotImplied,
+				"idx_recent_id":  RejectPredicateNotImplied,
+			},
+			ids: []int{3, 23, 43, 63, 83, 103, 123, 143, 163, 183},
+		},
+		{
+			name:   "equality inside the indexed range",
+			sql:    "SELECT id FROM accounts WHERE id = 150",
+			chosen: "idx_recent_id",
+			expected: map[string]RejectionReason{
+				"idx_live_owner": RejectPredicateNotImplied,
+				"idx_recent_id":  "",
+			},
+			ids: []int{150},
+		},
+		{
+			name: "equality outside the indexed range",
+			sql:  "SELECT id FROM accounts WHERE id = 50",
+			expected: map[string]RejectionReason{
+				"idx_live_owner": RejectPredicateNotImplied,
+				"idx_recent_id":  RejectPredicateNotImplied,
+			},
+			ids: []int{50},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			accessPaths, err := p.IndexCandidates(tt.sql, txn)
+			require.NoError(t, err)
+			require.NoError(t, txn.Commit())
+			require.Len(t, accessPaths, 1)
+
+			if tt.chosen == "" {
+				assert.Nil(t, accessPaths[0].Chosen)
+			} else {
+				require.NotNil(t, accessPaths[0].Chosen)
+				assert.Equal(t, tt.chosen, accessPaths[0].Chosen.IndexName)
+			}
+
+			reasons := make(map[string]RejectionReason)
+			for _, candidate := range accessPaths[0].Candidates {
+				reasons[candidate.IndexName] = candidate.Rejection
+			}
+			assert.Equal(t, tt.expected, reasons)
+
+			rows := runPlannerQuery(t, p, tt.sql, fm, lm, bm, lt, []string{"id"})
+			ids := make([]int, 0, len(rows))
+			for _, row := range rows {
+				ids = append(ids, row["id"].(int))
+			}
+			assert.ElementsMatch(t, tt.ids, ids)
+		})
+	}
+}
+
+func TestPlanner_IndexSelectionUsesIndexStats(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	insertTags := func(from, to int) {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		for i := from; i < to; i++ {
+			_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO tags (id) VALUES (%d)", i), txn)
+			require.NoError(t, err)
+		}
+		require.NoError(t, txn.Commit())
+	}
+	candidate := func() (*IndexCandidate, *AccessPath) {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		accessPaths, err := p.IndexCandidates("SELECT id FROM tags WHERE id = 7", txn)
+		require.NoError(t, err)
+		require.Len(t, accessPaths, 1)
+		require.Len(t, accessPaths[0].Candidates, 1)
+		return accessPaths[0].Candidates[0], accessPaths[0]
+	}
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE tags (id INT)", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_tag ON tags (id)", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	// With two blocks of distinct ids, a probe reads a bucket block and a data block,
+	// which is no cheaper than scanning the table.
+	insertTags(0, 60)
+	tags, accessPath := candidate()
+	require.Equal(t, 2, tags.BlocksWithoutIndex)
+	assert.Equal(t, 2, tags.BlocksWithIndex)
+	assert.Equal(t, RejectNotCheaper, tags.Rejection)
+	assert.Nil(t, accessPath.Chosen)
+
+	// Once the table outgrows the probe, the index is used.
+	insertTags(60, 90)
+	tags, accessPath = candidate()
+	require.Equal(t, 3, tags.BlocksWithoutIndex)
+	assert.Equal(t, 2, tags.BlocksWithIndex)
+	assert.Empty(t, tags.Rejection)
+	assert.Same(t, tags, accessPath.Chosen)
+
+	rows := runPlannerQuery(t, p, "SELECT id FROM tags WHERE id = 7", fm, lm, bm, lt, []string{"id"})
+	assert.Equal(t, []map[string]any{{"id": 7}}, rows)
+}
+
+func TestPlanner_BTreeRangeSelection(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE events (id INT, payload VARCHAR(20))", txn)
+	require.NoError(t, err)
+	for i := 0; i < 3000; i++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO events (id, payload) VALUES (%d, 'event %d')", i, i), txn)
+		require.NoError(t, err)
+	}
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_id ON events (id) USING btree", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_id_hash ON events (id)", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	selectSQL := "SELECT id FROM events WHERE id >= 1000 AND 1020 > id"
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	accessPaths, err := p.IndexCandidates(selectSQL, txn)
+	require.NoError(t, err)
+	require.Len(t, accessPaths, 1)
+	accessPath := accessPaths[0]
+	require.Len(t, accessPath.Candidates, 2)
+
+	// The hash index cannot serve the range, and the btree index reads far fewer blocks than a table scan.
+	btreeCandidate, hashCandidate := accessPath.Candidates[0], accessPath.Candidates[1]
+	assert.Equal(t, RejectNoEqualityTerm, hashCandidate.Rejection)
+	assert.Same(t, btreeCandidate, accessPath.Chosen)
+	assert.Nil(t, btreeCandidate.Term)
+	assert.Equal(t, &index.Range{Low: 1000, LowInclusive: true, High: 1020}, btreeCandidate.Range)
+	assert.Less(t, btreeCandidate.BlocksWithIndex*4, btreeCandidate.BlocksWithoutIndex)
+	assert.Equal(t, "index range select on events using idx_id (id >= 1000 and id < 1020)", accessPath.String())
+	require.NoError(t, txn.Commit())
+
+	// readIDs runs the query, and returns the ids it outputs along with the number of blocks read by its scan.
+	readIDs := func(sql string) ([]int, int) {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		queryPlan, err := p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err)
+
+		blocksRead := fm.Stats().BlocksRead
+		s, err := queryPlan.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		var ids []int
+		for {
+			hasNext, err := s.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				return ids, fm.Stats().BlocksRead - blocksRead
+			}
+			id, err := s.GetInt("id")
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+	}
+
+	ids, rangeReads := readIDs(selectSQL)
+	var expected []int
+	for id := 1000; id < 1020; id++ {
+		expected = append(expected, id)
+	}
+	assert.Equal(t, expected, ids)
+
+	// Reading the ids by scanning the table reads far more blocks.
+	ids, scanReads := readIDs("SELECT id FROM events")
+	assert.Len(t, ids, 3000)
+	assert.Less(t, rangeReads*10, scanReads)
+}
+
+func TestPlanner_BTreePatternSelection(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE users (id INT, name VARCHAR(8))", txn)
+	require.NoError(t, err)
+	_, err = p.ExecuteUpdate("CREATE INDEX idx_name ON users (name) USING btree", txn)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO users (id, name) VALUES (%d, 'n%03d')", i, i), txn)
+		require.NoError(t, err)
+	}
+	for i, name := range []string{"50%_off", "50% off", "5000"} {
+		_, err := p.ExecuteUpdate(fmt.Sprintf("INSERT INTO users (id, name) VALUES (%d, '%s')", 1000+i, name), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	// readNames runs the query, and returns the names it outputs.
+	readNames := func(sql string) []string {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		queryPlan, err := p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err, sql)
+		s, err := queryPlan.Open()
+		require.NoError(t, err, sql)
+		defer s.Close()
+		names := []string{}
+		for {
+			hasNext, err := s.Next()
+			require.NoError(t, err, sql)
+			if !hasNext {
+				sort.Strings(names)
+				return names
+			}
+			name, err := s.GetString("name")
+			require.NoError(t, err, sql)
+			names = append(names, name)
+		}
+	}
+
+	// A pattern with a literal prefix is served by a range of the btree index.
+	selectSQL := "SELECT name FROM users WHERE name LIKE 'n12_'"
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	accessPaths, err := p.IndexCandidates(selectSQL, txn)
+	require.NoError(t, err)
+	require.Len(t, accessPaths, 1)
+	candidate := accessPaths[0].Candidates[0]
+	assert.Same(t, candidate, accessPaths[0].Chosen)
+	assert.Equal(t, &index.Range{Low: "n12", LowInclusive: true, High: "n13"}, candidate.Range)
+
+	// A pattern starting with a wildcard cannot be served by the index.
+	accessPaths, err = p.IndexCandidates("SELECT name FROM users WHERE name LIKE '%12_'", txn)
+	require.NoError(t, err)
+	require.Len(t, accessPaths, 1)
+	assert.Equal(t, RejectNoEqualityTerm, accessPaths[0].Candidates[0].Rejection)
+	require.NoError(t, txn.Commit())
+
+	expected := []string{}
+	for i := 0; i < 10; i++ {
+		expected = append(expected, fmt.Sprintf("n12%d", i))
+	}
+	assert.Equal(t, expected, readNames(selectSQL))
+	assert.Equal(t, []string{"n025", "n125", "n225", "n325", "n425", "n525", "n625", "n725", "n825", "n925"},
+		readNames("SELECT name FROM users WHERE name LIKE '%_25'"))
+	assert.Equal(t, []string{"n999"}, readNames("SELECT name FROM users WHERE name LIKE 'n999%'"))
+
+	// An escaped wildcard only matches itself.
+	assert.Equal(t, []string{"50% off", "50%_off"}, readNames("SELECT name FROM users WHERE name LIKE '50\\%%'"))
+	assert.Equal(t, []string{"50%_off"}, readNames("SELECT name FROM users WHERE name LIKE '50\\%\\_%'"))
+	assert.Len(t, readNames("SELECT name FROM users WHERE name NOT LIKE 'n%'"), 3)
+}
+
+func TestPlanner_ScanFields(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table people (id int, name varchar(10), born date, dept_id int)",
+		"create table depts (did int, dname varchar(15), open bool)",
+		"insert into people (id, name, born, dept_id) values (1, 'ann', '1990-04-01', 10)",
+		"insert into people (id, name, born, dept_id) values (2, 'bob', '1985-12-24', 20)",
+		"insert into people (id, name, born, dept_id) values (3, 'cy', '2001-07-14', 10)",
+		"insert into depts (did, dname, open) values (10, 'sales', true)",
+		"insert into depts (did, dname, open) values (20, 'research', false)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	tests := []struct {
+		sql    string
+		fields []types.FieldInfo
+		rows   int
+	}{
+		{
+			sql: "select name, dname, open, id from people, depts where dept_id = did",
+			fields: []types.FieldInfo{
+				{Name: "name", Type: types.Varchar, Length: 10},
+				{Name: "dname", Type: types.Varchar, Length: 15},
+				{Name: "open", Type: types.Boolean},
+				{Name: "id", Type: types.Integer},
+			},
+			rows: 3,
+		},
+		{
+			sql: "select dept_id, count(id), sum(id), max(name), min(born), avg(id) from people group by dept_id",
+			fields: []types.FieldInfo{
+				{Name: "dept_id", Type: types.Integer},
+				{Name: "countOfid", Type: types.Long},
+				{Name: "sumOfid", Type: types.Long},
+				{Name: "maxOfname", Type: types.Varchar, Length: 10},
+				{Name: "minOfborn", Type: types.Date},
+				{Name: "avgOfid", Type: types.Float},
+			},
+			rows: 2,
+		},
+		{
+			sql: "select dname, max(born) from people, depts where dept_id = did group by dname order by dname",
+			fields: []types.FieldInfo{
+				{Name: "dname", Type: types.Varchar, Length: 15},
+				{Name: "maxOfborn", Type: types.Date},
+			},
+			rows: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			defer func() { require.NoError(t, txn.Commit()) }()
+
+			queryPlan, err := p.CreateQueryPlan(tt.sql, txn)
+			require.NoError(t, err)
+			s, err := queryPlan.Open()
+			require.NoError(t, err)
+			defer s.Close()
+
+			assert.Equal(t, tt.fields, s.Fields())
+			rows := 0
+			for {
+				next, err := s.Next()
+				require.NoError(t, err)
+				if !next {
+					break
+				}
+				rows++
+				// The values of every row have the type of their field.
+				for _, field := range s.Fields() {
+					val, err := s.GetVal(field.Name)
+					require.NoError(t, err)
+					assert.True(t, types.IsValueOfType(val, field.Type), "%s = %v (%T) is not a %s", field.Name, val, val, field.Type)
+					if str, ok := val.(string); ok {
+						assert.LessOrEqual(t, len(str), field.Length, field.Name)
+					}
+				}
+			}
+			assert.Equal(t, tt.rows, rows)
+		})
+	}
+}
+
+func TestPlanner_AggregateTypes(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE staff (name VARCHAR(10), dept INT, hired DATE, rate FLOAT)",
+		"INSERT INTO staff (name, dept, hired, rate) VALUES ('ann', 1, '2020-03-01', 1.5), ('bob', 1, '2018-07-15', 2.25)",
+		"INSERT INTO staff (name, dept, hired, rate) VALUES ('cy', 2, '2022-01-10', 4), ('dee', 2, NULL, NULL)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	// The aggregates are read with the getters of the types their schema gives them, whichever plan groups the records.
+	for _, sql := range []string{
+		"SELECT dept, MIN(hired), MAX(hired), SUM(rate), AVG(rate), AVG(dept), COUNT(name) FROM staff GROUP BY dept",
+		"SELECT dept, MIN(hired), MAX(hired), SUM(rate), AVG(rate), AVG(dept), COUNT(name) FROM staff GROUP BY dept ORDER BY dept",
+	} {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		queryPlan, err := p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err, sql)
+		schema := queryPlan.Schema()
+		assert.Equal(t, types.Date, schema.Type("minOfhired"), sql)
+		assert.Equal(t, types.Date, schema.Type("maxOfhired"), sql)
+		assert.Equal(t, types.Float, schema.Type("sumOfrate"), sql)
+		assert.Equal(t, types.Float, schema.Type("avgOfrate"), sql)
+		assert.Equal(t, types.Float, schema.Type("avgOfdept"), sql)
+		assert.Equal(t, types.Long, schema.Type("countOfname"), sql)
+
+		s, err := queryPlan.Open()
+		require.NoError(t, err, sql)
+		results := map[int][]any{}
+		for {
+			hasNext, err := s.Next()
+			require.NoError(t, err, sql)
+			if !hasNext {
+				break
+			}
+			dept, err := s.GetInt("dept")
+			require.NoError(t, err)
+			minHired, err := s.GetDate("minOfhired")
+			require.NoError(t, err)
+			maxHired, err := s.GetDate("maxOfhired")
+			require.NoError(t, err)
+			sumRate, err := s.GetFloat("sumOfrate")
+			require.NoError(t, err)
+			avgRate, err := s.GetFloat("avgOfrate")
+			require.NoError(t, err)
+			avgDept, err := s.GetFloat("avgOfdept")
+			require.NoError(t, err)
+			count, err := s.GetLong("countOfname")
+			require.NoError(t, err)
+			results[dept] = []any{minHired.Format(time.DateOnly), maxHired.Format(time.DateOnly), sumRate, avgRate, avgDept, count}
+		}
+		require.NoError(t, s.Close())
+		require.NoError(t, txn.Commit())
+
+		assert.Equal(t, map[int][]any{
+			1: {"2018-07-15", "2020-03-01", 3.75, 1.875, 1.0, int64(2)},
+			2: {"2022-01-10", "2022-01-10", 4.0, 4.0, 2.0, int64(2)},
+		}, results, sql)
+	}
+
+	// Sums and averages of fields that are not numbers are rejected when the query is planned.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	for _, sql := range []string{
+		"SELECT dept, SUM(name) FROM staff GROUP BY dept",
+		"SELECT dept, AVG(hired) FROM staff GROUP BY dept",
+		"SELECT dept, AVG(name) FROM staff GROUP BY dept ORDER BY dept",
+		"SELECT dept FROM staff GROUP BY dept HAVING SUM(hired) > 1",
+	} {
+		_, err := p.CreateQueryPlan(sql, txn)
+		assert.ErrorIs(t, err, query.ErrTypeMismatch, sql)
+	}
+	_, err := p.CreateQueryPlan("SELECT dept, SUM(name) FROM staff GROUP BY dept", txn)
+	assert.ErrorContains(t, err, "sumOfname aggregates field name of type varchar, which is not a number")
+}
+
+func TestPlanner_CountAll(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE staff (name VARCHAR(10), dept INT)",
+		"CREATE TABLE nobody (name VARCHAR(10), dept INT)",
+		"INSERT INTO staff (name, dept) VALUES ('ann', 1), ('bob', 1), (NULL, 1), ('cy', 2), (NULL, NULL)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	queryPlan, err := p.CreateQueryPlan("SELECT COUNT(*) FROM staff", txn)
+	require.NoError(t, err)
+	assert.Equal(t, types.Long, queryPlan.Schema().Type(functions.CountAllFieldName))
+	assert.Equal(t, 1, queryPlan.RecordsOutput())
+	require.NoError(t, txn.Commit())
+
+	// Every record is counted, whatever its values, unlike by the count of a field.
+	countFields := []string{functions.CountAllFieldName, "countOfname"}
+	assert.Equal(t, []map[string]any{{functions.CountAllFieldName: int64(5), "countOfname": int64(3)}},
+		runPlannerQuery(t, p, "SELECT COUNT(*), COUNT(name) FROM staff", fm, lm, bm, lt, countFields))
+	assert.Equal(t, []map[string]any{{functions.CountAllFieldName: int64(3), "countOfname": int64(2)}},
+		runPlannerQuery(t, p, "SELECT COUNT(*), COUNT(name) FROM staff WHERE dept = 1", fm, lm, bm, lt, countFields))
+
+	// Without GROUP BY, a query aggregating no record still outputs its aggregates.
+	nothingFields := []string{functions.CountAllFieldName, "maxOfdept"}
+	for _, sql := range []string{
+		"SELECT COUNT(*), MAX(dept) FROM nobody",
+		"SELECT COUNT(*), MAX(dept) FROM staff WHERE dept > 5",
+	} {
+		assert.Equal(t, []map[string]any{{functions.CountAllFieldName: int64(0), "maxOfdept": nil}},
+			runPlannerQuery(t, p, sql, fm, lm, bm, lt, nothingFields), sql)
+	}
+	assert.Empty(t, runPlannerQuery(t, p, "SELECT dept, COUNT(*) FROM nobody GROUP BY dept", fm, lm, bm, lt, nil))
+
+	// The groups are counted by whichever plan groups the records, and filtered and ordered by their count.
+	for _, sql := range []string{
+		"SELECT dept, COUNT(*) FROM staff GROUP BY dept",
+		"SELECT dept, COUNT(*) FROM staff GROUP BY dept ORDER BY dept",
+	} {
+		results := runPlannerQuery(t, p, sql, fm, lm, bm, lt, []string{"dept", functions.CountAllFieldName})
+		assert.ElementsMatch(t, []map[string]any{
+			{"dept": 1, functions.CountAllFieldName: int64(3)},
+			{"dept": 2, functions.CountAllFieldName: int64(1)},
+			{"dept": nil, functions.CountAllFieldName: int64(1)},
+		}, results, sql)
+	}
+	assert.Equal(t, []map[string]any{{"dept": 1, "total": int64(3)}},
+		runPlannerQuery(t, p, "SELECT dept, COUNT(*) AS total FROM staff GROUP BY dept HAVING total > 1 ORDER BY total DESC",
+			fm, lm, bm, lt, []string{"dept", "total"}))
+}
+
+func TestPlanner_SelectOnNonProjectedFields(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table users (id int, name varchar(10), age int)",
+		"insert into users (id, name, age) values (1, 'ann', 34)",
+		"insert into users (id, name, age) values (2, 'bob', 12)",
+		"insert into users (id, name, age) values (3, 'cid', 27)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	// The WHERE and ORDER BY fields are not projected, but the operators reading them are planned below the projection.
+	rows := runPlannerQuery(t, p, "select name from users where age > 18", fm, lm, bm, lt, []string{"name"})
+	assert.ElementsMatch(t, []map[string]any{{"name": "ann"}, {"name": "cid"}}, rows)
+
+	rows = runPlannerQuery(t, p, "select name from users where age > 18 order by age", fm, lm, bm, lt, []string{"name"})
+	assert.Equal(t, []map[string]any{{"name": "cid"}, {"name": "ann"}}, rows)
+
+	// The fields that are not projected are not readable from the result.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	queryPlan, err := p.CreateQueryPlan("select name from users where age > 18", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, queryPlan.Schema().Fields())
+}
+
+func TestPlanner_DeterministicCatalogOrder(t *testing.T) {
+	insertItems := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		insertItems = append(insertItems, fmt.Sprintf("insert into items (zeta, alpha, mid) values (%d, 'a%d', %d)", i, i%20, i))
+	}
+	// Both databases hold the same tables and indexes, created in different orders;
+	// idx_a and idx_b index the same field, so they are equally matched for every query.
+	databases := map[string][]string{
+		"first": append([]string{
+			"create table others (id int)",
+			"create table items (zeta int, alpha varchar(10), mid int)",
+			"create index idx_c on items (alpha)",
+			"create index idx_b on items (mid)",
+			"create index idx_a on items (mid)",
+		}, insertItems...),
+		"second": append(append([]string{
+			"create table items (zeta int, alpha varchar(10), mid int)",
+			"create index idx_a on items (mid)",
+		}, insertItems...),
+			"create index idx_b on items (mid)",
+			"create table others (id int)",
+			"create index idx_c on items (alpha)",
+		),
+	}
+
+	for name, statements := range databases {
+		t.Run(name, func(t *testing.T) {
+			fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+			mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+			p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			for _, sql := range statements {
+				_, err := p.ExecuteUpdate(sql, txn)
+				require.NoError(t, err, sql)
+			}
+			require.NoError(t, txn.Commit())
+
+			txn = tx.NewTransaction(fm, lm, bm, lt)
+			defer func() { require.NoError(t, txn.Commit()) }()
+
+			// The columns are reported in declaration order, and the indexes by name.
+			layout, err := mdm.GetLayout("items", txn)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"zeta", "alpha", "mid"}, layout.Schema().Fields())
+			indexes, err := mdm.GetIndexInfo("items", txn)
+			require.NoError(t, err)
+			var indexNames []string
+			for _, indexInfo := range indexes {
+				indexNames = append(indexNames, indexInfo.IndexName())
+			}
+			assert.Equal(t, []string{"idx_a", "idx_b", "idx_c"}, indexNames)
+
+			// Of the two equally matched indexes, the one whose name sorts first is chosen.
+			accessPaths, err := p.IndexCandidates("select zeta from items where mid = 42", txn)
+			require.NoError(t, err)
+			require.Len(t, accessPaths, 1)
+			require.Len(t, accessPaths[0].Candidates, 3)
+			assert.Equal(t, "index select on items using idx_a (mid = 42)", accessPaths[0].String())
+			assert.Equal(t, accessPaths[0].Candidates[0].BlocksWithIndex, accessPaths[0].Candidates[1].BlocksWithIndex)
+			assert.Equal(t, RejectCheaperIndex, accessPaths[0].Candidates[1].Rejection)
+			assert.Equal(t, RejectNoEqualityTerm, accessPaths[0].Candidates[2].Rejection)
+
+			// Both indexes on the same field are maintained.
+			for _, indexInfo := range indexes[:2] {
+				idx, err := indexInfo.Open()
+				require.NoError(t, err)
+				require.NoError(t, idx.BeforeFirst(42))
+				next, err := idx.Next()
+				require.NoError(t, err)
+				assert.True(t, next, "index %s has no record for 42", indexInfo.IndexName())
+				idx.Close()
+			}
+		})
+	}
+}
+
+func TestPlanner_Deadlines(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("CREATE TABLE people (id INT, name VARCHAR(20))", txn)
+	require.NoError(t, err)
+	for id := 0; id < 50; id++ {
+		_, err := p.ExecuteUpdateWithDeadline(fmt.Sprintf("INSERT INTO people (id, name) VALUES (%d, 'p%d')", id, id), nil, txn, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+	}
+	_, hasDeadline := txn.Deadline()
+	assert.False(t, hasDeadline, "the deadline of an update is cleared once it is done")
+	require.NoError(t, txn.Commit())
+
+	// An update past its deadline fails, and its transaction can be rolled back.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.ExecuteUpdateWithDeadline("UPDATE people SET name = 'x' WHERE id = 3", nil, txn, time.Now().Add(-time.Millisecond))
+	assert.ErrorIs(t, err, tx.ErrQueryTimeout)
+	require.NoError(t, txn.Rollback())
+
+	// The scan of a query times each move.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	deadline := time.Now().Add(time.Minute)
+	queryPlan, err := p.CreateQueryPlanWithDeadline("SELECT id FROM people", nil, txn, deadline)
+	require.NoError(t, err)
+	s, err := queryPlan.Open()
+	require.NoError(t, err)
+	hasNext, err := s.Next()
+	require.NoError(t, err)
+	assert.True(t, hasNext)
+
+	txn.SetDeadline(time.Now().Add(-time.Millisecond))
+	_, err = s.Next()
+	assert.ErrorIs(t, err, tx.ErrQueryTimeout)
+	require.NoError(t, s.Close())
+	require.NoError(t, txn.Rollback())
+
+	// Planning fails once the deadline has passed.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.CreateQueryPlanWithDeadline("SELECT id FROM people", nil, txn, time.Now().Add(-time.Millisecond))
+	assert.ErrorIs(t, err, tx.ErrQueryTimeout)
+	require.NoError(t, txn.Rollback())
+
+	rows := runPlannerQuery(t, p, "SELECT name FROM people WHERE id = 3", fm, lm, bm, lt, []string{"name"})
+	assert.Equal(t, []map[string]any{{"name": "p3"}}, rows)
+}
+
+func TestPlanner_CompositeIndexSelection(t *testing.T) {
+	for _, indexType := range []string{"hash", "btree"} {
+		t.Run(indexType, func(t *testing.T) {
+			p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			_, err := p.ExecuteUpdate("CREATE TABLE emp (id INT, dept_id INT, grade INT)", txn)
+			require.NoError(t, err)
+			for i := 0; i < 1000; i++ {
+				insertSQL := fmt.Sprintf("INSERT INTO emp (id, dept_id, grade) VALUES (%d, %d, %d)", i, i%10, (i/10)%50)
+				_, err := p.ExecuteUpdate(insertSQL, txn)
+				require.NoError(t, err)
+			}
+			_, err = p.ExecuteUpdate(fmt.Sprintf("CREATE INDEX idx_dept_grade ON emp (dept_id, grade) USING %s", indexType), txn)
+			require.NoError(t, err)
+			require.NoError(t, txn.Commit())
+
+			// The composite index serves a query equating both of its fields, but not one equating only the first.
+			selectSQL := "SELECT id FROM emp WHERE grade = 4 AND dept_id = 3"
+			txn = tx.NewTransaction(fm, lm, bm, lt)
+			accessPaths, err := p.IndexCandidates(selectSQL, txn)
+			require.NoError(t, err)
+			require.Len(t, accessPaths, 1)
+			require.Len(t, accessPaths[0].Candidates, 1)
+			candidate := accessPaths[0].Candidates[0]
+			assert.Same(t, candidate, accessPaths[0].Chosen)
+			assert.Len(t, candidate.Terms, 2)
+			assert.Equal(t, "index select on emp using idx_dept_grade (dept_id = 3 and grade = 4)", accessPaths[0].String())
+
+			accessPaths, err = p.IndexCandidates("SELECT id FROM emp WHERE dept_id = 3", txn)
+			require.NoError(t, err)
+			require.Len(t, accessPaths, 1)
+			assert.Nil(t, accessPaths[0].Chosen)
+			assert.Equal(t, RejectNoEqualityTerm, accessPaths[0].Candidates[0].Rejection)
+			require.NoError(t, txn.Commit())
+
+			readIDs := func(sql string) []int {
+				txn := tx.NewTransaction(fm, lm, bm, lt)
+				defer func() { require.NoError(t, txn.Commit()) }()
+				queryPlan, err := p.CreateQueryPlan(sql, txn)
+				require.NoError(t, err)
+				s, err := queryPlan.Open()
+				require.NoError(t, err)
+				defer s.Close()
+				var ids []int
+				for {
+					hasNext, err := s.Next()
+					require.NoError(t, err)
+					if !hasNext {
+						return ids
+					}
+					id, err := s.GetInt("id")
+					require.NoError(t, err)
+					ids = append(ids, id)
+				}
+			}
+			assert.ElementsMatch(t, []int{43, 543}, readIDs(selectSQL))
+
+			// Deleting and modifying records keeps the composite index up to date.
+			txn = tx.NewTransaction(fm, lm, bm, lt)
+			_, err = p.ExecuteUpdate("DELETE FROM emp WHERE id = 43", txn)
+			require.NoError(t, err)
+			_, err = p.ExecuteUpdate("UPDATE emp SET grade = 4 WHERE id = 3", txn)
+			require.NoError(t, err)
+			require.NoError(t, txn.Commit())
+			assert.ElementsMatch(t, []int{3, 543}, readIDs(selectSQL))
+			assert.Equal(t, []int{503}, readIDs("SELECT id FROM emp WHERE dept_id = 3 AND grade = 0"))
+		})
+	}
 }
@@ -0,0 +1,212 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// preparedInsert holds what inserting records into a table needs: the schema of the table,
+// an update scan over it, and its open indexes. It is reused by the consecutive inserts
+// of a transaction into the table, which then skip fetching the table's metadata and statistics,
+// and opening its indexes.
+type preparedInsert struct {
+	schema      *record.Schema
+	updateScan  scan.UpdateScan
+	indexes     []*metadata.IndexInfo
+	openIndexes []index.Index
+}
+
+// prepareInsert opens an update scan over the specified table, and the specified indexes of the table.
+func prepareInsert(tableName string, indexes []*metadata.IndexInfo, metadataManager *metadata.Manager, transaction *tx.Transaction) (*preparedInsert, error) {
+	tablePlan, err := NewTablePlan(transaction, tableName, metadataManager)
+	if err != nil {
+		return nil, err
+	}
+	tableScan, err := tablePlan.Open()
+	if err != nil {
+		return nil, err
+	}
+	updateScan, ok := tableScan.(scan.UpdateScan)
+	if !ok {
+		_ = tableScan.Close()
+		return nil, fmt.Errorf("table scan is not an update scan")
+	}
+
+	prepared := &preparedInsert{schema: tablePlan.Schema(), updateScan: updateScan, indexes: indexes}
+	for _, indexInfo := range indexes {
+		idx, err := indexInfo.Open()
+		if err != nil {
+			return nil, errors.Join(err, prepared.close())
+		}
+		prepared.openIndexes = append(prepared.openIndexes, idx)
+	}
+	return prepared, nil
+}
+
+// openIndex returns the open index of the prepared insert at the specified position of its indexes.
+func (pi *preparedInsert) openIndex(i int) (index.Index, error) {
+	return pi.openIndexes[i], nil
+}
+
+// insertRecord inserts a record having the specified values, and an index record into each index the record belongs in.
+// The fields of the table that are not among the specified ones are null.
+// If the record would have the same key as another record in a unique index, nothing is written,
+// and an error wrapping ErrDuplicateKey is returned.
+func (pi *preparedInsert) insertRecord(fields []string, vals []any, transaction *tx.Transaction) error {
+	// first, check that the keys of the record are unique, reading them from its values.
+	values := make(map[string]any, len(pi.schema.Fields()))
+	for _, field := range pi.schema.Fields() {
+		values[field] = nil
+	}
+	for i, field := range fields {
+		values[field] = vals[i]
+	}
+	noEntries := make([]indexEntry, len(pi.indexes))
+	entries, err := indexEntries(pi.indexes, &rowScan{values: values})
+	if err != nil {
+		return err
+	}
+	if err := checkUniqueEntries(transaction, pi.indexes, pi.openIndex, noEntries, entries, nil); err != nil {
+		return err
+	}
+
+	// then, insert the record.
+	updateScan := pi.updateScan
+	if err := updateScan.Insert(); err != nil {
+		return err
+	}
+	recordID, err := updateScan.GetRecordID()
+	if err != nil {
+		return err
+	}
+
+	// then set each field.
+	for i, field := range fields {
+		if err := updateScan.SetVal(field, vals[i]); err != nil {
+			return err
+		}
+	}
+	for _, field := range pi.schema.Fields() {
+		if !slices.Contains(fields, field) {
+			if err := updateScan.SetVal(field, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	// finally, insert an index record into each index the record belongs in.
+	return updateIndexEntries(pi.indexes, pi.openIndex, noEntries, entries, recordID)
+}
+
+// close closes the indexes and the update scan of the prepared insert.
+func (pi *preparedInsert) close() error {
+	for _, idx := range pi.openIndexes {
+		idx.Close()
+	}
+	return pi.updateScan.Close()
+}
+
+// preparedInserts holds the prepared inserts of each transaction, by table,
+// until they are released when the transaction ends or executes a DDL statement.
+// It is embedded in the update planners, and is safe for concurrent use by different transactions.
+type preparedInserts struct {
+	mu            sync.Mutex
+	byTransaction map[*tx.Transaction]map[string]*preparedInsert
+}
+
+// insert inserts a record into the specified table through the prepared insert of the transaction
+// for that table, preparing one with the specified function if there is none, and calling the specified
+// function to insert the record with it. If inserting the record fails, the prepared insert is closed and
+// discarded, since its scans may have been left in any state.
+func (p *preparedInserts) insert(tableName string, transaction *tx.Transaction, prepare func() (*preparedInsert, error), insert func(*preparedInsert) error) error {
+	prepared, err := p.get(tableName, transaction, prepare)
+	if err != nil {
+		return err
+	}
+	if err := insert(prepared); err != nil {
+		return errors.Join(err, p.discard(tableName, transaction))
+	}
+	return nil
+}
+
+// get returns the prepared insert of the transaction for the specified table,
+// preparing one with the specified function if there is none.
+func (p *preparedInserts) get(tableName string, transaction *tx.Transaction, prepare func() (*preparedInsert, error)) (*preparedInsert, error) {
+	p.mu.Lock()
+	prepared, ok := p.byTransaction[transaction][tableName]
+	p.mu.Unlock()
+	if ok {
+		return prepared, nil
+	}
+
+	// A transaction is used by a single goroutine at a time, so no other prepared insert
+	// can be added for the transaction while this one is prepared.
+	prepared, err := prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.byTransaction == nil {
+		p.byTransaction = make(map[*tx.Transaction]map[string]*preparedInsert)
+	}
+	if _, ok := p.byTransaction[transaction]; !ok {
+		p.byTransaction[transaction] = make(map[string]*preparedInsert)
+		// However the transaction ends, its prepared inserts are released then.
+		transaction.OnEnd(func() { _ = p.forget(transaction) })
+	}
+	p.byTransaction[transaction][tableName] = prepared
+	return prepared, nil
+}
+
+// discard closes and forgets the prepared insert of the transaction for the specified table, if any.
+func (p *preparedInserts) discard(tableName string, transaction *tx.Transaction) error {
+	p.mu.Lock()
+	prepared, ok := p.byTransaction[transaction][tableName]
+	delete(p.byTransaction[transaction], tableName)
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return prepared.close()
+}
+
+// ReleaseTransaction closes and forgets the prepared inserts of the specified transaction, such as before
+// it executes a DDL statement. They are released when the transaction ends without calling it.
+func (p *preparedInserts) ReleaseTransaction(transaction *tx.Transaction) error {
+	p.mu.Lock()
+	prepared := p.byTransaction[transaction]
+	if prepared != nil {
+		// The transaction is kept, so that its release is not registered again.
+		p.byTransaction[transaction] = make(map[string]*preparedInsert)
+	}
+	p.mu.Unlock()
+	return closePreparedInserts(prepared)
+}
+
+// forget closes the prepared inserts of the specified transaction, which ended, and forgets the transaction.
+func (p *preparedInserts) forget(transaction *tx.Transaction) error {
+	p.mu.Lock()
+	prepared := p.byTransaction[transaction]
+	delete(p.byTransaction, transaction)
+	p.mu.Unlock()
+	return closePreparedInserts(prepared)
+}
+
+// closePreparedInserts closes the specified prepared inserts.
+func closePreparedInserts(prepared map[string]*preparedInsert) error {
+	var errs []error
+	for _, preparedInsert := range prepared {
+		errs = append(errs, preparedInsert.close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,187 @@
+package plan_impl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// catalogBlocksRead returns the number of blocks read from the catalog tables.
+func catalogBlocksRead(fm *file.Manager) int {
+	reads := 0
+	for _, catalog := range []string{"table_catalog", "field_catalog", "index_catalog", "view_catalog"} {
+		reads += fm.GetBlocksReadFrom(catalog + ".tbl")
+	}
+	return reads
+}
+
+func TestPlanner_PreparedInserts(t *testing.T) {
+	planners := map[string]func(*metadata.Manager) UpdatePlanner{
+		"basic": NewBasicUpdatePlanner,
+		"index": NewIndexUpdatePlanner,
+	}
+
+	for name, newUpdatePlanner := range planners {
+		t.Run(name, func(t *testing.T) {
+			fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+			mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+			updatePlanner := newUpdatePlanner(mdm)
+			p := NewPlanner(NewBasicQueryPlanner(mdm), updatePlanner)
+			prepared := updatePlanner.(interface {
+				ReleaseTransaction(transaction *tx.Transaction) error
+			})
+
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			_, err := p.ExecuteUpdate("create table bulk (id int, name varchar(10))", txn)
+			require.NoError(t, err)
+			if name == "index" {
+				_, err = p.ExecuteUpdate("create index idx_id on bulk (id)", txn)
+				require.NoError(t, err)
+			}
+			require.NoError(t, txn.Commit())
+
+			const rows = 10000
+			insert := func(txn *tx.Transaction, id int) {
+				count, err := p.ExecuteUpdate(fmt.Sprintf("insert into bulk (id, name) values (%d, 'row %d')", id, id), txn)
+				require.NoError(t, err)
+				require.Equal(t, 1, count)
+			}
+
+			// Only the first insert of the transaction reads the table's metadata.
+			txn = tx.NewTransaction(fm, lm, bm, lt)
+			catalogReads := catalogBlocksRead(fm)
+			start := time.Now()
+			insert(txn, 0)
+			firstReads := catalogBlocksRead(fm) - catalogReads
+			for id := 1; id < rows; id++ {
+				insert(txn, id)
+			}
+			preparedDuration := time.Since(start) / rows
+			preparedReads := catalogBlocksRead(fm) - catalogReads
+			assert.Equal(t, firstReads, preparedReads, "catalog blocks read by the inserts following the first one")
+
+			// Releasing the prepared inserts after each statement makes every insert plan the table again:
+			// its metadata is read back from the catalog, which the statistics scan of the table evicted.
+			const unpreparedRows = 20
+			catalogReads = catalogBlocksRead(fm)
+			start = time.Now()
+			for id := rows; id < rows+unpreparedRows; id++ {
+				insert(txn, id)
+				require.NoError(t, prepared.ReleaseTransaction(txn))
+			}
+			unpreparedDuration := time.Since(start) / unpreparedRows
+			unpreparedReads := catalogBlocksRead(fm) - catalogReads
+			assert.GreaterOrEqual(t, unpreparedReads, unpreparedRows*firstReads/2)
+			t.Logf("prepared inserts: %v and %.4f catalog block reads per insert; unprepared inserts: %v and %.1f catalog block reads per insert",
+				preparedDuration, float64(preparedReads)/rows, unpreparedDuration, float64(unpreparedReads)/unpreparedRows)
+			require.NoError(t, txn.Commit())
+
+			// Every record was inserted, and indexed.
+			txn = tx.NewTransaction(fm, lm, bm, lt)
+			countRows := func(sql string) int {
+				queryPlan, err := p.CreateQueryPlan(sql, txn)
+				require.NoError(t, err)
+				s, err := queryPlan.Open()
+				require.NoError(t, err)
+				defer s.Close()
+				count := 0
+				for {
+					next, err := s.Next()
+					require.NoError(t, err)
+					if !next {
+						return count
+					}
+					count++
+				}
+			}
+			assert.Equal(t, rows+unpreparedRows, countRows("select id from bulk"))
+			assert.Equal(t, 1, countRows("select name from bulk where id = 4321"))
+			assert.Equal(t, 1, countRows("select name from bulk where id = 10005"))
+			require.NoError(t, txn.Commit())
+		})
+	}
+}
+
+func TestPlanner_PreparedInsertsAreReleased(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	updatePlanner := NewIndexUpdatePlanner(mdm).(*IndexUpdatePlanner)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), updatePlanner)
+
+	preparedTables := func(txn *tx.Transaction) int {
+		updatePlanner.preparedInserts.mu.Lock()
+		defer updatePlanner.preparedInserts.mu.Unlock()
+		return len(updatePlanner.preparedInserts.byTransaction[txn])
+	}
+	forgotten := func(txn *tx.Transaction) bool {
+		updatePlanner.preparedInserts.mu.Lock()
+		defer updatePlanner.preparedInserts.mu.Unlock()
+		_, ok := updatePlanner.preparedInserts.byTransaction[txn]
+		return !ok
+	}
+	countRows := func(txn *tx.Transaction, sql string) int {
+		queryPlan, err := p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err)
+		s, err := queryPlan.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		count := 0
+		for {
+			next, err := s.Next()
+			require.NoError(t, err)
+			if !next {
+				return count
+			}
+			count++
+		}
+	}
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table items (id int, kind int)",
+		"create table kinds (kind int)",
+		"insert into items (id, kind) values (1, 10)",
+		"insert into kinds (kind) values (10)",
+		"insert into items (id, kind) values (2, 20)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	assert.Equal(t, 2, preparedTables(txn), "each table has its prepared insert")
+
+	// A DDL statement releases the prepared inserts, so that the inserts that follow maintain the new index.
+	_, err := p.ExecuteUpdate("create index idx_kind on items (kind)", txn)
+	require.NoError(t, err)
+	assert.Zero(t, preparedTables(txn))
+	_, err = p.ExecuteUpdate("insert into items (id, kind) values (3, 30)", txn)
+	require.NoError(t, err)
+	assert.Equal(t, 1, countRows(txn, "select id from items where kind = 30"))
+	assert.Equal(t, 1, countRows(txn, "select id from items where kind = 10"))
+
+	// A failed insert discards the prepared insert of its table.
+	_, err = p.ExecuteUpdate("insert into items (id, kind) values ('four', 40)", txn)
+	assert.Error(t, err)
+	assert.Zero(t, preparedTables(txn))
+	require.NoError(t, txn.Commit())
+	assert.True(t, forgotten(txn), "the transaction is forgotten once it commits")
+
+	// Rolling back releases the prepared inserts too.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.ExecuteUpdate("insert into items (id, kind) values (5, 50)", txn)
+	require.NoError(t, err)
+	assert.Equal(t, 1, preparedTables(txn))
+	require.NoError(t, txn.Rollback())
+	assert.True(t, forgotten(txn), "the transaction is forgotten once it rolls back")
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	assert.Equal(t, 3, countRows(txn, "select id from items"))
+	assert.Zero(t, countRows(txn, "select id from items where kind = 50"))
+	require.NoError(t, txn.Commit())
+}
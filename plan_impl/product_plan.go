@@ -1,46 +1,90 @@
 package plan_impl
 
 import (
+	"errors"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
 )
 
 var _ plan.Plan = &ProductPlan{}
+var _ NodePlan = &ProductPlan{}
 
 type ProductPlan struct {
-	plan1  plan.Plan
-	plan2  plan.Plan
-	schema *record.Schema
+	transaction *tx.Transaction
+	plan1       plan.Plan
+	plan2       plan.Plan
+	schema      *record.Schema
 }
 
 // NewProductPlan creates a new product node in the query tree,
 // having the specified subqueries.
-func NewProductPlan(plan1 plan.Plan, plan2 plan.Plan) (*ProductPlan, error) {
-	pp := &ProductPlan{plan1: plan1, plan2: plan2, schema: record.NewSchema()}
+func NewProductPlan(transaction *tx.Transaction, plan1 plan.Plan, plan2 plan.Plan) (*ProductPlan, error) {
+	pp := &ProductPlan{transaction: transaction, plan1: plan1, plan2: plan2, schema: record.NewSchema()}
 	pp.schema.AddAll(plan1.Schema())
 	pp.schema.AddAll(plan2.Schema())
 	return pp, nil
 }
 
 // Open creates a product scan for this query.
+// The second subquery is materialized first if MaterializesRHS says it is cheaper.
 func (pp *ProductPlan) Open() (scan.Scan, error) {
 	s1, err := pp.plan1.Open()
 	if err != nil {
 		return nil, err
 	}
-	s2, err := pp.plan2.Open()
+	s2, err := pp.rhs().Open()
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(err, s1.Close())
+	}
+
+	// The product scan has to be positioned on the first LHS record before it is read.
+	productScan := query.NewProductScan(s1, s2)
+	if err := productScan.BeforeFirst(); err != nil {
+		return nil, errors.Join(err, productScan.Close())
+	}
+	return productScan, nil
+}
+
+// MaterializesRHS returns true if opening the product materializes the second subquery
+// into a temporary table, which is then read once for each record of the first subquery.
+// This is the case when re-executing the second subquery for each record of the first is
+// estimated to access more blocks than executing it once, writing its output to the temporary
+// table and reading that table for each record of the first subquery.
+// The decision is based on the current estimates of the subqueries, so it is made anew on each call.
+func (pp *ProductPlan) MaterializesRHS() bool {
+	return pp.materializedCost() < pp.rescanCost()
+}
+
+// rhs returns the plan that is read for each record of the first subquery.
+func (pp *ProductPlan) rhs() plan.Plan {
+	if pp.MaterializesRHS() {
+		return NewMaterializePlan(pp.transaction, pp.plan2)
 	}
-	return query.NewProductScan(s1, s2), nil
+	return pp.plan2
+}
+
+// rescanCost estimates the number of block accesses of executing the second subquery
+// for each record of the first.
+func (pp *ProductPlan) rescanCost() int {
+	return pp.plan1.RecordsOutput() * pp.plan2.BlocksAccessed()
+}
+
+// materializedCost estimates the number of block accesses of executing the second subquery once,
+// writing its output into a temporary table, and reading that table for each record of the first subquery.
+func (pp *ProductPlan) materializedCost() int {
+	tempBlocks := NewMaterializePlan(pp.transaction, pp.plan2).BlocksAccessed()
+	return pp.plan2.BlocksAccessed() + tempBlocks + pp.plan1.RecordsOutput()*tempBlocks
 }
 
 // BlocksAccessed estimates the number of block accesses in the product,
-// The formula is: blocks(plan1) + records(plan1) * blocks(plan2).
+// The formula is: blocks(plan1) + records(plan1) * blocks(plan2),
+// unless the second subquery is materialized, in which case the formula is:
+// blocks(plan1) + blocks(plan2) + (1 + records(plan1)) * blocks(materialized plan2).
 func (pp *ProductPlan) BlocksAccessed() int {
-	return pp.plan1.BlocksAccessed() + pp.plan1.RecordsOutput()*pp.plan2.BlocksAccessed()
+	return pp.plan1.BlocksAccessed() + min(pp.rescanCost(), pp.materializedCost())
 }
 
 // RecordsOutput estimates the number of records in the product.
@@ -64,3 +108,9 @@ func (pp *ProductPlan) DistinctValues(fieldName string) int {
 func (pp *ProductPlan) Schema() *record.Schema {
 	return pp.schema
 }
+
+// ToNode returns the description of the product plan and its inputs.
+// The second input is described as materialized if opening the product materializes it.
+func (pp *ProductPlan) ToNode() *PlanNode {
+	return newPlanNode("Product", pp, pp.plan1, pp.rhs())
+}
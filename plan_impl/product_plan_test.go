@@ -1,12 +1,25 @@
 package plan_impl
 
 import (
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 func TestProductPlan_Basic(t *testing.T) {
@@ -75,7 +88,7 @@ func TestProductPlan_Basic(t *testing.T) {
 	require.NoError(t, err)
 
 	// 4) Create a ProductPlan that combines "departments" x "employees"
-	productPlan, err := NewProductPlan(deptPlan, empPlan)
+	productPlan, err := NewProductPlan(txn, deptPlan, empPlan)
 	require.NoError(t, err)
 
 	// 5) Open the ProductPlan and verify the cross-join (Cartesian product)
@@ -155,3 +168,163 @@ func TestProductPlan_Basic(t *testing.T) {
 	assert.True(t, productSchema.HasField("emp_name"))
 	// Because both had a "dept_id" field, the schema just includes it once.
 }
+
+// countTempTables returns the number of temporary tables in the specified database directory.
+func countTempTables(t *testing.T, dbDir string) int {
+	entries, err := os.ReadDir(dbDir)
+	require.NoError(t, err)
+	count := 0
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "temp") {
+			count++
+		}
+	}
+	return count
+}
+
+// countRecords reads the whole scan and closes it, returning its number of records.
+func countRecords(t *testing.T, s scan.Scan) int {
+	defer func() { require.NoError(t, s.Close()) }()
+	count := 0
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			return count
+		}
+		count++
+	}
+}
+
+func TestProductPlan_MaterializesRHS(t *testing.T) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	txn := tx.NewTransaction(fm, lm, bm, concurrency.NewLockTable())
+	defer func() { require.NoError(t, txn.Commit()) }()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddIntField("tag")
+	mdm := createTableMetadata(t, txn, "big", schema)
+	require.NoError(t, mdm.CreateTable("small", schema, txn))
+
+	// "big" fills 100 blocks, with 4 records per tag. "small" fits in a block.
+	insert := func(tableName string, numRecords int) {
+		layout, err := mdm.GetLayout(tableName, txn)
+		require.NoError(t, err)
+		ts, err := table.NewTableScan(txn, tableName, layout)
+		require.NoError(t, err)
+		defer ts.Close()
+		for i := 0; i < numRecords; i++ {
+			require.NoError(t, ts.Insert())
+			require.NoError(t, ts.SetInt("id", i))
+			require.NoError(t, ts.SetInt("tag", i%(numRecords/4)))
+		}
+	}
+	layout, err := mdm.GetLayout("big", txn)
+	require.NoError(t, err)
+	insert("big", 100*(txn.BlockSize()/layout.SlotSize()))
+	insert("small", 5)
+
+	newTablePlan := func(tableName string) *TablePlan {
+		tablePlan, err := NewTablePlan(txn, tableName, mdm)
+		require.NoError(t, err)
+		return tablePlan
+	}
+	smallPlan := newTablePlan("small")
+	bigPlan := newTablePlan("big")
+	require.Equal(t, 100, bigPlan.BlocksAccessed())
+	selectivePlan, err := NewSelectPlan(bigPlan, query.NewPredicateFromTerm(
+		query.NewTerm(query.NewFieldExpression("tag"), query.NewConstantExpression(7), types.EQ)))
+	require.NoError(t, err)
+
+	// Re-executing the selection for each of the 5 records of "small" reads "big" 5 times,
+	// while materializing it reads "big" once and writes a single block.
+	productPlan, err := NewProductPlan(txn, smallPlan, selectivePlan)
+	require.NoError(t, err)
+	assert.True(t, productPlan.MaterializesRHS())
+	assert.Equal(t, 1+100+1+5, productPlan.BlocksAccessed())
+
+	tempTables := countTempTables(t, dbDir)
+	blocksRead := fm.GetBlocksRead()
+	s, err := productPlan.Open()
+	require.NoError(t, err)
+	assert.Equal(t, tempTables+1, countTempTables(t, dbDir), "the selection should be materialized")
+	assert.Equal(t, 5*4, countRecords(t, s))
+	materializedReads := fm.GetBlocksRead() - blocksRead
+	assert.Equal(t, tempTables, countTempTables(t, dbDir), "the materialized selection should be removed with its scan")
+
+	// The same product without materialization.
+	blocksRead = fm.GetBlocksRead()
+	openPlan := func(p plan.Plan) scan.Scan {
+		s, err := p.Open()
+		require.NoError(t, err)
+		return s
+	}
+	productScan := query.NewProductScan(openPlan(smallPlan), openPlan(selectivePlan))
+	require.NoError(t, productScan.BeforeFirst())
+	assert.Equal(t, 5*4, countRecords(t, productScan))
+	rescanReads := fm.GetBlocksRead() - blocksRead
+	assert.Less(t, materializedReads, rescanReads/2)
+
+	// A table that fits in a block is cheaper to re-read than to materialize.
+	productPlan, err = NewProductPlan(txn, smallPlan, newTablePlan("small"))
+	require.NoError(t, err)
+	assert.False(t, productPlan.MaterializesRHS())
+	assert.Equal(t, 1+5*1, productPlan.BlocksAccessed())
+
+	tempTables = countTempTables(t, dbDir)
+	s, err = productPlan.Open()
+	require.NoError(t, err)
+	assert.Equal(t, 5*5, countRecords(t, s))
+	assert.Equal(t, tempTables, countTempTables(t, dbDir))
+}
+
+func TestProductPlan_OpenReadsEveryPair(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewBasicUpdatePlanner(mdm))
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table colors (color varchar(10))",
+		"create table sizes (size int)",
+		"insert into colors (color) values ('red')",
+		"insert into colors (color) values ('blue')",
+		"insert into sizes (size) values (1)",
+		"insert into sizes (size) values (2)",
+		"insert into sizes (size) values (3)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	// The scan returned by Open is read without positioning it first, as the driver does.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	queryPlan, err := p.CreateQueryPlan("select color, size from colors, sizes", txn)
+	require.NoError(t, err)
+	s, err := queryPlan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	var rows []string
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		color, err := s.GetString("color")
+		require.NoError(t, err)
+		size, err := s.GetInt("size")
+		require.NoError(t, err)
+		rows = append(rows, fmt.Sprintf("%s %d", color, size))
+	}
+	assert.ElementsMatch(t, []string{"red 1", "red 2", "red 3", "blue 1", "blue 2", "blue 3"}, rows)
+}
@@ -1,6 +1,10 @@
 package plan_impl
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
@@ -8,31 +12,110 @@ import (
 )
 
 var _ plan.Plan = &ProjectPlan{}
+var _ NodePlan = &ProjectPlan{}
 
 type ProjectPlan struct {
 	inputPlan plan.Plan
 	schema    *record.Schema
+	// aliases maps the fields of the projection that are renamed to the fields of the subquery they read.
+	aliases map[string]string
+	// computed maps the fields of the projection computed from the fields of the subquery to their expression.
+	computed map[string]*query.Expression
+	// qualifier qualifies the fields of the input of a qualified query that the projection outputs under their
+	// qualified names (see qualifyInputs), or is empty for any other projection.
+	qualifier string
 }
 
 // NewProjectPlan creates a new project node in the query tree,
 // having the specified subquery and field list.
 func NewProjectPlan(inputPlan plan.Plan, fieldList []string) (*ProjectPlan, error) {
-	pp := &ProjectPlan{inputPlan: inputPlan, schema: record.NewSchema()}
+	return NewProjectPlanWithAliases(inputPlan, fieldList, nil)
+}
+
+// NewProjectPlanWithAliases creates a new project node in the query tree, having the specified subquery
+// and field list, in which the aliases map a field renamed by the projection to the field of the subquery it reads.
+func NewProjectPlanWithAliases(inputPlan plan.Plan, fieldList []string, aliases map[string]string) (*ProjectPlan, error) {
+	return NewProjectPlanWithExpressions(inputPlan, fieldList, aliases, nil)
+}
+
+// NewProjectPlanWithExpressions creates a new project node like NewProjectPlanWithAliases, in which the computed
+// fields of the field list, or the fields an alias maps to them, are computed by their expression from the fields
+// of the subquery. It returns an error if a field that is not computed, or the field its alias renames, is not
+// in the schema of the subquery, or if the type of an expression cannot be known from the schema of the
+// subquery, wrapping query.ErrTypeMismatch if an arithmetic operation has an operand that is not a number.
+func NewProjectPlanWithExpressions(inputPlan plan.Plan, fieldList []string, aliases map[string]string, computed map[string]*query.Expression) (*ProjectPlan, error) {
+	pp := &ProjectPlan{inputPlan: inputPlan, schema: record.NewSchema(), aliases: aliases, computed: computed}
 
+	inputSchema := inputPlan.Schema()
 	for _, fieldName := range fieldList {
-		pp.schema.Add(fieldName, inputPlan.Schema())
+		inputField := pp.inputField(fieldName)
+		if expression, ok := computed[inputField]; ok {
+			fieldType, err := expression.ResultType(inputSchema)
+			if err != nil {
+				return nil, err
+			}
+			pp.schema.AddField(fieldName, fieldType, 0)
+			continue
+		}
+		if !inputSchema.HasField(inputField) {
+			return nil, fmt.Errorf("projection reads field %s, which is not in the schema of its input (fields %s)",
+				inputField, strings.Join(inputSchema.Fields(), ", "))
+		}
+		pp.schema.AddField(fieldName, inputSchema.Type(inputField), inputSchema.Length(inputField))
 	}
 
 	return pp, nil
 }
 
-// Open creates a project scan for this query.
+// inputField returns the field of the subquery read as the specified field of the projection.
+func (pp *ProjectPlan) inputField(fieldName string) string {
+	if inputField, ok := pp.aliases[fieldName]; ok {
+		return inputField
+	}
+	return fieldName
+}
+
+// Open creates a project scan for this query. The fields the projection reads are pushed into the scan of
+// the table below it, if the input is a table or a selection of one, so that the table scan only reads them.
 func (pp *ProjectPlan) Open() (scan.Scan, error) {
-	inputScan, err := pp.inputPlan.Open()
+	inputScan, err := openReading(pp.inputPlan, pp.inputFields())
 	if err != nil {
 		return nil, err
 	}
-	return query.NewProjectScan(inputScan, pp.schema.Fields())
+	return query.NewProjectScanWithExpressions(inputScan, pp.schema.Fields(), pp.aliases, pp.computed)
+}
+
+// inputFields returns the fields of the subquery the projection reads,
+// which are the fields it renames or outputs unchanged, and the fields of its expressions.
+func (pp *ProjectPlan) inputFields() []string {
+	var fields []string
+	for _, fieldName := range pp.schema.Fields() {
+		inputField := pp.inputField(fieldName)
+		if expression, ok := pp.computed[inputField]; ok {
+			fields = append(fields, expression.Fields()...)
+		} else {
+			fields = append(fields, inputField)
+		}
+	}
+	return fields
+}
+
+// openReading opens the specified plan for a consumer that only reads the specified fields of its records.
+// A table plan opens a scan of these fields only (see TablePlan#OpenFields), and a selection of a table plan
+// opens one of these fields and the fields of its predicate. Any other plan is opened as usual.
+func openReading(inputPlan plan.Plan, fields []string) (scan.Scan, error) {
+	switch p := inputPlan.(type) {
+	case *TablePlan:
+		return p.OpenFields(fields)
+	case *SelectPlan:
+		inputScan, err := openReading(p.inputPlan, append(slices.Clone(fields), p.predicate.Fields()...))
+		if err != nil {
+			return nil, err
+		}
+		return query.NewSelectScan(inputScan, p.predicate)
+	default:
+		return inputPlan.Open()
+	}
 }
 
 // BlocksAccessed estimates the number of block accesses in the projection,
@@ -48,9 +131,18 @@ func (pp *ProjectPlan) RecordsOutput() int {
 }
 
 // DistinctValues estimates the number of distinct values in the projection,
-// which is the same as in the underlying query.
+// which is the same as in the underlying query. A computed field is estimated
+// to have as many values as the field of its expression with the most values.
 func (pp *ProjectPlan) DistinctValues(fieldName string) int {
-	return pp.inputPlan.DistinctValues(fieldName)
+	expression, ok := pp.computed[pp.inputField(fieldName)]
+	if !ok {
+		return pp.inputPlan.DistinctValues(pp.inputField(fieldName))
+	}
+	values := 1
+	for _, field := range expression.Fields() {
+		values = max(values, pp.inputPlan.DistinctValues(field))
+	}
+	return values
 }
 
 // Schema returns the schema of the projection,
@@ -58,3 +150,16 @@ func (pp *ProjectPlan) DistinctValues(fieldName string) int {
 func (pp *ProjectPlan) Schema() *record.Schema {
 	return pp.schema
 }
+
+// ToNode returns the description of the project plan and its input.
+func (pp *ProjectPlan) ToNode() *PlanNode {
+	node := newPlanNode("Project", pp, pp.inputPlan)
+	for _, fieldName := range pp.schema.Fields() {
+		if inputField := pp.inputField(fieldName); inputField != fieldName {
+			node.Fields = append(node.Fields, inputField+" as "+fieldName)
+		} else {
+			node.Fields = append(node.Fields, fieldName)
+		}
+	}
+	return node
+}
@@ -1,12 +1,17 @@
 package plan_impl
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 func TestProjectPlan_Basic(t *testing.T) {
@@ -107,3 +112,201 @@ func TestProjectPlan_Basic(t *testing.T) {
 	assert.False(t, schema.HasField("active"))
 	assert.Len(t, schema.Fields(), 2, "Schema should only have 2 fields in the projection")
 }
+
+// setupArithmeticTest creates employees with a salary, a bonus, which one of them lacks, and a number of months.
+func setupArithmeticTest(t *testing.T) (*Planner, *tx.Transaction) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE emp (ename VARCHAR(10), salary INT, bonus FLOAT, months INT)",
+		"INSERT INTO emp (ename, salary, bonus, months) VALUES ('alice', 100, 0.5, 12)",
+		"INSERT INTO emp (ename, salary, bonus, months) VALUES ('bob', 200, null, 0)",
+		"INSERT INTO emp (ename, salary, bonus, months) VALUES ('carol', 300, 1.5, 6)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	t.Cleanup(func() { require.NoError(t, txn.Commit()) })
+	return p, txn
+}
+
+func TestProjectPlan_UnknownField(t *testing.T) {
+	txn, cleanup := setupTestEnvironment(t, 800, 8)
+	defer cleanup()
+	mdm := createTableMetadataWithSchema(t, txn, "users", map[string]interface{}{"id": 0, "name": "string"})
+	tp, err := NewTablePlan(txn, "users", mdm)
+	require.NoError(t, err)
+
+	// A field of the projection, or the field an alias renames, must be a field of its input.
+	_, err = NewProjectPlan(tp, []string{"id", "email"})
+	assert.ErrorContains(t, err, "projection reads field email, which is not in the schema of its input")
+	_, err = NewProjectPlanWithAliases(tp, []string{"mail"}, map[string]string{"mail": "email"})
+	assert.ErrorContains(t, err, "projection reads field email")
+
+	// A computed field is not a field of the input, and is typed by its expression instead.
+	computed := map[string]*query.Expression{"next": query.NewArithmeticExpression(
+		query.NewFieldExpression("id"), types.ADD, query.NewConstantExpression(1))}
+	pp, err := NewProjectPlanWithExpressions(tp, []string{"next"}, nil, computed)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"next"}, pp.Schema().Fields())
+}
+
+func TestProjectPlan_ComputedFields(t *testing.T) {
+	p, txn := setupArithmeticTest(t)
+
+	// Integer arithmetic stays integral, and a null operand makes the result null.
+	sql := "SELECT ename, salary * 12 + 1 AS annual, salary + salary * bonus, (salary - 50) / 100 FROM emp WHERE salary * 2 > 250"
+	assert.Equal(t, [][]any{
+		{"bob", 2401, nil, 1},
+		{"carol", 3601, 750.0, 2},
+	}, queryRecords(t, p, sql, txn, "ename", "annual", "salary + salary * bonus", "(salary - 50) / 100"))
+
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ename", "annual", "salary + salary * bonus", "(salary - 50) / 100"}, queryPlan.Schema().Fields())
+	assert.Equal(t, types.Integer, queryPlan.Schema().Type("annual"))
+	assert.Equal(t, types.Float, queryPlan.Schema().Type("salary + salary * bonus"))
+
+	// Dividing by zero is an error of the scan, whether the quotient is selected or compared.
+	for _, sql := range []string{"SELECT ename, salary / months FROM emp", "SELECT ename FROM emp WHERE salary / months > 1"} {
+		assert.ErrorIs(t, readError(t, p, sql, txn), types.ErrDivisionByZero, sql)
+	}
+
+	// The operands of arithmetic operations must be numbers.
+	_, err = p.CreateQueryPlan("SELECT ename * 2 FROM emp", txn)
+	assert.ErrorIs(t, err, query.ErrTypeMismatch)
+	_, err = p.CreateQueryPlan("SELECT ename FROM emp WHERE ename + 1 > 2", txn)
+	assert.ErrorIs(t, err, query.ErrTypeMismatch)
+}
+
+// readError plans the query and reads each field of its records, returning the first error of its scan.
+func readError(t *testing.T, p *Planner, sql string, txn *tx.Transaction) error {
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err, sql)
+	s, err := queryPlan.Open()
+	require.NoError(t, err, sql)
+	defer s.Close()
+	for {
+		hasNext, err := s.Next()
+		if err != nil || !hasNext {
+			return err
+		}
+		for _, field := range queryPlan.Schema().Fields() {
+			if _, err := s.GetVal(field); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// setupWideTable creates a table of 20 integer fields, c0 to c19, whose record i holds i*100+j in field cj.
+func setupWideTable(tb testing.TB, records int) (*TablePlan, func()) {
+	txn, cleanup := setupTestEnvironment(tb, 4096, 8)
+	schema := record.NewSchema()
+	for j := range 20 {
+		schema.AddIntField(fmt.Sprintf("c%d", j))
+	}
+	mdm := createTableMetadata(tb, txn, "wide", schema)
+
+	tp, err := NewTablePlan(txn, "wide", mdm)
+	require.NoError(tb, err)
+	s, err := tp.Open()
+	require.NoError(tb, err)
+	us := s.(scan.UpdateScan)
+	for i := range records {
+		require.NoError(tb, us.Insert())
+		for j := range 20 {
+			require.NoError(tb, us.SetInt(fmt.Sprintf("c%d", j), i*100+j))
+		}
+	}
+	require.NoError(tb, s.Close())
+	return tp, cleanup
+}
+
+func TestProjectPlan_PushesFieldsIntoTableScan(t *testing.T) {
+	tp, cleanup := setupWideTable(t, 50)
+	defer cleanup()
+
+	predicate := query.NewPredicateFromTerm(query.NewTerm(query.NewFieldExpression("c7"), query.NewConstantExpression(707), types.GE))
+	sp, err := NewSelectPlan(tp, predicate)
+	require.NoError(t, err)
+	pp, err := NewProjectPlanWithExpressions(sp, []string{"c1", "total"}, map[string]string{"total": "c2 + c3"},
+		map[string]*query.Expression{"c2 + c3": query.NewArithmeticExpression(query.NewFieldExpression("c2"), types.ADD, query.NewFieldExpression("c3"))})
+	require.NoError(t, err)
+
+	// The table scan below the projection only has the fields read by the projection and the selection.
+	inputScan, err := openReading(sp, pp.inputFields())
+	require.NoError(t, err)
+	var fields []string
+	for _, field := range inputScan.Fields() {
+		fields = append(fields, field.Name)
+	}
+	assert.ElementsMatch(t, []string{"c1", "c2", "c3", "c7"}, fields)
+	assert.True(t, inputScan.HasField("c7"))
+	assert.False(t, inputScan.HasField("c4"))
+	require.NoError(t, inputScan.Close())
+
+	s, err := pp.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	var rows [][]any
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		c1, err := s.GetVal("c1")
+		require.NoError(t, err)
+		total, err := s.GetVal("total")
+		require.NoError(t, err)
+		rows = append(rows, []any{c1, total})
+	}
+	// Records 7 to 49 satisfy the predicate, and their fields are read at the offsets of the table's layout.
+	require.Len(t, rows, 43)
+	assert.Equal(t, []any{701, 1405}, rows[0])
+	assert.Equal(t, []any{4901, 9805}, rows[42])
+
+	// A scan of every field of the table is opened as usual, and can change records.
+	s, err = openReading(tp, tp.Schema().Fields())
+	require.NoError(t, err)
+	defer s.Close()
+	_, ok := s.(scan.UpdateScan)
+	assert.True(t, ok)
+	assert.Len(t, s.Fields(), 20)
+}
+
+// BenchmarkProjectPlan_WideTable compares reading two fields of a table of 20 fields with the fields
+// pushed into the table scan, and with a scan of every field, as a consumer of the input's fields does.
+func BenchmarkProjectPlan_WideTable(b *testing.B) {
+	tp, cleanup := setupWideTable(b, 500)
+	defer cleanup()
+
+	for name, open := range map[string]func() (scan.Scan, error){
+		"pushdown":   func() (scan.Scan, error) { return tp.OpenFields([]string{"c3", "c17"}) },
+		"all_fields": tp.Open,
+	} {
+		b.Run(name, func(b *testing.B) {
+			for range b.N {
+				s, err := open()
+				require.NoError(b, err)
+				for {
+					hasNext, err := s.Next()
+					require.NoError(b, err)
+					if !hasNext {
+						break
+					}
+					for _, field := range s.Fields() {
+						_, err := s.GetVal(field.Name)
+						require.NoError(b, err)
+					}
+				}
+				require.NoError(b, s.Close())
+			}
+		})
+	}
+}
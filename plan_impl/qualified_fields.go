@@ -0,0 +1,143 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+)
+
+// ErrAmbiguousField is wrapped by the error returned when a query reads a field without qualifying it
+// (see parse.QualifiedField) that several of the tables it reads have, such as the fields of a table joined with itself.
+var ErrAmbiguousField = errors.New("ambiguous field")
+
+// inputPredicate returns the predicate choosing the access path of the specified input of the query, and pushed
+// into it if it is a view (see expandView). It is the predicate of the query, unless the query is qualified
+// (see parse.QueryData#Qualified): it is then a new predicate conjoining the conjuncts that only read fields
+// qualified by the qualifier of the input, each renamed to the field of the input it stands for.
+func inputPredicate(queryData *parse.QueryData, input int) *query.Predicate {
+	if !queryData.Qualified() {
+		return queryData.Pred()
+	}
+	prefix := parse.QualifiedField(queryData.Qualifiers()[input], "")
+	names := make(map[string]string)
+	var local []*query.Predicate
+	for _, conjunct := range queryData.Pred().Conjuncts() {
+		fields := conjunct.Fields()
+		if len(fields) == 0 || slices.ContainsFunc(fields, func(field string) bool { return !strings.HasPrefix(field, prefix) }) {
+			continue
+		}
+		for _, field := range fields {
+			names[field] = strings.TrimPrefix(field, prefix)
+		}
+		local = append(local, conjunct)
+	}
+	selection := conjunctionOf(local)
+	if selection == nil {
+		return query.NewPredicate()
+	}
+	return selection.RenameFields(names)
+}
+
+// readFields returns the fields the query reads from the tables of its FROM clause: the fields of its select list
+// and of their arithmetic expressions, of its predicate and subquery conditions, of its GROUP BY, HAVING and
+// ORDER BY clauses, and the fields it aggregates. The wildcards of the select list are left out.
+func readFields(queryData *parse.QueryData) []string {
+	var fields []string
+	for _, field := range queryData.Fields() {
+		if expression, ok := queryData.Computed()[field]; ok {
+			fields = append(fields, expression.Fields()...)
+		} else if !isWildcard(field) {
+			fields = append(fields, field)
+		}
+	}
+	fields = append(fields, queryData.Pred().Fields()...)
+	for _, semiJoin := range queryData.SemiJoins() {
+		fields = append(fields, semiJoin.Field())
+	}
+	fields = append(fields, queryData.GroupBy()...)
+	fields = append(fields, queryData.Having().Fields()...)
+	for _, item := range queryData.OrderBy() {
+		fields = append(fields, item.Field())
+	}
+	for _, aggregate := range queryData.Aggregates() {
+		if field := aggregate.AggregatedField(); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// checkAmbiguousFields returns an error wrapping ErrAmbiguousField if one of the fields the query reads
+// is not qualified and several of its inputs have it, naming the qualified fields it could stand for.
+// The plans are those of the inputs of the query, in the order of its FROM clause.
+func checkAmbiguousFields(queryData *parse.QueryData, fields []string, plans []plan.Plan) error {
+	qualifiers := queryData.Qualifiers()
+	for _, field := range fields {
+		var candidates []string
+		for i, p := range plans {
+			if !p.Schema().HasField(field) {
+				continue
+			}
+			candidate := parse.QualifiedField(qualifiers[i], field)
+			if slices.Contains(candidates, candidate) {
+				return fmt.Errorf("%w: field %s is a field of two tables named %s, which need aliases to qualify it",
+					ErrAmbiguousField, field, qualifiers[i])
+			}
+			candidates = append(candidates, candidate)
+		}
+		if len(candidates) > 1 {
+			return fmt.Errorf("%w: field %s could be %s", ErrAmbiguousField, field, strings.Join(candidates, " or "))
+		}
+	}
+	return nil
+}
+
+// qualifyInputs returns the inputs of a qualified query (see parse.QueryData#Qualified), each below a projection
+// outputting its fields under their qualified names, such as e.salary, and also under their own names if no
+// other input has a field of that name. A table below such a projection is still joined through its indexes
+// (see joinPlans). It returns an error wrapping ErrAmbiguousField if two inputs have the same qualifier.
+func qualifyInputs(queryData *parse.QueryData, plans []plan.Plan) ([]plan.Plan, error) {
+	qualifiers := queryData.Qualifiers()
+	qualified := make([]plan.Plan, len(plans))
+	for i, p := range plans {
+		if slices.Index(qualifiers, qualifiers[i]) < i {
+			return nil, fmt.Errorf("%w: %s qualifies two tables of the FROM clause, which need aliases",
+				ErrAmbiguousField, qualifiers[i])
+		}
+		var fields []string
+		aliases := make(map[string]string)
+		for _, field := range p.Schema().Fields() {
+			qualifiedField := parse.QualifiedField(qualifiers[i], field)
+			fields = append(fields, qualifiedField)
+			aliases[qualifiedField] = field
+			if inputsHaving(plans, field) == 1 {
+				fields = append(fields, field)
+			}
+		}
+		projectPlan, err := NewProjectPlanWithAliases(p, fields, aliases)
+		if err != nil {
+			return nil, err
+		}
+		projectPlan.qualifier = qualifiers[i]
+		qualified[i] = projectPlan
+	}
+	return qualified, nil
+}
+
+// qualifyJoin returns the projection of a join of the outer plan with the table of an input of a qualified query,
+// which the join reads below the projection qualifying the fields of the input (see joinPlans), outputting the
+// fields of the outer plan and the fields of the qualifying projection. The fields of the table cannot clash with
+// those of the outer plan, which are either qualified or fields that only the other inputs have.
+func qualifyJoin(joinPlan, outerPlan plan.Plan, qualifying *ProjectPlan) (plan.Plan, error) {
+	fields := append(slices.Clone(outerPlan.Schema().Fields()), qualifying.Schema().Fields()...)
+	projectPlan, err := NewProjectPlanWithAliases(joinPlan, fields, qualifying.aliases)
+	if err != nil {
+		return nil, err
+	}
+	return projectPlan, nil
+}
@@ -0,0 +1,126 @@
+package plan_impl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+func TestBasicQueryPlanner_QualifiedFields(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE TABLE emp (id INT, name VARCHAR(10), manager INT)",
+		"INSERT INTO emp (id, name, manager) VALUES (1, 'ada', 0), (2, 'bob', 1), (3, 'cy', 1), (4, 'di', 2)",
+		"CREATE TABLE dept (id INT, title VARCHAR(10))",
+		"INSERT INTO dept (id, title) VALUES (1, 'ops'), (2, 'dev')",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	require.NoError(t, txn.Commit())
+
+	// A table joined with itself reads each of its fields under the alias of each of its reads.
+	rows := runPlannerQuery(t, p, "SELECT e1.name, e2.name AS boss FROM emp e1, emp AS e2 WHERE e1.manager = e2.id AND e2.id > 1",
+		fm, lm, bm, lt, []string{"e1.name", "boss"})
+	assert.Equal(t, []map[string]any{{"e1.name": "di", "boss": "bob"}}, rows)
+	rows = runPlannerQuery(t, p, "SELECT e2.name, COUNT(e1.id) FROM emp e1, emp e2 WHERE e1.manager = e2.id GROUP BY e2.name",
+		fm, lm, bm, lt, []string{"e2.name", "countOfe1.id"})
+	assert.ElementsMatch(t, []map[string]any{{"e2.name": "ada", "countOfe1.id": int64(2)}, {"e2.name": "bob", "countOfe1.id": int64(1)}}, rows)
+
+	// A field only one input has can be read without qualifying it, and a table without an alias is qualified by its name.
+	rows = runPlannerQuery(t, p, "SELECT name, dept.title FROM emp, dept WHERE emp.id = dept.id ORDER BY name",
+		fm, lm, bm, lt, []string{"name", "dept.title"})
+	assert.Equal(t, []map[string]any{{"name": "ada", "dept.title": "ops"}, {"name": "bob", "dept.title": "dev"}}, rows)
+
+	// The wildcards of a qualified query expand to qualified names for the fields several inputs have.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	queryPlan, err := p.CreateQueryPlan("SELECT * FROM emp e1, emp e2", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e1.id", "e1.name", "e1.manager", "e2.id", "e2.name", "e2.manager"}, queryPlan.Schema().Fields())
+	queryPlan, err = p.CreateQueryPlan("SELECT d.* FROM emp e, dept d WHERE e.id = d.id", txn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d.id", "title"}, queryPlan.Schema().Fields())
+
+	// A field several inputs have is rejected unless it is qualified, naming the fields it could stand for.
+	for sql, expected := range map[string]string{
+		"SELECT name FROM emp e1, emp e2":                     "ambiguous field: field name could be e1.name or e2.name",
+		"SELECT e1.name FROM emp e1, emp e2 WHERE id = 1":     "ambiguous field: field id could be e1.id or e2.id",
+		"SELECT title FROM emp, dept ORDER BY id":             "ambiguous field: field id could be emp.id or dept.id",
+		"SELECT name FROM emp, emp":                           "ambiguous field: field name is a field of two tables named emp, which need aliases to qualify it",
+		"SELECT emp.name FROM emp, emp":                       "ambiguous field: emp qualifies two tables of the FROM clause, which need aliases",
+		"SELECT e3.name FROM emp e1, emp e2":                  "unknown field: select list field e3.name is not a field of tables emp, emp",
+		"SELECT name FROM emp e1, dept d WHERE e1.id = d.ttl": "reads field d.ttl, which is not in the schema of its input",
+	} {
+		_, err := p.CreateQueryPlan(sql, txn)
+		if assert.Error(t, err, sql) {
+			assert.Contains(t, err.Error(), expected, sql)
+		}
+	}
+}
+
+func TestBasicQueryPlanner_TableAliases(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	// Employee i is in department i%2 and is managed by employee i/10.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE TABLE emp (id INT, name VARCHAR(10), manager_id INT, dept INT)",
+		"CREATE INDEX emp_id ON emp (id)",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	var values []string
+	for i := 0; i < 100; i++ {
+		values = append(values, fmt.Sprintf("(%d, 'e%d', %d, %d)", i, i, i/10, i%2))
+	}
+	_, err := p.ExecuteUpdate("INSERT INTO emp (id, name, manager_id, dept) VALUES "+strings.Join(values, ", "), txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	managers := func(visible func(id int) bool) []map[string]any {
+		var expected []map[string]any
+		for i := 0; i < 100; i++ {
+			if visible(i) && visible(i/10) {
+				expected = append(expected, map[string]any{"e.name": fmt.Sprintf("e%d", i), "m.name": fmt.Sprintf("e%d", i/10)})
+			}
+		}
+		return expected
+	}
+	explain := func(sql string) string {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		explanation, err := p.Explain(sql, txn)
+		require.NoError(t, err)
+		return explanation
+	}
+
+	// The same table is read twice under different aliases, the second time through its index on the join field.
+	sql := "SELECT e.name, m.name FROM emp e, emp m WHERE e.manager_id = m.id"
+	assert.ElementsMatch(t, managers(func(int) bool { return true }), runPlannerQuery(t, p, sql, fm, lm, bm, lt, []string{"e.name", "m.name"}))
+	assert.Contains(t, explain(sql), "IndexJoin table=emp index=emp_id")
+
+	// Alias-qualified fields are grouped and ordered by.
+	rows := runPlannerQuery(t, p, "SELECT m.name, COUNT(e.id) FROM emp AS e, emp AS m WHERE e.manager_id = m.id AND m.id < 3 GROUP BY m.name ORDER BY m.name DESC",
+		fm, lm, bm, lt, []string{"m.name", "countOfe.id"})
+	assert.Equal(t, []map[string]any{
+		{"m.name": "e2", "countOfe.id": int64(10)}, {"m.name": "e1", "countOfe.id": int64(10)}, {"m.name": "e0", "countOfe.id": int64(10)},
+	}, rows)
+
+	// The filter of the table applies to both of its reads, including the one through its index.
+	require.NoError(t, p.RegisterTableFilter("emp", func(*tx.Transaction) (*query.Predicate, error) {
+		return query.NewPredicateFromTerm(query.NewTerm(query.NewFieldExpression("dept"), query.NewConstantExpression(0), types.EQ)), nil
+	}))
+	assert.ElementsMatch(t, managers(func(id int) bool { return id%2 == 0 }), runPlannerQuery(t, p, sql, fm, lm, bm, lt, []string{"e.name", "m.name"}))
+	assert.Contains(t, explain(sql), "IndexJoin table=emp index=emp_id")
+}
@@ -1,6 +1,8 @@
 package plan_impl
 
 import (
+	"io"
+
 	"github.com/JyotinderSingh/dropdb/parse"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/tx"
@@ -11,3 +13,56 @@ type QueryPlanner interface {
 	// CreatePlan creates a query plan for the specified query data.
 	CreatePlan(queryData *parse.QueryData, transaction *tx.Transaction) (plan.Plan, error)
 }
+
+// IndexAdvisor is implemented by query planners that can report
+// which indexes they considered for each table read by a query.
+type IndexAdvisor interface {
+	// IndexCandidates returns the access path chosen for each table read by the query.
+	IndexCandidates(queryData *parse.QueryData, transaction *tx.Transaction) ([]*AccessPath, error)
+}
+
+// StatementStatsRecorder is implemented by query planners that record how they planned the last query of each
+// transaction (see StatementStats).
+type StatementStatsRecorder interface {
+	// StartStatement forgets the stats of the last query planned by the transaction, before it plans another one.
+	StartStatement(transaction *tx.Transaction)
+	// StatementStats returns the stats of the last query planned by the transaction, and false if it planned none.
+	StatementStats(transaction *tx.Transaction) (*StatementStats, bool)
+}
+
+// BlockDumper is implemented by query planners that can plan DUMP BLOCK statements.
+type BlockDumper interface {
+	// CreateDumpBlockPlan creates a plan that returns the physical contents of the specified block.
+	CreateDumpBlockPlan(data *parse.DumpBlockData, transaction *tx.Transaction) (plan.Plan, error)
+}
+
+// ConstraintLister is implemented by query planners that can plan SHOW CONSTRAINTS statements.
+type ConstraintLister interface {
+	// CreateShowConstraintsPlan creates a plan that returns the constraints of the specified table.
+	CreateShowConstraintsPlan(data *parse.ShowConstraintsData, transaction *tx.Transaction) (plan.Plan, error)
+}
+
+// TableChecker is implemented by query planners that can plan CHECK TABLE statements.
+type TableChecker interface {
+	// CreateCheckTablePlan creates a plan that returns the inconsistencies found in the specified table.
+	CreateCheckTablePlan(data *parse.CheckTableData, transaction *tx.Transaction) (plan.Plan, error)
+}
+
+// StatsReader is implemented by query planners that can plan queries reading the StatsTable.
+type StatsReader interface {
+	// SetStatsSource sets the function returning the stats of the database that the StatsTable holds.
+	SetStatsSource(source func() DatabaseStats)
+}
+
+// ParallelScanner is implemented by query planners that can read a table with several worker goroutines.
+type ParallelScanner interface {
+	// SetScanParallelism sets the number of workers reading a table in parallel, each in a read-only
+	// transaction returned by newTransaction. A single worker disables parallel scans.
+	SetScanParallelism(workers int, newTransaction func() *tx.Transaction)
+}
+
+// DatabaseDumper is implemented by query planners that can execute DUMP DATABASE statements.
+type DatabaseDumper interface {
+	// DumpDatabase writes the script recreating the database to the writer, and returns the number of records it dumped.
+	DumpDatabase(w io.Writer, transaction *tx.Transaction) (int, error)
+}
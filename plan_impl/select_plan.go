@@ -1,6 +1,9 @@
 package plan_impl
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/record"
@@ -9,6 +12,7 @@ import (
 )
 
 var _ plan.Plan = &SelectPlan{}
+var _ NodePlan = &SelectPlan{}
 
 type SelectPlan struct {
 	inputPlan plan.Plan
@@ -17,11 +21,21 @@ type SelectPlan struct {
 
 // NewSelectPlan creates a new select node in the query tree,
 // having the specified subquery and predicate.
-func NewSelectPlan(inputPlan plan.Plan, predicate *query.Predicate) *SelectPlan {
+// It returns an error if the predicate reads a field that is not in the schema of the subquery,
+// such as a field removed by a projection below the selection, since the predicate could not be
+// evaluated against any of its records.
+func NewSelectPlan(inputPlan plan.Plan, predicate *query.Predicate) (*SelectPlan, error) {
+	schema := inputPlan.Schema()
+	for _, fieldName := range predicate.Fields() {
+		if !schema.HasField(fieldName) {
+			return nil, fmt.Errorf("predicate %s reads field %s, which is not in the schema of its input (fields %s)",
+				predicate, fieldName, strings.Join(schema.Fields(), ", "))
+		}
+	}
 	return &SelectPlan{
 		inputPlan: inputPlan,
 		predicate: predicate,
-	}
+	}, nil
 }
 
 // Open creates a select scan for this query.
@@ -92,3 +106,10 @@ func (sp *SelectPlan) DistinctValues(fieldName string) int {
 func (sp *SelectPlan) Schema() *record.Schema {
 	return sp.inputPlan.Schema()
 }
+
+// ToNode returns the description of the select plan and its input.
+func (sp *SelectPlan) ToNode() *PlanNode {
+	node := newPlanNode("Select", sp, sp.inputPlan)
+	node.Predicate = sp.predicate.String()
+	return node
+}
@@ -56,7 +56,8 @@ func TestSelectPlan_BasicEquality(t *testing.T) {
 	pred := query.NewPredicateFromTerm(eqTerm)
 
 	// 4) Create a SelectPlan wrapping the table plan
-	sp := NewSelectPlan(tp, pred)
+	sp, err := NewSelectPlan(tp, pred)
+	require.NoError(t, err)
 
 	// 5) Open the SelectPlan and verify only matching records are returned
 	selScan, err := sp.Open()
@@ -129,7 +130,8 @@ func TestSelectPlan_Range(t *testing.T) {
 	)
 	pred := query.NewPredicateFromTerm(ltTerm)
 
-	sp := NewSelectPlan(tp, pred)
+	sp, err := NewSelectPlan(tp, pred)
+	require.NoError(t, err)
 
 	selScan, err := sp.Open()
 	require.NoError(t, err)
@@ -214,7 +216,8 @@ func TestSelectPlan_MultipleConditions(t *testing.T) {
 	// Conjoin the two single-term predicates
 	predNameEqAlice.ConjoinWith(predActiveEqTrue)
 
-	sp := NewSelectPlan(tp, predNameEqAlice)
+	sp, err := NewSelectPlan(tp, predNameEqAlice)
+	require.NoError(t, err)
 
 	selScan, err := sp.Open()
 	require.NoError(t, err)
@@ -248,3 +251,36 @@ func TestSelectPlan_MultipleConditions(t *testing.T) {
 	dvName := sp.DistinctValues("name")
 	assert.True(t, dvName >= 1, "Should have at least 1 distinct value for 'name'")
 }
+
+// TestSelectPlan_PredicateFieldNotInInput tests that a selection over a projection
+// that removed the field read by its predicate is rejected when the plan is built.
+func TestSelectPlan_PredicateFieldNotInInput(t *testing.T) {
+	txn, cleanup := setupTestEnvironment(t, 800, 8)
+	defer cleanup()
+
+	mdm := createTableMetadataWithSchema(t, txn, "users", map[string]interface{}{
+		"id":   0,
+		"name": "string",
+	})
+
+	tp, err := NewTablePlan(txn, "users", mdm)
+	require.NoError(t, err)
+
+	// The projection removes id, which the predicate reads.
+	pp, err := NewProjectPlan(tp, []string{"name"})
+	require.NoError(t, err)
+
+	pred := query.NewPredicateFromTerm(query.NewTerm(
+		query.NewFieldExpression("id"),
+		query.NewConstantExpression(1),
+		types.EQ,
+	))
+	_, err = NewSelectPlan(pp, pred)
+	assert.ErrorContains(t, err, "predicate id = 1 reads field id, which is not in the schema of its input (fields name)")
+
+	// The canonical order, with the selection below the projection, is accepted.
+	sp, err := NewSelectPlan(tp, pred)
+	require.NoError(t, err)
+	_, err = NewProjectPlan(sp, []string{"name"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,59 @@
+package plan_impl
+
+import (
+	"slices"
+
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+)
+
+// pushDownSelections applies the conjuncts of the predicate (see query.Predicate#Conjuncts) that only read the
+// fields of one of the inputs of a join as a selection directly above that input, so that its records are filtered
+// before they are joined with the records of the others, rather than on every record of their product.
+// It returns the inputs, each below the selection of its conjuncts if it has any, and the conjunction of the other
+// conjuncts, such as the join conditions, which are applied above the join.
+// A conjunct reading a field that several inputs have is not pushed, since the input whose field it reads
+// depends on the order of the join, and neither is a conjunct reading no field.
+func pushDownSelections(plans []plan.Plan, predicate *query.Predicate) ([]plan.Plan, *query.Predicate, error) {
+	local := make([][]*query.Predicate, len(plans))
+	remaining := query.NewPredicate()
+	for _, conjunct := range predicate.Conjuncts() {
+		if input := localInput(plans, conjunct.Fields()); input >= 0 {
+			local[input] = append(local[input], conjunct)
+		} else {
+			remaining.ConjoinWith(conjunct)
+		}
+	}
+
+	pushed := make([]plan.Plan, len(plans))
+	for i, p := range plans {
+		pushed[i] = p
+		if selection := conjunctionOf(local[i]); selection != nil {
+			var err error
+			if pushed[i], err = NewSelectPlan(p, selection); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return pushed, remaining, nil
+}
+
+// localInput returns the index of the only input having the specified fields, or -1 if there are no fields,
+// if no input has all of them, or if another input also has one of them.
+func localInput(plans []plan.Plan, fields []string) int {
+	if len(fields) == 0 {
+		return -1
+	}
+	input := -1
+	for i, p := range plans {
+		schema := p.Schema()
+		if !slices.ContainsFunc(fields, schema.HasField) {
+			continue
+		}
+		if input >= 0 || slices.ContainsFunc(fields, func(field string) bool { return !schema.HasField(field) }) {
+			return -1
+		}
+		input = i
+	}
+	return input
+}
@@ -0,0 +1,124 @@
+package plan_impl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+func TestBasicQueryPlanner_PushesSelectionsBelowJoin(t *testing.T) {
+	p, mdm, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	// Record i of ta has aval i%100, and record i of tb has bval i%50.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, table := range []string{"ta (aid INT, aval INT)", "tb (bid INT, bval INT)"} {
+		_, err := p.ExecuteUpdate("CREATE TABLE "+table, txn)
+		require.NoError(t, err)
+	}
+	for start := 0; start < 1000; start += 100 {
+		var aRows, bRows []string
+		for i := start; i < start+100; i++ {
+			aRows = append(aRows, fmt.Sprintf("(%d, %d)", i, i%100))
+			bRows = append(bRows, fmt.Sprintf("(%d, %d)", i, i%50))
+		}
+		_, err := p.ExecuteUpdate("INSERT INTO ta (aid, aval) VALUES "+strings.Join(aRows, ", "), txn)
+		require.NoError(t, err)
+		_, err = p.ExecuteUpdate("INSERT INTO tb (bid, bval) VALUES "+strings.Join(bRows, ", "), txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	sql := "SELECT aid, bid FROM ta, tb WHERE aval = 7 AND bval = 3 AND aid < bid"
+	var expected []map[string]any
+	for aid := 7; aid < 1000; aid += 100 {
+		for bid := 3; bid < 1000; bid += 50 {
+			if aid < bid {
+				expected = append(expected, map[string]any{"aid": aid, "bid": bid})
+			}
+		}
+	}
+	assert.ElementsMatch(t, expected, runPlannerQuery(t, p, sql, fm, lm, bm, lt, []string{"aid", "bid"}))
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	queryData, err := parse.NewParser(sql).Query()
+	require.NoError(t, err)
+	queryPlan, err := p.queryPlanner.CreatePlan(queryData, txn)
+	require.NoError(t, err)
+
+	// Selecting the product of the tables reads the inner table once per record of the outer one,
+	// while selecting each table first reads it once per selected record.
+	ta, err := NewTablePlan(txn, "ta", mdm)
+	require.NoError(t, err)
+	tb, err := NewTablePlan(txn, "tb", mdm)
+	require.NoError(t, err)
+	product, err := NewProductPlan(txn, ta, tb)
+	require.NoError(t, err)
+	productSelection, err := NewSelectPlan(product, queryData.Pred())
+	require.NoError(t, err)
+	assert.Less(t, queryPlan.BlocksAccessed()*20, productSelection.BlocksAccessed(),
+		"%d blocks with the selections pushed down, %d without", queryPlan.BlocksAccessed(), productSelection.BlocksAccessed())
+	assert.ElementsMatch(t, expected, readPlan(t, productSelection, "aid", "bid"))
+
+	explanation, err := p.Explain(sql, txn)
+	require.NoError(t, err)
+	assert.Contains(t, explanation, "aval = 7")
+	assert.Contains(t, explanation, "bval = 3")
+}
+
+func TestPushDownSelections(t *testing.T) {
+	txn, cleanup := setupTestEnvironment(t, 800, 8)
+	defer cleanup()
+	mdm := createTableMetadataWithSchema(t, txn, "emps", map[string]interface{}{"eid": 0, "dept": 0, "name": "string"})
+	emps, err := NewTablePlan(txn, "emps", mdm)
+	require.NoError(t, err)
+	projected, err := NewProjectPlanWithAliases(emps, []string{"did", "title"}, map[string]string{"did": "eid", "title": "name"})
+	require.NoError(t, err)
+
+	predicate, err := parse.NewParser("eid > 3 AND (did = 1 OR title = 'x') AND eid = did AND dept = 2 AND 1 = 1").Predicate()
+	require.NoError(t, err)
+	pushed, remaining, err := pushDownSelections([]plan.Plan{emps, projected}, predicate)
+	require.NoError(t, err)
+
+	// Each input is selected on the conjuncts reading only its own fields, and the others are left above the join.
+	require.Len(t, pushed, 2)
+	assert.Equal(t, "eid > 3 and dept = 2", pushed[0].(*SelectPlan).predicate.String())
+	assert.Equal(t, "(did = 1 or title = x)", pushed[1].(*SelectPlan).predicate.String())
+	assert.Equal(t, "eid = did and 1 = 1", remaining.String())
+
+	// A conjunct reading a field of both inputs is not pushed into either of them.
+	pushed, remaining, err = pushDownSelections([]plan.Plan{emps, emps}, predicate)
+	require.NoError(t, err)
+	assert.Same(t, emps, pushed[0])
+	assert.Same(t, emps, pushed[1])
+	assert.Equal(t, predicate.String(), remaining.String())
+}
+
+// readPlan opens the plan and returns the values of the specified fields in each of its records.
+func readPlan(t *testing.T, p plan.Plan, fields ...string) []map[string]any {
+	s, err := p.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	var records []map[string]any
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			return records
+		}
+		record := make(map[string]any, len(fields))
+		for _, field := range fields {
+			val, err := s.GetVal(field)
+			require.NoError(t, err)
+			record[field] = val
+		}
+		records = append(records, record)
+	}
+}
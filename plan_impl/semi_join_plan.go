@@ -0,0 +1,93 @@
+package plan_impl
+
+import (
+	"errors"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+)
+
+var _ plan.Plan = &SemiJoinPlan{}
+var _ NodePlan = &SemiJoinPlan{}
+
+// SemiJoinPlan is a plan for the semijoin operator, which outputs the records of its input whose field has one of the
+// values output by a subquery, or, without a field, every record of its input if the subquery outputs any
+// (see query.SemiJoinScan). It plans the IN and EXISTS subquery conditions of a query.
+type SemiJoinPlan struct {
+	inputPlan     plan.Plan
+	subqueryPlan  plan.Plan
+	fieldName     string
+	subqueryField string
+}
+
+// NewSemiJoinPlan creates a semijoin plan of the input plan whose specified field has one of the values of the
+// subquery field of the subquery plan, or of an EXISTS condition on the subquery plan if the field is empty.
+func NewSemiJoinPlan(inputPlan, subqueryPlan plan.Plan, fieldName, subqueryField string) *SemiJoinPlan {
+	return &SemiJoinPlan{
+		inputPlan:     inputPlan,
+		subqueryPlan:  subqueryPlan,
+		fieldName:     fieldName,
+		subqueryField: subqueryField,
+	}
+}
+
+// Open opens a semijoin scan of the input and subquery plans.
+func (sjp *SemiJoinPlan) Open() (scan.Scan, error) {
+	inputScan, err := sjp.inputPlan.Open()
+	if err != nil {
+		return nil, err
+	}
+	subqueryScan, err := sjp.subqueryPlan.Open()
+	if err != nil {
+		return nil, errors.Join(err, inputScan.Close())
+	}
+	semiJoinScan, err := query.NewSemiJoinScan(inputScan, subqueryScan, sjp.fieldName, sjp.subqueryField)
+	if err != nil {
+		return nil, errors.Join(err, inputScan.Close(), subqueryScan.Close())
+	}
+	return semiJoinScan, nil
+}
+
+// BlocksAccessed estimates the number of block accesses of the semijoin,
+// which is one pass through the subquery, and one through the input.
+func (sjp *SemiJoinPlan) BlocksAccessed() int {
+	return sjp.inputPlan.BlocksAccessed() + sjp.subqueryPlan.BlocksAccessed()
+}
+
+// RecordsOutput estimates the number of records output by the semijoin. Assuming the values of the subquery
+// are among those of the field if there are fewer of them, and contain them otherwise, the formula is
+// rows(semijoin(p, s)) = rows(p) * min(distinct(p, field), distinct(s, subqueryField)) / distinct(p, field)
+// An EXISTS condition is assumed to hold.
+func (sjp *SemiJoinPlan) RecordsOutput() int {
+	if sjp.fieldName == "" {
+		return sjp.inputPlan.RecordsOutput()
+	}
+	values := max(sjp.inputPlan.DistinctValues(sjp.fieldName), 1)
+	return sjp.inputPlan.RecordsOutput() * min(values, sjp.subqueryPlan.DistinctValues(sjp.subqueryField)) / values
+}
+
+// DistinctValues estimates the number of distinct values for the specified field, which is the same as in the input,
+// except for the field of the semijoin, which has at most as many values as the subquery field.
+func (sjp *SemiJoinPlan) DistinctValues(fieldName string) int {
+	values := sjp.inputPlan.DistinctValues(fieldName)
+	if fieldName == sjp.fieldName {
+		return min(values, sjp.subqueryPlan.DistinctValues(sjp.subqueryField))
+	}
+	return values
+}
+
+// Schema returns the schema of the input, the fields of the subquery not being output.
+func (sjp *SemiJoinPlan) Schema() *record.Schema {
+	return sjp.inputPlan.Schema()
+}
+
+// ToNode returns the description of the semijoin plan, its input, and its subquery.
+func (sjp *SemiJoinPlan) ToNode() *PlanNode {
+	node := newPlanNode("SemiJoin", sjp, sjp.inputPlan, sjp.subqueryPlan)
+	node.Predicate = "exists (subquery)"
+	if sjp.fieldName != "" {
+		node.Predicate = sjp.fieldName + " in (subquery)"
+	}
+	return node
+}
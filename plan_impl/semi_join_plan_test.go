@@ -0,0 +1,101 @@
+package plan_impl
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSemiJoinTest creates employees and departments, some employees having no department,
+// and some departments having no id, or the same id as another department.
+func setupSemiJoinTest(t *testing.T) (*Planner, *tx.Transaction) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"CREATE TABLE emps (ename VARCHAR(10), dept INT)",
+		"CREATE TABLE depts (did INT, region VARCHAR(10))",
+		"INSERT INTO emps (ename, dept) VALUES ('alice', 1)",
+		"INSERT INTO emps (ename, dept) VALUES ('bob', 2)",
+		"INSERT INTO emps (ename, dept) VALUES ('carol', 3)",
+		"INSERT INTO emps (ename, dept) VALUES ('dave', 1)",
+		"INSERT INTO emps (ename, dept) VALUES ('erin', null)",
+		"INSERT INTO depts (did, region) VALUES (1, 'east')",
+		"INSERT INTO depts (did, region) VALUES (1, 'east')",
+		"INSERT INTO depts (did, region) VALUES (2, 'west')",
+		"INSERT INTO depts (did, region) VALUES (null, 'east')",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	t.Cleanup(func() { require.NoError(t, txn.Commit()) })
+	return p, txn
+}
+
+// queryNames plans and runs the query, and returns the sorted values of its ename field.
+func queryNames(t *testing.T, p *Planner, sql string, txn *tx.Transaction) []string {
+	queryPlan, err := p.CreateQueryPlan(sql, txn)
+	require.NoError(t, err, sql)
+	s, err := queryPlan.Open()
+	require.NoError(t, err, sql)
+	defer s.Close()
+
+	names := []string{}
+	for {
+		hasNext, err := s.Next()
+		require.NoError(t, err, sql)
+		if !hasNext {
+			sort.Strings(names)
+			return names
+		}
+		name, err := s.GetString("ename")
+		require.NoError(t, err, sql)
+		names = append(names, name)
+	}
+}
+
+func TestSemiJoinPlan_In(t *testing.T) {
+	p, txn := setupSemiJoinTest(t)
+
+	// Each employee of a department of the east region is output once, though two departments have its id.
+	// The department without an id matches nobody, not even the employee without a department.
+	sql := "SELECT ename FROM emps WHERE dept IN (SELECT did FROM depts WHERE region = 'east')"
+	assert.Equal(t, []string{"alice", "dave"}, queryNames(t, p, sql, txn))
+	explained, err := p.Explain(sql, txn)
+	require.NoError(t, err)
+	assert.Contains(t, explained, "SemiJoin")
+
+	// The subquery condition is conjoined with the rest of the predicate.
+	assert.Equal(t, []string{"bob", "dave"}, queryNames(t, p, "SELECT ename FROM emps WHERE ename <> 'alice' AND dept IN (SELECT did FROM depts)", txn))
+
+	// An empty subquery result matches no employee.
+	assert.Empty(t, queryNames(t, p, "SELECT ename FROM emps WHERE dept IN (SELECT did FROM depts WHERE region = 'north')", txn))
+}
+
+func TestSemiJoinPlan_Exists(t *testing.T) {
+	p, txn := setupSemiJoinTest(t)
+
+	assert.Equal(t, []string{"alice", "bob", "carol", "dave", "erin"},
+		queryNames(t, p, "SELECT ename FROM emps WHERE EXISTS (SELECT did FROM depts WHERE region = 'west')", txn))
+	assert.Equal(t, []string{"alice", "dave"},
+		queryNames(t, p, "SELECT ename FROM emps WHERE dept = 1 AND EXISTS (SELECT did FROM depts)", txn))
+	assert.Empty(t, queryNames(t, p, "SELECT ename FROM emps WHERE EXISTS (SELECT did FROM depts WHERE region = 'north')", txn))
+}
+
+func TestSemiJoinPlan_Errors(t *testing.T) {
+	p, txn := setupSemiJoinTest(t)
+
+	_, err := p.CreateQueryPlan("SELECT ename FROM emps WHERE ename IN (SELECT did FROM depts)", txn)
+	assert.ErrorIs(t, err, query.ErrTypeMismatch)
+	_, err = p.CreateQueryPlan("SELECT ename FROM emps WHERE region IN (SELECT region FROM depts)", txn)
+	assert.ErrorContains(t, err, "region")
+	_, err = p.CreateQueryPlan("SELECT ename FROM emps WHERE dept IN (SELECT * FROM depts)", txn)
+	assert.ErrorContains(t, err, "single field")
+}
@@ -0,0 +1,64 @@
+package plan_impl
+
+import (
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+var _ plan.Plan = &ShowConstraintsPlan{}
+var _ NodePlan = &ShowConstraintsPlan{}
+
+// ShowConstraintsPlan is the plan of a SHOW CONSTRAINTS statement,
+// which returns the constraints of a table (see metadata.ConstraintScan).
+type ShowConstraintsPlan struct {
+	tableName   string
+	constraints []metadata.TableConstraint
+}
+
+// NewShowConstraintsPlan creates a plan that lists the constraints of the specified table.
+// The constraints are read from the catalog when the plan is created.
+func NewShowConstraintsPlan(transaction *tx.Transaction, tableName string, metadataManager *metadata.Manager) (*ShowConstraintsPlan, error) {
+	constraints, err := metadataManager.GetTableConstraints(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	return &ShowConstraintsPlan{
+		tableName:   tableName,
+		constraints: constraints,
+	}, nil
+}
+
+// Open creates a scan over the constraints of the table.
+func (p *ShowConstraintsPlan) Open() (scan.Scan, error) {
+	return metadata.NewConstraintScan(p.constraints), nil
+}
+
+// BlocksAccessed returns 0, since the constraints were read when the plan was created.
+func (p *ShowConstraintsPlan) BlocksAccessed() int {
+	return 0
+}
+
+// RecordsOutput returns the number of constraints of the table.
+func (p *ShowConstraintsPlan) RecordsOutput() int {
+	return len(p.constraints)
+}
+
+// DistinctValues returns the number of constraints, since each record describes a different constraint.
+func (p *ShowConstraintsPlan) DistinctValues(fieldName string) int {
+	return p.RecordsOutput()
+}
+
+// Schema returns the schema of the constraint listing.
+func (p *ShowConstraintsPlan) Schema() *record.Schema {
+	return metadata.ConstraintSchema()
+}
+
+// ToNode returns the description of the show constraints plan.
+func (p *ShowConstraintsPlan) ToNode() *PlanNode {
+	node := newPlanNode("ShowConstraints", p)
+	node.Table = p.tableName
+	return node
+}
@@ -0,0 +1,122 @@
+package plan_impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+)
+
+// setupConstraintsTest creates a planner over a new database that maintains indexes,
+// so that it can create unique indexes.
+func setupConstraintsTest(t *testing.T) (*Planner, *metadata.Manager, *file.Manager, *log.Manager, *buffer.Manager, *concurrency.LockTable) {
+	_, mdm, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+	return NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm)), mdm, fm, lm, bm, lt
+}
+
+func TestShowConstraintsPlan(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupConstraintsTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE TABLE users (id INT, email VARCHAR(30), active BOOL)",
+		"CREATE UNIQUE INDEX users_id ON users (id)",
+		"CREATE UNIQUE INDEX active_email ON users (email) WHERE active = true",
+		"CREATE INDEX users_email ON users (email)",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	require.NoError(t, txn.Commit())
+
+	fields := []string{
+		metadata.ConstraintNameField,
+		metadata.ConstraintKindField,
+		metadata.ConstraintColumnsField,
+		metadata.ConstraintPredicateField,
+	}
+	rows := runPlannerQuery(t, p, "SHOW CONSTRAINTS users", fm, lm, bm, lt, fields)
+	assert.Equal(t, []map[string]any{
+		{"constraint_name": "active_email", "constraint_kind": "UNIQUE", "column_names": "email", "predicate": "active = true"},
+		{"constraint_name": "users_id", "constraint_kind": "UNIQUE", "column_names": "id", "predicate": ""},
+	}, rows)
+
+	assert.Empty(t, runPlannerQuery(t, p, "SHOW CONSTRAINTS missing", fm, lm, bm, lt, fields))
+}
+
+func TestGenerateDDL_RoundTrip(t *testing.T) {
+	p, mdm, fm, lm, bm, lt := setupConstraintsTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE TABLE users (id INT, email VARCHAR(30), active BOOL, joined DATE) WITH COMPRESSION",
+		"CREATE UNIQUE INDEX users_id ON users (id)",
+		"CREATE UNIQUE INDEX active_email ON users (email) WHERE active = true AND email != 'nobody'",
+		"CREATE INDEX joined_idx ON users (joined)",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	statements, err := mdm.GenerateDDL("users", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	// Recreate the table in a fresh database.
+	restored, restoredMdm, rfm, rlm, rbm, rlt := setupConstraintsTest(t)
+	restoredTxn := tx.NewTransaction(rfm, rlm, rbm, rlt)
+	for _, statement := range statements {
+		_, err := restored.ExecuteUpdate(statement, restoredTxn)
+		require.NoError(t, err, statement)
+	}
+	require.NoError(t, restoredTxn.Commit())
+
+	// Both catalogs describe the same table.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	restoredTxn = tx.NewTransaction(rfm, rlm, rbm, rlt)
+	defer func() { require.NoError(t, restoredTxn.Commit()) }()
+
+	layout, err := mdm.GetLayout("users", txn)
+	require.NoError(t, err)
+	restoredLayout, err := restoredMdm.GetLayout("users", restoredTxn)
+	require.NoError(t, err)
+	assert.Equal(t, layout.Schema().FieldInfos(), restoredLayout.Schema().FieldInfos())
+	assert.Equal(t, layout.SlotSize(), restoredLayout.SlotSize())
+
+	options, err := mdm.GetTableOptions("users", txn)
+	require.NoError(t, err)
+	restoredOptions, err := restoredMdm.GetTableOptions("users", restoredTxn)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.TableOptions{Compressed: true}, restoredOptions)
+	assert.Equal(t, options, restoredOptions)
+
+	constraints, err := mdm.GetTableConstraints("users", txn)
+	require.NoError(t, err)
+	require.Len(t, constraints, 2)
+	restoredConstraints, err := restoredMdm.GetTableConstraints("users", restoredTxn)
+	require.NoError(t, err)
+	assert.Equal(t, constraints, restoredConstraints)
+
+	indexes, err := mdm.GetIndexInfo("users", txn)
+	require.NoError(t, err)
+	restoredIndexes, err := restoredMdm.GetIndexInfo("users", restoredTxn)
+	require.NoError(t, err)
+	require.Len(t, restoredIndexes, len(indexes))
+	for i := range indexes {
+		assert.Equal(t, indexes[i].IndexName(), restoredIndexes[i].IndexName())
+		assert.Equal(t, indexes[i].FieldName(), restoredIndexes[i].FieldName())
+		assert.Equal(t, indexes[i].Unique(), restoredIndexes[i].Unique())
+		assert.Equal(t, indexes[i].Predicate(), restoredIndexes[i].Predicate())
+	}
+
+	restoredStatements, err := restoredMdm.GenerateDDL("users", restoredTxn)
+	require.NoError(t, err)
+	assert.Equal(t, statements, restoredStatements)
+}
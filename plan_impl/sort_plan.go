@@ -7,31 +7,66 @@ import (
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/tx"
+	"slices"
 )
 
 var _ plan.Plan = (*SortPlan)(nil)
+var _ NodePlan = (*SortPlan)(nil)
 
-// SortPlan implements the sort operator
+// SortPlan implements the sort operator as an external merge sort. The records of the input are
+// sorted in memory in batches that fit in the available buffers, each written to a temporary table
+// as a sorted run, and the runs are then merged, as many at a time as there are available buffers
+// to read them, until the SortScan merging the remaining runs can output the records in order.
 type SortPlan struct {
 	transaction *tx.Transaction
 	inputPlan   plan.Plan
 	schema      *record.Schema
+	sortFields  []query.SortField
 	comparator  *query.RecordComparator
+	// sharedSorts is the number of sorts whose scans are read at the same time, such as the inputs of a merge join,
+	// which share the buffers pinned by the runs left for their scans, or 0 for a sort read on its own.
+	sharedSorts int
+	// lastSort describes the sort done by the last call to Open.
+	lastSort sortStats
 }
 
-// NewSortPlan creates a new sort plan for the specified query
+// sortStats describes a sort: the number of sorted runs it split its input into, the number of runs
+// it merged at a time, and the number of passes through the records merging runs into fewer runs.
+type sortStats struct {
+	runs        int
+	fanIn       int
+	mergePasses int
+}
+
+// NewSortPlan creates a new sort plan for the specified query,
+// sorting its records on the specified fields in ascending order.
 func NewSortPlan(transaction *tx.Transaction, p plan.Plan, sortFields []string) *SortPlan {
+	fields := make([]query.SortField, len(sortFields))
+	for i, field := range sortFields {
+		fields[i] = query.SortField{Name: field}
+	}
+	return NewSortPlanWithDirections(transaction, p, fields)
+}
+
+// NewSortPlanWithDirections creates a new sort plan for the specified query,
+// sorting its records on the specified fields, each in its own order.
+func NewSortPlanWithDirections(transaction *tx.Transaction, p plan.Plan, sortFields []query.SortField) *SortPlan {
 	return &SortPlan{
 		transaction: transaction,
 		inputPlan:   p,
 		schema:      p.Schema(),
-		comparator:  query.NewRecordComparator(sortFields),
+		sortFields:  sortFields,
+		comparator:  query.NewRecordComparatorWithDirections(sortFields),
 	}
 }
 
 // Open is where most of the action is.
-// Up to two sorted temporary tables are created,
+// The input is split into sorted runs, which are merged in passes
+// until at most fanIn of them remain, or fewer if the buffers are shared with other sorts,
 // and are passed into SortScan for final merging.
+// The buffers available once the input is open bound both the records
+// sorted in memory at a time and the number of runs merged at a time,
+// each run being read through a buffer of its own while the merged run is written through two others.
 func (sp *SortPlan) Open() (scan.Scan, error) {
 	// Open the source scan
 	src, err := sp.inputPlan.Open()
@@ -40,24 +75,47 @@ func (sp *SortPlan) Open() (scan.Scan, error) {
 	}
 	defer src.Close()
 
+	available := sp.transaction.AvailableBuffers()
+	// Merging needs a buffer for the merged run, and one for logging the records inserted into it
+	fanIn := max(available-2, 2)
+
 	// Split into sorted runs
-	runs, err := sp.splitIntoRuns(src)
+	runs, err := sp.splitIntoRuns(src, sp.runSize(available))
 	if err != nil {
 		return nil, err
 	}
+	stats := sortStats{runs: len(runs), fanIn: fanIn}
 
-	// Repeatedly merge runs until at most 2 remain
-	for len(runs) > 2 {
-		runs, err = sp.doAMergeIteration(runs)
+	// Repeatedly merge runs until at most fanIn remain, each of the sorts sharing the buffers leaving fewer
+	finalRuns := fanIn
+	if sp.sharedSorts > 1 {
+		finalRuns = max(fanIn/sp.sharedSorts, 1)
+	}
+	for len(runs) > finalRuns {
+		runs, err = sp.doAMergeIteration(runs, fanIn)
 		if err != nil {
 			return nil, err
 		}
+		stats.mergePasses++
 	}
+	sp.lastSort = stats
 
-	// Create sort scan with final run(s)
+	// An empty input is sorted into a single empty run
+	if len(runs) == 0 {
+		runs = append(runs, materialize.NewTempTable(sp.transaction, sp.schema))
+	}
+
+	// Create sort scan with final runs
 	return query.NewSortScan(runs, sp.comparator)
 }
 
+// runSize returns the number of records sorted in memory into a run, which is the number of
+// records that would fit in the specified number of buffers, and at least one block of them.
+func (sp *SortPlan) runSize(buffers int) int {
+	recordsPerBlock := sp.transaction.BlockSize() / record.NewLayout(sp.schema).SlotSize()
+	return max(buffers, 1) * max(recordsPerBlock, 1)
+}
+
 // BlocksAccessed returns the number of blocks in the sorted table,
 // which is the same as it would be in a materialized table.
 // It does not include the one-time cost of materializing and sorting the records.
@@ -67,6 +125,13 @@ func (sp *SortPlan) BlocksAccessed() int {
 	return mp.BlocksAccessed()
 }
 
+// sortingCost estimates the number of block accesses of sorting the input, which is not included in
+// BlocksAccessed: one pass through the input, whose records are written to the sorted table.
+// The passes merging the sorted runs of a large input are not counted.
+func (sp *SortPlan) sortingCost() int {
+	return sp.inputPlan.BlocksAccessed() + sp.BlocksAccessed()
+}
+
 // RecordsOutput returns the number of records in the sorted table,
 // which is the same as in the underlying query.
 func (sp *SortPlan) RecordsOutput() int {
@@ -85,91 +150,95 @@ func (sp *SortPlan) Schema() *record.Schema {
 	return sp.schema
 }
 
-// splitIntoRuns splits the records from the source scan into sorted runs
-func (sp *SortPlan) splitIntoRuns(src scan.Scan) ([]*materialize.TempTable, error) {
+// splitIntoRuns splits the records from the source scan into sorted runs of at most runSize records,
+// each sorted in memory. Equal records stay in the order of the source.
+func (sp *SortPlan) splitIntoRuns(src scan.Scan, runSize int) ([]*materialize.TempTable, error) {
 	var temps = make([]*materialize.TempTable, 0)
 
 	if err := src.BeforeFirst(); err != nil {
 		return nil, err
 	}
 
-	hasNext, err := src.Next()
-	if err != nil || !hasNext {
-		return temps, err
+	records := make([]map[string]any, 0, runSize)
+	for {
+		hasNext, err := src.Next()
+		if err != nil {
+			return nil, err
+		}
+		if hasNext {
+			rec := make(map[string]any, len(sp.schema.Fields()))
+			for _, fldName := range sp.schema.Fields() {
+				if rec[fldName], err = src.GetVal(fldName); err != nil {
+					return nil, err
+				}
+			}
+			records = append(records, rec)
+		}
+		if len(records) == runSize || (!hasNext && len(records) > 0) {
+			run, err := sp.writeRun(records)
+			if err != nil {
+				return nil, err
+			}
+			temps = append(temps, run)
+			records = records[:0]
+		}
+		if !hasNext {
+			return temps, nil
+		}
 	}
+}
 
-	currentTemp := materialize.NewTempTable(sp.transaction, sp.schema)
-	temps = append(temps, currentTemp)
+// writeRun sorts the specified records and writes them to a new run.
+func (sp *SortPlan) writeRun(records []map[string]any) (*materialize.TempTable, error) {
+	slices.SortStableFunc(records, sp.comparator.CompareRecords)
 
-	currentScan, err := currentTemp.Open()
+	run := materialize.NewTempTable(sp.transaction, sp.schema)
+	dest, err := run.Open()
 	if err != nil {
 		return nil, err
 	}
-	defer currentScan.Close()
+	defer dest.Close()
 
-	for {
-		if err := sp.copy(src, currentScan); err != nil {
+	for _, rec := range records {
+		if err := dest.Insert(); err != nil {
 			return nil, err
 		}
-
-		hasNext, err = src.Next()
-		if err != nil || !hasNext {
-			break
-		}
-
-		if sp.comparator.Compare(src, currentScan) < 0 {
-			// Start a new run
-			currentScan.Close()
-			currentTemp = materialize.NewTempTable(sp.transaction, sp.schema)
-			temps = append(temps, currentTemp)
-
-			currentScan, err = currentTemp.Open()
-			if err != nil {
+		for _, fldName := range sp.schema.Fields() {
+			if err := dest.SetVal(fldName, rec[fldName]); err != nil {
 				return nil, err
 			}
 		}
 	}
-
-	return temps, nil
+	return run, nil
 }
 
-// doAMergeIteration merges pairs of runs until at most one run is left
-func (sp *SortPlan) doAMergeIteration(runs []*materialize.TempTable) ([]*materialize.TempTable, error) {
+// doAMergeIteration merges the runs, fanIn consecutive runs at a time, into fewer runs
+func (sp *SortPlan) doAMergeIteration(runs []*materialize.TempTable, fanIn int) ([]*materialize.TempTable, error) {
 	var result []*materialize.TempTable
 
-	for len(runs) > 1 {
-		p1 := runs[0]
-		p2 := runs[1]
-		runs = runs[2:]
-
-		merged, err := sp.mergeTwoRuns(p1, p2)
+	for group := range slices.Chunk(runs, fanIn) {
+		// A remaining run is kept as it is
+		if len(group) == 1 {
+			result = append(result, group[0])
+			continue
+		}
+		merged, err := sp.mergeRuns(group)
 		if err != nil {
 			return nil, err
 		}
 		result = append(result, merged)
 	}
 
-	// Add any remaining run
-	if len(runs) == 1 {
-		result = append(result, runs[0])
-	}
-
 	return result, nil
 }
 
-// mergeTwoRuns merges two sorted runs into a single sorted run
-func (sp *SortPlan) mergeTwoRuns(p1, p2 *materialize.TempTable) (*materialize.TempTable, error) {
-	src1, err := p1.Open()
+// mergeRuns merges sorted runs into a single sorted run, removing the merged runs
+func (sp *SortPlan) mergeRuns(runs []*materialize.TempTable) (*materialize.TempTable, error) {
+	src, err := query.NewSortScan(runs, sp.comparator)
 	if err != nil {
 		return nil, err
 	}
-	defer src1.Close()
-
-	src2, err := p2.Open()
-	if err != nil {
-		return nil, err
-	}
-	defer src2.Close()
+	defer src.Close()
 
 	result := materialize.NewTempTable(sp.transaction, sp.schema)
 	dest, err := result.Open()
@@ -178,57 +247,18 @@ func (sp *SortPlan) mergeTwoRuns(p1, p2 *materialize.TempTable) (*materialize.Te
 	}
 	defer dest.Close()
 
-	hasMore1, err := src1.Next()
-	if err != nil {
-		return nil, err
-	}
-
-	hasMore2, err := src2.Next()
-	if err != nil {
-		return nil, err
-	}
-
-	// Merge while both runs have records
-	for hasMore1 && hasMore2 {
-		if sp.comparator.Compare(src1, src2) < 0 {
-			if err := sp.copy(src1, dest); err != nil {
-				return nil, err
-			}
-			hasMore1, err = src1.Next()
-		} else {
-			if err := sp.copy(src2, dest); err != nil {
-				return nil, err
-			}
-			hasMore2, err = src2.Next()
-		}
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// Copy remaining records from first run
-	for hasMore1 {
-		if err := sp.copy(src1, dest); err != nil {
-			return nil, err
-		}
-		hasMore1, err = src1.Next()
+	for {
+		hasNext, err := src.Next()
 		if err != nil {
 			return nil, err
 		}
-	}
-
-	// Copy remaining records from second run
-	for hasMore2 {
-		if err := sp.copy(src2, dest); err != nil {
-			return nil, err
+		if !hasNext {
+			return result, nil
 		}
-		hasMore2, err = src2.Next()
-		if err != nil {
+		if err := sp.copy(src, dest); err != nil {
 			return nil, err
 		}
 	}
-
-	return result, nil
 }
 
 // copy copies a record from src to dest
@@ -249,3 +279,16 @@ func (sp *SortPlan) copy(src scan.Scan, dest scan.UpdateScan) error {
 
 	return nil
 }
+
+// ToNode returns the description of the sort plan and its input.
+func (sp *SortPlan) ToNode() *PlanNode {
+	node := newPlanNode("Sort", sp, sp.inputPlan)
+	for _, field := range sp.sortFields {
+		if field.Descending {
+			node.Fields = append(node.Fields, field.Name+" desc")
+		} else {
+			node.Fields = append(node.Fields, field.Name)
+		}
+	}
+	return node
+}
@@ -1,9 +1,19 @@
 package plan_impl
 
 import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/query"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -226,7 +236,132 @@ func TestSortPlan_EmptyTable(t *testing.T) {
 
 	sortPlan := NewSortPlan(txn, tp, []string{"id"})
 
+	// The empty table is sorted into a scan having no records.
 	sortScan, err := sortPlan.Open()
 	require.NoError(t, err)
-	assert.Nil(t, sortScan)
+	defer sortScan.Close()
+	hasNext, err := sortScan.Next()
+	require.NoError(t, err)
+	assert.False(t, hasNext)
+}
+
+// TestSortPlan_Directions tests queries ordering their records in descending order,
+// alone and mixed with ascending order, over enough records to merge several runs.
+func TestSortPlan_Directions(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 16)
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.ExecuteUpdate("create table people (name varchar(10), age int)", txn)
+	require.NoError(t, err)
+	for i := 0; i < 60; i++ {
+		sql := fmt.Sprintf("insert into people (name, age) values ('p%02d', %d)", (i*7)%60, (i*7)%60/10)
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err)
+	}
+	require.NoError(t, txn.Commit())
+
+	fields := []string{"name", "age"}
+	// expected returns the rows of the people, listed by their number.
+	expected := func(numbers ...int) []map[string]any {
+		rows := make([]map[string]any, len(numbers))
+		for i, n := range numbers {
+			rows[i] = map[string]any{"name": fmt.Sprintf("p%02d", n), "age": n / 10}
+		}
+		return rows
+	}
+	var ascending, descending []int
+	for n := 0; n < 60; n++ {
+		ascending = append(ascending, n)
+		descending = append(descending, 59-n)
+	}
+
+	assert.Equal(t, expected(ascending...), runPlannerQuery(t, p, "select name, age from people order by name asc", fm, lm, bm, lt, fields))
+	assert.Equal(t, expected(descending...), runPlannerQuery(t, p, "select name, age from people order by name desc", fm, lm, bm, lt, fields))
+
+	// The people are ordered by decreasing age, and by name within an age.
+	var mixed []int
+	for age := 5; age >= 0; age-- {
+		for n := age * 10; n < age*10+10; n++ {
+			mixed = append(mixed, n)
+		}
+	}
+	assert.Equal(t, expected(mixed...), runPlannerQuery(t, p, "select name, age from people order by age desc, name asc", fm, lm, bm, lt, fields))
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	explained, err := p.Explain("select name, age from people order by age desc, name", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+	assert.Contains(t, explained, "age desc")
+}
+
+// TestSortPlan_MultiwayMerge tests sorting a table many times larger than the buffer pool, which is split
+// into many runs merged several at a time, in as many passes as needed to leave runs for a single final merge.
+func TestSortPlan_MultiwayMerge(t *testing.T) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 6)
+	txn := tx.NewTransaction(fm, lm, bm, concurrency.NewLockTable())
+	defer func() { require.NoError(t, txn.Commit()) }()
+
+	mdm := createTableMetadataWithSchema(t, txn, "numbers", map[string]interface{}{
+		"id":  0,
+		"val": 0,
+	})
+	tp, err := NewTablePlan(txn, "numbers", mdm)
+	require.NoError(t, err)
+	s, err := tp.Open()
+	require.NoError(t, err)
+	us := s.(scan.UpdateScan)
+	const numRecords = 3000
+	for i := 0; i < numRecords; i++ {
+		require.NoError(t, us.Insert())
+		require.NoError(t, us.SetInt("id", i))
+		require.NoError(t, us.SetInt("val", (i*7919)%numRecords/2))
+	}
+	require.NoError(t, s.Close())
+
+	tp, err = NewTablePlan(txn, "numbers", mdm)
+	require.NoError(t, err)
+	require.Greater(t, tp.BlocksAccessed(), 10*bm.Available())
+	sortPlan := NewSortPlanWithDirections(txn, tp, []query.SortField{{Name: "val", Descending: true}})
+	sortScan, err := sortPlan.Open()
+	require.NoError(t, err)
+
+	// The records are in order, and the records having the same value stay in the order of the table.
+	count := 0
+	prevVal, prevID := numRecords, -1
+	for {
+		hasNext, err := sortScan.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		val, err := sortScan.GetInt("val")
+		require.NoError(t, err)
+		id, err := sortScan.GetInt("id")
+		require.NoError(t, err)
+		require.LessOrEqual(t, val, prevVal)
+		if val == prevVal {
+			require.Greater(t, id, prevID)
+		}
+		prevVal, prevID = val, id
+		count++
+	}
+	assert.Equal(t, numRecords, count)
+
+	// Each pass merges fanIn runs into one, until at most fanIn runs are left for the scan to merge.
+	stats := sortPlan.lastSort
+	assert.Greater(t, stats.runs, stats.fanIn*stats.fanIn)
+	expectedPasses := int(math.Ceil(math.Log(float64(stats.runs))/math.Log(float64(stats.fanIn)))) - 1
+	assert.Equal(t, expectedPasses, stats.mergePasses)
+
+	// The runs are removed once the scan is closed.
+	require.NoError(t, sortScan.Close())
+	entries, err := os.ReadDir(dbDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasPrefix(entry.Name(), "temp"), entry.Name())
+	}
 }
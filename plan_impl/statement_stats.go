@@ -0,0 +1,62 @@
+package plan_impl
+
+import (
+	"sync"
+
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// StatementStats describe how the last query of a transaction was planned.
+type StatementStats struct {
+	// AccessPaths holds the access path chosen for each table read by the query, in the order they were planned,
+	// including the tables read through views and subqueries. Each lists the indexes considered for the table
+	// and the reasons those that were not chosen were rejected.
+	AccessPaths []*AccessPath
+}
+
+// statementStats holds the stats of the last query planned by each transaction, until the transaction ends.
+// It is embedded in the query planners, and is safe for concurrent use by different transactions.
+type statementStats struct {
+	mu            sync.Mutex
+	byTransaction map[*tx.Transaction]*StatementStats
+}
+
+// StartStatement replaces the stats of the last query planned by the transaction with empty stats,
+// which the planning of its next query fills.
+func (s *statementStats) StartStatement(transaction *tx.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byTransaction == nil {
+		s.byTransaction = make(map[*tx.Transaction]*StatementStats)
+	}
+	if _, ok := s.byTransaction[transaction]; !ok {
+		// However the transaction ends, its stats are forgotten then.
+		transaction.OnEnd(func() { s.forget(transaction) })
+	}
+	s.byTransaction[transaction] = &StatementStats{}
+}
+
+// StatementStats returns the stats of the last query planned by the transaction, and false if it planned none.
+func (s *statementStats) StatementStats(transaction *tx.Transaction) (*StatementStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.byTransaction[transaction]
+	return stats, ok
+}
+
+// recordAccessPath adds the access path chosen for a table to the stats of the query the transaction is planning,
+// if it started one.
+func (s *statementStats) recordAccessPath(transaction *tx.Transaction, accessPath *AccessPath) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stats, ok := s.byTransaction[transaction]; ok {
+		stats.AccessPaths = append(stats.AccessPaths, accessPath)
+	}
+}
+
+// forget forgets the stats of the transaction.
+func (s *statementStats) forget(transaction *tx.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byTransaction, transaction)
+}
@@ -0,0 +1,89 @@
+package plan_impl
+
+import (
+	"math"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+)
+
+var _ plan.Plan = &StatsPlan{}
+var _ NodePlan = &StatsPlan{}
+
+// StatsTable is the name of the virtual table holding the statistics of the database, one record per
+// statistic (see query.StatsScan), which queries read like any table once the planner has a stats source.
+const StatsTable = "dropdb_stats"
+
+// DatabaseStats are the stats of the database that the StatsTable holds: those of the buffer pool,
+// and the numbers of blocks the file manager read and wrote, which include the blocks of the log
+// and of the temporary tables of queries that do not go through the buffer pool.
+type DatabaseStats struct {
+	Buffer buffer.Stats
+	File   file.Stats
+}
+
+// StatsPlan is the plan reading the StatsTable, over a snapshot of the database's stats.
+type StatsPlan struct {
+	stats []query.Stat
+}
+
+// NewStatsPlan creates a plan reading the specified stats of the database.
+// The hit ratio of the pool is reported as a rounded percentage.
+func NewStatsPlan(databaseStats DatabaseStats) *StatsPlan {
+	stats := databaseStats.Buffer
+	return &StatsPlan{stats: []query.Stat{
+		{Name: "buffers", Value: stats.Buffers},
+		{Name: "pinned", Value: stats.Pinned},
+		{Name: "dirty", Value: stats.Dirty},
+		{Name: "waiters", Value: stats.Waiters},
+		{Name: "pins", Value: stats.Pins},
+		{Name: "unpins", Value: stats.Unpins},
+		{Name: "hits", Value: stats.Hits},
+		{Name: "reads", Value: stats.Reads},
+		{Name: "hit_ratio_percent", Value: int(math.Round(100 * stats.HitRatio()))},
+		{Name: "writes", Value: stats.Writes},
+		{Name: "waits", Value: stats.Waits},
+		{Name: "evictions", Value: stats.Evictions},
+		{Name: "prefetch_issued", Value: stats.PrefetchIssued},
+		{Name: "prefetch_hits", Value: stats.PrefetchHits},
+		{Name: "prefetch_wasted", Value: stats.PrefetchWasted},
+		{Name: "disk_reads", Value: databaseStats.File.BlocksRead},
+		{Name: "disk_writes", Value: databaseStats.File.BlocksWritten},
+	}}
+}
+
+// Open creates a scan over the stats.
+func (p *StatsPlan) Open() (scan.Scan, error) {
+	return query.NewStatsScan(p.stats), nil
+}
+
+// BlocksAccessed returns 0, since the stats are held in memory.
+func (p *StatsPlan) BlocksAccessed() int {
+	return 0
+}
+
+// RecordsOutput returns the number of stats.
+func (p *StatsPlan) RecordsOutput() int {
+	return len(p.stats)
+}
+
+// DistinctValues returns the number of stats, since each record describes a different statistic.
+func (p *StatsPlan) DistinctValues(fieldName string) int {
+	return p.RecordsOutput()
+}
+
+// Schema returns the schema of the stats listing.
+func (p *StatsPlan) Schema() *record.Schema {
+	return query.StatsSchema()
+}
+
+// ToNode returns the description of the stats plan.
+func (p *StatsPlan) ToNode() *PlanNode {
+	node := newPlanNode("Stats", p)
+	node.Table = StatsTable
+	return node
+}
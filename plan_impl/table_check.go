@@ -0,0 +1,292 @@
+package plan_impl
+
+import (
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+const (
+	// CheckError is the severity of the findings of a table check describing data that is lost or cannot be read correctly.
+	CheckError = "error"
+	// CheckWarning is the severity of the findings of a table check describing metadata that is out of date,
+	// such as the record counter of an index, which affects the plans of queries but not their results.
+	CheckWarning = "warning"
+)
+
+// CheckFinding is an inconsistency found by a table check (see CheckTable).
+type CheckFinding struct {
+	// Severity is CheckError or CheckWarning.
+	Severity string
+	// Location names where the inconsistency was found, such as "block 2 slot 5", "index people_id",
+	// or "catalog field name".
+	Location string
+	// Description describes the inconsistency.
+	Description string
+}
+
+// CheckTable checks the integrity of the specified table, and returns the inconsistencies it finds, in this order:
+//   - the layout of the table recorded in the catalog, whose slot size and field offsets must be the ones
+//     that the schema of the table lays out (see record.NewLayout);
+//   - the slots of every block of the table, whose flags must be valid, and whose used slots must hold values
+//     that can be decoded, such as strings that fit their fields (see record.Page#GetRawVal);
+//   - for each index of the table, the rows of the table, which must each have an index record with their key
+//     and record ID if the index includes them (see metadata.IndexInfo#Includes), the index records, which must each point at a used slot whose row
+//     has their key and is included by the index, and the record counter of the index, if it keeps one, which
+//     must count the rows the index includes.
+//
+// Neither the rows nor the index records are held in memory: each row is looked up in the indexes as it is read,
+// and each index record is checked by reading the slot it points at. The table is locked (see table.LockTable)
+// so that it does not change while it is checked, but nothing is modified.
+func CheckTable(tableName string, metadataManager *metadata.Manager, transaction *tx.Transaction) ([]CheckFinding, error) {
+	layout, err := metadataManager.GetLayout(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := metadataManager.GetIndexInfoWithoutStats(tableName, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.LockTable(transaction, tableName); err != nil {
+		return nil, err
+	}
+	blocks, err := table.BlockCount(transaction, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := &tableChecker{
+		transaction: transaction,
+		tableName:   tableName,
+		layout:      layout,
+		blocks:      blocks,
+	}
+	if !checker.checkLayout() {
+		// The slots cannot be located without a usable slot size.
+		return checker.findings, nil
+	}
+
+	checks := make([]*indexCheck, len(indexes))
+	for i, indexInfo := range indexes {
+		idx, err := indexInfo.Open()
+		if err != nil {
+			return nil, fmt.Errorf("index %s: %w", indexInfo.IndexName(), err)
+		}
+		checks[i] = &indexCheck{info: indexInfo, index: idx}
+		defer idx.Close()
+	}
+	if err := checker.checkRows(checks); err != nil {
+		return nil, err
+	}
+	for _, check := range checks {
+		if err := checker.checkIndexRecords(check); err != nil {
+			return nil, fmt.Errorf("index %s: %w", check.info.IndexName(), err)
+		}
+	}
+	return checker.findings, nil
+}
+
+// tableChecker accumulates the findings of the check of a table.
+type tableChecker struct {
+	transaction *tx.Transaction
+	tableName   string
+	layout      *record.Layout
+	blocks      int
+	findings    []CheckFinding
+}
+
+// indexCheck is the state of the check of an index of the table.
+type indexCheck struct {
+	info *metadata.IndexInfo
+	// index is the open index.
+	index index.Index
+	// includedRows is the number of rows of the table that the index includes.
+	includedRows int
+}
+
+func (c *tableChecker) report(severity, location, format string, args ...any) {
+	c.findings = append(c.findings, CheckFinding{Severity: severity, Location: location, Description: fmt.Sprintf(format, args...)})
+}
+
+// checkLayout compares the layout of the table recorded in the catalog with the layout of its schema.
+// It returns false if the recorded slot size is unusable, in which case the slots of the table cannot be checked.
+func (c *tableChecker) checkLayout() bool {
+	expected := record.NewLayout(c.layout.Schema())
+	location := "catalog table " + c.tableName
+	if c.layout.SlotSize() != expected.SlotSize() {
+		c.report(CheckError, location, "slot size is %d, but the fields of the table take %d bytes", c.layout.SlotSize(), expected.SlotSize())
+	}
+	for _, fieldName := range c.layout.Schema().Fields() {
+		if c.layout.Offset(fieldName) != expected.Offset(fieldName) {
+			c.report(CheckError, "catalog field "+fieldName, "offset is %d, but the layout of the table puts the field at offset %d",
+				c.layout.Offset(fieldName), expected.Offset(fieldName))
+		}
+	}
+	if c.layout.SlotSize() < types.IntSize || c.layout.SlotSize() > c.transaction.BlockSize() {
+		c.report(CheckError, location, "slot size %d does not fit a block of %d bytes, so the slots of the table were not checked",
+			c.layout.SlotSize(), c.transaction.BlockSize())
+		return false
+	}
+	return true
+}
+
+// checkRows checks the slots of every block of the table, and looks up each row in the indexes.
+func (c *tableChecker) checkRows(checks []*indexCheck) error {
+	for blockNumber := 0; blockNumber < c.blocks; blockNumber++ {
+		if err := c.checkBlock(blockNumber, checks); err != nil {
+			return fmt.Errorf("block %d: %w", blockNumber, err)
+		}
+	}
+	for _, check := range checks {
+		stats, err := check.index.Stats()
+		if err != nil {
+			return fmt.Errorf("index %s: %w", check.info.IndexName(), err)
+		}
+		if stats.Records >= 0 && stats.Records != check.includedRows {
+			c.report(CheckWarning, "index "+check.info.IndexName(), "record counter is %d, but the index includes %d rows of the table",
+				stats.Records, check.includedRows)
+		}
+	}
+	return nil
+}
+
+// checkBlock checks the slots of the specified block, and looks up each row of the block in the indexes.
+func (c *tableChecker) checkBlock(blockNumber int, checks []*indexCheck) error {
+	page, err := record.NewPage(c.transaction, table.BlockID(c.tableName, blockNumber), c.layout)
+	if err != nil {
+		return err
+	}
+	defer c.transaction.Unpin(page.Block())
+
+	for slot := 0; slot < page.NumSlots(); slot++ {
+		flag, err := page.RawFlag(slot)
+		if err != nil {
+			return err
+		}
+		if flag == record.FlagEmpty {
+			continue
+		}
+		location := fmt.Sprintf("block %d slot %d", blockNumber, slot)
+		if flag != record.FlagUsed {
+			c.report(CheckError, location, "flag %d is neither empty (%d) nor used (%d)", flag, record.FlagEmpty, record.FlagUsed)
+			continue
+		}
+
+		values, ok := c.readRow(page, slot, location)
+		if !ok {
+			continue
+		}
+		rid := record.NewID(blockNumber, slot)
+		for _, check := range checks {
+			if err := c.findIndexRecord(check, values, rid, location); err != nil {
+				return fmt.Errorf("index %s: %w", check.info.IndexName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// readRow decodes the values of the row of the specified used slot. If a value cannot be decoded,
+// it reports it and returns false, since the row cannot be compared with the indexes.
+func (c *tableChecker) readRow(page *record.Page, slot int, location string) (map[string]any, bool) {
+	values := make(map[string]any)
+	for _, fieldName := range c.layout.Schema().Fields() {
+		val, err := page.GetRawVal(slot, fieldName)
+		if err != nil {
+			c.report(CheckError, location, "field %s cannot be read: %v", fieldName, err)
+			continue
+		}
+		values[fieldName] = val
+	}
+	return values, len(values) == len(c.layout.Schema().Fields())
+}
+
+// findIndexRecord checks that the index has a record with the key and record ID of the row, if it includes the row.
+func (c *tableChecker) findIndexRecord(check *indexCheck, values map[string]any, rid *record.ID, location string) error {
+	included, err := check.info.Includes(&rowScan{values: values})
+	if err != nil || !included {
+		return err
+	}
+	check.includedRows++
+
+	key, _ := check.info.KeyOf(values)
+	if err := check.index.BeforeFirst(key); err != nil {
+		return err
+	}
+	for {
+		next, err := check.index.Next()
+		if err != nil {
+			return err
+		}
+		if !next {
+			c.report(CheckError, "index "+check.info.IndexName(), "no record for the row at %s, whose %s is %v",
+				location, check.info.FieldName(), key)
+			return nil
+		}
+		indexed, err := check.index.GetDataRecordID()
+		if err != nil {
+			return err
+		}
+		if indexed.Equals(rid) {
+			return nil
+		}
+	}
+}
+
+// checkIndexRecords checks that every record of the index points at a used slot of the table,
+// whose row has the key of the record and is included by the index.
+func (c *tableChecker) checkIndexRecords(check *indexCheck) error {
+	location := "index " + check.info.IndexName()
+	return check.index.ForEach(func(key any, rid *record.ID) error {
+		if rid.BlockNumber() < 0 || rid.BlockNumber() >= c.blocks {
+			c.report(CheckError, location, "record with key %v points at block %d, but the table has %d blocks",
+				key, rid.BlockNumber(), c.blocks)
+			return nil
+		}
+
+		page, err := record.NewPage(c.transaction, table.BlockID(c.tableName, rid.BlockNumber()), c.layout)
+		if err != nil {
+			return err
+		}
+		defer c.transaction.Unpin(page.Block())
+
+		target := fmt.Sprintf("block %d slot %d", rid.BlockNumber(), rid.Slot())
+		if rid.Slot() < 0 || rid.Slot() >= page.NumSlots() {
+			c.report(CheckError, location, "record with key %v points at %s, but a block has %d slots", key, target, page.NumSlots())
+			return nil
+		}
+		flag, err := page.RawFlag(rid.Slot())
+		if err != nil {
+			return err
+		}
+		if flag != record.FlagUsed {
+			c.report(CheckError, location, "record with key %v points at %s, which is not used", key, target)
+			return nil
+		}
+
+		// The rows that cannot be read were reported when the slots were checked.
+		values := make(map[string]any)
+		for _, fieldName := range c.layout.Schema().Fields() {
+			if values[fieldName], err = page.GetRawVal(rid.Slot(), fieldName); err != nil {
+				return nil
+			}
+		}
+		if val, _ := check.info.KeyOf(values); !types.CompareSupportedTypes(val, key, types.EQ) {
+			c.report(CheckError, location, "record with key %v points at %s, whose %s is %v", key, target, check.info.FieldName(), val)
+			return nil
+		}
+		included, err := check.info.Includes(&rowScan{values: values})
+		if err != nil {
+			return err
+		}
+		if !included {
+			c.report(CheckError, location, "record with key %v points at %s, whose row the index does not include", key, target)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,227 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// TableFilter returns the predicate that the records of a table must satisfy
+// to be visible to the specified transaction, such as "tenant_id = 7".
+// A nil predicate makes every record visible; an error aborts the statement.
+type TableFilter func(transaction *tx.Transaction) (*query.Predicate, error)
+
+// TableFilterer is implemented by query and update planners that apply
+// the table filters registered on a Planner.
+type TableFilterer interface {
+	// SetTableFilters sets the filters the planner applies to the tables of every statement.
+	SetTableFilters(filters *TableFilters)
+}
+
+// TableFilters holds the filter registered for each table.
+// It is safe for concurrent use, and a nil *TableFilters has no filters.
+type TableFilters struct {
+	mu      sync.RWMutex
+	filters map[string]TableFilter
+}
+
+// NewTableFilters creates an empty set of table filters.
+func NewTableFilters() *TableFilters {
+	return &TableFilters{filters: make(map[string]TableFilter)}
+}
+
+// Register sets the filter of the specified table, replacing any previous one.
+func (f *TableFilters) Register(tableName string, filter TableFilter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.filters[tableName] = filter
+}
+
+// Predicate returns the predicate the records of the specified table must satisfy
+// to be visible to the transaction, with its constants coerced to the types of the
+// table's fields, or nil if the table has no filter.
+// The predicate is a new one, so that it can be conjoined with the predicate of a statement.
+func (f *TableFilters) Predicate(tableName string, schema *record.Schema, transaction *tx.Transaction) (*query.Predicate, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	f.mu.RLock()
+	filter, ok := f.filters[tableName]
+	f.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	filterPredicate, err := filter(transaction)
+	if err != nil {
+		return nil, fmt.Errorf("filter of table %s: %w", tableName, err)
+	}
+	if filterPredicate == nil {
+		return nil, nil
+	}
+
+	predicate := query.NewPredicate()
+	predicate.ConjoinWith(filterPredicate)
+	predicate.CoerceConstants(schema)
+	return predicate, nil
+}
+
+// conjoinFilter conjoins the predicate of a statement with the filter of the table it reads,
+// so that the statement only sees the records visible to the transaction.
+// It returns the filter's predicate, or nil if the table has no filter.
+func (f *TableFilters) conjoinFilter(tableName string, schema *record.Schema, predicate *query.Predicate, transaction *tx.Transaction) (*query.Predicate, error) {
+	filterPredicate, err := f.Predicate(tableName, schema, transaction)
+	if err != nil || filterPredicate == nil {
+		return nil, err
+	}
+	predicate.ConjoinWith(filterPredicate)
+	return filterPredicate, nil
+}
+
+// checkFilter returns an error unless the record with the specified values satisfies the filter
+// predicate of the table, if any. Fields without a value are read from the base scan, if any.
+// Writes are checked before they are made, so that a transaction cannot write records it cannot see.
+func checkFilter(tableName string, filterPredicate *query.Predicate, base scan.Scan, values map[string]any) error {
+	if filterPredicate == nil {
+		return nil
+	}
+	satisfied, err := rowSatisfies(filterPredicate, &rowScan{base: base, values: values})
+	if err != nil {
+		return err
+	}
+	if !satisfied {
+		return fmt.Errorf("record violates the filter of table %s: %s", tableName, filterPredicate)
+	}
+	return nil
+}
+
+// rowSatisfies returns true if the record of the row scan satisfies the predicate, if any.
+// A term reading a field without a value is not satisfied, while the errors of the base scan are returned.
+func rowSatisfies(predicate *query.Predicate, row *rowScan) (bool, error) {
+	if predicate == nil {
+		return true, nil
+	}
+	satisfied, err := predicate.IsSatisfied(row)
+	if errors.Is(err, errNoValue) {
+		return false, nil
+	}
+	return satisfied, err
+}
+
+// checkInsert returns an error unless the record inserted by the statement,
+// whose fields have the specified values, satisfies the filter of the table.
+// The fields missing from the statement fail any term of the filter that reads them.
+func (f *TableFilters) checkInsert(data *parse.InsertData, schema *record.Schema, vals []any, transaction *tx.Transaction) error {
+	filterPredicate, err := f.Predicate(data.TableName(), schema, transaction)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]any, len(vals))
+	for i, field := range data.Fields() {
+		values[field] = vals[i]
+	}
+	return checkFilter(data.TableName(), filterPredicate, nil, values)
+}
+
+// errNoValue is returned by a rowScan for the fields that have no value.
+var errNoValue = errors.New("no value")
+
+// rowScan is a scan positioned on a single record, whose values are given
+// by a map, falling back to the current record of a base scan.
+// It is used to evaluate predicates against records before they are written.
+type rowScan struct {
+	base   scan.Scan
+	values map[string]any
+}
+
+var _ scan.Scan = (*rowScan)(nil)
+
+func (rs *rowScan) BeforeFirst() error  { return nil }
+func (rs *rowScan) Next() (bool, error) { return false, nil }
+func (rs *rowScan) Close() error        { return nil }
+
+func (rs *rowScan) HasField(fieldName string) bool {
+	if _, ok := rs.values[fieldName]; ok {
+		return true
+	}
+	return rs.base != nil && rs.base.HasField(fieldName)
+}
+
+func (rs *rowScan) GetVal(fieldName string) (any, error) {
+	if val, ok := rs.values[fieldName]; ok {
+		return val, nil
+	}
+	if rs.base == nil {
+		return nil, fmt.Errorf("field %s: %w", fieldName, errNoValue)
+	}
+	return rs.base.GetVal(fieldName)
+}
+
+// Fields returns the fields of the base scan, if any, followed by the other fields with a value.
+// The type of those fields is the type of their value.
+func (rs *rowScan) Fields() []types.FieldInfo {
+	var fields []types.FieldInfo
+	if rs.base != nil {
+		fields = rs.base.Fields()
+	}
+	for fieldName, val := range rs.values {
+		if _, ok := types.FindField(fields, fieldName); ok {
+			continue
+		}
+		if fieldType, ok := types.TypeOf(val); ok {
+			fields = append(fields, types.FieldInfo{Name: fieldName, Type: fieldType})
+		}
+	}
+	return fields
+}
+
+func (rs *rowScan) GetInt(fieldName string) (int, error) {
+	return rowScanValue[int](rs, fieldName)
+}
+
+func (rs *rowScan) GetLong(fieldName string) (int64, error) {
+	return rowScanValue[int64](rs, fieldName)
+}
+
+func (rs *rowScan) GetShort(fieldName string) (int16, error) {
+	return rowScanValue[int16](rs, fieldName)
+}
+
+func (rs *rowScan) GetFloat(fieldName string) (float64, error) {
+	return rowScanValue[float64](rs, fieldName)
+}
+
+func (rs *rowScan) GetString(fieldName string) (string, error) {
+	return rowScanValue[string](rs, fieldName)
+}
+
+func (rs *rowScan) GetBool(fieldName string) (bool, error) {
+	return rowScanValue[bool](rs, fieldName)
+}
+
+func (rs *rowScan) GetDate(fieldName string) (time.Time, error) {
+	return rowScanValue[time.Time](rs, fieldName)
+}
+
+// rowScanValue returns the value of the specified field, which must be of type T.
+func rowScanValue[T any](rs *rowScan, fieldName string) (T, error) {
+	var zero T
+	val, err := rs.GetVal(fieldName)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := val.(T)
+	if !ok {
+		return zero, fmt.Errorf("field %s is not a %T", fieldName, zero)
+	}
+	return typed, nil
+}
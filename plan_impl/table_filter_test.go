@@ -0,0 +1,163 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+func TestPlanner_TableFilters(t *testing.T) {
+	planners := map[string]func(*metadata.Manager) UpdatePlanner{
+		"basic": NewBasicUpdatePlanner,
+		"index": NewIndexUpdatePlanner,
+	}
+
+	for name, newUpdatePlanner := range planners {
+		t.Run(name, func(t *testing.T) {
+			fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+			mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+			p := NewPlanner(NewBasicQueryPlanner(mdm), newUpdatePlanner(mdm))
+
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			for _, sql := range []string{
+				"create table orders (id int, tenant_id int, amount int)",
+				"create index idx_tenant on orders (tenant_id)",
+				"create view big_orders as select id, amount from orders where amount > 10",
+			} {
+				_, err := p.ExecuteUpdate(sql, txn)
+				require.NoError(t, err, sql)
+			}
+			require.NoError(t, txn.Commit())
+
+			// Each transaction acts on behalf of a tenant, and only sees the orders of its tenant.
+			var mu sync.Mutex
+			tenants := make(map[*tx.Transaction]int)
+			errNoTenant := errors.New("transaction has no tenant")
+			require.NoError(t, p.RegisterTableFilter("orders", func(transaction *tx.Transaction) (*query.Predicate, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				tenant, ok := tenants[transaction]
+				if !ok {
+					return nil, errNoTenant
+				}
+				term := query.NewTerm(query.NewFieldExpression("tenant_id"), query.NewConstantExpression(tenant), types.EQ)
+				return query.NewPredicateFromTerm(term), nil
+			}))
+
+			asTenant := func(tenant int, statement func(txn *tx.Transaction)) {
+				txn := tx.NewTransaction(fm, lm, bm, lt)
+				mu.Lock()
+				tenants[txn] = tenant
+				mu.Unlock()
+				statement(txn)
+				require.NoError(t, txn.Commit())
+			}
+			execute := func(tenant int, sql string) (count int, err error) {
+				asTenant(tenant, func(txn *tx.Transaction) { count, err = p.ExecuteUpdate(sql, txn) })
+				return count, err
+			}
+			selectIDs := func(tenant int, sql string) []int {
+				var ids []int
+				asTenant(tenant, func(txn *tx.Transaction) {
+					queryPlan, err := p.CreateQueryPlan(sql, txn)
+					require.NoError(t, err, sql)
+					s, err := queryPlan.Open()
+					require.NoError(t, err)
+					defer s.Close()
+					for {
+						next, err := s.Next()
+						require.NoError(t, err)
+						if !next {
+							return
+						}
+						id, err := s.GetInt("id")
+						require.NoError(t, err)
+						ids = append(ids, id)
+					}
+				})
+				return ids
+			}
+
+			// Both tenants write to the same table.
+			for id := 1; id <= 6; id++ {
+				tenant := 1 + (id-1)/3
+				count, err := execute(tenant, fmt.Sprintf("insert into orders (id, tenant_id, amount) values (%d, %d, %d)", id, tenant, id*10))
+				require.NoError(t, err)
+				assert.Equal(t, 1, count)
+			}
+
+			// Inserting a record the tenant could not see is rejected.
+			_, err := execute(1, "insert into orders (id, tenant_id, amount) values (7, 2, 70)")
+			assert.ErrorContains(t, err, "record violates the filter of table orders")
+			_, err = execute(1, "insert into orders (id, amount) values (7, 70)")
+			assert.ErrorContains(t, err, "record violates the filter of table orders")
+
+			// Queries, including queries through views and queries trying to read other tenants, see their own records.
+			assert.ElementsMatch(t, []int{1, 2, 3}, selectIDs(1, "select id from orders"))
+			assert.ElementsMatch(t, []int{4, 5, 6}, selectIDs(2, "select id from orders"))
+			assert.Empty(t, selectIDs(1, "select id from orders where tenant_id = 2"))
+			assert.ElementsMatch(t, []int{2, 3}, selectIDs(1, "select id from big_orders"))
+			assert.ElementsMatch(t, []int{4, 5, 6}, selectIDs(2, "select id from big_orders"))
+
+			// The filter is conjoined with the predicate before choosing the index.
+			asTenant(1, func(txn *tx.Transaction) {
+				accessPaths, err := p.IndexCandidates("select id from orders", txn)
+				require.NoError(t, err)
+				require.Len(t, accessPaths, 1)
+				require.Len(t, accessPaths[0].Candidates, 1)
+				require.NotNil(t, accessPaths[0].Candidates[0].Term)
+				assert.Equal(t, "tenant_id = 1", accessPaths[0].Candidates[0].Term.String())
+			})
+
+			// Updates and deletes only affect the records of the tenant.
+			count, err := execute(1, "update orders set amount = 0")
+			require.NoError(t, err)
+			assert.Equal(t, 3, count)
+			assert.ElementsMatch(t, []int{4, 5, 6}, selectIDs(2, "select id from orders where amount > 0"))
+
+			_, err = execute(1, "update orders set tenant_id = 2 where id = 1")
+			assert.ErrorContains(t, err, "record violates the filter of table orders", "records cannot be moved to another tenant")
+			assert.ElementsMatch(t, []int{1, 2, 3}, selectIDs(1, "select id from orders"))
+
+			count, err = execute(2, "delete from orders")
+			require.NoError(t, err)
+			assert.Equal(t, 3, count)
+			assert.Empty(t, selectIDs(2, "select id from orders"))
+			assert.ElementsMatch(t, []int{1, 2, 3}, selectIDs(1, "select id from orders"))
+
+			// An error of the filter aborts the statement.
+			txn = tx.NewTransaction(fm, lm, bm, lt)
+			_, err = p.CreateQueryPlan("select id from orders", txn)
+			assert.ErrorIs(t, err, errNoTenant)
+			_, err = p.ExecuteUpdate("delete from orders", txn)
+			assert.ErrorIs(t, err, errNoTenant)
+			_, err = p.ExecuteUpdate("insert into orders (id, tenant_id, amount) values (8, 1, 80)", txn)
+			assert.ErrorIs(t, err, errNoTenant)
+			require.NoError(t, txn.Commit())
+			assert.ElementsMatch(t, []int{1, 2, 3}, selectIDs(1, "select id from orders"))
+		})
+	}
+}
+
+func TestPlanner_RegisterTableFilterRequiresFilteringPlanners(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	p := NewPlanner(noFilterQueryPlanner{NewBasicQueryPlanner(mdm)}, NewBasicUpdatePlanner(mdm))
+
+	err := p.RegisterTableFilter("orders", func(*tx.Transaction) (*query.Predicate, error) { return nil, nil })
+	assert.ErrorContains(t, err, "does not apply table filters")
+}
+
+// noFilterQueryPlanner is a query planner that does not apply table filters.
+type noFilterQueryPlanner struct {
+	QueryPlanner
+}
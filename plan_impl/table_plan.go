@@ -1,6 +1,8 @@
 package plan_impl
 
 import (
+	"slices"
+
 	"github.com/JyotinderSingh/dropdb/metadata"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/record"
@@ -10,20 +12,23 @@ import (
 )
 
 var _ plan.Plan = &TablePlan{}
+var _ NodePlan = &TablePlan{}
 
 type TablePlan struct {
-	tableName   string
-	transaction *tx.Transaction
-	layout      *record.Layout
-	statInfo    *metadata.StatInfo
+	tableName       string
+	transaction     *tx.Transaction
+	layout          *record.Layout
+	statInfo        *metadata.StatInfo
+	metadataManager *metadata.Manager
 }
 
 // NewTablePlan creates a leaf node in the query tree
 // corresponding to the specified table.
 func NewTablePlan(transaction *tx.Transaction, tableName string, metadataManager *metadata.Manager) (*TablePlan, error) {
 	tp := &TablePlan{
-		tableName:   tableName,
-		transaction: transaction,
+		tableName:       tableName,
+		transaction:     transaction,
+		metadataManager: metadataManager,
 	}
 
 	var err error
@@ -36,9 +41,27 @@ func NewTablePlan(transaction *tx.Transaction, tableName string, metadataManager
 	return tp, nil
 }
 
-// Open creates a table scan for this query
+// Open creates a table scan for this query. The records the scan changes are counted
+// towards the next refresh of the statistics of the table (see metadata.Manager#RecordTableChanges).
 func (tp *TablePlan) Open() (scan.Scan, error) {
-	return table.NewTableScan(tp.transaction, tp.tableName, tp.layout)
+	tableScan, err := table.NewTableScan(tp.transaction, tp.tableName, tp.layout)
+	if err != nil {
+		return nil, err
+	}
+	tableScan.SetChangeHandler(func() {
+		tp.metadataManager.RecordTableChanges(tp.tableName, 1)
+	})
+	return tableScan, nil
+}
+
+// OpenFields creates a table scan like Open that only reads the specified fields of the table, ignoring the others,
+// for a projection reading a few fields of a wide table (see table.NewProjectedTableScan). The scan cannot change
+// records, so it is only meant for queries. A scan of every field of the table is opened by Open.
+func (tp *TablePlan) OpenFields(fields []string) (scan.Scan, error) {
+	if !slices.ContainsFunc(tp.layout.Schema().Fields(), func(field string) bool { return !slices.Contains(fields, field) }) {
+		return tp.Open()
+	}
+	return table.NewProjectedTableScan(tp.transaction, tp.tableName, tp.layout, fields)
 }
 
 // BlocksAccessed estimates the number of block accesses for the table,
@@ -64,3 +87,10 @@ func (tp *TablePlan) DistinctValues(fieldName string) int {
 func (tp *TablePlan) Schema() *record.Schema {
 	return tp.layout.Schema()
 }
+
+// ToNode returns the description of the table plan.
+func (tp *TablePlan) ToNode() *PlanNode {
+	node := newPlanNode("TableScan", tp)
+	node.Table = tp.tableName
+	return node
+}
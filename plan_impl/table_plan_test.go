@@ -18,7 +18,7 @@ import (
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
 )
 
-func setupTestEnvironment(t *testing.T, blockSize, numBuffers int) (*tx.Transaction, func()) {
+func setupTestEnvironment(t testing.TB, blockSize, numBuffers int) (*tx.Transaction, func()) {
 	dbDir := t.TempDir()
 	fm, err := file.NewManager(dbDir, blockSize)
 	require.NoError(t, err)
@@ -39,9 +39,6 @@ func setupTestEnvironment(t *testing.T, blockSize, numBuffers int) (*tx.Transact
 }
 
 func createTableMetadataWithSchema(t *testing.T, txn *tx.Transaction, tableName string, schemaFields map[string]interface{}) *metadata.Manager {
-	mdm, err := metadata.NewManager(true, txn)
-	require.NoError(t, err)
-
 	schema := record.NewSchema()
 	for fieldName, fieldType := range schemaFields {
 		switch fieldType.(type) {
@@ -56,6 +53,16 @@ func createTableMetadataWithSchema(t *testing.T, txn *tx.Transaction, tableName
 		}
 	}
 
+	return createTableMetadata(t, txn, tableName, schema)
+}
+
+// createTableMetadata registers the table with the given schema in a fresh catalog.
+// Tests that write records through their own layout should use this helper with the
+// same schema, so that the catalog layout matches the layout the records were written with.
+func createTableMetadata(t testing.TB, txn *tx.Transaction, tableName string, schema *record.Schema) *metadata.Manager {
+	mdm, err := metadata.NewManager(true, txn)
+	require.NoError(t, err)
+
 	err = mdm.CreateTable(tableName, schema, txn)
 	require.NoError(t, err)
 	return mdm
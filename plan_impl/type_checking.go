@@ -0,0 +1,62 @@
+package plan_impl
+
+import (
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// TypeCheckMode determines how statements whose predicates compare values
+// of incompatible types, such as "name = 42" on a varchar field, are planned.
+type TypeCheckMode int
+
+const (
+	// StrictTypeChecking rejects such statements with an error wrapping query.ErrTypeMismatch.
+	StrictTypeChecking TypeCheckMode = iota
+	// LenientTypeChecking plans them anyway: the incompatible comparisons are false for every record.
+	LenientTypeChecking
+)
+
+// TypeCheckConfigurer is implemented by query and update planners
+// that check the types of the predicates of the statements they plan.
+type TypeCheckConfigurer interface {
+	// SetTypeCheckMode sets how the planner handles predicates comparing values of incompatible types.
+	SetTypeCheckMode(mode TypeCheckMode)
+}
+
+// typeChecker checks the types of the predicates of statements (see query.Predicate#CheckTypes).
+// It is embedded in the planners to implement TypeCheckConfigurer, and checks types strictly by default.
+// The mode is not synchronized, so it must be set before statements are planned.
+type typeChecker struct {
+	mode TypeCheckMode
+}
+
+// SetTypeCheckMode sets how the planner handles predicates comparing values of incompatible types.
+func (c *typeChecker) SetTypeCheckMode(mode TypeCheckMode) {
+	c.mode = mode
+}
+
+// checkTypes returns an error if the predicate compares values of incompatible types
+// when the fields are resolved against the specified schema, unless types are checked leniently.
+func (c *typeChecker) checkTypes(predicate *query.Predicate, schema *record.Schema) error {
+	if c.mode == LenientTypeChecking {
+		return nil
+	}
+	return predicate.CheckTypes(schema)
+}
+
+// checkSemiJoinTypes returns an error wrapping query.ErrTypeMismatch if the field of the schema is looked for among
+// the values of a subquery field of an incompatible type, which none of them equals, unless types are checked leniently.
+func (c *typeChecker) checkSemiJoinTypes(fieldName string, schema *record.Schema, subqueryField string, subquerySchema *record.Schema) error {
+	if c.mode == LenientTypeChecking {
+		return nil
+	}
+	fieldType, subqueryType := schema.Type(fieldName), subquerySchema.Type(subqueryField)
+	if !types.AreComparable(fieldType, subqueryType) {
+		return fmt.Errorf("%w: field %s of type %s looked for among the values of subquery field %s of type %s",
+			query.ErrTypeMismatch, fieldName, fieldType, subqueryField, subqueryType)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package plan_impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+func TestPlanner_TypeChecking(t *testing.T) {
+	planners := map[string]func(*metadata.Manager) UpdatePlanner{
+		"basic": NewBasicUpdatePlanner,
+		"index": NewIndexUpdatePlanner,
+	}
+
+	for name, newUpdatePlanner := range planners {
+		t.Run(name, func(t *testing.T) {
+			fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+			mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+			p := NewPlanner(NewBasicQueryPlanner(mdm), newUpdatePlanner(mdm))
+
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			defer func() { require.NoError(t, txn.Commit()) }()
+			for _, sql := range []string{
+				"create table people (id int, name varchar(10), born date, dept_id int)",
+				"create table depts (did int, dname varchar(10))",
+				"insert into people (id, name, born, dept_id) values (1, 'ann', '1990-04-01', 10)",
+				"insert into people (id, name, born, dept_id) values (2, 'bob', '1985-12-24', 20)",
+				"insert into depts (did, dname) values (10, 'sales')",
+			} {
+				_, err := p.ExecuteUpdate(sql, txn)
+				require.NoError(t, err, sql)
+			}
+
+			countRows := func(sql string) (int, error) {
+				queryPlan, err := p.CreateQueryPlan(sql, txn)
+				if err != nil {
+					return 0, err
+				}
+				s, err := queryPlan.Open()
+				require.NoError(t, err)
+				defer s.Close()
+				count := 0
+				for {
+					next, err := s.Next()
+					require.NoError(t, err)
+					if !next {
+						return count, nil
+					}
+					count++
+				}
+			}
+
+			accepted := map[string]int{
+				"select id from people where name = 'ann'":                                        1,
+				"select id from people where dept_id = 10":                                        1,
+				"select id from people where born < '1989-01-01'":                                 1,
+				"select id from people where born = '1990-04-01 00:00:00'":                        1,
+				"select id from people, depts where dept_id = did":                                1,
+				"select id from people, depts where did = dept_id and id = 1":                     1,
+				"select name, count(id) from people group by name having count(id) = 1":           2,
+				"select dept_id, max(name) from people group by dept_id having max(name) = 'ann'": 1,
+			}
+			for sql, expected := range accepted {
+				count, err := countRows(sql)
+				require.NoError(t, err, sql)
+				assert.Equal(t, expected, count, sql)
+			}
+
+			rejected := map[string]string{
+				"select id from people where name = 42":                                   "field name of type varchar compared with int constant 42",
+				"select id from people where born = 19900401":                             "field born of type date compared with int constant 19900401",
+				"select id from people where id = 'one'":                                  "field id of type int compared with varchar constant one",
+				"select id from people, depts where name = did":                           "name of type varchar compared with did of type int in name = did",
+				"select name, count(id) from people group by name having count(id) = 'x'": "field countOfid of type long compared with varchar constant x",
+				"select name, count(id) from people group by name having name = 1":        "field name of type varchar compared with int constant 1",
+			}
+			for sql, expected := range rejected {
+				_, err := countRows(sql)
+				assert.ErrorIs(t, err, query.ErrTypeMismatch, sql)
+				assert.ErrorContains(t, err, expected, sql)
+			}
+
+			for _, sql := range []string{
+				"update people set name = 'x' where born = 5",
+				"delete from people where name = 42",
+			} {
+				_, err := p.ExecuteUpdate(sql, txn)
+				assert.ErrorIs(t, err, query.ErrTypeMismatch, sql)
+			}
+			count, err := countRows("select id from people where name = 'x'")
+			require.NoError(t, err)
+			assert.Zero(t, count, "a rejected update modifies nothing")
+
+			// In lenient mode, the incompatible comparisons are false for every record.
+			p.SetTypeCheckMode(LenientTypeChecking)
+			count, err = countRows("select id from people where name = 42")
+			require.NoError(t, err)
+			assert.Zero(t, count)
+			deleted, err := p.ExecuteUpdate("delete from people where name = 42", txn)
+			require.NoError(t, err)
+			assert.Zero(t, deleted)
+
+			p.SetTypeCheckMode(StrictTypeChecking)
+			_, err = countRows("select id from people where name = 42")
+			assert.ErrorIs(t, err, query.ErrTypeMismatch)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package plan_impl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/utils"
+)
+
+// ErrDuplicateKey is returned when a write would give two records of a unique index the same key.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// uniqueKeyLockStripes is the number of locks the keys of a unique index are hashed into (see lockUniqueKey).
+// Two keys hashed into the same lock are locked together, so that a transaction writing one of them waits for
+// a transaction writing the other, and two transactions each writing one key and then the other may deadlock.
+// There are enough of them for two of the keys written by concurrent transactions to seldom share a lock,
+// and each only takes room in the lock table while a transaction holds it.
+const uniqueKeyLockStripes = 1 << 20
+
+// lockUniqueKey obtains an exclusive lock on the specified key of a unique index, held until the transaction completes.
+// Every check for a record having a key, and every write of the key to the index, is made under this lock,
+// so that two transactions cannot both find a key missing and then both write it.
+// The keys are hashed into uniqueKeyLockStripes locks, so writers of different keys seldom wait for each other.
+func lockUniqueKey(transaction *tx.Transaction, indexInfo *metadata.IndexInfo, key any) error {
+	hashValue, err := utils.HashValue(key)
+	if err != nil {
+		return err
+	}
+	return transaction.XLockFile(fmt.Sprintf("%s-unique-%d", indexInfo.IndexName(), hashValue%uniqueKeyLockStripes))
+}
+
+// findKey returns the ID of a record of the index having the specified key, other than the specified record,
+// or nil if there is none. A nil record to exclude excludes no record.
+func findKey(idx index.Index, key any, except *record.ID) (*record.ID, error) {
+	if err := idx.BeforeFirst(key); err != nil {
+		return nil, err
+	}
+	for {
+		hasNext, err := idx.Next()
+		if err != nil || !hasNext {
+			return nil, err
+		}
+		recordID, err := idx.GetDataRecordID()
+		if err != nil {
+			return nil, err
+		}
+		if except == nil || !recordID.Equals(except) {
+			return recordID, nil
+		}
+	}
+}
+
+// checkUniqueKey locks the specified key of a unique index, and returns an error wrapping ErrDuplicateKey
+// if a record of the index other than the specified one has the key.
+func checkUniqueKey(transaction *tx.Transaction, indexInfo *metadata.IndexInfo, idx index.Index, key any, except *record.ID) error {
+	if err := lockUniqueKey(transaction, indexInfo, key); err != nil {
+		return err
+	}
+	recordID, err := findKey(idx, key, except)
+	if err != nil {
+		return err
+	}
+	if recordID != nil {
+		return fmt.Errorf("%w %v for field %s in unique index %s", ErrDuplicateKey, key, indexInfo.FieldName(), indexInfo.IndexName())
+	}
+	return nil
+}
+
+// checkUniqueEntries checks the entries that a modification of the specified record gives it in the unique indexes,
+// and returns an error if one of them duplicates the entry of another record. Only the entries that change are checked.
+// The indexes are opened with the specified function.
+func checkUniqueEntries(transaction *tx.Transaction, indexes []*metadata.IndexInfo, openIndex func(i int) (index.Index, error),
+	oldEntries, newEntries []indexEntry, recordID *record.ID) error {
+	for i, indexInfo := range indexes {
+		if !indexInfo.Unique() || !newEntries[i].included || oldEntries[i].equals(newEntries[i]) {
+			continue
+		}
+		idx, err := openIndex(i)
+		if err != nil {
+			return err
+		}
+		if err := checkUniqueKey(transaction, indexInfo, idx, newEntries[i].val, recordID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateIndexEntries replaces the record's entry in each index whose entry changed.
+// The indexes are opened with the specified function.
+func updateIndexEntries(indexes []*metadata.IndexInfo, openIndex func(i int) (index.Index, error),
+	oldEntries, newEntries []indexEntry, recordID *record.ID) error {
+	for i := range indexes {
+		oldEntry, newEntry := oldEntries[i], newEntries[i]
+		if oldEntry.equals(newEntry) {
+			continue
+		}
+
+		idx, err := openIndex(i)
+		if err != nil {
+			return err
+		}
+		if oldEntry.included {
+			if err := idx.Delete(oldEntry.val, recordID); err != nil {
+				return err
+			}
+		}
+		if newEntry.included {
+			if err := idx.Insert(newEntry.val, recordID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
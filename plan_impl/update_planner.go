@@ -2,6 +2,8 @@ package plan_impl
 
 import (
 	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/tx"
 )
 
@@ -11,11 +13,16 @@ type UpdatePlanner interface {
 	ExecuteInsert(data *parse.InsertData, transaction *tx.Transaction) (int, error)
 
 	// ExecuteDelete executes the specified delete statement, and
-	// returns the number of affected records.
+	// returns the number of affected records. A statement with a
+	// limit stops once it has deleted that many records.
 	ExecuteDelete(data *parse.DeleteData, transaction *tx.Transaction) (int, error)
 
 	// ExecuteModify executes the specified modify statement, and
-	// returns the number of affected records.
+	// returns the number of affected records. A statement with a
+	// limit stops once it has modified that many records.
+	// Each matching record is modified exactly once, even if the
+	// modification moves it or it still satisfies the predicate
+	// afterward: the matching records are found before any is modified.
 	ExecuteModify(data *parse.ModifyData, transaction *tx.Transaction) (int, error)
 
 	// ExecuteCreateTable executes the specified create table statement, and
@@ -29,4 +36,38 @@ type UpdatePlanner interface {
 	// ExecuteCreateIndex executes the specified create index statement, and
 	// returns the number of affected records.
 	ExecuteCreateIndex(data *parse.CreateIndexData, transaction *tx.Transaction) (int, error)
+
+	// ExecuteDropTable executes the specified drop table statement, which
+	// also drops the indexes of the table, and returns the number of affected records.
+	ExecuteDropTable(data *parse.DropTableData, transaction *tx.Transaction) (int, error)
+
+	// ExecuteDropIndex executes the specified drop index statement, and
+	// returns the number of affected records.
+	ExecuteDropIndex(data *parse.DropIndexData, transaction *tx.Transaction) (int, error)
+}
+
+// limitReached returns true if a statement limited to modifying the specified number of
+// records (see parse.DeleteData#Limit) has already modified count records.
+func limitReached(count, limit int) bool {
+	return limit != parse.NoLimit && count >= limit
+}
+
+// matchingRecordIDs returns the record IDs of the records of the specified scan,
+// up to the specified limit (see parse.DeleteData#Limit).
+// Modifications collect the records they apply to before modifying any of them,
+// so that the modifications cannot change which records the scan visits.
+func matchingRecordIDs(s scan.UpdateScan, limit int) ([]*record.ID, error) {
+	var recordIDs []*record.ID
+	for !limitReached(len(recordIDs), limit) {
+		hasNext, err := s.Next()
+		if err != nil || !hasNext {
+			return recordIDs, err
+		}
+		recordID, err := s.GetRecordID()
+		if err != nil {
+			return recordIDs, err
+		}
+		recordIDs = append(recordIDs, recordID)
+	}
+	return recordIDs, nil
 }
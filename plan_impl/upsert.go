@@ -0,0 +1,118 @@
+package plan_impl
+
+import (
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// lockUniqueKeys locks the keys that a record having the specified values has in the unique indexes
+// whose indexed fields all have a value. The other keys are only known once the record is written,
+// and are locked when they are checked.
+func (pi *preparedInsert) lockUniqueKeys(values map[string]any, transaction *tx.Transaction) error {
+	for _, indexInfo := range pi.indexes {
+		if !indexInfo.Unique() {
+			continue
+		}
+		if key, ok := indexInfo.KeyOf(values); ok {
+			if err := lockUniqueKey(transaction, indexInfo, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findConflict returns the ID of the record that a record having the specified values conflicts with,
+// or nil if there is none. The records conflict if they have the same key in a unique index on the target field,
+// or in any unique index if there is no target field; only the unique indexes on fields all having a non-null value are searched.
+// The keys must have been locked (see lockUniqueKeys), so that the conflicting record cannot change until the transaction completes.
+func (pi *preparedInsert) findConflict(tableName, targetField string, values map[string]any) (*record.ID, error) {
+	if targetField != "" {
+		if _, ok := values[targetField]; !ok {
+			return nil, fmt.Errorf("conflict target %s is not one of the inserted fields", targetField)
+		}
+	}
+
+	candidate := &rowScan{values: values}
+	searched := false
+	for i, indexInfo := range pi.indexes {
+		if !indexInfo.Unique() || (targetField != "" && indexInfo.FieldName() != targetField) {
+			continue
+		}
+		searched = true
+		key, ok := indexInfo.KeyOf(values)
+		if !ok {
+			continue
+		}
+		included, err := rowSatisfies(indexInfo.Predicate(), candidate)
+		if err != nil {
+			return nil, err
+		}
+		if !included {
+			continue
+		}
+		conflictID, err := findKey(pi.openIndexes[i], key, nil)
+		if err != nil || conflictID != nil {
+			return conflictID, err
+		}
+	}
+	if targetField != "" && !searched {
+		return nil, fmt.Errorf("there is no unique index on %s.%s to detect conflicts with", tableName, targetField)
+	}
+	return nil, nil
+}
+
+// updateConflict applies the assignments of an ON CONFLICT DO UPDATE clause to the specified record,
+// keeping every index on the table up to date. The assignments are evaluated against the record.
+func (up *IndexUpdatePlanner) updateConflict(tableName string, prepared *preparedInsert, recordID *record.ID,
+	assignments []parse.Assignment, transaction *tx.Transaction) error {
+	updateScan := prepared.updateScan
+	if err := updateScan.MoveToRecordID(recordID); err != nil {
+		return err
+	}
+
+	newValues := make(map[string]any, len(assignments))
+	for _, assignment := range assignments {
+		fieldName := assignment.FieldName()
+		if !prepared.schema.HasField(fieldName) {
+			return fmt.Errorf("field %s is not in table %s", fieldName, tableName)
+		}
+		newValue, err := assignment.Value().Evaluate(updateScan)
+		if err != nil {
+			return err
+		}
+		if newValues[fieldName], err = coerceFieldValue(tableName, prepared.schema, fieldName, newValue); err != nil {
+			return err
+		}
+	}
+	filterPredicate, err := up.tableFilters.Predicate(tableName, prepared.schema, transaction)
+	if err != nil {
+		return err
+	}
+	if err := checkFilter(tableName, filterPredicate, updateScan, newValues); err != nil {
+		return err
+	}
+
+	// the new index entries are computed before the record is modified, so that a duplicate key leaves it unchanged.
+	oldEntries, err := indexEntries(prepared.indexes, updateScan)
+	if err != nil {
+		return err
+	}
+	newEntries, err := indexEntries(prepared.indexes, &rowScan{base: updateScan, values: newValues})
+	if err != nil {
+		return err
+	}
+	if err := checkUniqueEntries(transaction, prepared.indexes, prepared.openIndex, oldEntries, newEntries, recordID); err != nil {
+		return err
+	}
+
+	for _, assignment := range assignments {
+		if err := updateScan.SetVal(assignment.FieldName(), newValues[assignment.FieldName()]); err != nil {
+			return err
+		}
+	}
+	return updateIndexEntries(prepared.indexes, prepared.openIndex, oldEntries, newEntries, recordID)
+}
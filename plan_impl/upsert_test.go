@@ -0,0 +1,251 @@
+package plan_impl
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+)
+
+// setupUniqueIndexTest creates a users table with a unique index on id and an index on name,
+// and returns a planner maintaining its indexes.
+func setupUniqueIndexTest(t *testing.T) (*Planner, *file.Manager, *log.Manager, *buffer.Manager, *concurrency.LockTable) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 16)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table users (id int, name varchar(10), visits int)",
+		"create unique index idx_id on users (id)",
+		"create index idx_name on users (name)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+	return p, fm, lm, bm, lt
+}
+
+// executeUpdate executes the update statement in its own transaction, and returns the number of affected records.
+func executeUpdate(t *testing.T, p *Planner, sql string, fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *concurrency.LockTable) (int, error) {
+	t.Helper()
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	count, err := p.ExecuteUpdate(sql, txn)
+	if err != nil {
+		require.NoError(t, txn.Rollback())
+		return 0, err
+	}
+	require.NoError(t, txn.Commit())
+	return count, nil
+}
+
+func TestIndexUpdatePlanner_UniqueIndex(t *testing.T) {
+	p, fm, lm, bm, lt := setupUniqueIndexTest(t)
+	users := func(predicate string) []map[string]any {
+		return runPlannerQuery(t, p, "select id, name from users"+predicate, fm, lm, bm, lt, []string{"id", "name"})
+	}
+
+	for _, sql := range []string{
+		"insert into users (id, name) values (1, 'alice')",
+		"insert into users (id, name) values (2, 'bob')",
+	} {
+		count, err := executeUpdate(t, p, sql, fm, lm, bm, lt)
+		require.NoError(t, err, sql)
+		assert.Equal(t, 1, count)
+	}
+
+	// A duplicate key fails the insert, without leaving the record behind.
+	_, err := executeUpdate(t, p, "insert into users (id, name) values (1, 'carol')", fm, lm, bm, lt)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.ErrorContains(t, err, "duplicate key 1 for field id in unique index idx_id")
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.ExecuteUpdate("insert into users (id, name) values (3, 'carol')", txn)
+	require.NoError(t, err)
+	lastLogRecord := func() []byte {
+		iterator, err := lm.Iterator()
+		require.NoError(t, err)
+		require.True(t, iterator.HasNext())
+		logRecord, err := iterator.Next()
+		require.NoError(t, err)
+		return logRecord
+	}
+	logged := lastLogRecord()
+	_, err = p.ExecuteUpdate("insert into users (id, name) values (3, 'dave')", txn)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.Equal(t, logged, lastLogRecord(), "the rejected insert is not written, so nothing is logged")
+	require.NoError(t, txn.Commit())
+	assert.Len(t, users(""), 3)
+	assert.Empty(t, users(" where name = 'dave'"))
+
+	// So does a modification giving a record the key of another, which leaves the record unchanged.
+	_, err = executeUpdate(t, p, "update users set id = 1 where name = 'bob'", fm, lm, bm, lt)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.Equal(t, []map[string]any{{"id": 2, "name": "bob"}}, users(" where id = 2"))
+
+	// A record can keep its own key, or move to a free one.
+	count, err := executeUpdate(t, p, "update users set id = 2 where name = 'bob'", fm, lm, bm, lt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = executeUpdate(t, p, "update users set id = 4 where name = 'bob'", fm, lm, bm, lt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = executeUpdate(t, p, "insert into users (id, name) values (2, 'erin')", fm, lm, bm, lt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// A unique index cannot be created over duplicate keys.
+	_, err = executeUpdate(t, p, "insert into users (id, name) values (5, 'alice')", fm, lm, bm, lt)
+	require.NoError(t, err)
+	_, err = executeUpdate(t, p, "create unique index idx_unique_name on users (name)", fm, lm, bm, lt)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.ErrorContains(t, err, "cannot create unique index idx_unique_name")
+}
+
+func TestIndexUpdatePlanner_Upsert(t *testing.T) {
+	p, fm, lm, bm, lt := setupUniqueIndexTest(t)
+	users := func(predicate string) []map[string]any {
+		return runPlannerQuery(t, p, "select id, name, visits from users"+predicate, fm, lm, bm, lt, []string{"id", "name", "visits"})
+	}
+	upsert := func(sql string) int {
+		t.Helper()
+		count, err := executeUpdate(t, p, sql, fm, lm, bm, lt)
+		require.NoError(t, err, sql)
+		return count
+	}
+
+	// Without a conflict, the record is inserted.
+	assert.Equal(t, 1, upsert("insert into users (id, name, visits) values (1, 'alice', 1) on conflict (id) do update set visits = 2"))
+	assert.Equal(t, 1, upsert("insert into users (id, name, visits) values (2, 'bob', 1) on conflict do nothing"))
+	assert.Equal(t, []map[string]any{{"id": 1, "name": "alice", "visits": 1}}, users(" where id = 1"))
+
+	// With one, DO NOTHING leaves the existing record alone.
+	assert.Equal(t, 0, upsert("insert into users (id, name, visits) values (1, 'carol', 1) on conflict (id) do nothing"))
+	assert.Equal(t, 0, upsert("insert into users (id, name, visits) values (2, 'carol', 1) on conflict do nothing"))
+	assert.Len(t, users(""), 2)
+	assert.Empty(t, users(" where name = 'carol'"))
+
+	// DO UPDATE modifies it in place, evaluating the assignments against it, and maintains the other indexes.
+	assert.Equal(t, 1, upsert("insert into users (id, name, visits) values (1, 'alice', 1) on conflict (id) do update set name = 'alicia', visits = 5"))
+	assert.Equal(t, 1, upsert("insert into users (id, name, visits) values (1, 'alice', 1) on conflict (id) do update set name = name, visits = id"))
+	assert.Equal(t, []map[string]any{{"id": 1, "name": "alicia", "visits": 1}}, users(" where id = 1"))
+	assert.Equal(t, []map[string]any{{"id": 1, "name": "alicia", "visits": 1}}, users(" where name = 'alicia'"))
+	assert.Empty(t, users(" where name = 'alice'"))
+	assert.Len(t, users(""), 2)
+
+	// Changing the key of the existing record is checked like any modification.
+	_, err := executeUpdate(t, p, "insert into users (id, name) values (1, 'alice') on conflict (id) do update set id = 2", fm, lm, bm, lt)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.Equal(t, 1, upsert("insert into users (id, name) values (1, 'alice') on conflict (id) do update set id = 3"))
+	assert.Equal(t, []map[string]any{{"id": 3, "name": "alicia", "visits": 1}}, users(" where id = 3"))
+	assert.Empty(t, users(" where id = 1"))
+
+	// A conflict target needs a unique index, and a value.
+	for sql, message := range map[string]string{
+		"insert into users (id, name) values (1, 'alice') on conflict (name) do nothing":                  "there is no unique index on users.name to detect conflicts with",
+		"insert into users (name) values ('alice') on conflict (id) do update set visits = 1":             "conflict target id is not one of the inserted fields",
+		"insert into users (id, name) values (3, 'alice') on conflict (id) do update set missing = 1":     "field missing is not in table users",
+		"insert into users (id, name) values (3, 'alice') on conflict (id) do update set visits = 'many'": "invalid value for field visits",
+	} {
+		_, err := executeUpdate(t, p, sql, fm, lm, bm, lt)
+		assert.ErrorContains(t, err, message, sql)
+	}
+	assert.Equal(t, []map[string]any{{"id": 3, "name": "alicia", "visits": 1}}, users(" where id = 3"))
+}
+
+func TestIndexUpdatePlanner_ConcurrentUpserts(t *testing.T) {
+	p, fm, lm, bm, lt := setupUniqueIndexTest(t)
+	_, err := executeUpdate(t, p, "insert into users (id, name, visits) values (0, 'admin', 0)", fm, lm, bm, lt)
+	require.NoError(t, err)
+	fields := []string{"id", "name", "visits"}
+
+	// upsertWhileHeld runs the second upsert of a key in its own transaction while the transaction
+	// of the first one is still active, and checks that it waits for that transaction to complete.
+	upsertWhileHeld := func(first, second string, commitFirst bool) int {
+		t.Helper()
+		firstTx := tx.NewTransaction(fm, lm, bm, lt)
+		count, err := p.ExecuteUpdate(first, firstTx)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		type result struct {
+			count int
+			err   error
+		}
+		done := make(chan result, 1)
+		go func() {
+			secondTx := tx.NewTransaction(fm, lm, bm, lt)
+			count, err := p.ExecuteUpdate(second, secondTx)
+			if err != nil {
+				_ = secondTx.Rollback()
+				done <- result{err: err}
+				return
+			}
+			done <- result{count: count, err: secondTx.Commit()}
+		}()
+		select {
+		case r := <-done:
+			t.Fatalf("the upsert did not wait for the transaction holding the key: %v", r.err)
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		if commitFirst {
+			require.NoError(t, firstTx.Commit())
+		} else {
+			require.NoError(t, firstTx.Rollback())
+		}
+		r := <-done
+		require.NoError(t, r.err)
+		return r.count
+	}
+
+	// The second upsert sees the record inserted by the first one once it commits, and updates it.
+	count := upsertWhileHeld(
+		"insert into users (id, name, visits) values (1, 'a', 1) on conflict (id) do update set name = 'a'",
+		"insert into users (id, name, visits) values (1, 'b', 1) on conflict (id) do update set name = 'b', visits = 2",
+		true)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []map[string]any{{"id": 1, "name": "b", "visits": 2}},
+		runPlannerQuery(t, p, "select id, name, visits from users where id = 1", fm, lm, bm, lt, fields))
+
+	// Or skips it.
+	count = upsertWhileHeld(
+		"insert into users (id, name, visits) values (2, 'a', 1) on conflict do nothing",
+		"insert into users (id, name, visits) values (2, 'b', 1) on conflict (id) do nothing",
+		true)
+	assert.Zero(t, count)
+	assert.Equal(t, []map[string]any{{"id": 2, "name": "a", "visits": 1}},
+		runPlannerQuery(t, p, "select id, name, visits from users where id = 2", fm, lm, bm, lt, fields))
+
+	// If the first one rolls back, the key is free again, and the second upsert inserts it.
+	count = upsertWhileHeld(
+		"insert into users (id, name, visits) values (3, 'a', 1) on conflict do nothing",
+		"insert into users (id, name, visits) values (3, 'b', 1) on conflict (id) do update set name = 'c'",
+		false)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []map[string]any{{"id": 3, "name": "b", "visits": 1}},
+		runPlannerQuery(t, p, "select id, name, visits from users where id = 3", fm, lm, bm, lt, fields))
+
+	// A plain insert of the key waits in the same way, and then fails.
+	upsertTx := tx.NewTransaction(fm, lm, bm, lt)
+	_, err = p.ExecuteUpdate("insert into users (id, name, visits) values (4, 'a', 1) on conflict (id) do update set visits = 5", upsertTx)
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		insertTx := tx.NewTransaction(fm, lm, bm, lt)
+		_, err := p.ExecuteUpdate("insert into users (id, name, visits) values (4, 'b', 1)", insertTx)
+		done <- errors.Join(err, insertTx.Rollback())
+	}()
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, upsertTx.Commit())
+	assert.ErrorIs(t, <-done, ErrDuplicateKey)
+	assert.Len(t, runPlannerQuery(t, p, "select id from users", fm, lm, bm, lt, []string{"id"}), 5)
+}
@@ -0,0 +1,48 @@
+package plan_impl
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// coerceFieldValue converts the value to the type of the specified field of the schema of the specified table,
+// returning an error naming the field if the value is not compatible with it, or if it is a string with
+// more characters than the declared length of a varchar field (wrapping types.ErrValueTooLong).
+// Null values, which any field may hold, and values for fields that are not in the schema
+// are returned unchanged, leaving it to the scan to report the unknown field.
+func coerceFieldValue(tableName string, schema *record.Schema, fieldName string, val any) (any, error) {
+	if val == nil || !schema.HasField(fieldName) {
+		return val, nil
+	}
+	coerced, err := types.CoerceValue(val, schema.Type(fieldName))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for field %s: %w", fieldName, err)
+	}
+	if s, ok := coerced.(string); ok && schema.Type(fieldName) == types.Varchar {
+		if length := utf8.RuneCountInString(s); length > schema.Length(fieldName) {
+			return nil, fmt.Errorf("invalid value for field %s: %w: %q has %d characters, but field %s of table %s is varchar(%d)",
+				fieldName, types.ErrValueTooLong, s, length, fieldName, tableName, schema.Length(fieldName))
+		}
+	}
+	return coerced, nil
+}
+
+// coerceFieldValues converts each value to the type of the corresponding field of the specified table.
+// It is used to check all the values of an insert before any record is written.
+func coerceFieldValues(tableName string, schema *record.Schema, fieldNames []string, vals []any) ([]any, error) {
+	if len(fieldNames) != len(vals) {
+		return nil, fmt.Errorf("%d values given for %d fields", len(vals), len(fieldNames))
+	}
+	coerced := make([]any, len(vals))
+	for i, fieldName := range fieldNames {
+		val, err := coerceFieldValue(tableName, schema, fieldName, vals[i])
+		if err != nil {
+			return nil, err
+		}
+		coerced[i] = val
+	}
+	return coerced, nil
+}
@@ -0,0 +1,247 @@
+package plan_impl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdatePlanners_ValueCoercion(t *testing.T) {
+	planners := map[string]func(*metadata.Manager) UpdatePlanner{
+		"basic": NewBasicUpdatePlanner,
+		"index": NewIndexUpdatePlanner,
+	}
+
+	for name, newUpdatePlanner := range planners {
+		t.Run(name, func(t *testing.T) {
+			fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+			mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+			p := NewPlanner(NewBasicQueryPlanner(mdm), newUpdatePlanner(mdm))
+
+			execute := func(sql string) (int, error) {
+				txn := tx.NewTransaction(fm, lm, bm, lt)
+				defer func() { require.NoError(t, txn.Commit()) }()
+				return p.ExecuteUpdate(sql, txn)
+			}
+			selectIDs := func(sql string) []any {
+				var ids []any
+				for _, row := range runPlannerQuery(t, p, sql, fm, lm, bm, lt, []string{"id"}) {
+					ids = append(ids, row["id"])
+				}
+				return ids
+			}
+
+			schema := record.NewSchema()
+			schema.AddIntField("id")
+			schema.AddShortField("code")
+			schema.AddLongField("serial")
+			schema.AddDateField("installed")
+			schema.AddBoolField("active")
+			txn := tx.NewTransaction(fm, lm, bm, lt)
+			_, err := p.updatePlanner.ExecuteCreateTable(parse.NewCreateTableData("devices", schema), txn)
+			require.NoError(t, err)
+			_, err = p.updatePlanner.ExecuteCreateIndex(parse.NewCreateIndexData("idx_code", "devices", "code"), txn)
+			require.NoError(t, err)
+			require.NoError(t, txn.Commit())
+
+			count, err := execute("INSERT INTO devices (id, code, serial, installed, active) VALUES (1, 42, 7, '2024-03-15', 1)")
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+
+			rows := runPlannerQuery(t, p, "SELECT id, code, serial, installed, active FROM devices", fm, lm, bm, lt,
+				[]string{"id", "code", "serial", "installed", "active"})
+			require.Len(t, rows, 1)
+			assert.Equal(t, 1, rows[0]["id"])
+			assert.Equal(t, int16(42), rows[0]["code"])
+			assert.Equal(t, int64(7), rows[0]["serial"])
+			assert.True(t, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC).Equal(rows[0]["installed"].(time.Time)))
+			assert.Equal(t, true, rows[0]["active"])
+
+			rejections := []struct {
+				sql     string
+				target  error
+				message string
+			}{
+				{
+					"INSERT INTO devices (id, code, serial, installed, active) VALUES (2, 70000, 7, '2024-03-15', true)",
+					types.ErrValueOutOfRange,
+					"invalid value for field code: value out of range: 70000 does not fit type short",
+				},
+				{
+					"INSERT INTO devices (id, code, serial, installed, active) VALUES (2, 1, 7, 'yesterday', true)",
+					types.ErrIncompatibleValue,
+					"invalid value for field installed: incompatible value: cannot convert string value yesterday to type date",
+				},
+				{
+					"INSERT INTO devices (id, code, serial, installed, active) VALUES (2, 1, 7, '2024-03-15', 'maybe')",
+					types.ErrIncompatibleValue,
+					"invalid value for field active: incompatible value: cannot convert string value maybe to type bool",
+				},
+				{
+					"INSERT INTO devices (id, code, serial, installed, active) VALUES ('two', 1, 7, '2024-03-15', true)",
+					types.ErrIncompatibleValue,
+					"invalid value for field id: incompatible value: cannot convert string value two to type int",
+				},
+				{
+					"UPDATE devices SET code = 99999 WHERE id = 1",
+					types.ErrValueOutOfRange,
+					"invalid value for field code: value out of range: 99999 does not fit type short",
+				},
+			}
+			for _, rejection := range rejections {
+				_, err := execute(rejection.sql)
+				require.ErrorIs(t, err, rejection.target, rejection.sql)
+				assert.EqualError(t, err, rejection.message)
+			}
+			// None of the rejected statements wrote a record.
+			assert.Equal(t, []any{1}, selectIDs("SELECT id FROM devices"))
+			assert.Equal(t, []any{1}, selectIDs("SELECT id FROM devices WHERE code = 42"))
+
+			// Predicate constants are converted to the types of the fields they are compared with.
+			assert.Equal(t, []any{1}, selectIDs("SELECT id FROM devices WHERE serial = 7 AND installed = '2024-03-15'"))
+			assert.Equal(t, []any{1}, selectIDs("SELECT id FROM devices WHERE code >= 40 AND active = 1"))
+
+			count, err = execute("UPDATE devices SET code = 43 WHERE code = 42")
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+			assert.Nil(t, selectIDs("SELECT id FROM devices WHERE code = 42"))
+			assert.Equal(t, []any{1}, selectIDs("SELECT id FROM devices WHERE code = 43"))
+
+			count, err = execute("DELETE FROM devices WHERE code = 43")
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+			assert.Nil(t, selectIDs("SELECT id FROM devices"))
+		})
+	}
+}
+
+func TestUpdatePlanners_IntegerRange(t *testing.T) {
+	fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+	mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+	p := NewPlanner(NewBasicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+
+	execute := func(sql string) (int, error) {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		return p.ExecuteUpdate(sql, txn)
+	}
+
+	schema := record.NewSchema()
+	schema.AddStringField("kind", 10)
+	schema.AddShortField("code")
+	schema.AddIntField("amount")
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	_, err := p.updatePlanner.ExecuteCreateTable(parse.NewCreateTableData("ledger", schema), txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	// The boundaries of a short are accepted, and the values just past them are rejected.
+	_, err = execute("INSERT INTO ledger (kind, code, amount) VALUES ('a', 32767, 9223372036854775806)")
+	require.NoError(t, err)
+	_, err = execute("INSERT INTO ledger (kind, code, amount) VALUES ('a', 32768, 1)")
+	assert.ErrorIs(t, err, types.ErrValueOutOfRange)
+	assert.EqualError(t, err, "invalid value for field code: value out of range: 32768 does not fit type short")
+	_, err = execute("UPDATE ledger SET code = 32768 WHERE kind = 'a'")
+	assert.ErrorIs(t, err, types.ErrValueOutOfRange)
+	rows := runPlannerQuery(t, p, "SELECT code FROM ledger", fm, lm, bm, lt, []string{"code"})
+	assert.Equal(t, []map[string]any{{"code": int16(32767)}}, rows)
+
+	// Integer constants must fit an int.
+	_, err = execute("INSERT INTO ledger (kind, code, amount) VALUES ('a', 1, 9223372036854775808)")
+	assert.ErrorContains(t, err, "integer constant 9223372036854775808 is out of range for type int")
+
+	for _, sql := range []string{
+		"INSERT INTO ledger (kind, code, amount) VALUES ('a', 1, 1)",
+		"INSERT INTO ledger (kind, code, amount) VALUES ('b', 1, 9223372036854775807)",
+		"INSERT INTO ledger (kind, code, amount) VALUES ('b', 1, 1)",
+	} {
+		_, err = execute(sql)
+		require.NoError(t, err)
+	}
+
+	// The sum of the first group is exactly the largest int; the second overflows.
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	plan, err := p.CreateQueryPlan("SELECT kind, sum(amount) FROM ledger WHERE kind = 'a' GROUP BY kind", txn)
+	require.NoError(t, err)
+	s, err := plan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	hasNext, err := s.Next()
+	require.NoError(t, err)
+	require.True(t, hasNext)
+	sum, err := s.GetLong("sumOfamount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9223372036854775807), sum)
+
+	// Both the hash aggregation and the sort below the ORDER BY read every group when they are opened.
+	for _, sql := range []string{
+		"SELECT kind, sum(amount) FROM ledger GROUP BY kind",
+		"SELECT kind, sum(amount) FROM ledger GROUP BY kind ORDER BY kind",
+	} {
+		plan, err = p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err)
+		_, err = plan.Open()
+		assert.ErrorIs(t, err, types.ErrValueOutOfRange, sql)
+	}
+}
+
+func TestUpdatePlanners_VarcharLength(t *testing.T) {
+	planners := map[string]func(*metadata.Manager) UpdatePlanner{
+		"basic": NewBasicUpdatePlanner,
+		"index": NewIndexUpdatePlanner,
+	}
+
+	for name, newUpdatePlanner := range planners {
+		t.Run(name, func(t *testing.T) {
+			fm, lm, bm, lt := setupTestManagers(t, 800, 8)
+			mdm := createNewMetadataManager(t, fm, lm, bm, lt)
+			p := NewPlanner(NewBasicQueryPlanner(mdm), newUpdatePlanner(mdm))
+
+			execute := func(sql string) (int, error) {
+				txn := tx.NewTransaction(fm, lm, bm, lt)
+				defer func() { require.NoError(t, txn.Commit()) }()
+				return p.ExecuteUpdate(sql, txn)
+			}
+			for _, sql := range []string{
+				"CREATE TABLE people (id INT, name VARCHAR(5), city VARCHAR(5))",
+				"CREATE UNIQUE INDEX idx_id ON people (id)",
+				// Five characters fit, however many bytes they are encoded in.
+				"INSERT INTO people (id, name, city) VALUES (1, 'alice', 'oslo'), (2, 'ÿøüéñ', '東京都')",
+			} {
+				_, err := execute(sql)
+				require.NoError(t, err, sql)
+			}
+
+			rejections := map[string]string{
+				"INSERT INTO people (id, name, city) VALUES (3, 'bartholomew', 'rome')": `invalid value for field name: value too long: "bartholomew" has 11 characters, but field name of table people is varchar(5)`,
+				"INSERT INTO people (id, name, city) VALUES (3, 'bob', '東京都東京都')":       `invalid value for field city: value too long: "東京都東京都" has 6 characters, but field city of table people is varchar(5)`,
+				"UPDATE people SET city = 'ÿøüéñÿ' WHERE id = 1":                        `invalid value for field city: value too long: "ÿøüéñÿ" has 6 characters, but field city of table people is varchar(5)`,
+			}
+			if name == "index" {
+				rejections["INSERT INTO people (id, name, city) VALUES (1, 'al', 'oslo') ON CONFLICT (id) DO UPDATE SET name = 'alexandra'"] =
+					`invalid value for field name: value too long: "alexandra" has 9 characters, but field name of table people is varchar(5)`
+			}
+			for sql, message := range rejections {
+				_, err := execute(sql)
+				require.ErrorIs(t, err, types.ErrValueTooLong, sql)
+				assert.EqualError(t, err, message)
+			}
+
+			// The rejected statements left the records unchanged.
+			rows := runPlannerQuery(t, p, "SELECT id, name, city FROM people", fm, lm, bm, lt, []string{"id", "name", "city"})
+			assert.ElementsMatch(t, []map[string]any{
+				{"id": 1, "name": "alice", "city": "oslo"},
+				{"id": 2, "name": "ÿøüéñ", "city": "東京都"},
+			}, rows)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package plan_impl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+// expandView returns the query of the specified view, parsed from its definition, which the query reading
+// the view is planned with in place of a table. The conjuncts of the predicate of the query reading the view
+// that only read fields the view outputs unchanged are conjoined with the predicate of the view, if it is a
+// plain selection (see pushesIntoView), so that they choose the indexes of the tables the view reads and
+// filter its records before they leave the view.
+// It returns an error if the view is defined in terms of itself, directly or through other views.
+func (qp *BasicQueryPlanner) expandView(viewName, viewDefinition string, predicate *query.Predicate, transaction *tx.Transaction) (*parse.QueryData, error) {
+	viewData, err := parse.NewParser(viewDefinition).Query()
+	if err != nil {
+		return nil, err
+	}
+	if err := qp.checkViewCycle([]string{viewName}, viewData, transaction); err != nil {
+		return nil, err
+	}
+	if !pushesIntoView(viewData) || len(predicate.Conjuncts()) == 0 {
+		return viewData, nil
+	}
+
+	fields := viewData.Fields()
+	if slices.ContainsFunc(fields, isWildcard) {
+		// The fields of the tables a wildcard stands for are only known once the view is planned,
+		// which changes its data, so it is parsed again.
+		if _, err := qp.CreatePlan(viewData, transaction); err != nil {
+			return nil, err
+		}
+		fields = viewData.Fields()
+		if viewData, err = parse.NewParser(viewDefinition).Query(); err != nil {
+			return nil, err
+		}
+	}
+	if pushed := viewPushdown(viewData, fields, predicate); pushed != nil {
+		viewData.Pred().ConjoinWith(pushed)
+	}
+	return viewData, nil
+}
+
+// pushesIntoView returns true if the conjuncts of a predicate can be applied to the records of the tables
+// read by the specified view rather than to the records it outputs: the view neither groups, nor unites
+// queries, nor limits the records it outputs, each of which changes which records a predicate selects.
+func pushesIntoView(viewData *parse.QueryData) bool {
+	return len(viewData.GroupBy()) == 0 && len(viewData.Aggregates()) == 0 && len(viewData.Unions()) == 0 &&
+		viewData.Limit() == parse.NoLimit && viewData.Offset() == 0 && viewData.Values() == nil
+}
+
+// viewPushdown returns the conjunction of the conjuncts of the predicate that only read fields of the
+// select list of the view that it outputs under their own name, rather than aliases or arithmetic expressions,
+// or nil if there are none.
+func viewPushdown(viewData *parse.QueryData, fields []string, predicate *query.Predicate) *query.Predicate {
+	outputsUnchanged := func(field string) bool {
+		_, computed := viewData.Computed()[field]
+		return slices.Contains(fields, field) && viewData.OutputName(field) == field && !computed
+	}
+	var pushed []*query.Predicate
+	for _, conjunct := range predicate.Conjuncts() {
+		if !slices.ContainsFunc(conjunct.Fields(), func(field string) bool { return !outputsUnchanged(field) }) {
+			pushed = append(pushed, conjunct)
+		}
+	}
+	return conjunctionOf(pushed)
+}
+
+// isWildcard returns true if the field of a select list is a wildcard, such as * or t.*.
+func isWildcard(field string) bool {
+	_, ok := parse.WildcardTable(field)
+	return ok || field == parse.Wildcard
+}
+
+// checkViewCycle returns an error if the query of the last view of the path, or one of its unions or subqueries,
+// reads one of the views of the path, directly or through the views it reads.
+func (qp *BasicQueryPlanner) checkViewCycle(path []string, queryData *parse.QueryData, transaction *tx.Transaction) error {
+	queries := []*parse.QueryData{queryData}
+	for _, union := range queryData.Unions() {
+		queries = append(queries, union.Query())
+	}
+	for _, q := range queries {
+		for _, semiJoin := range q.SemiJoins() {
+			if err := qp.checkViewCycle(path, semiJoin.Subquery(), transaction); err != nil {
+				return err
+			}
+		}
+		for _, tableName := range q.Tables() {
+			viewDefinition, err := qp.metadataManager.GetViewDefinition(tableName, transaction)
+			if err != nil {
+				return err
+			}
+			if viewDefinition == "" {
+				continue
+			}
+			if slices.Contains(path, tableName) {
+				return fmt.Errorf("view %s is defined in terms of itself: %s -> %s", tableName, strings.Join(path, " -> "), tableName)
+			}
+			viewData, err := parse.NewParser(viewDefinition).Query()
+			if err != nil {
+				return err
+			}
+			if err := qp.checkViewCycle(append(slices.Clone(path), tableName), viewData, transaction); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
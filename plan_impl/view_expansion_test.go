@@ -0,0 +1,130 @@
+package plan_impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/tx"
+)
+
+func TestBasicQueryPlanner_ViewExpansion(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	// Item i is in category c(i%20) and has score i%50.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE TABLE labels (lscore INT, label VARCHAR(10))",
+		"INSERT INTO labels (lscore, label) VALUES (3, 'three'), (4, 'four')",
+		"CREATE VIEW low AS SELECT id, category, score FROM items WHERE score < 10",
+		"CREATE VIEW low_c3 AS SELECT * FROM low WHERE category = 'c3'",
+		"CREATE VIEW renamed AS SELECT id AS num, score + 1 AS next, category FROM items",
+		"CREATE VIEW top AS SELECT id, score FROM items ORDER BY score DESC LIMIT 2",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	require.NoError(t, txn.Commit())
+
+	ids := func(sql string) []any {
+		var ids []any
+		for _, row := range runPlannerQuery(t, p, sql, fm, lm, bm, lt, []string{"id"}) {
+			ids = append(ids, row["id"])
+		}
+		return ids
+	}
+	chosenIndexes := func(sql string) []string {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		accessPaths, err := p.IndexCandidates(sql, txn)
+		require.NoError(t, err, sql)
+		var chosen []string
+		for _, accessPath := range accessPaths {
+			if accessPath.Chosen != nil {
+				chosen = append(chosen, accessPath.Chosen.IndexName)
+			} else {
+				chosen = append(chosen, "")
+			}
+		}
+		return chosen
+	}
+
+	// The conjuncts of the query that only read fields of the view choose the index of the table it reads.
+	sql := "SELECT id FROM low WHERE category = 'c4'"
+	assert.ElementsMatch(t, []any{4, 104}, ids(sql))
+	assert.Equal(t, []string{"idx_category"}, chosenIndexes(sql))
+	sql = "SELECT id FROM low WHERE score = 3"
+	assert.ElementsMatch(t, []any{3, 53, 103, 153}, ids(sql))
+	assert.Equal(t, []string{"idx_score"}, chosenIndexes(sql))
+	assert.Equal(t, []string{""}, chosenIndexes("SELECT id FROM low"))
+
+	// The conjuncts are pushed through views over views, whose wildcards stand for the fields of the inner view.
+	sql = "SELECT id FROM low_c3 WHERE score = 3"
+	assert.ElementsMatch(t, []any{3, 103}, ids(sql))
+	assert.Equal(t, []string{"idx_score"}, chosenIndexes(sql))
+
+	// A view is joined with tables in the same FROM clause, and only the conjuncts of its own fields are pushed.
+	rows := runPlannerQuery(t, p, "SELECT id, label FROM low, labels WHERE score = lscore AND category = 'c3'",
+		fm, lm, bm, lt, []string{"id", "label"})
+	assert.ElementsMatch(t, []map[string]any{{"id": 3, "label": "three"}, {"id": 103, "label": "three"}}, rows)
+	assert.Equal(t, []string{"idx_category", ""}, chosenIndexes("SELECT id, label FROM low, labels WHERE score = lscore AND category = 'c3'"))
+
+	// Aliased and computed fields are not fields of the tables the view reads, so their conjuncts are applied to its output.
+	rows = runPlannerQuery(t, p, "SELECT num, next FROM renamed WHERE num = 7 AND next > 0", fm, lm, bm, lt, []string{"num", "next"})
+	assert.Equal(t, []map[string]any{{"num": 7, "next": 8}}, rows)
+	sql = "SELECT num FROM renamed WHERE category = 'c7' AND next = 8"
+	assert.ElementsMatch(t, []any{7, 107}, func() []any {
+		var nums []any
+		for _, row := range runPlannerQuery(t, p, sql, fm, lm, bm, lt, []string{"num"}) {
+			nums = append(nums, row["num"])
+		}
+		return nums
+	}())
+	assert.Equal(t, []string{"idx_category"}, chosenIndexes(sql))
+
+	// A view limiting its records selects them before the predicate of the query reading it.
+	sql = "SELECT id FROM top WHERE score = 49"
+	assert.ElementsMatch(t, []any{49, 99}, ids(sql))
+	assert.Empty(t, ids("SELECT id FROM top WHERE score = 3"))
+	assert.Equal(t, []string{""}, chosenIndexes(sql))
+}
+
+func TestBasicQueryPlanner_CyclicViews(t *testing.T) {
+	p, fm, lm, bm, lt := setupIndexedPlannerTest(t)
+
+	// Views are not checked when they are created, so that they can be defined before the views they read.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, statement := range []string{
+		"CREATE VIEW loop AS SELECT id FROM loop",
+		"CREATE VIEW ping AS SELECT id FROM pong WHERE id > 1",
+		"CREATE VIEW pong AS SELECT id FROM items WHERE id IN (SELECT id FROM ping)",
+		"CREATE VIEW outer_view AS SELECT id FROM ping",
+		"CREATE VIEW twice AS SELECT id FROM items WHERE id IN (SELECT id FROM items) UNION SELECT id FROM items",
+	} {
+		_, err := p.ExecuteUpdate(statement, txn)
+		require.NoError(t, err, statement)
+	}
+	require.NoError(t, txn.Commit())
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+	for sql, expected := range map[string]string{
+		"SELECT id FROM loop":              "view loop is defined in terms of itself: loop -> loop",
+		"SELECT id FROM ping WHERE id = 2": "view ping is defined in terms of itself: ping -> pong -> ping",
+		"SELECT id FROM outer_view":        "view ping is defined in terms of itself: outer_view -> ping -> pong -> ping",
+	} {
+		_, err := p.CreateQueryPlan(sql, txn)
+		assert.EqualError(t, err, expected, sql)
+		_, err = p.IndexCandidates(sql, txn)
+		assert.EqualError(t, err, expected, sql)
+	}
+
+	// The views read by a subquery are expanded when the subquery is planned.
+	_, err := p.CreateQueryPlan("SELECT id FROM items WHERE id IN (SELECT id FROM loop)", txn)
+	assert.EqualError(t, err, "view loop is defined in terms of itself: loop -> loop")
+
+	// A view reading the same table twice is not cyclic.
+	_, err = p.CreateQueryPlan("SELECT id FROM twice", txn)
+	assert.NoError(t, err)
+}
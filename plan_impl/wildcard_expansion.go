@@ -0,0 +1,74 @@
+package plan_impl
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/JyotinderSingh/dropdb/parse"
+	"github.com/JyotinderSingh/dropdb/plan"
+)
+
+// expandWildcards replaces the wildcard "*" of the select list of the query with the fields of all its tables,
+// in the order of its FROM clause, and each qualified wildcard "t.*" with the fields of the table or view t.
+// The fields of a table are in the order of its schema, which for a table is the order of its fields in the
+// catalog. The plans are those of the tables of the query, in the order of its FROM clause.
+// A field listed explicitly, or by an earlier wildcard, is not listed again.
+//
+// Output fields are named after the fields they read, so a field that two tables of the query have would be
+// ambiguous: a wildcard expanding to such a field is rejected, unless the query is qualified
+// (see parse.QueryData#Qualified), in which case it expands to the field qualified by its table, such as e.salary.
+// A wildcard naming a table the query does not read, by its alias or else by its name, is rejected too.
+func expandWildcards(queryData *parse.QueryData, plans []plan.Plan) error {
+	var explicit []string
+	hasWildcard := false
+	for _, field := range queryData.Fields() {
+		if _, ok := parse.WildcardTable(field); ok || field == parse.Wildcard {
+			hasWildcard = true
+		} else {
+			explicit = append(explicit, field)
+		}
+	}
+	if !hasWildcard {
+		return nil
+	}
+
+	var fields []string
+	for _, field := range queryData.Fields() {
+		var tableIndexes []int
+		if field == parse.Wildcard {
+			for i := range plans {
+				tableIndexes = append(tableIndexes, i)
+			}
+		} else if tableName, ok := parse.WildcardTable(field); ok {
+			tableIndex := slices.Index(queryData.Qualifiers(), tableName)
+			if tableIndex < 0 {
+				return fmt.Errorf("%s: table %s is not in the FROM clause", field, tableName)
+			}
+			tableIndexes = append(tableIndexes, tableIndex)
+		} else {
+			fields = append(fields, field)
+			continue
+		}
+
+		for _, tableIndex := range tableIndexes {
+			for _, tableField := range plans[tableIndex].Schema().Fields() {
+				for i, other := range plans {
+					if i == tableIndex || !other.Schema().HasField(tableField) {
+						continue
+					}
+					if !queryData.Qualified() {
+						return fmt.Errorf("%s: field %s is ambiguous, since tables %s and %s both have it",
+							field, tableField, queryData.Tables()[tableIndex], queryData.Tables()[i])
+					}
+					tableField = parse.QualifiedField(queryData.Qualifiers()[tableIndex], tableField)
+					break
+				}
+				if !slices.Contains(explicit, tableField) && !slices.Contains(fields, tableField) {
+					fields = append(fields, tableField)
+				}
+			}
+		}
+	}
+	queryData.SetFields(fields)
+	return nil
+}
@@ -0,0 +1,70 @@
+package plan_impl
+
+import (
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanner_QualifiedWildcard(t *testing.T) {
+	p, _, fm, lm, bm, lt := setupPlannerTest(t, 800, 8)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	for _, sql := range []string{
+		"create table emp (name varchar(10), salary int, emp_id int, dept int)",
+		"create table dept (dept_id int, dept_name varchar(10))",
+		"create table badge (emp_id int, badge_no int)",
+		"insert into emp (name, salary, emp_id, dept) values ('alice', 100, 1, 10)",
+		"insert into emp (name, salary, emp_id, dept) values ('bob', 80, 2, 20)",
+		"insert into dept (dept_id, dept_name) values (10, 'sales')",
+		"insert into dept (dept_id, dept_name) values (20, 'ops')",
+		"insert into badge (emp_id, badge_no) values (1, 7)",
+	} {
+		_, err := p.ExecuteUpdate(sql, txn)
+		require.NoError(t, err, sql)
+	}
+	require.NoError(t, txn.Commit())
+
+	outputFields := func(sql string) []string {
+		txn := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, txn.Commit()) }()
+		queryPlan, err := p.CreateQueryPlan(sql, txn)
+		require.NoError(t, err, sql)
+		return queryPlan.Schema().Fields()
+	}
+
+	// The wildcard expands to the fields of the table in catalog order, where it appears in the select list.
+	sql := "select emp.*, dept_name from emp, dept where dept = dept_id"
+	assert.Equal(t, []string{"name", "salary", "emp_id", "dept", "dept_name"}, outputFields(sql))
+	rows := runPlannerQuery(t, p, sql, fm, lm, bm, lt, []string{"name", "salary", "emp_id", "dept", "dept_name"})
+	require.Len(t, rows, 2)
+	for _, row := range rows {
+		switch row["name"] {
+		case "alice":
+			assert.Equal(t, map[string]any{"name": "alice", "salary": 100, "emp_id": 1, "dept": 10, "dept_name": "sales"}, row)
+		case "bob":
+			assert.Equal(t, map[string]any{"name": "bob", "salary": 80, "emp_id": 2, "dept": 20, "dept_name": "ops"}, row)
+		default:
+			t.Errorf("unexpected row %v", row)
+		}
+	}
+
+	// Fields listed explicitly, or by an earlier wildcard, are not repeated.
+	assert.Equal(t, []string{"dept_name", "name", "salary", "emp_id", "dept", "dept_id"},
+		outputFields("select dept_name, emp.*, dept.*, emp.* from emp, dept where dept = dept_id"))
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, txn.Commit()) }()
+
+	// A wildcard on a table that shares a field with another table of the query is ambiguous.
+	_, err := p.CreateQueryPlan("select emp.*, badge_no from emp, badge", txn)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "field emp_id is ambiguous")
+
+	// The table must be in the FROM clause.
+	_, err = p.CreateQueryPlan("select e.* from emp", txn)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "table e is not in the FROM clause")
+}
@@ -0,0 +1,121 @@
+package query
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*ChunkScan)(nil)
+
+// ChunkScan reads the records of a chunk of consecutive blocks of a table, all of which it keeps pinned until
+// it is closed, so that the records of the chunk are read again without reading its blocks from disk, as a
+// multibuffer product does for each record of its other input (see MultibufferProductScan).
+type ChunkScan struct {
+	transaction *tx.Transaction
+	blocks      []*file.BlockId
+	tableScan   *table.Scan
+	closed      bool
+}
+
+// NewChunkScan creates a scan of the records of the blocks of the specified table from firstBlock up to but
+// excluding endBlock, which are in its file, pinning each of them.
+func NewChunkScan(transaction *tx.Transaction, tableName string, layout *record.Layout, firstBlock, endBlock int) (*ChunkScan, error) {
+	cs := &ChunkScan{transaction: transaction}
+	for number := firstBlock; number < endBlock; number++ {
+		block := table.BlockID(tableName, number)
+		if err := transaction.Pin(block); err != nil {
+			return nil, errors.Join(err, cs.Close())
+		}
+		cs.blocks = append(cs.blocks, block)
+	}
+	tableScan, err := table.NewBlockRangeScan(transaction, tableName, layout, firstBlock, endBlock)
+	if err != nil {
+		return nil, errors.Join(err, cs.Close())
+	}
+	cs.tableScan = tableScan
+	return cs, nil
+}
+
+// BeforeFirst positions the scan before the first record of the chunk.
+func (cs *ChunkScan) BeforeFirst() error {
+	return cs.tableScan.BeforeFirst()
+}
+
+// Next moves to the next record of the chunk, returning false after its last one.
+func (cs *ChunkScan) Next() (bool, error) {
+	return cs.tableScan.Next()
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (cs *ChunkScan) GetInt(fieldName string) (int, error) {
+	return cs.tableScan.GetInt(fieldName)
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (cs *ChunkScan) GetLong(fieldName string) (int64, error) {
+	return cs.tableScan.GetLong(fieldName)
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (cs *ChunkScan) GetShort(fieldName string) (int16, error) {
+	return cs.tableScan.GetShort(fieldName)
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (cs *ChunkScan) GetFloat(fieldName string) (float64, error) {
+	return cs.tableScan.GetFloat(fieldName)
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (cs *ChunkScan) GetString(fieldName string) (string, error) {
+	return cs.tableScan.GetString(fieldName)
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (cs *ChunkScan) GetBool(fieldName string) (bool, error) {
+	return cs.tableScan.GetBool(fieldName)
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (cs *ChunkScan) GetDate(fieldName string) (time.Time, error) {
+	return cs.tableScan.GetDate(fieldName)
+}
+
+// GetVal returns the value of the specified field in the current record, or nil if the field is null.
+func (cs *ChunkScan) GetVal(fieldName string) (any, error) {
+	return cs.tableScan.GetVal(fieldName)
+}
+
+// HasField returns true if the specified field is a field of the table.
+func (cs *ChunkScan) HasField(fieldName string) bool {
+	return cs.tableScan.HasField(fieldName)
+}
+
+// Fields returns the fields of the table, in the order of its layout's schema.
+func (cs *ChunkScan) Fields() []types.FieldInfo {
+	return cs.tableScan.Fields()
+}
+
+// Close closes the scan, and unpins the blocks of the chunk.
+// Closing the scan again has no effect.
+func (cs *ChunkScan) Close() error {
+	if cs.closed {
+		return nil
+	}
+	cs.closed = true
+	var err error
+	if cs.tableScan != nil {
+		err = cs.tableScan.Close()
+	}
+	for _, block := range cs.blocks {
+		cs.transaction.Unpin(block)
+	}
+	return err
+}
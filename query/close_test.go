@@ -0,0 +1,101 @@
+package query_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// countingScan is an empty scan that counts how many times it is closed.
+type countingScan struct {
+	closeCount int
+	closeErr   error
+}
+
+var _ scan.Scan = (*countingScan)(nil)
+
+func (cs *countingScan) BeforeFirst() error                   { return nil }
+func (cs *countingScan) Next() (bool, error)                  { return false, nil }
+func (cs *countingScan) GetInt(string) (int, error)           { return 0, nil }
+func (cs *countingScan) GetLong(string) (int64, error)        { return 0, nil }
+func (cs *countingScan) GetShort(string) (int16, error)       { return 0, nil }
+func (cs *countingScan) GetFloat(string) (float64, error)     { return 0, nil }
+func (cs *countingScan) GetString(string) (string, error)     { return "", nil }
+func (cs *countingScan) GetBool(string) (bool, error)         { return false, nil }
+func (cs *countingScan) GetDate(string) (time.Time, error)    { return time.Time{}, nil }
+func (cs *countingScan) HasField(fieldName string) bool       { return true }
+func (cs *countingScan) GetVal(fieldName string) (any, error) { return nil, nil }
+func (cs *countingScan) Fields() []types.FieldInfo            { return nil }
+
+func (cs *countingScan) Close() error {
+	cs.closeCount++
+	return cs.closeErr
+}
+
+func TestScans_CloseIsIdempotent(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap func(children ...scan.Scan) (scan.Scan, error)
+		// number of children the scan wraps
+		children int
+	}{
+		{"select", func(c ...scan.Scan) (scan.Scan, error) { return query.NewSelectScan(c[0], query.NewPredicate()) }, 1},
+		{"project", func(c ...scan.Scan) (scan.Scan, error) { return query.NewProjectScan(c[0], []string{"a"}) }, 1},
+		{"product", func(c ...scan.Scan) (scan.Scan, error) { return query.NewProductScan(c[0], c[1]), nil }, 2},
+		{"group by", func(c ...scan.Scan) (scan.Scan, error) {
+			return query.NewGroupByScan(c[0], []string{"a"}, []functions.AggregationFunction{functions.NewCountFunction("a")})
+		}, 1},
+		{"hash aggregation", func(c ...scan.Scan) (scan.Scan, error) {
+			return query.NewHashAggregationScan(nil, c[0], nil, []string{"a"}, []functions.AggregationFunction{functions.NewCountFunction("a")}, 10)
+		}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			children := make([]*countingScan, tt.children)
+			scans := make([]scan.Scan, tt.children)
+			for i := range children {
+				children[i] = &countingScan{}
+				scans[i] = children[i]
+			}
+
+			s, err := tt.wrap(scans...)
+			require.NoError(t, err)
+
+			require.NoError(t, s.Close())
+			require.NoError(t, s.Close())
+			for i, child := range children {
+				assert.Equal(t, 1, child.closeCount, "child %d must be closed exactly once", i)
+			}
+		})
+	}
+}
+
+func TestScans_CloseReportsChildErrors(t *testing.T) {
+	errFirst := errors.New("first child failed to close")
+	errSecond := errors.New("second child failed to close")
+	first := &countingScan{closeErr: errFirst}
+	second := &countingScan{closeErr: errSecond}
+
+	// Both children are closed even if the first one fails.
+	err := query.NewProductScan(first, second).Close()
+	assert.ErrorIs(t, err, errFirst)
+	assert.ErrorIs(t, err, errSecond)
+	assert.Equal(t, 1, second.closeCount)
+
+	child := &countingScan{closeErr: fmt.Errorf("wrapped: %w", errFirst)}
+	s, err := query.NewSelectScan(child, query.NewPredicate())
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Close(), errFirst)
+	assert.NoError(t, s.Close(), "closing a closed scan has no effect")
+	assert.Equal(t, 1, child.closeCount)
+}
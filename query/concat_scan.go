@@ -0,0 +1,149 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*ConcatScan)(nil)
+
+// ConcatScan is the scan for UNION ALL, which outputs the records of its first scan, followed by those of its
+// second scan. The fields of the records are named after those of the first scan, the fields of the second scan
+// matching them by position, and their values are converted to the types of the fields (see types.CoerceValue).
+type ConcatScan struct {
+	scan1 scan.Scan
+	scan2 scan.Scan
+	// fields are the fields of the output records.
+	fields []types.FieldInfo
+	// fields2 are the names of the fields of the second scan, in the order of fields.
+	fields2 []string
+	// second is true once the records of the second scan are read.
+	second bool
+	closed bool
+}
+
+// NewConcatScan creates a scan outputting the records of the first scan, and then those of the second scan,
+// whose specified fields are output as the fields in the same position.
+func NewConcatScan(scan1, scan2 scan.Scan, fields []types.FieldInfo, fields2 []string) (*ConcatScan, error) {
+	if len(fields) != len(fields2) {
+		return nil, fmt.Errorf("the second scan has %d fields instead of %d", len(fields2), len(fields))
+	}
+	cs := &ConcatScan{scan1: scan1, scan2: scan2, fields: fields, fields2: fields2}
+	if err := cs.BeforeFirst(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// BeforeFirst positions the scan before the first record of the first scan.
+func (cs *ConcatScan) BeforeFirst() error {
+	cs.second = false
+	return errors.Join(cs.scan1.BeforeFirst(), cs.scan2.BeforeFirst())
+}
+
+// Next moves to the next record of the first scan, or once they are read, to the next record of the second scan.
+func (cs *ConcatScan) Next() (bool, error) {
+	if !cs.second {
+		hasNext, err := cs.scan1.Next()
+		if err != nil || hasNext {
+			return hasNext, err
+		}
+		cs.second = true
+	}
+	return cs.scan2.Next()
+}
+
+// GetVal returns the value of the specified field in the current record, converted to the type of the field.
+func (cs *ConcatScan) GetVal(fieldName string) (any, error) {
+	i := slices.IndexFunc(cs.fields, func(field types.FieldInfo) bool { return field.Name == fieldName })
+	if i < 0 {
+		return nil, fmt.Errorf("field %s not found", fieldName)
+	}
+	var val any
+	var err error
+	if cs.second {
+		val, err = cs.scan2.GetVal(cs.fields2[i])
+	} else {
+		val, err = cs.scan1.GetVal(fieldName)
+	}
+	if err != nil || val == nil {
+		return nil, err
+	}
+	return types.CoerceValue(val, cs.fields[i].Type)
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (cs *ConcatScan) GetInt(fieldName string) (int, error) {
+	return getConcatenated[int](cs, fieldName, "an int")
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (cs *ConcatScan) GetLong(fieldName string) (int64, error) {
+	return getConcatenated[int64](cs, fieldName, "a long")
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (cs *ConcatScan) GetShort(fieldName string) (int16, error) {
+	return getConcatenated[int16](cs, fieldName, "a short")
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (cs *ConcatScan) GetFloat(fieldName string) (float64, error) {
+	return getConcatenated[float64](cs, fieldName, "a float")
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (cs *ConcatScan) GetString(fieldName string) (string, error) {
+	return getConcatenated[string](cs, fieldName, "a string")
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (cs *ConcatScan) GetBool(fieldName string) (bool, error) {
+	return getConcatenated[bool](cs, fieldName, "a bool")
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (cs *ConcatScan) GetDate(fieldName string) (time.Time, error) {
+	return getConcatenated[time.Time](cs, fieldName, "a date")
+}
+
+// getConcatenated returns the value of the specified field in the current record of the scan, which must be of
+// type T, or the zero value of T if the field is null.
+func getConcatenated[T any](cs *ConcatScan, fieldName, typeName string) (T, error) {
+	var zero T
+	val, err := cs.GetVal(fieldName)
+	if err != nil || val == nil {
+		return zero, err
+	}
+	typed, ok := val.(T)
+	if !ok {
+		return zero, fmt.Errorf("field %s is not %s", fieldName, typeName)
+	}
+	return typed, nil
+}
+
+// HasField returns true if the field is one of the output fields.
+func (cs *ConcatScan) HasField(fieldName string) bool {
+	_, ok := types.FindField(cs.fields, fieldName)
+	return ok
+}
+
+// Fields returns the output fields.
+func (cs *ConcatScan) Fields() []types.FieldInfo {
+	return cs.fields
+}
+
+// Close closes both scans.
+// Closing the scan again has no effect.
+func (cs *ConcatScan) Close() error {
+	if cs.closed {
+		return nil
+	}
+	cs.closed = true
+	return errors.Join(cs.scan1.Close(), cs.scan2.Close())
+}
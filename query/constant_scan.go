@@ -0,0 +1,185 @@
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// Ensure ConstantScan implements the Scan interface.
+var _ scan.Scan = (*ConstantScan)(nil)
+
+// ConstantSchema returns the schema of rows of constants, such as the rows of a VALUES list, whose values are
+// read by the specified fields in order. The values of a field must all have the same type, except that a field
+// of integers and floats is a float field, whose integers are converted to floats in the rows, and that any value
+// may be null (nil); a field whose values are all null is an integer field. A varchar field is as long as its
+// longest value, if it is not empty. It returns an error naming the rows whose values of a field differ in type.
+func ConstantSchema(fieldNames []string, rows [][]any) (*record.Schema, error) {
+	schema := record.NewSchema()
+	for i, fieldName := range fieldNames {
+		promoteToFloats(rows, i)
+		first := 0
+		for first < len(rows)-1 && rows[first][i] == nil {
+			first++
+		}
+		fieldType := types.Integer
+		if rows[first][i] != nil {
+			var ok bool
+			if fieldType, ok = types.TypeOf(rows[first][i]); !ok {
+				return nil, fmt.Errorf("value %v of %s in row %d has unsupported type %T", rows[first][i], fieldName, first+1, rows[first][i])
+			}
+		}
+		length := 1
+		for j, row := range rows {
+			if row[i] != nil && !types.IsValueOfType(row[i], fieldType) {
+				otherType, ok := types.TypeOf(row[i])
+				if !ok {
+					return nil, fmt.Errorf("value %v of %s in row %d has unsupported type %T", row[i], fieldName, j+1, row[i])
+				}
+				return nil, fmt.Errorf("values of %s must all have the same type, but row %d has type %s and row %d has type %s",
+					fieldName, first+1, fieldType, j+1, otherType)
+			}
+			if s, ok := row[i].(string); ok {
+				length = max(length, len(s))
+			}
+		}
+		schema.AddField(fieldName, fieldType, length)
+	}
+	return schema, nil
+}
+
+// promoteToFloats converts the integers of the specified field of the rows to floats,
+// if the field holds both integers and floats and nothing else but nulls.
+func promoteToFloats(rows [][]any, field int) {
+	hasFloat := false
+	for _, row := range rows {
+		switch row[field].(type) {
+		case float64:
+			hasFloat = true
+		case int, nil:
+		default:
+			return
+		}
+	}
+	if !hasFloat {
+		return
+	}
+	for _, row := range rows {
+		if v, ok := row[field].(int); ok {
+			row[field] = float64(v)
+		}
+	}
+}
+
+// ConstantScan is a scan over rows of constants held in memory, such as the rows of a VALUES list
+// or the single row of a select list of constants, whose fields are described by ConstantSchema.
+type ConstantScan struct {
+	schema    *record.Schema
+	positions map[string]int
+	rows      [][]any
+	current   int
+}
+
+// NewConstantScan creates a scan over the specified rows, whose values are those of the fields of the schema, in order.
+func NewConstantScan(schema *record.Schema, rows [][]any) *ConstantScan {
+	positions := make(map[string]int, len(schema.Fields()))
+	for i, fieldName := range schema.Fields() {
+		positions[fieldName] = i
+	}
+	return &ConstantScan{schema: schema, positions: positions, rows: rows, current: -1}
+}
+
+// BeforeFirst positions the scan before the first row.
+func (cs *ConstantScan) BeforeFirst() error {
+	cs.current = -1
+	return nil
+}
+
+// Next moves to the next row.
+func (cs *ConstantScan) Next() (bool, error) {
+	if cs.current+1 >= len(cs.rows) {
+		return false, nil
+	}
+	cs.current++
+	return true, nil
+}
+
+// GetVal returns the value of the specified field in the current row.
+func (cs *ConstantScan) GetVal(fieldName string) (any, error) {
+	if cs.current < 0 || cs.current >= len(cs.rows) {
+		return nil, fmt.Errorf("no current row")
+	}
+	position, ok := cs.positions[fieldName]
+	if !ok {
+		return nil, fmt.Errorf("field %s not found", fieldName)
+	}
+	return cs.rows[cs.current][position], nil
+}
+
+// GetInt returns the value of the specified integer field in the current row.
+func (cs *ConstantScan) GetInt(fieldName string) (int, error) {
+	return getConstant[int](cs, fieldName, "an int")
+}
+
+// GetLong returns the value of the specified long field in the current row.
+func (cs *ConstantScan) GetLong(fieldName string) (int64, error) {
+	return getConstant[int64](cs, fieldName, "a long")
+}
+
+// GetShort returns the value of the specified short field in the current row.
+func (cs *ConstantScan) GetShort(fieldName string) (int16, error) {
+	return getConstant[int16](cs, fieldName, "a short")
+}
+
+// GetFloat returns the value of the specified float field in the current row.
+func (cs *ConstantScan) GetFloat(fieldName string) (float64, error) {
+	return getConstant[float64](cs, fieldName, "a float")
+}
+
+// GetString returns the value of the specified string field in the current row.
+func (cs *ConstantScan) GetString(fieldName string) (string, error) {
+	return getConstant[string](cs, fieldName, "a string")
+}
+
+// GetBool returns the value of the specified boolean field in the current row.
+func (cs *ConstantScan) GetBool(fieldName string) (bool, error) {
+	return getConstant[bool](cs, fieldName, "a bool")
+}
+
+// GetDate returns the value of the specified date field in the current row.
+func (cs *ConstantScan) GetDate(fieldName string) (time.Time, error) {
+	return getConstant[time.Time](cs, fieldName, "a date")
+}
+
+// getConstant returns the value of the specified field in the current row of the scan, which must be of type T,
+// or the zero value of T if the field is null.
+func getConstant[T any](cs *ConstantScan, fieldName, typeName string) (T, error) {
+	var zero T
+	val, err := cs.GetVal(fieldName)
+	if err != nil || val == nil {
+		return zero, err
+	}
+	typed, ok := val.(T)
+	if !ok {
+		return zero, fmt.Errorf("field %s is not %s", fieldName, typeName)
+	}
+	return typed, nil
+}
+
+// HasField returns true if the schema of the rows has the specified field.
+func (cs *ConstantScan) HasField(fieldName string) bool {
+	return cs.schema.HasField(fieldName)
+}
+
+// Fields returns the fields of the rows, which are described by their schema.
+func (cs *ConstantScan) Fields() []types.FieldInfo {
+	return cs.schema.FieldInfos()
+}
+
+// Close does nothing, since the rows are held in memory.
+func (cs *ConstantScan) Close() error {
+	return nil
+}
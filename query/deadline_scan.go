@@ -0,0 +1,109 @@
+package query
+
+import (
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*DeadlineScan)(nil)
+
+// DeadlineScan reads the records of its input scan, failing with tx.ErrQueryTimeout
+// once the deadline of the transaction has passed. It times each call to Next, so that
+// reading the results of a statement is covered by the deadline of the statement.
+type DeadlineScan struct {
+	inputScan   scan.Scan
+	transaction *tx.Transaction
+	closed      bool
+}
+
+// NewDeadlineScan creates a scan that checks the deadline of the specified transaction
+// before each move of the input scan.
+func NewDeadlineScan(inputScan scan.Scan, transaction *tx.Transaction) *DeadlineScan {
+	return &DeadlineScan{inputScan: inputScan, transaction: transaction}
+}
+
+// BeforeFirst positions the input scan before its first record, unless the deadline has passed.
+func (ds *DeadlineScan) BeforeFirst() error {
+	if err := ds.transaction.CheckDeadline(); err != nil {
+		return err
+	}
+	return ds.inputScan.BeforeFirst()
+}
+
+// Next moves the input scan to its next record, unless the deadline has passed
+// before or while it moves.
+func (ds *DeadlineScan) Next() (bool, error) {
+	if err := ds.transaction.CheckDeadline(); err != nil {
+		return false, err
+	}
+	hasNext, err := ds.inputScan.Next()
+	if err != nil {
+		return false, err
+	}
+	if err := ds.transaction.CheckDeadline(); err != nil {
+		return false, err
+	}
+	return hasNext, nil
+}
+
+// Close closes the input scan. Closing the scan again has no effect.
+func (ds *DeadlineScan) Close() error {
+	if ds.closed {
+		return nil
+	}
+	ds.closed = true
+	return ds.inputScan.Close()
+}
+
+// HasField returns true if the input scan has the specified field.
+func (ds *DeadlineScan) HasField(fieldName string) bool {
+	return ds.inputScan.HasField(fieldName)
+}
+
+// Fields returns the fields of the input scan.
+func (ds *DeadlineScan) Fields() []types.FieldInfo {
+	return ds.inputScan.Fields()
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (ds *DeadlineScan) GetInt(fieldName string) (int, error) {
+	return ds.inputScan.GetInt(fieldName)
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (ds *DeadlineScan) GetLong(fieldName string) (int64, error) {
+	return ds.inputScan.GetLong(fieldName)
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (ds *DeadlineScan) GetShort(fieldName string) (int16, error) {
+	return ds.inputScan.GetShort(fieldName)
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (ds *DeadlineScan) GetFloat(fieldName string) (float64, error) {
+	return ds.inputScan.GetFloat(fieldName)
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (ds *DeadlineScan) GetString(fieldName string) (string, error) {
+	return ds.inputScan.GetString(fieldName)
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (ds *DeadlineScan) GetBool(fieldName string) (bool, error) {
+	return ds.inputScan.GetBool(fieldName)
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (ds *DeadlineScan) GetDate(fieldName string) (time.Time, error) {
+	return ds.inputScan.GetDate(fieldName)
+}
+
+// GetVal returns the value of the specified field in the current record.
+func (ds *DeadlineScan) GetVal(fieldName string) (any, error) {
+	return ds.inputScan.GetVal(fieldName)
+}
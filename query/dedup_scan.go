@@ -0,0 +1,136 @@
+package query
+
+import (
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*DedupScan)(nil)
+
+// DedupScan is the scan for UNION, which outputs the records of its input scan, skipping the records equal to the
+// previous one on every field. Its input must be sorted on all of its fields, so that duplicate records are adjacent.
+// Null values are equal to each other.
+type DedupScan struct {
+	inputScan scan.Scan
+	// previous holds the values of the last record output, or is nil before the first record.
+	previous []any
+	closed   bool
+}
+
+// NewDedupScan creates a scan outputting the distinct records of the specified scan, which is sorted on all of its fields.
+func NewDedupScan(inputScan scan.Scan) *DedupScan {
+	return &DedupScan{inputScan: inputScan}
+}
+
+// BeforeFirst positions the scan before its first record.
+func (ds *DedupScan) BeforeFirst() error {
+	ds.previous = nil
+	return ds.inputScan.BeforeFirst()
+}
+
+// Next moves to the next record of the input scan which differs from the last record output.
+func (ds *DedupScan) Next() (bool, error) {
+	for {
+		hasNext, err := ds.inputScan.Next()
+		if err != nil || !hasNext {
+			return false, err
+		}
+		values, err := ds.currentValues()
+		if err != nil {
+			return false, err
+		}
+		if ds.previous == nil || !sameValues(ds.previous, values) {
+			ds.previous = values
+			return true, nil
+		}
+	}
+}
+
+// currentValues returns the values of the current record of the input scan, in the order of its fields.
+func (ds *DedupScan) currentValues() ([]any, error) {
+	fields := ds.inputScan.Fields()
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		val, err := ds.inputScan.GetVal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
+// sameValues returns true if the values are pairwise equal, or both null.
+func sameValues(values1, values2 []any) bool {
+	for i := range values1 {
+		if values1[i] == nil || values2[i] == nil {
+			if values1[i] != values2[i] {
+				return false
+			}
+		} else if !types.CompareSupportedTypes(values1[i], values2[i], types.EQ) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close closes the input scan. Closing the scan again has no effect.
+func (ds *DedupScan) Close() error {
+	if ds.closed {
+		return nil
+	}
+	ds.closed = true
+	return ds.inputScan.Close()
+}
+
+// HasField returns true if the input scan has the specified field.
+func (ds *DedupScan) HasField(fieldName string) bool {
+	return ds.inputScan.HasField(fieldName)
+}
+
+// Fields returns the fields of the input scan.
+func (ds *DedupScan) Fields() []types.FieldInfo {
+	return ds.inputScan.Fields()
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (ds *DedupScan) GetInt(fieldName string) (int, error) {
+	return ds.inputScan.GetInt(fieldName)
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (ds *DedupScan) GetLong(fieldName string) (int64, error) {
+	return ds.inputScan.GetLong(fieldName)
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (ds *DedupScan) GetShort(fieldName string) (int16, error) {
+	return ds.inputScan.GetShort(fieldName)
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (ds *DedupScan) GetFloat(fieldName string) (float64, error) {
+	return ds.inputScan.GetFloat(fieldName)
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (ds *DedupScan) GetString(fieldName string) (string, error) {
+	return ds.inputScan.GetString(fieldName)
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (ds *DedupScan) GetBool(fieldName string) (bool, error) {
+	return ds.inputScan.GetBool(fieldName)
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (ds *DedupScan) GetDate(fieldName string) (time.Time, error) {
+	return ds.inputScan.GetDate(fieldName)
+}
+
+// GetVal returns the value of the specified field in the current record.
+func (ds *DedupScan) GetVal(fieldName string) (any, error) {
+	return ds.inputScan.GetVal(fieldName)
+}
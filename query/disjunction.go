@@ -0,0 +1,112 @@
+package query
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+)
+
+// Disjunction is a predicate of the form "P1 or P2 or ...", which a record satisfies if it satisfies
+// any of its branches. It is conjoined with the terms of a predicate (see NewPredicateFromDisjunction).
+type Disjunction struct {
+	branches []*Predicate
+}
+
+// NewDisjunction creates the disjunction of the specified predicates.
+func NewDisjunction(branches ...*Predicate) *Disjunction {
+	return &Disjunction{branches: branches}
+}
+
+// Branches returns the predicates whose disjunction this is.
+func (d *Disjunction) Branches() []*Predicate {
+	return d.branches
+}
+
+// IsSatisfied returns true if the current record of the specified scan satisfies any of the branches.
+// The branches are evaluated in order, and the first one satisfied ends the evaluation.
+func (d *Disjunction) IsSatisfied(inputScan scan.Scan) (bool, error) {
+	for _, branch := range d.branches {
+		satisfied, err := branch.IsSatisfied(inputScan)
+		if err != nil || satisfied {
+			return satisfied, err
+		}
+	}
+	return false, nil
+}
+
+// Fields returns the names of the fields the branches read, in the order they first appear, without duplicates.
+func (d *Disjunction) Fields() []string {
+	var fields []string
+	for _, branch := range d.branches {
+		for _, field := range branch.Fields() {
+			if !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// AppliesTo returns true if every branch applies to the specified schema.
+func (d *Disjunction) AppliesTo(schema *record.Schema) bool {
+	for _, branch := range d.branches {
+		if !branch.AppliesTo(schema) {
+			return false
+		}
+	}
+	return true
+}
+
+// CoerceConstants converts the constants of the branches compared with fields of the schema to the fields' types.
+func (d *Disjunction) CoerceConstants(schema *record.Schema) {
+	for _, branch := range d.branches {
+		branch.CoerceConstants(schema)
+	}
+}
+
+// CheckTypes returns an error wrapping ErrTypeMismatch if a term of a branch compares values of incompatible types.
+func (d *Disjunction) CheckTypes(schema *record.Schema) error {
+	for _, branch := range d.branches {
+		if err := branch.CheckTypes(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReductionFactor estimates the reduction factor of the disjunction from those of its branches.
+// The fractions of the records the branches select are added up, as if no record satisfied two of them,
+// so a disjunction of two branches that each select a tenth of the records has a reduction factor of 5.
+func (d *Disjunction) ReductionFactor(queryPlan plan.Plan) int {
+	selected := 0.0
+	for _, branch := range d.branches {
+		selected += 1 / float64(branch.ReductionFactor(queryPlan))
+	}
+	if selected >= 1 {
+		return 1
+	}
+	return int(1 / selected)
+}
+
+// String returns the branches joined by "or", in parentheses, so that the disjunction reads back
+// as a single conjunct of a predicate.
+func (d *Disjunction) String() string {
+	text, _ := d.Format(formatValue)
+	return text
+}
+
+// Format returns the text of the disjunction like String, writing its constants with the specified function.
+func (d *Disjunction) Format(constant func(val any) (string, error)) (string, error) {
+	branches := make([]string, len(d.branches))
+	for i, branch := range d.branches {
+		text, err := branch.Format(constant)
+		if err != nil {
+			return "", err
+		}
+		branches[i] = text
+	}
+	return "(" + strings.Join(branches, " or ") + ")", nil
+}
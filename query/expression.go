@@ -2,13 +2,20 @@ package query
 
 import (
 	"fmt"
+
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
+// Expression is a field name, a constant, or an arithmetic operation on two expressions, such as "salary * 12".
 type Expression struct {
 	value     any
 	fieldName string
+	// lhs and rhs are the operands of an arithmetic expression, and op its operator. They are nil otherwise.
+	lhs *Expression
+	rhs *Expression
+	op  types.ArithmeticOperator
 }
 
 // NewFieldExpression creates a new expression for a field name.
@@ -16,14 +23,32 @@ func NewFieldExpression(fieldName string) *Expression {
 	return &Expression{value: nil, fieldName: fieldName}
 }
 
-// NewConstantExpression creates a new expression for a constant value.
+// NewConstantExpression creates a new expression for a constant value, which is null if the value is nil.
 func NewConstantExpression(value any) *Expression {
 	return &Expression{value: value, fieldName: ""}
 }
 
+// NewArithmeticExpression creates a new expression applying the arithmetic operator to the values of two expressions.
+func NewArithmeticExpression(lhs *Expression, op types.ArithmeticOperator, rhs *Expression) *Expression {
+	return &Expression{lhs: lhs, rhs: rhs, op: op}
+}
+
 // Evaluate the expression with respect to the current record of the specified inputScan.
+// An arithmetic expression is null if either of its operands is null, and fails with an error
+// wrapping types.ErrDivisionByZero if it divides by zero (see types.Calculate).
 func (e *Expression) Evaluate(inputScan scan.Scan) (any, error) {
-	if e.value != nil {
+	if e.IsArithmetic() {
+		lhsVal, err := e.lhs.Evaluate(inputScan)
+		if err != nil {
+			return nil, err
+		}
+		rhsVal, err := e.rhs.Evaluate(inputScan)
+		if err != nil || lhsVal == nil || rhsVal == nil {
+			return nil, err
+		}
+		return types.Calculate(lhsVal, rhsVal, e.op)
+	}
+	if !e.IsFieldName() {
 		return e.value, nil
 	}
 	return inputScan.GetVal(e.fieldName)
@@ -34,6 +59,16 @@ func (e *Expression) IsFieldName() bool {
 	return e.fieldName != ""
 }
 
+// IsArithmetic returns true if the expression is an arithmetic operation.
+func (e *Expression) IsArithmetic() bool {
+	return e.lhs != nil
+}
+
+// isConstant returns true if the expression is a constant, which may be null.
+func (e *Expression) isConstant() bool {
+	return !e.IsFieldName() && !e.IsArithmetic()
+}
+
 // IsConstant returns true if the expression is a constant expression,
 // or nil if the expression does not denote a constant.
 func (e *Expression) asConstant() any {
@@ -46,14 +81,138 @@ func (e *Expression) asFieldName() string {
 	return e.fieldName
 }
 
+// Fields returns the names of the fields the expression reads.
+func (e *Expression) Fields() []string {
+	if e.IsArithmetic() {
+		return append(e.lhs.Fields(), e.rhs.Fields()...)
+	}
+	if e.IsFieldName() {
+		return []string{e.fieldName}
+	}
+	return nil
+}
+
+// renameFields returns a copy of the expression reading the renamed fields (see Predicate#RenameFields).
+func (e *Expression) renameFields(names map[string]string) *Expression {
+	if e.IsArithmetic() {
+		return NewArithmeticExpression(e.lhs.renameFields(names), e.op, e.rhs.renameFields(names))
+	}
+	if name, ok := names[e.fieldName]; ok && e.IsFieldName() {
+		return NewFieldExpression(name)
+	}
+	return &Expression{value: e.value, fieldName: e.fieldName}
+}
+
 // AppliesTo determines if all the fields mentioned in this expression are contained in the specified schema.
 func (e *Expression) AppliesTo(schema *record.Schema) bool {
-	return e.value != nil || schema.HasField(e.fieldName)
+	if e.IsArithmetic() {
+		return e.lhs.AppliesTo(schema) && e.rhs.AppliesTo(schema)
+	}
+	return !e.IsFieldName() || schema.HasField(e.fieldName)
 }
 
+// typeIn returns the type of the expression: the type of the field in the specified schema,
+// the type of the constant, or the type of the result of the arithmetic operation. It returns false
+// if the field is not in the schema, the constant is null, or an operand is not a number.
+func (e *Expression) typeIn(schema *record.Schema) (types.SchemaType, bool) {
+	if e.IsArithmetic() {
+		lhsType, lhsOk := e.lhs.typeIn(schema)
+		rhsType, rhsOk := e.rhs.typeIn(schema)
+		if !lhsOk || !rhsOk {
+			return 0, false
+		}
+		return types.ArithmeticResultType(lhsType, rhsType)
+	}
+	if !e.IsFieldName() {
+		return types.TypeOf(e.value)
+	}
+	if !schema.HasField(e.fieldName) {
+		return 0, false
+	}
+	return schema.Type(e.fieldName), true
+}
+
+// checkTypes returns an error wrapping ErrTypeMismatch if an arithmetic operation of the expression has an
+// operand that is not a number. Operands of unknown type, such as fields that are not in the schema, are not checked.
+func (e *Expression) checkTypes(schema *record.Schema) error {
+	if !e.IsArithmetic() {
+		return nil
+	}
+	for _, operand := range []*Expression{e.lhs, e.rhs} {
+		if err := operand.checkTypes(schema); err != nil {
+			return err
+		}
+		if operandType, ok := operand.typeIn(schema); ok {
+			if _, numeric := types.ArithmeticResultType(operandType, operandType); !numeric {
+				return fmt.Errorf("%w: %s of type %s is not a number in %s", ErrTypeMismatch, operand, operandType, e)
+			}
+		}
+	}
+	return nil
+}
+
+// ResultType returns the type of the values of the expression for the records of the specified schema.
+// It returns an error wrapping ErrTypeMismatch if an arithmetic operation has an operand that is not a number,
+// or an error if the type cannot be known, because a field is not in the schema or a constant is null.
+func (e *Expression) ResultType(schema *record.Schema) (types.SchemaType, error) {
+	if err := e.checkTypes(schema); err != nil {
+		return 0, err
+	}
+	resultType, ok := e.typeIn(schema)
+	if !ok {
+		return 0, fmt.Errorf("the type of %s is unknown for fields %v", e, schema.Fields())
+	}
+	return resultType, nil
+}
+
+// coerceTo converts a constant expression to the representation used for fields of the specified type.
+// The expression is left unchanged if it is not a constant or the constant cannot be converted.
+func (e *Expression) coerceTo(fieldType types.SchemaType) {
+	if e.value == nil {
+		return
+	}
+	if val, err := types.CoerceValue(e.value, fieldType); err == nil {
+		e.value = val
+	}
+}
+
+// String returns the text of the expression, in which the operands of an arithmetic operation are only
+// parenthesized where the precedence of the operators requires it, such as in "(a + b) * c" or "a - (b - c)".
 func (e *Expression) String() string {
-	if e.value != nil {
-		return fmt.Sprintf("%v", e.value)
+	text, _ := e.Format(formatValue)
+	return text
+}
+
+// Format returns the text of the expression like String, writing its constants with the specified function,
+// such as one quoting strings so that the text can be parsed again. It returns the first error of the function.
+func (e *Expression) Format(constant func(val any) (string, error)) (string, error) {
+	if e.IsArithmetic() {
+		lhs, err := e.lhs.Format(constant)
+		if err != nil {
+			return "", err
+		}
+		rhs, err := e.rhs.Format(constant)
+		if err != nil {
+			return "", err
+		}
+		if e.lhs.IsArithmetic() && e.lhs.op.Precedence() < e.op.Precedence() {
+			lhs = "(" + lhs + ")"
+		}
+		if e.rhs.IsArithmetic() && e.rhs.op.Precedence() <= e.op.Precedence() {
+			rhs = "(" + rhs + ")"
+		}
+		return lhs + " " + e.op.String() + " " + rhs, nil
 	}
-	return e.fieldName
+	if e.IsFieldName() {
+		return e.fieldName, nil
+	}
+	if e.value == nil {
+		return "null", nil
+	}
+	return constant(e.value)
+}
+
+// formatValue writes a constant as its default text, which leaves strings unquoted.
+func formatValue(val any) (string, error) {
+	return fmt.Sprintf("%v", val), nil
 }
@@ -0,0 +1,41 @@
+package query
+
+import (
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// concatFields returns the fields of a scan combining the records of two scans:
+// the fields of the first one followed by those of the second one.
+// A field of both scans is only listed once, at its position in the first scan,
+// with the type of the scan its values are read from.
+func concatFields(fields1, fields2 []types.FieldInfo, readFromSecond bool) []types.FieldInfo {
+	fields := make([]types.FieldInfo, 0, len(fields1)+len(fields2))
+	for _, field := range fields1 {
+		if other, ok := types.FindField(fields2, field.Name); ok && readFromSecond {
+			field = other
+		}
+		fields = append(fields, field)
+	}
+	for _, field := range fields2 {
+		if _, ok := types.FindField(fields1, field.Name); !ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// aggregationFields returns the fields of a scan aggregating the records of the input scan:
+// the grouping fields, followed by the aggregation fields (see functions.AggregationFunction#OutputField).
+func aggregationFields(inputFields []types.FieldInfo, groupFields []string, aggregationFunctions []functions.AggregationFunction) []types.FieldInfo {
+	fields := make([]types.FieldInfo, 0, len(groupFields)+len(aggregationFunctions))
+	for _, groupField := range groupFields {
+		if field, ok := types.FindField(inputFields, groupField); ok {
+			fields = append(fields, field)
+		}
+	}
+	for _, function := range aggregationFunctions {
+		fields = append(fields, function.OutputField(inputFields))
+	}
+	return fields
+}
@@ -1,6 +1,11 @@
 package functions
 
-import "github.com/JyotinderSingh/dropdb/scan"
+import (
+	"fmt"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
 
 type AggregationFunction interface {
 	// ProcessFirst uses the current record of the
@@ -15,6 +20,41 @@ type AggregationFunction interface {
 	// field.
 	FieldName() string
 
+	// AggregatedField returns the name of the field whose values are aggregated.
+	AggregatedField() string
+
 	// Value returns the computed aggregation value.
 	Value() any
+
+	// OutputField returns the name, type, and length of the aggregation field,
+	// given the fields of the records being aggregated. Its type is the type of the values returned by Value.
+	OutputField(inputFields []types.FieldInfo) types.FieldInfo
+
+	// Clone returns a new aggregation function of the same kind over the
+	// same field, which has not processed any record yet. It is used to
+	// aggregate several groups at the same time.
+	Clone() AggregationFunction
+
+	// Merge combines the partial aggregate computed by the specified function,
+	// which must be of the same kind over the same field, into this one, as if
+	// this function had also processed the records the other one processed.
+	// Both functions must have processed at least one record. It is used to
+	// combine the aggregates computed over separate parts of the same group.
+	Merge(other AggregationFunction) error
+}
+
+// mergeError returns the error of merging a function of another kind or over another field into f.
+func mergeError(f, other AggregationFunction) error {
+	return fmt.Errorf("cannot merge %s into %s", other.FieldName(), f.FieldName())
+}
+
+// aggregatedFieldCopy returns a field with the specified name, and the type and length of the
+// aggregated field among the input fields. The type defaults to int if the input has no such field.
+func aggregatedFieldCopy(name, aggregatedField string, inputFields []types.FieldInfo) types.FieldInfo {
+	field, ok := types.FindField(inputFields, aggregatedField)
+	if !ok {
+		return types.FieldInfo{Name: name, Type: types.Integer}
+	}
+	field.Name = name
+	return field
 }
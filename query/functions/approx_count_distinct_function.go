@@ -0,0 +1,229 @@
+package functions
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ AggregationFunction = &ApproxCountDistinctFunction{}
+
+const approxCountDistinctFunctionPrefix = "approxCountDistinctOf"
+
+const (
+	// hllPrecision is the number of hash bits used to select a register.
+	hllPrecision = 12
+
+	// hllRegisters is the number of registers of the sketch. The standard error of
+	// the estimate is about 1.04/sqrt(hllRegisters), i.e. about 1.6%, so that estimates
+	// are typically within a few percent of the exact count.
+	hllRegisters = 1 << hllPrecision
+
+	// hllSparseLimit is the number of distinct hashes that are kept exactly before
+	// switching to the registers, so that tiny cardinalities are counted exactly.
+	hllSparseLimit = 64
+)
+
+// ApproxCountDistinctFunction estimates the number of distinct non-null values of a field
+// with a HyperLogLog sketch, so that the memory used per group is constant whatever the
+// number of distinct values in it.
+type ApproxCountDistinctFunction struct {
+	fieldName string
+	sparse    map[uint64]struct{}
+	registers []uint8
+}
+
+// NewApproxCountDistinctFunction creates a new approximate distinct count aggregation
+// function for the specified field.
+func NewApproxCountDistinctFunction(fieldName string) *ApproxCountDistinctFunction {
+	return &ApproxCountDistinctFunction{
+		fieldName: fieldName,
+	}
+}
+
+// ProcessFirst resets the sketch and adds the field value in the current record to it.
+func (f *ApproxCountDistinctFunction) ProcessFirst(s scan.Scan) error {
+	f.sparse = make(map[uint64]struct{})
+	f.registers = nil
+	return f.ProcessNext(s)
+}
+
+// ProcessNext adds the field value in the current record to the sketch.
+// Null values are not counted.
+func (f *ApproxCountDistinctFunction) ProcessNext(s scan.Scan) error {
+	val, err := s.GetVal(f.fieldName)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return nil
+	}
+	f.add(hashDistinctValue(val))
+	return nil
+}
+
+// add adds a hashed value to the sketch.
+func (f *ApproxCountDistinctFunction) add(hash uint64) {
+	if f.registers == nil {
+		if f.sparse == nil {
+			f.sparse = make(map[uint64]struct{})
+		}
+		f.sparse[hash] = struct{}{}
+		if len(f.sparse) <= hllSparseLimit {
+			return
+		}
+		f.registers = make([]uint8, hllRegisters)
+		for h := range f.sparse {
+			f.addToRegisters(h)
+		}
+		f.sparse = nil
+		return
+	}
+	f.addToRegisters(hash)
+}
+
+// addToRegisters records the rank of the hash in the register selected by its top bits.
+func (f *ApproxCountDistinctFunction) addToRegisters(hash uint64) {
+	index := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > f.registers[index] {
+		f.registers[index] = rank
+	}
+}
+
+// FieldName returns the field's name, prepended by approxCountDistinctFunctionPrefix.
+func (f *ApproxCountDistinctFunction) FieldName() string {
+	return approxCountDistinctFunctionPrefix + f.fieldName
+}
+
+// AggregatedField returns the name of the aggregated field.
+func (f *ApproxCountDistinctFunction) AggregatedField() string {
+	return f.fieldName
+}
+
+// Value returns the estimated number of distinct values as an int64.
+// Below hllSparseLimit distinct values the count is exact.
+func (f *ApproxCountDistinctFunction) Value() any {
+	if f.registers == nil {
+		return int64(len(f.sparse))
+	}
+
+	m := float64(hllRegisters)
+	sum := 0.0
+	zeros := 0
+	for _, r := range f.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Small range correction: linear counting is more accurate while registers are empty.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// OutputField returns the aggregation field, which is a long.
+func (f *ApproxCountDistinctFunction) OutputField([]types.FieldInfo) types.FieldInfo {
+	return types.FieldInfo{Name: f.FieldName(), Type: types.Long}
+}
+
+// Clone returns a new approximate distinct count function over the same field.
+func (f *ApproxCountDistinctFunction) Clone() AggregationFunction {
+	return NewApproxCountDistinctFunction(f.fieldName)
+}
+
+// hashDistinctValue returns a 64-bit hash of a value. Each value is tagged with its type,
+// integers of every width and integral floats hash the same since they compare equal, and
+// dates hash as the same instant whatever their location.
+func hashDistinctValue(val any) uint64 {
+	h := fnv.New64a()
+	var buf [9]byte
+	switch v := val.(type) {
+	case int:
+		buf[0] = 'i'
+		binary.BigEndian.PutUint64(buf[1:], uint64(v))
+		h.Write(buf[:])
+	case int16:
+		buf[0] = 'i'
+		binary.BigEndian.PutUint64(buf[1:], uint64(int64(v)))
+		h.Write(buf[:])
+	case int64:
+		buf[0] = 'i'
+		binary.BigEndian.PutUint64(buf[1:], uint64(v))
+		h.Write(buf[:])
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			buf[0] = 'i'
+			binary.BigEndian.PutUint64(buf[1:], uint64(int64(v)))
+		} else {
+			buf[0] = 'f'
+			binary.BigEndian.PutUint64(buf[1:], math.Float64bits(v))
+		}
+		h.Write(buf[:])
+	case string:
+		h.Write([]byte{'s'})
+		h.Write([]byte(v))
+	case bool:
+		buf[0] = 'b'
+		if v {
+			buf[1] = 1
+		}
+		h.Write(buf[:2])
+	case time.Time:
+		buf[0] = 't'
+		binary.BigEndian.PutUint64(buf[1:], uint64(v.UnixNano()))
+		h.Write(buf[:])
+	default:
+		fmt.Fprintf(h, "x%T:%v", v, v)
+	}
+	return mixHash(h.Sum64())
+}
+
+// mixHash spreads the bits of an FNV hash, whose high bits vary little between
+// similar inputs, so that every bit of the result is usable by the sketch.
+func mixHash(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9e502a4c3ad
+	x ^= x >> 33
+	return x
+}
+
+// Merge adds the values counted by the other sketch to this one. The registers of the
+// merged sketch are the maximum of the registers of both, so that the estimate is the
+// one a single sketch would have made of all the values.
+func (f *ApproxCountDistinctFunction) Merge(other AggregationFunction) error {
+	o, ok := other.(*ApproxCountDistinctFunction)
+	if !ok || o.fieldName != f.fieldName {
+		return mergeError(f, other)
+	}
+	for hash := range o.sparse {
+		f.add(hash)
+	}
+	if o.registers == nil {
+		return nil
+	}
+	if f.registers == nil {
+		f.registers = make([]uint8, hllRegisters)
+		for hash := range f.sparse {
+			f.addToRegisters(hash)
+		}
+		f.sparse = nil
+	}
+	for i, rank := range o.registers {
+		f.registers[i] = max(f.registers[i], rank)
+	}
+	return nil
+}
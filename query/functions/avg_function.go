@@ -1,7 +1,10 @@
 package functions
 
 import (
+	"fmt"
+
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 var _ AggregationFunction = &AvgFunction{}
@@ -10,8 +13,9 @@ const avgFunctionPrefix = "avgOf"
 
 type AvgFunction struct {
 	fieldName string
-	sum       int
-	count     int
+	sum       int64   // The sum of the integer values, accumulated as a long.
+	floatSum  float64 // The sum of the float values, which are not added to sum.
+	count     int64
 }
 
 // NewAvgFunction creates a new avg aggregation function for the specified field.
@@ -23,30 +27,28 @@ func NewAvgFunction(fieldName string) *AvgFunction {
 
 // ProcessFirst sets the initial sum and count.
 func (f *AvgFunction) ProcessFirst(s scan.Scan) error {
-	val, err := s.GetVal(f.fieldName)
-	if err != nil {
-		return err
-	}
-	numVal, err := toInt(val)
-	if err != nil {
-		return err
-	}
-	f.sum = numVal
-	f.count = 1
-	return nil
+	f.sum, f.floatSum, f.count = 0, 0, 0
+	return f.ProcessNext(s)
 }
 
-// ProcessNext adds the field value to the sum and increments the count.
+// ProcessNext adds the field value to the sum and increments the count, unless the value is null.
 func (f *AvgFunction) ProcessNext(s scan.Scan) error {
 	val, err := s.GetVal(f.fieldName)
-	if err != nil {
+	if err != nil || val == nil {
 		return err
 	}
-	numVal, err := toInt(val)
+	if floatVal, ok := val.(float64); ok {
+		f.floatSum += floatVal
+		f.count++
+		return nil
+	}
+	numVal, err := toLong(val)
 	if err != nil {
 		return err
 	}
-	f.sum += numVal
+	if f.sum, err = addLongs(f.sum, numVal); err != nil {
+		return fmt.Errorf("avg of %s: %w", f.fieldName, err)
+	}
 	f.count++
 	return nil
 }
@@ -56,11 +58,40 @@ func (f *AvgFunction) FieldName() string {
 	return avgFunctionPrefix + f.fieldName
 }
 
-// Value returns the current average as a float64 (or int, depending on your needs).
-// TODO: Casts value to int for now since our database doesnt support floats yet..
+// AggregatedField returns the name of the aggregated field.
+func (f *AvgFunction) AggregatedField() string {
+	return f.fieldName
+}
+
+// Value returns the current average as a float64, or nil if every value processed was null.
 func (f *AvgFunction) Value() any {
 	if f.count == 0 {
-		return 0 // or error if no rows
+		return nil // every value processed was null
 	}
-	return int(f.sum / f.count)
+	return (float64(f.sum) + f.floatSum) / float64(f.count)
+}
+
+// OutputField returns the aggregation field, which is a float like the values returned by Value.
+func (f *AvgFunction) OutputField([]types.FieldInfo) types.FieldInfo {
+	return types.FieldInfo{Name: f.FieldName(), Type: types.Float}
+}
+
+// Clone returns a new avg function over the same field.
+func (f *AvgFunction) Clone() AggregationFunction {
+	return NewAvgFunction(f.fieldName)
+}
+
+// Merge adds the sum and count of the other avg function to this one's.
+func (f *AvgFunction) Merge(other AggregationFunction) error {
+	o, ok := other.(*AvgFunction)
+	if !ok || o.fieldName != f.fieldName {
+		return mergeError(f, other)
+	}
+	var err error
+	if f.sum, err = addLongs(f.sum, o.sum); err != nil {
+		return fmt.Errorf("avg of %s: %w", f.fieldName, err)
+	}
+	f.floatSum += o.floatSum
+	f.count += o.count
+	return nil
 }
@@ -2,43 +2,90 @@ package functions
 
 import (
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 var _ AggregationFunction = &CountFunction{}
 
 const countFunctionPrefix = "countOf"
 
+// CountAllFieldName is the name of the field of COUNT(*), which counts every record.
+const CountAllFieldName = countFunctionPrefix + "all"
+
 type CountFunction struct {
 	fieldName string
 	count     int64
+	// all is true for COUNT(*), which counts every record whatever its values, and has no aggregated field.
+	all bool
 }
 
-// NewCountFunction creates a new count aggregation function for the specified field.
-// Some implementations ignore the fieldName if they want to count *all* rows.
+// NewCountFunction creates a new count aggregation function for the specified field,
+// which counts the records in which the field is not null.
 func NewCountFunction(fieldName string) *CountFunction {
 	return &CountFunction{
 		fieldName: fieldName,
 	}
 }
 
-// ProcessFirst initializes the count to 1.
+// NewCountAllFunction creates a new count aggregation function counting every record, like COUNT(*).
+func NewCountAllFunction() *CountFunction {
+	return &CountFunction{all: true}
+}
+
+// ProcessFirst initializes the count to 1, or to 0 if the field is null in the current record.
 func (f *CountFunction) ProcessFirst(s scan.Scan) error {
-	f.count = 1
-	return nil
+	f.count = 0
+	return f.ProcessNext(s)
 }
 
-// ProcessNext increments the count by 1.
+// ProcessNext increments the count by 1, unless the field is null in the current record.
 func (f *CountFunction) ProcessNext(s scan.Scan) error {
+	if f.all {
+		f.count++
+		return nil
+	}
+	val, err := s.GetVal(f.fieldName)
+	if err != nil || val == nil {
+		return err
+	}
 	f.count++
 	return nil
 }
 
-// FieldName returns a name like "countOf<field>".
+// FieldName returns a name like "countOf<field>", or CountAllFieldName if the function counts every record.
 func (f *CountFunction) FieldName() string {
+	if f.all {
+		return CountAllFieldName
+	}
 	return countFunctionPrefix + f.fieldName
 }
 
+// AggregatedField returns the name of the aggregated field, which is empty if the function counts every record.
+func (f *CountFunction) AggregatedField() string {
+	return f.fieldName
+}
+
 // Value returns the current count.
 func (f *CountFunction) Value() any {
 	return f.count
 }
+
+// OutputField returns the aggregation field, which is a long.
+func (f *CountFunction) OutputField([]types.FieldInfo) types.FieldInfo {
+	return types.FieldInfo{Name: f.FieldName(), Type: types.Long}
+}
+
+// Clone returns a new count function over the same field, or counting every record like this one.
+func (f *CountFunction) Clone() AggregationFunction {
+	return &CountFunction{fieldName: f.fieldName, all: f.all}
+}
+
+// Merge adds the count of the other count function to this one.
+func (f *CountFunction) Merge(other AggregationFunction) error {
+	o, ok := other.(*CountFunction)
+	if !ok || o.fieldName != f.fieldName || o.all != f.all {
+		return mergeError(f, other)
+	}
+	f.count += o.count
+	return nil
+}
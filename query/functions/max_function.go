@@ -30,14 +30,15 @@ func (f *MaxFunction) ProcessFirst(s scan.Scan) error {
 }
 
 // ProcessNext replaces the current maximum with the field
-// value in the current record if it is greater.
+// value in the current record if it is greater, or if there is no maximum yet
+// because every value processed was null. A null value is skipped.
 func (f *MaxFunction) ProcessNext(s scan.Scan) error {
 	newValue, err := s.GetVal(f.fieldName)
 	if err != nil {
 		return err
 	}
 
-	if types.CompareSupportedTypes(newValue, f.value, types.GT) {
+	if newValue != nil && (f.value == nil || types.CompareSupportedTypes(newValue, f.value, types.GT)) {
 		f.value = newValue
 	}
 
@@ -49,7 +50,34 @@ func (f *MaxFunction) FieldName() string {
 	return maxFunctionPrefix + f.fieldName
 }
 
-// Value returns the current maximum value.
+// AggregatedField returns the name of the aggregated field.
+func (f *MaxFunction) AggregatedField() string {
+	return f.fieldName
+}
+
+// Value returns the current maximum value, or nil if every value processed was null.
 func (f *MaxFunction) Value() any {
 	return f.value
 }
+
+// OutputField returns the aggregation field, which has the type and length of the aggregated field.
+func (f *MaxFunction) OutputField(inputFields []types.FieldInfo) types.FieldInfo {
+	return aggregatedFieldCopy(f.FieldName(), f.fieldName, inputFields)
+}
+
+// Clone returns a new max function over the same field.
+func (f *MaxFunction) Clone() AggregationFunction {
+	return NewMaxFunction(f.fieldName)
+}
+
+// Merge replaces the current maximum with the maximum of the other max function if it is greater.
+func (f *MaxFunction) Merge(other AggregationFunction) error {
+	o, ok := other.(*MaxFunction)
+	if !ok || o.fieldName != f.fieldName {
+		return mergeError(f, other)
+	}
+	if o.value != nil && (f.value == nil || types.CompareSupportedTypes(o.value, f.value, types.GT)) {
+		f.value = o.value
+	}
+	return nil
+}
@@ -32,14 +32,15 @@ func (f *MinFunction) ProcessFirst(s scan.Scan) error {
 }
 
 // ProcessNext replaces the current minimum with the field value in the current
-// record if it is smaller.
+// record if it is smaller, or if there is no minimum yet because every value
+// processed was null. A null value is skipped.
 func (f *MinFunction) ProcessNext(s scan.Scan) error {
 	newVal, err := s.GetVal(f.fieldName)
 	if err != nil {
 		return err
 	}
 
-	if types.CompareSupportedTypes(newVal, f.value, types.LT) {
+	if newVal != nil && (f.value == nil || types.CompareSupportedTypes(newVal, f.value, types.LT)) {
 		f.value = newVal
 	}
 	return nil
@@ -50,7 +51,34 @@ func (f *MinFunction) FieldName() string {
 	return minFunctionPrefix + f.fieldName
 }
 
-// Value returns the current minimum value.
+// AggregatedField returns the name of the aggregated field.
+func (f *MinFunction) AggregatedField() string {
+	return f.fieldName
+}
+
+// Value returns the current minimum value, or nil if every value processed was null.
 func (f *MinFunction) Value() any {
 	return f.value
 }
+
+// OutputField returns the aggregation field, which has the type and length of the aggregated field.
+func (f *MinFunction) OutputField(inputFields []types.FieldInfo) types.FieldInfo {
+	return aggregatedFieldCopy(f.FieldName(), f.fieldName, inputFields)
+}
+
+// Clone returns a new min function over the same field.
+func (f *MinFunction) Clone() AggregationFunction {
+	return NewMinFunction(f.fieldName)
+}
+
+// Merge replaces the current minimum with the minimum of the other min function if it is smaller.
+func (f *MinFunction) Merge(other AggregationFunction) error {
+	o, ok := other.(*MinFunction)
+	if !ok || o.fieldName != f.fieldName {
+		return mergeError(f, other)
+	}
+	if o.value != nil && (f.value == nil || types.CompareSupportedTypes(o.value, f.value, types.LT)) {
+		f.value = o.value
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package functions
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 var _ AggregationFunction = &SumFunction{}
@@ -12,7 +14,10 @@ const sumFunctionPrefix = "sumOf"
 
 type SumFunction struct {
 	fieldName string
-	sum       int // Using int to make it simpler to handle types across the db. This might cause issues with 64-bit integers on 32-bit architectures.
+	sum       int64   // Accumulated as a long whatever the size of the field, so that summing shorts or ints does not wrap.
+	floatSum  float64 // The sum of the float values, which are not added to sum.
+	summed    bool    // Whether a value that is not null was added to the sum.
+	floats    bool    // Whether a float value was added to the sum, which is then a float.
 }
 
 // NewSumFunction creates a new sum aggregation function for the specified field.
@@ -24,29 +29,29 @@ func NewSumFunction(fieldName string) *SumFunction {
 
 // ProcessFirst sets the initial sum to the field value in the current record.
 func (f *SumFunction) ProcessFirst(s scan.Scan) error {
-	val, err := s.GetVal(f.fieldName)
-	if err != nil {
-		return err
-	}
-	intVal, err := toInt(val)
-	if err != nil {
-		return err
-	}
-	f.sum = intVal
-	return nil
+	f.sum, f.floatSum, f.summed, f.floats = 0, 0, false, false
+	return f.ProcessNext(s)
 }
 
-// ProcessNext adds the field value in the current record to the running sum.
+// ProcessNext adds the field value in the current record to the running sum, unless it is null.
 func (f *SumFunction) ProcessNext(s scan.Scan) error {
 	val, err := s.GetVal(f.fieldName)
-	if err != nil {
+	if err != nil || val == nil {
 		return err
 	}
-	intVal, err := toInt(val)
+	if floatVal, ok := val.(float64); ok {
+		f.floatSum += floatVal
+		f.summed, f.floats = true, true
+		return nil
+	}
+	longVal, err := toLong(val)
 	if err != nil {
 		return err
 	}
-	f.sum += intVal
+	if f.sum, err = addLongs(f.sum, longVal); err != nil {
+		return fmt.Errorf("sum of %s: %w", f.fieldName, err)
+	}
+	f.summed = true
 	return nil
 }
 
@@ -55,23 +60,70 @@ func (f *SumFunction) FieldName() string {
 	return sumFunctionPrefix + f.fieldName
 }
 
-// Value returns the current sum.
+// AggregatedField returns the name of the aggregated field.
+func (f *SumFunction) AggregatedField() string {
+	return f.fieldName
+}
+
+// Value returns the current sum as an int64, or as a float64 if floats were summed,
+// or nil if every value processed was null.
 func (f *SumFunction) Value() any {
+	if !f.summed {
+		return nil
+	}
+	if f.floats {
+		return float64(f.sum) + f.floatSum
+	}
 	return f.sum
 }
 
-// Helper to handle int, int16, int64, or possibly other numeric types.
-// Using int to make it simpler to handle types across the db.
-// This might cause issues with 64-bit integers on 32-bit architectures.
-func toInt(v any) (int, error) {
+// OutputField returns the aggregation field, which is a float if the summed field is a float,
+// and otherwise a long whatever the size of the summed field.
+func (f *SumFunction) OutputField(inputFields []types.FieldInfo) types.FieldInfo {
+	if field, ok := types.FindField(inputFields, f.fieldName); ok && field.Type == types.Float {
+		return types.FieldInfo{Name: f.FieldName(), Type: types.Float}
+	}
+	return types.FieldInfo{Name: f.FieldName(), Type: types.Long}
+}
+
+// toLong converts an int, int16 or int64 to an int64.
+func toLong(v any) (int64, error) {
 	switch num := v.(type) {
 	case int:
-		return num, nil
+		return int64(num), nil
 	case int16:
-		return int(num), nil
+		return int64(num), nil
 	case int64:
-		return int(num), nil
+		return num, nil
 	default:
 		return 0, fmt.Errorf("cannot convert %T to int64 for sum", v)
 	}
 }
+
+// addLongs returns the sum of a and b, or an error wrapping types.ErrValueOutOfRange if it overflows an int64.
+func addLongs(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, fmt.Errorf("%w: %d + %d overflows type long", types.ErrValueOutOfRange, a, b)
+	}
+	return a + b, nil
+}
+
+// Clone returns a new sum function over the same field.
+func (f *SumFunction) Clone() AggregationFunction {
+	return NewSumFunction(f.fieldName)
+}
+
+// Merge adds the sum of the other sum function to this one.
+func (f *SumFunction) Merge(other AggregationFunction) error {
+	o, ok := other.(*SumFunction)
+	if !ok || o.fieldName != f.fieldName {
+		return mergeError(f, other)
+	}
+	var err error
+	if f.sum, err = addLongs(f.sum, o.sum); err != nil {
+		return fmt.Errorf("sum of %s: %w", f.fieldName, err)
+	}
+	f.floatSum += o.floatSum
+	f.summed, f.floats = f.summed || o.summed, f.floats || o.floats
+	return nil
+}
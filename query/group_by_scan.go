@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/query/functions"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 	"time"
 )
 
@@ -16,6 +17,10 @@ type GroupByScan struct {
 	aggregationFunctions []functions.AggregationFunction
 	groupValue           *GroupValue
 	moreGroups           bool
+	// emptyGroup is true if the scan has no group fields and its input is empty, until the single group
+	// of no record is output.
+	emptyGroup bool
+	closed     bool
 }
 
 // NewGroupByScan creates a groupby scan, given a grouped table scan.
@@ -39,13 +44,17 @@ func NewGroupByScan(inputScan scan.Scan, groupFields []string, aggregationFuncti
 // Internally, the underlying scan is always
 // positioned at the first record of a group, which
 // means that this method moves to the first underlying record.
+// An empty input has no first record, and so no group at all,
+// unless the scan has no group fields: all the records, even none, then form a single group.
 func (s *GroupByScan) BeforeFirst() error {
+	s.groupValue = nil
 	var err error
 	if err = s.inputScan.BeforeFirst(); err != nil {
 		return err
 	}
 
 	s.moreGroups, err = s.inputScan.Next()
+	s.emptyGroup = err == nil && !s.moreGroups && len(s.groupFields) == 0
 	return err
 }
 
@@ -54,8 +63,22 @@ func (s *GroupByScan) BeforeFirst() error {
 // The method repeatedly reads the underlying records until it encounters a
 // record having a different key.
 // The aggregation functions are called for each record in the group.
+// Once the input has no more records, including when it is empty, it returns false
+// without calling the aggregation functions, so that no group is formed from no record,
+// except for the single group of a scan without group fields, whose aggregates are those of no record.
 func (s *GroupByScan) Next() (bool, error) {
+	if s.emptyGroup {
+		s.emptyGroup = false
+		aggregationFunctions := make([]functions.AggregationFunction, len(s.aggregationFunctions))
+		for i, function := range s.aggregationFunctions {
+			aggregationFunctions[i] = function.Clone()
+		}
+		s.aggregationFunctions = aggregationFunctions
+		s.groupValue = &GroupValue{}
+		return true, nil
+	}
 	if !s.moreGroups {
+		s.groupValue = nil
 		return false, nil
 	}
 
@@ -107,8 +130,13 @@ func (s *GroupByScan) Next() (bool, error) {
 }
 
 // Close closes the scan by closing the underlying scan.
-func (s *GroupByScan) Close() {
-	s.inputScan.Close()
+// Closing the scan again has no effect.
+func (s *GroupByScan) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.inputScan.Close()
 }
 
 // GetVal gets the value of the specified field.
@@ -116,7 +144,12 @@ func (s *GroupByScan) Close() {
 // can be obtained from the saved group value.
 // Otherwise, the value is obtained from the appropriate
 // aggregation function.
+// It returns an error if the scan is not positioned on a group.
 func (s *GroupByScan) GetVal(field string) (any, error) {
+	if s.groupValue == nil {
+		return nil, fmt.Errorf("no current group")
+	}
+
 	for _, groupField := range s.groupFields {
 		if groupField == field {
 			return s.groupValue.GetVal(field), nil
@@ -186,6 +219,24 @@ func (s *GroupByScan) GetShort(field string) (int16, error) {
 	return castedValue, nil
 }
 
+// GetFloat gets the float value of the specified field.
+// If the field is a group field, then its value can be
+// obtained from the saved group value. Otherwise, the
+// value is obtained from the appropriate aggregation function.
+func (s *GroupByScan) GetFloat(field string) (float64, error) {
+	value, err := s.GetVal(field)
+	if err != nil {
+		return 0, err
+	}
+
+	castedValue, ok := value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not a float", field)
+	}
+
+	return castedValue, nil
+}
+
 // GetLong gets the long value of the specified field.
 // If the field is a group field, then its value can be
 // obtained from the saved group value. Otherwise, the
@@ -240,6 +291,11 @@ func (s *GroupByScan) GetDate(field string) (time.Time, error) {
 	return castedValue, nil
 }
 
+// Fields returns the grouping fields followed by the fields created by the aggregation functions.
+func (s *GroupByScan) Fields() []types.FieldInfo {
+	return aggregationFields(s.inputScan.Fields(), s.groupFields, s.aggregationFunctions)
+}
+
 // HasField returns true if the specified field is either a
 // grouping field or created by an aggregation function.
 func (s *GroupByScan) HasField(field string) bool {
@@ -1,8 +1,11 @@
 package query_test
 
 import (
+	"fmt"
+	"math"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,6 +19,7 @@ import (
 	"github.com/JyotinderSingh/dropdb/table"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 func setupGroupByTestTableScan(t *testing.T) (*table.Scan, func()) {
@@ -418,6 +422,52 @@ func TestGroupByScan_SumFunction(t *testing.T) {
 	assert.EqualValues(t, 4800, results["Sales"])
 }
 
+func TestGroupByScan_SumOverflow(t *testing.T) {
+	transaction, _, cleanup := createGroupByTransactionAndLayout(t)
+	defer cleanup()
+
+	schema := record.NewSchema()
+	schema.AddStringField("dept", 20)
+	schema.AddLongField("balance")
+	ts, err := table.NewTableScan(transaction, "groupbyscan_overflow_table", record.NewLayout(schema))
+	require.NoError(t, err)
+	defer ts.Close()
+
+	data := []struct {
+		Dept    string
+		Balance int64
+	}{
+		// The sum of the first group is exactly the smallest long; the second overflows it.
+		{"Engineering", math.MinInt64 + 1},
+		{"Engineering", -1},
+		{"Sales", math.MinInt64},
+		{"Sales", -1},
+	}
+	for _, row := range data {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetString("dept", row.Dept))
+		require.NoError(t, ts.SetLong("balance", row.Balance))
+	}
+	require.NoError(t, ts.BeforeFirst())
+
+	sumFn := functions.NewSumFunction("balance")
+	gbScan, err := query.NewGroupByScan(ts, []string{"dept"}, []functions.AggregationFunction{sumFn})
+	require.NoError(t, err)
+	require.NoError(t, gbScan.BeforeFirst())
+
+	hasNext, err := gbScan.Next()
+	require.NoError(t, err)
+	require.True(t, hasNext)
+	sum, err := gbScan.GetLong(sumFn.FieldName())
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), sum)
+
+	// The sum is an error rather than a value that wrapped around.
+	_, err = gbScan.Next()
+	assert.ErrorIs(t, err, types.ErrValueOutOfRange)
+	assert.ErrorContains(t, err, "sum of balance")
+}
+
 func TestGroupByScan_CountFunction(t *testing.T) {
 	ts, cleanup := setupGroupByTestTableScan(t)
 	defer cleanup()
@@ -463,6 +513,36 @@ func TestGroupByScan_CountFunction(t *testing.T) {
 	assert.EqualValues(t, 3, results["Sales"])
 }
 
+func TestGroupByScan_CountAllFunction(t *testing.T) {
+	countAllFn := functions.NewCountAllFunction()
+	countFn := functions.NewCountFunction("v")
+	gbScan, err := query.NewGroupByScan(&valuesScan{values: []any{1, nil, 2, nil, nil}}, []string{},
+		[]functions.AggregationFunction{countAllFn, countFn})
+	require.NoError(t, err)
+	defer gbScan.Close()
+
+	require.NoError(t, gbScan.BeforeFirst())
+	hasNext, err := gbScan.Next()
+	require.NoError(t, err)
+	require.True(t, hasNext)
+
+	// Every record is counted, nulls included, and the count has a stable name.
+	assert.Equal(t, functions.CountAllFieldName, countAllFn.FieldName())
+	assert.Empty(t, countAllFn.AggregatedField())
+	count, err := gbScan.GetLong(functions.CountAllFieldName)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+	count, err = gbScan.GetLong(countFn.FieldName())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// Counts of every record merge with each other only.
+	clone := countAllFn.Clone()
+	require.NoError(t, clone.Merge(countAllFn))
+	assert.Equal(t, int64(5), clone.Value())
+	assert.Error(t, clone.Merge(countFn))
+}
+
 func TestGroupByScan_AvgFunction(t *testing.T) {
 	ts, cleanup := setupGroupByTestTableScan(t)
 	defer cleanup()
@@ -508,3 +588,191 @@ func TestGroupByScan_AvgFunction(t *testing.T) {
 	assert.InDelta(t, 1500.0, results["Marketing"], 0.0001)
 	assert.InDelta(t, 1600.0, results["Sales"], 0.0001)
 }
+
+// valuesScan is a scan over a single field "v" holding the specified values.
+type valuesScan struct {
+	countingScan
+	values  []any
+	current int
+}
+
+func (vs *valuesScan) BeforeFirst() error { vs.current = -1; return nil }
+
+func (vs *valuesScan) Next() (bool, error) {
+	vs.current++
+	return vs.current < len(vs.values), nil
+}
+
+func (vs *valuesScan) GetVal(string) (any, error) { return vs.values[vs.current], nil }
+
+// approxCountDistinct aggregates the specified values in a single group and returns the estimate.
+func approxCountDistinct(t *testing.T, values []any) int64 {
+	fn := functions.NewApproxCountDistinctFunction("v")
+	gbScan, err := query.NewGroupByScan(&valuesScan{values: values}, []string{}, []functions.AggregationFunction{fn})
+	require.NoError(t, err)
+	defer gbScan.Close()
+
+	require.NoError(t, gbScan.BeforeFirst())
+	hasNext, err := gbScan.Next()
+	require.NoError(t, err)
+	require.True(t, hasNext)
+
+	estimate, err := gbScan.GetLong(fn.FieldName())
+	require.NoError(t, err)
+	return estimate
+}
+
+func TestGroupByScan_ApproxCountDistinctAccuracy(t *testing.T) {
+	const distinct = 100_000
+	values := make([]any, 0, 2*distinct)
+	for i := 0; i < distinct; i++ {
+		values = append(values, i)
+	}
+	// Duplicates of other integer widths are not counted again.
+	for i := 0; i < distinct; i += 2 {
+		values = append(values, int64(i))
+	}
+
+	estimate := approxCountDistinct(t, values)
+	assert.InEpsilon(t, distinct, estimate, 0.05, "estimate %d", estimate)
+
+	strs := make([]any, distinct)
+	for i := range strs {
+		strs[i] = fmt.Sprintf("value-%d", i)
+	}
+	estimate = approxCountDistinct(t, strs)
+	assert.InEpsilon(t, distinct, estimate, 0.05, "estimate %d", estimate)
+}
+
+func TestGroupByScan_ApproxCountDistinctTinyCardinalities(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := []any{
+		1, int16(1), int64(1), 2, "1", "a", "a", nil, nil, true, true, false,
+		day, day.In(time.FixedZone("x", 3600)), day.AddDate(0, 0, 1),
+	}
+	// 1, 2, "1", "a", true, false, day, day+1
+	assert.Equal(t, int64(8), approxCountDistinct(t, values))
+
+	assert.Equal(t, int64(0), approxCountDistinct(t, []any{nil}))
+
+	// Small cardinalities are counted exactly, larger ones are only close.
+	for n := 1; n <= 200; n++ {
+		values := make([]any, n)
+		for i := range values {
+			values[i] = i * 7
+		}
+		if n <= 64 {
+			assert.Equal(t, int64(n), approxCountDistinct(t, values), "cardinality %d", n)
+		} else {
+			assert.InDelta(t, n, approxCountDistinct(t, values), 0.05*float64(n), "cardinality %d", n)
+		}
+	}
+}
+
+func TestGroupByScan_ApproxCountDistinctWithOtherAggregates(t *testing.T) {
+	ts, cleanup := setupGroupByTestTableScan(t)
+	defer cleanup()
+
+	distinctFn := functions.NewApproxCountDistinctFunction("salary")
+	countFn := functions.NewCountFunction("salary")
+	maxFn := functions.NewMaxFunction("salary")
+
+	gbScan, err := query.NewGroupByScan(ts, []string{"dept"}, []functions.AggregationFunction{distinctFn, countFn, maxFn})
+	require.NoError(t, err)
+	defer gbScan.Close()
+
+	type result struct {
+		distinct, count int64
+		max             int
+	}
+	results := make(map[string]result)
+
+	require.NoError(t, gbScan.BeforeFirst())
+	for {
+		hasNext, err := gbScan.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		dept, err := gbScan.GetString("dept")
+		require.NoError(t, err)
+		distinct, err := gbScan.GetLong(distinctFn.FieldName())
+		require.NoError(t, err)
+		count, err := gbScan.GetLong(countFn.FieldName())
+		require.NoError(t, err)
+		maxVal, err := gbScan.GetInt(maxFn.FieldName())
+		require.NoError(t, err)
+		results[dept] = result{distinct, count, maxVal}
+	}
+
+	assert.Equal(t, map[string]result{
+		"Sales":       {3, 3, 2000},
+		"Marketing":   {1, 2, 1500},
+		"Engineering": {2, 2, 3000},
+	}, results)
+}
+
+//  7. Test grouping an input that has no records, because the table is empty or a selection
+//     filters out every record: the scan outputs no group, not a group of zero-valued aggregates.
+func TestGroupByScan_EmptyInput(t *testing.T) {
+	ts, cleanup := setupGroupByTestTableScan(t)
+	defer cleanup()
+
+	nothing := query.NewPredicateFromTerm(query.NewTerm(query.NewFieldExpression("salary"), query.NewConstantExpression(100000), types.GT))
+	selectScan, err := query.NewSelectScan(ts, nothing)
+	require.NoError(t, err)
+
+	maxSalaryFn := functions.NewMaxFunction("salary")
+	countFn := functions.NewCountFunction("salary")
+	gbScan, err := query.NewGroupByScan(selectScan, []string{"dept"}, []functions.AggregationFunction{maxSalaryFn, countFn})
+	require.NoError(t, err)
+
+	for range 2 {
+		hasNext, err := gbScan.Next()
+		require.NoError(t, err)
+		assert.False(t, hasNext, "an empty input has no group")
+		_, err = gbScan.GetVal(maxSalaryFn.FieldName())
+		assert.EqualError(t, err, "no current group")
+		require.NoError(t, gbScan.BeforeFirst())
+	}
+
+	// Without group fields, the records form a single group even when there is none.
+	countAllFn := functions.NewCountAllFunction()
+	gbScan, err = query.NewGroupByScan(selectScan, []string{}, []functions.AggregationFunction{maxSalaryFn, countFn, countAllFn})
+	require.NoError(t, err)
+	for range 2 {
+		hasNext, err := gbScan.Next()
+		require.NoError(t, err)
+		require.True(t, hasNext, "all the records form one group")
+		maxSalary, err := gbScan.GetVal(maxSalaryFn.FieldName())
+		require.NoError(t, err)
+		assert.Nil(t, maxSalary)
+		count, err := gbScan.GetLong(countFn.FieldName())
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		count, err = gbScan.GetLong(functions.CountAllFieldName)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		hasNext, err = gbScan.Next()
+		require.NoError(t, err)
+		assert.False(t, hasNext)
+		require.NoError(t, gbScan.BeforeFirst())
+	}
+
+	// The scan is not positioned on a group once it has read the last one either.
+	require.NoError(t, ts.BeforeFirst())
+	gbScan, err = query.NewGroupByScan(ts, []string{"dept"}, []functions.AggregationFunction{functions.NewMaxFunction("salary")})
+	require.NoError(t, err)
+	_, err = gbScan.GetVal("dept")
+	assert.EqualError(t, err, "no current group")
+	for {
+		hasNext, err := gbScan.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+	}
+	_, err = gbScan.GetString("dept")
+	assert.EqualError(t, err, "no current group")
+}
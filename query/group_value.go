@@ -1,11 +1,18 @@
 package query
 
 import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/types"
 )
 
 type GroupValue struct {
+	fields []string
 	values map[string]any
 }
 
@@ -21,7 +28,7 @@ func NewGroupValue(s scan.Scan, fields []string) (*GroupValue, error) {
 		}
 		values[field] = value
 	}
-	return &GroupValue{values: values}, nil
+	return &GroupValue{fields: fields, values: values}, nil
 }
 
 // GetVal returns the value of the specified field in the group.
@@ -34,7 +41,8 @@ func (g *GroupValue) GetVal(field string) any {
 }
 
 // Equals compares the specified group value with this one. Two group
-// values are equal if they have the same values for their grouping fields.
+// values are equal if they have the same values for their grouping fields,
+// where a null value only equals another null value.
 func (g *GroupValue) Equals(other any) bool {
 	otherGroup, ok := other.(*GroupValue)
 	if !ok {
@@ -43,7 +51,7 @@ func (g *GroupValue) Equals(other any) bool {
 
 	for field, value := range g.values {
 		value2 := otherGroup.GetVal(field)
-		if types.CompareSupportedTypes(value, value2, types.NE) {
+		if (value == nil) != (value2 == nil) || types.CompareSupportedTypes(value, value2, types.NE) {
 			return false
 		}
 	}
@@ -60,3 +68,66 @@ func (g *GroupValue) Hash() int {
 	}
 	return hash
 }
+
+// Key returns a map key built from the values of the group fields in order, each tagged with its type and
+// delimited, so that two group values over the same fields have the same key exactly when their values are equal
+// or both null.
+func (g *GroupValue) Key() string {
+	var key strings.Builder
+	for _, field := range g.fields {
+		writeKeyValue(&key, g.values[field])
+	}
+	return key.String()
+}
+
+// writeKeyValue appends the encoding of a single group field value to the key.
+func writeKeyValue(key *strings.Builder, value any) {
+	switch v := value.(type) {
+	case nil:
+		key.WriteString("n;")
+	case int:
+		writeKeyInt(key, int64(v))
+	case int16:
+		writeKeyInt(key, int64(v))
+	case int64:
+		writeKeyInt(key, v)
+	case float64:
+		// Integral floats are encoded as integers, since they compare equal to them.
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			writeKeyInt(key, int64(v))
+		} else {
+			key.WriteString("f")
+			key.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+			key.WriteString(";")
+		}
+	case string:
+		// The length prefix makes the encoding unambiguous whatever the contents of the string.
+		key.WriteString("s")
+		key.WriteString(strconv.Itoa(len(v)))
+		key.WriteString(":")
+		key.WriteString(v)
+	case bool:
+		if v {
+			key.WriteString("b1;")
+		} else {
+			key.WriteString("b0;")
+		}
+	case time.Time:
+		key.WriteString("t")
+		key.WriteString(v.UTC().Format(time.RFC3339Nano))
+		key.WriteString(";")
+	default:
+		formatted := fmt.Sprintf("%T:%v", v, v)
+		key.WriteString("x")
+		key.WriteString(strconv.Itoa(len(formatted)))
+		key.WriteString(":")
+		key.WriteString(formatted)
+	}
+}
+
+// writeKeyInt appends the encoding of an integer of any width to the key.
+func writeKeyInt(key *strings.Builder, value int64) {
+	key.WriteString("i")
+	key.WriteString(strconv.FormatInt(value, 10))
+	key.WriteString(";")
+}
@@ -0,0 +1,28 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupValue_Key(t *testing.T) {
+	fields := []string{"a", "b"}
+	key := func(a, b any) string {
+		return (&GroupValue{fields: fields, values: map[string]any{"a": a, "b": b}}).Key()
+	}
+
+	// Integers of different widths are equal, and so are the same instant in different locations.
+	instant := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, key(int16(5), 5), key(5, int64(5)))
+	assert.Equal(t, key(instant, true), key(instant.In(time.FixedZone("UTC+2", 2*60*60)), true))
+
+	// Values cannot run into the values of the next field.
+	assert.NotEqual(t, key("a", "bc"), key("ab", "c"))
+	assert.NotEqual(t, key("1", 2), key(1, "2"))
+	assert.NotEqual(t, key(12, 3), key(1, 23))
+	assert.NotEqual(t, key(nil, "x"), key("", "x"))
+	assert.NotEqual(t, key(true, 1), key(1, true))
+	assert.NotEqual(t, key(instant, 1), key(instant.Add(time.Nanosecond), 1))
+}
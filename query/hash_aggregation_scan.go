@@ -0,0 +1,407 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/materialize"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = &HashAggregationScan{}
+
+const (
+	// spillPartitions is the number of temporary tables the records are split into
+	// when the groups of a pass do not fit in memory.
+	spillPartitions = 4
+
+	// maxSpillLevel bounds how many times the records of a group can be spilled.
+	// A partition at this level is aggregated in memory whatever its number of groups,
+	// so that keys which keep hashing to the same partition cannot recurse forever.
+	maxSpillLevel = 8
+)
+
+// hashGroup is a group being aggregated by a HashAggregationScan.
+type hashGroup struct {
+	groupValue *GroupValue
+	functions  []functions.AggregationFunction
+}
+
+// spillPartition is a temporary table holding the spilled records of some groups.
+type spillPartition struct {
+	table *materialize.TempTable
+	level int
+}
+
+// HashAggregationScan is the scan class for the GROUP BY operation over unsorted input.
+// Unlike GroupByScan, it does not need the records of a group to be contiguous: it keeps
+// the groups in a map keyed by their GroupValue#Key, each with its own aggregation functions.
+//
+// At most maxGroups groups are kept in memory at a time. Once the map is full, the records
+// of the groups that are not in the map are spilled into temporary tables, partitioned by
+// the hash of their key, and each partition is aggregated in turn once the groups in memory
+// have been output. A group is therefore output exactly once, either from memory or from the
+// single partition its records were spilled to.
+type HashAggregationScan struct {
+	transaction          *tx.Transaction
+	inputScan            scan.Scan
+	inputSchema          *record.Schema
+	groupFields          []string
+	aggregationFunctions []functions.AggregationFunction
+	maxGroups            int
+	groups               []*hashGroup
+	nextGroup            int
+	currentGroup         *hashGroup
+	pending              []*spillPartition
+	closed               bool
+}
+
+// NewHashAggregationScan creates a hash aggregation scan over the specified input scan,
+// whose schema is used to spill records into temporary tables.
+// The aggregation functions are only used as prototypes: each group gets its own clones.
+func NewHashAggregationScan(transaction *tx.Transaction, inputScan scan.Scan, inputSchema *record.Schema, groupFields []string, aggregationFunctions []functions.AggregationFunction, maxGroups int) (*HashAggregationScan, error) {
+	s := &HashAggregationScan{
+		transaction:          transaction,
+		inputScan:            inputScan,
+		inputSchema:          inputSchema,
+		groupFields:          groupFields,
+		aggregationFunctions: aggregationFunctions,
+		maxGroups:            max(maxGroups, 1),
+	}
+
+	if err := s.BeforeFirst(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// BeforeFirst positions the scan before the first group.
+// It reads the whole input scan, aggregating the groups that fit in memory
+// and spilling the records of the others.
+func (s *HashAggregationScan) BeforeFirst() error {
+	s.pending = nil
+	s.currentGroup = nil
+	if err := s.inputScan.BeforeFirst(); err != nil {
+		return err
+	}
+	return s.aggregate(s.inputScan, 0)
+}
+
+// Next moves to the next group.
+// Once the groups in memory have been output, the next spilled partition is aggregated.
+func (s *HashAggregationScan) Next() (bool, error) {
+	for s.nextGroup >= len(s.groups) {
+		if len(s.pending) == 0 {
+			s.currentGroup = nil
+			return false, nil
+		}
+
+		partition := s.pending[0]
+		s.pending = s.pending[1:]
+		partitionScan, err := partition.table.Open()
+		if err != nil {
+			return false, err
+		}
+		err = errors.Join(s.aggregate(partitionScan, partition.level), partitionScan.Close(), partition.table.Remove())
+		if err != nil {
+			return false, err
+		}
+	}
+
+	s.currentGroup = s.groups[s.nextGroup]
+	s.nextGroup++
+	return true, nil
+}
+
+// aggregate reads the records of the specified scan, which is at the specified spill level,
+// and replaces the groups in memory with the groups they belong to.
+// The groups are output in the order in which their first record was read.
+func (s *HashAggregationScan) aggregate(input scan.Scan, level int) error {
+	groups := make(map[string]*hashGroup)
+	s.groups = nil
+	s.nextGroup = 0
+
+	var partitions []*spillPartition
+	var partitionScans []scan.UpdateScan
+	defer func() {
+		for _, partitionScan := range partitionScans {
+			partitionScan.Close()
+		}
+	}()
+
+	for {
+		next, err := input.Next()
+		if err != nil {
+			return err
+		}
+		if !next {
+			break
+		}
+
+		groupValue, err := NewGroupValue(input, s.groupFields)
+		if err != nil {
+			return err
+		}
+		key := groupValue.Key()
+
+		if group, ok := groups[key]; ok {
+			for _, function := range group.functions {
+				if err := function.ProcessNext(input); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if len(groups) >= s.maxGroups && level < maxSpillLevel && (partitions != nil || s.canSpill()) {
+			if partitions == nil {
+				if partitions, partitionScans, err = s.createPartitions(level + 1); err != nil {
+					return err
+				}
+			}
+			if err := s.spill(input, partitionScans[partitionOf(key, level)]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		group := &hashGroup{groupValue: groupValue}
+		for _, prototype := range s.aggregationFunctions {
+			function := prototype.Clone()
+			if err := function.ProcessFirst(input); err != nil {
+				return err
+			}
+			group.functions = append(group.functions, function)
+		}
+		groups[key] = group
+		s.groups = append(s.groups, group)
+	}
+
+	s.pending = append(s.pending, partitions...)
+	return nil
+}
+
+// canSpill reports whether there are enough available buffers to spill records, each partition pinning one
+// while it is written. Otherwise, the groups are aggregated in memory beyond maxGroups: when the pool is short
+// of buffers, the pins of the partitions would wait for the buffers of other transactions, which may be waiting
+// for the buffers of this one.
+func (s *HashAggregationScan) canSpill() bool {
+	return s.transaction.AvailableBuffers() > spillPartitions
+}
+
+// createPartitions creates the temporary tables for the records spilled at the specified level,
+// and opens a scan on each of them.
+func (s *HashAggregationScan) createPartitions(level int) ([]*spillPartition, []scan.UpdateScan, error) {
+	partitions := make([]*spillPartition, spillPartitions)
+	partitionScans := make([]scan.UpdateScan, 0, spillPartitions)
+	for i := range partitions {
+		partitions[i] = &spillPartition{table: materialize.NewTempTable(s.transaction, s.inputSchema), level: level}
+		partitionScan, err := partitions[i].table.Open()
+		if err != nil {
+			for _, opened := range partitionScans {
+				opened.Close()
+			}
+			return nil, nil, err
+		}
+		partitionScans = append(partitionScans, partitionScan)
+	}
+	return partitions, partitionScans, nil
+}
+
+// spill copies the current record of the input scan into the specified partition.
+func (s *HashAggregationScan) spill(input scan.Scan, partitionScan scan.UpdateScan) error {
+	if err := partitionScan.Insert(); err != nil {
+		return err
+	}
+	for _, field := range s.inputSchema.Fields() {
+		value, err := input.GetVal(field)
+		if err != nil {
+			return err
+		}
+		if err := partitionScan.SetVal(field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionOf returns the partition that the records of the group with the specified key
+// are spilled to. The level is hashed with the key, so that the groups of a partition
+// are split differently when the partition itself has to be spilled.
+func partitionOf(key string, level int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte{byte(level)})
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32() % spillPartitions)
+}
+
+// Close closes the scan by closing the underlying scan.
+// Closing the scan again has no effect.
+func (s *HashAggregationScan) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.pending = nil
+	return s.inputScan.Close()
+}
+
+// GetVal gets the value of the specified field in the current group.
+// If the field is a group field, then its value
+// can be obtained from the saved group value.
+// Otherwise, the value is obtained from the group's
+// aggregation function.
+func (s *HashAggregationScan) GetVal(field string) (any, error) {
+	if s.currentGroup == nil {
+		return nil, fmt.Errorf("no current group")
+	}
+
+	for _, groupField := range s.groupFields {
+		if groupField == field {
+			return s.currentGroup.groupValue.GetVal(field), nil
+		}
+	}
+
+	for i, function := range s.aggregationFunctions {
+		if function.FieldName() == field {
+			return s.currentGroup.functions[i].Value(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("field %s not found", field)
+}
+
+// GetInt gets the integer value of the specified field in the current group.
+func (s *HashAggregationScan) GetInt(field string) (int, error) {
+	value, err := s.GetVal(field)
+	if err != nil {
+		return 0, err
+	}
+
+	castedValue, ok := value.(int)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not an int", field)
+	}
+
+	return castedValue, nil
+}
+
+// GetString gets the string value of the specified field in the current group.
+func (s *HashAggregationScan) GetString(field string) (string, error) {
+	value, err := s.GetVal(field)
+	if err != nil {
+		return "", err
+	}
+
+	castedValue, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s is not a string", field)
+	}
+
+	return castedValue, nil
+}
+
+// GetShort gets the short value of the specified field in the current group.
+func (s *HashAggregationScan) GetShort(field string) (int16, error) {
+	value, err := s.GetVal(field)
+	if err != nil {
+		return 0, err
+	}
+
+	castedValue, ok := value.(int16)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not a short", field)
+	}
+
+	return castedValue, nil
+}
+
+// GetFloat gets the float value of the specified field in the current group.
+func (s *HashAggregationScan) GetFloat(field string) (float64, error) {
+	value, err := s.GetVal(field)
+	if err != nil {
+		return 0, err
+	}
+
+	castedValue, ok := value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not a float", field)
+	}
+
+	return castedValue, nil
+}
+
+// GetLong gets the long value of the specified field in the current group.
+func (s *HashAggregationScan) GetLong(field string) (int64, error) {
+	value, err := s.GetVal(field)
+	if err != nil {
+		return 0, err
+	}
+
+	castedValue, ok := value.(int64)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not a long", field)
+	}
+
+	return castedValue, nil
+}
+
+// GetBool gets the boolean value of the specified field in the current group.
+func (s *HashAggregationScan) GetBool(field string) (bool, error) {
+	value, err := s.GetVal(field)
+	if err != nil {
+		return false, err
+	}
+
+	castedValue, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("field %s is not a bool", field)
+	}
+
+	return castedValue, nil
+}
+
+// GetDate gets the date value of the specified field in the current group.
+func (s *HashAggregationScan) GetDate(field string) (time.Time, error) {
+	value, err := s.GetVal(field)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	castedValue, ok := value.(time.Time)
+	if !ok {
+		return time.Time{}, fmt.Errorf("field %s is not a date", field)
+	}
+
+	return castedValue, nil
+}
+
+// Fields returns the grouping fields followed by the fields created by the aggregation functions.
+func (s *HashAggregationScan) Fields() []types.FieldInfo {
+	return aggregationFields(s.inputScan.Fields(), s.groupFields, s.aggregationFunctions)
+}
+
+// HasField returns true if the specified field is either a
+// grouping field or created by an aggregation function.
+func (s *HashAggregationScan) HasField(field string) bool {
+	for _, groupField := range s.groupFields {
+		if groupField == field {
+			return true
+		}
+	}
+
+	for _, function := range s.aggregationFunctions {
+		if function.FieldName() == field {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package query_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+)
+
+type hashAggregationRow struct {
+	first  string
+	second string
+	amount int
+}
+
+// setupHashAggregationTableScan creates a table scan over the specified rows, in the specified order.
+func setupHashAggregationTableScan(t *testing.T, rows []hashAggregationRow) (*tx.Transaction, *table.Scan, *record.Schema) {
+	fm, err := file.NewManager(filepath.Join(t.TempDir(), "hash_aggregation"), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	transaction := tx.NewTransaction(fm, lm, bm, concurrency.NewLockTable())
+	t.Cleanup(func() { require.NoError(t, transaction.Commit()) })
+
+	schema := record.NewSchema()
+	schema.AddStringField("first", 5)
+	schema.AddStringField("second", 5)
+	schema.AddIntField("amount")
+
+	ts, err := table.NewTableScan(transaction, "hash_aggregation_table", record.NewLayout(schema))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, ts.Close()) })
+
+	for _, row := range rows {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetString("first", row.first))
+		require.NoError(t, ts.SetString("second", row.second))
+		require.NoError(t, ts.SetInt("amount", row.amount))
+	}
+	require.NoError(t, ts.BeforeFirst())
+	return transaction, ts, schema
+}
+
+// collectGroups reads the groups of the scan into a map from "first/second" to the count and sum of the group.
+func collectGroups(t *testing.T, s *query.HashAggregationScan) map[string][2]int64 {
+	groups := make(map[string][2]int64)
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			return groups
+		}
+		first, err := s.GetString("first")
+		require.NoError(t, err)
+		second, err := s.GetString("second")
+		require.NoError(t, err)
+		count, err := s.GetLong("countOfamount")
+		require.NoError(t, err)
+		sum, err := s.GetLong("sumOfamount")
+		require.NoError(t, err)
+
+		key := first + "/" + second
+		assert.NotContains(t, groups, key, "a group must be output only once")
+		groups[key] = [2]int64{count, sum}
+	}
+}
+
+func TestHashAggregationScan_CompositeKeys(t *testing.T) {
+	// The concatenation of the group fields is the same for several groups,
+	// and the records of a group are not contiguous.
+	rows := []hashAggregationRow{
+		{"a", "bc", 1},
+		{"ab", "c", 10},
+		{"abc", "", 100},
+		{"a", "bc", 2},
+		{"", "abc", 1000},
+		{"ab", "c", 20},
+		{"a", "bc", 3},
+	}
+	transaction, ts, schema := setupHashAggregationTableScan(t, rows)
+
+	s, err := query.NewHashAggregationScan(transaction, ts, schema, []string{"first", "second"},
+		[]functions.AggregationFunction{functions.NewCountFunction("amount"), functions.NewSumFunction("amount")}, 100)
+	require.NoError(t, err)
+
+	expected := map[string][2]int64{
+		"a/bc": {3, 6},
+		"ab/c": {2, 30},
+		"abc/": {1, 100},
+		"/abc": {1, 1000},
+	}
+	assert.Equal(t, expected, collectGroups(t, s))
+
+	assert.True(t, s.HasField("first"))
+	assert.True(t, s.HasField("sumOfamount"))
+	assert.False(t, s.HasField("amount"))
+}
+
+func TestHashAggregationScan_Spill(t *testing.T) {
+	var rows []hashAggregationRow
+	expected := make(map[string][2]int64)
+	for i := 0; i < 300; i++ {
+		// Spread the records of the 40 groups across the whole table.
+		group := (i * 7) % 40
+		row := hashAggregationRow{first: fmt.Sprintf("g%d", group%8), second: fmt.Sprintf("h%d", group/8), amount: i}
+		rows = append(rows, row)
+
+		key := row.first + "/" + row.second
+		expected[key] = [2]int64{expected[key][0] + 1, expected[key][1] + int64(i)}
+	}
+	transaction, ts, schema := setupHashAggregationTableScan(t, rows)
+
+	// Only 3 groups fit in memory, so most records are spilled, and some partitions are spilled again.
+	s, err := query.NewHashAggregationScan(transaction, ts, schema, []string{"first", "second"},
+		[]functions.AggregationFunction{functions.NewCountFunction("amount"), functions.NewSumFunction("amount")}, 3)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, collectGroups(t, s))
+
+	// The scan can be read again from the start.
+	require.NoError(t, s.BeforeFirst())
+	assert.Equal(t, expected, collectGroups(t, s))
+}
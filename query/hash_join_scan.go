@@ -0,0 +1,418 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/materialize"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*HashJoinScan)(nil)
+
+// hashJoinPartition is a pair of temporary tables holding the spilled records of both inputs of a hash join
+// whose join keys hash to the same partition, at the specified spill level, so that they are joined together.
+type hashJoinPartition struct {
+	build *materialize.TempTable
+	probe *materialize.TempTable
+	level int
+}
+
+// HashJoinScan is the scan for the hash join operator, which joins the records of two scans whose join fields
+// are equal. The records of the build scan, the smaller one, are read into a hash table keyed by their join value
+// (see GroupValue#Key), so that values that compare equal have the same key whatever their type. The records of
+// the probe scan are then read once, each joined with the build records having its join value.
+// Null join values match no record, like they do in the predicate of a product.
+//
+// At most maxBuildRecords build records are kept in memory. If the build scan has more, the records of both
+// scans are spilled into temporary tables partitioned by the hash of their join value, as a grace hash join
+// does, and the records of each pair of partitions are joined in turn, a partition being spilled again if its
+// build records do not fit in memory either.
+type HashJoinScan struct {
+	transaction     *tx.Transaction
+	probeScan       scan.Scan
+	buildScan       scan.Scan
+	probeSchema     *record.Schema
+	buildSchema     *record.Schema
+	probeField      string
+	buildField      string
+	maxBuildRecords int
+	// table maps the join keys of the build records in memory to those records.
+	table map[string][]map[string]any
+	// probeInput is the scan whose records probe the table: the probe scan, or the probe side of a partition.
+	probeInput scan.Scan
+	// partition is the partition whose records are being joined, or nil while the scans are joined.
+	partition *hashJoinPartition
+	matches   []map[string]any
+	match     int
+	pending   []*hashJoinPartition
+	closed    bool
+}
+
+// NewHashJoinScan creates a hash join scan of the specified scans, whose schemas are used to spill their records
+// into temporary tables. The records of the probe scan whose probe field equals the build field of the records
+// of the build scan are joined, at most maxBuildRecords build records being kept in memory at a time.
+func NewHashJoinScan(transaction *tx.Transaction, probeScan scan.Scan, probeSchema *record.Schema, probeField string,
+	buildScan scan.Scan, buildSchema *record.Schema, buildField string, maxBuildRecords int) (*HashJoinScan, error) {
+	hjs := &HashJoinScan{
+		transaction:     transaction,
+		probeScan:       probeScan,
+		buildScan:       buildScan,
+		probeSchema:     probeSchema,
+		buildSchema:     buildSchema,
+		probeField:      probeField,
+		buildField:      buildField,
+		maxBuildRecords: max(maxBuildRecords, 1),
+	}
+
+	if err := hjs.BeforeFirst(); err != nil {
+		return nil, err
+	}
+
+	return hjs, nil
+}
+
+// BeforeFirst positions the scan before the first record.
+// It reads the whole build scan into the hash table, or spills both scans if the build scan does not fit in memory.
+func (hjs *HashJoinScan) BeforeFirst() error {
+	if err := errors.Join(hjs.removePending(), hjs.closeProbeInput()); err != nil {
+		return err
+	}
+	if err := hjs.buildScan.BeforeFirst(); err != nil {
+		return err
+	}
+	if err := hjs.probeScan.BeforeFirst(); err != nil {
+		return err
+	}
+	return hjs.build(hjs.buildScan, hjs.probeScan, nil)
+}
+
+// Next moves to the next record of the join: the next build record matching the current probe record,
+// or else the first build record matching the next probe record having any.
+// Once the probe records have been read, the next spilled partition is joined.
+func (hjs *HashJoinScan) Next() (bool, error) {
+	for {
+		if hjs.match+1 < len(hjs.matches) {
+			hjs.match++
+			return true, nil
+		}
+		hjs.matches = nil
+
+		if hjs.probeInput != nil {
+			hasNext, err := hjs.probeInput.Next()
+			if err != nil {
+				return false, err
+			}
+			if hasNext {
+				value, err := hjs.probeInput.GetVal(hjs.probeField)
+				if err != nil {
+					return false, err
+				}
+				if value != nil {
+					hjs.matches, hjs.match = hjs.table[joinKey(value)], -1
+				}
+				continue
+			}
+			if err := hjs.closeProbeInput(); err != nil {
+				return false, err
+			}
+		}
+
+		if len(hjs.pending) == 0 {
+			return false, nil
+		}
+		if err := hjs.joinPartition(); err != nil {
+			return false, err
+		}
+	}
+}
+
+// joinPartition starts joining the records of the next spilled partition.
+func (hjs *HashJoinScan) joinPartition() error {
+	partition := hjs.pending[0]
+	hjs.pending = hjs.pending[1:]
+
+	buildInput, err := partition.build.Open()
+	if err != nil {
+		return err
+	}
+	probeInput, err := partition.probe.Open()
+	if err != nil {
+		return errors.Join(err, buildInput.Close())
+	}
+	err = hjs.build(buildInput, probeInput, partition)
+	return errors.Join(err, buildInput.Close(), partition.build.Remove())
+}
+
+// build reads the records of the build input, which belong to the specified partition, or to none at first,
+// into the hash table, so that the records of the probe input are then joined with them. If there are more
+// build records than fit in memory, the records of both inputs are spilled into partitions instead.
+func (hjs *HashJoinScan) build(buildInput, probeInput scan.Scan, partition *hashJoinPartition) error {
+	hjs.table = make(map[string][]map[string]any)
+	hjs.probeInput, hjs.partition = probeInput, partition
+	level := 0
+	if partition != nil {
+		level = partition.level
+	}
+
+	var buildRecords []map[string]any
+	for {
+		hasNext, err := buildInput.Next()
+		if err != nil {
+			return err
+		}
+		if !hasNext {
+			break
+		}
+		if len(buildRecords) >= hjs.maxBuildRecords && level < maxSpillLevel && hjs.canSpill() {
+			hjs.table = nil
+			return hjs.spill(buildRecords, buildInput, level+1)
+		}
+
+		value, err := buildInput.GetVal(hjs.buildField)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			continue
+		}
+		buildRecord := make(map[string]any, len(hjs.buildSchema.Fields()))
+		for _, field := range hjs.buildSchema.Fields() {
+			if buildRecord[field], err = buildInput.GetVal(field); err != nil {
+				return err
+			}
+		}
+		buildRecords = append(buildRecords, buildRecord)
+		key := joinKey(value)
+		hjs.table[key] = append(hjs.table[key], buildRecord)
+	}
+	return nil
+}
+
+// canSpill reports whether there are enough available buffers to spill records, each partition pinning one
+// while it is written (see HashAggregationScan#canSpill).
+func (hjs *HashJoinScan) canSpill() bool {
+	return hjs.transaction.AvailableBuffers() > spillPartitions
+}
+
+// spill writes the build records read so far, the current and remaining records of the build input,
+// and the records of the probe input, into new partitions at the specified level, which are joined in turn.
+func (hjs *HashJoinScan) spill(buildRecords []map[string]any, buildInput scan.Scan, level int) error {
+	partitions := make([]*hashJoinPartition, spillPartitions)
+	for i := range partitions {
+		partitions[i] = &hashJoinPartition{
+			build: materialize.NewTempTable(hjs.transaction, hjs.buildSchema),
+			probe: materialize.NewTempTable(hjs.transaction, hjs.probeSchema),
+			level: level,
+		}
+	}
+
+	err := hjs.writePartitions(partitions, true, func(write func(getVal func(string) (any, error)) error) error {
+		for _, buildRecord := range buildRecords {
+			if err := write(func(field string) (any, error) { return buildRecord[field], nil }); err != nil {
+				return err
+			}
+		}
+		for hasNext := true; hasNext; {
+			if err := write(buildInput.GetVal); err != nil {
+				return err
+			}
+			var err error
+			if hasNext, err = buildInput.Next(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = hjs.writePartitions(partitions, false, func(write func(getVal func(string) (any, error)) error) error {
+		for {
+			hasNext, err := hjs.probeInput.Next()
+			if err != nil || !hasNext {
+				return err
+			}
+			if err := write(hjs.probeInput.GetVal); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	hjs.pending = append(partitions, hjs.pending...)
+	return hjs.closeProbeInput()
+}
+
+// writePartitions opens the build or probe side of each partition, and calls fill with a function copying
+// a record, whose values are returned by getVal, into the partition its join value hashes to. The records
+// with a null join value, which match no record, are dropped.
+func (hjs *HashJoinScan) writePartitions(partitions []*hashJoinPartition, buildSide bool,
+	fill func(write func(getVal func(string) (any, error)) error) error) (err error) {
+	schema, joinField := hjs.probeSchema, hjs.probeField
+	if buildSide {
+		schema, joinField = hjs.buildSchema, hjs.buildField
+	}
+	// The records are partitioned on the hash of their join value at the level they are spilled from.
+	level := partitions[0].level - 1
+
+	partitionScans := make([]scan.UpdateScan, 0, len(partitions))
+	defer func() {
+		for _, partitionScan := range partitionScans {
+			err = errors.Join(err, partitionScan.Close())
+		}
+	}()
+	for _, partition := range partitions {
+		table := partition.probe
+		if buildSide {
+			table = partition.build
+		}
+		partitionScan, err := table.Open()
+		if err != nil {
+			return err
+		}
+		partitionScans = append(partitionScans, partitionScan)
+	}
+
+	return fill(func(getVal func(string) (any, error)) error {
+		value, err := getVal(joinField)
+		if err != nil || value == nil {
+			return err
+		}
+		partitionScan := partitionScans[partitionOf(joinKey(value), level)]
+		if err := partitionScan.Insert(); err != nil {
+			return err
+		}
+		for _, field := range schema.Fields() {
+			value, err := getVal(field)
+			if err != nil {
+				return err
+			}
+			if err := partitionScan.SetVal(field, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// closeProbeInput closes the probe side of the partition being joined, if any, and removes it.
+func (hjs *HashJoinScan) closeProbeInput() error {
+	probeInput, partition := hjs.probeInput, hjs.partition
+	hjs.probeInput, hjs.partition, hjs.matches = nil, nil, nil
+	if partition == nil || probeInput == nil {
+		return nil
+	}
+	return errors.Join(probeInput.Close(), partition.probe.Remove())
+}
+
+// joinKey returns the encoding of a join value that can be used as a map key,
+// which is the same for the values that compare equal (see GroupValue#Key).
+func joinKey(value any) string {
+	var key strings.Builder
+	writeKeyValue(&key, value)
+	return key.String()
+}
+
+// GetVal returns the value of the specified field in the current record.
+func (hjs *HashJoinScan) GetVal(fieldName string) (any, error) {
+	if hjs.buildSchema.HasField(fieldName) {
+		if hjs.match < 0 || hjs.match >= len(hjs.matches) {
+			return nil, fmt.Errorf("no current record")
+		}
+		return hjs.matches[hjs.match][fieldName], nil
+	}
+	if hjs.probeInput == nil {
+		return nil, fmt.Errorf("no current record")
+	}
+	return hjs.probeInput.GetVal(fieldName)
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (hjs *HashJoinScan) GetInt(fieldName string) (int, error) {
+	return getJoined[int](hjs, fieldName, "an int")
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (hjs *HashJoinScan) GetLong(fieldName string) (int64, error) {
+	return getJoined[int64](hjs, fieldName, "a long")
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (hjs *HashJoinScan) GetShort(fieldName string) (int16, error) {
+	return getJoined[int16](hjs, fieldName, "a short")
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (hjs *HashJoinScan) GetFloat(fieldName string) (float64, error) {
+	return getJoined[float64](hjs, fieldName, "a float")
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (hjs *HashJoinScan) GetString(fieldName string) (string, error) {
+	return getJoined[string](hjs, fieldName, "a string")
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (hjs *HashJoinScan) GetBool(fieldName string) (bool, error) {
+	return getJoined[bool](hjs, fieldName, "a bool")
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (hjs *HashJoinScan) GetDate(fieldName string) (time.Time, error) {
+	return getJoined[time.Time](hjs, fieldName, "a date")
+}
+
+// getJoined returns the value of the specified field in the current record of the scan, which must be of type T,
+// or the zero value of T if the field is null.
+func getJoined[T any](hjs *HashJoinScan, fieldName, typeName string) (T, error) {
+	var zero T
+	val, err := hjs.GetVal(fieldName)
+	if err != nil || val == nil {
+		return zero, err
+	}
+	typed, ok := val.(T)
+	if !ok {
+		return zero, fmt.Errorf("field %s is not %s", fieldName, typeName)
+	}
+	return typed, nil
+}
+
+// HasField returns true if the field is in the schema of either scan.
+func (hjs *HashJoinScan) HasField(fieldName string) bool {
+	return hjs.probeSchema.HasField(fieldName) || hjs.buildSchema.HasField(fieldName)
+}
+
+// Fields returns the fields of the probe scan followed by those of the build scan.
+func (hjs *HashJoinScan) Fields() []types.FieldInfo {
+	return concatFields(hjs.probeScan.Fields(), hjs.buildScan.Fields(), true)
+}
+
+// Close closes both scans, and removes the partitions that are left to join.
+// Closing the scan again has no effect.
+func (hjs *HashJoinScan) Close() error {
+	if hjs.closed {
+		return nil
+	}
+	hjs.closed = true
+	err := errors.Join(hjs.removePending(), hjs.closeProbeInput())
+	return errors.Join(err, hjs.probeScan.Close(), hjs.buildScan.Close())
+}
+
+// removePending removes the spilled partitions that have not been joined yet.
+func (hjs *HashJoinScan) removePending() error {
+	var err error
+	for _, partition := range hjs.pending {
+		err = errors.Join(err, partition.build.Remove(), partition.probe.Remove())
+	}
+	hjs.pending = nil
+	return err
+}
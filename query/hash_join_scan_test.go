@@ -0,0 +1,199 @@
+package query
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/materialize"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupHashJoinTest returns a transaction with enough buffers for the records of a hash join to be spilled,
+// and the directory of its files.
+func setupHashJoinTest(t *testing.T) (*tx.Transaction, string) {
+	dbDir := filepath.Join(t.TempDir(), "hash_join")
+	fm, err := file.NewManager(dbDir, 800)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 12)
+	transaction := tx.NewTransaction(fm, lm, bm, concurrency.NewLockTable())
+	t.Cleanup(func() { require.NoError(t, transaction.Commit()) })
+	return transaction, dbDir
+}
+
+// keyedScan returns a scan of a temporary table holding a record for each of the keys, whose key field, of the
+// specified type, is the specified field, and whose other field numbers the records.
+func keyedScan(t *testing.T, transaction *tx.Transaction, keyField string, keyType types.SchemaType, numberField string,
+	keys []any) (scan.Scan, *record.Schema) {
+	schema := record.NewSchema()
+	schema.AddField(keyField, keyType, 10)
+	schema.AddIntField(numberField)
+
+	tempTable := materialize.NewTempTable(transaction, schema)
+	tableScan, err := tempTable.OpenRemovingOnClose()
+	require.NoError(t, err)
+	for number, key := range keys {
+		require.NoError(t, tableScan.Insert())
+		require.NoError(t, tableScan.SetVal(keyField, key))
+		require.NoError(t, tableScan.SetInt(numberField, number))
+	}
+	require.NoError(t, tableScan.BeforeFirst())
+	return tableScan, schema
+}
+
+// hashJoinedPairs reads the hash join scan, and returns the numbers of the joined records, sorted.
+func hashJoinedPairs(t *testing.T, hjs *HashJoinScan) []string {
+	var pairs []string
+	for {
+		hasNext, err := hjs.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			sort.Strings(pairs)
+			return pairs
+		}
+		left, err := hjs.GetInt("l")
+		require.NoError(t, err)
+		right, err := hjs.GetInt("r")
+		require.NoError(t, err)
+		pairs = append(pairs, fmt.Sprintf("%d-%d", left, right))
+	}
+}
+
+// expectedPairs returns the numbers of the records of both sides whose keys are equal (see types.CompareSupportedTypes),
+// sorted.
+func expectedPairs(probeKeys, buildKeys []any) []string {
+	var pairs []string
+	for l, probeKey := range probeKeys {
+		for r, buildKey := range buildKeys {
+			if probeKey != nil && buildKey != nil && types.CompareSupportedTypes(probeKey, buildKey, types.EQ) {
+				pairs = append(pairs, fmt.Sprintf("%d-%d", l, r))
+			}
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func TestHashJoinScan_InMemory(t *testing.T) {
+	transaction, _ := setupHashJoinTest(t)
+
+	probeKeys := []any{1, 2, 2, 3, nil, 5, 2}
+	buildKeys := []any{int64(2), int64(2), int64(3), int64(4), nil}
+	probe, probeSchema := keyedScan(t, transaction, "lkey", types.Integer, "l", probeKeys)
+	build, buildSchema := keyedScan(t, transaction, "rkey", types.Long, "r", buildKeys)
+	hjs, err := NewHashJoinScan(transaction, probe, probeSchema, "lkey", build, buildSchema, "rkey", 10)
+	require.NoError(t, err)
+	defer hjs.Close()
+	assert.Empty(t, hjs.pending, "the build records fit in memory")
+
+	// Ints and longs having the same value are equal, and null keys match nothing.
+	expected := []string{"1-0", "1-1", "2-0", "2-1", "3-2", "6-0", "6-1"}
+	require.Equal(t, expected, expectedPairs(probeKeys, buildKeys))
+	assert.Equal(t, expected, hashJoinedPairs(t, hjs))
+
+	require.NoError(t, hjs.BeforeFirst())
+	assert.Equal(t, expected, hashJoinedPairs(t, hjs), "the join should be read again from its first record")
+
+	// The fields of both sides are read through the typed getters.
+	require.NoError(t, hjs.BeforeFirst())
+	hasNext, err := hjs.Next()
+	require.NoError(t, err)
+	require.True(t, hasNext)
+	probeKey, err := hjs.GetInt("lkey")
+	require.NoError(t, err)
+	buildKey, err := hjs.GetLong("rkey")
+	require.NoError(t, err)
+	assert.Equal(t, int64(probeKey), buildKey)
+	_, err = hjs.GetString("rkey")
+	assert.Error(t, err)
+}
+
+func TestHashJoinScan_Spill(t *testing.T) {
+	transaction, dbDir := setupHashJoinTest(t)
+
+	var probeKeys, buildKeys []any
+	for i := 0; i < 400; i++ {
+		probeKeys = append(probeKeys, fmt.Sprintf("k%d", (i*7)%60))
+	}
+	for i := 0; i < 150; i++ {
+		buildKeys = append(buildKeys, fmt.Sprintf("k%d", (i*11)%90))
+	}
+	files := func() int {
+		entries, err := filepath.Glob(filepath.Join(dbDir, file.TempFilePrefix+"*"))
+		require.NoError(t, err)
+		return len(entries)
+	}
+
+	probe, probeSchema := keyedScan(t, transaction, "lkey", types.Varchar, "l", probeKeys)
+	build, buildSchema := keyedScan(t, transaction, "rkey", types.Varchar, "r", buildKeys)
+	baseline := files()
+
+	// Only 8 build records fit in memory, so the records of both sides are spilled,
+	// and some partitions are spilled again.
+	hjs, err := NewHashJoinScan(transaction, probe, probeSchema, "lkey", build, buildSchema, "rkey", 8)
+	require.NoError(t, err)
+	assert.NotEmpty(t, hjs.pending, "the records should be spilled into partitions")
+
+	expected := expectedPairs(probeKeys, buildKeys)
+	require.NotEmpty(t, expected)
+	assert.Equal(t, expected, hashJoinedPairs(t, hjs))
+	assert.Equal(t, baseline, files(), "the partitions should be removed once they are joined")
+
+	require.NoError(t, hjs.BeforeFirst())
+	assert.Equal(t, expected, hashJoinedPairs(t, hjs))
+
+	// Closing the scan before all the partitions are joined removes them, and the inputs, which are removed
+	// with their scans.
+	require.NoError(t, hjs.BeforeFirst())
+	for range 100 {
+		_, err = hjs.Next()
+		require.NoError(t, err)
+	}
+	require.NotEmpty(t, hjs.pending)
+	require.NoError(t, hjs.Close())
+	assert.Zero(t, files())
+}
+
+func TestHashJoinScan_KeyTypes(t *testing.T) {
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		keyType   types.SchemaType
+		probeKeys []any
+		buildKeys []any
+	}{
+		{"int", types.Integer, []any{1, 2, 3, 2}, []any{2, 3, 3, 4}},
+		{"string", types.Varchar, []any{"a", "b", "", "b"}, []any{"b", "", "c"}},
+		{"date", types.Date, []any{day, day.AddDate(0, 0, 1), day}, []any{day.In(time.FixedZone("UTC+2", 2*60*60)), day.AddDate(0, 0, 2)}},
+		{"bool", types.Boolean, []any{true, false, true}, []any{true, true, false}},
+	}
+	for _, tt := range tests {
+		for _, maxBuildRecords := range []int{100, 1} {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, maxBuildRecords), func(t *testing.T) {
+				transaction, _ := setupHashJoinTest(t)
+				probe, probeSchema := keyedScan(t, transaction, "lkey", tt.keyType, "l", tt.probeKeys)
+				build, buildSchema := keyedScan(t, transaction, "rkey", tt.keyType, "r", tt.buildKeys)
+				hjs, err := NewHashJoinScan(transaction, probe, probeSchema, "lkey", build, buildSchema, "rkey", maxBuildRecords)
+				require.NoError(t, err)
+				defer hjs.Close()
+
+				expected := expectedPairs(tt.probeKeys, tt.buildKeys)
+				require.NotEmpty(t, expected)
+				assert.Equal(t, expected, hashJoinedPairs(t, hjs))
+			})
+		}
+	}
+}
@@ -1,30 +1,73 @@
 package query
 
 import (
+	"errors"
+	"log"
+	"time"
+
 	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/table"
-	"time"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 var _ scan.Scan = (*IndexJoinScan)(nil)
 
+const (
+	// staleIndexProbeWindow is the number of records read through the index after which
+	// an index join decides whether the index is stale.
+	staleIndexProbeWindow = 32
+
+	// staleIndexMismatchRatio is the ratio of the records read through the index that must
+	// not match their probe for the index to be considered stale.
+	staleIndexMismatchRatio = 0.25
+)
+
 // IndexJoinScan is a scan that joins two scans using an index.
 // It uses the index to look up the right-hand side of the join for each row of the left-hand side.
+//
+// Each record ID read from the index is verified: records whose slot is empty, or whose indexed field
+// does not equal the probe key, are skipped, so that an out-of-date index never produces wrong rows.
+// If too many of the records read through the first staleIndexProbeWindow probes are skipped, the index
+// is considered stale: the scan reports it to its stale index handler, and joins the records again from the
+// first record of the left-hand side, reading the whole table instead of the index. The rows it output before
+// are skipped then, so that the join goes on as if it had read the table from the start; this relies on the
+// left-hand side returning its records in the same order when it is read again.
 type IndexJoinScan struct {
-	lhs       scan.Scan
-	rhs       *table.Scan
-	joinField string
-	idx       index.Index
+	lhs            scan.Scan
+	rhs            *table.Scan
+	joinField      string
+	indexField     string
+	idx            index.Index
+	indexPredicate *Predicate
+	onStaleIndex   func(probes, mismatches int)
+	searchKey      any
+	lhsPosition    int
+	probes         int
+	mismatches     int
+	emitted        map[joinedRow]bool
+	stale          bool
+	closed         bool
+}
+
+// joinedRow identifies a row output by an index join: the position of its LHS record, counted from the first one,
+// and the record ID of its RHS record. The rows output through the index before it is found stale or not are
+// recorded as such, so that they are not output again if the join restarts by reading the table.
+type joinedRow struct {
+	lhsPosition int
+	rhs         record.ID
 }
 
 // NewIndexJoinScan creates a new IndexJoinScan for the specified LHS scan and RHS index.
-func NewIndexJoinScan(lhs scan.Scan, rhs *table.Scan, joinField string, idx index.Index) (*IndexJoinScan, error) {
+// The join field is the field of the LHS scan, and the index field is the field of the RHS table that is indexed.
+func NewIndexJoinScan(lhs scan.Scan, rhs *table.Scan, joinField, indexField string, idx index.Index) (*IndexJoinScan, error) {
 	ijs := &IndexJoinScan{
-		lhs:       lhs,
-		rhs:       rhs,
-		joinField: joinField,
-		idx:       idx,
+		lhs:        lhs,
+		rhs:        rhs,
+		joinField:  joinField,
+		indexField: indexField,
+		idx:        idx,
 	}
 
 	if err := ijs.BeforeFirst(); err != nil {
@@ -34,10 +77,35 @@ func NewIndexJoinScan(lhs scan.Scan, rhs *table.Scan, joinField string, idx inde
 	return ijs, nil
 }
 
+// SetIndexPredicate sets the predicate of a partial index, which restricts the records of the table
+// that are joined once the scan no longer reads them through the index.
+func (ijs *IndexJoinScan) SetIndexPredicate(predicate *Predicate) {
+	ijs.indexPredicate = predicate
+}
+
+// SetStaleIndexHandler sets the function called, with the number of records read through the index
+// and the number of them that were skipped, when the scan finds the index to be stale.
+func (ijs *IndexJoinScan) SetStaleIndexHandler(handler func(probes, mismatches int)) {
+	ijs.onStaleIndex = handler
+}
+
+// Stale returns true if the scan has found the index to be stale.
+func (ijs *IndexJoinScan) Stale() bool {
+	return ijs.stale
+}
+
 // BeforeFirst resets the scan and positions it before the first record.
 // That is, the LHS scan will be positioned at its first record, and
 // the RHS scan will be positioned at the first record for the join value.
+// Once the scan has fallen back from the index, the RHS table is read from its start for each LHS record instead.
 func (ijs *IndexJoinScan) BeforeFirst() error {
+	// Every row is output again, so none is skipped.
+	ijs.emitted = nil
+	return ijs.restartLHS()
+}
+
+// restartLHS positions the LHS scan at its first record, and the RHS before the first record matching it.
+func (ijs *IndexJoinScan) restartLHS() error {
 	if err := ijs.lhs.BeforeFirst(); err != nil {
 		return err
 	}
@@ -45,29 +113,21 @@ func (ijs *IndexJoinScan) BeforeFirst() error {
 	if _, err := ijs.lhs.Next(); err != nil {
 		return err
 	}
-
-	return ijs.resetIndex()
+	ijs.lhsPosition = 0
+	return ijs.resetRHS()
 }
 
 // Next advances the scan to the next record.
-// The method moves to the next index record, if possible.
+// The method moves to the next matching RHS record, if possible.
 // Otherwise, it moves to the next LHS record and the
-// first index record.
+// first RHS record matching it.
 func (ijs *IndexJoinScan) Next() (bool, error) {
 	for {
-		hasNext, err := ijs.idx.Next()
+		hasNext, err := ijs.nextRHS()
 		if err != nil {
 			return false, err
 		}
-
 		if hasNext {
-			recordID, err := ijs.idx.GetDataRecordID()
-			if err != nil {
-				return false, err
-			}
-			if err := ijs.rhs.MoveToRecordID(recordID); err != nil {
-				return false, err
-			}
 			return true, nil
 		}
 
@@ -78,13 +138,148 @@ func (ijs *IndexJoinScan) Next() (bool, error) {
 		if !hasNext {
 			return false, nil
 		}
+		ijs.lhsPosition++
 
-		if err := ijs.resetIndex(); err != nil {
+		if err := ijs.resetRHS(); err != nil {
 			return false, err
 		}
 	}
 }
 
+// nextRHS moves to the next RHS record matching the current LHS record.
+// If the index is found stale meanwhile, the join restarts from the first LHS record, reading the table.
+func (ijs *IndexJoinScan) nextRHS() (bool, error) {
+	if ijs.stale {
+		return ijs.nextTableRecord()
+	}
+	for {
+		hasNext, err := ijs.idx.Next()
+		if err != nil || !hasNext {
+			return false, err
+		}
+		recordID, err := ijs.idx.GetDataRecordID()
+		if err != nil {
+			return false, err
+		}
+
+		matches, err := ijs.probe(recordID)
+		if err != nil {
+			return false, err
+		}
+		ijs.probes++
+		if !matches {
+			ijs.mismatches++
+		}
+		if ijs.probes == staleIndexProbeWindow {
+			if float64(ijs.mismatches) > staleIndexMismatchRatio*float64(ijs.probes) {
+				ijs.markStale()
+				if err := ijs.restartLHS(); err != nil {
+					return false, err
+				}
+				return ijs.nextTableRecord()
+			}
+			// The index is not stale, so the join never restarts.
+			ijs.emitted = nil
+		}
+		if matches {
+			if ijs.probes < staleIndexProbeWindow {
+				ijs.recordEmitted(recordID)
+			}
+			return true, nil
+		}
+	}
+}
+
+// recordEmitted records that the row joining the current LHS record with the specified RHS record was output.
+func (ijs *IndexJoinScan) recordEmitted(recordID *record.ID) {
+	if ijs.emitted == nil {
+		ijs.emitted = make(map[joinedRow]bool)
+	}
+	ijs.emitted[joinedRow{lhsPosition: ijs.lhsPosition, rhs: *recordID}] = true
+}
+
+// wasEmitted returns true if the row joining the current LHS record with the current RHS record was output
+// through the index before the join restarted, forgetting the row, since the restarted join reaches it once.
+func (ijs *IndexJoinScan) wasEmitted() (bool, error) {
+	if ijs.emitted == nil {
+		return false, nil
+	}
+	recordID, err := ijs.rhs.GetRecordID()
+	if err != nil {
+		return false, err
+	}
+	row := joinedRow{lhsPosition: ijs.lhsPosition, rhs: *recordID}
+	if !ijs.emitted[row] {
+		return false, nil
+	}
+	if delete(ijs.emitted, row); len(ijs.emitted) == 0 {
+		ijs.emitted = nil
+	}
+	return true, nil
+}
+
+// probe moves the RHS scan to the specified record, and returns true if it holds a record matching the search key.
+func (ijs *IndexJoinScan) probe(recordID *record.ID) (bool, error) {
+	used, err := ijs.rhs.MoveToRecordIDIfUsed(recordID)
+	if err != nil || !used {
+		return false, err
+	}
+	return ijs.matchesSearchKey()
+}
+
+// nextTableRecord moves to the next record of the RHS table matching the current LHS record,
+// reading the table instead of the index, and skipping the rows output through the index.
+func (ijs *IndexJoinScan) nextTableRecord() (bool, error) {
+	for {
+		hasNext, err := ijs.rhs.Next()
+		if err != nil || !hasNext {
+			return false, err
+		}
+		matches, err := ijs.matchesSearchKey()
+		if err != nil {
+			return false, err
+		}
+		if !matches {
+			continue
+		}
+		if ijs.indexPredicate != nil {
+			satisfied, err := ijs.indexPredicate.IsSatisfied(ijs.rhs)
+			if err != nil {
+				return false, err
+			}
+			if !satisfied {
+				continue
+			}
+		}
+		emitted, err := ijs.wasEmitted()
+		if err != nil {
+			return false, err
+		}
+		if !emitted {
+			return true, nil
+		}
+	}
+}
+
+// matchesSearchKey returns true if the indexed field of the current RHS record equals the search key.
+func (ijs *IndexJoinScan) matchesSearchKey() (bool, error) {
+	val, err := ijs.rhs.GetVal(ijs.indexField)
+	if err != nil {
+		return false, err
+	}
+	return types.CompareSupportedTypes(ijs.searchKey, val, types.EQ), nil
+}
+
+// markStale records that the index is stale, logs a warning, and calls the stale index handler.
+func (ijs *IndexJoinScan) markStale() {
+	ijs.stale = true
+	log.Printf("warning: index join on %s skipped %d of the first %d records read through the index; "+
+		"the index is stale and the join falls back to reading the table", ijs.indexField, ijs.mismatches, ijs.probes)
+	if ijs.onStaleIndex != nil {
+		ijs.onStaleIndex(ijs.probes, ijs.mismatches)
+	}
+}
+
 // GetInt returns the integer value of the specified field in the current record.
 func (ijs *IndexJoinScan) GetInt(fieldName string) (int, error) {
 	if ijs.rhs.HasField(fieldName) {
@@ -109,6 +304,14 @@ func (ijs *IndexJoinScan) GetShort(fieldName string) (int16, error) {
 	return ijs.lhs.GetShort(fieldName)
 }
 
+// GetFloat returns the float value of the specified field in the current record.
+func (ijs *IndexJoinScan) GetFloat(fieldName string) (float64, error) {
+	if ijs.rhs.HasField(fieldName) {
+		return ijs.rhs.GetFloat(fieldName)
+	}
+	return ijs.lhs.GetFloat(fieldName)
+}
+
 // GetString returns the string value of the specified field in the current record.
 func (ijs *IndexJoinScan) GetString(fieldName string) (string, error) {
 	if ijs.rhs.HasField(fieldName) {
@@ -147,18 +350,32 @@ func (ijs *IndexJoinScan) HasField(fieldName string) bool {
 	return ijs.lhs.HasField(fieldName) || ijs.rhs.HasField(fieldName)
 }
 
-// Close closes the scan and its subscans.
-func (ijs *IndexJoinScan) Close() {
-	ijs.lhs.Close()
-	ijs.rhs.Close()
+// Fields returns the fields of the left-hand scan followed by those of the table.
+func (ijs *IndexJoinScan) Fields() []types.FieldInfo {
+	return concatFields(ijs.lhs.Fields(), ijs.rhs.Fields(), true)
+}
+
+// Close closes the scan and its subscans, and the index.
+// Closing the scan again has no effect.
+func (ijs *IndexJoinScan) Close() error {
+	if ijs.closed {
+		return nil
+	}
+	ijs.closed = true
 	ijs.idx.Close()
+	return errors.Join(ijs.lhs.Close(), ijs.rhs.Close())
 }
 
-func (ijs *IndexJoinScan) resetIndex() error {
+// resetRHS positions the RHS before the first record matching the current LHS record.
+func (ijs *IndexJoinScan) resetRHS() error {
 	searchKey, err := ijs.lhs.GetVal(ijs.joinField)
 	if err != nil {
 		return err
 	}
+	ijs.searchKey = searchKey
 
+	if ijs.stale {
+		return ijs.rhs.BeforeFirst()
+	}
 	return ijs.idx.BeforeFirst(searchKey)
 }
@@ -111,7 +111,8 @@ func setupJoinTest(t *testing.T, useHashIndex bool) *joinTestSetup {
 		require.NoError(t, rhs.SetInt("budget", d.budget))
 
 		// Add to index
-		rid := rhs.GetRecordID()
+		rid, err := rhs.GetRecordID()
+		require.NoError(t, err)
 		require.NoError(t, idx.Insert(d.deptID, rid))
 	}
 
@@ -151,7 +152,7 @@ func TestIndexJoinScan_Basic(t *testing.T) {
 			defer setup.cleanup()
 
 			// Create index join scan
-			ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", setup.idx)
+			ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", "dept_id", setup.idx)
 			require.NoError(t, err)
 			defer ijs.Close()
 
@@ -235,7 +236,8 @@ func TestIndexJoinScan_NoMatches(t *testing.T) {
 	require.NoError(t, rhs.SetInt("dept_id", 1))
 	require.NoError(t, rhs.SetString("dept_name", "Existing"))
 	require.NoError(t, rhs.SetInt("budget", 100000))
-	rid := rhs.GetRecordID()
+	rid, err := rhs.GetRecordID()
+	require.NoError(t, err)
 	require.NoError(t, idx.Insert(1, rid))
 
 	// Create a new employee table with just one employee
@@ -262,7 +264,7 @@ func TestIndexJoinScan_NoMatches(t *testing.T) {
 	require.True(t, hasNext)
 
 	// Create index join scan
-	ijs, err := NewIndexJoinScan(lhs, rhs, "dept_id", idx)
+	ijs, err := NewIndexJoinScan(lhs, rhs, "dept_id", "dept_id", idx)
 	require.NoError(t, err)
 	defer ijs.Close()
 
@@ -277,7 +279,7 @@ func TestIndexJoinScan_MultipleMatches(t *testing.T) {
 	defer setup.cleanup()
 
 	// Count employees in the Engineering department (should be 2)
-	ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", setup.idx)
+	ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", "dept_id", setup.idx)
 	require.NoError(t, err)
 	defer ijs.Close()
 
@@ -303,7 +305,7 @@ func TestIndexJoinScan_FieldAccess(t *testing.T) {
 	setup := setupJoinTest(t, true) // Using hash index for this test
 	defer setup.cleanup()
 
-	ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", setup.idx)
+	ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", "dept_id", setup.idx)
 	require.NoError(t, err)
 	defer ijs.Close()
 
@@ -334,7 +336,7 @@ func TestIndexJoinScan_BeforeFirst(t *testing.T) {
 	setup := setupJoinTest(t, false) // Using btree index for this test
 	defer setup.cleanup()
 
-	ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", setup.idx)
+	ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", "dept_id", setup.idx)
 	require.NoError(t, err)
 	defer ijs.Close()
 
@@ -365,3 +367,240 @@ func TestIndexJoinScan_BeforeFirst(t *testing.T) {
 	assert.Equal(t, matchCount, secondCount)
 	assert.Equal(t, 5, matchCount) // Based on our test data
 }
+
+// makeIndexStale makes the index of the departments stale: it deletes Sales while keeping its index entry,
+// adds an entry for Marketing pointing at Engineering, and one for Engineering pointing past the end of the table.
+func makeIndexStale(t *testing.T, setup *joinTestSetup) {
+	var engineering *record.ID
+	require.NoError(t, setup.rhsScan.BeforeFirst())
+	for {
+		hasNext, err := setup.rhsScan.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		deptID, err := setup.rhsScan.GetInt("dept_id")
+		require.NoError(t, err)
+		switch deptID {
+		case 2:
+			engineering, err = setup.rhsScan.GetRecordID()
+			require.NoError(t, err)
+		case 3:
+			require.NoError(t, setup.rhsScan.Delete())
+		}
+	}
+	require.NoError(t, setup.idx.Insert(1, engineering))
+	require.NoError(t, setup.idx.Insert(2, record.NewID(99, 0)))
+}
+
+func TestIndexJoinScan_StaleIndex(t *testing.T) {
+	tests := []struct {
+		name         string
+		useHashIndex bool
+	}{
+		{"HashIndex", true},
+		{"BTreeIndex", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup := setupJoinTest(t, tt.useHashIndex)
+			defer setup.cleanup()
+
+			// Add enough employees for the join to decide whether the index is stale.
+			for i := 0; i < 60; i++ {
+				require.NoError(t, setup.lhsScan.Insert())
+				require.NoError(t, setup.lhsScan.SetInt("id", 100+i))
+				require.NoError(t, setup.lhsScan.SetString("name", "Extra"))
+				require.NoError(t, setup.lhsScan.SetInt("dept_id", i%3+1))
+			}
+
+			makeIndexStale(t, setup)
+
+			// The expected join, read from the employees directly.
+			expected := map[int]string{}
+			require.NoError(t, setup.lhsScan.BeforeFirst())
+			for {
+				hasNext, err := setup.lhsScan.Next()
+				require.NoError(t, err)
+				if !hasNext {
+					break
+				}
+				id, err := setup.lhsScan.GetInt("id")
+				require.NoError(t, err)
+				deptID, err := setup.lhsScan.GetInt("dept_id")
+				require.NoError(t, err)
+				switch deptID {
+				case 1:
+					expected[id] = "Marketing"
+				case 2:
+					expected[id] = "Engineering"
+				}
+			}
+
+			ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", "dept_id", setup.idx)
+			require.NoError(t, err)
+			defer ijs.Close()
+			staleReports := 0
+			ijs.SetStaleIndexHandler(func(probes, mismatches int) {
+				staleReports++
+				assert.Equal(t, staleIndexProbeWindow, probes)
+				assert.Greater(t, mismatches, probes/4)
+			})
+
+			joined := func() map[int]string {
+				result := map[int]string{}
+				for {
+					hasNext, err := ijs.Next()
+					require.NoError(t, err)
+					if !hasNext {
+						return result
+					}
+					id, err := ijs.GetInt("id")
+					require.NoError(t, err)
+					deptID, err := ijs.GetVal("dept_id")
+					require.NoError(t, err)
+					deptName, err := ijs.GetString("dept_name")
+					require.NoError(t, err)
+					assert.Contains(t, []any{1, 2}, deptID)
+					_, duplicate := result[id]
+					assert.False(t, duplicate, "employee %d joined twice", id)
+					result[id] = deptName
+				}
+			}
+
+			assert.Equal(t, expected, joined())
+			assert.True(t, ijs.Stale())
+			assert.Equal(t, 1, staleReports)
+
+			// The scan reads the table from the start once it has fallen back.
+			require.NoError(t, ijs.BeforeFirst())
+			assert.Equal(t, expected, joined())
+			assert.Equal(t, 1, staleReports)
+		})
+	}
+}
+
+func TestIndexJoinScan_StaleIndexRestartsJoin(t *testing.T) {
+	for _, useHashIndex := range []bool{true, false} {
+		t.Run(map[bool]string{true: "HashIndex", false: "BTreeIndex"}[useHashIndex], func(t *testing.T) {
+			setup := setupJoinTest(t, useHashIndex)
+			defer setup.cleanup()
+
+			// Support is missing from the index, so the rows of its employees are only found by reading the table.
+			require.NoError(t, setup.rhsScan.Insert())
+			require.NoError(t, setup.rhsScan.SetInt("dept_id", 4))
+			require.NoError(t, setup.rhsScan.SetString("dept_name", "Support"))
+			require.NoError(t, setup.rhsScan.SetInt("budget", 50000))
+			makeIndexStale(t, setup)
+
+			expected := map[int]string{1: "Marketing", 2: "Engineering", 3: "Engineering", 5: "Marketing"}
+			for i := 0; i < 40; i++ {
+				require.NoError(t, setup.lhsScan.Insert())
+				require.NoError(t, setup.lhsScan.SetInt("id", 100+i))
+				require.NoError(t, setup.lhsScan.SetString("name", "Extra"))
+				require.NoError(t, setup.lhsScan.SetInt("dept_id", i%4+1))
+				if name, ok := map[int]string{0: "Marketing", 1: "Engineering", 3: "Support"}[i%4]; ok {
+					expected[100+i] = name
+				}
+			}
+
+			ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", "dept_id", setup.idx)
+			require.NoError(t, err)
+			defer ijs.Close()
+
+			// The index is found stale after some rows were output through it. The join restarts by reading
+			// the table, finding the employees of Support output before, without outputting any row twice.
+			result := map[int]string{}
+			outputBeforeStale := 0
+			for {
+				hasNext, err := ijs.Next()
+				require.NoError(t, err)
+				if !hasNext {
+					break
+				}
+				if !ijs.Stale() {
+					outputBeforeStale++
+				}
+				id, err := ijs.GetInt("id")
+				require.NoError(t, err)
+				deptName, err := ijs.GetString("dept_name")
+				require.NoError(t, err)
+				_, duplicate := result[id]
+				assert.False(t, duplicate, "employee %d joined twice", id)
+				result[id] = deptName
+			}
+			assert.True(t, ijs.Stale())
+			assert.Greater(t, outputBeforeStale, 4)
+			assert.Equal(t, expected, result)
+		})
+	}
+}
+
+func TestIndexJoinScan_HealthyIndexIsNotStale(t *testing.T) {
+	setup := setupJoinTest(t, true)
+	defer setup.cleanup()
+
+	for i := 0; i < 60; i++ {
+		require.NoError(t, setup.lhsScan.Insert())
+		require.NoError(t, setup.lhsScan.SetInt("id", 100+i))
+		require.NoError(t, setup.lhsScan.SetString("name", "Extra"))
+		require.NoError(t, setup.lhsScan.SetInt("dept_id", i%3+1))
+	}
+
+	ijs, err := NewIndexJoinScan(setup.lhsScan, setup.rhsScan, "dept_id", "dept_id", setup.idx)
+	require.NoError(t, err)
+	defer ijs.Close()
+
+	count := 0
+	for {
+		hasNext, err := ijs.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		count++
+	}
+	assert.Equal(t, 65, count)
+	assert.False(t, ijs.Stale())
+}
+
+func TestIndexJoinScan_ShortJoinField(t *testing.T) {
+	for _, useHashIndex := range []bool{true, false} {
+		t.Run(map[bool]string{true: "HashIndex", false: "BTreeIndex"}[useHashIndex], func(t *testing.T) {
+			setup := setupJoinTest(t, useHashIndex)
+			defer setup.cleanup()
+
+			// The departments are indexed on integers, and joined with the shorts of the projects.
+			projectSchema := record.NewSchema()
+			projectSchema.AddStringField("project", 20)
+			projectSchema.AddShortField("dept_id")
+			projects, err := table.NewTableScan(setup.transaction, "projects", record.NewLayout(projectSchema))
+			require.NoError(t, err)
+			for i, project := range []string{"Launch", "Compiler", "Outreach", "Audit"} {
+				require.NoError(t, projects.Insert())
+				require.NoError(t, projects.SetString("project", project))
+				require.NoError(t, projects.SetShort("dept_id", int16(i%4+1)))
+			}
+
+			ijs, err := NewIndexJoinScan(projects, setup.rhsScan, "dept_id", "dept_id", setup.idx)
+			require.NoError(t, err)
+			defer ijs.Close()
+
+			var joined []string
+			for {
+				hasNext, err := ijs.Next()
+				require.NoError(t, err)
+				if !hasNext {
+					break
+				}
+				project, err := ijs.GetString("project")
+				require.NoError(t, err)
+				deptName, err := ijs.GetString("dept_name")
+				require.NoError(t, err)
+				joined = append(joined, project+"-"+deptName)
+			}
+			assert.ElementsMatch(t, []string{"Launch-Marketing", "Compiler-Engineering", "Outreach-Sales"}, joined)
+		})
+	}
+}
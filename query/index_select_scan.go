@@ -1,9 +1,12 @@
 package query
 
 import (
+	"fmt"
+
 	"github.com/JyotinderSingh/dropdb/index"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/types"
 	"time"
 )
 
@@ -11,11 +14,14 @@ var _ scan.Scan = (*IndexSelectScan)(nil)
 
 // IndexSelectScan is a scan that combines an index scan with a table scan.
 // It is used to scan the data records of a table that satisfy a selection
-// constant on an index.
+// constant on an index, or whose search keys are in a range of a range index.
 type IndexSelectScan struct {
 	tableScan *table.Scan
 	idx       index.Index
 	value     any
+	// keyRange is the range of search keys of a range scan, or nil for a scan of the selection constant.
+	keyRange *index.Range
+	closed   bool
 }
 
 // NewIndexSelectScan creates an index select scan for the specified index
@@ -32,11 +38,32 @@ func NewIndexSelectScan(tableScan *table.Scan, idx index.Index, value any) (*Ind
 	return iss, nil
 }
 
+// NewIndexRangeScan creates an index select scan for the records whose search keys are in the specified range.
+// It returns an error if the index cannot find the search keys in a range (see index.RangeIndex).
+func NewIndexRangeScan(tableScan *table.Scan, idx index.Index, keyRange index.Range) (*IndexSelectScan, error) {
+	iss := &IndexSelectScan{
+		tableScan: tableScan,
+		idx:       idx,
+		keyRange:  &keyRange,
+	}
+	if err := iss.BeforeFirst(); err != nil {
+		return nil, err
+	}
+	return iss, nil
+}
+
 // BeforeFirst positions the scan before the first record,
 // which in this case means positioning the index before
-// the first instance of the selection constant.
+// the first instance of the selection constant, or the first search key in the range.
 func (iss *IndexSelectScan) BeforeFirst() error {
-	return iss.idx.BeforeFirst(iss.value)
+	if iss.keyRange == nil {
+		return iss.idx.BeforeFirst(iss.value)
+	}
+	rangeIndex, ok := iss.idx.(index.RangeIndex)
+	if !ok {
+		return fmt.Errorf("index of type %T cannot find the search keys in a range", iss.idx)
+	}
+	return rangeIndex.BeforeFirstRange(*iss.keyRange)
 }
 
 // Next moves to the next record, which in this case means
@@ -47,14 +74,17 @@ func (iss *IndexSelectScan) BeforeFirst() error {
 // to the corresponding data record.
 func (iss *IndexSelectScan) Next() (bool, error) {
 	next, err := iss.idx.Next()
-	if !next || err != nil {
-		return next, err
+	if err != nil || !next {
+		return false, err
 	}
 	dataRID, err := iss.idx.GetDataRecordID()
 	if err != nil {
 		return false, err
 	}
-	return next, iss.tableScan.MoveToRecordID(dataRID)
+	if err := iss.tableScan.MoveToRecordID(dataRID); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // GetInt returns the integer value of the specified field in the current record.
@@ -72,6 +102,11 @@ func (iss *IndexSelectScan) GetShort(fieldName string) (int16, error) {
 	return iss.tableScan.GetShort(fieldName)
 }
 
+// GetFloat returns the float value of the specified field in the current record.
+func (iss *IndexSelectScan) GetFloat(fieldName string) (float64, error) {
+	return iss.tableScan.GetFloat(fieldName)
+}
+
 // GetString returns the string value of the specified field in the current record.
 func (iss *IndexSelectScan) GetString(fieldName string) (string, error) {
 	return iss.tableScan.GetString(fieldName)
@@ -97,8 +132,18 @@ func (iss *IndexSelectScan) HasField(fieldName string) bool {
 	return iss.tableScan.HasField(fieldName)
 }
 
+// Fields returns the fields of the underlying table scan.
+func (iss *IndexSelectScan) Fields() []types.FieldInfo {
+	return iss.tableScan.Fields()
+}
+
 // Close closes the scan by closing the index and the tablescan.
-func (iss *IndexSelectScan) Close() {
+// Closing the scan again has no effect.
+func (iss *IndexSelectScan) Close() error {
+	if iss.closed {
+		return nil
+	}
+	iss.closed = true
 	iss.idx.Close()
-	iss.tableScan.Close()
+	return iss.tableScan.Close()
 }
@@ -82,7 +82,8 @@ func setupTestWithIndex(t *testing.T, useHashIndex bool) *testSetup {
 		require.NoError(t, ts.SetInt("val", d.val))
 
 		// Add to index
-		rid := ts.GetRecordID()
+		rid, err := ts.GetRecordID()
+		require.NoError(t, err)
 		require.NoError(t, idx.Insert(d.val, rid))
 	}
 
@@ -0,0 +1,116 @@
+package query
+
+import (
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*LimitScan)(nil)
+
+// LimitScan reads the records of its input scan, skipping the first offset of them,
+// and ending after it output limit records, so that it stops reading the input scan early.
+type LimitScan struct {
+	inputScan scan.Scan
+	limit     int
+	offset    int
+	// skipped is the number of records skipped since the scan was positioned before its first record.
+	skipped int
+	// output is the number of records output since the scan was positioned before its first record.
+	output int
+	closed bool
+}
+
+// NewLimitScan creates a scan outputting at most limit records of the specified scan, after skipping
+// the first offset of them. A negative limit does not limit the number of records output.
+func NewLimitScan(inputScan scan.Scan, limit, offset int) *LimitScan {
+	return &LimitScan{inputScan: inputScan, limit: limit, offset: offset}
+}
+
+// BeforeFirst positions the scan before its first record, which is the record of the input scan
+// following the ones it skips.
+func (ls *LimitScan) BeforeFirst() error {
+	ls.skipped, ls.output = 0, 0
+	return ls.inputScan.BeforeFirst()
+}
+
+// Next moves to the next record of the input scan, skipping the first offset records of the input scan.
+// It returns false once limit records were output, without moving the input scan any further.
+func (ls *LimitScan) Next() (bool, error) {
+	if ls.limit >= 0 && ls.output >= ls.limit {
+		return false, nil
+	}
+	for ls.skipped < ls.offset {
+		hasNext, err := ls.inputScan.Next()
+		if err != nil || !hasNext {
+			return false, err
+		}
+		ls.skipped++
+	}
+	hasNext, err := ls.inputScan.Next()
+	if err != nil || !hasNext {
+		return false, err
+	}
+	ls.output++
+	return true, nil
+}
+
+// Close closes the input scan. Closing the scan again has no effect.
+func (ls *LimitScan) Close() error {
+	if ls.closed {
+		return nil
+	}
+	ls.closed = true
+	return ls.inputScan.Close()
+}
+
+// HasField returns true if the input scan has the specified field.
+func (ls *LimitScan) HasField(fieldName string) bool {
+	return ls.inputScan.HasField(fieldName)
+}
+
+// Fields returns the fields of the input scan.
+func (ls *LimitScan) Fields() []types.FieldInfo {
+	return ls.inputScan.Fields()
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (ls *LimitScan) GetInt(fieldName string) (int, error) {
+	return ls.inputScan.GetInt(fieldName)
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (ls *LimitScan) GetLong(fieldName string) (int64, error) {
+	return ls.inputScan.GetLong(fieldName)
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (ls *LimitScan) GetShort(fieldName string) (int16, error) {
+	return ls.inputScan.GetShort(fieldName)
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (ls *LimitScan) GetFloat(fieldName string) (float64, error) {
+	return ls.inputScan.GetFloat(fieldName)
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (ls *LimitScan) GetString(fieldName string) (string, error) {
+	return ls.inputScan.GetString(fieldName)
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (ls *LimitScan) GetBool(fieldName string) (bool, error) {
+	return ls.inputScan.GetBool(fieldName)
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (ls *LimitScan) GetDate(fieldName string) (time.Time, error) {
+	return ls.inputScan.GetDate(fieldName)
+}
+
+// GetVal returns the value of the specified field in the current record.
+func (ls *LimitScan) GetVal(fieldName string) (any, error) {
+	return ls.inputScan.GetVal(fieldName)
+}
@@ -0,0 +1,45 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// limitScanIDs reads the ids output by a limit scan over the ids 1 to 5.
+func limitScanIDs(t *testing.T, limit, offset int) []int {
+	rows := [][]any{{1}, {2}, {3}, {4}, {5}}
+	schema, err := ConstantSchema([]string{"id"}, rows)
+	require.NoError(t, err)
+	ls := NewLimitScan(NewConstantScan(schema, rows), limit, offset)
+	defer ls.Close()
+
+	// The scan can be read again from the start.
+	var ids []int
+	for range 2 {
+		ids = nil
+		require.NoError(t, ls.BeforeFirst())
+		for {
+			next, err := ls.Next()
+			require.NoError(t, err)
+			if !next {
+				break
+			}
+			id, err := ls.GetInt("id")
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestLimitScan(t *testing.T) {
+	assert.Equal(t, []int{1, 2}, limitScanIDs(t, 2, 0))
+	assert.Equal(t, []int{3, 4}, limitScanIDs(t, 2, 2))
+	assert.Equal(t, []int{4, 5}, limitScanIDs(t, 10, 3))
+	assert.Equal(t, []int{2, 3, 4, 5}, limitScanIDs(t, -1, 1))
+	assert.Empty(t, limitScanIDs(t, 0, 0))
+	assert.Empty(t, limitScanIDs(t, 2, 5))
+	assert.Empty(t, limitScanIDs(t, 2, 10))
+}
@@ -0,0 +1,202 @@
+package query
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*MergeJoinScan)(nil)
+
+// MergeJoinScan is a scan for the merge join operator, which joins two scans sorted in ascending order
+// on their join fields by reading them side by side. The records of the second scan having the same
+// join value as a record of the first scan are read again for each following record of the first scan
+// with that value: the position of the first of them is saved, and restored (see SortScan#SavePosition).
+// Null join values match no record, like they do in the predicate of a product.
+type MergeJoinScan struct {
+	lhs        scan.Scan
+	rhs        *SortScan
+	fieldName1 string
+	fieldName2 string
+	// joinValue is the join value of the current records, or nil before the first match.
+	joinValue any
+	closed    bool
+}
+
+// NewMergeJoinScan creates a merge join scan of the specified scans, sorted on the specified fields.
+func NewMergeJoinScan(lhs scan.Scan, rhs *SortScan, fieldName1, fieldName2 string) (*MergeJoinScan, error) {
+	mjs := &MergeJoinScan{
+		lhs:        lhs,
+		rhs:        rhs,
+		fieldName1: fieldName1,
+		fieldName2: fieldName2,
+	}
+	if err := mjs.BeforeFirst(); err != nil {
+		return nil, err
+	}
+	return mjs, nil
+}
+
+// BeforeFirst positions the scan before the first record, by positioning both scans before their first record.
+func (mjs *MergeJoinScan) BeforeFirst() error {
+	mjs.joinValue = nil
+	if err := mjs.lhs.BeforeFirst(); err != nil {
+		return err
+	}
+	return mjs.rhs.BeforeFirst()
+}
+
+// Next moves to the next record of the join.
+// If the next record of the second scan has the current join value, it is joined with the current record
+// of the first scan. Otherwise, if the next record of the first scan has the current join value, the second
+// scan is moved back to its first record having it. Otherwise, the scan whose record has the lower join value
+// is moved on until both records have the same value, which becomes the join value.
+func (mjs *MergeJoinScan) Next() (bool, error) {
+	hasMore2, err := mjs.rhs.Next()
+	if err != nil {
+		return false, err
+	}
+	if hasMore2 && mjs.joinValue != nil {
+		matches, err := mjs.hasJoinValue(mjs.rhs, mjs.fieldName2)
+		if err != nil || matches {
+			return matches, err
+		}
+	}
+
+	hasMore1, err := mjs.lhs.Next()
+	if err != nil {
+		return false, err
+	}
+	if hasMore1 && mjs.joinValue != nil {
+		matches, err := mjs.hasJoinValue(mjs.lhs, mjs.fieldName1)
+		if err != nil {
+			return false, err
+		}
+		if matches {
+			return true, mjs.rhs.RestorePosition()
+		}
+	}
+
+	for hasMore1 && hasMore2 {
+		val1, err := mjs.lhs.GetVal(mjs.fieldName1)
+		if err != nil {
+			return false, err
+		}
+		val2, err := mjs.rhs.GetVal(mjs.fieldName2)
+		if err != nil {
+			return false, err
+		}
+
+		switch comparison := compareValues(val1, val2); {
+		case val1 == nil || comparison < 0:
+			hasMore1, err = mjs.lhs.Next()
+		case val2 == nil || comparison > 0:
+			hasMore2, err = mjs.rhs.Next()
+		default:
+			if err := mjs.rhs.SavePosition(); err != nil {
+				return false, err
+			}
+			mjs.joinValue = val2
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// hasJoinValue returns true if the specified field of the current record of the scan has the current join value.
+func (mjs *MergeJoinScan) hasJoinValue(s scan.Scan, fieldName string) (bool, error) {
+	val, err := s.GetVal(fieldName)
+	if err != nil {
+		return false, err
+	}
+	return val != nil && compareValues(val, mjs.joinValue) == 0, nil
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (mjs *MergeJoinScan) GetInt(fieldName string) (int, error) {
+	if mjs.lhs.HasField(fieldName) {
+		return mjs.lhs.GetInt(fieldName)
+	}
+	return mjs.rhs.GetInt(fieldName)
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (mjs *MergeJoinScan) GetLong(fieldName string) (int64, error) {
+	if mjs.lhs.HasField(fieldName) {
+		return mjs.lhs.GetLong(fieldName)
+	}
+	return mjs.rhs.GetLong(fieldName)
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (mjs *MergeJoinScan) GetShort(fieldName string) (int16, error) {
+	if mjs.lhs.HasField(fieldName) {
+		return mjs.lhs.GetShort(fieldName)
+	}
+	return mjs.rhs.GetShort(fieldName)
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (mjs *MergeJoinScan) GetFloat(fieldName string) (float64, error) {
+	if mjs.lhs.HasField(fieldName) {
+		return mjs.lhs.GetFloat(fieldName)
+	}
+	return mjs.rhs.GetFloat(fieldName)
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (mjs *MergeJoinScan) GetString(fieldName string) (string, error) {
+	if mjs.lhs.HasField(fieldName) {
+		return mjs.lhs.GetString(fieldName)
+	}
+	return mjs.rhs.GetString(fieldName)
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (mjs *MergeJoinScan) GetBool(fieldName string) (bool, error) {
+	if mjs.lhs.HasField(fieldName) {
+		return mjs.lhs.GetBool(fieldName)
+	}
+	return mjs.rhs.GetBool(fieldName)
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (mjs *MergeJoinScan) GetDate(fieldName string) (time.Time, error) {
+	if mjs.lhs.HasField(fieldName) {
+		return mjs.lhs.GetDate(fieldName)
+	}
+	return mjs.rhs.GetDate(fieldName)
+}
+
+// GetVal returns the value of the specified field in the current record.
+func (mjs *MergeJoinScan) GetVal(fieldName string) (any, error) {
+	if mjs.lhs.HasField(fieldName) {
+		return mjs.lhs.GetVal(fieldName)
+	}
+	return mjs.rhs.GetVal(fieldName)
+}
+
+// HasField returns true if the field is in the schema of either scan.
+func (mjs *MergeJoinScan) HasField(fieldName string) bool {
+	return mjs.lhs.HasField(fieldName) || mjs.rhs.HasField(fieldName)
+}
+
+// Fields returns the fields of the first scan followed by those of the second scan.
+func (mjs *MergeJoinScan) Fields() []types.FieldInfo {
+	return concatFields(mjs.lhs.Fields(), mjs.rhs.Fields(), false)
+}
+
+// Close closes both scans.
+// Closing the scan again has no effect.
+func (mjs *MergeJoinScan) Close() error {
+	if mjs.closed {
+		return nil
+	}
+	mjs.closed = true
+	return errors.Join(mjs.lhs.Close(), mjs.rhs.Close())
+}
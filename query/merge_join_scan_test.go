@@ -0,0 +1,126 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/materialize"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sortedKeysScan returns a sort scan of runs holding a record for each of the keys of the run, in order,
+// whose key field is the specified field, and whose other field numbers the records across the runs.
+func sortedKeysScan(t *testing.T, transaction *tx.Transaction, keyField, numberField string, runKeys ...[]any) *SortScan {
+	schema := record.NewSchema()
+	schema.AddIntField(keyField)
+	schema.AddIntField(numberField)
+
+	var runs []*materialize.TempTable
+	number := 0
+	for _, keys := range runKeys {
+		run := materialize.NewTempTable(transaction, schema)
+		runScan, err := run.Open()
+		require.NoError(t, err)
+		for _, key := range keys {
+			require.NoError(t, runScan.Insert())
+			require.NoError(t, runScan.SetVal(keyField, key))
+			require.NoError(t, runScan.SetInt(numberField, number))
+			number++
+		}
+		require.NoError(t, runScan.Close())
+		runs = append(runs, run)
+	}
+
+	sortScan, err := NewSortScan(runs, NewRecordComparator([]string{keyField}))
+	require.NoError(t, err)
+	return sortScan
+}
+
+// joinedPairs reads the merge join scan, and returns the numbers of the joined records, in order.
+func joinedPairs(t *testing.T, mjs *MergeJoinScan) []string {
+	var pairs []string
+	for {
+		hasNext, err := mjs.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			return pairs
+		}
+		left, err := mjs.GetInt("l")
+		require.NoError(t, err)
+		right, err := mjs.GetInt("r")
+		require.NoError(t, err)
+		key1, err := mjs.GetVal("lkey")
+		require.NoError(t, err)
+		key2, err := mjs.GetVal("rkey")
+		require.NoError(t, err)
+		require.Equal(t, key1, key2)
+		pairs = append(pairs, fmt.Sprintf("%d-%d", left, right))
+	}
+}
+
+func TestMergeJoinScan_ManyToMany(t *testing.T) {
+	transaction, _, cleanup := createTransactionAndLayout(t)
+	defer cleanup()
+
+	// Both sides have several records with the keys 2 and 5, and keys that the other side does not have.
+	lhs := sortedKeysScan(t, transaction, "lkey", "l", []any{1, 2, 2, 3, 5, 5, 5, 8})
+	rhs := sortedKeysScan(t, transaction, "rkey", "r", []any{2, 2, 2, 4, 5, 5, 6, 8})
+	mjs, err := NewMergeJoinScan(lhs, rhs, "lkey", "rkey")
+	require.NoError(t, err)
+	defer mjs.Close()
+
+	// Each record of the left side is joined with every record of the right side having its key.
+	expected := []string{
+		"1-0", "1-1", "1-2", "2-0", "2-1", "2-2",
+		"4-4", "4-5", "5-4", "5-5", "6-4", "6-5",
+		"7-7",
+	}
+	assert.Equal(t, expected, joinedPairs(t, mjs))
+
+	require.NoError(t, mjs.BeforeFirst())
+	assert.Equal(t, expected, joinedPairs(t, mjs), "the join should be read again from its first record")
+}
+
+func TestMergeJoinScan_SeveralRuns(t *testing.T) {
+	transaction, _, cleanup := createTransactionAndLayout(t)
+	defer cleanup()
+
+	// The records of the second side having the key 2 are spread across its runs, so going back to the first
+	// of them moves every run back.
+	lhs := sortedKeysScan(t, transaction, "lkey", "l", []any{2, 2, 3})
+	rhs := sortedKeysScan(t, transaction, "rkey", "r", []any{1, 2, 3}, []any{2, 2, 4})
+	mjs, err := NewMergeJoinScan(lhs, rhs, "lkey", "rkey")
+	require.NoError(t, err)
+	defer mjs.Close()
+
+	assert.Equal(t, []string{"0-1", "0-3", "0-4", "1-1", "1-3", "1-4", "2-2"}, joinedPairs(t, mjs))
+}
+
+func TestMergeJoinScan_NullKeysMatchNothing(t *testing.T) {
+	transaction, _, cleanup := createTransactionAndLayout(t)
+	defer cleanup()
+
+	lhs := sortedKeysScan(t, transaction, "lkey", "l", []any{nil, nil, 1, 3})
+	rhs := sortedKeysScan(t, transaction, "rkey", "r", []any{nil, 1, 1, 2})
+	mjs, err := NewMergeJoinScan(lhs, rhs, "lkey", "rkey")
+	require.NoError(t, err)
+	defer mjs.Close()
+
+	assert.Equal(t, []string{"2-1", "2-2"}, joinedPairs(t, mjs))
+}
+
+func TestMergeJoinScan_NoMatches(t *testing.T) {
+	transaction, _, cleanup := createTransactionAndLayout(t)
+	defer cleanup()
+
+	lhs := sortedKeysScan(t, transaction, "lkey", "l", []any{1, 3, 5})
+	rhs := sortedKeysScan(t, transaction, "rkey", "r", []any{2, 4})
+	mjs, err := NewMergeJoinScan(lhs, rhs, "lkey", "rkey")
+	require.NoError(t, err)
+	defer mjs.Close()
+
+	assert.Empty(t, joinedPairs(t, mjs))
+}
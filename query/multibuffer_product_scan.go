@@ -0,0 +1,196 @@
+package query
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/materialize"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*MultibufferProductScan)(nil)
+
+// MultibufferProductScan is the scan of the product of two inputs, one of which has been materialized into a
+// temporary table. The table is read in chunks of consecutive blocks, each pinned while it is read (see ChunkScan),
+// and the other input is read once per chunk, each of its records being combined with the records of the chunk.
+// The other input is thus read once per chunk rather than once per record of the table, as a ProductScan does.
+type MultibufferProductScan struct {
+	transaction *tx.Transaction
+	tempTable   *materialize.TempTable
+	otherScan   scan.Scan
+	chunkBlocks int
+	// chunkFirst is true if the table holds the first input of the product, whose fields come first.
+	chunkFirst bool
+	// numBlocks is the number of blocks of the table, and nextBlock is the first block of the next chunk.
+	numBlocks int
+	nextBlock int
+	// chunk is the scan of the current chunk, or nil before the first chunk and after the last one.
+	chunk *ChunkScan
+	// onOther is true if the other scan is positioned on a record.
+	onOther bool
+	closed  bool
+}
+
+// NewMultibufferProductScan creates the scan of the product of the records of the temporary table, read in chunks
+// of the specified number of blocks, and of the other scan. The scan owns the table, which it removes when it is
+// closed. chunkFirst is true if the table holds the first input of the product.
+func NewMultibufferProductScan(transaction *tx.Transaction, tempTable *materialize.TempTable, otherScan scan.Scan,
+	chunkBlocks int, chunkFirst bool) (*MultibufferProductScan, error) {
+	mps := &MultibufferProductScan{
+		transaction: transaction,
+		tempTable:   tempTable,
+		otherScan:   otherScan,
+		chunkBlocks: max(chunkBlocks, 1),
+		chunkFirst:  chunkFirst,
+	}
+	numBlocks, err := table.BlockCount(transaction, tempTable.TableName())
+	if err != nil {
+		return nil, err
+	}
+	mps.numBlocks = numBlocks
+	return mps, nil
+}
+
+// BeforeFirst positions the scan before its first record, which is in the first chunk of the table.
+func (mps *MultibufferProductScan) BeforeFirst() error {
+	mps.nextBlock, mps.onOther = 0, false
+	return mps.closeChunk()
+}
+
+// Next moves to the next record of the current chunk, or else to the next record of the other scan and the
+// first record of the chunk. Once the other scan has no more records, it moves on to the next chunk,
+// reading the other scan again from its first record. It returns false after the last chunk.
+func (mps *MultibufferProductScan) Next() (bool, error) {
+	for {
+		if mps.chunk != nil && mps.onOther {
+			hasNext, err := mps.chunk.Next()
+			if err != nil || hasNext {
+				return hasNext, err
+			}
+			if mps.onOther, err = mps.otherScan.Next(); err != nil {
+				return false, err
+			}
+			if mps.onOther {
+				if err := mps.chunk.BeforeFirst(); err != nil {
+					return false, err
+				}
+				continue
+			}
+		}
+		hasChunk, err := mps.nextChunk()
+		if err != nil || !hasChunk {
+			return false, err
+		}
+	}
+}
+
+// nextChunk moves to the next chunk of the table, if any, and positions the other scan on its first record.
+// It returns false if there are no more chunks, or if the other scan has no record.
+func (mps *MultibufferProductScan) nextChunk() (bool, error) {
+	if err := mps.closeChunk(); err != nil {
+		return false, err
+	}
+	if mps.nextBlock >= mps.numBlocks {
+		return false, nil
+	}
+	endBlock := min(mps.nextBlock+mps.chunkBlocks, mps.numBlocks)
+	chunk, err := NewChunkScan(mps.transaction, mps.tempTable.TableName(), mps.tempTable.GetLayout(), mps.nextBlock, endBlock)
+	if err != nil {
+		return false, err
+	}
+	mps.chunk, mps.nextBlock = chunk, endBlock
+
+	if err := mps.otherScan.BeforeFirst(); err != nil {
+		return false, err
+	}
+	if mps.onOther, err = mps.otherScan.Next(); err != nil {
+		return false, err
+	}
+	return mps.onOther, nil
+}
+
+// closeChunk closes the scan of the current chunk, if any, unpinning its blocks.
+func (mps *MultibufferProductScan) closeChunk() error {
+	if mps.chunk == nil {
+		return nil
+	}
+	err := mps.chunk.Close()
+	mps.chunk = nil
+	return err
+}
+
+// Close closes the scan of the current chunk and the other scan, and removes the temporary table.
+// Closing the scan again has no effect.
+func (mps *MultibufferProductScan) Close() error {
+	if mps.closed {
+		return nil
+	}
+	mps.closed = true
+	return errors.Join(mps.closeChunk(), mps.otherScan.Close(), mps.tempTable.Remove())
+}
+
+// scanOf returns the scan the specified field is read from: the scan of the first input of the product having it.
+func (mps *MultibufferProductScan) scanOf(fieldName string) scan.Scan {
+	inTable := mps.tempTable.GetLayout().Schema().HasField(fieldName)
+	if inTable && (mps.chunkFirst || !mps.otherScan.HasField(fieldName)) {
+		return mps.chunk
+	}
+	return mps.otherScan
+}
+
+// HasField returns true if the specified field is in either input of the product.
+func (mps *MultibufferProductScan) HasField(fieldName string) bool {
+	return mps.tempTable.GetLayout().Schema().HasField(fieldName) || mps.otherScan.HasField(fieldName)
+}
+
+// Fields returns the fields of the first input of the product followed by those of the second one.
+func (mps *MultibufferProductScan) Fields() []types.FieldInfo {
+	tableFields := mps.tempTable.GetLayout().Schema().FieldInfos()
+	if mps.chunkFirst {
+		return concatFields(tableFields, mps.otherScan.Fields(), false)
+	}
+	return concatFields(mps.otherScan.Fields(), tableFields, false)
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (mps *MultibufferProductScan) GetInt(fieldName string) (int, error) {
+	return mps.scanOf(fieldName).GetInt(fieldName)
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (mps *MultibufferProductScan) GetLong(fieldName string) (int64, error) {
+	return mps.scanOf(fieldName).GetLong(fieldName)
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (mps *MultibufferProductScan) GetShort(fieldName string) (int16, error) {
+	return mps.scanOf(fieldName).GetShort(fieldName)
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (mps *MultibufferProductScan) GetFloat(fieldName string) (float64, error) {
+	return mps.scanOf(fieldName).GetFloat(fieldName)
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (mps *MultibufferProductScan) GetString(fieldName string) (string, error) {
+	return mps.scanOf(fieldName).GetString(fieldName)
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (mps *MultibufferProductScan) GetBool(fieldName string) (bool, error) {
+	return mps.scanOf(fieldName).GetBool(fieldName)
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (mps *MultibufferProductScan) GetDate(fieldName string) (time.Time, error) {
+	return mps.scanOf(fieldName).GetDate(fieldName)
+}
+
+// GetVal returns the value of the specified field in the current record.
+func (mps *MultibufferProductScan) GetVal(fieldName string) (any, error) {
+	return mps.scanOf(fieldName).GetVal(fieldName)
+}
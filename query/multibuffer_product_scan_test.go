@@ -0,0 +1,92 @@
+package query
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/materialize"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+func TestMultibufferProductScan(t *testing.T) {
+	transaction, dbDir := setupHashJoinTest(t)
+
+	// The 50 records of the chunked table fill several blocks, which are read in chunks of two.
+	schema := record.NewSchema()
+	schema.AddIntField("l")
+	schema.AddStringField("pad", 60)
+	tempTable := materialize.NewTempTable(transaction, schema)
+	tableScan, err := tempTable.Open()
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, tableScan.Insert())
+		require.NoError(t, tableScan.SetInt("l", i))
+		require.NoError(t, tableScan.SetString("pad", "x"))
+	}
+	require.NoError(t, tableScan.Close())
+	blocks, err := table.BlockCount(transaction, tempTable.TableName())
+	require.NoError(t, err)
+	require.Greater(t, blocks, 4)
+
+	otherKeys := []any{0, 1, 2, 3}
+	other, _ := keyedScan(t, transaction, "key", types.Integer, "r", otherKeys)
+	mps, err := NewMultibufferProductScan(transaction, tempTable, other, 2, false)
+	require.NoError(t, err)
+
+	var expected []string
+	for l := 0; l < 50; l++ {
+		for r := range otherKeys {
+			expected = append(expected, fmt.Sprintf("%d-%d", l, r))
+		}
+	}
+	sort.Strings(expected)
+	productPairs := func() []string {
+		var pairs []string
+		maxPins := 0
+		for {
+			hasNext, err := mps.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				sort.Strings(pairs)
+				// A chunk of two blocks is pinned along with the blocks of the other scan.
+				assert.LessOrEqual(t, maxPins, 2+2)
+				return pairs
+			}
+			maxPins = max(maxPins, transaction.PinnedCount())
+			left, err := mps.GetInt("l")
+			require.NoError(t, err)
+			right, err := mps.GetVal("r")
+			require.NoError(t, err)
+			pairs = append(pairs, fmt.Sprintf("%d-%d", left, right))
+		}
+	}
+	assert.Equal(t, expected, productPairs())
+	require.NoError(t, mps.BeforeFirst())
+	assert.Equal(t, expected, productPairs(), "the product should be read again from its first record")
+
+	// The fields of the other input follow those of the chunked table only if it is the first input.
+	assert.Equal(t, []string{"key", "r", "l", "pad"}, fieldNames(mps.Fields()))
+	assert.True(t, mps.HasField("pad"))
+	assert.False(t, mps.HasField("missing"))
+
+	// Closing the scan unpins its chunk and removes the table.
+	require.NoError(t, mps.Close())
+	assert.Zero(t, transaction.PinnedCount())
+	assert.NoFileExists(t, filepath.Join(dbDir, tempTable.TableName()+".tbl"))
+}
+
+// fieldNames returns the names of the fields.
+func fieldNames(fields []types.FieldInfo) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+	return names
+}
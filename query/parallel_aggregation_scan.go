@@ -0,0 +1,177 @@
+package query
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = &ParallelAggregationScan{}
+
+// ErrTooManyGroups is returned by NewParallelAggregationScan when the groups of its inputs do not fit in memory.
+var ErrTooManyGroups = errors.New("too many groups to aggregate in parallel")
+
+// errWorkerStopped is returned by a worker that stopped reading its input because another worker failed.
+var errWorkerStopped = errors.New("worker stopped")
+
+// ParallelAggregationScan is the scan class for the GROUP BY operation over several inputs read in parallel,
+// such as the contiguous block ranges of a table. Each input is read and aggregated by a goroutine of its own,
+// with its own clones of the aggregation functions, and the partial aggregates of each group are then merged
+// (see functions.AggregationFunction#Merge) in the order of the inputs.
+//
+// The groups are output in the order in which their first record was read, taking the records of the inputs in
+// their order: when the inputs are the consecutive parts of a table, that is the order in which a HashAggregationScan
+// reading the whole table outputs them. Unlike a HashAggregationScan, it never spills: the groups are all kept in
+// memory, and it fails with ErrTooManyGroups if there are more than maxGroups of them.
+type ParallelAggregationScan struct {
+	// The merged groups are read like the groups in memory of a hash aggregation scan, which has no input.
+	*HashAggregationScan
+	inputFields []types.FieldInfo
+}
+
+// NewParallelAggregationScan opens the inputs returned by the specified functions and aggregates them in parallel,
+// each in its own goroutine. The functions are called by the goroutines, and each input is closed once it is read.
+// The input fields are the fields of the records of each input, and the aggregation functions are only used as
+// prototypes: each group of each input gets its own clones.
+func NewParallelAggregationScan(inputs []func() (scan.Scan, error), inputFields []types.FieldInfo, groupFields []string, aggregationFunctions []functions.AggregationFunction, maxGroups int) (*ParallelAggregationScan, error) {
+	maxGroups = max(maxGroups, 1)
+	partials := make([][]*hashGroup, len(inputs))
+	errs := make([]error, len(inputs))
+	var failed atomic.Bool
+
+	var wg sync.WaitGroup
+	for i, open := range inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			partials[i], errs[i] = aggregateInput(open, groupFields, aggregationFunctions, maxGroups, &failed)
+			if errs[i] != nil {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errWorkerStopped) {
+			return nil, err
+		}
+	}
+
+	groups, err := mergeGroups(partials, maxGroups)
+	if err != nil {
+		return nil, err
+	}
+	return &ParallelAggregationScan{
+		HashAggregationScan: &HashAggregationScan{
+			groupFields:          groupFields,
+			aggregationFunctions: aggregationFunctions,
+			maxGroups:            maxGroups,
+			groups:               groups,
+		},
+		inputFields: inputFields,
+	}, nil
+}
+
+// aggregateInput opens an input and aggregates its records into at most maxGroups groups, in the order in which
+// their first record was read. It stops early once another input has failed.
+func aggregateInput(open func() (scan.Scan, error), groupFields []string, aggregationFunctions []functions.AggregationFunction, maxGroups int, failed *atomic.Bool) (result []*hashGroup, err error) {
+	input, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = errors.Join(err, input.Close())
+	}()
+
+	groups := make(map[string]*hashGroup)
+	for {
+		if failed.Load() {
+			return nil, errWorkerStopped
+		}
+		next, err := input.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !next {
+			return result, nil
+		}
+
+		groupValue, err := NewGroupValue(input, groupFields)
+		if err != nil {
+			return nil, err
+		}
+		key := groupValue.Key()
+
+		if group, ok := groups[key]; ok {
+			for _, function := range group.functions {
+				if err := function.ProcessNext(input); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if len(groups) >= maxGroups {
+			return nil, ErrTooManyGroups
+		}
+		group := &hashGroup{groupValue: groupValue}
+		for _, prototype := range aggregationFunctions {
+			function := prototype.Clone()
+			if err := function.ProcessFirst(input); err != nil {
+				return nil, err
+			}
+			group.functions = append(group.functions, function)
+		}
+		groups[key] = group
+		result = append(result, group)
+	}
+}
+
+// mergeGroups merges the groups aggregated from each input, in the order of the inputs.
+// The partial aggregates of a group read from several inputs are merged into those of the first input holding it.
+func mergeGroups(partials [][]*hashGroup, maxGroups int) ([]*hashGroup, error) {
+	groups := make(map[string]*hashGroup)
+	var result []*hashGroup
+	for _, partial := range partials {
+		for _, group := range partial {
+			key := group.groupValue.Key()
+			merged, ok := groups[key]
+			if !ok {
+				if len(groups) >= maxGroups {
+					return nil, ErrTooManyGroups
+				}
+				groups[key] = group
+				result = append(result, group)
+				continue
+			}
+			for i, function := range merged.functions {
+				if err := function.Merge(group.functions[i]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// BeforeFirst positions the scan before the first group.
+func (s *ParallelAggregationScan) BeforeFirst() error {
+	s.nextGroup = 0
+	s.currentGroup = nil
+	return nil
+}
+
+// Close does nothing, since the inputs are closed once they are read.
+func (s *ParallelAggregationScan) Close() error {
+	return nil
+}
+
+// Fields returns the grouping fields followed by the fields created by the aggregation functions.
+func (s *ParallelAggregationScan) Fields() []types.FieldInfo {
+	return aggregationFields(s.inputFields, s.groupFields, s.aggregationFunctions)
+}
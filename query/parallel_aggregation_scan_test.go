@@ -0,0 +1,99 @@
+package query_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/table"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+)
+
+func TestParallelAggregationScan(t *testing.T) {
+	fm, err := file.NewManager(filepath.Join(t.TempDir(), "parallel_aggregation"), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	schema := record.NewSchema()
+	schema.AddStringField("first", 5)
+	schema.AddStringField("second", 5)
+	schema.AddIntField("amount")
+	layout := record.NewLayout(schema)
+
+	// Spread the records of the 40 groups across the whole table.
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	ts, err := table.NewTableScan(transaction, "parallel_aggregation_table", layout)
+	require.NoError(t, err)
+	expected := make(map[string][2]int64)
+	for i := 0; i < 300; i++ {
+		group := (i * 7) % 40
+		first, second := fmt.Sprintf("g%d", group%8), fmt.Sprintf("h%d", group/8)
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetString("first", first))
+		require.NoError(t, ts.SetString("second", second))
+		require.NoError(t, ts.SetInt("amount", i))
+
+		key := first + "/" + second
+		expected[key] = [2]int64{expected[key][0] + 1, expected[key][1] + int64(i)}
+	}
+	require.NoError(t, ts.Close())
+	require.NoError(t, transaction.Commit())
+
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	defer func() { require.NoError(t, transaction.Commit()) }()
+	blocks, err := table.BlockCount(transaction, "parallel_aggregation_table")
+	require.NoError(t, err)
+	require.Greater(t, blocks, 3)
+
+	// Each input reads a range of blocks in a transaction of its own.
+	ranges := [][2]int{{0, 1}, {1, blocks / 2}, {blocks / 2, blocks}}
+	var inputs []func() (scan.Scan, error)
+	var transactions []*tx.Transaction
+	for _, blockRange := range ranges {
+		worker := tx.NewReadOnlyTransaction(fm, lm, bm, lt)
+		transactions = append(transactions, worker)
+		inputs = append(inputs, func() (scan.Scan, error) {
+			return table.NewBlockRangeScan(worker, "parallel_aggregation_table", layout, blockRange[0], blockRange[1])
+		})
+	}
+	defer func() {
+		for _, worker := range transactions {
+			require.NoError(t, worker.Commit())
+		}
+	}()
+
+	aggregates := []functions.AggregationFunction{functions.NewCountFunction("amount"), functions.NewSumFunction("amount")}
+	s, err := query.NewParallelAggregationScan(inputs, schema.FieldInfos(), []string{"first", "second"}, aggregates, 100)
+	require.NoError(t, err)
+	assert.Equal(t, expected, collectGroups(t, s.HashAggregationScan))
+
+	// The scan can be read again from the start.
+	require.NoError(t, s.BeforeFirst())
+	assert.Equal(t, expected, collectGroups(t, s.HashAggregationScan))
+	var fields []string
+	for _, field := range s.Fields() {
+		fields = append(fields, field.Name)
+	}
+	assert.Equal(t, []string{"first", "second", "countOfamount", "sumOfamount"}, fields)
+	require.NoError(t, s.Close())
+	assert.Equal(t, 0, bm.Stats().Pinned, "every input must be closed once it is read")
+
+	// The groups must fit in memory.
+	_, err = query.NewParallelAggregationScan(inputs, schema.FieldInfos(), []string{"first", "second"}, aggregates, 39)
+	assert.True(t, errors.Is(err, query.ErrTooManyGroups))
+}
@@ -1,14 +1,21 @@
 package query
 
 import (
+	"slices"
+	"strings"
+
+	"github.com/JyotinderSingh/dropdb/index"
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/types"
 )
 
+// Predicate is the conjunction of terms and of disjunctions of predicates, such as
+// "a = 1 and (b = 2 or c = 3)". The empty predicate corresponds to TRUE.
 type Predicate struct {
-	terms []*Term
+	terms        []*Term
+	disjunctions []*Disjunction
 }
 
 // NewPredicate creates an empty predicate, corresponding to TRUE.
@@ -21,21 +28,139 @@ func NewPredicateFromTerm(term *Term) *Predicate {
 	return &Predicate{terms: []*Term{term}}
 }
 
+// NewPredicateFromDisjunction creates a new predicate from the specified disjunction.
+func NewPredicateFromDisjunction(disjunction *Disjunction) *Predicate {
+	return &Predicate{terms: []*Term{}, disjunctions: []*Disjunction{disjunction}}
+}
+
 // ConjoinWith modifies the predicate to be the conjunction of itself and the specified predicate.
 func (p *Predicate) ConjoinWith(other *Predicate) {
 	p.terms = append(p.terms, other.terms...)
+	p.disjunctions = append(p.disjunctions, other.disjunctions...)
 }
 
 // IsSatisfied returns true if the predicate evaluates to true with respect to the specified inputScan.
-func (p *Predicate) IsSatisfied(inputScan scan.Scan) bool {
+// It returns the error of the scan if the value of a field the predicate reads cannot be read,
+// rather than treating the record as not satisfying the predicate.
+func (p *Predicate) IsSatisfied(inputScan scan.Scan) (bool, error) {
+	for _, term := range p.terms {
+		satisfied, err := term.IsSatisfied(inputScan)
+		if err != nil || !satisfied {
+			return false, err
+		}
+	}
+	for _, disjunction := range p.disjunctions {
+		satisfied, err := disjunction.IsSatisfied(inputScan)
+		if err != nil || !satisfied {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Fields returns the names of the fields the predicate reads, those of its terms first,
+// in the order they first appear, without duplicates.
+func (p *Predicate) Fields() []string {
+	if p == nil {
+		return nil
+	}
+	var fields []string
+	appendFields := func(conjunctFields []string) {
+		for _, field := range conjunctFields {
+			if !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	for _, term := range p.terms {
+		appendFields(term.Fields())
+	}
+	for _, disjunction := range p.disjunctions {
+		appendFields(disjunction.Fields())
+	}
+	return fields
+}
+
+// Terms returns the terms of the predicate, which are conjoined with its disjunctions.
+func (p *Predicate) Terms() []*Term {
+	if p == nil {
+		return nil
+	}
+	return p.terms
+}
+
+// Disjunctions returns the disjunctions of the predicate, which are conjoined with its terms.
+func (p *Predicate) Disjunctions() []*Disjunction {
+	if p == nil {
+		return nil
+	}
+	return p.disjunctions
+}
+
+// Conjuncts returns each term and disjunction of the predicate as a predicate of its own,
+// terms first, so that the predicate can be split into parts that are applied separately.
+func (p *Predicate) Conjuncts() []*Predicate {
+	if p == nil {
+		return nil
+	}
+	conjuncts := make([]*Predicate, 0, len(p.terms)+len(p.disjunctions))
+	for _, term := range p.terms {
+		conjuncts = append(conjuncts, NewPredicateFromTerm(term))
+	}
+	for _, disjunction := range p.disjunctions {
+		conjuncts = append(conjuncts, NewPredicateFromDisjunction(disjunction))
+	}
+	return conjuncts
+}
+
+// AppliesTo returns true if every term and disjunction of the predicate applies to the specified schema.
+func (p *Predicate) AppliesTo(schema *record.Schema) bool {
 	for _, term := range p.terms {
-		if !term.IsSatisfied(inputScan) {
+		if !term.AppliesTo(schema) {
+			return false
+		}
+	}
+	for _, disjunction := range p.disjunctions {
+		if !disjunction.AppliesTo(schema) {
 			return false
 		}
 	}
 	return true
 }
 
+// CoerceConstants converts the constants compared with fields of the schema to the fields' types.
+// The predicate is modified in place; see Term.CoerceConstants.
+func (p *Predicate) CoerceConstants(schema *record.Schema) {
+	if p == nil {
+		return
+	}
+	for _, term := range p.terms {
+		term.CoerceConstants(schema)
+	}
+	for _, disjunction := range p.disjunctions {
+		disjunction.CoerceConstants(schema)
+	}
+}
+
+// CheckTypes returns an error wrapping ErrTypeMismatch if a term of the predicate
+// compares values of incompatible types; see Term.CheckTypes.
+func (p *Predicate) CheckTypes(schema *record.Schema) error {
+	if p == nil {
+		return nil
+	}
+	for _, term := range p.terms {
+		if err := term.CheckTypes(schema); err != nil {
+			return err
+		}
+	}
+	for _, disjunction := range p.disjunctions {
+		if err := disjunction.CheckTypes(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ReductionFactor calculates the extent to which selecting on the
 // predicate reduces the number of records output by a query.
 // For example, if the reduction factor is 2, then the predicate
@@ -46,6 +171,9 @@ func (p *Predicate) ReductionFactor(queryPlan plan.Plan) int {
 	for _, term := range p.terms {
 		factor *= term.ReductionFactor(queryPlan)
 	}
+	for _, disjunction := range p.disjunctions {
+		factor *= disjunction.ReductionFactor(queryPlan)
+	}
 	return factor
 }
 
@@ -57,8 +185,13 @@ func (p *Predicate) SelectSubPredicate(schema *record.Schema) *Predicate {
 			result.terms = append(result.terms, term)
 		}
 	}
+	for _, disjunction := range p.disjunctions {
+		if disjunction.AppliesTo(schema) {
+			result.disjunctions = append(result.disjunctions, disjunction)
+		}
+	}
 
-	if len(result.terms) == 0 {
+	if result.isEmpty() {
 		return nil
 	}
 
@@ -80,15 +213,26 @@ func (p *Predicate) JoinSubPredicate(schema1, schema2 *record.Schema) *Predicate
 			result.terms = append(result.terms, term)
 		}
 	}
-	if len(result.terms) == 0 {
+	for _, disjunction := range p.disjunctions {
+		if !disjunction.AppliesTo(schema1) && !disjunction.AppliesTo(schema2) && disjunction.AppliesTo(unionSchema) {
+			result.disjunctions = append(result.disjunctions, disjunction)
+		}
+	}
+	if result.isEmpty() {
 		return nil
 	}
 	return result
 }
 
+// isEmpty returns true if the predicate has neither terms nor disjunctions.
+func (p *Predicate) isEmpty() bool {
+	return len(p.terms) == 0 && len(p.disjunctions) == 0
+}
+
 // EquatesWithConstant determines if there is a term of the form "F=c"
 // where F is the specified field and c is some constant.
 // If so, the constant is returned; otherwise, nil is returned.
+// The disjunctions are not considered, since a branch equating F with c does not make every record's F equal c.
 func (p *Predicate) EquatesWithConstant(fieldName string) any {
 	for _, term := range p.terms {
 		if c := term.EquatesWithConstant(fieldName); c != nil {
@@ -98,6 +242,73 @@ func (p *Predicate) EquatesWithConstant(fieldName string) any {
 	return nil
 }
 
+// ConstantEqualityTerm returns the first term of the form "F=c",
+// where F is the specified field and c is some constant, or nil if there is none.
+func (p *Predicate) ConstantEqualityTerm(fieldName string) *Term {
+	for _, term := range p.terms {
+		if term.EquatesWithConstant(fieldName) != nil {
+			return term
+		}
+	}
+	return nil
+}
+
+// Implies returns true if every record satisfying this predicate also satisfies the other one,
+// that is, if each term and disjunction of the other predicate is implied by this predicate:
+//   - a term is implied by some term of this predicate, or by some disjunction of this predicate
+//     whose branches all imply it, such as "a = 1 or a = 2" implying "a > 0";
+//   - a disjunction is implied if this predicate implies one of its branches, such as "a = 1"
+//     implying "a = 1 or b = 2", or by some disjunction of this predicate whose branches all imply it.
+//
+// Since the check is conservative, false means the implication could not be established.
+func (p *Predicate) Implies(other *Predicate) bool {
+	if other == nil {
+		return true
+	}
+	for _, otherTerm := range other.terms {
+		implied := false
+		for _, term := range p.terms {
+			if term.Implies(otherTerm) {
+				implied = true
+				break
+			}
+		}
+		if !implied && !p.disjunctionImplies(NewPredicateFromTerm(otherTerm)) {
+			return false
+		}
+	}
+	for _, otherDisjunction := range other.disjunctions {
+		implied := false
+		for _, branch := range otherDisjunction.branches {
+			if p.Implies(branch) {
+				implied = true
+				break
+			}
+		}
+		if !implied && !p.disjunctionImplies(NewPredicateFromDisjunction(otherDisjunction)) {
+			return false
+		}
+	}
+	return true
+}
+
+// disjunctionImplies returns true if some disjunction of this predicate has branches that all imply the conjunct.
+func (p *Predicate) disjunctionImplies(conjunct *Predicate) bool {
+	for _, disjunction := range p.disjunctions {
+		implied := true
+		for _, branch := range disjunction.branches {
+			if !branch.Implies(conjunct) {
+				implied = false
+				break
+			}
+		}
+		if implied {
+			return true
+		}
+	}
+	return false
+}
+
 // ComparesWithConstant determines if there is a term of the form "F1>c"
 func (p *Predicate) ComparesWithConstant(fieldName string) (types.Operator, any) {
 	for _, term := range p.terms {
@@ -108,6 +319,67 @@ func (p *Predicate) ComparesWithConstant(fieldName string) (types.Operator, any)
 	return types.NONE, nil
 }
 
+// ConstantRange returns the range of values of the specified field that the terms comparing it with a constant,
+// such as "F > 100" and "F <= 500", allow, and true if there is such a term. Of several bounds on one side,
+// the tightest is kept. A term "F LIKE 'abc%'" bounds the field to the strings starting with the literal
+// prefix of its pattern. Like for EquatesWithConstant, the disjunctions are not considered.
+func (p *Predicate) ConstantRange(fieldName string) (index.Range, bool) {
+	var keyRange index.Range
+	found := false
+	for _, term := range p.terms {
+		field, op, constant, ok := term.fieldComparison()
+		if !ok || field != fieldName || constant == nil {
+			continue
+		}
+		for _, bound := range rangeBounds(op, constant) {
+			switch bound.op {
+			case types.GT, types.GE:
+				if keyRange.Low == nil || types.CompareSupportedTypes(bound.value, keyRange.Low, types.GT) ||
+					(bound.op == types.GT && types.CompareSupportedTypes(bound.value, keyRange.Low, types.EQ)) {
+					keyRange.Low, keyRange.LowInclusive = bound.value, bound.op == types.GE
+				}
+			case types.LT, types.LE:
+				if keyRange.High == nil || types.CompareSupportedTypes(bound.value, keyRange.High, types.LT) ||
+					(bound.op == types.LT && types.CompareSupportedTypes(bound.value, keyRange.High, types.EQ)) {
+					keyRange.High, keyRange.HighInclusive = bound.value, bound.op == types.LE
+				}
+			}
+			found = true
+		}
+	}
+	return keyRange, found
+}
+
+// rangeBound is a bound on the values of a field, such as "< 500".
+type rangeBound struct {
+	op    types.Operator
+	value any
+}
+
+// rangeBounds returns the bounds that the comparison of a field with the constant puts on the field's values.
+func rangeBounds(op types.Operator, constant any) []rangeBound {
+	switch op {
+	case types.GT, types.GE, types.LT, types.LE:
+		return []rangeBound{{op: op, value: constant}}
+	case types.LIKE:
+		pattern, ok := constant.(string)
+		if !ok {
+			return nil
+		}
+		prefix, _ := types.PatternPrefix(pattern)
+		if prefix == "" {
+			return nil
+		}
+		bounds := []rangeBound{{op: types.GE, value: prefix}}
+		if upper, ok := types.PrefixUpperBound(prefix); ok {
+			bounds = append(bounds, rangeBound{op: types.LT, value: upper})
+		}
+		return bounds
+	default:
+		return nil
+	}
+}
+
 // EquatesWithField determines if there is a term of the form "F1=F2"
 // where F1 is the specified field and F2 is another field.
 // If so, the name of the other field is returned; otherwise, an empty string is returned.
@@ -120,16 +392,69 @@ func (p *Predicate) EquatesWithField(fieldName string) string {
 	return ""
 }
 
-// String returns a string representation of the predicate.
-func (p *Predicate) String() string {
-	if len(p.terms) == 0 {
-		return ""
+// Negate returns the predicate holding for the records this predicate does not hold for, which it builds
+// by De Morgan's laws rather than by evaluating this predicate and inverting the result: the conjunction of
+// terms and disjunctions becomes the disjunction of the negated terms and of the negated disjunctions,
+// each of which is the conjunction of its negated branches (see Term#Negate). Negating twice gives back
+// an equivalent predicate, and the negated terms keep their use for choosing indexes and estimating costs.
+func (p *Predicate) Negate() *Predicate {
+	branches := make([]*Predicate, 0, len(p.terms)+len(p.disjunctions))
+	for _, term := range p.terms {
+		branches = append(branches, NewPredicateFromTerm(term.Negate()))
 	}
+	for _, disjunction := range p.disjunctions {
+		negated := NewPredicate()
+		for _, branch := range disjunction.branches {
+			negated.ConjoinWith(branch.Negate())
+		}
+		branches = append(branches, negated)
+	}
+	if len(branches) == 1 {
+		return branches[0]
+	}
+	return NewPredicateFromDisjunction(NewDisjunction(branches...))
+}
 
-	result := p.terms[0].String()
-	for _, term := range p.terms[1:] {
-		result += " and " + term.String()
+// RenameFields returns a copy of the predicate reading, in place of each field that is a key of names,
+// the field it maps to, such as the field of a table that a qualified field of a query stands for.
+func (p *Predicate) RenameFields(names map[string]string) *Predicate {
+	renamed := NewPredicate()
+	for _, term := range p.terms {
+		renamed.terms = append(renamed.terms, term.renameFields(names))
+	}
+	for _, disjunction := range p.disjunctions {
+		branches := make([]*Predicate, len(disjunction.branches))
+		for i, branch := range disjunction.branches {
+			branches[i] = branch.RenameFields(names)
+		}
+		renamed.disjunctions = append(renamed.disjunctions, NewDisjunction(branches...))
 	}
+	return renamed
+}
 
-	return result
+// String returns a string representation of the predicate.
+func (p *Predicate) String() string {
+	text, _ := p.Format(formatValue)
+	return text
+}
+
+// Format returns the text of the predicate like String, writing its constants with the specified function,
+// such as one quoting strings so that the text can be parsed again. It returns the first error of the function.
+func (p *Predicate) Format(constant func(val any) (string, error)) (string, error) {
+	conjuncts := make([]string, 0, len(p.terms)+len(p.disjunctions))
+	for _, term := range p.terms {
+		text, err := term.Format(constant)
+		if err != nil {
+			return "", err
+		}
+		conjuncts = append(conjuncts, text)
+	}
+	for _, disjunction := range p.disjunctions {
+		text, err := disjunction.Format(constant)
+		if err != nil {
+			return "", err
+		}
+		conjuncts = append(conjuncts, text)
+	}
+	return strings.Join(conjuncts, " and "), nil
 }
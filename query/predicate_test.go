@@ -0,0 +1,312 @@
+package query
+
+import (
+	"github.com/JyotinderSingh/dropdb/index"
+	"github.com/JyotinderSingh/dropdb/plan"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func fieldTerm(fieldName string, op types.Operator, val any) *Term {
+	return NewTerm(NewFieldExpression(fieldName), NewConstantExpression(val), op)
+}
+
+func TestTerm_Implies(t *testing.T) {
+	tests := []struct {
+		name     string
+		term     *Term
+		other    *Term
+		expected bool
+	}{
+		{"equal constants", fieldTerm("a", types.EQ, 5), fieldTerm("a", types.EQ, 5), true},
+		{"different constants", fieldTerm("a", types.EQ, 5), fieldTerm("a", types.EQ, 6), false},
+		{"equality implies range", fieldTerm("a", types.EQ, 5), fieldTerm("a", types.GT, 3), true},
+		{"equality outside range", fieldTerm("a", types.EQ, 5), fieldTerm("a", types.LT, 5), false},
+		{"equality implies not equal", fieldTerm("a", types.EQ, 5), fieldTerm("a", types.NE, 6), true},
+		{"booleans", fieldTerm("deleted", types.EQ, false), fieldTerm("deleted", types.EQ, false), true},
+		{"different fields", fieldTerm("a", types.EQ, 5), fieldTerm("b", types.EQ, 5), false},
+		{"type mismatch", fieldTerm("a", types.EQ, "5"), fieldTerm("a", types.EQ, 5), false},
+		{"narrower upper bound", fieldTerm("a", types.LT, 5), fieldTerm("a", types.LE, 5), true},
+		{"same strict upper bound", fieldTerm("a", types.LT, 5), fieldTerm("a", types.LT, 5), true},
+		{"inclusive bound does not imply strict", fieldTerm("a", types.LE, 5), fieldTerm("a", types.LT, 5), false},
+		{"inclusive bound implies looser strict", fieldTerm("a", types.LE, 5), fieldTerm("a", types.LT, 6), true},
+		{"wider upper bound", fieldTerm("a", types.LT, 10), fieldTerm("a", types.LT, 5), false},
+		{"narrower lower bound", fieldTerm("a", types.GT, 10), fieldTerm("a", types.GE, 5), true},
+		{"inclusive lower bound", fieldTerm("a", types.GE, 5), fieldTerm("a", types.GT, 5), false},
+		{"upper bound excludes value", fieldTerm("a", types.LT, 5), fieldTerm("a", types.NE, 5), true},
+		{"upper bound includes value", fieldTerm("a", types.LE, 5), fieldTerm("a", types.NE, 5), false},
+		{"opposite directions", fieldTerm("a", types.GT, 5), fieldTerm("a", types.LT, 10), false},
+		{"range does not imply equality", fieldTerm("a", types.GE, 5), fieldTerm("a", types.EQ, 5), false},
+		{"equality implies matched pattern", fieldTerm("s", types.EQ, "abc"), fieldTerm("s", types.LIKE, "a%"), true},
+		{"equality implies unmatched pattern", fieldTerm("s", types.EQ, "abc"), fieldTerm("s", types.NOTLIKE, "b%"), true},
+		{"same pattern", fieldTerm("s", types.LIKE, "a%"), fieldTerm("s", types.LIKE, "a%"), true},
+		{"pattern implies not null", fieldTerm("s", types.NOTLIKE, "a%"), fieldTerm("s", types.ISNOT, nil), true},
+		{"narrower pattern", fieldTerm("s", types.LIKE, "ab%"), fieldTerm("s", types.LIKE, "a%"), false},
+		{
+			"pattern from a field",
+			NewTerm(NewConstantExpression("abc"), NewFieldExpression("s"), types.LIKE),
+			NewTerm(NewConstantExpression("abc"), NewFieldExpression("s"), types.LIKE),
+			false,
+		},
+		{
+			"constant on the left",
+			NewTerm(NewConstantExpression(10), NewFieldExpression("a"), types.LT),
+			fieldTerm("a", types.GT, 5),
+			true,
+		},
+		{
+			"identical field comparison",
+			NewTerm(NewFieldExpression("a"), NewFieldExpression("b"), types.EQ),
+			NewTerm(NewFieldExpression("a"), NewFieldExpression("b"), types.EQ),
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.term.Implies(tt.other))
+		})
+	}
+}
+
+func TestPredicate_Implies(t *testing.T) {
+	query := NewPredicateFromTerm(fieldTerm("deleted", types.EQ, false))
+	query.ConjoinWith(NewPredicateFromTerm(fieldTerm("age", types.GT, 30)))
+
+	assert.True(t, query.Implies(NewPredicateFromTerm(fieldTerm("deleted", types.EQ, false))))
+	assert.True(t, query.Implies(NewPredicateFromTerm(fieldTerm("age", types.GE, 18))))
+	assert.True(t, query.Implies(NewPredicate()))
+	assert.True(t, query.Implies(nil))
+
+	both := NewPredicateFromTerm(fieldTerm("age", types.GE, 18))
+	both.ConjoinWith(NewPredicateFromTerm(fieldTerm("deleted", types.EQ, false)))
+	assert.True(t, query.Implies(both))
+
+	assert.False(t, query.Implies(NewPredicateFromTerm(fieldTerm("deleted", types.EQ, true))))
+	assert.False(t, query.Implies(NewPredicateFromTerm(fieldTerm("name", types.EQ, "x"))))
+	assert.False(t, NewPredicate().Implies(both))
+}
+
+func TestPredicate_ConstantRange(t *testing.T) {
+	predicate := NewPredicateFromTerm(fieldTerm("id", types.GT, 100))
+	predicate.ConjoinWith(NewPredicateFromTerm(fieldTerm("id", types.GE, 50)))
+	predicate.ConjoinWith(NewPredicateFromTerm(NewTerm(NewConstantExpression(500), NewFieldExpression("id"), types.GE)))
+	predicate.ConjoinWith(NewPredicateFromTerm(fieldTerm("id", types.LT, 500)))
+	predicate.ConjoinWith(NewPredicateFromTerm(fieldTerm("name", types.LT, "m")))
+	predicate.ConjoinWith(NewPredicateFromTerm(fieldTerm("id", types.NE, 200)))
+
+	keyRange, ok := predicate.ConstantRange("id")
+	require.True(t, ok)
+	assert.Equal(t, index.Range{Low: 100, High: 500}, keyRange)
+	assert.Equal(t, "id > 100 and id < 500", keyRange.Describe("id"))
+
+	keyRange, ok = predicate.ConstantRange("name")
+	require.True(t, ok)
+	assert.Equal(t, index.Range{High: "m"}, keyRange)
+
+	_, ok = NewPredicateFromTerm(fieldTerm("id", types.EQ, 5)).ConstantRange("id")
+	assert.False(t, ok)
+	_, ok = predicate.ConstantRange("age")
+	assert.False(t, ok)
+}
+
+func TestPredicate_ConstantRangeOfPattern(t *testing.T) {
+	// The strings starting with the literal prefix of the pattern, up to its first wildcard, are in the range.
+	keyRange, ok := NewPredicateFromTerm(fieldTerm("name", types.LIKE, "ab_d%")).ConstantRange("name")
+	require.True(t, ok)
+	assert.Equal(t, index.Range{Low: "ab", LowInclusive: true, High: "ac"}, keyRange)
+
+	keyRange, ok = NewPredicateFromTerm(fieldTerm("name", types.LIKE, "50\\%%")).ConstantRange("name")
+	require.True(t, ok)
+	assert.Equal(t, index.Range{Low: "50%", LowInclusive: true, High: "50&"}, keyRange)
+
+	// A tighter bound of another term is kept.
+	predicate := NewPredicateFromTerm(fieldTerm("name", types.LIKE, "ab%"))
+	predicate.ConjoinWith(NewPredicateFromTerm(fieldTerm("name", types.LT, "abc")))
+	keyRange, ok = predicate.ConstantRange("name")
+	require.True(t, ok)
+	assert.Equal(t, index.Range{Low: "ab", LowInclusive: true, High: "abc"}, keyRange)
+
+	// A pattern starting with a wildcard, or not matched with the field, does not bound it.
+	_, ok = NewPredicateFromTerm(fieldTerm("name", types.LIKE, "%ab")).ConstantRange("name")
+	assert.False(t, ok)
+	_, ok = NewPredicateFromTerm(fieldTerm("name", types.NOTLIKE, "ab%")).ConstantRange("name")
+	assert.False(t, ok)
+	_, ok = NewPredicateFromTerm(NewTerm(NewConstantExpression("ab"), NewFieldExpression("name"), types.LIKE)).ConstantRange("name")
+	assert.False(t, ok)
+}
+
+func TestPredicate_Fields(t *testing.T) {
+	predicate := NewPredicateFromTerm(fieldTerm("age", types.GT, 30))
+	predicate.ConjoinWith(NewPredicateFromTerm(NewTerm(NewFieldExpression("dept_id"), NewFieldExpression("did"), types.EQ)))
+	predicate.ConjoinWith(NewPredicateFromTerm(NewTerm(NewConstantExpression(60), NewFieldExpression("age"), types.GT)))
+
+	assert.Equal(t, []string{"age", "dept_id", "did"}, predicate.Fields())
+	assert.Empty(t, NewPredicate().Fields())
+	assert.Empty(t, (*Predicate)(nil).Fields())
+}
+
+func TestTerm_CheckTypes(t *testing.T) {
+	schema := record.NewSchema()
+	schema.AddStringField("name", 10)
+	schema.AddIntField("age")
+	schema.AddLongField("balance")
+	schema.AddShortField("code")
+	schema.AddBoolField("active")
+	schema.AddDateField("created")
+
+	fields := func(lhs, rhs string) *Term {
+		return NewTerm(NewFieldExpression(lhs), NewFieldExpression(rhs), types.EQ)
+	}
+
+	tests := []struct {
+		name string
+		term *Term
+		// expected is the error message, or empty if the term is accepted.
+		expected string
+	}{
+		{"string constant", fieldTerm("name", types.EQ, "joe"), ""},
+		{"int constant for varchar", fieldTerm("name", types.EQ, 42), "type mismatch: field name of type varchar compared with int constant 42"},
+		{"int constant for long", fieldTerm("balance", types.GT, 42), ""},
+		{"int constant for short", fieldTerm("code", types.EQ, 7), ""},
+		{"out of range constant for short", fieldTerm("code", types.LT, 100000), ""},
+		{"long constant for int", fieldTerm("age", types.EQ, int64(30)), ""},
+		{"string constant for int", fieldTerm("age", types.EQ, "30"), "type mismatch: field age of type int compared with varchar constant 30"},
+		{"date string for date", fieldTerm("created", types.GE, "2024-05-06"), ""},
+		{"date constant for date", fieldTerm("created", types.GE, time.Now()), ""},
+		{"bare number for date", fieldTerm("created", types.EQ, 20240506), "field created of type date compared with int constant 20240506"},
+		{"malformed date string", fieldTerm("created", types.EQ, "yesterday"), "field created of type date compared with varchar constant yesterday"},
+		{"bool constant", fieldTerm("active", types.EQ, true), ""},
+		{"boolean string", fieldTerm("active", types.EQ, "true"), ""},
+		{"string for bool", fieldTerm("active", types.EQ, "yes"), "field active of type bool"},
+		{"constant on the left", NewTerm(NewConstantExpression(42), NewFieldExpression("name"), types.LT), "field name of type varchar compared with int constant 42"},
+		{"integer fields", fields("age", "balance"), ""},
+		{"same type fields", fields("created", "created"), ""},
+		{"incompatible fields", fields("name", "age"), "type mismatch: name of type varchar compared with age of type int in name = age"},
+		{"incompatible constants", NewTerm(NewConstantExpression(1), NewConstantExpression("1"), types.EQ), "1 of type int compared with 1 of type varchar"},
+		{"field not in schema", fieldTerm("other", types.EQ, 42), ""},
+		{"pattern", fieldTerm("name", types.LIKE, "j%"), ""},
+		{"pattern for int", fieldTerm("age", types.LIKE, "3%"), "type mismatch: age of type int matched with pattern 3% of type varchar"},
+		{"int pattern", fieldTerm("name", types.NOTLIKE, 3), "name of type varchar matched with pattern 3 of type int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.term.CheckTypes(schema)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrTypeMismatch)
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+// distinctValuesPlan is a plan whose fields each have the specified number of distinct values.
+type distinctValuesPlan struct {
+	plan.Plan
+	distinct map[string]int
+}
+
+func (p *distinctValuesPlan) DistinctValues(fieldName string) int {
+	return p.distinct[fieldName]
+}
+
+func TestPredicate_Disjunction(t *testing.T) {
+	// (dept = 'Sales' or dept = 'Marketing') and age > 30
+	predicate := NewPredicateFromDisjunction(NewDisjunction(
+		NewPredicateFromTerm(fieldTerm("dept", types.EQ, "Sales")),
+		NewPredicateFromTerm(fieldTerm("dept", types.EQ, "Marketing")),
+	))
+	predicate.ConjoinWith(NewPredicateFromTerm(fieldTerm("age", types.GT, 30)))
+
+	assert.Equal(t, "age > 30 and (dept = Sales or dept = Marketing)", predicate.String())
+	assert.Equal(t, []string{"age", "dept"}, predicate.Fields())
+	require.Len(t, predicate.Conjuncts(), 2)
+	assert.Equal(t, "(dept = Sales or dept = Marketing)", predicate.Conjuncts()[1].String())
+
+	// A branch equating the field with a constant does not make the field constant.
+	assert.Nil(t, predicate.EquatesWithConstant("dept"))
+	assert.Nil(t, predicate.ConstantEqualityTerm("dept"))
+
+	// Each branch selects a tenth of the records, so the disjunction selects a fifth, and the range term a half.
+	queryPlan := &distinctValuesPlan{distinct: map[string]int{"dept": 10, "age": 50}}
+	assert.Equal(t, 10, predicate.ReductionFactor(queryPlan))
+
+	// A disjunction is applied where every field of its branches is available.
+	deptSchema := record.NewSchema()
+	deptSchema.AddStringField("dept", 10)
+	assert.Equal(t, "(dept = Sales or dept = Marketing)", predicate.SelectSubPredicate(deptSchema).String())
+	ageSchema := record.NewSchema()
+	ageSchema.AddIntField("age")
+	assert.Equal(t, "age > 30", predicate.SelectSubPredicate(ageSchema).String())
+	crossing := NewPredicateFromDisjunction(NewDisjunction(
+		NewPredicateFromTerm(fieldTerm("dept", types.EQ, "Sales")),
+		NewPredicateFromTerm(fieldTerm("age", types.LT, 20)),
+	))
+	assert.Nil(t, crossing.SelectSubPredicate(deptSchema))
+	assert.Equal(t, crossing.String(), crossing.JoinSubPredicate(deptSchema, ageSchema).String())
+}
+
+func TestPredicate_ImpliesDisjunction(t *testing.T) {
+	sales := NewPredicateFromTerm(fieldTerm("dept", types.EQ, "Sales"))
+	salesOrMarketing := NewPredicateFromDisjunction(NewDisjunction(
+		sales,
+		NewPredicateFromTerm(fieldTerm("dept", types.EQ, "Marketing")),
+	))
+	assert.True(t, sales.Implies(salesOrMarketing))
+	assert.False(t, salesOrMarketing.Implies(sales))
+	assert.True(t, salesOrMarketing.Implies(salesOrMarketing))
+
+	// Every branch must imply the term.
+	oneOrTwo := NewPredicateFromDisjunction(NewDisjunction(
+		NewPredicateFromTerm(fieldTerm("a", types.EQ, 1)),
+		NewPredicateFromTerm(fieldTerm("a", types.EQ, 2)),
+	))
+	assert.True(t, oneOrTwo.Implies(NewPredicateFromTerm(fieldTerm("a", types.GT, 0))))
+	assert.False(t, oneOrTwo.Implies(NewPredicateFromTerm(fieldTerm("a", types.GT, 1))))
+	assert.False(t, NewPredicate().Implies(oneOrTwo))
+}
+
+func TestPredicate_Negate(t *testing.T) {
+	assert.Equal(t, "a >= 5", fieldTerm("a", types.LT, 5).Negate().String())
+	assert.Equal(t, "a <> 5", fieldTerm("a", types.EQ, 5).Negate().String())
+
+	// not (a = 1 and b > 2) = a <> 1 or b <= 2
+	conjunction := NewPredicateFromTerm(fieldTerm("a", types.EQ, 1))
+	conjunction.ConjoinWith(NewPredicateFromTerm(fieldTerm("b", types.GT, 2)))
+	negated := conjunction.Negate()
+	assert.Equal(t, "(a <> 1 or b <= 2)", negated.String())
+	assert.Equal(t, "a = 1 and b > 2", negated.Negate().String())
+
+	// not (c < 3 and (a = 1 or b = 2)) = c >= 3 or (a <> 1 and b <> 2)
+	mixed := NewPredicateFromTerm(fieldTerm("c", types.LT, 3))
+	mixed.ConjoinWith(NewPredicateFromDisjunction(NewDisjunction(
+		NewPredicateFromTerm(fieldTerm("a", types.EQ, 1)),
+		NewPredicateFromTerm(fieldTerm("b", types.EQ, 2)),
+	)))
+	assert.Equal(t, "(c >= 3 or a <> 1 and b <> 2)", mixed.Negate().String())
+	assert.Equal(t, "c < 3 and (a = 1 or b = 2)", mixed.Negate().Negate().String())
+
+	// The negation of an equality keeps most records.
+	queryPlan := &distinctValuesPlan{distinct: map[string]int{"a": 10, "b": 10}}
+	assert.Equal(t, 10, NewPredicateFromTerm(fieldTerm("a", types.EQ, 1)).ReductionFactor(queryPlan))
+	assert.Equal(t, 1, NewPredicateFromTerm(fieldTerm("a", types.EQ, 1)).Negate().ReductionFactor(queryPlan))
+}
+
+func TestPredicate_RenameFields(t *testing.T) {
+	predicate := NewPredicateFromTerm(NewTerm(NewFieldExpression("e.salary"),
+		NewArithmeticExpression(NewFieldExpression("e.bonus"), types.MUL, NewConstantExpression(2)), types.GT))
+	predicate.ConjoinWith(NewPredicateFromDisjunction(NewDisjunction(
+		NewPredicateFromTerm(fieldTerm("e.dept", types.EQ, 1)), NewPredicateFromTerm(fieldTerm("name", types.EQ, "e.dept")))))
+
+	renamed := predicate.RenameFields(map[string]string{"e.salary": "salary", "e.bonus": "bonus", "e.dept": "dept"})
+	assert.Equal(t, "salary > bonus * 2 and (dept = 1 or name = e.dept)", renamed.String())
+	// The predicate renamed is left unchanged.
+	assert.Equal(t, "e.salary > e.bonus * 2 and (e.dept = 1 or name = e.dept)", predicate.String())
+}
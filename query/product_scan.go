@@ -1,9 +1,11 @@
 package query
 
 import (
+	"errors"
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 	"time"
 )
 
@@ -13,8 +15,9 @@ var (
 )
 
 type ProductScan struct {
-	scan1 scan.Scan
-	scan2 scan.Scan
+	scan1  scan.Scan
+	scan2  scan.Scan
+	closed bool
 }
 
 func NewProductScan(s1, s2 scan.Scan) *ProductScan {
@@ -68,10 +71,14 @@ func (ps *ProductScan) Next() (bool, error) {
 	return true, nil
 }
 
-// Close closes the scan.
-func (ps *ProductScan) Close() {
-	ps.scan1.Close()
-	ps.scan2.Close()
+// Close closes the scan and both underlying scans.
+// Closing the scan again has no effect.
+func (ps *ProductScan) Close() error {
+	if ps.closed {
+		return nil
+	}
+	ps.closed = true
+	return errors.Join(ps.scan1.Close(), ps.scan2.Close())
 }
 
 // HasField returns true if the specified field is in either of the underlying scans.
@@ -79,6 +86,11 @@ func (ps *ProductScan) HasField(fieldName string) bool {
 	return ps.scan1.HasField(fieldName) || ps.scan2.HasField(fieldName)
 }
 
+// Fields returns the fields of the first underlying scan followed by those of the second one.
+func (ps *ProductScan) Fields() []types.FieldInfo {
+	return concatFields(ps.scan1.Fields(), ps.scan2.Fields(), false)
+}
+
 // GetInt returns the integer value of the specified field in the current record.
 func (ps *ProductScan) GetInt(fieldName string) (int, error) {
 	if ps.scan1.HasField(fieldName) {
@@ -103,6 +115,14 @@ func (ps *ProductScan) GetShort(fieldName string) (int16, error) {
 	return ps.scan2.GetShort(fieldName)
 }
 
+// GetFloat returns the float value of the specified field in the current record.
+func (ps *ProductScan) GetFloat(fieldName string) (float64, error) {
+	if ps.scan1.HasField(fieldName) {
+		return ps.scan1.GetFloat(fieldName)
+	}
+	return ps.scan2.GetFloat(fieldName)
+}
+
 // GetString returns the string value of the specified field in the current record.
 func (ps *ProductScan) GetString(fieldName string) (string, error) {
 	if ps.scan1.HasField(fieldName) {
@@ -183,6 +203,22 @@ func (ps *ProductScan) SetShort(fieldName string, val int16) error {
 	return updateScan.SetShort(fieldName, val)
 }
 
+// SetFloat sets the float value of the specified field in the current record.
+func (ps *ProductScan) SetFloat(fieldName string, val float64) error {
+	if ps.scan1.HasField(fieldName) {
+		updateScan, ok := ps.scan1.(scan.UpdateScan)
+		if !ok {
+			return fmt.Errorf(ErrUpdateNotSupported, ps.scan1)
+		}
+		return updateScan.SetFloat(fieldName, val)
+	}
+	updateScan, ok := ps.scan2.(scan.UpdateScan)
+	if !ok {
+		return fmt.Errorf(ErrUpdateNotSupported, ps.scan2)
+	}
+	return updateScan.SetFloat(fieldName, val)
+}
+
 // SetString sets the string value of the specified field in the current record.
 func (ps *ProductScan) SetString(fieldName string, val string) error {
 	if ps.scan1.HasField(fieldName) {
@@ -257,10 +293,10 @@ func (ps *ProductScan) Delete() error {
 	return fmt.Errorf("delete not supported on ProductScan")
 }
 
-func (ps *ProductScan) GetRecordID() *record.ID {
+func (ps *ProductScan) GetRecordID() (*record.ID, error) {
 	// GetRecordID not supported on ProductScan, this is because we can't get a record ID from a product of two scans.
 	// Each row in the product is a combination of two records, so there is no single record ID.
-	panic("GetRecordID not supported on ProductScan")
+	return nil, fmt.Errorf("GetRecordID not supported on ProductScan")
 }
 
 func (ps *ProductScan) MoveToRecordID(rid *record.ID) error {
@@ -270,12 +270,22 @@ func TestProductScan_RecordID(t *testing.T) {
 	ps, cleanup := setupTestProductScan(t)
 	defer cleanup()
 
-	// GetRecordID -> panic
-	assert.Panics(t, func() {
-		_ = ps.GetRecordID()
-	}, "GetRecordID should panic on ProductScan")
+	// GetRecordID -> error, since a record of the product is made of a record of each scan
+	rid, err := ps.GetRecordID()
+	assert.ErrorContains(t, err, "GetRecordID not supported on ProductScan")
+	assert.Nil(t, rid)
 
 	// MoveToRecordID -> error
-	err := ps.MoveToRecordID(nil)
+	err = ps.MoveToRecordID(nil)
 	assert.Error(t, err, "MoveToRecordID should fail on ProductScan")
+
+	// The selections and projections of a product return its error rather than panicking.
+	selectScan, err := NewSelectScan(ps, NewPredicate())
+	require.NoError(t, err)
+	_, err = selectScan.GetRecordID()
+	assert.ErrorContains(t, err, "GetRecordID not supported on ProductScan")
+	projectScan, err := NewProjectScan(selectScan, []string{"A"})
+	require.NoError(t, err)
+	_, err = projectScan.GetRecordID()
+	assert.ErrorContains(t, err, "GetRecordID not supported on ProductScan")
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 	"time"
 )
 
@@ -15,10 +16,60 @@ var (
 type ProjectScan struct {
 	inputScan scan.Scan
 	fieldList []string
+	// aliases maps the fields of the field list that are renamed to the fields of the underlying scan they read.
+	aliases map[string]string
+	// computed maps the fields of the field list that are not read from the underlying scan to the expression
+	// computing their value for each of its records.
+	computed map[string]*Expression
+	closed   bool
 }
 
 func NewProjectScan(s scan.Scan, fieldList []string) (*ProjectScan, error) {
-	return &ProjectScan{inputScan: s, fieldList: fieldList}, nil
+	return NewProjectScanWithAliases(s, fieldList, nil)
+}
+
+// NewProjectScanWithAliases creates a project scan whose field list may rename fields of the underlying scan:
+// the aliases map a field of the list to the field of the underlying scan it reads. The other fields of the list
+// are read under their own name.
+func NewProjectScanWithAliases(s scan.Scan, fieldList []string, aliases map[string]string) (*ProjectScan, error) {
+	return NewProjectScanWithExpressions(s, fieldList, aliases, nil)
+}
+
+// NewProjectScanWithExpressions creates a project scan like NewProjectScanWithAliases, in which the fields of the list
+// that are computed, or that an alias maps to a computed field, are the value of their expression for the current
+// record of the underlying scan, such as "salary * 12".
+func NewProjectScanWithExpressions(s scan.Scan, fieldList []string, aliases map[string]string, computed map[string]*Expression) (*ProjectScan, error) {
+	return &ProjectScan{inputScan: s, fieldList: fieldList, aliases: aliases, computed: computed}, nil
+}
+
+// computedExpression returns the expression computing the specified field of the field list,
+// or false if the field is read from the underlying scan.
+func (ps *ProjectScan) computedExpression(fieldName string) (*Expression, bool) {
+	expression, ok := ps.computed[ps.inputField(fieldName)]
+	return expression, ok
+}
+
+// getComputed returns the value of the expression for the current record, which must be of type T,
+// or the zero value of T if it is null.
+func getComputed[T any](ps *ProjectScan, expression *Expression, fieldName, typeName string) (T, error) {
+	var zero T
+	val, err := expression.Evaluate(ps.inputScan)
+	if err != nil || val == nil {
+		return zero, err
+	}
+	typed, ok := val.(T)
+	if !ok {
+		return zero, fmt.Errorf("field %s is not %s", fieldName, typeName)
+	}
+	return typed, nil
+}
+
+// inputField returns the field of the underlying scan read as the specified field of the field list.
+func (ps *ProjectScan) inputField(fieldName string) string {
+	if inputField, ok := ps.aliases[fieldName]; ok {
+		return inputField
+	}
+	return fieldName
 }
 
 func (ps *ProjectScan) BeforeFirst() error {
@@ -29,8 +80,13 @@ func (ps *ProjectScan) Next() (bool, error) {
 	return ps.inputScan.Next()
 }
 
-func (ps *ProjectScan) Close() {
-	ps.inputScan.Close()
+// Close closes the underlying scan. Closing the scan again has no effect.
+func (ps *ProjectScan) Close() error {
+	if ps.closed {
+		return nil
+	}
+	ps.closed = true
+	return ps.inputScan.Close()
 }
 
 // HasField returns true if the specified field is in the field list.
@@ -43,12 +99,37 @@ func (ps *ProjectScan) HasField(fieldName string) bool {
 	return false
 }
 
+// Fields returns the fields of the field list, in its order, with the type of the field they read in the underlying scan,
+// or the type of the values of their expression for computed fields.
+func (ps *ProjectScan) Fields() []types.FieldInfo {
+	inputFields := ps.inputScan.Fields()
+	fields := make([]types.FieldInfo, 0, len(ps.fieldList))
+	for _, fieldName := range ps.fieldList {
+		if expression, ok := ps.computedExpression(fieldName); ok {
+			inputSchema := record.NewSchema()
+			for _, field := range inputFields {
+				inputSchema.AddField(field.Name, field.Type, field.Length)
+			}
+			if fieldType, ok := expression.typeIn(inputSchema); ok {
+				fields = append(fields, types.FieldInfo{Name: fieldName, Type: fieldType})
+			}
+		} else if field, ok := types.FindField(inputFields, ps.inputField(fieldName)); ok {
+			field.Name = fieldName
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
 // GetInt returns the integer value of the specified field in the current record.
 func (ps *ProjectScan) GetInt(fieldName string) (int, error) {
 	if !ps.HasField(fieldName) {
 		return 0, fmt.Errorf(ErrFieldNotFound, fieldName)
 	}
-	return ps.inputScan.GetInt(fieldName)
+	if expression, ok := ps.computedExpression(fieldName); ok {
+		return getComputed[int](ps, expression, fieldName, "an int")
+	}
+	return ps.inputScan.GetInt(ps.inputField(fieldName))
 }
 
 // GetLong returns the long value of the specified field in the current record.
@@ -56,7 +137,10 @@ func (ps *ProjectScan) GetLong(fieldName string) (int64, error) {
 	if !ps.HasField(fieldName) {
 		return 0, fmt.Errorf(ErrFieldNotFound, fieldName)
 	}
-	return ps.inputScan.GetLong(fieldName)
+	if expression, ok := ps.computedExpression(fieldName); ok {
+		return getComputed[int64](ps, expression, fieldName, "a long")
+	}
+	return ps.inputScan.GetLong(ps.inputField(fieldName))
 }
 
 // GetShort returns the short value of the specified field in the current record.
@@ -64,7 +148,21 @@ func (ps *ProjectScan) GetShort(fieldName string) (int16, error) {
 	if !ps.HasField(fieldName) {
 		return 0, fmt.Errorf(ErrFieldNotFound, fieldName)
 	}
-	return ps.inputScan.GetShort(fieldName)
+	if expression, ok := ps.computedExpression(fieldName); ok {
+		return getComputed[int16](ps, expression, fieldName, "a short")
+	}
+	return ps.inputScan.GetShort(ps.inputField(fieldName))
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (ps *ProjectScan) GetFloat(fieldName string) (float64, error) {
+	if !ps.HasField(fieldName) {
+		return 0, fmt.Errorf(ErrFieldNotFound, fieldName)
+	}
+	if expression, ok := ps.computedExpression(fieldName); ok {
+		return getComputed[float64](ps, expression, fieldName, "a float")
+	}
+	return ps.inputScan.GetFloat(ps.inputField(fieldName))
 }
 
 // GetString returns the string value of the specified field in the current record.
@@ -72,7 +170,10 @@ func (ps *ProjectScan) GetString(fieldName string) (string, error) {
 	if !ps.HasField(fieldName) {
 		return "", fmt.Errorf(ErrFieldNotFound, fieldName)
 	}
-	return ps.inputScan.GetString(fieldName)
+	if expression, ok := ps.computedExpression(fieldName); ok {
+		return getComputed[string](ps, expression, fieldName, "a string")
+	}
+	return ps.inputScan.GetString(ps.inputField(fieldName))
 }
 
 // GetBool returns the boolean value of the specified field in the current record.
@@ -80,7 +181,10 @@ func (ps *ProjectScan) GetBool(fieldName string) (bool, error) {
 	if !ps.HasField(fieldName) {
 		return false, fmt.Errorf(ErrFieldNotFound, fieldName)
 	}
-	return ps.inputScan.GetBool(fieldName)
+	if expression, ok := ps.computedExpression(fieldName); ok {
+		return getComputed[bool](ps, expression, fieldName, "a bool")
+	}
+	return ps.inputScan.GetBool(ps.inputField(fieldName))
 }
 
 // GetDate returns the date value of the specified field in the current record.
@@ -88,7 +192,10 @@ func (ps *ProjectScan) GetDate(fieldName string) (time.Time, error) {
 	if !ps.HasField(fieldName) {
 		return time.Time{}, fmt.Errorf(ErrFieldNotFound, fieldName)
 	}
-	return ps.inputScan.GetDate(fieldName)
+	if expression, ok := ps.computedExpression(fieldName); ok {
+		return getComputed[time.Time](ps, expression, fieldName, "a date")
+	}
+	return ps.inputScan.GetDate(ps.inputField(fieldName))
 }
 
 // GetVal returns the value of the specified field in the current record.
@@ -96,7 +203,10 @@ func (ps *ProjectScan) GetVal(fieldName string) (interface{}, error) {
 	if !ps.HasField(fieldName) {
 		return nil, fmt.Errorf(ErrFieldNotFound, fieldName)
 	}
-	return ps.inputScan.GetVal(fieldName)
+	if expression, ok := ps.computedExpression(fieldName); ok {
+		return expression.Evaluate(ps.inputScan)
+	}
+	return ps.inputScan.GetVal(ps.inputField(fieldName))
 }
 
 // SetInt sets the integer value of the specified field in the current record.
@@ -108,7 +218,7 @@ func (ps *ProjectScan) SetInt(fieldName string, val int) error {
 	if !ok {
 		return fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
 	}
-	return updateScan.SetInt(fieldName, val)
+	return updateScan.SetInt(ps.inputField(fieldName), val)
 }
 
 // SetLong sets the long value of the specified field in the current record.
@@ -120,7 +230,7 @@ func (ps *ProjectScan) SetLong(fieldName string, val int64) error {
 	if !ok {
 		return fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
 	}
-	return updateScan.SetLong(fieldName, val)
+	return updateScan.SetLong(ps.inputField(fieldName), val)
 }
 
 // SetShort sets the short value of the specified field in the current record.
@@ -132,7 +242,19 @@ func (ps *ProjectScan) SetShort(fieldName string, val int16) error {
 	if !ok {
 		return fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
 	}
-	return updateScan.SetShort(fieldName, val)
+	return updateScan.SetShort(ps.inputField(fieldName), val)
+}
+
+// SetFloat sets the float value of the specified field in the current record.
+func (ps *ProjectScan) SetFloat(fieldName string, val float64) error {
+	if !ps.HasField(fieldName) {
+		return fmt.Errorf(ErrFieldNotFound, fieldName)
+	}
+	updateScan, ok := ps.inputScan.(scan.UpdateScan)
+	if !ok {
+		return fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
+	}
+	return updateScan.SetFloat(ps.inputField(fieldName), val)
 }
 
 // SetString sets the string value of the specified field in the current record.
@@ -144,7 +266,7 @@ func (ps *ProjectScan) SetString(fieldName string, val string) error {
 	if !ok {
 		return fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
 	}
-	return updateScan.SetString(fieldName, val)
+	return updateScan.SetString(ps.inputField(fieldName), val)
 }
 
 // SetBool sets the boolean value of the specified field in the current record.
@@ -156,7 +278,7 @@ func (ps *ProjectScan) SetBool(fieldName string, val bool) error {
 	if !ok {
 		return fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
 	}
-	return updateScan.SetBool(fieldName, val)
+	return updateScan.SetBool(ps.inputField(fieldName), val)
 }
 
 // SetDate sets the date value of the specified field in the current record.
@@ -168,7 +290,7 @@ func (ps *ProjectScan) SetDate(fieldName string, val time.Time) error {
 	if !ok {
 		return fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
 	}
-	return updateScan.SetDate(fieldName, val)
+	return updateScan.SetDate(ps.inputField(fieldName), val)
 }
 
 // SetVal sets the value of the specified field in the current record.
@@ -180,7 +302,7 @@ func (ps *ProjectScan) SetVal(fieldName string, val interface{}) error {
 	if !ok {
 		return fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
 	}
-	return updateScan.SetVal(fieldName, val)
+	return updateScan.SetVal(ps.inputField(fieldName), val)
 }
 
 // Insert inserts a new record somewhere in the scan.
@@ -202,10 +324,10 @@ func (ps *ProjectScan) Delete() error {
 }
 
 // GetRecordID returns the record ID of the current record.
-func (ps *ProjectScan) GetRecordID() *record.ID {
+func (ps *ProjectScan) GetRecordID() (*record.ID, error) {
 	updateScan, ok := ps.inputScan.(scan.UpdateScan)
 	if !ok {
-		panic(fmt.Sprintf(ErrUpdateNotSupported, ps.inputScan))
+		return nil, fmt.Errorf(ErrUpdateNotSupported, ps.inputScan)
 	}
 	return updateScan.GetRecordID()
 }
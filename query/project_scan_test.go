@@ -240,7 +240,8 @@ func TestProjectScan_GetRecordID(t *testing.T) {
 		idVal, err := ps.GetInt("id")
 		require.NoError(t, err)
 		if idVal == 2 {
-			bobRID = ps.GetRecordID()
+			bobRID, err = ps.GetRecordID()
+			require.NoError(t, err)
 			break
 		}
 	}
@@ -1,38 +1,101 @@
 package query
 
 import (
+	"fmt"
+
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/types"
 )
 
+// SortField is a field that records are sorted on, in ascending or descending order.
+type SortField struct {
+	Name       string
+	Descending bool
+}
+
 // RecordComparator is a comparator for scans based on a list of field names.
 type RecordComparator struct {
-	fields []string
+	fields []SortField
 }
 
-// NewRecordComparator creates a new comparator using the specified fields.
+// NewRecordComparator creates a new comparator using the specified fields, in ascending order.
 func NewRecordComparator(fields []string) *RecordComparator {
+	sortFields := make([]SortField, len(fields))
+	for i, field := range fields {
+		sortFields[i] = SortField{Name: field}
+	}
+	return NewRecordComparatorWithDirections(sortFields)
+}
+
+// NewRecordComparatorWithDirections creates a new comparator using the specified fields,
+// each in its own order.
+func NewRecordComparatorWithDirections(fields []SortField) *RecordComparator {
 	return &RecordComparator{fields: fields}
 }
 
 // Compare compares the current records of two scans based on the specified fields. Expects supported types.
-func (rc *RecordComparator) Compare(s1, s2 scan.Scan) int {
-	for _, fieldName := range rc.fields {
-		// Get values for the current field
-		val1, err1 := s1.GetVal(fieldName)
-		val2, err2 := s2.GetVal(fieldName)
-
-		if err1 != nil || err2 != nil {
-			panic("Error retrieving field values for comparison")
+// Null values are equal to one another, and sort before any other value in ascending order, after them in descending order.
+// It returns the error of either scan if the value of a field cannot be read.
+func (rc *RecordComparator) Compare(s1, s2 scan.Scan) (int, error) {
+	for _, field := range rc.fields {
+		comparison, err := compareField(s1, s2, field.Name)
+		if err != nil {
+			return 0, err
+		}
+		if comparison != 0 {
+			if field.Descending {
+				return -comparison, nil
+			}
+			return comparison, nil
+		}
+	}
+	return 0, nil // All fields are equal
+}
+
+// CompareRecords compares two records held in memory, which map their fields to their values, like Compare.
+func (rc *RecordComparator) CompareRecords(r1, r2 map[string]any) int {
+	for _, field := range rc.fields {
+		if comparison := compareValues(r1[field.Name], r2[field.Name]); comparison != 0 {
+			if field.Descending {
+				return -comparison
+			}
+			return comparison
 		}
+	}
+	return 0
+}
+
+// compareField compares the values of the specified field in the current records of two scans, in ascending order.
+func compareField(s1, s2 scan.Scan, fieldName string) (int, error) {
+	val1, err := s1.GetVal(fieldName)
+	if err != nil {
+		return 0, fmt.Errorf("compare field %s: %w", fieldName, err)
+	}
+	val2, err := s2.GetVal(fieldName)
+	if err != nil {
+		return 0, fmt.Errorf("compare field %s: %w", fieldName, err)
+	}
+	return compareValues(val1, val2), nil
+}
 
-		// Compare using CompareSupportedTypes with equality and ordering operators
-		if types.CompareSupportedTypes(val1, val2, types.LT) {
-			return -1 // val1 < val2
-		} else if types.CompareSupportedTypes(val1, val2, types.GT) {
-			return 1 // val1 > val2
+// compareValues compares two values of a field in ascending order, null values sorting first.
+func compareValues(val1, val2 any) int {
+	if val1 == nil || val2 == nil {
+		if val1 != nil {
+			return 1
+		}
+		if val2 != nil {
+			return -1
 		}
-		// If neither LT nor GT, the values must be equal for this field; continue to next field.
+		return 0
+	}
+
+	// Compare using CompareSupportedTypes with equality and ordering operators
+	if types.CompareSupportedTypes(val1, val2, types.LT) {
+		return -1 // val1 < val2
+	} else if types.CompareSupportedTypes(val1, val2, types.GT) {
+		return 1 // val1 > val2
 	}
-	return 0 // All fields are equal
+	// If neither LT nor GT, the values must be equal for this field.
+	return 0
 }
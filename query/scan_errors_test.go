@@ -0,0 +1,111 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/materialize"
+	"github.com/JyotinderSingh/dropdb/query/functions"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readError is the error of the reads failed by a faultyScan.
+type readError struct {
+	reads int
+}
+
+func (e *readError) Error() string {
+	return fmt.Sprintf("injected read failure after %d reads", e.reads)
+}
+
+// faultyScan wraps a scan, failing the reads of integer fields once the specified number of them succeeded.
+type faultyScan struct {
+	scan.UpdateScan
+	reads     int
+	failAfter int
+}
+
+func (s *faultyScan) GetInt(fieldName string) (int, error) {
+	if s.reads >= s.failAfter {
+		return 0, &readError{reads: s.reads}
+	}
+	s.reads++
+	return s.UpdateScan.GetInt(fieldName)
+}
+
+func (s *faultyScan) GetVal(fieldName string) (any, error) {
+	if field, ok := types.FindField(s.Fields(), fieldName); ok && field.Type == types.Integer {
+		return s.GetInt(fieldName)
+	}
+	return s.UpdateScan.GetVal(fieldName)
+}
+
+// drain reads the records of the scan until its end or its first error, returning the number of records read.
+func drain(s scan.Scan) (int, error) {
+	records := 0
+	for {
+		next, err := s.Next()
+		if err != nil || !next {
+			return records, err
+		}
+		records++
+	}
+}
+
+// requireReadError checks that the scan fails with the error of a faultyScan, rather than ending early.
+func requireReadError(t *testing.T, s scan.Scan, expectedRecords int) {
+	t.Helper()
+	records, err := drain(s)
+	var injected *readError
+	require.True(t, errors.As(err, &injected), "expected an injected read error, got %v after %d records", err, records)
+	assert.Equal(t, expectedRecords, records)
+}
+
+func TestScanErrors_SelectScan(t *testing.T) {
+	ts, cleanup := setupTestTableScan(t)
+	defer cleanup()
+
+	// No record satisfies the predicate, so a failed read must not be mistaken for the end of the records.
+	predicate := NewPredicateFromTerm(NewTerm(NewFieldExpression("val"), NewConstantExpression(100), types.GT))
+	ss, err := NewSelectScan(&faultyScan{UpdateScan: ts, failAfter: 2}, predicate)
+	require.NoError(t, err)
+	requireReadError(t, ss, 0)
+}
+
+func TestScanErrors_GroupByScan(t *testing.T) {
+	ts, cleanup := setupTestTableScan(t)
+	defer cleanup()
+
+	// Each record is a group of its own, whose maximum reads its value.
+	gs, err := NewGroupByScan(&faultyScan{UpdateScan: ts, failAfter: 2}, []string{"name"}, []functions.AggregationFunction{functions.NewMaxFunction("val")})
+	require.NoError(t, err)
+	requireReadError(t, gs, 2)
+}
+
+func TestScanErrors_SortScan(t *testing.T) {
+	transaction, _, cleanup := createTransactionAndLayout(t)
+	defer cleanup()
+
+	type row = struct {
+		ID   int
+		Name string
+		Val  int
+	}
+	run1, err := setupTempTable(t, transaction, "sortscan_errors_run1", []row{{1, "Alice", 10}, {3, "Carol", 30}})
+	require.NoError(t, err)
+	run2, err := setupTempTable(t, transaction, "sortscan_errors_run2", []row{{2, "Bob", 20}, {4, "Dave", 40}})
+	require.NoError(t, err)
+
+	ss, err := NewSortScan([]*materialize.TempTable{run1, run2}, NewRecordComparator([]string{"id"}))
+	require.NoError(t, err)
+	defer ss.Close()
+
+	// Comparing the current records of the runs reads the id of each, so the third comparison fails.
+	ss.scans[0] = &faultyScan{UpdateScan: ss.scans[0], failAfter: 2}
+	ss.scans[1] = &faultyScan{UpdateScan: ss.scans[1], failAfter: 2}
+	requireReadError(t, ss, 2)
+}
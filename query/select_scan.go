@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 	"time"
 )
 
@@ -15,6 +16,7 @@ var (
 type SelectScan struct {
 	inputScan scan.Scan
 	predicate *Predicate
+	closed    bool
 }
 
 // NewSelectScan creates a new select inputScan with the specified underlying inputScan and predicate.
@@ -31,13 +33,17 @@ func (ss *SelectScan) BeforeFirst() error {
 func (ss *SelectScan) Next() (bool, error) {
 	for {
 		ok, err := ss.inputScan.Next()
-		if !ok || err != nil {
-			return ok, err
+		if err != nil || !ok {
+			return false, err
 		}
 		if ss.predicate == nil {
 			return true, nil
 		}
-		if ss.predicate.IsSatisfied(ss.inputScan) {
+		satisfied, err := ss.predicate.IsSatisfied(ss.inputScan)
+		if err != nil {
+			return false, err
+		}
+		if satisfied {
 			return true, nil
 		}
 	}
@@ -58,6 +64,11 @@ func (ss *SelectScan) GetShort(fieldName string) (int16, error) {
 	return ss.inputScan.GetShort(fieldName)
 }
 
+// GetFloat returns the float value of the specified field in the current record.
+func (ss *SelectScan) GetFloat(fieldName string) (float64, error) {
+	return ss.inputScan.GetFloat(fieldName)
+}
+
 // GetString returns the string value of the specified field in the current record.
 func (ss *SelectScan) GetString(fieldName string) (string, error) {
 	return ss.inputScan.GetString(fieldName)
@@ -83,9 +94,18 @@ func (ss *SelectScan) HasField(fieldName string) bool {
 	return ss.inputScan.HasField(fieldName)
 }
 
-// Close closes the underlying scan.
-func (ss *SelectScan) Close() {
-	ss.inputScan.Close()
+// Fields returns the fields of the underlying scan.
+func (ss *SelectScan) Fields() []types.FieldInfo {
+	return ss.inputScan.Fields()
+}
+
+// Close closes the underlying scan. Closing the scan again has no effect.
+func (ss *SelectScan) Close() error {
+	if ss.closed {
+		return nil
+	}
+	ss.closed = true
+	return ss.inputScan.Close()
 }
 
 // SetInt sets the integer value of the specified field in the current record.
@@ -115,6 +135,15 @@ func (ss *SelectScan) SetShort(fieldName string, val int16) error {
 	return updateScan.SetShort(fieldName, val)
 }
 
+// SetFloat sets the float value of the specified field in the current record.
+func (ss *SelectScan) SetFloat(fieldName string, val float64) error {
+	updateScan, ok := ss.inputScan.(scan.UpdateScan)
+	if !ok {
+		return fmt.Errorf(ErrUpdateNotSupported, ss.inputScan)
+	}
+	return updateScan.SetFloat(fieldName, val)
+}
+
 // SetString sets the string value of the specified field in the current record.
 func (ss *SelectScan) SetString(fieldName string, val string) error {
 	updateScan, ok := ss.inputScan.(scan.UpdateScan)
@@ -170,10 +199,10 @@ func (ss *SelectScan) Insert() error {
 }
 
 // GetRecordID returns the record ID of the current record.
-func (ss *SelectScan) GetRecordID() *record.ID {
+func (ss *SelectScan) GetRecordID() (*record.ID, error) {
 	updateScan, ok := ss.inputScan.(scan.UpdateScan)
 	if !ok {
-		panic(fmt.Sprintf(ErrUpdateNotSupported, ss.inputScan))
+		return nil, fmt.Errorf(ErrUpdateNotSupported, ss.inputScan)
 	}
 	return updateScan.GetRecordID()
 }
@@ -325,7 +325,8 @@ func TestSelectScan_RecordID(t *testing.T) {
 		require.NoError(t, err)
 		if id == 3 {
 			// Found Carol
-			carolRID = ts.GetRecordID()
+			carolRID, err = ts.GetRecordID()
+			require.NoError(t, err)
 			require.NotNil(t, carolRID)
 			foundCarol = true
 			break
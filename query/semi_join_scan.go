@@ -0,0 +1,163 @@
+package query
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+var _ scan.Scan = (*SemiJoinScan)(nil)
+
+// SemiJoinScan is the scan for the semijoin operator, which outputs each record of its input whose field has one
+// of the values of the subquery field, once however many records of the subquery have that value. The values of
+// the subquery are read into a set keyed like the join values of a hash join (see joinKey), so that values that
+// compare equal match whatever their type. Null values match nothing.
+// Without a field, the scan outputs every record of its input if the subquery has any record, like EXISTS.
+type SemiJoinScan struct {
+	input         scan.Scan
+	subquery      scan.Scan
+	fieldName     string
+	subqueryField string
+	// values holds the keys of the non-null values of the subquery field.
+	values map[string]struct{}
+	// exists is true if the subquery has any record.
+	exists bool
+	closed bool
+}
+
+// NewSemiJoinScan creates a semijoin scan outputting the records of the input scan whose specified field has one of
+// the values of the subquery field in the subquery scan, or, if the field is empty, every record of the input scan
+// if the subquery scan has any record. The subquery scan is read once, when the scan is created.
+func NewSemiJoinScan(input, subquery scan.Scan, fieldName, subqueryField string) (*SemiJoinScan, error) {
+	sjs := &SemiJoinScan{
+		input:         input,
+		subquery:      subquery,
+		fieldName:     fieldName,
+		subqueryField: subqueryField,
+		values:        make(map[string]struct{}),
+	}
+	if err := sjs.readSubquery(); err != nil {
+		return nil, err
+	}
+	if err := sjs.BeforeFirst(); err != nil {
+		return nil, err
+	}
+	return sjs, nil
+}
+
+// readSubquery reads the values of the subquery field, or only whether the subquery has a record without a field.
+func (sjs *SemiJoinScan) readSubquery() error {
+	if err := sjs.subquery.BeforeFirst(); err != nil {
+		return err
+	}
+	for {
+		hasNext, err := sjs.subquery.Next()
+		if err != nil || !hasNext {
+			return err
+		}
+		sjs.exists = true
+		if sjs.fieldName == "" {
+			return nil
+		}
+		value, err := sjs.subquery.GetVal(sjs.subqueryField)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			sjs.values[joinKey(value)] = struct{}{}
+		}
+	}
+}
+
+// BeforeFirst positions the scan before the first record.
+func (sjs *SemiJoinScan) BeforeFirst() error {
+	return sjs.input.BeforeFirst()
+}
+
+// Next moves to the next record of the input whose field has one of the values of the subquery.
+func (sjs *SemiJoinScan) Next() (bool, error) {
+	if !sjs.exists {
+		return false, nil
+	}
+	for {
+		hasNext, err := sjs.input.Next()
+		if err != nil || !hasNext {
+			return false, err
+		}
+		if sjs.fieldName == "" {
+			return true, nil
+		}
+		value, err := sjs.input.GetVal(sjs.fieldName)
+		if err != nil {
+			return false, err
+		}
+		if value == nil {
+			continue
+		}
+		if _, ok := sjs.values[joinKey(value)]; ok {
+			return true, nil
+		}
+	}
+}
+
+// GetInt returns the integer value of the specified field in the current record.
+func (sjs *SemiJoinScan) GetInt(fieldName string) (int, error) {
+	return sjs.input.GetInt(fieldName)
+}
+
+// GetLong returns the long value of the specified field in the current record.
+func (sjs *SemiJoinScan) GetLong(fieldName string) (int64, error) {
+	return sjs.input.GetLong(fieldName)
+}
+
+// GetShort returns the short value of the specified field in the current record.
+func (sjs *SemiJoinScan) GetShort(fieldName string) (int16, error) {
+	return sjs.input.GetShort(fieldName)
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (sjs *SemiJoinScan) GetFloat(fieldName string) (float64, error) {
+	return sjs.input.GetFloat(fieldName)
+}
+
+// GetString returns the string value of the specified field in the current record.
+func (sjs *SemiJoinScan) GetString(fieldName string) (string, error) {
+	return sjs.input.GetString(fieldName)
+}
+
+// GetBool returns the boolean value of the specified field in the current record.
+func (sjs *SemiJoinScan) GetBool(fieldName string) (bool, error) {
+	return sjs.input.GetBool(fieldName)
+}
+
+// GetDate returns the date value of the specified field in the current record.
+func (sjs *SemiJoinScan) GetDate(fieldName string) (time.Time, error) {
+	return sjs.input.GetDate(fieldName)
+}
+
+// GetVal returns the value of the specified field in the current record.
+func (sjs *SemiJoinScan) GetVal(fieldName string) (any, error) {
+	return sjs.input.GetVal(fieldName)
+}
+
+// HasField returns true if the field is in the schema of the input, the fields of the subquery not being output.
+func (sjs *SemiJoinScan) HasField(fieldName string) bool {
+	return sjs.input.HasField(fieldName)
+}
+
+// Fields returns the fields of the input.
+func (sjs *SemiJoinScan) Fields() []types.FieldInfo {
+	return sjs.input.Fields()
+}
+
+// Close closes the input and subquery scans.
+// Closing the scan again has no effect.
+func (sjs *SemiJoinScan) Close() error {
+	if sjs.closed {
+		return nil
+	}
+	sjs.closed = true
+	return errors.Join(sjs.input.Close(), sjs.subquery.Close())
+}
@@ -1,204 +1,214 @@
 package query
 
 import (
+	"errors"
 	"github.com/JyotinderSingh/dropdb/materialize"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
 	"time"
 )
 
 var _ scan.Scan = &SortScan{}
 
-// SortScan is a scan for the sort operator.
+// SortScan is a scan for the sort operator, which merges sorted runs: each record it outputs is the lowest
+// of the current records of the runs, or the one of the first run holding it if several are equal, so that
+// records that were in order across the runs stay in order.
 type SortScan struct {
-	scan1         scan.UpdateScan
-	scan2         scan.UpdateScan
-	currentScan   scan.UpdateScan
+	runs          []*materialize.TempTable
+	scans         []scan.UpdateScan
+	hasMore       []bool
+	current       int
 	comparator    *RecordComparator
-	hasMore1      bool
-	hasMore2      bool
 	savedPosition []*record.ID
+	savedCurrent  int
+	closed        bool
 }
 
-// NewSortScan creates a sort scan, given a list of one or two sorted runs.
-// If there is only one run, then s2 will be null and
-// hasMore2 will be false.
+// NewSortScan creates a sort scan, given a list of sorted runs,
+// whose files are removed when the scan is closed.
+// The run of an empty input is an empty table.
 func NewSortScan(runs []*materialize.TempTable, comparator *RecordComparator) (*SortScan, error) {
-	ss := &SortScan{
-		scan2:       nil,
-		currentScan: nil,
-		comparator:  comparator,
-		hasMore2:    false,
-	}
-
 	if len(runs) < 1 {
-		return nil, nil
-	}
-	
-	var err error
-	ss.scan1, err = runs[0].Open()
-	if err != nil {
-		return nil, err
+		return nil, errors.New("a sort scan needs at least one run")
 	}
 
-	ss.hasMore1, err = ss.scan1.Next()
-	if err != nil {
-		return nil, err
+	ss := &SortScan{
+		runs:       runs,
+		scans:      make([]scan.UpdateScan, 0, len(runs)),
+		hasMore:    make([]bool, len(runs)),
+		current:    -1,
+		comparator: comparator,
 	}
-
-	if len(runs) > 1 {
-		ss.scan2, err = runs[1].Open()
+	for _, run := range runs {
+		runScan, err := run.Open()
 		if err != nil {
-			return nil, err
-		}
-		ss.hasMore2, err = ss.scan2.Next()
-		if err != nil {
-			return nil, err
+			return nil, errors.Join(err, ss.Close())
 		}
+		ss.scans = append(ss.scans, runScan)
+	}
+	if err := ss.BeforeFirst(); err != nil {
+		return nil, errors.Join(err, ss.Close())
 	}
-
 	return ss, nil
 }
 
 // BeforeFirst positions the scan before the first record in sorted order.
 // Internally, it moves to the first record of each underlying scan.
-// The variable currentScan is set to nil, indicating that there is no current scan.
+// The current scan is reset, indicating that there is no current record.
 func (ss *SortScan) BeforeFirst() error {
-	ss.currentScan = nil
-	var err error
-
-	if err = ss.scan1.BeforeFirst(); err != nil {
-		return err
-	}
-
-	ss.hasMore1, err = ss.scan1.Next()
-	if err != nil {
-		return err
-	}
-
-	if ss.scan2 != nil {
-		if err = ss.scan2.BeforeFirst(); err != nil {
+	ss.current = -1
+	for i, runScan := range ss.scans {
+		if err := runScan.BeforeFirst(); err != nil {
 			return err
 		}
-		ss.hasMore2, err = ss.scan2.Next()
-		if err != nil {
+		var err error
+		if ss.hasMore[i], err = runScan.Next(); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
 // Next moves to the next record in sorted order.
 // First, the current scan is moved to the next record.
-// Then the lowest record of the two scans is found,
+// Then the lowest record of the scans is found,
 // and that scan is chosen to be the new current scan.
 func (ss *SortScan) Next() (bool, error) {
 	// Advance the current scan if it exists
-	if ss.currentScan != nil {
+	if ss.current >= 0 {
 		var err error
-		if ss.currentScan == ss.scan1 {
-			ss.hasMore1, err = ss.scan1.Next()
-		} else if ss.currentScan == ss.scan2 {
-			ss.hasMore2, err = ss.scan2.Next()
-		}
-		if err != nil {
+		if ss.hasMore[ss.current], err = ss.scans[ss.current].Next(); err != nil {
 			return false, err
 		}
 	}
 
-	// Determine if there are more records
-	if !ss.hasMore1 && !ss.hasMore2 {
-		return false, nil
-	}
-
-	// Choose the scan with the lowest record
-	switch {
-	case ss.hasMore1 && ss.hasMore2:
-		if ss.comparator.Compare(ss.scan1, ss.scan2) < 0 {
-			ss.currentScan = ss.scan1
-		} else {
-			ss.currentScan = ss.scan2
+	// Choose the scan with the lowest record, the first one on a tie
+	lowest := -1
+	for i, runScan := range ss.scans {
+		if !ss.hasMore[i] {
+			continue
+		}
+		if lowest >= 0 {
+			comparison, err := ss.comparator.Compare(runScan, ss.scans[lowest])
+			if err != nil {
+				return false, err
+			}
+			if comparison >= 0 {
+				continue
+			}
 		}
-	case ss.hasMore1:
-		ss.currentScan = ss.scan1
-	case ss.hasMore2:
-		ss.currentScan = ss.scan2
+		lowest = i
 	}
-
+	if lowest < 0 {
+		return false, nil
+	}
+	ss.current = lowest
 	return true, nil
 }
 
-// Close closes the two underlying scans.
-func (ss *SortScan) Close() {
-	ss.scan1.Close()
+// Close closes the underlying scans, and removes the files of the runs.
+// Closing the scan again has no effect.
+func (ss *SortScan) Close() error {
+	if ss.closed {
+		return nil
+	}
+	ss.closed = true
 
-	if ss.scan2 != nil {
-		ss.scan2.Close()
+	var errs []error
+	for _, runScan := range ss.scans {
+		errs = append(errs, runScan.Close())
 	}
+	for _, run := range ss.runs {
+		errs = append(errs, run.Remove())
+	}
+	return errors.Join(errs...)
 }
 
 // GetInt returns the integer value of the specified field in the current record.
-func (ss *SortScan) GetInt(fieldName string) (int, error) { return ss.currentScan.GetInt(fieldName) }
+func (ss *SortScan) GetInt(fieldName string) (int, error) {
+	return ss.scans[ss.current].GetInt(fieldName)
+}
 
 // GetLong returns the long value of the specified field in the current record.
 func (ss *SortScan) GetLong(fieldName string) (int64, error) {
-	return ss.currentScan.GetLong(fieldName)
+	return ss.scans[ss.current].GetLong(fieldName)
 }
 
 // GetShort returns the short value of the specified field in the current record.
 func (ss *SortScan) GetShort(fieldName string) (int16, error) {
-	return ss.currentScan.GetShort(fieldName)
+	return ss.scans[ss.current].GetShort(fieldName)
+}
+
+// GetFloat returns the float value of the specified field in the current record.
+func (ss *SortScan) GetFloat(fieldName string) (float64, error) {
+	return ss.scans[ss.current].GetFloat(fieldName)
 }
 
 // GetString returns the string value of the specified field in the current record.
 func (ss *SortScan) GetString(fieldName string) (string, error) {
-	return ss.currentScan.GetString(fieldName)
+	return ss.scans[ss.current].GetString(fieldName)
 }
 
 // GetBool returns the boolean value of the specified field in the current record.
-func (ss *SortScan) GetBool(fieldName string) (bool, error) { return ss.currentScan.GetBool(fieldName) }
+func (ss *SortScan) GetBool(fieldName string) (bool, error) {
+	return ss.scans[ss.current].GetBool(fieldName)
+}
 
 // GetDate returns the date value of the specified field in the current record.
 func (ss *SortScan) GetDate(fieldName string) (time.Time, error) {
-	return ss.currentScan.GetDate(fieldName)
+	return ss.scans[ss.current].GetDate(fieldName)
 }
 
 // HasField returns true if the current record has the specified field.
-func (ss *SortScan) HasField(fieldName string) bool { return ss.currentScan.HasField(fieldName) }
+func (ss *SortScan) HasField(fieldName string) bool { return ss.scans[0].HasField(fieldName) }
+
+// Fields returns the fields of the sorted runs.
+func (ss *SortScan) Fields() []types.FieldInfo { return ss.scans[0].Fields() }
 
 // GetVal returns the value of the specified field in the current record.
-func (ss *SortScan) GetVal(fieldName string) (any, error) { return ss.currentScan.GetVal(fieldName) }
+func (ss *SortScan) GetVal(fieldName string) (any, error) {
+	return ss.scans[ss.current].GetVal(fieldName)
+}
 
-// GetRecordID returns the record ID of the current record.
-func (ss *SortScan) GetRecordID() *record.ID { return ss.currentScan.GetRecordID() }
+// GetRecordID returns the record ID of the current record in the run holding it.
+func (ss *SortScan) GetRecordID() (*record.ID, error) {
+	if ss.current < 0 {
+		return nil, errors.New("sort scan is not positioned on a record")
+	}
+	return ss.scans[ss.current].GetRecordID()
+}
 
 // SavePosition saves the position of the current record so that it can be restored at a later time.
-func (ss *SortScan) SavePosition() {
-	recordID1 := ss.scan1.GetRecordID()
-
-	var recordID2 *record.ID
-	if ss.scan2 != nil {
-		recordID2 = ss.scan2.GetRecordID()
+// The position of every run is saved, along with the run holding the current record.
+func (ss *SortScan) SavePosition() error {
+	savedPosition := make([]*record.ID, len(ss.scans))
+	for i, runScan := range ss.scans {
+		if !ss.hasMore[i] {
+			continue
+		}
+		var err error
+		if savedPosition[i], err = runScan.GetRecordID(); err != nil {
+			return err
+		}
 	}
-
-	ss.savedPosition = []*record.ID{recordID1, recordID2}
+	ss.savedPosition, ss.savedCurrent = savedPosition, ss.current
+	return nil
 }
 
-// RestorePosition restores the position of the current record to the last saved position.
+// RestorePosition restores the position of the current record to the last saved position,
+// so that the records following it are output again.
+// A run that was read to its end when the position was saved is still at its end, so it is not moved.
 func (ss *SortScan) RestorePosition() error {
-	recordID1 := ss.savedPosition[0]
-	recordID2 := ss.savedPosition[1]
-
-	if err := ss.scan1.MoveToRecordID(recordID1); err != nil {
-		return err
-	}
-
-	if ss.scan2 != nil {
-		if err := ss.scan2.MoveToRecordID(recordID2); err != nil {
+	for i, runScan := range ss.scans {
+		ss.hasMore[i] = ss.savedPosition[i] != nil
+		if !ss.hasMore[i] {
+			continue
+		}
+		if err := runScan.MoveToRecordID(ss.savedPosition[i]); err != nil {
 			return err
 		}
 	}
-
+	ss.current = ss.savedCurrent
 	return nil
 }
@@ -256,8 +256,12 @@ func TestSortScan_SaveRestorePosition(t *testing.T) {
 	require.NoError(t, err)
 	defer ss.Close()
 
-	// Move to Bob's record (id=2)
+	// Before the first record, there is no record whose ID could be returned.
 	require.NoError(t, ss.BeforeFirst())
+	_, err = ss.GetRecordID()
+	assert.Error(t, err)
+
+	// Move to Bob's record (id=2)
 	hasNext, err := ss.Next()
 	require.NoError(t, err)
 	require.True(t, hasNext)
@@ -267,7 +271,7 @@ func TestSortScan_SaveRestorePosition(t *testing.T) {
 	require.True(t, hasNext)
 
 	// Save position at Bob's record
-	ss.SavePosition()
+	require.NoError(t, ss.SavePosition())
 
 	// Move forward to Dave
 	hasNext, err = ss.Next()
@@ -0,0 +1,143 @@
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// Ensure StatsScan implements the Scan interface.
+var _ scan.Scan = (*StatsScan)(nil)
+
+const (
+	// StatNameField is the field of a stats listing that holds the name of the statistic.
+	StatNameField = "stat_name"
+	// StatValueField is the field of a stats listing that holds the value of the statistic.
+	StatValueField = "stat_value"
+
+	// maxStatNameLength is the maximum length of the name of a statistic.
+	maxStatNameLength = 20
+)
+
+// Stat is a named statistic of the database.
+type Stat struct {
+	Name  string
+	Value int
+}
+
+// StatsSchema returns the schema of the records returned by a StatsScan.
+func StatsSchema() *record.Schema {
+	schema := record.NewSchema()
+	schema.AddStringField(StatNameField, maxStatNameLength)
+	schema.AddIntField(StatValueField)
+	return schema
+}
+
+// StatsScan is a scan over a snapshot of statistics, returning one record per statistic
+// with the fields described by StatsSchema.
+type StatsScan struct {
+	stats   []Stat
+	current int
+}
+
+// NewStatsScan creates a scan over the specified statistics.
+func NewStatsScan(stats []Stat) *StatsScan {
+	return &StatsScan{stats: stats, current: -1}
+}
+
+// BeforeFirst positions the scan before the first statistic.
+func (ss *StatsScan) BeforeFirst() error {
+	ss.current = -1
+	return nil
+}
+
+// Next moves to the next statistic.
+func (ss *StatsScan) Next() (bool, error) {
+	if ss.current+1 >= len(ss.stats) {
+		return false, nil
+	}
+	ss.current++
+	return true, nil
+}
+
+// GetVal returns the value of the specified field for the current statistic.
+func (ss *StatsScan) GetVal(fieldName string) (any, error) {
+	if ss.current < 0 || ss.current >= len(ss.stats) {
+		return nil, fmt.Errorf("no current statistic")
+	}
+	switch fieldName {
+	case StatNameField:
+		return ss.stats[ss.current].Name, nil
+	case StatValueField:
+		return ss.stats[ss.current].Value, nil
+	default:
+		return nil, fmt.Errorf("field %s not found", fieldName)
+	}
+}
+
+// GetString returns the name of the current statistic.
+func (ss *StatsScan) GetString(fieldName string) (string, error) {
+	if fieldName != StatNameField {
+		return "", fmt.Errorf("field %s is not a string", fieldName)
+	}
+	val, err := ss.GetVal(fieldName)
+	if err != nil {
+		return "", err
+	}
+	return val.(string), nil
+}
+
+// GetInt returns the value of the current statistic.
+func (ss *StatsScan) GetInt(fieldName string) (int, error) {
+	if fieldName != StatValueField {
+		return 0, fmt.Errorf("field %s is not an int", fieldName)
+	}
+	val, err := ss.GetVal(fieldName)
+	if err != nil {
+		return 0, err
+	}
+	return val.(int), nil
+}
+
+// GetLong returns an error, since the fields of a stats listing are a string and an int.
+func (ss *StatsScan) GetLong(fieldName string) (int64, error) {
+	return 0, fmt.Errorf("field %s is not a long", fieldName)
+}
+
+// GetShort returns an error, since the fields of a stats listing are a string and an int.
+func (ss *StatsScan) GetShort(fieldName string) (int16, error) {
+	return 0, fmt.Errorf("field %s is not a short", fieldName)
+}
+
+// GetFloat returns an error, since the fields of a stats listing are a string and an int.
+func (ss *StatsScan) GetFloat(fieldName string) (float64, error) {
+	return 0, fmt.Errorf("field %s is not a float", fieldName)
+}
+
+// GetBool returns an error, since the fields of a stats listing are a string and an int.
+func (ss *StatsScan) GetBool(fieldName string) (bool, error) {
+	return false, fmt.Errorf("field %s is not a bool", fieldName)
+}
+
+// GetDate returns an error, since the fields of a stats listing are a string and an int.
+func (ss *StatsScan) GetDate(fieldName string) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("field %s is not a date", fieldName)
+}
+
+// HasField returns true if the specified field is described by StatsSchema.
+func (ss *StatsScan) HasField(fieldName string) bool {
+	return StatsSchema().HasField(fieldName)
+}
+
+// Fields returns the fields of the stats listing, which are described by StatsSchema.
+func (ss *StatsScan) Fields() []types.FieldInfo {
+	return StatsSchema().FieldInfos()
+}
+
+// Close does nothing, since the statistics are held in memory.
+func (ss *StatsScan) Close() error {
+	return nil
+}
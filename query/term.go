@@ -1,12 +1,18 @@
 package query
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/JyotinderSingh/dropdb/plan"
 	"github.com/JyotinderSingh/dropdb/record"
 	"github.com/JyotinderSingh/dropdb/scan"
 	"github.com/JyotinderSingh/dropdb/types"
 )
 
+// ErrTypeMismatch is returned for predicates that compare values of incompatible types.
+var ErrTypeMismatch = errors.New("type mismatch")
+
 type Term struct {
 	lhs *Expression
 	rhs *Expression
@@ -18,26 +24,35 @@ func NewTerm(lhs, rhs *Expression, op types.Operator) *Term {
 	return &Term{lhs: lhs, rhs: rhs, op: op}
 }
 
-func (t *Term) IsSatisfied(inputScan scan.Scan) bool {
-	var lhsVal, rhsVal any
-	var err error
-	if lhsVal, err = t.lhs.Evaluate(inputScan); err != nil {
-		return false
+// IsSatisfied returns true if the term holds for the current record of the specified scan.
+// A comparison does not hold if either of its values is null, which only "is null" and "is not null" test.
+func (t *Term) IsSatisfied(inputScan scan.Scan) (bool, error) {
+	lhsVal, err := t.lhs.Evaluate(inputScan)
+	if err != nil {
+		return false, err
 	}
-
-	if rhsVal, err = t.rhs.Evaluate(inputScan); err != nil {
-		return false
+	rhsVal, err := t.rhs.Evaluate(inputScan)
+	if err != nil {
+		return false, err
 	}
 
 	switch t.op {
 	case types.EQ:
-		return lhsVal == rhsVal
+		return types.CompareSupportedTypes(lhsVal, rhsVal, types.EQ), nil
 	case types.NE:
-		return lhsVal != rhsVal
+		return lhsVal != nil && rhsVal != nil && !types.CompareSupportedTypes(lhsVal, rhsVal, types.EQ), nil
 	case types.LT, types.LE, types.GT, types.GE:
-		return types.CompareSupportedTypes(lhsVal, rhsVal, t.op)
+		return types.CompareSupportedTypes(lhsVal, rhsVal, t.op), nil
+	case types.IS:
+		return lhsVal == nil, nil
+	case types.ISNOT:
+		return lhsVal != nil, nil
+	case types.LIKE, types.NOTLIKE:
+		value, isString := lhsVal.(string)
+		pattern, isPattern := rhsVal.(string)
+		return isString && isPattern && types.CompareSupportedTypes(value, pattern, t.op), nil
 	default:
-		return false
+		return false, nil
 	}
 }
 
@@ -100,6 +115,12 @@ func reductionForConstantComparison(distinctValues int, op types.Operator) int {
 	case types.LT, types.LE, types.GT, types.GE:
 		// Assume uniform distribution; halve the distinct values for range operators.
 		return 2
+	case types.IS:
+		// Assume null is as frequent as any other value.
+		return max(1, distinctValues)
+	case types.LIKE:
+		// Nothing is known of how many values a pattern matches; assume it is as selective as a range.
+		return 2
 	default:
 		return 1 // Default for unsupported operators, assume no reduction.
 	}
@@ -113,9 +134,9 @@ func (t *Term) EquatesWithConstant(fieldName string) any {
 	if t.op != types.EQ { // Explicit check for equality
 		return nil
 	}
-	if t.lhs.IsFieldName() && t.lhs.asFieldName() == fieldName && !t.rhs.IsFieldName() {
+	if t.lhs.IsFieldName() && t.lhs.asFieldName() == fieldName && t.rhs.isConstant() {
 		return t.rhs.asConstant()
-	} else if t.rhs.IsFieldName() && t.rhs.asFieldName() == fieldName && !t.lhs.IsFieldName() {
+	} else if t.rhs.IsFieldName() && t.rhs.asFieldName() == fieldName && t.lhs.isConstant() {
 		return t.lhs.asConstant()
 	}
 	return nil
@@ -129,11 +150,11 @@ func (t *Term) ComparesWithConstant(fieldName string) (types.Operator, any) {
 	// If not, return (NONE, nil).
 
 	// LHS is the field, RHS is a constant
-	if t.lhs.IsFieldName() && t.lhs.asFieldName() == fieldName && !t.rhs.IsFieldName() {
+	if t.lhs.IsFieldName() && t.lhs.asFieldName() == fieldName && t.rhs.isConstant() {
 		return t.op, t.rhs.asConstant()
 	}
 	// RHS is the field, LHS is a constant
-	if t.rhs.IsFieldName() && t.rhs.asFieldName() == fieldName && !t.lhs.IsFieldName() {
+	if t.rhs.IsFieldName() && t.rhs.asFieldName() == fieldName && t.lhs.isConstant() {
 		return t.op, t.lhs.asConstant()
 	}
 	return types.NONE, nil
@@ -155,6 +176,198 @@ func (t *Term) EquatesWithField(fieldName string) string {
 	return ""
 }
 
+// Implies returns true if every record satisfying this term also satisfies the other term.
+// The check is conservative: it reasons about terms comparing the same field with a constant,
+// such as "F > 10" implying "F >= 5", and otherwise only recognizes identical field comparisons.
+// A return value of false means the implication could not be established.
+func (t *Term) Implies(other *Term) bool {
+	fieldName, op, val, ok := t.fieldComparison()
+	otherFieldName, otherOp, otherVal, otherOk := other.fieldComparison()
+	if !ok || !otherOk {
+		return t.lhs.IsFieldName() && t.rhs.IsFieldName() && t.String() == other.String()
+	}
+	if fieldName != otherFieldName {
+		return false
+	}
+
+	switch op {
+	case types.EQ:
+		// "F = c" implies any term that the constant c itself satisfies, and "F is not null".
+		return otherOp == types.ISNOT || types.CompareSupportedTypes(val, otherVal, otherOp)
+	case types.NE:
+		return otherOp == types.ISNOT || otherOp == types.NE && types.CompareSupportedTypes(val, otherVal, types.EQ)
+	case types.IS:
+		return otherOp == types.IS
+	case types.ISNOT:
+		return otherOp == types.ISNOT
+	case types.LIKE, types.NOTLIKE:
+		return otherOp == types.ISNOT || otherOp == op && val == otherVal
+	case types.LT, types.LE:
+		// this term bounds F from above by val.
+		switch otherOp {
+		case types.LT, types.NE:
+			if op == types.LE {
+				return types.CompareSupportedTypes(val, otherVal, types.LT)
+			}
+			return types.CompareSupportedTypes(val, otherVal, types.LE)
+		case types.LE:
+			return types.CompareSupportedTypes(val, otherVal, types.LE)
+		case types.ISNOT:
+			return true
+		}
+	case types.GT, types.GE:
+		// this term bounds F from below by val.
+		switch otherOp {
+		case types.GT, types.NE:
+			if op == types.GE {
+				return types.CompareSupportedTypes(val, otherVal, types.GT)
+			}
+			return types.CompareSupportedTypes(val, otherVal, types.GE)
+		case types.GE:
+			return types.CompareSupportedTypes(val, otherVal, types.GE)
+		case types.ISNOT:
+			return true
+		}
+	}
+	return false
+}
+
+// fieldComparison returns the term in the form "F op c", where F is a field name and c a constant,
+// flipping the operator if the constant is on the left-hand side.
+// The last return value is false if the term does not compare a field with a constant,
+// or matches a constant with a pattern read from a field, which cannot be flipped.
+func (t *Term) fieldComparison() (string, types.Operator, any, bool) {
+	if t.lhs.IsFieldName() && t.rhs.isConstant() {
+		return t.lhs.asFieldName(), t.op, t.rhs.asConstant(), true
+	}
+	if t.rhs.IsFieldName() && t.lhs.isConstant() && !t.matchesPattern() {
+		return t.rhs.asFieldName(), flipOperator(t.op), t.lhs.asConstant(), true
+	}
+	return "", types.NONE, nil, false
+}
+
+// flipOperator returns the operator that gives the same result when the operands are swapped.
+func flipOperator(op types.Operator) types.Operator {
+	switch op {
+	case types.LT:
+		return types.GT
+	case types.LE:
+		return types.GE
+	case types.GT:
+		return types.LT
+	case types.GE:
+		return types.LE
+	default:
+		return op
+	}
+}
+
+// Negate returns the term holding for the records whose values this term compares, but does not hold for,
+// such as "a >= 5" for "a < 5". Like this term, the negated term does not hold for a null value.
+func (t *Term) Negate() *Term {
+	return NewTerm(t.lhs, t.rhs, negateOperator(t.op))
+}
+
+// renameFields returns a copy of the term reading the renamed fields (see Predicate#RenameFields).
+func (t *Term) renameFields(names map[string]string) *Term {
+	return NewTerm(t.lhs.renameFields(names), t.rhs.renameFields(names), t.op)
+}
+
+// negateOperator returns the operator whose result is the opposite of the specified one's for comparable operands.
+func negateOperator(op types.Operator) types.Operator {
+	switch op {
+	case types.EQ:
+		return types.NE
+	case types.NE:
+		return types.EQ
+	case types.LT:
+		return types.GE
+	case types.LE:
+		return types.GT
+	case types.GT:
+		return types.LE
+	case types.GE:
+		return types.LT
+	case types.IS:
+		return types.ISNOT
+	case types.ISNOT:
+		return types.IS
+	case types.LIKE:
+		return types.NOTLIKE
+	case types.NOTLIKE:
+		return types.LIKE
+	default:
+		return op
+	}
+}
+
+// CoerceConstants converts a constant compared with a field of the schema to the field's type
+// (see types.CoerceValue), so that, for example, "code = 42" matches records of a short field.
+// Constants that cannot be converted are left unchanged.
+func (t *Term) CoerceConstants(schema *record.Schema) {
+	if t.lhs.IsFieldName() && schema.HasField(t.lhs.asFieldName()) {
+		t.rhs.coerceTo(schema.Type(t.lhs.asFieldName()))
+	}
+	if t.rhs.IsFieldName() && schema.HasField(t.rhs.asFieldName()) {
+		t.lhs.coerceTo(schema.Type(t.rhs.asFieldName()))
+	}
+}
+
+// CheckTypes returns an error wrapping ErrTypeMismatch if the term compares values
+// of incompatible types, which no record can satisfy. Fields are resolved against
+// the specified schema, and a term reading a field that is not in it is not checked.
+// Integers of every width are comparable with one another, and a constant is comparable
+// with a field if it can be converted to the field's type (see types.CoerceValue);
+// integers that do not fit the field are still comparable with it. The operands of arithmetic operations
+// must be numbers, and those of LIKE must be strings.
+func (t *Term) CheckTypes(schema *record.Schema) error {
+	if err := t.lhs.checkTypes(schema); err != nil {
+		return err
+	}
+	if err := t.rhs.checkTypes(schema); err != nil {
+		return err
+	}
+	lhsType, lhsOk := t.lhs.typeIn(schema)
+	rhsType, rhsOk := t.rhs.typeIn(schema)
+	if !lhsOk || !rhsOk {
+		return nil
+	}
+
+	switch {
+	case t.matchesPattern() && (lhsType != types.Varchar || rhsType != types.Varchar):
+		return fmt.Errorf("%w: %s of type %s matched with pattern %s of type %s in %s",
+			ErrTypeMismatch, t.lhs, lhsType, t.rhs, rhsType, t)
+	case t.lhs.IsFieldName() && t.rhs.isConstant():
+		return checkConstantType(t.lhs.asFieldName(), lhsType, t.rhs.asConstant())
+	case t.rhs.IsFieldName() && t.lhs.isConstant():
+		return checkConstantType(t.rhs.asFieldName(), rhsType, t.lhs.asConstant())
+	case !types.AreComparable(lhsType, rhsType):
+		return fmt.Errorf("%w: %s of type %s compared with %s of type %s in %s",
+			ErrTypeMismatch, t.lhs, lhsType, t.rhs, rhsType, t)
+	}
+	return nil
+}
+
+// matchesPattern returns true if the term matches its left-hand side with the pattern of its right-hand side.
+func (t *Term) matchesPattern() bool {
+	return t.op == types.LIKE || t.op == types.NOTLIKE
+}
+
+// checkConstantType returns an error if the constant is not comparable with the field of the specified type.
+func checkConstantType(fieldName string, fieldType types.SchemaType, constant any) error {
+	if _, err := types.CoerceValue(constant, fieldType); err == nil || errors.Is(err, types.ErrValueOutOfRange) {
+		return nil
+	}
+	constantType, _ := types.TypeOf(constant)
+	return fmt.Errorf("%w: field %s of type %s compared with %s constant %v",
+		ErrTypeMismatch, fieldName, fieldType, constantType, constant)
+}
+
+// Fields returns the names of the fields the term reads.
+func (t *Term) Fields() []string {
+	return append(t.lhs.Fields(), t.rhs.Fields()...)
+}
+
 // AppliesTo returns true if both of the term's expressions
 // apply to the specified schema.
 func (t *Term) AppliesTo(schema *record.Schema) bool {
@@ -162,5 +375,19 @@ func (t *Term) AppliesTo(schema *record.Schema) bool {
 }
 
 func (t *Term) String() string {
-	return t.lhs.String() + " " + t.op.String() + " " + t.rhs.String()
+	text, _ := t.Format(formatValue)
+	return text
+}
+
+// Format returns the text of the term like String, writing its constants with the specified function.
+func (t *Term) Format(constant func(val any) (string, error)) (string, error) {
+	lhs, err := t.lhs.Format(constant)
+	if err != nil {
+		return "", err
+	}
+	rhs, err := t.rhs.Format(constant)
+	if err != nil {
+		return "", err
+	}
+	return lhs + " " + t.op.String() + " " + rhs, nil
 }
@@ -8,6 +8,7 @@ import (
 const (
 	LongAlignment    = 8
 	ShortAlignment   = 2
+	FloatAlignment   = 8
 	BooleanAlignment = 1
 	DateAlignment    = 8
 	VarcharAlignment = 1 // No alignment for strings, packed tightly
@@ -22,6 +23,8 @@ func alignmentRequirement(fieldType types.SchemaType) int {
 		return LongAlignment
 	case types.Short:
 		return ShortAlignment
+	case types.Float:
+		return FloatAlignment
 	case types.Boolean:
 		return BooleanAlignment
 	case types.Date:
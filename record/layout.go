@@ -4,16 +4,23 @@ import (
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/types"
+	"slices"
 	"sort"
 )
 
 // Layout describes the structure of a record.
 // It contains the name, type, length, and offset of
 // each field of a given table.
+//
+// A slot starts with the empty/in-use flag of its record, followed by the null bitmap of the record,
+// which holds one bit per field, in the order of the fields of the schema, set if the field is null.
+// The bitmap is made of 8-byte words, so that the fields after it keep their alignment.
 type Layout struct {
 	schema   *Schema
 	offsets  map[string]int
 	slotSize int
+	// nullBits maps each field to the index of its bit in the null bitmap.
+	nullBits map[string]int
 }
 
 // NewLayout creates a new layout for a given schema.
@@ -24,8 +31,9 @@ type Layout struct {
 // requirements first, which minimizes padding between fields.
 func NewLayout(schema *Schema) *Layout {
 	layout := &Layout{
-		schema:  schema,
-		offsets: make(map[string]int),
+		schema:   schema,
+		offsets:  make(map[string]int),
+		nullBits: nullBits(schema),
 	}
 
 	// Determine the alignment and sizes of fields
@@ -37,12 +45,13 @@ func NewLayout(schema *Schema) *Layout {
 	// Sort fields by their alignment requirements in descending order.
 	// This ensures that fields with larger alignment requirements are placed first, which
 	// minimizes padding between fields and reduces the overall size of the record.
-	fields := schema.Fields()
+	// The fields are sorted on a copy, so that the schema keeps the declared order of its fields.
+	fields := slices.Clone(schema.Fields())
 	sort.Slice(fields, func(i, j int) bool {
 		return fieldAlignments[fields[i]] > fieldAlignments[fields[j]]
 	})
 
-	pos := types.IntSize // Reserve space for the empty/in-use field.
+	pos := types.IntSize + nullBitmapSize(schema) // Reserve space for the empty/in-use field and the null bitmap.
 	for _, field := range fields {
 		align := fieldAlignments[field]
 
@@ -75,7 +84,49 @@ func NewLayoutFromMetadata(schema *Schema, offsets map[string]int, slotSize int)
 		schema:   schema,
 		offsets:  offsets,
 		slotSize: slotSize,
+		nullBits: nullBits(schema),
+	}
+}
+
+// Projection returns a layout of the same slots that only holds the specified fields of this layout, in the order
+// of its schema, and ignores the fields it does not hold. The offsets, null bits and slot size come from this
+// layout, so that the projection reads the fields of the records written with it. A projection is only meant for
+// reading records: formatting a page or clearing the null bitmap of a slot with it would leave the other fields as they are.
+func (l *Layout) Projection(fields []string) *Layout {
+	schema := NewSchema()
+	projection := &Layout{
+		schema:   schema,
+		offsets:  make(map[string]int, len(fields)),
+		slotSize: l.slotSize,
+		nullBits: make(map[string]int, len(fields)),
+	}
+	for _, field := range l.schema.Fields() {
+		if !slices.Contains(fields, field) {
+			continue
+		}
+		schema.AddField(field, l.schema.Type(field), l.schema.Length(field))
+		projection.offsets[field] = l.offsets[field]
+		projection.nullBits[field] = l.nullBits[field]
 	}
+	return projection
+}
+
+// nullBitmapWordSize is the size in bytes of a word of the null bitmap of a slot.
+const nullBitmapWordSize = 8
+
+// nullBitmapSize returns the size in bytes of the null bitmap of the records of the schema.
+func nullBitmapSize(schema *Schema) int {
+	bits := nullBitmapWordSize * 8
+	return (len(schema.Fields()) + bits - 1) / bits * nullBitmapWordSize
+}
+
+// nullBits returns the index of the bit of each field of the schema in the null bitmap.
+func nullBits(schema *Schema) map[string]int {
+	bits := make(map[string]int, len(schema.Fields()))
+	for i, field := range schema.Fields() {
+		bits[field] = i
+	}
+	return bits
 }
 
 // Schema returns the schema of the table's records.
@@ -93,6 +144,14 @@ func (l *Layout) SlotSize() int {
 	return l.slotSize
 }
 
+// nullBit returns the offset within a slot of the word of the null bitmap holding the bit of the specified field,
+// and the mask of the bit within the word.
+func (l *Layout) nullBit(fieldName string) (int, int64) {
+	bit := l.nullBits[fieldName]
+	bitsPerWord := nullBitmapWordSize * 8
+	return types.IntSize + bit/bitsPerWord*nullBitmapWordSize, int64(1) << (bit % bitsPerWord)
+}
+
 // lengthInBytes returns the length of a field in bytes.
 func (l *Layout) lengthInBytes(fieldName string) int {
 	fieldType := l.schema.Type(fieldName)
@@ -104,6 +163,8 @@ func (l *Layout) lengthInBytes(fieldName string) int {
 		return 8 // 8 bytes for long
 	case types.Short:
 		return 2 // 2 bytes for short
+	case types.Float:
+		return 8 // 8 bytes for float (IEEE 754 double precision)
 	case types.Boolean:
 		return 1 // 1 byte for boolean
 	case types.Date:
@@ -3,6 +3,7 @@ package record
 import (
 	"github.com/JyotinderSingh/dropdb/types"
 	"github.com/stretchr/testify/assert"
+	"slices"
 	"testing"
 )
 
@@ -24,7 +25,7 @@ func TestNewLayout(t *testing.T) {
 				return s
 			},
 			expectedOrder: []string{"bigNum", "counter", "flag"},
-			expectedSize:  24, // utils.IntSize(header) + 8(long) + 2(short) + 1(bool) + padding
+			expectedSize:  32, // utils.IntSize(header) + 8(null bitmap) + 8(long) + 2(short) + 1(bool) + padding
 			expectedAlign: map[string]int{
 				"bigNum":  8,
 				"counter": 2,
@@ -41,7 +42,7 @@ func TestNewLayout(t *testing.T) {
 				return s
 			},
 			expectedOrder: []string{"timestamp", "count", "name"},
-			expectedSize:  72, // utils.IntSize(header) + 8(date) + utils.IntSize(int) + (utils.IntSize + 10*4)(varchar)
+			expectedSize:  80, // utils.IntSize(header) + 8(null bitmap) + 8(date) + utils.IntSize(int) + (utils.IntSize + 10*4)(varchar)
 			expectedAlign: map[string]int{
 				"timestamp": 8,
 				"count":     types.IntSize,
@@ -61,7 +62,7 @@ func TestNewLayout(t *testing.T) {
 				return s
 			},
 			expectedOrder: []string{"created", "count", "id", "type", "active", "name"},
-			expectedSize:  104, // utils.IntSize(header) + 8(date) + 8(long) + utils.IntSize(int) + 2(short) + 1(bool) + (utils.IntSize + 15*4)(varchar)
+			expectedSize:  112, // utils.IntSize(header) + 8(null bitmap) + 8(date) + 8(long) + utils.IntSize(int) + 2(short) + 1(bool) + (utils.IntSize + 15*4)(varchar)
 			expectedAlign: map[string]int{
 				"created": 8,
 				"id":      8,
@@ -76,19 +77,14 @@ func TestNewLayout(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			schema := tt.schemaBuilder()
+			declaredOrder := slices.Clone(schema.Fields())
 			layout := NewLayout(schema)
 
 			// Verify field order through offsets
-			var lastOffset int
-			var fields []string
-			for _, field := range schema.Fields() {
-				offset := layout.Offset(field)
-				if offset > lastOffset {
-					fields = append(fields, field)
-					lastOffset = offset
-				}
-			}
+			fields := slices.Clone(schema.Fields())
+			slices.SortFunc(fields, func(a, b string) int { return layout.Offset(a) - layout.Offset(b) })
 			assert.Equal(t, tt.expectedOrder, fields, "Field order mismatch")
+			assert.Equal(t, declaredOrder, schema.Fields(), "The schema keeps the declared order of its fields")
 
 			// Verify slot size
 			assert.Equal(t, tt.expectedSize, layout.SlotSize(), "Slot size mismatch")
@@ -143,7 +139,7 @@ func TestLayoutPaddingOptimization(t *testing.T) {
 				s.AddLongField("l2") // 8 bytes
 				return s
 			},
-			expectedSize: 32, // utils.IntSize(header) + 8(long) + 8(long) + 1(bool) + 1(bool) + padding
+			expectedSize: 40, // utils.IntSize(header) + 8(null bitmap) + 8(long) + 8(long) + 1(bool) + 1(bool) + padding
 		},
 		{
 			name: "mixed field sizes with varchar",
@@ -155,7 +151,7 @@ func TestLayoutPaddingOptimization(t *testing.T) {
 				s.AddLongField("l1")      // 8 bytes
 				return s
 			},
-			expectedSize: 48, // utils.IntSize(header) + 8(long) + utils.IntSize(int) + (utils.IntSize + 3*4)(varchar) + 1(bool) + padding
+			expectedSize: 56, // utils.IntSize(header) + 8(null bitmap) + 8(long) + utils.IntSize(int) + (utils.IntSize + 3*4)(varchar) + 1(bool) + padding
 		},
 	}
 
@@ -178,3 +174,28 @@ func TestLayoutPaddingOptimization(t *testing.T) {
 		})
 	}
 }
+
+func TestLayoutProjection(t *testing.T) {
+	schema := NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("name", 20)
+	schema.AddBoolField("active")
+	schema.AddLongField("count")
+	layout := NewLayout(schema)
+
+	// The projection keeps the order of the schema, and ignores the fields the layout does not have.
+	projection := layout.Projection([]string{"count", "name", "missing"})
+	assert.Equal(t, []string{"name", "count"}, projection.Schema().Fields())
+	assert.Equal(t, types.Varchar, projection.Schema().Type("name"))
+	assert.Equal(t, 20, projection.Schema().Length("name"))
+
+	// The fields are read at the positions of the layout of the table.
+	assert.Equal(t, layout.SlotSize(), projection.SlotSize())
+	for _, field := range []string{"name", "count"} {
+		assert.Equal(t, layout.Offset(field), projection.Offset(field), field)
+		offset, mask := layout.nullBit(field)
+		projectedOffset, projectedMask := projection.nullBit(field)
+		assert.Equal(t, offset, projectedOffset, field)
+		assert.Equal(t, mask, projectedMask, field)
+	}
+}
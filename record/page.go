@@ -37,6 +37,19 @@ func NewPage(transaction *tx.Transaction, block *file.BlockId, layout *Layout) (
 	}, nil
 }
 
+// NewSequentialPage creates a new page for a block that a scan reached by moving forward
+// through the file, so that the following blocks can be prefetched.
+func NewSequentialPage(transaction *tx.Transaction, block *file.BlockId, layout *Layout) (*Page, error) {
+	if err := transaction.PinSequential(block); err != nil {
+		return nil, err
+	}
+	return &Page{
+		tx:     transaction,
+		block:  block,
+		layout: layout,
+	}, nil
+}
+
 // GetInt returns the integer value stored for the specified field of a specified slot.
 func (p *Page) GetInt(slot int, fieldName string) (int, error) {
 	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
@@ -73,49 +86,212 @@ func (p *Page) GetShort(slot int, fieldName string) (int16, error) {
 	return p.tx.GetShort(p.block, fieldPosition)
 }
 
+// GetFloat returns the float value stored for the specified field of a specified slot.
+func (p *Page) GetFloat(slot int, fieldName string) (float64, error) {
+	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
+	return p.tx.GetFloat(p.block, fieldPosition)
+}
+
+// GetRawVal decodes the value stored for the specified field of a specified slot according to the type of the field,
+// or returns nil if the field is null. Unlike the typed getters, it does not trust the contents of the block:
+// it returns an error rather than panicking if the field lies outside the block, or if its bytes are not a valid value,
+// such as a string whose length prefix exceeds the length of the field. It is meant for inspecting possibly corrupted blocks.
+func (p *Page) GetRawVal(slot int, fieldName string) (any, error) {
+	schema := p.layout.Schema()
+	if !schema.HasField(fieldName) {
+		return nil, fmt.Errorf("field %s not found", fieldName)
+	}
+
+	position, mask := p.nullBitPosition(slot, fieldName)
+	bitmapWord, err := p.tx.GetRawBytes(p.block, position, nullBitmapWordSize)
+	if err != nil {
+		return nil, err
+	}
+	if file.NewPageFromBytes(bitmapWord).GetLong(0)&mask != 0 {
+		return nil, nil
+	}
+
+	fieldPosition := p.FieldOffset(slot, fieldName)
+	raw, err := p.tx.GetRawBytes(p.block, fieldPosition, p.layout.lengthInBytes(fieldName))
+	if err != nil {
+		return nil, err
+	}
+
+	contents := file.NewPageFromBytes(raw)
+	switch schema.Type(fieldName) {
+	case types.Integer:
+		return contents.GetInt(0), nil
+	case types.Long:
+		return contents.GetLong(0), nil
+	case types.Short:
+		return contents.GetShort(0), nil
+	case types.Float:
+		return contents.GetFloat(0), nil
+	case types.Boolean:
+		return contents.GetBool(0), nil
+	case types.Date:
+		return contents.GetDate(0), nil
+	case types.Varchar:
+		length := contents.GetInt(0)
+		if length < 0 || length > len(raw)-types.IntSize {
+			return nil, fmt.Errorf("string length %d at offset %d does not fit the %d bytes of field %s", length, fieldPosition, len(raw)-types.IntSize, fieldName)
+		}
+		return contents.GetString(0)
+	default:
+		return nil, fmt.Errorf("field %s has unknown type %d", fieldName, schema.Type(fieldName))
+	}
+}
+
+// RawFlag returns the empty/in-use flag stored for the specified slot.
+// In a corrupted block, the flag may be neither FlagEmpty nor FlagUsed.
+func (p *Page) RawFlag(slot int) (int, error) {
+	if !p.isValidSlot(slot) {
+		return 0, fmt.Errorf("slot %d is outside of block %s", slot, p.block)
+	}
+	return p.tx.GetInt(p.block, p.offset(slot))
+}
+
+// FieldOffset returns the offset of the specified field of a specified slot,
+// which is the number of bytes from the start of the block to the start of the field.
+func (p *Page) FieldOffset(slot int, fieldName string) int {
+	return p.offset(slot) + p.layout.Offset(fieldName)
+}
+
+// NumSlots returns the number of record slots that fit in the block.
+func (p *Page) NumSlots() int {
+	return p.tx.BlockSize() / p.layout.SlotSize()
+}
+
 // SetInt stores an integer value for the specified field of a specified slot.
 func (p *Page) SetInt(slot int, fieldName string, val int) error {
+	if err := p.clearNull(slot, fieldName); err != nil {
+		return err
+	}
 	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
 	return p.tx.SetInt(p.block, fieldPosition, val, true)
 }
 
 // SetLong stores a long value for the specified field of a specified slot.
 func (p *Page) SetLong(slot int, fieldName string, val int64) error {
+	if err := p.clearNull(slot, fieldName); err != nil {
+		return err
+	}
 	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
 	return p.tx.SetLong(p.block, fieldPosition, val, true)
 }
 
 // SetString stores a string value for the specified field of a specified slot.
+// It returns an error wrapping types.ErrValueTooLong if the encoded string does not fit in the bytes reserved
+// for the field, which would overwrite the fields after it.
 func (p *Page) SetString(slot int, fieldName string, val string) error {
+	if reserved := p.layout.lengthInBytes(fieldName) - types.IntSize; len(val) > reserved {
+		return fmt.Errorf("%w: field %s of %s holds at most %d bytes, but the value has %d",
+			types.ErrValueTooLong, fieldName, p.block.Filename(), reserved, len(val))
+	}
+	if err := p.clearNull(slot, fieldName); err != nil {
+		return err
+	}
 	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
 	return p.tx.SetString(p.block, fieldPosition, val, true)
 }
 
 // SetBool stores a boolean value for the specified field of a specified slot.
 func (p *Page) SetBool(slot int, fieldName string, val bool) error {
+	if err := p.clearNull(slot, fieldName); err != nil {
+		return err
+	}
 	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
 	return p.tx.SetBool(p.block, fieldPosition, val, true)
 }
 
 // SetDate stores a date value for the specified field of a specified slot.
 func (p *Page) SetDate(slot int, fieldName string, val time.Time) error {
+	if err := p.clearNull(slot, fieldName); err != nil {
+		return err
+	}
 	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
 	return p.tx.SetDate(p.block, fieldPosition, val, true)
 }
 
 // SetShort stores a short value for the specified field of a specified slot.
 func (p *Page) SetShort(slot int, fieldName string, val int16) error {
+	if err := p.clearNull(slot, fieldName); err != nil {
+		return err
+	}
 	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
 	return p.tx.SetShort(p.block, fieldPosition, val, true)
 }
 
+// SetFloat stores a float value for the specified field of a specified slot.
+func (p *Page) SetFloat(slot int, fieldName string, val float64) error {
+	if err := p.clearNull(slot, fieldName); err != nil {
+		return err
+	}
+	fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
+	return p.tx.SetFloat(p.block, fieldPosition, val, true)
+}
+
+// SetNull marks the specified field of a specified slot as null. The zero value of the type of the field
+// is stored for it, which is what the typed getters return for a null field. Storing a value for the field
+// with a typed setter clears its null mark.
+func (p *Page) SetNull(slot int, fieldName string) error {
+	if err := p.setZeroValue(p.offset(slot)+p.layout.Offset(fieldName), p.layout.Schema().Type(fieldName)); err != nil {
+		return err
+	}
+	position, mask := p.nullBitPosition(slot, fieldName)
+	word, err := p.tx.GetLong(p.block, position)
+	if err != nil || word&mask != 0 {
+		return err
+	}
+	return p.tx.SetLong(p.block, position, word|mask, true)
+}
+
+// IsNull returns true if the specified field of a specified slot is null.
+func (p *Page) IsNull(slot int, fieldName string) (bool, error) {
+	position, mask := p.nullBitPosition(slot, fieldName)
+	word, err := p.tx.GetLong(p.block, position)
+	if err != nil {
+		return false, err
+	}
+	return word&mask != 0, nil
+}
+
+// clearNull clears the null mark of the specified field of a specified slot, if it is set,
+// since a value is about to be stored for the field.
+func (p *Page) clearNull(slot int, fieldName string) error {
+	position, mask := p.nullBitPosition(slot, fieldName)
+	word, err := p.tx.GetLong(p.block, position)
+	if err != nil || word&mask == 0 {
+		return err
+	}
+	return p.tx.SetLong(p.block, position, word&^mask, true)
+}
+
+// nullBitPosition returns the position in the block of the word of the null bitmap of a slot
+// holding the bit of the specified field, and the mask of the bit within the word.
+func (p *Page) nullBitPosition(slot int, fieldName string) (int, int64) {
+	offset, mask := p.layout.nullBit(fieldName)
+	return p.offset(slot) + offset, mask
+}
+
+// clearNullBitmap marks every field of a specified slot as not null.
+func (p *Page) clearNullBitmap(slot int) error {
+	for offset := types.IntSize; offset < types.IntSize+nullBitmapSize(p.layout.Schema()); offset += nullBitmapWordSize {
+		if err := p.tx.SetLong(p.block, p.offset(slot)+offset, 0, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Delete marks a slot as empty.
 func (p *Page) Delete(slot int) error {
 	return p.setFlag(slot, FlagEmpty)
 }
 
 // Format uses the layout to format a new block of records.
-// Note: These values are not logged (because the old values are meaningless).
+// These values are logged even though the old values are meaningless, so that a follower
+// replaying the log (see tx.LogApplier) formats the block the same way as the primary.
 func (p *Page) Format() error {
 	if p.layout.SlotSize() > p.tx.BlockSize() {
 		return fmt.Errorf("record slot size (%d) exceeds block size (%d)", p.layout.SlotSize(), p.tx.BlockSize())
@@ -123,32 +299,18 @@ func (p *Page) Format() error {
 
 	slot := 0
 	for p.isValidSlot(slot) {
-		err := p.tx.SetInt(p.block, p.offset(slot), FlagEmpty, false)
+		err := p.tx.SetInt(p.block, p.offset(slot), FlagEmpty, true)
 		if err != nil {
 			return err
 		}
+		if err := p.clearNullBitmap(slot); err != nil {
+			return err
+		}
 
 		schema := p.layout.Schema()
 
 		for _, fieldName := range schema.Fields() {
-			fieldPosition := p.offset(slot) + p.layout.Offset(fieldName)
-
-			switch schema.Type(fieldName) {
-			case types.Integer:
-				err = p.tx.SetInt(p.block, fieldPosition, 0, false)
-			case types.Long:
-				err = p.tx.SetLong(p.block, fieldPosition, 0, false)
-			case types.Short:
-				err = p.tx.SetShort(p.block, fieldPosition, 0, false)
-			case types.Boolean:
-				err = p.tx.SetBool(p.block, fieldPosition, false, false)
-			case types.Date:
-				err = p.tx.SetDate(p.block, fieldPosition, time.Time{}, false)
-			case types.Varchar:
-				err = p.tx.SetString(p.block, fieldPosition, "", false)
-			}
-
-			if err != nil {
+			if err := p.setZeroValue(p.offset(slot)+p.layout.Offset(fieldName), schema.Type(fieldName)); err != nil {
 				return err
 			}
 		}
@@ -157,6 +319,28 @@ func (p *Page) Format() error {
 	return nil
 }
 
+// setZeroValue stores the zero value of the specified type at the specified position of the block.
+func (p *Page) setZeroValue(fieldPosition int, fieldType types.SchemaType) error {
+	switch fieldType {
+	case types.Integer:
+		return p.tx.SetInt(p.block, fieldPosition, 0, true)
+	case types.Long:
+		return p.tx.SetLong(p.block, fieldPosition, 0, true)
+	case types.Short:
+		return p.tx.SetShort(p.block, fieldPosition, 0, true)
+	case types.Float:
+		return p.tx.SetFloat(p.block, fieldPosition, 0, true)
+	case types.Boolean:
+		return p.tx.SetBool(p.block, fieldPosition, false, true)
+	case types.Date:
+		return p.tx.SetDate(p.block, fieldPosition, time.Time{}, true)
+	case types.Varchar:
+		return p.tx.SetString(p.block, fieldPosition, "", true)
+	default:
+		return nil
+	}
+}
+
 // NextAfter returns the next slot that is in use after the specified slot.
 func (p *Page) NextAfter(slot int) (int, error) {
 	return p.searchAfter(slot, FlagUsed)
@@ -170,9 +354,14 @@ func (p *Page) InsertAfter(slot int) (int, error) {
 		return -1, fmt.Errorf("insert after slot %d: %w", slot, err)
 	}
 
-	if err := p.setFlag(newSlot, FlagUsed); err != nil {
+	// The insertion is logged as a whole, rather than field by field (see tx.Transaction#InsertRow).
+	if err := p.tx.InsertRow(p.block, p.offset(newSlot), p.layout.SlotSize(), FlagUsed); err != nil {
 		return -1, fmt.Errorf("set flag for slot %d: %w", newSlot, err)
 	}
+	// The slot may hold the null marks of a deleted record.
+	if err := p.clearNullBitmap(newSlot); err != nil {
+		return -1, err
+	}
 	return newSlot, nil
 }
 
@@ -200,7 +389,9 @@ func (p *Page) Block() *file.BlockId {
 	return p.block
 }
 
-// isValidSlot returns true if the slot is within the block's capacity.
+// isValidSlot returns true if the slot is within the block's capacity: the whole slot, from its flag to the end of
+// its last field, lies within the block, so that the bytes left at the end of a block whose size is not a multiple
+// of the slot size are not a slot, even if its flag would fit in them.
 func (p *Page) isValidSlot(slot int) bool {
 	return slot >= 0 && p.offset(slot+1) <= p.tx.BlockSize()
 }
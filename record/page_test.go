@@ -6,8 +6,11 @@ import (
 	"github.com/JyotinderSingh/dropdb/log"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -42,6 +45,7 @@ func setupTestEnv(t *testing.T) (*tx.Transaction, *file.BlockId, *Layout, func()
 	schema.AddDateField("created")
 	schema.AddLongField("amount")
 	schema.AddShortField("type")
+	schema.AddFloatField("price")
 	layout := NewLayout(schema)
 
 	cleanup := func() {
@@ -142,6 +146,15 @@ func TestPageOperations(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, int16(123), val)
 	})
+
+	t.Run("Float Operations", func(t *testing.T) {
+		err = page.SetFloat(slot, "price", -19.99)
+		assert.NoError(t, err)
+
+		val, err := page.GetFloat(slot, "price")
+		assert.NoError(t, err)
+		assert.Equal(t, -19.99, val)
+	})
 }
 
 func TestPageSlotManagement(t *testing.T) {
@@ -224,3 +237,133 @@ func TestPageSlotManagement(t *testing.T) {
 		}
 	})
 }
+
+// TestPageOddBlockSize checks that a slot whose flag fits in the rest of a block but whose fields do not
+// is not a slot of the block.
+func TestPageOddBlockSize(t *testing.T) {
+	schema := NewSchema()
+	schema.AddIntField("id")
+	layout := NewLayout(schema)
+
+	for _, blockSize := range []int{56, 57} {
+		fm, err := file.NewManager(t.TempDir(), blockSize)
+		require.NoError(t, err)
+		// The log is kept in blocks of its own, since a log record of a slot's flag does not fit in such a block.
+		logFileManager, err := file.NewManager(t.TempDir(), 400)
+		require.NoError(t, err)
+		lm, err := log.NewManager(logFileManager, "test")
+		require.NoError(t, err)
+		transaction := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 4), concurrency.NewLockTable())
+		blk, err := transaction.Append("testfile")
+		require.NoError(t, err)
+
+		slots := blockSize / layout.SlotSize()
+		require.GreaterOrEqual(t, blockSize-slots*layout.SlotSize(), 8, "the flag of the next slot should fit in the block")
+
+		page, err := NewPage(transaction, blk, layout)
+		require.NoError(t, err)
+		require.NoError(t, page.Format())
+		assert.Equal(t, slots, page.NumSlots())
+
+		slot := -1
+		for i := 0; i < slots; i++ {
+			slot, err = page.InsertAfter(slot)
+			require.NoError(t, err, "block of %d bytes", blockSize)
+			require.NoError(t, page.SetInt(slot, "id", i))
+		}
+		_, err = page.InsertAfter(slot)
+		assert.ErrorIs(t, err, ErrNoSlotFound, "block of %d bytes", blockSize)
+		_, err = page.GetInt(slots, "id")
+		assert.Error(t, err, "block of %d bytes", blockSize)
+		for i := 0; i < slots; i++ {
+			id, err := page.GetInt(i, "id")
+			require.NoError(t, err)
+			assert.Equal(t, i, id)
+		}
+		require.NoError(t, transaction.Commit())
+	}
+}
+
+func TestPageSetStringTooLong(t *testing.T) {
+	transaction, blk, layout, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	page, err := NewPage(transaction, blk, layout)
+	require.NoError(t, err)
+	require.NoError(t, page.Format())
+	slot, err := page.InsertAfter(-1)
+	require.NoError(t, err)
+	next, err := page.InsertAfter(slot)
+	require.NoError(t, err)
+	require.NoError(t, page.SetInt(next, "id", 7))
+
+	// The name field of 20 characters reserves 80 bytes, which hold any 20 characters, and more characters
+	// as long as they fit; the declared length itself is enforced by the planner.
+	longest := strings.Repeat("€", 26) + "ab"
+	require.NoError(t, page.SetString(slot, "name", longest))
+	name, err := page.GetString(slot, "name")
+	require.NoError(t, err)
+	assert.Equal(t, longest, name)
+
+	for _, val := range []string{strings.Repeat("€", 27), strings.Repeat("x", 81)} {
+		err = page.SetString(slot, "name", val)
+		assert.ErrorIs(t, err, types.ErrValueTooLong)
+		assert.EqualError(t, err, "value too long: field name of testfile holds at most 80 bytes, but the value has 81")
+	}
+
+	// The rejected strings overwrote neither the field nor the fields after it.
+	name, err = page.GetString(slot, "name")
+	require.NoError(t, err)
+	assert.Equal(t, longest, name)
+	id, err := page.GetInt(next, "id")
+	require.NoError(t, err)
+	assert.Equal(t, 7, id)
+}
+
+func TestPageNulls(t *testing.T) {
+	transaction, blk, layout, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	page, err := NewPage(transaction, blk, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, page.Format())
+
+	slot, err := page.InsertAfter(-1)
+	assert.NoError(t, err)
+	assert.NoError(t, page.SetInt(slot, "id", 42))
+	assert.NoError(t, page.SetString(slot, "name", "test"))
+
+	// A null field reads as the zero value of its type, and the other fields keep their values.
+	assert.NoError(t, page.SetNull(slot, "name"))
+	null, err := page.IsNull(slot, "name")
+	assert.NoError(t, err)
+	assert.True(t, null)
+	name, err := page.GetString(slot, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "", name)
+	raw, err := page.GetRawVal(slot, "name")
+	assert.NoError(t, err)
+	assert.Nil(t, raw)
+	null, err = page.IsNull(slot, "id")
+	assert.NoError(t, err)
+	assert.False(t, null)
+	raw, err = page.GetRawVal(slot, "id")
+	assert.NoError(t, err)
+	assert.Equal(t, 42, raw)
+
+	// Storing a value clears the null mark.
+	assert.NoError(t, page.SetString(slot, "name", "again"))
+	null, err = page.IsNull(slot, "name")
+	assert.NoError(t, err)
+	assert.False(t, null)
+
+	// A record inserted into the slot of a deleted one does not inherit its null marks.
+	assert.NoError(t, page.SetNull(slot, "price"))
+	assert.NoError(t, page.Delete(slot))
+	reused, err := page.InsertAfter(-1)
+	assert.NoError(t, err)
+	assert.Equal(t, slot, reused)
+	null, err = page.IsNull(reused, "price")
+	assert.NoError(t, err)
+	assert.False(t, null)
+}
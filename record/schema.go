@@ -25,7 +25,7 @@ func NewSchema() *Schema {
 // value is irrelevant.
 func (s *Schema) AddField(fieldName string, fieldType types.SchemaType, length int) {
 	s.fields = append(s.fields, fieldName)
-	s.info[fieldName] = types.FieldInfo{Type: fieldType, Length: length}
+	s.info[fieldName] = types.FieldInfo{Name: fieldName, Type: fieldType, Length: length}
 }
 
 // AddIntField adds an integer field to the schema.
@@ -53,6 +53,11 @@ func (s *Schema) AddShortField(fieldName string) {
 	s.AddField(fieldName, types.Short, 0)
 }
 
+// AddFloatField adds a float field to the schema, holding 64-bit floating point numbers.
+func (s *Schema) AddFloatField(fieldName string) {
+	s.AddField(fieldName, types.Float, 0)
+}
+
 // AddDateField adds a date field to the schema.
 func (s *Schema) AddDateField(fieldName string) {
 	s.AddField(fieldName, types.Date, 0)
@@ -78,6 +83,15 @@ func (s *Schema) Fields() []string {
 	return s.fields
 }
 
+// FieldInfos returns the name, type, and length of all the fields in the schema, in the order of Fields.
+func (s *Schema) FieldInfos() []types.FieldInfo {
+	infos := make([]types.FieldInfo, len(s.fields))
+	for i, field := range s.fields {
+		infos[i] = s.info[field]
+	}
+	return infos
+}
+
 // HasField returns true if the schema contains a field with the specified name.
 func (s *Schema) HasField(fieldName string) bool {
 	_, ok := s.info[fieldName]
@@ -101,8 +101,8 @@ func TestAdd(t *testing.T) {
 	source := &Schema{
 		fields: []string{"id", "name"},
 		info: map[string]types.FieldInfo{
-			"id":   {types.Integer, 0},
-			"name": {types.Varchar, 25},
+			"id":   {Name: "id", Type: types.Integer, Length: 0},
+			"name": {Name: "name", Type: types.Varchar, Length: 25},
 		},
 	}
 
@@ -131,9 +131,9 @@ func TestAddAll(t *testing.T) {
 	source := &Schema{
 		fields: []string{"id", "name", "active"},
 		info: map[string]types.FieldInfo{
-			"id":     {types.Integer, 0},
-			"name":   {types.Varchar, 25},
-			"active": {types.Boolean, 0},
+			"id":     {Name: "id", Type: types.Integer, Length: 0},
+			"name":   {Name: "name", Type: types.Varchar, Length: 25},
+			"active": {Name: "active", Type: types.Boolean, Length: 0},
 		},
 	}
 
@@ -155,3 +155,16 @@ func TestAddAll(t *testing.T) {
 		assert.Equal(t, sourceInfo, destInfo, "Field info mismatch for %s", field)
 	}
 }
+
+func TestFieldInfos(t *testing.T) {
+	s := NewSchema()
+	s.AddStringField("name", 25)
+	s.AddIntField("id")
+	s.AddDateField("born")
+
+	assert.Equal(t, []types.FieldInfo{
+		{Name: "name", Type: types.Varchar, Length: 25},
+		{Name: "id", Type: types.Integer},
+		{Name: "born", Type: types.Date},
+	}, s.FieldInfos())
+}
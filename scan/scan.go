@@ -1,6 +1,10 @@
 package scan
 
-import "time"
+import (
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/types"
+)
 
 // Scan interface will be implemented by each query scan.
 // There is a Scan class for each relational algebra Operator.
@@ -9,6 +13,9 @@ type Scan interface {
 	BeforeFirst() error
 
 	// Next moves to the next record in the scan. It returns false if there are no more records to scan.
+	// It returns (false, nil) only at the end of the records: any other condition, such as a block or a field
+	// that cannot be read, is returned as an error wrapping its cause, so that callers can tell it apart with
+	// errors.Is and errors.As. A scan that returned an error is not positioned on a record.
 	Next() (bool, error)
 
 	// GetInt returns the integer value of the specified field in the current record.
@@ -20,6 +27,9 @@ type Scan interface {
 	// GetShort returns the short value of the specified field in the current record.
 	GetShort(fieldName string) (int16, error)
 
+	// GetFloat returns the float value of the specified field in the current record.
+	GetFloat(fieldName string) (float64, error)
+
 	// GetString returns the string value of the specified field in the current record.
 	GetString(fieldName string) (string, error)
 
@@ -35,6 +45,13 @@ type Scan interface {
 	// GetVal returns the value of the specified field in the current record.
 	GetVal(fieldName string) (any, error)
 
+	// Fields returns the name, type, and length of the fields of the records of the scan,
+	// in the order of the output columns. It can be called whether or not the scan is positioned on a record.
+	Fields() []types.FieldInfo
+
 	// Close closes the scan and its subscans, if any.
-	Close()
+	// A scan owns the subscans it wraps, so it closes each of them exactly once,
+	// and the subscans must not be closed by anyone else.
+	// Closing a scan that is already closed has no effect and returns nil.
+	Close() error
 }
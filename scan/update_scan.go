@@ -20,6 +20,9 @@ type UpdateScan interface {
 	// SetShort sets the short value of the specified field in the current record.
 	SetShort(fieldName string, val int16) error
 
+	// SetFloat sets the float value of the specified field in the current record.
+	SetFloat(fieldName string, val float64) error
+
 	// SetString sets the string value of the specified field in the current record.
 	SetString(fieldName string, val string) error
 
@@ -36,7 +39,9 @@ type UpdateScan interface {
 	Delete() error
 
 	// GetRecordID returns the record ID of the current record.
-	GetRecordID() *record.ID
+	// It returns an error if the records of the scan have no record ID of their own, such as the records
+	// of a product of two scans, or if the scan is not positioned in a block of its table.
+	GetRecordID() (*record.ID, error)
 
 	// MoveToRecordID moves the scan to the record with the specified record ID.
 	MoveToRecordID(rid *record.ID) error
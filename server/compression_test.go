@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tableLines returns the lines stored in the specified table, by id.
+func tableLines(t *testing.T, db *DropDB, tableName string) map[int]string {
+	t.Helper()
+	transaction := db.NewTx()
+	defer func() { require.NoError(t, transaction.Commit()) }()
+
+	plan, err := db.Planner().CreateQueryPlan("select id, line from "+tableName, transaction)
+	require.NoError(t, err)
+	s, err := plan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	lines := make(map[int]string)
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			return lines
+		}
+		id, err := s.GetInt("id")
+		require.NoError(t, err)
+		line, err := s.GetString("line")
+		require.NoError(t, err)
+		lines[id] = line
+	}
+}
+
+func TestDropDB_CompressedTable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	db, err := NewDropDB(dir)
+	require.NoError(t, err)
+
+	executeUpdates(t, db,
+		"create table logs (id int, line varchar(80)) with compression",
+		"create table plain_logs (id int, line varchar(80))",
+	)
+	const rows = 300
+	expected := make(map[int]string, rows)
+	statements := make([]string, 0, 2*rows)
+	for id := 0; id < rows; id++ {
+		expected[id] = fmt.Sprintf("2024-05-01 INFO GET /api/items/%d served", id)
+		for _, tableName := range []string{"logs", "plain_logs"} {
+			statements = append(statements, fmt.Sprintf("insert into %s (id, line) values (%d, '%s')", tableName, id, expected[id]))
+		}
+	}
+	executeUpdates(t, db, statements...)
+	assert.Equal(t, expected, tableLines(t, db, "logs"))
+
+	// The modifications of an uncommitted transaction reach the compressed blocks on disk before the crash.
+	uncommitted := db.NewTx()
+	updated, err := db.Planner().ExecuteUpdate("update logs set line = 'overwritten' where id > 10", uncommitted)
+	require.NoError(t, err)
+	assert.Equal(t, rows-11, updated)
+	require.NoError(t, db.BufferManager().FlushAllDirty())
+
+	// Recovery undoes them through the logical blocks of the table.
+	recovered, err := NewDropDB(dir)
+	require.NoError(t, err)
+	transaction := recovered.NewTx()
+	options, err := recovered.MetadataManager().GetTableOptions("logs", transaction)
+	require.NoError(t, err)
+	assert.True(t, options.Compressed)
+	require.NoError(t, transaction.Commit())
+	assert.Equal(t, expected, tableLines(t, recovered, "logs"))
+	assert.Equal(t, expected, tableLines(t, recovered, "plain_logs"))
+
+	compressed, err := recovered.FileManager().IsCompressed("logs.tbl")
+	require.NoError(t, err)
+	assert.True(t, compressed)
+	compressedSize, err := recovered.FileManager().SizeOnDisk("logs.tbl")
+	require.NoError(t, err)
+	plainSize, err := recovered.FileManager().SizeOnDisk("plain_logs.tbl")
+	require.NoError(t, err)
+	assert.Less(t, compressedSize, plainSize/2)
+	t.Logf("compressed table: %d bytes, uncompressed table: %d bytes", compressedSize, plainSize)
+}
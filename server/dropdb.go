@@ -9,6 +9,7 @@ import (
 	"github.com/JyotinderSingh/dropdb/plan_impl"
 	"github.com/JyotinderSingh/dropdb/tx"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"time"
 )
 
 const (
@@ -26,6 +27,8 @@ type DropDB struct {
 	queryPlanner    plan_impl.QueryPlanner
 	updatePlanner   plan_impl.UpdatePlanner
 	planner         *plan_impl.Planner
+	// orderJoins is true if queries are planned by a plan_impl.HeuristicQueryPlanner (see NewDropDBWithJoinOrdering).
+	orderJoins bool
 }
 
 // NewDropDBWithOptions is a constructor that is mostly useful for debugging purposes.
@@ -47,10 +50,18 @@ func NewDropDBWithOptions(dirName string, blockSize, bufferSize int) (*DropDB, e
 
 // NewDropDB creates a new DropDB instance. Use this constructor for production code.
 func NewDropDB(dirName string) (*DropDB, error) {
+	return NewDropDBWithJoinOrdering(dirName, false)
+}
+
+// NewDropDBWithJoinOrdering creates a new DropDB instance like NewDropDB. If orderJoins is true, its queries
+// are planned by a plan_impl.HeuristicQueryPlanner, which joins the tables of a query reading three or more
+// in the order estimated to keep the intermediate results smallest, rather than in the order of its FROM clause.
+func NewDropDBWithJoinOrdering(dirName string, orderJoins bool) (*DropDB, error) {
 	db, err := NewDropDBWithOptions(dirName, blockSize, bufferSize)
 	if err != nil {
 		return nil, err
 	}
+	db.orderJoins = orderJoins
 
 	transaction := db.NewTx()
 	isNew := db.fileManager.IsNew()
@@ -64,18 +75,51 @@ func NewDropDB(dirName string) (*DropDB, error) {
 		}
 	}
 
-	if db.metadataManager, err = metadata.NewManager(isNew, transaction); err != nil {
+	metadataManager, err := metadata.NewManager(isNew, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.setMetadataManager(metadataManager); err != nil {
 		return nil, err
 	}
-
-	db.queryPlanner = plan_impl.NewBasicQueryPlanner(db.metadataManager)
-	db.updatePlanner = plan_impl.NewBasicUpdatePlanner(db.metadataManager)
-	db.planner = plan_impl.NewPlanner(db.queryPlanner, db.updatePlanner)
 
 	err = transaction.Commit()
 	return db, err
 }
 
+// setMetadataManager sets the catalog of the database, and creates the planners reading it.
+// Queries can read the stats of the buffer pool from the plan_impl.StatsTable.
+func (db *DropDB) setMetadataManager(metadataManager *metadata.Manager) error {
+	db.metadataManager = metadataManager
+	if db.orderJoins {
+		db.queryPlanner = plan_impl.NewHeuristicQueryPlanner(metadataManager)
+	} else {
+		db.queryPlanner = plan_impl.NewBasicQueryPlanner(metadataManager)
+	}
+	db.updatePlanner = plan_impl.NewBasicUpdatePlanner(metadataManager)
+	db.planner = plan_impl.NewPlanner(db.queryPlanner, db.updatePlanner)
+	db.planner.SetRestoreTransactions(db.NewTx)
+	return db.planner.SetStatsSource(db.Stats)
+}
+
+// SetScanParallelism sets the number of worker goroutines aggregating the records of a table in parallel,
+// each in a read-only transaction of its own (see plan_impl.ParallelTablePlan). Tables are read serially by default.
+func (db *DropDB) SetScanParallelism(workers int) error {
+	return db.planner.SetScanParallelism(workers, db.newReadOnlyTx)
+}
+
+// RefreshStatsInBackground makes a goroutine calculate the statistics of the changed tables at the specified interval,
+// each time in a read-only transaction of its own, so that queries do not wait for them
+// (see metadata.StatManager#StartBackgroundRefresh). The returned function stops it.
+func (db *DropDB) RefreshStatsInBackground(interval time.Duration) (func(), error) {
+	return db.metadataManager.StartBackgroundStatsRefresh(interval, db.newReadOnlyTx)
+}
+
+// newReadOnlyTx creates a transaction that can read the database but not modify it.
+func (db *DropDB) newReadOnlyTx() *tx.Transaction {
+	return tx.NewReadOnlyTransaction(db.fileManager, db.logManager, db.bufferManager, db.lockTable)
+}
+
 func (db *DropDB) NewTx() *tx.Transaction {
 	return tx.NewTransaction(db.fileManager, db.logManager, db.bufferManager, db.lockTable)
 }
@@ -99,3 +143,28 @@ func (db *DropDB) LogManager() *log.Manager {
 func (db *DropDB) BufferManager() *buffer.Manager {
 	return db.bufferManager
 }
+
+// BufferStats returns a snapshot of the state and activity of the buffer pool. To be notified when the pool
+// runs short of buffers, register a hook on the buffer manager (see buffer.Manager#RegisterPressureHook).
+func (db *DropDB) BufferStats() buffer.Stats {
+	return db.bufferManager.Stats()
+}
+
+// Stats returns a snapshot of the stats of the buffer pool, along with the numbers of blocks the file manager
+// read and wrote, which queries also read from the plan_impl.StatsTable.
+func (db *DropDB) Stats() plan_impl.DatabaseStats {
+	return plan_impl.DatabaseStats{Buffer: db.bufferManager.Stats(), File: db.fileManager.Stats()}
+}
+
+// Checkpoint writes a non-quiescent checkpoint to the log while transactions keep running, so that recovery
+// after a crash reads the log back only as far as the oldest transaction that was running at the checkpoint
+// (see tx.TakeCheckpoint).
+func (db *DropDB) Checkpoint() error {
+	return tx.TakeCheckpoint(db.logManager, db.bufferManager)
+}
+
+// TruncateLog writes a checkpoint, and removes the start of the log that recovery no longer needs to read
+// (see tx.TruncateLog). It returns the number of log records removed.
+func (db *DropDB) TruncateLog() (int, error) {
+	return tx.TruncateLog(db.logManager, db.bufferManager)
+}
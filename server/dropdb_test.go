@@ -0,0 +1,186 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/plan_impl"
+	"github.com/JyotinderSingh/dropdb/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropDB_BufferStats(t *testing.T) {
+	db, err := NewDropDB(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	executeUpdates(t, db,
+		"create table items (id int)",
+		"insert into items (id) values (1)",
+	)
+
+	transaction := db.NewTx()
+	defer func() { require.NoError(t, transaction.Commit()) }()
+
+	// The virtual table holds the stats of the pool when the query is planned.
+	plan, err := db.Planner().CreateQueryPlan("select stat_name, stat_value from "+plan_impl.StatsTable, transaction)
+	require.NoError(t, err)
+	databaseStats := db.Stats()
+	stats := databaseStats.Buffer
+
+	s, err := plan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	values := make(map[string]int)
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		name, err := s.GetString(query.StatNameField)
+		require.NoError(t, err)
+		value, err := s.GetInt(query.StatValueField)
+		require.NoError(t, err)
+		values[name] = value
+	}
+
+	assert.Equal(t, bufferSize, stats.Buffers)
+	assert.Positive(t, stats.Reads)
+	assert.Equal(t, map[string]int{
+		"buffers":           stats.Buffers,
+		"pinned":            stats.Pinned,
+		"dirty":             stats.Dirty,
+		"waiters":           stats.Waiters,
+		"pins":              stats.Pins,
+		"unpins":            stats.Unpins,
+		"hits":              stats.Hits,
+		"reads":             stats.Reads,
+		"hit_ratio_percent": int(math.Round(100 * stats.HitRatio())),
+		"writes":            stats.Writes,
+		"waits":             stats.Waits,
+		"evictions":         stats.Evictions,
+		"prefetch_issued":   stats.PrefetchIssued,
+		"prefetch_hits":     stats.PrefetchHits,
+		"prefetch_wasted":   stats.PrefetchWasted,
+		"disk_reads":        databaseStats.File.BlocksRead,
+		"disk_writes":       databaseStats.File.BlocksWritten,
+	}, values)
+
+	// The stats can be selected like the records of a table.
+	plan, err = db.Planner().CreateQueryPlan("select stat_value from dropdb_stats where stat_name = 'buffers'", transaction)
+	require.NoError(t, err)
+	s, err = plan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	next, err := s.Next()
+	require.NoError(t, err)
+	require.True(t, next)
+	value, err := s.GetInt(query.StatValueField)
+	require.NoError(t, err)
+	assert.Equal(t, bufferSize, value)
+	next, err = s.Next()
+	require.NoError(t, err)
+	assert.False(t, next)
+}
+
+func TestDropDB_StatsCountHitsOfRescan(t *testing.T) {
+	db, err := NewDropDB(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	executeUpdates(t, db, "create table items (id int)")
+	for i := 0; i < 20; i++ {
+		executeUpdates(t, db, fmt.Sprintf("insert into items (id) values (%d)", i))
+	}
+
+	// scan reads the table, and returns the activity of the buffer pool and of the file manager meanwhile.
+	scan := func() (buffer.Stats, file.Stats) {
+		before := db.Stats()
+		transaction := db.NewTx()
+		defer func() { require.NoError(t, transaction.Commit()) }()
+		plan, err := db.Planner().CreateQueryPlan("select id from items", transaction)
+		require.NoError(t, err)
+		s, err := plan.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		records := 0
+		for {
+			next, err := s.Next()
+			require.NoError(t, err)
+			if !next {
+				break
+			}
+			records++
+		}
+		assert.Equal(t, 20, records)
+
+		after := db.Stats()
+		return buffer.Stats{
+			Pins:  after.Buffer.Pins - before.Buffer.Pins,
+			Hits:  after.Buffer.Hits - before.Buffer.Hits,
+			Reads: after.Buffer.Reads - before.Buffer.Reads,
+		}, file.Stats{
+			BlocksRead: after.File.BlocksRead - before.File.BlocksRead,
+		}
+	}
+
+	first, _ := scan()
+	assert.Positive(t, first.Pins)
+
+	// The table fits in the pool, so the second scan finds every block it pins there.
+	second, secondFile := scan()
+	assert.Zero(t, second.Reads)
+	assert.Zero(t, secondFile.BlocksRead)
+	assert.Positive(t, second.Hits)
+	assert.Equal(t, second.Pins, second.Hits)
+	assert.Greater(t, db.Stats().Buffer.HitRatio(), 0.0)
+}
+
+func TestDropDB_ScanParallelism(t *testing.T) {
+	db, err := NewDropDB(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	executeUpdates(t, db, "create table items (id int, kind int)")
+	for i := 0; i < 200; i++ {
+		executeUpdates(t, db, fmt.Sprintf("insert into items (id, kind) values (%d, %d)", i, i%3))
+	}
+
+	require.Error(t, db.SetScanParallelism(0))
+	require.NoError(t, db.SetScanParallelism(4))
+
+	transaction := db.NewTx()
+	defer func() { require.NoError(t, transaction.Commit()) }()
+	sql := "select kind, count(id), sum(id) from items where id > 9 group by kind"
+	explained, err := db.Planner().Explain(sql, transaction)
+	require.NoError(t, err)
+	assert.Contains(t, explained, "ParallelAggregation")
+
+	plan, err := db.Planner().CreateQueryPlan(sql, transaction)
+	require.NoError(t, err)
+	s, err := plan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+	sums := make(map[int]int64)
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		kind, err := s.GetInt("kind")
+		require.NoError(t, err)
+		count, err := s.GetLong("countOfid")
+		require.NoError(t, err)
+		sum, err := s.GetLong("sumOfid")
+		require.NoError(t, err)
+		assert.Positive(t, count)
+		sums[kind] = sum
+	}
+
+	expected := make(map[int]int64)
+	for i := 10; i < 200; i++ {
+		expected[i%3] += int64(i)
+	}
+	assert.Equal(t, expected, sums)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queryRows returns the values of the specified fields of the records output by the query, in the order they are output.
+func queryRows(t *testing.T, db *DropDB, sql string, fields ...string) []map[string]any {
+	t.Helper()
+	transaction := db.NewTx()
+	defer func() { require.NoError(t, transaction.Commit()) }()
+
+	plan, err := db.Planner().CreateQueryPlan(sql, transaction)
+	require.NoError(t, err)
+	s, err := plan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	rows := []map[string]any{}
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			return rows
+		}
+		row := make(map[string]any)
+		for _, field := range fields {
+			row[field], err = s.GetVal(field)
+			require.NoError(t, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// catalogContents returns the DDL of the tables of the database and its views.
+func catalogContents(t *testing.T, db *DropDB) ([][]string, []metadata.View) {
+	t.Helper()
+	transaction := db.NewTx()
+	defer func() { require.NoError(t, transaction.Commit()) }()
+
+	tableNames, err := db.MetadataManager().GetTableNames(transaction)
+	require.NoError(t, err)
+	var ddl [][]string
+	for _, tableName := range tableNames {
+		statements, err := db.MetadataManager().GenerateDDL(tableName, transaction)
+		require.NoError(t, err)
+		ddl = append(ddl, statements)
+	}
+	views, err := db.MetadataManager().GetViews(transaction)
+	require.NoError(t, err)
+	return ddl, views
+}
+
+func TestDropDB_DumpAndRestore(t *testing.T) {
+	source, err := NewDropDB(filepath.Join(t.TempDir(), "source"))
+	require.NoError(t, err)
+	executeUpdates(t, source,
+		"create table people (id int, name varchar(20), active bool, born date, score int) with compression",
+		"create table visits (person int, note varchar(30), day date)",
+	)
+	born := time.Date(1990, 3, 4, 5, 6, 7, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		name := fmt.Sprintf("O'Brien; %d", i)
+		executeUpdates(t, source,
+			fmt.Sprintf("insert into people (id, name, active, born, score) values (%d, '%s', %t, %s, %d)",
+				i, strings.ReplaceAll(name, "'", "''"), i%2 == 0, born.AddDate(0, 0, i).Format("2006-01-02 15:04:05"), 50-i*3),
+			fmt.Sprintf("insert into visits (person, note, day) values (%d, 'visit ''%d''', 2024-01-%02d)", i%7, i, i%28+1),
+		)
+	}
+	executeUpdates(t, source,
+		"create unique index people_id on people (id)",
+		"create index people_name on people (name)",
+		"create index active_score on people (score) where active = true",
+		"create index visits_person on visits (person)",
+		"create view scorers as select id, name, score from people where active = true",
+	)
+
+	// The records are dumped one INSERT statement each.
+	backup := filepath.Join(t.TempDir(), "backup.sql")
+	transaction := source.NewTx()
+	dumped, err := source.Planner().ExecuteUpdate(fmt.Sprintf("dump database to '%s'", backup), transaction)
+	require.NoError(t, err)
+	require.NoError(t, transaction.Commit())
+	assert.Equal(t, 120, dumped)
+
+	target, err := NewDropDB(filepath.Join(t.TempDir(), "target"))
+	require.NoError(t, err)
+	transaction = target.NewTx()
+	restored, err := target.Planner().ExecuteUpdate(fmt.Sprintf("restore from '%s'", backup), transaction)
+	require.NoError(t, err)
+	require.NoError(t, transaction.Commit())
+	assert.Equal(t, 120, restored)
+
+	sourceDDL, sourceViews := catalogContents(t, source)
+	targetDDL, targetViews := catalogContents(t, target)
+	assert.Equal(t, sourceDDL, targetDDL)
+	assert.Equal(t, sourceViews, targetViews)
+	require.Len(t, targetViews, 1)
+
+	queries := []struct {
+		sql    string
+		fields []string
+	}{
+		{"select id, name, active, born, score from people", []string{"id", "name", "active", "born", "score"}},
+		{"select person, note, day from visits", []string{"person", "note", "day"}},
+		{"select id, name from people where name = 'O''Brien; 17'", []string{"id", "name"}},
+		{"select id, score from people where score < -20 and active = true", []string{"id", "score"}},
+		{"select note from visits where person = 3", []string{"note"}},
+		{"select id, name, score from scorers", []string{"id", "name", "score"}},
+		{"select person, count(note) from visits group by person", []string{"person", "countOfnote"}},
+	}
+	for _, q := range queries {
+		expected := queryRows(t, source, q.sql, q.fields...)
+		assert.NotEmpty(t, expected, q.sql)
+		assert.Equal(t, expected, queryRows(t, target, q.sql, q.fields...), q.sql)
+	}
+	assert.Len(t, queryRows(t, target, "select id from people", "id"), 60)
+	assert.Len(t, queryRows(t, target, "select person from visits", "person"), 60)
+}
+
+func TestDropDB_RestoreFailure(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.sql")
+	require.NoError(t, os.WriteFile(script, []byte(`
+		create table first (id int);
+		insert into first (id) values (1);
+		create table second (id int);
+		insert into second (id) values (2);
+		insert into missing (id) values (3);
+	`), 0o644))
+
+	db, err := NewDropDB(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	transaction := db.NewTx()
+	_, err = db.Planner().ExecuteUpdate(fmt.Sprintf("restore from '%s'", script), transaction)
+	assert.ErrorContains(t, err, "statement 5")
+	require.NoError(t, transaction.Rollback())
+
+	// The tables restored before the failing one are kept, in their own transactions.
+	assert.Len(t, queryRows(t, db, "select id from first", "id"), 1)
+	transaction = db.NewTx()
+	defer func() { require.NoError(t, transaction.Commit()) }()
+	tableNames, err := db.MetadataManager().GetTableNames(transaction)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first"}, tableNames)
+
+	// A script cannot restore another script.
+	require.NoError(t, os.WriteFile(script, []byte(fmt.Sprintf("restore from '%s'", script)), 0o644))
+	_, err = db.Planner().ExecuteUpdate(fmt.Sprintf("restore from '%s'", script), transaction)
+	assert.ErrorContains(t, err, "statement 1: a script cannot dump or restore a database")
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/metadata"
+	"github.com/JyotinderSingh/dropdb/plan_impl"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"sync"
+)
+
+const replicationStateFile = "replication.state"
+
+// Follower is a read-only replica of a DropDB primary.
+// The primary ships its log records to the follower's Apply method (see log.Manager#RegisterShipper),
+// and the follower redoes the changes of committed transactions into its own files.
+// Transactions on the follower are read-only, so the follower refuses writes from its clients.
+type Follower struct {
+	db      *DropDB
+	applier *tx.LogApplier
+	mu      sync.Mutex
+}
+
+// NewFollowerWithOptions is a constructor that is mostly useful for debugging purposes.
+// The block size must be the same as the primary's, since the shipped log records address blocks and offsets.
+func NewFollowerWithOptions(dirName string, blockSize, bufferSize int) (*Follower, error) {
+	db, err := NewDropDBWithOptions(dirName, blockSize, bufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Follower{db: db}
+	if f.applier, err = tx.NewLogApplier(db.fileManager, db.logManager, db.bufferManager, db.lockTable, replicationStateFile); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// NewFollower creates a new Follower in the specified directory. Use this constructor for production code.
+func NewFollower(dirName string) (*Follower, error) {
+	return NewFollowerWithOptions(dirName, blockSize, bufferSize)
+}
+
+// Apply consumes a log record shipped by the primary. It has the signature of a log.Shipper.
+func (f *Follower) Apply(lsn int, record []byte) error {
+	return f.applier.Apply(lsn, record)
+}
+
+// AppliedLSN returns the LSN up to which the follower has consumed the primary's log.
+// Pass it to log.Manager#RegisterShipperFrom to resume replication after a restart.
+func (f *Follower) AppliedLSN() int {
+	return f.applier.AppliedLSN()
+}
+
+// NewTx returns a new read-only transaction on the follower.
+func (f *Follower) NewTx() *tx.Transaction {
+	return tx.NewReadOnlyTransaction(f.db.fileManager, f.db.logManager, f.db.bufferManager, f.db.lockTable)
+}
+
+// Planner returns the planner of the follower.
+// The catalog tables are replicated from the primary rather than created by the follower,
+// so the planner is only available once the primary's catalog has been applied.
+func (f *Follower) Planner() (*plan_impl.Planner, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.db.planner != nil {
+		return f.db.planner, nil
+	}
+
+	transaction := f.NewTx()
+	metadataManager, err := metadata.NewManager(false, transaction)
+	if err != nil {
+		_ = transaction.Rollback()
+		return nil, fmt.Errorf("catalog not replicated yet: %w", err)
+	}
+	if err := transaction.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := f.db.setMetadataManager(metadataManager); err != nil {
+		return nil, err
+	}
+	return f.db.planner, nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// executeUpdates runs the statements on the primary in a single committed transaction.
+func executeUpdates(t *testing.T, db *DropDB, statements ...string) {
+	t.Helper()
+	transaction := db.NewTx()
+	for _, statement := range statements {
+		_, err := db.Planner().ExecuteUpdate(statement, transaction)
+		require.NoError(t, err)
+	}
+	require.NoError(t, transaction.Commit())
+}
+
+// followerNames returns the names stored in the specified table of the follower.
+func followerNames(t *testing.T, follower *Follower, tableName string) []string {
+	t.Helper()
+	transaction := follower.NewTx()
+	defer func() { require.NoError(t, transaction.Commit()) }()
+
+	planner, err := follower.Planner()
+	require.NoError(t, err)
+	plan, err := planner.CreateQueryPlan("select name from "+tableName, transaction)
+	require.NoError(t, err)
+	s, err := plan.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	names := []string{}
+	for {
+		next, err := s.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		name, err := s.GetString("name")
+		require.NoError(t, err)
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestFollower_ReplicatesCommittedTransactions(t *testing.T) {
+	primary, err := NewDropDB(filepath.Join(t.TempDir(), "primary"))
+	require.NoError(t, err)
+	followerDir := filepath.Join(t.TempDir(), "follower")
+	follower, err := NewFollower(followerDir)
+	require.NoError(t, err)
+
+	require.NoError(t, primary.LogManager().RegisterShipperFrom(follower.AppliedLSN(), follower.Apply))
+
+	executeUpdates(t, primary,
+		"create table items (id int, name varchar(10))",
+		"create table drafts (id int, name varchar(10))",
+	)
+	executeUpdates(t, primary,
+		"insert into items (id, name) values (1, 'apple')",
+		"insert into items (id, name) values (2, 'banana')",
+	)
+
+	// The records of an uncommitted transaction are shipped whenever the log is flushed,
+	// for example when one of its buffers is written back, but they are never applied.
+	uncommitted := primary.NewTx()
+	_, err = primary.Planner().ExecuteUpdate("insert into drafts (id, name) values (1, 'draft')", uncommitted)
+	require.NoError(t, err)
+	require.NoError(t, primary.LogManager().Flush(math.MaxInt))
+
+	require.NoError(t, primary.LogManager().WaitForShipping())
+	assert.Equal(t, []string{"apple", "banana"}, followerNames(t, follower, "items"))
+	assert.Empty(t, followerNames(t, follower, "drafts"))
+
+	require.NoError(t, uncommitted.Rollback())
+	executeUpdates(t, primary,
+		"insert into items (id, name) values (3, 'cherry')",
+		"update items set name = 'blueberry' where id = 2",
+		"delete from items where id = 1",
+		"insert into drafts (id, name) values (2, 'final')",
+	)
+
+	require.NoError(t, primary.LogManager().WaitForShipping())
+	assert.Equal(t, []string{"blueberry", "cherry"}, followerNames(t, follower, "items"))
+	assert.Equal(t, []string{"final"}, followerNames(t, follower, "drafts"))
+
+	// The applied LSN is persisted, so a reopened follower resumes where it stopped.
+	appliedLSN := follower.AppliedLSN()
+	assert.Greater(t, appliedLSN, 0)
+	reopened, err := NewFollower(followerDir)
+	require.NoError(t, err)
+	assert.Equal(t, appliedLSN, reopened.AppliedLSN())
+}
+
+func TestFollower_RefusesWrites(t *testing.T) {
+	primary, err := NewDropDB(filepath.Join(t.TempDir(), "primary"))
+	require.NoError(t, err)
+	follower, err := NewFollower(filepath.Join(t.TempDir(), "follower"))
+	require.NoError(t, err)
+
+	require.NoError(t, primary.LogManager().RegisterShipperFrom(follower.AppliedLSN(), follower.Apply))
+	executeUpdates(t, primary,
+		"create table items (id int, name varchar(10))",
+		"insert into items (id, name) values (1, 'apple')",
+	)
+	require.NoError(t, primary.LogManager().WaitForShipping())
+
+	planner, err := follower.Planner()
+	require.NoError(t, err)
+	transaction := follower.NewTx()
+	_, err = planner.ExecuteUpdate("insert into items (id, name) values (2, 'banana')", transaction)
+	assert.ErrorIs(t, err, tx.ErrReadOnly)
+	_, err = planner.ExecuteUpdate("create table others (id int)", transaction)
+	assert.ErrorIs(t, err, tx.ErrReadOnly)
+	require.NoError(t, transaction.Rollback())
+
+	assert.Equal(t, []string{"apple"}, followerNames(t, follower, "items"))
+}
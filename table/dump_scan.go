@@ -0,0 +1,215 @@
+package table
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/record"
+	"github.com/JyotinderSingh/dropdb/scan"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// Ensure DumpScan implements the Scan interface.
+var _ scan.Scan = (*DumpScan)(nil)
+
+const (
+	// DumpSlotField is the field of a dump that holds the index of the slot.
+	DumpSlotField = "slot"
+	// DumpFlagField is the field of a dump that holds the flag of the slot.
+	DumpFlagField = "flag"
+	// dumpOffsetSuffix is appended to the name of a field to name the field holding its offset in the block.
+	dumpOffsetSuffix = "_offset"
+	// dumpValueLength is the length of the fields holding the decoded values, which are formatted as strings.
+	dumpValueLength = 100
+)
+
+// DumpScan is a scan over the physical contents of a single block of a table, meant for debugging.
+// It returns one record per slot of the block, used or not, with the index of the slot, its flag
+// ("empty", "used", or the raw value of an invalid flag), and for each field of the table its decoded value
+// formatted as a string, in a field of the same name, and the offset of the field in the block,
+// in a field named after the field with an "_offset" suffix.
+// Values that cannot be decoded, such as strings with a corrupted length, are reported in place of the value.
+type DumpScan struct {
+	tx          *tx.Transaction
+	layout      *record.Layout
+	recordPage  *record.Page
+	currentSlot int
+}
+
+// NewDumpScan creates a scan over the slots of the specified block of the specified table.
+// It returns an error if the block is not part of the table.
+func NewDumpScan(tx *tx.Transaction, tableName string, layout *record.Layout, blockNumber int) (*DumpScan, error) {
+	fileName := tableName + fileExtension
+	size, err := tx.Size(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("get file size: %w", err)
+	}
+	if blockNumber < 0 || blockNumber >= size {
+		return nil, fmt.Errorf("block %d is out of range: table %s has %d blocks", blockNumber, tableName, size)
+	}
+
+	recordPage, err := record.NewPage(tx, file.NewBlockId(fileName, blockNumber), layout)
+	if err != nil {
+		return nil, fmt.Errorf("pin block %d: %w", blockNumber, err)
+	}
+
+	return &DumpScan{
+		tx:          tx,
+		layout:      layout,
+		recordPage:  recordPage,
+		currentSlot: -1,
+	}, nil
+}
+
+// DumpSchema returns the schema of the records returned by a DumpScan over a table with the specified layout.
+func DumpSchema(layout *record.Layout) *record.Schema {
+	schema := record.NewSchema()
+	schema.AddIntField(DumpSlotField)
+	schema.AddStringField(DumpFlagField, dumpValueLength)
+	for _, fieldName := range layout.Schema().Fields() {
+		schema.AddStringField(fieldName, dumpValueLength)
+		schema.AddIntField(fieldName + dumpOffsetSuffix)
+	}
+	return schema
+}
+
+// BeforeFirst positions the scan before the first slot of the block.
+func (ds *DumpScan) BeforeFirst() error {
+	ds.currentSlot = -1
+	return nil
+}
+
+// Next moves to the next slot of the block, whether it is used or not.
+func (ds *DumpScan) Next() (bool, error) {
+	if ds.recordPage == nil || ds.currentSlot+1 >= ds.recordPage.NumSlots() {
+		return false, nil
+	}
+	ds.currentSlot++
+	return true, nil
+}
+
+// GetVal returns the value of the specified field for the current slot.
+func (ds *DumpScan) GetVal(fieldName string) (any, error) {
+	if ds.currentSlot < 0 {
+		return nil, fmt.Errorf("no current slot")
+	}
+
+	if fieldName == DumpSlotField {
+		return ds.currentSlot, nil
+	}
+	if fieldName == DumpFlagField {
+		flag, err := ds.recordPage.RawFlag(ds.currentSlot)
+		if err != nil {
+			return nil, err
+		}
+		switch flag {
+		case record.FlagEmpty:
+			return "empty", nil
+		case record.FlagUsed:
+			return "used", nil
+		default:
+			return fmt.Sprintf("invalid (%d)", flag), nil
+		}
+	}
+
+	schema := ds.layout.Schema()
+	if schema.HasField(fieldName) {
+		value, err := ds.recordPage.GetRawVal(ds.currentSlot, fieldName)
+		if err != nil {
+			return fmt.Sprintf("<undecodable: %v>", err), nil
+		}
+		return formatDumpValue(value), nil
+	}
+	if field, isOffset := strings.CutSuffix(fieldName, dumpOffsetSuffix); isOffset && schema.HasField(field) {
+		return ds.recordPage.FieldOffset(ds.currentSlot, field), nil
+	}
+
+	return nil, fmt.Errorf("field %s not found", fieldName)
+}
+
+// formatDumpValue formats a decoded value as a string, or as "null" for a null value.
+func formatDumpValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// GetInt returns the integer value of the specified field for the current slot.
+func (ds *DumpScan) GetInt(fieldName string) (int, error) {
+	value, err := ds.GetVal(fieldName)
+	if err != nil {
+		return 0, err
+	}
+	intValue, ok := value.(int)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not an int", fieldName)
+	}
+	return intValue, nil
+}
+
+// GetString returns the string value of the specified field for the current slot.
+func (ds *DumpScan) GetString(fieldName string) (string, error) {
+	value, err := ds.GetVal(fieldName)
+	if err != nil {
+		return "", err
+	}
+	stringValue, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s is not a string", fieldName)
+	}
+	return stringValue, nil
+}
+
+// GetLong is not supported, since a dump only has int and string fields.
+func (ds *DumpScan) GetLong(fieldName string) (int64, error) {
+	return 0, fmt.Errorf("field %s is not a long", fieldName)
+}
+
+// GetShort is not supported, since a dump only has int and string fields.
+func (ds *DumpScan) GetShort(fieldName string) (int16, error) {
+	return 0, fmt.Errorf("field %s is not a short", fieldName)
+}
+
+// GetFloat is not supported, since a dump only has int and string fields.
+func (ds *DumpScan) GetFloat(fieldName string) (float64, error) {
+	return 0, fmt.Errorf("field %s is not a float", fieldName)
+}
+
+// GetBool is not supported, since a dump only has int and string fields.
+func (ds *DumpScan) GetBool(fieldName string) (bool, error) {
+	return false, fmt.Errorf("field %s is not a bool", fieldName)
+}
+
+// GetDate is not supported, since a dump only has int and string fields.
+func (ds *DumpScan) GetDate(fieldName string) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("field %s is not a date", fieldName)
+}
+
+// HasField returns true if the dump has the specified field.
+func (ds *DumpScan) HasField(fieldName string) bool {
+	return DumpSchema(ds.layout).HasField(fieldName)
+}
+
+// Fields returns the fields of the dump, which are described by DumpSchema.
+func (ds *DumpScan) Fields() []types.FieldInfo {
+	return DumpSchema(ds.layout).FieldInfos()
+}
+
+// Close unpins the dumped block. Closing the scan again has no effect.
+func (ds *DumpScan) Close() error {
+	if ds.recordPage != nil {
+		ds.tx.Unpin(ds.recordPage.Block())
+		ds.recordPage = nil
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package table
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dumpRows reads every slot of a dump, as a map from field name to value.
+func dumpRows(t *testing.T, ds *DumpScan) []map[string]any {
+	var rows []map[string]any
+	for {
+		next, err := ds.Next()
+		require.NoError(t, err)
+		if !next {
+			return rows
+		}
+		row := make(map[string]any)
+		for _, field := range DumpSchema(ds.layout).Fields() {
+			value, err := ds.GetVal(field)
+			require.NoError(t, err)
+			row[field] = value
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestDumpScan_MatchesTableScan(t *testing.T) {
+	ts, transaction, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	created := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+		require.NoError(t, ts.SetString("name", fmt.Sprintf("name %d", i)))
+		require.NoError(t, ts.SetBool("active", i%2 == 1))
+		require.NoError(t, ts.SetDate("created", created))
+		require.NoError(t, ts.SetLong("count", int64(i)*1000))
+		require.NoError(t, ts.SetShort("code", int16(i)))
+	}
+
+	// Delete the second record.
+	require.NoError(t, ts.BeforeFirst())
+	for i := 0; i < 2; i++ {
+		next, err := ts.Next()
+		require.NoError(t, err)
+		require.True(t, next)
+	}
+	require.NoError(t, ts.Delete())
+
+	// The slots and names reported by the table scan.
+	scanned := make(map[int]string)
+	require.NoError(t, ts.BeforeFirst())
+	for {
+		next, err := ts.Next()
+		require.NoError(t, err)
+		if !next {
+			break
+		}
+		name, err := ts.GetString("name")
+		require.NoError(t, err)
+		rid, err := ts.GetRecordID()
+		require.NoError(t, err)
+		scanned[rid.Slot()] = name
+	}
+	require.Len(t, scanned, 2)
+
+	ds, err := NewDumpScan(transaction, "test_table", ts.layout, 0)
+	require.NoError(t, err)
+	defer ds.Close()
+
+	rows := dumpRows(t, ds)
+	slotSize := ts.layout.SlotSize()
+	assert.Len(t, rows, transaction.BlockSize()/slotSize)
+	for slot, row := range rows {
+		assert.Equal(t, slot, row[DumpSlotField])
+		assert.Equal(t, slot*slotSize+ts.layout.Offset("name"), row["name_offset"])
+		if name, used := scanned[slot]; used {
+			assert.Equal(t, "used", row[DumpFlagField])
+			assert.Equal(t, fmt.Sprintf("%q", name), row["name"])
+			assert.Equal(t, created.Format(time.RFC3339), row["created"])
+		} else {
+			assert.Equal(t, "empty", row[DumpFlagField], "slot %d", slot)
+		}
+	}
+
+	// The deleted record keeps its values, but its slot is empty.
+	assert.Equal(t, "empty", rows[1][DumpFlagField])
+	assert.Equal(t, `"name 2"`, rows[1]["name"])
+	assert.Equal(t, "2000", rows[1]["count"])
+	assert.Equal(t, "true", rows[2]["active"])
+	assert.Equal(t, "false", rows[1]["active"])
+
+	// Blocks outside of the table are reported.
+	_, err = NewDumpScan(transaction, "test_table", ts.layout, 1)
+	assert.ErrorContains(t, err, "block 1 is out of range: table test_table has 1 blocks")
+}
+
+func TestDumpScan_CorruptedValues(t *testing.T) {
+	ts, transaction, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+		require.NoError(t, ts.SetString("name", "valid"))
+	}
+
+	// Corrupt the length prefixes of the names of the first two records, and the flag of the third.
+	block := file.NewBlockId("test_table.tbl", 0)
+	require.NoError(t, transaction.Pin(block))
+	defer transaction.Unpin(block)
+	slotSize := ts.layout.SlotSize()
+	require.NoError(t, transaction.SetInt(block, ts.layout.Offset("name"), 1<<40, false))
+	require.NoError(t, transaction.SetInt(block, slotSize+ts.layout.Offset("name"), -3, false))
+	require.NoError(t, transaction.SetInt(block, 2*slotSize, 7, false))
+
+	ds, err := NewDumpScan(transaction, "test_table", ts.layout, 0)
+	require.NoError(t, err)
+	defer ds.Close()
+
+	rows := dumpRows(t, ds)
+	assert.Contains(t, rows[0]["name"], "<undecodable: string length 1099511627776")
+	assert.Contains(t, rows[1]["name"], "<undecodable: string length -3")
+	assert.Equal(t, "1", rows[0]["id"], "the other fields of a corrupted record are still decoded")
+	assert.Equal(t, "invalid (7)", rows[2][DumpFlagField])
+	assert.Equal(t, `"valid"`, rows[2]["name"])
+}
@@ -1,6 +1,7 @@
 package table
 
 import (
+	"errors"
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/record"
@@ -20,10 +21,30 @@ type Scan struct {
 	tx          *tx.Transaction
 	layout      *record.Layout
 	recordPage  *record.Page
+	pinned      bool
 	fileName    string
 	currentSlot int
+	// readableBlocks is the number of blocks the scan reads, if the transaction reads the file
+	// under tx.RelaxedPhantomProtection, or -1 if the scan reads up to the current end of the file.
+	readableBlocks int
+	// firstBlock and endBlock are the range of blocks read by a scan created by NewBlockRangeScan,
+	// from firstBlock up to but excluding endBlock. endBlock is -1 for a scan of the whole file.
+	firstBlock int
+	endBlock   int
+	// onChange is called with each record the scan changes (see SetChangeHandler), and changedBlock and
+	// changedSlot are the record it was last called with, if changed is true.
+	onChange     func()
+	changed      bool
+	changedBlock int
+	changedSlot  int
+	// projected is true for a scan created by NewProjectedTableScan, whose layout only holds the fields it reads.
+	projected bool
 }
 
+// ErrProjectedScan is returned when a scan reading only some of the fields of a table is used to change its records,
+// or to read one of the fields it leaves out.
+var ErrProjectedScan = errors.New("projected table scan")
+
 // NewTableScan creates a new table scan
 func NewTableScan(tx *tx.Transaction, tableName string, layout *record.Layout) (*Scan, error) {
 	if layout.SlotSize() > tx.BlockSize() {
@@ -31,18 +52,25 @@ func NewTableScan(tx *tx.Transaction, tableName string, layout *record.Layout) (
 	}
 
 	ts := &Scan{
-		tx:          tx,
-		layout:      layout,
-		fileName:    tableName + fileExtension,
-		currentSlot: -1,
+		tx:             tx,
+		layout:         layout,
+		fileName:       tableName + fileExtension,
+		currentSlot:    -1,
+		readableBlocks: -1,
+		endBlock:       -1,
 	}
 
 	size, err := tx.Size(ts.fileName)
 	if err != nil {
 		return nil, fmt.Errorf("get file size: %w", err)
 	}
+	ts.limitReadableBlocks(size)
 
 	if size == 0 {
+		// A read-only transaction cannot append the first block, so the scan of the empty file stays empty.
+		if tx.ReadOnly() {
+			return ts, nil
+		}
 		if err := ts.moveToNewBlock(); err != nil {
 			return nil, fmt.Errorf("move to new block: %w", err)
 		}
@@ -55,7 +83,62 @@ func NewTableScan(tx *tx.Transaction, tableName string, layout *record.Layout) (
 	return ts, nil
 }
 
+// NewProjectedTableScan creates a table scan like NewTableScan that only reads the specified fields of the table,
+// which are the only fields it has (see HasField), so that the scan skips the work of the others.
+// The scan reads the records through a projection of the layout of the table (see record.Layout#Projection),
+// so it cannot change them: inserting, deleting or setting a field returns an error wrapping ErrProjectedScan.
+func NewProjectedTableScan(tx *tx.Transaction, tableName string, layout *record.Layout, fields []string) (*Scan, error) {
+	// The scan is created with the layout of the table, with which it formats the first block of an empty table.
+	ts, err := NewTableScan(tx, tableName, layout)
+	if err != nil {
+		return nil, err
+	}
+	ts.layout = layout.Projection(fields)
+	ts.projected = true
+	return ts, nil
+}
+
+// NewBlockRangeScan creates a table scan reading the blocks of the table from firstBlock up to but excluding
+// endBlock, which the caller has checked are in the file. Unlike a scan of the whole table, it neither reads
+// the size of the file nor appends a block to it, so it is meant for reading: several scans of contiguous
+// ranges read the table in parallel, each in its own transaction.
+func NewBlockRangeScan(tx *tx.Transaction, tableName string, layout *record.Layout, firstBlock, endBlock int) (*Scan, error) {
+	if layout.SlotSize() > tx.BlockSize() {
+		return nil, fmt.Errorf("record slot size (%d) exceeds block size (%d)", layout.SlotSize(), tx.BlockSize())
+	}
+
+	ts := &Scan{
+		tx:             tx,
+		layout:         layout,
+		fileName:       tableName + fileExtension,
+		currentSlot:    -1,
+		readableBlocks: -1,
+		firstBlock:     firstBlock,
+		endBlock:       endBlock,
+	}
+	if firstBlock >= endBlock {
+		return ts, nil
+	}
+	if err := ts.moveToBlock(firstBlock); err != nil {
+		return nil, fmt.Errorf("move to block %d: %w", firstBlock, err)
+	}
+	return ts, nil
+}
+
 func (ts *Scan) BeforeFirst() error {
+	if ts.recordPage == nil {
+		return nil
+	}
+	if ts.endBlock >= 0 {
+		return ts.moveToBlock(ts.firstBlock)
+	}
+	if ts.readableBlocks >= 0 {
+		size, err := ts.tx.Size(ts.fileName)
+		if err != nil {
+			return fmt.Errorf("get file size: %w", err)
+		}
+		ts.limitReadableBlocks(size)
+	}
 	return ts.moveToBlock(0)
 }
 
@@ -64,55 +147,93 @@ func (ts *Scan) BeforeFirst() error {
 // Internally, it moves to the next slot in the current block.
 // If there are no more slots in the block, it moves to the next block.
 // If there are no more blocks, it returns false.
+// Only the end of a block moves the scan to the next one: an error reading a slot is returned.
 func (ts *Scan) Next() (bool, error) {
-	slot, err := ts.recordPage.NextAfter(ts.currentSlot)
+	if ts.recordPage == nil {
+		return false, nil
+	}
+	for {
+		slot, err := ts.recordPage.NextAfter(ts.currentSlot)
+		if err == nil {
+			ts.currentSlot = slot
+			return true, nil
+		}
+		if !errors.Is(err, record.ErrNoSlotFound) {
+			return false, err
+		}
 
-	if err != nil {
-		atLastBlock, err := ts.atLastBlock()
+		atLastBlock, err := ts.atLastReadableBlock()
 		if err != nil {
 			return false, err
 		}
 		if atLastBlock {
 			return false, nil
 		}
-		// Move to the next block in the file, and load it into the record page. This will also move to the first slot.
+		// Move to the next block in the file, and load it into the record page. This will also move before the first slot.
+		// The block may have no records left, in which case the scan moves on to the block after it.
 		if err := ts.moveToBlock(ts.recordPage.Block().Number() + 1); err != nil {
 			return false, err
 		}
-		if slot, err = ts.recordPage.NextAfter(ts.currentSlot); err != nil {
-			return false, err
-		}
 	}
-
-	ts.currentSlot = slot
-	return true, nil
 }
 
 func (ts *Scan) GetInt(fieldName string) (int, error) {
+	if err := ts.checkReadable(fieldName); err != nil {
+		return 0, err
+	}
 	return ts.recordPage.GetInt(ts.currentSlot, fieldName)
 }
 
 func (ts *Scan) GetLong(fieldName string) (int64, error) {
+	if err := ts.checkReadable(fieldName); err != nil {
+		return 0, err
+	}
 	return ts.recordPage.GetLong(ts.currentSlot, fieldName)
 }
 
 func (ts *Scan) GetShort(fieldName string) (int16, error) {
+	if err := ts.checkReadable(fieldName); err != nil {
+		return 0, err
+	}
 	return ts.recordPage.GetShort(ts.currentSlot, fieldName)
 }
 
+func (ts *Scan) GetFloat(fieldName string) (float64, error) {
+	if err := ts.checkReadable(fieldName); err != nil {
+		return 0, err
+	}
+	return ts.recordPage.GetFloat(ts.currentSlot, fieldName)
+}
+
 func (ts *Scan) GetString(fieldName string) (string, error) {
+	if err := ts.checkReadable(fieldName); err != nil {
+		return "", err
+	}
 	return ts.recordPage.GetString(ts.currentSlot, fieldName)
 }
 
 func (ts *Scan) GetBool(fieldName string) (bool, error) {
+	if err := ts.checkReadable(fieldName); err != nil {
+		return false, err
+	}
 	return ts.recordPage.GetBool(ts.currentSlot, fieldName)
 }
 
 func (ts *Scan) GetDate(fieldName string) (time.Time, error) {
+	if err := ts.checkReadable(fieldName); err != nil {
+		return time.Time{}, err
+	}
 	return ts.recordPage.GetDate(ts.currentSlot, fieldName)
 }
 
+// GetVal returns the value of the specified field in the current record, or nil if the field is null.
 func (ts *Scan) GetVal(fieldName string) (any, error) {
+	if err := ts.checkReadable(fieldName); err != nil {
+		return nil, err
+	}
+	if null, err := ts.recordPage.IsNull(ts.currentSlot, fieldName); err != nil || null {
+		return nil, err
+	}
 	fieldType := ts.layout.Schema().Type(fieldName)
 
 	switch fieldType {
@@ -125,6 +246,9 @@ func (ts *Scan) GetVal(fieldName string) (any, error) {
 	case types.Short:
 		val, err := ts.GetShort(fieldName)
 		return val, err
+	case types.Float:
+		val, err := ts.GetFloat(fieldName)
+		return val, err
 	case types.Varchar:
 		val, err := ts.GetString(fieldName)
 		return val, err
@@ -140,31 +264,81 @@ func (ts *Scan) GetVal(fieldName string) (any, error) {
 }
 
 func (ts *Scan) SetInt(fieldName string, val int) error {
+	if err := ts.lockForSet(); err != nil {
+		return err
+	}
 	return ts.recordPage.SetInt(ts.currentSlot, fieldName, val)
 }
 
 func (ts *Scan) SetLong(fieldName string, val int64) error {
+	if err := ts.lockForSet(); err != nil {
+		return err
+	}
 	return ts.recordPage.SetLong(ts.currentSlot, fieldName, val)
 }
 
 func (ts *Scan) SetShort(fieldName string, val int16) error {
+	if err := ts.lockForSet(); err != nil {
+		return err
+	}
 	return ts.recordPage.SetShort(ts.currentSlot, fieldName, val)
 }
 
+func (ts *Scan) SetFloat(fieldName string, val float64) error {
+	if err := ts.lockForSet(); err != nil {
+		return err
+	}
+	return ts.recordPage.SetFloat(ts.currentSlot, fieldName, val)
+}
+
 func (ts *Scan) SetString(fieldName string, val string) error {
+	if err := ts.lockForSet(); err != nil {
+		return err
+	}
 	return ts.recordPage.SetString(ts.currentSlot, fieldName, val)
 }
 
 func (ts *Scan) SetBool(fieldName string, val bool) error {
+	if err := ts.lockForSet(); err != nil {
+		return err
+	}
 	return ts.recordPage.SetBool(ts.currentSlot, fieldName, val)
 }
 
 func (ts *Scan) SetDate(fieldName string, val time.Time) error {
+	if err := ts.lockForSet(); err != nil {
+		return err
+	}
 	return ts.recordPage.SetDate(ts.currentSlot, fieldName, val)
 }
 
+// SetNull marks the specified field of the current record as null.
+func (ts *Scan) SetNull(fieldName string) error {
+	if err := ts.lockForSet(); err != nil {
+		return err
+	}
+	return ts.recordPage.SetNull(ts.currentSlot, fieldName)
+}
+
+// SetVal sets the value of the specified field in the current record, or marks it as null if the value is nil.
+// Integers of any size are converted to the size of an integer field, or to a float for a float field,
+// and an integer that does not fit the field results in an error wrapping types.ErrValueOutOfRange.
 func (ts *Scan) SetVal(fieldName string, val any) error {
-	switch ts.layout.Schema().Type(fieldName) {
+	if val == nil {
+		return ts.SetNull(fieldName)
+	}
+	fieldType := ts.layout.Schema().Type(fieldName)
+	if fieldType == types.Integer || fieldType == types.Long || fieldType == types.Short || fieldType == types.Float {
+		coerced, err := types.CoerceValue(val, fieldType)
+		if errors.Is(err, types.ErrValueOutOfRange) {
+			return fmt.Errorf("invalid value for field %s: %w", fieldName, err)
+		}
+		if err == nil {
+			val = coerced
+		}
+	}
+
+	switch fieldType {
 	case types.Integer:
 		if v, ok := val.(int); ok {
 			return ts.SetInt(fieldName, v)
@@ -177,6 +351,10 @@ func (ts *Scan) SetVal(fieldName string, val any) error {
 		if v, ok := val.(int16); ok {
 			return ts.SetShort(fieldName, v)
 		}
+	case types.Float:
+		if v, ok := val.(float64); ok {
+			return ts.SetFloat(fieldName, v)
+		}
 	case types.Varchar:
 		if v, ok := val.(string); ok {
 			return ts.SetString(fieldName, v)
@@ -193,33 +371,52 @@ func (ts *Scan) SetVal(fieldName string, val any) error {
 	return fmt.Errorf("type mismatch for field %s", fieldName)
 }
 
+// HasField returns true if the specified field is a field of the table read by the scan.
 func (ts *Scan) HasField(fieldName string) bool {
 	return ts.layout.Schema().HasField(fieldName)
 }
 
+// Fields returns the fields of the table read by the scan, in the order of its layout's schema.
+func (ts *Scan) Fields() []types.FieldInfo {
+	return ts.layout.Schema().FieldInfos()
+}
+
 // Close closes the scan.
-// Unpins the current record page.
-func (ts *Scan) Close() {
-	if ts.recordPage != nil {
-		ts.tx.Unpin(ts.recordPage.Block())
-	}
+// Unpins the current record page, unless it is already unpinned, so that closing the scan again has no effect.
+// A closed scan can be reused by calling BeforeFirst, which pins the first block again.
+func (ts *Scan) Close() error {
+	ts.unpin()
+	return nil
 }
 
 // Insert inserts a new record somewhere in the scan and moves the scan to the new record.
-// If there is no room in the current block, it moves to the next block.
+// If there is no room after the current record of the current block, the record is inserted into an empty slot
+// preceding it: the slots of a block all have the size of a record, so a slot freed by a delete is reused in place,
+// without moving the other records of the block. Otherwise, if there is no room in the current block, it moves to
+// the next block.
 // If there are no more blocks, it creates a new block.
 func (ts *Scan) Insert() error {
 	if ts.layout.SlotSize() > ts.tx.BlockSize() {
 		return fmt.Errorf("record slot size (%d) exceeds block size (%d)", ts.layout.SlotSize(), ts.tx.BlockSize())
 	}
+	if err := ts.lockForUpdate(); err != nil {
+		return err
+	}
 
 	for {
 		slot, err := ts.recordPage.InsertAfter(ts.currentSlot)
+		if errors.Is(err, record.ErrNoSlotFound) && ts.currentSlot >= 0 {
+			slot, err = ts.recordPage.InsertAfter(-1)
+		}
 		if err == nil {
 			// Successfully inserted
 			ts.currentSlot = slot
+			ts.noteChange()
 			return nil
 		}
+		if !errors.Is(err, record.ErrNoSlotFound) {
+			return err
+		}
 
 		// Check if we are at the last block.
 		atLastBlock, err2 := ts.atLastBlock()
@@ -245,15 +442,33 @@ func (ts *Scan) Insert() error {
 }
 
 func (ts *Scan) Delete() error {
-	return ts.recordPage.Delete(ts.currentSlot)
+	if err := ts.lockForUpdate(); err != nil {
+		return err
+	}
+	if err := ts.recordPage.Delete(ts.currentSlot); err != nil {
+		return err
+	}
+	ts.noteChange()
+	// a record inserted later into the same slot is another change.
+	ts.changed = false
+	return nil
+}
+
+// SetChangeHandler sets the function called with each record that the scan inserts, deletes or modifies,
+// once per record however many of its fields are set, so that the changes to the table can be counted.
+func (ts *Scan) SetChangeHandler(handler func()) {
+	ts.onChange = handler
 }
 
-func (ts *Scan) GetRecordID() *record.ID {
-	return record.NewID(ts.recordPage.Block().Number(), ts.currentSlot)
+func (ts *Scan) GetRecordID() (*record.ID, error) {
+	if ts.recordPage == nil {
+		return nil, fmt.Errorf("scan of table %s is not positioned in a block", ts.fileName)
+	}
+	return record.NewID(ts.recordPage.Block().Number(), ts.currentSlot), nil
 }
 
 func (ts *Scan) MoveToRecordID(rid *record.ID) error {
-	ts.Close()
+	ts.unpin()
 
 	blk := &file.BlockId{
 		File:        ts.fileName,
@@ -266,54 +481,188 @@ func (ts *Scan) MoveToRecordID(rid *record.ID) error {
 	}
 
 	ts.recordPage = page
+	ts.pinned = true
 	ts.currentSlot = rid.Slot()
 	return nil
 }
 
+// MoveToRecordIDIfUsed moves the scan to the record having the specified ID, like MoveToRecordID,
+// if the ID identifies a record of the table: its block must be part of the table's file, and its slot
+// must hold a record. It returns false otherwise, in which case the scan has no current record.
+// It is meant for record IDs read from an index that may be out of date.
+func (ts *Scan) MoveToRecordIDIfUsed(rid *record.ID) (bool, error) {
+	size, err := ts.tx.Size(ts.fileName)
+	if err != nil {
+		return false, fmt.Errorf("get file size: %w", err)
+	}
+	if rid.BlockNumber() < 0 || rid.BlockNumber() >= size || rid.Slot() < 0 {
+		return false, nil
+	}
+	if err := ts.MoveToRecordID(rid); err != nil {
+		return false, err
+	}
+	if rid.Slot() >= ts.recordPage.NumSlots() {
+		return false, nil
+	}
+	flag, err := ts.recordPage.RawFlag(rid.Slot())
+	if err != nil {
+		return false, err
+	}
+	return flag == record.FlagUsed, nil
+}
+
+// LockTable obtains an exclusive lock on the entire table, held until the transaction completes.
+// It waits for every transaction that is modifying the table to complete, and blocks new
+// modifications until then, so that the caller sees a stable set of records.
+func LockTable(tx *tx.Transaction, tableName string) error {
+	return tx.XLockFile(tableName + fileExtension)
+}
+
+// HoldsTableLock returns true if the transaction holds a lock on the entire table,
+// which it does once it has modified the records of the table or locked it (see LockTable).
+func HoldsTableLock(tx *tx.Transaction, tableName string) bool {
+	return tx.HasFileLock(tableName + fileExtension)
+}
+
+// BlockCount returns the number of blocks of the specified table (see tx.Transaction#Size).
+func BlockCount(tx *tx.Transaction, tableName string) (int, error) {
+	return tx.Size(tableName + fileExtension)
+}
+
+// BlockID returns the ID of the specified block of the specified table.
+func BlockID(tableName string, blockNumber int) *file.BlockId {
+	return file.NewBlockId(tableName+fileExtension, blockNumber)
+}
+
+// EnableCompression makes the file of the specified table store its blocks compressed.
+// It must be called before any record is inserted into the table.
+func EnableCompression(tx *tx.Transaction, tableName string) error {
+	return tx.EnableCompression(tableName + fileExtension)
+}
+
+// RemoveTable deletes the file of the specified table (see tx.Transaction#RemoveFile).
+func RemoveTable(tx *tx.Transaction, tableName string) error {
+	return tx.RemoveFile(tableName + fileExtension)
+}
+
 // Private helper methods
 
+// lockForUpdate obtains the shared table lock held by every transaction modifying the table.
+// It returns an error if the scan only reads some of the fields of the table, and cannot change its records.
+func (ts *Scan) lockForUpdate() error {
+	if ts.projected {
+		return fmt.Errorf("%w: the scan of file %s reads only fields %v, so it cannot change records", ErrProjectedScan, ts.fileName, ts.layout.Schema().Fields())
+	}
+	return ts.tx.SLockFile(ts.fileName)
+}
+
+// checkReadable returns an error if the scan only reads some of the fields of the table, and not the specified one.
+func (ts *Scan) checkReadable(fieldName string) error {
+	if ts.projected && !ts.layout.Schema().HasField(fieldName) {
+		return fmt.Errorf("%w: the scan of file %s does not read field %s", ErrProjectedScan, ts.fileName, fieldName)
+	}
+	return nil
+}
+
+// lockForSet obtains the lock of lockForUpdate before a field of the current record is set, and notes the change.
+func (ts *Scan) lockForSet() error {
+	if err := ts.lockForUpdate(); err != nil {
+		return err
+	}
+	ts.noteChange()
+	return nil
+}
+
+// noteChange calls the change handler with the current record, unless it was the last record changed.
+func (ts *Scan) noteChange() {
+	if ts.onChange == nil {
+		return
+	}
+	block := ts.recordPage.Block().Number()
+	if ts.changed && ts.changedBlock == block && ts.changedSlot == ts.currentSlot {
+		return
+	}
+	ts.changed, ts.changedBlock, ts.changedSlot = true, block, ts.currentSlot
+	ts.onChange()
+}
+
 // moveToBlock moves the scan to the specified block number.
+// The scan only moves to the first block or to the block following the current one,
+// so it pins the block as part of a sequential scan.
 func (ts *Scan) moveToBlock(blockNum int) error {
-	ts.Close()
+	ts.unpin()
 
 	blk := &file.BlockId{
 		File:        ts.fileName,
 		BlockNumber: blockNum,
 	}
 
-	page, err := record.NewPage(ts.tx, blk, ts.layout)
+	page, err := record.NewSequentialPage(ts.tx, blk, ts.layout)
 	if err != nil {
 		return fmt.Errorf("create new page: %w", err)
 	}
 
 	ts.recordPage = page
+	ts.pinned = true
 	ts.currentSlot = -1
 	return nil
 }
 
 // moveToNewBlock moves the scan to a new block. It appends a new block to the file and loads it into the record page.
 func (ts *Scan) moveToNewBlock() error {
-	ts.Close()
+	ts.unpin()
 
 	blk, err := ts.tx.Append(ts.fileName)
 	if err != nil {
 		return fmt.Errorf("append block: %w", err)
 	}
+	if ts.readableBlocks >= 0 {
+		ts.readableBlocks = max(ts.readableBlocks, blk.Number()+1)
+	}
 
 	page, err := record.NewPage(ts.tx, blk, ts.layout)
 	if err != nil {
 		return fmt.Errorf("create new page: %w", err)
 	}
+	ts.recordPage = page
+	ts.pinned = true
+	ts.currentSlot = -1
 
 	if err := page.Format(); err != nil {
 		return fmt.Errorf("format page: %w", err)
 	}
-
-	ts.recordPage = page
-	ts.currentSlot = -1
 	return nil
 }
 
+// unpin unpins the current record page if it is pinned.
+func (ts *Scan) unpin() {
+	if ts.recordPage != nil && ts.pinned {
+		ts.tx.Unpin(ts.recordPage.Block())
+		ts.pinned = false
+	}
+}
+
+// limitReadableBlocks limits the scan to the specified number of blocks,
+// if the transaction reads the file under tx.RelaxedPhantomProtection.
+func (ts *Scan) limitReadableBlocks(size int) {
+	if ts.tx.PhantomProtection() == tx.RelaxedPhantomProtection {
+		ts.readableBlocks = size
+	}
+}
+
+// atLastReadableBlock returns true if the scan is at the last block it reads.
+// A scan of a block range stops at the end of its range.
+// A scan limited to the blocks the file had when it was opened still reads the blocks it appended itself.
+func (ts *Scan) atLastReadableBlock() (bool, error) {
+	if ts.endBlock >= 0 {
+		return ts.recordPage.Block().Number() >= ts.endBlock-1, nil
+	}
+	if ts.readableBlocks < 0 {
+		return ts.atLastBlock()
+	}
+	return ts.recordPage.Block().Number() >= ts.readableBlocks-1, nil
+}
+
 // atLastBlock returns true if the scan is at the last block.
 func (ts *Scan) atLastBlock() (bool, error) {
 	fileSize, err := ts.tx.Size(ts.fileName)
@@ -5,8 +5,11 @@ import (
 	"github.com/JyotinderSingh/dropdb/buffer"
 	"github.com/JyotinderSingh/dropdb/log"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
+	"math"
 	"math/rand"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,7 +25,7 @@ func setupTestTable(t *testing.T) (*Scan, *tx.Transaction, func()) {
 	dbDir := t.TempDir()
 
 	// Set up temporary file manager
-	fm, err := file.NewManager(dbDir, 400)
+	fm, err := file.NewManager(dbDir, 512)
 	require.NoError(t, err)
 
 	// Set up log manager
@@ -224,7 +227,8 @@ func TestTableScan_RecordID(t *testing.T) {
 	require.NoError(t, err)
 
 	// Get its RID
-	rid := ts.GetRecordID()
+	rid, err := ts.GetRecordID()
+	require.NoError(t, err)
 	require.NotNil(t, rid)
 
 	// Insert another record
@@ -247,6 +251,67 @@ func TestTableScan_RecordID(t *testing.T) {
 	assert.Equal(t, "John", name)
 }
 
+func TestTableScan_CorruptStringLength(t *testing.T) {
+	ts, transaction, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	require.NoError(t, ts.Insert())
+	require.NoError(t, ts.SetInt("id", 1))
+	require.NoError(t, ts.SetString("name", "John"))
+	rid, err := ts.GetRecordID()
+	require.NoError(t, err)
+
+	// Overwrite the length prefix of the name with garbage.
+	block := file.NewBlockId(ts.fileName, rid.BlockNumber())
+	offset := rid.Slot()*ts.layout.SlotSize() + ts.layout.Offset("name")
+	require.NoError(t, transaction.SetInt(block, offset, math.MaxInt32, false))
+
+	_, err = ts.GetString("name")
+	require.ErrorIs(t, err, file.ErrCorruptValue)
+	var corrupt *file.CorruptValueError
+	require.ErrorAs(t, err, &corrupt)
+	assert.True(t, block.Equals(corrupt.Block))
+	assert.Equal(t, offset, corrupt.Offset)
+	assert.Equal(t, math.MaxInt32, corrupt.Length)
+
+	// The other fields of the record can still be read.
+	id, err := ts.GetInt("id")
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+}
+
+func TestTableScan_UTF8Strings(t *testing.T) {
+	ts, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	// Strings whose length in bytes differs from their number of characters, up to the 20 characters of the
+	// name field encoded in 4 bytes each. The records span several blocks, so that the values are read back
+	// from pages that were written out and read in again.
+	names := []string{
+		"café", "東京都", "👩‍👩‍👧", "e\u0301te\u0301", "한국어 텍스트", strings.Repeat("🦀", 20), strings.Repeat("é", 20), "",
+	}
+	for i := 0; i < 40; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+		require.NoError(t, ts.SetString("name", names[i%len(names)]))
+	}
+
+	require.NoError(t, ts.BeforeFirst())
+	for i := 0; i < 40; i++ {
+		next, err := ts.Next()
+		require.NoError(t, err)
+		require.True(t, next)
+		id, err := ts.GetInt("id")
+		require.NoError(t, err)
+		name, err := ts.GetString("name")
+		require.NoError(t, err)
+		assert.Equal(t, names[id%len(names)], name, "record %d", id)
+	}
+	next, err := ts.Next()
+	require.NoError(t, err)
+	assert.False(t, next)
+}
+
 func TestTableScan_MultiBlock(t *testing.T) {
 	ts, _, cleanup := setupTestTable(t)
 	defer cleanup()
@@ -284,6 +349,56 @@ func TestTableScan_MultiBlock(t *testing.T) {
 	assert.Equal(t, numRecords, lastID)
 }
 
+func TestTableScan_SkipsEmptyBlocks(t *testing.T) {
+	ts, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	numRecords := 100
+	for i := 1; i <= numRecords; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+	}
+
+	// Delete every record outside the last block, leaving empty blocks at the start of the file.
+	require.NoError(t, ts.BeforeFirst())
+	var lastBlock, remaining int
+	for {
+		found, err := ts.Next()
+		require.NoError(t, err)
+		if !found {
+			break
+		}
+		lastBlock = recordID(t, ts).BlockNumber()
+	}
+	require.Greater(t, lastBlock, 1)
+	require.NoError(t, ts.BeforeFirst())
+	for {
+		found, err := ts.Next()
+		require.NoError(t, err)
+		if !found {
+			break
+		}
+		if recordID(t, ts).BlockNumber() < lastBlock {
+			require.NoError(t, ts.Delete())
+		} else {
+			remaining++
+		}
+	}
+
+	require.NoError(t, ts.BeforeFirst())
+	count := 0
+	for {
+		found, err := ts.Next()
+		require.NoError(t, err)
+		if !found {
+			break
+		}
+		assert.Equal(t, lastBlock, recordID(t, ts).BlockNumber())
+		count++
+	}
+	assert.Equal(t, remaining, count)
+}
+
 func TestTableScanOperations(t *testing.T) {
 	dbDir := t.TempDir()
 	defer os.RemoveAll(dbDir)
@@ -312,8 +427,8 @@ func TestTableScanOperations(t *testing.T) {
 	layout := record.NewLayout(schema)
 
 	// Verify field offsets
-	assert.Equal(t, 8, layout.Offset("A"), "Incorrect offset for field A")
-	assert.Equal(t, 16, layout.Offset("B"), "Incorrect offset for field B")
+	assert.Equal(t, 16, layout.Offset("A"), "Incorrect offset for field A")
+	assert.Equal(t, 24, layout.Offset("B"), "Incorrect offset for field B")
 
 	// Create table scan
 	ts, err := NewTableScan(transaction, "T", layout)
@@ -337,7 +452,8 @@ func TestTableScanOperations(t *testing.T) {
 		err = ts.SetString("B", fmt.Sprintf("rec%d", n))
 		require.NoError(t, err)
 
-		rid := ts.GetRecordID()
+		rid, err := ts.GetRecordID()
+		require.NoError(t, err)
 		require.NotNil(t, rid)
 		expectedRecords[n] = fmt.Sprintf("rec%d", n)
 		insertedValues = append(insertedValues, n)
@@ -417,3 +533,632 @@ func TestTableScanOperations(t *testing.T) {
 	}
 	assert.Equal(t, expectedDeleted, deletedCount, "Incorrect number of records deleted")
 }
+
+func TestTableScan_SequentialPrefetch(t *testing.T) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+
+	// Each record fills most of a block, so the table has one block per record.
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("payload", 80)
+	layout := record.NewLayout(schema)
+	require.Greater(t, 2*layout.SlotSize(), fm.BlockSize())
+
+	numBlocks := 200
+	transaction := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), concurrency.NewLockTable())
+	ts, err := NewTableScan(transaction, "wide_table", layout)
+	require.NoError(t, err)
+	for i := 1; i <= numBlocks; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+		require.NoError(t, ts.SetString("payload", fmt.Sprintf("payload %d", i)))
+	}
+	ts.Close()
+	require.NoError(t, transaction.Commit())
+
+	// scanIDs scans the table using a cold buffer pool with the specified prefetch depth.
+	scanIDs := func(prefetchDepth int) ([]int, buffer.Stats) {
+		bm := buffer.NewManager(fm, lm, 16)
+		bm.SetPrefetchDepth(prefetchDepth)
+		transaction := tx.NewTransaction(fm, lm, bm, concurrency.NewLockTable())
+
+		ts, err := NewTableScan(transaction, "wide_table", layout)
+		require.NoError(t, err)
+		var ids []int
+		for {
+			next, err := ts.Next()
+			require.NoError(t, err)
+			if !next {
+				break
+			}
+			id, err := ts.GetInt("id")
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+		ts.Close()
+		require.NoError(t, transaction.Commit())
+
+		bm.WaitForPrefetch()
+		return ids, bm.Stats()
+	}
+
+	idsWithoutPrefetch, statsWithoutPrefetch := scanIDs(0)
+	idsWithPrefetch, statsWithPrefetch := scanIDs(8)
+
+	require.Len(t, idsWithoutPrefetch, numBlocks)
+	assert.Equal(t, idsWithoutPrefetch, idsWithPrefetch)
+
+	assert.Equal(t, numBlocks, statsWithoutPrefetch.Reads)
+	assert.Zero(t, statsWithoutPrefetch.PrefetchIssued)
+	assert.Less(t, statsWithPrefetch.Reads, numBlocks/4, "most blocks should be read ahead of the scan")
+	assert.Greater(t, statsWithPrefetch.PrefetchHits, numBlocks/2)
+	t.Logf("without prefetch: %+v, with prefetch: %+v", statsWithoutPrefetch, statsWithPrefetch)
+}
+
+func TestTableScan_SetValRange(t *testing.T) {
+	ts, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	require.NoError(t, ts.Insert())
+
+	// Integers of any size are stored if they fit the field.
+	require.NoError(t, ts.SetVal("code", math.MaxInt16))
+	require.NoError(t, ts.SetVal("count", math.MinInt64))
+	require.NoError(t, ts.SetVal("id", int16(-7)))
+
+	code, err := ts.GetShort("code")
+	require.NoError(t, err)
+	assert.Equal(t, int16(math.MaxInt16), code)
+	count, err := ts.GetLong("count")
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), count)
+	id, err := ts.GetInt("id")
+	require.NoError(t, err)
+	assert.Equal(t, -7, id)
+
+	// Integers that do not fit are rejected rather than truncated.
+	err = ts.SetVal("code", math.MaxInt16+1)
+	assert.ErrorIs(t, err, types.ErrValueOutOfRange)
+	assert.EqualError(t, err, "invalid value for field code: value out of range: 32768 does not fit type short")
+	err = ts.SetVal("code", int64(math.MinInt16-1))
+	assert.ErrorIs(t, err, types.ErrValueOutOfRange)
+
+	code, err = ts.GetShort("code")
+	require.NoError(t, err)
+	assert.Equal(t, int16(math.MaxInt16), code)
+
+	// Values of other types are still a type mismatch.
+	assert.EqualError(t, ts.SetVal("code", "1"), "type mismatch for field code")
+}
+
+func TestTableScan_CloseIsIdempotent(t *testing.T) {
+	ts, transaction, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	require.NoError(t, ts.Insert())
+	require.NoError(t, ts.SetInt("id", 1))
+
+	// A second scan pins the same block.
+	other, err := NewTableScan(transaction, "test_table", ts.layout)
+	require.NoError(t, err)
+	defer other.Close()
+	available := transaction.AvailableBuffers()
+
+	// Closing the scan twice must release its pin only once, leaving the pin of the other scan.
+	require.NoError(t, ts.Close())
+	require.NoError(t, ts.Close())
+	assert.Equal(t, available, transaction.AvailableBuffers())
+
+	next, err := other.Next()
+	require.NoError(t, err)
+	require.True(t, next)
+	id, err := other.GetInt("id")
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	// A closed scan can be reopened by moving it before the first record.
+	require.NoError(t, ts.BeforeFirst())
+	next, err = ts.Next()
+	require.NoError(t, err)
+	assert.True(t, next)
+}
+
+func TestTableScan_RelaxedPhantomProtection(t *testing.T) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	// Each record fills most of a block, so that every block is full and inserts append blocks.
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("payload", 80)
+	layout := record.NewLayout(schema)
+	require.Greater(t, 2*layout.SlotSize(), fm.BlockSize())
+
+	insert := func(transaction *tx.Transaction, first, count int) error {
+		ts, err := NewTableScan(transaction, "events", layout)
+		if err != nil {
+			return err
+		}
+		defer ts.Close()
+		for id := first; id < first+count; id++ {
+			if err := ts.Insert(); err != nil {
+				return err
+			}
+			if err := ts.SetInt("id", id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// insertConcurrently inserts records in a separate transaction, returning the channel the result is sent to.
+	insertConcurrently := func(first, count int) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			writer := tx.NewTransaction(fm, lm, bm, lt)
+			if err := insert(writer, first, count); err != nil {
+				_ = writer.Rollback()
+				done <- err
+				return
+			}
+			done <- writer.Commit()
+		}()
+		return done
+	}
+	countRecords := func(ts *Scan) int {
+		count := 0
+		for {
+			next, err := ts.Next()
+			require.NoError(t, err)
+			if !next {
+				return count
+			}
+			count++
+		}
+	}
+
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, insert(transaction, 0, 5))
+	require.NoError(t, transaction.Commit())
+
+	// Under relaxed phantom protection, inserts proceed while the scan is in progress,
+	// and the scan does not see them.
+	reader := tx.NewTransaction(fm, lm, bm, lt)
+	reader.SetPhantomProtection(tx.RelaxedPhantomProtection)
+	ts, err := NewTableScan(reader, "events", layout)
+	require.NoError(t, err)
+	next, err := ts.Next()
+	require.NoError(t, err)
+	require.True(t, next)
+
+	select {
+	case err := <-insertConcurrently(5, 3):
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the insert waited for the scan to complete")
+	}
+	assert.Equal(t, 4, countRecords(ts), "the scan reads the records that existed when it was opened")
+
+	// Scanning the table again reads the new records.
+	require.NoError(t, ts.BeforeFirst())
+	assert.Equal(t, 8, countRecords(ts))
+	ts.Close()
+	require.NoError(t, reader.Commit())
+
+	// Under serializable phantom protection, the insert waits for the scan's transaction to complete.
+	reader = tx.NewTransaction(fm, lm, bm, lt)
+	ts, err = NewTableScan(reader, "events", layout)
+	require.NoError(t, err)
+	next, err = ts.Next()
+	require.NoError(t, err)
+	require.True(t, next)
+
+	done := insertConcurrently(8, 1)
+	select {
+	case err := <-done:
+		t.Fatalf("the insert did not wait for the scan to complete: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+	assert.Equal(t, 7, countRecords(ts))
+	ts.Close()
+	require.NoError(t, reader.Commit())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the insert did not proceed once the scan completed")
+	}
+}
+
+// logBytesOf returns the number of bytes and the types of the update records the specified transaction wrote to the log.
+func logBytesOf(t *testing.T, lm *log.Manager, txNum int) (int, map[tx.LogRecordType]int) {
+	t.Helper()
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	size := 0
+	ops := map[tx.LogRecordType]int{}
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		logRecord, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		if logRecord.Op() == tx.Checkpoint || logRecord.TxNumber() != txNum ||
+			logRecord.Op() == tx.Start || logRecord.Op() == tx.Commit || logRecord.Op() == tx.Rollback {
+			continue
+		}
+		size += len(bytes)
+		ops[logRecord.Op()]++
+	}
+	return size, ops
+}
+
+func TestTableScan_InsertLogging(t *testing.T) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("name", 20)
+	schema.AddBoolField("active")
+	schema.AddDateField("created")
+	schema.AddLongField("count")
+	schema.AddShortField("code")
+	layout := record.NewLayout(schema)
+	created := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	const rows = 10
+
+	countRows := func() int {
+		transaction := tx.NewTransaction(fm, lm, bm, lt)
+		defer func() { require.NoError(t, transaction.Commit()) }()
+		ts, err := NewTableScan(transaction, "test_table", layout)
+		require.NoError(t, err)
+		defer ts.Close()
+		count := 0
+		for {
+			hasNext, err := ts.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				return count
+			}
+			count++
+		}
+	}
+	writeRows := func(ts *Scan, insert bool) {
+		require.NoError(t, ts.BeforeFirst())
+		for i := 0; i < rows; i++ {
+			if insert {
+				require.NoError(t, ts.Insert())
+			} else {
+				hasNext, err := ts.Next()
+				require.NoError(t, err)
+				require.True(t, hasNext)
+			}
+			require.NoError(t, ts.SetInt("id", i+1))
+			require.NoError(t, ts.SetString("name", fmt.Sprintf("name %d", i)))
+			require.NoError(t, ts.SetBool("active", true))
+			require.NoError(t, ts.SetDate("created", created))
+			require.NoError(t, ts.SetLong("count", int64(i)*1000))
+			require.NoError(t, ts.SetShort("code", int16(i)))
+		}
+	}
+
+	// Format the blocks of the table, so that the measured transactions only write records.
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	ts, err := NewTableScan(transaction, "test_table", layout)
+	require.NoError(t, err)
+	for i := 0; i < rows; i++ {
+		require.NoError(t, ts.Insert())
+	}
+	require.NoError(t, ts.BeforeFirst())
+	for i := 0; i < rows; i++ {
+		hasNext, err := ts.Next()
+		require.NoError(t, err)
+		require.True(t, hasNext)
+		require.NoError(t, ts.Delete())
+	}
+	ts.Close()
+	require.NoError(t, transaction.Commit())
+
+	// Inserting the rows logs two row images per row.
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	ts, err = NewTableScan(transaction, "test_table", layout)
+	require.NoError(t, err)
+	writeRows(ts, true)
+	ts.Close()
+	require.NoError(t, transaction.Commit())
+	insertBytes, insertOps := logBytesOf(t, lm, transaction.TxNum())
+	assert.Equal(t, map[tx.LogRecordType]int{tx.SetRow: 2 * rows}, insertOps)
+
+	// Updating every field of the rows logs one record per field, as inserting them used to.
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	ts, err = NewTableScan(transaction, "test_table", layout)
+	require.NoError(t, err)
+	writeRows(ts, false)
+	ts.Close()
+	require.NoError(t, transaction.Commit())
+	updateBytes, updateOps := logBytesOf(t, lm, transaction.TxNum())
+	assert.Equal(t, 6*rows, updateOps[tx.SetInt]+updateOps[tx.SetString]+updateOps[tx.SetBool]+
+		updateOps[tx.SetDate]+updateOps[tx.SetLong]+updateOps[tx.SetShort])
+
+	// Each field record repeats the file name, block and offset of the field, and holds its old and new values.
+	t.Logf("log bytes per inserted row: %d, per updated row: %d", insertBytes/rows, updateBytes/rows)
+	assert.Less(t, insertBytes, updateBytes)
+	assert.Equal(t, rows, countRows())
+
+	// Rolling back an insertion leaves its slot empty, with the values it held before.
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	ts, err = NewTableScan(transaction, "test_table", layout)
+	require.NoError(t, err)
+	writeRows(ts, true)
+	ts.Close()
+	require.NoError(t, transaction.Rollback())
+	assert.Equal(t, rows, countRows())
+
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	size, err := transaction.Size("test_table" + fileExtension)
+	require.NoError(t, err)
+	used := 0
+	for blockNumber := 0; blockNumber < size; blockNumber++ {
+		dump, err := NewDumpScan(transaction, "test_table", layout, blockNumber)
+		require.NoError(t, err)
+		for {
+			hasNext, err := dump.Next()
+			require.NoError(t, err)
+			if !hasNext {
+				break
+			}
+			flag, err := dump.GetString(DumpFlagField)
+			require.NoError(t, err)
+			if flag == "used" {
+				used++
+				continue
+			}
+			assert.Equal(t, "empty", flag)
+			id, err := dump.GetString("id")
+			require.NoError(t, err)
+			assert.Equal(t, "0", id)
+		}
+		require.NoError(t, dump.Close())
+	}
+	require.NoError(t, transaction.Commit())
+	assert.Equal(t, rows, used)
+}
+
+func TestTableScan_RecoverUncommittedInsert(t *testing.T) {
+	dbDir := t.TempDir()
+	fm, err := file.NewManager(dbDir, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("name", 20)
+	layout := record.NewLayout(schema)
+
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	ts, err := NewTableScan(transaction, "test_table", layout)
+	require.NoError(t, err)
+	require.NoError(t, ts.Insert())
+	require.NoError(t, ts.SetInt("id", 1))
+	require.NoError(t, ts.SetString("name", "committed"))
+	ts.Close()
+	require.NoError(t, transaction.Commit())
+
+	// The insertion is on disk, but the transaction never commits.
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	ts, err = NewTableScan(transaction, "test_table", layout)
+	require.NoError(t, err)
+	require.NoError(t, ts.Insert())
+	require.NoError(t, ts.SetInt("id", 2))
+	require.NoError(t, ts.SetString("name", "lost"))
+	ts.Close()
+	require.NoError(t, bm.FlushAll(transaction.TxNum()))
+
+	recovery := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), concurrency.NewLockTable())
+	require.NoError(t, recovery.Recover())
+
+	transaction = tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), concurrency.NewLockTable())
+	ts, err = NewTableScan(transaction, "test_table", layout)
+	require.NoError(t, err)
+	var names []string
+	for {
+		hasNext, err := ts.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		name, err := ts.GetString("name")
+		require.NoError(t, err)
+		names = append(names, name)
+	}
+	ts.Close()
+	require.NoError(t, transaction.Commit())
+	assert.Equal(t, []string{"committed"}, names)
+}
+
+func TestTableScan_PinnedCount(t *testing.T) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	transaction := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), concurrency.NewLockTable())
+
+	schema := record.NewSchema()
+	schema.AddIntField("id")
+	schema.AddStringField("name", 20)
+	layout := record.NewLayout(schema)
+
+	// Fill three blocks; the scan keeps the block of the last record pinned.
+	ts, err := NewTableScan(transaction, "test_table", layout)
+	require.NoError(t, err)
+	for i := 1; recordID(t, ts).BlockNumber() < 2; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+	}
+	first := file.BlockId{File: "test_table.tbl", BlockNumber: 0}
+	middle := file.BlockId{File: "test_table.tbl", BlockNumber: 1}
+	last := file.BlockId{File: "test_table.tbl", BlockNumber: 2}
+	assert.Equal(t, 1, transaction.PinnedCount())
+	assert.Equal(t, map[file.BlockId]int{last: 1}, transaction.PinnedBlocks())
+
+	// Two more scans positioned on the first block pin it twice.
+	scans := []*Scan{ts}
+	for range 2 {
+		scan, err := NewTableScan(transaction, "test_table", layout)
+		require.NoError(t, err)
+		scans = append(scans, scan)
+		next, err := scan.Next()
+		require.NoError(t, err)
+		require.True(t, next)
+	}
+	assert.Equal(t, 3, transaction.PinnedCount())
+	assert.Equal(t, map[file.BlockId]int{first: 2, last: 1}, transaction.PinnedBlocks())
+
+	// The hook fails the pins taking the transaction above its threshold, whether they pin a new block or not.
+	var hookPins []int
+	errTooManyPins := fmt.Errorf("too many pins")
+	transaction.SetPinHook(3, func(pins int) error {
+		hookPins = append(hookPins, pins)
+		return errTooManyPins
+	})
+	assert.ErrorIs(t, transaction.Pin(&middle), errTooManyPins)
+	assert.ErrorIs(t, transaction.Pin(&first), errTooManyPins)
+	assert.Equal(t, []int{4, 4}, hookPins)
+	assert.Equal(t, 3, transaction.PinnedCount())
+	assert.Equal(t, map[file.BlockId]int{first: 2, last: 1}, transaction.PinnedBlocks())
+
+	transaction.SetPinHook(3, nil)
+	require.NoError(t, transaction.Pin(&middle))
+	assert.Equal(t, 4, transaction.PinnedCount())
+
+	// Closing a scan releases its pin, and ending the transaction releases the others.
+	require.NoError(t, scans[2].Close())
+	assert.Equal(t, map[file.BlockId]int{first: 1, middle: 1, last: 1}, transaction.PinnedBlocks())
+	require.NoError(t, transaction.Commit())
+	assert.Zero(t, transaction.PinnedCount())
+	assert.Empty(t, transaction.PinnedBlocks())
+}
+
+func TestTableScan_Projected(t *testing.T) {
+	ts, transaction, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	for i := range 3 {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", i))
+		require.NoError(t, ts.SetString("name", fmt.Sprintf("user%d", i)))
+		require.NoError(t, ts.SetBool("active", i%2 == 0))
+		if i == 1 {
+			require.NoError(t, ts.SetNull("count"))
+		} else {
+			require.NoError(t, ts.SetLong("count", int64(i*10)))
+		}
+	}
+
+	projected, err := NewProjectedTableScan(transaction, "test_table", ts.layout, []string{"count", "name"})
+	require.NoError(t, err)
+	defer projected.Close()
+
+	// The scan only has the fields it reads, in the order of the table's schema.
+	assert.Equal(t, []types.FieldInfo{{Name: "name", Type: types.Varchar, Length: 20}, {Name: "count", Type: types.Long}}, projected.Fields())
+	assert.True(t, projected.HasField("count"))
+	assert.False(t, projected.HasField("id"))
+
+	var names []string
+	var counts []any
+	for {
+		hasNext, err := projected.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		name, err := projected.GetString("name")
+		require.NoError(t, err)
+		names = append(names, name)
+		count, err := projected.GetVal("count")
+		require.NoError(t, err)
+		counts = append(counts, count)
+
+		// The fields left out are neither read nor changed.
+		_, err = projected.GetVal("id")
+		assert.ErrorIs(t, err, ErrProjectedScan)
+		_, err = projected.GetBool("active")
+		assert.ErrorIs(t, err, ErrProjectedScan)
+		assert.ErrorIs(t, projected.SetString("name", "x"), ErrProjectedScan)
+	}
+	assert.Equal(t, []string{"user0", "user1", "user2"}, names)
+	assert.Equal(t, []any{int64(0), nil, int64(20)}, counts)
+	assert.ErrorIs(t, projected.Insert(), ErrProjectedScan)
+	assert.ErrorIs(t, projected.Delete(), ErrProjectedScan)
+}
+
+func TestTableScan_InsertReusesFreedSlot(t *testing.T) {
+	ts, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	// Fill the first block, delete its first two records, and move back to its last one.
+	slots := ts.tx.BlockSize() / ts.layout.SlotSize()
+	for id := 0; id < slots; id++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("id", id))
+	}
+	require.NoError(t, ts.BeforeFirst())
+	for range 2 {
+		_, err := ts.Next()
+		require.NoError(t, err)
+		require.NoError(t, ts.Delete())
+	}
+	for id := 2; id < slots; id++ {
+		_, err := ts.Next()
+		require.NoError(t, err)
+	}
+	require.Equal(t, record.NewID(0, slots-1), recordID(t, ts))
+
+	// The record is inserted into the first freed slot rather than into a new block.
+	require.NoError(t, ts.Insert())
+	require.NoError(t, ts.SetInt("id", 100))
+	assert.Equal(t, record.NewID(0, 0), recordID(t, ts))
+	size, err := ts.tx.Size(ts.fileName)
+	require.NoError(t, err)
+	assert.Equal(t, 1, size)
+
+	// The other records stay in their slots.
+	require.NoError(t, ts.BeforeFirst())
+	ids := map[int]int{}
+	for {
+		hasNext, err := ts.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		id, err := ts.GetInt("id")
+		require.NoError(t, err)
+		ids[recordID(t, ts).Slot()] = id
+	}
+	expected := map[int]int{0: 100}
+	for id := 2; id < slots; id++ {
+		expected[id] = id
+	}
+	assert.Equal(t, expected, ids)
+}
+
+// recordID returns the record ID of the current record of the scan.
+func recordID(t *testing.T, ts *Scan) *record.ID {
+	rid, err := ts.GetRecordID()
+	require.NoError(t, err)
+	return rid
+}
@@ -15,6 +15,16 @@ type pinnedBuffer struct {
 type BufferList struct {
 	buffers       map[file.BlockId]*pinnedBuffer
 	bufferManager *buffer.Manager
+	// pins is the number of pins the transaction holds, that is the sum of the reference counts of its buffers.
+	pins int
+	// pinHook is called when the number of pins exceeds its threshold, or nil if none was set.
+	pinHook *pinHook
+}
+
+// pinHook is a function called when the number of pins of a transaction exceeds a threshold.
+type pinHook struct {
+	threshold int
+	fn        func(pins int) error
 }
 
 // NewBufferList creates a new BufferList.
@@ -28,7 +38,7 @@ func NewBufferList(bufferManager *buffer.Manager) *BufferList {
 // GetBuffer returns the buffer pinned to the specified block.
 // The method returns nil if the transaction has not pinned the block.
 func (bl *BufferList) GetBuffer(block *file.BlockId) *buffer.Buffer {
-	pinnedBuf, ok := bl.buffers[*block]
+	pinnedBuf, ok := bl.buffers[block.Key()]
 	if !ok {
 		return nil
 	}
@@ -38,37 +48,84 @@ func (bl *BufferList) GetBuffer(block *file.BlockId) *buffer.Buffer {
 // Pin pins the block. If the block is already pinned by this transaction,
 // simply increment the reference count. Otherwise, pin it via bufferManager.
 func (bl *BufferList) Pin(block *file.BlockId) error {
-	if pinnedBuf, ok := bl.buffers[*block]; ok {
+	return bl.pin(block, bl.bufferManager.Pin)
+}
+
+// PinSequential pins the block like Pin, hinting to the buffer manager that
+// the transaction is scanning the file sequentially.
+func (bl *BufferList) PinSequential(block *file.BlockId) error {
+	return bl.pin(block, bl.bufferManager.PinSequential)
+}
+
+// pin pins the block, using the specified buffer manager method if the transaction has not pinned it yet.
+// If the pin makes the number of pins exceed the threshold of the pin hook, the hook is called, and the pin
+// is undone if the hook returns an error.
+func (bl *BufferList) pin(block *file.BlockId, pinBuffer func(*file.BlockId) (*buffer.Buffer, error)) error {
+	if pinnedBuf, ok := bl.buffers[block.Key()]; ok {
 		// Already pinned by this transaction; just increase refCount
 		pinnedBuf.refCount++
-		return nil
+	} else {
+		// Not pinned yet; ask bufferManager for a fresh pin
+		buff, err := pinBuffer(block)
+		if err != nil {
+			return err
+		}
+		bl.buffers[block.Key()] = &pinnedBuffer{
+			buffer:   buff,
+			refCount: 1,
+		}
 	}
+	bl.pins++
 
-	// Not pinned yet; ask bufferManager for a fresh pin
-	buff, err := bl.bufferManager.Pin(block)
-	if err != nil {
-		return err
-	}
-	bl.buffers[*block] = &pinnedBuffer{
-		buffer:   buff,
-		refCount: 1,
+	if bl.pinHook != nil && bl.pins == bl.pinHook.threshold+1 {
+		if err := bl.pinHook.fn(bl.pins); err != nil {
+			bl.Unpin(block)
+			return err
+		}
 	}
 	return nil
 }
 
+// SetPinHook sets the function called when the number of pins the transaction holds exceeds the specified
+// threshold, with the number of pins. The function is called each time a pin takes the number of pins above
+// the threshold, in the goroutine of the transaction; if it returns an error, the pin is undone and fails
+// with that error. A nil function removes the hook.
+func (bl *BufferList) SetPinHook(threshold int, fn func(pins int) error) {
+	if fn == nil {
+		bl.pinHook = nil
+		return
+	}
+	bl.pinHook = &pinHook{threshold: threshold, fn: fn}
+}
+
+// PinnedCount returns the number of pins the transaction holds, counting a block pinned several times once per pin.
+func (bl *BufferList) PinnedCount() int {
+	return bl.pins
+}
+
+// PinnedBlocks returns the number of pins the transaction holds on each block it pinned.
+func (bl *BufferList) PinnedBlocks() map[file.BlockId]int {
+	blocks := make(map[file.BlockId]int, len(bl.buffers))
+	for block, pinnedBuf := range bl.buffers {
+		blocks[block] = pinnedBuf.refCount
+	}
+	return blocks
+}
+
 // Unpin decrements the refCount. Only call bufferManager.Unpin when the last pin is released.
 func (bl *BufferList) Unpin(block *file.BlockId) {
-	pinnedBuf, ok := bl.buffers[*block]
+	pinnedBuf, ok := bl.buffers[block.Key()]
 	if !ok {
 		// This block isn't pinned or was already unpinned.
 		// In production, you might log a warning or return silently.
 		return
 	}
 	pinnedBuf.refCount--
+	bl.pins--
 	if pinnedBuf.refCount <= 0 {
 		// Now fully unpin from buffer manager and remove from our map
 		bl.bufferManager.Unpin(pinnedBuf.buffer)
-		delete(bl.buffers, *block)
+		delete(bl.buffers, block.Key())
 	}
 }
 
@@ -89,4 +146,5 @@ func (bl *BufferList) UnpinAll() {
 	}
 	// Clear our map
 	bl.buffers = make(map[file.BlockId]*pinnedBuffer)
+	bl.pins = 0
 }
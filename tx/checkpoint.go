@@ -3,6 +3,7 @@ package tx
 import (
 	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 type CheckpointRecord struct {
@@ -30,6 +31,11 @@ func (r *CheckpointRecord) Undo(_ *Transaction) error {
 	return nil
 }
 
+// Redo does nothing. CheckpointRecord does not change any data.
+func (r *CheckpointRecord) Redo(_ *Transaction) error {
+	return nil
+}
+
 // String returns a string representation of the log record.
 func (r *CheckpointRecord) String() string {
 	return "<CHECKPOINT>"
@@ -39,7 +45,7 @@ func (r *CheckpointRecord) String() string {
 // nothing else.
 // The method returns the LSN of the new log record.
 func WriteCheckpointToLog(logManager *log.Manager) (int, error) {
-	record := make([]byte, 4)
+	record := make([]byte, types.IntSize)
 
 	page := file.NewPageFromBytes(record)
 	page.SetInt(0, int(Checkpoint))
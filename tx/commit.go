@@ -36,13 +36,18 @@ func (r *CommitRecord) Undo(_ *Transaction) error {
 	return nil
 }
 
+// Redo does nothing. CommitRecord does not change any data.
+func (r *CommitRecord) Redo(_ *Transaction) error {
+	return nil
+}
+
 // String returns a string representation of the log record.
 func (r *CommitRecord) String() string {
 	return fmt.Sprintf("<COMMIT %d>", r.txNum)
 }
 
 // WriteCommitToLog writes a commit record to the log. This log record contains the Commit operator,
-// followed by the transaction id.
+// followed by the transaction id. The transaction is no longer active.
 // The method returns the LSN of the new log record.
 func WriteCommitToLog(logManager *log.Manager, txNum int) (int, error) {
 	record := make([]byte, 2*types.IntSize)
@@ -51,5 +56,5 @@ func WriteCommitToLog(logManager *log.Manager, txNum int) (int, error) {
 	page.SetInt(0, int(Commit))
 	page.SetInt(types.IntSize, txNum)
 
-	return logManager.Append(record)
+	return logManager.AppendEnd(txNum, record)
 }
@@ -0,0 +1,58 @@
+package tx_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+)
+
+// BenchmarkConcurrentCommits measures the throughput of transactions committing concurrently, each updating
+// a block of its own goroutine, with a log manager flushing every commit immediately, and with one grouping them.
+func BenchmarkConcurrentCommits(b *testing.B) {
+	for _, bench := range []struct {
+		name        string
+		groupCommit log.GroupCommit
+	}{
+		{name: "Immediate"},
+		{name: "GroupCommit", groupCommit: log.GroupCommit{Window: time.Millisecond, MaxBatch: 8}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			fm, err := file.NewManager(b.TempDir(), 400)
+			require.NoError(b, err)
+			lm, err := log.NewManagerWithGroupCommit(fm, "logfile", bench.groupCommit)
+			require.NoError(b, err)
+			bm := buffer.NewManager(fm, lm, 64)
+			lt := concurrency.NewLockTable()
+
+			var nextBlock atomic.Int64
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				block := file.NewBlockId("data", int(nextBlock.Add(1)))
+				for i := 0; pb.Next(); i++ {
+					transaction := tx.NewTransaction(fm, lm, bm, lt)
+					if err := transaction.Pin(block); err != nil {
+						b.Error(err)
+						return
+					}
+					if err := transaction.SetInt(block, 0, i, true); err != nil {
+						b.Error(err)
+						return
+					}
+					if err := transaction.Commit(); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
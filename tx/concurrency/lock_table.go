@@ -11,6 +11,15 @@ import (
 
 const maxWaitTime = 10 * time.Second
 
+// ErrDeadlock is returned when granting a lock would make its transaction wait for a transaction that,
+// directly or through others, is waiting for it. The transaction must be rolled back, which releases
+// its locks so that the others can proceed; it can then be retried.
+var ErrDeadlock = errors.New("deadlock detected")
+
+// ErrLockTimeout is returned when a transaction waited too long (10 seconds for now) for a lock.
+// Like ErrDeadlock, the transaction must be rolled back, and can then be retried.
+var ErrLockTimeout = errors.New("lock wait timeout exceeded")
+
 // LockTable provides methods to lock and Unlock blocks.
 // If a transaction requests a lock that causes a conflict with an existing lock,
 // then that transaction is placed on a wait list.
@@ -19,28 +28,46 @@ const maxWaitTime = 10 * time.Second
 // then all transactions are removed from the wait list and rescheduled.
 // If one of those transactions discovers that the lock it is waiting for is still locked,
 // it will place itself back on the wait list.
+//
+// The lock table records which transactions hold the locks of each block, and which lock each waiting
+// transaction requested. Before a transaction waits, the lock table follows the transactions it would
+// wait for, and the ones these are waiting for in turn: if it comes back to the transaction, waiting
+// would never end, so the request fails with ErrDeadlock instead.
 type LockTable struct {
 	locks map[file.BlockId]int
-	mu    sync.Mutex
-	cond  *sync.Cond
+	// holders are the numbers of the transactions holding a lock on each block.
+	holders map[file.BlockId]map[int]struct{}
+	// waiting is the lock request each waiting transaction is waiting to be granted, by transaction number.
+	waiting map[int]lockRequest
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+// lockRequest is a lock requested by a transaction.
+type lockRequest struct {
+	block     file.BlockId
+	exclusive bool
 }
 
 // NewLockTable creates a new LockTable.
 func NewLockTable() *LockTable {
 	lt := &LockTable{
-		locks: make(map[file.BlockId]int),
+		locks:   make(map[file.BlockId]int),
+		holders: make(map[file.BlockId]map[int]struct{}),
+		waiting: make(map[int]lockRequest),
 	}
 	lt.cond = sync.NewCond(&lt.mu)
 	return lt
 }
 
-// SLock grants a shared lock on the specified block.
+// SLock grants a shared lock on the specified block to the specified transaction.
 // If an XLock exists when the method is called,
 // then the calling thread will be placed on a wait list
 // until the lock is released.
+// If waiting would deadlock, the method returns an error wrapping ErrDeadlock.
 // If the thread remains on the wait list for too long (10 seconds for now),
-// then the method will return an error.
-func (lt *LockTable) SLock(block *file.BlockId) error {
+// then the method will return an error wrapping ErrLockTimeout.
+func (lt *LockTable) SLock(txNum int, block *file.BlockId) error {
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
@@ -55,36 +82,59 @@ func (lt *LockTable) SLock(block *file.BlockId) error {
 	})
 
 	defer stop()
+	defer delete(lt.waiting, txNum)
 
+	request := lockRequest{block: block.Key()}
 	for {
 		// If there's no exclusive lock, we can proceed
 		if !lt.hasXLock(block) {
 			// Get the number of shared locks.
 			val := lt.getLockVal(block)
 			// Grant the shared lock.
-			lt.locks[*block] = val + 1
+			lt.locks[block.Key()] = val + 1
+			lt.addHolder(txNum, block)
 			return nil
 		}
 
+		if lt.wouldDeadlock(txNum, request) {
+			return fmt.Errorf("lock abort exception: could not acquire shared lock on block %v for transaction %d: %w", block, txNum, ErrDeadlock)
+		}
+		lt.waiting[txNum] = request
+
 		// Wait until notified or context is done.
 		lt.cond.Wait()
 
 		if ctx.Err() != nil {
 			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-				return fmt.Errorf("lock abort exception: could not acquire shared lock on block %v: %v", block, ctx.Err())
+				return fmt.Errorf("lock abort exception: could not acquire shared lock on block %v for transaction %d: %w", block, txNum, ErrLockTimeout)
 			}
 			return ctx.Err()
 		}
 	}
 }
 
-// XLock grants an exclusive lock on the specified block.
-// Assumes that the calling thread already has a shared lock on the block.
+// XLock grants an exclusive lock on the specified block to the specified transaction.
+// Assumes that the transaction already has a shared lock on the block.
 // If a lock of any type (by some other transaction) exists when the method is called,
 // then the calling thread will be placed on a wait list until the locks are released.
+// If waiting would deadlock, the method returns an error wrapping ErrDeadlock.
 // If the thread remains on the wait list for too long (10 seconds for now),
-// then the method will return an error.
-func (lt *LockTable) XLock(block *file.BlockId) error {
+// then the method will return an error wrapping ErrLockTimeout.
+func (lt *LockTable) XLock(txNum int, block *file.BlockId) error {
+	return lt.xLock(txNum, block, 1)
+}
+
+// XLockDirectly grants an exclusive lock on the specified block to the specified transaction, which holds no lock on it.
+// Unlike XLock, it does not go through a shared lock: it waits until there is no lock at all on the block.
+// Two transactions requesting it for the same block thus wait for each other in turn, instead of both
+// obtaining a shared lock and then waiting for the other's to be released to upgrade it.
+func (lt *LockTable) XLockDirectly(txNum int, block *file.BlockId) error {
+	return lt.xLock(txNum, block, 0)
+}
+
+// xLock grants an exclusive lock on the specified block once the only locks left on it
+// are the specified number of shared locks, which are held by the specified transaction.
+func (lt *LockTable) xLock(txNum int, block *file.BlockId, ownSharedLocks int) error {
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
@@ -98,39 +148,53 @@ func (lt *LockTable) XLock(block *file.BlockId) error {
 	})
 
 	defer stop()
+	defer delete(lt.waiting, txNum)
 
+	request := lockRequest{block: block.Key(), exclusive: true}
 	for {
-		// Assume that the calling thread already has a shared lock. If any other shared locks exist, we can't proceed.
-		if !lt.hasOtherSLocks(block) {
-			lt.locks[*block] = -1
+		// If any other lock exists, we can't proceed.
+		if val := lt.getLockVal(block); val >= 0 && val <= ownSharedLocks {
+			lt.locks[block.Key()] = -1
+			lt.addHolder(txNum, block)
 			return nil
 		}
 
+		if lt.wouldDeadlock(txNum, request) {
+			return fmt.Errorf("lock abort exception: could not acquire exclusive lock on block %v for transaction %d: %w", block, txNum, ErrDeadlock)
+		}
+		lt.waiting[txNum] = request
+
 		lt.cond.Wait()
 
 		if ctx.Err() != nil {
 			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-				return fmt.Errorf("lock abort exception: could not acquire exclusive lock on block %v: %v", block, ctx.Err())
+				return fmt.Errorf("lock abort exception: could not acquire exclusive lock on block %v for transaction %d: %w", block, txNum, ErrLockTimeout)
 			}
 			return ctx.Err()
 		}
 	}
 }
 
-// Unlock releases the lock on the specified block.
-// If this lock is the last lock on that block,
-// then the waiting transactions are notified.
-func (lt *LockTable) Unlock(block *file.BlockId) {
+// Unlock releases the lock of the specified transaction on the specified block, and notifies the waiting transactions:
+// besides the transactions waiting for the last lock on the block to be released,
+// a transaction waiting to upgrade its shared lock can proceed once it holds the only remaining one.
+func (lt *LockTable) Unlock(txNum int, block *file.BlockId) {
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
 	val := lt.getLockVal(block)
 	if val > 1 {
-		lt.locks[*block] = val - 1
+		lt.locks[block.Key()] = val - 1
 	} else {
-		delete(lt.locks, *block)
-		lt.cond.Broadcast()
+		delete(lt.locks, block.Key())
+	}
+	if holders := lt.holders[block.Key()]; holders != nil {
+		delete(holders, txNum)
+		if len(holders) == 0 {
+			delete(lt.holders, block.Key())
+		}
 	}
+	lt.cond.Broadcast()
 }
 
 // hasXLock returns true if there is an exclusive lock on the block.
@@ -138,11 +202,53 @@ func (lt *LockTable) hasXLock(block *file.BlockId) bool {
 	return lt.getLockVal(block) < 0
 }
 
-// hasOtherSLocks returns true if there is more than one shared locks on the block.
-func (lt *LockTable) hasOtherSLocks(block *file.BlockId) bool {
-	return lt.getLockVal(block) > 1
+func (lt *LockTable) getLockVal(block *file.BlockId) int {
+	return lt.locks[block.Key()]
 }
 
-func (lt *LockTable) getLockVal(block *file.BlockId) int {
-	return lt.locks[*block]
+func (lt *LockTable) addHolder(txNum int, block *file.BlockId) {
+	holders := lt.holders[block.Key()]
+	if holders == nil {
+		holders = make(map[int]struct{})
+		lt.holders[block.Key()] = holders
+	}
+	holders[txNum] = struct{}{}
+}
+
+// blockers returns the numbers of the transactions that the specified request of the specified transaction waits for:
+// the other holders of the block for an exclusive lock, or the holder of its exclusive lock for a shared lock.
+func (lt *LockTable) blockers(txNum int, request lockRequest) []int {
+	if !request.exclusive && !lt.hasXLock(&request.block) {
+		return nil
+	}
+	var blockers []int
+	for holder := range lt.holders[request.block] {
+		if holder != txNum {
+			blockers = append(blockers, holder)
+		}
+	}
+	return blockers
+}
+
+// wouldDeadlock returns true if the specified transaction waiting for the specified request would close
+// a cycle of transactions waiting for each other, that is, if one of the transactions it would wait for
+// is itself waiting, directly or through other transactions, for the specified transaction.
+func (lt *LockTable) wouldDeadlock(txNum int, request lockRequest) bool {
+	visited := make(map[int]bool)
+	pending := lt.blockers(txNum, request)
+	for len(pending) > 0 {
+		blocker := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if blocker == txNum {
+			return true
+		}
+		if visited[blocker] {
+			continue
+		}
+		visited[blocker] = true
+		if waitingFor, ok := lt.waiting[blocker]; ok {
+			pending = append(pending, lt.blockers(blocker, waitingFor)...)
+		}
+	}
+	return false
 }
@@ -6,53 +6,71 @@ import (
 
 type Manager struct {
 	lockTable *LockTable // pointer to the global lock table.
+	txNum     int        // the number of the transaction the locks are held by.
 	locks     map[file.BlockId]string
 }
 
-// NewManager creates a new Manager.
-func NewManager(lockTable *LockTable) *Manager {
-	return &Manager{lockTable: lockTable, locks: make(map[file.BlockId]string)}
+// NewManager creates a new Manager for the locks of the specified transaction.
+func NewManager(lockTable *LockTable, txNum int) *Manager {
+	return &Manager{lockTable: lockTable, txNum: txNum, locks: make(map[file.BlockId]string)}
 }
 
 // SLock obtains a shared lock on the block, if necessary.
 // The method will ask the lock table for an SLock if the transaction currently has no locks on the block.
 func (m *Manager) SLock(block *file.BlockId) error {
 	// if the lock doesn't exist in the locks map, acquire it from the lock table.
-	if _, ok := m.locks[*block]; !ok {
-		if err := m.lockTable.SLock(block); err != nil {
+	if _, ok := m.locks[block.Key()]; !ok {
+		if err := m.lockTable.SLock(m.txNum, block); err != nil {
 			return err
 		}
-		m.locks[*block] = "s"
+		m.locks[block.Key()] = "s"
 	}
 	return nil
 }
 
 // XLock obtains an exclusive lock on the block, if necessary.
-// If the transaction does not have an exclusive lock on the block,
-// the method first gets a shared lock on that block (if necessary), and then upgrades it to an exclusive lock.
+// If the transaction has a shared lock on the block, the method upgrades it to an exclusive lock.
+// If it has no lock on the block, the exclusive lock is obtained directly, so that transactions
+// requesting an exclusive lock on the same block are granted it in turn (see LockTable#XLockDirectly).
 func (m *Manager) XLock(block *file.BlockId) error {
-	if !m.hasXLock(block) {
-		if err := m.SLock(block); err != nil {
-			return err
-		}
-		if err := m.lockTable.XLock(block); err != nil {
+	if m.hasXLock(block) {
+		return nil
+	}
+	if m.HasLock(block) {
+		if err := m.lockTable.XLock(m.txNum, block); err != nil {
 			return err
 		}
-		m.locks[*block] = "x"
+	} else if err := m.lockTable.XLockDirectly(m.txNum, block); err != nil {
+		return err
 	}
+	m.locks[block.Key()] = "x"
 	return nil
 }
 
+// Unlock releases the transaction's lock on the block, if it has one, before the transaction completes.
+func (m *Manager) Unlock(block *file.BlockId) {
+	if _, ok := m.locks[block.Key()]; ok {
+		m.lockTable.Unlock(m.txNum, block)
+		delete(m.locks, block.Key())
+	}
+}
+
+// HasLock returns true if the transaction has a lock of any type on the block.
+func (m *Manager) HasLock(block *file.BlockId) bool {
+	_, ok := m.locks[block.Key()]
+	return ok
+}
+
 // Release releases all the locks by asking the lock table to Unlock each one.
 func (m *Manager) Release() {
 	for block := range m.locks {
-		m.lockTable.Unlock(&block)
+		m.lockTable.Unlock(m.txNum, &block)
 	}
 	m.locks = make(map[file.BlockId]string)
 }
 
 // hasXLock returns true if the transaction has an exclusive lock on the block.
 func (m *Manager) hasXLock(block *file.BlockId) bool {
-	lock, ok := m.locks[*block]
+	lock, ok := m.locks[block.Key()]
 	return ok && lock == "x"
 }
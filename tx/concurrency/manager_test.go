@@ -0,0 +1,109 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/file"
+)
+
+// lockAsync requests the lock with lock in a goroutine, returning the channel receiving its result.
+func lockAsync(lock func(block *file.BlockId) error, block *file.BlockId) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- lock(block) }()
+	return done
+}
+
+// assertWaiting asserts that the lock requested in a goroutine has not been granted yet.
+func assertWaiting(t *testing.T, done <-chan error) {
+	select {
+	case err := <-done:
+		t.Fatalf("lock granted while another transaction holds a conflicting lock: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// assertGranted asserts that the lock requested in a goroutine is granted.
+func assertGranted(t *testing.T, done <-chan error) {
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("lock not granted after the conflicting lock was released")
+	}
+}
+
+func TestManager_XLockWithoutLockWaitsForOtherLocks(t *testing.T) {
+	lockTable := NewLockTable()
+	a, b := NewManager(lockTable, 1), NewManager(lockTable, 2)
+	block := file.NewBlockId("testfile", 1)
+
+	// An exclusive lock requested without a lock on the block waits for the shared locks of the others.
+	require.NoError(t, a.SLock(block))
+	done := lockAsync(b.XLock, block)
+	assertWaiting(t, done)
+	a.Release()
+	assertGranted(t, done)
+	assert.True(t, b.hasXLock(block))
+
+	// And for their exclusive locks.
+	done = lockAsync(a.XLock, block)
+	assertWaiting(t, done)
+	b.Release()
+	assertGranted(t, done)
+	a.Release()
+	assert.Zero(t, lockTable.getLockVal(block))
+}
+
+func TestManager_XLockUpgradesSharedLock(t *testing.T) {
+	lockTable := NewLockTable()
+	a, b := NewManager(lockTable, 1), NewManager(lockTable, 2)
+	block := file.NewBlockId("testfile", 1)
+
+	require.NoError(t, a.SLock(block))
+	require.NoError(t, b.SLock(block))
+
+	// The shared lock of a transaction is upgraded once the other transactions release theirs.
+	done := lockAsync(a.XLock, block)
+	assertWaiting(t, done)
+	b.Release()
+	assertGranted(t, done)
+	assert.Equal(t, -1, lockTable.getLockVal(block))
+
+	// Locking the block again is a no-op.
+	require.NoError(t, a.XLock(block))
+	require.NoError(t, a.SLock(block))
+	a.Release()
+	assert.Zero(t, lockTable.getLockVal(block))
+}
+
+func TestManager_XLocksWithoutLockAreGrantedInTurn(t *testing.T) {
+	lockTable := NewLockTable()
+	block := file.NewBlockId("testfile", 1)
+
+	// Transactions requesting an exclusive lock without holding a shared lock do not hold shared locks while
+	// they wait, so that none of them waits to upgrade a shared lock that another one waits on as well.
+	const numManagers = 4
+	done := make(chan *Manager, numManagers)
+	for i := 0; i < numManagers; i++ {
+		go func() {
+			m := NewManager(lockTable, i+1)
+			if assert.NoError(t, m.XLock(block)) {
+				done <- m
+			}
+		}()
+	}
+	for i := 0; i < numManagers; i++ {
+		select {
+		case m := <-done:
+			assert.Equal(t, -1, lockTable.getLockVal(block))
+			m.Release()
+		case <-time.After(5 * time.Second):
+			t.Fatal("exclusive locks not granted in turn")
+		}
+	}
+	assert.Zero(t, lockTable.getLockVal(block))
+}
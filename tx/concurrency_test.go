@@ -1,11 +1,11 @@
 package tx_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
 	"math/rand/v2"
 	"os"
-	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -165,7 +165,7 @@ func transactionB(fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *con
 
 	err = txB.SetInt(blk2, 0, 0, false)
 	if err != nil {
-		if strings.Contains(err.Error(), "lock abort") {
+		if errors.Is(err, concurrency.ErrDeadlock) {
 			_ = txB.Rollback()
 			result.Error = err
 			result.Aborted = true
@@ -177,7 +177,7 @@ func transactionB(fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *con
 	time.Sleep(1 * time.Second)
 	_, err = txB.GetInt(blk1, 0)
 	if err != nil {
-		if strings.Contains(err.Error(), "lock abort") {
+		if errors.Is(err, concurrency.ErrDeadlock) {
 			_ = txB.Rollback()
 			result.Error = err
 			result.Aborted = true
@@ -219,7 +219,7 @@ func transactionC(fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *con
 	time.Sleep(500 * time.Millisecond)
 	err = txC.SetInt(blk1, 0, 0, false)
 	if err != nil {
-		if strings.Contains(err.Error(), "lock abort") {
+		if errors.Is(err, concurrency.ErrDeadlock) {
 			_ = txC.Rollback()
 			result.Error = err
 			result.Aborted = true
@@ -231,7 +231,7 @@ func transactionC(fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *con
 	time.Sleep(1 * time.Second)
 	_, err = txC.GetInt(blk2, 0)
 	if err != nil {
-		if strings.Contains(err.Error(), "lock abort") {
+		if errors.Is(err, concurrency.ErrDeadlock) {
 			_ = txC.Rollback()
 			result.Error = err
 			result.Aborted = true
@@ -303,12 +303,20 @@ func TestDeadlockDetection(t *testing.T) {
 	assert.NotNil(t, resultA, "Transaction A result missing")
 	assert.NotNil(t, resultB, "Transaction B result missing")
 
+	aborted, committed := 0, 0
 	for _, result := range []*TransactionResult{resultA, resultB} {
-		assert.True(t, result.Aborted, "Transaction should have aborted")
-		assert.Error(t, result.Error, "Aborted transaction should have error")
-		assert.Contains(t, result.Error.Error(), "lock abort exception", "Aborted transaction should have lock abort error")
+		if result.Aborted {
+			aborted++
+			assert.ErrorIs(t, result.Error, concurrency.ErrDeadlock, "Aborted transaction should have deadlock error")
+			assert.Contains(t, result.Error.Error(), "lock abort exception", "Aborted transaction should have lock abort error")
+		}
+		if result.Committed {
+			committed++
+			assert.NoError(t, result.Error, "Committed transaction should not have error")
+		}
 	}
-
+	assert.Equal(t, 1, aborted, "Exactly one transaction should have aborted")
+	assert.Equal(t, 1, committed, "Exactly one transaction should have committed")
 }
 
 // transactionDeadlockA tries to write to block 1 and then block 2
@@ -345,7 +353,7 @@ func transactionDeadlockA(fm *file.Manager, lm *log.Manager, bm *buffer.Manager,
 	// Transaction A: Attempt XLock on block 2 (this will cause deadlock)
 	err = txA.SetInt(blk2, 0, 1, false)
 	if err != nil {
-		if strings.Contains(err.Error(), "lock abort") {
+		if errors.Is(err, concurrency.ErrDeadlock) {
 			_ = txA.Rollback()
 			result.Error = err
 			result.Aborted = true
@@ -398,7 +406,7 @@ func transactionDeadlockB(fm *file.Manager, lm *log.Manager, bm *buffer.Manager,
 	// Transaction B: Attempt XLock on block 1 (this will cause deadlock)
 	err = txB.SetInt(blk1, 0, 2, false)
 	if err != nil {
-		if strings.Contains(err.Error(), "lock abort") {
+		if errors.Is(err, concurrency.ErrDeadlock) {
 			_ = txB.Rollback()
 			result.Error = err
 			result.Aborted = true
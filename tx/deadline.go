@@ -0,0 +1,37 @@
+package tx
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrQueryTimeout is returned when a statement runs past the deadline set on its transaction.
+var ErrQueryTimeout = errors.New("query timeout exceeded")
+
+// SetDeadline sets the time by which the statement running in the transaction must be done.
+// Past the deadline, pinning a block fails with ErrQueryTimeout, as does CheckDeadline, which
+// the scans reading the statement's results call on each record. The deadline applies until it
+// is cleared or the transaction ends; a zero time clears it.
+func (tx *Transaction) SetDeadline(deadline time.Time) {
+	tx.deadline = deadline
+}
+
+// ClearDeadline removes the deadline of the transaction, once the statement it was set for is done.
+func (tx *Transaction) ClearDeadline() {
+	tx.deadline = time.Time{}
+}
+
+// Deadline returns the deadline of the transaction, and whether it has one.
+func (tx *Transaction) Deadline() (time.Time, bool) {
+	return tx.deadline, !tx.deadline.IsZero()
+}
+
+// CheckDeadline returns ErrQueryTimeout if the deadline of the transaction has passed.
+// It is called periodically while a statement runs, so that a statement running too long
+// is aborted even if its caller never gives up on it.
+func (tx *Transaction) CheckDeadline() error {
+	if !tx.deadline.IsZero() && time.Now().After(tx.deadline) {
+		return ErrQueryTimeout
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package tx
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// transactionEnd is a way a transaction can end, which lifecycle callbacks are registered for.
+type transactionEnd int
+
+const (
+	endCommit transactionEnd = 1 << iota
+	endRollback
+)
+
+// lifecycleCallback is a function to call when the transaction ends in one of the specified ways.
+type lifecycleCallback struct {
+	ends transactionEnd
+	fn   func()
+}
+
+// lifecycleCallbacks holds the callbacks registered on a transaction, in registration order.
+type lifecycleCallbacks struct {
+	mu           sync.Mutex
+	callbacks    []lifecycleCallback
+	beforeCommit []func() error
+}
+
+// BeforeCommit registers a function to call when the transaction is about to commit, before its commit record is
+// written, such as to write a change kept in memory until then. The functions are called in registration order.
+// If one returns an error, the transaction does not commit, and Commit returns the error, so the transaction
+// should be rolled back. The functions are forgotten when the transaction rolls back, without being called.
+func (tx *Transaction) BeforeCommit(fn func() error) {
+	tx.callbacks.mu.Lock()
+	defer tx.callbacks.mu.Unlock()
+	tx.callbacks.beforeCommit = append(tx.callbacks.beforeCommit, fn)
+}
+
+// OnCommit registers a function to call when the transaction commits, such as to release
+// a resource kept for the transaction. Callbacks are called once the commit record is flushed,
+// before the locks of the transaction are released, in registration order.
+// A callback that panics does not keep the others from being called: the panic is recovered and reported.
+func (tx *Transaction) OnCommit(fn func()) {
+	tx.callbacks.register(endCommit, fn)
+}
+
+// OnRollback registers a function to call when the transaction rolls back, once its changes are undone,
+// before its locks are released. Callbacks are called like those registered with OnCommit.
+func (tx *Transaction) OnRollback(fn func()) {
+	tx.callbacks.register(endRollback, fn)
+}
+
+// OnEnd registers a function to call when the transaction either commits or rolls back.
+// Callbacks are called like those registered with OnCommit.
+func (tx *Transaction) OnEnd(fn func()) {
+	tx.callbacks.register(endCommit|endRollback, fn)
+}
+
+// register adds a callback for the specified ways the transaction can end.
+func (lc *lifecycleCallbacks) register(ends transactionEnd, fn func()) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.callbacks = append(lc.callbacks, lifecycleCallback{ends: ends, fn: fn})
+}
+
+// runBeforeCommit calls the functions registered with BeforeCommit, including those they register in turn,
+// and forgets them, stopping at the first error.
+func (lc *lifecycleCallbacks) runBeforeCommit() error {
+	for {
+		lc.mu.Lock()
+		fns := lc.beforeCommit
+		lc.beforeCommit = nil
+		lc.mu.Unlock()
+		if len(fns) == 0 {
+			return nil
+		}
+		for _, fn := range fns {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// run calls the callbacks registered for the way the transaction ended, and forgets every callback,
+// so that each is called at most once. The panics of the callbacks are reported on standard error.
+func (lc *lifecycleCallbacks) run(txNum int, end transactionEnd) {
+	lc.mu.Lock()
+	callbacks := lc.callbacks
+	lc.callbacks, lc.beforeCommit = nil, nil
+	lc.mu.Unlock()
+
+	for _, callback := range callbacks {
+		if callback.ends&end == 0 {
+			continue
+		}
+		if err := callOnEnd(callback.fn); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Transaction %d: %v\n", txNum, err)
+		}
+	}
+}
+
+// callOnEnd calls a lifecycle callback, returning its panic as an error.
+func callOnEnd(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("lifecycle callback panicked: %v", r)
+		}
+	}()
+	fn()
+	return nil
+}
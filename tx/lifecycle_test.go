@@ -0,0 +1,152 @@
+package tx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+)
+
+func setupLifecycleTest(t *testing.T) (*file.Manager, *log.Manager, *buffer.Manager, *concurrency.LockTable) {
+	fm, err := file.NewManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	return fm, lm, buffer.NewManager(fm, lm, 8), concurrency.NewLockTable()
+}
+
+// registerCallbacks registers a callback of each kind on the transaction, recording their calls.
+func registerCallbacks(transaction *tx.Transaction, calls *[]string) {
+	transaction.OnCommit(func() { *calls = append(*calls, "commit 1") })
+	transaction.OnEnd(func() { *calls = append(*calls, "end") })
+	transaction.OnRollback(func() { *calls = append(*calls, "rollback") })
+	transaction.OnCommit(func() { *calls = append(*calls, "commit 2") })
+}
+
+func TestTransaction_LifecycleCallbacks(t *testing.T) {
+	fm, lm, bm, lt := setupLifecycleTest(t)
+
+	var calls []string
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	registerCallbacks(txn, &calls)
+	assert.Empty(t, calls, "no callback should be called before the transaction ends")
+	require.NoError(t, txn.Commit())
+	assert.Equal(t, []string{"commit 1", "end", "commit 2"}, calls)
+
+	// The callbacks are forgotten once called.
+	require.NoError(t, txn.Rollback())
+	assert.Equal(t, []string{"commit 1", "end", "commit 2"}, calls)
+
+	calls = nil
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	registerCallbacks(txn, &calls)
+	require.NoError(t, txn.Rollback())
+	assert.Equal(t, []string{"end", "rollback"}, calls)
+
+	// Read-only transactions call their callbacks too.
+	calls = nil
+	txn = tx.NewReadOnlyTransaction(fm, lm, bm, lt)
+	registerCallbacks(txn, &calls)
+	require.NoError(t, txn.Commit())
+	assert.Equal(t, []string{"commit 1", "end", "commit 2"}, calls)
+}
+
+func TestTransaction_PanickingCallback(t *testing.T) {
+	fm, lm, bm, lt := setupLifecycleTest(t)
+
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	block, err := txn.Append("data")
+	require.NoError(t, err)
+	require.NoError(t, txn.Pin(block))
+	require.NoError(t, txn.SetInt(block, 0, 42, true))
+
+	var calls []string
+	txn.OnCommit(func() { calls = append(calls, "before") })
+	txn.OnEnd(func() { panic("cleanup failed") })
+	txn.OnCommit(func() { calls = append(calls, "after") })
+	require.NoError(t, txn.Commit())
+	assert.Equal(t, []string{"before", "after"}, calls)
+
+	// The buffers and the locks of the transaction are released.
+	assert.Equal(t, 8, bm.Available())
+	done := make(chan error, 1)
+	go func() {
+		other := tx.NewTransaction(fm, lm, bm, lt)
+		if err := other.Pin(block); err != nil {
+			done <- err
+			return
+		}
+		if err := other.SetInt(block, 0, 43, true); err != nil {
+			done <- err
+			return
+		}
+		done <- other.Commit()
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock of the committed transaction was not released")
+	}
+}
+
+func TestTransaction_BeforeCommit(t *testing.T) {
+	fm, lm, bm, lt := setupLifecycleTest(t)
+
+	// The functions write their changes before the commit, in registration order.
+	txn := tx.NewTransaction(fm, lm, bm, lt)
+	block, err := txn.Append("data")
+	require.NoError(t, err)
+	require.NoError(t, txn.Pin(block))
+	var calls []string
+	txn.OnCommit(func() { calls = append(calls, "commit") })
+	txn.BeforeCommit(func() error {
+		calls = append(calls, "before 1")
+		txn.BeforeCommit(func() error {
+			calls = append(calls, "registered by before 1")
+			return nil
+		})
+		return txn.SetInt(block, 0, 42, true)
+	})
+	txn.BeforeCommit(func() error {
+		calls = append(calls, "before 2")
+		return nil
+	})
+	require.NoError(t, txn.Commit())
+	assert.Equal(t, []string{"before 1", "before 2", "registered by before 1", "commit"}, calls)
+
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, txn.Pin(block))
+	val, err := txn.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, val)
+
+	// A failing function keeps the transaction from committing, and rolling it back forgets the others.
+	calls = nil
+	txn.BeforeCommit(func() error { return assert.AnError })
+	txn.BeforeCommit(func() error {
+		calls = append(calls, "before")
+		return nil
+	})
+	txn.OnCommit(func() { calls = append(calls, "commit") })
+	assert.ErrorIs(t, txn.Commit(), assert.AnError)
+	require.NoError(t, txn.Rollback())
+	assert.Empty(t, calls)
+
+	calls = nil
+	txn = tx.NewTransaction(fm, lm, bm, lt)
+	txn.BeforeCommit(func() error {
+		calls = append(calls, "before")
+		return nil
+	})
+	require.NoError(t, txn.Rollback())
+	require.NoError(t, txn.Commit())
+	assert.Empty(t, calls)
+}
@@ -0,0 +1,166 @@
+package tx
+
+import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"sync"
+)
+
+// LogApplier replays the log records shipped by a primary database into the files of a follower.
+// The update records of each transaction are held back until the transaction's commit record arrives,
+// and are then redone in a single follower transaction, so the follower never sees uncommitted changes.
+// The records of transactions that roll back are discarded, as are those of transactions that were
+// still running when the primary wrote a checkpoint, since recovery on the primary undid them.
+//
+// The applier stores the LSN up to which every record has been applied or discarded in a state file,
+// so that a restarted follower can resume from it. Redoing a record writes an absolute value, so
+// records that are applied a second time after a restart leave the same result.
+type LogApplier struct {
+	fileManager   *file.Manager
+	logManager    *log.Manager
+	bufferManager *buffer.Manager
+	lockTable     *concurrency.LockTable
+	stateBlock    *file.BlockId
+	statePage     *file.Page
+	appliedLSN    int
+	pending       map[int][]LogRecord
+	firstLSN      map[int]int
+	mu            sync.Mutex
+}
+
+// NewLogApplier creates a LogApplier that writes into the files of the specified managers,
+// and reads the last applied LSN from the specified state file, if it exists.
+func NewLogApplier(fileManager *file.Manager, logManager *log.Manager, bufferManager *buffer.Manager, lockTable *concurrency.LockTable, stateFile string) (*LogApplier, error) {
+	la := &LogApplier{
+		fileManager:   fileManager,
+		logManager:    logManager,
+		bufferManager: bufferManager,
+		lockTable:     lockTable,
+		stateBlock:    file.NewBlockId(stateFile, 0),
+		statePage:     file.NewPage(fileManager.BlockSize()),
+		pending:       make(map[int][]LogRecord),
+		firstLSN:      make(map[int]int),
+	}
+
+	size, err := fileManager.Length(stateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state file length: %w", err)
+	}
+	if size > 0 {
+		if err := fileManager.Read(la.stateBlock, la.statePage); err != nil {
+			return nil, fmt.Errorf("failed to read replication state: %w", err)
+		}
+		la.appliedLSN = la.statePage.GetInt(0)
+	}
+	return la, nil
+}
+
+// AppliedLSN returns the LSN up to which every shipped record has been applied or discarded.
+func (la *LogApplier) AppliedLSN() int {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+	return la.appliedLSN
+}
+
+// Apply consumes the shipped log record with the specified LSN.
+// Records must be applied in LSN order; records at or below the applied LSN are ignored.
+func (la *LogApplier) Apply(lsn int, record []byte) error {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+
+	if lsn <= la.appliedLSN {
+		return nil
+	}
+
+	logRecord, err := CreateLogRecord(record)
+	if err != nil {
+		return fmt.Errorf("failed to read log record %d: %w", lsn, err)
+	}
+
+	txNum := logRecord.TxNumber()
+	switch logRecord.Op() {
+	case Start:
+	case Commit:
+		if err := la.redo(la.pending[txNum]); err != nil {
+			return fmt.Errorf("failed to redo transaction %d: %w", txNum, err)
+		}
+		la.discard(txNum)
+	case Rollback:
+		la.discard(txNum)
+	case Checkpoint:
+		for pendingTxNum := range la.pending {
+			la.discard(pendingTxNum)
+		}
+	case NonQuiescentCheckpoint:
+		// The transactions it lists are still running, so their records are kept until they complete.
+	default:
+		if _, exists := la.pending[txNum]; !exists {
+			la.firstLSN[txNum] = lsn
+		}
+		la.pending[txNum] = append(la.pending[txNum], logRecord)
+	}
+
+	return la.advance(lsn)
+}
+
+// redo redoes the specified records in a new transaction and commits it.
+// The writes are not logged, since the shipped records can be applied again.
+func (la *LogApplier) redo(records []LogRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	tx := NewTransaction(la.fileManager, la.logManager, la.bufferManager, la.lockTable)
+	for _, logRecord := range records {
+		if err := logRecord.Redo(tx); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// discard forgets the held back records of the specified transaction.
+func (la *LogApplier) discard(txNum int) {
+	delete(la.pending, txNum)
+	delete(la.firstLSN, txNum)
+}
+
+// advance moves the applied LSN after the specified record has been consumed, and persists it.
+// The applied LSN cannot move past the first record of a transaction whose records are held back.
+func (la *LogApplier) advance(lsn int) error {
+	appliedLSN := lsn
+	for _, firstLSN := range la.firstLSN {
+		appliedLSN = min(appliedLSN, firstLSN-1)
+	}
+	if appliedLSN <= la.appliedLSN {
+		return nil
+	}
+
+	la.statePage.SetInt(0, appliedLSN)
+	if err := la.fileManager.Write(la.stateBlock, la.statePage); err != nil {
+		return fmt.Errorf("failed to write replication state: %w", err)
+	}
+	la.appliedLSN = appliedLSN
+	return nil
+}
+
+// pinForRedo pins the specified block, first appending blocks to the file until it contains the block,
+// since the blocks appended by the primary are not logged.
+func pinForRedo(tx *Transaction, block *file.BlockId) error {
+	size, err := tx.Size(block.Filename())
+	if err != nil {
+		return err
+	}
+	for ; size <= block.Number(); size++ {
+		if _, err := tx.Append(block.Filename()); err != nil {
+			return err
+		}
+	}
+	return tx.Pin(block)
+}
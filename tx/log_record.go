@@ -19,6 +19,9 @@ const (
 	SetLong
 	SetShort
 	SetDate
+	SetRow
+	SetFloat
+	NonQuiescentCheckpoint
 )
 
 func (t LogRecordType) String() string {
@@ -43,6 +46,12 @@ func (t LogRecordType) String() string {
 		return "SetShort"
 	case SetDate:
 		return "SetDate"
+	case SetRow:
+		return "SetRow"
+	case SetFloat:
+		return "SetFloat"
+	case NonQuiescentCheckpoint:
+		return "NonQuiescentCheckpoint"
 	default:
 		return "Unknown"
 	}
@@ -70,6 +79,12 @@ func FromCode(code int) (LogRecordType, error) {
 		return SetShort, nil
 	case 9:
 		return SetDate, nil
+	case 10:
+		return SetRow, nil
+	case 11:
+		return SetFloat, nil
+	case 12:
+		return NonQuiescentCheckpoint, nil
 	default:
 		return -1, errors.New("unknown LogRecordType code")
 	}
@@ -88,6 +103,10 @@ type LogRecord interface {
 	// The only log record types for which this method does anything interesting are SETINT and SETSTRING.
 	Undo(tx *Transaction) error
 
+	// Redo redoes the operation encoded by this log record, writing the new value it saved.
+	// Only the update records do anything; it is used to replay committed transactions on a follower.
+	Redo(tx *Transaction) error
+
 	// String returns a string representation of the log record.
 	String() string
 }
@@ -123,6 +142,12 @@ func CreateLogRecord(bytes []byte) (LogRecord, error) {
 		return NewSetShortRecord(p)
 	case SetDate:
 		return NewSetDateRecord(p)
+	case SetRow:
+		return NewSetRowRecord(p)
+	case SetFloat:
+		return NewSetFloatRecord(p)
+	case NonQuiescentCheckpoint:
+		return NewNonQuiescentCheckpointRecord(p)
 	default:
 		return nil, errors.New("unexpected LogRecordType")
 	}
@@ -48,9 +48,9 @@ func TestSetBoolRecord(t *testing.T) {
 	page.SetInt(0, int(SetBool))
 	page.SetInt(types.IntSize, txNum)
 	require.NoError(t, page.SetString(2*types.IntSize, block.Filename()))
-	page.SetInt(2*types.IntSize+file.MaxLength(len(block.Filename())), block.Number())
-	page.SetInt(3*types.IntSize+file.MaxLength(len(block.Filename())), offset)
-	page.SetBool(4*types.IntSize+file.MaxLength(len(block.Filename())), oldValue)
+	page.SetInt(2*types.IntSize+file.EncodedLength(block.Filename()), block.Number())
+	page.SetInt(3*types.IntSize+file.EncodedLength(block.Filename()), offset)
+	page.SetBool(4*types.IntSize+file.EncodedLength(block.Filename()), oldValue)
 
 	// Test record creation
 	record, err := NewSetBoolRecord(page)
@@ -58,7 +58,7 @@ func TestSetBoolRecord(t *testing.T) {
 	assert.Equal(t, "<SETBOOL 1 [file testfile, block 1] 100 false>", record.String())
 
 	// Test log writing
-	lsn, err := WriteSetBoolToLog(lm, txNum, block, offset, oldValue)
+	lsn, err := WriteSetBoolToLog(lm, txNum, block, offset, oldValue, true)
 	require.NoError(t, err)
 	assert.True(t, lsn > 0)
 
@@ -90,9 +90,9 @@ func TestSetDateRecord(t *testing.T) {
 	page.SetInt(0, int(SetDate))
 	page.SetInt(types.IntSize, txNum)
 	require.NoError(t, page.SetString(2*types.IntSize, block.Filename()))
-	page.SetInt(2*types.IntSize+file.MaxLength(len(block.Filename())), block.Number())
-	page.SetInt(3*types.IntSize+file.MaxLength(len(block.Filename())), offset)
-	page.SetDate(4*types.IntSize+file.MaxLength(len(block.Filename())), oldValue)
+	page.SetInt(2*types.IntSize+file.EncodedLength(block.Filename()), block.Number())
+	page.SetInt(3*types.IntSize+file.EncodedLength(block.Filename()), offset)
+	page.SetDate(4*types.IntSize+file.EncodedLength(block.Filename()), oldValue)
 
 	// Test record creation
 	record, err := NewSetDateRecord(page)
@@ -102,7 +102,7 @@ func TestSetDateRecord(t *testing.T) {
 	assert.Equal(t, expectedStr, record.String())
 
 	// Test log writing
-	lsn, err := WriteSetDateToLog(lm, txNum, block, offset, oldValue)
+	lsn, err := WriteSetDateToLog(lm, txNum, block, offset, oldValue, oldValue.Add(time.Hour))
 	require.NoError(t, err)
 	assert.True(t, lsn > 0)
 
@@ -134,9 +134,9 @@ func TestSetIntRecord(t *testing.T) {
 	page.SetInt(0, int(SetInt))
 	page.SetInt(types.IntSize, txNum)
 	require.NoError(t, page.SetString(2*types.IntSize, block.Filename()))
-	page.SetInt(2*types.IntSize+file.MaxLength(len(block.Filename())), block.Number())
-	page.SetInt(3*types.IntSize+file.MaxLength(len(block.Filename())), offset)
-	page.SetInt(4*types.IntSize+file.MaxLength(len(block.Filename())), oldValue)
+	page.SetInt(2*types.IntSize+file.EncodedLength(block.Filename()), block.Number())
+	page.SetInt(3*types.IntSize+file.EncodedLength(block.Filename()), offset)
+	page.SetInt(4*types.IntSize+file.EncodedLength(block.Filename()), oldValue)
 
 	// Test record creation
 	record, err := NewSetIntRecord(page)
@@ -144,7 +144,7 @@ func TestSetIntRecord(t *testing.T) {
 	assert.Equal(t, "<SETINT 1 [file testfile, block 1] 300 42>", record.String())
 
 	// Test log writing
-	lsn, err := WriteSetIntToLog(lm, txNum, block, offset, oldValue)
+	lsn, err := WriteSetIntToLog(lm, txNum, block, offset, oldValue, 43)
 	require.NoError(t, err)
 	assert.True(t, lsn > 0)
 
@@ -176,9 +176,9 @@ func TestSetLongRecord(t *testing.T) {
 	page.SetInt(0, int(SetLong))
 	page.SetInt(types.IntSize, txNum)
 	require.NoError(t, page.SetString(2*types.IntSize, block.Filename()))
-	page.SetInt(2*types.IntSize+file.MaxLength(len(block.Filename())), block.Number())
-	page.SetInt(3*types.IntSize+file.MaxLength(len(block.Filename())), offset)
-	page.SetLong(4*types.IntSize+file.MaxLength(len(block.Filename())), oldValue)
+	page.SetInt(2*types.IntSize+file.EncodedLength(block.Filename()), block.Number())
+	page.SetInt(3*types.IntSize+file.EncodedLength(block.Filename()), offset)
+	page.SetLong(4*types.IntSize+file.EncodedLength(block.Filename()), oldValue)
 
 	// Test record creation
 	record, err := NewSetLongRecord(page)
@@ -186,7 +186,7 @@ func TestSetLongRecord(t *testing.T) {
 	assert.Equal(t, "<SETLONG 1 [file testfile, block 1] 400 987654321>", record.String())
 
 	// Test log writing
-	lsn, err := WriteSetLongToLog(lm, txNum, block, offset, oldValue)
+	lsn, err := WriteSetLongToLog(lm, txNum, block, offset, oldValue, int64(123456789))
 	require.NoError(t, err)
 	assert.True(t, lsn > 0)
 
@@ -218,9 +218,9 @@ func TestSetShortRecord(t *testing.T) {
 	page.SetInt(0, int(SetShort))
 	page.SetInt(types.IntSize, txNum)
 	require.NoError(t, page.SetString(2*types.IntSize, block.Filename()))
-	page.SetInt(2*types.IntSize+file.MaxLength(len(block.Filename())), block.Number())
-	page.SetInt(3*types.IntSize+file.MaxLength(len(block.Filename())), offset)
-	page.SetShort(4*types.IntSize+file.MaxLength(len(block.Filename())), oldValue)
+	page.SetInt(2*types.IntSize+file.EncodedLength(block.Filename()), block.Number())
+	page.SetInt(3*types.IntSize+file.EncodedLength(block.Filename()), offset)
+	page.SetShort(4*types.IntSize+file.EncodedLength(block.Filename()), oldValue)
 
 	// Test record creation
 	record, err := NewSetShortRecord(page)
@@ -228,7 +228,49 @@ func TestSetShortRecord(t *testing.T) {
 	assert.Equal(t, "<SETSHORT 1 [file testfile, block 1] 500 1234>", record.String())
 
 	// Test log writing
-	lsn, err := WriteSetShortToLog(lm, txNum, block, offset, oldValue)
+	lsn, err := WriteSetShortToLog(lm, txNum, block, offset, oldValue, int16(4321))
+	require.NoError(t, err)
+	assert.True(t, lsn > 0)
+
+	// Verify log content
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	require.True(t, iter.HasNext())
+
+	bytes, err := iter.Next()
+	require.NoError(t, err)
+
+	logRecord, err := CreateLogRecord(bytes)
+	require.NoError(t, err)
+	assert.Equal(t, record.String(), logRecord.String())
+}
+
+func TestSetFloatRecord(t *testing.T) {
+	fm, lm, cleanup := testSetup(t)
+	defer cleanup()
+
+	block := file.NewBlockId("testfile", 1)
+	page := file.NewPage(fm.BlockSize())
+
+	txNum := 1
+	offset := 500
+	oldValue := 19.99
+
+	// Set page values
+	page.SetInt(0, int(SetFloat))
+	page.SetInt(types.IntSize, txNum)
+	require.NoError(t, page.SetString(2*types.IntSize, block.Filename()))
+	page.SetInt(2*types.IntSize+file.EncodedLength(block.Filename()), block.Number())
+	page.SetInt(3*types.IntSize+file.EncodedLength(block.Filename()), offset)
+	page.SetFloat(4*types.IntSize+file.EncodedLength(block.Filename()), oldValue)
+
+	// Test record creation
+	record, err := NewSetFloatRecord(page)
+	require.NoError(t, err)
+	assert.Equal(t, "<SETFLOAT 1 [file testfile, block 1] 500 19.99>", record.String())
+
+	// Test log writing
+	lsn, err := WriteSetFloatToLog(lm, txNum, block, offset, oldValue, -0.25)
 	require.NoError(t, err)
 	assert.True(t, lsn > 0)
 
@@ -260,9 +302,9 @@ func TestSetStringRecord(t *testing.T) {
 	page.SetInt(0, int(SetString))
 	page.SetInt(types.IntSize, txNum)
 	require.NoError(t, page.SetString(2*types.IntSize, block.Filename()))
-	page.SetInt(2*types.IntSize+file.MaxLength(len(block.Filename())), block.Number())
-	page.SetInt(3*types.IntSize+file.MaxLength(len(block.Filename())), offset)
-	require.NoError(t, page.SetString(4*types.IntSize+file.MaxLength(len(block.Filename())), oldValue))
+	page.SetInt(2*types.IntSize+file.EncodedLength(block.Filename()), block.Number())
+	page.SetInt(3*types.IntSize+file.EncodedLength(block.Filename()), offset)
+	require.NoError(t, page.SetString(4*types.IntSize+file.EncodedLength(block.Filename()), oldValue))
 
 	// Test record creation
 	record, err := NewSetStringRecord(page)
@@ -270,7 +312,7 @@ func TestSetStringRecord(t *testing.T) {
 	assert.Equal(t, "<SETSTRING 1 [file testfile, block 1] 600 Hello, World!>", record.String())
 
 	// Test log writing
-	lsn, err := WriteSetStringToLog(lm, txNum, block, offset, oldValue)
+	lsn, err := WriteSetStringToLog(lm, txNum, block, offset, oldValue, "Goodbye, World!")
 	require.NoError(t, err)
 	assert.True(t, lsn > 0)
 
@@ -287,6 +329,51 @@ func TestSetStringRecord(t *testing.T) {
 	assert.Equal(t, record.String(), logRecord.String())
 }
 
+func TestSetRowRecord(t *testing.T) {
+	_, lm, cleanup := testSetup(t)
+	defer cleanup()
+
+	block := file.NewBlockId("testfile", 1)
+	txNum := 1
+	offset := 40
+	length := 48
+
+	// The old image has a short run of zeros within a segment, and a long one between two segments.
+	oldImage := make([]byte, length)
+	copy(oldImage[4:], []byte{1, 0, 0, 2})
+	copy(oldImage[40:], []byte{3, 4})
+	newImage := make([]byte, length)
+	newImage[length-1] = 5
+
+	_, err := WriteSetRowToLog(lm, txNum, block, offset, length, oldImage, nil)
+	require.NoError(t, err)
+	_, err = WriteSetRowToLog(lm, txNum, block, offset, length, nil, newImage)
+	require.NoError(t, err)
+	_, err = WriteSetRowToLog(lm, txNum, block, offset, length, make([]byte, length-1), nil)
+	assert.Error(t, err, "an image must have the length of its slot")
+
+	// The log iterator returns the newest record first.
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+
+	var records []*SetRowRecord
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		logRecord, err := CreateLogRecord(bytes)
+		require.NoError(t, err)
+		require.Equal(t, SetRow, logRecord.Op())
+		records = append(records, logRecord.(*SetRowRecord))
+	}
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "<SETROW 1 [file testfile, block 1] 40 48>", records[1].String())
+	assert.Equal(t, oldImage, records[1].image)
+	assert.Nil(t, records[1].newImage)
+	assert.Nil(t, records[0].image)
+	assert.Equal(t, newImage, records[0].newImage)
+}
+
 // TestMultipleLogRecords tests writing and reading multiple different types of records
 func TestMultipleLogRecords(t *testing.T) {
 	_, lm, cleanup := testSetup(t)
@@ -306,38 +393,44 @@ func TestMultipleLogRecords(t *testing.T) {
 	writes := []logWrite{
 		{
 			write: func() (int, error) {
-				return WriteSetBoolToLog(lm, txNum, block, 100, true)
+				return WriteSetBoolToLog(lm, txNum, block, 100, true, false)
 			},
 			expected: "<SETBOOL 1 [file testfile, block 1] 100 true>",
 		},
 		{
 			write: func() (int, error) {
-				return WriteSetDateToLog(lm, txNum, block, 200, testTime)
+				return WriteSetDateToLog(lm, txNum, block, 200, testTime, testTime.Add(time.Hour))
 			},
 			expected: fmt.Sprintf("<SETDATE 1 [file testfile, block 1] 200 %s>",
 				time.Unix(testTime.Unix(), 0)),
 		},
 		{
 			write: func() (int, error) {
-				return WriteSetIntToLog(lm, txNum, block, 300, 42)
+				return WriteSetIntToLog(lm, txNum, block, 300, 42, 43)
 			},
 			expected: "<SETINT 1 [file testfile, block 1] 300 42>",
 		},
 		{
 			write: func() (int, error) {
-				return WriteSetLongToLog(lm, txNum, block, 400, 987654321)
+				return WriteSetLongToLog(lm, txNum, block, 400, 987654321, 123456789)
 			},
 			expected: "<SETLONG 1 [file testfile, block 1] 400 987654321>",
 		},
 		{
 			write: func() (int, error) {
-				return WriteSetShortToLog(lm, txNum, block, 500, 1234)
+				return WriteSetShortToLog(lm, txNum, block, 500, 1234, 4321)
 			},
 			expected: "<SETSHORT 1 [file testfile, block 1] 500 1234>",
 		},
 		{
 			write: func() (int, error) {
-				return WriteSetStringToLog(lm, txNum, block, 600, "Test String")
+				return WriteSetFloatToLog(lm, txNum, block, 550, 19.99, -0.25)
+			},
+			expected: "<SETFLOAT 1 [file testfile, block 1] 550 19.99>",
+		},
+		{
+			write: func() (int, error) {
+				return WriteSetStringToLog(lm, txNum, block, 600, "Test String", "New String")
 			},
 			expected: "<SETSTRING 1 [file testfile, block 1] 600 Test String>",
 		},
@@ -0,0 +1,120 @@
+package tx
+
+import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/types"
+	"strings"
+)
+
+// NonQuiescentCheckpointRecord is a checkpoint written while transactions are running (see TakeCheckpoint).
+// It holds the numbers of the transactions that were active when it was written: recovery reading the log
+// backwards can stop once it has read the start records of those that did not complete, since every other
+// transaction that started before the checkpoint completed before it.
+type NonQuiescentCheckpointRecord struct {
+	LogRecord
+	txNums []int
+}
+
+// NewNonQuiescentCheckpointRecord creates a new NonQuiescentCheckpointRecord from a Page.
+func NewNonQuiescentCheckpointRecord(page *file.Page) (*NonQuiescentCheckpointRecord, error) {
+	operationPos := 0
+	countPos := operationPos + types.IntSize
+	count := page.GetInt(countPos)
+
+	txNums := make([]int, count)
+	for i := range txNums {
+		txNums[i] = page.GetInt(countPos + (i+1)*types.IntSize)
+	}
+	return &NonQuiescentCheckpointRecord{txNums: txNums}, nil
+}
+
+// Op returns the type of the log record.
+func (r *NonQuiescentCheckpointRecord) Op() LogRecordType {
+	return NonQuiescentCheckpoint
+}
+
+// TxNumber returns the transaction number stored in the log record. NonQuiescentCheckpointRecord does not have
+// a transaction number, so it returns a "dummy", negative txId.
+func (r *NonQuiescentCheckpointRecord) TxNumber() int {
+	return -1
+}
+
+// ActiveTxNumbers returns the numbers of the transactions that were active when the checkpoint was written.
+func (r *NonQuiescentCheckpointRecord) ActiveTxNumbers() []int {
+	return r.txNums
+}
+
+// Undo does nothing. NonQuiescentCheckpointRecord does not change any data.
+func (r *NonQuiescentCheckpointRecord) Undo(_ *Transaction) error {
+	return nil
+}
+
+// Redo does nothing. NonQuiescentCheckpointRecord does not change any data.
+func (r *NonQuiescentCheckpointRecord) Redo(_ *Transaction) error {
+	return nil
+}
+
+// String returns a string representation of the log record.
+func (r *NonQuiescentCheckpointRecord) String() string {
+	txNums := make([]string, len(r.txNums))
+	for i, txNum := range r.txNums {
+		txNums[i] = fmt.Sprint(txNum)
+	}
+	return fmt.Sprintf("<NQCKPT %s>", strings.Join(txNums, ", "))
+}
+
+// WriteNonQuiescentCheckpointToLog writes a non-quiescent checkpoint record to the log. This log record contains
+// the NonQuiescentCheckpoint operator, followed by the number of active transactions and their numbers.
+// The method returns the LSN of the new log record.
+func WriteNonQuiescentCheckpointToLog(logManager *log.Manager) (int, error) {
+	return logManager.AppendCheckpoint(func(activeTxNums []int) []byte {
+		record := make([]byte, (2+len(activeTxNums))*types.IntSize)
+
+		page := file.NewPageFromBytes(record)
+		page.SetInt(0, int(NonQuiescentCheckpoint))
+		page.SetInt(types.IntSize, len(activeTxNums))
+		for i, txNum := range activeTxNums {
+			page.SetInt((i+2)*types.IntSize, txNum)
+		}
+		return record
+	})
+}
+
+// TakeCheckpoint writes a non-quiescent checkpoint, without waiting for the running transactions to complete:
+// it flushes every dirty buffer, and then writes and flushes a NonQuiescentCheckpointRecord listing the
+// active transactions. Recovery then stops reading the log at the checkpoint, or at the start record of the
+// oldest transaction it lists that did not complete, instead of reading the whole log.
+func TakeCheckpoint(logManager *log.Manager, bufferManager *buffer.Manager) error {
+	_, err := takeCheckpoint(logManager, bufferManager)
+	return err
+}
+
+// TruncateLog takes a checkpoint (see TakeCheckpoint), and then truncates the log before the oldest record
+// that recovery or the rollback of a running transaction may read: the start record of the oldest active
+// transaction, or the checkpoint if no transaction is active (see log.Manager#Truncate).
+// It returns the number of log records removed.
+func TruncateLog(logManager *log.Manager, bufferManager *buffer.Manager) (int, error) {
+	lsn, err := takeCheckpoint(logManager, bufferManager)
+	if err != nil {
+		return 0, err
+	}
+	if oldest, ok := logManager.OldestActiveLSN(); ok && oldest < lsn {
+		lsn = oldest
+	}
+	return logManager.Truncate(lsn)
+}
+
+// takeCheckpoint writes a non-quiescent checkpoint, and returns its LSN.
+func takeCheckpoint(logManager *log.Manager, bufferManager *buffer.Manager) (int, error) {
+	if err := bufferManager.FlushAllDirty(); err != nil {
+		return 0, err
+	}
+	lsn, err := WriteNonQuiescentCheckpointToLog(logManager)
+	if err != nil {
+		return 0, err
+	}
+	return lsn, logManager.Flush(lsn)
+}
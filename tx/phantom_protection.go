@@ -0,0 +1,33 @@
+package tx
+
+// PhantomProtection determines how a transaction protects the files it reads against phantoms,
+// the records that other transactions append to a file while the transaction is reading it.
+type PhantomProtection int
+
+const (
+	// SerializablePhantomProtection holds the shared lock on the end-of-file marker of every file whose size
+	// the transaction reads until the transaction completes, so that no other transaction can append a block
+	// to the file meanwhile: reading the file again returns the same records. As a consequence, a long scan
+	// blocks every insert into its table that needs to append a block, until the scan's transaction completes
+	// or the insert's lock times out. This is the default.
+	SerializablePhantomProtection PhantomProtection = iota
+
+	// RelaxedPhantomProtection only holds the shared lock on the end-of-file marker for the duration of each
+	// Size call, so that other transactions can append blocks to the files the transaction is reading.
+	// Table scans read the blocks the file had when they were opened (or last moved before their first record),
+	// so they do not see the records appended meanwhile, but scanning the table again does. Other transactions
+	// inserting into a block the scan has already read still wait for it to complete.
+	RelaxedPhantomProtection
+)
+
+// SetPhantomProtection sets how the transaction protects the files it reads against phantoms.
+// It should be set before the transaction reads any file; the end-of-file locks the transaction
+// already holds are kept until it completes.
+func (tx *Transaction) SetPhantomProtection(phantomProtection PhantomProtection) {
+	tx.phantomProtection = phantomProtection
+}
+
+// PhantomProtection returns how the transaction protects the files it reads against phantoms.
+func (tx *Transaction) PhantomProtection() PhantomProtection {
+	return tx.phantomProtection
+}
@@ -11,11 +11,17 @@ import (
 // Commit writes a commit record to the log, and flushes it to disk.
 // Rollback rolls back the transaction, writes a rollback record to the log, and flushes it to the disk.
 // Recover recovers uncompleted transactions from the log, and then writes a quiescent checkpoint record to the log, and flushes it.
+// The start record of the transaction is written before its first update record, so transactions that do not
+// modify the database leave no start record.
 type RecoveryManager struct {
 	logManager    *log.Manager
 	bufferManager *buffer.Manager
 	transaction   *Transaction
 	txNum         int
+	// started is true once the start record of the transaction was written.
+	started bool
+	// lastLSN is the LSN of the last update record of the transaction.
+	lastLSN int
 }
 
 // NewRecoveryManager creates a new RecoveryManager.
@@ -30,6 +36,13 @@ func NewRecoveryManager(tx *Transaction, txNum int, logManager *log.Manager, buf
 
 // Commit writes a commit record to the log, and flushes it to disk.
 func (rm *RecoveryManager) Commit() error {
+	// The update records of the transaction are flushed first, along with those of the transactions committing
+	// meanwhile if the log manager groups commits, so that writing its buffers does not flush the log again.
+	if rm.started {
+		if err := rm.logManager.FlushCommit(rm.lastLSN); err != nil {
+			return err
+		}
+	}
 	// This flushes all the changes to the buffers for this transaction. Internally, it first flushes all the
 	// respective log records, and then the actual buffers to the disk blocks.
 	if err := rm.bufferManager.FlushAll(rm.txNum); err != nil {
@@ -40,8 +53,9 @@ func (rm *RecoveryManager) Commit() error {
 	if err != nil {
 		return err
 	}
-	// Flushes the commit log record to disk.
-	return rm.logManager.Flush(lsn)
+	// Flushes the commit log record to disk, along with those of the transactions committing meanwhile
+	// if the log manager groups commits.
+	return rm.logManager.FlushCommit(lsn)
 }
 
 // Rollback rolls back the transaction, writes a rollback record to the log, and flushes it to the disk.
@@ -77,53 +91,114 @@ func (rm *RecoveryManager) Recover() error {
 
 // SetInt writes a SetInt record to the log and returns its lsn.
 func (rm *RecoveryManager) SetInt(buffer *buffer.Buffer, offset int, newVal int) (int, error) {
+	if err := rm.start(); err != nil {
+		return -1, err
+	}
 	oldVal := buffer.Contents().GetInt(offset)
 	block := buffer.Block()
-	return WriteSetIntToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return rm.logged(WriteSetIntToLog(rm.logManager, rm.txNum, block, offset, oldVal, newVal))
 }
 
 // SetString writes a SetString record to the log and returns its lsn.
 func (rm *RecoveryManager) SetString(buffer *buffer.Buffer, offset int, newVal string) (int, error) {
+	if err := rm.start(); err != nil {
+		return -1, err
+	}
 	oldVal, err := buffer.Contents().GetString(offset)
 	if err != nil {
 		return -1, err
 	}
 	block := buffer.Block()
-	return WriteSetStringToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return rm.logged(WriteSetStringToLog(rm.logManager, rm.txNum, block, offset, oldVal, newVal))
 }
 
 // SetBool writes a SetBool record to the log and returns its lsn.
 func (rm *RecoveryManager) SetBool(buffer *buffer.Buffer, offset int, newVal bool) (int, error) {
+	if err := rm.start(); err != nil {
+		return -1, err
+	}
 	oldVal := buffer.Contents().GetBool(offset)
 	block := buffer.Block()
-	return WriteSetBoolToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return rm.logged(WriteSetBoolToLog(rm.logManager, rm.txNum, block, offset, oldVal, newVal))
 }
 
 // SetLong writes a SetLong record to the log and returns its lsn.
 func (rm *RecoveryManager) SetLong(buffer *buffer.Buffer, offset int, newVal int64) (int, error) {
+	if err := rm.start(); err != nil {
+		return -1, err
+	}
 	oldVal := buffer.Contents().GetLong(offset)
 	block := buffer.Block()
-	return WriteSetLongToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return rm.logged(WriteSetLongToLog(rm.logManager, rm.txNum, block, offset, oldVal, newVal))
 }
 
 // SetShort writes a SetShort record to the log and returns its lsn.
 func (rm *RecoveryManager) SetShort(buffer *buffer.Buffer, offset int, newVal int16) (int, error) {
+	if err := rm.start(); err != nil {
+		return -1, err
+	}
 	oldVal := buffer.Contents().GetShort(offset)
 	block := buffer.Block()
-	return WriteSetShortToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return rm.logged(WriteSetShortToLog(rm.logManager, rm.txNum, block, offset, oldVal, newVal))
+}
+
+// SetFloat writes a SetFloat record to the log and returns its lsn.
+func (rm *RecoveryManager) SetFloat(buffer *buffer.Buffer, offset int, newVal float64) (int, error) {
+	if err := rm.start(); err != nil {
+		return -1, err
+	}
+	oldVal := buffer.Contents().GetFloat(offset)
+	block := buffer.Block()
+	return rm.logged(WriteSetFloatToLog(rm.logManager, rm.txNum, block, offset, oldVal, newVal))
 }
 
 // SetDate writes a SetDate record to the log and returns its lsn.
 func (rm *RecoveryManager) SetDate(buffer *buffer.Buffer, offset int, newVal time.Time) (int, error) {
+	if err := rm.start(); err != nil {
+		return -1, err
+	}
 	oldVal := buffer.Contents().GetDate(offset)
 	block := buffer.Block()
-	return WriteSetDateToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return rm.logged(WriteSetDateToLog(rm.logManager, rm.txNum, block, offset, oldVal, newVal))
+}
+
+// SetRow writes a SetRow record holding the specified images of the slot at the specified offset to the log,
+// and returns its lsn. Either image may be nil.
+func (rm *RecoveryManager) SetRow(buffer *buffer.Buffer, offset, length int, oldImage, newImage []byte) (int, error) {
+	if err := rm.start(); err != nil {
+		return -1, err
+	}
+	return rm.logged(WriteSetRowToLog(rm.logManager, rm.txNum, buffer.Block(), offset, length, oldImage, newImage))
+}
+
+// logged records the LSN of an update record of the transaction written to the log, and returns it.
+func (rm *RecoveryManager) logged(lsn int, err error) (int, error) {
+	if err == nil {
+		rm.lastLSN = lsn
+	}
+	return lsn, err
+}
+
+// start writes the start record of the transaction to the log, unless it was already written.
+func (rm *RecoveryManager) start() error {
+	if rm.started {
+		return nil
+	}
+	if _, err := WriteStartToLog(rm.logManager, rm.txNum); err != nil {
+		return err
+	}
+	rm.started = true
+	return nil
 }
 
 // doRollback rolls back the transaction,
 // by iterating through the log records until it finds the transaction's Start record,
 // calling Undo() for each of the transaction's log records.
+// A transaction that did not write its start record has no records to undo.
 func (rm *RecoveryManager) doRollback() error {
+	if !rm.started {
+		return nil
+	}
 	iter, err := rm.logManager.Iterator()
 	if err != nil {
 		return err
@@ -161,9 +236,13 @@ func (rm *RecoveryManager) doRollback() error {
 // The method iterates through the log records.
 // Whenever it finds a log record for an unfinished transaction,
 // it calls Undo() on that record.
-// The method stops when it encounters a Checkpoint record or the end of the log.
+// The method stops when it encounters a quiescent Checkpoint record or the end of the log. Past a non-quiescent
+// checkpoint, it only goes on until it has found the Start records of the unfinished transactions the checkpoint
+// lists, since the other transactions that started before the checkpoint had finished.
 func (rm *RecoveryManager) doRecover() error {
 	finishedTransactions := make([]int, 0, 10)
+	// unstarted holds the unfinished transactions listed by a non-quiescent checkpoint whose Start record is not found yet.
+	var unstarted map[int]bool
 	iter, err := rm.logManager.Iterator()
 	if err != nil {
 		return err
@@ -183,6 +262,14 @@ func (rm *RecoveryManager) doRecover() error {
 		if logRecord.Op() == Checkpoint {
 			return nil
 		}
+		if checkpoint, ok := logRecord.(*NonQuiescentCheckpointRecord); ok && unstarted == nil {
+			unstarted = make(map[int]bool)
+			for _, txNum := range checkpoint.ActiveTxNumbers() {
+				if !contains(finishedTransactions, txNum) {
+					unstarted[txNum] = true
+				}
+			}
+		}
 
 		if logRecord.Op() == Commit || logRecord.Op() == Rollback {
 			finishedTransactions = append(finishedTransactions, logRecord.TxNumber())
@@ -191,6 +278,13 @@ func (rm *RecoveryManager) doRecover() error {
 				return err
 			}
 		}
+
+		if logRecord.Op() == Start {
+			delete(unstarted, logRecord.TxNumber())
+		}
+		if unstarted != nil && len(unstarted) == 0 {
+			return nil
+		}
 	}
 	return nil
 }
@@ -0,0 +1,325 @@
+package tx_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// recoveryEngine holds the managers of a database opened by a test, which is dropped without
+// being closed to simulate a crash.
+type recoveryEngine struct {
+	fm *file.Manager
+	lm *log.Manager
+	bm *buffer.Manager
+	lt *concurrency.LockTable
+}
+
+// openRecoveryEngine opens the database in the specified directory and recovers it, like the server does on startup.
+func openRecoveryEngine(t *testing.T, dir string) *recoveryEngine {
+	fm, err := file.NewManager(dir, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	e := &recoveryEngine{fm: fm, lm: lm, bm: buffer.NewManager(fm, lm, 8), lt: concurrency.NewLockTable()}
+
+	txn := e.newTx()
+	require.NoError(t, txn.Recover())
+	require.NoError(t, txn.Commit())
+	return e
+}
+
+func (e *recoveryEngine) newTx() *tx.Transaction {
+	return tx.NewTransaction(e.fm, e.lm, e.bm, e.lt)
+}
+
+// setInt sets the integer at the specified offset of the block in the transaction.
+func setInt(t *testing.T, txn *tx.Transaction, block *file.BlockId, offset, val int) {
+	t.Helper()
+	require.NoError(t, txn.Pin(block))
+	require.NoError(t, txn.SetInt(block, offset, val, true))
+}
+
+// getInt reads the integer at the specified offset of the block in a transaction of its own.
+func getInt(t *testing.T, e *recoveryEngine, block *file.BlockId, offset int) int {
+	t.Helper()
+	txn := e.newTx()
+	defer func() { require.NoError(t, txn.Commit()) }()
+	require.NoError(t, txn.Pin(block))
+	val, err := txn.GetInt(block, offset)
+	require.NoError(t, err)
+	return val
+}
+
+// loggedTxNumbers returns the numbers of the transactions having records in the log, and the transactions
+// having a commit record, from the most recent to the oldest.
+func loggedTxNumbers(t *testing.T, lm *log.Manager) (numbers []int, committed map[int]bool) {
+	t.Helper()
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	committed = make(map[int]bool)
+	seen := make(map[int]bool)
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		logRecord, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		if logRecord.Op() == tx.Checkpoint {
+			continue
+		}
+		if logRecord.Op() == tx.Commit {
+			committed[logRecord.TxNumber()] = true
+		}
+		if !seen[logRecord.TxNumber()] {
+			seen[logRecord.TxNumber()] = true
+			numbers = append(numbers, logRecord.TxNumber())
+		}
+	}
+	return numbers, committed
+}
+
+func TestRecovery_TxNumbersAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+
+	// First run: every transaction commits.
+	first := openRecoveryEngine(t, dir)
+	txn := first.newTx()
+	committedBlock, err := txn.Append("data")
+	require.NoError(t, err)
+	uncommittedBlock, err := txn.Append("data")
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+	for i := 0; i < 3; i++ {
+		txn = first.newTx()
+		setInt(t, txn, committedBlock, i*types.IntSize, 100+i)
+		setInt(t, txn, uncommittedBlock, i*types.IntSize, 100+i)
+		require.NoError(t, txn.Commit())
+	}
+	firstRun, _ := loggedTxNumbers(t, first.lm)
+	require.NotEmpty(t, firstRun)
+
+	// Second run: a transaction is still running when the database crashes, after its records and
+	// its modified block were written to disk by the commits of the other transactions.
+	second := openRecoveryEngine(t, dir)
+	uncommitted := second.newTx()
+	setInt(t, uncommitted, uncommittedBlock, 0, 999)
+	setInt(t, uncommitted, uncommittedBlock, types.IntSize, 999)
+	for i := 0; i < 3; i++ {
+		txn = second.newTx()
+		setInt(t, txn, committedBlock, i*types.IntSize, 200+i)
+		require.NoError(t, txn.Commit())
+	}
+	require.NoError(t, second.bm.FlushAll(uncommitted.TxNum()))
+
+	// No number of the first run was assigned again.
+	for _, txNum := range []int{uncommitted.TxNum(), txn.TxNum()} {
+		assert.Greater(t, txNum, firstRun[0])
+	}
+
+	// Recovery only rolls back the transaction that did not commit.
+	third := openRecoveryEngine(t, dir)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 200+i, getInt(t, third, committedBlock, i*types.IntSize))
+		assert.Equal(t, 100+i, getInt(t, third, uncommittedBlock, i*types.IntSize))
+	}
+
+	numbers, committed := loggedTxNumbers(t, third.lm)
+	assert.False(t, committed[uncommitted.TxNum()])
+	assert.True(t, committed[txn.TxNum()])
+	// The log is read from the most recent record, and each transaction only has records in one run,
+	// so the numbers of the transactions appear in decreasing order, each once.
+	for i := 1; i < len(numbers); i++ {
+		assert.Less(t, numbers[i], numbers[i-1], "transaction number %d was assigned after %d", numbers[i-1], numbers[i])
+	}
+	assert.Contains(t, numbers, uncommitted.TxNum())
+}
+
+func TestRecovery_NonQuiescentCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	e := openRecoveryEngine(t, dir)
+	txn := e.newTx()
+	block, err := txn.Append("data")
+	require.NoError(t, err)
+	// The transactions that do not commit write blocks of their own, so that they do not lock the others out.
+	runningBlock, err := txn.Append("data")
+	require.NoError(t, err)
+	lateBlock, err := txn.Append("data")
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	// Many transactions commit before and after the checkpoint, each writing its own slot.
+	slots := 40
+	for i := 0; i < slots/2; i++ {
+		txn = e.newTx()
+		setInt(t, txn, block, i*types.IntSize, 100+i)
+		require.NoError(t, txn.Commit())
+	}
+
+	// An update record of a transaction that never completed, with no start record, as written by a
+	// transaction that was not running anymore when the checkpoint was written. Recovery does not read
+	// that far back, so it does not undo it.
+	strayOffset := slots * types.IntSize
+	_, err = tx.WriteSetIntToLog(e.lm, 1<<20, block, strayOffset, 7, 0)
+	require.NoError(t, err)
+
+	// A transaction running across the checkpoint, which does not commit.
+	running := e.newTx()
+	setInt(t, running, runningBlock, 0, 900)
+	require.NoError(t, tx.TakeCheckpoint(e.lm, e.bm))
+	setInt(t, running, runningBlock, types.IntSize, 901)
+
+	for i := slots / 2; i < slots; i++ {
+		txn = e.newTx()
+		setInt(t, txn, block, i*types.IntSize, 100+i)
+		require.NoError(t, txn.Commit())
+	}
+
+	// A transaction started after the checkpoint, which does not commit either.
+	late := e.newTx()
+	setInt(t, late, lateBlock, 0, 902)
+	require.NoError(t, e.bm.FlushAllDirty())
+
+	// The checkpoint lists the running transaction.
+	iter, err := e.lm.Iterator()
+	require.NoError(t, err)
+	var checkpoint *tx.NonQuiescentCheckpointRecord
+	for checkpoint == nil && iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		logRecord, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		checkpoint, _ = logRecord.(*tx.NonQuiescentCheckpointRecord)
+	}
+	require.NotNil(t, checkpoint)
+	assert.Equal(t, []int{running.TxNum()}, checkpoint.ActiveTxNumbers())
+
+	// Recovery undoes the transactions that did not commit, including the writes of the running
+	// transaction before the checkpoint, and keeps those of the others.
+	recovered := openRecoveryEngine(t, dir)
+	for i := 0; i < slots; i++ {
+		assert.Equal(t, 100+i, getInt(t, recovered, block, i*types.IntSize), "slot %d", i)
+	}
+	assert.Zero(t, getInt(t, recovered, runningBlock, 0))
+	assert.Zero(t, getInt(t, recovered, runningBlock, types.IntSize))
+	assert.Zero(t, getInt(t, recovered, lateBlock, 0))
+	assert.Zero(t, getInt(t, recovered, block, strayOffset))
+}
+
+func TestRecovery_TruncateLog(t *testing.T) {
+	dir := t.TempDir()
+	e := openRecoveryEngine(t, dir)
+	txn := e.newTx()
+	block, err := txn.Append("data")
+	require.NoError(t, err)
+	runningBlock, err := txn.Append("data")
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	// Thousands of committed updates, each slot being overwritten many times.
+	slots := 50
+	for i := 0; i < 2000; i++ {
+		txn = e.newTx()
+		setInt(t, txn, block, (i%slots)*types.IntSize, i)
+		require.NoError(t, txn.Commit())
+	}
+
+	// A transaction running across the truncation, which does not commit.
+	running := e.newTx()
+	setInt(t, running, runningBlock, 0, 900)
+	for i := 2000; i < 2100; i++ {
+		txn = e.newTx()
+		setInt(t, txn, block, (i%slots)*types.IntSize, i)
+		require.NoError(t, txn.Commit())
+	}
+
+	blocks, err := e.fm.Length("logfile")
+	require.NoError(t, err)
+	removed, err := tx.TruncateLog(e.lm, e.bm)
+	require.NoError(t, err)
+	assert.Greater(t, removed, 0)
+	truncatedBlocks, err := e.fm.Length("logfile")
+	require.NoError(t, err)
+	assert.Less(t, truncatedBlocks, blocks/5)
+
+	// The running transaction can still be rolled back, since its records were kept.
+	setInt(t, running, runningBlock, types.IntSize, 901)
+	require.NoError(t, running.Rollback())
+	assert.Zero(t, getInt(t, e, runningBlock, 0))
+
+	// Another one does not commit before the crash.
+	uncommitted := e.newTx()
+	setInt(t, uncommitted, runningBlock, 0, 902)
+	require.NoError(t, e.bm.FlushAllDirty())
+
+	recovered := openRecoveryEngine(t, dir)
+	for slot := 0; slot < slots; slot++ {
+		assert.Equal(t, 2050+slot, getInt(t, recovered, block, slot*types.IntSize), "slot %d", slot)
+	}
+	assert.Zero(t, getInt(t, recovered, runningBlock, 0))
+	assert.Zero(t, getInt(t, recovered, runningBlock, types.IntSize))
+}
+
+func TestRecovery_UTF8Strings(t *testing.T) {
+	dir := t.TempDir()
+	e := openRecoveryEngine(t, dir)
+	txn := e.newTx()
+	block, err := txn.Append("data")
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	// Strings whose length in bytes differs from their number of characters, the longest of which are logged
+	// with their old values in records that only fit a log block when they reserve the bytes of the strings.
+	values := []string{"café", "東京都", "👩‍👩‍👧", "été", strings.Repeat("🦀", 20)}
+	replacements := []string{strings.Repeat("語", 26), "", "ñandú", strings.Repeat("👍🏽", 5), "ö"}
+	offsets := []int{0, 90, 180, 230, 290}
+	setStrings := func(txn *tx.Transaction, vals []string) {
+		require.NoError(t, txn.Pin(block))
+		for i, val := range vals {
+			require.NoError(t, txn.SetString(block, offsets[i], val, true))
+		}
+	}
+	getStrings := func() []string {
+		txn := e.newTx()
+		defer func() { require.NoError(t, txn.Commit()) }()
+		require.NoError(t, txn.Pin(block))
+		vals := make([]string, len(offsets))
+		for i, offset := range offsets {
+			vals[i], err = txn.GetString(block, offset)
+			require.NoError(t, err)
+		}
+		return vals
+	}
+
+	txn = e.newTx()
+	setStrings(txn, values)
+	require.NoError(t, txn.Commit())
+
+	// Rolling back restores the old values from the log.
+	txn = e.newTx()
+	setStrings(txn, replacements)
+	require.NoError(t, txn.Rollback())
+	assert.Equal(t, values, getStrings())
+
+	// So does recovering from a crash with the values of an uncommitted transaction written to disk.
+	uncommitted := e.newTx()
+	setStrings(uncommitted, replacements)
+	require.NoError(t, e.bm.FlushAllDirty())
+	e = openRecoveryEngine(t, dir)
+	assert.Equal(t, values, getStrings())
+
+	// And the new values of a committed transaction are recovered.
+	txn = e.newTx()
+	setStrings(txn, replacements)
+	require.NoError(t, txn.Commit())
+	e = openRecoveryEngine(t, dir)
+	assert.Equal(t, replacements, getStrings())
+}
@@ -36,20 +36,25 @@ func (r *RollbackRecord) Undo(_ *Transaction) error {
 	return nil
 }
 
+// Redo does nothing. RollbackRecord does not change any data.
+func (r *RollbackRecord) Redo(_ *Transaction) error {
+	return nil
+}
+
 // String returns a string representation of the log record.
 func (r *RollbackRecord) String() string {
 	return fmt.Sprintf("<ROLLBACK %d>", r.txNum)
 }
 
 // WriteRollbackToLog writes a rollback record to the log. This log record contains the Rollback operator,
-// followed by the transaction id.
+// followed by the transaction id. The transaction is no longer active.
 // The method returns the LSN of the new log record.
 func WriteRollbackToLog(logManager *log.Manager, txNum int) (int, error) {
 	record := make([]byte, 2*types.IntSize)
 
 	page := file.NewPageFromBytes(record)
 	page.SetInt(0, int(Rollback))
-	page.SetInt(4, txNum)
+	page.SetInt(types.IntSize, txNum)
 
-	return logManager.Append(record)
+	return logManager.AppendEnd(txNum, record)
 }
@@ -0,0 +1,189 @@
+package tx
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// maxPendingRows is the number of inserted rows whose field writes a transaction leaves unlogged
+// before it logs their images, which bounds the memory held by a transaction inserting many rows.
+const maxPendingRows = 256
+
+// pendingRow is a slot inserted by a transaction whose field writes are not logged yet.
+type pendingRow struct {
+	block  file.BlockId
+	offset int
+	length int
+}
+
+// pendingRowKey identifies the slot of a pending row: its block, and its number in the block,
+// which is its offset divided by its length, since the slots of a block all have the same length.
+type pendingRowKey struct {
+	block file.BlockId
+	slot  int
+}
+
+// pendingRows are the rows inserted by a transaction whose field writes are not logged yet, by slot,
+// so that finding whether a write lies within one of them does not go through all of them.
+type pendingRows struct {
+	rows map[pendingRowKey]*pendingRow
+	// slotLengths are the lengths of the slots of the blocks holding pending rows.
+	slotLengths map[file.BlockId]int
+}
+
+// add adds a row to the pending rows, if it is not among them yet.
+func (pr *pendingRows) add(block *file.BlockId, offset, length int) {
+	if pr.rows == nil {
+		pr.rows = make(map[pendingRowKey]*pendingRow)
+		pr.slotLengths = make(map[file.BlockId]int)
+	}
+	key := pendingRowKey{block: block.Key(), slot: offset / length}
+	if _, ok := pr.rows[key]; !ok {
+		pr.rows[key] = &pendingRow{block: block.Key(), offset: offset, length: length}
+		pr.slotLengths[block.Key()] = length
+	}
+}
+
+// contains returns true if the specified offset of the specified block lies within a pending row.
+func (pr *pendingRows) contains(block *file.BlockId, offset int) bool {
+	length, ok := pr.slotLengths[block.Key()]
+	if !ok {
+		return false
+	}
+	_, ok = pr.rows[pendingRowKey{block: block.Key(), slot: offset / length}]
+	return ok
+}
+
+// len returns the number of pending rows.
+func (pr *pendingRows) len() int {
+	return len(pr.rows)
+}
+
+// take returns the pending rows, ordered by block and offset, and forgets them.
+func (pr *pendingRows) take() []*pendingRow {
+	rows := slices.Collect(maps.Values(pr.rows))
+	slices.SortFunc(rows, func(a, b *pendingRow) int {
+		return cmp.Or(cmp.Compare(a.block.Filename(), b.block.Filename()),
+			cmp.Compare(a.block.Number(), b.block.Number()), cmp.Compare(a.offset, b.offset))
+	})
+	pr.clear()
+	return rows
+}
+
+// clear forgets the pending rows.
+func (pr *pendingRows) clear() {
+	pr.rows, pr.slotLengths = nil, nil
+}
+
+// removeFile forgets the pending rows of the specified file.
+func (pr *pendingRows) removeFile(filename string) {
+	maps.DeleteFunc(pr.rows, func(_ pendingRowKey, row *pendingRow) bool {
+		return row.block.Filename() == filename
+	})
+	maps.DeleteFunc(pr.slotLengths, func(block file.BlockId, _ int) bool {
+		return block.Filename() == filename
+	})
+}
+
+// InsertRow marks a slot of the specified block as holding a new record, by writing the specified flag
+// as the first int of the slot, which spans the specified number of bytes from the specified offset.
+//
+// Rather than one update record per field of the new record, the insertion is logged with two SetRow
+// records: one written now, holding the image of the slot before the insertion, so that undoing it restores
+// the slot, and one written when the transaction commits, holding the image of the slot with the values its
+// fields were given, so that a follower replaying the log inserts the same record. Until then, the writes
+// within the slot are not logged. They need not be, since undoing the first record discards them.
+func (tx *Transaction) InsertRow(block *file.BlockId, offset, length, flag int) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
+	if err := tx.concurrencyManager.XLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	contents := buff.Contents().Contents()
+	if offset < 0 || length < types.IntSize || offset+length > len(contents) {
+		return fmt.Errorf("slot [%d, %d) is outside of block %s of %d bytes", offset, offset+length, block, len(contents))
+	}
+
+	image := make([]byte, length)
+	copy(image, contents[offset:offset+length])
+
+	lsn, err := tx.recoverManager.SetRow(buff, offset, length, image, nil)
+	if err != nil {
+		return err
+	}
+	buff.Contents().SetInt(offset, flag)
+	buff.SetModified(tx.txNum, lsn)
+
+	tx.pendingRows.add(block, offset, length)
+	if tx.pendingRows.len() >= maxPendingRows {
+		return tx.logPendingRows()
+	}
+	return nil
+}
+
+// inPendingRow returns true if the specified offset of the specified block lies within
+// a row inserted by the transaction whose field writes are not logged yet.
+func (tx *Transaction) inPendingRow(block *file.BlockId, offset int) bool {
+	return tx.pendingRows.contains(block, offset)
+}
+
+// logPendingRows logs the images of the rows inserted by the transaction whose field writes are
+// not logged yet, so that the log holds their values. The later writes to the rows are logged.
+func (tx *Transaction) logPendingRows() error {
+	for _, row := range tx.pendingRows.take() {
+		if err := tx.logPendingRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logPendingRow logs the image of an inserted row.
+func (tx *Transaction) logPendingRow(row *pendingRow) error {
+	block := &row.block
+	if err := tx.Pin(block); err != nil {
+		return err
+	}
+	defer tx.Unpin(block)
+
+	buff := tx.myBuffers.GetBuffer(block)
+	newImage := buff.Contents().Contents()[row.offset : row.offset+row.length]
+	lsn, err := tx.recoverManager.SetRow(buff, row.offset, row.length, nil, newImage)
+	if err != nil {
+		return err
+	}
+	buff.SetModified(tx.txNum, lsn)
+	return nil
+}
+
+// setRawBytes writes the specified bytes at the specified offset of the specified block, without logging them.
+// It is used to undo and redo SetRow records.
+func (tx *Transaction) setRawBytes(block *file.BlockId, offset int, b []byte) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
+	if err := tx.concurrencyManager.XLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	contents := buff.Contents().Contents()
+	if offset < 0 || offset+len(b) > len(contents) {
+		return fmt.Errorf("bytes [%d, %d) are outside of block %s of %d bytes", offset, offset+len(b), block, len(contents))
+	}
+	copy(contents[offset:], b)
+	buff.SetModified(tx.txNum, -1)
+	return nil
+}
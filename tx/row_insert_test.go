@@ -0,0 +1,37 @@
+package tx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/JyotinderSingh/dropdb/file"
+)
+
+func TestPendingRows(t *testing.T) {
+	var rows pendingRows
+	first, second, other := file.NewBlockId("t.tbl", 0), file.NewBlockId("t.tbl", 1), file.NewBlockId("u.tbl", 0)
+	rows.add(second, 48, 24)
+	rows.add(first, 24, 24)
+	rows.add(first, 24, 24)
+	rows.add(other, 0, 40)
+	assert.Equal(t, 3, rows.len())
+
+	// A write lies within a pending row if it is in the slot of the row.
+	assert.True(t, rows.contains(first, 24))
+	assert.True(t, rows.contains(first, 47))
+	assert.False(t, rows.contains(first, 23))
+	assert.False(t, rows.contains(first, 48))
+	assert.True(t, rows.contains(second, 52))
+	assert.True(t, rows.contains(other, 39))
+	assert.False(t, rows.contains(file.NewBlockId("t.tbl", 2), 24))
+
+	rows.removeFile("u.tbl")
+	assert.False(t, rows.contains(other, 0))
+	assert.Equal(t, []*pendingRow{
+		{block: *first, offset: 24, length: 24},
+		{block: *second, offset: 48, length: 24},
+	}, rows.take())
+	assert.Zero(t, rows.len())
+	assert.False(t, rows.contains(first, 24))
+}
@@ -9,10 +9,11 @@ import (
 
 type SetBoolRecord struct {
 	LogRecord
-	txNum  int
-	offset int
-	value  bool
-	block  *file.BlockId
+	txNum    int
+	offset   int
+	value    bool
+	newValue bool
+	block    *file.BlockId
 }
 
 func NewSetBoolRecord(page *file.Page) (*SetBoolRecord, error) {
@@ -26,7 +27,7 @@ func NewSetBoolRecord(page *file.Page) (*SetBoolRecord, error) {
 		return nil, err
 	}
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := page.GetInt(blockNumPos)
 	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
 
@@ -36,7 +37,10 @@ func NewSetBoolRecord(page *file.Page) (*SetBoolRecord, error) {
 	valuePos := offsetPos + types.IntSize
 	val := page.GetBool(valuePos)
 
-	return &SetBoolRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+	newValuePos := valuePos + 1
+	newValue := page.GetBool(newValuePos)
+
+	return &SetBoolRecord{txNum: txNum, offset: offset, value: val, newValue: newValue, block: block}, nil
 }
 
 func (r *SetBoolRecord) Op() LogRecordType {
@@ -59,20 +63,29 @@ func (r *SetBoolRecord) Undo(tx *Transaction) error {
 	return tx.SetBool(r.block, r.offset, r.value, false)
 }
 
-func WriteSetBoolToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, val bool) (int, error) {
+func (r *SetBoolRecord) Redo(tx *Transaction) error {
+	if err := pinForRedo(tx, r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetBool(r.block, r.offset, r.newValue, false)
+}
+
+func WriteSetBoolToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, oldVal, newVal bool) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + types.IntSize
 	fileNamePos := txNumPos + types.IntSize
 	fileName := block.Filename()
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := block.Number()
 
 	offsetPos := blockNumPos + types.IntSize
 	valuePos := offsetPos + types.IntSize
 
 	// 1 byte for bool
-	recordLen := valuePos + 1
+	newValuePos := valuePos + 1
+	recordLen := newValuePos + 1
 
 	recordBytes := make([]byte, recordLen)
 	page := file.NewPageFromBytes(recordBytes)
@@ -84,7 +97,8 @@ func WriteSetBoolToLog(logManager *log.Manager, txNum int, block *file.BlockId,
 	}
 	page.SetInt(blockNumPos, blockNum)
 	page.SetInt(offsetPos, offset)
-	page.SetBool(valuePos, val)
+	page.SetBool(valuePos, oldVal)
+	page.SetBool(newValuePos, newVal)
 
 	return logManager.Append(recordBytes)
 }
@@ -11,10 +11,11 @@ import (
 
 type SetDateRecord struct {
 	LogRecord
-	txNum  int
-	offset int
-	value  time.Time
-	block  *file.BlockId
+	txNum    int
+	offset   int
+	value    time.Time
+	newValue time.Time
+	block    *file.BlockId
 }
 
 func NewSetDateRecord(page *file.Page) (*SetDateRecord, error) {
@@ -28,7 +29,7 @@ func NewSetDateRecord(page *file.Page) (*SetDateRecord, error) {
 		return nil, err
 	}
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := page.GetInt(blockNumPos)
 	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
 
@@ -38,7 +39,10 @@ func NewSetDateRecord(page *file.Page) (*SetDateRecord, error) {
 	valuePos := offsetPos + types.IntSize
 	val := page.GetDate(valuePos)
 
-	return &SetDateRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+	newValuePos := valuePos + 8
+	newValue := page.GetDate(newValuePos)
+
+	return &SetDateRecord{txNum: txNum, offset: offset, value: val, newValue: newValue, block: block}, nil
 }
 
 func (r *SetDateRecord) Op() LogRecordType {
@@ -61,19 +65,28 @@ func (r *SetDateRecord) Undo(tx *Transaction) error {
 	return tx.SetDate(r.block, r.offset, r.value, false)
 }
 
-func WriteSetDateToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, val time.Time) (int, error) {
+func (r *SetDateRecord) Redo(tx *Transaction) error {
+	if err := pinForRedo(tx, r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetDate(r.block, r.offset, r.newValue, false)
+}
+
+func WriteSetDateToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, oldVal, newVal time.Time) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + types.IntSize
 	fileNamePos := txNumPos + types.IntSize
 	fileName := block.Filename()
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := block.Number()
 
 	offsetPos := blockNumPos + types.IntSize
 	valuePos := offsetPos + types.IntSize
 	// time.Time stored as int64 (8 bytes)
-	recordLen := valuePos + 8
+	newValuePos := valuePos + 8
+	recordLen := newValuePos + 8
 
 	recordBytes := make([]byte, recordLen)
 	page := file.NewPageFromBytes(recordBytes)
@@ -85,7 +98,8 @@ func WriteSetDateToLog(logManager *log.Manager, txNum int, block *file.BlockId,
 	}
 	page.SetInt(blockNumPos, blockNum)
 	page.SetInt(offsetPos, offset)
-	page.SetDate(valuePos, val)
+	page.SetDate(valuePos, oldVal)
+	page.SetDate(newValuePos, newVal)
 
 	return logManager.Append(recordBytes)
 }
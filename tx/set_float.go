@@ -0,0 +1,103 @@
+package tx
+
+import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+type SetFloatRecord struct {
+	LogRecord
+	txNum    int
+	offset   int
+	value    float64
+	newValue float64
+	block    *file.BlockId
+}
+
+func NewSetFloatRecord(page *file.Page) (*SetFloatRecord, error) {
+	operationPos := 0
+	txNumPos := operationPos + types.IntSize
+	txNum := page.GetInt(txNumPos)
+
+	fileNamePos := txNumPos + types.IntSize
+	fileName, err := page.GetString(fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
+	blockNum := page.GetInt(blockNumPos)
+	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
+
+	offsetPos := blockNumPos + types.IntSize
+	offset := page.GetInt(offsetPos)
+
+	valuePos := offsetPos + types.IntSize
+	val := page.GetFloat(valuePos) // 8 bytes
+
+	newValuePos := valuePos + 8
+	newValue := page.GetFloat(newValuePos)
+
+	return &SetFloatRecord{txNum: txNum, offset: offset, value: val, newValue: newValue, block: block}, nil
+}
+
+func (r *SetFloatRecord) Op() LogRecordType {
+	return SetFloat
+}
+
+func (r *SetFloatRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *SetFloatRecord) String() string {
+	return fmt.Sprintf("<SETFLOAT %d %s %d %g>", r.txNum, r.block, r.offset, r.value)
+}
+
+func (r *SetFloatRecord) Undo(tx *Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetFloat(r.block, r.offset, r.value, false)
+}
+
+func (r *SetFloatRecord) Redo(tx *Transaction) error {
+	if err := pinForRedo(tx, r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetFloat(r.block, r.offset, r.newValue, false)
+}
+
+func WriteSetFloatToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, oldVal, newVal float64) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + types.IntSize
+	fileNamePos := txNumPos + types.IntSize
+	fileName := block.Filename()
+
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
+	blockNum := block.Number()
+
+	offsetPos := blockNumPos + types.IntSize
+	valuePos := offsetPos + types.IntSize
+	// float64 is 8 bytes
+	newValuePos := valuePos + 8
+	recordLen := newValuePos + 8
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(SetFloat))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blockNumPos, blockNum)
+	page.SetInt(offsetPos, offset)
+	page.SetFloat(valuePos, oldVal)
+	page.SetFloat(newValuePos, newVal)
+
+	return logManager.Append(recordBytes)
+}
@@ -9,10 +9,11 @@ import (
 
 type SetIntRecord struct {
 	LogRecord
-	txNum  int
-	offset int
-	value  int
-	block  *file.BlockId
+	txNum    int
+	offset   int
+	value    int
+	newValue int
+	block    *file.BlockId
 }
 
 // NewSetIntRecord creates a new SetIntRecord from a Page.
@@ -27,7 +28,7 @@ func NewSetIntRecord(page *file.Page) (*SetIntRecord, error) {
 		return nil, err
 	}
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := page.GetInt(blockNumPos)
 	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
 
@@ -37,7 +38,10 @@ func NewSetIntRecord(page *file.Page) (*SetIntRecord, error) {
 	valuePos := offsetPos + types.IntSize
 	value := page.GetInt(valuePos)
 
-	return &SetIntRecord{txNum: txNum, offset: offset, value: value, block: block}, nil
+	newValuePos := valuePos + types.IntSize
+	newValue := page.GetInt(newValuePos)
+
+	return &SetIntRecord{txNum: txNum, offset: offset, value: value, newValue: newValue, block: block}, nil
 }
 
 // Op returns the type of the log record.
@@ -67,22 +71,33 @@ func (r *SetIntRecord) Undo(tx *Transaction) error {
 	return tx.SetInt(r.block, r.offset, r.value, false)
 }
 
+// Redo writes the new value saved in the log record to the specified block,
+// appending blocks to the file if it does not contain the block yet.
+func (r *SetIntRecord) Redo(tx *Transaction) error {
+	if err := pinForRedo(tx, r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetInt(r.block, r.offset, r.newValue, false)
+}
+
 // WriteSetIntToLog writes a SetInt record to the log. The record contains the specified transaction number, the
-// filename and block number of the block containing the int, the offset of the int in the block, and the new value
-// of the int.
+// filename and block number of the block containing the int, the offset of the int in the block, and the old and new
+// values of the int.
 // The method returns the LSN of the new log record.
-func WriteSetIntToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset, val int) (int, error) {
+func WriteSetIntToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset, oldVal, newVal int) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + types.IntSize
 	fileNamePos := txNumPos + types.IntSize
 	fileName := block.Filename()
 
-	blockNumPos := fileNamePos + file.MaxLength(len(block.File))
+	blockNumPos := fileNamePos + file.EncodedLength(block.File)
 	blockNum := block.Number()
 
 	offsetPos := blockNumPos + types.IntSize
 	valuePos := offsetPos + types.IntSize
-	recordLen := valuePos + types.IntSize
+	newValuePos := valuePos + types.IntSize
+	recordLen := newValuePos + types.IntSize
 
 	recordBytes := make([]byte, recordLen)
 	page := file.NewPageFromBytes(recordBytes)
@@ -94,7 +109,8 @@ func WriteSetIntToLog(logManager *log.Manager, txNum int, block *file.BlockId, o
 	}
 	page.SetInt(blockNumPos, blockNum)
 	page.SetInt(offsetPos, offset)
-	page.SetInt(valuePos, val)
+	page.SetInt(valuePos, oldVal)
+	page.SetInt(newValuePos, newVal)
 
 	return logManager.Append(recordBytes)
 }
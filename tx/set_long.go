@@ -9,10 +9,11 @@ import (
 
 type SetLongRecord struct {
 	LogRecord
-	txNum  int
-	offset int
-	value  int64
-	block  *file.BlockId
+	txNum    int
+	offset   int
+	value    int64
+	newValue int64
+	block    *file.BlockId
 }
 
 func NewSetLongRecord(page *file.Page) (*SetLongRecord, error) {
@@ -26,7 +27,7 @@ func NewSetLongRecord(page *file.Page) (*SetLongRecord, error) {
 		return nil, err
 	}
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := page.GetInt(blockNumPos)
 	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
 
@@ -36,7 +37,10 @@ func NewSetLongRecord(page *file.Page) (*SetLongRecord, error) {
 	valuePos := offsetPos + types.IntSize
 	val := page.GetLong(valuePos) // 8 bytes long
 
-	return &SetLongRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+	newValuePos := valuePos + 8
+	newValue := page.GetLong(newValuePos)
+
+	return &SetLongRecord{txNum: txNum, offset: offset, value: val, newValue: newValue, block: block}, nil
 }
 
 func (r *SetLongRecord) Op() LogRecordType {
@@ -59,19 +63,28 @@ func (r *SetLongRecord) Undo(tx *Transaction) error {
 	return tx.SetLong(r.block, r.offset, r.value, false)
 }
 
-func WriteSetLongToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, val int64) (int, error) {
+func (r *SetLongRecord) Redo(tx *Transaction) error {
+	if err := pinForRedo(tx, r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetLong(r.block, r.offset, r.newValue, false)
+}
+
+func WriteSetLongToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, oldVal, newVal int64) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + types.IntSize
 	fileNamePos := txNumPos + types.IntSize
 	fileName := block.Filename()
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := block.Number()
 
 	offsetPos := blockNumPos + types.IntSize
 	valuePos := offsetPos + types.IntSize
 	// int64 is 8 bytes
-	recordLen := valuePos + 8
+	newValuePos := valuePos + 8
+	recordLen := newValuePos + 8
 
 	recordBytes := make([]byte, recordLen)
 	page := file.NewPageFromBytes(recordBytes)
@@ -83,7 +96,8 @@ func WriteSetLongToLog(logManager *log.Manager, txNum int, block *file.BlockId,
 	}
 	page.SetInt(blockNumPos, blockNum)
 	page.SetInt(offsetPos, offset)
-	page.SetLong(valuePos, val)
+	page.SetLong(valuePos, oldVal)
+	page.SetLong(newValuePos, newVal)
 
 	return logManager.Append(recordBytes)
 }
@@ -0,0 +1,220 @@
+package tx
+
+import (
+	"fmt"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+// rowImageGap is the number of zero bytes that separate two segments of a stored row image. Shorter runs of zeros
+// are stored within a segment, since storing another segment takes two ints.
+const rowImageGap = 8
+
+// SetRowRecord is a log record holding an image of a whole record slot. Inserting a record writes two of them,
+// instead of one update record per field (see Transaction#InsertRow): one when the slot is marked used, holding
+// the old image of the slot, which undoing the insertion restores, and one when the transaction commits, holding
+// the new image of the slot with the values of its fields, which a follower replaying the log writes.
+// Each record only holds one of the images, and does nothing to undo or redo the other.
+// The images are stored without their runs of zero bytes, so that the image of a slot formatted by Page#Format
+// takes little space.
+type SetRowRecord struct {
+	LogRecord
+	txNum    int
+	offset   int
+	length   int
+	image    []byte
+	newImage []byte
+	block    *file.BlockId
+}
+
+// NewSetRowRecord creates a new SetRowRecord from a Page.
+func NewSetRowRecord(page *file.Page) (*SetRowRecord, error) {
+	operationPos := 0
+	txNumPos := operationPos + types.IntSize
+	txNum := page.GetInt(txNumPos)
+
+	fileNamePos := txNumPos + types.IntSize
+	fileName, err := page.GetString(fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
+	blockNum := page.GetInt(blockNumPos)
+	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
+
+	offsetPos := blockNumPos + types.IntSize
+	offset := page.GetInt(offsetPos)
+
+	lengthPos := offsetPos + types.IntSize
+	length := page.GetInt(lengthPos)
+
+	imagePos := lengthPos + types.IntSize
+	image, newImagePos, err := readRowImage(page, imagePos, length)
+	if err != nil {
+		return nil, err
+	}
+	newImage, _, err := readRowImage(page, newImagePos, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetRowRecord{txNum: txNum, offset: offset, length: length, image: image, newImage: newImage, block: block}, nil
+}
+
+// Op returns the type of the log record.
+func (r *SetRowRecord) Op() LogRecordType {
+	return SetRow
+}
+
+// TxNumber returns the transaction number stored in the log record.
+func (r *SetRowRecord) TxNumber() int {
+	return r.txNum
+}
+
+// String returns a string representation of the log record.
+func (r *SetRowRecord) String() string {
+	return fmt.Sprintf("<SETROW %d %s %d %d>", r.txNum, r.block, r.offset, r.length)
+}
+
+// Undo restores the old image of the slot, if the record holds it.
+// The method pins a buffer to the specified block, writes the image, and unpins the buffer.
+func (r *SetRowRecord) Undo(tx *Transaction) error {
+	if r.image == nil {
+		return nil
+	}
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.setRawBytes(r.block, r.offset, r.image)
+}
+
+// Redo writes the new image of the slot to the specified block, if the record holds it,
+// appending blocks to the file if it does not contain the block yet.
+func (r *SetRowRecord) Redo(tx *Transaction) error {
+	if r.newImage == nil {
+		return nil
+	}
+	if err := pinForRedo(tx, r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.setRawBytes(r.block, r.offset, r.newImage)
+}
+
+// WriteSetRowToLog writes a SetRow record to the log. The record contains the specified transaction number, the
+// filename and block number of the block containing the slot, the offset and length of the slot in the block, and
+// the old and new images of the slot, either of which may be nil if the record does not hold it.
+// The method returns the LSN of the new log record.
+func WriteSetRowToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset, length int, oldImage, newImage []byte) (int, error) {
+	for _, image := range [][]byte{oldImage, newImage} {
+		if image != nil && len(image) != length {
+			return -1, fmt.Errorf("row image of %d bytes does not match its slot of %d bytes", len(image), length)
+		}
+	}
+	oldSegments, newSegments := rowImageSegments(oldImage), rowImageSegments(newImage)
+
+	operationPos := 0
+	txNumPos := operationPos + types.IntSize
+	fileNamePos := txNumPos + types.IntSize
+	fileName := block.Filename()
+
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
+	blockNum := block.Number()
+
+	offsetPos := blockNumPos + types.IntSize
+	lengthPos := offsetPos + types.IntSize
+	imagePos := lengthPos + types.IntSize
+	newImagePos := imagePos + rowImageSize(oldSegments)
+	recordLen := newImagePos + rowImageSize(newSegments)
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(SetRow))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blockNumPos, blockNum)
+	page.SetInt(offsetPos, offset)
+	page.SetInt(lengthPos, length)
+	writeRowImage(page, imagePos, oldImage, oldSegments)
+	writeRowImage(page, newImagePos, newImage, newSegments)
+
+	return logManager.Append(recordBytes)
+}
+
+// rowImageSegment is a run of the bytes of a row image that holds no long run of zero bytes.
+type rowImageSegment struct {
+	start, end int
+}
+
+// rowImageSegments returns the segments of the image that must be stored, in order.
+// The bytes outside of the segments are zero. A nil image has no segments.
+func rowImageSegments(image []byte) []rowImageSegment {
+	var segments []rowImageSegment
+	for i := 0; i < len(image); i++ {
+		if image[i] == 0 {
+			continue
+		}
+		if n := len(segments); n > 0 && i-segments[n-1].end < rowImageGap {
+			segments[n-1].end = i + 1
+		} else {
+			segments = append(segments, rowImageSegment{start: i, end: i + 1})
+		}
+	}
+	return segments
+}
+
+// rowImageSize returns the number of bytes taken by an image stored as the specified segments:
+// the number of segments, followed by the offset and the bytes of each segment.
+func rowImageSize(segments []rowImageSegment) int {
+	size := types.IntSize
+	for _, segment := range segments {
+		size += 2*types.IntSize + segment.end - segment.start
+	}
+	return size
+}
+
+// writeRowImage stores the segments of the image at the specified position of the page.
+// A nil image is stored as a count of -1.
+func writeRowImage(page *file.Page, pos int, image []byte, segments []rowImageSegment) {
+	if image == nil {
+		page.SetInt(pos, -1)
+		return
+	}
+	page.SetInt(pos, len(segments))
+	pos += types.IntSize
+	for _, segment := range segments {
+		page.SetInt(pos, segment.start)
+		page.SetBytes(pos+types.IntSize, image[segment.start:segment.end])
+		pos += 2*types.IntSize + segment.end - segment.start
+	}
+}
+
+// readRowImage reads the image of a slot of the specified length stored at the specified position of the page.
+// It returns the image, or nil if none is stored, and the position following it.
+func readRowImage(page *file.Page, pos, length int) ([]byte, int, error) {
+	count := page.GetInt(pos)
+	pos += types.IntSize
+	if count < 0 {
+		return nil, pos, nil
+	}
+	image := make([]byte, length)
+	for i := 0; i < count; i++ {
+		start := page.GetInt(pos)
+		segment, err := page.GetBytes(pos + types.IntSize)
+		if err != nil {
+			return nil, pos, err
+		}
+		if start < 0 || start+len(segment) > length {
+			return nil, pos, fmt.Errorf("row image segment [%d, %d) is outside of its slot of %d bytes", start, start+len(segment), length)
+		}
+		copy(image[start:], segment)
+		pos += 2*types.IntSize + len(segment)
+	}
+	return image, pos, nil
+}
@@ -9,10 +9,11 @@ import (
 
 type SetShortRecord struct {
 	LogRecord
-	txNum  int
-	offset int
-	value  int16
-	block  *file.BlockId
+	txNum    int
+	offset   int
+	value    int16
+	newValue int16
+	block    *file.BlockId
 }
 
 func NewSetShortRecord(page *file.Page) (*SetShortRecord, error) {
@@ -26,7 +27,7 @@ func NewSetShortRecord(page *file.Page) (*SetShortRecord, error) {
 		return nil, err
 	}
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := page.GetInt(blockNumPos)
 	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
 
@@ -36,7 +37,10 @@ func NewSetShortRecord(page *file.Page) (*SetShortRecord, error) {
 	valuePos := offsetPos + types.IntSize
 	val := page.GetShort(valuePos)
 
-	return &SetShortRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+	newValuePos := valuePos + 2
+	newValue := page.GetShort(newValuePos)
+
+	return &SetShortRecord{txNum: txNum, offset: offset, value: val, newValue: newValue, block: block}, nil
 }
 
 func (r *SetShortRecord) Op() LogRecordType {
@@ -59,19 +63,28 @@ func (r *SetShortRecord) Undo(tx *Transaction) error {
 	return tx.SetShort(r.block, r.offset, r.value, false)
 }
 
-func WriteSetShortToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, val int16) (int, error) {
+func (r *SetShortRecord) Redo(tx *Transaction) error {
+	if err := pinForRedo(tx, r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetShort(r.block, r.offset, r.newValue, false)
+}
+
+func WriteSetShortToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, oldVal, newVal int16) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + types.IntSize
 	fileNamePos := txNumPos + types.IntSize
 	fileName := block.Filename()
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := block.Number()
 
 	offsetPos := blockNumPos + types.IntSize
 	valuePos := offsetPos + types.IntSize
 	// int16 is 2 bytes
-	recordLen := valuePos + 2
+	newValuePos := valuePos + 2
+	recordLen := newValuePos + 2
 
 	recordBytes := make([]byte, recordLen)
 	page := file.NewPageFromBytes(recordBytes)
@@ -83,7 +96,8 @@ func WriteSetShortToLog(logManager *log.Manager, txNum int, block *file.BlockId,
 	}
 	page.SetInt(blockNumPos, blockNum)
 	page.SetInt(offsetPos, offset)
-	page.SetShort(valuePos, val)
+	page.SetShort(valuePos, oldVal)
+	page.SetShort(newValuePos, newVal)
 
 	return logManager.Append(recordBytes)
 }
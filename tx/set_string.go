@@ -9,10 +9,11 @@ import (
 
 type SetStringRecord struct {
 	LogRecord
-	txNum  int
-	offset int
-	value  string
-	block  *file.BlockId
+	txNum    int
+	offset   int
+	value    string
+	newValue string
+	block    *file.BlockId
 }
 
 // NewSetStringRecord creates a new SetStringRecord from a Page.
@@ -27,7 +28,7 @@ func NewSetStringRecord(page *file.Page) (*SetStringRecord, error) {
 		return nil, err
 	}
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := page.GetInt(blockNumPos)
 	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
 
@@ -40,7 +41,13 @@ func NewSetStringRecord(page *file.Page) (*SetStringRecord, error) {
 		return nil, err
 	}
 
-	return &SetStringRecord{txNum: txNum, offset: offset, value: value, block: block}, nil
+	newValuePos := valuePos + file.EncodedLength(value)
+	newValue, err := page.GetString(newValuePos)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetStringRecord{txNum: txNum, offset: offset, value: value, newValue: newValue, block: block}, nil
 }
 
 // Op returns the type of the log record.
@@ -69,22 +76,33 @@ func (r *SetStringRecord) Undo(tx *Transaction) error {
 	return tx.SetString(r.block, r.offset, r.value, false) // Don't log the undo
 }
 
+// Redo writes the new value saved in the log record to the specified block,
+// appending blocks to the file if it does not contain the block yet.
+func (r *SetStringRecord) Redo(tx *Transaction) error {
+	if err := pinForRedo(tx, r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetString(r.block, r.offset, r.newValue, false)
+}
+
 // WriteSetStringToLog writes a set string record to the log. The record contains the specified transaction number, the
-// filename and block number of the block containing the string, the offset of the string in the block, and the new value
-// of the string.
+// filename and block number of the block containing the string, the offset of the string in the block, and the old and
+// new values of the string.
 // The method returns the LSN of the new log record.
-func WriteSetStringToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, value string) (int, error) {
+func WriteSetStringToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, oldVal, newVal string) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + types.IntSize
 	fileNamePos := txNumPos + types.IntSize
 	fileName := block.Filename()
 
-	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNumPos := fileNamePos + file.EncodedLength(fileName)
 	blockNum := block.Number()
 
 	offsetPos := blockNumPos + types.IntSize
 	valuePos := offsetPos + types.IntSize
-	recordLen := valuePos + file.MaxLength(len(value))
+	newValuePos := valuePos + file.EncodedLength(oldVal)
+	recordLen := newValuePos + file.EncodedLength(newVal)
 
 	recordBytes := make([]byte, recordLen)
 	page := file.NewPageFromBytes(recordBytes)
@@ -96,7 +114,10 @@ func WriteSetStringToLog(logManager *log.Manager, txNum int, block *file.BlockId
 	}
 	page.SetInt(blockNumPos, blockNum)
 	page.SetInt(offsetPos, offset)
-	if err := page.SetString(valuePos, value); err != nil {
+	if err := page.SetString(valuePos, oldVal); err != nil {
+		return -1, err
+	}
+	if err := page.SetString(newValuePos, newVal); err != nil {
 		return -1, err
 	}
 
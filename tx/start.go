@@ -36,20 +36,25 @@ func (r *StartRecord) Undo(_ *Transaction) error {
 	return nil
 }
 
+// Redo does nothing. StartRecord does not change any data.
+func (r *StartRecord) Redo(_ *Transaction) error {
+	return nil
+}
+
 // String returns a string representation of the log record.
 func (r *StartRecord) String() string {
 	return fmt.Sprintf("<START %d>", r.txNum)
 }
 
 // WriteStartToLog writes a start record to the log. This log record contains the Start operator,
-// followed by the transaction id.
+// followed by the transaction id. The transaction is active until its commit or rollback record is written.
 // The method returns the LSN of the new log record.
 func WriteStartToLog(logManager *log.Manager, txNum int) (int, error) {
 	record := make([]byte, 2*types.IntSize)
 
 	page := file.NewPageFromBytes(record)
 	page.SetInt(0, int(Start))
-	page.SetInt(4, txNum)
+	page.SetInt(types.IntSize, txNum)
 
-	return logManager.Append(record)
+	return logManager.AppendStart(txNum, record)
 }
@@ -1,30 +1,24 @@
 package tx
 
 import (
+	"errors"
 	"fmt"
 	"github.com/JyotinderSingh/dropdb/buffer"
 	"github.com/JyotinderSingh/dropdb/file"
 	"github.com/JyotinderSingh/dropdb/log"
 	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
 	"math"
-	"sync"
 	"time"
 )
 
 const EndOfFile = -1
 
-var (
-	nextTxNum   = 0
-	nextTxNumMu sync.Mutex
-)
+// WholeFile is the block number of the dummy block used to lock an entire file.
+const WholeFile = -2
 
-// nextTxNumber increments and returns the next transaction number.
-func nextTxNumber() int {
-	nextTxNumMu.Lock()
-	defer nextTxNumMu.Unlock()
-	nextTxNum++
-	return nextTxNum
-}
+// ErrReadOnly is returned when a read-only transaction attempts to modify the database.
+var ErrReadOnly = errors.New("transaction is read-only")
 
 type Transaction struct {
 	recoverManager     *RecoveryManager
@@ -33,6 +27,11 @@ type Transaction struct {
 	fileManager        *file.Manager
 	txNum              int
 	myBuffers          *BufferList
+	readOnly           bool
+	phantomProtection  PhantomProtection
+	callbacks          lifecycleCallbacks
+	deadline           time.Time
+	pendingRows        pendingRows
 }
 
 // NewTransaction creates a new Transaction and its associated recovery and concurrency managers.
@@ -41,27 +40,49 @@ type Transaction struct {
 // the DropDB#Init or DropDB#InitFileLogAndBufferManager methods are called.
 func NewTransaction(fileManager *file.Manager, logManager *log.Manager, bufferManager *buffer.Manager, lockTable *concurrency.LockTable) *Transaction {
 	tx := &Transaction{
-		fileManager:        fileManager,
-		bufferManager:      bufferManager,
-		txNum:              nextTxNumber(),
-		concurrencyManager: concurrency.NewManager(lockTable),
-		myBuffers:          NewBufferList(bufferManager),
+		fileManager:   fileManager,
+		bufferManager: bufferManager,
+		txNum:         logManager.NextTxNumber(),
+		myBuffers:     NewBufferList(bufferManager),
 	}
+	tx.concurrencyManager = concurrency.NewManager(lockTable, tx.txNum)
 	tx.recoverManager = NewRecoveryManager(tx, tx.txNum, logManager, bufferManager)
+	// The buffers are unpinned before the other callbacks run, so that they can remove the files of the transaction.
+	tx.OnEnd(tx.myBuffers.UnpinAll)
+	return tx
+}
+
+// NewReadOnlyTransaction creates a new Transaction that can read the database but not modify it.
+// Every method that writes a value or appends a block returns ErrReadOnly.
+func NewReadOnlyTransaction(fileManager *file.Manager, logManager *log.Manager, bufferManager *buffer.Manager, lockTable *concurrency.LockTable) *Transaction {
+	tx := NewTransaction(fileManager, logManager, bufferManager, lockTable)
+	tx.readOnly = true
 	return tx
 }
 
 // Commit commits the current transaction.
+// Calls the functions registered with BeforeCommit,
+// Logs the images of the rows it inserted (see InsertRow),
 // Flushes all modified buffers (and their log records),
 // Writes and flushes a commit record to the log,
-// Releases all the locks, and unpins any pinned buffers.
+// Unpins any pinned buffers and calls the callbacks registered for the commit, and releases all the locks.
 func (tx *Transaction) Commit() error {
-	if err := tx.recoverManager.Commit(); err != nil {
+	tx.ClearDeadline()
+	if err := tx.callbacks.runBeforeCommit(); err != nil {
 		return err
 	}
+	// A read-only transaction has no changes to flush, so it needs no commit record.
+	if !tx.readOnly {
+		if err := tx.logPendingRows(); err != nil {
+			return err
+		}
+		if err := tx.recoverManager.Commit(); err != nil {
+			return err
+		}
+	}
 	fmt.Printf("Transaction %d committed\n", tx.txNum)
+	tx.callbacks.run(tx.txNum, endCommit)
 	tx.concurrencyManager.Release()
-	tx.myBuffers.UnpinAll()
 	return nil
 }
 
@@ -69,14 +90,20 @@ func (tx *Transaction) Commit() error {
 // Undoes any modified values,
 // Flushes those buffers,
 // Writes and flushes a rollback record to the log,
-// Releases all the locks, and unpins any pinned buffers.
+// Unpins any pinned buffers and calls the callbacks registered for the rollback, and releases all the locks.
 func (tx *Transaction) Rollback() error {
-	if err := tx.recoverManager.Rollback(); err != nil {
-		return err
+	// Rolling back must not be cut short by the deadline of the statement that is aborted.
+	tx.ClearDeadline()
+	// The rows inserted by the transaction are removed by undoing their insertion, so their images need not be logged.
+	tx.pendingRows.clear()
+	if !tx.readOnly {
+		if err := tx.recoverManager.Rollback(); err != nil {
+			return err
+		}
 	}
 	fmt.Printf("Transaction %d rolled back\n", tx.txNum)
+	tx.callbacks.run(tx.txNum, endRollback)
 	tx.concurrencyManager.Release()
-	tx.myBuffers.UnpinAll()
 	return nil
 }
 
@@ -95,16 +122,47 @@ func (tx *Transaction) Recover() error {
 
 // Pin pins the specified block.
 // The transaction manages the buffer for the client.
+// It fails with ErrQueryTimeout if the deadline of the transaction has passed.
 func (tx *Transaction) Pin(block *file.BlockId) error {
+	if err := tx.CheckDeadline(); err != nil {
+		return err
+	}
 	return tx.myBuffers.Pin(block)
 }
 
+// PinSequential pins the specified block like Pin, as part of a scan that moves forward
+// through the file one block at a time, which lets the buffer manager prefetch the following blocks.
+func (tx *Transaction) PinSequential(block *file.BlockId) error {
+	if err := tx.CheckDeadline(); err != nil {
+		return err
+	}
+	return tx.myBuffers.PinSequential(block)
+}
+
 // Unpin unpins the specified block.
 // The transaction looks up the buffer pinned to this block, and unpins it.
 func (tx *Transaction) Unpin(block *file.BlockId) {
 	tx.myBuffers.Unpin(block)
 }
 
+// PinnedCount returns the number of pins the transaction holds. A block pinned several times counts once per pin,
+// so the count can exceed the number of blocks returned by PinnedBlocks. It is zero once the transaction ends.
+func (tx *Transaction) PinnedCount() int {
+	return tx.myBuffers.PinnedCount()
+}
+
+// PinnedBlocks returns the blocks the transaction has pinned, with the number of pins it holds on each.
+func (tx *Transaction) PinnedBlocks() map[file.BlockId]int {
+	return tx.myBuffers.PinnedBlocks()
+}
+
+// SetPinHook sets the function called when the number of pins the transaction holds exceeds the specified
+// threshold, so that the application can log the transaction, or abort it by returning an error, which
+// fails the pin (see BufferList#SetPinHook).
+func (tx *Transaction) SetPinHook(threshold int, fn func(pins int) error) {
+	tx.myBuffers.SetPinHook(threshold, fn)
+}
+
 // GetInt returns the integer value stored at the specified offset of the specified block.
 // The method first obtains an SLock on the block,
 // then it calls the buffer to retrieve the value.
@@ -116,6 +174,9 @@ func (tx *Transaction) GetInt(block *file.BlockId, offset int) (int, error) {
 	if buff == nil {
 		return math.MinInt, fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, types.IntSize); err != nil {
+		return math.MinInt, err
+	}
 	return buff.Contents().GetInt(offset), nil
 }
 
@@ -130,7 +191,12 @@ func (tx *Transaction) GetString(block *file.BlockId, offset int) (string, error
 	if buff == nil {
 		return "", fmt.Errorf("buffer for block %s not found", block)
 	}
-	return buff.Contents().GetString(offset)
+	val, err := buff.Contents().GetString(offset)
+	var corrupt *file.CorruptValueError
+	if errors.As(err, &corrupt) {
+		corrupt.Block = block
+	}
+	return val, err
 }
 
 // SetInt stores an integer at the specified offset of the specified block.
@@ -140,6 +206,9 @@ func (tx *Transaction) GetString(block *file.BlockId, offset int) (string, error
 // Finally, it calls the buffer to store the value,
 // passing in the LSN of the log record and the transaction's ID.
 func (tx *Transaction) SetInt(block *file.BlockId, offset int, val int, logIt bool) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
 	var err error
 	if err = tx.concurrencyManager.XLock(block); err != nil {
 		return err
@@ -148,9 +217,12 @@ func (tx *Transaction) SetInt(block *file.BlockId, offset int, val int, logIt bo
 	if buff == nil {
 		return fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, types.IntSize); err != nil {
+		return err
+	}
 
 	lsn := -1
-	if logIt {
+	if logIt && !tx.inPendingRow(block, offset) {
 		if lsn, err = tx.recoverManager.SetInt(buff, offset, val); err != nil {
 			return err
 		}
@@ -169,6 +241,9 @@ func (tx *Transaction) SetInt(block *file.BlockId, offset int, val int, logIt bo
 // Finally, it calls the buffer to store the value,
 // passing in the LSN of the log record and the transaction's ID.
 func (tx *Transaction) SetString(block *file.BlockId, offset int, val string, logIt bool) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
 	var err error
 	if err = tx.concurrencyManager.XLock(block); err != nil {
 		return err
@@ -177,9 +252,12 @@ func (tx *Transaction) SetString(block *file.BlockId, offset int, val string, lo
 	if buff == nil {
 		return fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, types.IntSize+len(val)); err != nil {
+		return err
+	}
 
 	lsn := -1
-	if logIt {
+	if logIt && !tx.inPendingRow(block, offset) {
 		if lsn, err = tx.recoverManager.SetString(buff, offset, val); err != nil {
 			return err
 		}
@@ -203,12 +281,18 @@ func (tx *Transaction) GetBool(block *file.BlockId, offset int) (bool, error) {
 	if buff == nil {
 		return false, fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, 1); err != nil {
+		return false, err
+	}
 	return buff.Contents().GetBool(offset), nil
 }
 
 // SetBool stores a boolean value at the specified offset of the specified block.
 // The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
 func (tx *Transaction) SetBool(block *file.BlockId, offset int, val bool, logIt bool) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
 	if err := tx.concurrencyManager.XLock(block); err != nil {
 		return err
 	}
@@ -216,9 +300,12 @@ func (tx *Transaction) SetBool(block *file.BlockId, offset int, val bool, logIt
 	if buff == nil {
 		return fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, 1); err != nil {
+		return err
+	}
 
 	lsn := -1
-	if logIt {
+	if logIt && !tx.inPendingRow(block, offset) {
 		var err error
 		if lsn, err = tx.recoverManager.SetBool(buff, offset, val); err != nil {
 			return err
@@ -241,12 +328,18 @@ func (tx *Transaction) GetLong(block *file.BlockId, offset int) (int64, error) {
 	if buff == nil {
 		return 0, fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, 8); err != nil {
+		return 0, err
+	}
 	return buff.Contents().GetLong(offset), nil
 }
 
 // SetLong stores an int64 value at the specified offset of the specified block.
 // The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
 func (tx *Transaction) SetLong(block *file.BlockId, offset int, val int64, logIt bool) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
 	if err := tx.concurrencyManager.XLock(block); err != nil {
 		return err
 	}
@@ -254,9 +347,12 @@ func (tx *Transaction) SetLong(block *file.BlockId, offset int, val int64, logIt
 	if buff == nil {
 		return fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, 8); err != nil {
+		return err
+	}
 
 	lsn := -1
-	if logIt {
+	if logIt && !tx.inPendingRow(block, offset) {
 		var err error
 		if lsn, err = tx.recoverManager.SetLong(buff, offset, val); err != nil {
 			return err
@@ -279,12 +375,18 @@ func (tx *Transaction) GetShort(block *file.BlockId, offset int) (int16, error)
 	if buff == nil {
 		return 0, fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, 2); err != nil {
+		return 0, err
+	}
 	return buff.Contents().GetShort(offset), nil
 }
 
 // SetShort stores an int16 value at the specified offset of the specified block.
 // The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
 func (tx *Transaction) SetShort(block *file.BlockId, offset int, val int16, logIt bool) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
 	if err := tx.concurrencyManager.XLock(block); err != nil {
 		return err
 	}
@@ -292,9 +394,12 @@ func (tx *Transaction) SetShort(block *file.BlockId, offset int, val int16, logI
 	if buff == nil {
 		return fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, 2); err != nil {
+		return err
+	}
 
 	lsn := -1
-	if logIt {
+	if logIt && !tx.inPendingRow(block, offset) {
 		var err error
 		if lsn, err = tx.recoverManager.SetShort(buff, offset, val); err != nil {
 			return err
@@ -307,6 +412,53 @@ func (tx *Transaction) SetShort(block *file.BlockId, offset int, val int16, logI
 	return nil
 }
 
+// GetFloat returns the float64 value stored at the specified offset of the specified block.
+// The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
+func (tx *Transaction) GetFloat(block *file.BlockId, offset int) (float64, error) {
+	if err := tx.concurrencyManager.SLock(block); err != nil {
+		return 0, err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return 0, fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err := checkBounds(buff, block, offset, 8); err != nil {
+		return 0, err
+	}
+	return buff.Contents().GetFloat(offset), nil
+}
+
+// SetFloat stores an float64 value at the specified offset of the specified block.
+// The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
+func (tx *Transaction) SetFloat(block *file.BlockId, offset int, val float64, logIt bool) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
+	if err := tx.concurrencyManager.XLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err := checkBounds(buff, block, offset, 8); err != nil {
+		return err
+	}
+
+	lsn := -1
+	if logIt && !tx.inPendingRow(block, offset) {
+		var err error
+		if lsn, err = tx.recoverManager.SetFloat(buff, offset, val); err != nil {
+			return err
+		}
+	}
+
+	page := buff.Contents()
+	page.SetFloat(offset, val)
+	buff.SetModified(tx.txNum, lsn)
+	return nil
+}
+
 // GetDate returns the time.Time value stored at the specified offset of the specified block.
 // The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
 func (tx *Transaction) GetDate(block *file.BlockId, offset int) (time.Time, error) {
@@ -317,12 +469,47 @@ func (tx *Transaction) GetDate(block *file.BlockId, offset int) (time.Time, erro
 	if buff == nil {
 		return time.Time{}, fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, 8); err != nil {
+		return time.Time{}, err
+	}
 	return buff.Contents().GetDate(offset), nil
 }
 
+// GetRawBytes returns a copy of the specified number of bytes stored at the specified offset of the specified block,
+// without interpreting them.
+func (tx *Transaction) GetRawBytes(block *file.BlockId, offset, length int) ([]byte, error) {
+	if err := tx.concurrencyManager.SLock(block); err != nil {
+		return nil, err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return nil, fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err := checkBounds(buff, block, offset, length); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, length)
+	copy(raw, buff.Contents().Contents()[offset:offset+length])
+	return raw, nil
+}
+
+// checkBounds returns an error if the specified number of bytes at the specified offset do not lie within the block
+// held by the buffer, so that a value that does not fit, such as the field of a slot overrunning a block whose size
+// is not a multiple of the slot size, is reported rather than read or written past the end of the page.
+func checkBounds(buff *buffer.Buffer, block *file.BlockId, offset, width int) error {
+	size := len(buff.Contents().Contents())
+	if offset < 0 || width < 0 || offset+width > size {
+		return fmt.Errorf("bytes [%d, %d) are outside of block %s of %d bytes", offset, offset+width, block, size)
+	}
+	return nil
+}
+
 // SetDate stores a time.Time value at the specified offset of the specified block.
 // The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
 func (tx *Transaction) SetDate(block *file.BlockId, offset int, val time.Time, logIt bool) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
 	if err := tx.concurrencyManager.XLock(block); err != nil {
 		return err
 	}
@@ -330,9 +517,12 @@ func (tx *Transaction) SetDate(block *file.BlockId, offset int, val time.Time, l
 	if buff == nil {
 		return fmt.Errorf("buffer for block %s not found", block)
 	}
+	if err := checkBounds(buff, block, offset, 8); err != nil {
+		return err
+	}
 
 	lsn := -1
-	if logIt {
+	if logIt && !tx.inPendingRow(block, offset) {
 		var err error
 		if lsn, err = tx.recoverManager.SetDate(buff, offset, val); err != nil {
 			return err
@@ -350,8 +540,17 @@ func (tx *Transaction) SetDate(block *file.BlockId, offset int, val time.Time, l
 // before asking the file manager to return the file size.
 // This is necessary to prevent another transaction from adding a block to the file
 // while this transaction is counting the blocks and causing phantom reads.
+// Under RelaxedPhantomProtection, the SLock is released as soon as the blocks are counted,
+// unless the transaction already held a lock on the marker.
 func (tx *Transaction) Size(filename string) (int, error) {
 	dummyBlock := file.NewBlockId(filename, EndOfFile)
+	if tx.phantomProtection == RelaxedPhantomProtection && !tx.concurrencyManager.HasLock(dummyBlock) {
+		if err := tx.concurrencyManager.SLock(dummyBlock); err != nil {
+			return -1, err
+		}
+		defer tx.concurrencyManager.Unlock(dummyBlock)
+		return tx.fileManager.Length(filename)
+	}
 	if err := tx.concurrencyManager.SLock(dummyBlock); err != nil {
 		return -1, err
 	}
@@ -363,6 +562,9 @@ func (tx *Transaction) Size(filename string) (int, error) {
 // This is necessary to prevent another transaction from reading the size of the file while this append is in progress.
 // This helps prevent phantom reads.
 func (tx *Transaction) Append(filename string) (*file.BlockId, error) {
+	if tx.readOnly {
+		return nil, ErrReadOnly
+	}
 	dummyBlock := file.NewBlockId(filename, EndOfFile)
 	if err := tx.concurrencyManager.XLock(dummyBlock); err != nil {
 		return nil, err
@@ -370,6 +572,57 @@ func (tx *Transaction) Append(filename string) (*file.BlockId, error) {
 	return tx.fileManager.Append(filename)
 }
 
+// EnableCompression makes the specified file store its blocks compressed (see file.Manager#EnableCompression).
+// The file must not have any block yet. This method first obtains an XLock on the "end of file" marker,
+// so that no other transaction appends a block to the file meanwhile.
+// Compression is not undone if the transaction rolls back, which leaves an empty compressed file.
+func (tx *Transaction) EnableCompression(filename string) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
+	dummyBlock := file.NewBlockId(filename, EndOfFile)
+	if err := tx.concurrencyManager.XLock(dummyBlock); err != nil {
+		return err
+	}
+	return tx.fileManager.EnableCompression(filename)
+}
+
+// RemoveFile deletes the specified file (see file.Manager#Remove), after discarding its blocks from the
+// buffer pool (see buffer.Manager#DiscardFile). The removal is not logged, so it is not undone if the
+// transaction rolls back; it is meant for the files that no other transaction reads, such as temporary
+// tables, or dropped tables once the transaction dropping them commits. It fails, leaving the file, while
+// a block of the file is pinned, so that no buffer refers to a removed file. The rows inserted into the file
+// whose images are not logged yet (see InsertRow) are forgotten, so that logging them does not recreate it.
+func (tx *Transaction) RemoveFile(filename string) error {
+	if tx.bufferManager.HasPinnedBlocks(filename) {
+		return fmt.Errorf("cannot remove file %s while some of its blocks are pinned", filename)
+	}
+	tx.pendingRows.removeFile(filename)
+	tx.bufferManager.DiscardFile(filename)
+	return tx.fileManager.Remove(filename)
+}
+
+// SLockFile obtains a shared lock on the entire file.
+// Transactions modifying the records of a file hold this lock until they complete,
+// so that they can run concurrently with each other, but not with a transaction
+// that needs the whole file to stay unchanged (see XLockFile).
+func (tx *Transaction) SLockFile(filename string) error {
+	return tx.concurrencyManager.SLock(file.NewBlockId(filename, WholeFile))
+}
+
+// XLockFile obtains an exclusive lock on the entire file.
+// The method waits for every other transaction holding a shared lock on the file to complete,
+// and blocks transactions that request one until this transaction completes.
+func (tx *Transaction) XLockFile(filename string) error {
+	return tx.concurrencyManager.XLock(file.NewBlockId(filename, WholeFile))
+}
+
+// HasFileLock returns true if the transaction holds a lock on the entire file,
+// which it does once it has modified the records of the file (see SLockFile) or locked it (see XLockFile).
+func (tx *Transaction) HasFileLock(filename string) bool {
+	return tx.concurrencyManager.HasLock(file.NewBlockId(filename, WholeFile))
+}
+
 // BlockSize returns the size of a block in the database.
 func (tx *Transaction) BlockSize() int {
 	return tx.fileManager.BlockSize()
@@ -380,6 +633,11 @@ func (tx *Transaction) AvailableBuffers() int {
 	return tx.bufferManager.Available()
 }
 
+// ReadOnly returns true if the transaction cannot modify the database.
+func (tx *Transaction) ReadOnly() bool {
+	return tx.readOnly
+}
+
 // TxNum returns the transaction number.
 func (tx *Transaction) TxNum() int {
 	return tx.txNum
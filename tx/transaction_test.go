@@ -0,0 +1,64 @@
+package tx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JyotinderSingh/dropdb/buffer"
+	"github.com/JyotinderSingh/dropdb/file"
+	"github.com/JyotinderSingh/dropdb/log"
+	"github.com/JyotinderSingh/dropdb/tx"
+	"github.com/JyotinderSingh/dropdb/tx/concurrency"
+	"github.com/JyotinderSingh/dropdb/types"
+)
+
+func TestTransaction_OutOfBlock(t *testing.T) {
+	for _, blockSize := range []int{56, 57} {
+		fm, err := file.NewManager(t.TempDir(), blockSize)
+		require.NoError(t, err)
+		lm, err := log.NewManager(fm, "logfile")
+		require.NoError(t, err)
+		txn := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 4), concurrency.NewLockTable())
+		block, err := txn.Append("data")
+		require.NoError(t, err)
+		require.NoError(t, txn.Pin(block))
+
+		// A value ending at the end of the block fits, while one overrunning it is an error rather than a panic.
+		last := blockSize - types.IntSize
+		require.NoError(t, txn.SetInt(block, last, 7, false))
+		val, err := txn.GetInt(block, last)
+		require.NoError(t, err)
+		assert.Equal(t, 7, val)
+
+		for _, offset := range []int{last + 1, blockSize, -1} {
+			assert.Error(t, txn.SetInt(block, offset, 7, true), "offset %d of a %d-byte block", offset, blockSize)
+			_, err = txn.GetInt(block, offset)
+			assert.Error(t, err, "offset %d of a %d-byte block", offset, blockSize)
+		}
+		assert.Error(t, txn.SetLong(block, blockSize-7, 1, true))
+		_, err = txn.GetLong(block, blockSize-7)
+		assert.Error(t, err)
+		assert.Error(t, txn.SetFloat(block, blockSize-7, 1, true))
+		_, err = txn.GetFloat(block, blockSize-7)
+		assert.Error(t, err)
+		assert.Error(t, txn.SetDate(block, blockSize-7, time.Unix(0, 0), true))
+		_, err = txn.GetDate(block, blockSize-7)
+		assert.Error(t, err)
+		assert.Error(t, txn.SetShort(block, blockSize-1, 1, true))
+		_, err = txn.GetShort(block, blockSize-1)
+		assert.Error(t, err)
+		assert.Error(t, txn.SetBool(block, blockSize, true, true))
+		_, err = txn.GetBool(block, blockSize)
+		assert.Error(t, err)
+		assert.Error(t, txn.SetString(block, blockSize-types.IntSize-2, "abc", true))
+		require.NoError(t, txn.SetString(block, blockSize-types.IntSize-3, "abc", false))
+		s, err := txn.GetString(block, blockSize-types.IntSize-3)
+		require.NoError(t, err)
+		assert.Equal(t, "abc", s)
+
+		require.NoError(t, txn.Commit())
+	}
+}
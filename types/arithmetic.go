@@ -0,0 +1,151 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrDivisionByZero is returned when a number is divided by zero.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// ArithmeticOperator is the operator of an arithmetic expression.
+type ArithmeticOperator int
+
+const (
+	// ADD is the addition operator.
+	ADD ArithmeticOperator = iota
+	// SUB is the subtraction operator.
+	SUB
+	// MUL is the multiplication operator.
+	MUL
+	// DIV is the division operator, which truncates the quotient of integers.
+	DIV
+)
+
+// String returns the string representation of the ArithmeticOperator.
+func (op ArithmeticOperator) String() string {
+	switch op {
+	case ADD:
+		return "+"
+	case SUB:
+		return "-"
+	case MUL:
+		return "*"
+	case DIV:
+		return "/"
+	default:
+		return ""
+	}
+}
+
+// Precedence returns how tightly the operator binds its operands: multiplication and division
+// bind tighter than addition and subtraction.
+func (op ArithmeticOperator) Precedence() int {
+	if op == MUL || op == DIV {
+		return 2
+	}
+	return 1
+}
+
+// ArithmeticResultType returns the type of the result of an arithmetic operation on values of the specified types,
+// which is a float if either of them is a float, and otherwise the widest of both integer types.
+// It returns false if either type is not numeric.
+func ArithmeticResultType(lhs, rhs SchemaType) (SchemaType, bool) {
+	if !IsNumericType(lhs) || !IsNumericType(rhs) {
+		return 0, false
+	}
+	for _, fieldType := range []SchemaType{Float, Long, Integer} {
+		if lhs == fieldType || rhs == fieldType {
+			return fieldType, true
+		}
+	}
+	return Short, true
+}
+
+// Calculate applies the arithmetic operator to the numbers, whose result has the type given by ArithmeticResultType.
+// Dividing by zero results in an error wrapping ErrDivisionByZero, an integer result that does not fit its type in an
+// error wrapping ErrValueOutOfRange, and an operand that is not a number in an error wrapping ErrIncompatibleValue.
+func Calculate(lhs, rhs any, op ArithmeticOperator) (any, error) {
+	lhsType, lhsOk := TypeOf(lhs)
+	rhsType, rhsOk := TypeOf(rhs)
+	resultType, ok := ArithmeticResultType(lhsType, rhsType)
+	if !lhsOk || !rhsOk || !ok {
+		return nil, fmt.Errorf("%w: cannot calculate %v %s %v", ErrIncompatibleValue, lhs, op, rhs)
+	}
+
+	if resultType == Float {
+		a, b := toFloat64(lhs), toFloat64(rhs)
+		switch op {
+		case ADD:
+			return a + b, nil
+		case SUB:
+			return a - b, nil
+		case MUL:
+			return a * b, nil
+		default:
+			if b == 0 {
+				return nil, fmt.Errorf("%w: %v / %v", ErrDivisionByZero, lhs, rhs)
+			}
+			return a / b, nil
+		}
+	}
+
+	a, _ := toInt64(lhs)
+	b, _ := toInt64(rhs)
+	result, err := calculateInt64s(a, b, op)
+	if err != nil {
+		return nil, err
+	}
+	switch resultType {
+	case Integer:
+		if result >= math.MinInt && result <= math.MaxInt {
+			return int(result), nil
+		}
+	case Short:
+		if result >= math.MinInt16 && result <= math.MaxInt16 {
+			return int16(result), nil
+		}
+	default:
+		return result, nil
+	}
+	return nil, fmt.Errorf("%w: %v %s %v does not fit type %s", ErrValueOutOfRange, lhs, op, rhs, resultType)
+}
+
+// calculateInt64s applies the arithmetic operator to the integers, checking that the result fits an int64.
+func calculateInt64s(a, b int64, op ArithmeticOperator) (int64, error) {
+	var result int64
+	overflow := false
+	switch op {
+	case ADD:
+		result = a + b
+		overflow = (b > 0 && result < a) || (b < 0 && result > a)
+	case SUB:
+		result = a - b
+		overflow = (b > 0 && result > a) || (b < 0 && result < a)
+	case MUL:
+		result = a * b
+		overflow = a != 0 && (result/a != b || (a == -1 && b == math.MinInt64))
+	case DIV:
+		if b == 0 {
+			return 0, fmt.Errorf("%w: %d / %d", ErrDivisionByZero, a, b)
+		}
+		overflow = a == math.MinInt64 && b == -1
+		if !overflow {
+			result = a / b
+		}
+	}
+	if overflow {
+		return 0, fmt.Errorf("%w: %d %s %d overflows type long", ErrValueOutOfRange, a, op, b)
+	}
+	return result, nil
+}
+
+// toFloat64 returns the number as a float64.
+func toFloat64(val any) float64 {
+	if f, ok := val.(float64); ok {
+		return f
+	}
+	n, _ := toInt64(val)
+	return float64(n)
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name     string
+		lhs      any
+		rhs      any
+		op       ArithmeticOperator
+		expected any
+	}{
+		{"int addition", 40, 2, ADD, 42},
+		{"int subtraction", 40, 50, SUB, -10},
+		{"int multiplication", 6, 7, MUL, 42},
+		{"int division truncates", 7, 2, DIV, 3},
+		{"negative division truncates", -7, 2, DIV, -3},
+		{"short and short", int16(2), int16(3), MUL, int16(6)},
+		{"short and int", int16(2), 3, ADD, 5},
+		{"long and int", int64(2), 3, SUB, int64(-1)},
+		{"float and int", 1.5, 2, MUL, 3.0},
+		{"float division", 7.0, 2, DIV, 3.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := Calculate(tt.lhs, tt.rhs, tt.op)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, val)
+		})
+	}
+}
+
+func TestCalculate_Errors(t *testing.T) {
+	_, err := Calculate(1, 0, DIV)
+	assert.ErrorIs(t, err, ErrDivisionByZero)
+	_, err = Calculate(1.5, 0, DIV)
+	assert.ErrorIs(t, err, ErrDivisionByZero)
+
+	_, err = Calculate(int64(math.MaxInt64), 1, ADD)
+	assert.ErrorIs(t, err, ErrValueOutOfRange)
+	_, err = Calculate(int64(math.MinInt64), 1, SUB)
+	assert.ErrorIs(t, err, ErrValueOutOfRange)
+	_, err = Calculate(int64(math.MaxInt64/2+1), 2, MUL)
+	assert.ErrorIs(t, err, ErrValueOutOfRange)
+	_, err = Calculate(int64(math.MinInt64), -1, DIV)
+	assert.ErrorIs(t, err, ErrValueOutOfRange)
+	_, err = Calculate(int16(math.MaxInt16), int16(1), ADD)
+	assert.ErrorIs(t, err, ErrValueOutOfRange, "the sum of shorts is a short")
+
+	_, err = Calculate("a", 1, ADD)
+	assert.ErrorIs(t, err, ErrIncompatibleValue)
+	_, err = Calculate(true, false, MUL)
+	assert.ErrorIs(t, err, ErrIncompatibleValue)
+}
+
+func TestArithmeticResultType(t *testing.T) {
+	for _, tt := range []struct{ lhs, rhs, expected SchemaType }{
+		{Short, Short, Short},
+		{Short, Integer, Integer},
+		{Integer, Long, Long},
+		{Long, Float, Float},
+	} {
+		resultType, ok := ArithmeticResultType(tt.lhs, tt.rhs)
+		assert.True(t, ok)
+		assert.Equal(t, tt.expected, resultType)
+	}
+	_, ok := ArithmeticResultType(Integer, Varchar)
+	assert.False(t, ok)
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrIncompatibleValue is returned when a value cannot be converted to the type of a field.
+var ErrIncompatibleValue = errors.New("incompatible value")
+
+// ErrValueOutOfRange is returned when an integer does not fit the type of a field,
+// or when an arithmetic result does not fit the type that holds it.
+var ErrValueOutOfRange = errors.New("value out of range")
+
+// ErrValueTooLong is returned when a string is longer than the declared length of a varchar field,
+// or does not fit in the bytes reserved for the field.
+var ErrValueTooLong = errors.New("value too long")
+
+// dateLayouts are the canonical formats accepted for date strings.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+}
+
+// CoerceValue converts the value to the Go representation used by the database
+// for fields of the specified type (see IsValueOfType). The following conversions are made:
+//   - integers of any size to int, int64 (long) or int16 (short), checking that the value fits;
+//   - integers of any size to float64 (float);
+//   - strings in the "YYYY-MM-DD" or "YYYY-MM-DD HH:MM:SS" formats to dates;
+//   - 0 and 1, or the strings "true" and "false", to booleans.
+//
+// Values that already have the right representation are returned unchanged.
+// An integer that does not fit the type results in an error wrapping ErrValueOutOfRange,
+// and any other value in an error wrapping ErrIncompatibleValue.
+func CoerceValue(val any, fieldType SchemaType) (any, error) {
+	if IsValueOfType(val, fieldType) {
+		return val, nil
+	}
+
+	switch fieldType {
+	case Integer:
+		if n, ok := toInt64(val); ok && n >= math.MinInt && n <= math.MaxInt {
+			return int(n), nil
+		}
+	case Long:
+		if n, ok := toInt64(val); ok {
+			return n, nil
+		}
+	case Short:
+		if n, ok := toInt64(val); ok && n >= math.MinInt16 && n <= math.MaxInt16 {
+			return int16(n), nil
+		}
+	case Float:
+		if n, ok := toInt64(val); ok {
+			return float64(n), nil
+		}
+	case Boolean:
+		if n, ok := toInt64(val); ok && (n == 0 || n == 1) {
+			return n == 1, nil
+		}
+		if s, ok := val.(string); ok {
+			switch strings.ToLower(s) {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+		}
+	case Date:
+		if s, ok := val.(string); ok {
+			for _, layout := range dateLayouts {
+				if t, err := time.Parse(layout, s); err == nil {
+					return t, nil
+				}
+			}
+		}
+	}
+
+	if _, isInt := toInt64(val); isInt && (fieldType == Integer || fieldType == Short) {
+		return nil, fmt.Errorf("%w: %v does not fit type %s", ErrValueOutOfRange, val, fieldType)
+	}
+	return nil, fmt.Errorf("%w: cannot convert %T value %v to type %s", ErrIncompatibleValue, val, val, fieldType)
+}
+
+// toInt64 returns the value as an int64 if it is an integer of any size.
+func toInt64(val any) (int64, bool) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int16:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
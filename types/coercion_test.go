@@ -0,0 +1,95 @@
+package types
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		val       any
+		fieldType SchemaType
+		expected  any
+	}{
+		{"int unchanged", 42, Integer, 42},
+		{"long to int", int64(42), Integer, 42},
+		{"short to int", int16(42), Integer, 42},
+		{"int to long", 42, Long, int64(42)},
+		{"short to long", int16(-7), Long, int64(-7)},
+		{"int to short", 42, Short, int16(42)},
+		{"largest short", math.MaxInt16, Short, int16(math.MaxInt16)},
+		{"smallest short", math.MinInt16, Short, int16(math.MinInt16)},
+		{"float unchanged", 19.99, Float, 19.99},
+		{"int to float", 42, Float, 42.0},
+		{"long to float", int64(-7), Float, -7.0},
+		{"string unchanged", "abc", Varchar, "abc"},
+		{"date string", "2024-03-15", Date, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"date time string", "2024-03-15 10:30:00", Date, time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)},
+		{"zero to bool", 0, Boolean, false},
+		{"one to bool", 1, Boolean, true},
+		{"true string", "TRUE", Boolean, true},
+		{"false string", "false", Boolean, false},
+		{"bool unchanged", true, Boolean, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := CoerceValue(tt.val, tt.fieldType)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, val)
+		})
+	}
+}
+
+func TestCoerceValue_Rejections(t *testing.T) {
+	tests := []struct {
+		name      string
+		val       any
+		fieldType SchemaType
+		message   string
+	}{
+		{"string to int", "42", Integer, "cannot convert string value 42 to type int"},
+		{"int to string", 42, Varchar, "cannot convert int value 42 to type varchar"},
+		{"malformed date", "15/03/2024", Date, "cannot convert string value 15/03/2024 to type date"},
+		{"int to date", 20240315, Date, "cannot convert int value 20240315 to type date"},
+		{"two to bool", 2, Boolean, "cannot convert int value 2 to type bool"},
+		{"word to bool", "yes", Boolean, "cannot convert string value yes to type bool"},
+		{"bool to int", true, Integer, "cannot convert bool value true to type int"},
+		{"float to int", 1.5, Integer, "cannot convert float64 value 1.5 to type int"},
+		{"string to float", "1.5", Float, "cannot convert string value 1.5 to type float"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CoerceValue(tt.val, tt.fieldType)
+			assert.ErrorIs(t, err, ErrIncompatibleValue)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+}
+
+func TestCoerceValue_OutOfRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		val       any
+		fieldType SchemaType
+		message   string
+	}{
+		{"short overflow", math.MaxInt16 + 1, Short, "value out of range: 32768 does not fit type short"},
+		{"short underflow", int64(math.MinInt16 - 1), Short, "value out of range: -32769 does not fit type short"},
+		{"long to short", int64(math.MaxInt64), Short, "value out of range: 9223372036854775807 does not fit type short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CoerceValue(tt.val, tt.fieldType)
+			assert.ErrorIs(t, err, ErrValueOutOfRange)
+			assert.EqualError(t, err, tt.message)
+		})
+	}
+}
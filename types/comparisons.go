@@ -22,7 +22,14 @@ func CompareSupportedTypes(lhs, rhs any, op Operator) bool {
 		}
 	}
 
-	// If not both integers, switch on types for the other supported comparisons:
+	// Then compare floats with each other, or with integers, as float64.
+	if lhsFloat, lhsIsNumber := toFloat(lhs); lhsIsNumber {
+		if rhsFloat, rhsIsNumber := toFloat(rhs); rhsIsNumber {
+			return compareFloats(lhsFloat, rhsFloat, op)
+		}
+	}
+
+	// If not both numbers, switch on types for the other supported comparisons:
 	switch lhs := lhs.(type) {
 	case string:
 		if rhs, ok := rhs.(string); ok {
@@ -36,8 +43,10 @@ func CompareSupportedTypes(lhs, rhs any, op Operator) bool {
 		if rhs, ok := rhs.(time.Time); ok {
 			return compareTimes(lhs, rhs, op)
 		}
-	// You can still directly handle type == type comparisons if needed
-	// (e.g., if you had float64 or others).
+	case CompositeKey:
+		if rhs, ok := rhs.(CompositeKey); ok {
+			return compareCompositeKeys(lhs, rhs, op)
+		}
 	default:
 		// Log unsupported type for debugging
 		fmt.Printf("Unsupported or mismatched types for comparison: lhs=%T, rhs=%T\n", lhs, rhs)
@@ -62,6 +71,39 @@ func toInt(i any) (int, bool) {
 	}
 }
 
+// toFloat attempts to convert an interface holding a float or an integer to float64.
+// It returns (convertedValue, true) if successful; (0, false) otherwise.
+func toFloat(i any) (float64, bool) {
+	if f, ok := i.(float64); ok {
+		return f, true
+	}
+	if n, ok := toInt(i); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// compareFloats compares two float64 values. Like in SQL, NaN is not equal to any value, itself included.
+func compareFloats(lhs, rhs float64, op Operator) bool {
+	switch op {
+	case NE:
+		return lhs != rhs
+	case EQ:
+		return lhs == rhs
+	case LT:
+		return lhs < rhs
+	case LE:
+		return lhs <= rhs
+	case GT:
+		return lhs > rhs
+	case GE:
+		return lhs >= rhs
+	default:
+		fmt.Printf("unsupported operator: %v\n", op)
+		return false
+	}
+}
+
 // compareInts compares two integers.
 func compareInts(lhs, rhs int, op Operator) bool {
 	switch op {
@@ -140,6 +182,10 @@ func compareStrings(lhs, rhs string, op Operator) bool {
 		return lhs > rhs
 	case GE:
 		return lhs >= rhs
+	case LIKE:
+		return MatchesPattern(lhs, rhs)
+	case NOTLIKE:
+		return !MatchesPattern(lhs, rhs)
 	default:
 		fmt.Printf("unsupported operator: %v\n", op)
 		return false
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompositeKey is the search key of an index on several fields, holding the value of each indexed field,
+// in the order of the fields of the index.
+type CompositeKey []any
+
+// String returns the values of the key, separated by commas and enclosed in parentheses.
+func (ck CompositeKey) String() string {
+	values := make([]string, len(ck))
+	for i, val := range ck {
+		values[i] = fmt.Sprintf("%v", val)
+	}
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+// compareCompositeKeys compares two composite keys field by field: the first field whose values differ
+// orders the keys, and a key whose values are the first values of the other sorts before it.
+// Like the comparison of single values, a comparison involving a null value fails.
+func compareCompositeKeys(lhs, rhs CompositeKey, op Operator) bool {
+	for i := 0; i < min(len(lhs), len(rhs)); i++ {
+		if lhs[i] == nil || rhs[i] == nil {
+			return false
+		}
+		if CompareSupportedTypes(lhs[i], rhs[i], EQ) {
+			continue
+		}
+		// Booleans only support equality comparisons, so false is ordered before true here.
+		lhsBool, lhsIsBool := lhs[i].(bool)
+		if _, rhsIsBool := rhs[i].(bool); lhsIsBool && rhsIsBool {
+			return compareInts(boolOrder(lhsBool), boolOrder(!lhsBool), op)
+		}
+		if CompareSupportedTypes(lhs[i], rhs[i], LT) {
+			return compareInts(-1, 0, op)
+		}
+		return compareInts(1, 0, op)
+	}
+	return compareInts(len(lhs), len(rhs), op)
+}
+
+// boolOrder returns the position of a boolean value in the order of composite keys.
+func boolOrder(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
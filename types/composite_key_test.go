@@ -0,0 +1,34 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSupportedTypes_CompositeKey(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	key := CompositeKey{5, day}
+
+	assert.True(t, CompareSupportedTypes(key, CompositeKey{5, day}, EQ))
+	assert.True(t, CompareSupportedTypes(key, CompositeKey{int64(5), day}, EQ))
+	assert.False(t, CompareSupportedTypes(key, CompositeKey{5, day}, NE))
+
+	// The first field that differs orders the keys.
+	assert.True(t, CompareSupportedTypes(key, CompositeKey{6, day.AddDate(-1, 0, 0)}, LT))
+	assert.True(t, CompareSupportedTypes(key, CompositeKey{5, day.AddDate(0, 0, 1)}, LT))
+	assert.True(t, CompareSupportedTypes(key, CompositeKey{5, day.AddDate(0, 0, -1)}, GT))
+	assert.True(t, CompareSupportedTypes(key, CompositeKey{4, day.AddDate(1, 0, 0)}, GE))
+	assert.True(t, CompareSupportedTypes(CompositeKey{false, 9}, CompositeKey{true, 1}, LT))
+
+	// A prefix of a key sorts before it.
+	assert.True(t, CompareSupportedTypes(CompositeKey{5}, key, LT))
+
+	// Comparisons involving a null value fail.
+	assert.False(t, CompareSupportedTypes(CompositeKey{5, nil}, key, EQ))
+	assert.False(t, CompareSupportedTypes(CompositeKey{5, nil}, key, NE))
+
+	assert.Equal(t, "(5, abc)", CompositeKey{5, "abc"}.String())
+	assert.Equal(t, Hash(CompositeKey{5, "abc"}), Hash(CompositeKey{int64(5), "abc"}))
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"fmt"
+	"time"
+)
+
 type SchemaType int
 
 // JDBC type codes
@@ -9,10 +14,108 @@ const (
 	Boolean SchemaType = 16
 	Long    SchemaType = -5
 	Short   SchemaType = 5
+	Float   SchemaType = 8
 	Date    SchemaType = 91
 )
 
+// String returns the SQL name of the type, as used in CREATE TABLE statements.
+func (t SchemaType) String() string {
+	switch t {
+	case Integer:
+		return "int"
+	case Varchar:
+		return "varchar"
+	case Boolean:
+		return "bool"
+	case Long:
+		return "long"
+	case Short:
+		return "short"
+	case Float:
+		return "float"
+	case Date:
+		return "date"
+	default:
+		return fmt.Sprintf("unknown type %d", int(t))
+	}
+}
+
+// FieldInfo describes a field: its name, its type, and its length if it is a varchar field.
 type FieldInfo struct {
+	Name   string
 	Type   SchemaType
 	Length int
 }
+
+// FindField returns the field with the specified name among the specified fields.
+// It returns false if there is no such field.
+func FindField(fields []FieldInfo, fieldName string) (FieldInfo, bool) {
+	for _, field := range fields {
+		if field.Name == fieldName {
+			return field, true
+		}
+	}
+	return FieldInfo{}, false
+}
+
+// IsValueOfType reports whether the value has the Go representation
+// used by the database for fields of the specified type.
+func IsValueOfType(val any, fieldType SchemaType) bool {
+	switch val.(type) {
+	case int:
+		return fieldType == Integer
+	case string:
+		return fieldType == Varchar
+	case bool:
+		return fieldType == Boolean
+	case int64:
+		return fieldType == Long
+	case int16:
+		return fieldType == Short
+	case float64:
+		return fieldType == Float
+	case time.Time:
+		return fieldType == Date
+	default:
+		return false
+	}
+}
+
+// TypeOf returns the type of the fields represented by values like the specified one (see IsValueOfType).
+// It returns false if the value does not have the representation of any field type.
+func TypeOf(val any) (SchemaType, bool) {
+	switch val.(type) {
+	case int:
+		return Integer, true
+	case string:
+		return Varchar, true
+	case bool:
+		return Boolean, true
+	case int64:
+		return Long, true
+	case int16:
+		return Short, true
+	case float64:
+		return Float, true
+	case time.Time:
+		return Date, true
+	default:
+		return 0, false
+	}
+}
+
+// AreComparable reports whether values of the specified types can be compared with each other,
+// which is the case if the types are the same or both are numeric types.
+func AreComparable(lhs, rhs SchemaType) bool {
+	return lhs == rhs || (IsNumericType(lhs) && IsNumericType(rhs))
+}
+
+// IsNumericType reports whether the type holds numbers, integers or floats.
+func IsNumericType(fieldType SchemaType) bool {
+	return isIntegerType(fieldType) || fieldType == Float
+}
+
+// isIntegerType reports whether the type holds integers.
+func isIntegerType(fieldType SchemaType) bool {
+	return fieldType == Integer || fieldType == Long || fieldType == Short
+}
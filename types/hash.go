@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 func Hash(value any) int {
 	if value == nil {
@@ -14,6 +17,12 @@ func Hash(value any) int {
 		return int(v)
 	case int16:
 		return int(v)
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			// Floats holding integers hash like the integers they are equal to.
+			return int(v)
+		}
+		return int(math.Float64bits(v))
 	case string:
 		hash := 0
 		for _, c := range v {
@@ -28,6 +37,12 @@ func Hash(value any) int {
 		}
 	case time.Time:
 		return int(v.Unix())
+	case CompositeKey:
+		hash := 0
+		for _, val := range v {
+			hash = hash*31 + Hash(val)
+		}
+		return hash
 	default:
 		return 0
 	}
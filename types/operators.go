@@ -19,6 +19,14 @@ const (
 	GT
 	// GE is the greater than or equal Operator.
 	GE
+	// IS is the Operator of "is null", which holds for a null value.
+	IS
+	// ISNOT is the Operator of "is not null", which holds for any value but null.
+	ISNOT
+	// LIKE is the Operator of "like", which holds for a string matching a pattern (see MatchesPattern).
+	LIKE
+	// NOTLIKE is the Operator of "not like", which holds for a string not matching a pattern.
+	NOTLIKE
 )
 
 // String returns the string representation of the Operator.
@@ -36,6 +44,14 @@ func (op Operator) String() string {
 		return ">"
 	case GE:
 		return ">="
+	case IS:
+		return "is"
+	case ISNOT:
+		return "is not"
+	case LIKE:
+		return "like"
+	case NOTLIKE:
+		return "not like"
 	default:
 		return ""
 	}
@@ -0,0 +1,91 @@
+package types
+
+import "strings"
+
+// PatternEscape is the character escaping a wildcard of a pattern, or itself, so that it matches literally.
+const PatternEscape = '\\'
+
+// patternElement is a character of a pattern: a literal character, or one of the wildcards '_' and '%'.
+type patternElement struct {
+	char     rune
+	wildcard bool
+}
+
+// parsePattern splits the pattern into its characters, resolving escapes.
+// An escape at the end of the pattern matches itself.
+func parsePattern(pattern string) []patternElement {
+	runes := []rune(pattern)
+	elements := make([]patternElement, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == PatternEscape && i+1 < len(runes):
+			i++
+			elements = append(elements, patternElement{char: runes[i]})
+		case r == '_' || r == '%':
+			elements = append(elements, patternElement{char: r, wildcard: true})
+		default:
+			elements = append(elements, patternElement{char: r})
+		}
+	}
+	return elements
+}
+
+// MatchesPattern returns true if the value matches the pattern of a LIKE comparison, in which '_' matches any
+// character, '%' matches any sequence of characters, and every other character matches itself, case included.
+// A wildcard preceded by PatternEscape matches itself, as does an escaped PatternEscape.
+func MatchesPattern(value, pattern string) bool {
+	elements := parsePattern(pattern)
+	runes := []rune(value)
+
+	// The last '%' seen, and the position in the value it was last matched up to, to backtrack to on a mismatch.
+	star, starPos := -1, 0
+	e, pos := 0, 0
+	for pos < len(runes) {
+		switch {
+		case e < len(elements) && elements[e].wildcard && elements[e].char == '%':
+			star, starPos = e, pos
+			e++
+		case e < len(elements) && (elements[e].wildcard || elements[e].char == runes[pos]):
+			e++
+			pos++
+		case star >= 0:
+			// Let the last '%' match one more character.
+			starPos++
+			e, pos = star+1, starPos
+		default:
+			return false
+		}
+	}
+	for e < len(elements) && elements[e].wildcard && elements[e].char == '%' {
+		e++
+	}
+	return e == len(elements)
+}
+
+// PatternPrefix returns the literal prefix of the pattern, which every matching value starts with,
+// and whether the pattern has no wildcard, in which case only the prefix itself matches it.
+func PatternPrefix(pattern string) (string, bool) {
+	var prefix strings.Builder
+	for _, element := range parsePattern(pattern) {
+		if element.wildcard {
+			return prefix.String(), false
+		}
+		prefix.WriteRune(element.char)
+	}
+	return prefix.String(), true
+}
+
+// PrefixUpperBound returns the smallest string greater than every string starting with the prefix,
+// or false if there is none, such as for the empty prefix.
+func PrefixUpperBound(prefix string) (string, bool) {
+	bound := []byte(prefix)
+	for len(bound) > 0 {
+		last := len(bound) - 1
+		if bound[last] < 0xFF {
+			bound[last]++
+			return string(bound), true
+		}
+		bound = bound[:last]
+	}
+	return "", false
+}
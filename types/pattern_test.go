@@ -0,0 +1,74 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesPattern(t *testing.T) {
+	tests := []struct {
+		value    string
+		pattern  string
+		expected bool
+	}{
+		{"abc", "abc", true},
+		{"abc", "ABC", false},
+		{"abc", "ab", false},
+		{"abcdef", "abc%", true},
+		{"abc", "abc%", true},
+		{"xabc", "abc%", false},
+		{"xyzabc", "%abc", true},
+		{"abcx", "%abc", false},
+		{"xxabcxx", "%abc%", true},
+		{"", "%", true},
+		{"", "_", false},
+		{"abc", "a_c", true},
+		{"ac", "a_c", false},
+		{"abbc", "a_c", false},
+		{"héllo", "h_llo", true},
+		{"a_c", "a\\_c", true},
+		{"abc", "a\\_c", false},
+		{"100%", "100\\%", true},
+		{"1000", "100\\%", false},
+		{"50% off", "%\\%%", true},
+		{"50 off", "%\\%%", false},
+		{"a\\b", "a\\\\b", true},
+		{"mississippi", "%iss%ppi", true},
+		{"mississippi", "m%s_s%i", true},
+		{"mississippi", "%ss_s", false},
+		{"trailing\\", "trailing\\", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, MatchesPattern(tt.value, tt.pattern), "%q like %q", tt.value, tt.pattern)
+	}
+}
+
+func TestPatternPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		prefix  string
+		exact   bool
+	}{
+		{"abc%", "abc", false},
+		{"ab_d", "ab", false},
+		{"%abc", "", false},
+		{"abc", "abc", true},
+		{"100\\%%", "100%", false},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		prefix, exact := PatternPrefix(tt.pattern)
+		assert.Equal(t, tt.prefix, prefix, tt.pattern)
+		assert.Equal(t, tt.exact, exact, tt.pattern)
+	}
+
+	bound, ok := PrefixUpperBound("abc")
+	assert.True(t, ok)
+	assert.Equal(t, "abd", bound)
+	bound, ok = PrefixUpperBound("a\xff")
+	assert.True(t, ok)
+	assert.Equal(t, "b", bound)
+	_, ok = PrefixUpperBound("")
+	assert.False(t, ok)
+}
@@ -4,7 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"strconv"
 	"time"
+
+	"github.com/JyotinderSingh/dropdb/types"
 )
 
 // HashValue hashes a variety of types using fnv
@@ -27,6 +31,16 @@ func HashValue(val interface{}) (uint32, error) {
 		if err != nil {
 			return 0, fmt.Errorf("failed to hash int64: %w", err)
 		}
+	case float64:
+		// Floats holding integers hash like the integers they are equal to.
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			text = strconv.FormatInt(int64(v), 10)
+		}
+		_, err := h.Write([]byte(text))
+		if err != nil {
+			return 0, fmt.Errorf("failed to hash float64: %w", err)
+		}
 	case string:
 		_, err := h.Write([]byte(v))
 		if err != nil {
@@ -42,6 +56,17 @@ func HashValue(val interface{}) (uint32, error) {
 		if err != nil {
 			return 0, fmt.Errorf("failed to hash time.Time: %w", err)
 		}
+	case types.CompositeKey:
+		// The key hashes like the sequence of the hashes of its values.
+		for _, val := range v {
+			valueHash, err := HashValue(val)
+			if err != nil {
+				return 0, fmt.Errorf("failed to hash composite key: %w", err)
+			}
+			if _, err := fmt.Fprintf(h, "%d,", valueHash); err != nil {
+				return 0, fmt.Errorf("failed to hash composite key: %w", err)
+			}
+		}
 	case nil:
 		return 0, errors.New("cannot hash nil value")
 	default: